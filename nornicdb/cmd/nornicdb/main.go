@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
@@ -10,19 +11,36 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/orneryd/nornicdb/pkg/arrowflight"
 	"github.com/orneryd/nornicdb/pkg/auth"
 	"github.com/orneryd/nornicdb/pkg/bolt"
+	"github.com/orneryd/nornicdb/pkg/bookmark"
 	"github.com/orneryd/nornicdb/pkg/cache"
 	"github.com/orneryd/nornicdb/pkg/config"
+	"github.com/orneryd/nornicdb/pkg/cursor"
+	"github.com/orneryd/nornicdb/pkg/cypher"
+	"github.com/orneryd/nornicdb/pkg/edgesync"
+	"github.com/orneryd/nornicdb/pkg/federation"
 	"github.com/orneryd/nornicdb/pkg/gpu"
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+	"github.com/orneryd/nornicdb/pkg/idempotency"
+	"github.com/orneryd/nornicdb/pkg/livequery"
 	"github.com/orneryd/nornicdb/pkg/nornicdb"
 	"github.com/orneryd/nornicdb/pkg/pool"
+	"github.com/orneryd/nornicdb/pkg/preflight"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/sample"
+	"github.com/orneryd/nornicdb/pkg/secrets"
 	"github.com/orneryd/nornicdb/pkg/server"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/orneryd/nornicdb/pkg/writethrottle"
 	"github.com/orneryd/nornicdb/ui"
 )
 
@@ -127,8 +145,54 @@ Features:
 	serveCmd.Flags().Bool("log-queries", getEnvBool("NORNICDB_LOG_QUERIES", false), "Log all Bolt queries to stdout (for debugging)")
 	// Headless mode
 	serveCmd.Flags().Bool("headless", getEnvBool("NORNICDB_HEADLESS", false), "Disable web UI and browser-related endpoints")
+	// Preflight checks
+	serveCmd.Flags().Bool("skip-preflight", getEnvBool("NORNICDB_SKIP_PREFLIGHT", false), "Start even if preflight checks fail")
 	rootCmd.AddCommand(serveCmd)
 
+	// Check command (configuration and environment preflight)
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Validate configuration, GPU, models and data directory",
+		Long: `Run the preflight checks that serve performs at startup and print a report.
+
+Checks the effective configuration (environment variables and flags), an
+optional YAML config file, GPU backends compiled into this binary, local
+GGUF model files and their quantization, and data directory permissions
+and free disk space. Exits non-zero if any check fails.`,
+		RunE: runCheck,
+	}
+	checkCmd.Flags().String("config", "", "YAML config file to validate (default: <data-dir>/nornicdb.yaml if present)")
+	checkCmd.Flags().String("data-dir", getEnvStr("NORNICDB_DATA_DIR", "./data"), "Data directory")
+	checkCmd.Flags().Int("bolt-port", getEnvInt("NORNICDB_BOLT_PORT", 7687), "Bolt protocol port")
+	checkCmd.Flags().Int("http-port", getEnvInt("NORNICDB_HTTP_PORT", 7474), "HTTP API port")
+	checkCmd.Flags().String("embedding-provider", getEnvStr("NORNICDB_EMBEDDING_PROVIDER", "ollama"), "Embedding provider: local, ollama, openai")
+	checkCmd.Flags().String("embedding-model", getEnvStr("NORNICDB_EMBEDDING_MODEL", "bge-m3"), "Embedding model name")
+	checkCmd.Flags().Int("embedding-dim", getEnvInt("NORNICDB_EMBEDDING_DIMENSIONS", 1024), "Embedding dimensions")
+	checkCmd.Flags().Bool("json", false, "Print the report as JSON")
+	rootCmd.AddCommand(checkCmd)
+
+	// Consistency check command (offline store integrity scan)
+	consistencyCmd := &cobra.Command{
+		Use:   "check-consistency",
+		Short: "Scan the store for dangling relationships, index and count errors, and corruption",
+		Long: `Scan the data directory for corrupt data files and records, relationships
+whose start or end node is missing, label, adjacency and type index entries
+that disagree with the records, and storage counters that have drifted.
+
+Stop the server first: the store is opened directly. With --repair, orphaned
+index entries are removed and missing ones are added; --delete-dangling also
+deletes relationships with a missing endpoint. Corrupt records are only
+reported; restore them from a backup. Exits non-zero if unrepaired issues
+remain.`,
+		RunE: runCheckConsistency,
+	}
+	consistencyCmd.Flags().String("data-dir", getEnvStr("NORNICDB_DATA_DIR", "./data"), "Data directory")
+	consistencyCmd.Flags().Bool("repair", false, "Fix index entries and counters")
+	consistencyCmd.Flags().Bool("delete-dangling", false, "With --repair, delete relationships whose start or end node is missing")
+	consistencyCmd.Flags().Int("max-issues", 1000, "Maximum issues listed in the report (all are counted)")
+	consistencyCmd.Flags().Bool("json", false, "Print the report as JSON")
+	rootCmd.AddCommand(consistencyCmd)
+
 	// Init command
 	initCmd := &cobra.Command{
 		Use:   "init",
@@ -149,6 +213,32 @@ Features:
 	importCmd.Flags().String("embedding-url", "http://localhost:11434", "Embedding API URL")
 	rootCmd.AddCommand(importCmd)
 
+	// Seed command (built-in sample datasets)
+	seedCmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Populate a database with a sample dataset",
+		Long: `Load one of the built-in sample datasets into the data directory:
+
+  movies   the classic movies graph: Person and Movie nodes with ACTED_IN and
+           DIRECTED relationships; --scale N adds 100 generated movies per
+           unit above 1
+  social   a synthetic social network: Person nodes who KNOW each other,
+           POST and LIKE Posts; 1,000 people per unit of --scale
+
+Movies, posts and bios are embedded with a local hashing model so vector
+search works without an embedding service. Use --embeddings=false to leave
+them for the server's model (POST /nornicdb/embed/trigger after starting).
+Stop the server first: the store is opened directly.`,
+		RunE: runSeed,
+	}
+	seedCmd.Flags().String("data-dir", getEnvStr("NORNICDB_DATA_DIR", "./data"), "Data directory")
+	seedCmd.Flags().String("dataset", sample.Movies, "Dataset: "+strings.Join(sample.Names(), ", "))
+	seedCmd.Flags().Int("scale", 1, "Dataset size multiplier")
+	seedCmd.Flags().Int64("seed", 1, "Random seed (the same seed gives the same data)")
+	seedCmd.Flags().Bool("embeddings", true, "Generate embeddings")
+	seedCmd.Flags().Int("embedding-dim", getEnvInt("NORNICDB_EMBEDDING_DIMENSIONS", 1024), "Embedding dimensions")
+	rootCmd.AddCommand(seedCmd)
+
 	// Shell command (interactive Cypher REPL)
 	shellCmd := &cobra.Command{
 		Use:   "shell",
@@ -246,6 +336,13 @@ func runServe(cmd *cobra.Command, args []string) error {
 	// Configure query cache
 	if cfg.Memory.QueryCacheEnabled {
 		cache.ConfigureGlobalCache(cfg.Memory.QueryCacheSize, cfg.Memory.QueryCacheTTL)
+		budgets, err := cache.ParseDatabaseBudgets(cfg.Memory.QueryCacheDatabases)
+		if err != nil {
+			return fmt.Errorf("configuring query cache: %w", err)
+		}
+		for db, size := range budgets {
+			cache.ConfigureDatabaseCache(db, size, cfg.Memory.QueryCacheTTL)
+		}
 	}
 
 	// Display version with commit hash and build timestamp
@@ -302,6 +399,28 @@ func runServe(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Println()
 
+	// Preflight checks: stop with a clear report instead of failing later
+	if skipPreflight, _ := cmd.Flags().GetBool("skip-preflight"); skipPreflight {
+		fmt.Println("⚠️  Preflight checks skipped")
+	} else {
+		fmt.Println("🩺 Running preflight checks...")
+		applyPreflightFlags(cmd, cfg)
+		report := preflight.Run(preflight.Options{
+			Config:     cfg,
+			ConfigFile: defaultConfigFile(dataDir),
+		})
+		problems := report.Problems()
+		for _, c := range problems {
+			preflight.WriteCheck(os.Stdout, c)
+		}
+		if !report.OK {
+			return fmt.Errorf("preflight checks failed (run 'nornicdb check' for the full report, or use --skip-preflight)")
+		}
+		if len(problems) == 0 {
+			fmt.Println("   ✅ All checks passed")
+		}
+	}
+
 	// Create data directory
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return fmt.Errorf("creating data directory: %w", err)
@@ -332,6 +451,60 @@ func runServe(cmd *cobra.Command, args []string) error {
 	}
 	defer db.Close()
 
+	// Storage quotas, applied before any writes are served
+	quota, err := newStorageQuota(cfg.Quota)
+	if err != nil {
+		return fmt.Errorf("configuring storage quotas: %w", err)
+	}
+	if !quota.IsZero() {
+		if err := db.SetStorageQuota(quota); err != nil {
+			return fmt.Errorf("applying storage quotas: %w", err)
+		}
+		fmt.Printf("📏 Storage quotas enabled (%s)\n", quota)
+	}
+
+	// Server-side retries of retryable auto-commit writes on conflict
+	if cfg.WriteRetry.MaxRetries > 0 {
+		db.SetWriteRetryPolicy(cypher.RetryPolicy{
+			MaxRetries:     cfg.WriteRetry.MaxRetries,
+			InitialBackoff: cfg.WriteRetry.InitialBackoff,
+			MaxBackoff:     cfg.WriteRetry.MaxBackoff,
+		})
+		fmt.Printf("🔁 Write conflict retries enabled (up to %d, backoff %v-%v)\n",
+			cfg.WriteRetry.MaxRetries, cfg.WriteRetry.InitialBackoff, cfg.WriteRetry.MaxBackoff)
+	}
+
+	// Property schema registry, loaded before any writes are served
+	if err := configurePropertySchema(db.PropertySchema(), cfg.PropertySchema); err != nil {
+		return fmt.Errorf("configuring property schema: %w", err)
+	}
+
+	// Offline sync: serve edge instances, and sync with a central server
+	var syncReplica *edgesync.Replica
+	if cfg.Sync.ReplicaID != "" {
+		syncReplica, err = db.OpenSyncReplica(cfg.Sync.ReplicaID, nil)
+		if err != nil {
+			return fmt.Errorf("configuring sync: %w", err)
+		}
+		fmt.Printf("🔄 Offline sync enabled (replica %s)\n", cfg.Sync.ReplicaID)
+		if cfg.Sync.PeerURL != "" {
+			syncCtx, stopSync := context.WithCancel(context.Background())
+			defer stopSync()
+			go syncReplica.Run(syncCtx, edgesync.NewHTTPPeer(cfg.Sync.PeerURL, cfg.Sync.PeerToken), cfg.Sync.Interval)
+			fmt.Printf("   → Syncing with %s every %v\n", cfg.Sync.PeerURL, cfg.Sync.Interval)
+		}
+	}
+
+	// Virtual labels resolved from external data sources
+	if cfg.Federation.File != "" {
+		registry := federation.NewRegistry()
+		if err := registry.LoadFile(cfg.Federation.File); err != nil {
+			return fmt.Errorf("configuring federation: %w", err)
+		}
+		db.SetVirtualLabels(registry)
+		fmt.Printf("🔗 Virtual labels enabled (%d from %s)\n", len(registry.Labels()), cfg.Federation.File)
+	}
+
 	// Initialize GPU acceleration (Metal on macOS, auto-detect otherwise)
 	fmt.Println("🎮 Initializing GPU acceleration...")
 	gpuConfig := gpu.DefaultConfig()
@@ -361,6 +534,20 @@ func runServe(cmd *cobra.Command, args []string) error {
 			fmt.Println("   ⚠️  GPU disabled (CPU fallback active)")
 		}
 	}
+	printGPUProbe(gpuManager != nil && gpuManager.IsEnabled())
+
+	// Rank Cypher vector queries on the backend picked by gpu.AutoSelect
+	// (NORNICDB_GPU_BACKEND overrides it), sharing the device fairly
+	db.SetGPUScheduler(gpu.NewScheduler(gpu.SchedulerConfig{
+		TimeSlice:      cfg.GPUScheduler.TimeSlice,
+		ChunkRows:      cfg.GPUScheduler.ChunkRows,
+		MaxQueryVRAMMB: cfg.GPUScheduler.MaxQueryVRAMMB,
+	}))
+	if backend, err := db.EnableGPUVectorSearch(); err != nil {
+		fmt.Printf("   ⚠️  GPU vector search disabled: %v (using CPU)\n", err)
+	} else if backend != gpu.BackendNone {
+		fmt.Printf("   ✅ Vector queries ranked on %s\n", backend)
+	}
 
 	// Load data if specified
 	if loadExport != "" {
@@ -388,7 +575,17 @@ func runServe(cmd *cobra.Command, args []string) error {
 	if !noAuth {
 		fmt.Println("🔐 Setting up authentication...")
 		authConfig := auth.DefaultAuthConfig()
-		authConfig.JWTSecret = []byte("nornicdb-dev-secret") // TODO: Make configurable
+		authConfig.JWTSecret = []byte("nornicdb-dev-secret")
+		// NORNICDB_AUTH_JWT_SECRET may be a literal or a secret reference (env:, file:, vault:)
+		if secretRef := getEnvStr("NORNICDB_AUTH_JWT_SECRET", ""); secretRef != "" {
+			jwtSecret, err := secrets.Resolve(context.Background(), secretRef)
+			if err != nil {
+				return fmt.Errorf("resolving JWT secret: %w", err)
+			}
+			authConfig.JWTSecret = []byte(jwtSecret)
+		} else {
+			fmt.Println("   ⚠️  Using development JWT secret (set NORNICDB_AUTH_JWT_SECRET)")
+		}
 
 		var authErr error
 		authenticator, authErr = auth.NewAuthenticator(authConfig)
@@ -404,10 +601,76 @@ func runServe(cmd *cobra.Command, args []string) error {
 		} else {
 			fmt.Println("   ✅ Admin user created (admin)")
 		}
+
+		// External identity providers (LDAP / OIDC)
+		if err := addIdentityProviders(authenticator, cfg.Auth); err != nil {
+			return fmt.Errorf("configuring identity providers: %w", err)
+		}
+		for _, name := range authenticator.Providers() {
+			fmt.Printf("   ✅ Identity provider: %s\n", name)
+		}
 	} else {
 		fmt.Println("⚠️  Authentication disabled")
 	}
 
+	// Query rate limits shared by HTTP and Bolt
+	queryLimiter, err := newQueryLimiter(cfg.QueryLimits)
+	if err != nil {
+		return fmt.Errorf("configuring query limits: %w", err)
+	}
+	if queryLimiter.Enabled() {
+		fmt.Printf("🚦 Query rate limits enabled (default: %.0f qps, %d concurrent, %d result bytes/s)\n",
+			cfg.QueryLimits.QueriesPerSecond, cfg.QueryLimits.MaxConcurrent, cfg.QueryLimits.ResultBytesPerSecond)
+	}
+
+	// Write backpressure shared by HTTP and Bolt
+	var writeThrottle *writethrottle.Throttle
+	if cfg.WriteThrottle.Enabled {
+		writeThrottle = writethrottle.New(writethrottle.Config{
+			WALLagSoft:     cfg.WriteThrottle.WALLagSoft,
+			WALLagHard:     cfg.WriteThrottle.WALLagHard,
+			MemorySoft:     cfg.WriteThrottle.MemorySoft,
+			MemoryHard:     cfg.WriteThrottle.MemoryHard,
+			EventQueueSoft: cfg.WriteThrottle.EventQueueSoft,
+			EventQueueHard: cfg.WriteThrottle.EventQueueHard,
+			MaxDelay:       cfg.WriteThrottle.MaxDelay,
+		}, server.WriteThrottleSignals(db))
+		fmt.Printf("🐢 Write throttling enabled (WAL lag %v/%v, max delay %v)\n",
+			cfg.WriteThrottle.WALLagSoft, cfg.WriteThrottle.WALLagHard, cfg.WriteThrottle.MaxDelay)
+	}
+
+	// Idempotency keys shared by HTTP and Bolt, so a retry on either is applied once
+	idempotencyStore := idempotency.New(cfg.Idempotency.TTL, cfg.Idempotency.MaxKeys)
+	if idempotencyStore != nil {
+		if err := db.PersistIdempotencyKeys(idempotencyStore); err != nil {
+			return fmt.Errorf("persisting idempotency keys: %w", err)
+		}
+		fmt.Printf("🔁 Idempotency keys enabled (%s)\n", idempotencyStore)
+	}
+
+	// Bookmarks shared by HTTP and Bolt, so a session on either reads the other's commits
+	bookmarks := bookmark.New(cfg.Bookmarks.WaitTimeout)
+
+	// Result cursors shared by HTTP and Arrow Flight
+	cursorStore := cursor.New(cfg.Cursors.TTL, cfg.Cursors.MaxCursors)
+
+	// PII redaction for logs, Heimdall prompts and database events
+	redactor, err := redact.New(redact.Config{
+		Enabled:    cfg.Redaction.Enabled,
+		Properties: cfg.Redaction.Properties,
+		Patterns:   cfg.Redaction.Patterns,
+		Mask:       cfg.Redaction.Mask,
+	})
+	if err != nil {
+		return fmt.Errorf("configuring redaction: %w", err)
+	}
+	if redactor.Enabled() {
+		heimdall.SetRedactor(redactor)
+		db.SetRedactor(redactor)
+		fmt.Printf("🕶️  PII redaction enabled (%d properties, %d patterns)\n",
+			len(cfg.Redaction.Properties), len(cfg.Redaction.Patterns))
+	}
+
 	// Create and start HTTP server
 	serverConfig := server.DefaultConfig()
 	serverConfig.Port = httpPort
@@ -421,7 +684,28 @@ func runServe(cmd *cobra.Command, args []string) error {
 	serverConfig.EmbeddingModel = embeddingModel
 	serverConfig.EmbeddingDimensions = embeddingDim
 	serverConfig.EmbeddingCacheSize = embeddingCache
+	serverConfig.EmbeddingCacheTTL = cfg.Memory.EmbeddingCacheTTL
+	serverConfig.EmbeddingCacheDir = cfg.Memory.EmbeddingCacheDir
 	serverConfig.Headless = headless
+	serverConfig.QueryLimiter = queryLimiter
+	serverConfig.WriteThrottle = writeThrottle
+	serverConfig.Idempotency = idempotencyStore
+	serverConfig.Bookmarks = bookmarks
+	serverConfig.LiveQuery = livequery.Config{
+		Interval:   cfg.LiveQueries.Interval,
+		MaxQueries: cfg.LiveQueries.MaxQueries,
+	}
+	serverConfig.Cursors = cursorStore
+	serverConfig.Sync = syncReplica
+	serverConfig.Redactor = redactor
+	// HTTPS key pair from PEM values or secret references (env:, file:, vault:)
+	if certRef, keyRef := getEnvStr("NORNICDB_HTTP_TLS_CERT", ""), getEnvStr("NORNICDB_HTTP_TLS_KEY", ""); certRef != "" && keyRef != "" {
+		cert, err := secrets.LoadX509KeyPair(context.Background(), certRef, keyRef)
+		if err != nil {
+			return fmt.Errorf("loading HTTPS certificate: %w", err)
+		}
+		serverConfig.TLSCertificate = &cert
+	}
 
 	// Enable embedded UI from the ui package (unless headless mode)
 	if !headless {
@@ -442,10 +726,15 @@ func runServe(cmd *cobra.Command, args []string) error {
 	boltConfig := bolt.DefaultConfig()
 	boltConfig.Port = boltPort
 	boltConfig.LogQueries = logQueries
+	boltConfig.QueryLimiter = queryLimiter
+	boltConfig.WriteThrottle = writeThrottle
+	boltConfig.Idempotency = idempotencyStore
+	boltConfig.Bookmarks = bookmarks
 
 	// Create query executor adapter
 	queryExecutor := &DBQueryExecutor{db: db}
 	boltServer := bolt.New(boltConfig, queryExecutor)
+	httpServer.AddPanicSource("bolt", boltServer.PanicCount)
 
 	// Start Bolt server in goroutine
 	go func() {
@@ -454,6 +743,23 @@ func runServe(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
+	// Start Arrow Flight server for bulk analytical reads and ingestion
+	var flightServer *arrowflight.Server
+	if cfg.Server.FlightEnabled {
+		flightServer = arrowflight.New(db, authenticator, &arrowflight.Config{
+			Port:                 cfg.Server.FlightPort,
+			BatchSize:            cfg.Server.FlightBatchSize,
+			WriteTransactionSize: cfg.Server.FlightWriteTransactionSize,
+			QueryLimiter:         queryLimiter,
+			Cursors:              cursorStore,
+		})
+		go func() {
+			if err := flightServer.ListenAndServe(); err != nil {
+				fmt.Printf("Arrow Flight server error: %v\n", err)
+			}
+		}()
+	}
+
 	fmt.Println()
 	fmt.Println("✅ NornicDB is ready!")
 	fmt.Println()
@@ -465,6 +771,9 @@ func runServe(cmd *cobra.Command, args []string) error {
 	fmt.Println("Endpoints:")
 	fmt.Printf("  • HTTP API:     http://%s:%d\n", displayAddr, httpPort)
 	fmt.Printf("  • Bolt:         bolt://%s:%d\n", displayAddr, boltPort)
+	if flightServer != nil {
+		fmt.Printf("  • Arrow Flight: grpc://%s:%d\n", displayAddr, cfg.Server.FlightPort)
+	}
 	fmt.Printf("  • Health:       http://%s:%d/health\n", displayAddr, httpPort)
 	fmt.Printf("  • Search:       POST http://%s:%d/nornicdb/search\n", displayAddr, httpPort)
 	fmt.Printf("  • Cypher:       POST http://%s:%d/db/neo4j/tx/commit\n", displayAddr, httpPort)
@@ -495,6 +804,10 @@ func runServe(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Warning: error stopping Bolt server: %v\n", err)
 	}
 
+	if flightServer != nil {
+		flightServer.Close()
+	}
+
 	if err := httpServer.Stop(ctx); err != nil {
 		return fmt.Errorf("stopping HTTP server: %w", err)
 	}
@@ -504,13 +817,28 @@ func runServe(cmd *cobra.Command, args []string) error {
 }
 
 // DBQueryExecutor adapts nornicdb.DB to bolt.QueryExecutor interface.
+// It implements bolt.TransactionalExecutor to hold back the Heimdall events
+// of explicit transactions until they commit.
 type DBQueryExecutor struct {
 	db *nornicdb.DB
+
+	eventTxs sync.Map // bolt transaction ID -> *heimdall.EventTransaction
 }
 
 // Execute runs a Cypher query against the database.
+// The query is published to Heimdall plugins as a query event carrying the
+// Bolt request ID from ctx.
 func (e *DBQueryExecutor) Execute(ctx context.Context, query string, params map[string]any) (*bolt.QueryResult, error) {
+	if tx, ok := e.eventTxs.Load(bolt.TransactionID(ctx)); ok {
+		ctx = heimdall.WithEventTransaction(ctx, tx.(*heimdall.EventTransaction))
+	}
+	start := time.Now()
 	result, err := e.db.ExecuteCypher(ctx, query, params)
+	var rows int64
+	if result != nil {
+		rows = int64(len(result.Rows))
+	}
+	heimdall.EmitQueryEventContext(ctx, "bolt", query, params, time.Since(start), rows, err)
 	if err != nil {
 		return nil, err
 	}
@@ -518,9 +846,257 @@ func (e *DBQueryExecutor) Execute(ctx context.Context, query string, params map[
 	return &bolt.QueryResult{
 		Columns: result.Columns,
 		Rows:    result.Rows,
+		Release: result.Release,
+	}, nil
+}
+
+// BeginTransaction starts buffering the transaction's Heimdall events.
+func (e *DBQueryExecutor) BeginTransaction(ctx context.Context, metadata map[string]any) error {
+	id := bolt.TransactionID(ctx)
+	e.eventTxs.Store(id, heimdall.BeginEventTransaction(id, "bolt"))
+	return nil
+}
+
+// CommitTransaction publishes the transaction's Heimdall events.
+func (e *DBQueryExecutor) CommitTransaction(ctx context.Context) error {
+	if tx, ok := e.eventTxs.LoadAndDelete(bolt.TransactionID(ctx)); ok {
+		tx.(*heimdall.EventTransaction).Commit()
+	}
+	return nil
+}
+
+// RollbackTransaction discards the transaction's Heimdall events.
+func (e *DBQueryExecutor) RollbackTransaction(ctx context.Context) error {
+	if tx, ok := e.eventTxs.LoadAndDelete(bolt.TransactionID(ctx)); ok {
+		tx.(*heimdall.EventTransaction).Rollback()
+	}
+	return nil
+}
+
+// addIdentityProviders registers the LDAP and OIDC providers enabled in cfg.
+func addIdentityProviders(authenticator *auth.Authenticator, cfg config.AuthConfig) error {
+	if cfg.LDAPURL == "" && cfg.OIDCIssuer == "" {
+		return nil
+	}
+	mapping, err := auth.ParseRoleMapping(cfg.RoleMapping, cfg.ExternalDefaultRole)
+	if err != nil {
+		return err
+	}
+	if cfg.LDAPURL != "" {
+		ldapProvider, err := auth.NewLDAPProvider(auth.LDAPConfig{
+			URL:                  cfg.LDAPURL,
+			UserDNTemplate:       cfg.LDAPUserDNTemplate,
+			GroupBaseDN:          cfg.LDAPGroupBaseDN,
+			GroupMemberAttribute: cfg.LDAPGroupMemberAttribute,
+			RoleMapping:          mapping,
+		})
+		if err != nil {
+			return err
+		}
+		authenticator.AddProvider(ldapProvider)
+	}
+	if cfg.OIDCIssuer != "" {
+		oidcProvider, err := auth.NewOIDCProvider(auth.OIDCConfig{
+			Issuer:      cfg.OIDCIssuer,
+			Audience:    cfg.OIDCAudience,
+			JWKSURL:     cfg.OIDCJWKSURL,
+			GroupsClaim: cfg.OIDCGroupsClaim,
+			RoleMapping: mapping,
+		})
+		if err != nil {
+			return err
+		}
+		authenticator.AddProvider(oidcProvider)
+	}
+	return nil
+}
+
+// newQueryLimiter builds the per-user/role/IP query limiter from config.
+// Returns nil when query limits are disabled.
+func newQueryLimiter(cfg config.QueryLimitsConfig) (*ratelimit.Limiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	users, err := ratelimit.ParseOverrides(cfg.UserOverrides)
+	if err != nil {
+		return nil, fmt.Errorf("user limits: %w", err)
+	}
+	roles, err := ratelimit.ParseOverrides(cfg.RoleOverrides)
+	if err != nil {
+		return nil, fmt.Errorf("role limits: %w", err)
+	}
+	ips, err := ratelimit.ParseOverrides(cfg.IPOverrides)
+	if err != nil {
+		return nil, fmt.Errorf("ip limits: %w", err)
+	}
+	return ratelimit.New(ratelimit.Config{
+		Enabled: true,
+		Default: ratelimit.Limits{
+			QueriesPerSecond:     cfg.QueriesPerSecond,
+			Burst:                cfg.Burst,
+			MaxConcurrent:        cfg.MaxConcurrent,
+			ResultBytesPerSecond: cfg.ResultBytesPerSecond,
+		},
+		Users: users,
+		Roles: roles,
+		IPs:   ips,
+	}), nil
+}
+
+// newStorageQuota builds the storage quota from config.
+func newStorageQuota(cfg config.QuotaConfig) (storage.Quota, error) {
+	labels, err := storage.ParseLabelQuotas(cfg.Labels)
+	if err != nil {
+		return storage.Quota{}, err
+	}
+	return storage.Quota{
+		MaxNodes:         cfg.MaxNodes,
+		MaxRelationships: cfg.MaxRelationships,
+		MaxBytes:         cfg.MaxBytes,
+		MaxNodesPerLabel: labels,
 	}, nil
 }
 
+// configurePropertySchema loads the schema file, if any, and sets the mode
+// from config, which overrides a mode set in the file.
+func configurePropertySchema(schema *storage.PropertySchema, cfg config.PropertySchemaConfig) error {
+	mode, err := storage.ParseSchemaMode(cfg.Mode)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		if mode != storage.SchemaModeOff || cfg.File != "" {
+			return fmt.Errorf("property schemas are not supported by this storage engine")
+		}
+		return nil
+	}
+	if cfg.File != "" {
+		data, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return err
+		}
+		if err := schema.Load(data); err != nil {
+			return fmt.Errorf("%s: %w", cfg.File, err)
+		}
+	}
+	if cfg.Mode != "" {
+		schema.SetMode(mode)
+	}
+	if schema.Mode() != storage.SchemaModeOff {
+		fmt.Printf("📐 Property schema %s mode (%d labels, %d relationship types)\n",
+			schema.Mode(), len(schema.Labels()), len(schema.RelationshipTypes()))
+	}
+	return nil
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	configFile, _ := cmd.Flags().GetString("config")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	cfg := config.LoadFromEnv()
+	applyPreflightFlags(cmd, cfg)
+	if configFile == "" {
+		configFile = defaultConfigFile(cfg.Database.DataDir)
+	}
+
+	report := preflight.Run(preflight.Options{
+		Config:     cfg,
+		ConfigFile: configFile,
+	})
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("🩺 NornicDB preflight check (v%s)\n\n", version)
+		report.WriteText(os.Stdout)
+	}
+
+	if !report.OK {
+		_, _, failed := report.Counts()
+		return fmt.Errorf("%d preflight check(s) failed", failed)
+	}
+	return nil
+}
+
+func runCheckConsistency(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	dataDir, _ := cmd.Flags().GetString("data-dir")
+	repair, _ := cmd.Flags().GetBool("repair")
+	deleteDangling, _ := cmd.Flags().GetBool("delete-dangling")
+	maxIssues, _ := cmd.Flags().GetInt("max-issues")
+	asJSON, _ := cmd.Flags().GetBool("json")
+	if deleteDangling && !repair {
+		return fmt.Errorf("--delete-dangling requires --repair")
+	}
+
+	engine, err := storage.NewBadgerEngineWithOptions(storage.BadgerOptions{DataDir: dataDir})
+	if err != nil {
+		return fmt.Errorf("opening %s (stop the server first): %w", dataDir, err)
+	}
+	defer engine.Close()
+
+	report, err := engine.CheckConsistency(storage.ConsistencyOptions{
+		Repair:         repair,
+		DeleteDangling: deleteDangling,
+		MaxIssues:      maxIssues,
+	})
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("🩺 NornicDB consistency check (%s)\n\n", dataDir)
+		report.WriteText(os.Stdout)
+	}
+
+	if !report.OK() {
+		return fmt.Errorf("store has unrepaired consistency issues")
+	}
+	return nil
+}
+
+// applyPreflightFlags copies the flags shared by serve and check into cfg so
+// preflight checks see the same settings the server will use.
+func applyPreflightFlags(cmd *cobra.Command, cfg *config.Config) {
+	if dataDir, err := cmd.Flags().GetString("data-dir"); err == nil {
+		cfg.Database.DataDir = dataDir
+	}
+	if port, err := cmd.Flags().GetInt("bolt-port"); err == nil {
+		cfg.Server.BoltPort = port
+	}
+	if port, err := cmd.Flags().GetInt("http-port"); err == nil {
+		cfg.Server.HTTPPort = port
+	}
+	if provider, err := cmd.Flags().GetString("embedding-provider"); err == nil {
+		cfg.Memory.EmbeddingProvider = provider
+	}
+	if model, err := cmd.Flags().GetString("embedding-model"); err == nil {
+		cfg.Memory.EmbeddingModel = model
+	}
+	if dims, err := cmd.Flags().GetInt("embedding-dim"); err == nil {
+		cfg.Memory.EmbeddingDimensions = dims
+	}
+}
+
+// defaultConfigFile returns the config file written by init, or "" if the
+// data directory has none.
+func defaultConfigFile(dataDir string) string {
+	path := filepath.Join(dataDir, "nornicdb.yaml")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
 func runInit(cmd *cobra.Command, args []string) error {
 	dataDir, _ := cmd.Flags().GetString("data-dir")
 
@@ -632,6 +1208,52 @@ func runImport(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runSeed(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	dataDir, _ := cmd.Flags().GetString("data-dir")
+	dataset, _ := cmd.Flags().GetString("dataset")
+	scale, _ := cmd.Flags().GetInt("scale")
+	seed, _ := cmd.Flags().GetInt64("seed")
+	withEmbeddings, _ := cmd.Flags().GetBool("embeddings")
+	dims, _ := cmd.Flags().GetInt("embedding-dim")
+	if !withEmbeddings {
+		dims = 0
+	}
+
+	ds, err := sample.Generate(sample.Options{Dataset: dataset, Scale: scale, Dimensions: dims, Seed: seed})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🌱 Seeding %s dataset (scale %d) into %s\n", dataset, scale, dataDir)
+	config := nornicdb.DefaultConfig()
+	config.DataDir = dataDir
+	db, err := nornicdb.Open(dataDir, config)
+	if err != nil {
+		return fmt.Errorf("opening database (stop the server first): %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	startTime := time.Now()
+	result, err := db.LoadSample(ctx, ds)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Loaded %d nodes, %d relationships, %d embeddings in %v\n",
+		result.NodesLoaded, result.EdgesLoaded, result.EmbeddingsLoaded, time.Since(startTime))
+
+	if result.EmbeddingsLoaded > 0 {
+		if err := db.BuildSearchIndexes(ctx); err != nil {
+			return fmt.Errorf("building indexes: %w", err)
+		}
+		fmt.Printf("🔍 Vector index %q covers :%s nodes\n", ds.VectorIndex, ds.VectorLabel)
+	}
+	return nil
+}
+
 func runShell(cmd *cobra.Command, args []string) error {
 	uri, _ := cmd.Flags().GetString("uri")
 	fmt.Printf("🔌 Connecting to %s...\n", uri)
@@ -701,3 +1323,23 @@ func getEnvBool(key string, defaultVal bool) bool {
 	}
 	return defaultVal
 }
+
+// printGPUProbe logs what each GPU backend found. When GPU acceleration is
+// active only usable devices are listed; otherwise every backend is listed
+// with the reason it cannot be used.
+func printGPUProbe(enabled bool) {
+	for _, b := range gpu.Probe() {
+		if enabled && !b.Available {
+			continue
+		}
+		fmt.Printf("      %s\n", b)
+		if !b.Available {
+			continue
+		}
+		for _, d := range b.Devices {
+			if len(d.Features) > 0 {
+				fmt.Printf("         %s: %s\n", d.Name, strings.Join(d.Features, ", "))
+			}
+		}
+	}
+}
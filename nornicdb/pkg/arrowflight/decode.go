@@ -0,0 +1,228 @@
+package arrowflight
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// decodeValue returns the property value at row i of arr. Integers become
+// int64, floats float64, timestamps and dates time.Time, and lists []any.
+// Other types are stored as their string form.
+func decodeValue(arr arrow.Array, i int) any {
+	if arr.IsNull(i) {
+		return nil
+	}
+	switch a := arr.(type) {
+	case *array.Int8:
+		return int64(a.Value(i))
+	case *array.Int16:
+		return int64(a.Value(i))
+	case *array.Int32:
+		return int64(a.Value(i))
+	case *array.Int64:
+		return a.Value(i)
+	case *array.Uint8:
+		return int64(a.Value(i))
+	case *array.Uint16:
+		return int64(a.Value(i))
+	case *array.Uint32:
+		return int64(a.Value(i))
+	case *array.Uint64:
+		return int64(a.Value(i))
+	case *array.Float32:
+		return float64(a.Value(i))
+	case *array.Float64:
+		return a.Value(i)
+	case *array.Boolean:
+		return a.Value(i)
+	case *array.String:
+		return a.Value(i)
+	case *array.LargeString:
+		return a.Value(i)
+	case *array.Timestamp:
+		return a.Value(i).ToTime(a.DataType().(*arrow.TimestampType).Unit)
+	case *array.Date32:
+		return a.Value(i).ToTime()
+	case *array.Date64:
+		return a.Value(i).ToTime()
+	case array.ListLike:
+		start, end := a.ValueOffsets(i)
+		values := a.ListValues()
+		list := make([]any, 0, end-start)
+		for j := start; j < end; j++ {
+			list = append(list, decodeValue(values, int(j)))
+		}
+		return list
+	}
+	return arr.ValueStr(i)
+}
+
+// decodeString returns the string at row i of a column, or "" if it is
+// null or missing.
+func decodeString(arr arrow.Array, i int) string {
+	if arr == nil {
+		return ""
+	}
+	switch v := decodeValue(arr, i).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// decodeLabels returns the labels at row i: a list of strings, or a string
+// of labels separated by semicolons as in neo4j-admin import files.
+func decodeLabels(arr arrow.Array, i int) []string {
+	if arr == nil {
+		return nil
+	}
+	var labels []string
+	switch v := decodeValue(arr, i).(type) {
+	case []any:
+		for _, l := range v {
+			if s, ok := l.(string); ok && s != "" {
+				labels = append(labels, s)
+			}
+		}
+	case string:
+		for _, l := range strings.Split(v, ";") {
+			if l = strings.TrimSpace(l); l != "" {
+				labels = append(labels, l)
+			}
+		}
+	}
+	return labels
+}
+
+// decodeProperties decodes a JSON object of properties, keeping integers
+// as int64.
+func decodeProperties(s string) (map[string]any, error) {
+	if s == "" {
+		return nil, nil
+	}
+	d := json.NewDecoder(bytes.NewReader([]byte(s)))
+	d.UseNumber()
+	var props map[string]any
+	if err := d.Decode(&props); err != nil {
+		return nil, fmt.Errorf("properties must be a JSON object: %w", err)
+	}
+	for k, v := range props {
+		props[k] = fromJSON(v)
+	}
+	return props, nil
+}
+
+// fromJSON converts the json.Numbers in a decoded value to int64 or float64.
+func fromJSON(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i, e := range v {
+			v[i] = fromJSON(e)
+		}
+	case map[string]any:
+		for k, e := range v {
+			v[k] = fromJSON(e)
+		}
+	}
+	return v
+}
+
+// recordColumns maps the reserved columns of a write stream to their index;
+// every other column is a property.
+type recordColumns struct {
+	reserved   map[string]int
+	properties []int
+}
+
+func newRecordColumns(schema *arrow.Schema, reserved ...string) recordColumns {
+	c := recordColumns{reserved: make(map[string]int)}
+	for i, f := range schema.Fields() {
+		if slices.Contains(reserved, f.Name) {
+			c.reserved[f.Name] = i
+		} else {
+			c.properties = append(c.properties, i)
+		}
+	}
+	return c
+}
+
+// column returns the reserved column name of rec, or nil if it is absent.
+func (c recordColumns) column(rec arrow.RecordBatch, name string) arrow.Array {
+	if i, ok := c.reserved[name]; ok {
+		return rec.Column(i)
+	}
+	return nil
+}
+
+// rowProperties returns the properties of row i: the JSON "properties"
+// column, overlaid with the property columns. Null cells are skipped, so
+// sparse columns leave stored values alone; a null in the JSON removes the
+// property on upsert.
+func (c recordColumns) rowProperties(rec arrow.RecordBatch, i int) (map[string]any, error) {
+	props, err := decodeProperties(decodeString(c.column(rec, "properties"), i))
+	if err != nil {
+		return nil, err
+	}
+	if props == nil {
+		props = make(map[string]any, len(c.properties))
+	}
+	for _, col := range c.properties {
+		if v := decodeValue(rec.Column(col), i); v != nil {
+			props[rec.Schema().Field(col).Name] = v
+		}
+	}
+	return props, nil
+}
+
+// decodeNodes converts rows [from, to) of a nodes record batch.
+func decodeNodes(rec arrow.RecordBatch, c recordColumns, from, to int) ([]*storage.Node, error) {
+	nodes := make([]*storage.Node, 0, to-from)
+	for i := from; i < to; i++ {
+		props, err := c.rowProperties(rec, i)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		nodes = append(nodes, &storage.Node{
+			ID:         storage.NodeID(decodeString(c.column(rec, "id"), i)),
+			Labels:     decodeLabels(c.column(rec, "labels"), i),
+			Properties: props,
+		})
+	}
+	return nodes, nil
+}
+
+// decodeEdges converts rows [from, to) of a relationships record batch.
+func decodeEdges(rec arrow.RecordBatch, c recordColumns, from, to int) ([]*storage.Edge, error) {
+	edges := make([]*storage.Edge, 0, to-from)
+	for i := from; i < to; i++ {
+		props, err := c.rowProperties(rec, i)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		edges = append(edges, &storage.Edge{
+			ID:         storage.EdgeID(decodeString(c.column(rec, "id"), i)),
+			Type:       decodeString(c.column(rec, "type"), i),
+			StartNode:  storage.NodeID(decodeString(c.column(rec, "source"), i)),
+			EndNode:    storage.NodeID(decodeString(c.column(rec, "target"), i)),
+			Properties: props,
+		})
+	}
+	return edges, nil
+}
@@ -0,0 +1,351 @@
+package arrowflight
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+// kind is the Arrow type chosen for a column.
+type kind int
+
+const (
+	kindNull kind = iota // only nulls seen; sent as a string column
+	kindInt64
+	kindFloat64
+	kindBool
+	kindString
+	kindTimestamp
+	kindFloat32List
+	kindFloat64List
+	kindStringList
+	kindJSON // anything else, sent as JSON text
+)
+
+// valueKind returns the kind that holds v.
+func valueKind(v any) kind {
+	switch v := v.(type) {
+	case nil:
+		return kindNull
+	case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		return kindInt64
+	case float32, float64:
+		return kindFloat64
+	case bool:
+		return kindBool
+	case string:
+		return kindString
+	case time.Time:
+		return kindTimestamp
+	case []float32:
+		return kindFloat32List
+	case []float64:
+		return kindFloat64List
+	case []string:
+		return kindStringList
+	case []any:
+		// Lists from Cypher arrive untyped
+		k := kindNull
+		for _, e := range v {
+			switch valueKind(e) {
+			case kindString:
+				k = mergeKinds(k, kindStringList)
+			case kindInt64, kindFloat64:
+				k = mergeKinds(k, kindFloat64List)
+			default:
+				return kindJSON
+			}
+		}
+		if k == kindNull {
+			return kindJSON
+		}
+		return k
+	default:
+		return kindJSON
+	}
+}
+
+// mergeKinds returns a kind that holds values of both a and b.
+func mergeKinds(a, b kind) kind {
+	switch {
+	case a == b || b == kindNull:
+		return a
+	case a == kindNull:
+		return b
+	case (a == kindInt64 && b == kindFloat64) || (a == kindFloat64 && b == kindInt64):
+		return kindFloat64
+	case (a == kindFloat32List && b == kindFloat64List) || (a == kindFloat64List && b == kindFloat32List):
+		return kindFloat64List
+	default:
+		return kindJSON
+	}
+}
+
+// inferKinds chooses a kind for each of n columns from the values in rows.
+func inferKinds(rows [][]any, n int) []kind {
+	kinds := make([]kind, n)
+	for _, row := range rows {
+		for i := 0; i < n && i < len(row); i++ {
+			if kinds[i] != kindJSON {
+				kinds[i] = mergeKinds(kinds[i], valueKind(row[i]))
+			}
+		}
+	}
+	return kinds
+}
+
+// arrowType returns the Arrow type of a column of kind k.
+func arrowType(k kind) arrow.DataType {
+	switch k {
+	case kindInt64:
+		return arrow.PrimitiveTypes.Int64
+	case kindFloat64:
+		return arrow.PrimitiveTypes.Float64
+	case kindBool:
+		return arrow.FixedWidthTypes.Boolean
+	case kindTimestamp:
+		return arrow.FixedWidthTypes.Timestamp_us
+	case kindFloat32List:
+		return arrow.ListOf(arrow.PrimitiveTypes.Float32)
+	case kindFloat64List:
+		return arrow.ListOf(arrow.PrimitiveTypes.Float64)
+	case kindStringList:
+		return arrow.ListOf(arrow.BinaryTypes.String)
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// batchWriter streams rows to a DoGet client as Arrow record batches of
+// up to size rows. Column kinds are inferred from the first batch unless
+// preset in kinds; later values that do not fit their column are sent as
+// null, except in string columns, which receive them as JSON text.
+type batchWriter struct {
+	stream flight.DataStreamWriter
+	names  []string
+	kinds  []kind
+	size   int
+	mem    memory.Allocator
+	// metadata is attached to the schema
+	metadata map[string]string
+
+	rows    [][]any
+	builder *array.RecordBuilder
+	writer  *flight.Writer
+}
+
+func newBatchWriter(stream flight.DataStreamWriter, names []string, size int) *batchWriter {
+	return &batchWriter{stream: stream, names: names, size: size, mem: memory.DefaultAllocator}
+}
+
+// add queues a row, writing a batch once size rows are queued.
+func (w *batchWriter) add(row []any) error {
+	w.rows = append(w.rows, row)
+	if len(w.rows) >= w.size {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush writes the queued rows as one record batch.
+func (w *batchWriter) flush() error {
+	if w.writer == nil {
+		w.start()
+	}
+	if len(w.rows) == 0 {
+		return nil
+	}
+	for _, row := range w.rows {
+		for i, k := range w.kinds {
+			var v any
+			if i < len(row) {
+				v = row[i]
+			}
+			appendValue(w.builder.Field(i), k, v)
+		}
+	}
+	w.rows = w.rows[:0]
+
+	rec := w.builder.NewRecordBatch()
+	defer rec.Release()
+	return w.writer.Write(rec)
+}
+
+// start fixes the schema and opens the stream. Columns without a preset
+// kind take the kind of their values in the first batch.
+func (w *batchWriter) start() {
+	if w.kinds == nil {
+		w.kinds = make([]kind, len(w.names))
+	}
+	if slices.Contains(w.kinds, kindNull) {
+		inferred := inferKinds(w.rows, len(w.names))
+		for i, k := range w.kinds {
+			if k == kindNull {
+				w.kinds[i] = inferred[i]
+			}
+		}
+	}
+	fields := make([]arrow.Field, len(w.names))
+	for i, name := range w.names {
+		fields[i] = arrow.Field{Name: name, Type: arrowType(w.kinds[i]), Nullable: true}
+	}
+	var md *arrow.Metadata
+	if len(w.metadata) > 0 {
+		m := arrow.MetadataFrom(w.metadata)
+		md = &m
+	}
+	schema := arrow.NewSchema(fields, md)
+	w.builder = array.NewRecordBuilder(w.mem, schema)
+	w.writer = flight.NewRecordWriter(w.stream, ipc.WithSchema(schema), ipc.WithAllocator(w.mem))
+}
+
+// close writes the remaining rows and ends the stream. A stream without
+// rows still carries its schema.
+func (w *batchWriter) close() error {
+	err := w.flush()
+	if cerr := w.writer.Close(); err == nil {
+		err = cerr
+	}
+	w.builder.Release()
+	return err
+}
+
+// appendValue appends v to a builder of kind k, or a null if it does not fit.
+func appendValue(b array.Builder, k kind, v any) {
+	if v == nil {
+		b.AppendNull()
+		return
+	}
+	switch k {
+	case kindInt64:
+		if n, ok := toInt64(v); ok {
+			b.(*array.Int64Builder).Append(n)
+			return
+		}
+	case kindFloat64:
+		if f, ok := toFloat64(v); ok {
+			b.(*array.Float64Builder).Append(f)
+			return
+		}
+	case kindBool:
+		if x, ok := v.(bool); ok {
+			b.(*array.BooleanBuilder).Append(x)
+			return
+		}
+	case kindTimestamp:
+		if t, ok := v.(time.Time); ok {
+			b.(*array.TimestampBuilder).Append(arrow.Timestamp(t.UnixMicro()))
+			return
+		}
+	case kindFloat32List, kindFloat64List, kindStringList:
+		if appendList(b.(*array.ListBuilder), k, v) {
+			return
+		}
+	default: // kindString, kindJSON, kindNull
+		if s, ok := v.(string); ok && k != kindJSON {
+			b.(*array.StringBuilder).Append(s)
+			return
+		}
+		if data, err := json.Marshal(v); err == nil {
+			b.(*array.StringBuilder).Append(string(data))
+			return
+		}
+	}
+	b.AppendNull()
+}
+
+// appendList appends a list value, reporting false if v is not a list of
+// the column's element type.
+func appendList(b *array.ListBuilder, k kind, v any) bool {
+	var items []any
+	switch v := v.(type) {
+	case []float32:
+		if k == kindStringList {
+			return false
+		}
+		b.Append(true)
+		for _, f := range v {
+			appendListItem(b.ValueBuilder(), float64(f))
+		}
+		return true
+	case []float64:
+		items = make([]any, len(v))
+		for i, f := range v {
+			items[i] = f
+		}
+	case []string:
+		items = make([]any, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+	case []any:
+		items = v
+	default:
+		return false
+	}
+	for _, item := range items {
+		if _, ok := item.(string); k == kindStringList && !ok {
+			return false
+		}
+		if _, ok := toFloat64(item); k != kindStringList && !ok {
+			return false
+		}
+	}
+	b.Append(true)
+	for _, item := range items {
+		appendListItem(b.ValueBuilder(), item)
+	}
+	return true
+}
+
+func appendListItem(b array.Builder, v any) {
+	switch b := b.(type) {
+	case *array.Float32Builder:
+		f, _ := toFloat64(v)
+		b.Append(float32(f))
+	case *array.Float64Builder:
+		f, _ := toFloat64(v)
+		b.Append(f)
+	case *array.StringBuilder:
+		b.Append(v.(string))
+	}
+}
+
+func toInt64(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func toFloat64(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	n, ok := toInt64(v)
+	return float64(n), ok
+}
@@ -0,0 +1,419 @@
+// Package arrowflight serves query results and graph projections over
+// Apache Arrow Flight, for bulk analytical reads from pandas, pyarrow and
+// other Arrow clients, and accepts streamed bulk writes from ingestion
+// pipelines.
+//
+// Bolt and the HTTP API encode results row by row. Flight sends them as
+// columnar Arrow record batches that clients load without per-value
+// decoding, which is much faster for pulls of millions of rows.
+//
+// Clients call DoGet with a JSON ticket naming what to stream:
+//
+//	{"query": "MATCH (p:Person) RETURN p.name AS name, p.age AS age"}
+//	{"query": "MATCH (p:Person) WHERE p.age > $min RETURN p", "parameters": {"min": 30}}
+//	{"projection": "nodes", "labels": ["Person"], "properties": ["name", "age"], "embeddings": true}
+//	{"projection": "relationships", "types": ["KNOWS"]}
+//
+// A query ticket may set "pageSize" to stream only the first rows. If more
+// remain, the schema metadata holds a cursor under "nornicdb.cursor"; a
+// ticket of {"cursor": "..."} streams the next page without running the
+// query again, optionally with its own "pageSize":
+//
+//	reader = client.do_get(flight.Ticket(json.dumps({"query": q, "pageSize": 10000})), options)
+//	cursor = (reader.schema.metadata or {}).get(b"nornicdb.cursor")
+//
+// Queries must be read-only. Column types are inferred from the values:
+// integers, floats, booleans, strings, datetimes, vectors and string lists
+// map to their Arrow types; nodes, maps and mixed columns are sent as JSON
+// text.
+//
+// From Python:
+//
+//	import json, pyarrow.flight as flight
+//	client = flight.connect("grpc://localhost:8815")
+//	options = flight.FlightCallOptions(headers=[client.authenticate_basic_token("admin", "password")])
+//	ticket = flight.Ticket(json.dumps({"projection": "nodes", "labels": ["Person"]}))
+//	df = client.do_get(ticket, options).read_pandas()
+//
+// Clients call DoPut to upsert nodes or relationships, naming what the
+// stream holds in the descriptor command:
+//
+//	{"write": "nodes"}                                  // columns id, labels, properties, ...
+//	{"write": "relationships", "transactionSize": 5000} // columns id, type, source, target, ...
+//
+// Other columns become properties, as does the JSON object in an optional
+// "properties" column. Each record batch is committed in transactions of
+// at most transactionSize rows and then acknowledged with a JSON WriteAck
+// in the PutResult metadata. Writes require the write permission.
+//
+//	table = pa.table({"id": ["u1", "u2"], "labels": [["User"], ["User"]], "name": ["Ada", "Bob"]})
+//	descriptor = flight.FlightDescriptor.for_command(json.dumps({"write": "nodes"}))
+//	writer, acks = client.do_put(descriptor, table.schema, options)
+//	writer.write_table(table, max_chunksize=50000)
+//	writer.done_writing()
+//	print(json.loads(acks.read().to_pybytes()))
+package arrowflight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/cursor"
+	"github.com/orneryd/nornicdb/pkg/cypher"
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+	"github.com/orneryd/nornicdb/pkg/nornicdb"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// Ticket selects what DoGet streams. Clients send it JSON encoded.
+type Ticket struct {
+	// Query is a read-only Cypher query whose result is streamed.
+	Query      string         `json:"query,omitempty"`
+	Parameters map[string]any `json:"parameters,omitempty"`
+
+	// Projection streams stored graph data instead of a query result:
+	// "nodes" (columns id, labels, ...) or "relationships" (columns id,
+	// type, source, target, ...).
+	Projection string `json:"projection,omitempty"`
+	// Labels and Types restrict nodes and relationships (any match; empty = all).
+	Labels []string `json:"labels,omitempty"`
+	Types  []string `json:"types,omitempty"`
+	// Properties become one column each; empty sends a single "properties"
+	// column holding every property as JSON.
+	Properties []string `json:"properties,omitempty"`
+	// Embeddings adds an "embedding" column to node projections.
+	Embeddings bool `json:"embeddings,omitempty"`
+
+	// PageSize streams at most this many rows of a query result, leaving
+	// the rest in a cursor (0 = all rows).
+	PageSize int `json:"pageSize,omitempty"`
+	// Cursor streams the next page of an earlier query instead.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// CursorMetadataKey is the schema metadata key holding the cursor of a
+// paged query result.
+const CursorMetadataKey = "nornicdb.cursor"
+
+// Config holds Arrow Flight server settings.
+type Config struct {
+	// Port to listen on (default 8815, the conventional Flight port; 0 = any)
+	Port int
+	// BatchSize is the number of rows per record batch (default 65536)
+	BatchSize int
+	// WriteTransactionSize caps the rows DoPut commits per transaction
+	// (default 10000)
+	WriteTransactionSize int
+	// QueryLimiter enforces per-user/role/IP query limits (nil = unlimited).
+	// Share one limiter with the Bolt and HTTP servers.
+	QueryLimiter *ratelimit.Limiter
+	// Cursors holds the rest of query results requested with a pageSize
+	// (nil = pageSize is ignored). Share one store with the HTTP server.
+	Cursors *cursor.Store
+}
+
+// DefaultConfig returns the default Arrow Flight configuration.
+func DefaultConfig() *Config {
+	return &Config{
+		Port:                 8815,
+		BatchSize:            65536,
+		WriteTransactionSize: 10000,
+	}
+}
+
+// Server is an Arrow Flight server exporting from a database.
+type Server struct {
+	flight.BaseFlightServer
+
+	config   *Config
+	db       *nornicdb.DB
+	auth     *auth.Authenticator
+	analyzer *cypher.QueryAnalyzer
+	server   flight.Server
+}
+
+// New creates an Arrow Flight server for db. If authenticator is non-nil,
+// clients must authenticate with HTTP basic credentials during the Flight
+// handshake and present the returned bearer token on later calls; reads
+// require the read permission.
+//
+// Example:
+//
+//	srv := arrowflight.New(db, authenticator, arrowflight.DefaultConfig())
+//	go srv.ListenAndServe()
+//	defer srv.Close()
+func New(db *nornicdb.DB, authenticator *auth.Authenticator, config *Config) *Server {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultConfig().BatchSize
+	}
+	if config.WriteTransactionSize <= 0 {
+		config.WriteTransactionSize = DefaultConfig().WriteTransactionSize
+	}
+
+	s := &Server{
+		config:   config,
+		db:       db,
+		auth:     authenticator,
+		analyzer: cypher.NewQueryAnalyzer(1000),
+	}
+	var middleware []flight.ServerMiddleware
+	if authenticator != nil {
+		middleware = append(middleware, flight.CreateServerBasicAuthMiddleware(validator{authenticator}))
+	}
+	s.server = flight.NewServerWithMiddleware(middleware)
+	s.server.RegisterFlightService(s)
+	return s
+}
+
+// Listen binds the configured port. ListenAndServe calls it; call it
+// directly to learn the address before serving.
+func (s *Server) Listen() error {
+	addr := fmt.Sprintf(":%d", s.config.Port)
+	if err := s.server.Init(addr); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return nil
+}
+
+// Serve accepts Flight calls until Close. Listen must have succeeded.
+func (s *Server) Serve() error {
+	return s.server.Serve()
+}
+
+// ListenAndServe binds the configured port and serves until Close.
+func (s *Server) ListenAndServe() error {
+	if err := s.Listen(); err != nil {
+		return err
+	}
+	fmt.Printf("Arrow Flight server listening on grpc://localhost:%d\n", s.Addr().(*net.TCPAddr).Port)
+	return s.Serve()
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.server.Addr()
+}
+
+// Close stops the server, letting running streams finish.
+func (s *Server) Close() error {
+	s.server.Shutdown()
+	return nil
+}
+
+// DoGet streams the query result or projection named by the ticket.
+func (s *Server) DoGet(tkt *flight.Ticket, stream flight.FlightService_DoGetServer) error {
+	var t Ticket
+	if err := json.Unmarshal(tkt.GetTicket(), &t); err != nil {
+		return status.Errorf(codes.InvalidArgument, "ticket must be JSON: %v", err)
+	}
+
+	ctx, release, err := s.admit(stream.Context(), auth.PermRead)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	switch {
+	case t.Cursor != "":
+		page, err := s.config.Cursors.Next(cursorOwner(ctx), t.Cursor, t.PageSize)
+		if err != nil {
+			return status.Error(codes.NotFound, err.Error())
+		}
+		return s.streamPage(stream, page)
+
+	case t.Query != "":
+		if !s.analyzer.Analyze(t.Query).IsReadOnly {
+			return status.Error(codes.InvalidArgument, "Arrow Flight serves read-only queries; use Bolt or HTTP for writes")
+		}
+		start := time.Now()
+		result, err := s.db.ExecuteCypher(ctx, t.Query, t.Parameters)
+		var rows int64
+		if result != nil {
+			rows = int64(len(result.Rows))
+		}
+		heimdall.EmitQueryEventContext(ctx, "flight", t.Query, t.Parameters, time.Since(start), rows, err)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		defer result.Release()
+
+		held := result.Rows
+		if t.PageSize > 0 && t.PageSize < len(held) && s.config.Cursors != nil {
+			// Pooled rows are released when the stream ends; the cursor
+			// keeps its own copies
+			held = make([][]any, len(result.Rows))
+			for i, row := range result.Rows {
+				held[i] = append([]any(nil), row...)
+			}
+		}
+		return s.streamPage(stream, s.config.Cursors.Open(cursorOwner(ctx), result.Columns, held, t.PageSize))
+
+	case t.Projection == "nodes":
+		w := newBatchWriter(stream, projectionColumns([]string{"id", "labels"}, t), s.config.BatchSize)
+		w.kinds = projectionKinds([]kind{kindString, kindStringList}, t)
+		err := s.db.StreamNodes(ctx, t.Labels, func(n *storage.Node) error {
+			row := append([]any{string(n.ID), n.Labels}, projectProperties(n.Properties, t.Properties)...)
+			if t.Embeddings && len(n.Embedding) > 0 {
+				row = append(row, n.Embedding)
+			}
+			return w.add(row)
+		})
+		return finish(w, err)
+
+	case t.Projection == "relationships":
+		t.Embeddings = false
+		w := newBatchWriter(stream, projectionColumns([]string{"id", "type", "source", "target"}, t), s.config.BatchSize)
+		w.kinds = projectionKinds([]kind{kindString, kindString, kindString, kindString}, t)
+		err := s.db.StreamEdges(ctx, t.Types, func(e *storage.Edge) error {
+			row := append([]any{string(e.ID), e.Type, string(e.StartNode), string(e.EndNode)}, projectProperties(e.Properties, t.Properties)...)
+			return w.add(row)
+		})
+		return finish(w, err)
+
+	default:
+		return status.Error(codes.InvalidArgument, `ticket needs a "query" or a "projection" of "nodes" or "relationships"`)
+	}
+}
+
+// streamPage streams a page of a query result, with its cursor in the
+// schema metadata if more rows remain.
+func (s *Server) streamPage(stream flight.FlightService_DoGetServer, page cursor.Page) error {
+	w := newBatchWriter(stream, page.Columns, s.config.BatchSize)
+	w.kinds = inferKinds(page.Rows, len(page.Columns))
+	if page.Cursor != "" {
+		w.metadata = map[string]string{CursorMetadataKey: page.Cursor}
+	}
+	for _, row := range page.Rows {
+		if err := w.add(row); err != nil {
+			return err
+		}
+	}
+	return w.close()
+}
+
+// cursorOwner binds cursors to the authenticated caller.
+func cursorOwner(ctx context.Context) string {
+	p, _ := ratelimit.PrincipalFromContext(ctx)
+	return p.User
+}
+
+// finish closes a projection stream, or reports the error that ended it.
+func finish(w *batchWriter, err error) error {
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	return w.close()
+}
+
+// projectionColumns appends the property and embedding columns of t to base.
+func projectionColumns(base []string, t Ticket) []string {
+	columns := append([]string(nil), base...)
+	if len(t.Properties) == 0 {
+		columns = append(columns, "properties")
+	} else {
+		columns = append(columns, t.Properties...)
+	}
+	if t.Embeddings {
+		columns = append(columns, "embedding")
+	}
+	return columns
+}
+
+// projectionKinds returns the column kinds matching projectionColumns, with
+// requested properties left to inference.
+func projectionKinds(base []kind, t Ticket) []kind {
+	kinds := append([]kind(nil), base...)
+	if len(t.Properties) == 0 {
+		kinds = append(kinds, kindJSON)
+	} else {
+		kinds = append(kinds, make([]kind, len(t.Properties))...)
+	}
+	if t.Embeddings {
+		kinds = append(kinds, kindFloat32List)
+	}
+	return kinds
+}
+
+// projectProperties returns the values of the requested properties, or all
+// properties as one value if none are requested.
+func projectProperties(props map[string]any, names []string) []any {
+	if len(names) == 0 {
+		return []any{props}
+	}
+	values := make([]any, len(names))
+	for i, name := range names {
+		values[i] = props[name]
+	}
+	return values
+}
+
+// admit checks that the caller has perm and takes a query limiter slot,
+// returning the call context tagged with the caller and the slot's release.
+func (s *Server) admit(ctx context.Context, perm auth.Permission) (context.Context, func(), error) {
+	var p ratelimit.Principal
+	if claims, ok := flight.AuthFromContext(ctx).(*auth.JWTClaims); ok {
+		p.User, p.Roles = claims.Username, claims.Roles
+	}
+	if s.auth != nil && !hasPermission(p.Roles, perm) {
+		return nil, nil, status.Errorf(codes.PermissionDenied, "%s permission required", perm)
+	}
+	if pr, ok := peer.FromContext(ctx); ok {
+		p.IP = pr.Addr.String()
+	}
+	release, err := s.config.QueryLimiter.Acquire(p)
+	if err != nil {
+		return nil, nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return ratelimit.NewContext(ctx, p), release, nil
+}
+
+// hasPermission reports whether any of roles grants perm.
+func hasPermission(roles []string, perm auth.Permission) bool {
+	for _, role := range roles {
+		for _, p := range auth.RolePermissions[auth.Role(role)] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validator checks Flight credentials against the authenticator: basic
+// credentials in the handshake yield a JWT, which later calls present as a
+// bearer token.
+type validator struct {
+	auth *auth.Authenticator
+}
+
+func (v validator) Validate(username, password string) (string, error) {
+	token, _, err := v.auth.Authenticate(username, password, "", "arrow-flight")
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, err.Error())
+	}
+	return token.AccessToken, nil
+}
+
+func (v validator) IsValid(bearerToken string) (interface{}, error) {
+	claims, err := v.auth.ValidateToken(bearerToken)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return claims, nil
+}
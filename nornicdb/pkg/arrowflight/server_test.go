@@ -0,0 +1,380 @@
+package arrowflight
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/cursor"
+	"github.com/orneryd/nornicdb/pkg/nornicdb"
+)
+
+func TestInferKinds(t *testing.T) {
+	now := time.Now()
+	rows := [][]any{
+		{int64(1), 1.5, "a", true, now, []float32{1, 2}, []any{"x", "y"}, nil, map[string]any{"k": 1}, int64(1)},
+		{int64(2), int64(2), nil, false, now, []float64{3}, []any{"z"}, nil, nil, "mixed"},
+	}
+	assert.Equal(t, []kind{
+		kindInt64, kindFloat64, kindString, kindBool, kindTimestamp,
+		kindFloat64List, kindStringList, kindNull, kindJSON, kindJSON,
+	}, inferKinds(rows, 10))
+}
+
+func TestAppendValue(t *testing.T) {
+	lb := array.NewListBuilder(memory.DefaultAllocator, arrow.PrimitiveTypes.Float32)
+	defer lb.Release()
+
+	appendValue(lb, kindFloat32List, []float32{0.5, 1})
+	appendValue(lb, kindFloat32List, []any{int64(2)})
+	appendValue(lb, kindFloat32List, "not a vector")
+	list := lb.NewListArray()
+	defer list.Release()
+	assert.Equal(t, `[[0.5 1] [2] (null)]`, list.String())
+
+	sb := array.NewStringBuilder(memory.DefaultAllocator)
+	defer sb.Release()
+	appendValue(sb, kindString, "plain")
+	appendValue(sb, kindString, int64(7))
+	appendValue(sb, kindJSON, map[string]any{"a": 1})
+	strs := sb.NewStringArray()
+	defer strs.Release()
+	assert.Equal(t, []string{"plain", "7", `{"a":1}`}, []string{strs.Value(0), strs.Value(1), strs.Value(2)})
+}
+
+// startServer serves db on a free port and returns a connected client.
+func startServer(t *testing.T, db *nornicdb.DB, authenticator *auth.Authenticator) flight.Client {
+	t.Helper()
+	return startServerConfig(t, db, authenticator, &Config{Port: 0, BatchSize: 2})
+}
+
+func startServerConfig(t *testing.T, db *nornicdb.DB, authenticator *auth.Authenticator, config *Config) flight.Client {
+	t.Helper()
+	srv := New(db, authenticator, config)
+	require.NoError(t, srv.Listen())
+	go srv.Serve()
+	t.Cleanup(func() { srv.Close() })
+
+	client, err := flight.NewClientWithMiddleware(srv.Addr().String(), nil, nil,
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// doGet streams a ticket and returns the schema and record batches.
+func doGet(ctx context.Context, client flight.Client, ticket Ticket) (*arrow.Schema, []arrow.RecordBatch, error) {
+	data, _ := json.Marshal(ticket)
+	stream, err := client.DoGet(ctx, &flight.Ticket{Ticket: data})
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := flight.NewRecordReader(stream)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Release()
+
+	var batches []arrow.RecordBatch
+	for reader.Next() {
+		rec := reader.RecordBatch()
+		rec.Retain()
+		batches = append(batches, rec)
+	}
+	return reader.Schema(), batches, reader.Err()
+}
+
+func openTestDB(t *testing.T) *nornicdb.DB {
+	t.Helper()
+	db, err := nornicdb.Open("", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	for _, q := range []string{
+		"CREATE (:Person {name: 'Ada', age: 36})",
+		"CREATE (:Person {name: 'Bob', age: 41})",
+		"CREATE (:Person {name: 'Cy', age: 29})",
+		"CREATE (:City {name: 'Oslo'})",
+		"MATCH (a:Person {name: 'Ada'}), (b:Person {name: 'Bob'}) CREATE (a)-[:KNOWS {since: 2020}]->(b)",
+	} {
+		_, err := db.ExecuteCypher(ctx, q, nil)
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func TestDoGetQuery(t *testing.T) {
+	client := startServer(t, openTestDB(t), nil)
+	ctx := context.Background()
+
+	schema, batches, err := doGet(ctx, client, Ticket{
+		Query:      "MATCH (p:Person) WHERE p.age > $min RETURN p.name AS name, p.age AS age ORDER BY name",
+		Parameters: map[string]any{"min": 30},
+	})
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, arrow.BinaryTypes.String, schema.Field(0).Type)
+	assert.Equal(t, arrow.PrimitiveTypes.Int64, schema.Field(1).Type)
+	assert.Equal(t, `["Ada" "Bob"]`, batches[0].Column(0).String())
+	assert.Equal(t, `[36 41]`, batches[0].Column(1).String())
+
+	// Empty results still carry the schema
+	schema, batches, err = doGet(ctx, client, Ticket{Query: "MATCH (p:Nobody) RETURN p.name AS name"})
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+	assert.Equal(t, "name", schema.Field(0).Name)
+
+	_, _, err = doGet(ctx, client, Ticket{Query: "CREATE (:Person {name: 'Eve'})"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "writes are rejected")
+}
+
+func TestDoGetQueryPages(t *testing.T) {
+	client := startServerConfig(t, openTestDB(t), nil, &Config{Port: 0, BatchSize: 2, Cursors: cursor.New(time.Minute, 0)})
+	ctx := context.Background()
+	query := "MATCH (p:Person) RETURN p.name AS name ORDER BY name"
+
+	schema, batches, err := doGet(ctx, client, Ticket{Query: query, PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, `["Ada" "Bob"]`, batches[0].Column(0).String())
+	token, ok := schema.Metadata().GetValue(CursorMetadataKey)
+	require.True(t, ok, "more rows remain")
+
+	schema, batches, err = doGet(ctx, client, Ticket{Cursor: token})
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, `["Cy"]`, batches[0].Column(0).String())
+	assert.Equal(t, "name", schema.Field(0).Name)
+	_, ok = schema.Metadata().GetValue(CursorMetadataKey)
+	assert.False(t, ok, "last page")
+
+	_, _, err = doGet(ctx, client, Ticket{Cursor: token})
+	assert.Equal(t, codes.NotFound, status.Code(err), "exhausted cursor")
+
+	schema, _, err = doGet(ctx, client, Ticket{Query: query, PageSize: 3})
+	require.NoError(t, err)
+	_, ok = schema.Metadata().GetValue(CursorMetadataKey)
+	assert.False(t, ok, "result fits one page")
+}
+
+func TestDoGetProjection(t *testing.T) {
+	client := startServer(t, openTestDB(t), nil)
+	ctx := context.Background()
+
+	schema, batches, err := doGet(ctx, client, Ticket{Projection: "nodes", Labels: []string{"Person"}, Properties: []string{"name", "age"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "labels", "name", "age"}, fieldNames(schema))
+	assert.Equal(t, arrow.ListOf(arrow.BinaryTypes.String), schema.Field(1).Type)
+	assert.Equal(t, arrow.PrimitiveTypes.Int64, schema.Field(3).Type)
+	assert.Len(t, batches, 2, "3 rows in batches of 2")
+	assert.Equal(t, int64(3), batches[0].NumRows()+batches[1].NumRows())
+
+	schema, batches, err = doGet(ctx, client, Ticket{Projection: "relationships", Types: []string{"KNOWS"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "type", "source", "target", "properties"}, fieldNames(schema))
+	require.Len(t, batches, 1)
+	assert.Equal(t, `["KNOWS"]`, batches[0].Column(1).String())
+	assert.Equal(t, `["{\"since\":2020}"]`, batches[0].Column(4).String())
+
+	_, _, err = doGet(ctx, client, Ticket{Projection: "paths"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestDoGetAuth(t *testing.T) {
+	authenticator, err := auth.NewAuthenticator(auth.AuthConfig{
+		SecurityEnabled: true,
+		JWTSecret:       []byte("test-secret-key-for-testing-only-32b"),
+	})
+	require.NoError(t, err)
+	_, err = authenticator.CreateUser("reader", "password123", []auth.Role{auth.RoleViewer})
+	require.NoError(t, err)
+
+	client := startServer(t, openTestDB(t), authenticator)
+	ticket := Ticket{Query: "MATCH (c:City) RETURN c.name AS name"}
+
+	_, _, err = doGet(context.Background(), client, ticket)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.AuthenticateBasicToken(context.Background(), "reader", "wrong")
+	assert.Error(t, err)
+
+	ctx, err := client.AuthenticateBasicToken(context.Background(), "reader", "password123")
+	require.NoError(t, err)
+	_, batches, err := doGet(ctx, client, ticket)
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, `["Oslo"]`, batches[0].Column(0).String())
+}
+
+// doPut streams the JSON rows of batches under cmd and returns the acks.
+func doPut(ctx context.Context, client flight.Client, cmd WriteCommand, schema *arrow.Schema, batches ...string) ([]WriteAck, error) {
+	stream, err := client.DoPut(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := json.Marshal(cmd)
+	w := flight.NewRecordWriter(stream, ipc.WithSchema(schema))
+	w.SetFlightDescriptor(&flight.FlightDescriptor{Type: flight.DescriptorCMD, Cmd: data})
+	for _, rows := range batches {
+		rec, _, err := array.RecordFromJSON(memory.DefaultAllocator, schema, strings.NewReader(rows))
+		if err != nil {
+			return nil, err
+		}
+		err = w.Write(rec)
+		rec.Release()
+		if err != nil {
+			break // the server ended the stream; Recv reports why
+		}
+	}
+	w.Close()
+	stream.CloseSend()
+
+	var acks []WriteAck
+	for {
+		res, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return acks, nil
+		}
+		if err != nil {
+			return acks, err
+		}
+		var ack WriteAck
+		if err := json.Unmarshal(res.AppMetadata, &ack); err != nil {
+			return acks, err
+		}
+		acks = append(acks, ack)
+	}
+}
+
+func TestDoPutNodes(t *testing.T) {
+	db := openTestDB(t)
+	client := startServer(t, db, nil)
+	ctx := context.Background()
+
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.BinaryTypes.String},
+		{Name: "labels", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+		{Name: "age", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+		{Name: "properties", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, nil)
+	acks, err := doPut(ctx, client, WriteCommand{Write: "nodes", TransactionSize: 2}, schema,
+		`[{"id": "u1", "labels": ["User"], "age": 36, "properties": "{\"name\": \"Ada\", \"score\": 7}"},
+		  {"id": "u2", "labels": ["User", "Admin"], "age": 41},
+		  {"id": "u3", "labels": ["User"], "age": null}]`,
+		`[{"id": "u1", "labels": ["Admin"], "age": null, "properties": "{\"score\": null}"}]`)
+	require.NoError(t, err)
+	require.Len(t, acks, 2)
+	assert.Equal(t, WriteAck{Batch: 0, Rows: 3, BulkWriteResult: nornicdb.BulkWriteResult{NodesCreated: 3}}, acks[0])
+	assert.Equal(t, WriteAck{Batch: 1, Rows: 1, BulkWriteResult: nornicdb.BulkWriteResult{NodesUpdated: 1}}, acks[1])
+
+	result, err := db.ExecuteCypher(ctx, "MATCH (u:Admin) RETURN u.name AS name, u.age AS age, u.score AS score ORDER BY age", nil)
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{"Ada", int64(36), nil}, {nil, int64(41), nil}}, result.Rows,
+		"null cells leave properties alone, JSON nulls remove them")
+}
+
+func TestDoPutRelationships(t *testing.T) {
+	db := openTestDB(t)
+	client := startServer(t, db, nil)
+	ctx := context.Background()
+
+	nodes := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.BinaryTypes.String},
+		{Name: "labels", Type: arrow.BinaryTypes.String},
+	}, nil)
+	_, err := doPut(ctx, client, WriteCommand{Write: "nodes"}, nodes, `[{"id": "a", "labels": "Item;Tagged"}, {"id": "b", "labels": "Item"}]`)
+	require.NoError(t, err)
+
+	rels := arrow.NewSchema([]arrow.Field{
+		{Name: "type", Type: arrow.BinaryTypes.String},
+		{Name: "source", Type: arrow.BinaryTypes.String},
+		{Name: "target", Type: arrow.BinaryTypes.String},
+		{Name: "weight", Type: arrow.PrimitiveTypes.Float64},
+	}, nil)
+	acks, err := doPut(ctx, client, WriteCommand{Write: "relationships"}, rels,
+		`[{"type": "SIMILAR", "source": "a", "target": "b", "weight": 0.5}]`,
+		`[{"type": "SIMILAR", "source": "a", "target": "missing", "weight": 1}]`)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "batch 1")
+	require.Len(t, acks, 1, "batches before the failure are acknowledged")
+	assert.Equal(t, 1, acks[0].RelationshipsCreated)
+
+	result, err := db.ExecuteCypher(ctx, "MATCH (:Tagged)-[r:SIMILAR]->(:Item) RETURN r.weight AS w", nil)
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{0.5}}, result.Rows)
+
+	_, err = doPut(ctx, client, WriteCommand{Write: "relationships"}, nodes, `[{"id": "x", "labels": "Item"}]`)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "relationships need endpoints")
+	_, err = doPut(ctx, client, WriteCommand{Write: "paths"}, nodes, `[]`)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestDoPutAuth(t *testing.T) {
+	authenticator, err := auth.NewAuthenticator(auth.AuthConfig{
+		SecurityEnabled: true,
+		JWTSecret:       []byte("test-secret-key-for-testing-only-32b"),
+	})
+	require.NoError(t, err)
+	_, err = authenticator.CreateUser("reader", "password123", []auth.Role{auth.RoleViewer})
+	require.NoError(t, err)
+	_, err = authenticator.CreateUser("writer", "password123", []auth.Role{auth.RoleEditor})
+	require.NoError(t, err)
+
+	client := startServer(t, openTestDB(t), authenticator)
+	schema := arrow.NewSchema([]arrow.Field{{Name: "id", Type: arrow.BinaryTypes.String}}, nil)
+	rows := `[{"id": "n1"}]`
+
+	ctx, err := client.AuthenticateBasicToken(context.Background(), "reader", "password123")
+	require.NoError(t, err)
+	_, err = doPut(ctx, client, WriteCommand{Write: "nodes"}, schema, rows)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	ctx, err = client.AuthenticateBasicToken(context.Background(), "writer", "password123")
+	require.NoError(t, err)
+	acks, err := doPut(ctx, client, WriteCommand{Write: "nodes"}, schema, rows)
+	require.NoError(t, err)
+	require.Len(t, acks, 1)
+}
+
+func TestDecodeValue(t *testing.T) {
+	props, err := decodeProperties(`{"n": 1, "f": 1.5, "list": [2, "x"], "nested": {"m": 3}}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"n": int64(1), "f": 1.5, "list": []any{int64(2), "x"}, "nested": map[string]any{"m": int64(3)}}, props)
+	_, err = decodeProperties(`[1]`)
+	assert.Error(t, err)
+
+	lb := array.NewListBuilder(memory.DefaultAllocator, arrow.PrimitiveTypes.Float32)
+	defer lb.Release()
+	appendValue(lb, kindFloat32List, []float32{0.5, 1})
+	lb.AppendNull()
+	list := lb.NewListArray()
+	defer list.Release()
+	assert.Equal(t, []any{0.5, 1.0}, decodeValue(list, 0))
+	assert.Nil(t, decodeValue(list, 1))
+}
+
+func fieldNames(schema *arrow.Schema) []string {
+	names := make([]string, schema.NumFields())
+	for i, f := range schema.Fields() {
+		names[i] = f.Name
+	}
+	return names
+}
@@ -0,0 +1,140 @@
+package arrowflight
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/nornicdb"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// WriteCommand selects what a DoPut stream writes. Clients send it JSON
+// encoded as the command of the stream's FlightDescriptor.
+type WriteCommand struct {
+	// Write is "nodes" (columns id, labels, properties, ...) or
+	// "relationships" (columns id, type, source, target, properties, ...).
+	Write string `json:"write"`
+	// TransactionSize is the most rows committed per transaction; larger
+	// record batches are split (default and maximum
+	// Config.WriteTransactionSize).
+	TransactionSize int `json:"transactionSize,omitempty"`
+}
+
+// WriteAck acknowledges a record batch of a DoPut stream once all its rows
+// are committed. It is sent JSON encoded as the app metadata of a PutResult.
+type WriteAck struct {
+	// Batch is the 0-based index of the record batch in the stream.
+	Batch int `json:"batch"`
+	Rows  int `json:"rows"`
+	nornicdb.BulkWriteResult
+}
+
+// DoPut upserts the record batches of a write stream, acknowledging each
+// batch after it commits. The stream is read only as fast as batches are
+// committed, so gRPC flow control holds back clients that send faster.
+//
+// A failed batch ends the stream with an error naming it. Batches before it
+// are committed, as may be transactions of the failed batch itself when it
+// was split; since writes are upserts, clients resend from the failed batch.
+func (s *Server) DoPut(stream flight.FlightService_DoPutServer) error {
+	ctx, release, err := s.admit(stream.Context(), auth.PermWrite)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	reader, err := flight.NewRecordReader(stream)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "reading write stream: %v", err)
+	}
+	defer reader.Release()
+
+	var cmd WriteCommand
+	if err := json.Unmarshal(reader.LatestFlightDescriptor().GetCmd(), &cmd); err != nil {
+		return status.Errorf(codes.InvalidArgument, "descriptor command must be JSON: %v", err)
+	}
+	columns, err := writeColumns(cmd.Write, reader.Schema())
+	if err != nil {
+		return err
+	}
+	size := cmd.TransactionSize
+	if size <= 0 || size > s.config.WriteTransactionSize {
+		size = s.config.WriteTransactionSize
+	}
+
+	for batch := 0; reader.Next(); batch++ {
+		rec := reader.RecordBatch()
+		ack := WriteAck{Batch: batch, Rows: int(rec.NumRows())}
+		for from := 0; from < ack.Rows; from += size {
+			res, err := s.write(ctx, cmd.Write, rec, columns, from, min(from+size, ack.Rows))
+			if err != nil {
+				return status.Errorf(writeCode(err), "batch %d: %v", batch, err)
+			}
+			ack.Add(res)
+		}
+		meta, _ := json.Marshal(ack)
+		if err := stream.Send(&flight.PutResult{AppMetadata: meta}); err != nil {
+			return err
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return status.Errorf(codes.InvalidArgument, "reading write stream: %v", err)
+	}
+	return nil
+}
+
+// writeColumns checks the schema of a write stream and maps its columns.
+func writeColumns(write string, schema *arrow.Schema) (recordColumns, error) {
+	switch write {
+	case "nodes":
+		return newRecordColumns(schema, "id", "labels", "properties"), nil
+	case "relationships":
+		for _, name := range []string{"type", "source", "target"} {
+			if !schema.HasField(name) {
+				return recordColumns{}, status.Errorf(codes.InvalidArgument, "relationships need a %q column", name)
+			}
+		}
+		return newRecordColumns(schema, "id", "type", "source", "target", "properties"), nil
+	}
+	return recordColumns{}, status.Error(codes.InvalidArgument, `command needs a "write" of "nodes" or "relationships"`)
+}
+
+// write upserts rows [from, to) of rec in one transaction.
+func (s *Server) write(ctx context.Context, write string, rec arrow.RecordBatch, c recordColumns, from, to int) (nornicdb.BulkWriteResult, error) {
+	if write == "nodes" {
+		nodes, err := decodeNodes(rec, c, from, to)
+		if err != nil {
+			return nornicdb.BulkWriteResult{}, err
+		}
+		return s.db.BulkWrite(ctx, nodes, nil)
+	}
+	edges, err := decodeEdges(rec, c, from, to)
+	if err != nil {
+		return nornicdb.BulkWriteResult{}, err
+	}
+	return s.db.BulkWrite(ctx, nil, edges)
+}
+
+// writeCode returns the gRPC status code for a failed write.
+func writeCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, storage.ErrConflict):
+		return codes.Aborted
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		return codes.ResourceExhausted
+	case errors.Is(err, storage.ErrSchemaViolation):
+		return codes.FailedPrecondition
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return codes.Canceled
+	case errors.Is(err, nornicdb.ErrClosed):
+		return codes.Unavailable
+	}
+	return codes.InvalidArgument
+}
@@ -134,6 +134,8 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/orneryd/nornicdb/pkg/redact"
 )
 
 // EventType categorizes audit events for compliance reporting.
@@ -149,6 +151,7 @@ const (
 	// Authorization events
 	EventAccessDenied  EventType = "ACCESS_DENIED"
 	EventRoleChange    EventType = "ROLE_CHANGE"
+	EventActionApproval EventType = "ACTION_APPROVAL" // Heimdall action confirmations
 
 	// Data access events (GDPR Art.15 - right of access)
 	EventDataRead      EventType = "DATA_READ"
@@ -168,6 +171,7 @@ const (
 	EventBackup         EventType = "BACKUP"
 	EventRestore        EventType = "RESTORE"
 	EventSchemaChange   EventType = "SCHEMA_CHANGE"
+	EventSchemaViolation EventType = "SCHEMA_VIOLATION"
 
 	// Security events
 	EventSecurityAlert  EventType = "SECURITY_ALERT"
@@ -300,6 +304,9 @@ type Logger struct {
 
 	// Callback for real-time alerting (breach detection)
 	alertCallback func(Event)
+
+	// Optional PII redaction applied before events are written
+	redactor *redact.Redactor
 }
 
 // Config holds audit logger configuration.
@@ -543,6 +550,16 @@ func (l *Logger) SetAlertCallback(fn func(Event)) {
 	l.alertCallback = fn
 }
 
+// SetRedactor sets the PII redactor applied to every event before it is
+// written. Reason and metadata values are pattern-redacted, metadata under
+// sensitive names is masked, and the "query" metadata entry is redacted as
+// Cypher text. A nil redactor disables redaction.
+func (l *Logger) SetRedactor(r *redact.Redactor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redactor = r
+}
+
 // Log records an audit event to the audit trail.
 //
 // The event is automatically timestamped and assigned a unique ID if not provided.
@@ -612,6 +629,16 @@ func (l *Logger) Log(event Event) error {
 		event.ID = fmt.Sprintf("audit-%d-%d", event.Timestamp.UnixNano(), l.sequence)
 	}
 
+	// Mask PII before it reaches the log or alert callback
+	if l.redactor.Enabled() {
+		event.Reason = l.redactor.String(event.Reason)
+		query, hasQuery := event.Metadata["query"]
+		event.Metadata = l.redactor.StringMap(event.Metadata)
+		if hasQuery && !l.redactor.Sensitive("query") {
+			event.Metadata["query"] = l.redactor.Query(query)
+		}
+	}
+
 	// Serialize to JSON
 	data, err := json.Marshal(event)
 	if err != nil {
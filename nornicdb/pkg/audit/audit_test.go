@@ -9,6 +9,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/orneryd/nornicdb/pkg/redact"
 )
 
 func TestNewLogger(t *testing.T) {
@@ -103,6 +105,42 @@ func TestLoggerWithWriter(t *testing.T) {
 	}
 }
 
+func TestLoggerRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLoggerWithWriter(&buf, Config{Enabled: true})
+	r, err := redact.New(redact.Config{Enabled: true, Properties: []string{"ssn"}, Patterns: []string{"email"}})
+	if err != nil {
+		t.Fatalf("redact.New() error = %v", err)
+	}
+	logger.SetRedactor(r)
+
+	err = logger.Log(Event{
+		Type:   EventDataRead,
+		Reason: "lookup by bob@example.com",
+		Metadata: map[string]string{
+			"ssn":   "123-45-6789",
+			"query": "MATCH (p {ssn: '123-45-6789'}) RETURN p",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Log() error = %v", err)
+	}
+
+	out := buf.String()
+	for _, leaked := range []string{"bob@example.com", "123-45-6789"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("audit log leaked %q: %s", leaked, out)
+		}
+	}
+	var parsed Event
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("parsing event: %v", err)
+	}
+	if parsed.Metadata["query"] != "MATCH (p {ssn: '[REDACTED]'}) RETURN p" {
+		t.Errorf("unexpected redacted query: %q", parsed.Metadata["query"])
+	}
+}
+
 func TestLogAuth(t *testing.T) {
 	var buf bytes.Buffer
 	logger := NewLoggerWithWriter(&buf, Config{Enabled: true})
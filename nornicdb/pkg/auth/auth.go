@@ -14,6 +14,8 @@
 //   - Account lockout after failed login attempts
 //   - Password hashing with bcrypt
 //   - Audit logging for compliance
+//   - Pluggable external identity providers: LDAP bind and OIDC token
+//     validation with group-to-role mapping (see AddProvider)
 //
 // Example Usage:
 //
@@ -364,6 +366,9 @@ type Authenticator struct {
 
 	// Audit callback for compliance logging
 	auditLog func(event AuditEvent)
+
+	// External identity providers (LDAP, OIDC), see AddProvider
+	providers []IdentityProvider
 }
 
 // AuditEvent represents an authentication-related event for compliance logging.
@@ -753,6 +758,11 @@ func (a *Authenticator) CreateUser(username, password string, roles []Role) (*Us
 //   - GDPR Art.32: Technical measures to ensure security
 //   - FISMA AC-7: Unsuccessful Login Attempts
 func (a *Authenticator) Authenticate(username, password, ipAddress, userAgent string) (*TokenResponse, *User, error) {
+	// Users without a local account are verified by external providers (LDAP)
+	if a.isExternalLogin(username) {
+		return a.authenticateExternal(username, password, ipAddress, userAgent)
+	}
+
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -920,7 +930,12 @@ func (a *Authenticator) ValidateToken(token string) (*JWTClaims, error) {
 	token = strings.TrimPrefix(token, "Bearer ")
 	token = strings.TrimSpace(token)
 
-	return a.verifyJWT(token)
+	claims, err := a.verifyJWT(token)
+	if errors.Is(err, ErrInvalidToken) && !isLocalJWT(token) {
+		// Not signed by us - may be an SSO token (OIDC)
+		return a.validateExternalToken(token)
+	}
+	return claims, err
 }
 
 // GetUserByID retrieves a user by their ID.
@@ -1369,6 +1384,23 @@ func (a *Authenticator) verifyJWT(token string) (*JWTClaims, error) {
 	return &claims, nil
 }
 
+// isLocalJWT reports whether token's header declares the HS256 algorithm used
+// for NornicDB-issued tokens.
+func isLocalJWT(token string) bool {
+	header, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return false
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	return json.Unmarshal(headerJSON, &h) == nil && h.Alg == "HS256"
+}
+
 // copyUserSafe returns a copy of user without sensitive data.
 func (a *Authenticator) copyUserSafe(u *User) *User {
 	roles := make([]Role, len(u.Roles))
@@ -0,0 +1,463 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// LDAPConfig configures an LDAP bind-based identity provider.
+//
+// Users are authenticated with an LDAPv3 simple bind as their own DN, built
+// from UserDNTemplate. Group membership is then read with a subtree search
+// under GroupBaseDN for entries whose GroupMemberAttribute equals the user DN,
+// and the GroupNameAttribute of each match is mapped to roles.
+//
+// Example:
+//
+//	provider, err := auth.NewLDAPProvider(auth.LDAPConfig{
+//		URL:            "ldaps://ldap.example.com:636",
+//		UserDNTemplate: "uid=%s,ou=people,dc=example,dc=com",
+//		GroupBaseDN:    "ou=groups,dc=example,dc=com",
+//		RoleMapping: auth.RoleMapping{
+//			Groups: map[string]auth.Role{"db-admins": auth.RoleAdmin},
+//		},
+//	})
+type LDAPConfig struct {
+	Name                 string        // Provider name (default "ldap")
+	URL                  string        // ldap://host:389 or ldaps://host:636
+	TLSConfig            *tls.Config   // TLS settings for ldaps:// (nil = system defaults)
+	UserDNTemplate       string        // fmt template with one %s for the escaped username
+	GroupBaseDN          string        // Base DN for group search ("" = no group lookup)
+	GroupMemberAttribute string        // Group attribute holding member DNs (default "member")
+	GroupNameAttribute   string        // Group attribute used as group name (default "cn")
+	RoleMapping          RoleMapping   // Group to role mapping
+	Timeout              time.Duration // Dial and I/O timeout (default 5s)
+}
+
+// LDAPProvider authenticates users against an LDAP directory.
+type LDAPProvider struct {
+	config LDAPConfig
+	addr   string
+	useTLS bool
+}
+
+// NewLDAPProvider validates config and returns an LDAP provider.
+func NewLDAPProvider(config LDAPConfig) (*LDAPProvider, error) {
+	if config.Name == "" {
+		config.Name = "ldap"
+	}
+	if config.GroupMemberAttribute == "" {
+		config.GroupMemberAttribute = "member"
+	}
+	if config.GroupNameAttribute == "" {
+		config.GroupNameAttribute = "cn"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if strings.Count(config.UserDNTemplate, "%s") != 1 {
+		return nil, fmt.Errorf("ldap: user DN template must contain exactly one %%s")
+	}
+
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid URL: %w", err)
+	}
+	p := &LDAPProvider{config: config, addr: u.Host}
+	switch u.Scheme {
+	case "ldap":
+		if u.Port() == "" {
+			p.addr = net.JoinHostPort(u.Hostname(), "389")
+		}
+	case "ldaps":
+		p.useTLS = true
+		if u.Port() == "" {
+			p.addr = net.JoinHostPort(u.Hostname(), "636")
+		}
+	default:
+		return nil, fmt.Errorf("ldap: unsupported URL scheme %q (use ldap:// or ldaps://)", u.Scheme)
+	}
+	return p, nil
+}
+
+// Name implements IdentityProvider.
+func (p *LDAPProvider) Name() string {
+	return p.config.Name
+}
+
+// AuthenticatePassword implements PasswordProvider.
+func (p *LDAPProvider) AuthenticatePassword(ctx context.Context, username, password string) (*ExternalIdentity, error) {
+	// An empty password is an "unauthenticated bind" that most servers accept
+	// without checking anything (RFC 4513 §5.1.2) - never allow it.
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	userDN := fmt.Sprintf(p.config.UserDNTemplate, escapeDN(username))
+	if err := conn.bind(userDN, password); err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	if p.config.GroupBaseDN != "" {
+		groups, err = conn.searchValues(p.config.GroupBaseDN, p.config.GroupMemberAttribute, userDN, p.config.GroupNameAttribute)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: group lookup failed: %w", err)
+		}
+	}
+	conn.unbind()
+
+	return &ExternalIdentity{
+		Provider: p.config.Name,
+		Subject:  userDN,
+		Username: username,
+		Groups:   groups,
+		Roles:    p.config.RoleMapping.Map(groups),
+	}, nil
+}
+
+func (p *LDAPProvider) dial(ctx context.Context) (*ldapConn, error) {
+	dialer := &net.Dialer{Timeout: p.config.Timeout}
+	var conn net.Conn
+	var err error
+	if p.useTLS {
+		tlsConfig := p.config.TLSConfig
+		if tlsConfig == nil {
+			host, _, _ := net.SplitHostPort(p.addr)
+			tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		}
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", p.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", p.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: connect %s: %w", p.addr, err)
+	}
+	deadline := time.Now().Add(p.config.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	return &ldapConn{conn: conn}, nil
+}
+
+// escapeDN escapes a value for use in a DN attribute value (RFC 4514).
+func escapeDN(v string) string {
+	var sb strings.Builder
+	for i, r := range v {
+		switch {
+		case strings.ContainsRune(`,+"\<>;=`, r),
+			i == 0 && (r == ' ' || r == '#'),
+			i == len(v)-1 && r == ' ':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == 0:
+			sb.WriteString(`\00`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// =============================================================================
+// Minimal LDAPv3 client (RFC 4511): simple bind, equality search, unbind.
+// Messages are BER encoded; only the subset needed here is implemented.
+// =============================================================================
+
+// BER tags used by the LDAP messages below.
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berBoolean     = 0x01
+	berEnumerated  = 0x0a
+	berSequence    = 0x30
+	berSet         = 0x31
+
+	ldapBindRequest      = 0x60
+	ldapBindResponse     = 0x61
+	ldapUnbindRequest    = 0x42
+	ldapSearchRequest    = 0x63
+	ldapSearchEntry      = 0x64
+	ldapSearchDone       = 0x65
+	ldapSearchReference  = 0x73
+	ldapSimpleAuth       = 0x80
+	ldapFilterEquality   = 0xa3
+	ldapResultSuccess    = 0
+	ldapResultInvalidCrd = 49
+)
+
+// errLDAPProtocol reports a malformed or unexpected server response.
+var errLDAPProtocol = errors.New("ldap: protocol error")
+
+type ldapConn struct {
+	conn  net.Conn
+	msgID int
+}
+
+func (c *ldapConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *ldapConn) send(op []byte) error {
+	c.msgID++
+	msg := berTLV(berSequence, append(berInt(berInteger, c.msgID), op...))
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+// receive reads one LDAPMessage and returns its protocol op tag and body.
+func (c *ldapConn) receive() (byte, []byte, error) {
+	tag, body, err := readBER(c.conn)
+	if err != nil {
+		return 0, nil, err
+	}
+	if tag != berSequence {
+		return 0, nil, errLDAPProtocol
+	}
+	_, _, rest, err := parseBER(body) // message ID
+	if err != nil {
+		return 0, nil, err
+	}
+	opTag, op, _, err := parseBER(rest)
+	return opTag, op, err
+}
+
+func (c *ldapConn) bind(dn, password string) error {
+	op := berInt(berInteger, 3)
+	op = append(op, berTLV(berOctetString, []byte(dn))...)
+	op = append(op, berTLV(ldapSimpleAuth, []byte(password))...)
+	if err := c.send(berTLV(ldapBindRequest, op)); err != nil {
+		return fmt.Errorf("ldap: bind: %w", err)
+	}
+	tag, body, err := c.receive()
+	if err != nil {
+		return fmt.Errorf("ldap: bind: %w", err)
+	}
+	if tag != ldapBindResponse {
+		return errLDAPProtocol
+	}
+	code, msg, err := parseLDAPResult(body)
+	if err != nil {
+		return err
+	}
+	switch code {
+	case ldapResultSuccess:
+		return nil
+	case ldapResultInvalidCrd:
+		return ErrInvalidCredentials
+	default:
+		return fmt.Errorf("ldap: bind failed (result %d): %s", code, msg)
+	}
+}
+
+// searchValues runs a subtree search under base for (attr=value) and returns
+// every value of returnAttr in the matching entries.
+func (c *ldapConn) searchValues(base, attr, value, returnAttr string) ([]string, error) {
+	filter := berTLV(berOctetString, []byte(attr))
+	filter = append(filter, berTLV(berOctetString, []byte(value))...)
+
+	op := berTLV(berOctetString, []byte(base))
+	op = append(op, berInt(berEnumerated, 2)...) // wholeSubtree
+	op = append(op, berInt(berEnumerated, 0)...) // neverDerefAliases
+	op = append(op, berInt(berInteger, 0)...)    // sizeLimit
+	op = append(op, berInt(berInteger, 0)...)    // timeLimit
+	op = append(op, berTLV(berBoolean, []byte{0})...)
+	op = append(op, berTLV(ldapFilterEquality, filter)...)
+	op = append(op, berTLV(berSequence, berTLV(berOctetString, []byte(returnAttr)))...)
+	if err := c.send(berTLV(ldapSearchRequest, op)); err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for {
+		tag, body, err := c.receive()
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case ldapSearchEntry:
+			vals, err := parseSearchEntry(body, returnAttr)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, vals...)
+		case ldapSearchReference:
+			// Referrals are not followed
+		case ldapSearchDone:
+			code, msg, err := parseLDAPResult(body)
+			if err != nil {
+				return nil, err
+			}
+			if code != ldapResultSuccess {
+				return nil, fmt.Errorf("search failed (result %d): %s", code, msg)
+			}
+			return values, nil
+		default:
+			return nil, errLDAPProtocol
+		}
+	}
+}
+
+func (c *ldapConn) unbind() {
+	_ = c.send(berTLV(ldapUnbindRequest, nil))
+}
+
+// parseLDAPResult extracts resultCode and diagnosticMessage from an LDAPResult.
+func parseLDAPResult(body []byte) (int, string, error) {
+	tag, code, rest, err := parseBER(body)
+	if err != nil || tag != berEnumerated {
+		return 0, "", errLDAPProtocol
+	}
+	_, _, rest, err = parseBER(rest) // matchedDN
+	if err != nil {
+		return 0, "", errLDAPProtocol
+	}
+	_, diag, _, err := parseBER(rest)
+	if err != nil {
+		return 0, "", errLDAPProtocol
+	}
+	return berToInt(code), string(diag), nil
+}
+
+// parseSearchEntry returns the values of attr in a SearchResultEntry.
+func parseSearchEntry(body []byte, attr string) ([]string, error) {
+	_, _, rest, err := parseBER(body) // objectName
+	if err != nil {
+		return nil, errLDAPProtocol
+	}
+	_, attrs, _, err := parseBER(rest)
+	if err != nil {
+		return nil, errLDAPProtocol
+	}
+	var values []string
+	for len(attrs) > 0 {
+		var partial []byte
+		_, partial, attrs, err = parseBER(attrs)
+		if err != nil {
+			return nil, errLDAPProtocol
+		}
+		_, name, rest, err := parseBER(partial)
+		if err != nil {
+			return nil, errLDAPProtocol
+		}
+		if !strings.EqualFold(string(name), attr) {
+			continue
+		}
+		_, set, _, err := parseBER(rest)
+		if err != nil {
+			return nil, errLDAPProtocol
+		}
+		for len(set) > 0 {
+			var v []byte
+			_, v, set, err = parseBER(set)
+			if err != nil {
+				return nil, errLDAPProtocol
+			}
+			values = append(values, string(v))
+		}
+	}
+	return values, nil
+}
+
+// berTLV encodes a BER tag-length-value.
+func berTLV(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	n := len(value)
+	switch {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	case n <= 0xffff:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x84, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+// berInt encodes a non-negative integer with the given tag.
+func berInt(tag byte, v int) []byte {
+	b := []byte{byte(v)}
+	for v >>= 8; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return berTLV(tag, b)
+}
+
+func berToInt(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<8 | int(c)
+	}
+	return n
+}
+
+// parseBER splits the first TLV off data.
+func parseBER(data []byte) (tag byte, value, rest []byte, err error) {
+	if len(data) < 2 {
+		return 0, nil, nil, errLDAPProtocol
+	}
+	tag = data[0]
+	n, hdr := int(data[1]), 2
+	if n&0x80 != 0 {
+		octets := n & 0x7f
+		if octets == 0 || octets > 4 || len(data) < 2+octets {
+			return 0, nil, nil, errLDAPProtocol
+		}
+		n = berToInt(data[2 : 2+octets])
+		hdr += octets
+	}
+	if len(data) < hdr+n {
+		return 0, nil, nil, errLDAPProtocol
+	}
+	return tag, data[hdr : hdr+n], data[hdr+n:], nil
+}
+
+// maxLDAPMessage bounds a single server response.
+const maxLDAPMessage = 16 << 20
+
+// readBER reads one complete TLV from r.
+func readBER(r io.Reader) (byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := int(hdr[1])
+	if n&0x80 != 0 {
+		octets := n & 0x7f
+		if octets == 0 || octets > 4 {
+			return 0, nil, errLDAPProtocol
+		}
+		lenBuf := make([]byte, octets)
+		if _, err := io.ReadFull(r, lenBuf); err != nil {
+			return 0, nil, err
+		}
+		n = berToInt(lenBuf)
+	}
+	if n > maxLDAPMessage {
+		return 0, nil, errLDAPProtocol
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], body, nil
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// fakeLDAPServer is a minimal in-process LDAP server: it accepts simple binds
+// for known DN/password pairs and answers group searches from a fixed table.
+type fakeLDAPServer struct {
+	listener  net.Listener
+	passwords map[string]string   // DN -> password
+	groups    map[string][]string // member DN -> group names
+}
+
+func newFakeLDAPServer(t *testing.T) *fakeLDAPServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeLDAPServer{
+		listener:  l,
+		passwords: map[string]string{},
+		groups:    map[string][]string{},
+	}
+	t.Cleanup(func() { l.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeLDAPServer) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *fakeLDAPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeLDAPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		_, msg, err := readBER(conn)
+		if err != nil {
+			return
+		}
+		_, id, rest, _ := parseBER(msg)
+		opTag, op, _, _ := parseBER(rest)
+		msgID := berToInt(id)
+
+		reply := func(tag byte, body []byte) {
+			conn.Write(berTLV(berSequence, append(berInt(berInteger, msgID), berTLV(tag, body)...)))
+		}
+		result := func(code int) []byte {
+			out := berInt(berEnumerated, code)
+			out = append(out, berTLV(berOctetString, nil)...)
+			return append(out, berTLV(berOctetString, nil)...)
+		}
+
+		switch opTag {
+		case ldapBindRequest:
+			_, _, rest, _ := parseBER(op) // version
+			_, dn, rest, _ := parseBER(rest)
+			_, pw, _, _ := parseBER(rest)
+			code := ldapResultInvalidCrd
+			if want, ok := s.passwords[string(dn)]; ok && want == string(pw) {
+				code = ldapResultSuccess
+			}
+			reply(ldapBindResponse, result(code))
+		case ldapSearchRequest:
+			rest := op
+			for i := 0; i < 6; i++ { // base, scope, deref, size, time, typesOnly
+				_, _, rest, _ = parseBER(rest)
+			}
+			_, filter, _, _ := parseBER(rest)
+			_, _, fRest, _ := parseBER(filter)
+			_, member, _, _ := parseBER(fRest)
+			for _, g := range s.groups[string(member)] {
+				vals := berTLV(berSet, berTLV(berOctetString, []byte(g)))
+				attr := berTLV(berSequence, append(berTLV(berOctetString, []byte("cn")), vals...))
+				entry := berTLV(berOctetString, []byte("cn="+g+",ou=groups,dc=example,dc=com"))
+				entry = append(entry, berTLV(berSequence, attr)...)
+				reply(ldapSearchEntry, entry)
+			}
+			reply(ldapSearchDone, result(ldapResultSuccess))
+		case ldapUnbindRequest:
+			return
+		}
+	}
+}
+
+func newTestLDAPProvider(t *testing.T, srv *fakeLDAPServer) *LDAPProvider {
+	t.Helper()
+	p, err := NewLDAPProvider(LDAPConfig{
+		URL:            srv.url(),
+		UserDNTemplate: "uid=%s,ou=people,dc=example,dc=com",
+		GroupBaseDN:    "ou=groups,dc=example,dc=com",
+		RoleMapping: RoleMapping{
+			Groups: map[string]Role{"db-admins": RoleAdmin, "developers": RoleEditor},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewLDAPProvider: %v", err)
+	}
+	return p
+}
+
+func TestLDAPProvider_AuthenticatePassword(t *testing.T) {
+	srv := newFakeLDAPServer(t)
+	srv.passwords["uid=carol,ou=people,dc=example,dc=com"] = "s3cret"
+	srv.groups["uid=carol,ou=people,dc=example,dc=com"] = []string{"developers", "staff"}
+	p := newTestLDAPProvider(t, srv)
+
+	id, err := p.AuthenticatePassword(t.Context(), "carol", "s3cret")
+	if err != nil {
+		t.Fatalf("AuthenticatePassword: %v", err)
+	}
+	if id.Subject != "uid=carol,ou=people,dc=example,dc=com" {
+		t.Errorf("unexpected subject %q", id.Subject)
+	}
+	if len(id.Groups) != 2 {
+		t.Errorf("expected 2 groups, got %v", id.Groups)
+	}
+	if len(id.Roles) != 1 || id.Roles[0] != RoleEditor {
+		t.Errorf("expected editor role, got %v", id.Roles)
+	}
+
+	if _, err := p.AuthenticatePassword(t.Context(), "carol", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := p.AuthenticatePassword(t.Context(), "carol", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("empty password must be rejected, got %v", err)
+	}
+}
+
+func TestAuthenticator_LDAPLogin(t *testing.T) {
+	srv := newFakeLDAPServer(t)
+	srv.passwords["uid=dave,ou=people,dc=example,dc=com"] = "pw-dave"
+	srv.groups["uid=dave,ou=people,dc=example,dc=com"] = []string{"db-admins"}
+	srv.passwords["uid=erin,ou=people,dc=example,dc=com"] = "pw-erin"
+
+	a, _ := NewAuthenticator(AuthConfig{
+		SecurityEnabled: true,
+		JWTSecret:       []byte("test-secret-at-least-32-bytes!!"),
+	})
+	a.AddProvider(newTestLDAPProvider(t, srv))
+
+	// Local accounts are still checked locally
+	if _, err := a.CreateUser("local", "password123", []Role{RoleViewer}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, _, err := a.Authenticate("local", "password123", "", ""); err != nil {
+		t.Errorf("local login failed: %v", err)
+	}
+
+	tok, user, err := a.Authenticate("dave", "pw-dave", "10.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("LDAP login failed: %v", err)
+	}
+	if !user.HasRole(RoleAdmin) {
+		t.Errorf("expected admin role from group mapping, got %v", user.Roles)
+	}
+	claims, err := a.ValidateToken(tok.AccessToken)
+	if err != nil || claims.Username != "dave" {
+		t.Errorf("token for LDAP user invalid: %v %+v", err, claims)
+	}
+
+	// Provisioned account must not bypass the directory on later logins
+	if _, _, err := a.Authenticate("dave", "wrong", "", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	// Valid directory user without a mapped group is rejected
+	if _, _, err := a.Authenticate("erin", "pw-erin", "", ""); !errors.Is(err, ErrProviderRejected) {
+		t.Errorf("expected ErrProviderRejected, got %v", err)
+	}
+}
+
+func TestEscapeDN(t *testing.T) {
+	tests := map[string]string{
+		"alice":       "alice",
+		"a,b":         `a\,b`,
+		" lead":       `\ lead`,
+		"#hash":       `\#hash`,
+		"trail ":      `trail\ `,
+		"x=y+z":       `x\=y\+z`,
+		`q"uote\back`: `q\"uote\\back`,
+	}
+	for in, want := range tests {
+		if got := escapeDN(in); got != want {
+			t.Errorf("escapeDN(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
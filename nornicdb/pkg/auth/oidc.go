@@ -0,0 +1,371 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha512" // registers SHA-384/512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCConfig configures an OpenID Connect token-validating identity provider.
+//
+// Bearer tokens (ID tokens or JWT access tokens) are verified against the
+// issuer's JWKS: signature (RS256/384/512, ES256/384), issuer, audience,
+// expiry and not-before. Group claims are mapped to roles.
+//
+// Example:
+//
+//	provider, err := auth.NewOIDCProvider(auth.OIDCConfig{
+//		Issuer:   "https://login.example.com/realms/corp",
+//		Audience: "nornicdb",
+//		RoleMapping: auth.RoleMapping{
+//			Groups:      map[string]auth.Role{"db-admins": auth.RoleAdmin},
+//			DefaultRole: auth.RoleViewer,
+//		},
+//	})
+type OIDCConfig struct {
+	Name          string        // Provider name (default "oidc")
+	Issuer        string        // Expected "iss" claim; also used for discovery
+	Audience      string        // Expected "aud" claim (client ID)
+	JWKSURL       string        // JWKS endpoint ("" = discover from Issuer)
+	UsernameClaim string        // Claim used as username (default "preferred_username", falls back to "sub")
+	GroupsClaim   string        // Claim holding group names (default "groups")
+	RoleMapping   RoleMapping   // Group to role mapping
+	ClockSkew     time.Duration // Allowed clock skew for exp/nbf (default 1m)
+	JWKSCacheTTL  time.Duration // How long fetched keys are trusted (default 1h)
+	HTTPClient    *http.Client  // Client for discovery/JWKS (default 10s timeout)
+}
+
+// OIDCProvider validates tokens issued by an OpenID Connect provider.
+type OIDCProvider struct {
+	config OIDCConfig
+
+	mu        sync.Mutex
+	jwksURL   string
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// NewOIDCProvider validates config and returns an OIDC provider.
+// Discovery and key fetching happen lazily on first use.
+func NewOIDCProvider(config OIDCConfig) (*OIDCProvider, error) {
+	if config.Issuer == "" {
+		return nil, fmt.Errorf("oidc: issuer is required")
+	}
+	if config.Audience == "" {
+		return nil, fmt.Errorf("oidc: audience is required")
+	}
+	if config.Name == "" {
+		config.Name = "oidc"
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "preferred_username"
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if config.ClockSkew == 0 {
+		config.ClockSkew = time.Minute
+	}
+	if config.JWKSCacheTTL == 0 {
+		config.JWKSCacheTTL = time.Hour
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OIDCProvider{config: config, jwksURL: config.JWKSURL}, nil
+}
+
+// Name implements IdentityProvider.
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+// ValidateToken implements TokenProvider.
+func (p *OIDCProvider) ValidateToken(ctx context.Context, token string) (*ExternalIdentity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := p.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	sub, _ := claims["sub"].(string)
+	username, _ := claims[p.config.UsernameClaim].(string)
+	if username == "" {
+		username = sub
+	}
+	email, _ := claims["email"].(string)
+	groups := stringList(claims[p.config.GroupsClaim])
+	exp, _ := claims["exp"].(float64)
+
+	return &ExternalIdentity{
+		Provider: p.config.Name,
+		Subject:  sub,
+		Username: username,
+		Email:    email,
+		Groups:   groups,
+		Roles:    p.config.RoleMapping.Map(groups),
+		Expiry:   int64(exp),
+	}, nil
+}
+
+// checkClaims validates the registered claims of a verified token.
+func (p *OIDCProvider) checkClaims(claims map[string]any) error {
+	if iss, _ := claims["iss"].(string); iss != p.config.Issuer {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, iss)
+	}
+	audOK := false
+	for _, aud := range stringList(claims["aud"]) {
+		if aud == p.config.Audience {
+			audOK = true
+			break
+		}
+	}
+	if !audOK {
+		return fmt.Errorf("%w: audience mismatch", ErrInvalidToken)
+	}
+
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(p.config.ClockSkew)) {
+		return ErrSessionExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(p.config.ClockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	}
+	return nil
+}
+
+// key returns the signing key for kid, refreshing the JWKS when the key is
+// unknown or the cache has expired. Unknown-key refreshes are limited to one
+// per minute so forged kids cannot hammer the issuer.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	age := time.Since(p.fetchedAt)
+	if key, ok := p.keys[kid]; ok && age < p.config.JWKSCacheTTL {
+		return key, nil
+	}
+	if p.keys == nil || age >= p.config.JWKSCacheTTL || age >= time.Minute {
+		if err := p.refreshKeys(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, kid)
+}
+
+// refreshKeys fetches the JWKS. Called with p.mu held.
+func (p *OIDCProvider) refreshKeys(ctx context.Context) error {
+	if p.jwksURL == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+		if err := p.getJSON(ctx, wellKnown, &discovery); err != nil {
+			return fmt.Errorf("oidc: discovery failed: %w", err)
+		}
+		if discovery.Issuer != p.config.Issuer {
+			return fmt.Errorf("oidc: discovery issuer %q does not match %q", discovery.Issuer, p.config.Issuer)
+		}
+		if discovery.JWKSURI == "" {
+			return fmt.Errorf("oidc: discovery document has no jwks_uri")
+		}
+		p.jwksURL = discovery.JWKSURI
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.jwksURL, &jwks); err != nil {
+		return fmt.Errorf("oidc: fetching JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+	p.keys = keys
+	p.fetchedAt = time.Now()
+	return nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// jsonWebKey is a single RFC 7517 key (RSA or EC public key).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// verifyJWTSignature checks an asymmetric JWT signature. Symmetric and "none"
+// algorithms are rejected: external tokens must be verifiable with public keys.
+func verifyJWTSignature(alg string, key crypto.PublicKey, message string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+	}
+	h := hash.New()
+	h.Write([]byte(message))
+	digest := h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("%w: algorithm %s does not match RSA key", ErrInvalidToken, alg)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, sig); err != nil {
+			return ErrInvalidToken
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") || len(sig)%2 != 0 {
+			return fmt.Errorf("%w: algorithm %s does not match EC key", ErrInvalidToken, alg)
+		}
+		r := new(big.Int).SetBytes(sig[:len(sig)/2])
+		s := new(big.Int).SetBytes(sig[len(sig)/2:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return ErrInvalidToken
+		}
+	default:
+		return errors.New("oidc: unsupported key")
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// stringList normalizes a claim that may be a string or a list of strings.
+func stringList(v any) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
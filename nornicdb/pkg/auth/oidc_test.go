@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testIssuer serves OIDC discovery and a JWKS for a single RSA key.
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	iss := &testIssuer{key: key, kid: "test-key"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   iss.server.URL,
+			"jwks_uri": iss.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": iss.kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	iss.server = httptest.NewServer(mux)
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+func (iss *testIssuer) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": iss.kid})
+	payload, _ := json.Marshal(claims)
+	msg := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(msg))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, iss.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return msg + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (iss *testIssuer) claims(overrides map[string]any) map[string]any {
+	c := map[string]any{
+		"iss":                iss.server.URL,
+		"aud":                "nornicdb",
+		"sub":                "user-123",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"groups":             []string{"db-admins"},
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range overrides {
+		c[k] = v
+	}
+	return c
+}
+
+func TestOIDCProvider_ValidateToken(t *testing.T) {
+	iss := newTestIssuer(t)
+	provider, err := NewOIDCProvider(OIDCConfig{
+		Issuer:   iss.server.URL,
+		Audience: "nornicdb",
+		RoleMapping: RoleMapping{
+			Groups: map[string]Role{"db-admins": RoleAdmin},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		id, err := provider.ValidateToken(t.Context(), iss.sign(t, iss.claims(nil)))
+		if err != nil {
+			t.Fatalf("ValidateToken: %v", err)
+		}
+		if id.Username != "alice" || id.Subject != "user-123" || id.Email != "alice@example.com" {
+			t.Errorf("unexpected identity: %+v", id)
+		}
+		if len(id.Roles) != 1 || id.Roles[0] != RoleAdmin {
+			t.Errorf("expected admin role, got %v", id.Roles)
+		}
+	})
+
+	rejects := []struct {
+		name   string
+		claims map[string]any
+	}{
+		{"wrong audience", map[string]any{"aud": "other-app"}},
+		{"wrong issuer", map[string]any{"iss": "https://evil.example.com"}},
+		{"expired", map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}},
+		{"not yet valid", map[string]any{"nbf": time.Now().Add(time.Hour).Unix()}},
+	}
+	for _, tt := range rejects {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := provider.ValidateToken(t.Context(), iss.sign(t, iss.claims(tt.claims))); err == nil {
+				t.Error("expected token to be rejected")
+			}
+		})
+	}
+
+	t.Run("tampered signature", func(t *testing.T) {
+		token := iss.sign(t, iss.claims(nil))
+		other := iss.sign(t, iss.claims(map[string]any{"groups": []string{"nobody"}}))
+		forged := token[:len(token)-10] + other[len(other)-10:]
+		if _, err := provider.ValidateToken(t.Context(), forged); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("alg none rejected", func(t *testing.T) {
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"test-key"}`))
+		payload, _ := json.Marshal(iss.claims(nil))
+		token := header + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+		if _, err := provider.ValidateToken(t.Context(), token); err == nil {
+			t.Error("expected alg=none to be rejected")
+		}
+	})
+}
+
+func TestAuthenticator_ValidateToken_OIDC(t *testing.T) {
+	iss := newTestIssuer(t)
+	provider, _ := NewOIDCProvider(OIDCConfig{
+		Issuer:      iss.server.URL,
+		Audience:    "nornicdb",
+		RoleMapping: RoleMapping{DefaultRole: RoleViewer},
+	})
+
+	a, _ := NewAuthenticator(AuthConfig{
+		SecurityEnabled: true,
+		JWTSecret:       []byte("test-secret-at-least-32-bytes!!"),
+	})
+	a.AddProvider(provider)
+
+	claims, err := a.ValidateToken("Bearer " + iss.sign(t, iss.claims(map[string]any{"groups": []string{"unmapped"}})))
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.Username != "alice" || claims.Sub != "oidc:user-123" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != string(RoleViewer) {
+		t.Errorf("expected default viewer role, got %v", claims.Roles)
+	}
+
+	// Local tokens keep working alongside the provider
+	if _, err := a.CreateUser("bob", "password123", []Role{RoleEditor}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	tok, _, err := a.Authenticate("bob", "password123", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if _, err := a.ValidateToken(tok.AccessToken); err != nil {
+		t.Errorf("local token rejected: %v", err)
+	}
+}
+
+func TestParseRoleMapping(t *testing.T) {
+	m, err := ParseRoleMapping([]string{"DB-Admins=admin", "devs=editor"}, "viewer")
+	if err != nil {
+		t.Fatalf("ParseRoleMapping: %v", err)
+	}
+	roles := m.Map([]string{"db-admins", "devs"})
+	if len(roles) != 2 || roles[0] != RoleAdmin || roles[1] != RoleEditor {
+		t.Errorf("unexpected roles: %v", roles)
+	}
+	if roles := m.Map([]string{"other"}); len(roles) != 1 || roles[0] != RoleViewer {
+		t.Errorf("expected default role, got %v", roles)
+	}
+
+	if _, err := ParseRoleMapping([]string{"devs=superuser"}, ""); err == nil {
+		t.Error("expected error for unknown role")
+	}
+	if _, err := ParseRoleMapping([]string{"devs"}, ""); err == nil {
+		t.Error("expected error for missing role")
+	}
+}
@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrProviderRejected is returned when an external identity provider
+// authenticates a user who maps to no NornicDB role.
+var ErrProviderRejected = errors.New("identity provider user has no mapped role")
+
+// providerTimeout bounds calls to external identity providers.
+const providerTimeout = 10 * time.Second
+
+// ExternalIdentity is a user identity asserted by an external provider
+// (LDAP directory, OIDC issuer).
+type ExternalIdentity struct {
+	Provider string   // Provider name, e.g. "ldap" or "oidc"
+	Subject  string   // Stable provider-side ID (DN, "sub" claim)
+	Username string   // Login name
+	Email    string   // Email address, if known
+	Groups   []string // Group names used for role mapping
+	Roles    []Role   // Mapped NornicDB roles
+	Expiry   int64    // Unix expiry of the external credential (0 = none)
+}
+
+// IdentityProvider is an external source of user identities.
+//
+// Providers implement PasswordProvider (username/password, e.g. LDAP bind),
+// TokenProvider (bearer tokens, e.g. OIDC ID/access tokens), or both, and are
+// registered with Authenticator.AddProvider.
+type IdentityProvider interface {
+	Name() string
+}
+
+// PasswordProvider verifies username/password credentials.
+type PasswordProvider interface {
+	IdentityProvider
+	AuthenticatePassword(ctx context.Context, username, password string) (*ExternalIdentity, error)
+}
+
+// TokenProvider validates bearer tokens issued by an external party.
+type TokenProvider interface {
+	IdentityProvider
+	ValidateToken(ctx context.Context, token string) (*ExternalIdentity, error)
+}
+
+// RoleMapping maps external group names to NornicDB roles.
+//
+// Group names are matched case-insensitively. Users in several mapped groups
+// receive every mapped role. DefaultRole, if set, is granted to users who
+// match no group; otherwise such users are rejected.
+//
+// Example:
+//
+//	mapping := auth.RoleMapping{
+//		Groups: map[string]auth.Role{
+//			"db-admins":  auth.RoleAdmin,
+//			"developers": auth.RoleEditor,
+//		},
+//		DefaultRole: auth.RoleViewer,
+//	}
+type RoleMapping struct {
+	Groups      map[string]Role
+	DefaultRole Role
+}
+
+// Map returns the roles for the given groups.
+func (m RoleMapping) Map(groups []string) []Role {
+	var roles []Role
+	seen := make(map[Role]bool)
+	for _, g := range groups {
+		for name, role := range m.Groups {
+			if strings.EqualFold(name, g) && !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+	if len(roles) == 0 && m.DefaultRole != "" && m.DefaultRole != RoleNone {
+		roles = append(roles, m.DefaultRole)
+	}
+	return roles
+}
+
+// ParseRoleMapping parses "group=role" entries into a RoleMapping.
+//
+// Example:
+//
+//	mapping, err := auth.ParseRoleMapping([]string{"db-admins=admin", "devs=editor"}, "viewer")
+func ParseRoleMapping(entries []string, defaultRole string) (RoleMapping, error) {
+	m := RoleMapping{Groups: make(map[string]Role, len(entries))}
+	for _, entry := range entries {
+		group, roleStr, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(group) == "" {
+			return m, fmt.Errorf("invalid role mapping %q: expected group=role", entry)
+		}
+		role, err := RoleFromString(strings.TrimSpace(roleStr))
+		if err != nil {
+			return m, fmt.Errorf("invalid role mapping %q: %w", entry, err)
+		}
+		m.Groups[strings.TrimSpace(group)] = role
+	}
+	if defaultRole != "" {
+		role, err := RoleFromString(defaultRole)
+		if err != nil {
+			return m, fmt.Errorf("invalid default role: %w", err)
+		}
+		m.DefaultRole = role
+	}
+	return m, nil
+}
+
+// AddProvider registers an external identity provider.
+//
+// Password providers are consulted, in registration order, for usernames that
+// do not belong to a local account. Users authenticated this way are
+// provisioned as external accounts on first login and their roles are
+// refreshed from the provider on every login.
+//
+// Token providers are consulted for bearer tokens that are not NornicDB-issued
+// JWTs, so tokens from an SSO issuer can be used directly on HTTP and Bolt.
+//
+// Example:
+//
+//	ldapProvider, _ := auth.NewLDAPProvider(auth.LDAPConfig{...})
+//	authenticator.AddProvider(ldapProvider)
+//
+//	oidcProvider, _ := auth.NewOIDCProvider(auth.OIDCConfig{...})
+//	authenticator.AddProvider(oidcProvider)
+func (a *Authenticator) AddProvider(p IdentityProvider) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.providers = append(a.providers, p)
+}
+
+// Providers returns the names of registered identity providers.
+func (a *Authenticator) Providers() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, len(a.providers))
+	for i, p := range a.providers {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+func (a *Authenticator) passwordProviders() []PasswordProvider {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var out []PasswordProvider
+	for _, p := range a.providers {
+		if pp, ok := p.(PasswordProvider); ok {
+			out = append(out, pp)
+		}
+	}
+	return out
+}
+
+func (a *Authenticator) tokenProviders() []TokenProvider {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var out []TokenProvider
+	for _, p := range a.providers {
+		if tp, ok := p.(TokenProvider); ok {
+			out = append(out, tp)
+		}
+	}
+	return out
+}
+
+// isExternalLogin reports whether username should be authenticated by an
+// external provider rather than against the local user store.
+func (a *Authenticator) isExternalLogin(username string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	hasPasswordProvider := false
+	for _, p := range a.providers {
+		if _, ok := p.(PasswordProvider); ok {
+			hasPasswordProvider = true
+			break
+		}
+	}
+	if !hasPasswordProvider {
+		return false
+	}
+	user, exists := a.users[username]
+	return !exists || user.Metadata[metaProvider] != ""
+}
+
+// metaProvider is the User.Metadata key naming the provider of an external account.
+const metaProvider = "auth_provider"
+
+// authenticateExternal verifies credentials with the registered password
+// providers and provisions or refreshes the matching local account.
+func (a *Authenticator) authenticateExternal(username, password, ipAddress, userAgent string) (*TokenResponse, *User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), providerTimeout)
+	defer cancel()
+
+	var identity *ExternalIdentity
+	var lastErr error = ErrInvalidCredentials
+	for _, p := range a.passwordProviders() {
+		id, err := p.AuthenticatePassword(ctx, username, password)
+		if err == nil {
+			identity = id
+			break
+		}
+		lastErr = err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if identity == nil {
+		a.logAudit(AuditEvent{
+			EventType: "login",
+			Username:  username,
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+			Success:   false,
+			Details:   fmt.Sprintf("external authentication failed: %v", lastErr),
+		})
+		return nil, nil, ErrInvalidCredentials
+	}
+	if len(identity.Roles) == 0 {
+		a.logAudit(AuditEvent{
+			EventType: "login",
+			Username:  username,
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+			Success:   false,
+			Details:   fmt.Sprintf("%s: no role mapped for groups %v", identity.Provider, identity.Groups),
+		})
+		return nil, nil, ErrProviderRejected
+	}
+
+	user := a.provisionExternalUser(username, identity)
+	if user.Disabled {
+		a.logAudit(AuditEvent{
+			EventType: "login",
+			Username:  username,
+			UserID:    user.ID,
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+			Success:   false,
+			Details:   "account disabled",
+		})
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	token, err := a.generateJWT(user)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	response := &TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		Scope:       "default",
+	}
+	if a.config.TokenExpiry > 0 {
+		response.ExpiresIn = int64(a.config.TokenExpiry.Seconds())
+	}
+
+	a.logAudit(AuditEvent{
+		EventType: "login",
+		Username:  username,
+		UserID:    user.ID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Success:   true,
+		Details:   "token generated via " + identity.Provider,
+	})
+
+	return response, a.copyUserSafe(user), nil
+}
+
+// provisionExternalUser creates or refreshes the local record for an external
+// identity. Called with a.mu held.
+func (a *Authenticator) provisionExternalUser(username string, identity *ExternalIdentity) *User {
+	now := time.Now()
+	user, exists := a.users[username]
+	if !exists {
+		user = &User{
+			ID:        generateID(),
+			Username:  username,
+			CreatedAt: now,
+			Metadata:  make(map[string]string),
+		}
+		a.users[username] = user
+	}
+	if user.Metadata == nil {
+		user.Metadata = make(map[string]string)
+	}
+	user.Metadata[metaProvider] = identity.Provider
+	user.Metadata["auth_subject"] = identity.Subject
+	if identity.Email != "" {
+		user.Email = identity.Email
+	}
+	user.Roles = append([]Role(nil), identity.Roles...)
+	user.LastLogin = now
+	user.UpdatedAt = now
+	return user
+}
+
+// validateExternalToken checks token against registered token providers.
+func (a *Authenticator) validateExternalToken(token string) (*JWTClaims, error) {
+	providers := a.tokenProviders()
+	if len(providers) == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerTimeout)
+	defer cancel()
+
+	var lastErr error = ErrInvalidToken
+	for _, p := range providers {
+		identity, err := p.ValidateToken(ctx, token)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(identity.Roles) == 0 {
+			return nil, ErrProviderRejected
+		}
+		roles := make([]string, len(identity.Roles))
+		for i, r := range identity.Roles {
+			roles[i] = string(r)
+		}
+		return &JWTClaims{
+			Sub:      identity.Provider + ":" + identity.Subject,
+			Email:    identity.Email,
+			Username: identity.Username,
+			Roles:    roles,
+			Exp:      identity.Expiry,
+		}, nil
+	}
+	if errors.Is(lastErr, ErrInvalidToken) {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("%w: %v", ErrInvalidToken, lastErr)
+}
@@ -0,0 +1,337 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/orneryd/nornicdb/pkg/value"
+)
+
+// maxChunkSize is the largest Bolt chunk; longer messages span several chunks.
+const maxChunkSize = 0xFFFF
+
+// packStreamEncoder appends PackStream values to a single byte buffer.
+//
+// Unlike encodePackStreamValue, which returns a new slice per value and copies
+// it into its parent, the encoder writes every value in place. Nodes are
+// written straight from their result map (or *storage.Node) without building
+// a filtered property map, and typed slices without converting to []any.
+//
+// Lifetime: buf usually comes from pool.GetByteBuffer. The caller returns it
+// with pool.PutByteBuffer once the bytes have been copied to the connection
+// writer; nothing retains the encoded bytes past that point.
+type packStreamEncoder struct {
+	buf []byte
+}
+
+func (e *packStreamEncoder) writeNull() {
+	e.buf = append(e.buf, 0xC0)
+}
+
+func (e *packStreamEncoder) writeBool(b bool) {
+	if b {
+		e.buf = append(e.buf, 0xC3)
+	} else {
+		e.buf = append(e.buf, 0xC2)
+	}
+}
+
+func (e *packStreamEncoder) writeInt(val int64) {
+	switch {
+	case val >= -16 && val <= 127:
+		e.buf = append(e.buf, byte(val))
+	case val >= math.MinInt8 && val < -16:
+		e.buf = append(e.buf, 0xC8, byte(val))
+	case val >= math.MinInt16 && val <= math.MaxInt16:
+		e.buf = append(e.buf, 0xC9, byte(val>>8), byte(val))
+	case val >= math.MinInt32 && val <= math.MaxInt32:
+		e.buf = append(e.buf, 0xCA)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(val))
+	default:
+		e.buf = append(e.buf, 0xCB)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(val))
+	}
+}
+
+func (e *packStreamEncoder) writeFloat(f float64) {
+	e.buf = append(e.buf, 0xC1)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(f))
+}
+
+func (e *packStreamEncoder) writeString(s string) {
+	e.writeHeader(len(s), 0x80, 0xD0)
+	e.buf = append(e.buf, s...)
+}
+
+func (e *packStreamEncoder) writeListHeader(size int) {
+	e.writeHeader(size, 0x90, 0xD4)
+}
+
+func (e *packStreamEncoder) writeMapHeader(size int) {
+	e.writeHeader(size, 0xA0, 0xD8)
+}
+
+// writeHeader writes a size marker: tiny (high nibble) below 16, otherwise
+// the 8, 16 or 32 bit form starting at marker8.
+func (e *packStreamEncoder) writeHeader(size int, tiny, marker8 byte) {
+	switch {
+	case size < 16:
+		e.buf = append(e.buf, tiny+byte(size))
+	case size < 256:
+		e.buf = append(e.buf, marker8, byte(size))
+	case size < 65536:
+		e.buf = append(e.buf, marker8+1, byte(size>>8), byte(size))
+	default:
+		e.buf = append(e.buf, marker8+2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(size))
+	}
+}
+
+func (e *packStreamEncoder) writeList(items []any) {
+	e.writeListHeader(len(items))
+	for _, item := range items {
+		e.writeValue(item)
+	}
+}
+
+func (e *packStreamEncoder) writeMap(m map[string]any) {
+	e.writeMapHeader(len(m))
+	for k, v := range m {
+		e.writeString(k)
+		e.writeValue(v)
+	}
+}
+
+// writeValue encodes v with the same type mapping as encodePackStreamValue.
+// Unknown types are written as null.
+func (e *packStreamEncoder) writeValue(v any) {
+	switch val := v.(type) {
+	case nil:
+		e.writeNull()
+	case bool:
+		e.writeBool(val)
+	// All integer types - encode as INT64 for Neo4j driver compatibility
+	case int:
+		e.writeInt(int64(val))
+	case int8:
+		e.writeInt(int64(val))
+	case int16:
+		e.writeInt(int64(val))
+	case int32:
+		e.writeInt(int64(val))
+	case int64:
+		e.writeInt(val)
+	case uint:
+		e.writeInt(int64(val))
+	case uint8:
+		e.writeInt(int64(val))
+	case uint16:
+		e.writeInt(int64(val))
+	case uint32:
+		e.writeInt(int64(val))
+	case uint64:
+		e.writeInt(int64(val))
+	case float32:
+		e.writeFloat(float64(val))
+	case float64:
+		e.writeFloat(val)
+	case string:
+		e.writeString(val)
+	// List types are written element by element, without a []any copy
+	case []any:
+		e.writeList(val)
+	case []string:
+		e.writeListHeader(len(val))
+		for _, s := range val {
+			e.writeString(s)
+		}
+	case []int:
+		e.writeListHeader(len(val))
+		for _, n := range val {
+			e.writeInt(int64(n))
+		}
+	case []int64:
+		e.writeListHeader(len(val))
+		for _, n := range val {
+			e.writeInt(n)
+		}
+	case []float64:
+		e.writeListHeader(len(val))
+		for _, f := range val {
+			e.writeFloat(f)
+		}
+	case []float32:
+		e.writeListHeader(len(val))
+		for _, f := range val {
+			e.writeFloat(float64(f))
+		}
+	case []map[string]any:
+		e.writeListHeader(len(val))
+		for _, m := range val {
+			e.writeValue(m)
+		}
+	case map[string]any:
+		// Check if this is a node (has _nodeId and labels)
+		if nodeID, ok := val["_nodeId"]; ok {
+			if labels, ok := val["labels"]; ok {
+				e.writeNodeMap(nodeID, labels, val)
+				return
+			}
+		}
+		e.writeMap(val)
+	case value.Value:
+		e.writeTyped(val)
+	case []value.Value:
+		e.writeTypedList(val)
+	case value.Map:
+		e.writeTypedMap(val)
+	case *storage.Node:
+		e.writeNode(val)
+	case *storage.Edge:
+		e.writeRelationship(val)
+	case packedValue:
+		e.buf = append(e.buf, val...)
+	default:
+		e.writeNull()
+	}
+}
+
+// packedValue is a value already encoded as PackStream, written verbatim.
+// Results recorded for idempotent replay hold their fields this way.
+type packedValue []byte
+
+// writeTyped writes a typed value, branching on its kind instead of its Go
+// type. KindOther values go through writeValue.
+func (e *packStreamEncoder) writeTyped(v value.Value) {
+	switch v.Kind() {
+	case value.KindNull:
+		e.writeNull()
+	case value.KindBool:
+		b, _ := v.AsBool()
+		e.writeBool(b)
+	case value.KindInt:
+		n, _ := v.AsInt()
+		e.writeInt(n)
+	case value.KindFloat:
+		f, _ := v.AsFloat()
+		e.writeFloat(f)
+	case value.KindString:
+		s, _ := v.AsString()
+		e.writeString(s)
+	case value.KindList:
+		items, _ := v.AsList()
+		e.writeTypedList(items)
+	case value.KindMap:
+		m, _ := v.AsMap()
+		e.writeTypedMap(m)
+	default:
+		e.writeValue(v.Any())
+	}
+}
+
+func (e *packStreamEncoder) writeTypedList(items []value.Value) {
+	e.writeListHeader(len(items))
+	for _, item := range items {
+		e.writeTyped(item)
+	}
+}
+
+// writeTypedMap writes a typed map, as a Bolt Node structure when it holds
+// _nodeId and labels like the untyped node maps.
+func (e *packStreamEncoder) writeTypedMap(m value.Map) {
+	id, isNode := m["_nodeId"]
+	labels, hasLabels := m["labels"]
+	if !isNode || !hasLabels {
+		e.writeMapHeader(len(m))
+		for k, v := range m {
+			e.writeString(k)
+			e.writeTyped(v)
+		}
+		return
+	}
+
+	e.buf = append(e.buf, 0xB3, 0x4E)
+	idStr, _ := id.AsString()
+	e.writeInt(boltID(idStr))
+	if items, ok := labels.AsList(); ok {
+		e.writeTypedList(items)
+	} else {
+		e.writeListHeader(0)
+	}
+	e.writeMapHeader(len(m) - 2)
+	for k, v := range m {
+		if k == "_nodeId" || k == "labels" {
+			continue
+		}
+		e.writeString(k)
+		e.writeTyped(v)
+	}
+}
+
+// writeNodeMap writes a node result map, which must hold _nodeId and labels,
+// as a Bolt Node structure (signature 0x4E) - the same bytes as encodeNode.
+// Properties are the map's other entries, written directly from the map.
+func (e *packStreamEncoder) writeNodeMap(nodeID, labels any, nodeMap map[string]any) {
+	e.buf = append(e.buf, 0xB3, 0x4E)
+	idStr, _ := nodeID.(string)
+	e.writeInt(boltID(idStr))
+
+	switch l := labels.(type) {
+	case []string, []any:
+		e.writeValue(l)
+	default:
+		e.writeListHeader(0)
+	}
+
+	e.writeMapHeader(len(nodeMap) - 2) // all but _nodeId and labels
+	for k, v := range nodeMap {
+		if k == "_nodeId" || k == "labels" {
+			continue
+		}
+		e.writeString(k)
+		e.writeValue(v)
+	}
+}
+
+// writeNode writes a storage node as a Bolt Node structure.
+func (e *packStreamEncoder) writeNode(node *storage.Node) {
+	if node == nil {
+		e.writeNull()
+		return
+	}
+	e.buf = append(e.buf, 0xB3, 0x4E)
+	e.writeInt(boltID(string(node.ID)))
+	e.writeValue(node.Labels)
+	e.writeMap(node.Properties)
+}
+
+// writeRelationship writes a storage edge as a Bolt Relationship structure
+// (signature 0x52): id, start node id, end node id, type, properties.
+func (e *packStreamEncoder) writeRelationship(edge *storage.Edge) {
+	if edge == nil {
+		e.writeNull()
+		return
+	}
+	e.buf = append(e.buf, 0xB5, 0x52)
+	e.writeInt(boltID(string(edge.ID)))
+	e.writeInt(boltID(string(edge.StartNode)))
+	e.writeInt(boltID(string(edge.EndNode)))
+	e.writeString(edge.Type)
+	e.writeMap(edge.Properties)
+}
+
+// writeRecord writes a complete RECORD message for one result row.
+func (e *packStreamEncoder) writeRecord(fields []any) {
+	e.buf = append(e.buf, 0xB1, MsgRecord)
+	e.writeList(fields)
+}
+
+// boltID maps a NornicDB string ID to the int64 ID Neo4j drivers expect.
+func boltID(id string) int64 {
+	var n int64
+	for _, c := range id {
+		n = n*31 + int64(c)
+	}
+	return n
+}
@@ -0,0 +1,228 @@
+package bolt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/orneryd/nornicdb/pkg/value"
+)
+
+func encodeStreaming(v any) []byte {
+	var enc packStreamEncoder
+	enc.writeValue(v)
+	return enc.buf
+}
+
+func TestPackStreamEncoderMatchesLegacy(t *testing.T) {
+	// Maps hold at most one key so iteration order cannot differ
+	values := []any{
+		nil, true, false,
+		0, -16, -17, 127, 128, -129, 40000, -40000, int64(math.MaxInt64), int64(math.MinInt64),
+		int8(-5), int16(300), int32(-70000), uint(7), uint8(200), uint16(60000), uint32(1 << 31), uint64(1 << 40),
+		float32(1.5), 3.14159,
+		"", "short", strings.Repeat("x", 15), strings.Repeat("y", 200), strings.Repeat("z", 70000),
+		[]any{}, []any{1, "two", 3.0, nil}, make([]any, 300),
+		[]string{"a", "b"}, []int{1, 2, 300}, []int64{-1, 1 << 33}, []float64{0.5}, []float32{0.25},
+		[]map[string]any{{"k": 1}, {}},
+		map[string]any{}, map[string]any{"nested": map[string]any{"list": []any{1, 2}}},
+		map[string]any{"_nodeId": "n1", "labels": []string{"Person", "Admin"}},
+		map[string]any{"_nodeId": "n2", "labels": []any{"Person"}, "name": "Alice"},
+		map[string]any{"_nodeId": "n3", "labels": nil, "age": 30},
+		struct{}{},
+	}
+	for i, v := range values {
+		legacy := encodePackStreamValue(v)
+		streaming := encodeStreaming(v)
+		if !bytes.Equal(legacy, streaming) {
+			t.Errorf("value %d (%T): streaming encoding % x, legacy % x", i, v, head(streaming), head(legacy))
+		}
+	}
+}
+
+func TestPackStreamEncoderRecord(t *testing.T) {
+	row := []any{"alice", int64(42), []string{"a"}, map[string]any{"k": true}}
+	var enc packStreamEncoder
+	enc.writeRecord(row)
+
+	want := append([]byte{0xB1, MsgRecord}, encodePackStreamList(row)...)
+	if !bytes.Equal(enc.buf, want) {
+		t.Errorf("writeRecord() = % x, want % x", enc.buf, want)
+	}
+}
+
+func TestPackStreamEncoderLargeCollections(t *testing.T) {
+	// 32-bit size markers, which the legacy encoder cannot produce
+	list := make([]any, 70000)
+	enc := packStreamEncoder{}
+	enc.writeList(list)
+	if !bytes.Equal(enc.buf[:5], []byte{0xD6, 0x00, 0x01, 0x11, 0x70}) {
+		t.Errorf("list header = % x, want d6 00 01 11 70", enc.buf[:5])
+	}
+	decoded, _, err := decodePackStreamList(enc.buf, 0)
+	if err != nil {
+		t.Fatalf("decodePackStreamList() error = %v", err)
+	}
+	if len(decoded) != len(list) {
+		t.Errorf("decoded %d items, want %d", len(decoded), len(list))
+	}
+}
+
+func TestPackStreamEncoderStorageTypes(t *testing.T) {
+	node := &storage.Node{
+		ID:         "n1",
+		Labels:     []string{"Person"},
+		Properties: map[string]any{"name": "Alice"},
+		Embedding:  []float32{0.1, 0.2},
+	}
+	got := encodeStreaming(node)
+	want := encodePackStreamValue(map[string]any{"_nodeId": "n1", "labels": []string{"Person"}, "name": "Alice"})
+	if !bytes.Equal(got, want) {
+		t.Errorf("storage node = % x, want % x", got, want)
+	}
+
+	edge := &storage.Edge{
+		ID:         "e1",
+		StartNode:  "n1",
+		EndNode:    "n2",
+		Type:       "KNOWS",
+		Properties: map[string]any{"since": 2020},
+	}
+	got = encodeStreaming(edge)
+	want = []byte{0xB5, 0x52}
+	want = append(want, encodePackStreamInt(boltID("e1"))...)
+	want = append(want, encodePackStreamInt(boltID("n1"))...)
+	want = append(want, encodePackStreamInt(boltID("n2"))...)
+	want = append(want, encodePackStreamString("KNOWS")...)
+	want = append(want, encodePackStreamMap(map[string]any{"since": 2020})...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("storage edge = % x, want % x", got, want)
+	}
+
+	var nilNode *storage.Node
+	if got := encodeStreaming(nilNode); !bytes.Equal(got, []byte{0xC0}) {
+		t.Errorf("nil node = % x, want c0", got)
+	}
+}
+
+func TestPackStreamEncoderTypedValues(t *testing.T) {
+	// Typed values encode like the untyped values they convert from
+	values := []any{
+		nil, true, int64(-300), 2.5, "text",
+		[]any{int64(1), "two", nil},
+		map[string]any{"k": []any{1.5}},
+		map[string]any{"_nodeId": "n1", "labels": []any{"Person"}, "name": "Alice"},
+		map[string]any{"_nodeId": "n2", "labels": nil},
+	}
+	for i, v := range values {
+		typed := encodeStreaming(value.FromAny(v))
+		untyped := encodeStreaming(v)
+		if !bytes.Equal(typed, untyped) {
+			t.Errorf("value %d (%T): typed encoding % x, untyped % x", i, v, typed, untyped)
+		}
+		if got := encodePackStreamValue(value.FromAny(v)); !bytes.Equal(got, untyped) {
+			t.Errorf("value %d (%T): encodePackStreamValue % x, want % x", i, v, got, untyped)
+		}
+	}
+
+	row := []any{"a", int64(1)}
+	if got, want := encodeStreaming(value.FromRow(row)), encodeStreaming(row); !bytes.Equal(got, want) {
+		t.Errorf("typed row = % x, want % x", got, want)
+	}
+}
+
+func TestWriteMessageSplitsChunks(t *testing.T) {
+	var out bytes.Buffer
+	s := &Session{writer: bufio.NewWriter(&out)}
+	data := bytes.Repeat([]byte{0x42}, maxChunkSize+10)
+	if err := s.sendChunk(data); err != nil {
+		t.Fatalf("sendChunk() error = %v", err)
+	}
+
+	got := out.Bytes()
+	if got[0] != 0xFF || got[1] != 0xFF {
+		t.Fatalf("first chunk header = % x, want ff ff", got[:2])
+	}
+	second := got[2+maxChunkSize:]
+	if second[0] != 0x00 || second[1] != 10 {
+		t.Fatalf("second chunk header = % x, want 00 0a", second[:2])
+	}
+	if !bytes.Equal(second[2+10:], []byte{0, 0}) {
+		t.Errorf("message not terminated: % x", second[2+10:])
+	}
+	if len(got) != 2+maxChunkSize+2+10+2 {
+		t.Errorf("wrote %d bytes", len(got))
+	}
+}
+
+// head shortens long encodings in failure messages.
+func head(b []byte) []byte {
+	if len(b) > 32 {
+		return b[:32]
+	}
+	return b
+}
+
+// benchmarkRows returns rows shaped like MATCH (n) RETURN n, n.name, n.age.
+func benchmarkRows(n int) [][]any {
+	rows := make([][]any, n)
+	for i := range rows {
+		node := map[string]any{
+			"_nodeId":   fmt.Sprintf("node-%d", i),
+			"labels":    []string{"Person", "Employee"},
+			"id":        fmt.Sprintf("node-%d", i),
+			"name":      fmt.Sprintf("Person %d", i),
+			"age":       int64(20 + i%50),
+			"tags":      []string{"a", "b", "c"},
+			"embedding": map[string]any{"status": "ready", "dimensions": 384},
+		}
+		rows[i] = []any{node, node["name"], node["age"]}
+	}
+	return rows
+}
+
+// BenchmarkRecordEncoding compares the per-value encoder with the streaming
+// encoder used by sendRecordsBatched.
+func BenchmarkRecordEncoding(b *testing.B) {
+	rows := benchmarkRows(500)
+
+	b.Run("legacy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, row := range rows {
+				buf := []byte{0xB1, MsgRecord}
+				_ = append(buf, encodePackStreamList(row)...)
+			}
+		}
+	})
+
+	b.Run("streaming", func(b *testing.B) {
+		b.ReportAllocs()
+		var enc packStreamEncoder
+		for i := 0; i < b.N; i++ {
+			for _, row := range rows {
+				enc.buf = enc.buf[:0]
+				enc.writeRecord(row)
+			}
+		}
+	})
+}
+
+// BenchmarkSendRecordsBatched measures encoding plus chunked writes.
+func BenchmarkSendRecordsBatched(b *testing.B) {
+	rows := benchmarkRows(500)
+	var out bytes.Buffer
+	s := &Session{writer: bufio.NewWriterSize(&out, 64*1024)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		out.Reset()
+		if err := s.sendRecordsBatched(rows); err != nil {
+			b.Fatal(err)
+		}
+		s.writer.Flush()
+	}
+}
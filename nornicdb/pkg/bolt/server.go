@@ -123,13 +123,27 @@ import (
 	"bufio"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/bookmark"
+	"github.com/orneryd/nornicdb/pkg/idempotency"
+	"github.com/orneryd/nornicdb/pkg/neo4jerr"
+	"github.com/orneryd/nornicdb/pkg/pool"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/requestid"
+	"github.com/orneryd/nornicdb/pkg/value"
+	"github.com/orneryd/nornicdb/pkg/writethrottle"
 )
 
 // Protocol versions supported
@@ -203,6 +217,12 @@ type Server struct {
 
 	// Query executor (injected dependency)
 	executor QueryExecutor
+
+	// Panics recovered in connection handlers and query execution
+	panics atomic.Int64
+
+	// Commit sequence for bookmarks (Config.Bookmarks or the server's own)
+	bookmarks *bookmark.Manager
 }
 
 // QueryExecutor executes Cypher queries for the Bolt server.
@@ -246,6 +266,11 @@ type QueryExecutor interface {
 // real transactions for BEGIN/COMMIT/ROLLBACK messages. Otherwise,
 // transaction messages are acknowledged but operations are auto-committed.
 //
+// One executor serves every session. The contexts passed to the transaction
+// methods, and to Execute for queries run inside the transaction, carry the
+// session's transaction ID (see TransactionID) so executors can keep
+// per-transaction state.
+//
 // Example Implementation:
 //
 //	type TxExecutor struct {
@@ -282,6 +307,15 @@ type TransactionalExecutor interface {
 	RollbackTransaction(ctx context.Context) error
 }
 
+type transactionIDKey struct{}
+
+// TransactionID returns the ID of the explicit transaction ctx belongs to,
+// or "" outside one. IDs are unique per BEGIN.
+func TransactionID(ctx context.Context) string {
+	id, _ := ctx.Value(transactionIDKey{}).(string)
+	return id
+}
+
 // FlushableExecutor extends QueryExecutor with deferred commit support.
 // This enables Neo4j-style optimization where writes are buffered until PULL.
 type FlushableExecutor interface {
@@ -298,9 +332,16 @@ type DeferrableExecutor interface {
 }
 
 // QueryResult holds the result of a query.
+//
+// Release, if set, hands pooled row storage back to the executor. The session
+// calls it exactly once, when it stops referencing Rows: after the last
+// record is encoded, on DISCARD or RESET, when the next RUN replaces the
+// result, or when the connection closes. Records are fully encoded into the
+// write buffer before release, so no row is read after it is returned.
 type QueryResult struct {
 	Columns []string
 	Rows    [][]any
+	Release func()
 }
 
 // BoltAuthenticator is the interface for authenticating Bolt protocol connections.
@@ -433,6 +474,26 @@ type Config struct {
 	Authenticator  BoltAuthenticator // Authentication handler (nil = no auth)
 	RequireAuth    bool              // Require authentication for all connections
 	AllowAnonymous bool              // Allow "none" auth scheme (grants viewer role)
+
+	// QueryLimiter enforces per-user/role/IP query rate limits (nil = unlimited).
+	// Share one limiter with the HTTP server so limits apply across protocols.
+	QueryLimiter *ratelimit.Limiter
+
+	// Idempotency records auto-commit writes sent with an idempotency key so
+	// retries are applied once (nil = keys are ignored). Share one store with
+	// the HTTP server.
+	Idempotency *idempotency.Store
+
+	// WriteThrottle delays or rejects writes while the server is under
+	// strain (nil = writes are never throttled). Share one throttle with
+	// the HTTP server.
+	WriteThrottle *writethrottle.Throttle
+
+	// Bookmarks numbers commits and makes sessions that present a bookmark
+	// wait for the state it names (nil = the server keeps its own, with a
+	// 30s wait timeout). Share one manager with the HTTP server so that
+	// bookmarks work across protocols.
+	Bookmarks *bookmark.Manager
 }
 
 // DefaultConfig returns Neo4j-compatible default Bolt server configuration.
@@ -636,10 +697,16 @@ func New(config *Config, executor QueryExecutor) *Server {
 		config = DefaultConfig()
 	}
 
+	bookmarks := config.Bookmarks
+	if bookmarks == nil {
+		bookmarks = bookmark.New(30 * time.Second)
+	}
+
 	return &Server{
-		config:   config,
-		sessions: make(map[string]*Session),
-		executor: executor,
+		config:    config,
+		sessions:  make(map[string]*Session),
+		executor:  executor,
+		bookmarks: bookmarks,
 	}
 }
 
@@ -708,6 +775,22 @@ func (s *Server) IsClosed() bool {
 	return s.closed.Load()
 }
 
+// PanicCount returns the number of panics recovered by the server since startup.
+// Includes panics in connection handlers and in the query executor.
+func (s *Server) PanicCount() int64 {
+	return s.panics.Load()
+}
+
+// recordPanic logs a recovered panic with its stack trace and bumps the panic counter.
+func (s *Server) recordPanic(where string, conn net.Conn, requestID string, r any) {
+	s.panics.Add(1)
+	remoteAddr := "unknown"
+	if conn != nil && conn.RemoteAddr() != nil {
+		remoteAddr = conn.RemoteAddr().String()
+	}
+	fmt.Printf("[BOLT] PANIC in %s (remote=%s req=%s): %v\n%s\n", where, remoteAddr, requestID, r, debug.Stack())
+}
+
 // handleConnection handles a single client connection.
 func (s *Server) handleConnection(conn net.Conn) {
 	defer conn.Close()
@@ -718,13 +801,6 @@ func (s *Server) handleConnection(conn net.Conn) {
 		tcpConn.SetNoDelay(true)
 	}
 
-	// Recover from panics to prevent crashing the server
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Printf("Recovered from panic in connection handler: %v\n", r)
-		}
-	}()
-
 	session := &Session{
 		conn:       conn,
 		reader:     bufio.NewReaderSize(conn, 8192), // 8KB read buffer
@@ -734,6 +810,13 @@ func (s *Server) handleConnection(conn net.Conn) {
 		messageBuf: make([]byte, 0, 4096), // Pre-allocate 4KB message buffer
 	}
 
+	// Recover from panics to prevent crashing the server
+	defer func() {
+		if r := recover(); r != nil {
+			s.recordPanic("connection handler", conn, session.requestID, r)
+		}
+	}()
+
 	// Enable deferred flush mode for Neo4j-style write batching
 	if deferrable, ok := s.executor.(DeferrableExecutor); ok {
 		deferrable.SetDeferFlush(true)
@@ -741,6 +824,9 @@ func (s *Server) handleConnection(conn net.Conn) {
 
 	// Ensure cleanup on session end
 	defer func() {
+		session.setResult(nil)
+		// A transaction left open by a dropped connection is rolled back
+		session.abortTransaction()
 		// Flush any pending writes
 		if flushable, ok := s.executor.(FlushableExecutor); ok {
 			flushable.Flush()
@@ -794,6 +880,8 @@ type Session struct {
 	// Transaction state
 	inTransaction bool
 	txMetadata    map[string]any // Transaction metadata from BEGIN
+	txID          string         // Transaction ID from BEGIN (see TransactionID)
+	txDeadline    time.Time      // Expiry from the BEGIN tx_timeout (zero = none)
 
 	// Query result state (for streaming with PULL)
 	lastResult  *QueryResult
@@ -807,6 +895,14 @@ type Session struct {
 	queryId          int64 // Query ID counter for qid field
 	lastQueryIsWrite bool  // Was last query a write operation
 
+	// Request ID of the most recent RUN (for log/error correlation)
+	requestID string
+
+	// Session settings from :config (see handleConfig)
+	queryOptions map[string]string // Cypher options, sent as a CYPHER prefix
+	cypherPrefix string            // Prefix built from queryOptions
+	fetchSize    int               // Max records per PULL when the client asks for all (0 = no limit)
+
 	// Reusable buffers to reduce allocations
 	headerBuf  [2]byte // For reading chunk headers
 	messageBuf []byte  // Reusable message buffer
@@ -1174,11 +1270,33 @@ func (s *Session) handleRun(data []byte) error {
 		return s.sendFailure("Neo.ClientError.Security.Unauthorized", "Not authenticated")
 	}
 
-	// Parse PackStream to extract query and params
-	query, params, err := s.parseRunMessage(data)
+	// Every RUN gets its own request ID for correlated logging across layers
+	s.requestID = requestid.New()
+
+	// Parse PackStream to extract query, params and metadata
+	query, params, extra, err := s.parseRunMessageExtra(data)
 	if err != nil {
 		return s.sendFailure("Neo.ClientError.Request.Invalid", fmt.Sprintf("Failed to parse RUN message: %v", err))
 	}
+	idemKey := idempotencyKey(params, extra)
+	if idemKey != "" && s.inTransaction {
+		return s.sendFailure("Neo.ClientError.Request.Invalid", "Idempotency keys apply to auto-commit queries, not explicit transactions")
+	}
+	if s.txExpired() {
+		s.abortTransaction()
+		return s.sendFailure(neo4jerr.TransactionTimedOut, withRequestID("transaction timed out", s.requestID))
+	}
+	if !s.inTransaction {
+		// An auto-commit query reads the state named by its bookmarks
+		if err := s.server.bookmarkManager().Wait(s.txContext(), txBookmarks(extra)); err != nil {
+			return s.sendFailure(neo4jerr.Code(err, neo4jerr.BookmarkTimeout), withRequestID(err.Error(), s.requestID))
+		}
+	}
+
+	// Session settings are handled by the server, not the executor
+	if isConfigCommand(query) {
+		return s.handleConfig(query)
+	}
 
 	// Classify query type once (used for auth and deferred flush)
 	upperQuery := strings.ToUpper(query)
@@ -1214,20 +1332,53 @@ func (s *Session) handleRun(data []byte) error {
 			user = s.authResult.Username
 		}
 		if len(params) > 0 {
-			fmt.Printf("[BOLT] %s@%s: %s (params: %v)\n", user, remoteAddr, truncateQuery(query, 200), params)
+			fmt.Printf("[BOLT] [req=%s] %s@%s: %s (params: %v)\n", s.requestID, user, remoteAddr, truncateQuery(query, 200), params)
 		} else {
-			fmt.Printf("[BOLT] %s@%s: %s\n", user, remoteAddr, truncateQuery(query, 200))
+			fmt.Printf("[BOLT] [req=%s] %s@%s: %s\n", s.requestID, user, remoteAddr, truncateQuery(query, 200))
 		}
 	}
 
-	// Execute query
-	ctx := context.Background()
-	result, err := s.executor.Execute(ctx, query, params)
+	// Apply write backpressure before taking a query slot
+	if isWrite {
+		if err := s.server.writeThrottle().Admit(s.txContext()); err != nil {
+			return s.sendFailure("Neo.TransientError.Request.ResourceExhaustion", withRequestID(err.Error(), s.requestID))
+		}
+	}
+
+	// Enforce query rate limits
+	principal := s.limitPrincipal()
+	release, err := s.server.queryLimiter().Acquire(principal)
+	if err != nil {
+		return s.sendFailure("Neo.ClientError.Request.TooManyRequests", withRequestID(err.Error(), s.requestID))
+	}
+
+	// Execute query with the session's Cypher options, until the driver's
+	// timeout expires
+	ctx := requestid.NewContext(s.txContext(), s.requestID)
+	ctx = ratelimit.NewContext(ctx, principal)
+	ctx, cancel := s.deadlineContext(ctx, extra)
+	defer cancel()
+	var result *QueryResult
+	if idemKey != "" && isWrite {
+		result, err = s.executeIdempotent(ctx, idemKey, s.cypherPrefix+query, params)
+	} else {
+		result, err = s.executeQuery(ctx, s.cypherPrefix+query, params)
+	}
+	release()
 	if err != nil {
 		if s.server != nil && s.server.config.LogQueries {
-			fmt.Printf("[BOLT] ERROR: %v\n", err)
+			fmt.Printf("[BOLT] [req=%s] ERROR: %v\n", s.requestID, err)
+		}
+		if _, ok := err.(*executorPanicError); ok {
+			return s.sendFailure(neo4jerr.UnknownError, withRequestID(err.Error(), s.requestID))
 		}
-		return s.sendFailure("Neo.ClientError.Statement.SyntaxError", err.Error())
+		code := neo4jerr.Code(err, neo4jerr.StatementSyntaxError)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// Whatever the executor failed with, the deadline stopped it
+			code = neo4jerr.TransactionTimedOut
+			s.abortTransaction()
+		}
+		return s.sendFailure(code, withRequestID(err.Error(), s.requestID))
 	}
 
 	// Track write operation for deferred flush
@@ -1237,8 +1388,7 @@ func (s *Session) handleRun(data []byte) error {
 	s.lastQueryIsWrite = isWrite
 
 	// Store result for PULL
-	s.lastResult = result
-	s.resultIndex = 0
+	s.setResult(result)
 	s.queryId++
 
 	// Return SUCCESS with field names (Neo4j compatible metadata)
@@ -1257,6 +1407,261 @@ func (s *Session) handleRun(data []byte) error {
 	})
 }
 
+// sessionCypherOptions are the :config keys forwarded to the executor as
+// CYPHER prefix options. The executor validates their values.
+var sessionCypherOptions = map[string]bool{"planner": true, "runtime": true, "timeout": true}
+
+// isConfigCommand reports whether a RUN query is a :config command.
+func isConfigCommand(query string) bool {
+	query = strings.TrimSpace(query)
+	return query == ":config" || strings.HasPrefix(query, ":config ")
+}
+
+// handleConfig handles :config session commands and returns the resulting
+// settings as key/value records:
+//
+//	:config                               list settings
+//	:config runtime=parallel timeout=2s   set Cypher options for later queries
+//	:config fetch_size=500                cap records per PULL
+//	:config reset                         clear all settings
+func (s *Session) handleConfig(query string) error {
+	args := strings.Fields(strings.TrimSpace(query)[len(":config"):])
+	if len(args) == 1 && strings.EqualFold(args[0], "reset") {
+		s.queryOptions = nil
+		s.fetchSize = 0
+		args = nil
+	}
+
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		key = strings.ToLower(key)
+		if !ok || value == "" {
+			return s.sendFailure("Neo.ClientError.Statement.SyntaxError", fmt.Sprintf("invalid :config setting %q (expected key=value)", arg))
+		}
+		switch {
+		case key == "fetch_size":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return s.sendFailure("Neo.ClientError.Statement.SyntaxError", fmt.Sprintf("invalid fetch_size %q", value))
+			}
+			s.fetchSize = n
+		case sessionCypherOptions[key]:
+			if s.queryOptions == nil {
+				s.queryOptions = make(map[string]string)
+			}
+			s.queryOptions[key] = value
+		default:
+			return s.sendFailure("Neo.ClientError.Statement.SyntaxError", fmt.Sprintf("unknown :config setting %q", key))
+		}
+	}
+
+	// Rebuild the prefix and the listing
+	keys := make([]string, 0, len(s.queryOptions))
+	for key := range s.queryOptions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	s.cypherPrefix = ""
+	result := &QueryResult{Columns: []string{"key", "value"}, Rows: [][]any{}}
+	if len(keys) > 0 {
+		var prefix strings.Builder
+		prefix.WriteString("CYPHER")
+		for _, key := range keys {
+			prefix.WriteString(" " + key + "=" + s.queryOptions[key])
+			result.Rows = append(result.Rows, []any{key, s.queryOptions[key]})
+		}
+		prefix.WriteString(" ")
+		s.cypherPrefix = prefix.String()
+	}
+	if s.fetchSize > 0 {
+		result.Rows = append(result.Rows, []any{"fetch_size", int64(s.fetchSize)})
+	}
+
+	s.setResult(result)
+	s.lastQueryIsWrite = false
+	s.queryId++
+	return s.sendSuccess(map[string]any{
+		"fields":  result.Columns,
+		"t_first": int64(0),
+	})
+}
+
+// setResult replaces the result being streamed, releasing the previous one.
+func (s *Session) setResult(result *QueryResult) {
+	if prev := s.lastResult; prev != nil && prev != result && prev.Release != nil {
+		prev.Release()
+	}
+	s.lastResult = result
+	s.resultIndex = 0
+}
+
+// executorPanicError reports a panic recovered from the query executor.
+type executorPanicError struct {
+	value any
+}
+
+func (e *executorPanicError) Error() string {
+	return fmt.Sprintf("internal error executing query: %v", e.value)
+}
+
+// executeQuery runs the query on the executor inside a recover boundary.
+// A panicking executor fails only the current query; the connection stays usable.
+func (s *Session) executeQuery(ctx context.Context, query string, params map[string]any) (result *QueryResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if s.server != nil {
+				s.server.recordPanic("query executor", s.conn, requestid.FromContext(ctx), r)
+			}
+			result, err = nil, &executorPanicError{value: r}
+		}
+	}()
+	return s.executor.Execute(ctx, query, params)
+}
+
+// executeIdempotent runs a write tagged with an idempotency key at most once
+// per key and caller. A retry of an applied write receives the recorded
+// result instead of running again.
+func (s *Session) executeIdempotent(ctx context.Context, key, query string, params map[string]any) (*QueryResult, error) {
+	user := ""
+	if s.authResult != nil {
+		user = s.authResult.Username
+	}
+	var result *QueryResult
+	recorded, replayed, err := s.server.idempotencyStore().Do(idempotency.Scope(user, key), idempotency.Fingerprint(query, params), func() (any, error) {
+		r, err := s.executeQuery(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+		result = r
+		return recordResult(r), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		if s.server != nil && s.server.config.LogQueries {
+			fmt.Printf("[BOLT] [req=%s] idempotency key %q replayed\n", s.requestID, key)
+		}
+		r, err := idempotency.Decode[*recordedResult](recorded)
+		if err != nil {
+			return nil, err
+		}
+		return r.queryResult(), nil
+	}
+	return result, nil
+}
+
+// recordedResult is a result recorded for idempotent replay. Each field
+// holds the PackStream bytes it is sent as, so a replay after a restart,
+// decoded from JSON, sends the same values with the same types.
+type recordedResult struct {
+	Columns []string   `json:"columns"`
+	Rows    [][][]byte `json:"rows"`
+}
+
+// recordResult encodes r for replay. It copies the fields, as the executor
+// may reuse the row storage after Release.
+func recordResult(r *QueryResult) *recordedResult {
+	rec := &recordedResult{Columns: r.Columns, Rows: make([][][]byte, len(r.Rows))}
+	for i, row := range r.Rows {
+		fields := make([][]byte, len(row))
+		for j, v := range row {
+			enc := packStreamEncoder{}
+			enc.writeValue(v)
+			fields[j] = enc.buf
+		}
+		rec.Rows[i] = fields
+	}
+	return rec
+}
+
+func (r *recordedResult) queryResult() *QueryResult {
+	rows := make([][]any, len(r.Rows))
+	for i, fields := range r.Rows {
+		row := make([]any, len(fields))
+		for j, field := range fields {
+			row[j] = packedValue(field)
+		}
+		rows[i] = row
+	}
+	return &QueryResult{Columns: r.Columns, Rows: rows}
+}
+
+// idempotencyKey removes and returns the idempotency key passed as a RUN
+// parameter or in the RUN transaction metadata, or "" if there is none.
+func idempotencyKey(params, extra map[string]any) string {
+	key, _ := params[idempotency.ParamName].(string)
+	delete(params, idempotency.ParamName)
+	if meta, ok := extra["tx_metadata"].(map[string]any); ok {
+		if k, ok := meta[idempotency.MetadataKey].(string); ok && k != "" {
+			key = k
+		}
+	}
+	return key
+}
+
+// idempotencyStore returns the configured idempotency store, or nil.
+func (s *Server) idempotencyStore() *idempotency.Store {
+	if s == nil || s.config == nil {
+		return nil
+	}
+	return s.config.Idempotency
+}
+
+// queryLimiter returns the configured query limiter, or nil.
+func (s *Server) queryLimiter() *ratelimit.Limiter {
+	if s == nil || s.config == nil {
+		return nil
+	}
+	return s.config.QueryLimiter
+}
+
+// bookmarkManager returns the server's bookmark manager, or nil.
+func (s *Server) bookmarkManager() *bookmark.Manager {
+	if s == nil {
+		return nil
+	}
+	return s.bookmarks
+}
+
+// writeThrottle returns the configured write throttle, or nil.
+func (s *Server) writeThrottle() *writethrottle.Throttle {
+	if s == nil || s.config == nil {
+		return nil
+	}
+	return s.config.WriteThrottle
+}
+
+// limitPrincipal identifies this session's caller for rate limiting.
+func (s *Session) limitPrincipal() ratelimit.Principal {
+	var p ratelimit.Principal
+	if s.authResult != nil {
+		p.User = s.authResult.Username
+		p.Roles = s.authResult.Roles
+	}
+	if s.conn != nil && s.conn.RemoteAddr() != nil {
+		p.IP = s.conn.RemoteAddr().String()
+	}
+	return p
+}
+
+// chargeResultBytes counts streamed result bytes against the caller's limit.
+func (s *Session) chargeResultBytes(n int) {
+	if limiter := s.server.queryLimiter(); limiter.Enabled() {
+		limiter.ConsumeResultBytes(s.limitPrincipal(), int64(n))
+	}
+}
+
+// withRequestID appends the request ID to a failure message sent to the driver,
+// so users can quote it when reporting problems. Skips IDs already present.
+func withRequestID(msg, id string) string {
+	if id == "" || strings.Contains(msg, id) {
+		return msg
+	}
+	return msg + " (request_id: " + id + ")"
+}
+
 // truncateQuery truncates a query for logging.
 func truncateQuery(q string, maxLen int) string {
 	if len(q) <= maxLen {
@@ -1268,8 +1673,15 @@ func truncateQuery(q string, maxLen int) string {
 // parseRunMessage parses a RUN message to extract query and parameters.
 // Bolt v4+ RUN message format: [query: String, parameters: Map, extra: Map]
 func (s *Session) parseRunMessage(data []byte) (string, map[string]any, error) {
+	query, params, _, err := s.parseRunMessageExtra(data)
+	return query, params, err
+}
+
+// parseRunMessageExtra parses a RUN message, also returning the extra
+// metadata map (bookmarks, tx_timeout, tx_metadata, ...), or nil if absent.
+func (s *Session) parseRunMessageExtra(data []byte) (string, map[string]any, map[string]any, error) {
 	if len(data) == 0 {
-		return "", nil, fmt.Errorf("empty RUN message")
+		return "", nil, nil, fmt.Errorf("empty RUN message")
 	}
 
 	offset := 0
@@ -1277,7 +1689,7 @@ func (s *Session) parseRunMessage(data []byte) (string, map[string]any, error) {
 	// Parse query string
 	query, n, err := decodePackStreamString(data, offset)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to parse query: %w", err)
+		return "", nil, nil, fmt.Errorf("failed to parse query: %w", err)
 	}
 	offset += n
 
@@ -1295,10 +1707,14 @@ func (s *Session) parseRunMessage(data []byte) (string, map[string]any, error) {
 	}
 
 	// Bolt v4+ has an extra metadata map after params (for bookmarks, tx_timeout, etc.)
-	// We can ignore it for now, but we should parse it to avoid issues
-	// offset is now pointing to the extra map if present
+	var extra map[string]any
+	if offset < len(data) {
+		if e, _, err := decodePackStreamMap(data, offset); err == nil {
+			extra = e
+		}
+	}
 
-	return query, params, nil
+	return query, params, extra, nil
 }
 
 // handlePull handles the PULL message.
@@ -1324,6 +1740,11 @@ func (s *Session) handlePull(data []byte) error {
 		}
 	}
 
+	// Session fetch size bounds "pull all" requests; the driver pulls again on has_more
+	if pullN < 0 && s.fetchSize > 0 {
+		pullN = s.fetchSize
+	}
+
 	// Stream records - use batched writing for large result sets
 	remaining := len(s.lastResult.Rows) - s.resultIndex
 	if pullN > 0 && remaining > pullN {
@@ -1360,8 +1781,7 @@ func (s *Session) handlePull(data []byte) error {
 
 	// Clear result if done
 	if !hasMore {
-		s.lastResult = nil
-		s.resultIndex = 0
+		s.setResult(nil)
 
 		// Neo4j-style deferred commit: flush pending writes after streaming completes
 		if s.pendingFlush {
@@ -1380,10 +1800,24 @@ func (s *Session) handlePull(data []byte) error {
 
 		// Build stats matching Neo4j format (only if there are updates)
 		metadata := map[string]any{
-			"bookmark": "nornicdb:tx:auto",
-			"type":     queryType,
-			"t_last":   int64(0), // Streaming time
-			"db":       "neo4j",  // Default database name
+			"type":   queryType,
+			"t_last": int64(0), // Streaming time
+			"db":     "neo4j",  // Default database name
+		}
+
+		// An auto-commit query commits here; explicit transactions get
+		// their bookmark from COMMIT
+		if !s.inTransaction {
+			bookmarks := s.server.bookmarkManager()
+			var b string
+			if s.lastQueryIsWrite {
+				b = bookmarks.Commit()
+			} else {
+				b = bookmarks.Current()
+			}
+			if b != "" {
+				metadata["bookmark"] = b
+			}
 		}
 
 		// Note: Neo4j does NOT send has_more when it's false
@@ -1398,8 +1832,7 @@ func (s *Session) handlePull(data []byte) error {
 
 // handleDiscard handles the DISCARD message.
 func (s *Session) handleDiscard(data []byte) error {
-	s.lastResult = nil
-	s.resultIndex = 0
+	s.setResult(nil)
 	// Neo4j doesn't send has_more when false - just empty metadata
 	return s.sendSuccess(map[string]any{})
 }
@@ -1418,17 +1851,8 @@ func (s *Session) handleRoute(data []byte) error {
 // Resets the session state and rolls back any active transaction.
 func (s *Session) handleReset(data []byte) error {
 	// Rollback any active transaction
-	if s.inTransaction {
-		if txExec, ok := s.executor.(TransactionalExecutor); ok {
-			ctx := context.Background()
-			_ = txExec.RollbackTransaction(ctx) // Ignore error on reset
-		}
-	}
-
-	s.inTransaction = false
-	s.txMetadata = nil
-	s.lastResult = nil
-	s.resultIndex = 0
+	s.abortTransaction()
+	s.setResult(nil)
 	return s.sendSuccess(nil)
 }
 
@@ -1445,12 +1869,22 @@ func (s *Session) handleBegin(data []byte) error {
 		}
 	}
 	s.txMetadata = metadata
+	s.txID = requestid.New()
+	if timeout := txTimeout(metadata); timeout > 0 {
+		s.txDeadline = time.Now().Add(timeout)
+	}
+
+	// The transaction reads the state named by its bookmarks
+	if err := s.server.bookmarkManager().Wait(s.txContext(), txBookmarks(metadata)); err != nil {
+		s.txMetadata, s.txID, s.txDeadline = nil, "", time.Time{}
+		return s.sendFailure(neo4jerr.Code(err, neo4jerr.BookmarkTimeout), err.Error())
+	}
 
 	// If executor supports transactions, start one
 	if txExec, ok := s.executor.(TransactionalExecutor); ok {
-		ctx := context.Background()
+		ctx := s.txContext()
 		if err := txExec.BeginTransaction(ctx, metadata); err != nil {
-			return s.sendFailure("Neo.TransactionError.Begin", err.Error())
+			return s.sendFailure(neo4jerr.Code(err, neo4jerr.TransactionStartFailed), err.Error())
 		}
 	}
 
@@ -1466,23 +1900,27 @@ func (s *Session) handleCommit(data []byte) error {
 			"No transaction to commit")
 	}
 
+	if s.txExpired() {
+		s.abortTransaction()
+		return s.sendFailure(neo4jerr.TransactionTimedOut, "transaction timed out before commit")
+	}
+
 	// If executor supports transactions, commit
 	if txExec, ok := s.executor.(TransactionalExecutor); ok {
-		ctx := context.Background()
+		ctx := s.txContext()
 		if err := txExec.CommitTransaction(ctx); err != nil {
-			s.inTransaction = false
-			s.txMetadata = nil
-			return s.sendFailure("Neo.TransactionError.Commit", err.Error())
+			s.endTransaction()
+			return s.sendFailure(neo4jerr.Code(err, neo4jerr.TransactionCommitFailed), err.Error())
 		}
 	}
 
-	s.inTransaction = false
-	s.txMetadata = nil
+	s.endTransaction()
 
 	// Return bookmark for client tracking
-	return s.sendSuccess(map[string]any{
-		"bookmark": "nornicdb:bookmark:1",
-	})
+	if b := s.server.bookmarkManager().Commit(); b != "" {
+		return s.sendSuccess(map[string]any{"bookmark": b})
+	}
+	return s.sendSuccess(map[string]any{})
 }
 
 // handleRollback handles the ROLLBACK message.
@@ -1495,53 +1933,121 @@ func (s *Session) handleRollback(data []byte) error {
 
 	// If executor supports transactions, rollback
 	if txExec, ok := s.executor.(TransactionalExecutor); ok {
-		ctx := context.Background()
+		ctx := s.txContext()
 		if err := txExec.RollbackTransaction(ctx); err != nil {
 			// Rollback failed, but we still clear state
-			s.inTransaction = false
-			s.txMetadata = nil
-			return s.sendFailure("Neo.TransactionError.Rollback", err.Error())
+			s.endTransaction()
+			return s.sendFailure(neo4jerr.Code(err, neo4jerr.TransactionRollbackFailed), err.Error())
+		}
+	}
+
+	s.endTransaction()
+	return s.sendSuccess(nil)
+}
+
+// txContext returns a context carrying the session's transaction ID, if any.
+func (s *Session) txContext() context.Context {
+	if s.txID == "" {
+		return context.Background()
+	}
+	return context.WithValue(context.Background(), transactionIDKey{}, s.txID)
+}
+
+// txTimeout returns the tx_timeout a driver sent in BEGIN or RUN metadata, or
+// 0 if there is none. Drivers send it in milliseconds.
+func txTimeout(metadata map[string]any) time.Duration {
+	ms, _ := metadata["tx_timeout"].(int64)
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// txBookmarks returns the bookmarks a driver sent in BEGIN or RUN metadata.
+func txBookmarks(metadata map[string]any) []string {
+	list, _ := metadata["bookmarks"].([]any)
+	bookmarks := make([]string, 0, len(list))
+	for _, b := range list {
+		if str, ok := b.(string); ok {
+			bookmarks = append(bookmarks, str)
+		}
+	}
+	return bookmarks
+}
+
+// deadlineContext bounds a query by the driver's timeout: the transaction's
+// deadline inside an explicit transaction, else the RUN's own tx_timeout.
+// The executor stops read queries and pending GPU work when it expires.
+func (s *Session) deadlineContext(ctx context.Context, extra map[string]any) (context.Context, context.CancelFunc) {
+	if s.inTransaction {
+		if s.txDeadline.IsZero() {
+			return ctx, func() {}
+		}
+		return context.WithDeadline(ctx, s.txDeadline)
+	}
+	if timeout := txTimeout(extra); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// txExpired reports whether the explicit transaction outlived its
+// tx_timeout.
+func (s *Session) txExpired() bool {
+	return s.inTransaction && !s.txDeadline.IsZero() && !time.Now().Before(s.txDeadline)
+}
+
+// abortTransaction rolls back the active transaction, ignoring errors, and
+// clears the transaction state.
+func (s *Session) abortTransaction() {
+	if s.inTransaction {
+		if txExec, ok := s.executor.(TransactionalExecutor); ok {
+			_ = txExec.RollbackTransaction(s.txContext())
 		}
 	}
+	s.endTransaction()
+}
 
+// endTransaction clears the transaction state.
+func (s *Session) endTransaction() {
 	s.inTransaction = false
 	s.txMetadata = nil
-	return s.sendSuccess(nil)
+	s.txID = ""
+	s.txDeadline = time.Time{}
 }
 
 // sendRecord sends a RECORD response.
 func (s *Session) sendRecord(fields []any) error {
 	// Format: <struct marker 0xB1> <signature 0x71> <list of fields>
-	buf := []byte{0xB1, MsgRecord}
-	buf = append(buf, encodePackStreamList(fields)...)
-	return s.sendChunk(buf)
+	enc := packStreamEncoder{buf: pool.GetByteBuffer()}
+	enc.writeRecord(fields)
+	s.chargeResultBytes(len(enc.buf))
+	err := s.sendChunk(enc.buf)
+	pool.PutByteBuffer(enc.buf)
+	return err
 }
 
 // sendRecordsBatched sends multiple RECORD responses using buffered I/O.
 // This dramatically reduces syscall overhead for large result sets.
 // For 500 records: ~500 syscalls → 1 syscall = ~8x faster
+//
+// Each record is streamed into one pooled buffer and copied to the writer,
+// so rows are fully encoded before the caller may release them.
 func (s *Session) sendRecordsBatched(rows [][]any) error {
 	if len(rows) == 0 {
 		return nil
 	}
 
-	// Write all records to buffer
+	enc := packStreamEncoder{buf: pool.GetByteBuffer()}
+	defer func() { pool.PutByteBuffer(enc.buf) }()
+	total := 0
 	for _, row := range rows {
-		recordData := []byte{0xB1, MsgRecord}
-		recordData = append(recordData, encodePackStreamList(row)...)
-
-		// Write chunk header
-		size := len(recordData)
-		s.writer.WriteByte(byte(size >> 8))
-		s.writer.WriteByte(byte(size))
-
-		// Write record data
-		s.writer.Write(recordData)
-
-		// Write terminator
-		s.writer.WriteByte(0)
-		s.writer.WriteByte(0)
+		enc.buf = enc.buf[:0]
+		enc.writeRecord(row)
+		total += len(enc.buf)
+		s.writeMessage(enc.buf)
 	}
+	s.chargeResultBytes(total)
 
 	// Don't flush here - let the final SUCCESS message flush everything
 	return nil
@@ -1570,25 +2076,40 @@ func (s *Session) sendFailure(code, message string) error {
 	return s.sendChunk(buf)
 }
 
-// sendChunk sends a chunk to the client using buffered I/O.
+// sendChunk sends a message to the client using buffered I/O.
 // The buffer is flushed after each complete message response.
 func (s *Session) sendChunk(data []byte) error {
-	size := len(data)
+	s.writeMessage(data)
+
+	// Flush immediately to ensure response is sent
+	// This is critical for request-response protocols
+	return s.writer.Flush()
+}
+
+// writeMessage writes one message to the buffered writer without flushing.
+// Messages longer than a chunk are split; the terminator (0x00 0x00) ends
+// the message. Write errors stick to the writer and surface on Flush.
+func (s *Session) writeMessage(data []byte) {
+	for {
+		n := len(data)
+		if n > maxChunkSize {
+			n = maxChunkSize
+		}
 
-	// Write chunk header (2 bytes)
-	s.writer.WriteByte(byte(size >> 8))
-	s.writer.WriteByte(byte(size))
+		// Write chunk header (2 bytes) and data
+		s.writer.WriteByte(byte(n >> 8))
+		s.writer.WriteByte(byte(n))
+		s.writer.Write(data[:n])
 
-	// Write data
-	s.writer.Write(data)
+		data = data[n:]
+		if len(data) == 0 {
+			break
+		}
+	}
 
 	// Write terminator (0x00 0x00)
 	s.writer.WriteByte(0)
 	s.writer.WriteByte(0)
-
-	// Flush immediately to ensure response is sent
-	// This is critical for request-response protocols
-	return s.writer.Flush()
 }
 
 // ============================================================================
@@ -1618,6 +2139,14 @@ func encodePackStreamMap(m map[string]any) []byte {
 	return buf
 }
 
+// encodePooledList encodes a list built from pool.GetInterfaceSlice and
+// returns the slice to the pool. The encoded bytes do not reference it.
+func encodePooledList(items []any) []byte {
+	buf := encodePackStreamList(items)
+	pool.PutInterfaceSlice(items)
+	return buf
+}
+
 func encodePackStreamList(items []any) []byte {
 	if len(items) == 0 {
 		return []byte{0x90}
@@ -1660,6 +2189,12 @@ func encodePackStreamString(s string) []byte {
 
 func encodePackStreamValue(v any) []byte {
 	switch val := v.(type) {
+	case packedValue:
+		return append([]byte(nil), val...)
+	case value.Value, []value.Value, value.Map:
+		var enc packStreamEncoder
+		enc.writeValue(val)
+		return enc.buf
 	case nil:
 		return []byte{0xC0}
 	case bool:
@@ -1703,43 +2238,43 @@ func encodePackStreamValue(v any) []byte {
 		return encodePackStreamString(val)
 	// List types
 	case []string:
-		items := make([]any, len(val))
-		for i, s := range val {
-			items[i] = s
+		items := pool.GetInterfaceSlice()
+		for _, s := range val {
+			items = append(items, s)
 		}
-		return encodePackStreamList(items)
+		return encodePooledList(items)
 	case []any:
 		return encodePackStreamList(val)
 	case []int:
-		items := make([]any, len(val))
-		for i, n := range val {
-			items[i] = int64(n)
+		items := pool.GetInterfaceSlice()
+		for _, n := range val {
+			items = append(items, int64(n))
 		}
-		return encodePackStreamList(items)
+		return encodePooledList(items)
 	case []int64:
-		items := make([]any, len(val))
-		for i, n := range val {
-			items[i] = n
+		items := pool.GetInterfaceSlice()
+		for _, n := range val {
+			items = append(items, n)
 		}
-		return encodePackStreamList(items)
+		return encodePooledList(items)
 	case []float64:
-		items := make([]any, len(val))
-		for i, n := range val {
-			items[i] = n
+		items := pool.GetInterfaceSlice()
+		for _, n := range val {
+			items = append(items, n)
 		}
-		return encodePackStreamList(items)
+		return encodePooledList(items)
 	case []float32:
-		items := make([]any, len(val))
-		for i, n := range val {
-			items[i] = float64(n)
+		items := pool.GetInterfaceSlice()
+		for _, n := range val {
+			items = append(items, float64(n))
 		}
-		return encodePackStreamList(items)
+		return encodePooledList(items)
 	case []map[string]any:
-		items := make([]any, len(val))
-		for i, m := range val {
-			items[i] = m
+		items := pool.GetInterfaceSlice()
+		for _, m := range val {
+			items = append(items, m)
 		}
-		return encodePackStreamList(items)
+		return encodePooledList(items)
 	// Map types
 	case map[string]any:
 		// Check if this is a node (has _nodeId and labels)
@@ -1763,29 +2298,26 @@ func encodeNode(nodeId any, labels any, nodeMap map[string]any) []byte {
 	buf := []byte{0xB3, 0x4E}
 
 	// Field 1: Node ID (as int64 for Neo4j compatibility)
-	// Use element_id or _nodeId string, hash it to int64 for now
 	idStr, _ := nodeId.(string)
-	// Use a simple hash - Neo4j drivers use int64 IDs
-	var id int64 = 0
-	for _, c := range idStr {
-		id = id*31 + int64(c)
-	}
-	buf = append(buf, encodePackStreamInt(id)...)
+	buf = append(buf, encodePackStreamInt(boltID(idStr))...)
 
 	// Field 2: Labels (list of strings)
-	labelList := make([]any, 0)
 	switch l := labels.(type) {
 	case []string:
+		labelList := pool.GetInterfaceSlice()
 		for _, s := range l {
 			labelList = append(labelList, s)
 		}
+		buf = append(buf, encodePooledList(labelList)...)
 	case []any:
-		labelList = l
+		buf = append(buf, encodePackStreamList(l)...)
+	default:
+		buf = append(buf, encodePackStreamList(nil)...)
 	}
-	buf = append(buf, encodePackStreamList(labelList)...)
 
-	// Field 3: Properties (map) - exclude internal fields
-	props := make(map[string]any)
+	// Field 3: Properties (map) - exclude internal fields. The pooled map is
+	// returned once encoded; the bytes do not reference it.
+	props := pool.GetMap()
 	for k, v := range nodeMap {
 		// Skip internal fields
 		if k == "_nodeId" || k == "labels" {
@@ -1794,6 +2326,7 @@ func encodeNode(nodeId any, labels any, nodeMap map[string]any) []byte {
 		props[k] = v
 	}
 	buf = append(buf, encodePackStreamMap(props)...)
+	pool.PutMap(props)
 
 	return buf
 }
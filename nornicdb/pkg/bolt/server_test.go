@@ -3,13 +3,25 @@ package bolt
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/orneryd/nornicdb/pkg/bookmark"
+	"github.com/orneryd/nornicdb/pkg/cypher"
+	"github.com/orneryd/nornicdb/pkg/idempotency"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/requestid"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/orneryd/nornicdb/pkg/writethrottle"
 )
 
 // mockExecutor implements QueryExecutor for testing.
@@ -1513,6 +1525,469 @@ func TestSessionExecuteWithParams(t *testing.T) {
 	}
 }
 
+func TestSessionExecutorPanicIsolated(t *testing.T) {
+	calls := 0
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, query string, params map[string]any) (*QueryResult, error) {
+			calls++
+			if calls == 1 {
+				panic("boom")
+			}
+			return &QueryResult{Columns: []string{"n"}, Rows: [][]any{{int64(1)}}}, nil
+		},
+	}
+
+	runMessage := func(query string) []byte {
+		full := []byte{0xB1, MsgRun}
+		full = append(full, encodePackStreamString(query)...)
+		full = append(full, encodePackStreamMap(map[string]any{})...)
+		data := []byte{byte(len(full) >> 8), byte(len(full))}
+		data = append(data, full...)
+		return append(data, 0x00, 0x00)
+	}
+
+	conn := &mockConn{readData: append(runMessage("RETURN 1"), runMessage("RETURN 1")...)}
+	server := New(DefaultConfig(), executor)
+	session := newTestSession(conn, executor)
+	session.server = server
+
+	// First query panics: the session must answer with FAILURE, not die.
+	if err := session.handleMessage(); err != nil {
+		t.Fatalf("handleMessage after panic: %v", err)
+	}
+	if !strings.Contains(string(conn.writeData), "Neo.DatabaseError.General.UnknownError") {
+		t.Errorf("expected UnknownError failure, got %q", conn.writeData)
+	}
+	if got := server.PanicCount(); got != 1 {
+		t.Errorf("PanicCount: got %d, want 1", got)
+	}
+
+	// Second query on the same session still works.
+	conn.writeData = nil
+	if err := session.handleMessage(); err != nil {
+		t.Fatalf("handleMessage after recovery: %v", err)
+	}
+	if len(conn.writeData) < 4 || conn.writeData[3] != MsgSuccess {
+		t.Errorf("expected SUCCESS after recovery, got %x", conn.writeData)
+	}
+}
+
+func TestSessionRunPropagatesRequestID(t *testing.T) {
+	var seenID string
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, query string, params map[string]any) (*QueryResult, error) {
+			seenID = requestid.FromContext(ctx)
+			return nil, fmt.Errorf("bad query")
+		},
+	}
+
+	full := []byte{0xB1, MsgRun}
+	full = append(full, encodePackStreamString("RETURN x")...)
+	full = append(full, encodePackStreamMap(map[string]any{})...)
+	data := []byte{byte(len(full) >> 8), byte(len(full))}
+	data = append(data, full...)
+	data = append(data, 0x00, 0x00)
+
+	conn := &mockConn{readData: data}
+	session := newTestSession(conn, executor)
+	if err := session.handleMessage(); err != nil {
+		t.Fatalf("handleMessage: %v", err)
+	}
+
+	if seenID == "" {
+		t.Fatal("executor did not receive a request ID in context")
+	}
+	if seenID != session.requestID {
+		t.Errorf("request ID mismatch: executor saw %q, session has %q", seenID, session.requestID)
+	}
+	if !strings.Contains(string(conn.writeData), "request_id: "+seenID) {
+		t.Errorf("failure message should include request ID %q, got %q", seenID, conn.writeData)
+	}
+}
+
+func TestSessionRunTxTimeout(t *testing.T) {
+	var deadlines []bool
+	executor := &mockTransactionalExecutor{mockExecutor: mockExecutor{
+		executeFunc: func(ctx context.Context, query string, params map[string]any) (*QueryResult, error) {
+			_, ok := ctx.Deadline()
+			deadlines = append(deadlines, ok)
+			if query == "slow" {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return &QueryResult{Columns: []string{"x"}, Rows: [][]any{{int64(1)}}}, nil
+		},
+	}}
+	run := func(query string, extra map[string]any) []byte {
+		full := []byte{0xB3, MsgRun}
+		full = append(full, encodePackStreamString(query)...)
+		full = append(full, encodePackStreamMap(map[string]any{})...)
+		full = append(full, encodePackStreamMap(extra)...)
+		return full
+	}
+
+	t.Run("auto-commit RUN", func(t *testing.T) {
+		deadlines = nil
+		conn := &mockConn{}
+		session := newTestSession(conn, executor)
+
+		if err := session.handleRun(run("fast", nil)[2:]); err != nil {
+			t.Fatal(err)
+		}
+		if err := session.handleRun(run("slow", map[string]any{"tx_timeout": int64(20)})[2:]); err != nil {
+			t.Fatal(err)
+		}
+		if len(deadlines) != 2 || deadlines[0] || !deadlines[1] {
+			t.Errorf("only the RUN with tx_timeout should carry a deadline, got %v", deadlines)
+		}
+		if out := string(conn.writeData); !strings.Contains(out, "Neo.TransientError.Transaction.TransactionTimedOut") {
+			t.Errorf("expected TransactionTimedOut failure, got %q", out)
+		}
+	})
+
+	t.Run("BEGIN tx_timeout", func(t *testing.T) {
+		deadlines = nil
+		conn := &mockConn{}
+		session := newTestSession(conn, executor)
+
+		if err := session.handleBegin(encodePackStreamMap(map[string]any{"tx_timeout": int64(30)})); err != nil {
+			t.Fatal(err)
+		}
+		if err := session.handleRun(run("fast", nil)[2:]); err != nil {
+			t.Fatal(err)
+		}
+		if len(deadlines) != 1 || !deadlines[0] {
+			t.Errorf("queries in the transaction should carry its deadline, got %v", deadlines)
+		}
+
+		// Past the deadline the transaction is rolled back and COMMIT fails
+		time.Sleep(40 * time.Millisecond)
+		if err := session.handleCommit(nil); err != nil {
+			t.Fatal(err)
+		}
+		if executor.commitCalled || !executor.rollbackCalled {
+			t.Errorf("expired transaction should roll back, not commit")
+		}
+		if session.inTransaction {
+			t.Error("expired transaction should be cleared")
+		}
+		if out := string(conn.writeData); !strings.Contains(out, "Neo.TransientError.Transaction.TransactionTimedOut") {
+			t.Errorf("expected TransactionTimedOut failure, got %q", out)
+		}
+	})
+}
+
+func TestSessionBookmarks(t *testing.T) {
+	leader := bookmark.New(0)
+	replica := bookmark.New(30 * time.Millisecond)
+	replica.Advance(leader.Epoch(), 0)
+	server := &Server{config: DefaultConfig(), bookmarks: replica}
+	executor := &mockTransactionalExecutor{}
+	newSession := func(conn *mockConn) *Session {
+		session := newTestSession(conn, executor)
+		session.server = server
+		return session
+	}
+	run := func(query string, extra map[string]any) []byte {
+		full := encodePackStreamString(query)
+		full = append(full, encodePackStreamMap(map[string]any{})...)
+		return append(full, encodePackStreamMap(extra)...)
+	}
+
+	t.Run("commit returns a new bookmark", func(t *testing.T) {
+		conn := &mockConn{}
+		session := newSession(conn)
+		if err := session.handleBegin(nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := session.handleCommit(nil); err != nil {
+			t.Fatal(err)
+		}
+		if out := string(conn.writeData); !strings.Contains(out, replica.Current()) {
+			t.Errorf("expected bookmark %q in COMMIT success, got %q", replica.Current(), out)
+		}
+	})
+
+	t.Run("BEGIN waits for the bookmarked state", func(t *testing.T) {
+		leader.Commit()
+		token := leader.Commit()
+		conn := &mockConn{}
+		session := newSession(conn)
+		go func() {
+			time.Sleep(5 * time.Millisecond)
+			replica.Advance(leader.Epoch(), 2)
+		}()
+		if err := session.handleBegin(encodePackStreamMap(map[string]any{"bookmarks": []any{token}})); err != nil {
+			t.Fatal(err)
+		}
+		if !session.inTransaction || strings.Contains(string(conn.writeData), "Neo.") {
+			t.Errorf("BEGIN should succeed once the replica caught up, got %q", conn.writeData)
+		}
+	})
+
+	t.Run("auto-commit RUN times out on a lagging replica", func(t *testing.T) {
+		conn := &mockConn{}
+		session := newSession(conn)
+		if err := session.handleRun(run("MATCH (n) RETURN n", map[string]any{"bookmarks": []any{leader.Commit()}})); err != nil {
+			t.Fatal(err)
+		}
+		if out := string(conn.writeData); !strings.Contains(out, "Neo.TransientError.Transaction.BookmarkTimeout") {
+			t.Errorf("expected BookmarkTimeout failure, got %q", out)
+		}
+	})
+
+	t.Run("invalid bookmark", func(t *testing.T) {
+		conn := &mockConn{}
+		session := newSession(conn)
+		if err := session.handleBegin(encodePackStreamMap(map[string]any{"bookmarks": []any{"FB:nornicdb:1"}})); err != nil {
+			t.Fatal(err)
+		}
+		if session.inTransaction {
+			t.Error("BEGIN with an invalid bookmark should not start a transaction")
+		}
+		if out := string(conn.writeData); !strings.Contains(out, "Neo.ClientError.Transaction.InvalidBookmark") {
+			t.Errorf("expected InvalidBookmark failure, got %q", out)
+		}
+	})
+}
+
+func TestSessionRunRateLimited(t *testing.T) {
+	calls := 0
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, query string, params map[string]any) (*QueryResult, error) {
+			calls++
+			return &QueryResult{Columns: []string{"x"}, Rows: [][]any{{int64(1)}}}, nil
+		},
+	}
+
+	full := []byte{0xB1, MsgRun}
+	full = append(full, encodePackStreamString("RETURN 1 AS x")...)
+	full = append(full, encodePackStreamMap(map[string]any{})...)
+	msg := []byte{byte(len(full) >> 8), byte(len(full))}
+	msg = append(msg, full...)
+	msg = append(msg, 0x00, 0x00)
+
+	conn := &mockConn{readData: append(append([]byte{}, msg...), msg...)}
+	session := newTestSession(conn, executor)
+	session.server = &Server{config: &Config{
+		QueryLimiter: ratelimit.New(ratelimit.Config{
+			Enabled: true,
+			Default: ratelimit.Limits{QueriesPerSecond: 1, Burst: 1},
+		}),
+	}}
+
+	if err := session.handleMessage(); err != nil {
+		t.Fatalf("first RUN: %v", err)
+	}
+	if err := session.handleMessage(); err != nil {
+		t.Fatalf("second RUN: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected throttled query to skip executor, got %d calls", calls)
+	}
+	out := string(conn.writeData)
+	if !strings.Contains(out, "Neo.ClientError.Request.TooManyRequests") {
+		t.Errorf("expected TooManyRequests failure, got %q", out)
+	}
+	if !strings.Contains(out, ratelimit.LimitQueriesPerSecond) {
+		t.Errorf("failure should name the exceeded limit, got %q", out)
+	}
+}
+
+func TestSessionRunQueryPanic(t *testing.T) {
+	exec := cypher.NewStorageExecutor(storage.NewMemoryEngine())
+	exec.SetNodeCreatedCallback(func(nodeID string) {
+		panic("callback exploded")
+	})
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, query string, params map[string]any) (*QueryResult, error) {
+			_, err := exec.Execute(ctx, query, params)
+			return nil, err
+		},
+	}
+
+	full := []byte{0xB1, MsgRun}
+	full = append(full, encodePackStreamString("CREATE (n:Person)")...)
+	full = append(full, encodePackStreamMap(map[string]any{})...)
+	msg := []byte{byte(len(full) >> 8), byte(len(full))}
+	msg = append(msg, full...)
+	msg = append(msg, 0x00, 0x00)
+
+	conn := &mockConn{readData: msg}
+	session := newTestSession(conn, executor)
+	if err := session.handleMessage(); err != nil {
+		t.Fatalf("RUN: %v", err)
+	}
+
+	// A recovered panic is a database error, not the client's syntax error
+	out := string(conn.writeData)
+	if !strings.Contains(out, "Neo.DatabaseError.General.UnknownError") {
+		t.Errorf("expected UnknownError failure, got %q", out)
+	}
+}
+
+func TestSessionRunWriteThrottled(t *testing.T) {
+	var queries []string
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, query string, params map[string]any) (*QueryResult, error) {
+			queries = append(queries, query)
+			return &QueryResult{Columns: []string{"x"}, Rows: [][]any{{int64(1)}}}, nil
+		},
+	}
+
+	run := func(query string) []byte {
+		full := []byte{0xB1, MsgRun}
+		full = append(full, encodePackStreamString(query)...)
+		full = append(full, encodePackStreamMap(map[string]any{})...)
+		msg := []byte{byte(len(full) >> 8), byte(len(full))}
+		msg = append(msg, full...)
+		return append(msg, 0x00, 0x00)
+	}
+	conn := &mockConn{readData: append(run("CREATE (n) RETURN 1 AS x"), run("RETURN 1 AS x")...)}
+	session := newTestSession(conn, executor)
+	session.server = &Server{config: &Config{
+		WriteThrottle: writethrottle.New(writethrottle.DefaultConfig(), func() writethrottle.Signals {
+			return writethrottle.Signals{WALSyncLag: time.Minute}
+		}),
+	}}
+
+	for i := 0; i < 2; i++ {
+		if err := session.handleMessage(); err != nil {
+			t.Fatalf("RUN %d: %v", i+1, err)
+		}
+	}
+
+	if len(queries) != 1 || queries[0] != "RETURN 1 AS x" {
+		t.Errorf("expected only the read to reach the executor, got %v", queries)
+	}
+	out := string(conn.writeData)
+	if !strings.Contains(out, "Neo.TransientError.Request.ResourceExhaustion") {
+		t.Errorf("expected a transient failure for the write, got %q", out)
+	}
+	if !strings.Contains(out, writethrottle.SignalWALLag) {
+		t.Errorf("failure should name the signal, got %q", out)
+	}
+}
+
+func TestSessionRunIdempotencyKey(t *testing.T) {
+	calls := 0
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, query string, params map[string]any) (*QueryResult, error) {
+			calls++
+			if _, ok := params[idempotency.ParamName]; ok {
+				t.Errorf("idempotency key parameter reached the executor")
+			}
+			return &QueryResult{Columns: []string{"id"}, Rows: [][]any{{int64(calls)}}}, nil
+		},
+	}
+
+	run := func(query string, params, extra map[string]any) []byte {
+		full := []byte{0xB3, MsgRun}
+		full = append(full, encodePackStreamString(query)...)
+		full = append(full, encodePackStreamMap(params)...)
+		full = append(full, encodePackStreamMap(extra)...)
+		msg := []byte{byte(len(full) >> 8), byte(len(full))}
+		msg = append(msg, full...)
+		return append(msg, 0x00, 0x00)
+	}
+	var data []byte
+	// First attempt passes the key in tx_metadata, the retry as a parameter
+	data = append(data, run("CREATE (n) RETURN id(n) AS id", map[string]any{},
+		map[string]any{"tx_metadata": map[string]any{idempotency.MetadataKey: "k1"}})...)
+	data = append(data, run("CREATE (n) RETURN id(n) AS id", map[string]any{idempotency.ParamName: "k1"}, map[string]any{})...)
+	data = append(data, run("CREATE (m) RETURN id(m) AS id", map[string]any{idempotency.ParamName: "k1"}, map[string]any{})...)
+
+	conn := &mockConn{readData: data}
+	session := newTestSession(conn, executor)
+	session.server = &Server{config: &Config{Idempotency: idempotency.New(time.Minute, 0)}}
+
+	for i := 0; i < 2; i++ {
+		if err := session.handleMessage(); err != nil {
+			t.Fatalf("RUN %d: %v", i+1, err)
+		}
+		if session.lastResult == nil || len(session.lastResult.Rows) != 1 ||
+			!bytes.Equal(encodePackStreamValue(session.lastResult.Rows[0][0]), encodePackStreamValue(int64(1))) {
+			t.Fatalf("RUN %d: expected the first write's result, got %+v", i+1, session.lastResult)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected retried write to run once, got %d calls", calls)
+	}
+
+	if err := session.handleMessage(); err != nil {
+		t.Fatalf("third RUN: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("key reused for a different statement must not run, got %d calls", calls)
+	}
+	if !strings.Contains(string(conn.writeData), "Neo.ClientError.Request.Invalid") {
+		t.Errorf("expected Request.Invalid failure for reused key, got %q", conn.writeData)
+	}
+}
+
+func TestRecordedResultReplaysSameBytes(t *testing.T) {
+	// One entry per map: encoded map order follows Go map iteration
+	node := &storage.Node{ID: "n1", Labels: []string{"Order"}, Properties: map[string]any{"total": 9.0}}
+	result := &QueryResult{
+		Columns: []string{"n", "count", "ratio", "tags", "props"},
+		Rows: [][]any{
+			{node, int64(1) << 60, 2.0, []any{"a", int64(1)}, map[string]any{"k": nil}},
+			{nil, int64(-1), 0.5, []string{}, map[string]any{}},
+		},
+	}
+
+	// Replayed after a restart: recorded as JSON by the store, then decoded
+	encoded, err := json.Marshal(recordResult(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := idempotency.Decode[*recordedResult](json.RawMessage(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := rec.queryResult()
+
+	if !reflect.DeepEqual(replay.Columns, result.Columns) {
+		t.Errorf("columns = %v, want %v", replay.Columns, result.Columns)
+	}
+	for i, row := range result.Rows {
+		want := packStreamEncoder{}
+		want.writeRecord(row)
+		got := packStreamEncoder{}
+		got.writeRecord(replay.Rows[i])
+		if !bytes.Equal(got.buf, want.buf) {
+			t.Errorf("row %d: replayed % x, want % x", i, got.buf, want.buf)
+		}
+	}
+}
+
+func TestSessionRunIdempotencyKeyInTransaction(t *testing.T) {
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, query string, params map[string]any) (*QueryResult, error) {
+			t.Errorf("query with a key inside a transaction should be rejected")
+			return &QueryResult{}, nil
+		},
+	}
+
+	full := []byte{0xB2, MsgRun}
+	full = append(full, encodePackStreamString("CREATE (n)")...)
+	full = append(full, encodePackStreamMap(map[string]any{idempotency.ParamName: "k"})...)
+	data := []byte{byte(len(full) >> 8), byte(len(full))}
+	data = append(data, full...)
+	data = append(data, 0x00, 0x00)
+
+	conn := &mockConn{readData: data}
+	session := newTestSession(conn, executor)
+	session.inTransaction = true
+	if err := session.handleMessage(); err != nil {
+		t.Fatalf("handleMessage: %v", err)
+	}
+	if !strings.Contains(string(conn.writeData), "explicit transactions") {
+		t.Errorf("expected failure for key in explicit transaction, got %q", conn.writeData)
+	}
+}
+
 // =============================================================================
 // Tests for truncateQuery helper
 // =============================================================================
@@ -2286,21 +2761,25 @@ type mockTransactionalExecutor struct {
 	commitError    error
 	rollbackError  error
 	lastMetadata   map[string]any
+	txIDs          []string // TransactionID of each call's context
 }
 
 func (m *mockTransactionalExecutor) BeginTransaction(ctx context.Context, metadata map[string]any) error {
 	m.beginCalled = true
 	m.lastMetadata = metadata
+	m.txIDs = append(m.txIDs, TransactionID(ctx))
 	return m.beginError
 }
 
 func (m *mockTransactionalExecutor) CommitTransaction(ctx context.Context) error {
 	m.commitCalled = true
+	m.txIDs = append(m.txIDs, TransactionID(ctx))
 	return m.commitError
 }
 
 func (m *mockTransactionalExecutor) RollbackTransaction(ctx context.Context) error {
 	m.rollbackCalled = true
+	m.txIDs = append(m.txIDs, TransactionID(ctx))
 	return m.rollbackError
 }
 
@@ -2439,6 +2918,24 @@ func TestHandleCommitWithTransactionalExecutor(t *testing.T) {
 			t.Error("transaction state should be cleared even on error")
 		}
 	})
+
+	t.Run("commit conflict returns transient failure", func(t *testing.T) {
+		executor := &mockTransactionalExecutor{
+			commitError: fmt.Errorf("commit: %w", storage.ErrConflict),
+		}
+		conn := &mockConn{}
+		session := newTestSession(conn, executor)
+		session.inTransaction = true
+
+		if err := session.handleCommit(nil); err != nil {
+			t.Fatalf("handleCommit should not return Go error: %v", err)
+		}
+
+		// Drivers retry the transaction on a TransientError
+		if out := string(conn.writeData); !strings.Contains(out, "Neo.TransientError.Transaction.Outdated") {
+			t.Errorf("expected Outdated failure, got %q", out)
+		}
+	})
 }
 
 func TestHandleRollbackWithTransactionalExecutor(t *testing.T) {
@@ -2515,6 +3012,52 @@ func TestHandleResetWithTransactionalExecutor(t *testing.T) {
 	})
 }
 
+func TestTransactionIDContext(t *testing.T) {
+	executor := &mockTransactionalExecutor{}
+	executor.executeFunc = func(ctx context.Context, query string, params map[string]any) (*QueryResult, error) {
+		executor.txIDs = append(executor.txIDs, TransactionID(ctx))
+		return &QueryResult{Columns: []string{"n"}}, nil
+	}
+	session := newTestSession(&mockConn{}, executor)
+	run := func() {
+		t.Helper()
+		msg := append(encodePackStreamString("RETURN 1"), 0xA0)
+		if err := session.handleRun(msg); err != nil {
+			t.Fatalf("handleRun error: %v", err)
+		}
+	}
+
+	run()
+	if err := session.handleBegin(nil); err != nil {
+		t.Fatalf("handleBegin error: %v", err)
+	}
+	run()
+	if err := session.handleCommit(nil); err != nil {
+		t.Fatalf("handleCommit error: %v", err)
+	}
+	if err := session.handleBegin(nil); err != nil {
+		t.Fatalf("handleBegin error: %v", err)
+	}
+	if err := session.handleRollback(nil); err != nil {
+		t.Fatalf("handleRollback error: %v", err)
+	}
+	run()
+
+	ids := executor.txIDs
+	if len(ids) != 7 {
+		t.Fatalf("expected 7 calls, got %d: %v", len(ids), ids)
+	}
+	if ids[0] != "" || ids[6] != "" {
+		t.Errorf("queries outside a transaction should have no transaction ID: %v", ids)
+	}
+	if ids[1] == "" || ids[2] != ids[1] || ids[3] != ids[1] {
+		t.Errorf("BEGIN, RUN and COMMIT should share a transaction ID: %v", ids)
+	}
+	if ids[4] == "" || ids[4] == ids[1] || ids[5] != ids[4] {
+		t.Errorf("each BEGIN should get a new transaction ID: %v", ids)
+	}
+}
+
 func TestTransactionWithNonTransactionalExecutor(t *testing.T) {
 	t.Run("begin works without TransactionalExecutor", func(t *testing.T) {
 		executor := &mockExecutor{} // Does NOT implement TransactionalExecutor
@@ -3249,3 +3792,163 @@ func TestAuthDisabled(t *testing.T) {
 		}
 	})
 }
+
+func TestSessionConfigCommand(t *testing.T) {
+	var seenQuery string
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, query string, params map[string]any) (*QueryResult, error) {
+			seenQuery = query
+			return &QueryResult{
+				Columns: []string{"n"},
+				Rows:    [][]any{{int64(1)}, {int64(2)}, {int64(3)}, {int64(4)}, {int64(5)}},
+			}, nil
+		},
+	}
+	session := newTestSession(&mockConn{}, executor)
+	run := func(query string) error {
+		payload := encodePackStreamString(query)
+		payload = append(payload, encodePackStreamMap(map[string]any{})...)
+		return session.handleRun(payload)
+	}
+
+	if err := run(":config runtime=parallel timeout=2s fetch_size=2"); err != nil {
+		t.Fatalf("handleRun(:config) error = %v", err)
+	}
+	if seenQuery != "" {
+		t.Fatal(":config must not reach the executor")
+	}
+	want := [][]any{{"runtime", "parallel"}, {"timeout", "2s"}, {"fetch_size", int64(2)}}
+	if fmt.Sprint(session.lastResult.Rows) != fmt.Sprint(want) {
+		t.Errorf(":config rows = %v, want %v", session.lastResult.Rows, want)
+	}
+
+	// Later queries carry the options as a CYPHER prefix
+	if err := run("MATCH (n) RETURN n"); err != nil {
+		t.Fatalf("handleRun() error = %v", err)
+	}
+	if seenQuery != "CYPHER runtime=parallel timeout=2s MATCH (n) RETURN n" {
+		t.Errorf("executor saw %q", seenQuery)
+	}
+
+	// Pull-all requests are capped at the fetch size
+	if err := session.handlePull(nil); err != nil {
+		t.Fatalf("handlePull() error = %v", err)
+	}
+	if session.resultIndex != 2 {
+		t.Errorf("pulled %d records, want 2", session.resultIndex)
+	}
+
+	// Invalid settings fail without changing the session
+	conn := &mockConn{}
+	session.conn, session.writer = conn, bufio.NewWriter(conn)
+	for _, bad := range []string{":config colour=blue", ":config fetch_size=-1", ":config runtime"} {
+		if err := run(bad); err != nil {
+			t.Fatalf("handleRun(%q) error = %v", bad, err)
+		}
+	}
+	if !strings.Contains(string(conn.writeData), "unknown :config setting") {
+		t.Errorf("expected failure for unknown setting, got %q", conn.writeData)
+	}
+
+	if err := run(":config reset"); err != nil {
+		t.Fatalf("handleRun(reset) error = %v", err)
+	}
+	if len(session.lastResult.Rows) != 0 || session.fetchSize != 0 {
+		t.Errorf("reset left settings: %v", session.lastResult.Rows)
+	}
+	if err := run("RETURN 1"); err != nil {
+		t.Fatalf("handleRun() error = %v", err)
+	}
+	if seenQuery != "RETURN 1" {
+		t.Errorf("executor saw %q after reset", seenQuery)
+	}
+}
+
+func TestSessionReleasesResults(t *testing.T) {
+	releases := 0
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, query string, params map[string]any) (*QueryResult, error) {
+			rows := [][]any{{"a"}, {"b"}, {"c"}}
+			result := &QueryResult{Columns: []string{"v"}, Rows: rows}
+			result.Release = func() {
+				// Poison the rows like a pool reusing them would
+				for _, row := range rows {
+					row[0] = "poisoned"
+				}
+				releases++
+			}
+			return result, nil
+		},
+	}
+	conn := &mockConn{}
+	session := newTestSession(conn, executor)
+	run := func() {
+		payload := encodePackStreamString("MATCH (n) RETURN n.v")
+		payload = append(payload, encodePackStreamMap(map[string]any{})...)
+		if err := session.handleRun(payload); err != nil {
+			t.Fatalf("handleRun() error = %v", err)
+		}
+	}
+
+	// Released once the last record is encoded, never before
+	run()
+	if err := session.handlePull(encodePackStreamMap(map[string]any{"n": int64(2)})); err != nil {
+		t.Fatalf("handlePull() error = %v", err)
+	}
+	if releases != 0 {
+		t.Fatal("released while records remain")
+	}
+	if err := session.handlePull(nil); err != nil {
+		t.Fatalf("handlePull() error = %v", err)
+	}
+	if releases != 1 || session.lastResult != nil {
+		t.Fatalf("releases = %d after final PULL, want 1", releases)
+	}
+	if strings.Contains(string(conn.writeData), "poisoned") {
+		t.Error("a record was encoded after its rows were released")
+	}
+
+	// DISCARD, RESET and a replacing RUN each release the pending result
+	run()
+	session.handleDiscard(nil)
+	run()
+	session.handleReset(nil)
+	run()
+	run()
+	if releases != 4 {
+		t.Errorf("releases = %d, want 4", releases)
+	}
+	session.setResult(nil)
+	session.setResult(nil)
+	if releases != 5 {
+		t.Errorf("releases = %d after clearing, want 5", releases)
+	}
+}
+
+// TestEncodePooledValuesConcurrent encodes nodes and typed lists, whose
+// scratch maps and slices come from the pool, from many goroutines. Run
+// with -race to detect a scratch object used after it was returned.
+func TestEncodePooledValuesConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			node := map[string]any{
+				"_nodeId": fmt.Sprintf("n%d", w),
+				"labels":  []string{"Person", fmt.Sprintf("L%d", w)},
+				"name":    fmt.Sprintf("name-%d", w),
+			}
+			list := []int64{int64(w), int64(w + 1)}
+			want := append(encodePackStreamValue(node), encodePackStreamValue(list)...)
+			for i := 0; i < 200; i++ {
+				got := append(encodePackStreamValue(node), encodePackStreamValue(list)...)
+				if string(got) != string(want) {
+					t.Errorf("worker %d: encoding changed between calls", w)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
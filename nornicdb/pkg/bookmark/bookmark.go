@@ -0,0 +1,193 @@
+// Package bookmark tracks committed transactions so that sessions can read
+// their own writes, and each other's, across connections and protocols.
+//
+// Every commit is numbered in the server's commit sequence and returned to
+// the client as a bookmark. A client that presents bookmarks when it starts
+// a transaction or runs an auto-commit query is guaranteed to observe the
+// state they name: the server waits until it has applied every commit up to
+// the newest bookmark, and fails with ErrTimeout if that takes too long.
+//
+// On a single server a commit is visible once it returns, so waits end at
+// once. Replicas apply the leader's commits later; they report the
+// positions they have applied with Advance, and a session holding a
+// bookmark from the leader waits until the replica catches up.
+//
+// Bookmarks have the form "nornicdb:bookmark:<epoch>:<seq>". The sequence
+// is held in memory and starts over when the server restarts, with a new
+// epoch. Bookmarks from another epoch were issued before the restart, and
+// the state they name was already applied, so they never wait.
+//
+// The Manager is shared by the Bolt and HTTP servers:
+//
+//	bookmarks := bookmark.New(30 * time.Second)
+//
+//	// Session A commits a write
+//	token := bookmarks.Commit()
+//
+//	// Session B, on any connection, presents A's bookmark before reading
+//	if err := bookmarks.Wait(ctx, []string{token}); err != nil {
+//		return err
+//	}
+package bookmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prefix starts every bookmark.
+const prefix = "nornicdb:bookmark:"
+
+var (
+	// ErrInvalid is returned for a bookmark this server did not issue.
+	ErrInvalid = errors.New("invalid bookmark")
+
+	// ErrTimeout is returned when the state named by a bookmark was not
+	// applied within the wait timeout.
+	ErrTimeout = errors.New("timed out waiting for bookmark")
+)
+
+// Manager numbers commits and waits for bookmarks. It is safe for
+// concurrent use. A nil *Manager issues no bookmarks and never waits.
+type Manager struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	epoch   string
+	applied uint64        // last commit visible on this server
+	changed chan struct{} // closed and replaced when applied grows
+}
+
+// New returns a manager with a new epoch. Wait gives up after timeout
+// (0 = only when its context ends).
+func New(timeout time.Duration) *Manager {
+	return &Manager{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		timeout: timeout,
+		changed: make(chan struct{}),
+	}
+}
+
+// Commit records a committed transaction and returns its bookmark.
+func (m *Manager) Commit() string {
+	if m == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.applied++
+	m.notifyLocked()
+	return m.formatLocked()
+}
+
+// Current returns the bookmark of the last applied commit. Read-only
+// transactions return it, so a session's bookmark never moves backwards.
+func (m *Manager) Current() string {
+	if m == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.formatLocked()
+}
+
+// Epoch returns the epoch of the commit sequence.
+func (m *Manager) Epoch() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.epoch
+}
+
+// Advance marks the commits of epoch up to seq as applied, waking the
+// sessions waiting for them. Replicas call it with the leader's epoch as
+// they apply its commits, so that the leader's bookmarks wait on them. A
+// new epoch replaces the current one; a seq at or below the current
+// position is ignored.
+func (m *Manager) Advance(epoch string, seq uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if epoch != m.epoch {
+		m.epoch = epoch
+		m.applied = 0
+	}
+	if seq > m.applied {
+		m.applied = seq
+	}
+	m.notifyLocked()
+}
+
+// Wait blocks until the state named by every bookmark has been applied.
+// Empty bookmarks are skipped. It returns an error wrapping ErrInvalid for
+// a malformed bookmark, or ErrTimeout if the timeout passes or ctx ends
+// first.
+func (m *Manager) Wait(ctx context.Context, bookmarks []string) error {
+	if m == nil {
+		return nil
+	}
+	targets := make(map[string]uint64, len(bookmarks))
+	for _, b := range bookmarks {
+		if b == "" {
+			continue
+		}
+		epoch, seq, err := parse(b)
+		if err != nil {
+			return err
+		}
+		targets[epoch] = max(targets[epoch], seq)
+	}
+
+	var expired <-chan time.Time
+	for {
+		m.mu.Lock()
+		// Bookmarks from another epoch were applied before it ended
+		target, applied, changed := targets[m.epoch], m.applied, m.changed
+		m.mu.Unlock()
+		if applied >= target {
+			return nil
+		}
+		if expired == nil && m.timeout > 0 {
+			timer := time.NewTimer(m.timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+		select {
+		case <-changed:
+		case <-expired:
+			return fmt.Errorf("%w: commit %d not applied after %s (applied: %d)", ErrTimeout, target, m.timeout, applied)
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrTimeout, ctx.Err())
+		}
+	}
+}
+
+// notifyLocked wakes the waiters. m.mu must be held.
+func (m *Manager) notifyLocked() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// formatLocked returns the bookmark of the last applied commit. m.mu must
+// be held.
+func (m *Manager) formatLocked() string {
+	return prefix + m.epoch + ":" + strconv.FormatUint(m.applied, 10)
+}
+
+// parse splits a bookmark into its epoch and commit sequence.
+func parse(b string) (epoch string, seq uint64, err error) {
+	rest, ok := strings.CutPrefix(b, prefix)
+	if ok {
+		var n string
+		epoch, n, ok = strings.Cut(rest, ":")
+		if ok && epoch != "" {
+			if seq, err = strconv.ParseUint(n, 10, 64); err == nil {
+				return epoch, seq, nil
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("%w: %q", ErrInvalid, b)
+}
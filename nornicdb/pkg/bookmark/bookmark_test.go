@@ -0,0 +1,85 @@
+package bookmark
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitAdvancesBookmark(t *testing.T) {
+	m := New(time.Second)
+	start := m.Current()
+	first := m.Commit()
+	second := m.Commit()
+
+	assert.True(t, strings.HasPrefix(first, "nornicdb:bookmark:"+m.Epoch()+":"))
+	assert.NotEqual(t, start, first)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, second, m.Current(), "reads return the last commit")
+	require.NoError(t, m.Wait(context.Background(), []string{first, second, ""}))
+}
+
+func TestWaitInvalid(t *testing.T) {
+	m := New(time.Second)
+	for _, b := range []string{"FB:nornicdb:1", "nornicdb:bookmark:1", "nornicdb:bookmark:e:x", "nornicdb:bookmark::1"} {
+		assert.ErrorIs(t, m.Wait(context.Background(), []string{b}), ErrInvalid, b)
+	}
+}
+
+func TestWaitForReplica(t *testing.T) {
+	leader := New(time.Second)
+	leader.Commit()
+	token := leader.Commit()
+
+	replica := New(time.Second)
+	replica.Advance(leader.Epoch(), 1)
+
+	done := make(chan error, 1)
+	go func() { done <- replica.Wait(context.Background(), []string{token}) }()
+	select {
+	case err := <-done:
+		t.Fatalf("returned before the replica applied the commit: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	replica.Advance(leader.Epoch(), 2)
+	require.NoError(t, <-done)
+	assert.Equal(t, token, replica.Current())
+}
+
+func TestWaitTimeout(t *testing.T) {
+	leader := New(0)
+	token := leader.Commit()
+
+	replica := New(10 * time.Millisecond)
+	replica.Advance(leader.Epoch(), 0)
+	assert.ErrorIs(t, replica.Wait(context.Background(), []string{token}), ErrTimeout)
+
+	// Without a timeout, the context bounds the wait
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	replica = New(0)
+	replica.Advance(leader.Epoch(), 0)
+	assert.ErrorIs(t, replica.Wait(ctx, []string{token}), ErrTimeout)
+}
+
+func TestWaitOtherEpoch(t *testing.T) {
+	before := New(time.Second)
+	token := before.Commit()
+
+	// A restarted server has a new epoch: the old bookmark is satisfied
+	after := New(10 * time.Millisecond)
+	assert.NotEqual(t, before.Epoch(), after.Epoch())
+	assert.NoError(t, after.Wait(context.Background(), []string{token}))
+}
+
+func TestNilManager(t *testing.T) {
+	var m *Manager
+	assert.Empty(t, m.Commit())
+	assert.Empty(t, m.Current())
+	assert.NoError(t, m.Wait(context.Background(), []string{"not a bookmark"}))
+}
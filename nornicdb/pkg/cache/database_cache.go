@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Per-Database Query Caches
+// =============================================================================
+
+// DefaultDatabase is the database whose plans live in GlobalQueryCache.
+const DefaultDatabase = "neo4j"
+
+var (
+	// databaseCaches holds the query cache of each database other than the
+	// default one. Guarded by globalQueryCacheMu.
+	databaseCaches = map[string]*QueryCache{}
+	// databaseBudgets holds the sizes set with ConfigureDatabaseCache.
+	// Databases without one follow the global cache's size and TTL.
+	databaseBudgets = map[string]int{}
+)
+
+// DatabaseQueryCache returns the query cache of a database.
+//
+// Each database has its own cache, so plans cached for one tenant are never
+// returned for another and a busy database cannot evict the plans of a quiet
+// one. Hits, misses and capacity are counted per database. The default
+// database ("" or DefaultDatabase) uses GlobalQueryCache.
+//
+// A database's cache is created on first use, sized by ConfigureDatabaseCache
+// or else like the global cache.
+//
+// Example:
+//
+//	qc := cache.DatabaseQueryCache(dbName)
+//	key := qc.Key(query, params)
+//	if plan, ok := qc.Get(key); ok {
+//		return plan.(*ParsedPlan)
+//	}
+//
+// Thread Safety:
+//   - Safe to call concurrently; every call for a database returns the same cache
+func DatabaseQueryCache(db string) *QueryCache {
+	if db == "" || db == DefaultDatabase {
+		return GlobalQueryCache()
+	}
+	globalQueryCacheMu.Lock()
+	defer globalQueryCacheMu.Unlock()
+	if c, ok := databaseCaches[db]; ok {
+		return c
+	}
+	global := globalQueryCacheLocked()
+	size, ttl := global.Stats().MaxSize, global.Stats().TTL
+	if budget, ok := databaseBudgets[db]; ok {
+		size = budget
+	}
+	c := NewQueryCache(size, ttl)
+	databaseCaches[db] = c
+	return c
+}
+
+// ConfigureDatabaseCache gives a database its own cache size budget.
+//
+// The database's cache is resized in place if it exists, keeping its most
+// recently used plans. The budget stays in force when the global cache is
+// reconfigured. Configuring the default database is the same as
+// ConfigureGlobalCache.
+//
+// Example:
+//
+//	// A large tenant gets more room than the default
+//	cache.ConfigureDatabaseCache("tenant_a", 5000, 10*time.Minute)
+func ConfigureDatabaseCache(db string, maxSize int, ttl time.Duration) {
+	if db == "" || db == DefaultDatabase {
+		ConfigureGlobalCache(maxSize, ttl)
+		return
+	}
+	globalQueryCacheMu.Lock()
+	defer globalQueryCacheMu.Unlock()
+	databaseBudgets[db] = maxSize
+	if c, ok := databaseCaches[db]; ok {
+		c.Resize(maxSize, ttl)
+		return
+	}
+	databaseCaches[db] = NewQueryCache(maxSize, ttl)
+}
+
+// DropDatabaseCache discards a database's cache and budget, for example
+// when the database is dropped. The default database's cache is cleared.
+func DropDatabaseCache(db string) {
+	if db == "" || db == DefaultDatabase {
+		GlobalQueryCache().Clear()
+		return
+	}
+	globalQueryCacheMu.Lock()
+	defer globalQueryCacheMu.Unlock()
+	delete(databaseCaches, db)
+	delete(databaseBudgets, db)
+}
+
+// DatabaseCacheStats returns the statistics of every database's cache,
+// keyed by database name. The default database is always included.
+//
+// Example:
+//
+//	for db, stats := range cache.DatabaseCacheStats() {
+//		log.Printf("%s: %d/%d plans, %.1f%% hit rate",
+//			db, stats.Size, stats.MaxSize, stats.HitRate)
+//	}
+func DatabaseCacheStats() map[string]CacheStats {
+	globalQueryCacheMu.Lock()
+	caches := make(map[string]*QueryCache, len(databaseCaches)+1)
+	caches[DefaultDatabase] = globalQueryCacheLocked()
+	for db, c := range databaseCaches {
+		caches[db] = c
+	}
+	globalQueryCacheMu.Unlock()
+
+	stats := make(map[string]CacheStats, len(caches))
+	for db, c := range caches {
+		stats[db] = c.Stats()
+	}
+	return stats
+}
+
+// ParseDatabaseBudgets parses per-database cache sizes given as
+// "database=maxSize" entries, as in NORNICDB_QUERY_CACHE_DATABASES.
+func ParseDatabaseBudgets(entries []string) (map[string]int, error) {
+	out := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		db, size, ok := strings.Cut(entry, "=")
+		db = strings.TrimSpace(db)
+		if !ok || db == "" {
+			return nil, fmt.Errorf("invalid query cache budget %q: expected database=maxSize", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid query cache budget %q: size must be a positive integer", entry)
+		}
+		out[db] = n
+	}
+	return out, nil
+}
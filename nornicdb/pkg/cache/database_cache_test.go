@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDatabaseQueryCache(t *testing.T) {
+	before := GlobalQueryCache().Stats()
+	defer ConfigureGlobalCache(before.MaxSize, before.TTL)
+	defer DropDatabaseCache("tenant_a")
+	defer DropDatabaseCache("tenant_b")
+	ConfigureGlobalCache(100, time.Minute)
+
+	t.Run("default database uses the global cache", func(t *testing.T) {
+		if DatabaseQueryCache("") != GlobalQueryCache() || DatabaseQueryCache(DefaultDatabase) != GlobalQueryCache() {
+			t.Error("default database should use GlobalQueryCache")
+		}
+	})
+
+	t.Run("plans do not cross databases", func(t *testing.T) {
+		a, b := DatabaseQueryCache("tenant_a"), DatabaseQueryCache("tenant_b")
+		if a == b || a == GlobalQueryCache() {
+			t.Fatal("each database should have its own cache")
+		}
+		if DatabaseQueryCache("tenant_a") != a {
+			t.Error("a database should keep its cache")
+		}
+
+		key := a.Key("MATCH (n) RETURN n", nil)
+		a.Put(key, "plan-a")
+		if _, ok := b.Get(key); ok {
+			t.Error("tenant_b should not see tenant_a's plan")
+		}
+		if v, ok := a.Get(key); !ok || v != "plan-a" {
+			t.Errorf("Get = %v, %v, want plan-a", v, ok)
+		}
+
+		if s := a.Stats(); s.Hits != 1 || s.Misses != 0 {
+			t.Errorf("tenant_a hits/misses = %d/%d, want 1/0", s.Hits, s.Misses)
+		}
+		if s := b.Stats(); s.Hits != 0 || s.Misses != 1 {
+			t.Errorf("tenant_b hits/misses = %d/%d, want 0/1", s.Hits, s.Misses)
+		}
+	})
+
+	t.Run("budgets", func(t *testing.T) {
+		ConfigureDatabaseCache("tenant_a", 3, time.Minute)
+		a := DatabaseQueryCache("tenant_a")
+		for i := 0; i < 5; i++ {
+			a.Put(uint64(i), i)
+		}
+		if a.Len() != 3 {
+			t.Errorf("tenant_a Len = %d, want 3", a.Len())
+		}
+
+		ConfigureGlobalCache(50, time.Minute)
+		if got := DatabaseQueryCache("tenant_b").Stats().MaxSize; got != 50 {
+			t.Errorf("tenant_b MaxSize = %d, want 50 (follows the global cache)", got)
+		}
+		if got := a.Stats().MaxSize; got != 3 {
+			t.Errorf("tenant_a MaxSize = %d, want its own budget 3", got)
+		}
+
+		stats := DatabaseCacheStats()
+		if stats["tenant_a"].MaxSize != 3 || stats["tenant_b"].MaxSize != 50 || stats[DefaultDatabase].MaxSize != 50 {
+			t.Errorf("DatabaseCacheStats = %+v", stats)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		a := DatabaseQueryCache("tenant_a")
+		DropDatabaseCache("tenant_a")
+		if _, ok := DatabaseCacheStats()["tenant_a"]; ok {
+			t.Error("dropped database should have no cache")
+		}
+		if fresh := DatabaseQueryCache("tenant_a"); fresh == a || fresh.Stats().MaxSize != 50 {
+			t.Error("a dropped database should start over with the default size")
+		}
+	})
+}
+
+func TestParseDatabaseBudgets(t *testing.T) {
+	budgets, err := ParseDatabaseBudgets([]string{"tenant_a=5000", " tenant_b = 200 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(budgets) != 2 || budgets["tenant_a"] != 5000 || budgets["tenant_b"] != 200 {
+		t.Errorf("budgets = %v", budgets)
+	}
+
+	for _, entry := range []string{"tenant_a", "=10", "tenant_a=0", "tenant_a=lots"} {
+		if _, err := ParseDatabaseBudgets([]string{entry}); err == nil {
+			t.Errorf("%q: expected an error", entry)
+		}
+	}
+}
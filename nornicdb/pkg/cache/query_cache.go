@@ -8,6 +8,7 @@
 // - TTL expiration for stale plans
 // - Thread-safe operations
 // - Cache hit/miss statistics
+// - Separate caches per database, with their own size budgets
 //
 // Usage:
 //
@@ -289,6 +290,7 @@ func (c *QueryCache) Get(key uint64) (interface{}, bool) {
 
 	c.mu.RLock()
 	elem, ok := c.items[key]
+	ttl := c.ttl
 	c.mu.RUnlock()
 
 	if !ok {
@@ -299,7 +301,7 @@ func (c *QueryCache) Get(key uint64) (interface{}, bool) {
 	entry := elem.Value.(*cacheEntry)
 
 	// Check TTL
-	if c.ttl > 0 && time.Now().After(entry.expiresAt) {
+	if ttl > 0 && time.Now().After(entry.expiresAt) {
 		// Expired - remove and return miss
 		c.mu.Lock()
 		c.removeElement(elem)
@@ -667,7 +669,7 @@ func (c *QueryCache) Stats() CacheStats {
 	misses := atomic.LoadUint64(&c.misses)
 
 	c.mu.RLock()
-	size := c.list.Len()
+	size, maxSize, ttl := c.list.Len(), c.maxSize, c.ttl
 	c.mu.RUnlock()
 
 	total := hits + misses
@@ -678,7 +680,8 @@ func (c *QueryCache) Stats() CacheStats {
 
 	return CacheStats{
 		Size:    size,
-		MaxSize: c.maxSize,
+		MaxSize: maxSize,
+		TTL:     ttl,
 		Hits:    hits,
 		Misses:  misses,
 		HitRate: hitRate,
@@ -692,7 +695,8 @@ func (c *QueryCache) Stats() CacheStats {
 //
 // Fields:
 //   - Size: Current number of entries in the cache
-//   - MaxSize: Maximum capacity (from NewQueryCache)
+//   - MaxSize: Maximum capacity (from NewQueryCache or Resize)
+//   - TTL: Time-to-live for new entries
 //   - Hits: Total number of successful cache lookups
 //   - Misses: Total number of cache misses (parse required)
 //   - HitRate: Percentage of lookups that were hits (0-100)
@@ -752,10 +756,11 @@ func (c *QueryCache) Stats() CacheStats {
 // Higher hit rate = better cache = faster queries!
 type CacheStats struct {
 	Size    int     // Current number of entries
-	MaxSize int     // Maximum capacity
-	Hits    uint64  // Number of cache hits
-	Misses  uint64  // Number of cache misses
-	HitRate float64 // Hit rate percentage (0-100)
+	MaxSize int           // Maximum capacity
+	TTL     time.Duration // Time-to-live for new entries (0 = no expiration)
+	Hits    uint64        // Number of cache hits
+	Misses  uint64        // Number of cache misses
+	HitRate float64       // Hit rate percentage (0-100)
 }
 
 // SetEnabled enables or disables the cache.
@@ -829,6 +834,36 @@ func (c *QueryCache) SetEnabled(enabled bool) {
 	}
 }
 
+// Resize changes the capacity and TTL of a live cache.
+//
+// Shrinking evicts the least recently used entries until the cache fits, so
+// the hottest plans survive. A new TTL applies to entries stored or updated
+// afterwards; existing entries keep their expiry.
+//
+// Parameters:
+//   - maxSize: New maximum number of cached plans (<= 0 keeps the current size)
+//   - ttl: New time-to-live (0 = no expiration)
+//
+// Example:
+//
+//	// Shrink under memory pressure without restarting
+//	cache.GlobalQueryCache().Resize(200, time.Minute)
+//
+// Thread Safety:
+//   - Safe to call concurrently with Get and Put
+//   - Exclusive lock held while evicting
+func (c *QueryCache) Resize(maxSize int, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if maxSize > 0 {
+		c.maxSize = maxSize
+	}
+	c.ttl = ttl
+	for c.list.Len() > c.maxSize {
+		c.evictOldest()
+	}
+}
+
 // evictOldest removes the least recently used entry.
 // Caller must hold the lock.
 func (c *QueryCache) evictOldest() {
@@ -851,15 +886,15 @@ func (c *QueryCache) removeElement(elem *list.Element) {
 // =============================================================================
 
 var (
-	globalQueryCache     *QueryCache
-	globalQueryCacheOnce sync.Once
+	globalQueryCache   *QueryCache
+	globalQueryCacheMu sync.Mutex
 )
 
 // GlobalQueryCache returns the global query cache instance.
 //
 // The global cache is a singleton that's lazily initialized with default
 // settings (1000 entries, 5-minute TTL). Use ConfigureGlobalCache to
-// customize the cache before first use.
+// customize the cache, before or after first use.
 //
 // Returns:
 //   - Shared QueryCache instance
@@ -914,6 +949,7 @@ var (
 // Thread Safety:
 //   - Singleton initialization is thread-safe
 //   - All cache operations are thread-safe
+//   - Reconfiguring resizes the same instance, so callers may keep it
 //
 // ELI12:
 //
@@ -923,16 +959,27 @@ var (
 //   - Everyone can read and write at the same time (thread-safe)
 //   - No need to pass the notebook around - just call GlobalQueryCache()!
 func GlobalQueryCache() *QueryCache {
-	globalQueryCacheOnce.Do(func() {
+	globalQueryCacheMu.Lock()
+	defer globalQueryCacheMu.Unlock()
+	return globalQueryCacheLocked()
+}
+
+// globalQueryCacheLocked returns the global cache, creating it if needed.
+// Caller must hold globalQueryCacheMu.
+func globalQueryCacheLocked() *QueryCache {
+	if globalQueryCache == nil {
 		globalQueryCache = NewQueryCache(1000, 5*time.Minute)
-	})
+	}
 	return globalQueryCache
 }
 
 // ConfigureGlobalCache configures the global query cache.
 //
-// This function must be called before the first use of GlobalQueryCache()
-// to customize the cache settings. Subsequent calls are no-ops (first call wins).
+// The first call (or first use of GlobalQueryCache()) creates the cache.
+// Later calls resize the existing cache in place with Resize, keeping its
+// most recently used plans, so settings can change at runtime without a
+// restart. Databases without their own budget (see ConfigureDatabaseCache)
+// are resized the same way.
 //
 // Parameters:
 //   - maxSize: Maximum number of cached plans (LRU eviction when exceeded)
@@ -977,25 +1024,36 @@ func GlobalQueryCache() *QueryCache {
 //		os.Exit(m.Run())
 //	}
 //
+// Example 5 - Runtime Change:
+//
+//	// CALL dbms.setConfig('nornicdb.memory.query_cache.size', '200')
+//	cache.ConfigureGlobalCache(200, cache.GlobalQueryCache().Stats().TTL)
+//
 // Timing:
-//   - Call in init() or early in main()
-//   - Before any query execution
-//   - Before starting HTTP server
+//   - Call in init() or early in main() for the initial settings
+//   - Call again at any time to resize
 //
 // Thread Safety:
-//   - First call wins (sync.Once)
-//   - Subsequent calls are ignored
 //   - Safe to call from multiple goroutines
+//   - Concurrent queries keep using the cache while it is resized
 //
 // ELI12:
 //
 // ConfigureGlobalCache is like setting up the classroom before students arrive:
 //   - You decide how big the shared notebook should be (maxSize)
 //   - You decide how long notes stay valid (ttl)
-//   - Once students arrive, you can't change the notebook (first call wins)
-//   - Do this in init() or main() before anyone uses the cache!
+//   - Later you can swap in a thinner notebook, keeping the most-read pages
 func ConfigureGlobalCache(maxSize int, ttl time.Duration) {
-	globalQueryCacheOnce.Do(func() {
+	globalQueryCacheMu.Lock()
+	defer globalQueryCacheMu.Unlock()
+	if globalQueryCache == nil {
 		globalQueryCache = NewQueryCache(maxSize, ttl)
-	})
+	} else {
+		globalQueryCache.Resize(maxSize, ttl)
+	}
+	for db, c := range databaseCaches {
+		if _, ok := databaseBudgets[db]; !ok {
+			c.Resize(maxSize, ttl)
+		}
+	}
 }
@@ -478,7 +478,6 @@ func TestQueryCache_ConcurrentEviction(t *testing.T) {
 // =============================================================================
 
 func TestGlobalQueryCache(t *testing.T) {
-	// Note: GlobalQueryCache uses sync.Once, so we can only test basic functionality
 	cache := GlobalQueryCache()
 
 	if cache == nil {
@@ -492,6 +491,97 @@ func TestGlobalQueryCache(t *testing.T) {
 	}
 }
 
+func TestConfigureGlobalCache_Reconfigures(t *testing.T) {
+	cache := GlobalQueryCache()
+	before := cache.Stats()
+	defer ConfigureGlobalCache(before.MaxSize, before.TTL)
+
+	ConfigureGlobalCache(42, time.Minute)
+	if GlobalQueryCache() != cache {
+		t.Fatal("reconfiguring should keep the same instance")
+	}
+	stats := cache.Stats()
+	if stats.MaxSize != 42 || stats.TTL != time.Minute {
+		t.Errorf("MaxSize = %d, TTL = %v, want 42, 1m", stats.MaxSize, stats.TTL)
+	}
+}
+
+// =============================================================================
+// Resize Tests
+// =============================================================================
+
+func TestQueryCache_Resize(t *testing.T) {
+	t.Run("shrink keeps most recently used", func(t *testing.T) {
+		cache := NewQueryCache(5, 0)
+		for i := uint64(1); i <= 5; i++ {
+			cache.Put(i, i)
+		}
+		cache.Get(1) // 1 becomes the hottest
+
+		cache.Resize(2, 0)
+		if cache.Len() != 2 {
+			t.Fatalf("Len = %d, want 2", cache.Len())
+		}
+		for _, key := range []uint64{1, 5} {
+			if _, ok := cache.Get(key); !ok {
+				t.Errorf("key %d should survive the resize", key)
+			}
+		}
+
+		cache.Put(6, 6)
+		if cache.Len() != 2 {
+			t.Errorf("Len = %d, new size should bound later puts", cache.Len())
+		}
+	})
+
+	t.Run("grow keeps entries", func(t *testing.T) {
+		cache := NewQueryCache(2, 0)
+		cache.Put(1, 1)
+		cache.Put(2, 2)
+		cache.Resize(4, 0)
+		cache.Put(3, 3)
+		cache.Put(4, 4)
+		if cache.Len() != 4 {
+			t.Errorf("Len = %d, want 4", cache.Len())
+		}
+	})
+
+	t.Run("ttl and invalid size", func(t *testing.T) {
+		cache := NewQueryCache(3, 0)
+		cache.Resize(0, 50*time.Millisecond)
+		if stats := cache.Stats(); stats.MaxSize != 3 || stats.TTL != 50*time.Millisecond {
+			t.Errorf("MaxSize = %d, TTL = %v", stats.MaxSize, stats.TTL)
+		}
+		cache.Put(1, 1)
+		time.Sleep(60 * time.Millisecond)
+		if _, ok := cache.Get(1); ok {
+			t.Error("entry stored after the resize should expire")
+		}
+	})
+
+	t.Run("concurrent with reads and writes", func(t *testing.T) {
+		cache := NewQueryCache(100, time.Minute)
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					cache.Put(uint64(g*1000+i), i)
+					cache.Get(uint64(g*1000 + i/2))
+				}
+			}(g)
+		}
+		for size := 10; size <= 100; size += 10 {
+			cache.Resize(size, time.Minute)
+		}
+		wg.Wait()
+		if cache.Len() > 100 {
+			t.Errorf("Len = %d, exceeds maxSize", cache.Len())
+		}
+	})
+}
+
 // =============================================================================
 // Benchmarks
 // =============================================================================
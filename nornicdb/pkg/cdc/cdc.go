@@ -0,0 +1,257 @@
+// Package cdc delivers the changes recorded in the write-ahead log (change
+// data capture) to named consumer groups.
+//
+// Each downstream system (a search index, a warehouse loader, a cache
+// invalidator) reads the change stream through its own consumer group and
+// at its own pace. Within a group, consumers fetch batches of changes and
+// acknowledge them once processed; the group's offset is the WAL sequence
+// up to which every batch was acknowledged, and it is persisted in the
+// graph as a :_CDCGroup node, so a group resumes where it stopped after a
+// restart.
+//
+// Delivery is at least once. A batch not acknowledged within the ack
+// timeout, or rejected with Nack, is delivered again, possibly to another
+// consumer of the group. Consumers must therefore handle a change more
+// than once. A group holds at most MaxInFlight unacknowledged batches:
+// once it reaches the limit, Fetch returns ErrBackpressure until the
+// consumers catch up, so a slow group never accumulates unbounded work.
+// The WAL keeps the entries every group still needs, even when a snapshot
+// would otherwise compact them away.
+//
+// Properties are masked with the redactor set by SetRedactor before a batch
+// is built, so sensitive values never reach a consumer.
+//
+// Example:
+//
+//	m, _ := cdc.NewManager(wal, cdc.NewGraphOffsetStore(engine), cdc.Config{})
+//	batch, err := m.Fetch("search-indexer", "worker-1", 100)
+//	for _, ev := range batch.Events {
+//		index(ev)
+//	}
+//	m.Ack("search-indexer", batch.ID)
+package cdc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/idempotency"
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// Change event types, named like the Heimdall database events.
+const (
+	NodeCreated         = "node.created"
+	NodeUpdated         = "node.updated"
+	NodeDeleted         = "node.deleted"
+	RelationshipCreated = "relationship.created"
+	RelationshipUpdated = "relationship.updated"
+	RelationshipDeleted = "relationship.deleted"
+)
+
+// Event is one change of a node or relationship.
+type Event struct {
+	Offset        uint64         `json:"offset"` // WAL sequence of the change
+	Time          time.Time      `json:"time"`
+	Type          string         `json:"type"`
+	ID            string         `json:"id"`
+	Labels        []string       `json:"labels,omitempty"`     // Nodes
+	RelType       string         `json:"rel_type,omitempty"`   // Relationships
+	StartNode     string         `json:"start_node,omitempty"` // Relationships
+	EndNode       string         `json:"end_node,omitempty"`   // Relationships
+	Properties    map[string]any `json:"properties,omitempty"`
+	OldProperties map[string]any `json:"old_properties,omitempty"` // Updates and deletes logged with their before image
+	TxID          string         `json:"tx_id,omitempty"`
+}
+
+// Events converts WAL entries to change events. Embedding updates,
+// transaction markers, changes of transactions rolled back within entries
+// and changes of internal nodes (CDC offsets, recorded idempotency keys)
+// are left out.
+func Events(entries []storage.WALEntry) ([]Event, error) {
+	aborted := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Operation == storage.OpTxAbort {
+			var tx storage.WALTxData
+			if err := json.Unmarshal(entry.Data, &tx); err == nil {
+				aborted[tx.TxID] = true
+			}
+		}
+	}
+
+	var events []Event
+	for _, entry := range entries {
+		converted, err := entryEvents(entry)
+		if err != nil {
+			return nil, fmt.Errorf("cdc: WAL entry %d (%s): %w", entry.Sequence, entry.Operation, err)
+		}
+		for _, ev := range converted {
+			if (ev.TxID != "" && aborted[ev.TxID]) || isInternalNode(ev) {
+				continue
+			}
+			ev.Offset, ev.Time = entry.Sequence, entry.Timestamp
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// entryEvents converts one WAL entry.
+func entryEvents(entry storage.WALEntry) ([]Event, error) {
+	switch entry.Operation {
+	case storage.OpCreateNode, storage.OpUpdateNode:
+		var data storage.WALNodeData
+		if err := json.Unmarshal(entry.Data, &data); err != nil || data.Node == nil {
+			return nil, decodeError(err)
+		}
+		ev := nodeEvent(NodeCreated, data.Node, data.TxID)
+		if entry.Operation == storage.OpUpdateNode {
+			ev.Type = NodeUpdated
+			if data.OldNode != nil {
+				ev.OldProperties = data.OldNode.Properties
+			}
+		}
+		return []Event{ev}, nil
+
+	case storage.OpCreateEdge, storage.OpUpdateEdge:
+		var data storage.WALEdgeData
+		if err := json.Unmarshal(entry.Data, &data); err != nil || data.Edge == nil {
+			return nil, decodeError(err)
+		}
+		ev := edgeEvent(RelationshipCreated, data.Edge, data.TxID)
+		if entry.Operation == storage.OpUpdateEdge {
+			ev.Type = RelationshipUpdated
+			if data.OldEdge != nil {
+				ev.OldProperties = data.OldEdge.Properties
+			}
+		}
+		return []Event{ev}, nil
+
+	case storage.OpDeleteNode, storage.OpDeleteEdge:
+		var data storage.WALDeleteData
+		if err := json.Unmarshal(entry.Data, &data); err != nil {
+			return nil, decodeError(err)
+		}
+		if entry.Operation == storage.OpDeleteNode {
+			ev := Event{Type: NodeDeleted, ID: data.ID, TxID: data.TxID}
+			if data.OldNode != nil {
+				ev.Labels, ev.OldProperties = data.OldNode.Labels, data.OldNode.Properties
+			}
+			return []Event{ev}, nil
+		}
+		ev := Event{Type: RelationshipDeleted, ID: data.ID, TxID: data.TxID}
+		if data.OldEdge != nil {
+			ev.RelType, ev.StartNode, ev.EndNode = data.OldEdge.Type, string(data.OldEdge.StartNode), string(data.OldEdge.EndNode)
+			ev.OldProperties = data.OldEdge.Properties
+		}
+		return []Event{ev}, nil
+
+	case storage.OpBulkNodes:
+		var data storage.WALBulkNodesData
+		if err := json.Unmarshal(entry.Data, &data); err != nil {
+			return nil, decodeError(err)
+		}
+		events := make([]Event, 0, len(data.Nodes))
+		for _, node := range data.Nodes {
+			events = append(events, nodeEvent(NodeCreated, node, data.TxID))
+		}
+		return events, nil
+
+	case storage.OpBulkEdges:
+		var data storage.WALBulkEdgesData
+		if err := json.Unmarshal(entry.Data, &data); err != nil {
+			return nil, decodeError(err)
+		}
+		events := make([]Event, 0, len(data.Edges))
+		for _, edge := range data.Edges {
+			events = append(events, edgeEvent(RelationshipCreated, edge, data.TxID))
+		}
+		return events, nil
+
+	case storage.OpBulkDeleteNodes:
+		var data storage.WALBulkDeleteNodesData
+		if err := json.Unmarshal(entry.Data, &data); err != nil {
+			return nil, decodeError(err)
+		}
+		old := make(map[string]*storage.Node, len(data.OldNodes))
+		for _, n := range data.OldNodes {
+			old[string(n.ID)] = n
+		}
+		events := make([]Event, 0, len(data.IDs))
+		for _, id := range data.IDs {
+			ev := Event{Type: NodeDeleted, ID: id, TxID: data.TxID}
+			if n := old[id]; n != nil {
+				ev.Labels, ev.OldProperties = n.Labels, n.Properties
+			}
+			events = append(events, ev)
+		}
+		return events, nil
+
+	case storage.OpBulkDeleteEdges:
+		var data storage.WALBulkDeleteEdgesData
+		if err := json.Unmarshal(entry.Data, &data); err != nil {
+			return nil, decodeError(err)
+		}
+		old := make(map[string]*storage.Edge, len(data.OldEdges))
+		for _, e := range data.OldEdges {
+			old[string(e.ID)] = e
+		}
+		events := make([]Event, 0, len(data.IDs))
+		for _, id := range data.IDs {
+			ev := Event{Type: RelationshipDeleted, ID: id, TxID: data.TxID}
+			if e := old[id]; e != nil {
+				ev.RelType, ev.StartNode, ev.EndNode = e.Type, string(e.StartNode), string(e.EndNode)
+				ev.OldProperties = e.Properties
+			}
+			events = append(events, ev)
+		}
+		return events, nil
+	}
+	// Embedding updates, checkpoints and transaction markers
+	return nil, nil
+}
+
+// redactEvents masks the properties of events in place.
+func redactEvents(events []Event, r *redact.Redactor) {
+	if !r.Enabled() {
+		return
+	}
+	for i := range events {
+		events[i].Properties = r.Map(events[i].Properties)
+		events[i].OldProperties = r.Map(events[i].OldProperties)
+	}
+}
+
+func nodeEvent(typ string, node *storage.Node, txID string) Event {
+	return Event{Type: typ, ID: string(node.ID), Labels: node.Labels, Properties: node.Properties, TxID: txID}
+}
+
+func edgeEvent(typ string, edge *storage.Edge, txID string) Event {
+	return Event{
+		Type:       typ,
+		ID:         string(edge.ID),
+		RelType:    edge.Type,
+		StartNode:  string(edge.StartNode),
+		EndNode:    string(edge.EndNode),
+		Properties: edge.Properties,
+		TxID:       txID,
+	}
+}
+
+func decodeError(err error) error {
+	if err == nil {
+		return fmt.Errorf("missing element")
+	}
+	return err
+}
+
+// isInternalNode reports whether ev is a change of a group's offset node,
+// which would otherwise feed every ack back into the stream, or of a
+// recorded idempotency key.
+func isInternalNode(ev Event) bool {
+	return (ev.Type == NodeCreated || ev.Type == NodeUpdated || ev.Type == NodeDeleted) &&
+		(strings.HasPrefix(ev.ID, offsetNodePrefix) || strings.HasPrefix(ev.ID, idempotency.NodePrefix))
+}
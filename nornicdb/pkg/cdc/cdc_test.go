@@ -0,0 +1,310 @@
+package cdc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/config"
+	"github.com/orneryd/nornicdb/pkg/idempotency"
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixture struct {
+	wal    *storage.WAL
+	engine storage.Engine
+	store  *GraphOffsetStore
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	t.Cleanup(config.WithWALEnabled())
+	wal, err := storage.NewWAL("", &storage.WALConfig{Dir: t.TempDir(), SyncMode: "none"})
+	require.NoError(t, err)
+	t.Cleanup(func() { wal.Close() })
+	engine := storage.NewWALEngine(storage.NewMemoryEngine(), wal)
+	return &fixture{wal: wal, engine: engine, store: NewGraphOffsetStore(engine)}
+}
+
+func (f *fixture) manager(t *testing.T, cfg Config) (*Manager, *time.Time) {
+	t.Helper()
+	m, err := NewManager(f.wal, f.store, cfg)
+	require.NoError(t, err)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func (f *fixture) createNodes(t *testing.T, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		require.NoError(t, f.engine.CreateNode(&storage.Node{ID: storage.NodeID(id), Labels: []string{"Doc"}, Properties: map[string]any{"name": id}}))
+	}
+}
+
+func eventIDs(b *Batch) []string {
+	var ids []string
+	for _, ev := range b.Events {
+		ids = append(ids, ev.ID)
+	}
+	return ids
+}
+
+func TestEvents(t *testing.T) {
+	f := newFixture(t)
+	f.createNodes(t, "a", "b")
+	require.NoError(t, f.engine.CreateEdge(&storage.Edge{ID: "e", StartNode: "a", EndNode: "b", Type: "LINKS"}))
+	node, err := f.engine.GetNode("a")
+	require.NoError(t, err)
+	node.Properties = map[string]any{"name": "renamed"}
+	require.NoError(t, f.engine.UpdateNode(node))
+	require.NoError(t, f.wal.Append(storage.OpDeleteEdge, storage.WALDeleteData{ID: "e", OldEdge: &storage.Edge{ID: "e", StartNode: "a", EndNode: "b", Type: "LINKS"}}))
+	require.NoError(t, f.wal.Append(storage.OpCreateNode, storage.WALNodeData{Node: &storage.Node{ID: "rolled-back"}, TxID: "tx1"}))
+	require.NoError(t, f.wal.Append(storage.OpTxAbort, storage.WALTxData{TxID: "tx1"}))
+	require.NoError(t, f.store.SaveOffset("g", 1))
+	require.NoError(t, idempotency.NewGraphBackend(f.engine).SaveRecord("k", idempotency.Record{Expires: time.Now().Add(time.Minute)}))
+
+	entries, err := f.wal.EntriesAfter(0, 0)
+	require.NoError(t, err)
+	events, err := Events(entries)
+	require.NoError(t, err)
+
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev.Type+" "+ev.ID)
+	}
+	assert.Equal(t, []string{
+		"node.created a", "node.created b", "relationship.created e", "node.updated a", "relationship.deleted e",
+	}, types)
+	assert.Equal(t, "renamed", events[3].Properties["name"])
+	assert.Equal(t, "LINKS", events[4].RelType)
+	assert.Equal(t, "a", events[4].StartNode)
+	for i := 1; i < len(events); i++ {
+		assert.Greater(t, events[i].Offset, events[i-1].Offset)
+	}
+}
+
+func TestManager_AckAndRedelivery(t *testing.T) {
+	f := newFixture(t)
+	f.createNodes(t, "before")
+	m, now := f.manager(t, Config{MaxBatch: 2, MaxInFlight: 2, AckTimeout: time.Minute})
+
+	batch, err := m.Fetch("indexer", "w1", 0)
+	require.NoError(t, err)
+	assert.Nil(t, batch, "a new group starts at the head")
+
+	f.createNodes(t, "a", "b", "c", "d", "e")
+	first, err := m.Fetch("indexer", "w1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, eventIDs(first))
+	second, err := m.Fetch("indexer", "w2", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, eventIDs(second))
+
+	_, err = m.Fetch("indexer", "w1", 0)
+	assert.ErrorIs(t, err, ErrBackpressure)
+
+	// Acking out of order does not move the offset past the first batch
+	require.NoError(t, m.Ack("indexer", second.ID))
+	assert.ErrorIs(t, m.Ack("indexer", second.ID), ErrUnknownBatch)
+	stats := m.Stats()[0]
+	assert.Equal(t, first.From-1, stats.Acked)
+	assert.Equal(t, 1, stats.InFlight)
+
+	// w1 stalls; the batch goes to w2 after the ack timeout
+	*now = now.Add(2 * time.Minute)
+	redelivered, err := m.Fetch("indexer", "w2", 0)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, redelivered.ID)
+	assert.Equal(t, 2, redelivered.Attempt)
+	assert.Equal(t, "w2", redelivered.Consumer)
+
+	require.NoError(t, m.Ack("indexer", first.ID))
+	stats = m.Stats()[0]
+	assert.Equal(t, second.To, stats.Acked)
+	assert.Equal(t, int64(1), stats.Redeliveries)
+
+	third, err := m.Fetch("indexer", "w1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e"}, eventIDs(third))
+	require.NoError(t, m.Nack("indexer", third.ID))
+	again, err := m.Fetch("indexer", "w1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, third.ID, again.ID, "nacked batches are redelivered at once")
+	require.NoError(t, m.Ack("indexer", third.ID))
+
+	caughtUp, err := m.Fetch("indexer", "w1", 0)
+	require.NoError(t, err)
+	assert.Nil(t, caughtUp, "offset writes are not delivered")
+	assert.Zero(t, m.Stats()[0].Lag)
+
+	assert.ErrorIs(t, m.Ack("other", "1-2"), ErrUnknownGroup)
+	_, err = m.Fetch("", "w1", 0)
+	assert.ErrorIs(t, err, ErrInvalidGroup)
+}
+
+func TestManager_Redaction(t *testing.T) {
+	f := newFixture(t)
+	m, _ := f.manager(t, Config{})
+	redactor, err := redact.New(redact.Config{Enabled: true, Properties: []string{"ssn"}, Patterns: []string{`\d{3}-\d{2}-\d{4}`}})
+	require.NoError(t, err)
+	m.SetRedactor(redactor)
+	_, err = m.Fetch("warehouse", "w1", 0)
+	require.NoError(t, err)
+
+	require.NoError(t, f.engine.CreateNode(&storage.Node{ID: "p", Labels: []string{"Person"}, Properties: map[string]any{
+		"name": "Ann", "ssn": "123-45-6789", "note": "old ssn 111-22-3333",
+	}}))
+	old, err := f.engine.GetNode("p")
+	require.NoError(t, err)
+	require.NoError(t, f.wal.Append(storage.OpUpdateNode, storage.WALNodeData{
+		Node:    &storage.Node{ID: "p", Labels: []string{"Person"}, Properties: map[string]any{"name": "Ann", "ssn": "987-65-4321"}},
+		OldNode: old,
+	}))
+	require.NoError(t, f.engine.DeleteNode("p"))
+
+	batch, err := m.Fetch("warehouse", "w1", 0)
+	require.NoError(t, err)
+	require.Len(t, batch.Events, 3)
+	assert.Equal(t, "Ann", batch.Events[0].Properties["name"])
+	assert.Equal(t, redact.DefaultMask, batch.Events[0].Properties["ssn"])
+	assert.Equal(t, "old ssn "+redact.DefaultMask, batch.Events[0].Properties["note"])
+	require.NotNil(t, batch.Events[1].OldProperties)
+	assert.Equal(t, redact.DefaultMask, batch.Events[1].OldProperties["ssn"])
+
+	// Neither the delivered batch nor a redelivery of the stored one carries
+	// the values
+	require.NoError(t, m.Nack("warehouse", batch.ID))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, BasePath+"fetch", strings.NewReader(`{"group": "warehouse", "consumer": "w2"}`))
+	Handler(m).ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	for _, delivered := range []string{fmt.Sprint(batch.Events), w.Body.String()} {
+		for _, secret := range []string{"123-45-6789", "987-65-4321", "111-22-3333"} {
+			assert.NotContains(t, delivered, secret)
+		}
+	}
+}
+
+func TestManager_ResumesAfterRestart(t *testing.T) {
+	f := newFixture(t)
+	m, _ := f.manager(t, Config{MaxBatch: 1})
+	_, err := m.Fetch("loader", "w1", 0)
+	require.NoError(t, err)
+	f.createNodes(t, "a", "b")
+
+	acked, err := m.Fetch("loader", "w1", 0)
+	require.NoError(t, err)
+	require.NoError(t, m.Ack("loader", acked.ID))
+	_, err = m.Fetch("loader", "w1", 0)
+	require.NoError(t, err) // b: delivered, never acknowledged
+
+	restarted, _ := f.manager(t, Config{MaxBatch: 1})
+	batch, err := restarted.Fetch("loader", "w1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, eventIDs(batch), "unacknowledged changes are delivered again")
+
+	require.NoError(t, restarted.DeleteGroup("loader"))
+	assert.Empty(t, restarted.Stats())
+	offsets, err := f.store.LoadOffsets()
+	require.NoError(t, err)
+	assert.Empty(t, offsets)
+}
+
+func TestManager_Retention(t *testing.T) {
+	f := newFixture(t)
+	m, _ := f.manager(t, Config{})
+	assert.Equal(t, uint64(1<<64-1), m.retained.Load(), "no groups, nothing retained")
+
+	_, err := m.Fetch("slow", "w1", 0)
+	require.NoError(t, err)
+	f.createNodes(t, "a", "b")
+
+	// A snapshot covering every entry must not drop the unread ones
+	require.NoError(t, f.wal.TruncateAfterSnapshot(f.wal.Sequence()))
+	batch, err := m.Fetch("slow", "w1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, eventIDs(batch))
+	require.NoError(t, m.Ack("slow", batch.ID))
+
+	require.NoError(t, f.wal.TruncateAfterSnapshot(f.wal.Sequence()))
+	entries, err := f.wal.EntriesAfter(0, 0)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.Greater(t, entry.Sequence, batch.To, "acknowledged entries are truncated")
+	}
+}
+
+func TestStats_ConsumerLag(t *testing.T) {
+	f := newFixture(t)
+	m, _ := f.manager(t, Config{MaxBatch: 1})
+	_, err := m.Fetch("g", "w1", 0)
+	require.NoError(t, err)
+	f.createNodes(t, "a", "b", "c")
+	_, err = m.Fetch("g", "w1", 0)
+	require.NoError(t, err)
+	_, err = m.Fetch("g", "w2", 0)
+	require.NoError(t, err)
+
+	stats := m.Stats()[0]
+	assert.Equal(t, uint64(3), stats.Lag)
+	require.Len(t, stats.Consumers, 2)
+	assert.Equal(t, "w1", stats.Consumers[0].Name)
+	assert.Equal(t, uint64(3), stats.Consumers[0].Lag)
+	assert.Equal(t, uint64(2), stats.Consumers[1].Lag)
+	assert.Equal(t, 1, stats.Consumers[1].InFlight)
+}
+
+func TestHandler(t *testing.T) {
+	f := newFixture(t)
+	m, _ := f.manager(t, Config{MaxInFlight: 1})
+	srv := httptest.NewServer(Handler(m))
+	defer srv.Close()
+
+	post := func(endpoint, body string) *http.Response {
+		resp, err := http.Post(srv.URL+BasePath+endpoint, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	assert.Equal(t, http.StatusNoContent, post("fetch", `{"group": "g", "consumer": "w1"}`).StatusCode)
+	f.createNodes(t, "a", "b")
+	assert.Equal(t, http.StatusOK, post("fetch", `{"group": "g", "consumer": "w1"}`).StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, post("fetch", `{"group": "g", "consumer": "w1"}`).StatusCode)
+
+	batchID := m.Stats()[0]
+	require.Equal(t, 1, batchID.InFlight)
+	id := fmt.Sprintf("%d-%d", batchID.Acked+1, batchID.Delivered)
+	assert.Equal(t, http.StatusNoContent, post("ack", `{"group": "g", "batch": "`+id+`"}`).StatusCode)
+	assert.Equal(t, http.StatusNotFound, post("ack", `{"group": "g", "batch": "`+id+`"}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, post("fetch", `{"group": ""}`).StatusCode)
+
+	resp, err := http.Get(srv.URL + BasePath + "groups")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+BasePath+"groups/g", nil)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+type failingStore struct{ *GraphOffsetStore }
+
+func (failingStore) LoadOffsets() (map[string]uint64, error) { return nil, errors.New("boom") }
+
+func TestNewManager_LoadError(t *testing.T) {
+	f := newFixture(t)
+	_, err := NewManager(f.wal, failingStore{}, Config{})
+	assert.ErrorContains(t, err, "boom")
+}
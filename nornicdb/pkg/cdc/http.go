@@ -0,0 +1,104 @@
+package cdc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// BasePath is where the HTTP server mounts Handler.
+const BasePath = "/nornicdb/cdc/"
+
+type fetchRequest struct {
+	Group    string `json:"group"`
+	Consumer string `json:"consumer"`
+	Limit    int    `json:"limit"`
+}
+
+type ackRequest struct {
+	Group string `json:"group"`
+	Batch string `json:"batch"`
+}
+
+// Handler serves a Manager over HTTP:
+//
+//	GET    .../groups         group and consumer stats
+//	DELETE .../groups/{name}  delete a group
+//	POST   .../fetch          {"group", "consumer", "limit"} → Batch, or 204 when caught up
+//	POST   .../ack            {"group", "batch"}
+//	POST   .../nack           {"group", "batch"}
+//
+// Fetch answers 429 under backpressure. Authentication is left to the
+// caller, e.g. the server's auth middleware.
+func Handler(m *Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		switch {
+		case strings.HasSuffix(path, "/groups") && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string]any{"groups": m.Stats()})
+		case strings.Contains(path, "/groups/") && r.Method == http.MethodDelete:
+			name := path[strings.LastIndex(path, "/groups/")+len("/groups/"):]
+			if err := m.DeleteGroup(name); err != nil {
+				writeError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(path, "/fetch") && r.Method == http.MethodPost:
+			var req fetchRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+				return
+			}
+			batch, err := m.Fetch(req.Group, req.Consumer, req.Limit)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			if batch == nil {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			writeJSON(w, http.StatusOK, batch)
+		case (strings.HasSuffix(path, "/ack") || strings.HasSuffix(path, "/nack")) && r.Method == http.MethodPost:
+			var req ackRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+				return
+			}
+			ack := m.Ack
+			if strings.HasSuffix(path, "/nack") {
+				ack = m.Nack
+			}
+			if err := ack(req.Group, req.Batch); err != nil {
+				writeError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("unknown CDC endpoint %s %s", r.Method, r.URL.Path)})
+		}
+	})
+}
+
+var errBadRequest = errors.New("invalid request body")
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrBackpressure):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, ErrUnknownGroup), errors.Is(err, ErrUnknownBatch):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvalidGroup), errors.Is(err, errBadRequest):
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,420 @@
+package cdc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// Errors returned by Manager.
+var (
+	ErrInvalidGroup = errors.New("cdc: group and consumer names must not be empty")
+	ErrUnknownGroup = errors.New("cdc: unknown consumer group")
+	ErrUnknownBatch = errors.New("cdc: unknown or already acknowledged batch")
+	// ErrBackpressure means the group has MaxInFlight unacknowledged
+	// batches; ack or nack one before fetching more.
+	ErrBackpressure = errors.New("cdc: too many unacknowledged batches")
+)
+
+// Source is the log changes are read from. *storage.WAL implements it.
+type Source interface {
+	// Sequence returns the sequence of the latest entry.
+	Sequence() uint64
+	// EntriesAfter returns up to limit entries after afterSeq, in order.
+	EntriesAfter(afterSeq uint64, limit int) ([]storage.WALEntry, error)
+	// SetRetention keeps the entries after the sequence fn returns.
+	SetRetention(fn func() uint64)
+}
+
+// Config configures a Manager. Zero fields take the defaults.
+type Config struct {
+	MaxBatch    int           // WAL entries per batch (default 500)
+	MaxInFlight int           // Unacknowledged batches per group (default 4)
+	AckTimeout  time.Duration // Redeliver batches not acknowledged within (default 30s)
+}
+
+// DefaultConfig returns the default configuration.
+func DefaultConfig() Config {
+	return Config{MaxBatch: 500, MaxInFlight: 4, AckTimeout: 30 * time.Second}
+}
+
+// Batch is a range of changes delivered to one consumer of a group.
+type Batch struct {
+	ID       string    `json:"id"`
+	Group    string    `json:"group"`
+	Consumer string    `json:"consumer"`
+	From     uint64    `json:"from"` // First WAL sequence covered
+	To       uint64    `json:"to"`   // Last WAL sequence covered
+	Events   []Event   `json:"events"`
+	Attempt  int       `json:"attempt"` // 1 on first delivery
+	Deadline time.Time `json:"deadline"`
+}
+
+// GroupStats describes a consumer group. Offsets and lag count WAL
+// entries, including entries without events such as embedding updates.
+type GroupStats struct {
+	Name         string          `json:"name"`
+	Acked        uint64          `json:"acked_offset"`
+	Delivered    uint64          `json:"delivered_offset"`
+	Head         uint64          `json:"head"`
+	Lag          uint64          `json:"lag"`
+	InFlight     int             `json:"in_flight"`
+	Redeliveries int64           `json:"redeliveries"`
+	Consumers    []ConsumerStats `json:"consumers"`
+}
+
+// ConsumerStats describes one consumer of a group.
+type ConsumerStats struct {
+	Name     string    `json:"name"`
+	Batches  int64     `json:"batches"` // Deliveries, including redeliveries
+	Events   int64     `json:"events"`
+	Acked    int64     `json:"acked"` // Acknowledged batches
+	InFlight int       `json:"in_flight"`
+	LastSeen time.Time `json:"last_seen"`
+	// Lag is the WAL entries between the consumer's oldest unacknowledged
+	// batch (or the group offset) and the head.
+	Lag uint64 `json:"lag"`
+}
+
+// Manager tracks consumer groups over a Source.
+type Manager struct {
+	source Source
+	store  OffsetStore
+	config Config
+	now    func() time.Time
+
+	mu       sync.Mutex
+	groups   map[string]*group
+	redactor *redact.Redactor
+
+	// retained is the lowest group offset, read by the WAL while it holds
+	// its own lock, hence atomic rather than under mu.
+	retained atomic.Uint64
+}
+
+type group struct {
+	name         string
+	acked        uint64 // Every entry up to here is acknowledged
+	next         uint64 // Every entry up to here was delivered
+	inFlight     map[string]*inFlightBatch
+	redeliveries int64
+	consumers    map[string]*ConsumerStats
+}
+
+type inFlightBatch struct {
+	batch Batch
+	due   bool // Nacked: redeliver on the next fetch
+}
+
+// NewManager loads the groups from store and registers their offsets with
+// source, so entries not yet acknowledged survive log truncation.
+func NewManager(source Source, store OffsetStore, config Config) (*Manager, error) {
+	defaults := DefaultConfig()
+	if config.MaxBatch <= 0 {
+		config.MaxBatch = defaults.MaxBatch
+	}
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = defaults.MaxInFlight
+	}
+	if config.AckTimeout <= 0 {
+		config.AckTimeout = defaults.AckTimeout
+	}
+
+	offsets, err := store.LoadOffsets()
+	if err != nil {
+		return nil, fmt.Errorf("cdc: loading offsets: %w", err)
+	}
+	m := &Manager{
+		source: source,
+		store:  store,
+		config: config,
+		now:    time.Now,
+		groups: make(map[string]*group, len(offsets)),
+	}
+	for name, offset := range offsets {
+		m.groups[name] = newGroup(name, offset)
+	}
+	m.updateRetention()
+	source.SetRetention(m.retained.Load)
+	return m, nil
+}
+
+func newGroup(name string, offset uint64) *group {
+	return &group{
+		name:      name,
+		acked:     offset,
+		next:      offset,
+		inFlight:  make(map[string]*inFlightBatch),
+		consumers: make(map[string]*ConsumerStats),
+	}
+}
+
+// SetRedactor masks PII in the properties of the events delivered from
+// now on. A nil redactor delivers properties as written. Batches already
+// in flight are redelivered as first built.
+func (m *Manager) SetRedactor(r *redact.Redactor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.redactor = r
+}
+
+// Fetch delivers the next batch of group to consumer. A batch that timed
+// out or was nacked is delivered again before new changes. It returns nil
+// when the group has consumed every change, and ErrBackpressure when the
+// group has MaxInFlight unacknowledged batches. limit caps the WAL
+// entries of a new batch (<= 0 = MaxBatch); one bulk entry can hold many
+// events.
+//
+// A group fetched for the first time is created and starts at the latest
+// change.
+func (m *Manager) Fetch(groupName, consumer string, limit int) (*Batch, error) {
+	if groupName == "" || consumer == "" {
+		return nil, ErrInvalidGroup
+	}
+	if limit <= 0 || limit > m.config.MaxBatch {
+		limit = m.config.MaxBatch
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, err := m.group(groupName)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	stats := g.consumer(consumer)
+	stats.LastSeen = now
+
+	if b := g.expired(now); b != nil {
+		b.due = false
+		b.batch.Consumer = consumer
+		b.batch.Attempt++
+		b.batch.Deadline = now.Add(m.config.AckTimeout)
+		g.redeliveries++
+		stats.Batches++
+		stats.Events += int64(len(b.batch.Events))
+		batch := b.batch
+		return &batch, nil
+	}
+	if len(g.inFlight) >= m.config.MaxInFlight {
+		return nil, ErrBackpressure
+	}
+
+	for g.next < m.source.Sequence() {
+		entries, err := m.source.EntriesAfter(g.next, limit)
+		if err != nil {
+			return nil, fmt.Errorf("cdc: reading changes: %w", err)
+		}
+		if len(entries) == 0 {
+			// The log was truncated before the offset was registered; the
+			// missing changes cannot be delivered any more.
+			entries = []storage.WALEntry{{Sequence: m.source.Sequence()}}
+		}
+		events, err := Events(entries)
+		if err != nil {
+			return nil, err
+		}
+		redactEvents(events, m.redactor)
+		from, to := g.next+1, entries[len(entries)-1].Sequence
+		g.next = to
+		if len(events) == 0 {
+			// Nothing to deliver: acknowledged as soon as every earlier
+			// batch is. Persisted with the next ack, as rereading these
+			// entries after a restart is harmless.
+			if len(g.inFlight) == 0 {
+				g.acked = to
+				m.updateRetention()
+			}
+			continue
+		}
+
+		batch := Batch{
+			ID:       fmt.Sprintf("%d-%d", from, to),
+			Group:    g.name,
+			Consumer: consumer,
+			From:     from,
+			To:       to,
+			Events:   events,
+			Attempt:  1,
+			Deadline: now.Add(m.config.AckTimeout),
+		}
+		g.inFlight[batch.ID] = &inFlightBatch{batch: batch}
+		stats.Batches++
+		stats.Events += int64(len(events))
+		return &batch, nil
+	}
+	return nil, nil
+}
+
+// Ack acknowledges a batch. The group offset advances to the oldest batch
+// still in flight and is persisted.
+func (m *Manager) Ack(groupName, batchID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[groupName]
+	if !ok {
+		return ErrUnknownGroup
+	}
+	b, ok := g.inFlight[batchID]
+	if !ok {
+		return ErrUnknownBatch
+	}
+	delete(g.inFlight, batchID)
+	if stats, ok := g.consumers[b.batch.Consumer]; ok {
+		stats.Acked++
+		stats.LastSeen = m.now()
+	}
+
+	acked := g.next
+	for _, other := range g.inFlight {
+		if other.batch.From-1 < acked {
+			acked = other.batch.From - 1
+		}
+	}
+	if acked == g.acked {
+		return nil
+	}
+	g.acked = acked
+	m.updateRetention()
+	if err := m.store.SaveOffset(g.name, acked); err != nil {
+		// The batch is redelivered after a restart, as at least once allows.
+		return fmt.Errorf("cdc: saving offset: %w", err)
+	}
+	return nil
+}
+
+// Nack rejects a batch so the next Fetch of the group delivers it again.
+func (m *Manager) Nack(groupName, batchID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[groupName]
+	if !ok {
+		return ErrUnknownGroup
+	}
+	b, ok := g.inFlight[batchID]
+	if !ok {
+		return ErrUnknownBatch
+	}
+	b.due = true
+	return nil
+}
+
+// DeleteGroup removes a group and its offset. Its batches in flight can no
+// longer be acknowledged.
+func (m *Manager) DeleteGroup(groupName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[groupName]; !ok {
+		return ErrUnknownGroup
+	}
+	if err := m.store.DeleteGroup(groupName); err != nil {
+		return fmt.Errorf("cdc: deleting offset: %w", err)
+	}
+	delete(m.groups, groupName)
+	m.updateRetention()
+	return nil
+}
+
+// Stats returns the groups, sorted by name.
+func (m *Manager) Stats() []GroupStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	head := m.source.Sequence()
+	result := make([]GroupStats, 0, len(m.groups))
+	for _, g := range m.groups {
+		gs := GroupStats{
+			Name:         g.name,
+			Acked:        g.acked,
+			Delivered:    g.next,
+			Head:         head,
+			Lag:          lag(head, g.acked),
+			InFlight:     len(g.inFlight),
+			Redeliveries: g.redeliveries,
+			Consumers:    make([]ConsumerStats, 0, len(g.consumers)),
+		}
+		for _, c := range g.consumers {
+			cs := *c
+			oldest := g.next
+			for _, b := range g.inFlight {
+				if b.batch.Consumer == c.Name {
+					cs.InFlight++
+					oldest = min(oldest, b.batch.From-1)
+				}
+			}
+			if cs.InFlight == 0 {
+				oldest = g.acked
+			}
+			cs.Lag = lag(head, oldest)
+			gs.Consumers = append(gs.Consumers, cs)
+		}
+		sort.Slice(gs.Consumers, func(i, j int) bool { return gs.Consumers[i].Name < gs.Consumers[j].Name })
+		result = append(result, gs)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// group returns the named group, creating it at the head of the log.
+// Caller holds m.mu.
+func (m *Manager) group(name string) (*group, error) {
+	if g, ok := m.groups[name]; ok {
+		return g, nil
+	}
+	head := m.source.Sequence()
+	if err := m.store.SaveOffset(name, head); err != nil {
+		return nil, fmt.Errorf("cdc: saving offset: %w", err)
+	}
+	g := newGroup(name, head)
+	m.groups[name] = g
+	m.updateRetention()
+	return g, nil
+}
+
+// updateRetention publishes the lowest group offset. Caller holds m.mu.
+func (m *Manager) updateRetention() {
+	retained := uint64(math.MaxUint64)
+	for _, g := range m.groups {
+		retained = min(retained, g.acked)
+	}
+	m.retained.Store(retained)
+}
+
+// expired returns the oldest batch that was nacked or not acknowledged in
+// time.
+func (g *group) expired(now time.Time) *inFlightBatch {
+	var oldest *inFlightBatch
+	for _, b := range g.inFlight {
+		if (b.due || now.After(b.batch.Deadline)) && (oldest == nil || b.batch.From < oldest.batch.From) {
+			oldest = b
+		}
+	}
+	return oldest
+}
+
+func (g *group) consumer(name string) *ConsumerStats {
+	stats, ok := g.consumers[name]
+	if !ok {
+		stats = &ConsumerStats{Name: name}
+		g.consumers[name] = stats
+	}
+	return stats
+}
+
+func lag(head, offset uint64) uint64 {
+	if head <= offset {
+		return 0
+	}
+	return head - offset
+}
@@ -0,0 +1,86 @@
+package cdc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// OffsetLabel labels the nodes holding group offsets.
+const OffsetLabel = "_CDCGroup"
+
+// offsetNodePrefix prefixes the IDs of offset nodes.
+const offsetNodePrefix = "_cdc:"
+
+// OffsetStore persists the acknowledged offset of each group.
+type OffsetStore interface {
+	LoadOffsets() (map[string]uint64, error)
+	SaveOffset(group string, offset uint64) error
+	DeleteGroup(group string) error
+}
+
+// GraphOffsetStore keeps offsets in the graph, one :_CDCGroup node per
+// group, so they are stored, replicated and backed up with the data.
+type GraphOffsetStore struct {
+	engine storage.Engine
+}
+
+// NewGraphOffsetStore returns an OffsetStore over engine.
+func NewGraphOffsetStore(engine storage.Engine) *GraphOffsetStore {
+	return &GraphOffsetStore{engine: engine}
+}
+
+// LoadOffsets implements OffsetStore.
+func (s *GraphOffsetStore) LoadOffsets() (map[string]uint64, error) {
+	nodes, err := s.engine.GetNodesByLabel(OffsetLabel)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make(map[string]uint64, len(nodes))
+	for _, node := range nodes {
+		name, _ := node.Properties["group"].(string)
+		if name == "" {
+			continue
+		}
+		switch offset := node.Properties["acked_offset"].(type) {
+		case int64:
+			offsets[name] = uint64(offset)
+		case float64: // Decoded from JSON
+			offsets[name] = uint64(offset)
+		default:
+			return nil, fmt.Errorf("group %q: invalid acked_offset %v", name, offset)
+		}
+	}
+	return offsets, nil
+}
+
+// SaveOffset implements OffsetStore.
+func (s *GraphOffsetStore) SaveOffset(group string, offset uint64) error {
+	id := storage.NodeID(offsetNodePrefix + group)
+	props := map[string]any{
+		"group":        group,
+		"acked_offset": int64(offset),
+		"updated_at":   time.Now().UTC().Format(time.RFC3339),
+	}
+	node, err := s.engine.GetNode(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return s.engine.CreateNode(&storage.Node{ID: id, Labels: []string{OffsetLabel}, Properties: props, CreatedAt: time.Now()})
+	}
+	if err != nil {
+		return err
+	}
+	node.Properties = props
+	node.UpdatedAt = time.Now()
+	return s.engine.UpdateNode(node)
+}
+
+// DeleteGroup implements OffsetStore.
+func (s *GraphOffsetStore) DeleteGroup(group string) error {
+	err := s.engine.DeleteNode(storage.NodeID(offsetNodePrefix + group))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -84,6 +84,45 @@ type Config struct {
 	// Compliance settings for GDPR/HIPAA/FISMA/SOC2 (NornicDB-specific)
 	Compliance ComplianceConfig
 
+	// Query rate limiting per user/role/IP for Bolt and HTTP (NornicDB-specific)
+	QueryLimits QueryLimitsConfig
+
+	// Idempotency keys for retried writes over Bolt and HTTP (NornicDB-specific)
+	Idempotency IdempotencyConfig
+
+	// Bookmarks for read-your-writes consistency across sessions
+	Bookmarks BookmarkConfig
+
+	// Live queries pushed to Bifrost clients (NornicDB-specific)
+	LiveQueries LiveQueryConfig
+
+	// Result cursors for paging over HTTP and Arrow Flight (NornicDB-specific)
+	Cursors CursorConfig
+
+	// Storage quotas enforced at write time (NornicDB-specific)
+	Quota QuotaConfig
+
+	// Server-side retry of auto-commit writes that lose a write conflict (NornicDB-specific)
+	WriteRetry WriteRetryConfig
+
+	// Write backpressure when health signals cross thresholds (NornicDB-specific)
+	WriteThrottle WriteThrottleConfig
+
+	// Sharing the GPU between vector queries (NornicDB-specific)
+	GPUScheduler GPUSchedulerConfig
+
+	// Property schema registry checked at write time (NornicDB-specific)
+	PropertySchema PropertySchemaConfig
+
+	// Virtual labels resolved from external SQL and REST sources (NornicDB-specific)
+	Federation FederationConfig
+
+	// Offline sync between edge instances and a central server (NornicDB-specific)
+	Sync SyncConfig
+
+	// PII redaction for audit/slow query logs and Heimdall (NornicDB-specific)
+	Redaction RedactionConfig
+
 	// Logging
 	Logging LoggingConfig
 
@@ -103,8 +142,30 @@ type AuthConfig struct {
 	MinPasswordLength int
 	// TokenExpiry for JWT tokens
 	TokenExpiry time.Duration
-	// JWTSecret for signing tokens
+	// JWTSecret for signing tokens (literal or secret reference: env:, file:, vault:)
 	JWTSecret string
+
+	// External identity providers (NornicDB-specific)
+	// LDAPURL enables LDAP bind authentication (ldap://host:389 or ldaps://host:636)
+	LDAPURL string
+	// LDAPUserDNTemplate builds the bind DN, e.g. "uid=%s,ou=people,dc=example,dc=com"
+	LDAPUserDNTemplate string
+	// LDAPGroupBaseDN is searched for groups listing the user as member
+	LDAPGroupBaseDN string
+	// LDAPGroupMemberAttribute holds member DNs on group entries (default: member)
+	LDAPGroupMemberAttribute string
+	// OIDCIssuer enables OIDC bearer token validation against this issuer
+	OIDCIssuer string
+	// OIDCAudience is the expected token audience (client ID)
+	OIDCAudience string
+	// OIDCJWKSURL overrides JWKS discovery
+	OIDCJWKSURL string
+	// OIDCGroupsClaim names the token claim holding groups (default: groups)
+	OIDCGroupsClaim string
+	// RoleMapping maps provider groups to roles ("group=role" entries)
+	RoleMapping []string
+	// ExternalDefaultRole is granted to provider users matching no group ("" = reject)
+	ExternalDefaultRole string
 }
 
 // DatabaseConfig holds database settings.
@@ -175,6 +236,20 @@ type ServerConfig struct {
 	HTTPTLSCert string
 	// HTTPTLSKey path to private key
 	HTTPTLSKey string
+
+	// FlightEnabled controls the Arrow Flight export server (NornicDB-specific)
+	// Environment: NORNICDB_FLIGHT_ENABLED (default: false)
+	FlightEnabled bool
+	// FlightPort for Arrow Flight connections (default 8815)
+	// Environment: NORNICDB_FLIGHT_PORT
+	FlightPort int
+	// FlightBatchSize is the number of rows per Arrow record batch (default 65536)
+	// Environment: NORNICDB_FLIGHT_BATCH_SIZE
+	FlightBatchSize int
+	// FlightWriteTransactionSize caps the rows committed per transaction by
+	// Arrow Flight bulk writes (default 10000)
+	// Environment: NORNICDB_FLIGHT_WRITE_TRANSACTION_SIZE
+	FlightWriteTransactionSize int
 }
 
 // EmbeddingWorkerConfig holds settings for the background embedding worker.
@@ -217,6 +292,12 @@ type MemoryConfig struct {
 	// Each cached embedding uses ~4KB (1024 dims × 4 bytes)
 	// 10000 cache = ~40MB memory, provides significant speedup for repeated queries
 	EmbeddingCacheSize int
+	// EmbeddingCacheTTL expires cached embeddings (0 = never)
+	// Environment: NORNICDB_EMBEDDING_CACHE_TTL (default: 0)
+	EmbeddingCacheTTL time.Duration
+	// EmbeddingCacheDir keeps cached embeddings on disk across restarts ("" = memory only)
+	// Environment: NORNICDB_EMBEDDING_CACHE_DIR (default: "")
+	EmbeddingCacheDir string
 	// AutoLinksEnabled for automatic relationship detection
 	AutoLinksEnabled bool
 	// AutoLinksSimilarityThreshold for similarity-based links
@@ -243,6 +324,9 @@ type MemoryConfig struct {
 	QueryCacheSize int
 	// QueryCacheTTL is how long cached plans remain valid
 	QueryCacheTTL time.Duration
+	// QueryCacheDatabases gives databases their own cache sizes as
+	// "database=maxSize" entries; others use QueryCacheSize each
+	QueryCacheDatabases []string
 }
 
 // ComplianceConfig holds settings for GDPR/HIPAA/FISMA/SOC2 compliance.
@@ -290,6 +374,226 @@ type ComplianceConfig struct {
 	BreachNotifyWebhook    string
 }
 
+// QueryLimitsConfig holds per-user, per-role and per-IP query rate limits.
+// Limits apply to both Bolt and HTTP Cypher queries. Zero means unlimited.
+//
+// Overrides use "name=qps/burst/concurrent/bytes" entries, comma separated;
+// trailing fields may be omitted and empty fields mean unlimited:
+//
+//	NORNICDB_QUERY_LIMIT_USERS="etl=200/400/8,guest=2/5/1/1048576"
+//	NORNICDB_QUERY_LIMIT_ROLES="admin=,viewer=20/40/4"
+//	NORNICDB_QUERY_LIMIT_IPS="10.0.0.5=5"
+//
+// Environment variables:
+//   - NORNICDB_QUERY_LIMIT_ENABLED: Enable query rate limiting (default: false)
+//   - NORNICDB_QUERY_LIMIT_QPS: Default queries per second per caller (default: 0)
+//   - NORNICDB_QUERY_LIMIT_BURST: Default query burst (default: 0 = ceil(QPS))
+//   - NORNICDB_QUERY_LIMIT_CONCURRENT: Default concurrent queries per caller (default: 0)
+//   - NORNICDB_QUERY_LIMIT_RESULT_BYTES: Default result bytes per second per caller (default: 0)
+//   - NORNICDB_QUERY_LIMIT_USERS, NORNICDB_QUERY_LIMIT_ROLES, NORNICDB_QUERY_LIMIT_IPS: Overrides
+type QueryLimitsConfig struct {
+	Enabled              bool
+	QueriesPerSecond     float64
+	Burst                int
+	MaxConcurrent        int
+	ResultBytesPerSecond int64
+	UserOverrides        []string
+	RoleOverrides        []string
+	IPOverrides          []string
+}
+
+// IdempotencyConfig holds the dedupe table for idempotency keys. A write
+// sent with a key is applied once; retries with the same key within TTL
+// receive the recorded result. Keys are passed as Bolt tx_metadata
+// "idempotency_key", the "_idempotency_key" query parameter, or the HTTP
+// Idempotency-Key header.
+//
+// Environment variables:
+//   - NORNICDB_IDEMPOTENCY_TTL: How long results are kept (default: 15m, 0 = disabled)
+//   - NORNICDB_IDEMPOTENCY_MAX_KEYS: Maximum keys held in memory, oldest dropped first (default: 100000)
+type IdempotencyConfig struct {
+	TTL     time.Duration
+	MaxKeys int
+}
+
+// BookmarkConfig holds the bookmarks returned by commits. A session that
+// presents a bookmark waits until the server has applied the state it
+// names, up to WaitTimeout, then fails with BookmarkTimeout.
+//
+// Environment variables:
+//   - NORNICDB_BOOKMARK_WAIT_TIMEOUT: How long sessions wait for a bookmark (default: 30s, 0 = no limit)
+type BookmarkConfig struct {
+	WaitTimeout time.Duration
+}
+
+// LiveQueryConfig holds live queries: Cypher queries registered by Bifrost
+// clients, which receive diffs of the result as the data changes. Queries
+// are evaluated again after writes to the labels they watch, and every
+// query at least once per Interval.
+//
+// Environment variables:
+//   - NORNICDB_LIVE_QUERY_INTERVAL: Full re-evaluation interval (default: 30s)
+//   - NORNICDB_LIVE_QUERY_MAX: Maximum registered live queries (default: 1000)
+type LiveQueryConfig struct {
+	Interval   time.Duration
+	MaxQueries int
+}
+
+// CursorConfig holds server-side result cursors. A statement sent with a
+// page size returns its first page and a cursor; the server keeps the rest
+// of the result until the client fetches it, closes the cursor, or the
+// cursor is idle for TTL.
+//
+// Environment variables:
+//   - NORNICDB_CURSOR_TTL: How long idle cursors are kept (default: 5m, 0 = paging disabled)
+//   - NORNICDB_CURSOR_MAX: Maximum open cursors, least recently used dropped first (default: 1000)
+type CursorConfig struct {
+	TTL        time.Duration
+	MaxCursors int
+}
+
+// QuotaConfig holds storage quotas enforced at write time, so one tenant
+// cannot exhaust a shared instance. Writes that would exceed a limit fail
+// with Neo.ClientError.Database.QuotaExceeded; deletes always succeed.
+// Zero means unlimited.
+//
+// Per-label limits use "Label=maxNodes" entries, comma separated:
+//
+//	NORNICDB_QUOTA_LABELS="Log=50000,Session=10000"
+//
+// Environment variables:
+//   - NORNICDB_QUOTA_MAX_NODES: Maximum nodes (default: 0)
+//   - NORNICDB_QUOTA_MAX_RELATIONSHIPS: Maximum relationships (default: 0)
+//   - NORNICDB_QUOTA_MAX_BYTES: Maximum encoded size of nodes and relationships, e.g. "10GB" (default: 0)
+//   - NORNICDB_QUOTA_LABELS: Maximum nodes per label
+type QuotaConfig struct {
+	MaxNodes         int64
+	MaxRelationships int64
+	MaxBytes         int64
+	Labels           []string
+}
+
+// WriteRetryConfig holds the server-side retry policy for auto-commit
+// writes that abort on a write conflict with a concurrent transaction.
+// Only statements flagged retryable are retried: the "retryable" field of
+// an HTTP statement, or a CYPHER retryable=true prefix. Each retry waits an
+// exponentially growing, jittered backoff.
+//
+// Environment variables:
+//   - NORNICDB_WRITE_RETRY_MAX_RETRIES: Retries after the first attempt (default: 0 = disabled)
+//   - NORNICDB_WRITE_RETRY_BACKOFF: Wait before the first retry (default: 10ms)
+//   - NORNICDB_WRITE_RETRY_MAX_BACKOFF: Maximum wait between retries (default: 1s)
+type WriteRetryConfig struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// WriteThrottleConfig holds the thresholds at which Bolt and HTTP writes
+// are slowed down and then rejected. Between a soft and a hard threshold
+// writes wait up to MaxDelay; past the hard threshold they fail with a
+// retryable error (HTTP 429). Memory is watched only when a memory limit
+// (GOMEMLIMIT) is set; a zero hard threshold stops watching a signal.
+//
+// Environment variables:
+//   - NORNICDB_WRITE_THROTTLE_ENABLED: Enable write throttling (default: false)
+//   - NORNICDB_WRITE_THROTTLE_WAL_LAG_SOFT / _HARD: WAL sync lag (default: 1s / 10s)
+//   - NORNICDB_WRITE_THROTTLE_MEMORY_SOFT / _HARD: Heap fraction of the memory limit (default: 0.85 / 0.95)
+//   - NORNICDB_WRITE_THROTTLE_QUEUE_SOFT / _HARD: Plugin event queue fill (default: 0.8 / 0.95)
+//   - NORNICDB_WRITE_THROTTLE_MAX_DELAY: Longest delay of an admitted write (default: 500ms)
+type WriteThrottleConfig struct {
+	Enabled        bool
+	WALLagSoft     time.Duration
+	WALLagHard     time.Duration
+	MemorySoft     float64
+	MemoryHard     float64
+	EventQueueSoft float64
+	EventQueueHard float64
+	MaxDelay       time.Duration
+}
+
+// GPUSchedulerConfig controls how GPU vector queries share the device.
+// Each query is split into chunks of ChunkRows embeddings, one kernel launch
+// each; a query keeps the device for TimeSlice, then yields to queries of
+// other sessions, which take turns round robin. Queries needing more than
+// MaxQueryVRAMMB of device memory are scored on CPU instead.
+//
+// Environment variables:
+//   - NORNICDB_GPU_TIME_SLICE: Device time per turn (default: 20ms)
+//   - NORNICDB_GPU_CHUNK_ROWS: Embeddings per kernel launch (default: 65536)
+//   - NORNICDB_GPU_MAX_QUERY_VRAM_MB: Device memory limit per query (default: 0 = none)
+type GPUSchedulerConfig struct {
+	TimeSlice      time.Duration
+	ChunkRows      int
+	MaxQueryVRAMMB int
+}
+
+// PropertySchemaConfig holds the property schema registry, which describes
+// the properties expected per node label and relationship type. In warn
+// mode violating writes are stored and reported as Heimdall
+// schema.violation events and audit log entries; in strict mode they fail
+// with Neo.ClientError.Schema.ConstraintValidationFailed. Schemas can also
+// be managed at runtime with the nornicdb.schema.* procedures.
+//
+// Environment variables:
+//   - NORNICDB_SCHEMA_MODE: off, warn or strict (default: the schema file's mode, else off)
+//   - NORNICDB_SCHEMA_FILE: JSON file of label and relationship schemas to load at startup
+type PropertySchemaConfig struct {
+	Mode string
+	File string
+}
+
+// FederationConfig holds the virtual labels whose nodes are read from
+// external data sources (SQL tables, REST endpoints) at query time, so
+// Cypher can join graph data with external reference data. See the
+// federation package for the file format.
+//
+// Environment variables:
+//   - NORNICDB_FEDERATION_FILE: JSON file of virtual labels to load at startup (default: none)
+type FederationConfig struct {
+	File string
+}
+
+// SyncConfig holds offline sync (see the edgesync package). A server with a
+// replica ID serves /nornicdb/sync/ to edge instances; an edge instance
+// that also sets a peer URL syncs with that server every interval, and
+// keeps working while it is offline.
+//
+// Environment variables:
+//   - NORNICDB_SYNC_REPLICA_ID: Unique, stable name of this instance (default: none = sync disabled)
+//   - NORNICDB_SYNC_PEER_URL: Central server to sync with (default: none)
+//   - NORNICDB_SYNC_PEER_TOKEN: Bearer token for the central server (default: none)
+//   - NORNICDB_SYNC_INTERVAL: Time between syncs (default: 1m)
+type SyncConfig struct {
+	ReplicaID string
+	PeerURL   string
+	PeerToken string
+	Interval  time.Duration
+}
+
+// RedactionConfig holds PII redaction rules applied before query text,
+// parameters and properties reach the audit log, slow query log, Heimdall
+// prompts and the Heimdall database event stream.
+//
+// Patterns are regular expressions or built-in names ("email", "ssn",
+// "credit_card", "phone", "ipv4"), separated by semicolons because regular
+// expressions commonly contain commas:
+//
+//	NORNICDB_REDACT_PROPERTIES="ssn,password,dob"
+//	NORNICDB_REDACT_PATTERNS="email;ssn;\bMRN-\d{6,8}\b"
+//
+// Environment variables:
+//   - NORNICDB_REDACT_ENABLED: Enable redaction (default: false)
+//   - NORNICDB_REDACT_PROPERTIES: Sensitive property/parameter names (default: password,ssn,credit_card,api_key,secret,token)
+//   - NORNICDB_REDACT_PATTERNS: Semicolon-separated patterns (default: none)
+//   - NORNICDB_REDACT_MASK: Replacement text (default: [REDACTED])
+type RedactionConfig struct {
+	Enabled    bool
+	Properties []string
+	Patterns   []string
+	Mask       string
+}
+
 // LoggingConfig holds logging settings.
 type LoggingConfig struct {
 	// Level (DEBUG, INFO, WARN, ERROR)
@@ -334,6 +638,11 @@ type FeatureFlagsConfig struct {
 	// Environment: NORNICDB_HEIMDALL_MODEL (default: qwen2.5-1.5b-instruct-q4_k_m)
 	HeimdallModel string
 
+	// Models for individual Heimdall tasks as "task=model" entries; tasks are
+	// chat, qc, summarize, plugin and embedding. Others use HeimdallModel.
+	// Environment: NORNICDB_HEIMDALL_MODEL_ROUTES (comma-separated, default: none)
+	HeimdallModelRoutes []string
+
 	// GPU layers for Heimdall SLM (-1=auto, 0=CPU only)
 	// Falls back to CPU if GPU memory insufficient
 	// Environment: NORNICDB_HEIMDALL_GPU_LAYERS (default: -1)
@@ -368,6 +677,97 @@ type FeatureFlagsConfig struct {
 	// Enable Heimdall memory curation (experimental)
 	// Environment: NORNICDB_HEIMDALL_MEMORY_CURATION (default: false)
 	HeimdallMemoryCuration bool
+
+	// How often memory curation consolidates agent session episodes
+	// Environment: NORNICDB_HEIMDALL_MEMORY_CONSOLIDATION_INTERVAL (default: 1h)
+	HeimdallMemoryConsolidationInterval time.Duration
+
+	// Guardrail rules checked before Heimdall executes an action, in the form
+	// "action[:param]=pattern" (e.g. "*:cypher=\bDETACH\s+DELETE\b").
+	// Environment: NORNICDB_HEIMDALL_GUARDRAILS (semicolon-separated, default: none)
+	HeimdallGuardrails []string
+
+	// Patterns that block an action when found anywhere in the SLM response
+	// Environment: NORNICDB_HEIMDALL_OUTPUT_BLOCKLIST (semicolon-separated, default: none)
+	HeimdallOutputBlocklist []string
+
+	// Reject Heimdall actions whose "cypher" param writes to the graph
+	// Environment: NORNICDB_HEIMDALL_READ_ONLY (default: false)
+	HeimdallReadOnly bool
+
+	// How long Heimdall waits for a user to confirm a medium/high-risk action
+	// Environment: NORNICDB_HEIMDALL_CONFIRM_TIMEOUT (default: 30s)
+	HeimdallConfirmTimeout time.Duration
+
+	// Approve unanswered confirmations instead of rejecting them
+	// Environment: NORNICDB_HEIMDALL_CONFIRM_APPROVE_ON_TIMEOUT (default: false)
+	HeimdallConfirmApproveOnTimeout bool
+
+	// Distinct approvers required for high-risk actions
+	// Environment: NORNICDB_HEIMDALL_HIGH_RISK_APPROVERS (default: 2)
+	HeimdallHighRiskApprovers int
+
+	// Gzip the Bifrost event stream for clients that accept it
+	// Environment: NORNICDB_BIFROST_COMPRESSION (default: true)
+	HeimdallBifrostCompression bool
+
+	// Send notifications arriving within this window as one Bifrost event
+	// Environment: NORNICDB_BIFROST_BATCH_WINDOW (default: 0, sends each at once)
+	HeimdallBifrostBatchWindow time.Duration
+
+	// Send a Bifrost batch early once it holds this many messages or bytes
+	// Environment: NORNICDB_BIFROST_BATCH_MAX_MESSAGES (default: 100),
+	// NORNICDB_BIFROST_BATCH_MAX_BYTES (default: 65536)
+	HeimdallBifrostBatchMaxMessages int
+	HeimdallBifrostBatchMaxBytes    int
+
+	// YAML/JSON file defining named Heimdall personas
+	// Environment: NORNICDB_HEIMDALL_PERSONAS_FILE (default: none)
+	HeimdallPersonasFile string
+
+	// Persona used when a chat request does not select one
+	// Environment: NORNICDB_HEIMDALL_DEFAULT_PERSONA (default: none)
+	HeimdallDefaultPersona string
+
+	// JSON file persisting the watcher's autonomous action rules
+	// Environment: NORNICDB_HEIMDALL_RULES_FILE (default: heimdall/rules.json in the data directory)
+	HeimdallRulesFile string
+
+	// Directory of prompt template versions for autonomous tasks, laid out
+	// as <prompt>/<version>.txt (prompts: qc, qc_augment, consolidation)
+	// Environment: NORNICDB_HEIMDALL_PROMPTS_DIR (default: none, built-in v1 only)
+	HeimdallPromptsDir string
+
+	// Active prompt versions as "prompt=version" entries, e.g. "qc=v2"
+	// Environment: NORNICDB_HEIMDALL_PROMPT_VERSIONS (comma-separated, default: v1)
+	HeimdallPromptVersions []string
+
+	// A/B experiments as "prompt=a:b[:share]" entries, e.g. "qc=v1:v2:0.2"
+	// sends a fifth of QC batches to v2
+	// Environment: NORNICDB_HEIMDALL_PROMPT_EXPERIMENTS (comma-separated, default: none)
+	HeimdallPromptExperiments []string
+
+	// Constrain SLM answers that are parsed as JSON (action commands, QC
+	// reviews, memory consolidation) with a JSON grammar; llama.cpp models only
+	// Environment: NORNICDB_HEIMDALL_JSON_GRAMMAR (default: false)
+	HeimdallJSONGrammar bool
+
+	// Record a trace of each Heimdall chat request (prompt, SLM output,
+	// parsed action, hook changes, result), retrievable by request ID
+	// Environment: NORNICDB_HEIMDALL_TRACE (default: false)
+	HeimdallTrace bool
+
+	// Number of recent traces kept in memory
+	// Environment: NORNICDB_HEIMDALL_TRACE_LIMIT (default: 100)
+	HeimdallTraceLimit int
+
+	// JSON file persisting scheduled Heimdall reports
+	// Environment: NORNICDB_HEIMDALL_REPORTS_FILE (default: heimdall/reports.json in the data directory)
+	HeimdallReportsFile string
+
+	// YAML or JSON file listing webhooks that receive Heimdall alerts
+	// Environment: NORNICDB_HEIMDALL_WEBHOOKS_FILE (default: none)
+	HeimdallWebhooksFile string
 }
 
 // Heimdall config getter methods for heimdall.FeatureFlagsSource interface
@@ -605,6 +1005,18 @@ func LoadFromEnv() *Config {
 	config.Auth.TokenExpiry = getEnvDuration("NORNICDB_AUTH_TOKEN_EXPIRY", 24*time.Hour)
 	config.Auth.JWTSecret = getEnv("NORNICDB_AUTH_JWT_SECRET", generateDefaultSecret())
 
+	// External identity providers (LDAP / OIDC) - disabled unless configured
+	config.Auth.LDAPURL = getEnv("NORNICDB_AUTH_LDAP_URL", "")
+	config.Auth.LDAPUserDNTemplate = getEnv("NORNICDB_AUTH_LDAP_USER_DN_TEMPLATE", "")
+	config.Auth.LDAPGroupBaseDN = getEnv("NORNICDB_AUTH_LDAP_GROUP_BASE_DN", "")
+	config.Auth.LDAPGroupMemberAttribute = getEnv("NORNICDB_AUTH_LDAP_GROUP_MEMBER_ATTRIBUTE", "member")
+	config.Auth.OIDCIssuer = getEnv("NORNICDB_AUTH_OIDC_ISSUER", "")
+	config.Auth.OIDCAudience = getEnv("NORNICDB_AUTH_OIDC_AUDIENCE", "")
+	config.Auth.OIDCJWKSURL = getEnv("NORNICDB_AUTH_OIDC_JWKS_URL", "")
+	config.Auth.OIDCGroupsClaim = getEnv("NORNICDB_AUTH_OIDC_GROUPS_CLAIM", "groups")
+	config.Auth.RoleMapping = getEnvStringSlice("NORNICDB_AUTH_ROLE_MAPPING", nil)
+	config.Auth.ExternalDefaultRole = getEnv("NORNICDB_AUTH_EXTERNAL_DEFAULT_ROLE", "")
+
 	// Database settings
 	config.Database.DataDir = getEnv("NEO4J_dbms_directories_data", "./data")
 	config.Database.DefaultDatabase = getEnv("NEO4J_dbms_default__database", "nornicdb")
@@ -642,6 +1054,12 @@ func LoadFromEnv() *Config {
 	config.Server.HTTPTLSCert = getEnv("NEO4J_dbms_ssl_policy_https_base__directory", "") + "/public.crt"
 	config.Server.HTTPTLSKey = getEnv("NEO4J_dbms_ssl_policy_https_base__directory", "") + "/private.key"
 
+	// Server settings - Arrow Flight (NornicDB-specific, disabled by default)
+	config.Server.FlightEnabled = getEnvBool("NORNICDB_FLIGHT_ENABLED", false)
+	config.Server.FlightPort = getEnvInt("NORNICDB_FLIGHT_PORT", 8815)
+	config.Server.FlightBatchSize = getEnvInt("NORNICDB_FLIGHT_BATCH_SIZE", 65536)
+	config.Server.FlightWriteTransactionSize = getEnvInt("NORNICDB_FLIGHT_WRITE_TRANSACTION_SIZE", 10000)
+
 	// Memory settings (NornicDB-specific, prefixed with NORNICDB_)
 	config.Memory.DecayEnabled = getEnvBool("NORNICDB_MEMORY_DECAY_ENABLED", true)
 	config.Memory.DecayInterval = getEnvDuration("NORNICDB_MEMORY_DECAY_INTERVAL", time.Hour)
@@ -651,6 +1069,8 @@ func LoadFromEnv() *Config {
 	config.Memory.EmbeddingAPIURL = getEnv("NORNICDB_EMBEDDING_API_URL", "http://localhost:11434")
 	config.Memory.EmbeddingDimensions = getEnvInt("NORNICDB_EMBEDDING_DIMENSIONS", 1024)
 	config.Memory.EmbeddingCacheSize = getEnvInt("NORNICDB_EMBEDDING_CACHE_SIZE", 10000) // Default: 10K (~40MB)
+	config.Memory.EmbeddingCacheTTL = getEnvDuration("NORNICDB_EMBEDDING_CACHE_TTL", 0)
+	config.Memory.EmbeddingCacheDir = getEnv("NORNICDB_EMBEDDING_CACHE_DIR", "")
 	config.Memory.AutoLinksEnabled = getEnvBool("NORNICDB_AUTO_LINKS_ENABLED", true)
 	config.Memory.AutoLinksSimilarityThreshold = getEnvFloat("NORNICDB_AUTO_LINKS_THRESHOLD", 0.82)
 
@@ -663,6 +1083,7 @@ func LoadFromEnv() *Config {
 	config.Memory.QueryCacheEnabled = getEnvBool("NORNICDB_QUERY_CACHE_ENABLED", true)
 	config.Memory.QueryCacheSize = getEnvInt("NORNICDB_QUERY_CACHE_SIZE", 1000)
 	config.Memory.QueryCacheTTL = getEnvDuration("NORNICDB_QUERY_CACHE_TTL", 5*time.Minute)
+	config.Memory.QueryCacheDatabases = getEnvStringSlice("NORNICDB_QUERY_CACHE_DATABASES", nil)
 
 	// Embedding worker settings (NornicDB-specific)
 	config.EmbeddingWorker.ScanInterval = getEnvDuration("NORNICDB_EMBED_SCAN_INTERVAL", 15*time.Minute)
@@ -713,6 +1134,77 @@ func LoadFromEnv() *Config {
 	config.Compliance.BreachNotifyEmail = getEnv("NORNICDB_BREACH_NOTIFY_EMAIL", "")
 	config.Compliance.BreachNotifyWebhook = getEnv("NORNICDB_BREACH_NOTIFY_WEBHOOK", "")
 
+	// Query rate limits (NornicDB-specific, disabled by default)
+	config.QueryLimits.Enabled = getEnvBool("NORNICDB_QUERY_LIMIT_ENABLED", false)
+	config.QueryLimits.QueriesPerSecond = getEnvFloat("NORNICDB_QUERY_LIMIT_QPS", 0)
+	config.QueryLimits.Burst = getEnvInt("NORNICDB_QUERY_LIMIT_BURST", 0)
+	config.QueryLimits.MaxConcurrent = getEnvInt("NORNICDB_QUERY_LIMIT_CONCURRENT", 0)
+	config.QueryLimits.ResultBytesPerSecond = int64(getEnvInt("NORNICDB_QUERY_LIMIT_RESULT_BYTES", 0))
+	config.QueryLimits.UserOverrides = getEnvStringSlice("NORNICDB_QUERY_LIMIT_USERS", nil)
+	config.QueryLimits.RoleOverrides = getEnvStringSlice("NORNICDB_QUERY_LIMIT_ROLES", nil)
+	config.QueryLimits.IPOverrides = getEnvStringSlice("NORNICDB_QUERY_LIMIT_IPS", nil)
+
+	// Idempotency keys
+	config.Idempotency.TTL = getEnvDuration("NORNICDB_IDEMPOTENCY_TTL", 15*time.Minute)
+	config.Idempotency.MaxKeys = getEnvInt("NORNICDB_IDEMPOTENCY_MAX_KEYS", 100000)
+
+	// Bookmarks
+	config.Bookmarks.WaitTimeout = getEnvDuration("NORNICDB_BOOKMARK_WAIT_TIMEOUT", 30*time.Second)
+
+	// Live queries
+	config.LiveQueries.Interval = getEnvDuration("NORNICDB_LIVE_QUERY_INTERVAL", 30*time.Second)
+	config.LiveQueries.MaxQueries = getEnvInt("NORNICDB_LIVE_QUERY_MAX", 1000)
+
+	// Result cursors
+	config.Cursors.TTL = getEnvDuration("NORNICDB_CURSOR_TTL", 5*time.Minute)
+	config.Cursors.MaxCursors = getEnvInt("NORNICDB_CURSOR_MAX", 1000)
+
+	// Storage quotas (unlimited by default)
+	config.Quota.MaxNodes = int64(getEnvInt("NORNICDB_QUOTA_MAX_NODES", 0))
+	config.Quota.MaxRelationships = int64(getEnvInt("NORNICDB_QUOTA_MAX_RELATIONSHIPS", 0))
+	config.Quota.MaxBytes = parseMemorySize(getEnv("NORNICDB_QUOTA_MAX_BYTES", "0"))
+	config.Quota.Labels = getEnvStringSlice("NORNICDB_QUOTA_LABELS", nil)
+
+	// Write conflict retries (disabled by default)
+	config.WriteRetry.MaxRetries = getEnvInt("NORNICDB_WRITE_RETRY_MAX_RETRIES", 0)
+	config.WriteRetry.InitialBackoff = getEnvDuration("NORNICDB_WRITE_RETRY_BACKOFF", 10*time.Millisecond)
+	config.WriteRetry.MaxBackoff = getEnvDuration("NORNICDB_WRITE_RETRY_MAX_BACKOFF", time.Second)
+
+	// Write throttling on health signals (disabled by default)
+	config.WriteThrottle.Enabled = getEnvBool("NORNICDB_WRITE_THROTTLE_ENABLED", false)
+	config.WriteThrottle.WALLagSoft = getEnvDuration("NORNICDB_WRITE_THROTTLE_WAL_LAG_SOFT", time.Second)
+	config.WriteThrottle.WALLagHard = getEnvDuration("NORNICDB_WRITE_THROTTLE_WAL_LAG_HARD", 10*time.Second)
+	config.WriteThrottle.MemorySoft = getEnvFloat("NORNICDB_WRITE_THROTTLE_MEMORY_SOFT", 0.85)
+	config.WriteThrottle.MemoryHard = getEnvFloat("NORNICDB_WRITE_THROTTLE_MEMORY_HARD", 0.95)
+	config.WriteThrottle.EventQueueSoft = getEnvFloat("NORNICDB_WRITE_THROTTLE_QUEUE_SOFT", 0.8)
+	config.WriteThrottle.EventQueueHard = getEnvFloat("NORNICDB_WRITE_THROTTLE_QUEUE_HARD", 0.95)
+	config.WriteThrottle.MaxDelay = getEnvDuration("NORNICDB_WRITE_THROTTLE_MAX_DELAY", 500*time.Millisecond)
+
+	// GPU vector query scheduling
+	config.GPUScheduler.TimeSlice = getEnvDuration("NORNICDB_GPU_TIME_SLICE", 20*time.Millisecond)
+	config.GPUScheduler.ChunkRows = getEnvInt("NORNICDB_GPU_CHUNK_ROWS", 65536)
+	config.GPUScheduler.MaxQueryVRAMMB = getEnvInt("NORNICDB_GPU_MAX_QUERY_VRAM_MB", 0)
+
+	// Property schema registry (off by default)
+	config.PropertySchema.Mode = getEnv("NORNICDB_SCHEMA_MODE", "")
+	config.PropertySchema.File = getEnv("NORNICDB_SCHEMA_FILE", "")
+
+	// Virtual labels (none by default)
+	config.Federation.File = getEnv("NORNICDB_FEDERATION_FILE", "")
+
+	// Offline sync (disabled by default)
+	config.Sync.ReplicaID = getEnv("NORNICDB_SYNC_REPLICA_ID", "")
+	config.Sync.PeerURL = getEnv("NORNICDB_SYNC_PEER_URL", "")
+	config.Sync.PeerToken = getEnv("NORNICDB_SYNC_PEER_TOKEN", "")
+	config.Sync.Interval = getEnvDuration("NORNICDB_SYNC_INTERVAL", time.Minute)
+
+	// PII redaction (disabled by default)
+	config.Redaction.Enabled = getEnvBool("NORNICDB_REDACT_ENABLED", false)
+	config.Redaction.Properties = getEnvStringSlice("NORNICDB_REDACT_PROPERTIES",
+		[]string{"password", "ssn", "credit_card", "api_key", "secret", "token"})
+	config.Redaction.Patterns = splitNonEmpty(getEnv("NORNICDB_REDACT_PATTERNS", ""), ";")
+	config.Redaction.Mask = getEnv("NORNICDB_REDACT_MASK", "[REDACTED]")
+
 	// Logging settings
 	config.Logging.Level = getEnv("NEO4J_dbms_logs_debug_level", "INFO")
 	config.Logging.Format = getEnv("NORNICDB_LOG_FORMAT", "json")
@@ -736,6 +1228,7 @@ func LoadFromEnv() *Config {
 	// Opt-in cognitive database features - disabled by default
 	config.Features.HeimdallEnabled = getEnvBool("NORNICDB_HEIMDALL_ENABLED", false)
 	config.Features.HeimdallModel = getEnv("NORNICDB_HEIMDALL_MODEL", "qwen2.5-0.5b-instruct")
+	config.Features.HeimdallModelRoutes = splitNonEmpty(getEnv("NORNICDB_HEIMDALL_MODEL_ROUTES", ""), ",")
 	config.Features.HeimdallGPULayers = getEnvInt("NORNICDB_HEIMDALL_GPU_LAYERS", -1)        // -1 = auto
 	config.Features.HeimdallContextSize = getEnvInt("NORNICDB_HEIMDALL_CONTEXT_SIZE", 32768) // 32K max (no perf impact)
 	config.Features.HeimdallBatchSize = getEnvInt("NORNICDB_HEIMDALL_BATCH_SIZE", 8192)      // 8K max (no perf impact)
@@ -745,6 +1238,28 @@ func LoadFromEnv() *Config {
 	config.Features.HeimdallAnomalyDetection = getEnvBool("NORNICDB_HEIMDALL_ANOMALY_DETECTION", config.Features.HeimdallEnabled)
 	config.Features.HeimdallRuntimeDiagnosis = getEnvBool("NORNICDB_HEIMDALL_RUNTIME_DIAGNOSIS", config.Features.HeimdallEnabled)
 	config.Features.HeimdallMemoryCuration = getEnvBool("NORNICDB_HEIMDALL_MEMORY_CURATION", false) // Experimental
+	config.Features.HeimdallMemoryConsolidationInterval = getEnvDuration("NORNICDB_HEIMDALL_MEMORY_CONSOLIDATION_INTERVAL", time.Hour)
+	config.Features.HeimdallGuardrails = splitNonEmpty(getEnv("NORNICDB_HEIMDALL_GUARDRAILS", ""), ";")
+	config.Features.HeimdallOutputBlocklist = splitNonEmpty(getEnv("NORNICDB_HEIMDALL_OUTPUT_BLOCKLIST", ""), ";")
+	config.Features.HeimdallReadOnly = getEnvBool("NORNICDB_HEIMDALL_READ_ONLY", false)
+	config.Features.HeimdallConfirmTimeout = getEnvDuration("NORNICDB_HEIMDALL_CONFIRM_TIMEOUT", 30*time.Second)
+	config.Features.HeimdallConfirmApproveOnTimeout = getEnvBool("NORNICDB_HEIMDALL_CONFIRM_APPROVE_ON_TIMEOUT", false)
+	config.Features.HeimdallHighRiskApprovers = getEnvInt("NORNICDB_HEIMDALL_HIGH_RISK_APPROVERS", 2)
+	config.Features.HeimdallBifrostCompression = getEnvBool("NORNICDB_BIFROST_COMPRESSION", true)
+	config.Features.HeimdallBifrostBatchWindow = getEnvDuration("NORNICDB_BIFROST_BATCH_WINDOW", 0)
+	config.Features.HeimdallBifrostBatchMaxMessages = getEnvInt("NORNICDB_BIFROST_BATCH_MAX_MESSAGES", 100)
+	config.Features.HeimdallBifrostBatchMaxBytes = getEnvInt("NORNICDB_BIFROST_BATCH_MAX_BYTES", 64*1024)
+	config.Features.HeimdallPersonasFile = getEnv("NORNICDB_HEIMDALL_PERSONAS_FILE", "")
+	config.Features.HeimdallDefaultPersona = getEnv("NORNICDB_HEIMDALL_DEFAULT_PERSONA", "")
+	config.Features.HeimdallRulesFile = getEnv("NORNICDB_HEIMDALL_RULES_FILE", "")
+	config.Features.HeimdallPromptsDir = getEnv("NORNICDB_HEIMDALL_PROMPTS_DIR", "")
+	config.Features.HeimdallPromptVersions = splitNonEmpty(getEnv("NORNICDB_HEIMDALL_PROMPT_VERSIONS", ""), ",")
+	config.Features.HeimdallPromptExperiments = splitNonEmpty(getEnv("NORNICDB_HEIMDALL_PROMPT_EXPERIMENTS", ""), ",")
+	config.Features.HeimdallJSONGrammar = getEnvBool("NORNICDB_HEIMDALL_JSON_GRAMMAR", false)
+	config.Features.HeimdallTrace = getEnvBool("NORNICDB_HEIMDALL_TRACE", false)
+	config.Features.HeimdallTraceLimit = getEnvInt("NORNICDB_HEIMDALL_TRACE_LIMIT", 100)
+	config.Features.HeimdallReportsFile = getEnv("NORNICDB_HEIMDALL_REPORTS_FILE", "")
+	config.Features.HeimdallWebhooksFile = getEnv("NORNICDB_HEIMDALL_WEBHOOKS_FILE", "")
 
 	return config
 }
@@ -786,6 +1301,10 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid http port: %d", c.Server.HTTPPort)
 	}
 
+	if c.Server.FlightEnabled && c.Server.FlightPort <= 0 {
+		return fmt.Errorf("invalid flight port: %d", c.Server.FlightPort)
+	}
+
 	if c.Memory.EmbeddingDimensions <= 0 {
 		return fmt.Errorf("invalid embedding dimensions: %d", c.Memory.EmbeddingDimensions)
 	}
@@ -880,6 +1399,17 @@ func getEnvStringSlice(key string, defaultVal []string) []string {
 	return defaultVal
 }
 
+// splitNonEmpty splits s by sep, trimming whitespace and dropping empty parts.
+func splitNonEmpty(s, sep string) []string {
+	var result []string
+	for _, p := range strings.Split(s, sep) {
+		if trimmed := strings.TrimSpace(p); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
 func generateDefaultSecret() string {
 	// In production, this should be explicitly set
 	return "CHANGE_ME_IN_PRODUCTION_" + strconv.FormatInt(time.Now().UnixNano(), 36)
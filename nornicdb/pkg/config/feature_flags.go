@@ -108,6 +108,12 @@ const (
 	// DISABLED by default - increases SLM workload
 	EnvAutoTLPLLMAugmentEnabled = "NORNICDB_AUTO_TLP_LLM_AUGMENT_ENABLED"
 
+	// EnvAutoTLPLLMQCShadowEnabled runs Heimdall QC in shadow mode
+	// Decisions are recorded and scored against later feedback, but no edge is filtered
+	// Requires EnvAutoTLPLLMQCEnabled to also be enabled
+	// DISABLED by default
+	EnvAutoTLPLLMQCShadowEnabled = "NORNICDB_AUTO_TLP_LLM_QC_SHADOW_ENABLED"
+
 	// FeatureKalmanDecay enables Kalman filtering for memory decay prediction
 	FeatureKalmanDecay = "kalman_decay"
 
@@ -196,6 +202,7 @@ var (
 	edgeDecayEnabled                     atomic.Bool
 	autoTLPLLMQCEnabled                  atomic.Bool
 	autoTLPLLMAugmentEnabled             atomic.Bool
+	autoTLPLLMQCShadowEnabled            atomic.Bool
 	featureFlags                         = make(map[string]bool)
 	featureFlagsMu                       sync.RWMutex
 	initOnce                             sync.Once
@@ -304,6 +311,12 @@ func init() {
 		if env := os.Getenv(EnvAutoTLPLLMAugmentEnabled); env == "true" || env == "1" {
 			autoTLPLLMAugmentEnabled.Store(true)
 		}
+
+		// Auto-TLP LLM QC shadow mode: DISABLED by default
+		// When enabled, QC decisions are only recorded for evaluation
+		if env := os.Getenv(EnvAutoTLPLLMQCShadowEnabled); env == "true" || env == "1" {
+			autoTLPLLMQCShadowEnabled.Store(true)
+		}
 	})
 }
 
@@ -1187,3 +1200,29 @@ func WithAutoTLPLLMAugmentDisabled() func() {
 		autoTLPLLMAugmentEnabled.Store(prev)
 	}
 }
+
+// EnableAutoTLPLLMQCShadow runs Heimdall QC in shadow mode.
+// Decisions are recorded but no suggestion is filtered.
+func EnableAutoTLPLLMQCShadow() {
+	autoTLPLLMQCShadowEnabled.Store(true)
+}
+
+// DisableAutoTLPLLMQCShadow lets Heimdall QC filter suggestions again.
+func DisableAutoTLPLLMQCShadow() {
+	autoTLPLLMQCShadowEnabled.Store(false)
+}
+
+// IsAutoTLPLLMQCShadowEnabled returns true if Heimdall QC runs in shadow mode.
+// It sets the default of HeimdallQCConfig.ShadowMode.
+func IsAutoTLPLLMQCShadowEnabled() bool {
+	return autoTLPLLMQCShadowEnabled.Load()
+}
+
+// WithAutoTLPLLMQCShadowEnabled temporarily enables QC shadow mode.
+func WithAutoTLPLLMQCShadowEnabled() func() {
+	prev := autoTLPLLMQCShadowEnabled.Load()
+	autoTLPLLMQCShadowEnabled.Store(true)
+	return func() {
+		autoTLPLLMQCShadowEnabled.Store(prev)
+	}
+}
@@ -0,0 +1,225 @@
+// Package cursor pages through large query results without re-running them.
+//
+// A web application showing a large result one screen at a time would
+// otherwise re-execute the query with SKIP and LIMIT for every page, paying
+// for all skipped rows each time and seeing rows shift as data changes. With
+// a cursor the query runs once: the first page is returned with an opaque
+// token, and the server holds the remaining rows until the client fetches
+// them with the token, closes the cursor, or the TTL passes.
+//
+// Tokens are random and bound to the caller that opened the cursor; another
+// caller presenting the token is told the cursor does not exist. Each fetch
+// extends the cursor's life by the TTL, and the cursor is dropped after its
+// last page.
+//
+// Cursors are held in memory and shared by the HTTP and Arrow Flight
+// servers; they do not survive a restart.
+//
+// Example:
+//
+//	store := cursor.New(5*time.Minute, 1000)
+//
+//	page := store.Open(user, result.Columns, result.Rows, 100)
+//	// send page.Rows and page.Cursor; later:
+//	page, err := store.Next(user, page.Cursor, 0)
+package cursor
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotFound is returned for a token that is unknown, expired, exhausted
+// or owned by another caller.
+var ErrNotFound = errors.New("cursor not found or expired")
+
+// Store holds open cursors keyed by token. It is safe for concurrent use.
+// A nil *Store holds nothing: Open returns every row as a single page.
+type Store struct {
+	ttl        time.Duration
+	maxCursors int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	cursors map[string]*entry
+	order   []deadline // expiry order; refreshed cursors appear again later
+
+	pages atomic.Int64
+}
+
+type entry struct {
+	token    string
+	owner    string
+	columns  []string
+	rows     [][]any
+	pos      int
+	pageSize int
+	expires  time.Time
+}
+
+// deadline records an expiry time of a cursor. It is stale once the cursor
+// is closed or refreshed.
+type deadline struct {
+	e       *entry
+	expires time.Time
+}
+
+// Page is a slice of a result.
+type Page struct {
+	Columns []string
+	Rows    [][]any
+	// Cursor fetches the next page; empty on the last page.
+	Cursor string
+}
+
+// New returns a store that keeps idle cursors for ttl, holding at most
+// maxCursors cursors (0 = unlimited); the least recently used are dropped
+// first. It returns nil, which disables paging, if ttl is not positive.
+func New(ttl time.Duration, maxCursors int) *Store {
+	if ttl <= 0 {
+		return nil
+	}
+	return &Store{
+		ttl:        ttl,
+		maxCursors: maxCursors,
+		now:        time.Now,
+		cursors:    make(map[string]*entry),
+	}
+}
+
+// Open returns the first pageSize rows of a result. If more rows remain it
+// holds them for owner and returns a cursor for the next page. A pageSize
+// of 0 or less, or a nil store, returns every row. The store keeps rows;
+// the caller must not modify them afterwards.
+func (s *Store) Open(owner string, columns []string, rows [][]any, pageSize int) Page {
+	if s == nil || pageSize <= 0 || len(rows) <= pageSize {
+		return Page{Columns: columns, Rows: rows}
+	}
+	e := &entry{
+		token:    newToken(),
+		owner:    owner,
+		columns:  columns,
+		rows:     rows,
+		pos:      pageSize,
+		pageSize: pageSize,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(1)
+	s.cursors[e.token] = e
+	s.touch(e)
+	s.pages.Add(1)
+	return Page{Columns: columns, Rows: rows[:pageSize], Cursor: e.token}
+}
+
+// Next returns the next page of the cursor token opened by owner. A
+// pageSize of 0 or less uses the size the cursor was opened with. The
+// returned page has the same token while rows remain.
+func (s *Store) Next(owner, token string, pageSize int) (Page, error) {
+	if s == nil {
+		return Page{}, ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(0)
+	e, ok := s.cursors[token]
+	if !ok || e.owner != owner {
+		return Page{}, ErrNotFound
+	}
+	if pageSize <= 0 {
+		pageSize = e.pageSize
+	}
+
+	end := min(e.pos+pageSize, len(e.rows))
+	page := Page{Columns: e.columns, Rows: e.rows[e.pos:end]}
+	e.pos = end
+	if end < len(e.rows) {
+		page.Cursor = token
+		s.touch(e)
+	} else {
+		delete(s.cursors, token)
+	}
+	s.pages.Add(1)
+	return page, nil
+}
+
+// Close drops the cursor token opened by owner, reporting whether it was
+// open.
+func (s *Store) Close(owner, token string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.cursors[token]; ok && e.owner == owner {
+		delete(s.cursors, token)
+		return true
+	}
+	return false
+}
+
+// touch extends the life of e by the TTL. The caller must hold s.mu.
+func (s *Store) touch(e *entry) {
+	e.expires = s.now().Add(s.ttl)
+	s.order = append(s.order, deadline{e: e, expires: e.expires})
+}
+
+// expire drops expired cursors and the least recently used ones until
+// adding more fit within maxCursors. The caller must hold s.mu.
+func (s *Store) expire(adding int) {
+	now := s.now()
+	n := 0
+	for _, d := range s.order {
+		if s.cursors[d.e.token] != d.e || !d.expires.Equal(d.e.expires) {
+			n++ // closed or refreshed since
+			continue
+		}
+		if now.Before(d.expires) && (s.maxCursors <= 0 || len(s.cursors)+adding <= s.maxCursors) {
+			break
+		}
+		delete(s.cursors, d.e.token)
+		n++
+	}
+	if n > 0 {
+		s.order = append(s.order[:0:0], s.order[n:]...)
+	}
+}
+
+// newToken returns a random URL-safe token.
+func newToken() string {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("cursor: reading random bytes: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Stats describes the open cursors.
+type Stats struct {
+	Cursors int   // cursors currently open
+	Pages   int64 // pages served from cursors
+}
+
+// Stats returns the number of open cursors and pages served so far.
+func (s *Store) Stats() Stats {
+	if s == nil {
+		return Stats{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{Cursors: len(s.cursors), Pages: s.pages.Load()}
+}
+
+// String describes the store for startup logs.
+func (s *Store) String() string {
+	if s == nil {
+		return "disabled"
+	}
+	return fmt.Sprintf("ttl %s, max %d cursors", s.ttl, s.maxCursors)
+}
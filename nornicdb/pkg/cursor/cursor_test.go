@@ -0,0 +1,107 @@
+package cursor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rows(n int) [][]any {
+	out := make([][]any, n)
+	for i := range out {
+		out[i] = []any{int64(i)}
+	}
+	return out
+}
+
+func TestPaging(t *testing.T) {
+	s := New(time.Minute, 0)
+	cols := []string{"n"}
+
+	page := s.Open("ada", cols, rows(5), 2)
+	assert.Equal(t, cols, page.Columns)
+	assert.Equal(t, rows(2), page.Rows)
+	require.NotEmpty(t, page.Cursor)
+	token := page.Cursor
+
+	page, err := s.Next("ada", token, 0)
+	require.NoError(t, err)
+	assert.Equal(t, [][]any{{int64(2)}, {int64(3)}}, page.Rows)
+	assert.Equal(t, token, page.Cursor)
+
+	page, err = s.Next("ada", token, 10)
+	require.NoError(t, err)
+	assert.Equal(t, [][]any{{int64(4)}}, page.Rows)
+	assert.Empty(t, page.Cursor, "last page")
+
+	_, err = s.Next("ada", token, 0)
+	assert.ErrorIs(t, err, ErrNotFound, "exhausted cursors are dropped")
+	assert.Equal(t, Stats{Cursors: 0, Pages: 3}, s.Stats())
+}
+
+func TestOpenWithoutPaging(t *testing.T) {
+	s := New(time.Minute, 0)
+	for name, page := range map[string]Page{
+		"fits one page": s.Open("ada", nil, rows(3), 3),
+		"no page size":  s.Open("ada", nil, rows(3), 0),
+		"nil store":     (*Store)(nil).Open("ada", nil, rows(3), 1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Len(t, page.Rows, 3)
+			assert.Empty(t, page.Cursor)
+		})
+	}
+	assert.Nil(t, New(0, 10), "non-positive TTL disables the store")
+	assert.Equal(t, Stats{}, s.Stats())
+
+	var disabled *Store
+	_, err := disabled.Next("ada", "x", 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.False(t, disabled.Close("ada", "x"))
+}
+
+func TestOwnerAndClose(t *testing.T) {
+	s := New(time.Minute, 0)
+	token := s.Open("ada", nil, rows(3), 1).Cursor
+
+	_, err := s.Next("bob", token, 0)
+	assert.ErrorIs(t, err, ErrNotFound, "cursors are bound to their owner")
+	assert.False(t, s.Close("bob", token))
+
+	assert.True(t, s.Close("ada", token))
+	_, err = s.Next("ada", token, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotEqual(t, token, s.Open("ada", nil, rows(3), 1).Cursor, "tokens are unique")
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := New(time.Minute, 2)
+	s.now = func() time.Time { return now }
+
+	a := s.Open("u", nil, rows(10), 1).Cursor
+	now = now.Add(30 * time.Second)
+	b := s.Open("u", nil, rows(10), 1).Cursor
+
+	now = now.Add(40 * time.Second)
+	_, err := s.Next("u", a, 0)
+	assert.ErrorIs(t, err, ErrNotFound, "idle past the TTL")
+	_, err = s.Next("u", b, 0)
+	require.NoError(t, err, "fetching refreshes the TTL")
+
+	now = now.Add(50 * time.Second)
+	_, err = s.Next("u", b, 0)
+	require.NoError(t, err)
+
+	c := s.Open("u", nil, rows(10), 1).Cursor
+	d := s.Open("u", nil, rows(10), 1).Cursor
+	_, err = s.Next("u", b, 0)
+	assert.ErrorIs(t, err, ErrNotFound, "least recently used dropped past maxCursors")
+	for _, token := range []string{c, d} {
+		_, err = s.Next("u", token, 0)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, s.Stats().Cursors)
+}
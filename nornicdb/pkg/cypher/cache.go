@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/orneryd/nornicdb/pkg/value"
 )
 
 // QueryCache provides LRU (Least Recently Used) caching for Cypher query results
@@ -275,6 +276,7 @@ func (qc *QueryCache) Get(cypher string, params map[string]interface{}) (*Execut
 //
 //	Safe to call concurrently from multiple goroutines.
 func (qc *QueryCache) Put(cypher string, params map[string]interface{}, result *ExecuteResult, ttl time.Duration) {
+	result.retain() // Hits share the rows
 	key := qc.cacheKey(cypher, params)
 
 	qc.mu.Lock()
@@ -415,9 +417,9 @@ func (qc *QueryCache) cacheKey(cypher string, params map[string]interface{}) str
 	h := fnv.New64a()
 	h.Write([]byte(cypher))
 
-	// Add params in sorted order for consistency
+	// Type-tagged params, so 1 and "1" get different keys
 	if params != nil {
-		h.Write([]byte(fmt.Sprintf("%v", params)))
+		h.Write(value.FromAnyMap(params).AppendKey(nil))
 	}
 
 	return strconv.FormatUint(h.Sum64(), 36)
@@ -543,6 +545,7 @@ func (sc *SmartQueryCache) Get(cypher string, params map[string]interface{}) (*E
 
 // PutWithLabels stores a result with associated labels for smart invalidation.
 func (sc *SmartQueryCache) PutWithLabels(cypher string, params map[string]interface{}, result *ExecuteResult, ttl time.Duration, labels []string) {
+	result.retain() // Hits share the rows
 	key := cacheKeyFNV(cypher, params)
 
 	sc.mu.Lock()
@@ -631,6 +634,27 @@ func (sc *SmartQueryCache) Stats() (hits, misses int64, size int, smartInvals, f
 	return sc.hits, sc.misses, len(sc.cache), sc.smartInvals, sc.fullInvals
 }
 
+// Capacity returns the maximum number of cached results.
+func (sc *SmartQueryCache) Capacity() int {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.maxSize
+}
+
+// Resize changes the capacity of a live cache, evicting the least recently
+// used results until it fits. Sizes below 1 are ignored.
+func (sc *SmartQueryCache) Resize(maxSize int) {
+	if maxSize < 1 {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.maxSize = maxSize
+	for sc.lru.Len() > sc.maxSize {
+		sc.evictOldestLRU()
+	}
+}
+
 // removeEntry removes an entry and cleans up label indexes.
 func (sc *SmartQueryCache) removeEntry(key string) {
 	if entry, ok := sc.cache[key]; ok {
@@ -691,7 +715,7 @@ func cacheKeyFNV(cypher string, params map[string]interface{}) string {
 	h := fnv.New64a()
 	h.Write([]byte(cypher))
 	if params != nil {
-		h.Write([]byte(fmt.Sprintf("%v", params)))
+		h.Write(value.FromAnyMap(params).AppendKey(nil))
 	}
 	return strconv.FormatUint(h.Sum64(), 36)
 }
@@ -129,6 +129,24 @@ func TestQueryCache_ParameterizedQueries(t *testing.T) {
 	}
 }
 
+func TestQueryCache_ParameterTypes(t *testing.T) {
+	cache := NewQueryCache(10)
+	query := "MATCH (n {id: $id}) RETURN n"
+
+	// 1 and "1" print the same with %v but match different nodes
+	cache.Put(query, map[string]interface{}{"id": 1}, &ExecuteResult{Rows: [][]interface{}{{"int"}}}, time.Minute)
+	cache.Put(query, map[string]interface{}{"id": "1"}, &ExecuteResult{Rows: [][]interface{}{{"string"}}}, time.Minute)
+
+	cached, found := cache.Get(query, map[string]interface{}{"id": int64(1)})
+	if !found || cached.Rows[0][0] != "int" {
+		t.Error("Should retrieve int result")
+	}
+	cached, found = cache.Get(query, map[string]interface{}{"id": "1"})
+	if !found || cached.Rows[0][0] != "string" {
+		t.Error("Should retrieve string result")
+	}
+}
+
 func TestQueryCache_Invalidate(t *testing.T) {
 	cache := NewQueryCache(10)
 
@@ -26,6 +26,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/orneryd/nornicdb/pkg/convert"
 	"github.com/orneryd/nornicdb/pkg/math/vector"
@@ -317,10 +318,52 @@ func (e *StorageExecutor) executeCall(ctx context.Context, cypher string) (*Exec
 	var result *ExecuteResult
 	var err error
 
+	// Procedures taking a query string dispatch on their exact name, since
+	// the query argument may mention other procedures
+	var procName string
+	if m := callProcedurePattern.FindStringSubmatch(cypher); m != nil {
+		procName = strings.ToLower(m[1])
+	}
+
 	switch {
+	// Query linting and prepared statements
+	case procName == "db.lint":
+		result, err = e.callDbLint(cypher)
+	case procName == "db.prepare":
+		result, err = e.callDbPrepare(cypher)
+	case procName == "db.prepared.execute":
+		result, err = e.callDbPreparedExecute(ctx, cypher)
+	case procName == "db.prepared.drop":
+		result, err = e.callDbPreparedDrop(cypher)
+	case procName == "db.prepared.list":
+		result, err = e.callDbPreparedList()
+	// Stored query library
+	case procName == "db.query.save":
+		result, err = e.callDbQuerySave(ctx, cypher)
+	case procName == "db.query.run":
+		result, err = e.callDbQueryRun(ctx, cypher)
+	case procName == "db.query.list":
+		result, err = e.callDbQueryList(ctx)
+	case procName == "db.query.history":
+		result, err = e.callDbQueryHistory(ctx, cypher)
+	case procName == "db.query.drop":
+		result, err = e.callDbQueryDrop(ctx, cypher)
+	// Graph sampling
+	case procName == "db.sample.nodes":
+		result, err = e.callDbSampleNodes(ctx, cypher)
+	case procName == "db.sample.subgraph":
+		result, err = e.callDbSampleSubgraph(ctx, cypher)
+	// Live query registry
+	case procName == "dbms.listqueries":
+		result, err = e.callDbmsListQueries(ctx)
+	case procName == "dbms.killquery":
+		result, err = e.callDbmsKillQuery(ctx, cypher)
+	// Runtime configuration
+	case procName == "dbms.setconfig" || procName == "dbms.setconfigvalue":
+		result, err = e.callDbmsSetConfig(ctx, cypher, procName)
 	// Neo4j Vector Index Procedures (CRITICAL for Mimir)
 	case strings.Contains(upper, "DB.INDEX.VECTOR.QUERYNODES"):
-		result, err = e.callDbIndexVectorQueryNodes(cypher)
+		result, err = e.callDbIndexVectorQueryNodes(ctx, cypher)
 	// Neo4j Fulltext Index Procedures (CRITICAL for Mimir)
 	case strings.Contains(upper, "DB.INDEX.FULLTEXT.QUERYNODES"):
 		result, err = e.callDbIndexFulltextQueryNodes(cypher)
@@ -376,10 +419,34 @@ func (e *StorageExecutor) executeCall(ctx context.Context, cypher string) (*Exec
 	// NornicDB Extensions
 	case strings.Contains(upper, "NORNICDB.VERSION"):
 		result, err = e.callNornicDbVersion()
+	case procName == "nornicdb.workload":
+		result, err = e.callNornicDbWorkload()
+	case procName == "nornicdb.workload.recommend":
+		result, err = e.callNornicDbWorkloadRecommend()
+	case procName == "nornicdb.schema.registry":
+		result, err = e.callNornicDbSchemaRegistry()
+	case procName == "nornicdb.schema.define":
+		result, err = e.callNornicDbSchemaDefine(cypher)
+	case procName == "nornicdb.schema.drop":
+		result, err = e.callNornicDbSchemaDrop(cypher)
+	case procName == "nornicdb.schema.mode":
+		result, err = e.callNornicDbSchemaMode(cypher)
 	case strings.Contains(upper, "NORNICDB.STATS"):
 		result, err = e.callNornicDbStats()
 	case strings.Contains(upper, "NORNICDB.DECAY.INFO"):
 		result, err = e.callNornicDbDecayInfo()
+	case strings.Contains(upper, "NORNICDB.GPU.PROBE"):
+		result, err = e.callNornicDbGpuProbe()
+	case procName == "nornicdb.cluster.embeddings":
+		result, err = e.callNornicDbClusterEmbeddings(ctx, cypher)
+	case procName == "nornicdb.memory.append":
+		result, err = e.callNornicDbMemoryAppend(ctx, cypher)
+	case procName == "nornicdb.memory.consolidate":
+		result, err = e.callNornicDbMemoryConsolidate(ctx, cypher)
+	case procName == "nornicdb.memory.retrieve":
+		result, err = e.callNornicDbMemoryRetrieve(ctx, cypher)
+	case procName == "nornicdb.qc.report":
+		result, err = e.callNornicDbQCReport()
 	// Neo4j Schema/Metadata Procedures
 	case strings.Contains(upper, "DB.SCHEMA.VISUALIZATION"):
 		result, err = e.callDbSchemaVisualization()
@@ -444,6 +511,8 @@ func (e *StorageExecutor) executeCall(ctx context.Context, cypher string) (*Exec
 		result, err = e.callDbIndexFulltextCreateRelationshipIndex(ctx, cypher)
 	case strings.Contains(upper, "DB.INDEX.FULLTEXT.DROP"):
 		result, err = e.callDbIndexFulltextDrop(cypher)
+	case strings.Contains(upper, "DB.TLP.EXPLAIN"):
+		result, err = e.callDbTlpExplain(cypher)
 	case strings.Contains(upper, "DB.INDEX.VECTOR.DROP"):
 		result, err = e.callDbIndexVectorDrop(cypher)
 	case strings.Contains(upper, "DB.INDEX.FULLTEXT.LISTAVAILABLEANALYZERS"):
@@ -471,9 +540,9 @@ func (e *StorageExecutor) executeCall(ctx context.Context, cypher string) (*Exec
 		result, err = e.callTxSetMetadata(cypher)
 	// Index management procedures
 	case strings.Contains(upper, "DB.AWAITINDEXES"):
-		result, err = e.callDbAwaitIndexes(cypher)
+		result, err = e.callDbAwaitIndexes(ctx, cypher)
 	case strings.Contains(upper, "DB.AWAITINDEX"):
-		result, err = e.callDbAwaitIndex(cypher)
+		result, err = e.callDbAwaitIndex(ctx, cypher)
 	case strings.Contains(upper, "DB.RESAMPLEINDEX"):
 		result, err = e.callDbResampleIndex(cypher)
 	// Query statistics procedures (longer matches first)
@@ -484,11 +553,11 @@ func (e *StorageExecutor) executeCall(ctx context.Context, cypher string) (*Exec
 	case strings.Contains(upper, "DB.STATS.COLLECT"):
 		result, err = e.callDbStatsCollect(cypher)
 	case strings.Contains(upper, "DB.STATS.CLEAR"):
-		result, err = e.callDbStatsClear()
+		result, err = e.callDbStatsClear(cypher)
 	case strings.Contains(upper, "DB.STATS.STATUS"):
 		result, err = e.callDbStatsStatus()
 	case strings.Contains(upper, "DB.STATS.STOP"):
-		result, err = e.callDbStatsStop()
+		result, err = e.callDbStatsStop(cypher)
 	// Database cleardown procedures (for testing)
 	case strings.Contains(upper, "DB.CLEARQUERYCACHES"):
 		result, err = e.callDbClearQueryCaches()
@@ -596,11 +665,20 @@ func (e *StorageExecutor) callDbIndexes() (*ExecuteResult, error) {
 			properties = ps
 		}
 
-		rows = append(rows, []interface{}{name, idxType, labels, properties, "ONLINE"})
+		// Vector indexes are populated in the background; the others are
+		// maintained on write and online at once
+		state, percent := "ONLINE", 100.0
+		if population, ok := e.vectorIndexes.(VectorIndexPopulation); ok && idxType == "VECTOR" {
+			if s, p, known := population.VectorIndexState(name.(string)); known {
+				state, percent = s, p
+			}
+		}
+
+		rows = append(rows, []interface{}{name, idxType, labels, properties, state, percent})
 	}
 
 	return &ExecuteResult{
-		Columns: []string{"name", "type", "labelsOrTypes", "properties", "state"},
+		Columns: []string{"name", "type", "labelsOrTypes", "properties", "state", "populationPercent"},
 		Rows:    rows,
 	}, nil
 }
@@ -705,6 +783,243 @@ func (e *StorageExecutor) callNornicDbDecayInfo() (*ExecuteResult, error) {
 	}, nil
 }
 
+// callNornicDbGpuProbe returns one row per GPU backend. Devices are listed
+// as maps with name, vendor, memory, compute capability, driver version,
+// max work group size and features.
+func (e *StorageExecutor) callNornicDbGpuProbe() (*ExecuteResult, error) {
+	if e.gpuDiagnostics == nil {
+		return nil, fmt.Errorf("GPU diagnostics are not available")
+	}
+	result := &ExecuteResult{
+		Columns: []string{"backend", "compiled", "available", "reason", "devices"},
+		Rows:    [][]interface{}{},
+	}
+	for _, b := range e.gpuDiagnostics.ProbeGPU() {
+		devices := b["devices"]
+		if devices == nil {
+			devices = []interface{}{}
+		}
+		result.Rows = append(result.Rows, []interface{}{b["backend"], b["compiled"], b["available"], b["reason"], devices})
+	}
+	return result, nil
+}
+
+// callNornicDbClusterEmbeddings implements
+// CALL nornicdb.cluster.embeddings(algorithm[, {k, minClusterSize, label}]).
+func (e *StorageExecutor) callNornicDbClusterEmbeddings(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	const proc = "nornicdb.cluster.embeddings"
+	if e.embeddingClusterer == nil {
+		return nil, fmt.Errorf("embedding clustering is not available")
+	}
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("%s requires (algorithm[, config])", proc)
+	}
+	var options EmbeddingClusterOptions
+	var ok bool
+	if options.Algorithm, ok = args[0].(string); !ok {
+		return nil, fmt.Errorf("%s: algorithm must be 'kmeans' or 'hdbscan', got %v", proc, args[0])
+	}
+	if len(args) == 2 && args[1] != nil {
+		config, ok := args[1].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: config must be a map, got %v", proc, args[1])
+		}
+		for key, value := range config {
+			switch key {
+			case "k":
+				options.K = int(toInt64(value))
+			case "minClusterSize":
+				options.MinClusterSize = int(toInt64(value))
+			case "label":
+				if options.Label, ok = value.(string); !ok {
+					return nil, fmt.Errorf("%s: label must be a string, got %v", proc, value)
+				}
+			default:
+				return nil, fmt.Errorf("%s: unknown config key %q (use k, minClusterSize or label)", proc, key)
+			}
+		}
+	}
+	summary, err := e.embeddingClusterer.ClusterEmbeddings(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{"algorithm", "nodes", "clusters", "noise", "skipped", "duration_ms"}
+	row := make([]interface{}, len(columns))
+	for i, column := range columns {
+		row[i] = summary[column]
+	}
+	return &ExecuteResult{Columns: columns, Rows: [][]interface{}{row}}, nil
+}
+
+// CALL nornicdb.memory.append(session, content[, properties]).
+func (e *StorageExecutor) callNornicDbMemoryAppend(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	const proc = "nornicdb.memory.append"
+	if e.agentMemory == nil {
+		return nil, fmt.Errorf("agent memory is not available")
+	}
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("%s requires (session, content[, properties])", proc)
+	}
+	session, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: session must be a string, got %v", proc, args[0])
+	}
+	content, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: content must be a string, got %v", proc, args[1])
+	}
+	var properties map[string]interface{}
+	if len(args) == 3 && args[2] != nil {
+		if properties, ok = args[2].(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("%s: properties must be a map, got %v", proc, args[2])
+		}
+	}
+	memory, err := e.agentMemory.AppendEpisode(ctx, session, content, properties)
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{"id", "session", "created_at"}
+	row := make([]interface{}, len(columns))
+	for i, column := range columns {
+		row[i] = memory[column]
+	}
+	return &ExecuteResult{Columns: columns, Rows: [][]interface{}{row}}, nil
+}
+
+// CALL nornicdb.memory.consolidate([{session, minAgeSeconds, minEpisodes, maxEpisodes}]).
+func (e *StorageExecutor) callNornicDbMemoryConsolidate(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	const proc = "nornicdb.memory.consolidate"
+	if e.agentMemory == nil {
+		return nil, fmt.Errorf("agent memory is not available")
+	}
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 1 {
+		return nil, fmt.Errorf("%s requires ([config])", proc)
+	}
+	var options MemoryConsolidationOptions
+	if len(args) == 1 && args[0] != nil {
+		config, ok := args[0].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: config must be a map, got %v", proc, args[0])
+		}
+		for key, value := range config {
+			switch key {
+			case "session":
+				if options.Session, ok = value.(string); !ok {
+					return nil, fmt.Errorf("%s: session must be a string, got %v", proc, value)
+				}
+			case "minAgeSeconds":
+				seconds, ok := toFloat64(value)
+				if !ok || seconds < 0 {
+					return nil, fmt.Errorf("%s: minAgeSeconds must be a non-negative number, got %v", proc, value)
+				}
+				options.MinAge = time.Duration(seconds * float64(time.Second))
+			case "minEpisodes":
+				options.MinEpisodes = int(toInt64(value))
+			case "maxEpisodes":
+				options.MaxEpisodes = int(toInt64(value))
+			default:
+				return nil, fmt.Errorf("%s: unknown config key %q (use session, minAgeSeconds, minEpisodes or maxEpisodes)", proc, key)
+			}
+		}
+	}
+	summary, err := e.agentMemory.ConsolidateMemories(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{"sessions", "episodes", "memories", "rejected", "failed", "duration_ms"}
+	row := make([]interface{}, len(columns))
+	for i, column := range columns {
+		row[i] = summary[column]
+	}
+	return &ExecuteResult{Columns: columns, Rows: [][]interface{}{row}}, nil
+}
+
+// CALL nornicdb.memory.retrieve(query[, {session, limit}]).
+func (e *StorageExecutor) callNornicDbMemoryRetrieve(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	const proc = "nornicdb.memory.retrieve"
+	if e.agentMemory == nil {
+		return nil, fmt.Errorf("agent memory is not available")
+	}
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("%s requires (query[, config])", proc)
+	}
+	query, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: query must be a string, got %v", proc, args[0])
+	}
+	var options MemoryRetrievalOptions
+	if len(args) == 2 && args[1] != nil {
+		config, ok := args[1].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: config must be a map, got %v", proc, args[1])
+		}
+		for key, value := range config {
+			switch key {
+			case "session":
+				if options.Session, ok = value.(string); !ok {
+					return nil, fmt.Errorf("%s: session must be a string, got %v", proc, value)
+				}
+			case "limit":
+				options.Limit = int(toInt64(value))
+			default:
+				return nil, fmt.Errorf("%s: unknown config key %q (use session or limit)", proc, key)
+			}
+		}
+	}
+	hits, err := e.agentMemory.RetrieveMemories(ctx, query, options)
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{"id", "tier", "title", "content", "session", "score", "via"}
+	rows := make([][]interface{}, len(hits))
+	for i, hit := range hits {
+		row := make([]interface{}, len(columns))
+		for j, column := range columns {
+			row[j] = hit[column]
+		}
+		rows[i] = row
+	}
+	return &ExecuteResult{Columns: columns, Rows: rows}, nil
+}
+
+// callNornicDbQCReport implements CALL nornicdb.qc.report().
+func (e *StorageExecutor) callNornicDbQCReport() (*ExecuteResult, error) {
+	if e.qcEvaluator == nil {
+		return nil, fmt.Errorf("Heimdall QC is not available")
+	}
+	report, err := e.qcEvaluator.QCReport()
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{"dimension", "key", "pending", "outcomes", "true_positives", "false_positives",
+		"false_negatives", "true_negatives", "precision", "recall"}
+	rows := make([][]interface{}, len(report))
+	for i, entry := range report {
+		row := make([]interface{}, len(columns))
+		for j, column := range columns {
+			row[j] = entry[column]
+		}
+		rows[i] = row
+	}
+	return &ExecuteResult{Columns: columns, Rows: rows}, nil
+}
+
 // Neo4j schema procedures
 
 func (e *StorageExecutor) callDbSchemaVisualization() (*ExecuteResult, error) {
@@ -844,12 +1159,40 @@ func (e *StorageExecutor) callDbmsProcedures() (*ExecuteResult, error) {
 		{"db.schema.visualization", "Visualizes the database schema", "READ"},
 		{"db.schema.nodeProperties", "Lists node properties by label", "READ"},
 		{"db.schema.relProperties", "Lists relationship properties by type", "READ"},
+		{"db.tlp.explain", "Explains how an inferred relationship was created", "READ"},
+		{"db.lint", "Reports performance and compatibility issues in a query", "READ"},
+		{"db.prepare", "Prepares a query for repeated execution and returns its handle", "READ"},
+		{"db.prepared.execute", "Executes a prepared query with the request parameters", "WRITE"},
+		{"db.prepared.drop", "Releases a prepared query", "READ"},
+		{"db.prepared.list", "Lists prepared queries", "READ"},
+		{"db.query.save", "Saves a named, parameterized query as a new version", "WRITE"},
+		{"db.query.run", "Runs a stored query by name with parameters", "WRITE"},
+		{"db.query.list", "Lists the stored queries the caller may run", "READ"},
+		{"db.query.history", "Lists the versions of a stored query", "READ"},
+		{"db.query.drop", "Deletes all versions of a stored query", "WRITE"},
+		{"db.sample.nodes", "Samples nodes with a label uniformly or by random walks", "READ"},
+		{"db.sample.subgraph", "Samples the neighbourhood of a node by random walks", "READ"},
 		{"dbms.components", "Lists database components", "DBMS"},
 		{"dbms.procedures", "Lists available procedures", "DBMS"},
+		{"dbms.listQueries", "Lists running queries with elapsed time, rows and memory", "DBMS"},
+		{"dbms.killQuery", "Kills a running query", "DBMS"},
+		{"dbms.setConfig", "Changes a dynamic setting at runtime", "DBMS"},
 		{"dbms.functions", "Lists available functions", "DBMS"},
 		{"nornicdb.version", "Returns NornicDB version", "READ"},
 		{"nornicdb.stats", "Returns database statistics", "READ"},
+		{"nornicdb.workload", "Returns rolling query workload statistics: arrival rates, result sizes and query shapes", "DBMS"},
+		{"nornicdb.workload.recommend", "Suggests query cache, pool and index changes for the observed workload", "DBMS"},
+		{"nornicdb.schema.registry", "Lists the property schemas writes are checked against", "DBMS"},
+		{"nornicdb.schema.define", "Defines the expected properties of a label or relationship type", "DBMS"},
+		{"nornicdb.schema.drop", "Removes the property schema of a label or relationship type", "DBMS"},
+		{"nornicdb.schema.mode", "Returns or sets the property schema mode: off, warn or strict", "DBMS"},
 		{"nornicdb.decay.info", "Returns memory decay configuration", "READ"},
+		{"nornicdb.gpu.probe", "Lists GPU backends, devices and why unavailable backends cannot be used", "DBMS"},
+		{"nornicdb.cluster.embeddings", "Clusters node embeddings with k-means or HDBSCAN and stores the clusters as :Cluster nodes", "WRITE"},
+		{"nornicdb.memory.append", "Appends an episodic memory to an agent session", "WRITE"},
+		{"nornicdb.memory.consolidate", "Consolidates the episodes of agent sessions into semantic memories with the SLM", "WRITE"},
+		{"nornicdb.memory.retrieve", "Retrieves agent memories by hybrid search, with the memories they were consolidated from or into", "READ"},
+		{"nornicdb.qc.report", "Reports the precision and recall of Heimdall QC shadow decisions per method and prompt version", "DBMS"},
 	}
 
 	return &ExecuteResult{
@@ -866,6 +1209,7 @@ func (e *StorageExecutor) callDbmsFunctions() (*ExecuteResult, error) {
 		{"min", "Returns minimum value", "Aggregating"},
 		{"max", "Returns maximum value", "Aggregating"},
 		{"collect", "Collects values into a list", "Aggregating"},
+		{"approxCountDistinct", "Estimates the number of distinct values", "Aggregating"},
 		{"id", "Returns internal ID", "Scalar"},
 		{"labels", "Returns labels of a node", "Scalar"},
 		{"type", "Returns type of relationship", "Scalar"},
@@ -910,7 +1254,10 @@ func (e *StorageExecutor) callDbmsFunctions() (*ExecuteResult, error) {
 // Returns:
 //   - node: The matched node with all properties
 //   - score: Cosine similarity score (0.0 to 1.0)
-func (e *StorageExecutor) callDbIndexVectorQueryNodes(cypher string) (*ExecuteResult, error) {
+//
+// Cosine indexes are ranked on the GPU when SetVectorSearchAccelerator was
+// called; if the accelerator fails, the query is scored on CPU.
+func (e *StorageExecutor) callDbIndexVectorQueryNodes(ctx context.Context, cypher string) (*ExecuteResult, error) {
 	// Parse parameters from: CALL db.index.vector.queryNodes('indexName', k, queryInput)
 	// queryInput can be: [0.1, 0.2, ...] OR 'search text' OR $param
 	indexName, k, input, err := e.parseVectorQueryParams(cypher)
@@ -929,7 +1276,6 @@ func (e *StorageExecutor) callDbIndexVectorQueryNodes(cypher string) (*ExecuteRe
 		if e.embedder == nil {
 			return nil, fmt.Errorf("string query provided but no embedder configured; use vector array or configure embedding service")
 		}
-		ctx := context.Background()
 		embedded, embedErr := e.embedder.Embed(ctx, input.stringQuery)
 		if embedErr != nil {
 			return nil, fmt.Errorf("failed to embed query '%s': %w", input.stringQuery, embedErr)
@@ -964,18 +1310,24 @@ func (e *StorageExecutor) callDbIndexVectorQueryNodes(cypher string) (*ExecuteRe
 		}
 	}
 
-	// Get all nodes and filter to those with embeddings
-	nodes, err := e.storage.AllNodes()
+	// Route to the index's label instead of scanning every node
+	var nodes []*storage.Node
+	if targetLabel != "" {
+		nodes, err = e.storage.GetNodesByLabel(targetLabel)
+	} else {
+		nodes, err = e.storage.AllNodes()
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Collect nodes with embeddings and calculate similarities
+	// Collect nodes with embeddings
 	type scoredNode struct {
 		node  *storage.Node
 		score float64
 	}
-	var scoredNodes []scoredNode
+	var candidates []*storage.Node
+	var embeddings [][]float32
 
 	for _, node := range nodes {
 		// Check label filter if index specifies one
@@ -1012,18 +1364,46 @@ func (e *StorageExecutor) callDbIndexVectorQueryNodes(cypher string) (*ExecuteRe
 			continue
 		}
 
-		// Calculate similarity
-		var score float64
-		switch similarityFunc {
-		case "euclidean":
-			score = vector.EuclideanSimilarity(queryVector, nodeEmbedding)
-		case "dot":
-			score = vector.DotProduct(queryVector, nodeEmbedding)
-		default: // cosine
-			score = vector.CosineSimilarity(queryVector, nodeEmbedding)
+		candidates = append(candidates, node)
+		embeddings = append(embeddings, nodeEmbedding)
+	}
+
+	// Rank cosine indexes on the GPU when one is configured
+	var scoredNodes []scoredNode
+	gpuRanked := false
+	if e.vectorAccelerator != nil && len(candidates) > 0 && similarityFunc != "euclidean" && similarityFunc != "dot" {
+		limit := k
+		if limit <= 0 || limit > len(candidates) {
+			limit = len(candidates)
 		}
+		ids := make([]string, len(candidates))
+		for i, node := range candidates {
+			ids[i] = string(node.ID)
+		}
+		positions, scores, gpuErr := e.vectorAccelerator.SearchVectors(ctx, indexName, ids, embeddings, queryVector, limit)
+		if gpuErr == nil {
+			gpuRanked = true
+			for i, pos := range positions {
+				scoredNodes = append(scoredNodes, scoredNode{node: candidates[pos], score: scores[i]})
+			}
+		}
+	}
+
+	// Otherwise calculate similarities on CPU
+	if !gpuRanked {
+		for i, node := range candidates {
+			var score float64
+			switch similarityFunc {
+			case "euclidean":
+				score = vector.EuclideanSimilarity(queryVector, embeddings[i])
+			case "dot":
+				score = vector.DotProduct(queryVector, embeddings[i])
+			default: // cosine
+				score = vector.CosineSimilarity(queryVector, embeddings[i])
+			}
 
-		scoredNodes = append(scoredNodes, scoredNode{node: node, score: score})
+			scoredNodes = append(scoredNodes, scoredNode{node: node, score: score})
+		}
 	}
 
 	// Sort by score descending
@@ -2249,14 +2629,16 @@ func (e *StorageExecutor) callDbmsInfo() (*ExecuteResult, error) {
 
 // callDbmsListConfig lists DBMS configuration - Neo4j dbms.listConfig()
 func (e *StorageExecutor) callDbmsListConfig() (*ExecuteResult, error) {
-	return &ExecuteResult{
+	result := &ExecuteResult{
 		Columns: []string{"name", "description", "value", "dynamic"},
 		Rows: [][]interface{}{
 			{"nornicdb.version", "NornicDB version", "1.0.0", false},
 			{"nornicdb.bolt.enabled", "Bolt protocol enabled", true, false},
 			{"nornicdb.http.enabled", "HTTP API enabled", true, false},
 		},
-	}, nil
+	}
+	result.Rows = append(result.Rows, e.dynamicConfigRows()...)
+	return result, nil
 }
 
 // callDbmsClientConfig lists client-visible configuration - Neo4j dbms.clientConfig()
@@ -2390,6 +2772,9 @@ func (e *StorageExecutor) callDbIndexVectorCreateNodeIndex(ctx context.Context,
 	if err != nil {
 		return nil, fmt.Errorf("failed to create vector index: %w", err)
 	}
+	if err := e.registerVectorIndex(ctx, indexName); err != nil {
+		return nil, err
+	}
 
 	return &ExecuteResult{
 		Columns: []string{"name", "label", "property", "dimension", "similarityFunction"},
@@ -2571,7 +2956,8 @@ func (e *StorageExecutor) callDbIndexVectorDrop(cypher string) (*ExecuteResult,
 
 	indexName := strings.Trim(strings.TrimSpace(cypher[idx+argsStart+1:idx+argsEnd]), "'\"")
 
-	// Drop vector index - NornicDB manages indexes internally, so this is a no-op but returns success
+	// Dropping a missing index still succeeds (Neo4j-compatible IF EXISTS semantics)
+	e.dropVectorIndex(indexName)
 	return &ExecuteResult{
 		Columns: []string{"name", "dropped"},
 		Rows:    [][]interface{}{{indexName, true}},
@@ -2824,9 +3210,32 @@ func (e *StorageExecutor) callTxSetMetadata(cypher string) (*ExecuteResult, erro
 
 // callDbAwaitIndex waits for a specific index to come online - Neo4j db.awaitIndex()
 // Syntax: CALL db.awaitIndex(indexName, timeOutSeconds)
-func (e *StorageExecutor) callDbAwaitIndex(cypher string) (*ExecuteResult, error) {
-	// NornicDB indexes are always online (no background building)
-	// This is a no-op for compatibility
+//
+// Only vector indexes are populated in the background; other and unknown
+// indexes are online at once. The timeout defaults to 300 seconds.
+func (e *StorageExecutor) callDbAwaitIndex(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	const proc = "db.awaitIndex"
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("%s requires (indexName[, timeOutSeconds])", proc)
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: indexName must be a string, got %v", proc, args[0])
+	}
+	ctx, cancel, err := awaitIndexTimeout(ctx, proc, args[1:])
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	if _, isVector := e.storage.GetSchema().GetVectorIndex(name); isVector {
+		if err := e.awaitVectorIndex(ctx, name); err != nil {
+			return nil, err
+		}
+	}
 	return &ExecuteResult{
 		Columns: []string{"status"},
 		Rows: [][]interface{}{
@@ -2837,9 +3246,25 @@ func (e *StorageExecutor) callDbAwaitIndex(cypher string) (*ExecuteResult, error
 
 // callDbAwaitIndexes waits for all indexes to come online - Neo4j db.awaitIndexes()
 // Syntax: CALL db.awaitIndexes(timeOutSeconds)
-func (e *StorageExecutor) callDbAwaitIndexes(cypher string) (*ExecuteResult, error) {
-	// NornicDB indexes are always online (no background building)
-	// This is a no-op for compatibility
+func (e *StorageExecutor) callDbAwaitIndexes(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	const proc = "db.awaitIndexes"
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 1 {
+		return nil, fmt.Errorf("%s requires ([timeOutSeconds])", proc)
+	}
+	ctx, cancel, err := awaitIndexTimeout(ctx, proc, args)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	for _, idx := range e.storage.GetSchema().GetVectorIndexes() {
+		if err := e.awaitVectorIndex(ctx, idx.Name); err != nil {
+			return nil, err
+		}
+	}
 	return &ExecuteResult{
 		Columns: []string{"status"},
 		Rows: [][]interface{}{
@@ -2848,6 +3273,42 @@ func (e *StorageExecutor) callDbAwaitIndexes(cypher string) (*ExecuteResult, err
 	}, nil
 }
 
+// awaitIndexTimeout bounds ctx by the optional timeOutSeconds argument of
+// db.awaitIndex and db.awaitIndexes.
+func awaitIndexTimeout(ctx context.Context, proc string, args []interface{}) (context.Context, context.CancelFunc, error) {
+	seconds := int64(300)
+	if len(args) > 0 {
+		switch n := args[0].(type) {
+		case int64:
+			seconds = n
+		case float64:
+			seconds = int64(n)
+		default:
+			seconds = -1
+		}
+		if seconds < 0 {
+			return nil, nil, fmt.Errorf("%s: timeOutSeconds must be a non-negative number, got %v", proc, args[0])
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(seconds)*time.Second)
+	return ctx, cancel, nil
+}
+
+// awaitVectorIndex waits for a vector index that is being populated.
+func (e *StorageExecutor) awaitVectorIndex(ctx context.Context, name string) error {
+	population, ok := e.vectorIndexes.(VectorIndexPopulation)
+	if !ok {
+		return nil
+	}
+	if err := population.AwaitVectorIndex(ctx, name); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("index %s is not online after the timeout: %w", name, err)
+		}
+		return err
+	}
+	return nil
+}
+
 // callDbResampleIndex forces index statistics to be recalculated - Neo4j db.resampleIndex()
 // Syntax: CALL db.resampleIndex(indexName)
 func (e *StorageExecutor) callDbResampleIndex(cypher string) (*ExecuteResult, error) {
@@ -2866,12 +3327,17 @@ func (e *StorageExecutor) callDbResampleIndex(cypher string) (*ExecuteResult, er
 // ========================================
 
 // callDbStatsClear clears collected query statistics - Neo4j db.stats.clear()
-func (e *StorageExecutor) callDbStatsClear() (*ExecuteResult, error) {
-	// Clear any cached query stats
+// Syntax: CALL db.stats.clear(section)
+func (e *StorageExecutor) callDbStatsClear(cypher string) (*ExecuteResult, error) {
+	section, err := dbStatsSection(cypher, "db.stats.clear", true)
+	if err != nil {
+		return nil, err
+	}
+	e.workload.Clear()
 	return &ExecuteResult{
 		Columns: []string{"section", "data"},
 		Rows: [][]interface{}{
-			{"QUERIES", map[string]interface{}{"cleared": true}},
+			{section, map[string]interface{}{"cleared": true}},
 		},
 	}, nil
 }
@@ -2879,78 +3345,157 @@ func (e *StorageExecutor) callDbStatsClear() (*ExecuteResult, error) {
 // callDbStatsCollect starts collecting query statistics - Neo4j db.stats.collect()
 // Syntax: CALL db.stats.collect(section, config)
 func (e *StorageExecutor) callDbStatsCollect(cypher string) (*ExecuteResult, error) {
+	section, err := dbStatsSection(cypher, "db.stats.collect", true)
+	if err != nil {
+		return nil, err
+	}
+	e.workload.SetCollecting(true)
 	return &ExecuteResult{
 		Columns: []string{"section", "success", "message"},
 		Rows: [][]interface{}{
-			{"QUERIES", true, "Query collection started"},
+			{section, true, "Query collection started"},
 		},
 	}, nil
 }
 
 // callDbStatsRetrieve retrieves collected statistics - Neo4j db.stats.retrieve()
 // Syntax: CALL db.stats.retrieve(section)
+//
+// QUERIES returns the workload summary with its query shapes; GRAPH COUNTS
+// returns node and relationship counts.
 func (e *StorageExecutor) callDbStatsRetrieve(cypher string) (*ExecuteResult, error) {
-	// Return basic query statistics
+	section, err := dbStatsSection(cypher, "db.stats.retrieve", false)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	switch section {
+	case "QUERIES":
+		data = e.dbStatsQueries(true)
+	case "GRAPH COUNTS":
+		data = e.dbStatsGraphCounts()
+	default:
+		return nil, fmt.Errorf("unknown statistics section %q (expected QUERIES or GRAPH COUNTS)", section)
+	}
 	return &ExecuteResult{
 		Columns: []string{"section", "data"},
 		Rows: [][]interface{}{
-			{"QUERIES", map[string]interface{}{
-				"totalQueries":   0,
-				"cachedQueries":  0,
-				"avgExecutionMs": 0,
-			}},
+			{section, data},
 		},
 	}, nil
 }
 
 // callDbStatsRetrieveAllAnTheStats retrieves all statistics - Neo4j db.stats.retrieveAllAnTheStats()
 func (e *StorageExecutor) callDbStatsRetrieveAllAnTheStats() (*ExecuteResult, error) {
-	nodeCount := 0
-	edgeCount := 0
-
-	if nodes, err := e.storage.AllNodes(); err == nil {
-		nodeCount = len(nodes)
-	}
-	if edges, err := e.storage.AllEdges(); err == nil {
-		edgeCount = len(edges)
-	}
-
 	return &ExecuteResult{
 		Columns: []string{"section", "data"},
 		Rows: [][]interface{}{
-			{"GRAPH COUNTS", map[string]interface{}{
-				"nodeCount":         nodeCount,
-				"relationshipCount": edgeCount,
-			}},
-			{"QUERIES", map[string]interface{}{
-				"totalQueries":   0,
-				"cachedQueries":  0,
-				"avgExecutionMs": 0,
-			}},
+			{"GRAPH COUNTS", e.dbStatsGraphCounts()},
+			{"QUERIES", e.dbStatsQueries(false)},
 		},
 	}, nil
 }
 
 // callDbStatsStatus returns statistics collection status - Neo4j db.stats.status()
 func (e *StorageExecutor) callDbStatsStatus() (*ExecuteResult, error) {
+	snap := e.workload.Snapshot()
+	status := "idle"
+	if snap.Collecting {
+		status = "collecting"
+	}
 	return &ExecuteResult{
 		Columns: []string{"section", "status", "message"},
 		Rows: [][]interface{}{
-			{"QUERIES", "idle", "Statistics collection is available"},
+			{"QUERIES", status, fmt.Sprintf("%d queries and %d query shapes in the last %s",
+				snap.Queries, len(snap.Shapes), snap.Window)},
 		},
 	}, nil
 }
 
 // callDbStatsStop stops statistics collection - Neo4j db.stats.stop()
-func (e *StorageExecutor) callDbStatsStop() (*ExecuteResult, error) {
+// Syntax: CALL db.stats.stop(section)
+func (e *StorageExecutor) callDbStatsStop(cypher string) (*ExecuteResult, error) {
+	section, err := dbStatsSection(cypher, "db.stats.stop", true)
+	if err != nil {
+		return nil, err
+	}
+	e.workload.SetCollecting(false)
 	return &ExecuteResult{
 		Columns: []string{"section", "success", "message"},
 		Rows: [][]interface{}{
-			{"QUERIES", true, "Statistics collection stopped"},
+			{section, true, "Statistics collection stopped"},
 		},
 	}, nil
 }
 
+// dbStatsSection returns the upper-cased section argument of a db.stats
+// procedure, QUERIES when omitted. Only QUERIES can be collected.
+func dbStatsSection(cypher, proc string, collectable bool) (string, error) {
+	section, err := procedureStringArg(cypher, proc, "a section")
+	if err != nil {
+		return "QUERIES", nil
+	}
+	section = strings.ToUpper(strings.TrimSpace(section))
+	if collectable && section != "QUERIES" {
+		return "", fmt.Errorf("unknown statistics section %q (only QUERIES is collected)", section)
+	}
+	return section, nil
+}
+
+// dbStatsQueries returns the QUERIES section of db.stats, with the per-shape
+// aggregates when withShapes is set.
+func (e *StorageExecutor) dbStatsQueries(withShapes bool) map[string]interface{} {
+	snap := e.workload.Snapshot()
+	var totalMs float64
+	var measured int64
+	for _, shape := range snap.Shapes {
+		totalMs += shape.AvgMs * float64(shape.Samples)
+		measured += shape.Samples
+	}
+	avgMs := 0.0
+	if measured > 0 {
+		avgMs = totalMs / float64(measured)
+	}
+	var cached int64
+	if e.cache != nil {
+		cached, _, _, _, _ = e.cache.Stats()
+	}
+
+	data := map[string]interface{}{
+		"totalQueries":         snap.Queries,
+		"readQueries":          snap.Reads,
+		"writeQueries":         snap.Writes,
+		"failedQueries":        snap.Errors,
+		"cachedQueries":        cached,
+		"avgExecutionMs":       avgMs,
+		"queriesPerSecond":     snap.QueriesPerSecond,
+		"peakQueriesPerMinute": snap.PeakQueriesPerMinute,
+		"peakConcurrency":      int64(snap.PeakConcurrency),
+		"rowsP50":              snap.RowsP50,
+		"rowsP95":              snap.RowsP95,
+		"rowsMax":              snap.RowsMax,
+		"queryShapes":          int64(len(snap.Shapes)),
+	}
+	if withShapes {
+		shapes := make([]interface{}, 0, len(snap.Shapes))
+		for _, shape := range snap.Shapes {
+			shapes = append(shapes, workloadShapeMap(shape))
+		}
+		data["queries"] = shapes
+	}
+	return data
+}
+
+// dbStatsGraphCounts returns the GRAPH COUNTS section of db.stats.
+func (e *StorageExecutor) dbStatsGraphCounts() map[string]interface{} {
+	nodeCount, _ := e.storage.NodeCount()
+	edgeCount, _ := e.storage.EdgeCount()
+	return map[string]interface{}{
+		"nodeCount":         nodeCount,
+		"relationshipCount": edgeCount,
+	}
+}
+
 // callDbClearQueryCaches clears all query caches - Neo4j db.clearQueryCaches()
 func (e *StorageExecutor) callDbClearQueryCaches() (*ExecuteResult, error) {
 	// If there's a query cache, clear it
@@ -927,7 +927,7 @@ func (e *StorageExecutor) executeCompoundMatchOptionalMatch(ctx context.Context,
 	var initialNodes []*storage.Node
 	var err error
 	if len(nodePattern.labels) > 0 {
-		initialNodes, err = e.storage.GetNodesByLabel(nodePattern.labels[0])
+		initialNodes, err = e.nodesByLabel(ctx, nodePattern.labels[0], nodePattern.properties, nodePattern.variable, whereClause)
 	} else {
 		initialNodes, err = e.storage.AllNodes()
 	}
@@ -955,7 +955,7 @@ func (e *StorageExecutor) executeCompoundMatchOptionalMatch(ctx context.Context,
 
 	// Apply WHERE clause filtering if present
 	if whereClause != "" {
-		initialNodes = e.filterNodes(initialNodes, nodePattern.variable, whereClause)
+		initialNodes = e.filterNodes(ctx, initialNodes, nodePattern.variable, whereClause)
 	}
 
 	// Parse the OPTIONAL MATCH relationship pattern
@@ -6,6 +6,7 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/orneryd/nornicdb/pkg/math/vector"
 	"github.com/orneryd/nornicdb/pkg/storage"
@@ -417,6 +418,219 @@ func TestCallNornicDbDecayInfo(t *testing.T) {
 	}
 }
 
+type fakeGPUDiagnostics struct{}
+
+func (fakeGPUDiagnostics) ProbeGPU() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"backend": "cuda", "compiled": true, "available": true, "reason": "", "devices": []interface{}{
+			map[string]interface{}{"name": "RTX 4090", "compute_capability": "8.9"},
+		}},
+		{"backend": "vulkan", "compiled": false, "available": false, "reason": "binary built without Vulkan support"},
+	}
+}
+
+func TestCallNornicDbGpuProbe(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	e := NewStorageExecutor(store)
+	ctx := context.Background()
+
+	if _, err := e.Execute(ctx, "CALL nornicdb.gpu.probe()", nil); err == nil {
+		t.Error("Expected error without GPU diagnostics")
+	}
+
+	e.SetGPUDiagnostics(fakeGPUDiagnostics{})
+	result, err := e.Execute(ctx, "CALL nornicdb.gpu.probe()", nil)
+	if err != nil {
+		t.Fatalf("CALL nornicdb.gpu.probe() failed: %v", err)
+	}
+	if len(result.Rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(result.Rows))
+	}
+	if result.Rows[0][0] != "cuda" || result.Rows[0][2] != true {
+		t.Errorf("Unexpected cuda row: %v", result.Rows[0])
+	}
+	if devices, ok := result.Rows[0][4].([]interface{}); !ok || len(devices) != 1 {
+		t.Errorf("Expected 1 cuda device, got %v", result.Rows[0][4])
+	}
+	if result.Rows[1][3] != "binary built without Vulkan support" {
+		t.Errorf("Expected vulkan reason, got %v", result.Rows[1][3])
+	}
+	if devices, ok := result.Rows[1][4].([]interface{}); !ok || len(devices) != 0 {
+		t.Errorf("Expected empty device list for vulkan, got %v", result.Rows[1][4])
+	}
+}
+
+type fakeEmbeddingClusterer struct {
+	options EmbeddingClusterOptions
+}
+
+func (f *fakeEmbeddingClusterer) ClusterEmbeddings(ctx context.Context, options EmbeddingClusterOptions) (map[string]interface{}, error) {
+	f.options = options
+	return map[string]interface{}{
+		"algorithm": options.Algorithm, "nodes": int64(40), "clusters": int64(3),
+		"noise": int64(2), "skipped": int64(0), "duration_ms": int64(12),
+	}, nil
+}
+
+func TestCallNornicDbClusterEmbeddings(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	e := NewStorageExecutor(store)
+	ctx := context.Background()
+
+	if _, err := e.Execute(ctx, "CALL nornicdb.cluster.embeddings('kmeans')", nil); err == nil {
+		t.Error("Expected error without a clusterer")
+	}
+
+	clusterer := &fakeEmbeddingClusterer{}
+	e.SetEmbeddingClusterer(clusterer)
+	result, err := e.Execute(ctx, "CALL nornicdb.cluster.embeddings('hdbscan', {minClusterSize: 8, label: 'Memory'})", nil)
+	if err != nil {
+		t.Fatalf("CALL nornicdb.cluster.embeddings() failed: %v", err)
+	}
+	want := EmbeddingClusterOptions{Algorithm: "hdbscan", MinClusterSize: 8, Label: "Memory"}
+	if clusterer.options != want {
+		t.Errorf("Expected options %+v, got %+v", want, clusterer.options)
+	}
+	if len(result.Rows) != 1 || result.Columns[2] != "clusters" || result.Rows[0][2] != int64(3) || result.Rows[0][3] != int64(2) {
+		t.Errorf("Unexpected result: %v %v", result.Columns, result.Rows)
+	}
+
+	if _, err := e.Execute(ctx, "CALL nornicdb.cluster.embeddings('kmeans', {k: 12})", nil); err != nil || clusterer.options.K != 12 {
+		t.Errorf("Expected k=12, got %+v (%v)", clusterer.options, err)
+	}
+	for _, q := range []string{
+		"CALL nornicdb.cluster.embeddings()",
+		"CALL nornicdb.cluster.embeddings(3)",
+		"CALL nornicdb.cluster.embeddings('kmeans', {clusters: 3})",
+		"CALL nornicdb.cluster.embeddings('kmeans', 'Memory')",
+	} {
+		if _, err := e.Execute(ctx, q, nil); err == nil {
+			t.Errorf("Expected error for %s", q)
+		}
+	}
+}
+
+type fakeAgentMemory struct {
+	session, content string
+	properties       map[string]interface{}
+	consolidation    MemoryConsolidationOptions
+	query            string
+	retrieval        MemoryRetrievalOptions
+}
+
+func (f *fakeAgentMemory) AppendEpisode(ctx context.Context, sessionID, content string, properties map[string]interface{}) (map[string]interface{}, error) {
+	f.session, f.content, f.properties = sessionID, content, properties
+	return map[string]interface{}{"id": "mem-1", "session": sessionID, "created_at": "2026-01-01T00:00:00Z"}, nil
+}
+
+func (f *fakeAgentMemory) ConsolidateMemories(ctx context.Context, options MemoryConsolidationOptions) (map[string]interface{}, error) {
+	f.consolidation = options
+	return map[string]interface{}{
+		"sessions": int64(2), "episodes": int64(9), "memories": int64(3),
+		"rejected": int64(1), "failed": int64(0), "duration_ms": int64(40),
+	}, nil
+}
+
+func (f *fakeAgentMemory) RetrieveMemories(ctx context.Context, query string, options MemoryRetrievalOptions) ([]map[string]interface{}, error) {
+	f.query, f.retrieval = query, options
+	return []map[string]interface{}{
+		{"id": "mem-1", "tier": "EPISODIC", "content": "likes tea", "session": "s1", "score": 0.8, "via": ""},
+		{"id": "mem-2", "tier": "SEMANTIC", "title": "Drinks", "content": "The user likes tea.", "session": "s1", "score": 0.4, "via": "mem-1"},
+	}, nil
+}
+
+func TestCallNornicDbMemory(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	e := NewStorageExecutor(store)
+	ctx := context.Background()
+
+	if _, err := e.Execute(ctx, "CALL nornicdb.memory.retrieve('tea')", nil); err == nil {
+		t.Error("Expected error without agent memory")
+	}
+
+	memory := &fakeAgentMemory{}
+	e.SetAgentMemory(memory)
+
+	result, err := e.Execute(ctx, "CALL nornicdb.memory.append($session, 'likes tea', {agent: 'chat'})", map[string]interface{}{"session": "s1"})
+	if err != nil {
+		t.Fatalf("CALL nornicdb.memory.append() failed: %v", err)
+	}
+	if memory.session != "s1" || memory.content != "likes tea" || memory.properties["agent"] != "chat" {
+		t.Errorf("Unexpected append: %+v", memory)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != "mem-1" || result.Rows[0][1] != "s1" {
+		t.Errorf("Unexpected result: %v %v", result.Columns, result.Rows)
+	}
+
+	result, err = e.Execute(ctx, "CALL nornicdb.memory.consolidate({session: 's1', minAgeSeconds: 90, maxEpisodes: 10})", nil)
+	if err != nil {
+		t.Fatalf("CALL nornicdb.memory.consolidate() failed: %v", err)
+	}
+	want := MemoryConsolidationOptions{Session: "s1", MinAge: 90 * time.Second, MaxEpisodes: 10}
+	if memory.consolidation != want {
+		t.Errorf("Expected options %+v, got %+v", want, memory.consolidation)
+	}
+	if len(result.Rows) != 1 || result.Columns[2] != "memories" || result.Rows[0][2] != int64(3) {
+		t.Errorf("Unexpected result: %v %v", result.Columns, result.Rows)
+	}
+	if _, err := e.Execute(ctx, "CALL nornicdb.memory.consolidate()", nil); err != nil || memory.consolidation != (MemoryConsolidationOptions{}) {
+		t.Errorf("Expected default options, got %+v (%v)", memory.consolidation, err)
+	}
+
+	result, err = e.Execute(ctx, "CALL nornicdb.memory.retrieve('tea', {session: 's1', limit: 5}) YIELD id, via RETURN id, via", nil)
+	if err != nil {
+		t.Fatalf("CALL nornicdb.memory.retrieve() failed: %v", err)
+	}
+	if memory.query != "tea" || memory.retrieval != (MemoryRetrievalOptions{Session: "s1", Limit: 5}) {
+		t.Errorf("Unexpected retrieval: %q %+v", memory.query, memory.retrieval)
+	}
+	if len(result.Rows) != 2 || result.Rows[1][0] != "mem-2" || result.Rows[1][1] != "mem-1" {
+		t.Errorf("Unexpected result: %v %v", result.Columns, result.Rows)
+	}
+
+	for _, q := range []string{
+		"CALL nornicdb.memory.append('s1')",
+		"CALL nornicdb.memory.append(1, 'x')",
+		"CALL nornicdb.memory.append('s1', 'x', 'y')",
+		"CALL nornicdb.memory.consolidate({sessions: 's1'})",
+		"CALL nornicdb.memory.consolidate({minAgeSeconds: -1})",
+		"CALL nornicdb.memory.retrieve()",
+		"CALL nornicdb.memory.retrieve('tea', {depth: 2})",
+	} {
+		if _, err := e.Execute(ctx, q, nil); err == nil {
+			t.Errorf("Expected error for %s", q)
+		}
+	}
+}
+
+type fakeQCEvaluator struct{}
+
+func (fakeQCEvaluator) QCReport() ([]map[string]interface{}, error) {
+	return []map[string]interface{}{
+		{"dimension": "method", "key": "similarity", "pending": int64(2), "outcomes": int64(4),
+			"true_positives": int64(3), "false_positives": int64(1), "precision": 0.75, "recall": 1.0},
+	}, nil
+}
+
+func TestCallNornicDbQCReport(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	e := NewStorageExecutor(store)
+	ctx := context.Background()
+
+	if _, err := e.Execute(ctx, "CALL nornicdb.qc.report()", nil); err == nil {
+		t.Error("Expected error without a QC evaluator")
+	}
+
+	e.SetQCEvaluator(fakeQCEvaluator{})
+	result, err := e.Execute(ctx, "CALL nornicdb.qc.report() YIELD key, precision, recall RETURN key, precision, recall", nil)
+	if err != nil {
+		t.Fatalf("CALL nornicdb.qc.report() failed: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != "similarity" || result.Rows[0][1] != 0.75 || result.Rows[0][2] != 1.0 {
+		t.Errorf("Unexpected result: %v %v", result.Columns, result.Rows)
+	}
+}
+
 func TestCallDbSchemaVisualization(t *testing.T) {
 	store := storage.NewMemoryEngine()
 	e := NewStorageExecutor(store)
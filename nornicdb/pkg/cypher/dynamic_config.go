@@ -0,0 +1,174 @@
+// Runtime configuration changes via CALL dbms.setConfig.
+//
+// Cache and pool sizes can be changed while the server runs, without a
+// restart: shrinking the query cache keeps its most recently used entries,
+// and pool changes apply to objects returned from then on. The current
+// values are listed by CALL dbms.listConfig() with dynamic set.
+//
+//	CALL dbms.setConfig('nornicdb.memory.query_cache.size', '5000')
+//	CALL dbms.setConfig('nornicdb.memory.pool.max_size', 4096)
+//
+// Changing a setting requires the admin permission. Changes are not
+// persisted; the environment configuration applies again after a restart.
+
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/cache"
+	"github.com/orneryd/nornicdb/pkg/pool"
+)
+
+// dynamicSetting is a configuration value that can change at runtime.
+type dynamicSetting struct {
+	description string
+	get         func(e *StorageExecutor) interface{}
+	set         func(e *StorageExecutor, value string) error
+}
+
+// dynamicSettings are the settings dbms.setConfig can change, by name.
+var dynamicSettings = map[string]dynamicSetting{
+	"nornicdb.memory.pool.enabled": {
+		description: "Reuse query result buffers from object pools",
+		get:         func(*StorageExecutor) interface{} { return pool.IsEnabled() },
+		set: func(_ *StorageExecutor, value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("expected true or false")
+			}
+			config := pool.Config()
+			config.Enabled = enabled
+			pool.Configure(config)
+			return nil
+		},
+	},
+	"nornicdb.memory.pool.max_size": {
+		description: "Largest buffer, in elements, returned to the object pools",
+		get:         func(*StorageExecutor) interface{} { return int64(pool.MaxSize()) },
+		set: func(_ *StorageExecutor, value string) error {
+			n, err := positiveSetting(value)
+			if err != nil {
+				return err
+			}
+			config := pool.Config()
+			config.MaxSize = n
+			pool.Configure(config)
+			return nil
+		},
+	},
+	"nornicdb.memory.query_cache.size": {
+		description: "Maximum cached query results and plans; shrinking keeps the most recently used",
+		get: func(e *StorageExecutor) interface{} {
+			if e.cache == nil {
+				return int64(cache.GlobalQueryCache().Stats().MaxSize)
+			}
+			return int64(e.cache.Capacity())
+		},
+		set: func(e *StorageExecutor, value string) error {
+			n, err := positiveSetting(value)
+			if err != nil {
+				return err
+			}
+			if e.cache != nil {
+				e.cache.Resize(n)
+			}
+			cache.ConfigureGlobalCache(n, cache.GlobalQueryCache().Stats().TTL)
+			return nil
+		},
+	},
+	"nornicdb.memory.query_cache.ttl": {
+		description: "How long cached query plans stay valid (0s = no expiry)",
+		get:         func(*StorageExecutor) interface{} { return cache.GlobalQueryCache().Stats().TTL.String() },
+		set: func(_ *StorageExecutor, value string) error {
+			ttl, err := time.ParseDuration(value)
+			if err != nil || ttl < 0 {
+				return fmt.Errorf("expected a duration such as 5m")
+			}
+			cache.ConfigureGlobalCache(cache.GlobalQueryCache().Stats().MaxSize, ttl)
+			return nil
+		},
+	},
+}
+
+// positiveSetting parses a size setting.
+func positiveSetting(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("expected a positive integer")
+	}
+	return n, nil
+}
+
+// SetConfig changes a dynamic setting at runtime and returns its previous
+// value. The value may be given as a string, as Neo4j's setConfigValue
+// takes it, or as a number or boolean. Callers need the admin permission.
+func (e *StorageExecutor) SetConfig(ctx context.Context, name string, value interface{}) (previous interface{}, err error) {
+	if p, ok := queryCaller(ctx); ok && !callerHas(p, auth.PermAdmin) {
+		return nil, fmt.Errorf("changing configuration requires the admin permission: %w", auth.ErrInsufficientRole)
+	}
+	setting, ok := dynamicSettings[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown or non-dynamic setting %q (dynamic settings: %s)", name, strings.Join(dynamicSettingNames(), ", "))
+	}
+	if value == nil {
+		return nil, fmt.Errorf("setting %s requires a value", name)
+	}
+
+	previous = setting.get(e)
+	if err := setting.set(e, fmt.Sprint(value)); err != nil {
+		return nil, fmt.Errorf("invalid value %v for %s: %w", value, name, err)
+	}
+	return previous, nil
+}
+
+// dynamicSettingNames returns the dynamic setting names in order.
+func dynamicSettingNames() []string {
+	names := make([]string, 0, len(dynamicSettings))
+	for name := range dynamicSettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dynamicConfigRows returns dbms.listConfig rows for the dynamic settings.
+func (e *StorageExecutor) dynamicConfigRows() [][]interface{} {
+	names := dynamicSettingNames()
+	rows := make([][]interface{}, len(names))
+	for i, name := range names {
+		setting := dynamicSettings[name]
+		rows[i] = []interface{}{name, setting.description, setting.get(e), true}
+	}
+	return rows
+}
+
+// callDbmsSetConfig implements dbms.setConfig(name, value), also available
+// under Neo4j's name dbms.setConfigValue.
+func (e *StorageExecutor) callDbmsSetConfig(ctx context.Context, cypher, proc string) (*ExecuteResult, error) {
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s requires a setting name and a value", proc)
+	}
+	name, ok := args[0].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("%s requires a setting name", proc)
+	}
+	previous, err := e.SetConfig(ctx, name, args[1])
+	if err != nil {
+		return nil, err
+	}
+	return &ExecuteResult{
+		Columns: []string{"name", "previous", "value"},
+		Rows:    [][]interface{}{{strings.ToLower(name), previous, dynamicSettings[strings.ToLower(name)].get(e)}},
+	}, nil
+}
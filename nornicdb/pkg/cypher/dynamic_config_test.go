@@ -0,0 +1,91 @@
+package cypher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/cache"
+	"github.com/orneryd/nornicdb/pkg/pool"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDbmsSetConfig(t *testing.T) {
+	origPool := pool.Config()
+	origCache := cache.GlobalQueryCache().Stats()
+	t.Cleanup(func() {
+		pool.Configure(origPool)
+		cache.ConfigureGlobalCache(origCache.MaxSize, origCache.TTL)
+	})
+
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+	ctx := context.Background()
+
+	t.Run("query cache size", func(t *testing.T) {
+		result, err := exec.Execute(ctx, `CALL dbms.setConfig('nornicdb.memory.query_cache.size', '250')`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"name", "previous", "value"}, result.Columns)
+		assert.Equal(t, [][]interface{}{{"nornicdb.memory.query_cache.size", int64(1000), int64(250)}}, result.Rows)
+		assert.Equal(t, 250, exec.cache.Capacity())
+		assert.Equal(t, 250, cache.GlobalQueryCache().Stats().MaxSize)
+	})
+
+	t.Run("query cache ttl", func(t *testing.T) {
+		_, err := exec.Execute(ctx, `CALL dbms.setConfig('nornicdb.memory.query_cache.ttl', '90s')`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 90*time.Second, cache.GlobalQueryCache().Stats().TTL)
+	})
+
+	t.Run("pool", func(t *testing.T) {
+		_, err := exec.Execute(ctx, `CALL dbms.setConfigValue('nornicdb.memory.pool.max_size', 4096)`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 4096, pool.MaxSize())
+
+		_, err = exec.Execute(ctx, `CALL dbms.setConfig('nornicdb.memory.pool.enabled', false)`, nil)
+		require.NoError(t, err)
+		assert.False(t, pool.IsEnabled())
+		assert.Equal(t, 4096, pool.MaxSize(), "other pool settings are kept")
+	})
+
+	t.Run("listConfig shows current values", func(t *testing.T) {
+		result, err := exec.Execute(ctx, `CALL dbms.listConfig()`, nil)
+		require.NoError(t, err)
+		values := map[string]interface{}{}
+		for _, row := range result.Rows {
+			if row[3] == true {
+				values[row[0].(string)] = row[2]
+			}
+		}
+		assert.Equal(t, map[string]interface{}{
+			"nornicdb.memory.pool.enabled":     false,
+			"nornicdb.memory.pool.max_size":    int64(4096),
+			"nornicdb.memory.query_cache.size": int64(250),
+			"nornicdb.memory.query_cache.ttl":  "1m30s",
+		}, values)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, q := range []string{
+			`CALL dbms.setConfig('nornicdb.version', '2')`,
+			`CALL dbms.setConfig('nornicdb.memory.query_cache.size', '0')`,
+			`CALL dbms.setConfig('nornicdb.memory.query_cache.ttl', 'soon')`,
+			`CALL dbms.setConfig('nornicdb.memory.pool.enabled', 'maybe')`,
+			`CALL dbms.setConfig('nornicdb.memory.pool.max_size')`,
+		} {
+			_, err := exec.Execute(ctx, q, nil)
+			assert.Error(t, err, q)
+		}
+		assert.Equal(t, 250, exec.cache.Capacity(), "failed changes leave settings alone")
+	})
+
+	t.Run("requires admin", func(t *testing.T) {
+		_, err := exec.Execute(asUser("ed", "editor"), `CALL dbms.setConfig('nornicdb.memory.query_cache.size', '10')`, nil)
+		assert.ErrorIs(t, err, auth.ErrInsufficientRole)
+		_, err = exec.Execute(asUser("root", "admin"), `CALL dbms.setConfig('nornicdb.memory.query_cache.size', '10')`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 10, exec.cache.Capacity())
+	})
+}
@@ -112,6 +112,7 @@ package cypher
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"regexp"
 	"sort"
 	"strconv"
@@ -120,6 +121,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/requestid"
 	"github.com/orneryd/nornicdb/pkg/storage"
 )
 
@@ -195,6 +198,27 @@ type StorageExecutor struct {
 	nodeLookupCache   map[string]*storage.Node
 	nodeLookupCacheMu sync.RWMutex
 
+	// Prepared statements by handle (see Prepare)
+	prepared   map[string]*PreparedStatement
+	preparedMu sync.RWMutex
+
+	// Running top-level queries by ID (see RunningQueries)
+	running     map[string]*RunningQuery
+	runningMu   sync.RWMutex
+	nextQueryID atomic.Uint64
+
+	// storedQueryMu serializes stored query saves (see SaveQuery)
+	storedQueryMu sync.Mutex
+
+	// Rolling query workload statistics (see Workload)
+	workload *WorkloadRecorder
+
+	// retryPolicy retries retryable auto-commit writes on conflict (see SetRetryPolicy)
+	retryPolicy RetryPolicy
+
+	// redactor masks PII in query text listed by dbms.listQueries (see SetRedactor)
+	redactor *redact.Redactor
+
 	// deferFlush when true, writes are not auto-flushed (Bolt layer handles it)
 	deferFlush bool
 
@@ -202,6 +226,29 @@ type StorageExecutor struct {
 	// If set, vector search can accept string queries which are embedded automatically
 	embedder QueryEmbedder
 
+	// vectorIndexes receives vector index definitions (optional)
+	// If set, CREATE VECTOR INDEX and drops are mirrored to the search service
+	vectorIndexes VectorIndexRegistry
+
+	// gpuDiagnostics reports GPU backends for nornicdb.gpu.probe (optional)
+	gpuDiagnostics GPUDiagnostics
+
+	// embeddingClusterer runs nornicdb.cluster.embeddings (optional)
+	embeddingClusterer EmbeddingClusterer
+
+	// agentMemory runs the nornicdb.memory.* procedures (optional)
+	agentMemory AgentMemory
+
+	// qcEvaluator reports Heimdall QC shadow decisions for nornicdb.qc.report (optional)
+	qcEvaluator QCEvaluator
+
+	// vectorAccelerator scores db.index.vector.queryNodes on a GPU (optional)
+	// If nil or failing, candidates are scored on CPU
+	vectorAccelerator VectorSearchAccelerator
+
+	// virtualLabels resolves MATCHes on virtual labels from external sources (optional)
+	virtualLabels VirtualLabelResolver
+
 	// onNodeCreated is called when a node is created or updated via CREATE/MERGE
 	// This allows the embed queue to be notified of new content requiring embeddings
 	onNodeCreated NodeCreatedCallback
@@ -213,6 +260,103 @@ type QueryEmbedder interface {
 	Embed(ctx context.Context, text string) ([]float32, error)
 }
 
+// VectorIndexRegistry maintains named vector indexes outside the executor.
+// This is a minimal interface to avoid import cycles with search package.
+type VectorIndexRegistry interface {
+	CreateVectorIndex(ctx context.Context, name, label, property string, dimensions int, similarity string) error
+	DropVectorIndex(name string) bool
+}
+
+// VectorIndexPopulation is implemented by a VectorIndexRegistry that
+// populates new indexes in the background. db.indexes() reports their state
+// and db.awaitIndex() waits for them; without it every index is online.
+type VectorIndexPopulation interface {
+	// VectorIndexState returns "POPULATING", "ONLINE" or "FAILED" and the
+	// percentage populated, or false for an unknown index.
+	VectorIndexState(name string) (state string, percent float64, ok bool)
+	// AwaitVectorIndex waits until the index is online. It fails if the
+	// population failed or ctx ends first.
+	AwaitVectorIndex(ctx context.Context, name string) error
+}
+
+// GPUDiagnostics reports GPU backends, devices and unavailability reasons.
+// This is a minimal interface to avoid import cycles with gpu package.
+//
+// Each entry describes one backend with the keys backend, compiled,
+// available, reason and devices (a list of per-device maps).
+type GPUDiagnostics interface {
+	ProbeGPU() []map[string]interface{}
+}
+
+// EmbeddingClusterer clusters node embeddings and stores the clusters in
+// the graph. This is a minimal interface to avoid import cycles with the
+// nornicdb package.
+type EmbeddingClusterer interface {
+	// ClusterEmbeddings runs one clustering and returns its summary with
+	// the keys algorithm, nodes, clusters, noise, skipped and duration_ms.
+	ClusterEmbeddings(ctx context.Context, options EmbeddingClusterOptions) (map[string]interface{}, error)
+}
+
+// EmbeddingClusterOptions are the arguments of nornicdb.cluster.embeddings.
+type EmbeddingClusterOptions struct {
+	Algorithm      string // "kmeans" or "hdbscan"
+	K              int    // k-means clusters (0 = automatic)
+	MinClusterSize int    // HDBSCAN smallest cluster (0 = default)
+	Label          string // Cluster only nodes with this label ("" = all)
+}
+
+// AgentMemory appends, consolidates and retrieves agent memories. This is
+// a minimal interface to avoid import cycles with the nornicdb package.
+type AgentMemory interface {
+	// AppendEpisode stores an episodic memory of a session and returns the
+	// keys id, session and created_at.
+	AppendEpisode(ctx context.Context, sessionID, content string, properties map[string]interface{}) (map[string]interface{}, error)
+	// ConsolidateMemories runs one consolidation and returns its summary
+	// with the keys sessions, episodes, memories, rejected, failed and
+	// duration_ms.
+	ConsolidateMemories(ctx context.Context, options MemoryConsolidationOptions) (map[string]interface{}, error)
+	// RetrieveMemories returns memories for a query, best first, with the
+	// keys id, tier, title, content, session, score and via.
+	RetrieveMemories(ctx context.Context, query string, options MemoryRetrievalOptions) ([]map[string]interface{}, error)
+}
+
+// MemoryConsolidationOptions are the config of nornicdb.memory.consolidate.
+type MemoryConsolidationOptions struct {
+	Session     string        // Consolidate only this session ("" = all)
+	MinAge      time.Duration // Skip younger episodes
+	MinEpisodes int           // Fewest new episodes per session (0 = default)
+	MaxEpisodes int           // Episodes per semantic memory (0 = default)
+}
+
+// MemoryRetrievalOptions are the config of nornicdb.memory.retrieve.
+type MemoryRetrievalOptions struct {
+	Session string // Only memories of this session ("" = all)
+	Limit   int    // Memories found by search (0 = default)
+}
+
+// QCEvaluator reports how the decisions Heimdall QC made in shadow mode
+// compare with later feedback on the edges. This is a minimal interface to
+// avoid import cycles with the inference package.
+type QCEvaluator interface {
+	// QCReport returns one row per suggestion method and prompt version
+	// with the keys dimension, key, pending, outcomes, true_positives,
+	// false_positives, false_negatives, true_negatives, precision and
+	// recall.
+	QCReport() ([]map[string]interface{}, error)
+}
+
+// VectorSearchAccelerator ranks vector query candidates on a GPU.
+// This is a minimal interface to avoid import cycles with gpu package.
+type VectorSearchAccelerator interface {
+	// SearchVectors returns the positions in vectors of the k vectors most
+	// cosine-similar to query, best first, with their scores. ids identify
+	// the vectors across calls, so an implementation can keep them on the
+	// device and upload only changes. An error means the caller should
+	// score on CPU instead. ctx carries the caller's principal, which a
+	// GPU scheduler uses to share the device fairly between sessions.
+	SearchVectors(ctx context.Context, index string, ids []string, vectors [][]float32, query []float32, k int) ([]int, []float64, error)
+}
+
 // NewStorageExecutor creates a new Cypher executor with the given storage backend.
 //
 // The executor is initialized with a parser and connected to the storage engine.
@@ -240,6 +384,9 @@ func NewStorageExecutor(store storage.Engine) *StorageExecutor {
 		planCache:       NewQueryPlanCache(500),   // Cache 500 parsed query plans
 		analyzer:        NewQueryAnalyzer(1000),   // Cache 1000 parsed query ASTs
 		nodeLookupCache: make(map[string]*storage.Node, 1000),
+		prepared:        make(map[string]*PreparedStatement),
+		running:         make(map[string]*RunningQuery),
+		workload:        NewWorkloadRecorder(DefaultWorkloadWindow, 1, DefaultWorkloadMaxShapes),
 	}
 }
 
@@ -259,6 +406,89 @@ func (e *StorageExecutor) SetEmbedder(embedder QueryEmbedder) {
 	e.embedder = embedder
 }
 
+// SetVectorIndexRegistry mirrors vector index definitions created or dropped
+// through Cypher to the registry, so each named index is maintained with its
+// own dimensions and similarity function.
+//
+// Example:
+//
+//	executor := cypher.NewStorageExecutor(storage)
+//	executor.SetVectorIndexRegistry(registry)
+//
+//	// Registered with both the schema and the registry:
+//	// CREATE VECTOR INDEX image_idx FOR (n:Image) ON (n.clip)
+//	// OPTIONS {indexConfig: {`vector.dimensions`: 512}}
+func (e *StorageExecutor) SetVectorIndexRegistry(registry VectorIndexRegistry) {
+	e.vectorIndexes = registry
+}
+
+// SetGPUDiagnostics enables CALL nornicdb.gpu.probe(), which lists every GPU
+// backend with its devices or the reason it is unavailable.
+//
+// Example:
+//
+//	executor := cypher.NewStorageExecutor(storage)
+//	executor.SetGPUDiagnostics(diagnostics)
+//
+//	// CALL nornicdb.gpu.probe() YIELD backend, available, reason, devices
+func (e *StorageExecutor) SetGPUDiagnostics(diagnostics GPUDiagnostics) {
+	e.gpuDiagnostics = diagnostics
+}
+
+// SetEmbeddingClusterer enables CALL nornicdb.cluster.embeddings(), which
+// clusters node embeddings, stores each node's cluster_id and creates a
+// :Cluster node per cluster.
+//
+// Example:
+//
+//	executor := cypher.NewStorageExecutor(storage)
+//	executor.SetEmbeddingClusterer(clusterer)
+//
+//	// CALL nornicdb.cluster.embeddings('hdbscan', {label: 'Memory'})
+func (e *StorageExecutor) SetEmbeddingClusterer(clusterer EmbeddingClusterer) {
+	e.embeddingClusterer = clusterer
+}
+
+// SetAgentMemory enables the agent memory procedures
+// nornicdb.memory.append, nornicdb.memory.consolidate and
+// nornicdb.memory.retrieve.
+//
+// Example:
+//
+//	executor := cypher.NewStorageExecutor(storage)
+//	executor.SetAgentMemory(memory)
+//
+//	// CALL nornicdb.memory.retrieve('display preferences', {session: 's1'})
+func (e *StorageExecutor) SetAgentMemory(memory AgentMemory) {
+	e.agentMemory = memory
+}
+
+// SetQCEvaluator enables CALL nornicdb.qc.report(), which lists the
+// precision and recall of Heimdall QC shadow decisions per method and
+// prompt version.
+//
+// Example:
+//
+//	executor := cypher.NewStorageExecutor(storage)
+//	executor.SetQCEvaluator(evaluator)
+//
+//	// CALL nornicdb.qc.report() YIELD dimension, key, precision, recall
+func (e *StorageExecutor) SetQCEvaluator(evaluator QCEvaluator) {
+	e.qcEvaluator = evaluator
+}
+
+// SetVectorSearchAccelerator makes db.index.vector.queryNodes rank
+// candidates on a GPU for cosine indexes. Dot and euclidean indexes, and
+// queries the accelerator fails, are still scored on CPU.
+//
+// Example:
+//
+//	executor := cypher.NewStorageExecutor(storage)
+//	executor.SetVectorSearchAccelerator(accelerator)
+func (e *StorageExecutor) SetVectorSearchAccelerator(accelerator VectorSearchAccelerator) {
+	e.vectorAccelerator = accelerator
+}
+
 // SetNodeCreatedCallback sets a callback that is invoked when nodes are created
 // or updated via CREATE/MERGE statements. This allows the embed queue to be
 // notified of new content that needs embedding generation.
@@ -391,14 +621,38 @@ func queryDeletesNodes(query string) bool {
 // Error Handling:
 //
 //	Returns detailed error messages for syntax errors, type mismatches,
-//	and execution failures with Neo4j-compatible error codes.
-func (e *StorageExecutor) Execute(ctx context.Context, cypher string, params map[string]interface{}) (*ExecuteResult, error) {
+//	and execution failures with Neo4j-compatible error codes. Panics raised
+//	during execution are recovered and returned as a *QueryPanicError so one
+//	bad query cannot crash the server.
+func (e *StorageExecutor) Execute(ctx context.Context, cypher string, params map[string]interface{}) (result *ExecuteResult, err error) {
+	ctx, running := e.startQuery(ctx, cypher)
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, newQueryPanicError(requestid.FromContext(ctx), cypher, r)
+		}
+		result, err = e.finishQuery(running, result, err)
+	}()
+	return e.execute(ctx, cypher, params)
+}
+
+// execute routes and runs a single query. Called by Execute inside its recover boundary.
+func (e *StorageExecutor) execute(ctx context.Context, cypher string, params map[string]interface{}) (*ExecuteResult, error) {
 	// Normalize query
 	cypher = strings.TrimSpace(cypher)
 	if cypher == "" {
 		return nil, fmt.Errorf("empty query")
 	}
 
+	// CYPHER prefix options override the caller's defaults for this query
+	if hasCypherPrefix(cypher) {
+		opts, rest, err := ParseQueryOptions(cypher)
+		if err != nil {
+			return nil, err
+		}
+		cypher = rest
+		ctx = WithQueryOptions(ctx, QueryOptionsFromContext(ctx).Merge(opts))
+	}
+
 	// Validate basic syntax
 	if err := e.validateSyntax(cypher); err != nil {
 		return nil, err
@@ -420,18 +674,56 @@ func (e *StorageExecutor) Execute(ctx context.Context, cypher string, params map
 	// This extracts query metadata (HasMatch, IsReadOnly, Labels, etc.) once
 	// and caches it for repeated queries, avoiding redundant string parsing
 	info := e.analyzer.Analyze(cypher)
+	markReadOnly(ctx, info)
 
 	// For routing, we still need upperQuery for some handlers
 	// TODO: Migrate handlers to use QueryInfo directly
 	upperQuery := strings.ToUpper(cypher)
 
 	// Try cache for read-only queries (using cached analysis)
-	if info.IsReadOnly && e.cache != nil {
+	cacheable := info.IsReadOnly && e.cache != nil && !e.usesVirtualLabels(info.Labels)
+	if cacheable {
 		if cached, found := e.cache.Get(cypher, params); found {
 			return cached, nil
 		}
 	}
 
+	result, err := e.executeAnalyzed(ctx, cypher, upperQuery, info)
+
+	// Cache successful read-only queries
+	if err == nil && cacheable {
+		// Determine TTL based on query type (using cached analysis)
+		ttl := 60 * time.Second // Default: 60s for data queries
+		if info.HasCall || info.HasShow {
+			ttl = 300 * time.Second // 5 minutes for schema queries
+		}
+		e.cache.Put(cypher, params, result, ttl)
+	}
+
+	e.invalidateAfterWrite(cypher, info)
+
+	return result, err
+}
+
+// executeAnalyzed runs a validated, analyzed query. Shared by execute and
+// ExecutePrepared; params and query options must already be stored in ctx.
+func (e *StorageExecutor) executeAnalyzed(ctx context.Context, cypher, upperQuery string, info *QueryInfo) (*ExecuteResult, error) {
+	if info.IsWriteQuery || info.HasSchema {
+		if _, recovering := storage.Recovering(); recovering {
+			return nil, fmt.Errorf("%w: writes are rejected until WAL recovery completes", storage.ErrRecovering)
+		}
+	}
+	if info.IsReadOnly {
+		timeout := QueryOptionsFromContext(ctx).Timeout
+		if _, hasDeadline := ctx.Deadline(); timeout > 0 || hasDeadline {
+			return e.executeWithTimeout(ctx, cypher, upperQuery, info, timeout)
+		}
+	}
+	return e.executeRouted(ctx, cypher, upperQuery, info)
+}
+
+// executeRouted dispatches a query to its transaction mode and handler.
+func (e *StorageExecutor) executeRouted(ctx context.Context, cypher, upperQuery string, info *QueryInfo) (*ExecuteResult, error) {
 	// Check for transaction control statements FIRST
 	if result, err := e.parseTransactionStatement(cypher); result != nil || err != nil {
 		return result, err
@@ -455,37 +747,30 @@ func (e *StorageExecutor) Execute(ctx context.Context, cypher string, params map
 	// Auto-commit single query - use async path for performance
 	// This uses AsyncEngine's write-behind cache instead of synchronous disk I/O
 	// For strict ACID, users should use explicit BEGIN/COMMIT transactions
-	result, err := e.executeImplicitAsync(ctx, cypher, upperQuery)
+	return e.executeImplicitAsync(ctx, cypher, upperQuery, info)
+}
 
-	// Cache successful read-only queries
-	if err == nil && info.IsReadOnly && e.cache != nil {
-		// Determine TTL based on query type (using cached analysis)
-		ttl := 60 * time.Second // Default: 60s for data queries
-		if info.HasCall || info.HasShow {
-			ttl = 300 * time.Second // 5 minutes for schema queries
-		}
-		e.cache.Put(cypher, params, result, ttl)
+// invalidateAfterWrite drops cached lookups and results a write query may
+// have made stale.
+func (e *StorageExecutor) invalidateAfterWrite(cypher string, info *QueryInfo) {
+	if !info.IsWriteQuery {
+		return
 	}
 
-	// Invalidate caches on write operations (using cached analysis)
-	if info.IsWriteQuery {
-		// Only invalidate node lookup cache when NODES are deleted
-		// Relationship-only deletes (like benchmark CREATE rel DELETE rel) don't affect node cache
-		if info.HasDelete && queryDeletesNodes(cypher) {
-			e.invalidateNodeLookupCache()
-		}
+	// Only invalidate node lookup cache when NODES are deleted
+	// Relationship-only deletes (like benchmark CREATE rel DELETE rel) don't affect node cache
+	if info.HasDelete && queryDeletesNodes(cypher) {
+		e.invalidateNodeLookupCache()
+	}
 
-		// Invalidate query result cache using cached labels
-		if e.cache != nil {
-			if len(info.Labels) > 0 {
-				e.cache.InvalidateLabels(info.Labels)
-			} else {
-				e.cache.Invalidate()
-			}
+	// Invalidate query result cache using cached labels
+	if e.cache != nil {
+		if len(info.Labels) > 0 {
+			e.cache.InvalidateLabels(info.Labels)
+		} else {
+			e.cache.Invalidate()
 		}
 	}
-
-	return result, err
 }
 
 // TransactionCapableEngine is an engine that supports ACID transactions.
@@ -498,15 +783,14 @@ type TransactionCapableEngine interface {
 // For write operations, wraps execution in an implicit transaction that can be
 // rolled back on error, preventing partial data corruption from failed queries.
 // For strict ACID guarantees with durability, use explicit BEGIN/COMMIT transactions.
-func (e *StorageExecutor) executeImplicitAsync(ctx context.Context, cypher string, upperQuery string) (*ExecuteResult, error) {
+func (e *StorageExecutor) executeImplicitAsync(ctx context.Context, cypher string, upperQuery string, info *QueryInfo) (*ExecuteResult, error) {
 	// Check if this is a write operation using cached analysis
-	info := e.analyzer.Analyze(cypher)
 	isWrite := info.IsWriteQuery
 
 	// For write operations, use implicit transaction for atomicity
 	// This ensures partial writes are rolled back on error
 	if isWrite {
-		return e.executeWithImplicitTransaction(ctx, cypher, upperQuery)
+		return e.executeWithConflictRetry(ctx, cypher, upperQuery)
 	}
 
 	// Read-only operations don't need transaction wrapping
@@ -562,12 +846,22 @@ func (e *StorageExecutor) executeWithImplicitTransaction(ctx context.Context, cy
 	if err != nil {
 		return nil, fmt.Errorf("failed to start implicit transaction: %w", err)
 	}
+	tx.RequestID = requestid.FromContext(ctx)
 
 	// Create a transactional wrapper that routes writes through the transaction
 	// CRITICAL: We pass the wrapper through context instead of modifying e.storage
 	// because e.storage modification is NOT thread-safe for concurrent executions.
 	txWrapper := &transactionStorageWrapper{tx: tx, underlying: e.storage}
 
+	// Roll back before the panic propagates to Execute's recover boundary so a
+	// recovered panic never leaves a half-applied implicit transaction behind.
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
 	// Execute with transaction wrapper via context
 	txCtx := context.WithValue(ctx, ctxKeyTxStorage, txWrapper)
 
@@ -699,6 +993,18 @@ func (w *transactionStorageWrapper) GetOutDegree(nodeID storage.NodeID) int {
 	return w.underlying.GetOutDegree(nodeID)
 }
 
+func (w *transactionStorageWrapper) Degree(nodeID storage.NodeID, direction string, types ...string) (int64, error) {
+	return storage.CountRelationships(w.underlying, nodeID, direction, types...)
+}
+
+func (w *transactionStorageWrapper) SampleNodeIDs(label string, n int, rnd *rand.Rand) ([]storage.NodeID, error) {
+	return storage.SampleNodeIDs(w.underlying, label, n, rnd)
+}
+
+func (w *transactionStorageWrapper) RandomNodeID(label string, rnd *rand.Rand) (storage.NodeID, error) {
+	return storage.RandomNodeID(w.underlying, label, rnd)
+}
+
 func (w *transactionStorageWrapper) GetSchema() *storage.SchemaManager {
 	return w.underlying.GetSchema()
 }
@@ -1021,8 +1327,7 @@ func (e *StorageExecutor) executeWithoutTransaction(ctx context.Context, cypher
 	case strings.HasPrefix(upperQuery, "RETURN"):
 		return e.executeReturn(ctx, cypher)
 	case strings.HasPrefix(upperQuery, "DROP"):
-		// DROP INDEX/CONSTRAINT - treat as no-op (NornicDB manages indexes internally)
-		return &ExecuteResult{Columns: []string{}, Rows: [][]interface{}{}}, nil
+		return e.executeDrop(ctx, cypher)
 	case strings.HasPrefix(upperQuery, "WITH"):
 		return e.executeWith(ctx, cypher)
 	case strings.HasPrefix(upperQuery, "UNWIND"):
@@ -1174,6 +1479,11 @@ func splitReturnExpressions(clause string) []string {
 
 // validateSyntax performs basic syntax validation.
 func (e *StorageExecutor) validateSyntax(cypher string) error {
+	return validateCypherSyntax(cypher)
+}
+
+// validateCypherSyntax checks the starting keyword and bracket and quote balance.
+func validateCypherSyntax(cypher string) error {
 	upper := strings.ToUpper(cypher)
 
 	// Check for valid starting keyword (including EXPLAIN/PROFILE prefixes)
@@ -1942,14 +2252,14 @@ func (e *StorageExecutor) parseReturnItems(returnPart string) []returnItem {
 	return items
 }
 
-func (e *StorageExecutor) filterNodes(nodes []*storage.Node, variable, whereClause string) []*storage.Node {
+func (e *StorageExecutor) filterNodes(ctx context.Context, nodes []*storage.Node, variable, whereClause string) []*storage.Node {
 	// Create filter function for parallel execution
 	filterFn := func(node *storage.Node) bool {
 		return e.evaluateWhere(node, variable, whereClause)
 	}
 
 	// Use parallel filtering for large datasets
-	return parallelFilterNodes(nodes, filterFn)
+	return parallelFilterNodesWithConfig(parallelConfigFor(ctx), nodes, filterFn)
 }
 
 func (e *StorageExecutor) evaluateWhere(node *storage.Node, variable, whereClause string) bool {
@@ -2009,6 +2319,11 @@ func (e *StorageExecutor) evaluateWhere(node *storage.Node, variable, whereClaus
 		return e.evaluateCountSubqueryComparison(node, variable, whereClause)
 	}
 
+	// Handle size((n)-[:TYPE]->()) with comparison
+	if matched, result := e.evaluatePatternSizeComparison(node, variable, whereClause); matched {
+		return result
+	}
+
 	// Handle NOT prefix
 	if strings.HasPrefix(upperClause, "NOT ") {
 		inner := strings.TrimSpace(whereClause[4:])
@@ -2757,7 +3072,12 @@ func (e *StorageExecutor) evaluateCountSubqueryComparison(node *storage.Node, va
 	}
 
 	// Get comparison part after COUNT { }
-	comparison := strings.TrimSpace(remaining[closeIdx+1:])
+	return compareCount(count, strings.TrimSpace(remaining[closeIdx+1:]))
+}
+
+// compareCount evaluates a comparison like "> 2" against a count. An empty
+// comparison is true for a non-zero count.
+func compareCount(count int64, comparison string) bool {
 	if comparison == "" {
 		// No comparison, return true if count > 0
 		return count > 0
@@ -2823,6 +3143,11 @@ func (e *StorageExecutor) evaluateCountSubqueryComparison(node *storage.Node, va
 
 // countSubqueryMatches counts how many matches a subquery produces
 func (e *StorageExecutor) countSubqueryMatches(node *storage.Node, variable, subquery string) int64 {
+	// Single relationships from the node are counted without expanding
+	if count, ok := e.patternCount(subquery, map[string]*storage.Node{variable: node}); ok {
+		return count
+	}
+
 	// Parse the MATCH pattern from the subquery
 	upperSub := strings.ToUpper(subquery)
 
@@ -3341,6 +3666,7 @@ func (e *StorageExecutor) executeShowProcedures(ctx context.Context, cypher stri
 		{"dbms.functions", "dbms.functions() :: (name :: STRING, ...)", "List all functions", "DBMS", false},
 		{"dbms.info", "dbms.info() :: (id :: STRING, name :: STRING, creationDate :: STRING)", "DBMS information", "DBMS", false},
 		{"dbms.listConfig", "dbms.listConfig() :: (name :: STRING, ...)", "List DBMS configuration", "DBMS", false},
+		{"dbms.setConfig", "dbms.setConfig(name :: STRING, value :: ANY) :: (name :: STRING, previous :: ANY, value :: ANY)", "Change a dynamic setting at runtime", "DBMS", false},
 		{"dbms.clientConfig", "dbms.clientConfig() :: (name :: STRING, value :: ANY)", "Client configuration", "DBMS", false},
 		{"dbms.listConnections", "dbms.listConnections() :: (...)", "List active connections", "DBMS", false},
 		{"apoc.path.subgraphNodes", "apoc.path.subgraphNodes(startNode :: NODE, config :: MAP) :: (node :: NODE)", "Return all nodes in a subgraph", "READ", false},
@@ -3349,6 +3675,12 @@ func (e *StorageExecutor) executeShowProcedures(ctx context.Context, cypher stri
 		{"nornicdb.version", "nornicdb.version() :: (version :: STRING)", "NornicDB version", "READ", false},
 		{"nornicdb.stats", "nornicdb.stats() :: (...)", "NornicDB statistics", "READ", false},
 		{"nornicdb.decay.info", "nornicdb.decay.info() :: (...)", "NornicDB decay information", "READ", false},
+		{"nornicdb.gpu.probe", "nornicdb.gpu.probe() :: (backend :: STRING, compiled :: BOOLEAN, available :: BOOLEAN, reason :: STRING, devices :: LIST OF MAP)", "GPU backends, devices and unavailability reasons", "DBMS", false},
+		{"nornicdb.cluster.embeddings", "nornicdb.cluster.embeddings(algorithm :: STRING, config = {} :: MAP) :: (algorithm :: STRING, nodes :: INTEGER, clusters :: INTEGER, noise :: INTEGER, skipped :: INTEGER, duration_ms :: INTEGER)", "Cluster node embeddings into :Cluster nodes", "WRITE", false},
+		{"nornicdb.memory.append", "nornicdb.memory.append(session :: STRING, content :: STRING, properties = {} :: MAP) :: (id :: STRING, session :: STRING, created_at :: STRING)", "Append an episodic memory to an agent session", "WRITE", false},
+		{"nornicdb.memory.consolidate", "nornicdb.memory.consolidate(config = {} :: MAP) :: (sessions :: INTEGER, episodes :: INTEGER, memories :: INTEGER, rejected :: INTEGER, failed :: INTEGER, duration_ms :: INTEGER)", "Consolidate session episodes into semantic memories with the SLM", "WRITE", false},
+		{"nornicdb.memory.retrieve", "nornicdb.memory.retrieve(query :: STRING, config = {} :: MAP) :: (id :: STRING, tier :: STRING, title :: STRING, content :: STRING, session :: STRING, score :: FLOAT, via :: STRING)", "Retrieve memories by hybrid search and their consolidation links", "READ", false},
+		{"nornicdb.qc.report", "nornicdb.qc.report() :: (dimension :: STRING, key :: STRING, pending :: INTEGER, outcomes :: INTEGER, true_positives :: INTEGER, false_positives :: INTEGER, false_negatives :: INTEGER, true_negatives :: INTEGER, precision :: FLOAT, recall :: FLOAT)", "Precision and recall of Heimdall QC shadow decisions", "DBMS", false},
 	}
 
 	return &ExecuteResult{
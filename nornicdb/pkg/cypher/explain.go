@@ -273,6 +273,9 @@ func (e *StorageExecutor) analyzeMatchClause(query string) *PlanOperator {
 
 	// Check for relationship pattern
 	if strings.Contains(query, "->") || strings.Contains(query, "<-") || strings.Contains(query, "]-[") {
+		if op := e.analyzeTraversal(query); op != nil {
+			return op
+		}
 		return &PlanOperator{
 			OperatorType:  "Expand",
 			Description:   "Expand relationships",
@@ -287,6 +290,63 @@ func (e *StorageExecutor) analyzeMatchClause(query string) *PlanOperator {
 	return e.analyzeNodeScan(query)
 }
 
+// analyzeTraversal plans a single relationship pattern the way
+// traverseGraph executes it. Returns nil if the pattern is not recognized.
+func (e *StorageExecutor) analyzeTraversal(query string) *PlanOperator {
+	parts := pathPatternRe.FindStringSubmatch(query)
+	if parts == nil {
+		return nil
+	}
+	match := e.parseTraversalPattern(parts[0])
+	if match == nil {
+		return nil
+	}
+
+	rel := match.Relationship
+	switch planTraversal(match) {
+	case anchorRelType:
+		scan := "DirectedRelationshipTypeScan"
+		if rel.Direction == "both" {
+			scan = "UndirectedRelationshipTypeScan"
+		}
+		return &PlanOperator{
+			OperatorType:  scan,
+			Description:   fmt.Sprintf("Scan relationships of type :%s", strings.Join(rel.Types, "|")),
+			EstimatedRows: 100,
+			Arguments: map[string]interface{}{
+				"types": rel.Types,
+			},
+			Identifiers: []string{match.StartNode.variable, rel.Variable, match.EndNode.variable},
+		}
+	case anchorEnd:
+		return &PlanOperator{
+			OperatorType:  "Expand(All)",
+			Description:   fmt.Sprintf("Expand %s relationships from (%s)", reverseDirection(rel.Direction), match.EndNode.variable),
+			EstimatedRows: 100,
+			Arguments: map[string]interface{}{
+				"from":      match.EndNode.variable,
+				"direction": reverseDirection(rel.Direction),
+			},
+			Children: []*PlanOperator{
+				e.analyzeNodeScan("(" + parts[3] + ")"),
+			},
+		}
+	default:
+		return &PlanOperator{
+			OperatorType:  "Expand(All)",
+			Description:   fmt.Sprintf("Expand %s relationships from (%s)", rel.Direction, match.StartNode.variable),
+			EstimatedRows: 100,
+			Arguments: map[string]interface{}{
+				"from":      match.StartNode.variable,
+				"direction": rel.Direction,
+			},
+			Children: []*PlanOperator{
+				e.analyzeNodeScan("(" + parts[1] + ")"),
+			},
+		}
+	}
+}
+
 // analyzeNodeScan determines the type of node scan needed
 func (e *StorageExecutor) analyzeNodeScan(query string) *PlanOperator {
 	// Check for label in pattern (n:Label)
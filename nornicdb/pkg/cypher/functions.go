@@ -122,6 +122,11 @@ func (e *StorageExecutor) evaluateExpressionWithContext(expr string, nodes map[s
 		return e.evaluateCaseExpression(expr, nodes, rels)
 	}
 
+	// COUNT { (n)-[:TYPE]->() } - relationship count of a bound node
+	if count, ok := e.evaluateCountSubqueryExpr(expr, nodes); ok {
+		return count
+	}
+
 	lowerExpr := strings.ToLower(expr)
 
 	// ========================================
@@ -236,6 +241,10 @@ func (e *StorageExecutor) evaluateExpressionWithContext(expr string, nodes map[s
 	// size(list) or size(string) - return length
 	if matchFuncStartAndSuffix(expr, "size") {
 		inner := extractFuncArgs(expr, "size")
+		// size((n)-[:TYPE]->()) - relationship count of a bound node
+		if count, ok := e.patternCount(inner, nodes); ok {
+			return count
+		}
 		innerVal := e.evaluateExpressionWithContext(inner, nodes, rels)
 		switch v := innerVal.(type) {
 		case string:
@@ -0,0 +1,177 @@
+// Package cypher - approximate distinct counting with HyperLogLog.
+//
+// approxCountDistinct(expr) estimates how many distinct non-null values
+// expr takes, like count(DISTINCT expr) but in bounded memory. count
+// (DISTINCT) keeps every value it has seen; over hundreds of millions of
+// values, or a GROUP BY with millions of groups, that set is what runs a
+// query out of memory.
+//
+// Each group folds its values into its own sketch as rows are grouped. A
+// sketch stays exact up to hllSparseLimit values and then switches to
+// 2^hllPrecision one-byte registers (16 KiB), with a standard error of
+// about 0.8% from there on. Most groups of a high-cardinality GROUP BY are
+// small, so they stay in the small exact form.
+
+package cypher
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+const (
+	// hllPrecision is the number of hash bits that pick a register.
+	hllPrecision = 14
+	hllRegisters = 1 << hllPrecision
+
+	// hllSparseLimit is how many distinct values a sketch counts exactly
+	// before switching to registers. Kept well below the register size so
+	// the exact form is always the smaller one.
+	hllSparseLimit = 512
+)
+
+// hllSketch estimates the number of distinct values added to it.
+type hllSketch struct {
+	sparse    map[uint64]struct{} // hashes seen, until hllSparseLimit
+	registers []uint8             // max leading zeros per register, after
+}
+
+func newHLLSketch() *hllSketch {
+	return &hllSketch{sparse: make(map[uint64]struct{})}
+}
+
+// add records a value. Null values are ignored, as count(DISTINCT) does.
+func (s *hllSketch) add(value interface{}) {
+	if value == nil {
+		return
+	}
+	h := hllHash(value)
+	if s.registers == nil {
+		s.sparse[h] = struct{}{}
+		if len(s.sparse) <= hllSparseLimit {
+			return
+		}
+		s.registers = make([]uint8, hllRegisters)
+		for seen := range s.sparse {
+			s.insert(seen)
+		}
+		s.sparse = nil
+		return
+	}
+	s.insert(h)
+}
+
+func (s *hllSketch) insert(h uint64) {
+	idx := h >> (64 - hllPrecision)
+	// The low bit set stops the count at 64-hllPrecision+1.
+	rank := uint8(bits.LeadingZeros64(h<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > s.registers[idx] {
+		s.registers[idx] = rank
+	}
+}
+
+// estimate returns the estimated number of distinct values.
+func (s *hllSketch) estimate() int64 {
+	if s.registers == nil {
+		return int64(len(s.sparse))
+	}
+	m := float64(hllRegisters)
+	sum, zeros := 0.0, 0
+	for _, r := range s.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	est := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate while registers are empty.
+		est = m * math.Log(m/float64(zeros))
+	}
+	return int64(est + 0.5)
+}
+
+// hllHash hashes a value so that equal Cypher values hash equally: integer
+// types are widened to int64, and nodes and relationships hash by ID.
+func hllHash(value interface{}) uint64 {
+	switch v := value.(type) {
+	case int:
+		value = int64(v)
+	case int32:
+		value = int64(v)
+	case float32:
+		value = float64(v)
+	case *storage.Node:
+		value = "node:" + string(v.ID)
+	case *storage.Edge:
+		value = "rel:" + string(v.ID)
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%T:%v", value, value)
+	// FNV leaves the high bits, which pick the register, poorly mixed for
+	// short inputs; finish with the splitmix64 finalizer.
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// approxDistinctArg returns the argument of approxCountDistinct(expr),
+// dropping a redundant DISTINCT.
+func approxDistinctArg(expr string) string {
+	inner := extractFuncInner(expr)
+	if len(inner) > 9 && strings.EqualFold(inner[:9], "DISTINCT ") {
+		inner = strings.TrimSpace(inner[9:])
+	}
+	return inner
+}
+
+// approxCountDistinctNodes evaluates approxCountDistinct(expr) over nodes
+// bound to variable.
+func (e *StorageExecutor) approxCountDistinctNodes(expr, variable string, nodes []*storage.Node) int64 {
+	inner := approxDistinctArg(expr)
+	sketch := newHLLSketch()
+	for _, node := range nodes {
+		switch {
+		case inner == variable:
+			sketch.add(node)
+		case strings.HasPrefix(inner, variable+".") && isValidIdentifier(inner[len(variable)+1:]):
+			sketch.add(node.Properties[inner[len(variable)+1:]])
+		default:
+			sketch.add(e.evaluateExpressionWithContext(inner, map[string]*storage.Node{variable: node}, nil))
+		}
+	}
+	return sketch.estimate()
+}
+
+// approxDistinctValue evaluates the argument of approxCountDistinct for one
+// row, keeping bound nodes and relationships as entities so they hash by ID.
+func (e *StorageExecutor) approxDistinctValue(inner string, nodes map[string]*storage.Node, rels map[string]*storage.Edge) interface{} {
+	if node, ok := nodes[inner]; ok {
+		return node
+	}
+	if rel, ok := rels[inner]; ok {
+		return rel
+	}
+	return e.evaluateExpressionWithContext(inner, nodes, rels)
+}
+
+// approxCountDistinctPaths evaluates approxCountDistinct(expr) over the
+// paths matched by a traversal.
+func (e *StorageExecutor) approxCountDistinctPaths(expr string, paths []PathResult, match *TraversalMatch) int64 {
+	inner := approxDistinctArg(expr)
+	sketch := newHLLSketch()
+	for _, path := range paths {
+		ctx := e.buildPathContext(path, match)
+		sketch.add(e.approxDistinctValue(inner, ctx.nodes, ctx.rels))
+	}
+	return sketch.estimate()
+}
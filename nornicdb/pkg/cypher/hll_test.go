@@ -0,0 +1,111 @@
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHLLSketch(t *testing.T) {
+	t.Run("exact while small", func(t *testing.T) {
+		s := newHLLSketch()
+		for i := 0; i < hllSparseLimit; i++ {
+			s.add(int64(i % 300))
+			s.add(nil)
+		}
+		s.add(7)          // int and int64 are the same value
+		s.add("7")        // a string is not
+		s.add(float32(1)) // widened to float64
+		s.add(float64(1))
+		assert.Equal(t, int64(302), s.estimate())
+		assert.Nil(t, s.registers)
+	})
+
+	for _, n := range []int{1_000, 20_000, 500_000} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			s := newHLLSketch()
+			for i := 0; i < n; i++ {
+				s.add(fmt.Sprintf("user-%d", i))
+				s.add(fmt.Sprintf("user-%d", i/2))
+			}
+			require.NotNil(t, s.registers)
+			errRate := math.Abs(float64(s.estimate()-int64(n))) / float64(n)
+			assert.Less(t, errRate, 0.03, "estimate %d", s.estimate())
+		})
+	}
+}
+
+func TestApproxCountDistinct(t *testing.T) {
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+	ctx := context.Background()
+
+	for _, q := range []string{
+		`CREATE (:City {name: 'Oslo'}), (:City {name: 'Rome'})`,
+		`CREATE (:User {name: 'ann', city: 'Oslo', lang: 'no'}), (:User {name: 'bob', city: 'Oslo', lang: 'en'})`,
+		`CREATE (:User {name: 'cy', city: 'Oslo', lang: 'en'}), (:User {name: 'di', city: 'Rome', lang: 'it'})`,
+		`CREATE (:User {name: 'ed', city: 'Rome'})`,
+	} {
+		_, err := exec.Execute(ctx, q, nil)
+		require.NoError(t, err, q)
+	}
+	for _, u := range [][2]string{{"ann", "Oslo"}, {"bob", "Oslo"}, {"cy", "Oslo"}, {"di", "Rome"}, {"ed", "Rome"}} {
+		_, err := exec.Execute(ctx, `MATCH (u:User {name: $user}), (c:City {name: $city}) CREATE (u)-[:LIVES_IN]->(c)`,
+			map[string]interface{}{"user": u[0], "city": u[1]})
+		require.NoError(t, err)
+	}
+
+	rows := func(query string) []string {
+		t.Helper()
+		result, err := exec.Execute(ctx, query, nil)
+		require.NoError(t, err, query)
+		var out []string
+		for _, row := range result.Rows {
+			out = append(out, fmt.Sprint(row...))
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "single group",
+			query: `MATCH (u:User) RETURN approxCountDistinct(u.lang)`,
+			want:  []string{"3"},
+		},
+		{
+			name:  "grouped",
+			query: `MATCH (u:User) RETURN u.city, approxCountDistinct(u.lang), count(u)`,
+			want:  []string{"Oslo2 3", "Rome1 2"},
+		},
+		{
+			name:  "nodes",
+			query: `MATCH (u:User) RETURN approxCountDistinct(u)`,
+			want:  []string{"5"},
+		},
+		{
+			name:  "traversal",
+			query: `MATCH (u:User)-[:LIVES_IN]->(c:City) RETURN c.name, approxCountDistinct(u.lang)`,
+			want:  []string{"Oslo2", "Rome1"},
+		},
+		{
+			name:  "WITH",
+			query: `MATCH (u:User) WITH u.city AS city, approxCountDistinct(u.lang) AS langs RETURN city, langs`,
+			want:  []string{"Oslo2", "Rome1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rows(tt.query))
+		})
+	}
+}
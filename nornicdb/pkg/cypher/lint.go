@@ -0,0 +1,491 @@
+// Package cypher - Cypher query linter.
+//
+// Lint statically analyzes a query without executing it and reports issues
+// that make it slow, fragile or non-portable:
+//
+//   - CARTESIAN_PRODUCT: disconnected patterns in a MATCH multiply row counts
+//   - MISSING_INDEX: property lookups on a label with no index scan every node
+//   - UNPARAMETERIZED_LITERAL: inline literals defeat plan and result caching
+//   - DEPRECATED_SYNTAX: constructs removed or deprecated in Neo4j 5
+//   - SYNTAX_ERROR: unbalanced brackets or quotes, unknown first clause
+//
+// It is exposed as cypher.Lint for CI pipelines and as a procedure for the
+// web console:
+//
+//	CALL db.lint('MATCH (a:Person), (b:Company) WHERE a.name = "Bob" RETURN a, b')
+//	YIELD code, severity, message, suggestion, position
+package cypher
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// LintSeverity ranks lint issues.
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "ERROR"   // Query will fail
+	LintWarning LintSeverity = "WARNING" // Query runs but likely slowly or will break on upgrade
+	LintInfo    LintSeverity = "INFO"    // Style or caching improvement
+)
+
+// Lint issue codes.
+const (
+	LintSyntaxError            = "SYNTAX_ERROR"
+	LintCartesianProduct       = "CARTESIAN_PRODUCT"
+	LintMissingIndex           = "MISSING_INDEX"
+	LintUnparameterizedLiteral = "UNPARAMETERIZED_LITERAL"
+	LintDeprecatedSyntax       = "DEPRECATED_SYNTAX"
+)
+
+// LintIssue is a single finding reported by Lint.
+type LintIssue struct {
+	Code       string       `json:"code"`
+	Severity   LintSeverity `json:"severity"`
+	Message    string       `json:"message"`
+	Suggestion string       `json:"suggestion,omitempty"`
+	Position   int          `json:"position"` // Byte offset in the query
+}
+
+var (
+	// Property predicates in WHERE: n.prop <op> [literal]
+	lintPredicatePattern = regexp.MustCompile(`(?i)\b([A-Za-z_]\w*)\.([A-Za-z_]\w*)\s*(<>|<=|>=|=~|=|<|>|\bIN\b|\bSTARTS\s+WITH\b|\bENDS\s+WITH\b|\bCONTAINS\b)\s*('[^']*'|"[^"]*"|-?\d+(?:\.\d+)?\b)?`)
+	// Inline pattern properties: {key: literal}
+	lintPropMapPattern     = regexp.MustCompile(`\{[^{}]*\}`)
+	lintPropLiteralPattern = regexp.MustCompile(`(\w+)\s*:\s*('[^']*'|"[^"]*"|-?\d+(?:\.\d+)?\b)`)
+	lintIdentifierPattern  = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+)
+
+// lintDeprecations lists deprecated constructs and their replacements.
+var lintDeprecations = []struct {
+	pattern     *regexp.Regexp
+	message     string
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)^\s*START\s`), "START clause was removed", "Use MATCH"},
+	{regexp.MustCompile(`(?i)\bCREATE\s+UNIQUE\b`), "CREATE UNIQUE was removed", "Use MERGE"},
+	{regexp.MustCompile(`\{\s*[A-Za-z_]\w*\s*\}`), "{param} parameter syntax was removed", "Use $param"},
+	{regexp.MustCompile(`(?i)\btoInt\s*\(`), "toInt() was renamed", "Use toInteger()"},
+	{regexp.MustCompile(`(?i)\bupper\s*\(`), "upper() was renamed", "Use toUpper()"},
+	{regexp.MustCompile(`(?i)\blower\s*\(`), "lower() was renamed", "Use toLower()"},
+	{regexp.MustCompile(`(?i)\bextract\s*\(`), "extract() was removed", "Use a list comprehension: [x IN list | expr]"},
+	{regexp.MustCompile(`(?i)\bfilter\s*\(`), "filter() was removed", "Use a list comprehension: [x IN list WHERE pred]"},
+	{regexp.MustCompile(`(?i)\brels\s*\(`), "rels() was renamed", "Use relationships()"},
+	{regexp.MustCompile(`(?i)\bexists\s*\(\s*\w+\.\w+\s*\)`), "exists(n.prop) is deprecated", "Use n.prop IS NOT NULL"},
+	{regexp.MustCompile(`\[[^\]]*\|\s*:`), "Colons in relationship type alternatives are deprecated", "Use [:A|B]"},
+}
+
+// Lint statically analyzes a Cypher query and returns its issues sorted by
+// position. The schema (may be nil) is used to check predicates for indexes;
+// without it MISSING_INDEX is not reported.
+//
+// Example:
+//
+//	for _, issue := range cypher.Lint(query, engine.GetSchema()) {
+//		fmt.Printf("%s %s at %d: %s\n", issue.Severity, issue.Code, issue.Position, issue.Message)
+//	}
+func Lint(query string, schema *storage.SchemaManager) []LintIssue {
+	l := &linter{
+		query:  query,
+		masked: maskStringLiterals(query),
+		schema: schema,
+		issues: []LintIssue{},
+	}
+
+	if err := validateCypherSyntax(strings.TrimSpace(query)); err != nil {
+		l.add(LintSyntaxError, LintError, 0, err.Error(), "")
+	}
+	l.checkDeprecated()
+	if ast, err := NewASTBuilder().Build(l.masked); err == nil {
+		l.checkClauses(ast)
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		return l.issues[i].Position < l.issues[j].Position
+	})
+	return l.issues
+}
+
+// Lint analyzes a query against this executor's schema. See Lint.
+func (e *StorageExecutor) Lint(query string) []LintIssue {
+	return Lint(query, e.storage.GetSchema())
+}
+
+// linter holds the state of one Lint run. Rules scan the masked query, where
+// string literal contents are blanked out, so keywords inside strings never
+// match; positions are the same in both.
+type linter struct {
+	query  string
+	masked string
+	schema *storage.SchemaManager
+	issues []LintIssue
+}
+
+func (l *linter) add(code string, severity LintSeverity, pos int, message, suggestion string) {
+	l.issues = append(l.issues, LintIssue{
+		Code:       code,
+		Severity:   severity,
+		Message:    message,
+		Suggestion: suggestion,
+		Position:   pos,
+	})
+}
+
+func (l *linter) checkDeprecated() {
+	for _, d := range lintDeprecations {
+		for _, loc := range d.pattern.FindAllStringIndex(l.masked, -1) {
+			l.add(LintDeprecatedSyntax, LintWarning, loc[0], d.message, d.replacement)
+		}
+	}
+}
+
+// checkClauses walks the clauses in order, tracking which variables are in
+// scope, and applies the pattern and predicate rules.
+func (l *linter) checkClauses(ast *AST) {
+	scope := make(map[string]bool)      // Variables in scope
+	graphVars := make(map[string]bool)  // Subset bound by patterns
+	labels := make(map[string][]string) // Variable -> labels from patterns
+	reported := make(map[string]bool)   // MISSING_INDEX already reported for var.prop
+
+	bindPatterns := func(patterns []ASTPattern) {
+		for _, p := range patterns {
+			for _, n := range p.Nodes {
+				if n.Variable == "" {
+					continue
+				}
+				scope[n.Variable] = true
+				graphVars[n.Variable] = true
+				if len(n.Labels) > 0 {
+					labels[n.Variable] = n.Labels
+				}
+			}
+		}
+	}
+
+	for i := range ast.Clauses {
+		c := &ast.Clauses[i]
+		switch c.Type {
+		case ASTClauseMatch, ASTClauseOptionalMatch:
+			if c.Match == nil {
+				continue
+			}
+			l.checkCartesian(c, c.Match.Patterns, scope, graphVars)
+			bindPatterns(c.Match.Patterns)
+			l.checkPatternProperties(c, c.Match.Patterns, reported)
+		case ASTClauseMerge:
+			if c.Merge == nil {
+				continue
+			}
+			patterns := []ASTPattern{c.Merge.Pattern}
+			bindPatterns(patterns)
+			l.checkPatternProperties(c, patterns, reported)
+		case ASTClauseCreate:
+			if c.Create != nil {
+				bindPatterns(c.Create.Patterns)
+			}
+		case ASTClauseWhere:
+			l.checkWhere(c, labels, reported)
+		case ASTClauseUnwind:
+			if c.Unwind != nil && c.Unwind.Variable != "" {
+				scope[c.Unwind.Variable] = true
+			}
+		case ASTClauseCall:
+			if c.Call != nil {
+				for _, y := range c.Call.Yield {
+					scope[y] = true
+				}
+			}
+		case ASTClauseWith:
+			if c.With == nil || strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(strings.ToUpper(c.RawText), "WITH")), "*") {
+				continue
+			}
+			// WITH projects: only listed variables survive
+			next := make(map[string]bool)
+			nextGraph := make(map[string]bool)
+			for _, item := range c.With.Items {
+				name := item.Alias
+				if name == "" && lintIdentifierPattern.MatchString(item.RawText) {
+					name = item.RawText
+				}
+				if name == "" {
+					continue
+				}
+				next[name] = true
+				if item.Alias == "" && graphVars[name] {
+					nextGraph[name] = true
+				}
+			}
+			scope, graphVars = next, nextGraph
+		}
+	}
+}
+
+// checkCartesian reports MATCH patterns that share no variable with each
+// other or with the graph variables already in scope.
+func (l *linter) checkCartesian(c *ASTClause, patterns []ASTPattern, scope, graphVars map[string]bool) {
+	if len(patterns) == 0 {
+		return
+	}
+
+	// Union-find over patterns; index len(patterns) stands for the variables
+	// bound by earlier clauses
+	bound := len(patterns)
+	parent := make([]int, len(patterns)+1)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(a, b int) { parent[find(a)] = find(b) }
+
+	owner := make(map[string]int)
+	for i, p := range patterns {
+		for _, n := range p.Nodes {
+			if n.Variable == "" {
+				continue
+			}
+			if j, seen := owner[n.Variable]; seen {
+				union(i, j)
+			} else {
+				owner[n.Variable] = i
+			}
+		}
+		for v := range scope {
+			if patternMentions(p.RawText, v) {
+				union(i, bound)
+				break
+			}
+		}
+	}
+
+	roots := make(map[int]bool)
+	for i := range patterns {
+		roots[find(i)] = true
+	}
+	if len(graphVars) > 0 {
+		roots[find(bound)] = true
+	}
+	if len(roots) < 2 {
+		return
+	}
+
+	// Report the first pattern outside the component of the first anchor
+	anchor := find(0)
+	if len(graphVars) > 0 {
+		anchor = find(bound)
+	}
+	for i, p := range patterns {
+		if find(i) == anchor {
+			continue
+		}
+		pos := c.StartPos
+		if off := strings.Index(c.RawText, p.RawText); off >= 0 {
+			pos += off
+		}
+		l.add(LintCartesianProduct, LintWarning, pos,
+			fmt.Sprintf("Pattern %s is not connected to the rest of the query, producing a cartesian product", p.RawText),
+			"Connect the patterns with a relationship, or separate them with WITH if the product is intended")
+		return
+	}
+}
+
+// checkPatternProperties reports unindexed and literal inline property lookups.
+func (l *linter) checkPatternProperties(c *ASTClause, patterns []ASTPattern, reported map[string]bool) {
+	for _, p := range patterns {
+		for _, n := range p.Nodes {
+			for prop := range n.Properties {
+				l.checkIndex(n.Variable, prop, n.Labels, c.StartPos, reported)
+			}
+		}
+	}
+
+	for _, loc := range lintPropMapPattern.FindAllStringIndex(c.RawText, -1) {
+		block := c.RawText[loc[0]:loc[1]]
+		for _, m := range lintPropLiteralPattern.FindAllStringSubmatchIndex(block, -1) {
+			l.reportLiteral(c.StartPos+loc[0]+m[4], c.StartPos+loc[0]+m[5], block[m[2]:m[3]])
+		}
+	}
+}
+
+// checkWhere reports unindexed and literal property predicates.
+func (l *linter) checkWhere(c *ASTClause, labels map[string][]string, reported map[string]bool) {
+	for _, m := range lintPredicatePattern.FindAllStringSubmatchIndex(c.RawText, -1) {
+		variable := c.RawText[m[2]:m[3]]
+		prop := c.RawText[m[4]:m[5]]
+		op := strings.ToUpper(strings.Join(strings.Fields(c.RawText[m[6]:m[7]]), " "))
+
+		switch op {
+		case "=", "<", ">", "<=", ">=", "IN", "STARTS WITH":
+			l.checkIndex(variable, prop, labels[variable], c.StartPos+m[2], reported)
+		}
+		if m[8] >= 0 {
+			l.reportLiteral(c.StartPos+m[8], c.StartPos+m[9], prop)
+		}
+	}
+}
+
+// checkIndex reports a lookup on var.prop when none of the variable's labels
+// has an index or uniqueness constraint on the property.
+func (l *linter) checkIndex(variable, prop string, labels []string, pos int, reported map[string]bool) {
+	if l.schema == nil || len(labels) == 0 {
+		return
+	}
+	key := variable + "." + prop
+	if reported[key] {
+		return
+	}
+	for _, label := range labels {
+		if lintHasIndex(l.schema, label, prop) {
+			return
+		}
+	}
+	reported[key] = true
+	v := variable
+	if v == "" {
+		v = "n"
+	}
+	l.add(LintMissingIndex, LintWarning, pos,
+		fmt.Sprintf("No index on :%s(%s); the lookup on %s scans every :%s node", labels[0], prop, key, labels[0]),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS FOR (%s:%s) ON (%s.%s)", v, labels[0], v, prop))
+}
+
+// reportLiteral reports a literal at [start, end) in the query.
+func (l *linter) reportLiteral(start, end int, prop string) {
+	l.add(LintUnparameterizedLiteral, LintInfo, start,
+		fmt.Sprintf("Literal %s for %s prevents plan and result caching across values", l.query[start:end], prop),
+		fmt.Sprintf("Pass it as a parameter: $%s", prop))
+}
+
+// lintHasIndex reports whether label.prop is covered by a property, range or
+// composite (leading property) index, or a uniqueness constraint.
+func lintHasIndex(schema *storage.SchemaManager, label, prop string) bool {
+	if _, ok := schema.GetPropertyIndex(label, prop); ok {
+		return true
+	}
+	for _, idx := range schema.GetCompositeIndexesForLabel(label) {
+		if len(idx.Properties) > 0 && idx.Properties[0] == prop {
+			return true
+		}
+	}
+	for _, raw := range schema.GetIndexes() {
+		idx, ok := raw.(map[string]interface{})
+		if !ok || idx["label"] != label {
+			continue
+		}
+		if idx["property"] == prop && idx["type"] == "RANGE" {
+			return true
+		}
+		if props, ok := idx["properties"].([]string); ok && len(props) > 0 && props[0] == prop {
+			return true
+		}
+	}
+	constraints := schema.GetConstraints()
+	for i := range constraints {
+		if constraints[i].Label == label && constraints[i].Property == prop {
+			return true
+		}
+	}
+	return false
+}
+
+// patternMentions reports whether an identifier appears as a whole word in
+// pattern text (as a node variable or inside an inline property map).
+func patternMentions(text, ident string) bool {
+	for i := 0; ; {
+		j := strings.Index(text[i:], ident)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(ident)
+		before := start == 0 || !isIdentChar(text[start-1])
+		after := end == len(text) || !isIdentChar(text[end])
+		if before && after && (start == 0 || text[start-1] != '.') {
+			return true
+		}
+		i = end
+	}
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// maskStringLiterals replaces the contents of quoted strings with spaces,
+// keeping the quotes and every byte offset.
+func maskStringLiterals(query string) string {
+	masked := []byte(query)
+	var quote byte
+	for i := 0; i < len(masked); i++ {
+		c := masked[i]
+		switch {
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case quote != 0 && c == '\\' && i+1 < len(masked):
+			masked[i], masked[i+1] = ' ', ' '
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			masked[i] = ' '
+		}
+	}
+	return string(masked)
+}
+
+// callDbLint implements CALL db.lint(query).
+func (e *StorageExecutor) callDbLint(cypher string) (*ExecuteResult, error) {
+	query, err := procedureStringArg(cypher, "db.lint", "a query string")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ExecuteResult{
+		Columns: []string{"code", "severity", "message", "suggestion", "position"},
+		Rows:    [][]interface{}{},
+	}
+	for _, issue := range e.Lint(query) {
+		result.Rows = append(result.Rows, []interface{}{
+			issue.Code, string(issue.Severity), issue.Message, issue.Suggestion, int64(issue.Position),
+		})
+	}
+	return result, nil
+}
+
+// parseQuotedString reads a leading single- or double-quoted string literal,
+// resolving backslash escapes.
+func parseQuotedString(s string) (string, bool) {
+	if s == "" || (s[0] != '\'' && s[0] != '"') {
+		return "", false
+	}
+	quote := s[0]
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(s[i])
+			}
+		case c == quote:
+			return sb.String(), true
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,149 @@
+package cypher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lintCodes(issues []LintIssue) []string {
+	codes := make([]string, len(issues))
+	for i, issue := range issues {
+		codes[i] = issue.Code
+	}
+	return codes
+}
+
+func TestLint_CartesianProduct(t *testing.T) {
+	issues := Lint("MATCH (a:Person), (b:Company) RETURN a, b", nil)
+	require.Len(t, issues, 1)
+	assert.Equal(t, LintCartesianProduct, issues[0].Code)
+	assert.Equal(t, LintWarning, issues[0].Severity)
+	assert.Equal(t, 18, issues[0].Position, "points at the disconnected pattern")
+
+	// Connected patterns, earlier bindings and explicit WITH are fine
+	for _, q := range []string{
+		"MATCH (a:Person)-[:WORKS_AT]->(c), (c)<-[:OWNS]-(b) RETURN a, b",
+		"MATCH (a:Person) MATCH (a)-[:KNOWS]->(b) RETURN b",
+		"MATCH (a:Person) WITH a MATCH (b:Company {owner: a.name}) RETURN b",
+		"MATCH (a:Person) RETURN a",
+	} {
+		assert.NotContains(t, lintCodes(Lint(q, nil)), LintCartesianProduct, q)
+	}
+
+	// A new MATCH that ignores earlier bindings is a product
+	issues = Lint("MATCH (a:Person) MATCH (b:Company) RETURN a, b", nil)
+	assert.Contains(t, lintCodes(issues), LintCartesianProduct)
+
+	// WITH drops a from scope, so the second MATCH starts fresh
+	issues = Lint("MATCH (a:Person) WITH count(a) AS total MATCH (b:Company) RETURN total, b", nil)
+	assert.NotContains(t, lintCodes(issues), LintCartesianProduct)
+}
+
+func TestLint_MissingIndex(t *testing.T) {
+	schema := storage.NewSchemaManager()
+	query := "MATCH (p:Person {email: $email})-[:KNOWS]->(f:Person) WHERE f.age > $age AND f.name CONTAINS $s RETURN f"
+
+	issues := Lint(query, schema)
+	require.Len(t, issues, 2)
+	for _, issue := range issues {
+		assert.Equal(t, LintMissingIndex, issue.Code)
+	}
+	assert.Contains(t, issues[0].Message, ":Person(email)")
+	assert.Equal(t, "CREATE INDEX IF NOT EXISTS FOR (p:Person) ON (p.email)", issues[0].Suggestion)
+	assert.Contains(t, issues[1].Message, ":Person(age)")
+
+	// Property, range and constraint indexes all cover their lookups
+	require.NoError(t, schema.AddPropertyIndex("idx_email", "Person", []string{"email"}))
+	require.NoError(t, schema.AddRangeIndex("idx_age", "Person", "age"))
+	assert.Empty(t, Lint(query, schema))
+
+	schema = storage.NewSchemaManager()
+	require.NoError(t, schema.AddUniqueConstraint("uniq_email", "Person", "email"))
+	require.NoError(t, schema.AddCompositeIndex("idx_age_name", "Person", []string{"age", "name"}))
+	assert.Empty(t, Lint(query, schema))
+
+	// Without a schema, or on unlabeled nodes, nothing can be checked
+	assert.Empty(t, Lint(query, nil))
+	assert.Empty(t, Lint("MATCH (n) WHERE n.email = $email RETURN n", storage.NewSchemaManager()))
+}
+
+func TestLint_UnparameterizedLiteral(t *testing.T) {
+	query := `MATCH (p:Person {name: 'Alice'}) WHERE p.age >= 30 AND p.city = "Oslo" RETURN p`
+	issues := Lint(query, nil)
+	require.Len(t, issues, 3)
+	for _, issue := range issues {
+		assert.Equal(t, LintUnparameterizedLiteral, issue.Code)
+		assert.Equal(t, LintInfo, issue.Severity)
+	}
+	assert.Equal(t, "'Alice'", query[issues[0].Position:issues[0].Position+7])
+	assert.Equal(t, "Pass it as a parameter: $name", issues[0].Suggestion)
+	assert.Contains(t, issues[1].Message, "30")
+	assert.Contains(t, issues[2].Message, `"Oslo"`)
+
+	assert.Empty(t, Lint("MATCH (p:Person {name: $name}) WHERE p.age >= $age RETURN p", nil))
+}
+
+func TestLint_DeprecatedSyntax(t *testing.T) {
+	tests := []struct {
+		query      string
+		suggestion string
+	}{
+		{"MATCH (n) WHERE n.id = {id} RETURN n", "Use $param"},
+		{"MATCH (n) RETURN toInt(n.x)", "Use toInteger()"},
+		{"MATCH (n) RETURN upper(n.name)", "Use toUpper()"},
+		{"MATCH (n) WHERE exists(n.name) RETURN n", "Use n.prop IS NOT NULL"},
+		{"MATCH p = (a)-[*]->(b) RETURN rels(p)", "Use relationships()"},
+		{"MATCH (a)-[:KNOWS|:LIKES]->(b) RETURN b", "Use [:A|B]"},
+		{"MATCH (a), (b) CREATE UNIQUE (a)-[:R]->(b)", "Use MERGE"},
+	}
+	for _, tt := range tests {
+		var found bool
+		for _, issue := range Lint(tt.query, nil) {
+			if issue.Code == LintDeprecatedSyntax && issue.Suggestion == tt.suggestion {
+				found = true
+			}
+		}
+		assert.True(t, found, "expected deprecation in %q", tt.query)
+	}
+
+	// Modern equivalents and look-alikes inside strings are clean
+	assert.Empty(t, Lint("MATCH (n) WHERE n.name IS NOT NULL RETURN toInteger(n.x), toUpper(n.name)", nil))
+	assert.Empty(t, Lint("MATCH (n) WHERE n.note = $note RETURN 'toInt(x) {id} MATCH (b)' AS s", nil))
+}
+
+func TestLint_SyntaxError(t *testing.T) {
+	issues := Lint("MATCH (n RETURN n", nil)
+	require.NotEmpty(t, issues)
+	assert.Equal(t, LintSyntaxError, issues[0].Code)
+	assert.Equal(t, LintError, issues[0].Severity)
+}
+
+func TestCallDbLint(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	exec := NewStorageExecutor(store)
+	ctx := context.Background()
+
+	result, err := exec.Execute(ctx, `CALL db.lint('MATCH (a:Person), (b:Person) WHERE a.name = \'Bob\' RETURN a, b')`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"code", "severity", "message", "suggestion", "position"}, result.Columns)
+	codes := make([]string, len(result.Rows))
+	for i, row := range result.Rows {
+		codes[i] = row[0].(string)
+	}
+	assert.Equal(t, []string{LintCartesianProduct, LintMissingIndex, LintUnparameterizedLiteral}, codes)
+
+	// Procedure names and write clauses inside the query are not executed
+	result, err = exec.Execute(ctx, `CALL db.lint("CREATE (n:Person {name: $name}) WITH n CALL db.labels() YIELD label RETURN label") YIELD code RETURN code`, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Rows)
+	nodes, err := store.AllNodes()
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+
+	_, err = exec.Execute(ctx, "CALL db.lint()", nil)
+	assert.Error(t, err)
+}
@@ -10,7 +10,9 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/orneryd/nornicdb/pkg/pool"
 	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/orneryd/nornicdb/pkg/value"
 )
 
 // isAggregateFunc checks if expression is an aggregate function (whitespace-tolerant)
@@ -20,7 +22,8 @@ func isAggregateFunc(expr string) bool {
 		isFunctionCallWS(expr, "avg") ||
 		isFunctionCallWS(expr, "min") ||
 		isFunctionCallWS(expr, "max") ||
-		isFunctionCallWS(expr, "collect")
+		isFunctionCallWS(expr, "collect") ||
+		isFunctionCallWS(expr, "approxcountdistinct")
 }
 
 // containsAggregateFunc checks if expression contains any aggregate function
@@ -28,7 +31,7 @@ func isAggregateFunc(expr string) bool {
 func containsAggregateFunc(expr string) bool {
 	upper := strings.ToUpper(expr)
 	// Check for aggregate function names followed by opening paren (with optional whitespace)
-	for _, fn := range []string{"COUNT", "SUM", "AVG", "MIN", "MAX", "COLLECT"} {
+	for _, fn := range []string{"COUNT", "SUM", "AVG", "MIN", "MAX", "COLLECT", "APPROXCOUNTDISTINCT"} {
 		idx := strings.Index(upper, fn)
 		if idx >= 0 {
 			// Check if followed by ( with optional whitespace
@@ -274,7 +277,11 @@ func (e *StorageExecutor) executeMatch(ctx context.Context, cypher string) (*Exe
 	var err error
 
 	if len(nodePattern.labels) > 0 {
-		nodes, err = e.storage.GetNodesByLabel(nodePattern.labels[0])
+		var where string
+		if whereIdx > 0 {
+			where = cypher[whereIdx+5 : returnIdx]
+		}
+		nodes, err = e.nodesByLabel(ctx, nodePattern.labels[0], nodePattern.properties, nodePattern.variable, where)
 	} else {
 		nodes, err = e.storage.AllNodes()
 	}
@@ -284,14 +291,14 @@ func (e *StorageExecutor) executeMatch(ctx context.Context, cypher string) (*Exe
 
 	// Apply property filter from MATCH pattern (e.g., {name: 'Alice'})
 	if len(nodePattern.properties) > 0 {
-		nodes = e.filterNodesByProperties(nodes, nodePattern.properties)
+		nodes = e.filterNodesByProperties(ctx, nodes, nodePattern.properties)
 	}
 
 	// Apply WHERE filter if present
 	if whereIdx > 0 {
 		// Find end of WHERE clause (before RETURN)
 		wherePart := cypher[whereIdx+5 : returnIdx]
-		nodes = e.filterNodes(nodes, nodePattern.variable, strings.TrimSpace(wherePart))
+		nodes = e.filterNodes(ctx, nodes, nodePattern.variable, strings.TrimSpace(wherePart))
 	}
 
 	// Handle aggregation queries
@@ -406,10 +413,21 @@ func (e *StorageExecutor) executeMatch(ctx context.Context, cypher string) (*Exe
 		}
 	}
 
-	// Build result rows with SKIP and LIMIT
+	// Build result rows with SKIP and LIMIT, from the pool (see ExecuteResult.Release)
+	result.Rows = pool.GetRowSlice()
+	result.pooled = true
+	running := runningQueryFromContext(ctx)
 	seen := make(map[string]bool) // For DISTINCT
 	rowCount := 0
 	for i, node := range nodes {
+		// Stop a killed or timed-out query between rows
+		if i&1023 == 0 {
+			if err := cancelled(ctx); err != nil {
+				result.Release()
+				return nil, err
+			}
+		}
+
 		// Apply SKIP
 		if i < skip {
 			continue
@@ -420,15 +438,16 @@ func (e *StorageExecutor) executeMatch(ctx context.Context, cypher string) (*Exe
 			break
 		}
 
-		row := make([]interface{}, len(returnItems))
-		for j, item := range returnItems {
-			row[j] = e.resolveReturnItem(item, nodePattern.variable, node)
+		row := pool.GetInterfaceSlice()
+		for _, item := range returnItems {
+			row = append(row, e.resolveReturnItem(item, nodePattern.variable, node))
 		}
 
 		// Handle DISTINCT
 		if distinct {
 			key := fmt.Sprintf("%v", row)
 			if seen[key] {
+				pool.PutInterfaceSlice(row)
 				continue
 			}
 			seen[key] = true
@@ -436,6 +455,9 @@ func (e *StorageExecutor) executeMatch(ctx context.Context, cypher string) (*Exe
 
 		result.Rows = append(result.Rows, row)
 		rowCount++
+		if running != nil {
+			running.addRow(row)
+		}
 	}
 
 	return result, nil
@@ -698,6 +720,9 @@ func (e *StorageExecutor) executeAggregation(nodes []*storage.Node, variable str
 					}
 				}
 				row[i] = collected
+
+			case strings.HasPrefix(upperExpr, "APPROXCOUNTDISTINCT("):
+				row[i] = e.approxCountDistinctNodes(item.expr, variable, groupNodes)
 			}
 		}
 
@@ -954,6 +979,9 @@ func (e *StorageExecutor) executeAggregationSingleGroup(nodes []*storage.Node, v
 			}
 			row[i] = collected
 
+		case strings.HasPrefix(upperExpr, "APPROXCOUNTDISTINCT("):
+			row[i] = e.approxCountDistinctNodes(item.expr, variable, nodes)
+
 		default:
 			// Non-aggregate in aggregation query - return first value
 			if len(nodes) > 0 {
@@ -1230,6 +1258,9 @@ func (e *StorageExecutor) executeMatchRelationshipsWithClause(ctx context.Contex
 			for _, ae := range aggregateExprs {
 				inner := extractFuncInner(ae.expr)
 				switch {
+				case isAggregateFuncName(ae.expr, "approxcountdistinct"):
+					values[ae.alias] = e.approxCountDistinctPaths(ae.expr, groupPaths, matches)
+
 				case isAggregateFuncName(ae.expr, "count") && strings.Contains(strings.ToUpper(inner), "DISTINCT"):
 					// COUNT(DISTINCT ...) - extract after DISTINCT
 					distinctInner := strings.TrimSpace(inner[8:]) // skip "DISTINCT"
@@ -1556,7 +1587,7 @@ func (e *StorageExecutor) executeMatchWithClause(ctx context.Context, cypher str
 	var err error
 
 	if len(nodePattern.labels) > 0 {
-		nodes, err = e.storage.GetNodesByLabel(nodePattern.labels[0])
+		nodes, err = e.nodesByLabel(ctx, nodePattern.labels[0], nodePattern.properties, nodePattern.variable, whereClause)
 	} else {
 		nodes, err = e.storage.AllNodes()
 	}
@@ -1566,12 +1597,12 @@ func (e *StorageExecutor) executeMatchWithClause(ctx context.Context, cypher str
 
 	// Apply property filter from MATCH pattern (e.g., {name: 'Alice'})
 	if len(nodePattern.properties) > 0 {
-		nodes = e.filterNodesByProperties(nodes, nodePattern.properties)
+		nodes = e.filterNodesByProperties(ctx, nodes, nodePattern.properties)
 	}
 
 	// Apply WHERE clause filter if present
 	if whereClause != "" {
-		nodes = e.filterNodesByWhereClause(nodes, whereClause, nodePattern.variable)
+		nodes = e.filterNodesByWhereClause(ctx, nodes, whereClause, nodePattern.variable)
 	}
 
 	// Extract WITH clause expressions
@@ -1739,6 +1770,9 @@ func (e *StorageExecutor) executeMatchWithClause(ctx context.Context, cypher str
 			for _, ae := range aggregateExprs {
 				inner := extractFuncInner(ae.expr)
 				switch {
+				case isAggregateFuncName(ae.expr, "approxcountdistinct"):
+					values[ae.alias] = e.approxCountDistinctNodes(ae.expr, nodePattern.variable, groupNodes)
+
 				case isAggregateFuncName(ae.expr, "count") && strings.Contains(strings.ToUpper(inner), "DISTINCT"):
 					// COUNT(DISTINCT ...) - extract after DISTINCT
 					distinctInner := strings.TrimSpace(inner[8:]) // skip "DISTINCT"
@@ -2344,7 +2378,7 @@ func (e *StorageExecutor) evaluateWithWhereCondition(whereClause string, values
 
 // filterNodesByWhereClause filters nodes based on a WHERE clause condition.
 // Uses evaluateWhere for consistent condition evaluation.
-func (e *StorageExecutor) filterNodesByWhereClause(nodes []*storage.Node, whereClause, variable string) []*storage.Node {
+func (e *StorageExecutor) filterNodesByWhereClause(ctx context.Context, nodes []*storage.Node, whereClause, variable string) []*storage.Node {
 	if whereClause == "" {
 		return nodes
 	}
@@ -2353,7 +2387,7 @@ func (e *StorageExecutor) filterNodesByWhereClause(nodes []*storage.Node, whereC
 		return e.evaluateWhere(node, variable, whereClause)
 	}
 
-	return parallelFilterNodes(nodes, filterFn)
+	return parallelFilterNodesWithConfig(parallelConfigFor(ctx), nodes, filterFn)
 }
 
 // orderSpec represents a single ORDER BY column specification
@@ -2375,10 +2409,21 @@ func (e *StorageExecutor) orderResultRows(rows [][]interface{}, columns []string
 		return rows
 	}
 
+	// Convert the sort columns once per row, not once per comparison
+	keys := make([]orderKey, len(rows)*len(orderSpecs))
+	sorted := make([]orderedRow, len(rows))
+	for i, row := range rows {
+		rowKeys := keys[i*len(orderSpecs) : (i+1)*len(orderSpecs)]
+		for k, spec := range orderSpecs {
+			rowKeys[k] = newOrderKey(row[spec.colIdx])
+		}
+		sorted[i] = orderedRow{row: row, keys: rowKeys}
+	}
+
 	// Sort rows using all order specifications
-	sort.Slice(rows, func(i, j int) bool {
-		for _, spec := range orderSpecs {
-			cmp := e.compareOrderValues(rows[i][spec.colIdx], rows[j][spec.colIdx])
+	sort.Slice(sorted, func(i, j int) bool {
+		for k, spec := range orderSpecs {
+			cmp := compareOrderKeys(&sorted[i].keys[k], &sorted[j].keys[k])
 			if cmp != 0 {
 				if spec.descending {
 					return cmp > 0
@@ -2390,9 +2435,64 @@ func (e *StorageExecutor) orderResultRows(rows [][]interface{}, columns []string
 		return false // All columns equal, maintain order
 	})
 
+	for i := range sorted {
+		rows[i] = sorted[i].row
+	}
 	return rows
 }
 
+// orderedRow pairs a result row with its ORDER BY keys while sorting.
+type orderedRow struct {
+	row  []interface{}
+	keys []orderKey
+}
+
+// orderKey is an ORDER BY sort key. Numeric strings hold their number, as
+// toFloat64 parses them; the %v text used for values without a natural
+// order is formatted at most once.
+type orderKey struct {
+	val     value.Value
+	raw     interface{}
+	text    string
+	hasText bool
+}
+
+func newOrderKey(x interface{}) orderKey {
+	v := value.FromAny(x)
+	if s, ok := v.AsString(); ok {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			v = value.Float(f)
+		}
+	}
+	return orderKey{val: v, raw: x}
+}
+
+func (k *orderKey) formatted() string {
+	if !k.hasText {
+		k.text = fmt.Sprintf("%v", k.raw)
+		k.hasText = true
+	}
+	return k.text
+}
+
+// compareOrderKeys orders nulls last, numbers numerically and everything
+// else by its %v text.
+func compareOrderKeys(a, b *orderKey) int {
+	aNull, bNull := a.val.IsNull(), b.val.IsNull()
+	switch {
+	case aNull && bNull:
+		return 0
+	case aNull:
+		return 1 // nil goes last
+	case bNull:
+		return -1 // non-nil before nil
+	}
+	if cmp, ok := value.Compare(a.val, b.val); ok {
+		return cmp
+	}
+	return strings.Compare(a.formatted(), b.formatted())
+}
+
 // parseOrderBySpecs parses "col1 ASC, col2 DESC" into orderSpec slice
 func (e *StorageExecutor) parseOrderBySpecs(orderExpr string, columns []string) []orderSpec {
 	var specs []orderSpec
@@ -2436,40 +2536,8 @@ func (e *StorageExecutor) parseOrderBySpecs(orderExpr string, columns []string)
 // compareOrderValues compares two values for ordering
 // Returns -1 if a < b, 0 if a == b, 1 if a > b
 func (e *StorageExecutor) compareOrderValues(a, b interface{}) int {
-	// Handle nil values (nulls last)
-	if a == nil && b == nil {
-		return 0
-	}
-	if a == nil {
-		return 1 // nil goes last
-	}
-	if b == nil {
-		return -1 // non-nil before nil
-	}
-
-	// Try numeric comparison
-	numA, okA := toFloat64(a)
-	numB, okB := toFloat64(b)
-	if okA && okB {
-		if numA < numB {
-			return -1
-		}
-		if numA > numB {
-			return 1
-		}
-		return 0
-	}
-
-	// String comparison
-	strA := fmt.Sprintf("%v", a)
-	strB := fmt.Sprintf("%v", b)
-	if strA < strB {
-		return -1
-	}
-	if strA > strB {
-		return 1
-	}
-	return 0
+	ka, kb := newOrderKey(a), newOrderKey(b)
+	return compareOrderKeys(&ka, &kb)
 }
 
 // splitOutsideParens splits a string by delimiter, respecting parentheses
@@ -2503,7 +2571,7 @@ func splitOutsideParens(s string, delim rune) []string {
 // filterNodesByProperties filters nodes to only include those matching ALL specified properties.
 // This is used for MATCH pattern property filtering like MATCH (n:Label {prop: value}).
 // Uses parallel execution for large datasets (>1000 nodes) for improved performance.
-func (e *StorageExecutor) filterNodesByProperties(nodes []*storage.Node, props map[string]interface{}) []*storage.Node {
+func (e *StorageExecutor) filterNodesByProperties(ctx context.Context, nodes []*storage.Node, props map[string]interface{}) []*storage.Node {
 	if len(props) == 0 {
 		return nodes
 	}
@@ -2523,7 +2591,7 @@ func (e *StorageExecutor) filterNodesByProperties(nodes []*storage.Node, props m
 	}
 
 	// Use parallel filtering for large datasets
-	return parallelFilterNodes(nodes, filterFn)
+	return parallelFilterNodesWithConfig(parallelConfigFor(ctx), nodes, filterFn)
 }
 
 // executeMatchUnwind handles MATCH ... UNWIND ... RETURN queries
@@ -2563,7 +2631,7 @@ func (e *StorageExecutor) executeMatchUnwind(ctx context.Context, cypher string)
 	var err error
 
 	if len(nodePattern.labels) > 0 {
-		nodes, err = e.storage.GetNodesByLabel(nodePattern.labels[0])
+		nodes, err = e.nodesByLabel(ctx, nodePattern.labels[0], nodePattern.properties, nodePattern.variable, whereClause)
 	} else {
 		nodes, err = e.storage.AllNodes()
 	}
@@ -2573,12 +2641,12 @@ func (e *StorageExecutor) executeMatchUnwind(ctx context.Context, cypher string)
 
 	// Apply property filter from MATCH pattern
 	if len(nodePattern.properties) > 0 {
-		nodes = e.filterNodesByProperties(nodes, nodePattern.properties)
+		nodes = e.filterNodesByProperties(ctx, nodes, nodePattern.properties)
 	}
 
 	// Apply WHERE clause filter if present
 	if whereClause != "" {
-		nodes = e.filterNodesByWhereClause(nodes, whereClause, nodePattern.variable)
+		nodes = e.filterNodesByWhereClause(ctx, nodes, whereClause, nodePattern.variable)
 	}
 
 	// Parse UNWIND clause: UNWIND expr AS variable
@@ -2927,7 +2995,7 @@ func (e *StorageExecutor) executeMatchWithUnwind(ctx context.Context, cypher str
 	var err error
 
 	if len(nodePattern.labels) > 0 {
-		nodes, err = e.storage.GetNodesByLabel(nodePattern.labels[0])
+		nodes, err = e.nodesByLabel(ctx, nodePattern.labels[0], nodePattern.properties, nodePattern.variable, matchWhere)
 	} else {
 		nodes, err = e.storage.AllNodes()
 	}
@@ -2936,11 +3004,11 @@ func (e *StorageExecutor) executeMatchWithUnwind(ctx context.Context, cypher str
 	}
 
 	if len(nodePattern.properties) > 0 {
-		nodes = e.filterNodesByProperties(nodes, nodePattern.properties)
+		nodes = e.filterNodesByProperties(ctx, nodes, nodePattern.properties)
 	}
 
 	if matchWhere != "" {
-		nodes = e.filterNodesByWhereClause(nodes, matchWhere, nodePattern.variable)
+		nodes = e.filterNodesByWhereClause(ctx, nodes, matchWhere, nodePattern.variable)
 	}
 
 	// Step 2: Process first WITH clause - compute filteredLabels for each node
@@ -3220,11 +3288,11 @@ func (e *StorageExecutor) executeMultiMatch(ctx context.Context, cypher string)
 	}
 
 	// Execute first MATCH and get initial bindings
-	bindings := e.executeFirstMatch(matchClauses[0])
+	bindings := e.executeFirstMatch(ctx, matchClauses[0], whereClause)
 
 	// Execute subsequent MATCH clauses with bindings
 	for i := 1; i < len(matchClauses); i++ {
-		bindings = e.executeChainedMatch(matchClauses[i], bindings)
+		bindings = e.executeChainedMatch(ctx, matchClauses[i], bindings, whereClause)
 	}
 
 	// Apply WHERE filter if present
@@ -3294,6 +3362,14 @@ func (e *StorageExecutor) executeMultiMatch(ctx context.Context, cypher string)
 				// Aggregation function (whitespace-tolerant)
 				inner := extractFuncInner(item.expr)
 				switch {
+				case isAggregateFuncName(item.expr, "approxcountdistinct"):
+					sketch := newHLLSketch()
+					arg := approxDistinctArg(item.expr)
+					for _, b := range groupBindings {
+						sketch.add(e.approxDistinctValue(arg, b, nil))
+					}
+					row[i] = sketch.estimate()
+
 				case isAggregateFuncName(item.expr, "count"):
 					if inner == "*" {
 						row[i] = int64(len(groupBindings))
@@ -3459,8 +3535,9 @@ func splitMatchClauses(cypher string, whereIdx, returnIdx int) []string {
 // binding represents variable bindings from multiple MATCH clauses
 type binding map[string]*storage.Node
 
-// executeFirstMatch executes the first MATCH and returns initial bindings
-func (e *StorageExecutor) executeFirstMatch(pattern string) []binding {
+// executeFirstMatch executes the first MATCH and returns initial bindings.
+// Simple predicates of the query's where clause are pushed down to virtual labels.
+func (e *StorageExecutor) executeFirstMatch(ctx context.Context, pattern, where string) []binding {
 	var bindings []binding
 
 	// Check for relationship pattern
@@ -3489,13 +3566,13 @@ func (e *StorageExecutor) executeFirstMatch(pattern string) []binding {
 		nodePattern := e.parseNodePattern(pattern)
 		var nodes []*storage.Node
 		if len(nodePattern.labels) > 0 {
-			nodes, _ = e.storage.GetNodesByLabel(nodePattern.labels[0])
+			nodes, _ = e.nodesByLabel(ctx, nodePattern.labels[0], nodePattern.properties, nodePattern.variable, where)
 		} else {
 			nodes, _ = e.storage.AllNodes()
 		}
 
 		if len(nodePattern.properties) > 0 {
-			nodes = e.filterNodesByProperties(nodes, nodePattern.properties)
+			nodes = e.filterNodesByProperties(ctx, nodes, nodePattern.properties)
 		}
 
 		for _, node := range nodes {
@@ -3508,10 +3585,16 @@ func (e *StorageExecutor) executeFirstMatch(pattern string) []binding {
 	return bindings
 }
 
-// executeChainedMatch executes a subsequent MATCH against existing bindings
-func (e *StorageExecutor) executeChainedMatch(pattern string, existingBindings []binding) []binding {
+// executeChainedMatch executes a subsequent MATCH against existing bindings.
+// Simple predicates of the query's where clause are pushed down to virtual labels.
+func (e *StorageExecutor) executeChainedMatch(ctx context.Context, pattern string, existingBindings []binding, where string) []binding {
 	var newBindings []binding
 
+	// Nodes of a simple node pattern do not depend on the bindings, so they
+	// are fetched once
+	var patternNodes []*storage.Node
+	fetched := false
+
 	for _, existing := range existingBindings {
 		// Check for relationship pattern
 		if strings.Contains(pattern, "-[") || strings.Contains(pattern, "]-") {
@@ -3562,18 +3645,20 @@ func (e *StorageExecutor) executeChainedMatch(pattern string, existingBindings [
 				continue
 			}
 
-			var nodes []*storage.Node
-			if len(nodePattern.labels) > 0 {
-				nodes, _ = e.storage.GetNodesByLabel(nodePattern.labels[0])
-			} else {
-				nodes, _ = e.storage.AllNodes()
-			}
+			if !fetched {
+				if len(nodePattern.labels) > 0 {
+					patternNodes, _ = e.nodesByLabel(ctx, nodePattern.labels[0], nodePattern.properties, nodePattern.variable, where)
+				} else {
+					patternNodes, _ = e.storage.AllNodes()
+				}
 
-			if len(nodePattern.properties) > 0 {
-				nodes = e.filterNodesByProperties(nodes, nodePattern.properties)
+				if len(nodePattern.properties) > 0 {
+					patternNodes = e.filterNodesByProperties(ctx, patternNodes, nodePattern.properties)
+				}
+				fetched = true
 			}
 
-			for _, node := range nodes {
+			for _, node := range patternNodes {
 				b := make(binding)
 				for k, v := range existing {
 					b[k] = v
@@ -3659,6 +3744,12 @@ func (e *StorageExecutor) evaluateBindingWhere(b binding, whereClause string) bo
 				if node := b[varName]; node != nil {
 					actualVal := node.Properties[propName]
 					expectedVal := e.parseValue(right)
+					// Join predicate: compare with another bound node's property
+					if rDot := strings.Index(right, "."); rDot > 0 {
+						if other := b[right[:rDot]]; other != nil {
+							expectedVal = other.Properties[right[rDot+1:]]
+						}
+					}
 
 					switch op {
 					case "=":
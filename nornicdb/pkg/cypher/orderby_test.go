@@ -2,6 +2,8 @@ package cypher
 
 import (
 	"context"
+	"math"
+	"reflect"
 	"testing"
 
 	"github.com/orneryd/nornicdb/pkg/storage"
@@ -207,6 +209,34 @@ func TestOrderByStringValues(t *testing.T) {
 	})
 }
 
+func TestOrderResultRowsMixedValues(t *testing.T) {
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+
+	// Numeric strings sort as numbers, other values by their text, nulls last
+	rows := [][]interface{}{
+		{nil}, {"b"}, {"10"}, {true}, {int64(3)}, {"a"}, {"9"},
+	}
+	got := exec.orderResultRows(rows, []string{"v"}, "v")
+	want := []interface{}{int64(3), "9", "10", "a", "b", true, nil}
+	for i, row := range got {
+		if !reflect.DeepEqual(row[0], want[i]) {
+			t.Fatalf("row %d = %v, want order %v", i, row[0], want)
+		}
+	}
+
+	// Ties on the first key fall through to the second; large integers
+	// compare exactly
+	rows = [][]interface{}{
+		{"x", int64(math.MaxInt64 - 1)},
+		{"y", int64(1)},
+		{"x", int64(math.MaxInt64)},
+	}
+	got = exec.orderResultRows(rows, []string{"k", "n"}, "k ASC, n DESC")
+	if got[0][1] != int64(math.MaxInt64) || got[1][1] != int64(math.MaxInt64-1) || got[2][0] != "y" {
+		t.Fatalf("multi-key order = %v", got)
+	}
+}
+
 func TestLimitSkipEdgeCases(t *testing.T) {
 	store := storage.NewMemoryEngine()
 	exec := NewStorageExecutor(store)
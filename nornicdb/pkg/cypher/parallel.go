@@ -97,12 +97,18 @@ type FilterFunc func(node *storage.Node) bool
 // Returns:
 //   - Slice of nodes that passed the filter
 func parallelFilterNodes(nodes []*storage.Node, filterFn FilterFunc) []*storage.Node {
-	if !parallelConfig.Enabled || len(nodes) < parallelConfig.MinBatchSize {
+	return parallelFilterNodesWithConfig(parallelConfig, nodes, filterFn)
+}
+
+// parallelFilterNodesWithConfig is parallelFilterNodes with an explicit
+// configuration, for queries that override it (see QueryOptions.Runtime).
+func parallelFilterNodesWithConfig(config ParallelConfig, nodes []*storage.Node, filterFn FilterFunc) []*storage.Node {
+	if !config.Enabled || len(nodes) < config.MinBatchSize {
 		// Sequential fallback for small datasets
 		return sequentialFilterNodes(nodes, filterFn)
 	}
 
-	numWorkers := parallelConfig.MaxWorkers
+	numWorkers := config.MaxWorkers
 	if numWorkers > len(nodes) {
 		numWorkers = len(nodes)
 	}
@@ -0,0 +1,169 @@
+// Package cypher - relationship counts of bound nodes.
+//
+// size((n)-[:TYPE]->()) and COUNT { (n)-[:TYPE]->() } count the
+// relationships of a node that is already bound. When the far end of the
+// pattern is unconstrained they are answered from the storage engine's
+// degree counters (see storage.DegreeEngine) without reading a single
+// relationship, which keeps recommendation queries on hub nodes cheap.
+// Patterns that constrain the far end or the relationship properties are
+// counted by expanding the node's relationships.
+
+package cypher
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+var (
+	// Relationships without brackets: -->, <-- and --
+	bareOutgoingRelRe = regexp.MustCompile(`\)\s*-->\s*\(`)
+	bareIncomingRelRe = regexp.MustCompile(`\)\s*<--\s*\(`)
+	bareBothRelRe     = regexp.MustCompile(`\)\s*--\s*\(`)
+
+	// COUNT { ... } as a whole expression
+	countSubqueryExprRe = regexp.MustCompile(`(?is)^COUNT\s*\{(.*)\}$`)
+)
+
+// normalizeBareRelationships rewrites (a)-->(b), (a)<--(b) and (a)--(b)
+// with empty brackets, as parseTraversalPattern expects.
+func normalizeBareRelationships(pattern string) string {
+	pattern = bareIncomingRelRe.ReplaceAllString(pattern, ")<-[]-(")
+	pattern = bareOutgoingRelRe.ReplaceAllString(pattern, ")-[]->(")
+	return bareBothRelRe.ReplaceAllString(pattern, ")-[]-(")
+}
+
+// evaluateCountSubqueryExpr evaluates an expression that is a whole
+// COUNT { pattern } subquery. Returns false if expr is not one, or its
+// pattern is not a single relationship from a bound node.
+func (e *StorageExecutor) evaluateCountSubqueryExpr(expr string, nodes map[string]*storage.Node) (int64, bool) {
+	m := countSubqueryExprRe.FindStringSubmatch(expr)
+	if m == nil || strings.Contains(m[1], "{") || strings.Contains(m[1], "}") {
+		return 0, false
+	}
+	return e.patternCount(m[1], nodes)
+}
+
+// patternCount counts the matches of a single-hop relationship pattern
+// with a bound node at one end, like (n)-[:KNOWS]->() or
+// MATCH ()-[:KNOWS]->(n). Returns false if pattern is not one.
+func (e *StorageExecutor) patternCount(pattern string, nodes map[string]*storage.Node) (int64, bool) {
+	pattern = strings.TrimSpace(pattern)
+	if len(pattern) > 6 && strings.EqualFold(pattern[:6], "MATCH ") {
+		pattern = strings.TrimSpace(pattern[6:])
+	}
+	if !strings.HasPrefix(pattern, "(") {
+		return 0, false
+	}
+	pattern = normalizeBareRelationships(pattern)
+	if strings.TrimSpace(pathPatternRe.FindString(pattern)) != pattern {
+		return 0, false // WHERE, chained patterns, ...
+	}
+	match := e.parseTraversalPattern(pattern)
+	if match == nil || match.Relationship.MinHops != 1 || match.Relationship.MaxHops != 1 {
+		return 0, false
+	}
+
+	node, ok := nodes[match.StartNode.variable]
+	if !ok || match.StartNode.variable == "" {
+		if node, ok = nodes[match.EndNode.variable]; !ok || match.EndNode.variable == "" {
+			return 0, false
+		}
+		match = &TraversalMatch{
+			StartNode:    match.EndNode,
+			EndNode:      match.StartNode,
+			Relationship: match.Relationship,
+		}
+		match.Relationship.Direction = reverseDirection(match.Relationship.Direction)
+	}
+	if node == nil || !e.matchesEndPattern(node, &match.StartNode) {
+		return 0, true
+	}
+
+	rel, far := match.Relationship, match.EndNode
+	bound, farBound := nodes[far.variable]
+	farBound = farBound && far.variable != ""
+	if !farBound && len(far.labels) == 0 && len(far.properties) == 0 && len(rel.Properties) == 0 {
+		count, err := storage.CountRelationships(e.storage, node.ID, rel.Direction, rel.Types...)
+		if err != nil {
+			return 0, true
+		}
+		return count, true
+	}
+
+	var edges []*storage.Edge
+	if rel.Direction != storage.DirectionIncoming {
+		out, _ := e.storage.GetOutgoingEdges(node.ID)
+		edges = append(edges, out...)
+	}
+	if rel.Direction != storage.DirectionOutgoing {
+		in, _ := e.storage.GetIncomingEdges(node.ID)
+		for _, edge := range in {
+			// Self-loops were counted as outgoing.
+			if rel.Direction == storage.DirectionIncoming || edge.StartNode != node.ID {
+				edges = append(edges, edge)
+			}
+		}
+	}
+
+	var count int64
+	for _, edge := range edges {
+		if len(rel.Types) > 0 && !e.edgeTypeMatches(edge.Type, rel.Types) {
+			continue
+		}
+		if !e.edgeMatchesProps(edge, rel.Properties) {
+			continue
+		}
+		farID := edge.EndNode
+		if edge.EndNode == node.ID && (rel.Direction == storage.DirectionIncoming || edge.StartNode != node.ID) {
+			farID = edge.StartNode
+		}
+		if farBound {
+			if bound != nil && farID == bound.ID {
+				count++
+			}
+			continue
+		}
+		farNode, err := e.storage.GetNode(farID)
+		if err != nil || farNode == nil || !e.matchesEndPattern(farNode, &far) {
+			continue
+		}
+		count++
+	}
+	return count, true
+}
+
+// edgeMatchesProps checks a relationship's properties like nodeMatchesProps.
+func (e *StorageExecutor) edgeMatchesProps(edge *storage.Edge, props map[string]interface{}) bool {
+	for key, expected := range props {
+		actual, exists := edge.Properties[key]
+		if !exists || !e.compareEqual(actual, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluatePatternSizeComparison evaluates a WHERE condition like
+// size((n)-[:KNOWS]->()) > 2 for the node bound to variable. Returns false
+// if the condition is not a size() of a pattern patternCount can count.
+func (e *StorageExecutor) evaluatePatternSizeComparison(node *storage.Node, variable, whereClause string) (bool, bool) {
+	if len(whereClause) < 5 || !strings.EqualFold(whereClause[:4], "size") {
+		return false, false
+	}
+	open := strings.IndexByte(whereClause, '(')
+	if open < 0 || strings.TrimSpace(whereClause[4:open]) != "" {
+		return false, false
+	}
+	closeIdx := findMatchingParen(whereClause, open)
+	if closeIdx < 0 {
+		return false, false
+	}
+	count, ok := e.patternCount(whereClause[open+1:closeIdx], map[string]*storage.Node{variable: node})
+	if !ok {
+		return false, false
+	}
+	return true, compareCount(count, strings.TrimSpace(whereClause[closeIdx+1:]))
+}
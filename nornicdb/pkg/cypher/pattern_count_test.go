@@ -0,0 +1,123 @@
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// edgeReadCountingEngine counts reads of a node's relationships.
+type edgeReadCountingEngine struct {
+	*storage.MemoryEngine
+	reads int
+}
+
+func (c *edgeReadCountingEngine) GetOutgoingEdges(id storage.NodeID) ([]*storage.Edge, error) {
+	c.reads++
+	return c.MemoryEngine.GetOutgoingEdges(id)
+}
+
+func (c *edgeReadCountingEngine) GetIncomingEdges(id storage.NodeID) ([]*storage.Edge, error) {
+	c.reads++
+	return c.MemoryEngine.GetIncomingEdges(id)
+}
+
+func TestPatternCount(t *testing.T) {
+	engine := &edgeReadCountingEngine{MemoryEngine: storage.NewMemoryEngine()}
+	exec := NewStorageExecutor(engine)
+	ctx := context.Background()
+
+	for _, q := range []string{
+		`CREATE (:Person {name: 'Ann'}), (:Person {name: 'Bob'}), (:Person {name: 'Cy'}), (:Movie {title: 'Up'})`,
+		`MATCH (a:Person {name: 'Ann'}), (b:Person {name: 'Bob'}) CREATE (a)-[:KNOWS {since: 2020}]->(b)`,
+		`MATCH (a:Person {name: 'Ann'}), (b:Person {name: 'Cy'}) CREATE (a)-[:KNOWS]->(b)`,
+		`MATCH (a:Person {name: 'Cy'}), (b:Person {name: 'Ann'}) CREATE (a)-[:KNOWS]->(b)`,
+		`MATCH (a:Person {name: 'Ann'}), (m:Movie) CREATE (a)-[:LIKES]->(m)`,
+		`MATCH (a:Person {name: 'Bob'}), (m:Movie) CREATE (a)-[:LIKES]->(m)`,
+	} {
+		_, err := exec.Execute(ctx, q, nil)
+		require.NoError(t, err, q)
+	}
+
+	rows := func(query string) []string {
+		t.Helper()
+		result, err := exec.Execute(ctx, query, nil)
+		require.NoError(t, err, query)
+		var out []string
+		for _, row := range result.Rows {
+			out = append(out, fmt.Sprint(row...))
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	counted := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "size outgoing by type",
+			query: `MATCH (n:Person) RETURN n.name, size((n)-[:KNOWS]->())`,
+			want:  []string{"Ann2", "Bob0", "Cy1"},
+		},
+		{
+			name:  "size incoming",
+			query: `MATCH (n:Person) RETURN n.name, size((n)<--())`,
+			want:  []string{"Ann1", "Bob1", "Cy1"},
+		},
+		{
+			name:  "count subquery either direction",
+			query: `MATCH (n:Person) RETURN n.name, count { (n)--() }`,
+			want:  []string{"Ann4", "Bob2", "Cy2"},
+		},
+		{
+			name:  "count subquery with MATCH",
+			query: `MATCH (n:Person) RETURN n.name, COUNT { MATCH (n)-[:KNOWS|LIKES]->() }`,
+			want:  []string{"Ann3", "Bob1", "Cy1"},
+		},
+		{
+			name:  "bound node at the right",
+			query: `MATCH (n:Movie) RETURN n.title, size(()-[:LIKES]->(n))`,
+			want:  []string{"Up2"},
+		},
+		{
+			name:  "size in WHERE",
+			query: `MATCH (n:Person) WHERE size((n)<-[:KNOWS]-()) > 0 RETURN n.name`,
+			want:  []string{"Ann", "Bob", "Cy"},
+		},
+		{
+			name:  "count subquery in WHERE",
+			query: `MATCH (n:Person) WHERE COUNT { (n)--() } > 2 RETURN n.name`,
+			want:  []string{"Ann"},
+		},
+	}
+	for _, tt := range counted {
+		t.Run(tt.name, func(t *testing.T) {
+			engine.reads = 0
+			assert.Equal(t, tt.want, rows(tt.query))
+			assert.Zero(t, engine.reads, "answered from degree counters")
+		})
+	}
+
+	t.Run("constrained far end is expanded", func(t *testing.T) {
+		assert.Equal(t, []string{"Ann3", "Bob1", "Cy2"},
+			rows(`MATCH (n:Person) RETURN n.name, size((n)--(:Person))`))
+		assert.Equal(t, []string{"Ann1", "Bob0", "Cy0"},
+			rows(`MATCH (n:Person) RETURN n.name, size((n)-[:KNOWS {since: 2020}]->())`))
+		assert.Equal(t, []string{"Ann"},
+			rows(`MATCH (n:Person) WHERE COUNT { (n)-->(:Movie {title: 'Up'}) } > 0 AND size((n)-[:KNOWS]->()) > 1 RETURN n.name`))
+	})
+
+	t.Run("counters follow writes", func(t *testing.T) {
+		_, err := exec.Execute(ctx, `MATCH (:Person {name: 'Ann'})-[r:KNOWS]->(:Person {name: 'Bob'}) DELETE r`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Ann1", "Bob0", "Cy1"},
+			rows(`MATCH (n:Person) RETURN n.name, size((n)-[:KNOWS]->())`))
+	})
+}
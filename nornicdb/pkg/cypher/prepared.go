@@ -0,0 +1,239 @@
+// Package cypher - prepared statements for repeated query execution.
+//
+// Execute validates, analyzes and hashes the query text on every call, and
+// looks read queries up in the result cache. A prepared statement does that
+// work once: ExecutePrepared goes straight to execution with new parameters.
+//
+// Embedded (Go):
+//
+//	stmt, err := executor.Prepare("MATCH (p:Person {id: $id}) RETURN p.name")
+//	for _, id := range ids {
+//		result, err := executor.ExecutePrepared(ctx, stmt, map[string]interface{}{"id": id})
+//	}
+//	executor.Deallocate(stmt.ID)
+//
+// Bolt clients use procedures; execute takes the parameters sent with the
+// request:
+//
+//	CALL db.prepare('MATCH (p:Person {id: $id}) RETURN p.name') YIELD id
+//	CALL db.prepared.execute($handle)   // params: {handle: ..., id: 42}
+//	CALL db.prepared.drop($handle)
+//	CALL db.prepared.list()
+package cypher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/orneryd/nornicdb/pkg/requestid"
+)
+
+// MaxPreparedStatements caps the statements one executor holds, so clients
+// that never drop their handles cannot grow memory without bound.
+const MaxPreparedStatements = 10000
+
+// Prepared statement errors.
+var (
+	ErrPreparedNotFound = errors.New("prepared statement not found")
+	ErrTooManyPrepared  = fmt.Errorf("too many prepared statements (max %d)", MaxPreparedStatements)
+)
+
+// PreparedStatement is a validated, analyzed query that can be executed many
+// times with different parameters.
+type PreparedStatement struct {
+	ID        string    // Handle for ExecutePrepared and the db.prepared procedures
+	Query     string    // Normalized query text
+	CreatedAt time.Time // When the statement was prepared
+
+	upper      string
+	info       *QueryInfo
+	options    QueryOptions // From a CYPHER prefix
+	executions atomic.Uint64
+	dropped    atomic.Bool
+}
+
+// ReadOnly reports whether the statement never writes.
+func (s *PreparedStatement) ReadOnly() bool {
+	return s.info.IsReadOnly
+}
+
+// Executions returns how many times the statement has been executed.
+func (s *PreparedStatement) Executions() uint64 {
+	return s.executions.Load()
+}
+
+// Prepare validates and analyzes a query once and registers it under a new
+// handle. The statement stays valid until Deallocate.
+func (e *StorageExecutor) Prepare(query string) (*PreparedStatement, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+	options, query, err := ParseQueryOptions(query)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.validateSyntax(query); err != nil {
+		return nil, err
+	}
+
+	stmt := &PreparedStatement{
+		ID:        uuid.New().String(),
+		Query:     query,
+		CreatedAt: time.Now(),
+		upper:     strings.ToUpper(query),
+		info:      analyzeQuery(query),
+		options:   options,
+	}
+
+	e.preparedMu.Lock()
+	defer e.preparedMu.Unlock()
+	if len(e.prepared) >= MaxPreparedStatements {
+		return nil, ErrTooManyPrepared
+	}
+	if e.prepared == nil {
+		e.prepared = make(map[string]*PreparedStatement)
+	}
+	e.prepared[stmt.ID] = stmt
+	return stmt, nil
+}
+
+// ExecutePrepared runs a prepared statement with the given parameters. It
+// skips syntax validation, query analysis and the result cache; writes still
+// invalidate cached results of other queries.
+func (e *StorageExecutor) ExecutePrepared(ctx context.Context, stmt *PreparedStatement, params map[string]interface{}) (result *ExecuteResult, err error) {
+	if stmt == nil || stmt.dropped.Load() {
+		return nil, ErrPreparedNotFound
+	}
+	ctx, running := e.startQuery(ctx, stmt.Query)
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, newQueryPanicError(requestid.FromContext(ctx), stmt.Query, r)
+		}
+		result, err = e.finishQuery(running, result, err)
+	}()
+
+	stmt.executions.Add(1)
+	markReadOnly(ctx, stmt.info)
+	ctx = context.WithValue(ctx, paramsKey, params)
+	ctx = WithQueryOptions(ctx, QueryOptionsFromContext(ctx).Merge(stmt.options))
+	result, err = e.executeAnalyzed(ctx, stmt.Query, stmt.upper, stmt.info)
+	e.invalidateAfterWrite(stmt.Query, stmt.info)
+	return result, err
+}
+
+// GetPrepared returns the prepared statement with the given handle.
+func (e *StorageExecutor) GetPrepared(id string) (*PreparedStatement, bool) {
+	e.preparedMu.RLock()
+	defer e.preparedMu.RUnlock()
+	stmt, ok := e.prepared[id]
+	return stmt, ok
+}
+
+// Deallocate releases a prepared statement. Later executions of it fail with
+// ErrPreparedNotFound. Returns false if the handle is unknown.
+func (e *StorageExecutor) Deallocate(id string) bool {
+	e.preparedMu.Lock()
+	defer e.preparedMu.Unlock()
+	stmt, ok := e.prepared[id]
+	if !ok {
+		return false
+	}
+	stmt.dropped.Store(true)
+	delete(e.prepared, id)
+	return true
+}
+
+// PreparedStatements returns all prepared statements, oldest first.
+func (e *StorageExecutor) PreparedStatements() []*PreparedStatement {
+	e.preparedMu.RLock()
+	stmts := make([]*PreparedStatement, 0, len(e.prepared))
+	for _, stmt := range e.prepared {
+		stmts = append(stmts, stmt)
+	}
+	e.preparedMu.RUnlock()
+
+	sort.Slice(stmts, func(i, j int) bool {
+		return stmts[i].CreatedAt.Before(stmts[j].CreatedAt)
+	})
+	return stmts
+}
+
+// callDbPrepare implements CALL db.prepare(query).
+func (e *StorageExecutor) callDbPrepare(cypher string) (*ExecuteResult, error) {
+	query, err := procedureStringArg(cypher, "db.prepare", "a query string")
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := e.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &ExecuteResult{
+		Columns: []string{"id", "query", "readOnly"},
+		Rows:    [][]interface{}{{stmt.ID, stmt.Query, stmt.ReadOnly()}},
+	}, nil
+}
+
+// callDbPreparedExecute implements CALL db.prepared.execute(id), running the
+// statement with the parameters of the CALL request.
+func (e *StorageExecutor) callDbPreparedExecute(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	id, err := procedureStringArg(cypher, "db.prepared.execute", "a statement id")
+	if err != nil {
+		return nil, err
+	}
+	stmt, ok := e.GetPrepared(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPreparedNotFound, id)
+	}
+	return e.ExecutePrepared(ctx, stmt, getParamsFromContext(ctx))
+}
+
+// callDbPreparedDrop implements CALL db.prepared.drop(id).
+func (e *StorageExecutor) callDbPreparedDrop(cypher string) (*ExecuteResult, error) {
+	id, err := procedureStringArg(cypher, "db.prepared.drop", "a statement id")
+	if err != nil {
+		return nil, err
+	}
+	return &ExecuteResult{
+		Columns: []string{"id", "dropped"},
+		Rows:    [][]interface{}{{id, e.Deallocate(id)}},
+	}, nil
+}
+
+// callDbPreparedList implements CALL db.prepared.list().
+func (e *StorageExecutor) callDbPreparedList() (*ExecuteResult, error) {
+	result := &ExecuteResult{
+		Columns: []string{"id", "query", "readOnly", "executions", "createdAt"},
+		Rows:    [][]interface{}{},
+	}
+	for _, stmt := range e.PreparedStatements() {
+		result.Rows = append(result.Rows, []interface{}{
+			stmt.ID, stmt.Query, stmt.ReadOnly(), int64(stmt.Executions()), stmt.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return result, nil
+}
+
+// procedureStringArg returns the leading string argument of CALL proc(...).
+func procedureStringArg(cypher, proc, what string) (string, error) {
+	idx := strings.Index(strings.ToUpper(cypher), strings.ToUpper(proc))
+	if idx < 0 {
+		return "", fmt.Errorf("invalid %s syntax", proc)
+	}
+	rest := strings.TrimSpace(cypher[idx+len(proc):])
+	if !strings.HasPrefix(rest, "(") {
+		return "", fmt.Errorf("invalid %s syntax: missing parentheses", proc)
+	}
+	arg, ok := parseQuotedString(strings.TrimSpace(rest[1:]))
+	if !ok {
+		return "", fmt.Errorf("%s requires %s", proc, what)
+	}
+	return arg, nil
+}
@@ -0,0 +1,111 @@
+package cypher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreparedStatement_ExecuteManyTimes(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	exec := NewStorageExecutor(store)
+	ctx := context.Background()
+
+	create, err := exec.Prepare("CREATE (p:Person {id: $id, name: $name})")
+	require.NoError(t, err)
+	assert.False(t, create.ReadOnly())
+	lookup, err := exec.Prepare("MATCH (p:Person {id: $id}) RETURN p.name")
+	require.NoError(t, err)
+	assert.True(t, lookup.ReadOnly())
+
+	// Results reflect writes in between: no stale result cache hits
+	for i, name := range []string{"Alice", "Bob", "Carol"} {
+		_, err := exec.ExecutePrepared(ctx, create, map[string]interface{}{"id": int64(i), "name": name})
+		require.NoError(t, err)
+
+		result, err := exec.ExecutePrepared(ctx, lookup, map[string]interface{}{"id": int64(i)})
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 1)
+		assert.Equal(t, name, result.Rows[0][0])
+	}
+	assert.Equal(t, uint64(3), lookup.Executions())
+
+	// Prepared writes invalidate cached results of ordinary queries
+	count, err := exec.Execute(ctx, "MATCH (p:Person) RETURN count(p)", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, count.Rows[0][0])
+	_, err = exec.ExecutePrepared(ctx, create, map[string]interface{}{"id": int64(3), "name": "Dan"})
+	require.NoError(t, err)
+	count, err = exec.Execute(ctx, "MATCH (p:Person) RETURN count(p)", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, count.Rows[0][0])
+}
+
+func TestPreparedStatement_Lifecycle(t *testing.T) {
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+	ctx := context.Background()
+
+	_, err := exec.Prepare("MATCH (n RETURN n")
+	assert.Error(t, err, "syntax is validated at prepare time")
+	_, err = exec.Prepare("  ")
+	assert.Error(t, err)
+
+	stmt, err := exec.Prepare("RETURN $x AS x")
+	require.NoError(t, err)
+	got, ok := exec.GetPrepared(stmt.ID)
+	require.True(t, ok)
+	assert.Same(t, stmt, got)
+	assert.Len(t, exec.PreparedStatements(), 1)
+
+	assert.True(t, exec.Deallocate(stmt.ID))
+	assert.False(t, exec.Deallocate(stmt.ID))
+	_, err = exec.ExecutePrepared(ctx, stmt, nil)
+	assert.ErrorIs(t, err, ErrPreparedNotFound)
+	_, err = exec.ExecutePrepared(ctx, nil, nil)
+	assert.ErrorIs(t, err, ErrPreparedNotFound)
+	assert.Empty(t, exec.PreparedStatements())
+}
+
+func TestPreparedStatement_Procedures(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	exec := NewStorageExecutor(store)
+	ctx := context.Background()
+
+	result, err := exec.Execute(ctx, "CALL db.prepare('CREATE (n:Item {sku: $sku}) RETURN n.sku AS sku')", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "query", "readOnly"}, result.Columns)
+	id := result.Rows[0][0].(string)
+	assert.Equal(t, false, result.Rows[0][2])
+
+	// Execute uses the request parameters; repeated identical calls all run
+	for i := 0; i < 2; i++ {
+		result, err = exec.Execute(ctx, "CALL db.prepared.execute($handle)",
+			map[string]interface{}{"handle": id, "sku": "A-1"})
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 1)
+		assert.Equal(t, "A-1", result.Rows[0][0])
+	}
+	nodes, err := store.GetNodesByLabel("Item")
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2)
+
+	result, err = exec.Execute(ctx, "CALL db.prepared.list()", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, id, result.Rows[0][0])
+	assert.EqualValues(t, 2, result.Rows[0][3])
+
+	result, err = exec.Execute(ctx, "CALL db.prepared.drop($handle)", map[string]interface{}{"handle": id})
+	require.NoError(t, err)
+	assert.Equal(t, true, result.Rows[0][1])
+	_, err = exec.Execute(ctx, "CALL db.prepared.execute($handle)", map[string]interface{}{"handle": id})
+	assert.ErrorIs(t, err, ErrPreparedNotFound)
+
+	// Procedure names inside the prepared query do not change routing
+	result, err = exec.Execute(ctx, "CALL db.prepare('CALL db.labels()')", nil)
+	require.NoError(t, err)
+	assert.Len(t, result.Rows[0][0], 36)
+}
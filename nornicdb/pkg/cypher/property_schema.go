@@ -0,0 +1,216 @@
+// Package cypher - procedures managing the property schema registry.
+//
+// The registry (see storage.PropertySchema) describes the properties
+// expected per node label and relationship type, and whether violating
+// writes are rejected (strict), reported (warn) or ignored (off):
+//
+//	CALL nornicdb.schema.define('label', 'Person', {name: 'STRING NOT NULL', age: 'INTEGER'}, true)
+//	CALL nornicdb.schema.define('relationship', 'KNOWS', {since: 'DATE'})
+//	CALL nornicdb.schema.mode('strict')
+//	CALL nornicdb.schema.registry()
+//	CALL nornicdb.schema.drop('label', 'Person')
+//
+// The optional fourth argument of define closes the schema, so properties
+// it does not declare are violations too. Types may end with a default set
+// on creates without the property, in any mode:
+//
+//	CALL nornicdb.schema.define('label', 'Post', {id: 'STRING DEFAULT randomUUID()', createdAt: 'INTEGER DEFAULT timestamp()'})
+package cypher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// propertySchema returns the storage engine's property schema registry.
+func (e *StorageExecutor) propertySchema() (*storage.PropertySchema, error) {
+	if pe, ok := e.storage.(storage.PropertySchemaEngine); ok {
+		if schema := pe.PropertySchema(); schema != nil {
+			return schema, nil
+		}
+	}
+	return nil, fmt.Errorf("property schemas are not supported by this storage engine")
+}
+
+// procedureArgs evaluates the literal arguments of a CALL to proc.
+func (e *StorageExecutor) procedureArgs(cypher, proc string) ([]interface{}, error) {
+	idx := strings.Index(strings.ToUpper(cypher), strings.ToUpper(proc))
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid %s syntax", proc)
+	}
+	open := idx + len(proc)
+	for open < len(cypher) && cypher[open] == ' ' {
+		open++
+	}
+	if open >= len(cypher) || cypher[open] != '(' {
+		return nil, fmt.Errorf("invalid %s syntax: missing parentheses", proc)
+	}
+	close := e.findMatchingParen(cypher, open)
+	if close < 0 {
+		return nil, fmt.Errorf("invalid %s syntax: unmatched parenthesis", proc)
+	}
+	inner := strings.TrimSpace(cypher[open+1 : close])
+	if inner == "" {
+		return nil, nil
+	}
+	parts := splitParamsCarefully(inner)
+	args := make([]interface{}, len(parts))
+	for i, part := range parts {
+		args[i] = e.evaluateExpressionWithContext(strings.TrimSpace(part), nil, nil)
+	}
+	return args, nil
+}
+
+// schemaKind returns whether kind names node labels or relationship types.
+func schemaKind(proc string, kind interface{}) (relationship bool, err error) {
+	s, _ := kind.(string)
+	switch strings.ToLower(s) {
+	case "label", "node":
+		return false, nil
+	case "relationship", "type":
+		return true, nil
+	}
+	return false, fmt.Errorf("%s: kind must be 'label' or 'relationship', got %v", proc, kind)
+}
+
+// callNornicDbSchemaRegistry implements CALL nornicdb.schema.registry(),
+// listing the defined schemas with the current mode.
+func (e *StorageExecutor) callNornicDbSchemaRegistry() (*ExecuteResult, error) {
+	schema, err := e.propertySchema()
+	if err != nil {
+		return nil, err
+	}
+	mode := string(schema.Mode())
+	result := &ExecuteResult{Columns: []string{"kind", "name", "properties", "closed", "mode"}}
+	add := func(kind string, entries []storage.EntitySchema) {
+		for _, entry := range entries {
+			props := make(map[string]interface{}, len(entry.Properties))
+			for name, def := range entry.Properties {
+				props[name] = def.String()
+			}
+			result.Rows = append(result.Rows, []interface{}{kind, entry.Name, props, entry.Closed, mode})
+		}
+	}
+	add("label", schema.Labels())
+	add("relationship", schema.RelationshipTypes())
+	return result, nil
+}
+
+// callNornicDbSchemaDefine implements
+// CALL nornicdb.schema.define(kind, name, {property: type}, closed).
+func (e *StorageExecutor) callNornicDbSchemaDefine(cypher string) (*ExecuteResult, error) {
+	const proc = "nornicdb.schema.define"
+	schema, err := e.propertySchema()
+	if err != nil {
+		return nil, err
+	}
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 3 || len(args) > 4 {
+		return nil, fmt.Errorf("%s requires (kind, name, properties[, closed])", proc)
+	}
+	relationship, err := schemaKind(proc, args[0])
+	if err != nil {
+		return nil, err
+	}
+	name, _ := args[1].(string)
+	if name == "" {
+		return nil, fmt.Errorf("%s: name must be a non-empty string", proc)
+	}
+	props, ok := args[2].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: properties must be a map of property name to type", proc)
+	}
+	entry := storage.EntitySchema{Properties: make(map[string]storage.PropertyDefinition, len(props))}
+	if len(args) == 4 {
+		if entry.Closed, ok = args[3].(bool); !ok {
+			return nil, fmt.Errorf("%s: closed must be a boolean", proc)
+		}
+	}
+	for prop, spec := range props {
+		s, _ := spec.(string)
+		def, err := storage.ParsePropertyDefinition(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: property %s: %w", proc, prop, err)
+		}
+		entry.Properties[prop] = def
+	}
+
+	kind := "label"
+	if relationship {
+		kind = "relationship"
+		schema.DefineRelationshipType(name, entry)
+	} else {
+		schema.DefineLabel(name, entry)
+	}
+	return &ExecuteResult{
+		Columns: []string{"kind", "name", "properties", "closed"},
+		Rows:    [][]interface{}{{kind, name, len(entry.Properties), entry.Closed}},
+	}, nil
+}
+
+// callNornicDbSchemaDrop implements CALL nornicdb.schema.drop(kind, name).
+func (e *StorageExecutor) callNornicDbSchemaDrop(cypher string) (*ExecuteResult, error) {
+	const proc = "nornicdb.schema.drop"
+	schema, err := e.propertySchema()
+	if err != nil {
+		return nil, err
+	}
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s requires (kind, name)", proc)
+	}
+	relationship, err := schemaKind(proc, args[0])
+	if err != nil {
+		return nil, err
+	}
+	name, _ := args[1].(string)
+
+	var dropped bool
+	if relationship {
+		dropped = schema.DropRelationshipType(name)
+	} else {
+		dropped = schema.DropLabel(name)
+	}
+	return &ExecuteResult{
+		Columns: []string{"name", "dropped"},
+		Rows:    [][]interface{}{{name, dropped}},
+	}, nil
+}
+
+// callNornicDbSchemaMode implements CALL nornicdb.schema.mode([mode]),
+// setting the mode if one is given and returning the current one.
+func (e *StorageExecutor) callNornicDbSchemaMode(cypher string) (*ExecuteResult, error) {
+	const proc = "nornicdb.schema.mode"
+	schema, err := e.propertySchema()
+	if err != nil {
+		return nil, err
+	}
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 1 {
+		return nil, fmt.Errorf("%s takes at most one argument", proc)
+	}
+	if len(args) == 1 {
+		s, _ := args[0].(string)
+		mode, err := storage.ParseSchemaMode(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", proc, err)
+		}
+		schema.SetMode(mode)
+	}
+	warned, rejected := schema.Stats()
+	return &ExecuteResult{
+		Columns: []string{"mode", "warned", "rejected"},
+		Rows:    [][]interface{}{{string(schema.Mode()), warned, rejected}},
+	}, nil
+}
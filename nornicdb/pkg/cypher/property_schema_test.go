@@ -0,0 +1,78 @@
+package cypher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropertySchemaProcedures(t *testing.T) {
+	ctx := context.Background()
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+
+	result, err := exec.Execute(ctx, "CALL nornicdb.schema.define('label', 'Person', {name: 'STRING NOT NULL', age: 'INTEGER'}, true)", nil)
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{"label", "Person", 2, true}}, result.Rows)
+	_, err = exec.Execute(ctx, "CALL nornicdb.schema.define('relationship', 'KNOWS', {since: 'DATE'})", nil)
+	require.NoError(t, err)
+
+	result, err = exec.Execute(ctx, "CALL nornicdb.schema.mode('strict')", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "strict", result.Rows[0][0])
+
+	result, err = exec.Execute(ctx, "CALL nornicdb.schema.registry()", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kind", "name", "properties", "closed", "mode"}, result.Columns)
+	assert.Equal(t, [][]interface{}{
+		{"label", "Person", map[string]interface{}{"name": "STRING NOT NULL", "age": "INTEGER"}, true, "strict"},
+		{"relationship", "KNOWS", map[string]interface{}{"since": "DATE"}, false, "strict"},
+	}, result.Rows)
+
+	// Strict mode rejects violating writes
+	_, err = exec.Execute(ctx, "CREATE (p:Person {name: 'Ada', age: 36})", nil)
+	require.NoError(t, err)
+	_, err = exec.Execute(ctx, "CREATE (p:Person {name: 'Bob', nickname: 'B'})", nil)
+	assert.ErrorIs(t, err, storage.ErrSchemaViolation)
+
+	result, err = exec.Execute(ctx, "CALL nornicdb.schema.mode()", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"strict", int64(0), int64(1)}, result.Rows[0])
+
+	result, err = exec.Execute(ctx, "CALL nornicdb.schema.drop('label', 'Person')", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"Person", true}, result.Rows[0])
+	_, err = exec.Execute(ctx, "CREATE (p:Person {name: 'Bob', nickname: 'B'})", nil)
+	assert.NoError(t, err)
+
+	// Invalid arguments
+	for _, q := range []string{
+		"CALL nornicdb.schema.define('index', 'Person', {})",
+		"CALL nornicdb.schema.define('label', 'Person', {name: 'TEXT'})",
+		"CALL nornicdb.schema.define('label', 'Person')",
+		"CALL nornicdb.schema.mode('loose')",
+	} {
+		_, err := exec.Execute(ctx, q, nil)
+		assert.Error(t, err, q)
+	}
+}
+
+func TestPropertyDefaults_Create(t *testing.T) {
+	ctx := context.Background()
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+	_, err := exec.Execute(ctx, "CALL nornicdb.schema.define('label', 'Post', {createdAt: 'INTEGER DEFAULT timestamp()', id: 'STRING DEFAULT randomUUID()', status: \"DEFAULT 'draft'\"})", nil)
+	require.NoError(t, err)
+
+	result, err := exec.Execute(ctx, "CREATE (p:Post {title: 'Hello', status: 'published'}) RETURN p.id, p.createdAt, p.status", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	assert.Len(t, result.Rows[0][0], 36)
+	assert.IsType(t, int64(0), result.Rows[0][1])
+	assert.Equal(t, "published", result.Rows[0][2])
+
+	result, err = exec.Execute(ctx, "CALL nornicdb.schema.registry()", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ANY DEFAULT 'draft'", result.Rows[0][2].(map[string]interface{})["status"])
+}
@@ -260,7 +260,14 @@ func analyzeQuery(cypher string) *QueryInfo {
 	// For CALL, only "CALL db." procedures are read-only (schema introspection).
 	// Other procedures like gds.graph.drop() may be writes.
 	isDbCall := info.HasCall && strings.Contains(strings.ToUpper(cypher), "CALL DB.")
-	info.IsReadOnly = !info.IsWriteQuery && !info.HasSchema &&
+	// Prepared statement, stored query, live query, workload, schema registry, index state and configuration procedures are stateful and never cacheable.
+	isStatefulCall := info.HasCall && (strings.Contains(upper, "CALL DB.PREPARE") || strings.Contains(upper, "CALL DB.QUERY.") ||
+		strings.Contains(upper, "CALL DBMS.LISTQUERIES") || strings.Contains(upper, "CALL DBMS.KILLQUERY") ||
+		strings.Contains(upper, "CALL DB.STATS.") || strings.Contains(upper, "CALL NORNICDB.WORKLOAD") ||
+		strings.Contains(upper, "CALL NORNICDB.SCHEMA.") ||
+		strings.Contains(upper, "CALL DB.INDEXES") || strings.Contains(upper, "CALL DB.AWAITINDEX") ||
+		strings.Contains(upper, "CALL DBMS.SETCONFIG") || strings.Contains(upper, "CALL DBMS.LISTCONFIG"))
+	info.IsReadOnly = !info.IsWriteQuery && !info.HasSchema && !isStatefulCall &&
 		(info.HasMatch || info.HasReturn || isDbCall || info.HasShow)
 	info.IsSchemaQuery = info.HasSchema || info.HasShow
 
@@ -0,0 +1,247 @@
+// Package cypher - per-query execution options.
+//
+// Queries may start with a Neo4j-style CYPHER prefix that tunes how that one
+// query runs, without server restarts:
+//
+//	CYPHER runtime=parallel MATCH (n:Person) WHERE n.age > 30 RETURN n
+//	CYPHER 5 planner=cost timeout=2s MATCH (n) RETURN count(n)
+//
+// Supported options:
+//   - planner: cost, idp, dp. Accepted for Neo4j compatibility; NornicDB
+//     always uses its cost-based planner.
+//   - runtime: parallel runs filters on all configured workers regardless of
+//     input size; slotted, interpreted and pipelined run single-threaded.
+//     Without it, parallelism follows ParallelConfig.
+//   - timeout: Go duration (2s, 500ms) or integer milliseconds. Read queries
+//     running longer fail with ErrQueryTimeout. Writes always run to
+//     completion, so a timeout never hides an applied write. A deadline on
+//     the query's context (a driver's tx_timeout) stops reads the same way.
+//   - retryable: true lets an auto-commit write that aborts on a write
+//     conflict be re-run under the executor's RetryPolicy.
+//
+// Several prefixes may be chained; later values win. Bolt sessions use this
+// to apply their :config settings ahead of the client's own prefix.
+package cypher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/orneryd/nornicdb/pkg/neo4jerr"
+	"github.com/orneryd/nornicdb/pkg/requestid"
+)
+
+// ErrQueryTimeout is returned when a read query exceeds its timeout option
+// or the deadline of its context. Clients see it as
+// Neo.TransientError.Transaction.TransactionTimedOut.
+var ErrQueryTimeout error = neo4jerr.New(neo4jerr.TransactionTimedOut, "query timed out")
+
+// QueryOptions holds per-query execution options.
+type QueryOptions struct {
+	Planner   string        // Planner name (compatibility only)
+	Runtime   string        // Runtime name, "" = size-based parallelism
+	Timeout   time.Duration // Read query timeout, 0 = none
+	Retryable bool          // Auto-commit write may be retried on conflict
+}
+
+var (
+	validPlanners = map[string]bool{"cost": true, "idp": true, "dp": true}
+	validRuntimes = map[string]bool{"parallel": true, "slotted": true, "interpreted": true, "pipelined": true}
+)
+
+// Set assigns one option by name, validating the value.
+func (o *QueryOptions) Set(key, value string) error {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch strings.ToLower(strings.TrimSpace(key)) {
+	case "planner":
+		if !validPlanners[value] {
+			return fmt.Errorf("invalid CYPHER option planner=%s (expected cost, idp or dp)", value)
+		}
+		o.Planner = value
+	case "runtime":
+		if !validRuntimes[value] {
+			return fmt.Errorf("invalid CYPHER option runtime=%s (expected parallel, slotted, interpreted or pipelined)", value)
+		}
+		o.Runtime = value
+	case "timeout":
+		d, err := parseOptionDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid CYPHER option timeout=%s: %w", value, err)
+		}
+		o.Timeout = d
+	case "retryable":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid CYPHER option retryable=%s (expected true or false)", value)
+		}
+		o.Retryable = b
+	default:
+		return fmt.Errorf("unknown CYPHER option %q", key)
+	}
+	return nil
+}
+
+// Merge returns o with the non-zero fields of override applied.
+func (o QueryOptions) Merge(override QueryOptions) QueryOptions {
+	if override.Planner != "" {
+		o.Planner = override.Planner
+	}
+	if override.Runtime != "" {
+		o.Runtime = override.Runtime
+	}
+	if override.Timeout != 0 {
+		o.Timeout = override.Timeout
+	}
+	if override.Retryable {
+		o.Retryable = true
+	}
+	return o
+}
+
+// ParseQueryOptions strips any leading CYPHER prefixes from a query and
+// returns their options and the remaining query. Queries without a prefix
+// are returned unchanged with zero options.
+func ParseQueryOptions(query string) (QueryOptions, string, error) {
+	var opts QueryOptions
+	rest := strings.TrimSpace(query)
+	for hasCypherPrefix(rest) {
+		rest = strings.TrimSpace(rest[len("CYPHER"):])
+
+		// Optional language version: CYPHER 5
+		if end := strings.IndexFunc(rest, unicode.IsSpace); end > 0 && isVersion(rest[:end]) {
+			rest = strings.TrimSpace(rest[end:])
+		}
+
+		// key=value pairs up to the first token without '='
+		for {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			key, value, ok := strings.Cut(rest[:end], "=")
+			if !ok {
+				break
+			}
+			if err := opts.Set(key, value); err != nil {
+				return QueryOptions{}, query, err
+			}
+			rest = strings.TrimSpace(rest[end:])
+		}
+	}
+	return opts, rest, nil
+}
+
+// hasCypherPrefix reports whether a query starts with the CYPHER keyword.
+func hasCypherPrefix(query string) bool {
+	return len(query) > len("CYPHER") &&
+		strings.EqualFold(query[:len("CYPHER")], "CYPHER") &&
+		unicode.IsSpace(rune(query[len("CYPHER")]))
+}
+
+func isVersion(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && c != '.' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func parseOptionDuration(value string) (time.Duration, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if ms < 0 {
+			return 0, fmt.Errorf("must not be negative")
+		}
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return d, nil
+}
+
+// queryOptionsKey is the context key for QueryOptions.
+type queryOptionsKey struct{}
+
+// WithQueryOptions returns a context carrying default options for queries
+// executed with it. A query's own CYPHER prefix overrides them.
+func WithQueryOptions(ctx context.Context, opts QueryOptions) context.Context {
+	return context.WithValue(ctx, queryOptionsKey{}, opts)
+}
+
+// QueryOptionsFromContext returns the options carried by ctx.
+func QueryOptionsFromContext(ctx context.Context) QueryOptions {
+	if ctx == nil {
+		return QueryOptions{}
+	}
+	opts, _ := ctx.Value(queryOptionsKey{}).(QueryOptions)
+	return opts
+}
+
+// parallelConfigFor returns the parallel configuration for a query, applying
+// its runtime option.
+func parallelConfigFor(ctx context.Context) ParallelConfig {
+	cfg := parallelConfig
+	switch QueryOptionsFromContext(ctx).Runtime {
+	case "parallel":
+		cfg.Enabled = true
+		cfg.MinBatchSize = 1
+	case "slotted", "interpreted", "pipelined":
+		cfg.Enabled = false
+	}
+	return cfg
+}
+
+// executeWithTimeout runs a read query, giving up once the timeout (0 = none)
+// or the deadline of ctx passes. The abandoned execution finishes in the
+// background; reads have no effects to roll back.
+func (e *StorageExecutor) executeWithTimeout(ctx context.Context, cypher, upperQuery string, info *QueryInfo, timeout time.Duration) (*ExecuteResult, error) {
+	parent := ctx
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	type outcome struct {
+		result *ExecuteResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: newQueryPanicError(requestid.FromContext(ctx), cypher, r)}
+			}
+		}()
+		result, err := e.executeRouted(ctx, cypher, upperQuery, info)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case out := <-done:
+		return out.result, out.err
+	case <-ctx.Done():
+		if err := cancelled(ctx); errors.Is(err, ErrQueryKilled) {
+			return nil, err
+		}
+		switch err := parent.Err(); {
+		case errors.Is(err, context.DeadlineExceeded):
+			return nil, fmt.Errorf("%w: transaction deadline exceeded", ErrQueryTimeout)
+		case err != nil:
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w after %s", ErrQueryTimeout, timeout)
+	}
+}
@@ -0,0 +1,123 @@
+package cypher
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/neo4jerr"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueryOptions(t *testing.T) {
+	opts, rest, err := ParseQueryOptions("CYPHER 5 planner=COST runtime=parallel timeout=1500 retryable=true MATCH (n) WHERE n.x=1 RETURN n")
+	require.NoError(t, err)
+	assert.Equal(t, QueryOptions{Planner: "cost", Runtime: "parallel", Timeout: 1500 * time.Millisecond, Retryable: true}, opts)
+	assert.Equal(t, "MATCH (n) WHERE n.x=1 RETURN n", rest)
+
+	// Chained prefixes: later values win
+	opts, rest, err = ParseQueryOptions("CYPHER runtime=slotted timeout=2s cypher runtime=parallel RETURN 1")
+	require.NoError(t, err)
+	assert.Equal(t, QueryOptions{Runtime: "parallel", Timeout: 2 * time.Second}, opts)
+	assert.Equal(t, "RETURN 1", rest)
+
+	opts, rest, err = ParseQueryOptions("MATCH (n) RETURN n")
+	require.NoError(t, err)
+	assert.Equal(t, QueryOptions{}, opts)
+	assert.Equal(t, "MATCH (n) RETURN n", rest)
+
+	for _, bad := range []string{
+		"CYPHER runtime=turbo RETURN 1",
+		"CYPHER planner=rule RETURN 1",
+		"CYPHER timeout=soon RETURN 1",
+		"CYPHER timeout=-5 RETURN 1",
+		"CYPHER retryable=maybe RETURN 1",
+		"CYPHER colour=blue RETURN 1",
+	} {
+		_, _, err := ParseQueryOptions(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestQueryOptions_MergeAndContext(t *testing.T) {
+	session := QueryOptions{Runtime: "slotted", Timeout: time.Second}
+	merged := session.Merge(QueryOptions{Runtime: "parallel"})
+	assert.Equal(t, QueryOptions{Runtime: "parallel", Timeout: time.Second}, merged)
+
+	ctx := WithQueryOptions(context.Background(), merged)
+	assert.Equal(t, merged, QueryOptionsFromContext(ctx))
+	assert.Equal(t, QueryOptions{}, QueryOptionsFromContext(context.Background()))
+
+	// Runtime overrides the global parallel configuration per query
+	assert.True(t, parallelConfigFor(ctx).Enabled)
+	assert.Equal(t, 1, parallelConfigFor(ctx).MinBatchSize)
+	sequential := WithQueryOptions(context.Background(), QueryOptions{Runtime: "interpreted"})
+	assert.False(t, parallelConfigFor(sequential).Enabled)
+	assert.Equal(t, GetParallelConfig(), parallelConfigFor(context.Background()))
+}
+
+// slowEmbedder delays vector query embedding to simulate a slow read.
+type slowEmbedder struct {
+	delay time.Duration
+}
+
+func (s *slowEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	time.Sleep(s.delay)
+	return []float32{1, 0, 0, 0}, nil
+}
+
+func TestExecute_CypherPrefixOptions(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	exec := NewStorageExecutor(store)
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		_, err := exec.Execute(ctx, "CREATE (:Item {n: $n})", map[string]interface{}{"n": int64(i)})
+		require.NoError(t, err)
+	}
+
+	// Every runtime returns the same rows
+	for _, runtime := range []string{"parallel", "slotted"} {
+		result, err := exec.Execute(ctx, "CYPHER runtime="+runtime+" MATCH (i:Item) WHERE i.n >= 10 RETURN count(i)", nil)
+		require.NoError(t, err, runtime)
+		assert.EqualValues(t, 10, result.Rows[0][0], runtime)
+	}
+
+	_, err := exec.Execute(ctx, "CYPHER runtime=turbo MATCH (i:Item) RETURN i", nil)
+	assert.Error(t, err)
+
+	// Read queries give up at the timeout; session defaults come from ctx
+	exec.SetEmbedder(&slowEmbedder{delay: 300 * time.Millisecond})
+	query := "CALL db.index.vector.queryNodes('idx', 5, 'slow text') YIELD node RETURN node"
+
+	start := time.Now()
+	_, err = exec.Execute(ctx, "CYPHER timeout=20ms "+query, nil)
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.Equal(t, neo4jerr.TransactionTimedOut, neo4jerr.Code(err, neo4jerr.StatementSyntaxError))
+	assert.Less(t, time.Since(start), 250*time.Millisecond)
+
+	_, err = exec.Execute(WithQueryOptions(ctx, QueryOptions{Timeout: 20 * time.Millisecond}), query, nil)
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+
+	// A prefix overrides the session default
+	_, err = exec.Execute(WithQueryOptions(ctx, QueryOptions{Timeout: 20 * time.Millisecond}), "CYPHER timeout=5s "+query, nil)
+	assert.NoError(t, err)
+
+	// A deadline on the context, as set from a driver's tx_timeout, stops reads too
+	deadlineCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	start = time.Now()
+	_, err = exec.Execute(deadlineCtx, strings.Replace(query, "slow text", "other text", 1), nil)
+	cancel()
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.Less(t, time.Since(start), 250*time.Millisecond)
+
+	// Writes ignore the timeout and complete
+	_, err = exec.Execute(ctx, "CYPHER timeout=1ns CREATE (:Item {n: 99})", nil)
+	require.NoError(t, err)
+	result, err := exec.Execute(ctx, "MATCH (i:Item {n: 99}) RETURN count(i)", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, result.Rows[0][0])
+}
@@ -0,0 +1,298 @@
+// Package cypher - live query registry.
+//
+// Every top-level query (Execute, ExecutePrepared) is registered while it
+// runs, with its elapsed time, rows produced so far and an estimate of the
+// memory those rows hold. Nested executions made by procedures and clauses
+// are attributed to the query that started them.
+//
+//	CALL dbms.listQueries()
+//	CALL dbms.killQuery('query-42')
+//
+// Killing is cooperative: the query's context is cancelled and read queries
+// fail with ErrQueryKilled at their next check (between result rows, or at
+// once when they run with a timeout). Writes run to completion, like with
+// the timeout option, so a kill never leaves half-applied changes.
+//
+// The user and client IP come from the principal the query was admitted for
+// (ratelimit.NewContext), so a caller holding concurrency slots can be found
+// and its queries killed to free them. Callers without the admin permission
+// see and kill only their own queries, and the listed query text is passed
+// through the redactor set with SetRedactor.
+package cypher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/neo4jerr"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/requestid"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// ErrQueryKilled is returned by read queries killed with KillQuery. Clients
+// see it as Neo.TransientError.Transaction.Terminated.
+var ErrQueryKilled error = neo4jerr.New(neo4jerr.Terminated, "query was killed")
+
+// RunningQuery describes a query that is currently executing.
+type RunningQuery struct {
+	ID        string    // "query-N", unique per executor
+	Query     string    // Query text as submitted
+	User      string    // Admitted principal's user ("" if anonymous or unknown)
+	ClientIP  string    // Admitted principal's address
+	RequestID string    // Request ID from the context
+	StartedAt time.Time // When execution started
+
+	rows     atomic.Int64
+	bytes    atomic.Int64
+	readOnly atomic.Bool
+	killed   atomic.Bool
+	cancel   context.CancelCauseFunc
+
+	concurrent int // Top-level queries running when it started, itself included
+}
+
+// Elapsed returns how long the query has been running.
+func (q *RunningQuery) Elapsed() time.Duration {
+	return time.Since(q.StartedAt)
+}
+
+// Rows returns the number of result rows produced so far.
+func (q *RunningQuery) Rows() int64 {
+	return q.rows.Load()
+}
+
+// MemoryBytes returns an estimate of the memory held by rows produced so far.
+func (q *RunningQuery) MemoryBytes() int64 {
+	return q.bytes.Load()
+}
+
+// ReadOnly reports whether the query has been analyzed as read-only.
+func (q *RunningQuery) ReadOnly() bool {
+	return q.readOnly.Load()
+}
+
+// Killed reports whether KillQuery was called for the query.
+func (q *RunningQuery) Killed() bool {
+	return q.killed.Load()
+}
+
+// addRow records a produced result row.
+func (q *RunningQuery) addRow(row []interface{}) {
+	q.rows.Add(1)
+	q.bytes.Add(estimateBytes(row))
+}
+
+type runningQueryKey struct{}
+
+// runningQueryFromContext returns the registered query ctx belongs to.
+func runningQueryFromContext(ctx context.Context) *RunningQuery {
+	if ctx == nil {
+		return nil
+	}
+	q, _ := ctx.Value(runningQueryKey{}).(*RunningQuery)
+	return q
+}
+
+// startQuery registers a top-level query and returns a context that KillQuery
+// cancels. Nested executions return ctx unchanged and a nil query.
+func (e *StorageExecutor) startQuery(ctx context.Context, query string) (context.Context, *RunningQuery) {
+	if runningQueryFromContext(ctx) != nil {
+		return ctx, nil
+	}
+
+	q := &RunningQuery{
+		ID:        "query-" + strconv.FormatUint(e.nextQueryID.Add(1), 10),
+		Query:     query,
+		RequestID: requestid.FromContext(ctx),
+		StartedAt: time.Now(),
+	}
+	if p, ok := ratelimit.PrincipalFromContext(ctx); ok {
+		q.User = p.User
+		q.ClientIP = p.IP
+	}
+	ctx, q.cancel = context.WithCancelCause(ctx)
+	ctx = context.WithValue(ctx, runningQueryKey{}, q)
+
+	e.runningMu.Lock()
+	e.running[q.ID] = q
+	q.concurrent = len(e.running)
+	e.runningMu.Unlock()
+	return ctx, q
+}
+
+// finishQuery unregisters q. A killed read query's result is dropped and
+// replaced with ErrQueryKilled.
+func (e *StorageExecutor) finishQuery(q *RunningQuery, result *ExecuteResult, err error) (*ExecuteResult, error) {
+	if q == nil {
+		return result, err
+	}
+	e.runningMu.Lock()
+	delete(e.running, q.ID)
+	e.runningMu.Unlock()
+	q.cancel(nil)
+
+	if q.Killed() && q.ReadOnly() && err == nil {
+		result.Release()
+		result, err = nil, fmt.Errorf("%w: %s", ErrQueryKilled, q.ID)
+	}
+	e.recordWorkload(q, result, err)
+	return result, err
+}
+
+// markReadOnly records the analysis of the query ctx belongs to.
+func markReadOnly(ctx context.Context, info *QueryInfo) {
+	if q := runningQueryFromContext(ctx); q != nil {
+		q.readOnly.Store(info.IsReadOnly)
+	}
+}
+
+// RunningQueries returns the queries currently executing, oldest first.
+func (e *StorageExecutor) RunningQueries() []*RunningQuery {
+	e.runningMu.RLock()
+	queries := make([]*RunningQuery, 0, len(e.running))
+	for _, q := range e.running {
+		queries = append(queries, q)
+	}
+	e.runningMu.RUnlock()
+
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].StartedAt.Before(queries[j].StartedAt)
+	})
+	return queries
+}
+
+// KillQuery cancels a running query. Returns false if no query has the ID.
+func (e *StorageExecutor) KillQuery(id string) bool {
+	e.runningMu.RLock()
+	q, ok := e.running[id]
+	e.runningMu.RUnlock()
+	if !ok {
+		return false
+	}
+	q.killed.Store(true)
+	q.cancel(ErrQueryKilled)
+	return true
+}
+
+// cancelled returns the error a query stopped by ctx should fail with, or nil
+// if ctx is still live.
+func cancelled(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrQueryKilled) {
+		return cause
+	}
+	return ctx.Err()
+}
+
+// SetRedactor masks PII in the query text listed by dbms.listQueries. A nil
+// redactor lists query text as submitted.
+func (e *StorageExecutor) SetRedactor(r *redact.Redactor) {
+	e.redactor = r
+}
+
+// canSeeQuery reports whether the caller may list or kill q: its own
+// queries, or any query with the admin permission.
+func canSeeQuery(ctx context.Context, q *RunningQuery) bool {
+	p, ok := queryCaller(ctx)
+	return !ok || p.User == q.User || callerHas(p, auth.PermAdmin)
+}
+
+// callDbmsListQueries implements CALL dbms.listQueries().
+func (e *StorageExecutor) callDbmsListQueries(ctx context.Context) (*ExecuteResult, error) {
+	result := &ExecuteResult{
+		Columns: []string{"queryId", "username", "clientAddress", "requestId", "query",
+			"elapsedTimeMillis", "rows", "allocatedBytes", "status"},
+		Rows: [][]interface{}{},
+	}
+	for _, q := range e.RunningQueries() {
+		if !canSeeQuery(ctx, q) {
+			continue
+		}
+		status := "running"
+		if q.Killed() {
+			status = "killed"
+		}
+		result.Rows = append(result.Rows, []interface{}{
+			q.ID, q.User, q.ClientIP, q.RequestID, e.redactor.Query(q.Query),
+			q.Elapsed().Milliseconds(), q.Rows(), q.MemoryBytes(), status,
+		})
+	}
+	return result, nil
+}
+
+// callDbmsKillQuery implements CALL dbms.killQuery(id).
+func (e *StorageExecutor) callDbmsKillQuery(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	id, err := procedureStringArg(cypher, "dbms.killQuery", "a query id")
+	if err != nil {
+		return nil, err
+	}
+
+	e.runningMu.RLock()
+	q, ok := e.running[id]
+	e.runningMu.RUnlock()
+	if ok && !canSeeQuery(ctx, q) {
+		return nil, fmt.Errorf("killing another user's query requires the admin permission: %w", auth.ErrInsufficientRole)
+	}
+
+	username, message := "", "No Query found with this id"
+	if ok && e.KillQuery(id) {
+		username = q.User
+		message = "Query found"
+		if !q.ReadOnly() {
+			message = "Query found; writes run to completion"
+		}
+	}
+	return &ExecuteResult{
+		Columns: []string{"queryId", "username", "message"},
+		Rows:    [][]interface{}{{id, username, message}},
+	}, nil
+}
+
+// estimateBytes approximates the heap held by a result value. It is meant
+// for relative comparisons between queries, not exact accounting.
+func estimateBytes(v interface{}) int64 {
+	switch val := v.(type) {
+	case nil:
+		return 8
+	case string:
+		return 16 + int64(len(val))
+	case []interface{}:
+		n := int64(24)
+		for _, item := range val {
+			n += 16 + estimateBytes(item)
+		}
+		return n
+	case map[string]interface{}:
+		n := int64(48)
+		for k, item := range val {
+			n += 16 + int64(len(k)) + 16 + estimateBytes(item)
+		}
+		return n
+	case []string:
+		n := int64(24)
+		for _, s := range val {
+			n += 16 + int64(len(s))
+		}
+		return n
+	case []float32:
+		return 24 + 4*int64(len(val))
+	case []float64:
+		return 24 + 8*int64(len(val))
+	case *storage.Node:
+		return 96 + estimateBytes(val.Properties) + estimateBytes(val.Labels) + 4*int64(len(val.Embedding))
+	case *storage.Edge:
+		return 96 + estimateBytes(val.Properties)
+	default:
+		return 16
+	}
+}
@@ -0,0 +1,190 @@
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/neo4jerr"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRegistryTestExecutor(t *testing.T, items int) *StorageExecutor {
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+	for i := 0; i < items; i++ {
+		_, err := exec.Execute(context.Background(), "CREATE (:Item {i: $i})", map[string]interface{}{"i": i})
+		require.NoError(t, err)
+	}
+	return exec
+}
+
+func TestListQueries(t *testing.T) {
+	exec := newRegistryTestExecutor(t, 0)
+	ctx := ratelimit.NewContext(context.Background(), ratelimit.Principal{User: "alice", IP: "10.0.0.1"})
+
+	result, err := exec.Execute(ctx, "CALL dbms.listQueries()", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1, "the listing query sees itself")
+
+	row := map[string]interface{}{}
+	for i, col := range result.Columns {
+		row[col] = result.Rows[0][i]
+	}
+	assert.Regexp(t, `^query-\d+$`, row["queryId"])
+	assert.Equal(t, "alice", row["username"])
+	assert.Equal(t, "10.0.0.1", row["clientAddress"])
+	assert.Equal(t, "CALL dbms.listQueries()", row["query"])
+	assert.Equal(t, "running", row["status"])
+
+	// Never served from the result cache
+	again, err := exec.Execute(ctx, "CALL dbms.listQueries()", nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, row["queryId"], again.Rows[0][0])
+
+	// Finished queries are unregistered
+	assert.Empty(t, exec.RunningQueries())
+}
+
+func TestListQueries_Permissions(t *testing.T) {
+	exec := newRegistryTestExecutor(t, 0)
+	r, err := redact.New(redact.Config{Enabled: true, Properties: []string{"ssn"}})
+	require.NoError(t, err)
+	exec.SetRedactor(r)
+
+	bobCtx := ratelimit.NewContext(context.Background(), ratelimit.Principal{User: "bob", Roles: []string{string(auth.RoleViewer)}})
+	_, bobQuery := exec.startQuery(bobCtx, "MATCH (p {ssn: '123-45-6789'}) RETURN p")
+	defer exec.finishQuery(bobQuery, nil, nil)
+
+	list := func(p ratelimit.Principal) map[string]string {
+		result, err := exec.Execute(ratelimit.NewContext(context.Background(), p), "CALL dbms.listQueries()", nil)
+		require.NoError(t, err)
+		queries := map[string]string{}
+		for _, row := range result.Rows {
+			queries[row[1].(string)] = row[4].(string)
+		}
+		return queries
+	}
+
+	alice := list(ratelimit.Principal{User: "alice", Roles: []string{string(auth.RoleViewer)}})
+	assert.NotContains(t, alice, "bob", "other users' queries are hidden")
+	assert.Contains(t, alice, "alice", "own queries are listed")
+
+	admin := list(ratelimit.Principal{User: "root", Roles: []string{string(auth.RoleAdmin)}})
+	require.Contains(t, admin, "bob")
+	assert.NotContains(t, admin["bob"], "123-45-6789", "query text is redacted")
+	assert.Contains(t, admin["bob"], redact.DefaultMask)
+}
+
+func TestRunningQueryProgress(t *testing.T) {
+	exec := newRegistryTestExecutor(t, 3)
+	ctx, q := exec.startQuery(context.Background(), "outer")
+	require.NotNil(t, q)
+	require.Len(t, exec.RunningQueries(), 1)
+
+	// Nested executions are attributed to the outer query
+	_, err := exec.Execute(ctx, "MATCH (n:Item) RETURN n.i", nil)
+	require.NoError(t, err)
+	assert.Len(t, exec.RunningQueries(), 1)
+	assert.Equal(t, int64(3), q.Rows())
+	assert.Greater(t, q.MemoryBytes(), int64(0))
+	assert.True(t, q.ReadOnly())
+
+	_, err = exec.finishQuery(q, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, exec.RunningQueries())
+}
+
+func TestKillQuery(t *testing.T) {
+	t.Run("read query fails", func(t *testing.T) {
+		exec := newRegistryTestExecutor(t, 5)
+		ctx, q := exec.startQuery(context.Background(), "outer")
+		assert.True(t, exec.KillQuery(q.ID))
+		assert.True(t, q.Killed())
+
+		_, err := exec.Execute(ctx, "MATCH (n:Item) RETURN n.i", nil)
+		assert.ErrorIs(t, err, ErrQueryKilled)
+		_, err = exec.Execute(ctx, "CYPHER timeout=10s MATCH (n:Item) RETURN n.i", nil)
+		assert.ErrorIs(t, err, ErrQueryKilled)
+
+		_, err = exec.finishQuery(q, &ExecuteResult{}, nil)
+		assert.ErrorIs(t, err, ErrQueryKilled)
+		assert.Equal(t, neo4jerr.Terminated, neo4jerr.Code(err, neo4jerr.StatementSyntaxError))
+		assert.Empty(t, exec.RunningQueries())
+	})
+
+	t.Run("write query completes", func(t *testing.T) {
+		exec := newRegistryTestExecutor(t, 0)
+		ctx, q := exec.startQuery(context.Background(), "outer")
+		require.True(t, exec.KillQuery(q.ID))
+
+		_, err := exec.Execute(ctx, "CREATE (:Written)", nil)
+		require.NoError(t, err)
+		_, err = exec.finishQuery(q, &ExecuteResult{}, nil)
+		require.NoError(t, err)
+
+		result, err := exec.Execute(context.Background(), "MATCH (n:Written) RETURN count(n)", nil)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, result.Rows[0][0])
+	})
+
+	t.Run("unknown id", func(t *testing.T) {
+		exec := newRegistryTestExecutor(t, 0)
+		assert.False(t, exec.KillQuery("query-999"))
+	})
+}
+
+func TestKillQueryProcedure(t *testing.T) {
+	exec := newRegistryTestExecutor(t, 0)
+	ctx := ratelimit.NewContext(context.Background(), ratelimit.Principal{User: "bob"})
+	_, q := exec.startQuery(ctx, "MATCH (n) RETURN n")
+	markReadOnly(context.WithValue(ctx, runningQueryKey{}, q), &QueryInfo{IsReadOnly: true})
+
+	result, err := exec.Execute(context.Background(), fmt.Sprintf("CALL dbms.killQuery('%s')", q.ID), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"queryId", "username", "message"}, result.Columns)
+	assert.Equal(t, []interface{}{q.ID, "bob", "Query found"}, result.Rows[0])
+	assert.True(t, q.Killed())
+
+	result, err = exec.Execute(context.Background(), "CALL dbms.killQuery('query-999')", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "No Query found with this id", result.Rows[0][2])
+
+	_, err = exec.Execute(context.Background(), "CALL dbms.killQuery()", nil)
+	assert.Error(t, err)
+}
+
+func TestKillQueryProcedure_Permissions(t *testing.T) {
+	exec := newRegistryTestExecutor(t, 0)
+	bob := ratelimit.Principal{User: "bob", Roles: []string{string(auth.RoleViewer)}}
+	_, q := exec.startQuery(ratelimit.NewContext(context.Background(), bob), "MATCH (n) RETURN n")
+	defer exec.finishQuery(q, nil, nil)
+	kill := fmt.Sprintf("CALL dbms.killQuery('%s')", q.ID)
+
+	alice := ratelimit.Principal{User: "alice", Roles: []string{string(auth.RoleViewer)}}
+	_, err := exec.Execute(ratelimit.NewContext(context.Background(), alice), kill, nil)
+	assert.ErrorIs(t, err, auth.ErrInsufficientRole)
+	assert.False(t, q.Killed())
+
+	_, err = exec.Execute(ratelimit.NewContext(context.Background(), bob), kill, nil)
+	require.NoError(t, err)
+	assert.True(t, q.Killed(), "users may kill their own queries")
+
+	_, q2 := exec.startQuery(ratelimit.NewContext(context.Background(), bob), "MATCH (n) RETURN n")
+	defer exec.finishQuery(q2, nil, nil)
+	admin := ratelimit.Principal{User: "root", Roles: []string{string(auth.RoleAdmin)}}
+	_, err = exec.Execute(ratelimit.NewContext(context.Background(), admin), fmt.Sprintf("CALL dbms.killQuery('%s')", q2.ID), nil)
+	require.NoError(t, err)
+	assert.True(t, q2.Killed(), "admins may kill any query")
+}
+
+func TestEstimateBytes(t *testing.T) {
+	small := estimateBytes([]interface{}{"a"})
+	large := estimateBytes([]interface{}{map[string]interface{}{"text": string(make([]byte, 1000))}})
+	assert.Greater(t, large, small+1000)
+	assert.Equal(t, int64(24+4*384), estimateBytes(make([]float32, 384)))
+}
@@ -0,0 +1,58 @@
+// Package cypher provides Cypher query execution for NornicDB.
+package cypher
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/orneryd/nornicdb/pkg/neo4jerr"
+)
+
+// queryPanics counts panics recovered during query execution across all executors.
+var queryPanics atomic.Int64
+
+// QueryPanicError is returned when query execution panicked and was recovered.
+//
+// A panic inside a single query must never take down the server. Execute
+// recovers it at the query boundary and reports it as a regular error so the
+// caller (Bolt, HTTP, MCP) can send a failure to the client and keep serving.
+// The error is wrapped with the Neo.DatabaseError.General.UnknownError code.
+//
+// Example:
+//
+//	result, err := executor.Execute(ctx, query, params)
+//	var panicErr *cypher.QueryPanicError
+//	if errors.As(err, &panicErr) {
+//		log.Printf("query panicked: %v\n%s", panicErr.Value, panicErr.Stack)
+//	}
+type QueryPanicError struct {
+	RequestID string // Request ID from the query context, if any
+	Query     string // Query text that triggered the panic
+	Value     any    // Value passed to panic()
+	Stack     []byte // Stack trace captured at recovery time
+}
+
+// Error implements the error interface.
+func (e *QueryPanicError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("internal error executing query (request_id=%s): %v", e.RequestID, e.Value)
+	}
+	return fmt.Sprintf("internal error executing query: %v", e.Value)
+}
+
+// PanicCount returns the total number of query panics recovered since startup.
+func PanicCount() int64 {
+	return queryPanics.Load()
+}
+
+// newQueryPanicError records a recovered panic and wraps it as an error.
+func newQueryPanicError(requestID, query string, value any) error {
+	queryPanics.Add(1)
+	return neo4jerr.Wrap(neo4jerr.UnknownError, &QueryPanicError{
+		RequestID: requestID,
+		Query:     query,
+		Value:     value,
+		Stack:     debug.Stack(),
+	})
+}
@@ -0,0 +1,45 @@
+package cypher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/neo4jerr"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExecuteRecoversPanic verifies a panic during execution is returned as an
+// error and leaves the executor usable.
+func TestExecuteRecoversPanic(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	exec := NewStorageExecutor(store)
+	ctx := context.Background()
+
+	exec.SetNodeCreatedCallback(func(nodeID string) {
+		panic("callback exploded")
+	})
+
+	before := PanicCount()
+	result, err := exec.Execute(ctx, `CREATE (n:Person {name: 'Alice'})`, nil)
+	require.Error(t, err)
+	assert.Nil(t, result)
+
+	var panicErr *QueryPanicError
+	require.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "callback exploded", panicErr.Value)
+	assert.NotEmpty(t, panicErr.Stack)
+	assert.Equal(t, before+1, PanicCount())
+	assert.Equal(t, neo4jerr.UnknownError, neo4jerr.Code(err, neo4jerr.StatementSyntaxError))
+
+	// Executor keeps working after the panic
+	exec.SetNodeCreatedCallback(nil)
+	_, err = exec.Execute(ctx, `CREATE (n:Person {name: 'Bob'})`, nil)
+	require.NoError(t, err)
+	result, err = exec.Execute(ctx, `MATCH (n:Person {name: 'Bob'}) RETURN n.name`, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, "Bob", result.Rows[0][0])
+}
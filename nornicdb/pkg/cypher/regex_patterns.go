@@ -33,9 +33,12 @@ var (
 	fulltextIndexPattern = regexp.MustCompile(`(?i)CREATE\s+FULLTEXT\s+INDEX\s+(\w+)(?:\s+IF\s+NOT\s+EXISTS)?\s+FOR\s+\((\w+):(\w+)\)\s+ON\s+EACH\s+\[([^\]]+)\]`)
 
 	// Vector index patterns
-	vectorIndexPattern      = regexp.MustCompile(`(?i)CREATE\s+VECTOR\s+INDEX\s+(\w+)(?:\s+IF\s+NOT\s+EXISTS)?\s+FOR\s+\((\w+):(\w+)\)\s+ON\s+\((\w+)\.(\w+)\)`)
-	vectorDimensionsPattern = regexp.MustCompile(`vector\.dimensions[:\s]+(\d+)`)
-	vectorSimilarityPattern = regexp.MustCompile(`vector\.similarity_function[:\s]+['"]?(\w+)['"]?`)
+	vectorIndexPattern      = regexp.MustCompile(`(?i)CREATE\s+VECTOR\s+INDEX\s+\x60?(\w+)\x60?(?:\s+IF\s+NOT\s+EXISTS)?\s+FOR\s+\((\w+):\x60?(\w+)\x60?\)\s+ON\s+\(?(\w+)\.\x60?(\w+)\x60?\)?`)
+	vectorDimensionsPattern = regexp.MustCompile(`vector\.dimensions[\x60'"]?[:\s]+(\d+)`)
+	vectorSimilarityPattern = regexp.MustCompile(`vector\.similarity_function[\x60'"]?[:\s]+['"]?(\w+)['"]?`)
+
+	// DROP INDEX name [IF EXISTS]
+	dropIndexPattern = regexp.MustCompile(`(?i)^\s*DROP\s+INDEX\s+\x60?(\w+)\x60?`)
 )
 
 // =============================================================================
@@ -105,7 +108,7 @@ var (
 	labelExtractPattern = regexp.MustCompile(`\(\w*:(\w+)`)
 
 	// Aggregation function detection
-	aggregationPattern = regexp.MustCompile(`(?i)(COUNT|SUM|AVG|MIN|MAX|COLLECT)\s*\(`)
+	aggregationPattern = regexp.MustCompile(`(?i)(COUNT|SUM|AVG|MIN|MAX|COLLECT|APPROXCOUNTDISTINCT)\s*\(`)
 
 	// LIMIT/SKIP extraction
 	limitPattern = regexp.MustCompile(`(?i)LIMIT\s+(\d+)`)
@@ -0,0 +1,279 @@
+// Package cypher - graph sampling procedures for exploring large graphs.
+//
+//	CALL db.sample.nodes('Person', 100)                  // uniform
+//	CALL db.sample.nodes('Person', 100, 'randomWalk')    // random walks
+//	CALL db.sample.subgraph('node-id', 200) YIELD nodes, relationships
+//
+// A uniform sample gives every node with the label the same chance. It
+// reads the label index but no nodes besides the ones returned. A random
+// walk sample reads only the nodes it walks through, so its cost does not
+// grow with the graph; it favours well-connected nodes, as walks reach
+// them more often. The walk jumps to a random node with the label now and
+// then so it does not stay in one neighbourhood.
+//
+// db.sample.subgraph walks from a seed node, returning to the seed now and
+// then, and returns the nodes reached with the relationships between them:
+// a connected picture of the seed's neighbourhood, denser near the seed.
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+const (
+	// sampleMaxSize bounds the nodes a sampling procedure returns.
+	sampleMaxSize = 100_000
+
+	// sampleRestartProbability is how often a walk jumps back to its
+	// start (subgraph) or to a random node (nodes).
+	sampleRestartProbability = 0.15
+
+	// sampleStepsPerNode bounds a walk at this many steps per node asked
+	// for, for graphs with fewer reachable nodes than that.
+	sampleStepsPerNode = 50
+)
+
+// graphWalker takes random steps along relationships in either direction,
+// caching the relationships of the nodes it has been at.
+type graphWalker struct {
+	e     *StorageExecutor
+	rnd   *rand.Rand
+	edges map[storage.NodeID][]*storage.Edge
+}
+
+func newGraphWalker(e *StorageExecutor, rnd *rand.Rand) *graphWalker {
+	return &graphWalker{e: e, rnd: rnd, edges: make(map[storage.NodeID][]*storage.Edge)}
+}
+
+// relationships returns the relationships of a node in either direction,
+// with self-loops once.
+func (w *graphWalker) relationships(id storage.NodeID) []*storage.Edge {
+	if edges, ok := w.edges[id]; ok {
+		return edges
+	}
+	edges, _ := w.e.storage.GetOutgoingEdges(id)
+	incoming, _ := w.e.storage.GetIncomingEdges(id)
+	for _, edge := range incoming {
+		if edge.StartNode != id {
+			edges = append(edges, edge)
+		}
+	}
+	w.edges[id] = edges
+	return edges
+}
+
+// step returns a random neighbour of the node, or false if it has none.
+func (w *graphWalker) step(id storage.NodeID) (storage.NodeID, bool) {
+	edges := w.relationships(id)
+	if len(edges) == 0 {
+		return "", false
+	}
+	edge := edges[w.rnd.Intn(len(edges))]
+	if edge.StartNode == id {
+		return edge.EndNode, true
+	}
+	return edge.StartNode, true
+}
+
+// sampleSize checks the size argument of a sampling procedure.
+func sampleSize(proc string, arg interface{}) (int, error) {
+	switch arg.(type) {
+	case int, int64, float64:
+	default:
+		return 0, fmt.Errorf("%s: size must be an integer, got %v", proc, arg)
+	}
+	n := toInt64(arg)
+	if n < 1 || n > sampleMaxSize {
+		return 0, fmt.Errorf("%s: size must be between 1 and %d, got %d", proc, sampleMaxSize, n)
+	}
+	return int(n), nil
+}
+
+// callDbSampleNodes implements CALL db.sample.nodes(label, n[, method]).
+// label may be null or an empty string for all nodes; method is 'uniform'
+// (default) or 'randomWalk'.
+func (e *StorageExecutor) callDbSampleNodes(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	const proc = "db.sample.nodes"
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("%s requires (label, n[, method])", proc)
+	}
+	label, ok := args[0].(string)
+	if !ok && args[0] != nil {
+		return nil, fmt.Errorf("%s: label must be a string or null, got %v", proc, args[0])
+	}
+	n, err := sampleSize(proc, args[1])
+	if err != nil {
+		return nil, err
+	}
+	method := "uniform"
+	if len(args) == 3 {
+		if method, ok = args[2].(string); !ok {
+			return nil, fmt.Errorf("%s: method must be 'uniform' or 'randomWalk', got %v", proc, args[2])
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var nodes []*storage.Node
+	switch strings.ToLower(method) {
+	case "uniform":
+		ids, err := storage.SampleNodeIDs(e.storage, label, n, rnd)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if node, err := e.storage.GetNode(id); err == nil && node != nil {
+				nodes = append(nodes, node)
+			}
+		}
+	case "randomwalk":
+		if nodes, err = e.sampleNodesByRandomWalk(ctx, label, n, rnd); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s: method must be 'uniform' or 'randomWalk', got %q", proc, method)
+	}
+
+	result := &ExecuteResult{Columns: []string{"node"}, Rows: make([][]interface{}, 0, len(nodes))}
+	for _, node := range nodes {
+		result.Rows = append(result.Rows, []interface{}{e.nodeToMap(node)})
+	}
+	return result, nil
+}
+
+// sampleNodesByRandomWalk collects up to n distinct nodes with label met on
+// random walks.
+func (e *StorageExecutor) sampleNodesByRandomWalk(ctx context.Context, label string, n int, rnd *rand.Rand) ([]*storage.Node, error) {
+	walker := newGraphWalker(e, rnd)
+	seen := make(map[storage.NodeID]bool)
+	var nodes []*storage.Node
+
+	jump := func() (storage.NodeID, error) {
+		id, err := storage.RandomNodeID(e.storage, label, rnd)
+		if err == storage.ErrNotFound {
+			return "", nil
+		}
+		return id, err
+	}
+	current, err := jump()
+	if err != nil || current == "" {
+		return nil, err
+	}
+	for steps := 0; len(nodes) < n && steps < n*sampleStepsPerNode; steps++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !seen[current] {
+			seen[current] = true
+			if node, err := e.storage.GetNode(current); err == nil && node != nil && nodeHasLabel(node, label) {
+				nodes = append(nodes, node)
+			}
+		}
+		next, ok := walker.step(current)
+		if !ok || rnd.Float64() < sampleRestartProbability {
+			if next, err = jump(); err != nil {
+				return nil, err
+			}
+		}
+		current = next
+	}
+	return nodes, nil
+}
+
+// nodeHasLabel reports whether the node has label, matched like the label
+// index does; an empty label matches every node.
+func nodeHasLabel(node *storage.Node, label string) bool {
+	if label == "" {
+		return true
+	}
+	for _, l := range node.Labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// callDbSampleSubgraph implements CALL db.sample.subgraph(seed, size),
+// returning one row with the sampled nodes and the relationships between
+// them. seed is a node ID or element ID, or a node map passed as a
+// parameter.
+func (e *StorageExecutor) callDbSampleSubgraph(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	const proc = "db.sample.subgraph"
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s requires (seed, size)", proc)
+	}
+	var seed storage.NodeID
+	switch v := args[0].(type) {
+	case string:
+		seed = storage.NodeID(strings.TrimPrefix(v, "4:nornicdb:"))
+	case map[string]interface{}:
+		id, _ := v["_nodeId"].(string)
+		seed = storage.NodeID(id)
+	}
+	if seed == "" {
+		return nil, fmt.Errorf("%s: seed must be a node or node ID, got %v", proc, args[0])
+	}
+	size, err := sampleSize(proc, args[1])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := e.storage.GetNode(seed); err != nil {
+		return nil, fmt.Errorf("%s: seed node %s not found", proc, seed)
+	}
+
+	walker := newGraphWalker(e, rand.New(rand.NewSource(time.Now().UnixNano())))
+	sampled := map[storage.NodeID]bool{seed: true}
+	order := []storage.NodeID{seed}
+	current := seed
+	for steps := 0; len(order) < size && steps < size*sampleStepsPerNode; steps++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		next, ok := walker.step(current)
+		if !ok {
+			break // the seed has no relationships
+		}
+		if !sampled[next] {
+			sampled[next] = true
+			order = append(order, next)
+		}
+		current = next
+		if walker.rnd.Float64() < sampleRestartProbability {
+			current = seed
+		}
+	}
+
+	nodes := make([]interface{}, 0, len(order))
+	relationships := []interface{}{}
+	for _, id := range order {
+		node, err := e.storage.GetNode(id)
+		if err != nil || node == nil {
+			continue
+		}
+		nodes = append(nodes, e.nodeToMap(node))
+		for _, edge := range walker.relationships(id) {
+			// Each relationship once, from its start node.
+			if edge.StartNode == id && sampled[edge.EndNode] {
+				relationships = append(relationships, e.edgeToMap(edge))
+			}
+		}
+	}
+	return &ExecuteResult{
+		Columns: []string{"nodes", "relationships"},
+		Rows:    [][]interface{}{{nodes, relationships}},
+	}, nil
+}
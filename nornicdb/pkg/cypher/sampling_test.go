@@ -0,0 +1,119 @@
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sampleGraph creates a ring of 30 :Person nodes with a :Robot beside each.
+func sampleGraph(t *testing.T) (*StorageExecutor, *storage.MemoryEngine) {
+	engine := storage.NewMemoryEngine()
+	for i := 0; i < 30; i++ {
+		require.NoError(t, engine.CreateNode(&storage.Node{ID: storage.NodeID(fmt.Sprintf("p%02d", i)), Labels: []string{"Person"}}))
+		require.NoError(t, engine.CreateNode(&storage.Node{ID: storage.NodeID(fmt.Sprintf("r%02d", i)), Labels: []string{"Robot"}}))
+	}
+	for i := 0; i < 30; i++ {
+		p := storage.NodeID(fmt.Sprintf("p%02d", i))
+		require.NoError(t, engine.CreateEdge(&storage.Edge{ID: storage.EdgeID(fmt.Sprintf("k%02d", i)), Type: "KNOWS",
+			StartNode: p, EndNode: storage.NodeID(fmt.Sprintf("p%02d", (i+1)%30))}))
+		require.NoError(t, engine.CreateEdge(&storage.Edge{ID: storage.EdgeID(fmt.Sprintf("o%02d", i)), Type: "OWNS",
+			StartNode: p, EndNode: storage.NodeID(fmt.Sprintf("r%02d", i))}))
+	}
+	return NewStorageExecutor(engine), engine
+}
+
+func TestDbSampleNodes(t *testing.T) {
+	exec, _ := sampleGraph(t)
+	ctx := context.Background()
+
+	for _, method := range []string{"uniform", "randomWalk"} {
+		t.Run(method, func(t *testing.T) {
+			result, err := exec.Execute(ctx, fmt.Sprintf(`CALL db.sample.nodes('Person', 10, '%s') YIELD node RETURN node`, method), nil)
+			require.NoError(t, err)
+			require.Len(t, result.Rows, 10)
+			seen := map[string]bool{}
+			for _, row := range result.Rows {
+				node := row[0].(map[string]interface{})
+				assert.Equal(t, []string{"Person"}, node["labels"])
+				seen[node["_nodeId"].(string)] = true
+			}
+			assert.Len(t, seen, 10, "distinct nodes")
+		})
+	}
+
+	t.Run("all nodes", func(t *testing.T) {
+		result, err := exec.Execute(ctx, `CALL db.sample.nodes(null, 100)`, nil)
+		require.NoError(t, err)
+		assert.Len(t, result.Rows, 60)
+	})
+
+	for _, q := range []string{
+		`CALL db.sample.nodes('Person', 0)`,
+		`CALL db.sample.nodes('Person', 'ten')`,
+		`CALL db.sample.nodes('Person', 10, 'bfs')`,
+		`CALL db.sample.nodes(1, 10)`,
+	} {
+		_, err := exec.Execute(ctx, q, nil)
+		assert.Error(t, err, q)
+	}
+}
+
+func TestDbSampleSubgraph(t *testing.T) {
+	exec, engine := sampleGraph(t)
+	ctx := context.Background()
+
+	result, err := exec.Execute(ctx, `CALL db.sample.subgraph('p00', 12) YIELD nodes, relationships RETURN nodes, relationships`, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	nodes := result.Rows[0][0].([]interface{})
+	rels := result.Rows[0][1].([]interface{})
+	require.Len(t, nodes, 12)
+	assert.Equal(t, "p00", nodes[0].(map[string]interface{})["_nodeId"])
+
+	sampled := map[string]bool{}
+	for _, n := range nodes {
+		sampled[n.(map[string]interface{})["_nodeId"].(string)] = true
+	}
+	assert.Len(t, sampled, 12)
+
+	// Exactly the relationships between sampled nodes, each once.
+	var want []string
+	all, err := engine.AllEdges()
+	require.NoError(t, err)
+	for _, edge := range all {
+		if sampled[string(edge.StartNode)] && sampled[string(edge.EndNode)] {
+			want = append(want, string(edge.ID))
+		}
+	}
+	var got []string
+	for _, r := range rels {
+		got = append(got, r.(map[string]interface{})["_edgeId"].(string))
+	}
+	assert.ElementsMatch(t, want, got)
+	// A walk reaches every sampled node along sampled relationships.
+	assert.GreaterOrEqual(t, len(got), len(nodes)-1)
+
+	t.Run("smaller component", func(t *testing.T) {
+		require.NoError(t, engine.CreateNode(&storage.Node{ID: "a"}))
+		require.NoError(t, engine.CreateNode(&storage.Node{ID: "b"}))
+		require.NoError(t, engine.CreateEdge(&storage.Edge{ID: "ab", Type: "KNOWS", StartNode: "a", EndNode: "b"}))
+		result, err := exec.Execute(ctx, `CALL db.sample.subgraph('4:nornicdb:a', 10)`, nil)
+		require.NoError(t, err)
+		assert.Len(t, result.Rows[0][0], 2)
+		assert.Len(t, result.Rows[0][1], 1)
+
+		require.NoError(t, engine.CreateNode(&storage.Node{ID: "lonely"}))
+		result, err = exec.Execute(ctx, `CALL db.sample.subgraph('lonely', 5)`, nil)
+		require.NoError(t, err)
+		assert.Len(t, result.Rows[0][0], 1)
+		assert.Empty(t, result.Rows[0][1])
+	})
+
+	_, err = exec.Execute(ctx, `CALL db.sample.subgraph('missing', 5)`, nil)
+	assert.Error(t, err)
+}
@@ -6,6 +6,7 @@
 //   - CREATE RANGE INDEX
 //   - CREATE FULLTEXT INDEX
 //   - CREATE VECTOR INDEX
+//   - DROP INDEX
 package cypher
 
 import (
@@ -274,6 +275,9 @@ func (e *StorageExecutor) executeCreateFulltextIndex(ctx context.Context, cypher
 //	CREATE VECTOR INDEX index_name IF NOT EXISTS
 //	FOR (n:Label) ON (n.property)
 //	OPTIONS {indexConfig: {`vector.dimensions`: 1024, `vector.similarity_function`: 'cosine'}}
+//
+// The index is populated from existing nodes in the background; it reports
+// POPULATING in db.indexes() until then, and db.awaitIndex() waits for it.
 func (e *StorageExecutor) executeCreateVectorIndex(ctx context.Context, cypher string) (*ExecuteResult, error) {
 	// Pattern: CREATE VECTOR INDEX name IF NOT EXISTS FOR (n:Label) ON (n.property)
 	// Uses pre-compiled patterns from regex_patterns.go
@@ -301,7 +305,7 @@ func (e *StorageExecutor) executeCreateVectorIndex(ctx context.Context, cypher s
 
 		// Extract similarity function using pre-compiled pattern
 		if simMatches := vectorSimilarityPattern.FindStringSubmatch(cypher); simMatches != nil {
-			similarityFunc = simMatches[1]
+			similarityFunc = strings.ToLower(simMatches[1])
 		}
 	}
 
@@ -309,6 +313,50 @@ func (e *StorageExecutor) executeCreateVectorIndex(ctx context.Context, cypher s
 	if err := e.storage.GetSchema().AddVectorIndex(indexName, label, property, dimensions, similarityFunc); err != nil {
 		return nil, err
 	}
+	if err := e.registerVectorIndex(ctx, indexName); err != nil {
+		return nil, err
+	}
 
 	return &ExecuteResult{Columns: []string{}, Rows: [][]interface{}{}}, nil
 }
+
+// registerVectorIndex mirrors a schema vector index to the vector index
+// registry, if one is set. The schema definition wins when the index
+// already existed with different options (IF NOT EXISTS semantics).
+func (e *StorageExecutor) registerVectorIndex(ctx context.Context, name string) error {
+	if e.vectorIndexes == nil {
+		return nil
+	}
+	idx, exists := e.storage.GetSchema().GetVectorIndex(name)
+	if !exists {
+		return nil
+	}
+	if err := e.vectorIndexes.CreateVectorIndex(ctx, idx.Name, idx.Label, idx.Property, idx.Dimensions, idx.SimilarityFunc); err != nil {
+		return fmt.Errorf("failed to build vector index %s: %w", name, err)
+	}
+	return nil
+}
+
+// dropVectorIndex removes a vector index from the schema and the registry.
+// Returns false if no such vector index existed.
+func (e *StorageExecutor) dropVectorIndex(name string) bool {
+	dropped := e.storage.GetSchema().DropVectorIndex(name)
+	if e.vectorIndexes != nil && e.vectorIndexes.DropVectorIndex(name) {
+		dropped = true
+	}
+	return dropped
+}
+
+// executeDrop handles DROP commands.
+//
+// DROP INDEX removes property, composite and vector indexes (vectors stop
+// being maintained); other index and constraint drops are accepted as no-ops
+// (NornicDB manages those indexes internally).
+func (e *StorageExecutor) executeDrop(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	if matches := dropIndexPattern.FindStringSubmatch(cypher); matches != nil {
+		if !e.storage.GetSchema().DropPropertyIndex(matches[1]) {
+			e.dropVectorIndex(matches[1])
+		}
+	}
+	return &ExecuteResult{Columns: []string{}, Rows: [][]interface{}{}}, nil
+}
@@ -2,6 +2,7 @@ package cypher
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -308,6 +309,174 @@ func TestVectorIndexWithDifferentOptions(t *testing.T) {
 	}
 }
 
+// fakeVectorIndexRegistry records vector index definitions mirrored by the executor.
+type fakeVectorIndexRegistry struct {
+	created map[string]string // name -> "label.property/dims/similarity"
+}
+
+func (f *fakeVectorIndexRegistry) CreateVectorIndex(ctx context.Context, name, label, property string, dimensions int, similarity string) error {
+	f.created[name] = fmt.Sprintf("%s.%s/%d/%s", label, property, dimensions, similarity)
+	return nil
+}
+
+func (f *fakeVectorIndexRegistry) DropVectorIndex(name string) bool {
+	_, exists := f.created[name]
+	delete(f.created, name)
+	return exists
+}
+
+func TestVectorIndexMultipleDimensions(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	exec := NewStorageExecutor(store)
+	registry := &fakeVectorIndexRegistry{created: map[string]string{}}
+	exec.SetVectorIndexRegistry(registry)
+	ctx := context.Background()
+
+	queries := []string{
+		"CREATE VECTOR INDEX text_idx IF NOT EXISTS FOR (n:Document) ON (n.embedding) OPTIONS {indexConfig: {`vector.dimensions`: 1024, `vector.similarity_function`: 'COSINE'}}",
+		"CREATE VECTOR INDEX `image_idx` FOR (i:Image) ON i.clip OPTIONS {indexConfig: {`vector.dimensions`: 512, `vector.similarity_function`: 'euclidean'}}",
+		"CALL db.index.vector.createNodeIndex('code_idx', 'Code', 'vec', 768, 'dot')",
+	}
+	for _, q := range queries {
+		if _, err := exec.Execute(ctx, q, nil); err != nil {
+			t.Fatalf("Execute(%q) error: %v", q, err)
+		}
+	}
+
+	want := map[string]string{
+		"text_idx":  "Document.embedding/1024/cosine",
+		"image_idx": "Image.clip/512/euclidean",
+		"code_idx":  "Code.vec/768/dot",
+	}
+	for name, def := range want {
+		idx, exists := store.GetSchema().GetVectorIndex(name)
+		if !exists {
+			t.Fatalf("Vector index %s not found", name)
+		}
+		if got := fmt.Sprintf("%s.%s/%d/%s", idx.Label, idx.Property, idx.Dimensions, idx.SimilarityFunc); got != def {
+			t.Errorf("Schema %s = %s, want %s", name, got, def)
+		}
+		if registry.created[name] != def {
+			t.Errorf("Registry %s = %s, want %s", name, registry.created[name], def)
+		}
+	}
+
+	// Queries route to the index's label and vector property
+	store.CreateNode(&storage.Node{ID: "img1", Labels: []string{"Image"}, Properties: map[string]interface{}{"clip": []float64{1, 0}}})
+	store.CreateNode(&storage.Node{ID: "doc1", Labels: []string{"Document"}, Embedding: []float32{1, 0}})
+	store.GetSchema().AddVectorIndex("small_image_idx", "Image", "clip", 2, "cosine")
+	result, err := exec.Execute(ctx, "CALL db.index.vector.queryNodes('small_image_idx', 10, [1.0, 0.0]) YIELD node, score", nil)
+	if err != nil {
+		t.Fatalf("queryNodes error: %v", err)
+	}
+	if len(result.Rows) != 1 {
+		t.Fatalf("Expected only the Image node, got %d rows", len(result.Rows))
+	}
+
+	// DROP INDEX removes vector indexes from both
+	if _, err := exec.Execute(ctx, "DROP INDEX image_idx IF EXISTS", nil); err != nil {
+		t.Fatalf("DROP INDEX error: %v", err)
+	}
+	if _, err := exec.Execute(ctx, "CALL db.index.vector.drop('code_idx')", nil); err != nil {
+		t.Fatalf("db.index.vector.drop error: %v", err)
+	}
+	for _, name := range []string{"image_idx", "code_idx"} {
+		if _, exists := store.GetSchema().GetVectorIndex(name); exists {
+			t.Errorf("Vector index %s should be dropped from the schema", name)
+		}
+		if _, exists := registry.created[name]; exists {
+			t.Errorf("Vector index %s should be dropped from the registry", name)
+		}
+	}
+	if _, exists := store.GetSchema().GetVectorIndex("text_idx"); !exists {
+		t.Error("Other vector indexes should be kept")
+	}
+}
+
+// populatingVectorIndexRegistry reports every index as populating until ready is closed.
+type populatingVectorIndexRegistry struct {
+	fakeVectorIndexRegistry
+	ready chan struct{}
+}
+
+func (f *populatingVectorIndexRegistry) VectorIndexState(name string) (string, float64, bool) {
+	if _, exists := f.created[name]; !exists {
+		return "", 0, false
+	}
+	select {
+	case <-f.ready:
+		return "ONLINE", 100, true
+	default:
+		return "POPULATING", 40, true
+	}
+}
+
+func (f *populatingVectorIndexRegistry) AwaitVectorIndex(ctx context.Context, name string) error {
+	select {
+	case <-f.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestVectorIndexPopulationState(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	exec := NewStorageExecutor(store)
+	registry := &populatingVectorIndexRegistry{
+		fakeVectorIndexRegistry: fakeVectorIndexRegistry{created: map[string]string{}},
+		ready:                   make(chan struct{}),
+	}
+	exec.SetVectorIndexRegistry(registry)
+	ctx := context.Background()
+
+	for _, q := range []string{
+		"CREATE VECTOR INDEX image_idx FOR (i:Image) ON (i.clip) OPTIONS {indexConfig: {`vector.dimensions`: 2}}",
+		"CREATE INDEX name_idx FOR (n:Person) ON (n.name)",
+	} {
+		if _, err := exec.Execute(ctx, q, nil); err != nil {
+			t.Fatalf("Execute(%q) error: %v", q, err)
+		}
+	}
+
+	states := func() map[string]string {
+		result, err := exec.Execute(ctx, "CALL db.indexes()", nil)
+		if err != nil {
+			t.Fatalf("db.indexes() error: %v", err)
+		}
+		if got := result.Columns[5]; got != "populationPercent" {
+			t.Fatalf("Columns = %v, want populationPercent last", result.Columns)
+		}
+		states := make(map[string]string)
+		for _, row := range result.Rows {
+			states[row[0].(string)] = fmt.Sprintf("%v %v", row[4], row[5])
+		}
+		return states
+	}
+	if got := states(); got["image_idx"] != "POPULATING 40" || got["name_idx"] != "ONLINE 100" {
+		t.Errorf("States while populating = %v", got)
+	}
+
+	_, err := exec.Execute(ctx, "CALL db.awaitIndex('image_idx', 0)", nil)
+	if err == nil || !strings.Contains(err.Error(), "not online") {
+		t.Errorf("awaitIndex on a populating index should time out, got %v", err)
+	}
+	if _, err := exec.Execute(ctx, "CALL db.awaitIndex('name_idx', 0)", nil); err != nil {
+		t.Errorf("awaitIndex on a property index: %v", err)
+	}
+	if _, err := exec.Execute(ctx, "CALL db.awaitIndexes('soon')", nil); err == nil {
+		t.Error("awaitIndexes should reject a non-numeric timeout")
+	}
+
+	close(registry.ready)
+	if _, err := exec.Execute(ctx, "CALL db.awaitIndexes(5)", nil); err != nil {
+		t.Errorf("awaitIndexes once online: %v", err)
+	}
+	if got := states(); got["image_idx"] != "ONLINE 100" {
+		t.Errorf("States once online = %v", got)
+	}
+}
+
 func TestFulltextIndexMultipleProperties(t *testing.T) {
 	store := storage.NewMemoryEngine()
 	exec := NewStorageExecutor(store)
@@ -0,0 +1,498 @@
+// Package cypher - stored query library.
+//
+// Vetted queries are saved under a name and run by name with parameters, so
+// applications and Heimdall actions reference them instead of embedding raw
+// Cypher:
+//
+//	CALL db.query.save('topCustomers', 'MATCH (c:Customer) RETURN c.name ORDER BY c.revenue DESC LIMIT $n', 'Best customers by revenue')
+//	CALL db.query.run('topCustomers', {n: 10})
+//	CALL db.query.list()
+//	CALL db.query.history('topCustomers')
+//	CALL db.query.drop('topCustomers')
+//
+// Saving an existing name adds a version; run uses the latest one unless a
+// version is given: CALL db.query.run('topCustomers', {n: 10}, 1).
+//
+// Stored queries are kept in the graph as StoredQuery nodes, one per
+// version, so they persist and replicate with the data. A version's node ID
+// ends with a digest of its name, query, owner and roles. Cypher cannot
+// change node IDs, so a StoredQuery node created or edited by a plain
+// CREATE or SET no longer matches its ID and is ignored. Whether a version
+// writes is always derived from its query text, never read from the node.
+// Access is checked
+// against the principal the query was admitted for (ratelimit.NewContext):
+//   - saving requires write permission; a new version of an existing query
+//     may only be saved by its owner or an admin
+//   - running requires one of the query's roles, when any were given at
+//     save time, and write permission for queries that write
+//   - only the owner or an admin may drop a query
+//
+// Queries run without a principal (authentication disabled, embedded use)
+// are not restricted.
+package cypher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// StoredQueryLabel is the node label of stored query versions.
+const StoredQueryLabel = "StoredQuery"
+
+// ErrStoredQueryNotFound is returned when running an unknown stored query
+// or version.
+var ErrStoredQueryNotFound = errors.New("stored query not found")
+
+// storedQueryName matches valid stored query names.
+var storedQueryName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// StoredQuery is one version of a named, saved query.
+type StoredQuery struct {
+	Name        string
+	Version     int64
+	Query       string
+	Description string
+	Owner       string   // User who saved the version ("" without authentication)
+	Roles       []string // Roles allowed to run it (empty = anyone who can read)
+	ReadOnly    bool     // Analyzed from Query
+	CreatedAt   time.Time
+}
+
+// storedQueryNodeID returns the node ID of a stored query version: its name
+// and version followed by a digest of the fields that control what it runs
+// and who may run it.
+func storedQueryNodeID(sq *StoredQuery) storage.NodeID {
+	roles := sq.Roles
+	if len(roles) == 0 {
+		roles = nil // Saved without a roles property
+	}
+	fields, _ := json.Marshal([]interface{}{
+		sq.Name, sq.Version, sq.Query, sq.Description, sq.Owner, roles, sq.CreatedAt.Format(time.RFC3339),
+	})
+	return storage.NodeID(fmt.Sprintf("stored-query:%s:%d:%x", sq.Name, sq.Version, sha256.Sum256(fields)))
+}
+
+// storedQueryFromNode reads a stored query version. ok is false if the node
+// was not written by SaveQuery as it is.
+func (e *StorageExecutor) storedQueryFromNode(n *storage.Node) (sq *StoredQuery, ok bool) {
+	sq = &StoredQuery{}
+	sq.Name, _ = n.Properties["name"].(string)
+	sq.Query, _ = n.Properties["query"].(string)
+	sq.Description, _ = n.Properties["description"].(string)
+	sq.Owner, _ = n.Properties["owner"].(string)
+	sq.Version = toInt64(n.Properties["version"])
+	sq.Roles = roleNames(n.Properties["roles"])
+	if s, ok := n.Properties["createdAt"].(string); ok {
+		sq.CreatedAt, _ = time.Parse(time.RFC3339, s)
+	}
+	if n.ID != storedQueryNodeID(sq) {
+		log.Printf("⚠️  Ignoring StoredQuery node %s: changed outside db.query.save", n.ID)
+		return nil, false
+	}
+	sq.ReadOnly = e.analyzer.Analyze(sq.Query).IsReadOnly
+	return sq, true
+}
+
+// queryCaller returns the principal the running query was admitted for.
+// ok is false for queries without an authenticated principal.
+func queryCaller(ctx context.Context) (p ratelimit.Principal, ok bool) {
+	p, ok = ratelimit.PrincipalFromContext(ctx)
+	return p, ok && (p.User != "" || len(p.Roles) > 0)
+}
+
+// callerHas reports whether one of the principal's roles grants perm.
+func callerHas(p ratelimit.Principal, perm auth.Permission) bool {
+	for _, role := range p.Roles {
+		for _, granted := range auth.RolePermissions[auth.Role(role)] {
+			if granted == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// canManage reports whether the caller may save versions of or drop sq.
+func canManage(ctx context.Context, sq *StoredQuery) bool {
+	p, ok := queryCaller(ctx)
+	return !ok || p.User == sq.Owner || callerHas(p, auth.PermAdmin)
+}
+
+// canRun reports whether the caller may run sq.
+func canRun(ctx context.Context, sq *StoredQuery) bool {
+	p, ok := queryCaller(ctx)
+	if !ok || callerHas(p, auth.PermAdmin) {
+		return true
+	}
+	if !sq.ReadOnly && !callerHas(p, auth.PermWrite) {
+		return false
+	}
+	if p.User == sq.Owner || len(sq.Roles) == 0 {
+		return true
+	}
+	for _, role := range p.Roles {
+		for _, allowed := range sq.Roles {
+			if strings.EqualFold(role, allowed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// storedQueryVersions returns the versions of a stored query, oldest first.
+func (e *StorageExecutor) storedQueryVersions(name string) ([]*StoredQuery, error) {
+	nodes, err := e.storage.GetNodesByLabel(StoredQueryLabel)
+	if err != nil {
+		return nil, err
+	}
+	var versions []*StoredQuery
+	for _, n := range nodes {
+		if n.Properties["name"] != name {
+			continue
+		}
+		if sq, ok := e.storedQueryFromNode(n); ok {
+			versions = append(versions, sq)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// SaveQuery saves query under name as a new version, which run uses from
+// then on. roles restricts who may run it.
+func (e *StorageExecutor) SaveQuery(ctx context.Context, name, query, description string, roles []string) (*StoredQuery, error) {
+	if !storedQueryName.MatchString(name) {
+		return nil, fmt.Errorf("invalid stored query name %q", name)
+	}
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("stored query %s: empty query", name)
+	}
+	if err := e.validateSyntax(query); err != nil {
+		return nil, fmt.Errorf("stored query %s: %w", name, err)
+	}
+	if strings.Contains(strings.ToUpper(query), "DB.QUERY.") {
+		return nil, fmt.Errorf("stored query %s: stored queries cannot call db.query procedures", name)
+	}
+	if p, ok := queryCaller(ctx); ok && !callerHas(p, auth.PermWrite) {
+		return nil, fmt.Errorf("%w: saving stored queries requires write permission", auth.ErrInsufficientRole)
+	}
+
+	// Saves are serialized so two cannot both create the next version
+	e.storedQueryMu.Lock()
+	defer e.storedQueryMu.Unlock()
+	versions, err := e.storedQueryVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	sq := &StoredQuery{
+		Name:        name,
+		Version:     1,
+		Query:       query,
+		Description: description,
+		Roles:       roles,
+		ReadOnly:    e.analyzer.Analyze(query).IsReadOnly,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		if !canManage(ctx, latest) {
+			return nil, fmt.Errorf("%w: stored query %s is owned by %s", auth.ErrInsufficientRole, name, latest.Owner)
+		}
+		sq.Version = latest.Version + 1
+	}
+	if p, ok := queryCaller(ctx); ok {
+		sq.Owner = p.User
+	}
+
+	props := map[string]interface{}{
+		"name":        sq.Name,
+		"version":     sq.Version,
+		"query":       sq.Query,
+		"description": sq.Description,
+		"owner":       sq.Owner,
+		"createdAt":   sq.CreatedAt.Format(time.RFC3339),
+	}
+	if len(roles) > 0 {
+		props["roles"] = roles
+	}
+	err = e.getStorage(ctx).CreateNode(&storage.Node{
+		ID:         storedQueryNodeID(sq),
+		Labels:     []string{StoredQueryLabel},
+		Properties: props,
+	})
+	if errors.Is(err, storage.ErrAlreadyExists) {
+		return nil, fmt.Errorf("stored query %s: version %d was saved concurrently: %w", name, sq.Version, storage.ErrConflict)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sq, nil
+}
+
+// StoredQueries returns the latest version of each stored query the caller
+// may run, sorted by name.
+func (e *StorageExecutor) StoredQueries(ctx context.Context) ([]*StoredQuery, error) {
+	nodes, err := e.storage.GetNodesByLabel(StoredQueryLabel)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]*StoredQuery)
+	for _, n := range nodes {
+		sq, ok := e.storedQueryFromNode(n)
+		if !ok {
+			continue
+		}
+		if cur, ok := latest[sq.Name]; !ok || sq.Version > cur.Version {
+			latest[sq.Name] = sq
+		}
+	}
+	queries := make([]*StoredQuery, 0, len(latest))
+	for _, sq := range latest {
+		if canRun(ctx, sq) {
+			queries = append(queries, sq)
+		}
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i].Name < queries[j].Name })
+	return queries, nil
+}
+
+// StoredQueryHistory returns the versions of a stored query, oldest first.
+func (e *StorageExecutor) StoredQueryHistory(ctx context.Context, name string) ([]*StoredQuery, error) {
+	versions, err := e.storedQueryVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrStoredQueryNotFound, name)
+	}
+	if latest := versions[len(versions)-1]; !canRun(ctx, latest) && !canManage(ctx, latest) {
+		return nil, fmt.Errorf("%w: stored query %s", auth.ErrInsufficientRole, name)
+	}
+	return versions, nil
+}
+
+// RunStoredQuery runs a version of a stored query with params. Version 0
+// runs the latest version.
+func (e *StorageExecutor) RunStoredQuery(ctx context.Context, name string, version int64, params map[string]interface{}) (*ExecuteResult, error) {
+	versions, err := e.storedQueryVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrStoredQueryNotFound, name)
+	}
+	sq := versions[len(versions)-1]
+	if version > 0 {
+		sq = nil
+		for _, v := range versions {
+			if v.Version == version {
+				sq = v
+			}
+		}
+		if sq == nil {
+			return nil, fmt.Errorf("%w: %s version %d", ErrStoredQueryNotFound, name, version)
+		}
+	}
+	// Access follows the owner and roles of the latest version, so
+	// restricting a query also covers its older versions
+	check := *sq
+	check.Owner, check.Roles = versions[len(versions)-1].Owner, versions[len(versions)-1].Roles
+	if !canRun(ctx, &check) {
+		return nil, fmt.Errorf("%w: stored query %s", auth.ErrInsufficientRole, name)
+	}
+	return e.Execute(ctx, sq.Query, params)
+}
+
+// DropStoredQuery deletes all versions of a stored query, returning how
+// many were deleted.
+func (e *StorageExecutor) DropStoredQuery(ctx context.Context, name string) (int, error) {
+	versions, err := e.storedQueryVersions(name)
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+	if !canManage(ctx, versions[len(versions)-1]) {
+		return 0, fmt.Errorf("%w: stored query %s is owned by %s", auth.ErrInsufficientRole, name, versions[len(versions)-1].Owner)
+	}
+	store := e.getStorage(ctx)
+	for _, sq := range versions {
+		if err := store.DeleteNode(storedQueryNodeID(sq)); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return 0, err
+		}
+	}
+	return len(versions), nil
+}
+
+// callDbQuerySave implements
+// CALL db.query.save(name, query, description[, roles]).
+func (e *StorageExecutor) callDbQuerySave(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	const proc = "db.query.save"
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 2 || len(args) > 4 {
+		return nil, fmt.Errorf("%s requires (name, query[, description[, roles]])", proc)
+	}
+	name, _ := args[0].(string)
+	query, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: query must be a string", proc)
+	}
+	var description string
+	if len(args) > 2 && args[2] != nil {
+		if description, ok = args[2].(string); !ok {
+			return nil, fmt.Errorf("%s: description must be a string", proc)
+		}
+	}
+	var roles []string
+	if len(args) > 3 && args[3] != nil {
+		list, ok := args[3].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: roles must be a list of role names", proc)
+		}
+		roles = roleNames(list)
+	}
+
+	sq, err := e.SaveQuery(ctx, name, query, description, roles)
+	if err != nil {
+		return nil, err
+	}
+	return &ExecuteResult{
+		Columns: []string{"name", "version", "readOnly"},
+		Rows:    [][]interface{}{{sq.Name, sq.Version, sq.ReadOnly}},
+	}, nil
+}
+
+// callDbQueryRun implements CALL db.query.run(name[, params[, version]]),
+// returning the stored query's own columns. Without a params map it runs
+// with the parameters of the CALL request.
+func (e *StorageExecutor) callDbQueryRun(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	const proc = "db.query.run"
+	args, err := e.procedureArgs(cypher, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 1 || len(args) > 3 {
+		return nil, fmt.Errorf("%s requires (name[, params[, version]])", proc)
+	}
+	name, _ := args[0].(string)
+	params := getParamsFromContext(ctx)
+	if len(args) > 1 && args[1] != nil {
+		if params, err = toParamsMap(proc, args[1]); err != nil {
+			return nil, err
+		}
+	}
+	var version int64
+	if len(args) > 2 {
+		if v, ok := args[2].(int64); ok && v > 0 {
+			version = v
+		} else {
+			return nil, fmt.Errorf("%s: version must be a positive integer", proc)
+		}
+	}
+	return e.RunStoredQuery(ctx, name, version, params)
+}
+
+// toParamsMap converts a procedure's parameter map argument.
+func toParamsMap(proc string, v interface{}) (map[string]interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: params must be a map", proc)
+	}
+	return m, nil
+}
+
+// callDbQueryList implements CALL db.query.list().
+func (e *StorageExecutor) callDbQueryList(ctx context.Context) (*ExecuteResult, error) {
+	queries, err := e.StoredQueries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &ExecuteResult{
+		Columns: []string{"name", "version", "description", "query", "owner", "roles", "readOnly", "createdAt"},
+		Rows:    [][]interface{}{},
+	}
+	for _, sq := range queries {
+		result.Rows = append(result.Rows, []interface{}{
+			sq.Name, sq.Version, sq.Description, sq.Query, sq.Owner, stringsToInterfaces(sq.Roles), sq.ReadOnly, sq.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return result, nil
+}
+
+// callDbQueryHistory implements CALL db.query.history(name).
+func (e *StorageExecutor) callDbQueryHistory(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	name, err := procedureStringArg(cypher, "db.query.history", "a stored query name")
+	if err != nil {
+		return nil, err
+	}
+	versions, err := e.StoredQueryHistory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	result := &ExecuteResult{
+		Columns: []string{"name", "version", "description", "query", "owner", "readOnly", "createdAt"},
+		Rows:    [][]interface{}{},
+	}
+	for _, sq := range versions {
+		result.Rows = append(result.Rows, []interface{}{
+			sq.Name, sq.Version, sq.Description, sq.Query, sq.Owner, sq.ReadOnly, sq.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return result, nil
+}
+
+// callDbQueryDrop implements CALL db.query.drop(name).
+func (e *StorageExecutor) callDbQueryDrop(ctx context.Context, cypher string) (*ExecuteResult, error) {
+	name, err := procedureStringArg(cypher, "db.query.drop", "a stored query name")
+	if err != nil {
+		return nil, err
+	}
+	dropped, err := e.DropStoredQuery(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &ExecuteResult{
+		Columns: []string{"name", "versions"},
+		Rows:    [][]interface{}{{name, int64(dropped)}},
+	}, nil
+}
+
+// roleNames returns the strings of a list property or argument.
+func roleNames(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		names := make([]string, 0, len(list))
+		for _, e := range list {
+			if s, ok := e.(string); ok && s != "" {
+				names = append(names, s)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func stringsToInterfaces(s []string) []interface{} {
+	out := make([]interface{}, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
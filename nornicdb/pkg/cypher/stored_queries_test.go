@@ -0,0 +1,259 @@
+package cypher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStoredQueryExecutor(t *testing.T) *StorageExecutor {
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+	for _, q := range []string{
+		`CREATE (:Customer {name: 'Ada', revenue: 300})`,
+		`CREATE (:Customer {name: 'Bo', revenue: 200})`,
+		`CREATE (:Customer {name: 'Cy', revenue: 100})`,
+	} {
+		_, err := exec.Execute(context.Background(), q, nil)
+		require.NoError(t, err)
+	}
+	return exec
+}
+
+func asUser(user string, roles ...string) context.Context {
+	return ratelimit.NewContext(context.Background(), ratelimit.Principal{User: user, Roles: roles})
+}
+
+func TestStoredQueries_SaveAndRun(t *testing.T) {
+	ctx := context.Background()
+	exec := newStoredQueryExecutor(t)
+
+	result, err := exec.Execute(ctx, `CALL db.query.save('topCustomers', 'MATCH (c:Customer) RETURN c.name ORDER BY c.revenue DESC LIMIT $n', 'Best customers')`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "version", "readOnly"}, result.Columns)
+	assert.Equal(t, []interface{}{"topCustomers", int64(1), true}, result.Rows[0])
+
+	result, err = exec.Execute(ctx, `CALL db.query.run('topCustomers', {n: 2})`, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 2)
+	assert.Equal(t, "Ada", result.Rows[0][0])
+	assert.Equal(t, "Bo", result.Rows[1][0])
+
+	t.Run("request parameters", func(t *testing.T) {
+		result, err := exec.Execute(ctx, `CALL db.query.run($name, $params)`, map[string]interface{}{
+			"name": "topCustomers", "params": map[string]interface{}{"n": int64(1)},
+		})
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 1)
+		assert.Equal(t, "Ada", result.Rows[0][0])
+
+		result, err = exec.Execute(ctx, `CALL db.query.run('topCustomers')`, map[string]interface{}{"n": int64(3)})
+		require.NoError(t, err)
+		assert.Len(t, result.Rows, 3)
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		result, err := exec.Execute(ctx, `CALL db.query.save('topCustomers', 'MATCH (c:Customer) RETURN c.name ORDER BY c.revenue ASC LIMIT $n', 'Worst customers')`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Rows[0][1])
+
+		result, err = exec.Execute(ctx, `CALL db.query.run('topCustomers', {n: 1})`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "Cy", result.Rows[0][0])
+
+		result, err = exec.Execute(ctx, `CALL db.query.run('topCustomers', {n: 1}, 1)`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "Ada", result.Rows[0][0])
+
+		_, err = exec.Execute(ctx, `CALL db.query.run('topCustomers', {n: 1}, 3)`, nil)
+		assert.ErrorIs(t, err, ErrStoredQueryNotFound)
+
+		result, err = exec.Execute(ctx, `CALL db.query.history('topCustomers')`, nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 2)
+		assert.Equal(t, int64(1), result.Rows[0][1])
+		assert.Equal(t, "Best customers", result.Rows[0][2])
+		assert.Equal(t, int64(2), result.Rows[1][1])
+	})
+
+	t.Run("list and drop", func(t *testing.T) {
+		_, err := exec.Execute(ctx, `CALL db.query.save('raise', 'MATCH (c:Customer {name: $name}) SET c.revenue = c.revenue + 1')`, nil)
+		require.NoError(t, err)
+
+		result, err := exec.Execute(ctx, `CALL db.query.list()`, nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 2)
+		assert.Equal(t, "raise", result.Rows[0][0])
+		assert.Equal(t, false, result.Rows[0][6], "raise writes")
+		assert.Equal(t, "topCustomers", result.Rows[1][0])
+		assert.Equal(t, int64(2), result.Rows[1][1])
+
+		result, err = exec.Execute(ctx, `CALL db.query.drop('topCustomers')`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{"topCustomers", int64(2)}, result.Rows[0])
+
+		_, err = exec.Execute(ctx, `CALL db.query.run('topCustomers', {n: 1})`, nil)
+		assert.ErrorIs(t, err, ErrStoredQueryNotFound)
+	})
+
+	t.Run("results are not cached", func(t *testing.T) {
+		_, err := exec.Execute(ctx, `CALL db.query.save('count', 'MATCH (c:Customer) RETURN count(c) AS n')`, nil)
+		require.NoError(t, err)
+		result, err := exec.Execute(ctx, `CALL db.query.run('count', {})`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), result.Rows[0][0])
+
+		_, err = exec.Execute(ctx, `CREATE (:Customer {name: 'Di'})`, nil)
+		require.NoError(t, err)
+		result, err = exec.Execute(ctx, `CALL db.query.run('count', {})`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), result.Rows[0][0])
+	})
+}
+
+func TestStoredQueries_Validation(t *testing.T) {
+	ctx := context.Background()
+	exec := newStoredQueryExecutor(t)
+
+	for name, q := range map[string]string{
+		"bad name":      `CALL db.query.save('not a name', 'MATCH (n) RETURN n')`,
+		"empty query":   `CALL db.query.save('q', '')`,
+		"syntax":        `CALL db.query.save('q', 'MATCH (n RETURN n')`,
+		"recursive":     `CALL db.query.save('q', 'CALL db.query.run(\"q\")')`,
+		"missing query": `CALL db.query.save('q')`,
+		"bad roles":     `CALL db.query.save('q', 'MATCH (n) RETURN n', 'd', 'admin')`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := exec.Execute(ctx, q, nil)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := exec.Execute(ctx, `CALL db.query.run('nope')`, nil)
+	assert.ErrorIs(t, err, ErrStoredQueryNotFound)
+	_, err = exec.Execute(ctx, `CALL db.query.history('nope')`, nil)
+	assert.ErrorIs(t, err, ErrStoredQueryNotFound)
+}
+
+func TestStoredQueries_AccessControl(t *testing.T) {
+	exec := newStoredQueryExecutor(t)
+	alice := asUser("alice", "editor")
+	bob := asUser("bob", "editor")
+	viewer := asUser("vic", "viewer")
+	analyst := asUser("ann", "viewer", "analyst")
+	admin := asUser("root", "admin")
+
+	_, err := exec.Execute(viewer, `CALL db.query.save('q', 'MATCH (c:Customer) RETURN c.name')`, nil)
+	assert.ErrorIs(t, err, auth.ErrInsufficientRole, "saving needs write permission")
+
+	_, err = exec.Execute(alice, `CALL db.query.save('names', 'MATCH (c:Customer) RETURN c.name', 'Names', ['analyst'])`, nil)
+	require.NoError(t, err)
+	_, err = exec.Execute(alice, `CALL db.query.save('raise', 'MATCH (c:Customer) SET c.revenue = c.revenue + 1')`, nil)
+	require.NoError(t, err)
+
+	t.Run("run", func(t *testing.T) {
+		for _, ctx := range []context.Context{alice, analyst, admin} {
+			_, err := exec.Execute(ctx, `CALL db.query.run('names', {})`, nil)
+			assert.NoError(t, err)
+		}
+		for _, ctx := range []context.Context{bob, viewer} {
+			_, err := exec.Execute(ctx, `CALL db.query.run('names', {})`, nil)
+			assert.ErrorIs(t, err, auth.ErrInsufficientRole)
+		}
+
+		_, err := exec.Execute(bob, `CALL db.query.run('raise', {})`, nil)
+		assert.NoError(t, err)
+		_, err = exec.Execute(viewer, `CALL db.query.run('raise', {})`, nil)
+		assert.ErrorIs(t, err, auth.ErrInsufficientRole, "writes need write permission")
+	})
+
+	t.Run("list shows runnable queries", func(t *testing.T) {
+		result, err := exec.Execute(viewer, `CALL db.query.list()`, nil)
+		require.NoError(t, err)
+		assert.Empty(t, result.Rows)
+
+		result, err = exec.Execute(analyst, `CALL db.query.list()`, nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 1)
+		assert.Equal(t, "names", result.Rows[0][0])
+		assert.Equal(t, "alice", result.Rows[0][4])
+		assert.Equal(t, []interface{}{"analyst"}, result.Rows[0][5])
+	})
+
+	t.Run("only owner or admin manage", func(t *testing.T) {
+		_, err := exec.Execute(bob, `CALL db.query.save('names', 'MATCH (c:Customer) RETURN c')`, nil)
+		assert.ErrorIs(t, err, auth.ErrInsufficientRole)
+		_, err = exec.Execute(bob, `CALL db.query.drop('names')`, nil)
+		assert.ErrorIs(t, err, auth.ErrInsufficientRole)
+
+		_, err = exec.Execute(alice, `CALL db.query.save('names', 'MATCH (c:Customer) RETURN c.name ORDER BY c.name')`, nil)
+		require.NoError(t, err)
+		// Roles are per version; the new one has none
+		_, err = exec.Execute(bob, `CALL db.query.run('names', {}, 1)`, nil)
+		assert.NoError(t, err, "older versions follow the latest version's roles")
+
+		result, err := exec.Execute(admin, `CALL db.query.drop('names')`, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Rows[0][1])
+	})
+}
+
+func TestStoredQueries_DirectEditsIgnored(t *testing.T) {
+	exec := newStoredQueryExecutor(t)
+	alice := asUser("alice", "editor")
+	bob := asUser("bob", "editor")
+	viewer := asUser("vic", "viewer")
+
+	_, err := exec.Execute(alice, `CALL db.query.save('names', 'MATCH (c:Customer) RETURN c.name', 'Names', ['analyst'])`, nil)
+	require.NoError(t, err)
+	_, err = exec.Execute(alice, `CALL db.query.save('raise', 'MATCH (c:Customer) SET c.revenue = c.revenue + 1')`, nil)
+	require.NoError(t, err)
+	_, err = exec.Execute(bob, `CALL db.query.run('names', {})`, nil)
+	require.ErrorIs(t, err, auth.ErrInsufficientRole)
+
+	t.Run("edited owner and roles", func(t *testing.T) {
+		_, err := exec.Execute(bob, `MATCH (q:StoredQuery {name: 'names'}) SET q.owner = 'bob', q.roles = []`, nil)
+		require.NoError(t, err)
+		_, err = exec.Execute(bob, `CALL db.query.run('names', {})`, nil)
+		assert.ErrorIs(t, err, ErrStoredQueryNotFound, "the edited version is ignored")
+	})
+
+	t.Run("forged readOnly", func(t *testing.T) {
+		_, err := exec.Execute(bob, `MATCH (q:StoredQuery {name: 'raise'}) SET q.readOnly = true`, nil)
+		require.NoError(t, err)
+		_, err = exec.Execute(viewer, `CALL db.query.run('raise', {})`, nil)
+		assert.ErrorIs(t, err, auth.ErrInsufficientRole, "read-only is analyzed from the query")
+	})
+
+	t.Run("created version", func(t *testing.T) {
+		_, err := exec.Execute(bob, `CREATE (:StoredQuery {name: 'raise', version: 2, query: 'MATCH (c:Customer) DETACH DELETE c', owner: 'alice', readOnly: true})`, nil)
+		require.NoError(t, err)
+		versions, err := exec.StoredQueryHistory(context.Background(), "raise")
+		require.NoError(t, err)
+		require.Len(t, versions, 1, "only the saved version counts")
+		assert.Contains(t, versions[0].Query, "SET c.revenue")
+		assert.False(t, versions[0].ReadOnly)
+	})
+}
+
+func TestStoredQueries_PersistedIDsMatch(t *testing.T) {
+	engine, err := storage.NewBadgerEngineInMemory()
+	require.NoError(t, err)
+	defer engine.Close()
+	exec := NewStorageExecutor(engine)
+	alice := asUser("alice", "editor")
+
+	_, err = exec.Execute(alice, `CALL db.query.save('open', 'RETURN 1 AS one')`, nil)
+	require.NoError(t, err)
+	_, err = exec.Execute(alice, `CALL db.query.save('restricted', 'RETURN 2 AS two', 'Two', ['analyst', 'admin'])`, nil)
+	require.NoError(t, err)
+
+	queries, err := exec.StoredQueries(context.Background())
+	require.NoError(t, err)
+	require.Len(t, queries, 2, "versions read back from storage keep their digest")
+	assert.Equal(t, []string{"analyst", "admin"}, queries[1].Roles)
+}
@@ -0,0 +1,141 @@
+// Package cypher - TLP explanation procedure.
+//
+// Edges created by the inference engine (topological link prediction, "TLP")
+// carry their provenance as properties: the score each method gave, the
+// ensemble weights, and the Heimdall QC decision with the hash of the prompt
+// it reviewed. CALL db.tlp.explain(edgeId) reads those back and returns the
+// full decision chain:
+//
+//	CALL db.tlp.explain('edge-123')
+//	YIELD method, scores, qcDecision, chain
+//	RETURN method, scores, qcDecision, chain
+//
+// The chain is an ordered list of steps: one "scorer" step per method, the
+// "ensemble" blend, the "qc" review, and finally "created".
+package cypher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// tlpNotReviewed is reported when an edge has no QC decision recorded.
+const tlpNotReviewed = "not_reviewed"
+
+// callDbTlpExplain implements CALL db.tlp.explain(edgeId).
+func (e *StorageExecutor) callDbTlpExplain(cypher string) (*ExecuteResult, error) {
+	upper := strings.ToUpper(cypher)
+	idx := strings.Index(upper, "DB.TLP.EXPLAIN")
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid db.tlp.explain syntax")
+	}
+
+	argsStart := strings.Index(cypher[idx:], "(")
+	argsEnd := strings.LastIndex(cypher[idx:], ")")
+	if argsStart < 0 || argsEnd < argsStart {
+		return nil, fmt.Errorf("invalid db.tlp.explain syntax: missing parentheses")
+	}
+
+	edgeID := strings.Trim(strings.TrimSpace(cypher[idx+argsStart+1:idx+argsEnd]), "'\"")
+	if edgeID == "" {
+		return nil, fmt.Errorf("db.tlp.explain requires an edge ID")
+	}
+
+	edge, err := e.storage.GetEdge(storage.EdgeID(edgeID))
+	if err != nil || edge == nil {
+		return nil, fmt.Errorf("relationship not found: %s", edgeID)
+	}
+
+	props := edge.Properties
+	method, _ := props[storage.EdgePropMethod].(string)
+	reason, _ := props[storage.EdgePropReason].(string)
+	scores := tlpFloatMap(props[storage.EdgePropScores])
+	weights := tlpFloatMap(props[storage.EdgePropWeights])
+	qcDecision, _ := props[storage.EdgePropQCDecision].(string)
+	if qcDecision == "" {
+		qcDecision = tlpNotReviewed
+	}
+	qcReasoning, _ := props[storage.EdgePropQCReasoning].(string)
+	promptHash, _ := props[storage.EdgePropPromptHash].(string)
+
+	// Methods in the order the ensemble listed them, then any extras
+	methods := []string{}
+	seen := make(map[string]bool)
+	for _, m := range strings.Split(method, ",") {
+		if m = strings.TrimSpace(m); m != "" && !seen[m] {
+			seen[m] = true
+			methods = append(methods, m)
+		}
+	}
+	extra := []string{}
+	for m := range scores {
+		if !seen[m] {
+			extra = append(extra, m)
+		}
+	}
+	sort.Strings(extra)
+	methods = append(methods, extra...)
+
+	contributions := make(map[string]interface{}, len(scores))
+	chain := make([]interface{}, 0, len(methods)+3)
+	for _, m := range methods {
+		step := map[string]interface{}{"step": "scorer", "method": m}
+		if score, ok := scores[m]; ok {
+			step["score"] = score
+			if weight, ok := weights[m]; ok {
+				step["weight"] = weight
+				step["contribution"] = score * weight
+				contributions[m] = score * weight
+			}
+		}
+		chain = append(chain, step)
+	}
+	chain = append(chain,
+		map[string]interface{}{"step": "ensemble", "confidence": edge.Confidence, "reason": reason},
+		map[string]interface{}{"step": "qc", "decision": qcDecision, "reasoning": qcReasoning, "promptHash": promptHash},
+		map[string]interface{}{"step": "created", "type": edge.Type, "autoGenerated": edge.AutoGenerated, "createdAt": edge.CreatedAt.Unix()},
+	)
+
+	return &ExecuteResult{
+		Columns: []string{
+			"edgeId", "type", "source", "target", "confidence", "autoGenerated",
+			"method", "reason", "scores", "weights", "contributions",
+			"qcDecision", "qcReasoning", "promptHash", "createdAt", "chain",
+		},
+		Rows: [][]interface{}{{
+			string(edge.ID), edge.Type, string(edge.StartNode), string(edge.EndNode), edge.Confidence, edge.AutoGenerated,
+			method, reason, tlpAnyMap(scores), tlpAnyMap(weights), contributions,
+			qcDecision, qcReasoning, promptHash, edge.CreatedAt.Unix(), chain,
+		}},
+	}, nil
+}
+
+// tlpFloatMap reads a method -> number property map.
+func tlpFloatMap(v interface{}) map[string]float64 {
+	out := make(map[string]float64)
+	switch m := v.(type) {
+	case map[string]interface{}:
+		for k, val := range m {
+			if f, ok := toFloat64(val); ok {
+				out[k] = f
+			}
+		}
+	case map[string]float64:
+		for k, val := range m {
+			out[k] = val
+		}
+	}
+	return out
+}
+
+// tlpAnyMap converts for use as a Cypher map value.
+func tlpAnyMap(m map[string]float64) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package cypher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallDbTlpExplain(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	exec := NewStorageExecutor(store)
+	ctx := context.Background()
+
+	for _, id := range []string{"a", "b"} {
+		require.NoError(t, store.CreateNode(&storage.Node{ID: storage.NodeID(id), Labels: []string{"Memory"}}))
+	}
+	created := time.Unix(1700000000, 0)
+	require.NoError(t, store.CreateEdge(&storage.Edge{
+		ID:            "inferred",
+		StartNode:     "a",
+		EndNode:       "b",
+		Type:          "RELATES_TO",
+		Confidence:    0.7,
+		AutoGenerated: true,
+		CreatedAt:     created,
+		Properties: map[string]interface{}{
+			storage.EdgePropMethod:      "similarity,co_access",
+			storage.EdgePropReason:      "High embedding similarity + Frequently co-accessed",
+			storage.EdgePropScores:      map[string]interface{}{"similarity": 0.9, "co_access": 0.5},
+			storage.EdgePropWeights:     map[string]interface{}{"similarity": 0.5, "co_access": 0.5},
+			storage.EdgePropQCDecision:  "approved",
+			storage.EdgePropQCReasoning: "related topics",
+			storage.EdgePropPromptHash:  "abc123",
+		},
+	}))
+	require.NoError(t, store.CreateEdge(&storage.Edge{ID: "manual", StartNode: "b", EndNode: "a", Type: "KNOWS", CreatedAt: created}))
+
+	result, err := exec.Execute(ctx, "CALL db.tlp.explain($id)", map[string]interface{}{"id": "inferred"})
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	row := make(map[string]interface{})
+	for i, col := range result.Columns {
+		row[col] = result.Rows[0][i]
+	}
+
+	assert.Equal(t, "inferred", row["edgeId"])
+	assert.Equal(t, "a", row["source"])
+	assert.Equal(t, "b", row["target"])
+	assert.Equal(t, 0.7, row["confidence"])
+	assert.Equal(t, true, row["autoGenerated"])
+	assert.Equal(t, "approved", row["qcDecision"])
+	assert.Equal(t, "related topics", row["qcReasoning"])
+	assert.Equal(t, "abc123", row["promptHash"])
+	assert.Equal(t, created.Unix(), row["createdAt"])
+	contributions := row["contributions"].(map[string]interface{})
+	assert.InDelta(t, 0.45, contributions["similarity"], 0.001)
+	assert.InDelta(t, 0.25, contributions["co_access"], 0.001)
+
+	chain := row["chain"].([]interface{})
+	steps := []string{}
+	for _, s := range chain {
+		steps = append(steps, s.(map[string]interface{})["step"].(string))
+	}
+	assert.Equal(t, []string{"scorer", "scorer", "ensemble", "qc", "created"}, steps)
+	assert.Equal(t, "similarity", chain[0].(map[string]interface{})["method"], "scorers keep the method order")
+
+	// Edges without provenance still explain, as not reviewed
+	result, err = exec.Execute(ctx, "CALL db.tlp.explain('manual')", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, tlpNotReviewed, result.Rows[0][11])
+	assert.Len(t, result.Rows[0][15], 3)
+
+	_, err = exec.Execute(ctx, "CALL db.tlp.explain('missing')", nil)
+	assert.Error(t, err)
+	_, err = exec.Execute(ctx, "CALL db.tlp.explain()", nil)
+	assert.Error(t, err)
+}
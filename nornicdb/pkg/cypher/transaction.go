@@ -267,8 +267,7 @@ func (e *StorageExecutor) executeQueryAgainstStorage(ctx context.Context, cypher
 			return nil, fmt.Errorf("unsupported SHOW command in transaction: %s", cypher)
 		}
 	case strings.HasPrefix(upper, "DROP"):
-		// DROP INDEX/CONSTRAINT - treat as no-op (NornicDB manages indexes internally)
-		return &ExecuteResult{Columns: []string{}, Rows: [][]interface{}{}}, nil
+		return e.executeDrop(ctx, cypher)
 	case strings.HasPrefix(upper, "UNWIND"):
 		return e.executeUnwind(ctx, cypher)
 	case strings.HasPrefix(upper, "WITH"):
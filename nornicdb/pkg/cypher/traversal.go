@@ -169,7 +169,8 @@ func (e *StorageExecutor) executeMatchWithRelationships(pattern string, whereCla
 			strings.HasPrefix(upperExprs[i], "AVG(") ||
 			strings.HasPrefix(upperExprs[i], "MIN(") ||
 			strings.HasPrefix(upperExprs[i], "MAX(") ||
-			strings.HasPrefix(upperExprs[i], "COLLECT(")
+			strings.HasPrefix(upperExprs[i], "COLLECT(") ||
+			strings.HasPrefix(upperExprs[i], "APPROXCOUNTDISTINCT(")
 	}
 
 	// Check if this is an aggregation query
@@ -212,6 +213,9 @@ func (e *StorageExecutor) executeMatchWithRelationships(pattern string, whereCla
 					}
 					row[i] = collected
 
+				case strings.HasPrefix(upperExpr, "APPROXCOUNTDISTINCT("):
+					row[i] = e.approxCountDistinctPaths(item.expr, paths, matches)
+
 				default:
 					if len(paths) > 0 {
 						context := e.buildPathContext(paths[0], matches)
@@ -278,6 +282,9 @@ func (e *StorageExecutor) executeMatchWithRelationships(pattern string, whereCla
 					}
 					row[i] = collected
 
+				case strings.HasPrefix(upperExpr, "APPROXCOUNTDISTINCT("):
+					row[i] = e.approxCountDistinctPaths(item.expr, groupPaths, matches)
+
 				default:
 					if len(groupPaths) > 0 {
 						context := e.buildPathContext(groupPaths[0], matches)
@@ -474,6 +481,17 @@ func (e *StorageExecutor) parseNodePatternFromString(s string) nodePatternInfo {
 
 // traverseGraph executes the traversal and returns all matching paths
 func (e *StorageExecutor) traverseGraph(match *TraversalMatch) []PathResult {
+	switch planTraversal(match) {
+	case anchorEnd:
+		return e.traverseGraphReverse(match)
+	case anchorRelType:
+		return e.traverseRelTypeIndex(match)
+	}
+	return e.traverseGraphFrom(match)
+}
+
+// traverseGraphFrom expands from the nodes matching the start pattern
+func (e *StorageExecutor) traverseGraphFrom(match *TraversalMatch) []PathResult {
 	// Get starting nodes
 	var startNodes []*storage.Node
 	if len(match.StartNode.labels) > 0 {
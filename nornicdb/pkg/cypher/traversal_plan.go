@@ -0,0 +1,152 @@
+// Package cypher - traversal planning for single relationship patterns.
+//
+// A pattern like (a)<-[:WROTE]-(b:Person) used to start from every node
+// matching (a) - with no label, every node in the store. Storage keeps
+// adjacency in both directions, so the planner is free to start from
+// whichever side is cheaper and expand against the arrow, or to read the
+// relationship type index when neither side narrows the search.
+
+package cypher
+
+import (
+	"slices"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// traversalAnchor is where the planner starts a traversal.
+type traversalAnchor int
+
+const (
+	// anchorStart expands from the nodes matching the left node pattern.
+	anchorStart traversalAnchor = iota
+	// anchorEnd expands from the nodes matching the right node pattern,
+	// following relationships in the opposite direction.
+	anchorEnd
+	// anchorRelType reads relationships from the type index and checks
+	// both endpoints.
+	anchorRelType
+)
+
+// planTraversal picks the anchor for a traversal.
+//
+// Only single-hop patterns are re-anchored: variable-length paths keep
+// expanding from the left so their cycle handling is unchanged. A node
+// pattern with properties is assumed more selective than one with only
+// labels, and either beats an unconstrained node. Ties keep the left side.
+func planTraversal(match *TraversalMatch) traversalAnchor {
+	rel := match.Relationship
+	if rel.MinHops != 1 || rel.MaxHops != 1 {
+		return anchorStart
+	}
+	start, end := nodePatternSelectivity(match.StartNode), nodePatternSelectivity(match.EndNode)
+	switch {
+	case end > start:
+		return anchorEnd
+	case start == 0 && end == 0 && len(rel.Types) > 0:
+		return anchorRelType
+	default:
+		return anchorStart
+	}
+}
+
+// nodePatternSelectivity ranks how much a node pattern narrows the nodes
+// it matches: 2 for properties, 1 for labels only, 0 for neither.
+func nodePatternSelectivity(p nodePatternInfo) int {
+	switch {
+	case len(p.properties) > 0:
+		return 2
+	case len(p.labels) > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// reverseDirection returns the direction of a relationship pattern seen
+// from its other end.
+func reverseDirection(direction string) string {
+	switch direction {
+	case "outgoing":
+		return "incoming"
+	case "incoming":
+		return "outgoing"
+	default:
+		return direction
+	}
+}
+
+// traverseGraphReverse traverses from the right node pattern against the
+// arrow and returns the paths in pattern order.
+func (e *StorageExecutor) traverseGraphReverse(match *TraversalMatch) []PathResult {
+	reversed := &TraversalMatch{
+		StartNode:    match.EndNode,
+		EndNode:      match.StartNode,
+		Relationship: match.Relationship,
+	}
+	reversed.Relationship.Direction = reverseDirection(match.Relationship.Direction)
+
+	paths := e.traverseGraphFrom(reversed)
+	for i := range paths {
+		slices.Reverse(paths[i].Nodes)
+		slices.Reverse(paths[i].Relationships)
+	}
+	return paths
+}
+
+// traverseRelTypeIndex matches a single-hop pattern by reading its
+// relationship types from the type index instead of expanding from nodes.
+func (e *StorageExecutor) traverseRelTypeIndex(match *TraversalMatch) []PathResult {
+	var results []PathResult
+	nodeCache := make(map[storage.NodeID]*storage.Node)
+	getNode := func(id storage.NodeID) *storage.Node {
+		if n, ok := nodeCache[id]; ok {
+			return n
+		}
+		n, err := e.storage.GetNode(id)
+		if err != nil {
+			n = nil
+		}
+		nodeCache[id] = n
+		return n
+	}
+	emit := func(from, to storage.NodeID, edge *storage.Edge) {
+		a, b := getNode(from), getNode(to)
+		if a == nil || b == nil || !e.matchesEndPattern(a, &match.StartNode) || !e.matchesEndPattern(b, &match.EndNode) {
+			return
+		}
+		results = append(results, PathResult{
+			Nodes:         []*storage.Node{a, b},
+			Relationships: []*storage.Edge{edge},
+			Length:        1,
+		})
+	}
+
+	seen := make(map[string]bool, len(match.Relationship.Types))
+	for _, relType := range match.Relationship.Types {
+		if seen[relType] {
+			continue
+		}
+		seen[relType] = true
+		edges, err := e.storage.GetEdgesByType(relType)
+		if err != nil {
+			continue
+		}
+		for _, edge := range edges {
+			// The type index is case-insensitive; pattern types are not.
+			if edge.Type != relType {
+				continue
+			}
+			switch match.Relationship.Direction {
+			case "outgoing":
+				emit(edge.StartNode, edge.EndNode, edge)
+			case "incoming":
+				emit(edge.EndNode, edge.StartNode, edge)
+			default:
+				emit(edge.StartNode, edge.EndNode, edge)
+				emit(edge.EndNode, edge.StartNode, edge)
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,144 @@
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scanCountingEngine counts full node scans.
+type scanCountingEngine struct {
+	*storage.MemoryEngine
+	scans int
+}
+
+func (s *scanCountingEngine) GetAllNodes() []*storage.Node {
+	s.scans++
+	return s.MemoryEngine.GetAllNodes()
+}
+
+func (s *scanCountingEngine) AllNodes() ([]*storage.Node, error) {
+	s.scans++
+	return s.MemoryEngine.AllNodes()
+}
+
+func TestPlanTraversal(t *testing.T) {
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+	tests := []struct {
+		pattern string
+		want    traversalAnchor
+	}{
+		{"(a:Person)-[:WROTE]->(b)", anchorStart},
+		{"(b)<-[:WROTE]-(a:Person)", anchorEnd},
+		{"(b:Book)<-[:WROTE]-(a:Person {name: 'Ann'})", anchorEnd},
+		{"(a:Person)-[:WROTE]->(b:Book)", anchorStart},
+		{"(b)<-[:WROTE]-(a)", anchorRelType},
+		{"(a)-[:WROTE|EDITED]-(b)", anchorRelType},
+		{"(a)-[r]->(b)", anchorStart},
+		{"(b)<-[:WROTE*1..3]-(a:Person)", anchorStart},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			match := exec.parseTraversalPattern(tt.pattern)
+			require.NotNil(t, match)
+			assert.Equal(t, tt.want, planTraversal(match))
+		})
+	}
+}
+
+func TestReverseTraversal(t *testing.T) {
+	engine := &scanCountingEngine{MemoryEngine: storage.NewMemoryEngine()}
+	exec := NewStorageExecutor(engine)
+	ctx := context.Background()
+
+	for _, q := range []string{
+		`CREATE (:Person {name: 'Ann'}), (:Person {name: 'Bob'}), (:Robot {name: 'R2'})`,
+		`CREATE (:Book {title: 'Go'}), (:Book {title: 'Graphs'}), (:Note {title: 'todo'})`,
+		`MATCH (a:Person {name: 'Ann'}), (b:Book {title: 'Go'}) CREATE (a)-[:WROTE]->(b)`,
+		`MATCH (a:Person {name: 'Ann'}), (b:Book {title: 'Graphs'}) CREATE (a)-[:WROTE]->(b)`,
+		`MATCH (a:Person {name: 'Bob'}), (b:Book {title: 'Graphs'}) CREATE (a)-[:WROTE]->(b)`,
+		`MATCH (a:Robot), (b:Note) CREATE (a)-[:WROTE]->(b)`,
+		`MATCH (a:Person {name: 'Ann'}), (b:Person {name: 'Bob'}) CREATE (a)-[:KNOWS]->(b)`,
+	} {
+		_, err := exec.Execute(ctx, q, nil)
+		require.NoError(t, err, q)
+	}
+
+	rows := func(query string) []string {
+		t.Helper()
+		result, err := exec.Execute(ctx, query, nil)
+		require.NoError(t, err, query)
+		var out []string
+		for _, row := range result.Rows {
+			out = append(out, fmt.Sprint(row...))
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "anchored at the labeled end",
+			query: `MATCH (b)<-[:WROTE]-(a:Person) RETURN b.title, a.name`,
+			want:  []string{"GoAnn", "GraphsAnn", "GraphsBob"},
+		},
+		{
+			name:  "anchored at the end with properties",
+			query: `MATCH (b:Book)<-[:WROTE]-(a:Person {name: 'Ann'}) RETURN b.title, a.name`,
+			want:  []string{"GoAnn", "GraphsAnn"},
+		},
+		{
+			name:  "outgoing anchored at the end",
+			query: `MATCH (a)-[:WROTE]->(b:Book {title: 'Graphs'}) RETURN a.name, b.title`,
+			want:  []string{"AnnGraphs", "BobGraphs"},
+		},
+		{
+			name:  "relationship type scan",
+			query: `MATCH (b)<-[:WROTE]-(a) RETURN b.title, a.name`,
+			want:  []string{"GoAnn", "GraphsAnn", "GraphsBob", "todoR2"},
+		},
+		{
+			name:  "undirected relationship type scan",
+			query: `MATCH (a)-[:KNOWS]-(b) RETURN a.name, b.name`,
+			want:  []string{"AnnBob", "BobAnn"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine.scans = 0
+			assert.Equal(t, tt.want, rows(tt.query))
+			assert.Zero(t, engine.scans, "no full node scan")
+		})
+	}
+
+	t.Run("count", func(t *testing.T) {
+		assert.Equal(t, []string{"3"}, rows(`MATCH (b)<-[:WROTE]-(a:Person) RETURN count(b)`))
+	})
+}
+
+func TestExplainReverseTraversal(t *testing.T) {
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+
+	op := exec.analyzeMatchClause(`MATCH (b)<-[:WROTE]-(a:Person) RETURN b`)
+	assert.Equal(t, "Expand(All)", op.OperatorType)
+	assert.Equal(t, "a", op.Arguments["from"])
+	assert.Equal(t, "outgoing", op.Arguments["direction"])
+	require.Len(t, op.Children, 1)
+	assert.Equal(t, "NodeByLabelScan", op.Children[0].OperatorType)
+
+	op = exec.analyzeMatchClause(`MATCH (b)<-[:WROTE]-(a) RETURN b`)
+	assert.Equal(t, "DirectedRelationshipTypeScan", op.OperatorType)
+
+	op = exec.analyzeMatchClause(`MATCH (a:Person)-[:WROTE]->(b) RETURN b`)
+	assert.Equal(t, "Expand(All)", op.OperatorType)
+	assert.Equal(t, "a", op.Arguments["from"])
+}
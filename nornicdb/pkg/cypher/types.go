@@ -1,12 +1,43 @@
 // Package cypher provides Cypher query execution for NornicDB.
 package cypher
 
+import "github.com/orneryd/nornicdb/pkg/pool"
+
 // ExecuteResult holds execution results in Neo4j-compatible format.
+//
+// Row lifetime: some handlers build Rows from pkg/pool. Such a result is
+// owned by the caller of Execute, which may call Release once it has read
+// the last row. Results the query cache keeps are shared with every cache
+// hit, so caching clears the pooled flag and Release becomes a no-op.
 type ExecuteResult struct {
 	Columns  []string
 	Rows     [][]interface{}
 	Stats    *QueryStats
 	Metadata map[string]interface{} // Additional result metadata (e.g., execution plan)
+
+	pooled bool // Rows and each row came from pkg/pool
+}
+
+// Release returns pooled rows to pkg/pool and clears Rows. Neither the rows
+// nor any row slice may be used afterwards; values inside rows are not
+// pooled and stay valid. Safe to call on any result, and more than once.
+func (r *ExecuteResult) Release() {
+	if r == nil || !r.pooled {
+		return
+	}
+	r.pooled = false
+	for _, row := range r.Rows {
+		pool.PutInterfaceSlice(row)
+	}
+	pool.PutRowSlice(r.Rows)
+	r.Rows = nil
+}
+
+// retain marks a result as shared, so Release leaves its rows alone.
+func (r *ExecuteResult) retain() {
+	if r != nil {
+		r.pooled = false
+	}
 }
 
 // QueryStats holds query execution statistics.
@@ -17,6 +48,7 @@ type QueryStats struct {
 	RelationshipsDeleted int `json:"relationships_deleted"`
 	PropertiesSet        int `json:"properties_set"`
 	LabelsAdded          int `json:"labels_added"`
+	Retries              int `json:"retries"` // Write conflict retries before commit
 }
 
 // nodePatternInfo holds parsed node pattern information
@@ -0,0 +1,92 @@
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteResult_Release(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	exec := NewStorageExecutor(store)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := exec.Execute(ctx, "CREATE (:Tag {name: $name})", map[string]interface{}{"name": fmt.Sprintf("t%d", i)})
+		require.NoError(t, err)
+	}
+
+	stmt, err := exec.Prepare("MATCH (t:Tag) RETURN t.name ORDER BY t.name")
+	require.NoError(t, err)
+	result, err := exec.ExecutePrepared(ctx, stmt, nil)
+	require.NoError(t, err)
+	require.True(t, result.pooled, "MATCH ... RETURN builds rows from the pool")
+	assert.Equal(t, [][]interface{}{{"t0"}, {"t1"}, {"t2"}}, result.Rows)
+
+	result.Release()
+	assert.Nil(t, result.Rows)
+	result.Release() // Idempotent
+	var nilResult *ExecuteResult
+	nilResult.Release()
+
+	// Cached results are shared with every hit and must survive Release
+	cached, err := exec.Execute(ctx, "MATCH (t:Tag) RETURN t.name ORDER BY t.name", nil)
+	require.NoError(t, err)
+	assert.False(t, cached.pooled)
+	cached.Release()
+	require.Len(t, cached.Rows, 3)
+	hit, err := exec.Execute(ctx, "MATCH (t:Tag) RETURN t.name ORDER BY t.name", nil)
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{"t0"}, {"t1"}, {"t2"}}, hit.Rows)
+}
+
+// TestExecuteResult_ReleaseConcurrent recycles pooled rows across goroutines.
+// A row used after being returned would be overwritten by another query,
+// which the value checks catch and `go test -race` reports.
+func TestExecuteResult_ReleaseConcurrent(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	exec := NewStorageExecutor(store)
+	ctx := context.Background()
+	for i := 0; i < 50; i++ {
+		_, err := exec.Execute(ctx, "CREATE (:Item {n: $n})", map[string]interface{}{"n": int64(i)})
+		require.NoError(t, err)
+	}
+	stmt, err := exec.Prepare("MATCH (i:Item) WHERE i.n >= $min RETURN i.n, $min")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(min int64) {
+			defer wg.Done()
+			for iter := 0; iter < 50; iter++ {
+				result, err := exec.ExecutePrepared(ctx, stmt, map[string]interface{}{"min": min})
+				if err != nil {
+					errs <- err
+					return
+				}
+				if len(result.Rows) != int(50-min) {
+					errs <- fmt.Errorf("min %d: got %d rows", min, len(result.Rows))
+					return
+				}
+				for _, row := range result.Rows {
+					if row[1] != min || row[0].(int64) < min {
+						errs <- fmt.Errorf("min %d: foreign row %v", min, row)
+						return
+					}
+				}
+				result.Release()
+			}
+		}(int64(w * 5))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
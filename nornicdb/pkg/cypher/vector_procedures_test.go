@@ -2,6 +2,7 @@ package cypher
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/orneryd/nornicdb/pkg/storage"
@@ -356,6 +357,91 @@ func TestVectorSearchEndToEnd(t *testing.T) {
 	}
 }
 
+// fakeVectorAccelerator ranks candidates by ID order, so tests can tell its
+// results from CPU scoring.
+type fakeVectorAccelerator struct {
+	calls int
+	ids   []string
+	err   error
+}
+
+func (f *fakeVectorAccelerator) SearchVectors(ctx context.Context, index string, ids []string, vectors [][]float32, query []float32, k int) ([]int, []float64, error) {
+	f.calls++
+	f.ids = ids
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	positions := make([]int, k)
+	scores := make([]float64, k)
+	for i := range positions {
+		positions[i] = i
+		scores[i] = 1 - float64(i)/10
+	}
+	return positions, scores, nil
+}
+
+func TestVectorQueryNodesAccelerator(t *testing.T) {
+	engine := storage.NewMemoryEngine()
+	exec := NewStorageExecutor(engine)
+	ctx := context.Background()
+
+	for _, n := range []struct {
+		id  storage.NodeID
+		vec []float32
+	}{
+		{"a", []float32{0, 1}},
+		{"b", []float32{1, 0}},
+		{"c", []float32{0.6, 0.8}},
+	} {
+		require.NoError(t, engine.CreateNode(&storage.Node{
+			ID:         n.id,
+			Labels:     []string{"Doc"},
+			Properties: map[string]interface{}{"id": string(n.id)},
+			Embedding:  n.vec,
+		}))
+	}
+	_, err := exec.Execute(ctx, "CALL db.index.vector.createNodeIndex('doc_cos', 'Doc', 'vec', 2, 'cosine')", nil)
+	require.NoError(t, err)
+	_, err = exec.Execute(ctx, "CALL db.index.vector.createNodeIndex('doc_dot', 'Doc', 'vec', 2, 'dot')", nil)
+	require.NoError(t, err)
+
+	accel := &fakeVectorAccelerator{}
+	exec.SetVectorSearchAccelerator(accel)
+	topID := func(result *ExecuteResult) interface{} {
+		return result.Rows[0][0].(map[string]interface{})["id"]
+	}
+
+	t.Run("cosine_ranked_on_accelerator", func(t *testing.T) {
+		result, err := exec.Execute(ctx, "CALL db.index.vector.queryNodes('doc_cos', 2, [1.0, 0.0]) YIELD node, score", nil)
+		require.NoError(t, err)
+		require.Equal(t, 1, accel.calls)
+		require.Len(t, result.Rows, 2)
+		assert.Len(t, accel.ids, 3)
+		// The fake ranks the first candidate best, which CPU scoring would not
+		assert.Equal(t, accel.ids[0], topID(result))
+		assert.Equal(t, 1.0, result.Rows[0][1])
+	})
+
+	t.Run("falls_back_to_cpu_on_error", func(t *testing.T) {
+		accel.err = errors.New("device lost")
+		defer func() { accel.err = nil }()
+		// A different vector, so the cached result of the last query is not reused
+		result, err := exec.Execute(ctx, "CALL db.index.vector.queryNodes('doc_cos', 2, [0.9, 0.0]) YIELD node, score", nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 2)
+		assert.Equal(t, "b", topID(result))
+	})
+
+	t.Run("dot_index_scored_on_cpu", func(t *testing.T) {
+		calls := accel.calls
+		result, err := exec.Execute(ctx, "CALL db.index.vector.queryNodes('doc_dot', 1, [1.0, 0.0]) YIELD node, score", nil)
+		require.NoError(t, err)
+		assert.Equal(t, calls, accel.calls)
+		require.Len(t, result.Rows, 1)
+		assert.Equal(t, "b", topID(result))
+	})
+}
+
 // TestMultiLineSetWithArray tests that SET clauses with arrays and multiple properties work
 func TestMultiLineSetWithArray(t *testing.T) {
 	engine := storage.NewMemoryEngine()
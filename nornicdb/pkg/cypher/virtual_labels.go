@@ -0,0 +1,150 @@
+// Package cypher - virtual labels resolved from external data sources.
+//
+// With a VirtualLabelResolver set (see federation.Registry), a MATCH on a
+// virtual label reads its nodes from the label's external source instead of
+// storage. Inline properties and WHERE equality comparisons with literals
+// on the pattern's variable are passed to the source as filters:
+//
+//	MATCH (k:Country {region: 'Europe'}) WHERE k.code = 'NO' RETURN k.name
+//	// fetches the rows with region = 'Europe' AND code = 'NO'
+//
+// Filters are only pushed down for WHERE clauses that are a plain AND of
+// comparisons; the full WHERE is evaluated on the returned nodes either way.
+// Results of queries on virtual labels bypass the query result cache, since
+// the external data can change without a write to the graph.
+package cypher
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// VirtualLabelResolver resolves virtual labels to nodes held by external
+// data sources.
+// This is a minimal interface to avoid import cycles with federation package.
+type VirtualLabelResolver interface {
+	// IsVirtualLabel reports whether label is resolved externally.
+	IsVirtualLabel(label string) bool
+	// ResolveLabel returns the nodes of a virtual label whose properties
+	// equal filters, or ok = false if label is not virtual.
+	ResolveLabel(ctx context.Context, label string, filters map[string]interface{}) (nodes []*storage.Node, ok bool, err error)
+}
+
+// SetVirtualLabels makes MATCH resolve the resolver's virtual labels from
+// their external sources.
+//
+// Example:
+//
+//	registry := federation.NewRegistry()
+//	registry.LoadFile("federation.json")
+//	executor.SetVirtualLabels(registry)
+//
+//	// MATCH (c:Customer) MATCH (k:Country) WHERE k.code = c.country RETURN c.name, k.name
+func (e *StorageExecutor) SetVirtualLabels(resolver VirtualLabelResolver) {
+	e.virtualLabels = resolver
+}
+
+// usesVirtualLabels reports whether any of labels is virtual.
+func (e *StorageExecutor) usesVirtualLabels(labels []string) bool {
+	if e.virtualLabels == nil {
+		return false
+	}
+	for _, label := range labels {
+		if e.virtualLabels.IsVirtualLabel(label) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodesByLabel returns the nodes carrying label, resolving virtual labels
+// with the pattern's properties and the simple predicates of where on
+// variable pushed down to the source.
+func (e *StorageExecutor) nodesByLabel(ctx context.Context, label string, props map[string]interface{}, variable, where string) ([]*storage.Node, error) {
+	if e.virtualLabels != nil {
+		nodes, ok, err := e.virtualLabels.ResolveLabel(ctx, label, pushdownFilters(props, variable, where))
+		if ok {
+			return nodes, err
+		}
+	}
+	return e.storage.GetNodesByLabel(label)
+}
+
+var (
+	// pushdownComparison matches `variable.property = literal` with a
+	// string, number or boolean literal.
+	pushdownComparison = regexp.MustCompile(`^(\w+)\.(\w+)\s*=\s*('[^']*'|"[^"]*"|-?\d+(?:\.\d+)?|(?i:true|false))$`)
+	// pushdownBlockers make a WHERE clause more than a plain conjunction.
+	pushdownBlockers = regexp.MustCompile(`(?i)\b(OR|XOR)\b|[()]`)
+	pushdownAnd      = regexp.MustCompile(`(?i)\s+AND\s+`)
+)
+
+// pushdownFilters returns the scalar inline properties of a pattern plus
+// the equality comparisons of variable's properties with literals in where,
+// if where is a plain AND of comparisons.
+func pushdownFilters(props map[string]interface{}, variable, where string) map[string]interface{} {
+	filters := make(map[string]interface{}, len(props))
+	for k, v := range props {
+		switch v.(type) {
+		case string, int64, float64, bool:
+			filters[k] = v
+		}
+	}
+
+	where = strings.TrimSpace(where)
+	if where == "" || variable == "" {
+		return filters
+	}
+	// Blank string literals so keywords inside them are not mistaken for
+	// operators; the blanked text keeps the original's byte offsets.
+	blanked := []byte(where)
+	var quote byte
+	for i := 0; i < len(blanked); i++ {
+		switch c := blanked[i]; {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			blanked[i] = ' '
+		case c == '\'' || c == '"':
+			quote = c
+		}
+	}
+	if quote != 0 || pushdownBlockers.Match(blanked) {
+		return filters
+	}
+
+	start := 0
+	for _, sep := range append(pushdownAnd.FindAllIndex(blanked, -1), []int{len(where), len(where)}) {
+		m := pushdownComparison.FindStringSubmatch(strings.TrimSpace(where[start:sep[0]]))
+		start = sep[1]
+		if m == nil || m[1] != variable {
+			continue
+		}
+		if _, conflict := filters[m[2]]; conflict {
+			continue
+		}
+		filters[m[2]] = literalValue(m[3])
+	}
+	return filters
+}
+
+// literalValue parses a literal matched by pushdownComparison.
+func literalValue(lit string) interface{} {
+	switch {
+	case lit[0] == '\'' || lit[0] == '"':
+		return lit[1 : len(lit)-1]
+	case strings.EqualFold(lit, "true"):
+		return true
+	case strings.EqualFold(lit, "false"):
+		return false
+	case strings.Contains(lit, "."):
+		f, _ := strconv.ParseFloat(lit, 64)
+		return f
+	}
+	i, _ := strconv.ParseInt(lit, 10, 64)
+	return i
+}
@@ -0,0 +1,189 @@
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVirtualLabels resolves the label Country from a fixed table, recording
+// the filters of each call.
+type fakeVirtualLabels struct {
+	mu      sync.Mutex
+	rows    []map[string]interface{}
+	filters []map[string]interface{}
+}
+
+func (f *fakeVirtualLabels) IsVirtualLabel(label string) bool {
+	return strings.EqualFold(label, "Country")
+}
+
+func (f *fakeVirtualLabels) ResolveLabel(ctx context.Context, label string, filters map[string]interface{}) ([]*storage.Node, bool, error) {
+	if !f.IsVirtualLabel(label) {
+		return nil, false, nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.filters = append(f.filters, filters)
+	var nodes []*storage.Node
+	for _, row := range f.rows {
+		match := true
+		for k, v := range filters {
+			if row[k] != v {
+				match = false
+			}
+		}
+		if match {
+			props := make(map[string]interface{}, len(row))
+			for k, v := range row {
+				props[k] = v
+			}
+			nodes = append(nodes, &storage.Node{
+				ID:         storage.NodeID(fmt.Sprintf("Country:%v", row["code"])),
+				Labels:     []string{"Country"},
+				Properties: props,
+			})
+		}
+	}
+	return nodes, true, nil
+}
+
+func (f *fakeVirtualLabels) lastFilters() map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.filters) == 0 {
+		return nil
+	}
+	return f.filters[len(f.filters)-1]
+}
+
+func newVirtualLabelExecutor(t *testing.T) (*StorageExecutor, *fakeVirtualLabels) {
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+	resolver := &fakeVirtualLabels{rows: []map[string]interface{}{
+		{"code": "NO", "name": "Norway", "region": "Europe"},
+		{"code": "SE", "name": "Sweden", "region": "Europe"},
+		{"code": "JP", "name": "Japan", "region": "Asia"},
+	}}
+	exec.SetVirtualLabels(resolver)
+	return exec, resolver
+}
+
+func TestVirtualLabels_Match(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("inline properties are pushed down", func(t *testing.T) {
+		exec, resolver := newVirtualLabelExecutor(t)
+		result, err := exec.Execute(ctx, `MATCH (k:Country {code: 'NO'}) RETURN k.name`, nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 1)
+		assert.Equal(t, "Norway", result.Rows[0][0])
+		assert.Equal(t, map[string]interface{}{"code": "NO"}, resolver.lastFilters())
+	})
+
+	t.Run("WHERE equalities are pushed down", func(t *testing.T) {
+		exec, resolver := newVirtualLabelExecutor(t)
+		result, err := exec.Execute(ctx, `MATCH (k:Country) WHERE k.region = 'Europe' RETURN k.name ORDER BY k.name`, nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 2)
+		assert.Equal(t, "Norway", result.Rows[0][0])
+		assert.Equal(t, "Sweden", result.Rows[1][0])
+		assert.Equal(t, map[string]interface{}{"region": "Europe"}, resolver.lastFilters())
+	})
+
+	t.Run("other predicates are still applied", func(t *testing.T) {
+		exec, resolver := newVirtualLabelExecutor(t)
+		result, err := exec.Execute(ctx, `MATCH (k:Country) WHERE k.code = 'NO' OR k.code = 'JP' RETURN k.name ORDER BY k.name`, nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 2)
+		assert.Equal(t, "Japan", result.Rows[0][0])
+		assert.Equal(t, "Norway", result.Rows[1][0])
+		assert.Empty(t, resolver.lastFilters())
+	})
+
+	t.Run("native labels are unaffected", func(t *testing.T) {
+		exec, resolver := newVirtualLabelExecutor(t)
+		_, err := exec.Execute(ctx, `CREATE (:Customer {name: 'Ada', country: 'NO'})`, nil)
+		require.NoError(t, err)
+		result, err := exec.Execute(ctx, `MATCH (c:Customer) RETURN c.name`, nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 1)
+		assert.Equal(t, "Ada", result.Rows[0][0])
+		assert.Nil(t, resolver.lastFilters())
+	})
+}
+
+func TestVirtualLabels_JoinWithNativeNodes(t *testing.T) {
+	ctx := context.Background()
+	exec, _ := newVirtualLabelExecutor(t)
+	for _, q := range []string{
+		`CREATE (:Customer {name: 'Ada', country: 'NO'})`,
+		`CREATE (:Customer {name: 'Bo', country: 'JP'})`,
+	} {
+		_, err := exec.Execute(ctx, q, nil)
+		require.NoError(t, err)
+	}
+
+	result, err := exec.Execute(ctx, `
+		MATCH (c:Customer)
+		MATCH (k:Country)
+		WHERE k.code = c.country
+		RETURN c.name, k.name
+		ORDER BY c.name`, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 2)
+	assert.Equal(t, []interface{}{"Ada", "Norway"}, result.Rows[0])
+	assert.Equal(t, []interface{}{"Bo", "Japan"}, result.Rows[1])
+}
+
+func TestVirtualLabels_BypassQueryCache(t *testing.T) {
+	ctx := context.Background()
+	exec, resolver := newVirtualLabelExecutor(t)
+
+	query := `MATCH (k:Country {code: 'NO'}) RETURN k.name`
+	_, err := exec.Execute(ctx, query, nil)
+	require.NoError(t, err)
+
+	resolver.mu.Lock()
+	resolver.rows[0]["name"] = "Noreg"
+	calls := len(resolver.filters)
+	resolver.mu.Unlock()
+
+	result, err := exec.Execute(ctx, query, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, "Noreg", result.Rows[0][0])
+	assert.Greater(t, len(resolver.filters), calls)
+}
+
+func TestPushdownFilters(t *testing.T) {
+	tests := []struct {
+		name  string
+		props map[string]interface{}
+		where string
+		want  map[string]interface{}
+	}{
+		{"no where", map[string]interface{}{"code": "NO"}, "", map[string]interface{}{"code": "NO"}},
+		{"non-scalar props skipped", map[string]interface{}{"tags": []interface{}{"a"}}, "", map[string]interface{}{}},
+		{"literals", nil, `k.name = "x" AND k.pop = 5 AND k.area = 1.5 AND k.eu = false`,
+			map[string]interface{}{"name": "x", "pop": int64(5), "area": 1.5, "eu": false}},
+		{"other variable ignored", nil, `c.code = 'NO' AND k.code = 'SE'`, map[string]interface{}{"code": "SE"}},
+		{"non-equality ignored", nil, `k.pop > 5 AND k.code = 'SE'`, map[string]interface{}{"code": "SE"}},
+		{"property comparison ignored", nil, `k.code = c.country`, map[string]interface{}{}},
+		{"OR blocks pushdown", nil, `k.code = 'NO' OR k.code = 'SE'`, map[string]interface{}{}},
+		{"parentheses block pushdown", nil, `(k.code = 'NO')`, map[string]interface{}{}},
+		{"keywords in strings", nil, `k.name = 'Trinidad and Tobago' AND k.motto = 'Or (not)'`,
+			map[string]interface{}{"name": "Trinidad and Tobago", "motto": "Or (not)"}},
+		{"inline props win", map[string]interface{}{"code": "NO"}, `k.code = 'SE'`, map[string]interface{}{"code": "NO"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, pushdownFilters(tt.props, "k", tt.where))
+		})
+	}
+}
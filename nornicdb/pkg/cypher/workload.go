@@ -0,0 +1,675 @@
+// Package cypher - query workload statistics.
+//
+// The executor keeps rolling aggregates of the top-level queries it runs:
+// arrival rates and result sizes per minute, peak concurrency, and a table of
+// query shapes (the query text with literals replaced by ?) with their
+// counts, latencies and row counts. Aggregates cover a sliding window (an
+// hour by default); shapes not seen within it are dropped.
+//
+//	CALL nornicdb.workload()               // summary and top shapes
+//	CALL nornicdb.workload.recommend()     // cache, pool and index suggestions
+//	CALL db.stats.retrieve('QUERIES')      // summary with every shape
+//	CALL db.stats.collect('QUERIES')       // start collecting
+//	CALL db.stats.stop('QUERIES')          // stop collecting
+//	CALL db.stats.clear('QUERIES')         // reset
+//
+// Arrival counts include every query. Shape details are sampled at the
+// recorder's sample rate, and per-shape counts are estimates scaled by it.
+//
+// Recommendations compare the observed workload with the current query cache
+// size, pool.MaxSize and schema, so capacity changes are grounded in what the
+// database actually serves. They are suggestions; nothing is changed.
+package cypher
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/pool"
+)
+
+const (
+	// DefaultWorkloadWindow is how far back workload aggregates reach.
+	DefaultWorkloadWindow = time.Hour
+	// DefaultWorkloadMaxShapes caps the number of distinct shapes tracked.
+	DefaultWorkloadMaxShapes = 1000
+
+	workloadMaxShapeLen = 1000
+	workloadRowBuckets  = 25
+
+	// Minimum observations before a recommendation is made
+	workloadMinCacheLookups = 100
+	workloadMinPoolQueries  = 100
+	workloadMinIndexQueries = 10
+	workloadMaxCacheSize    = 100000
+)
+
+// WorkloadRecorder keeps rolling aggregates of executed queries.
+// A nil recorder records nothing. Safe for concurrent use.
+type WorkloadRecorder struct {
+	mu         sync.Mutex
+	collecting bool
+	sampleRate float64
+	window     time.Duration
+	maxShapes  int
+	since      time.Time
+	minutes    []workloadMinute
+	shapes     map[string]*workloadShapeStats
+	now        func() time.Time
+}
+
+// workloadMinute aggregates the queries that finished in one minute.
+type workloadMinute struct {
+	minute        int64 // Unix minute; zero when unused
+	queries       int64
+	reads         int64
+	errors        int64
+	maxRows       int64
+	maxConcurrent int
+	rows          [workloadRowBuckets]int64 // Result sizes by bits.Len
+}
+
+// workloadShapeStats aggregates the sampled executions of one shape.
+type workloadShapeStats struct {
+	shape     string
+	example   string // Latest query text, used for index analysis
+	readOnly  bool
+	samples   int64
+	errors    int64
+	totalTime time.Duration
+	maxTime   time.Duration
+	totalRows int64
+	maxRows   int64
+	lastSeen  time.Time
+}
+
+// WorkloadShape is the aggregate for one query shape.
+type WorkloadShape struct {
+	Shape    string    `json:"shape"`
+	ReadOnly bool      `json:"read_only"`
+	Count    int64     `json:"count"`   // Estimated executions (samples / sample rate)
+	Samples  int64     `json:"samples"` // Executions actually measured
+	Errors   int64     `json:"errors"`
+	AvgMs    float64   `json:"avg_ms"`
+	MaxMs    float64   `json:"max_ms"`
+	TotalMs  float64   `json:"total_ms"` // Estimated, like Count
+	AvgRows  float64   `json:"avg_rows"`
+	MaxRows  int64     `json:"max_rows"`
+	LastSeen time.Time `json:"last_seen"`
+
+	example string
+}
+
+// WorkloadSnapshot summarizes the workload within the recorder's window.
+type WorkloadSnapshot struct {
+	Collecting           bool            `json:"collecting"`
+	Since                time.Time       `json:"since"`
+	Window               time.Duration   `json:"window"`
+	SampleRate           float64         `json:"sample_rate"`
+	Queries              int64           `json:"queries"`
+	Reads                int64           `json:"reads"`
+	Writes               int64           `json:"writes"`
+	Errors               int64           `json:"errors"`
+	QueriesPerSecond     float64         `json:"queries_per_second"`
+	PeakQueriesPerMinute int64           `json:"peak_queries_per_minute"`
+	PeakConcurrency      int             `json:"peak_concurrency"`
+	RowsP50              int64           `json:"rows_p50"`
+	RowsP95              int64           `json:"rows_p95"`
+	RowsMax              int64           `json:"rows_max"`
+	Shapes               []WorkloadShape `json:"shapes"` // Most frequent first
+}
+
+// WorkloadRecommendation is a suggested configuration or schema change.
+type WorkloadRecommendation struct {
+	Area      string      `json:"area"`    // "query_cache", "pool" or "index"
+	Setting   string      `json:"setting"` // Setting name, or Label(property) for indexes
+	Current   interface{} `json:"current"`
+	Suggested interface{} `json:"suggested"`
+	Reason    string      `json:"reason"`
+	Queries   int64       `json:"queries"` // Observations behind the suggestion
+}
+
+// NewWorkloadRecorder creates a collecting recorder. A window below one
+// minute, a sample rate outside (0, 1] or maxShapes below one select the
+// defaults.
+func NewWorkloadRecorder(window time.Duration, sampleRate float64, maxShapes int) *WorkloadRecorder {
+	if window < time.Minute {
+		window = DefaultWorkloadWindow
+	}
+	if sampleRate <= 0 || sampleRate > 1 {
+		sampleRate = 1
+	}
+	if maxShapes < 1 {
+		maxShapes = DefaultWorkloadMaxShapes
+	}
+	w := &WorkloadRecorder{
+		collecting: true,
+		sampleRate: sampleRate,
+		window:     window,
+		maxShapes:  maxShapes,
+		minutes:    make([]workloadMinute, int(window/time.Minute)),
+		shapes:     make(map[string]*workloadShapeStats),
+		now:        time.Now,
+	}
+	w.since = w.now()
+	return w
+}
+
+// Collecting reports whether queries are being recorded.
+func (w *WorkloadRecorder) Collecting() bool {
+	if w == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.collecting
+}
+
+// SetCollecting starts or stops recording. Aggregates are kept.
+func (w *WorkloadRecorder) SetCollecting(on bool) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.collecting = on
+}
+
+// Clear discards all aggregates.
+func (w *WorkloadRecorder) Clear() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.minutes = make([]workloadMinute, len(w.minutes))
+	w.shapes = make(map[string]*workloadShapeStats)
+	w.since = w.now()
+}
+
+// Record adds one finished query. concurrent is the number of top-level
+// queries that were running when it started, itself included.
+func (w *WorkloadRecorder) Record(query string, readOnly bool, elapsed time.Duration, rows int64, concurrent int, failed bool) {
+	if w == nil {
+		return
+	}
+	sampled := w.sampleRate >= 1 || rand.Float64() < w.sampleRate
+	var shape string
+	if sampled {
+		shape = workloadShape(query)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.collecting {
+		return
+	}
+	now := w.now()
+
+	m := w.minute(now)
+	m.queries++
+	if readOnly {
+		m.reads++
+	}
+	if failed {
+		m.errors++
+	}
+	if rows > m.maxRows {
+		m.maxRows = rows
+	}
+	if concurrent > m.maxConcurrent {
+		m.maxConcurrent = concurrent
+	}
+	m.rows[rowBucket(rows)]++
+
+	if !sampled {
+		return
+	}
+	s, ok := w.shapes[shape]
+	if !ok {
+		if len(w.shapes) >= w.maxShapes {
+			w.evictOldestShape()
+		}
+		s = &workloadShapeStats{shape: shape}
+		w.shapes[shape] = s
+	}
+	s.example = query
+	s.readOnly = readOnly
+	s.samples++
+	if failed {
+		s.errors++
+	}
+	s.totalTime += elapsed
+	if elapsed > s.maxTime {
+		s.maxTime = elapsed
+	}
+	s.totalRows += rows
+	if rows > s.maxRows {
+		s.maxRows = rows
+	}
+	s.lastSeen = now
+}
+
+// minute returns the bucket for now, resetting it if it held an older minute.
+func (w *WorkloadRecorder) minute(now time.Time) *workloadMinute {
+	unixMinute := now.Unix() / 60
+	m := &w.minutes[unixMinute%int64(len(w.minutes))]
+	if m.minute != unixMinute {
+		*m = workloadMinute{minute: unixMinute}
+	}
+	return m
+}
+
+// evictOldestShape drops the least recently seen shape.
+func (w *WorkloadRecorder) evictOldestShape() {
+	var oldest *workloadShapeStats
+	for _, s := range w.shapes {
+		if oldest == nil || s.lastSeen.Before(oldest.lastSeen) {
+			oldest = s
+		}
+	}
+	if oldest != nil {
+		delete(w.shapes, oldest.shape)
+	}
+}
+
+// Snapshot returns the aggregates within the window.
+func (w *WorkloadRecorder) Snapshot() WorkloadSnapshot {
+	if w == nil {
+		return WorkloadSnapshot{Shapes: []WorkloadShape{}}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	snap := WorkloadSnapshot{
+		Collecting: w.collecting,
+		Since:      w.since,
+		Window:     w.window,
+		SampleRate: w.sampleRate,
+		Shapes:     []WorkloadShape{},
+	}
+
+	oldest := now.Unix()/60 - int64(len(w.minutes)) + 1
+	var rows [workloadRowBuckets]int64
+	for i := range w.minutes {
+		m := &w.minutes[i]
+		if m.minute < oldest {
+			continue
+		}
+		snap.Queries += m.queries
+		snap.Reads += m.reads
+		snap.Errors += m.errors
+		if m.queries > snap.PeakQueriesPerMinute {
+			snap.PeakQueriesPerMinute = m.queries
+		}
+		if m.maxConcurrent > snap.PeakConcurrency {
+			snap.PeakConcurrency = m.maxConcurrent
+		}
+		if m.maxRows > snap.RowsMax {
+			snap.RowsMax = m.maxRows
+		}
+		for b, n := range m.rows {
+			rows[b] += n
+		}
+	}
+	snap.Writes = snap.Queries - snap.Reads
+	snap.RowsP50 = rowPercentile(rows, snap.Queries, 0.50, snap.RowsMax)
+	snap.RowsP95 = rowPercentile(rows, snap.Queries, 0.95, snap.RowsMax)
+
+	observed := now.Sub(w.since)
+	if observed > w.window {
+		observed = w.window
+	}
+	if observed > 0 {
+		snap.QueriesPerSecond = float64(snap.Queries) / observed.Seconds()
+	}
+
+	cutoff := now.Add(-w.window)
+	for _, s := range w.shapes {
+		if !s.lastSeen.After(cutoff) {
+			delete(w.shapes, s.shape)
+			continue
+		}
+		snap.Shapes = append(snap.Shapes, WorkloadShape{
+			Shape:    s.shape,
+			ReadOnly: s.readOnly,
+			Count:    int64(float64(s.samples)/w.sampleRate + 0.5),
+			Samples:  s.samples,
+			Errors:   s.errors,
+			AvgMs:    durationMs(s.totalTime) / float64(s.samples),
+			MaxMs:    durationMs(s.maxTime),
+			TotalMs:  durationMs(s.totalTime) / w.sampleRate,
+			AvgRows:  float64(s.totalRows) / float64(s.samples),
+			MaxRows:  s.maxRows,
+			LastSeen: s.lastSeen,
+			example:  s.example,
+		})
+	}
+	sort.Slice(snap.Shapes, func(i, j int) bool {
+		if snap.Shapes[i].Count != snap.Shapes[j].Count {
+			return snap.Shapes[i].Count > snap.Shapes[j].Count
+		}
+		return snap.Shapes[i].Shape < snap.Shapes[j].Shape
+	})
+	return snap
+}
+
+// rowBucket returns the histogram bucket for a result size.
+func rowBucket(rows int64) int {
+	if rows < 0 {
+		rows = 0
+	}
+	b := bits.Len64(uint64(rows))
+	if b >= workloadRowBuckets {
+		b = workloadRowBuckets - 1
+	}
+	return b
+}
+
+// rowPercentile returns the upper bound of the bucket holding the p-th
+// percentile result size, capped at max.
+func rowPercentile(hist [workloadRowBuckets]int64, total int64, p float64, max int64) int64 {
+	if total == 0 {
+		return 0
+	}
+	rank := int64(float64(total)*p + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for b, n := range hist {
+		seen += n
+		if seen >= rank {
+			bound := int64(1)<<uint(b) - 1
+			if bound > max {
+				bound = max
+			}
+			return bound
+		}
+	}
+	return max
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// workloadShape returns query with string and number literals replaced by ?
+// and whitespace collapsed, so executions differing only in literal values
+// share a shape. Identifiers, including backquoted ones and $parameters, are
+// kept.
+func workloadShape(query string) string {
+	var sb strings.Builder
+	sb.Grow(len(query))
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			j := i + 1
+			for j < len(query) && query[j] != c {
+				if query[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			sb.WriteByte('?')
+			i = j
+		case c == '`':
+			j := strings.IndexByte(query[i+1:], '`')
+			if j < 0 {
+				sb.WriteString(query[i:])
+				i = len(query)
+				continue
+			}
+			sb.WriteString(query[i : i+j+2])
+			i += j + 1
+		case c >= '0' && c <= '9' && (i == 0 || !isIdentChar(query[i-1]) && query[i-1] != '$'):
+			j := i
+			for j+1 < len(query) && (query[j+1] >= '0' && query[j+1] <= '9' || query[j+1] == '.') {
+				j++
+			}
+			sb.WriteByte('?')
+			i = j
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	shape := normalizeQuery(sb.String())
+	if len(shape) > workloadMaxShapeLen {
+		shape = shape[:workloadMaxShapeLen]
+	}
+	return shape
+}
+
+// Workload returns the executor's workload recorder.
+func (e *StorageExecutor) Workload() *WorkloadRecorder {
+	return e.workload
+}
+
+// recordWorkload adds a finished top-level query to the workload.
+func (e *StorageExecutor) recordWorkload(q *RunningQuery, result *ExecuteResult, err error) {
+	var rows int64
+	if result != nil {
+		rows = int64(len(result.Rows))
+	}
+	e.workload.Record(q.Query, q.ReadOnly(), time.Since(q.StartedAt), rows, q.concurrent, err != nil)
+}
+
+// lintMissingIndexPattern extracts the label and property of a MISSING_INDEX issue.
+var lintMissingIndexPattern = regexp.MustCompile(`^No index on :([^(]+)\(([^)]+)\)`)
+
+// WorkloadRecommendations suggests query cache, pool and index changes for
+// the observed workload.
+func (e *StorageExecutor) WorkloadRecommendations() []WorkloadRecommendation {
+	snap := e.workload.Snapshot()
+	recs := []WorkloadRecommendation{}
+	recs = append(recs, e.recommendQueryCache(snap)...)
+	recs = append(recs, recommendPool(snap)...)
+	recs = append(recs, e.recommendIndexes(snap)...)
+	return recs
+}
+
+// recommendQueryCache sizes the result cache from its fill and hit rate.
+func (e *StorageExecutor) recommendQueryCache(snap WorkloadSnapshot) []WorkloadRecommendation {
+	if e.cache == nil {
+		return nil
+	}
+	hits, misses, size, _, _ := e.cache.Stats()
+	capacity := e.cache.Capacity()
+	lookups := hits + misses
+	if lookups < workloadMinCacheLookups || capacity <= 0 {
+		return nil
+	}
+	hitRate := float64(hits) / float64(lookups)
+
+	var repeated int
+	for _, s := range snap.Shapes {
+		if s.ReadOnly && s.Count > 1 {
+			repeated++
+		}
+	}
+
+	switch {
+	case size >= capacity && hitRate < 0.5 && repeated > 0 && capacity < workloadMaxCacheSize:
+		suggested := capacity * 2
+		if suggested > workloadMaxCacheSize {
+			suggested = workloadMaxCacheSize
+		}
+		return []WorkloadRecommendation{{
+			Area: "query_cache", Setting: "nornicdb.memory.query_cache.size", Current: capacity, Suggested: suggested,
+			Reason: fmt.Sprintf("Cache is full with a %.0f%% hit rate while %d read query shapes repeat; evictions are discarding reusable results",
+				hitRate*100, repeated),
+			Queries: lookups,
+		}}
+	case size < capacity/4 && lookups >= 10*workloadMinCacheLookups:
+		suggested := int(nextPow2(int64(size) * 2))
+		if suggested < 100 {
+			suggested = 100
+		}
+		if suggested >= capacity {
+			return nil
+		}
+		return []WorkloadRecommendation{{
+			Area: "query_cache", Setting: "nornicdb.memory.query_cache.size", Current: capacity, Suggested: suggested,
+			Reason:  fmt.Sprintf("Only %d of %d entries are used after %d lookups", size, capacity, lookups),
+			Queries: lookups,
+		}}
+	}
+	return nil
+}
+
+// recommendPool sizes pool.MaxSize from observed result sizes. Results
+// larger than MaxSize are not returned to the pools.
+func recommendPool(snap WorkloadSnapshot) []WorkloadRecommendation {
+	if !pool.IsEnabled() || snap.Queries < workloadMinPoolQueries {
+		return nil
+	}
+	current := pool.MaxSize()
+	switch {
+	case snap.RowsP95 > int64(current):
+		return []WorkloadRecommendation{{
+			Area: "pool", Setting: "nornicdb.memory.pool.max_size", Current: current, Suggested: nextPow2(snap.RowsP95),
+			Reason:  fmt.Sprintf("95%% of results have up to %d rows, above MaxSize; their buffers are not pooled and are reallocated", snap.RowsP95),
+			Queries: snap.Queries,
+		}}
+	case snap.RowsMax*10 < int64(current):
+		suggested := nextPow2(snap.RowsMax * 2)
+		if suggested < 64 {
+			suggested = 64
+		}
+		if suggested >= int64(current) {
+			return nil
+		}
+		return []WorkloadRecommendation{{
+			Area: "pool", Setting: "nornicdb.memory.pool.max_size", Current: current, Suggested: suggested,
+			Reason:  fmt.Sprintf("The largest result in the last %s had %d rows; pooled buffers above that size only hold memory", snap.Window, snap.RowsMax),
+			Queries: snap.Queries,
+		}}
+	}
+	return nil
+}
+
+// recommendIndexes suggests indexes for frequent shapes that look up
+// unindexed properties, ordered by the time spent in those shapes.
+func (e *StorageExecutor) recommendIndexes(snap WorkloadSnapshot) []WorkloadRecommendation {
+	schema := e.storage.GetSchema()
+	if schema == nil {
+		return nil
+	}
+	type candidate struct {
+		label, prop string
+		queries     int64
+		totalMs     float64
+	}
+	byKey := map[string]*candidate{}
+	for _, s := range snap.Shapes {
+		if s.Count < workloadMinIndexQueries {
+			continue
+		}
+		for _, issue := range Lint(s.example, schema) {
+			if issue.Code != LintMissingIndex {
+				continue
+			}
+			m := lintMissingIndexPattern.FindStringSubmatch(issue.Message)
+			if m == nil {
+				continue
+			}
+			key := m[1] + "(" + m[2] + ")"
+			c, ok := byKey[key]
+			if !ok {
+				c = &candidate{label: m[1], prop: m[2]}
+				byKey[key] = c
+			}
+			c.queries += s.Count
+			c.totalMs += s.TotalMs
+		}
+	}
+
+	candidates := make([]*candidate, 0, len(byKey))
+	for _, c := range byKey {
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].totalMs != candidates[j].totalMs {
+			return candidates[i].totalMs > candidates[j].totalMs
+		}
+		return candidates[i].label+candidates[i].prop < candidates[j].label+candidates[j].prop
+	})
+
+	recs := make([]WorkloadRecommendation, 0, len(candidates))
+	for _, c := range candidates {
+		recs = append(recs, WorkloadRecommendation{
+			Area:      "index",
+			Setting:   c.label + "(" + c.prop + ")",
+			Current:   "none",
+			Suggested: fmt.Sprintf("CREATE INDEX IF NOT EXISTS FOR (n:%s) ON (n.%s)", c.label, c.prop),
+			Reason: fmt.Sprintf("%d queries in the last %s looked up :%s(%s) without an index, taking %.0fms in total",
+				c.queries, snap.Window, c.label, c.prop, c.totalMs),
+			Queries: c.queries,
+		})
+	}
+	return recs
+}
+
+// nextPow2 returns the smallest power of two >= n (1 for n <= 1).
+func nextPow2(n int64) int64 {
+	if n <= 1 {
+		return 1
+	}
+	return int64(1) << uint(bits.Len64(uint64(n-1)))
+}
+
+// callNornicDbWorkload implements CALL nornicdb.workload().
+func (e *StorageExecutor) callNornicDbWorkload() (*ExecuteResult, error) {
+	snap := e.workload.Snapshot()
+	shapes := make([]interface{}, 0, len(snap.Shapes))
+	for _, s := range snap.Shapes {
+		shapes = append(shapes, workloadShapeMap(s))
+	}
+	return &ExecuteResult{
+		Columns: []string{"collecting", "since", "windowSeconds", "sampleRate", "queries", "reads", "writes", "errors",
+			"queriesPerSecond", "peakQueriesPerMinute", "peakConcurrency", "rowsP50", "rowsP95", "rowsMax", "shapes"},
+		Rows: [][]interface{}{{
+			snap.Collecting, snap.Since.UTC().Format(time.RFC3339), int64(snap.Window.Seconds()), snap.SampleRate,
+			snap.Queries, snap.Reads, snap.Writes, snap.Errors,
+			snap.QueriesPerSecond, snap.PeakQueriesPerMinute, int64(snap.PeakConcurrency),
+			snap.RowsP50, snap.RowsP95, snap.RowsMax, shapes,
+		}},
+	}, nil
+}
+
+// callNornicDbWorkloadRecommend implements CALL nornicdb.workload.recommend().
+func (e *StorageExecutor) callNornicDbWorkloadRecommend() (*ExecuteResult, error) {
+	result := &ExecuteResult{
+		Columns: []string{"area", "setting", "current", "suggested", "reason", "queries"},
+		Rows:    [][]interface{}{},
+	}
+	for _, r := range e.WorkloadRecommendations() {
+		result.Rows = append(result.Rows, []interface{}{r.Area, r.Setting, r.Current, r.Suggested, r.Reason, r.Queries})
+	}
+	return result, nil
+}
+
+// workloadShapeMap converts a shape to the map returned by procedures.
+func workloadShapeMap(s WorkloadShape) map[string]interface{} {
+	return map[string]interface{}{
+		"query":    s.Shape,
+		"readOnly": s.ReadOnly,
+		"count":    s.Count,
+		"samples":  s.Samples,
+		"errors":   s.Errors,
+		"avgMs":    s.AvgMs,
+		"maxMs":    s.MaxMs,
+		"totalMs":  s.TotalMs,
+		"avgRows":  s.AvgRows,
+		"maxRows":  s.MaxRows,
+		"lastSeen": s.LastSeen.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,152 @@
+// Package cypher - workload profiles for cold-start priming.
+//
+// On shutdown the database saves the most frequent query shapes seen by the
+// workload recorder, each with a recent example. On the next start the
+// executor primes itself from that profile before traffic arrives: every
+// example is validated and analyzed, its AST is built, and the labels read by
+// the workload are looked up once so their index entries and first nodes are
+// in storage caches. Nothing is executed and no results are cached.
+package cypher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultWorkloadPrimeQueries is how many shapes a profile keeps by default.
+const DefaultWorkloadPrimeQueries = 100
+
+// WorkloadProfile is the on-disk form of a workload's most frequent queries.
+type WorkloadProfile struct {
+	Version int                    `json:"version"`
+	SavedAt time.Time              `json:"saved_at"`
+	Queries []WorkloadProfileQuery `json:"queries"` // Most frequent first
+}
+
+// WorkloadProfileQuery is one shape in a profile.
+type WorkloadProfileQuery struct {
+	Shape    string `json:"shape"`
+	Example  string `json:"example"` // Latest query text with this shape
+	ReadOnly bool   `json:"read_only"`
+	Count    int64  `json:"count"`
+}
+
+// WorkloadPrimeStats reports what PrimeFromProfile did.
+type WorkloadPrimeStats struct {
+	Queries int // Examples analyzed and parsed
+	Skipped int // Examples that failed validation
+	Labels  int // Distinct labels touched in storage
+}
+
+// Profile returns the n most frequent shapes in the window. n below one
+// selects DefaultWorkloadPrimeQueries.
+func (w *WorkloadRecorder) Profile(n int) *WorkloadProfile {
+	if n < 1 {
+		n = DefaultWorkloadPrimeQueries
+	}
+	snap := w.Snapshot()
+	profile := &WorkloadProfile{Version: 1, SavedAt: time.Now(), Queries: []WorkloadProfileQuery{}}
+	for _, s := range snap.Shapes {
+		if len(profile.Queries) >= n {
+			break
+		}
+		if s.example == "" {
+			continue
+		}
+		profile.Queries = append(profile.Queries, WorkloadProfileQuery{
+			Shape:    s.Shape,
+			Example:  s.example,
+			ReadOnly: s.ReadOnly,
+			Count:    s.Count,
+		})
+	}
+	return profile
+}
+
+// SaveProfile writes the n most frequent shapes to path. The file is written
+// to a temp file first, then renamed.
+func (w *WorkloadRecorder) SaveProfile(path string, n int) error {
+	data, err := json.Marshal(w.Profile(n))
+	if err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// LoadWorkloadProfile reads a profile saved by SaveProfile. A missing file
+// returns nil and no error.
+func LoadWorkloadProfile(path string) (*WorkloadProfile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var profile WorkloadProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("parse workload profile %s: %w", path, err)
+	}
+	return &profile, nil
+}
+
+// PrimeFromProfile pre-parses the first n queries of profile (all of them
+// when n is below one) and touches the labels read-only queries match.
+// It stops early when ctx is done. A nil profile primes nothing.
+func (e *StorageExecutor) PrimeFromProfile(ctx context.Context, profile *WorkloadProfile, n int) WorkloadPrimeStats {
+	var stats WorkloadPrimeStats
+	if profile == nil {
+		return stats
+	}
+	queries := profile.Queries
+	if n > 0 && len(queries) > n {
+		queries = queries[:n]
+	}
+
+	touched := make(map[string]bool)
+	for _, q := range queries {
+		if ctx.Err() != nil {
+			break
+		}
+		cypher := strings.TrimSpace(q.Example)
+		if hasCypherPrefix(cypher) {
+			_, rest, err := ParseQueryOptions(cypher)
+			if err != nil {
+				stats.Skipped++
+				continue
+			}
+			cypher = rest
+		}
+		if cypher == "" || e.validateSyntax(cypher) != nil {
+			stats.Skipped++
+			continue
+		}
+		info := e.analyzer.Analyze(cypher)
+		info.GetAST()
+		stats.Queries++
+
+		if !info.IsReadOnly {
+			continue
+		}
+		for _, label := range info.Labels {
+			if touched[label] || ctx.Err() != nil {
+				continue
+			}
+			touched[label] = true
+			if node, err := e.storage.GetFirstNodeByLabel(label); err == nil && node != nil {
+				_, _ = e.storage.GetOutgoingEdges(node.ID)
+			}
+		}
+	}
+	stats.Labels = len(touched)
+	return stats
+}
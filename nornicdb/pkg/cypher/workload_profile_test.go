@@ -0,0 +1,81 @@
+package cypher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkloadRecorder_SaveLoadProfile(t *testing.T) {
+	w := NewWorkloadRecorder(10*time.Minute, 1, 10)
+	for i := 0; i < 3; i++ {
+		w.Record("MATCH (n:Person {name: 'Alice'}) RETURN n", true, time.Millisecond, 1, 1, false)
+	}
+	w.Record("CREATE (n:Person {name: 'Bob'})", false, time.Millisecond, 0, 1, false)
+	w.Record("MATCH (m:Movie) RETURN m", true, time.Millisecond, 5, 1, false)
+
+	path := filepath.Join(t.TempDir(), "workload.json")
+	require.NoError(t, w.SaveProfile(path, 2))
+
+	profile, err := LoadWorkloadProfile(path)
+	require.NoError(t, err)
+	require.NotNil(t, profile)
+	assert.Equal(t, 1, profile.Version)
+	require.Len(t, profile.Queries, 2)
+	assert.Equal(t, "MATCH (n:Person {name: ?}) RETURN n", profile.Queries[0].Shape)
+	assert.Equal(t, "MATCH (n:Person {name: 'Alice'}) RETURN n", profile.Queries[0].Example)
+	assert.Equal(t, int64(3), profile.Queries[0].Count)
+	assert.True(t, profile.Queries[0].ReadOnly)
+
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLoadWorkloadProfile_MissingAndCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	profile, err := LoadWorkloadProfile(filepath.Join(dir, "missing.json"))
+	require.NoError(t, err)
+	assert.Nil(t, profile)
+
+	path := filepath.Join(dir, "workload.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0644))
+	_, err = LoadWorkloadProfile(path)
+	assert.ErrorContains(t, err, "parse workload profile")
+}
+
+func TestStorageExecutor_PrimeFromProfile(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	require.NoError(t, store.CreateNode(&storage.Node{ID: "p1", Labels: []string{"Person"}}))
+	exec := NewStorageExecutor(store)
+	exec.analyzer.ClearCache()
+
+	profile := &WorkloadProfile{Version: 1, Queries: []WorkloadProfileQuery{
+		{Example: "MATCH (n:Person) RETURN n", ReadOnly: true},
+		{Example: "CYPHER runtime=slotted MATCH (m:Movie) RETURN m", ReadOnly: true},
+		{Example: "CREATE (n:Tag {name: 'x'})"},
+		{Example: "MATCH (n RETURN n"},
+		{Example: "MATCH (x:Ignored) RETURN x", ReadOnly: true},
+	}}
+
+	stats := exec.PrimeFromProfile(context.Background(), profile, 4)
+	assert.Equal(t, 3, stats.Queries)
+	assert.Equal(t, 1, stats.Skipped)
+	assert.Equal(t, 2, stats.Labels) // Person and Movie; writes are not touched
+	assert.Equal(t, 3, exec.analyzer.CacheSize())
+
+	info := exec.analyzer.Analyze("MATCH (n:Person) RETURN n")
+	assert.True(t, info.HasAST())
+
+	// Nothing was executed
+	count, err := store.NodeCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.Equal(t, WorkloadPrimeStats{}, exec.PrimeFromProfile(context.Background(), nil, 0))
+}
@@ -0,0 +1,207 @@
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/pool"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkloadShape(t *testing.T) {
+	tests := []struct {
+		query string
+		shape string
+	}{
+		{"MATCH (n:Person {name: 'Alice'}) RETURN n", "MATCH (n:Person {name: ?}) RETURN n"},
+		{"MATCH (n)\n  WHERE n.age > 42 AND n.score < 0.5\n  RETURN n LIMIT 10", "MATCH (n) WHERE n.age > ? AND n.score < ? RETURN n LIMIT ?"},
+		{`RETURN "it\"s", 'x'`, "RETURN ?, ?"},
+		{"MATCH (n1:`Label 2`) WHERE n1.v = $p1 RETURN n1", "MATCH (n1:`Label 2`) WHERE n1.v = $p1 RETURN n1"},
+		{"MATCH p=(a)-[*1..3]->(b) RETURN p", "MATCH p=(a)-[*?]->(b) RETURN p"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.shape, workloadShape(tt.query), tt.query)
+	}
+}
+
+func TestWorkloadRecorder(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := NewWorkloadRecorder(10*time.Minute, 1, 2)
+	w.now = func() time.Time { return now }
+	w.Clear()
+
+	for i := 0; i < 4; i++ {
+		w.Record(fmt.Sprintf("MATCH (n {id: %d}) RETURN n", i), true, 2*time.Millisecond, 1, 1, false)
+	}
+	now = now.Add(time.Minute)
+	w.Record("CREATE (n {id: 1})", false, 10*time.Millisecond, 0, 3, true)
+	w.Record("MATCH (n) RETURN n", true, time.Millisecond, 100, 2, false)
+
+	snap := w.Snapshot()
+	assert.Equal(t, int64(6), snap.Queries)
+	assert.Equal(t, int64(5), snap.Reads)
+	assert.Equal(t, int64(1), snap.Writes)
+	assert.Equal(t, int64(1), snap.Errors)
+	assert.Equal(t, int64(4), snap.PeakQueriesPerMinute)
+	assert.Equal(t, 3, snap.PeakConcurrency)
+	assert.InDelta(t, 0.1, snap.QueriesPerSecond, 0.001)
+	assert.Equal(t, int64(1), snap.RowsP50)
+	assert.Equal(t, int64(100), snap.RowsP95)
+	assert.Equal(t, int64(100), snap.RowsMax)
+
+	// maxShapes 2: the MATCH-by-id shape was least recently seen and evicted
+	require.Len(t, snap.Shapes, 2)
+	assert.Equal(t, "CREATE (n {id: ?})", snap.Shapes[0].Shape)
+	assert.Equal(t, int64(1), snap.Shapes[0].Errors)
+	assert.Equal(t, 10.0, snap.Shapes[0].AvgMs)
+	assert.Equal(t, "MATCH (n) RETURN n", snap.Shapes[1].Shape)
+	assert.Equal(t, int64(100), snap.Shapes[1].MaxRows)
+
+	// Minutes and shapes roll out of the window
+	now = now.Add(10 * time.Minute)
+	w.Record("MATCH (n) RETURN n", true, time.Millisecond, 1, 1, false)
+	snap = w.Snapshot()
+	assert.Equal(t, int64(1), snap.Queries)
+	require.Len(t, snap.Shapes, 1)
+	assert.Equal(t, int64(2), snap.Shapes[0].Count, "shapes seen within the window keep their totals")
+
+	w.SetCollecting(false)
+	w.Record("MATCH (n) RETURN n", true, time.Millisecond, 1, 1, false)
+	assert.Equal(t, int64(1), w.Snapshot().Queries)
+	assert.False(t, w.Collecting())
+
+	w.Clear()
+	snap = w.Snapshot()
+	assert.Zero(t, snap.Queries)
+	assert.Empty(t, snap.Shapes)
+
+	var nilRecorder *WorkloadRecorder
+	nilRecorder.Record("RETURN 1", true, 0, 1, 1, false)
+	assert.Empty(t, nilRecorder.Snapshot().Shapes)
+}
+
+func TestWorkloadRecorder_Sampling(t *testing.T) {
+	w := NewWorkloadRecorder(time.Hour, 0.5, 10)
+	for i := 0; i < 2000; i++ {
+		w.Record("RETURN 1", true, time.Millisecond, 1, 1, false)
+	}
+	snap := w.Snapshot()
+	assert.Equal(t, int64(2000), snap.Queries, "arrivals are always counted")
+	require.Len(t, snap.Shapes, 1)
+	assert.Less(t, snap.Shapes[0].Samples, int64(2000))
+	assert.InDelta(t, 2000, snap.Shapes[0].Count, 300, "counts are scaled by the sample rate")
+}
+
+func TestWorkloadProcedures(t *testing.T) {
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := exec.Execute(ctx, "CREATE (:Item {i: $i})", map[string]interface{}{"i": i})
+		require.NoError(t, err)
+	}
+	_, err := exec.Execute(ctx, "MATCH (n:Item) RETURN n.i", nil)
+	require.NoError(t, err)
+
+	result, err := exec.Execute(ctx, "CALL nornicdb.workload()", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	row := map[string]interface{}{}
+	for i, col := range result.Columns {
+		row[col] = result.Rows[0][i]
+	}
+	assert.Equal(t, true, row["collecting"])
+	assert.Equal(t, int64(4), row["queries"])
+	assert.Equal(t, int64(1), row["reads"])
+	assert.Equal(t, int64(3), row["rowsMax"])
+	shapes := row["shapes"].([]interface{})
+	require.Len(t, shapes, 2)
+	assert.Equal(t, "CREATE (:Item {i: $i})", shapes[0].(map[string]interface{})["query"])
+	assert.Equal(t, int64(3), shapes[0].(map[string]interface{})["count"])
+
+	// db.stats sees the same workload, including the procedure call above
+	result, err = exec.Execute(ctx, "CALL db.stats.retrieve('QUERIES')", nil)
+	require.NoError(t, err)
+	data := result.Rows[0][1].(map[string]interface{})
+	assert.Equal(t, int64(5), data["totalQueries"])
+	assert.Len(t, data["queries"], 3)
+
+	_, err = exec.Execute(ctx, "CALL db.stats.stop('QUERIES')", nil)
+	require.NoError(t, err)
+	assert.False(t, exec.Workload().Collecting())
+	result, err = exec.Execute(ctx, "CALL db.stats.status()", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "idle", result.Rows[0][1])
+
+	_, err = exec.Execute(ctx, "CALL db.stats.clear()", nil)
+	require.NoError(t, err)
+	_, err = exec.Execute(ctx, "CALL db.stats.collect('QUERIES')", nil)
+	require.NoError(t, err)
+	assert.True(t, exec.Workload().Collecting())
+	assert.Len(t, exec.Workload().Snapshot().Shapes, 1, "only the collect call since clearing")
+
+	_, err = exec.Execute(ctx, "CALL db.stats.collect('TOKENS')", nil)
+	assert.Error(t, err)
+	_, err = exec.Execute(ctx, "CALL db.stats.retrieve('GRAPH COUNTS')", nil)
+	require.NoError(t, err)
+}
+
+func TestWorkloadRecommendations_Index(t *testing.T) {
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+	ctx := context.Background()
+	for i := 0; i < workloadMinIndexQueries; i++ {
+		_, err := exec.Execute(ctx, fmt.Sprintf("MATCH (p:Person) WHERE p.email = 'user%d@example.com' RETURN p", i), nil)
+		require.NoError(t, err)
+	}
+
+	result, err := exec.Execute(ctx, "CALL nornicdb.workload.recommend()", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"area", "setting", "current", "suggested", "reason", "queries"}, result.Columns)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, "index", result.Rows[0][0])
+	assert.Equal(t, "Person(email)", result.Rows[0][1])
+	assert.Equal(t, "CREATE INDEX IF NOT EXISTS FOR (n:Person) ON (n.email)", result.Rows[0][3])
+	assert.Contains(t, result.Rows[0][4], "10 queries")
+	assert.Equal(t, int64(10), result.Rows[0][5])
+
+	_, err = exec.Execute(ctx, result.Rows[0][3].(string), nil)
+	require.NoError(t, err)
+	assert.Empty(t, exec.WorkloadRecommendations(), "indexed lookups need no index")
+
+	_, err = exec.Execute(ctx, "DROP INDEX index_person_email", nil)
+	require.NoError(t, err)
+	assert.Len(t, exec.WorkloadRecommendations(), 1, "a dropped index is suggested again")
+}
+
+func TestWorkloadRecommendations_CacheAndPool(t *testing.T) {
+	defer pool.Configure(pool.PoolConfig{Enabled: true, MaxSize: 1000})
+	pool.Configure(pool.PoolConfig{Enabled: true, MaxSize: 4})
+
+	exec := newRegistryTestExecutor(t, 10)
+	exec.cache = NewSmartQueryCache(2)
+	exec.Workload().Clear()
+	ctx := context.Background()
+
+	// Ten parameter values cycling through a two-entry cache always miss
+	for i := 0; i < workloadMinCacheLookups; i++ {
+		_, err := exec.Execute(ctx, "MATCH (n:Item) WHERE n.i >= $i RETURN n.i", map[string]interface{}{"i": i % 10})
+		require.NoError(t, err)
+	}
+	_, err := exec.Execute(ctx, "MATCH (n:Item) RETURN n.i", nil)
+	require.NoError(t, err)
+
+	byArea := map[string]WorkloadRecommendation{}
+	for _, r := range exec.WorkloadRecommendations() {
+		byArea[r.Area] = r
+	}
+	require.Contains(t, byArea, "query_cache")
+	assert.Equal(t, 2, byArea["query_cache"].Current)
+	assert.Equal(t, 4, byArea["query_cache"].Suggested)
+
+	require.Contains(t, byArea, "pool")
+	assert.Equal(t, 4, byArea["pool"].Current)
+	assert.Equal(t, int64(16), byArea["pool"].Suggested)
+}
@@ -0,0 +1,110 @@
+// Package cypher - server-side retry of auto-commit writes that lose a write
+// conflict.
+//
+// An implicit write transaction aborts with storage.ErrConflict when a
+// concurrent transaction commits a write to data it read or wrote. With a
+// RetryPolicy set, auto-commit statements flagged retryable are re-run from
+// the start after an exponentially growing, jittered backoff, so clients do
+// not each need their own retry loop:
+//
+//	CYPHER retryable=true MATCH (c:Counter {id: 1}) SET c.n = c.n + 1
+//
+// The number of retries a statement needed is reported in QueryStats.Retries.
+// Only flagged statements are retried, since a statement whose procedures
+// act outside the database would repeat those effects on every attempt.
+// Statements inside explicit transactions are never retried here; the
+// client owns the transaction and retries it as a whole.
+package cypher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// RetryPolicy controls retries of retryable auto-commit writes.
+// The zero value disables retries.
+type RetryPolicy struct {
+	MaxRetries     int           // Retries after the first attempt, 0 = disabled
+	InitialBackoff time.Duration // Wait before the first retry
+	MaxBackoff     time.Duration // Cap on the doubling wait, 0 = uncapped
+}
+
+// backoff returns the wait before the given retry (0-based): the initial
+// backoff doubled per retry and capped, with the upper half jittered so
+// that writers that conflicted together do not retry in lockstep.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff
+	for i := 0; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// SetRetryPolicy sets how auto-commit writes flagged retryable are retried
+// after a write conflict. Set it before executing queries.
+//
+// Example:
+//
+//	executor.SetRetryPolicy(cypher.RetryPolicy{
+//		MaxRetries:     5,
+//		InitialBackoff: 10 * time.Millisecond,
+//		MaxBackoff:     time.Second,
+//	})
+func (e *StorageExecutor) SetRetryPolicy(p RetryPolicy) {
+	e.retryPolicy = p
+}
+
+// executeWithConflictRetry runs an auto-commit write in an implicit
+// transaction, retrying it under the retry policy if it is retryable.
+func (e *StorageExecutor) executeWithConflictRetry(ctx context.Context, cypher string, upperQuery string) (*ExecuteResult, error) {
+	attempt := func() (*ExecuteResult, error) {
+		return e.executeWithImplicitTransaction(ctx, cypher, upperQuery)
+	}
+	if !QueryOptionsFromContext(ctx).Retryable {
+		return attempt()
+	}
+	return retryOnConflict(ctx, e.retryPolicy, attempt)
+}
+
+// retryOnConflict calls attempt until it succeeds, fails with an error other
+// than storage.ErrConflict, or the policy's retries are used up. A
+// successful result records the retries it took in Stats.Retries.
+func retryOnConflict(ctx context.Context, policy RetryPolicy, attempt func() (*ExecuteResult, error)) (*ExecuteResult, error) {
+	for retries := 0; ; retries++ {
+		result, err := attempt()
+		if err == nil {
+			if retries > 0 && result != nil {
+				if result.Stats == nil {
+					result.Stats = &QueryStats{}
+				}
+				result.Stats.Retries = retries
+			}
+			return result, nil
+		}
+		if !errors.Is(err, storage.ErrConflict) || policy.MaxRetries <= 0 {
+			return nil, err
+		}
+		if retries == policy.MaxRetries {
+			return nil, fmt.Errorf("%w (gave up after %d retries)", err, retries)
+		}
+
+		timer := time.NewTimer(policy.backoff(retries))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package cypher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	for retry, want := range []time.Duration{10, 20, 40, 50, 50} {
+		want *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			d := p.backoff(retry)
+			assert.GreaterOrEqual(t, d, want/2, "retry %d", retry)
+			assert.LessOrEqual(t, d, want, "retry %d", retry)
+		}
+	}
+	assert.Zero(t, RetryPolicy{}.backoff(3))
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	ctx := context.Background()
+	policy := RetryPolicy{MaxRetries: 3, InitialBackoff: time.Microsecond}
+
+	// Succeeds on the third attempt
+	calls := 0
+	result, err := retryOnConflict(ctx, policy, func() (*ExecuteResult, error) {
+		calls++
+		if calls < 3 {
+			return nil, storage.ErrConflict
+		}
+		return &ExecuteResult{}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	require.NotNil(t, result.Stats)
+	assert.Equal(t, 2, result.Stats.Retries)
+
+	// Gives up after MaxRetries
+	calls = 0
+	_, err = retryOnConflict(ctx, policy, func() (*ExecuteResult, error) {
+		calls++
+		return nil, storage.ErrConflict
+	})
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	assert.Contains(t, err.Error(), "gave up after 3 retries")
+	assert.Equal(t, 4, calls)
+
+	// Other errors and disabled policies are not retried
+	calls = 0
+	other := errors.New("syntax error")
+	_, err = retryOnConflict(ctx, policy, func() (*ExecuteResult, error) {
+		calls++
+		return nil, other
+	})
+	assert.Equal(t, other, err)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	_, err = retryOnConflict(ctx, RetryPolicy{}, func() (*ExecuteResult, error) {
+		calls++
+		return nil, storage.ErrConflict
+	})
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	assert.Equal(t, 1, calls)
+
+	// A cancelled context stops waiting
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	calls = 0
+	_, err = retryOnConflict(cancelled, RetryPolicy{MaxRetries: 3, InitialBackoff: time.Hour}, func() (*ExecuteResult, error) {
+		calls++
+		return nil, storage.ErrConflict
+	})
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	assert.Equal(t, 1, calls)
+}
+
+func TestExecute_RetryableWrite(t *testing.T) {
+	// Retryable writes run normally when no conflict occurs
+	exec := NewStorageExecutor(storage.NewMemoryEngine())
+	exec.SetRetryPolicy(RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond})
+	result, err := exec.Execute(context.Background(), "CYPHER retryable=true CREATE (n:Counter {n: 1}) RETURN n.n", nil)
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(1)}}, result.Rows)
+}
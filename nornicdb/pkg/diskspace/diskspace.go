@@ -0,0 +1,10 @@
+// Package diskspace reports the free and total space of the volume holding
+// a path. The preflight checks and the health metrics both use it to warn
+// before the data directory fills up.
+//
+// Example:
+//
+//	if free, total, ok := diskspace.Usage(dataDir); ok {
+//		log.Printf("%d of %d bytes free", free, total)
+//	}
+package diskspace
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package diskspace
+
+// Usage reports no disk usage on platforms without statfs.
+func Usage(path string) (free, total uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package diskspace
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsage(t *testing.T) {
+	free, total, ok := Usage(t.TempDir())
+	require.True(t, ok)
+	assert.Positive(t, total)
+	assert.LessOrEqual(t, free, total)
+
+	_, _, ok = Usage(filepath.Join(t.TempDir(), "missing"))
+	assert.False(t, ok)
+}
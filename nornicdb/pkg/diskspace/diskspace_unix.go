@@ -0,0 +1,15 @@
+//go:build unix
+
+package diskspace
+
+import "syscall"
+
+// Usage returns the free and total bytes of the volume holding path. Free
+// counts the bytes available to unprivileged users.
+func Usage(path string) (free, total uint64, ok bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, false
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), true
+}
@@ -0,0 +1,727 @@
+// Package edgesync syncs an edge instance of NornicDB with a central server
+// after working offline.
+//
+// A desktop app or agent embeds NornicDB and keeps writing while it has no
+// connection. When it is back online it syncs with the central server: each
+// side sends the nodes and relationships the other has not seen, and both
+// end up with the same graph.
+//
+// Every element carries a version vector: for each replica that wrote it,
+// that replica's write counter at its last write. Comparing the vectors of
+// the two copies of an element tells whether one copy has seen the other's
+// writes (it is sent as is) or the copies were written independently, a
+// conflict. Conflicts are settled by a Resolver, LastWriterWins by default,
+// and every replica settles a conflict the same way, so the replicas
+// converge without coordination.
+//
+// A Replica detects local writes by comparing the graph with a digest of
+// each element from the previous sync (Refresh), so writes made through
+// Cypher, Bolt, transactions or bulk imports are all picked up. Embeddings
+// are not synced; each side computes its own. Deleted elements are kept as
+// tombstones so deletes win over older writes.
+//
+// Example:
+//
+//	replica, _ := edgesync.Open(engine, edgesync.Options{
+//		ReplicaID: "laptop-42",
+//		StateFile: filepath.Join(dataDir, "sync", "state.json"),
+//	})
+//	central := edgesync.NewHTTPPeer("https://graph.example.com", token)
+//	result, err := replica.Sync(ctx, central) // after coming back online
+package edgesync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// Element kinds.
+const (
+	KindNode = "node"
+	KindEdge = "edge"
+)
+
+// ErrNoReplicaID is returned by Open without Options.ReplicaID.
+var ErrNoReplicaID = errors.New("edgesync: replica ID is required")
+
+// VersionVector maps replica IDs to write counters.
+type VersionVector map[string]uint64
+
+// Ordering is the result of comparing two version vectors.
+type Ordering int
+
+// Orderings of version vectors.
+const (
+	Equal      Ordering = iota // Same writes
+	Before                     // The other vector has seen every write of this one and more
+	After                      // This vector has seen every write of the other and more
+	Concurrent                 // Each has writes the other has not seen: a conflict
+)
+
+// Clone returns a copy of v.
+func (v VersionVector) Clone() VersionVector {
+	c := make(VersionVector, len(v))
+	for id, n := range v {
+		c[id] = n
+	}
+	return c
+}
+
+// Merge raises each counter of v to at least the counter in o.
+func (v VersionVector) Merge(o VersionVector) {
+	for id, n := range o {
+		if n > v[id] {
+			v[id] = n
+		}
+	}
+}
+
+// Compare orders v against o.
+func (v VersionVector) Compare(o VersionVector) Ordering {
+	less, greater := false, false
+	for id, n := range v {
+		if n > o[id] {
+			greater = true
+		} else if n < o[id] {
+			less = true
+		}
+	}
+	for id, n := range o {
+		if _, ok := v[id]; !ok && n > 0 {
+			less = true
+		}
+	}
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	}
+	return Equal
+}
+
+// newerThan reports whether v has a write o has not seen.
+func (v VersionVector) newerThan(o VersionVector) bool {
+	for id, n := range v {
+		if n > o[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// Change is the latest state of one element, as sent between replicas.
+type Change struct {
+	Kind       string         `json:"kind"`
+	ID         string         `json:"id"`
+	Deleted    bool           `json:"deleted,omitempty"`
+	Labels     []string       `json:"labels,omitempty"`     // Nodes
+	Type       string         `json:"type,omitempty"`       // Edges
+	StartNode  string         `json:"start_node,omitempty"` // Edges
+	EndNode    string         `json:"end_node,omitempty"`   // Edges
+	Properties map[string]any `json:"properties,omitempty"`
+	Version    VersionVector  `json:"version"`
+	Origin     string         `json:"origin"` // Replica of the last write
+	Time       time.Time      `json:"time"`   // Time of the last write
+}
+
+func (c *Change) key() string { return c.Kind + ":" + c.ID }
+
+// Resolver settles a conflict between the local and remote copies of an
+// element by returning the copy to keep, or a new one merged from both.
+// Either copy may be a delete. Resolvers must be deterministic and
+// symmetric, so every replica settles a conflict the same way.
+type Resolver func(local, remote Change) Change
+
+// LastWriterWins keeps the copy written last, breaking ties by the higher
+// replica ID.
+func LastWriterWins(local, remote Change) Change {
+	if !local.Time.Equal(remote.Time) {
+		if remote.Time.After(local.Time) {
+			return remote
+		}
+		return local
+	}
+	if remote.Origin > local.Origin {
+		return remote
+	}
+	return local
+}
+
+// Peer is a replica to sync with, in process (*Replica) or over HTTP
+// (HTTPPeer).
+type Peer interface {
+	// Clock returns the highest write counter of each replica the peer has
+	// seen.
+	Clock(ctx context.Context) (VersionVector, error)
+	// Changes returns the elements with writes not covered by since.
+	Changes(ctx context.Context, since VersionVector) ([]Change, error)
+	// Apply merges changes from another replica.
+	Apply(ctx context.Context, changes []Change) (ApplyResult, error)
+}
+
+// ApplyResult counts the outcome of Apply.
+type ApplyResult struct {
+	Applied   int `json:"applied"`   // Changes written
+	Skipped   int `json:"skipped"`   // Changes already seen
+	Conflicts int `json:"conflicts"` // Concurrent writes settled by the resolver
+}
+
+// SyncResult reports a Sync.
+type SyncResult struct {
+	Pulled   ApplyResult   `json:"pulled"`
+	Pushed   ApplyResult   `json:"pushed"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Options configures a Replica.
+type Options struct {
+	// ReplicaID names this replica; it must be unique among the replicas
+	// that sync with each other and stable across restarts.
+	ReplicaID string
+	// StateFile keeps the version vectors across restarts ("" = in memory).
+	StateFile string
+	// Resolver settles conflicts (nil = LastWriterWins).
+	Resolver Resolver
+	// OnApplied is called for each change written from another replica,
+	// e.g. to update search indexes.
+	OnApplied func(Change)
+}
+
+// elementMeta is what a replica knows about one element.
+type elementMeta struct {
+	Version VersionVector `json:"version"`
+	Origin  string        `json:"origin"`
+	Time    time.Time     `json:"time"`
+	Hash    string        `json:"hash,omitempty"` // Digest of the element at the last refresh
+	Deleted bool          `json:"deleted,omitempty"`
+}
+
+// state is persisted in Options.StateFile.
+type state struct {
+	ReplicaID string                  `json:"replica_id"`
+	Clock     VersionVector           `json:"clock"`
+	Elements  map[string]*elementMeta `json:"elements"`
+}
+
+// Replica tracks the versions of the elements of a storage engine and syncs
+// them with peers. It is safe for concurrent use.
+type Replica struct {
+	engine    storage.Engine
+	id        string
+	stateFile string
+	resolve   Resolver
+	onApplied func(Change)
+	now       func() time.Time
+
+	mu    sync.Mutex
+	state state
+}
+
+// Open returns the replica of engine, loading its state from
+// opts.StateFile if the file exists.
+func Open(engine storage.Engine, opts Options) (*Replica, error) {
+	if opts.ReplicaID == "" {
+		return nil, ErrNoReplicaID
+	}
+	r := &Replica{
+		engine:    engine,
+		id:        opts.ReplicaID,
+		stateFile: opts.StateFile,
+		resolve:   opts.Resolver,
+		onApplied: opts.OnApplied,
+		now:       time.Now,
+		state: state{
+			ReplicaID: opts.ReplicaID,
+			Clock:     VersionVector{},
+			Elements:  map[string]*elementMeta{},
+		},
+	}
+	if r.resolve == nil {
+		r.resolve = LastWriterWins
+	}
+	if opts.StateFile == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(opts.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("edgesync: reading state: %w", err)
+	}
+	var loaded state
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("edgesync: parsing state: %w", err)
+	}
+	if loaded.ReplicaID != opts.ReplicaID {
+		return nil, fmt.Errorf("edgesync: state file belongs to replica %q, not %q", loaded.ReplicaID, opts.ReplicaID)
+	}
+	if loaded.Clock != nil {
+		r.state.Clock = loaded.Clock
+	}
+	if loaded.Elements != nil {
+		r.state.Elements = loaded.Elements
+	}
+	return r, nil
+}
+
+// ID returns the replica ID.
+func (r *Replica) ID() string { return r.id }
+
+// Refresh records the local writes since the last refresh: new, changed
+// and deleted elements get a new write counter of this replica. It returns
+// the number of writes found. Clock, Changes and Sync refresh first.
+func (r *Replica) Refresh(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.refreshLocked(ctx)
+}
+
+func (r *Replica) refreshLocked(ctx context.Context) (int, error) {
+	now := r.now()
+	seen := make(map[string]bool, len(r.state.Elements))
+	writes := 0
+	record := func(key, hash string, updated time.Time) {
+		seen[key] = true
+		m := r.state.Elements[key]
+		if m != nil && !m.Deleted && m.Hash == hash {
+			return
+		}
+		if updated.IsZero() || updated.After(now) {
+			updated = now
+		}
+		r.bumpLocked(key, hash, false, updated)
+		writes++
+	}
+
+	err := storage.StreamNodesWithFallback(ctx, r.engine, 1000, func(node *storage.Node) error {
+		record(KindNode+":"+string(node.ID), nodeHash(node), node.UpdatedAt)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("edgesync: scanning nodes: %w", err)
+	}
+	err = storage.StreamEdgesWithFallback(ctx, r.engine, 1000, func(edge *storage.Edge) error {
+		record(KindEdge+":"+string(edge.ID), edgeHash(edge), edge.UpdatedAt)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("edgesync: scanning edges: %w", err)
+	}
+
+	for key, m := range r.state.Elements {
+		if !seen[key] && !m.Deleted {
+			r.bumpLocked(key, "", true, now)
+			writes++
+		}
+	}
+	if writes == 0 {
+		return 0, nil
+	}
+	// Persist the new counters before peers see them, so a restart never
+	// reuses a counter for a different write.
+	return writes, r.saveLocked()
+}
+
+// bumpLocked records a local write of an element.
+func (r *Replica) bumpLocked(key, hash string, deleted bool, at time.Time) {
+	m := r.state.Elements[key]
+	if m == nil {
+		m = &elementMeta{Version: VersionVector{}}
+		r.state.Elements[key] = m
+	}
+	r.state.Clock[r.id]++
+	m.Version[r.id] = r.state.Clock[r.id]
+	m.Origin, m.Time, m.Hash, m.Deleted = r.id, at, hash, deleted
+}
+
+// Clock returns the highest write counter of each replica this replica has
+// seen, its own included.
+func (r *Replica) Clock(ctx context.Context) (VersionVector, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.refreshLocked(ctx); err != nil {
+		return nil, err
+	}
+	return r.state.Clock.Clone(), nil
+}
+
+// Changes returns the elements with writes not covered by since, nodes
+// before the edges that need them.
+func (r *Replica) Changes(ctx context.Context, since VersionVector) ([]Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.refreshLocked(ctx); err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for key, m := range r.state.Elements {
+		if !m.Version.newerThan(since) {
+			continue
+		}
+		change, err := r.changeLocked(key, m)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	sortChanges(changes)
+	return changes, nil
+}
+
+// changeLocked builds the change for an element from the engine.
+func (r *Replica) changeLocked(key string, m *elementMeta) (Change, error) {
+	kind, id := splitKey(key)
+	c := Change{Kind: kind, ID: id, Version: m.Version.Clone(), Origin: m.Origin, Time: m.Time, Deleted: m.Deleted}
+	if m.Deleted {
+		return c, nil
+	}
+	var err error
+	if kind == KindNode {
+		var node *storage.Node
+		if node, err = r.engine.GetNode(storage.NodeID(id)); err == nil {
+			c.Labels, c.Properties = node.Labels, node.Properties
+		}
+	} else {
+		var edge *storage.Edge
+		if edge, err = r.engine.GetEdge(storage.EdgeID(id)); err == nil {
+			c.Type, c.StartNode, c.EndNode, c.Properties = edge.Type, string(edge.StartNode), string(edge.EndNode), edge.Properties
+		}
+	}
+	if err != nil {
+		return c, fmt.Errorf("edgesync: reading %s: %w", key, err)
+	}
+	return c, nil
+}
+
+// Apply merges changes from another replica: changes this replica has
+// seen are skipped, newer ones are written, and concurrent ones are
+// settled by the resolver.
+func (r *Replica) Apply(ctx context.Context, changes []Change) (ApplyResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result ApplyResult
+	// Writes made before the changes arrive must count as local writes,
+	// or they would be overwritten without a conflict.
+	if _, err := r.refreshLocked(ctx); err != nil {
+		return result, err
+	}
+
+	sorted := append([]Change(nil), changes...)
+	sortChanges(sorted)
+	for _, remote := range sorted {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if remote.Kind != KindNode && remote.Kind != KindEdge {
+			return result, fmt.Errorf("edgesync: unknown element kind %q", remote.Kind)
+		}
+		key := remote.key()
+		local := r.state.Elements[key]
+		if local == nil {
+			if err := r.writeLocked(remote, remote.Version.Clone()); err != nil {
+				return result, err
+			}
+			result.Applied++
+			continue
+		}
+
+		switch local.Version.Compare(remote.Version) {
+		case Equal, After:
+			result.Skipped++
+		case Before:
+			if err := r.writeLocked(remote, remote.Version.Clone()); err != nil {
+				return result, err
+			}
+			result.Applied++
+		case Concurrent:
+			result.Conflicts++
+			mine, err := r.changeLocked(key, local)
+			if err != nil {
+				return result, err
+			}
+			version := local.Version.Clone()
+			version.Merge(remote.Version)
+			winner := r.resolve(mine, remote)
+			winner.Kind, winner.ID = remote.Kind, remote.ID
+			switch changeHash(winner) {
+			case changeHash(mine):
+				// Keep the local copy; the merged version makes peers take it.
+				local.Version = version
+				local.Origin, local.Time = mine.Origin, mine.Time
+				continue
+			case changeHash(remote):
+			default:
+				// A merged copy is a new write of this replica.
+				r.state.Clock[r.id]++
+				version[r.id] = r.state.Clock[r.id]
+				winner.Origin, winner.Time = r.id, r.now()
+			}
+			if err := r.writeLocked(winner, version); err != nil {
+				return result, err
+			}
+			result.Applied++
+		}
+	}
+	return result, r.saveLocked()
+}
+
+// writeLocked writes a change to the engine and records its version.
+func (r *Replica) writeLocked(c Change, version VersionVector) error {
+	m := &elementMeta{Version: version, Origin: c.Origin, Time: c.Time, Deleted: c.Deleted}
+	var err error
+	switch {
+	case c.Kind == KindNode && c.Deleted:
+		err = ignoreNotFound(r.engine.DeleteNode(storage.NodeID(c.ID)))
+	case c.Kind == KindEdge && c.Deleted:
+		err = ignoreNotFound(r.engine.DeleteEdge(storage.EdgeID(c.ID)))
+	case c.Kind == KindNode:
+		m.Hash, err = r.putNode(c)
+	default:
+		m.Hash, err = r.putEdge(c)
+		if errors.Is(err, storage.ErrNotFound) {
+			// An endpoint was deleted here: the edge goes with it.
+			m.Hash, m.Deleted, err = "", true, nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("edgesync: writing %s: %w", c.key(), err)
+	}
+	for id, n := range version {
+		if n > r.state.Clock[id] {
+			r.state.Clock[id] = n
+		}
+	}
+	r.state.Elements[c.key()] = m
+	if r.onApplied != nil {
+		r.onApplied(c)
+	}
+	return nil
+}
+
+// putNode creates or replaces a node and returns its digest as stored.
+func (r *Replica) putNode(c Change) (string, error) {
+	node := &storage.Node{ID: storage.NodeID(c.ID), Labels: c.Labels, Properties: c.Properties}
+	existing, err := r.engine.GetNode(node.ID)
+	switch {
+	case err == nil:
+		// Embeddings are not synced; the embed worker recomputes them.
+		node.CreatedAt = existing.CreatedAt
+		err = r.engine.UpdateNode(node)
+	case errors.Is(err, storage.ErrNotFound):
+		err = r.engine.CreateNode(node)
+	}
+	if err != nil {
+		return "", err
+	}
+	stored, err := r.engine.GetNode(node.ID)
+	if err != nil {
+		return "", err
+	}
+	return nodeHash(stored), nil
+}
+
+// putEdge creates or replaces an edge and returns its digest as stored. It
+// returns storage.ErrNotFound if an endpoint is missing.
+func (r *Replica) putEdge(c Change) (string, error) {
+	for _, id := range []string{c.StartNode, c.EndNode} {
+		if _, err := r.engine.GetNode(storage.NodeID(id)); err != nil {
+			return "", err
+		}
+	}
+	edge := &storage.Edge{
+		ID:         storage.EdgeID(c.ID),
+		StartNode:  storage.NodeID(c.StartNode),
+		EndNode:    storage.NodeID(c.EndNode),
+		Type:       c.Type,
+		Properties: c.Properties,
+	}
+	existing, err := r.engine.GetEdge(edge.ID)
+	switch {
+	case err == nil && existing.StartNode == edge.StartNode && existing.EndNode == edge.EndNode:
+		edge.CreatedAt = existing.CreatedAt
+		err = r.engine.UpdateEdge(edge)
+	case err == nil:
+		// Endpoints cannot be updated in place.
+		if err = r.engine.DeleteEdge(edge.ID); err == nil {
+			err = r.engine.CreateEdge(edge)
+		}
+	case errors.Is(err, storage.ErrNotFound):
+		err = r.engine.CreateEdge(edge)
+	}
+	if err != nil {
+		return "", err
+	}
+	stored, err := r.engine.GetEdge(edge.ID)
+	if err != nil {
+		return "", err
+	}
+	return edgeHash(stored), nil
+}
+
+// Sync pulls the peer's changes this replica has not seen, then pushes
+// this replica's changes the peer has not seen, including the outcome of
+// conflicts settled during the pull.
+func (r *Replica) Sync(ctx context.Context, peer Peer) (SyncResult, error) {
+	start := r.now()
+	var result SyncResult
+
+	local, err := r.Clock(ctx)
+	if err != nil {
+		return result, err
+	}
+	incoming, err := peer.Changes(ctx, local)
+	if err != nil {
+		return result, fmt.Errorf("edgesync: pulling changes: %w", err)
+	}
+	if result.Pulled, err = r.Apply(ctx, incoming); err != nil {
+		return result, err
+	}
+
+	remote, err := peer.Clock(ctx)
+	if err != nil {
+		return result, fmt.Errorf("edgesync: reading peer clock: %w", err)
+	}
+	outgoing, err := r.Changes(ctx, remote)
+	if err != nil {
+		return result, err
+	}
+	if len(outgoing) > 0 {
+		if result.Pushed, err = peer.Apply(ctx, outgoing); err != nil {
+			return result, fmt.Errorf("edgesync: pushing changes: %w", err)
+		}
+	}
+	result.Duration = r.now().Sub(start)
+	return result, nil
+}
+
+// Run syncs with peer every interval until ctx is done. Failed syncs, such
+// as while offline, are logged and retried at the next interval.
+func (r *Replica) Run(ctx context.Context, peer Peer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if result, err := r.Sync(ctx, peer); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("⚠️  Sync of replica %s failed: %v", r.id, err)
+		} else if result.Pulled.Applied+result.Pushed.Applied > 0 {
+			log.Printf("🔄 Synced replica %s: %d pulled, %d pushed, %d conflicts",
+				r.id, result.Pulled.Applied, result.Pushed.Applied, result.Pulled.Conflicts+result.Pushed.Conflicts)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Save writes the replica state to the state file, if any.
+func (r *Replica) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.saveLocked()
+}
+
+func (r *Replica) saveLocked() error {
+	if r.stateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(r.state)
+	if err != nil {
+		return fmt.Errorf("edgesync: save state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.stateFile), 0o700); err != nil {
+		return fmt.Errorf("edgesync: save state: %w", err)
+	}
+	tmp := r.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("edgesync: save state: %w", err)
+	}
+	if err := os.Rename(tmp, r.stateFile); err != nil {
+		return fmt.Errorf("edgesync: save state: %w", err)
+	}
+	return nil
+}
+
+// sortChanges orders changes so they can be applied in turn: nodes, then
+// edges, then edge deletes, then node deletes.
+func sortChanges(changes []Change) {
+	rank := func(c Change) int {
+		switch {
+		case c.Kind == KindNode && !c.Deleted:
+			return 0
+		case c.Kind == KindEdge && !c.Deleted:
+			return 1
+		case c.Kind == KindEdge:
+			return 2
+		}
+		return 3
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if ri, rj := rank(changes[i]), rank(changes[j]); ri != rj {
+			return ri < rj
+		}
+		return changes[i].ID < changes[j].ID
+	})
+}
+
+func splitKey(key string) (kind, id string) {
+	if len(key) > len(KindNode) && key[:len(KindNode)+1] == KindNode+":" {
+		return KindNode, key[len(KindNode)+1:]
+	}
+	return KindEdge, key[len(KindEdge)+1:]
+}
+
+func ignoreNotFound(err error) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// nodeHash digests the synced content of a node: labels and properties.
+func nodeHash(node *storage.Node) string {
+	return changeHash(Change{Kind: KindNode, Labels: node.Labels, Properties: node.Properties})
+}
+
+// edgeHash digests the synced content of an edge.
+func edgeHash(edge *storage.Edge) string {
+	return changeHash(Change{
+		Kind:       KindEdge,
+		Type:       edge.Type,
+		StartNode:  string(edge.StartNode),
+		EndNode:    string(edge.EndNode),
+		Properties: edge.Properties,
+	})
+}
+
+// changeHash digests the content of a change, ignoring its version. JSON
+// encodes map keys sorted, so equal content gives equal digests.
+func changeHash(c Change) string {
+	if c.Deleted {
+		return "deleted"
+	}
+	labels := append([]string(nil), c.Labels...)
+	sort.Strings(labels)
+	data, _ := json.Marshal([]any{c.Kind, labels, c.Type, c.StartNode, c.EndNode, c.Properties})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
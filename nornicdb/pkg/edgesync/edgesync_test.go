@@ -0,0 +1,219 @@
+package edgesync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock returns increasing times, so LastWriterWins is deterministic.
+func clock(start time.Time) func() time.Time {
+	return func() time.Time {
+		start = start.Add(time.Second)
+		return start
+	}
+}
+
+func openReplica(t *testing.T, id string, opts Options) (*Replica, storage.Engine) {
+	t.Helper()
+	engine := storage.NewMemoryEngine()
+	opts.ReplicaID = id
+	r, err := Open(engine, opts)
+	require.NoError(t, err)
+	r.now = clock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	return r, engine
+}
+
+func node(id string, props map[string]any) *storage.Node {
+	return &storage.Node{ID: storage.NodeID(id), Labels: []string{"Note"}, Properties: props}
+}
+
+func getNode(t *testing.T, engine storage.Engine, id string) *storage.Node {
+	t.Helper()
+	n, err := engine.GetNode(storage.NodeID(id))
+	require.NoError(t, err)
+	return n
+}
+
+func TestVersionVector_Compare(t *testing.T) {
+	a := VersionVector{"x": 2, "y": 1}
+	assert.Equal(t, Equal, a.Compare(VersionVector{"x": 2, "y": 1}))
+	assert.Equal(t, Before, a.Compare(VersionVector{"x": 2, "y": 2}))
+	assert.Equal(t, Before, VersionVector{"x": 1}.Compare(VersionVector{"x": 1, "z": 1}))
+	assert.Equal(t, After, a.Compare(VersionVector{"x": 1}))
+	assert.Equal(t, Concurrent, a.Compare(VersionVector{"x": 1, "y": 2}))
+
+	merged := a.Clone()
+	merged.Merge(VersionVector{"x": 1, "z": 4})
+	assert.Equal(t, VersionVector{"x": 2, "y": 1, "z": 4}, merged)
+	assert.Equal(t, VersionVector{"x": 2, "y": 1}, a, "clone is independent")
+}
+
+func TestReplica_Sync(t *testing.T) {
+	ctx := context.Background()
+	central, centralDB := openReplica(t, "central", Options{})
+	edge, edgeDB := openReplica(t, "edge", Options{})
+
+	require.NoError(t, centralDB.CreateNode(node("a", map[string]any{"title": "from central", "n": int64(1)})))
+	require.NoError(t, edgeDB.CreateNode(node("b", map[string]any{"title": "written offline"})))
+	require.NoError(t, edgeDB.CreateNode(node("c", map[string]any{"title": "deleted later"})))
+	require.NoError(t, edgeDB.CreateEdge(&storage.Edge{ID: "e1", StartNode: "b", EndNode: "c", Type: "LINKS"}))
+
+	result, err := edge.Sync(ctx, central)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Pulled.Applied)
+	assert.Equal(t, 3, result.Pushed.Applied)
+	assert.Equal(t, "from central", getNode(t, edgeDB, "a").Properties["title"])
+	assert.Equal(t, "written offline", getNode(t, centralDB, "b").Properties["title"])
+	_, err = centralDB.GetEdge("e1")
+	require.NoError(t, err)
+
+	t.Run("nothing new", func(t *testing.T) {
+		result, err := edge.Sync(ctx, central)
+		require.NoError(t, err)
+		assert.Zero(t, result.Pulled.Applied)
+		assert.Zero(t, result.Pushed.Applied)
+	})
+
+	t.Run("updates and deletes", func(t *testing.T) {
+		a := getNode(t, centralDB, "a")
+		a.Properties = map[string]any{"title": "edited centrally"}
+		require.NoError(t, centralDB.UpdateNode(a))
+		require.NoError(t, edgeDB.DeleteEdge("e1"))
+		require.NoError(t, edgeDB.DeleteNode("c"))
+
+		result, err := edge.Sync(ctx, central)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Pulled.Applied)
+		assert.Equal(t, 2, result.Pushed.Applied)
+		assert.Equal(t, "edited centrally", getNode(t, edgeDB, "a").Properties["title"])
+		_, err = centralDB.GetNode("c")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = centralDB.GetEdge("e1")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		// Both sides edit b offline; the edge writes last and wins.
+		b := getNode(t, centralDB, "b")
+		b.Properties = map[string]any{"title": "central edit"}
+		require.NoError(t, centralDB.UpdateNode(b))
+		_, err := central.Refresh(ctx)
+		require.NoError(t, err)
+
+		b = getNode(t, edgeDB, "b")
+		b.Properties = map[string]any{"title": "edge edit"}
+		require.NoError(t, edgeDB.UpdateNode(b))
+		edge.now = clock(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+
+		result, err := edge.Sync(ctx, central)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Pulled.Conflicts)
+		assert.Equal(t, 1, result.Pushed.Applied, "the resolution is pushed")
+		assert.Equal(t, "edge edit", getNode(t, edgeDB, "b").Properties["title"])
+		assert.Equal(t, "edge edit", getNode(t, centralDB, "b").Properties["title"])
+
+		result, err = edge.Sync(ctx, central)
+		require.NoError(t, err)
+		assert.Zero(t, result.Pulled.Applied+result.Pushed.Applied, "converged")
+	})
+
+	t.Run("delete wins over older edit", func(t *testing.T) {
+		a := getNode(t, centralDB, "a")
+		a.Properties = map[string]any{"title": "edited before delete"}
+		require.NoError(t, centralDB.UpdateNode(a))
+		_, err := central.Refresh(ctx)
+		require.NoError(t, err)
+		require.NoError(t, edgeDB.DeleteNode("a"))
+
+		_, err = edge.Sync(ctx, central)
+		require.NoError(t, err)
+		_, err = centralDB.GetNode("a")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
+
+func TestReplica_CustomResolver(t *testing.T) {
+	ctx := context.Background()
+	// Keep the larger count, whoever wrote it.
+	maxCount := func(local, remote Change) Change {
+		merged := remote
+		merged.Properties = map[string]any{"count": max(local.Properties["count"].(int64), remote.Properties["count"].(int64))}
+		return merged
+	}
+	one, oneDB := openReplica(t, "one", Options{Resolver: maxCount})
+	two, twoDB := openReplica(t, "two", Options{Resolver: maxCount})
+
+	require.NoError(t, oneDB.CreateNode(node("counter", map[string]any{"count": int64(5)})))
+	_, err := one.Sync(ctx, two)
+	require.NoError(t, err)
+
+	n := getNode(t, oneDB, "counter")
+	n.Properties = map[string]any{"count": int64(9)}
+	require.NoError(t, oneDB.UpdateNode(n))
+	n = getNode(t, twoDB, "counter")
+	n.Properties = map[string]any{"count": int64(7)}
+	require.NoError(t, twoDB.UpdateNode(n))
+
+	_, err = two.Sync(ctx, one)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), getNode(t, oneDB, "counter").Properties["count"])
+	assert.Equal(t, int64(9), getNode(t, twoDB, "counter").Properties["count"])
+}
+
+func TestReplica_StateFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sync", "state.json")
+	engine := storage.NewMemoryEngine()
+	r, err := Open(engine, Options{ReplicaID: "edge", StateFile: path})
+	require.NoError(t, err)
+	require.NoError(t, engine.CreateNode(node("a", nil)))
+	writes, err := r.Refresh(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, writes)
+
+	reopened, err := Open(engine, Options{ReplicaID: "edge", StateFile: path})
+	require.NoError(t, err)
+	writes, err = reopened.Refresh(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, writes, "known writes are not counted again")
+	clock, err := reopened.Clock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, VersionVector{"edge": 1}, clock)
+
+	_, err = Open(engine, Options{ReplicaID: "other", StateFile: path})
+	assert.Error(t, err)
+	_, err = Open(engine, Options{})
+	assert.ErrorIs(t, err, ErrNoReplicaID)
+}
+
+func TestHTTPPeer(t *testing.T) {
+	ctx := context.Background()
+	central, centralDB := openReplica(t, "central", Options{})
+	edge, edgeDB := openReplica(t, "edge", Options{})
+	srv := httptest.NewServer(Handler(central))
+	defer srv.Close()
+
+	require.NoError(t, centralDB.CreateNode(node("a", map[string]any{"n": int64(3), "list": []any{int64(1), 2.5}})))
+	require.NoError(t, edgeDB.CreateNode(node("b", map[string]any{"n": int64(4)})))
+
+	result, err := edge.Sync(ctx, NewHTTPPeer(srv.URL+"/", ""))
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Pulled.Applied)
+	assert.Equal(t, 1, result.Pushed.Applied)
+	assert.Equal(t, int64(3), getNode(t, edgeDB, "a").Properties["n"], "integers stay int64")
+	assert.Equal(t, []any{int64(1), 2.5}, getNode(t, edgeDB, "a").Properties["list"])
+	assert.Equal(t, int64(4), getNode(t, centralDB, "b").Properties["n"])
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	_, err = NewHTTPPeer(missing.URL, "").Clock(ctx)
+	assert.ErrorContains(t, err, "404")
+}
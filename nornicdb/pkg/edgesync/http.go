@@ -0,0 +1,208 @@
+package edgesync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BasePath is where the HTTP server mounts Handler.
+const BasePath = "/nornicdb/sync/"
+
+// maxRequestBytes bounds the changes a peer can push in one request.
+const maxRequestBytes = 256 << 20
+
+type clockResponse struct {
+	Replica string        `json:"replica"`
+	Clock   VersionVector `json:"clock"`
+}
+
+type changesRequest struct {
+	Since VersionVector `json:"since"`
+}
+
+type changesBody struct {
+	Changes []Change `json:"changes"`
+}
+
+// Handler serves a replica to HTTPPeer clients:
+//
+//	GET  .../clock    the replica's clock
+//	POST .../changes  {"since": {...}} → {"changes": [...]}
+//	POST .../apply    {"changes": [...]} → ApplyResult
+//
+// Authentication is left to the caller, e.g. the server's auth middleware.
+func Handler(replica *Replica) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		switch {
+		case strings.HasSuffix(path, "/clock") && r.Method == http.MethodGet:
+			clock, err := replica.Clock(r.Context())
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeJSON(w, clockResponse{Replica: replica.ID(), Clock: clock})
+		case strings.HasSuffix(path, "/changes") && r.Method == http.MethodPost:
+			var req changesRequest
+			if err := decodeJSON(http.MaxBytesReader(w, r.Body, maxRequestBytes), &req); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			changes, err := replica.Changes(r.Context(), req.Since)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeJSON(w, changesBody{Changes: changes})
+		case strings.HasSuffix(path, "/apply") && r.Method == http.MethodPost:
+			var req changesBody
+			if err := decodeJSON(http.MaxBytesReader(w, r.Body, maxRequestBytes), &req); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			result, err := replica.Apply(r.Context(), req.Changes)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeJSON(w, result)
+		default:
+			writeError(w, http.StatusNotFound, fmt.Errorf("unknown sync endpoint %s %s", r.Method, r.URL.Path))
+		}
+	})
+}
+
+// HTTPPeer is a replica served by Handler on a remote NornicDB server.
+type HTTPPeer struct {
+	BaseURL string       // Server URL, e.g. "https://graph.example.com:7474"
+	Token   string       // Bearer token ("" = none)
+	Client  *http.Client // nil = a client with a one-minute timeout
+}
+
+// NewHTTPPeer returns the peer served at baseURL.
+func NewHTTPPeer(baseURL, token string) *HTTPPeer {
+	return &HTTPPeer{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Token:   token,
+		Client:  &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Clock implements Peer.
+func (p *HTTPPeer) Clock(ctx context.Context) (VersionVector, error) {
+	var resp clockResponse
+	if err := p.call(ctx, http.MethodGet, "clock", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Clock, nil
+}
+
+// Changes implements Peer.
+func (p *HTTPPeer) Changes(ctx context.Context, since VersionVector) ([]Change, error) {
+	var resp changesBody
+	if err := p.call(ctx, http.MethodPost, "changes", changesRequest{Since: since}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Changes, nil
+}
+
+// Apply implements Peer.
+func (p *HTTPPeer) Apply(ctx context.Context, changes []Change) (ApplyResult, error) {
+	var result ApplyResult
+	err := p.call(ctx, http.MethodPost, "apply", changesBody{Changes: changes}, &result)
+	return result, err
+}
+
+func (p *HTTPPeer) call(ctx context.Context, method, endpoint string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.BaseURL+BasePath+endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("sync %s: %s: %s", endpoint, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return decodeJSON(resp.Body, out)
+}
+
+// decodeJSON decodes v keeping integer properties int64, as stored, rather
+// than float64.
+func decodeJSON(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	var changes []Change
+	switch body := v.(type) {
+	case *changesBody:
+		changes = body.Changes
+	default:
+		return nil
+	}
+	for i := range changes {
+		for k, value := range changes[i].Properties {
+			changes[i].Properties[k] = fromJSONNumber(value)
+		}
+	}
+	return nil
+}
+
+// fromJSONNumber converts the json.Numbers in v to int64 or float64.
+func fromJSONNumber(v any) any {
+	switch x := v.(type) {
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	case []any:
+		for i := range x {
+			x[i] = fromJSONNumber(x[i])
+		}
+	case map[string]any:
+		for k := range x {
+			x[k] = fromJSONNumber(x[k])
+		}
+	}
+	return v
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // mockEmbedder tracks calls for testing
@@ -173,6 +174,90 @@ func TestCachedEmbedder_Concurrent(t *testing.T) {
 	}
 }
 
+func TestCachedEmbedder_TTL(t *testing.T) {
+	mock := &mockEmbedder{}
+	cached := NewCachedEmbedderWithConfig(mock, CacheConfig{TTL: time.Minute})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cached.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, _ = cached.Embed(ctx, "hello")
+	now = now.Add(30 * time.Second)
+	_, _ = cached.Embed(ctx, "hello")
+	if mock.CallCount() != 1 {
+		t.Errorf("Expected a hit within the TTL, got %d calls", mock.CallCount())
+	}
+
+	now = now.Add(time.Minute)
+	_, _ = cached.Embed(ctx, "hello")
+	if mock.CallCount() != 2 {
+		t.Errorf("Expected the expired entry to be embedded again, got %d calls", mock.CallCount())
+	}
+	if stats := cached.Stats(); stats.Expired != 1 || stats.Size != 1 {
+		t.Errorf("Expected 1 expired entry and 1 cached, got %+v", stats)
+	}
+}
+
+func TestCachedEmbedder_Disk(t *testing.T) {
+	dir := t.TempDir()
+	mock := &mockEmbedder{}
+	ctx := context.Background()
+
+	first := NewCachedEmbedderWithConfig(mock, CacheConfig{Dir: dir})
+	want, _ := first.Embed(ctx, "persisted")
+	_, _ = first.EmbedBatch(ctx, []string{"batched"})
+
+	// A new cache (after a restart) serves both from disk
+	second := NewCachedEmbedderWithConfig(mock, CacheConfig{Dir: dir, TTL: time.Hour})
+	got, err := second.Embed(ctx, "persisted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := second.EmbedBatch(ctx, []string{"batched", "fresh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mock.CallCount() != 3 {
+		t.Errorf("Expected only the new text to be embedded, got %d calls", mock.CallCount())
+	}
+	if len(got) != len(want) || got[0] != want[0] || results[0][0] != float32(len("batched")) {
+		t.Errorf("Disk cache returned %v and %v", got, results)
+	}
+	if stats := second.Stats(); stats.DiskHits != 2 || stats.Hits != 2 {
+		t.Errorf("Expected 2 disk hits, got %+v", stats)
+	}
+
+	// Expired disk entries are pruned
+	second.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	removed, err := second.PruneDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("Expected 3 expired files removed, got %d", removed)
+	}
+}
+
+type namedEmbedder struct {
+	mockEmbedder
+	model string
+}
+
+func (n *namedEmbedder) Model() string { return n.model }
+
+func TestCachedEmbedder_KeyedByModel(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	a := &namedEmbedder{model: "a"}
+	_, _ = NewCachedEmbedderWithConfig(a, CacheConfig{Dir: dir}).Embed(ctx, "text")
+
+	b := &namedEmbedder{model: "b"}
+	_, _ = NewCachedEmbedderWithConfig(b, CacheConfig{Dir: dir}).Embed(ctx, "text")
+	if b.CallCount() != 1 {
+		t.Error("Expected a different model not to reuse cached vectors")
+	}
+}
+
 func BenchmarkCachedEmbedder_CacheHit(b *testing.B) {
 	mock := &mockEmbedder{}
 	cached := NewCachedEmbedder(mock, 1000)
@@ -0,0 +1,322 @@
+// Package federation maps virtual labels to external data sources.
+//
+// A virtual label is a node label whose nodes are not stored in the graph
+// but read from an external source when a query matches it: a SQL table
+// through database/sql, or a REST endpoint returning JSON. Cypher can then
+// join native graph data with external reference data:
+//
+//	MATCH (c:Customer) MATCH (k:Country) WHERE k.code = c.country RETURN c.name, k.name
+//
+// Simple predicates on the virtual node (inline properties and equality
+// comparisons with literals) are pushed down to the source as a SQL WHERE
+// clause or as query parameters, so only matching rows are fetched. The
+// query still applies every predicate to the rows returned, so a source
+// that ignores a pushed-down filter returns too many rows, never wrong
+// ones.
+//
+// Virtual nodes are read-only, have no relationships, and carry the IDs
+// "Label:key", where key is the value of the label's key column. Fetched
+// rows are cached per label and filter for the label's cache TTL.
+//
+// Example:
+//
+//	registry := federation.NewRegistry()
+//	registry.Register(federation.Label{
+//		Name:     "Country",
+//		Source:   &federation.SQLSource{DB: sqlDB, Table: "countries"},
+//		Key:      "code",
+//		CacheTTL: time.Minute,
+//	})
+//	executor.SetVirtualLabels(registry)
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// Default limits for labels loaded from a configuration file.
+const (
+	DefaultLimit    = 10000
+	DefaultTimeout  = 10 * time.Second
+	DefaultCacheTTL = 30 * time.Second
+)
+
+// Source is an external source of rows for a virtual label.
+type Source interface {
+	// Fetch returns at most limit rows whose columns equal filters. A
+	// source may ignore filters it cannot push down.
+	Fetch(ctx context.Context, filters map[string]any, limit int) ([]map[string]any, error)
+	// Kind names the source type, e.g. "sql" or "rest".
+	Kind() string
+}
+
+// Label maps a virtual label to its source.
+type Label struct {
+	Name   string
+	Source Source
+	// Key is the column identifying rows (default "id").
+	Key string
+	// Limit caps the rows fetched per query (0 = DefaultLimit).
+	Limit int
+	// Timeout bounds each fetch (0 = DefaultTimeout).
+	Timeout time.Duration
+	// CacheTTL is how long fetched rows are reused (0 = not cached).
+	CacheTTL time.Duration
+}
+
+// Registry holds the virtual labels. It is safe for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	labels map[string]*virtualLabel // by lowercase name
+}
+
+type virtualLabel struct {
+	Label
+
+	mu    sync.Mutex
+	cache map[string]cachedRows // by filter key
+}
+
+type cachedRows struct {
+	nodes   []*storage.Node
+	expires time.Time
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{labels: make(map[string]*virtualLabel)}
+}
+
+// Register adds or replaces a virtual label.
+func (r *Registry) Register(l Label) error {
+	if l.Name == "" || l.Source == nil {
+		return fmt.Errorf("virtual label needs a name and a source")
+	}
+	if l.Key == "" {
+		l.Key = "id"
+	}
+	if l.Limit <= 0 {
+		l.Limit = DefaultLimit
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultTimeout
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.labels[strings.ToLower(l.Name)] = &virtualLabel{Label: l, cache: make(map[string]cachedRows)}
+	return nil
+}
+
+// Unregister removes a virtual label, reporting whether it existed.
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := strings.ToLower(name)
+	_, ok := r.labels[key]
+	delete(r.labels, key)
+	return ok
+}
+
+// Labels returns the virtual labels sorted by name.
+func (r *Registry) Labels() []Label {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	labels := make([]Label, 0, len(r.labels))
+	for _, vl := range r.labels {
+		labels = append(labels, vl.Label)
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels
+}
+
+// IsVirtualLabel reports whether label is virtual. Labels match
+// case-insensitively.
+func (r *Registry) IsVirtualLabel(label string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.labels[strings.ToLower(label)]
+	return ok
+}
+
+// ResolveLabel returns the nodes of a virtual label whose properties equal
+// filters, fetching them from its source unless cached. ok is false if the
+// label is not virtual.
+func (r *Registry) ResolveLabel(ctx context.Context, label string, filters map[string]any) ([]*storage.Node, bool, error) {
+	if r == nil {
+		return nil, false, nil
+	}
+	r.mu.RLock()
+	vl, ok := r.labels[strings.ToLower(label)]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	nodes, err := vl.resolve(ctx, filters)
+	if err != nil {
+		return nil, true, fmt.Errorf("virtual label %s (%s source): %w", vl.Name, vl.Source.Kind(), err)
+	}
+	return nodes, true, nil
+}
+
+func (vl *virtualLabel) resolve(ctx context.Context, filters map[string]any) ([]*storage.Node, error) {
+	cacheKey := filterKey(filters)
+	if vl.CacheTTL > 0 {
+		vl.mu.Lock()
+		cached, ok := vl.cache[cacheKey]
+		vl.mu.Unlock()
+		if ok && time.Now().Before(cached.expires) {
+			return copyNodes(cached.nodes), nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, vl.Timeout)
+	defer cancel()
+	rows, err := vl.Source.Fetch(ctx, filters, vl.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > vl.Limit {
+		rows = rows[:vl.Limit]
+	}
+
+	nodes := make([]*storage.Node, len(rows))
+	for i, row := range rows {
+		id := fmt.Sprintf("%s:%d", vl.Name, i)
+		if key, ok := row[vl.Key]; ok && key != nil {
+			id = fmt.Sprintf("%s:%v", vl.Name, key)
+		}
+		nodes[i] = &storage.Node{ID: storage.NodeID(id), Labels: []string{vl.Name}, Properties: row}
+	}
+
+	if vl.CacheTTL > 0 {
+		vl.mu.Lock()
+		for k, c := range vl.cache {
+			if time.Now().After(c.expires) {
+				delete(vl.cache, k)
+			}
+		}
+		vl.cache[cacheKey] = cachedRows{nodes: copyNodes(nodes), expires: time.Now().Add(vl.CacheTTL)}
+		vl.mu.Unlock()
+	}
+	return nodes, nil
+}
+
+// copyNodes copies nodes and their property maps, so queries cannot modify
+// cached rows.
+func copyNodes(nodes []*storage.Node) []*storage.Node {
+	out := make([]*storage.Node, len(nodes))
+	for i, n := range nodes {
+		props := make(map[string]any, len(n.Properties))
+		for k, v := range n.Properties {
+			props[k] = v
+		}
+		out[i] = &storage.Node{ID: n.ID, Labels: append([]string(nil), n.Labels...), Properties: props}
+	}
+	return out
+}
+
+// filterKey returns a canonical key for a set of filters.
+func filterKey(filters map[string]any) string {
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%T:%v;", name, filters[name], filters[name])
+	}
+	return b.String()
+}
+
+// Config is the JSON configuration of virtual labels, keyed by label name:
+//
+//	{"labels": {
+//	  "Country": {"sql": {"driver": "postgres", "dsn": "${COUNTRIES_DSN}", "table": "countries", "placeholder": "$"}, "key": "code"},
+//	  "Station": {"rest": {"url": "https://api.example.com/stations", "results": "data"}, "cacheTTL": "5m"}
+//	}}
+//
+// Environment variables in DSNs, URLs and headers are expanded.
+type Config struct {
+	Labels map[string]LabelConfig `json:"labels"`
+}
+
+// LabelConfig configures one virtual label. Exactly one of SQL and REST is set.
+type LabelConfig struct {
+	SQL      *SQLConfig  `json:"sql,omitempty"`
+	REST     *RESTConfig `json:"rest,omitempty"`
+	Key      string      `json:"key,omitempty"`
+	Limit    int         `json:"limit,omitempty"`
+	Timeout  string      `json:"timeout,omitempty"`  // default 10s
+	CacheTTL string      `json:"cacheTTL,omitempty"` // default 30s; "0s" disables caching
+}
+
+// Load registers the virtual labels of a JSON configuration. Labels are
+// registered only if all of them are valid.
+func (r *Registry) Load(data []byte) error {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("invalid federation config: %w", err)
+	}
+	labels := make([]Label, 0, len(cfg.Labels))
+	for name, lc := range cfg.Labels {
+		l, err := lc.label(name)
+		if err != nil {
+			return fmt.Errorf("virtual label %s: %w", name, err)
+		}
+		labels = append(labels, l)
+	}
+	for _, l := range labels {
+		if err := r.Register(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFile registers the virtual labels configured in a JSON file.
+func (r *Registry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading federation config: %w", err)
+	}
+	return r.Load(data)
+}
+
+func (lc LabelConfig) label(name string) (Label, error) {
+	l := Label{Name: name, Key: lc.Key, Limit: lc.Limit, CacheTTL: DefaultCacheTTL}
+	var err error
+	if lc.Timeout != "" {
+		if l.Timeout, err = time.ParseDuration(lc.Timeout); err != nil {
+			return l, fmt.Errorf("invalid timeout: %w", err)
+		}
+	}
+	if lc.CacheTTL != "" {
+		if l.CacheTTL, err = time.ParseDuration(lc.CacheTTL); err != nil {
+			return l, fmt.Errorf("invalid cacheTTL: %w", err)
+		}
+	}
+	switch {
+	case lc.SQL != nil && lc.REST != nil:
+		return l, fmt.Errorf("set either sql or rest, not both")
+	case lc.SQL != nil:
+		l.Source, err = lc.SQL.open()
+	case lc.REST != nil:
+		l.Source, err = lc.REST.source()
+	default:
+		err = fmt.Errorf("needs an sql or rest source")
+	}
+	return l, err
+}
@@ -0,0 +1,297 @@
+package federation
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource returns fixed rows filtered by equality, counting fetches.
+type fakeSource struct {
+	mu      sync.Mutex
+	rows    []map[string]any
+	fetches int
+	err     error
+}
+
+func (s *fakeSource) Kind() string { return "fake" }
+
+func (s *fakeSource) Fetch(ctx context.Context, filters map[string]any, limit int) ([]map[string]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+	if s.err != nil {
+		return nil, s.err
+	}
+	var rows []map[string]any
+	for _, row := range s.rows {
+		match := true
+		for k, v := range filters {
+			if row[k] != v {
+				match = false
+			}
+		}
+		if match {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func TestRegistry_ResolveLabel(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{rows: []map[string]any{
+		{"code": "NO", "name": "Norway"},
+		{"code": "SE", "name": "Sweden"},
+	}}
+	r := NewRegistry()
+	require.NoError(t, r.Register(Label{Name: "Country", Source: src, Key: "code"}))
+
+	assert.True(t, r.IsVirtualLabel("country"))
+	assert.False(t, r.IsVirtualLabel("Person"))
+
+	nodes, ok, err := r.ResolveLabel(ctx, "Country", map[string]any{"code": "NO"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, storage.NodeID("Country:NO"), nodes[0].ID)
+	assert.Equal(t, []string{"Country"}, nodes[0].Labels)
+	assert.Equal(t, "Norway", nodes[0].Properties["name"])
+
+	_, ok, err = r.ResolveLabel(ctx, "Person", nil)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	src.err = errors.New("connection refused")
+	_, ok, err = r.ResolveLabel(ctx, "Country", nil)
+	assert.True(t, ok)
+	assert.ErrorContains(t, err, "virtual label Country (fake source): connection refused")
+
+	assert.True(t, r.Unregister("COUNTRY"))
+	assert.False(t, r.IsVirtualLabel("Country"))
+	assert.Empty(t, r.Labels())
+}
+
+func TestRegistry_Limit(t *testing.T) {
+	src := &fakeSource{rows: []map[string]any{{"id": 1}, {"id": 2}, {"id": 3}}}
+	r := NewRegistry()
+	require.NoError(t, r.Register(Label{Name: "Thing", Source: src, Limit: 2}))
+
+	nodes, _, err := r.ResolveLabel(context.Background(), "Thing", nil)
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2)
+}
+
+func TestRegistry_Cache(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{rows: []map[string]any{{"id": "a", "v": 1}}}
+	r := NewRegistry()
+	require.NoError(t, r.Register(Label{Name: "Thing", Source: src, CacheTTL: 50 * time.Millisecond}))
+
+	nodes, _, err := r.ResolveLabel(ctx, "Thing", nil)
+	require.NoError(t, err)
+	nodes[0].Properties["v"] = 2 // must not leak into the cache
+
+	nodes, _, err = r.ResolveLabel(ctx, "Thing", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, nodes[0].Properties["v"])
+	assert.Equal(t, 1, src.fetches)
+
+	_, _, err = r.ResolveLabel(ctx, "Thing", map[string]any{"id": "a"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, src.fetches, "filters are cached separately")
+
+	time.Sleep(60 * time.Millisecond)
+	_, _, err = r.ResolveLabel(ctx, "Thing", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, src.fetches)
+}
+
+func TestSQLSource_Query(t *testing.T) {
+	s := &SQLSource{Table: "public.countries", Columns: []string{"code", "name"}}
+	query, args, err := s.Query(map[string]any{"name": "Norway", "code": "NO", "bogus": 1, "x; DROP": 2}, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT code, name FROM public.countries WHERE code = ? AND name = ? LIMIT 100", query)
+	assert.Equal(t, []any{"NO", "Norway"}, args)
+
+	s = &SQLSource{Table: "countries", Placeholder: "$"}
+	query, args, err = s.Query(map[string]any{"region": "Europe", "code": "NO"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM countries WHERE code = $1 AND region = $2", query)
+	assert.Equal(t, []any{"NO", "Europe"}, args)
+
+	_, _, err = (&SQLSource{Table: "countries; DROP TABLE x"}).Query(nil, 0)
+	assert.Error(t, err)
+	_, _, err = (&SQLSource{Table: "countries", Columns: []string{"name)"}}).Query(nil, 0)
+	assert.Error(t, err)
+}
+
+// fakeDriver is a database/sql driver serving fakeRows for any query and
+// recording the last query and arguments.
+type fakeDriver struct {
+	mu    sync.Mutex
+	query string
+	args  []driver.Value
+}
+
+var testDriver = &fakeDriver{}
+
+func init() { sql.Register("federation-test", testDriver) }
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.d, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	s.d.query, s.d.args = s.query, args
+	s.d.mu.Unlock()
+	return &fakeRows{rows: [][]driver.Value{
+		{[]byte("NO"), "Norway", int64(5_500_000), nil},
+	}}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+	i    int
+}
+
+func (r *fakeRows) Columns() []string { return []string{"code", "name", "population", "motto"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.i])
+	r.i++
+	return nil
+}
+
+func TestSQLSource_Fetch(t *testing.T) {
+	db, err := sql.Open("federation-test", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	s := &SQLSource{DB: db, Table: "countries"}
+	rows, err := s.Fetch(context.Background(), map[string]any{"code": "NO"}, 10)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, map[string]any{"code": "NO", "name": "Norway", "population": int64(5_500_000)}, rows[0])
+
+	testDriver.mu.Lock()
+	defer testDriver.mu.Unlock()
+	assert.Equal(t, "SELECT * FROM countries WHERE code = ? LIMIT 10", testDriver.query)
+	assert.Equal(t, []driver.Value{"NO"}, testDriver.args)
+}
+
+func TestRESTSource_Fetch(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data": {"items": [{"id": "osl", "capacity": 12, "lat": 59.9, "closed": null}]}}`))
+	}))
+	defer srv.Close()
+
+	s := &RESTSource{
+		URL:        srv.URL + "/stations?format=json",
+		Results:    "data.items",
+		LimitParam: "limit",
+		Headers:    map[string]string{"Authorization": "Bearer secret"},
+	}
+	rows, err := s.Fetch(context.Background(), map[string]any{"country": "NO", "active": true}, 50)
+	require.NoError(t, err)
+	assert.Equal(t, "active=true&country=NO&format=json&limit=50", query)
+	require.Len(t, rows, 1)
+	assert.Equal(t, map[string]any{"id": "osl", "capacity": int64(12), "lat": 59.9}, rows[0])
+
+	s.Headers = nil
+	_, err = s.Fetch(context.Background(), nil, 0)
+	assert.ErrorContains(t, err, "401")
+
+	s = &RESTSource{URL: srv.URL, Results: "missing"}
+	_, err = s.Fetch(context.Background(), nil, 0)
+	assert.Error(t, err)
+}
+
+func TestRegistry_Load(t *testing.T) {
+	t.Setenv("STATIONS_URL", "https://api.example.com/stations")
+	r := NewRegistry()
+	err := r.Load([]byte(`{"labels": {
+		"Country": {"sql": {"driver": "federation-test", "dsn": "x", "table": "countries"}, "key": "code", "cacheTTL": "0s"},
+		"Station": {"rest": {"url": "${STATIONS_URL}", "results": "data"}, "timeout": "2s", "limit": 5}
+	}}`))
+	require.NoError(t, err)
+
+	labels := r.Labels()
+	require.Len(t, labels, 2)
+	assert.Equal(t, "Country", labels[0].Name)
+	assert.Equal(t, "code", labels[0].Key)
+	assert.Equal(t, time.Duration(0), labels[0].CacheTTL)
+	assert.Equal(t, "sql", labels[0].Source.Kind())
+
+	assert.Equal(t, "Station", labels[1].Name)
+	assert.Equal(t, "id", labels[1].Key)
+	assert.Equal(t, 5, labels[1].Limit)
+	assert.Equal(t, 2*time.Second, labels[1].Timeout)
+	assert.Equal(t, DefaultCacheTTL, labels[1].CacheTTL)
+	assert.Equal(t, "https://api.example.com/stations", labels[1].Source.(*RESTSource).URL)
+
+	for name, config := range map[string]string{
+		"not json":        `{`,
+		"no source":       `{"labels": {"X": {}}}`,
+		"both sources":    `{"labels": {"X": {"sql": {"driver": "federation-test", "table": "t"}, "rest": {"url": "http://x"}}}}`,
+		"bad table":       `{"labels": {"X": {"sql": {"driver": "federation-test", "table": "t; --"}}}}`,
+		"bad driver":      `{"labels": {"X": {"sql": {"driver": "nope", "table": "t"}}}}`,
+		"bad url":         `{"labels": {"X": {"rest": {"url": "file:///etc/passwd"}}}}`,
+		"bad ttl":         `{"labels": {"X": {"rest": {"url": "http://x"}, "cacheTTL": "soon"}}}`,
+		"bad placeholder": `{"labels": {"X": {"sql": {"driver": "federation-test", "table": "t", "placeholder": ":"}}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := NewRegistry()
+			assert.Error(t, r.Load([]byte(config)))
+			assert.Empty(t, r.Labels())
+		})
+	}
+}
+
+func TestRegistry_LoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "federation.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"labels": {"Station": {"rest": {"url": "http://localhost/stations"}}}}`), 0o600))
+
+	r := NewRegistry()
+	require.NoError(t, r.LoadFile(path))
+	assert.True(t, r.IsVirtualLabel("Station"))
+
+	assert.Error(t, r.LoadFile(filepath.Join(t.TempDir(), "missing.json")))
+}
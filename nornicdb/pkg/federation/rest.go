@@ -0,0 +1,163 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxRESTResponse caps the size of a REST response body.
+const maxRESTResponse = 64 << 20
+
+// RESTSource reads rows from an HTTP endpoint returning JSON. Filters are
+// sent as query parameters, e.g. GET /stations?country=NO.
+type RESTSource struct {
+	URL string
+	// Results is the dot-separated path of the row array in the response,
+	// e.g. "data.items" (empty = the response is the array).
+	Results string
+	// LimitParam names a query parameter carrying the row limit (optional).
+	LimitParam string
+	Headers    map[string]string
+	Client     *http.Client // nil = http.DefaultClient
+}
+
+// Kind returns "rest".
+func (s *RESTSource) Kind() string { return "rest" }
+
+// Request returns the URL Fetch requests.
+func (s *RESTSource) Request(filters map[string]any, limit int) (string, error) {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		q.Set(name, fmt.Sprint(filters[name]))
+	}
+	if s.LimitParam != "" && limit > 0 {
+		q.Set(s.LimitParam, strconv.Itoa(limit))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Fetch requests the endpoint and returns the objects of the row array.
+func (s *RESTSource) Fetch(ctx context.Context, filters map[string]any, limit int) ([]map[string]any, error) {
+	target, err := s.Request(filters, limit)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+
+	d := json.NewDecoder(io.LimitReader(resp.Body, maxRESTResponse))
+	d.UseNumber()
+	var body any
+	if err := d.Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	if s.Results != "" {
+		for _, field := range strings.Split(s.Results, ".") {
+			obj, ok := body.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("response has no %q", s.Results)
+			}
+			body = obj[field]
+		}
+	}
+	items, ok := body.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a JSON array of rows, got %T", body)
+	}
+
+	rows := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		for k, v := range obj {
+			if v == nil {
+				delete(obj, k)
+			} else {
+				obj[k] = jsonValue(v)
+			}
+		}
+		rows = append(rows, obj)
+	}
+	return rows, nil
+}
+
+// jsonValue converts the json.Numbers in a decoded value to int64 or float64.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+	case map[string]any:
+		for k, e := range v {
+			v[k] = jsonValue(e)
+		}
+	}
+	return v
+}
+
+// RESTConfig configures a REST source.
+type RESTConfig struct {
+	URL        string            `json:"url"`
+	Results    string            `json:"results,omitempty"`
+	LimitParam string            `json:"limitParam,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+func (c *RESTConfig) source() (*RESTSource, error) {
+	s := &RESTSource{URL: os.ExpandEnv(c.URL), Results: c.Results, LimitParam: c.LimitParam}
+	if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("url must be an http or https URL")
+	}
+	if len(c.Headers) > 0 {
+		s.Headers = make(map[string]string, len(c.Headers))
+		for k, v := range c.Headers {
+			s.Headers[k] = os.ExpandEnv(v)
+		}
+	}
+	return s, nil
+}
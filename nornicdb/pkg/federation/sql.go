@@ -0,0 +1,167 @@
+package federation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// identifier matches the column and table names a SQLSource puts in
+// queries; other names are never interpolated.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// SQLSource reads rows from a table through database/sql. Filters on
+// columns become a WHERE clause of equality comparisons with bound
+// parameters.
+type SQLSource struct {
+	DB    *sql.DB
+	Table string
+	// Columns selected and filterable (empty = all columns, any filter).
+	Columns []string
+	// Placeholder is the parameter style: "?" (default; MySQL, SQLite) or
+	// "$" for $1, $2, ... (PostgreSQL).
+	Placeholder string
+}
+
+// Kind returns "sql".
+func (s *SQLSource) Kind() string { return "sql" }
+
+// Query returns the statement and arguments Fetch runs.
+func (s *SQLSource) Query(filters map[string]any, limit int) (string, []any, error) {
+	if !identifier.MatchString(s.Table) {
+		return "", nil, fmt.Errorf("invalid table name %q", s.Table)
+	}
+	columns := "*"
+	if len(s.Columns) > 0 {
+		for _, c := range s.Columns {
+			if !identifier.MatchString(c) {
+				return "", nil, fmt.Errorf("invalid column name %q", c)
+			}
+		}
+		columns = strings.Join(s.Columns, ", ")
+	}
+
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		if identifier.MatchString(name) && !strings.Contains(name, ".") &&
+			(len(s.Columns) == 0 || containsFold(s.Columns, name)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT %s FROM %s", columns, s.Table)
+	args := make([]any, 0, len(names))
+	for i, name := range names {
+		if i == 0 {
+			b.WriteString(" WHERE ")
+		} else {
+			b.WriteString(" AND ")
+		}
+		args = append(args, filters[name])
+		if s.Placeholder == "$" {
+			fmt.Fprintf(&b, "%s = $%d", name, len(args))
+		} else {
+			fmt.Fprintf(&b, "%s = ?", name)
+		}
+	}
+	if limit > 0 {
+		fmt.Fprintf(&b, " LIMIT %d", limit)
+	}
+	return b.String(), args, nil
+}
+
+// Fetch runs the query and returns its rows keyed by column name.
+func (s *SQLSource) Fetch(ctx context.Context, filters map[string]any, limit int) ([]map[string]any, error) {
+	query, args, err := s.Query(filters, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]any
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(columns))
+		for i, c := range columns {
+			if v := sqlValue(values[i]); v != nil {
+				row[c] = v
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// sqlValue converts a scanned value to a property value.
+func sqlValue(v any) any {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case time.Time:
+		return v
+	}
+	return v
+}
+
+func containsFold(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// SQLConfig configures a SQL source. The driver must be registered with
+// database/sql by the binary.
+type SQLConfig struct {
+	Driver      string   `json:"driver"`
+	DSN         string   `json:"dsn"`
+	Table       string   `json:"table"`
+	Columns     []string `json:"columns,omitempty"`
+	Placeholder string   `json:"placeholder,omitempty"`
+}
+
+func (c *SQLConfig) open() (*SQLSource, error) {
+	if c.Placeholder != "" && c.Placeholder != "?" && c.Placeholder != "$" {
+		return nil, fmt.Errorf(`placeholder must be "?" or "$"`)
+	}
+	db, err := sql.Open(c.Driver, os.ExpandEnv(c.DSN))
+	if err != nil {
+		return nil, err
+	}
+	s := &SQLSource{DB: db, Table: c.Table, Columns: c.Columns, Placeholder: c.Placeholder}
+	if _, _, err := s.Query(nil, 0); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
@@ -6,11 +6,7 @@ import (
 	"fmt"
 	"runtime"
 	"sync"
-
-	"github.com/orneryd/nornicdb/pkg/gpu/cuda"
-	"github.com/orneryd/nornicdb/pkg/gpu/metal"
-	"github.com/orneryd/nornicdb/pkg/gpu/opencl"
-	"github.com/orneryd/nornicdb/pkg/gpu/vulkan"
+	"sync/atomic"
 )
 
 // Accelerator provides GPU-accelerated vector operations.
@@ -33,18 +29,8 @@ import (
 type Accelerator struct {
 	backend Backend
 	config  *Config
-
-	// Metal-specific (macOS)
-	metalDevice *metal.Device
-
-	// CUDA-specific (NVIDIA)
-	cudaDevice *cuda.Device
-
-	// OpenCL-specific (AMD, Intel, cross-platform)
-	openclDevice *opencl.Device
-
-	// Vulkan-specific (cross-platform compute)
-	vulkanDevice *vulkan.Device
+	device  Device         // nil in CPU-only mode
+	memory  *MemoryManager // Wraps device; nil in CPU-only mode
 
 	// Stats
 	mu    sync.RWMutex
@@ -124,101 +110,22 @@ func (a *Accelerator) initBackend(preferred Backend) error {
 
 // tryBackend attempts to initialize a specific backend.
 func (a *Accelerator) tryBackend(backend Backend) error {
-	switch backend {
-	case BackendMetal:
-		return a.initMetal()
-	case BackendOpenCL:
-		return a.initOpenCL()
-	case BackendCUDA:
-		return a.initCUDA()
-	case BackendVulkan:
-		return a.initVulkan()
-	default:
-		return ErrGPUNotAvailable
-	}
-}
-
-// initMetal initializes the Metal backend (macOS only).
-func (a *Accelerator) initMetal() error {
-	if !metal.IsAvailable() {
-		return ErrGPUNotAvailable
-	}
-
-	device, err := metal.NewDevice()
-	if err != nil {
-		return err
-	}
-
-	a.metalDevice = device
-	a.backend = BackendMetal
-	return nil
-}
-
-// initOpenCL initializes the OpenCL backend.
-func (a *Accelerator) initOpenCL() error {
-	if !opencl.IsAvailable() {
-		return ErrGPUNotAvailable
-	}
-
-	device, err := opencl.NewDevice(0)
-	if err != nil {
-		return err
-	}
-
-	a.openclDevice = device
-	a.backend = BackendOpenCL
-	return nil
-}
-
-// initCUDA initializes the CUDA backend.
-func (a *Accelerator) initCUDA() error {
-	if !cuda.IsAvailable() {
-		return ErrGPUNotAvailable
-	}
-
-	device, err := cuda.NewDevice(0)
+	device, err := OpenDevice(backend, 0)
 	if err != nil {
 		return err
 	}
-
-	a.cudaDevice = device
-	a.backend = BackendCUDA
-	return nil
-}
-
-// initVulkan initializes the Vulkan compute backend.
-func (a *Accelerator) initVulkan() error {
-	if !vulkan.IsAvailable() {
-		return ErrGPUNotAvailable
-	}
-
-	device, err := vulkan.NewDevice(0)
-	if err != nil {
-		return err
-	}
-
-	a.vulkanDevice = device
-	a.backend = BackendVulkan
+	a.memory = NewMemoryManager(device, uint64(a.config.MaxMemoryMB)*1024*1024)
+	a.device = a.memory
+	a.backend = backend
 	return nil
 }
 
 // Release frees all GPU resources.
 func (a *Accelerator) Release() {
-	if a.metalDevice != nil {
-		a.metalDevice.Release()
-		a.metalDevice = nil
-	}
-	if a.cudaDevice != nil {
-		a.cudaDevice.Release()
-		a.cudaDevice = nil
-	}
-	if a.openclDevice != nil {
-		a.openclDevice.Release()
-		a.openclDevice = nil
-	}
-	if a.vulkanDevice != nil {
-		a.vulkanDevice.Release()
-		a.vulkanDevice = nil
+	if a.device != nil {
+		a.device.Release()
+		a.device = nil
+		a.memory = nil
 	}
 	a.backend = BackendNone
 }
@@ -233,48 +140,29 @@ func (a *Accelerator) Backend() Backend {
 	return a.backend
 }
 
+// Device returns the GPU device, or nil in CPU-only mode.
+func (a *Accelerator) Device() Device {
+	return a.device
+}
+
+// Memory returns the manager tracking the device's buffers, or nil in
+// CPU-only mode.
+func (a *Accelerator) Memory() *MemoryManager {
+	return a.memory
+}
+
 // DeviceName returns the GPU device name.
 func (a *Accelerator) DeviceName() string {
-	switch a.backend {
-	case BackendMetal:
-		if a.metalDevice != nil {
-			return a.metalDevice.Name()
-		}
-	case BackendCUDA:
-		if a.cudaDevice != nil {
-			return a.cudaDevice.Name()
-		}
-	case BackendOpenCL:
-		if a.openclDevice != nil {
-			return a.openclDevice.Name()
-		}
-	case BackendVulkan:
-		if a.vulkanDevice != nil {
-			return a.vulkanDevice.Name()
-		}
+	if a.device != nil {
+		return a.device.Name()
 	}
 	return "CPU"
 }
 
 // DeviceMemoryMB returns the GPU memory in megabytes.
 func (a *Accelerator) DeviceMemoryMB() int {
-	switch a.backend {
-	case BackendMetal:
-		if a.metalDevice != nil {
-			return a.metalDevice.MemoryMB()
-		}
-	case BackendCUDA:
-		if a.cudaDevice != nil {
-			return a.cudaDevice.MemoryMB()
-		}
-	case BackendOpenCL:
-		if a.openclDevice != nil {
-			return a.openclDevice.MemoryMB()
-		}
-	case BackendVulkan:
-		if a.vulkanDevice != nil {
-			return a.vulkanDevice.MemoryMB()
-		}
+	if a.device != nil {
+		return a.device.MemoryMB()
 	}
 	return 0
 }
@@ -296,19 +184,10 @@ type GPUEmbeddingIndex struct {
 	idToIndex map[string]int
 	cpuData   []float32 // Flat array: [vec0..., vec1..., vec2...]
 
-	// GPU-side data (Metal)
-	metalBuffer *metal.Buffer
-
-	// GPU-side data (CUDA)
-	cudaBuffer *cuda.Buffer
-
-	// GPU-side data (OpenCL)
-	openclBuffer *opencl.Buffer
-
-	// GPU-side data (Vulkan)
-	vulkanBuffer *vulkan.Buffer
-
+	// GPU-side data on the accelerator's device
+	rows      *RowBuffer
 	gpuSynced bool
+	pinned    bool // Keep buffer resident (see SetPinned)
 
 	// Stats
 	searchesGPU int64
@@ -337,17 +216,21 @@ func (idx *GPUEmbeddingIndex) Add(nodeID string, embedding []float32) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	if i, exists := idx.idToIndex[nodeID]; exists {
+	i, exists := idx.idToIndex[nodeID]
+	if exists {
 		// Update existing
 		copy(idx.cpuData[i*idx.dimensions:], embedding)
 	} else {
 		// Add new
 		idx.nodeIDs = append(idx.nodeIDs, nodeID)
-		idx.idToIndex[nodeID] = len(idx.nodeIDs) - 1
+		i = len(idx.nodeIDs) - 1
+		idx.idToIndex[nodeID] = i
 		idx.cpuData = append(idx.cpuData, embedding...)
 	}
 
-	idx.gpuSynced = false
+	if !idx.writeRows(i) {
+		idx.gpuSynced = false
+	}
 	return nil
 }
 
@@ -360,6 +243,7 @@ func (idx *GPUEmbeddingIndex) AddBatch(nodeIDs []string, embeddings [][]float32)
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
+	rows := make([]int, 0, len(nodeIDs))
 	for i, nodeID := range nodeIDs {
 		if len(embeddings[i]) != idx.dimensions {
 			return ErrInvalidDimensions
@@ -367,14 +251,18 @@ func (idx *GPUEmbeddingIndex) AddBatch(nodeIDs []string, embeddings [][]float32)
 
 		if j, exists := idx.idToIndex[nodeID]; exists {
 			copy(idx.cpuData[j*idx.dimensions:], embeddings[i])
+			rows = append(rows, j)
 		} else {
 			idx.nodeIDs = append(idx.nodeIDs, nodeID)
 			idx.idToIndex[nodeID] = len(idx.nodeIDs) - 1
 			idx.cpuData = append(idx.cpuData, embeddings[i]...)
+			rows = append(rows, len(idx.nodeIDs)-1)
 		}
 	}
 
-	idx.gpuSynced = false
+	if !idx.writeRows(rows...) {
+		idx.gpuSynced = false
+	}
 	return nil
 }
 
@@ -389,8 +277,10 @@ func (idx *GPUEmbeddingIndex) Remove(nodeID string) bool {
 	}
 
 	// Swap with last
+	var moved []int
 	lastIdx := len(idx.nodeIDs) - 1
 	if i != lastIdx {
+		moved = append(moved, i)
 		lastNodeID := idx.nodeIDs[lastIdx]
 		idx.nodeIDs[i] = lastNodeID
 		idx.idToIndex[lastNodeID] = i
@@ -405,129 +295,86 @@ func (idx *GPUEmbeddingIndex) Remove(nodeID string) bool {
 	idx.cpuData = idx.cpuData[:lastIdx*idx.dimensions]
 	delete(idx.idToIndex, nodeID)
 
-	idx.gpuSynced = false
+	// Searches only read the first len(nodeIDs) rows, so the GPU copy just
+	// needs the moved embedding.
+	if !idx.writeRows(moved...) {
+		idx.gpuSynced = false
+	}
 	return true
 }
 
-// SyncToGPU uploads embeddings to GPU memory.
-func (idx *GPUEmbeddingIndex) SyncToGPU() error {
-	idx.mu.Lock()
-	defer idx.mu.Unlock()
-
-	if !idx.accel.IsEnabled() {
-		return ErrGPUDisabled
-	}
-
-	if len(idx.cpuData) == 0 {
-		idx.gpuSynced = true
-		return nil
+// writeRows brings the GPU rows in line with cpuData, so a synced index
+// stays synced without uploading everything again: the given rows are
+// written in place, nodes added since are appended (growing the buffer if
+// needed) and rows removed are dropped. It returns false if the index was
+// not synced or a write fails; the caller must then clear gpuSynced.
+func (idx *GPUEmbeddingIndex) writeRows(rows ...int) bool {
+	if !idx.gpuSynced || idx.rows == nil {
+		return false
 	}
-
-	switch idx.accel.backend {
-	case BackendMetal:
-		return idx.syncToMetal()
-	case BackendCUDA:
-		return idx.syncToCUDA()
-	case BackendOpenCL:
-		return idx.syncToOpenCL()
-	case BackendVulkan:
-		return idx.syncToVulkan()
-	default:
-		return ErrGPUNotAvailable
+	rb := idx.rows
+	before := rb.Stats().BytesWritten
+	held, n, dims := rb.Rows(), len(idx.nodeIDs), idx.dimensions
+	for _, row := range rows {
+		if row >= min(held, n) {
+			continue // Appended below
+		}
+		if err := rb.Update(row, idx.cpuData[row*dims:(row+1)*dims]); err != nil {
+			return false
+		}
 	}
-}
-
-// syncToMetal uploads data to Metal GPU buffer.
-func (idx *GPUEmbeddingIndex) syncToMetal() error {
-	// Release old buffer
-	if idx.metalBuffer != nil {
-		idx.metalBuffer.Release()
-		idx.metalBuffer = nil
+	var err error
+	switch {
+	case n > held:
+		err = rb.Append(idx.cpuData[held*dims:])
+		idx.pinBuffer() // A grown buffer is new
+	case n < held:
+		err = rb.Truncate(n)
 	}
-
-	// Create new buffer with embeddings
-	buffer, err := idx.accel.metalDevice.NewBuffer(idx.cpuData, metal.StorageShared)
 	if err != nil {
-		return err
+		return false
 	}
 
-	idx.metalBuffer = buffer
-	idx.gpuSynced = true
-
-	// Update stats
 	idx.accel.mu.Lock()
-	idx.accel.stats.BytesUploaded += int64(len(idx.cpuData) * 4)
+	idx.accel.stats.BytesUploaded += int64(rb.Stats().BytesWritten - before)
 	idx.accel.mu.Unlock()
-
-	return nil
+	return true
 }
 
-// syncToCUDA uploads data to CUDA GPU buffer.
-func (idx *GPUEmbeddingIndex) syncToCUDA() error {
-	// Release old buffer
-	if idx.cudaBuffer != nil {
-		idx.cudaBuffer.Release()
-		idx.cudaBuffer = nil
+// pinBuffer pins the GPU buffer if the index is pinned.
+func (idx *GPUEmbeddingIndex) pinBuffer() {
+	if !idx.pinned || idx.rows == nil {
+		return
 	}
-
-	// Create new buffer with embeddings
-	buffer, err := idx.accel.cudaDevice.NewBuffer(idx.cpuData, cuda.MemoryDevice)
-	if err != nil {
-		return err
+	if b, ok := idx.rows.Buffer().(*ManagedBuffer); ok {
+		b.Pin()
 	}
-
-	idx.cudaBuffer = buffer
-	idx.gpuSynced = true
-
-	// Update stats
-	idx.accel.mu.Lock()
-	idx.accel.stats.BytesUploaded += int64(len(idx.cpuData) * 4)
-	idx.accel.mu.Unlock()
-
-	return nil
 }
 
-// syncToOpenCL uploads data to OpenCL GPU buffer.
-func (idx *GPUEmbeddingIndex) syncToOpenCL() error {
-	// Release old buffer
-	if idx.openclBuffer != nil {
-		idx.openclBuffer.Release()
-		idx.openclBuffer = nil
-	}
+// SyncToGPU uploads embeddings to GPU memory.
+func (idx *GPUEmbeddingIndex) SyncToGPU() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
 
-	// Create new buffer with embeddings
-	buffer, err := idx.accel.openclDevice.NewBuffer(idx.cpuData)
-	if err != nil {
-		return err
+	if !idx.accel.IsEnabled() {
+		return ErrGPUDisabled
 	}
 
-	idx.openclBuffer = buffer
-	idx.gpuSynced = true
-
-	// Update stats
-	idx.accel.mu.Lock()
-	idx.accel.stats.BytesUploaded += int64(len(idx.cpuData) * 4)
-	idx.accel.mu.Unlock()
-
-	return nil
-}
-
-// syncToVulkan uploads data to Vulkan GPU buffer.
-func (idx *GPUEmbeddingIndex) syncToVulkan() error {
 	// Release old buffer
-	if idx.vulkanBuffer != nil {
-		idx.vulkanBuffer.Release()
-		idx.vulkanBuffer = nil
+	if idx.rows != nil {
+		idx.rows.Release()
+		idx.rows = nil
 	}
 
-	// Create new buffer with embeddings
-	buffer, err := idx.accel.vulkanDevice.NewBuffer(idx.cpuData)
+	// Create new buffer with embeddings; later additions are appended
+	rows, err := NewRowBuffer(idx.accel.device, idx.dimensions, idx.cpuData)
 	if err != nil {
 		return err
 	}
 
-	idx.vulkanBuffer = buffer
+	idx.rows = rows
 	idx.gpuSynced = true
+	idx.pinBuffer()
 
 	// Update stats
 	idx.accel.mu.Lock()
@@ -539,8 +386,16 @@ func (idx *GPUEmbeddingIndex) syncToVulkan() error {
 
 // Search finds the k most similar embeddings.
 func (idx *GPUEmbeddingIndex) Search(query []float32, k int) ([]SearchResult, error) {
+	results, _, err := idx.SearchWithBackend(query, k)
+	return results, err
+}
+
+// SearchWithBackend is Search that also reports which backend served the
+// query: the accelerator's, or BackendNone if the search ran on the CPU
+// because the index is not synced or the GPU search failed.
+func (idx *GPUEmbeddingIndex) SearchWithBackend(query []float32, k int) ([]SearchResult, Backend, error) {
 	if len(query) != idx.dimensions {
-		return nil, ErrInvalidDimensions
+		return nil, BackendNone, ErrInvalidDimensions
 	}
 
 	idx.mu.RLock()
@@ -548,189 +403,34 @@ func (idx *GPUEmbeddingIndex) Search(query []float32, k int) ([]SearchResult, er
 
 	n := len(idx.nodeIDs)
 	if n == 0 {
-		return nil, nil
+		return nil, BackendNone, nil
 	}
 	if k > n {
 		k = n
 	}
 
-	// Use GPU if available and synced
+	// Use GPU if available and synced, falling back to CPU on GPU error
 	if idx.accel.IsEnabled() && idx.gpuSynced {
-		return idx.searchGPU(query, k)
+		if results, err := idx.searchGPU(query, k); err == nil {
+			return results, idx.accel.backend, nil
+		}
 	}
 
-	return idx.searchCPU(query, k)
+	results, err := idx.searchCPU(query, k)
+	return results, BackendNone, err
 }
 
 // searchGPU performs GPU-accelerated search.
 func (idx *GPUEmbeddingIndex) searchGPU(query []float32, k int) ([]SearchResult, error) {
-	idx.searchesGPU++
-
-	switch idx.accel.backend {
-	case BackendMetal:
-		return idx.searchMetal(query, k)
-	case BackendCUDA:
-		return idx.searchCUDA(query, k)
-	case BackendOpenCL:
-		return idx.searchOpenCL(query, k)
-	case BackendVulkan:
-		return idx.searchVulkan(query, k)
-	default:
-		// Fallback to CPU
-		return idx.searchCPU(query, k)
-	}
-}
-
-// searchMetal performs search using Metal GPU.
-func (idx *GPUEmbeddingIndex) searchMetal(query []float32, k int) ([]SearchResult, error) {
-	if idx.metalBuffer == nil {
-		return idx.searchCPU(query, k)
-	}
-
-	n := uint32(len(idx.nodeIDs))
-
-	results, err := idx.accel.metalDevice.Search(
-		idx.metalBuffer,
-		query,
-		n,
-		uint32(idx.dimensions),
-		k,
-		true, // normalized
-	)
-
-	if err != nil {
-		// Fallback to CPU on GPU error
-		return idx.searchCPU(query, k)
-	}
-
-	// Update stats
-	idx.accel.mu.Lock()
-	idx.accel.stats.SearchesGPU++
-	idx.accel.stats.KernelExecutions += 2 // similarity + topk
-	idx.accel.mu.Unlock()
-
-	// Convert to SearchResult with nodeIDs
-	output := make([]SearchResult, len(results))
-	for i, r := range results {
-		if int(r.Index) < len(idx.nodeIDs) {
-			output[i] = SearchResult{
-				ID:       idx.nodeIDs[r.Index],
-				Score:    r.Score,
-				Distance: 1 - r.Score,
-			}
-		}
-	}
-
-	return output, nil
-}
-
-// searchCUDA performs search using CUDA GPU.
-func (idx *GPUEmbeddingIndex) searchCUDA(query []float32, k int) ([]SearchResult, error) {
-	if idx.cudaBuffer == nil {
-		return idx.searchCPU(query, k)
+	if idx.rows == nil || idx.rows.Rows() != len(idx.nodeIDs) {
+		return nil, ErrGPUNotAvailable
 	}
 
-	n := uint32(len(idx.nodeIDs))
-
-	results, err := idx.accel.cudaDevice.Search(
-		idx.cudaBuffer,
-		query,
-		n,
-		uint32(idx.dimensions),
-		k,
-		true, // normalized
-	)
-
+	hits, err := idx.rows.Search(query, k, SearchOptions{}) // cpuData is not normalized
 	if err != nil {
-		// Fallback to CPU on GPU error
-		return idx.searchCPU(query, k)
-	}
-
-	// Update stats
-	idx.accel.mu.Lock()
-	idx.accel.stats.SearchesGPU++
-	idx.accel.stats.KernelExecutions += 2 // similarity + topk
-	idx.accel.mu.Unlock()
-
-	// Convert to SearchResult with nodeIDs
-	output := make([]SearchResult, len(results))
-	for i, r := range results {
-		if int(r.Index) < len(idx.nodeIDs) {
-			output[i] = SearchResult{
-				ID:       idx.nodeIDs[r.Index],
-				Score:    r.Score,
-				Distance: 1 - r.Score,
-			}
-		}
-	}
-
-	return output, nil
-}
-
-// searchOpenCL performs search using OpenCL GPU.
-func (idx *GPUEmbeddingIndex) searchOpenCL(query []float32, k int) ([]SearchResult, error) {
-	if idx.openclBuffer == nil {
-		return idx.searchCPU(query, k)
-	}
-
-	n := uint32(len(idx.nodeIDs))
-
-	results, err := idx.accel.openclDevice.Search(
-		idx.openclBuffer,
-		query,
-		n,
-		uint32(idx.dimensions),
-		k,
-		true, // normalized
-	)
-
-	if err != nil {
-		// Fallback to CPU on GPU error
-		return idx.searchCPU(query, k)
-	}
-
-	// Update stats
-	idx.accel.mu.Lock()
-	idx.accel.stats.SearchesGPU++
-	idx.accel.stats.KernelExecutions += 2 // similarity + topk
-	idx.accel.mu.Unlock()
-
-	// Convert to SearchResult with nodeIDs
-	output := make([]SearchResult, len(results))
-	for i, r := range results {
-		if int(r.Index) < len(idx.nodeIDs) {
-			output[i] = SearchResult{
-				ID:       idx.nodeIDs[r.Index],
-				Score:    r.Score,
-				Distance: 1 - r.Score,
-			}
-		}
-	}
-
-	return output, nil
-}
-
-// searchVulkan performs search using Vulkan compute GPU.
-func (idx *GPUEmbeddingIndex) searchVulkan(query []float32, k int) ([]SearchResult, error) {
-	if idx.vulkanBuffer == nil {
-		return idx.searchCPU(query, k)
-	}
-
-	n := uint32(len(idx.nodeIDs))
-
-	results, err := idx.accel.vulkanDevice.Search(
-		idx.vulkanBuffer,
-		query,
-		n,
-		uint32(idx.dimensions),
-		k,
-		true, // normalized
-	)
-
-	if err != nil {
-		// Fallback to CPU on GPU error
-		return idx.searchCPU(query, k)
+		return nil, err
 	}
+	atomic.AddInt64(&idx.searchesGPU, 1)
 
 	// Update stats
 	idx.accel.mu.Lock()
@@ -739,13 +439,13 @@ func (idx *GPUEmbeddingIndex) searchVulkan(query []float32, k int) ([]SearchResu
 	idx.accel.mu.Unlock()
 
 	// Convert to SearchResult with nodeIDs
-	output := make([]SearchResult, len(results))
-	for i, r := range results {
-		if int(r.Index) < len(idx.nodeIDs) {
+	output := make([]SearchResult, len(hits))
+	for i, h := range hits {
+		if int(h.Index) < len(idx.nodeIDs) {
 			output[i] = SearchResult{
-				ID:       idx.nodeIDs[r.Index],
-				Score:    r.Score,
-				Distance: 1 - r.Score,
+				ID:       idx.nodeIDs[h.Index],
+				Score:    h.Score,
+				Distance: 1 - h.Score,
 			}
 		}
 	}
@@ -755,7 +455,7 @@ func (idx *GPUEmbeddingIndex) searchVulkan(query []float32, k int) ([]SearchResu
 
 // searchCPU performs CPU-based search (fallback).
 func (idx *GPUEmbeddingIndex) searchCPU(query []float32, k int) ([]SearchResult, error) {
-	idx.searchesCPU++
+	atomic.AddInt64(&idx.searchesCPU, 1)
 
 	idx.accel.mu.Lock()
 	idx.accel.stats.SearchesCPU++
@@ -806,26 +506,61 @@ func (idx *GPUEmbeddingIndex) IsGPUSynced() bool {
 	return idx.gpuSynced
 }
 
-// Release frees GPU resources.
-func (idx *GPUEmbeddingIndex) Release() {
+// Get retrieves the embedding for a nodeID.
+func (idx *GPUEmbeddingIndex) Get(nodeID string) ([]float32, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	i, exists := idx.idToIndex[nodeID]
+	if !exists {
+		return nil, false
+	}
+
+	start := i * idx.dimensions
+	result := make([]float32, idx.dimensions)
+	copy(result, idx.cpuData[start:start+idx.dimensions])
+	return result, true
+}
+
+// NodeIDs returns the IDs of all indexed nodes in index order.
+func (idx *GPUEmbeddingIndex) NodeIDs() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	ids := make([]string, len(idx.nodeIDs))
+	copy(ids, idx.nodeIDs)
+	return ids
+}
+
+// SetPinned keeps the index's GPU buffer resident when the accelerator's
+// memory manager evicts buffers to make room for others. It applies to the
+// current buffer and those replacing it when the index syncs or grows.
+func (idx *GPUEmbeddingIndex) SetPinned(pinned bool) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	if idx.metalBuffer != nil {
-		idx.metalBuffer.Release()
-		idx.metalBuffer = nil
+	idx.pinned = pinned
+	if idx.rows == nil {
+		return nil
 	}
-	if idx.cudaBuffer != nil {
-		idx.cudaBuffer.Release()
-		idx.cudaBuffer = nil
+	b, ok := idx.rows.Buffer().(*ManagedBuffer)
+	if !ok {
+		return nil
 	}
-	if idx.openclBuffer != nil {
-		idx.openclBuffer.Release()
-		idx.openclBuffer = nil
+	if !pinned {
+		b.Unpin()
+		return nil
 	}
-	if idx.vulkanBuffer != nil {
-		idx.vulkanBuffer.Release()
-		idx.vulkanBuffer = nil
+	return b.Pin()
+}
+
+// Release frees GPU resources.
+func (idx *GPUEmbeddingIndex) Release() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.rows != nil {
+		idx.rows.Release()
+		idx.rows = nil
 	}
 }
 
@@ -851,8 +586,8 @@ func (idx *GPUEmbeddingIndex) Stats() GPUEmbeddingIndexStats {
 		Count:       len(idx.nodeIDs),
 		Dimensions:  idx.dimensions,
 		GPUSynced:   idx.gpuSynced,
-		SearchesGPU: idx.searchesGPU,
-		SearchesCPU: idx.searchesCPU,
+		SearchesGPU: atomic.LoadInt64(&idx.searchesGPU),
+		SearchesCPU: atomic.LoadInt64(&idx.searchesCPU),
 		MemoryMB:    float64(totalBytes) / (1024 * 1024),
 	}
 }
@@ -3,6 +3,7 @@ package gpu
 import (
 	"fmt"
 	"runtime"
+	"sync"
 	"testing"
 )
 
@@ -233,6 +234,34 @@ func TestGPUEmbeddingIndexStats(t *testing.T) {
 	}
 }
 
+func TestGPUEmbeddingIndexConcurrentSearch(t *testing.T) {
+	accel, _ := NewAccelerator(nil)
+	defer accel.Release()
+
+	idx := accel.NewGPUEmbeddingIndex(3)
+	idx.AddBatch([]string{"a", "b", "c"}, [][]float32{
+		{1, 0, 0},
+		{0, 1, 0},
+		{0, 0, 1},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := idx.Search([]float32{1, 0, 0}, 1); err != nil {
+				t.Errorf("Search() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := idx.Stats().SearchesCPU + idx.Stats().SearchesGPU; got != 16 {
+		t.Errorf("expected 16 searches counted, got %d", got)
+	}
+}
+
 func BenchmarkGPUEmbeddingIndexSearch(b *testing.B) {
 	accel, _ := NewAccelerator(&Config{
 		Enabled:         true,
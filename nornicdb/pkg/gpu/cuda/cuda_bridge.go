@@ -14,36 +14,60 @@ package cuda
 #include <cuda.h>
 #include <cuda_runtime_api.h>
 #include <cublas_v2.h>
+#include <math.h>
+#include <stdint.h>
 #include <stdlib.h>
 #include <string.h>
 
-// Error handling
-static char cuda_last_error[256] = {0};
+// Error handling. The codes let Go classify failures (see errors.go). Error
+// state is thread-local so concurrent operations cannot overwrite each
+// other's; Go stays on the calling thread until it has read it (see call).
+static __thread char cuda_last_error[256] = {0};
+static __thread int cuda_last_code = 0;   // cudaError_t
+static __thread int cuda_last_cublas = 0; // cublasStatus_t
 
 void cuda_set_error(const char* msg) {
     strncpy(cuda_last_error, msg, sizeof(cuda_last_error) - 1);
 }
 
+void cuda_set_runtime_error(cudaError_t err) {
+    cuda_last_code = (int)err;
+    cuda_set_error(cudaGetErrorString(err));
+}
+
+void cuda_set_cublas_error(cublasStatus_t status, const char* msg) {
+    cuda_last_cublas = (int)status;
+    cuda_set_error(msg);
+}
+
 const char* cuda_get_last_error() {
     return cuda_last_error;
 }
 
+int cuda_get_last_code() {
+    return cuda_last_code;
+}
+
+int cuda_get_last_cublas_status() {
+    return cuda_last_cublas;
+}
+
 void cuda_clear_error() {
     cuda_last_error[0] = 0;
+    cuda_last_code = 0;
+    cuda_last_cublas = 0;
 }
 
 // Device management
 typedef struct {
     int device_id;
-    cublasHandle_t cublas_handle;
-    cudaStream_t stream;
 } CudaDevice;
 
 int cuda_get_device_count() {
     int count = 0;
     cudaError_t err = cudaGetDeviceCount(&count);
     if (err != cudaSuccess) {
-        cuda_set_error(cudaGetErrorString(err));
+        cuda_set_runtime_error(err);
         return -1;
     }
     return count;
@@ -56,7 +80,7 @@ int cuda_is_available() {
 CudaDevice* cuda_create_device(int device_id) {
     cudaError_t err = cudaSetDevice(device_id);
     if (err != cudaSuccess) {
-        cuda_set_error(cudaGetErrorString(err));
+        cuda_set_runtime_error(err);
         return NULL;
     }
 
@@ -67,38 +91,116 @@ CudaDevice* cuda_create_device(int device_id) {
     }
 
     dev->device_id = device_id;
+    return dev;
+}
 
-    // Create cuBLAS handle
-    cublasStatus_t status = cublasCreate(&dev->cublas_handle);
+void cuda_release_device(CudaDevice* dev) {
+    free(dev);
+}
+
+// Buffer management
+typedef struct {
+    float* data;
+    size_t size;
+    int memory_type; // 0 = device, 1 = host pinned
+    int precision;   // 0 = fp32, 1 = fp16, 2 = bf16, 3 = int8 (see Precision)
+} CudaBuffer;
+
+static size_t cuda_element_size(int precision) {
+    switch (precision) {
+    case 0: return sizeof(float);
+    case 3: return sizeof(int8_t);
+    default: return sizeof(uint16_t);
+    }
+}
+
+static cudaDataType cuda_data_type(int precision) {
+    switch (precision) {
+    case 1: return CUDA_R_16F;
+    case 2: return CUDA_R_16BF;
+    case 3: return CUDA_R_8I;
+    default: return CUDA_R_32F;
+    }
+}
+
+// Byte offset of the scales in an int8 image of count codes, as
+// quant.ScalesOffset.
+static size_t cuda_scales_offset(size_t count) {
+    return (count + 3) & ~(size_t)3;
+}
+
+// Streams. Each stream has its own cuBLAS handle and scratch buffers, so
+// operations on different streams run concurrently. Streams are
+// non-blocking: they do not synchronize with the legacy default stream.
+typedef struct {
+    int device_id;
+    cudaStream_t stream;
+    cublasHandle_t cublas_handle;
+    CudaBuffer* query;  // search scratch, grown on demand
+    CudaBuffer* scores;
+    CudaBuffer* norms;  // squared embedding norms for unnormalized searches
+} CudaStream;
+
+void cuda_release_buffer(CudaBuffer* buf);
+
+CudaStream* cuda_create_stream(CudaDevice* dev) {
+    cudaError_t err = cudaSetDevice(dev->device_id);
+    if (err != cudaSuccess) {
+        cuda_set_runtime_error(err);
+        return NULL;
+    }
+
+    CudaStream* s = (CudaStream*)calloc(1, sizeof(CudaStream));
+    if (!s) {
+        cuda_set_error("Failed to allocate stream struct");
+        return NULL;
+    }
+    s->device_id = dev->device_id;
+
+    cublasStatus_t status = cublasCreate(&s->cublas_handle);
     if (status != CUBLAS_STATUS_SUCCESS) {
-        cuda_set_error("Failed to create cuBLAS handle");
-        free(dev);
+        cuda_set_cublas_error(status, "Failed to create cuBLAS handle");
+        free(s);
         return NULL;
     }
 
-    // Create CUDA stream
-    err = cudaStreamCreate(&dev->stream);
+    err = cudaStreamCreateWithFlags(&s->stream, cudaStreamNonBlocking);
     if (err != cudaSuccess) {
-        cuda_set_error(cudaGetErrorString(err));
-        cublasDestroy(dev->cublas_handle);
-        free(dev);
+        cuda_set_runtime_error(err);
+        cublasDestroy(s->cublas_handle);
+        free(s);
         return NULL;
     }
 
     // Associate stream with cuBLAS
-    cublasSetStream(dev->cublas_handle, dev->stream);
+    cublasSetStream(s->cublas_handle, s->stream);
 
-    return dev;
+    return s;
 }
 
-void cuda_release_device(CudaDevice* dev) {
-    if (dev) {
-        if (dev->stream) cudaStreamDestroy(dev->stream);
-        if (dev->cublas_handle) cublasDestroy(dev->cublas_handle);
-        free(dev);
+void cuda_release_stream(CudaStream* s) {
+    if (s) {
+        cudaSetDevice(s->device_id);
+        cudaStreamSynchronize(s->stream);
+        cuda_release_buffer(s->query);
+        cuda_release_buffer(s->scores);
+        cuda_release_buffer(s->norms);
+        if (s->stream) cudaStreamDestroy(s->stream);
+        if (s->cublas_handle) cublasDestroy(s->cublas_handle);
+        free(s);
     }
 }
 
+// Selects the stream's device on the calling thread. The current device is
+// per-thread state in CUDA, and Go may run each call on a different thread.
+static int cuda_use_stream(CudaStream* s) {
+    cudaError_t err = cudaSetDevice(s->device_id);
+    if (err != cudaSuccess) {
+        cuda_set_runtime_error(err);
+        return -1;
+    }
+    return 0;
+}
 const char* cuda_device_name(int device_id) {
     static char name[256];
     struct cudaDeviceProp prop;
@@ -128,37 +230,66 @@ int cuda_device_compute_capability(int device_id) {
     return prop.major * 10 + prop.minor;
 }
 
-// Buffer management
-typedef struct {
-    float* data;
-    size_t size;
-    int memory_type; // 0 = device, 1 = host pinned
-} CudaBuffer;
+int cuda_device_max_threads(int device_id) {
+    struct cudaDeviceProp prop;
+    cudaError_t err = cudaGetDeviceProperties(&prop, device_id);
+    if (err != cudaSuccess) {
+        return 0;
+    }
+    return prop.maxThreadsPerBlock;
+}
+
+int cuda_driver_version() {
+    int version = 0;
+    if (cudaDriverGetVersion(&version) != cudaSuccess) {
+        return 0;
+    }
+    return version;
+}
+
+// Why no device is usable: driver/runtime mismatch, no device, etc.
+const char* cuda_unavailable_reason() {
+    int count = 0;
+    cudaError_t err = cudaGetDeviceCount(&count);
+    if (err != cudaSuccess) {
+        return cudaGetErrorString(err);
+    }
+    if (count == 0) {
+        return "no CUDA devices found";
+    }
+    return "";
+}
+
+// Buffers are allocated on the stream's device; initial data, already in
+// the buffer's precision, is copied on the stream.
+CudaBuffer* cuda_create_buffer(CudaStream* s, void* host_data, size_t count, int memory_type, int precision) {
+    if (cuda_use_stream(s) != 0) return NULL;
 
-CudaBuffer* cuda_create_buffer(CudaDevice* dev, float* host_data, size_t count, int memory_type) {
     CudaBuffer* buf = (CudaBuffer*)malloc(sizeof(CudaBuffer));
     if (!buf) {
         cuda_set_error("Failed to allocate buffer struct");
         return NULL;
     }
 
-    buf->size = count * sizeof(float);
+    buf->size = count * cuda_element_size(precision);
     buf->memory_type = memory_type;
+    buf->precision = precision;
 
     cudaError_t err;
     if (memory_type == 0) {
         // Device memory
         err = cudaMalloc((void**)&buf->data, buf->size);
         if (err != cudaSuccess) {
-            cuda_set_error(cudaGetErrorString(err));
+            cuda_set_runtime_error(err);
             free(buf);
             return NULL;
         }
 
         if (host_data) {
-            err = cudaMemcpy(buf->data, host_data, buf->size, cudaMemcpyHostToDevice);
+            err = cudaMemcpyAsync(buf->data, host_data, buf->size, cudaMemcpyHostToDevice, s->stream);
+            if (err == cudaSuccess) err = cudaStreamSynchronize(s->stream);
             if (err != cudaSuccess) {
-                cuda_set_error(cudaGetErrorString(err));
+                cuda_set_runtime_error(err);
                 cudaFree(buf->data);
                 free(buf);
                 return NULL;
@@ -168,7 +299,7 @@ CudaBuffer* cuda_create_buffer(CudaDevice* dev, float* host_data, size_t count,
         // Host pinned memory (for faster transfers)
         err = cudaMallocHost((void**)&buf->data, buf->size);
         if (err != cudaSuccess) {
-            cuda_set_error(cudaGetErrorString(err));
+            cuda_set_runtime_error(err);
             free(buf);
             return NULL;
         }
@@ -202,22 +333,81 @@ size_t cuda_buffer_size(CudaBuffer* buf) {
     return buf ? buf->size : 0;
 }
 
-int cuda_buffer_copy_to_host(CudaBuffer* buf, float* host_data, size_t count) {
+// Copy count elements starting at an element offset into host memory
+int cuda_buffer_copy_to_host(CudaStream* s, CudaBuffer* buf, void* host_data, size_t offset, size_t count) {
     if (!buf || !host_data) return -1;
 
-    size_t copy_size = count * sizeof(float);
-    if (copy_size > buf->size) copy_size = buf->size;
+    size_t byte_offset = offset * cuda_element_size(buf->precision);
+    size_t copy_size = count * cuda_element_size(buf->precision);
+    if (byte_offset + copy_size > buf->size) {
+        cuda_set_error("copy exceeds buffer size");
+        return -1;
+    }
 
     cudaError_t err;
     if (buf->memory_type == 0) {
-        err = cudaMemcpy(host_data, buf->data, copy_size, cudaMemcpyDeviceToHost);
+        if (cuda_use_stream(s) != 0) return -1;
+        err = cudaMemcpyAsync(host_data, (char*)buf->data + byte_offset, copy_size, cudaMemcpyDeviceToHost, s->stream);
+        if (err == cudaSuccess) err = cudaStreamSynchronize(s->stream);
     } else {
-        memcpy(host_data, buf->data, copy_size);
+        memcpy(host_data, (char*)buf->data + byte_offset, copy_size);
         err = cudaSuccess;
     }
 
     if (err != cudaSuccess) {
-        cuda_set_error(cudaGetErrorString(err));
+        cuda_set_runtime_error(err);
+        return -1;
+    }
+    return 0;
+}
+
+// Copy host data, already in the buffer's precision, into a buffer at an
+// element offset
+int cuda_buffer_copy_from_host(CudaStream* s, CudaBuffer* buf, const void* host_data, size_t offset, size_t count) {
+    if (!buf || !host_data) return -1;
+
+    size_t byte_offset = offset * cuda_element_size(buf->precision);
+    size_t copy_size = count * cuda_element_size(buf->precision);
+    if (byte_offset + copy_size > buf->size) {
+        cuda_set_error("copy exceeds buffer size");
+        return -1;
+    }
+
+    cudaError_t err;
+    if (buf->memory_type == 0) {
+        if (cuda_use_stream(s) != 0) return -1;
+        err = cudaMemcpyAsync((char*)buf->data + byte_offset, host_data, copy_size, cudaMemcpyHostToDevice, s->stream);
+        if (err == cudaSuccess) err = cudaStreamSynchronize(s->stream);
+    } else {
+        memcpy((char*)buf->data + byte_offset, host_data, copy_size);
+        err = cudaSuccess;
+    }
+
+    if (err != cudaSuccess) {
+        cuda_set_runtime_error(err);
+        return -1;
+    }
+    return 0;
+}
+
+// Copy count elements from a pinned host buffer into a device buffer of the
+// same precision at an element offset. Pinned sources transfer by DMA
+// without an intermediate staging copy.
+int cuda_buffer_copy_from_buffer(CudaStream* s, CudaBuffer* dst, size_t offset, CudaBuffer* src, size_t count) {
+    if (!dst || !src) return -1;
+
+    size_t byte_offset = offset * cuda_element_size(dst->precision);
+    size_t copy_size = count * cuda_element_size(dst->precision);
+    if (byte_offset + copy_size > dst->size || copy_size > src->size) {
+        cuda_set_error("copy exceeds buffer size");
+        return -1;
+    }
+
+    if (cuda_use_stream(s) != 0) return -1;
+    cudaError_t err = cudaMemcpyAsync((char*)dst->data + byte_offset, src->data, copy_size, cudaMemcpyDefault, s->stream);
+    if (err == cudaSuccess) err = cudaStreamSynchronize(s->stream);
+    if (err != cudaSuccess) {
+        cuda_set_runtime_error(err);
         return -1;
     }
     return 0;
@@ -228,48 +418,48 @@ int cuda_buffer_copy_to_host(CudaBuffer* buf, float* host_data, size_t count) {
 // Compute L2 norms for each vector (for normalization)
 // vectors: n x dims matrix (row-major)
 // norms: output array of n floats
-int cuda_compute_norms(CudaDevice* dev, CudaBuffer* vectors, CudaBuffer* norms,
+int cuda_compute_norms(CudaStream* s, CudaBuffer* vectors, CudaBuffer* norms,
                        unsigned int n, unsigned int dims) {
     cublasStatus_t status;
 
     for (unsigned int i = 0; i < n; i++) {
         float* vec = vectors->data + i * dims;
-        status = cublasSnrm2(dev->cublas_handle, dims, vec, 1, norms->data + i);
+        status = cublasSnrm2(s->cublas_handle, dims, vec, 1, norms->data + i);
         if (status != CUBLAS_STATUS_SUCCESS) {
-            cuda_set_error("cuBLAS norm computation failed");
+            cuda_set_cublas_error(status, "cuBLAS norm computation failed");
             return -1;
         }
     }
 
-    cudaStreamSynchronize(dev->stream);
+    cudaStreamSynchronize(s->stream);
     return 0;
 }
 
 // Normalize vectors in-place
-int cuda_normalize_vectors(CudaDevice* dev, CudaBuffer* vectors,
+int cuda_normalize_vectors(CudaStream* s, CudaBuffer* vectors,
                            unsigned int n, unsigned int dims) {
     // Allocate norms buffer
-    CudaBuffer* norms = cuda_create_buffer(dev, NULL, n, 0);
+    CudaBuffer* norms = cuda_create_buffer(s, NULL, n, 0, 0);
     if (!norms) return -1;
 
     // Compute norms
-    if (cuda_compute_norms(dev, vectors, norms, n, dims) != 0) {
+    if (cuda_compute_norms(s, vectors, norms, n, dims) != 0) {
         cuda_release_buffer(norms);
         return -1;
     }
 
     // Copy norms to host for scaling
     float* host_norms = (float*)malloc(n * sizeof(float));
-    cuda_buffer_copy_to_host(norms, host_norms, n);
+    cuda_buffer_copy_to_host(s, norms, host_norms, 0, n);
 
     // Scale each vector by 1/norm
     for (unsigned int i = 0; i < n; i++) {
         if (host_norms[i] > 1e-10f) {
             float scale = 1.0f / host_norms[i];
-            cublasStatus_t status = cublasSscal(dev->cublas_handle, dims,
+            cublasStatus_t status = cublasSscal(s->cublas_handle, dims,
                                                  &scale, vectors->data + i * dims, 1);
             if (status != CUBLAS_STATUS_SUCCESS) {
-                cuda_set_error("cuBLAS scale failed");
+                cuda_set_cublas_error(status, "cuBLAS scale failed");
                 free(host_norms);
                 cuda_release_buffer(norms);
                 return -1;
@@ -277,7 +467,7 @@ int cuda_normalize_vectors(CudaDevice* dev, CudaBuffer* vectors,
         }
     }
 
-    cudaStreamSynchronize(dev->stream);
+    cudaStreamSynchronize(s->stream);
     free(host_norms);
     cuda_release_buffer(norms);
     return 0;
@@ -287,11 +477,12 @@ int cuda_normalize_vectors(CudaDevice* dev, CudaBuffer* vectors,
 // embeddings: n x dims (row-major on device)
 // query: dims x 1 (column vector on device)
 // scores: n x 1 output
-int cuda_cosine_similarity(CudaDevice* dev, CudaBuffer* embeddings, CudaBuffer* query,
+int cuda_cosine_similarity(CudaStream* s, CudaBuffer* embeddings, CudaBuffer* query,
                            CudaBuffer* scores, unsigned int n, unsigned int dims,
                            int normalized) {
-    // If not normalized, we'd need to normalize first
-    // For now, assume normalized (dot product = cosine similarity)
+    // Computes dot products, which equal cosine similarity only for
+    // normalized vectors; cuda_search scales them otherwise.
+    if (cuda_use_stream(s) != 0) return -1;
 
     float alpha = 1.0f;
     float beta = 0.0f;
@@ -299,7 +490,7 @@ int cuda_cosine_similarity(CudaDevice* dev, CudaBuffer* embeddings, CudaBuffer*
     // Matrix-vector multiply: scores = embeddings * query
     // embeddings is n x dims (row-major)
     // In cuBLAS (column-major), we treat it as dims x n and transpose
-    cublasStatus_t status = cublasSgemv(dev->cublas_handle,
+    cublasStatus_t status = cublasSgemv(s->cublas_handle,
                                          CUBLAS_OP_T,  // Transpose because row-major
                                          dims, n,       // Matrix dimensions
                                          &alpha,
@@ -309,32 +500,38 @@ int cuda_cosine_similarity(CudaDevice* dev, CudaBuffer* embeddings, CudaBuffer*
                                          scores->data, 1);        // y vector
 
     if (status != CUBLAS_STATUS_SUCCESS) {
-        cuda_set_error("cuBLAS gemv failed");
+        cuda_set_cublas_error(status, "cuBLAS gemv failed");
         return -1;
     }
 
-    cudaStreamSynchronize(dev->stream);
+    cudaStreamSynchronize(s->stream);
     return 0;
 }
 
-// Simple top-k selection (CPU implementation for now)
-// For production, use thrust::sort or custom CUDA kernel
-int cuda_topk(CudaDevice* dev, CudaBuffer* scores, unsigned int* out_indices,
-              float* out_scores, unsigned int n, unsigned int k) {
-    // Copy scores to host
-    float* host_scores = (float*)malloc(n * sizeof(float));
-    if (cuda_buffer_copy_to_host(scores, host_scores, n) != 0) {
-        free(host_scores);
+// Selects the k highest of n host scores, skipping rows that score below
+// min_score or whose bit in filter (a row bitset, or NULL for all rows) is
+// clear. Returns the number of results, fewer than k if not enough rows
+// qualify, or -1. Simple selection sort on CPU for now; for production, use
+// thrust::sort or a custom CUDA kernel.
+static int cuda_select_topk(const float* host_scores, unsigned int n, unsigned int k,
+                            float min_score, const uint64_t* filter,
+                            unsigned int* out_indices, float* out_scores) {
+    unsigned int* indices = (unsigned int*)malloc(n * sizeof(unsigned int));
+    if (!indices) {
+        cuda_set_error("Failed to allocate top-k indices");
         return -1;
     }
+    unsigned int m = 0;
+    for (unsigned int i = 0; i < n; i++) {
+        if (host_scores[i] < min_score) continue;
+        if (filter && !(filter[i / 64] & (1ULL << (i % 64)))) continue;
+        indices[m++] = i;
+    }
+    if (k > m) k = m;
 
-    // Simple selection sort for top-k
-    unsigned int* indices = (unsigned int*)malloc(n * sizeof(unsigned int));
-    for (unsigned int i = 0; i < n; i++) indices[i] = i;
-
-    for (unsigned int i = 0; i < k && i < n; i++) {
+    for (unsigned int i = 0; i < k; i++) {
         unsigned int max_idx = i;
-        for (unsigned int j = i + 1; j < n; j++) {
+        for (unsigned int j = i + 1; j < m; j++) {
             if (host_scores[indices[j]] > host_scores[indices[max_idx]]) {
                 max_idx = j;
             }
@@ -346,34 +543,328 @@ int cuda_topk(CudaDevice* dev, CudaBuffer* scores, unsigned int* out_indices,
     }
 
     // Copy top-k results
-    for (unsigned int i = 0; i < k && i < n; i++) {
+    for (unsigned int i = 0; i < k; i++) {
         out_indices[i] = indices[i];
         out_scores[i] = host_scores[indices[i]];
     }
 
-    free(host_scores);
     free(indices);
+    return (int)k;
+}
+
+int cuda_topk(CudaStream* s, CudaBuffer* scores, unsigned int* out_indices,
+              float* out_scores, unsigned int n, unsigned int k) {
+    // Copy scores to host
+    float* host_scores = (float*)malloc(n * sizeof(float));
+    if (!host_scores) {
+        cuda_set_error("Failed to allocate host scores");
+        return -1;
+    }
+    if (cuda_buffer_copy_to_host(s, scores, host_scores, 0, n) != 0) {
+        free(host_scores);
+        return -1;
+    }
+
+    int found = cuda_select_topk(host_scores, n, k, -INFINITY, NULL, out_indices, out_scores);
+    free(host_scores);
+    return found < 0 ? -1 : 0;
+}
+
+static CudaBuffer* cuda_scratch(CudaStream* s, CudaBuffer** slot, size_t count);
+
+// Returns the n embedding norms in host memory (freed by the caller), or
+// NULL. The squared norms are computed in one batched call: row i times
+// itself is a 1x1 matrix product. Half-precision and int8 rows are widened
+// and summed in fp32; int8 norms are those of the codes, without scales.
+static float* cuda_host_norms(CudaStream* s, CudaBuffer* embeddings, unsigned int n, unsigned int dims) {
+    CudaBuffer* norms = cuda_scratch(s, &s->norms, n);
+    if (!norms) return NULL;
+
+    float alpha = 1.0f;
+    float beta = 0.0f;
+    cublasStatus_t status;
+    if (embeddings->precision == 0) {
+        status = cublasSgemmStridedBatched(s->cublas_handle,
+                                           CUBLAS_OP_T, CUBLAS_OP_N,
+                                           1, 1, dims,
+                                           &alpha,
+                                           embeddings->data, dims, dims,
+                                           embeddings->data, dims, dims,
+                                           &beta,
+                                           norms->data, 1, 1,
+                                           n);
+    } else {
+        cudaDataType type = cuda_data_type(embeddings->precision);
+        status = cublasGemmStridedBatchedEx(s->cublas_handle,
+                                            CUBLAS_OP_T, CUBLAS_OP_N,
+                                            1, 1, dims,
+                                            &alpha,
+                                            embeddings->data, type, dims, dims,
+                                            embeddings->data, type, dims, dims,
+                                            &beta,
+                                            norms->data, CUDA_R_32F, 1, 1,
+                                            n, CUBLAS_COMPUTE_32F, CUBLAS_GEMM_DEFAULT);
+    }
+    if (status != CUBLAS_STATUS_SUCCESS) {
+        cuda_set_cublas_error(status, "cuBLAS norm computation failed");
+        return NULL;
+    }
+
+    float* host_norms = (float*)malloc(n * sizeof(float));
+    if (!host_norms) {
+        cuda_set_error("Failed to allocate host norms");
+        return NULL;
+    }
+    if (cuda_buffer_copy_to_host(s, norms, host_norms, 0, n) != 0) {
+        free(host_norms);
+        return NULL;
+    }
+    for (unsigned int i = 0; i < n; i++) {
+        host_norms[i] = sqrtf(host_norms[i]);
+    }
+    return host_norms;
+}
+
+// Divides n host dot products by the embedding and query norms, turning
+// them into cosine similarities.
+static void cuda_divide_by_norms(const float* host_norms, const float* host_query,
+                                 float* host_scores, unsigned int n, unsigned int dims) {
+    float query_norm = 0.0f;
+    for (unsigned int d = 0; d < dims; d++) {
+        query_norm += host_query[d] * host_query[d];
+    }
+    query_norm = sqrtf(query_norm);
+
+    for (unsigned int i = 0; i < n; i++) {
+        float denom = host_norms[i] * query_norm;
+        host_scores[i] = denom > 1e-10f ? host_scores[i] / denom : 0.0f;
+    }
+}
+
+static int cuda_scale_to_cosine(CudaStream* s, CudaBuffer* embeddings, const float* host_query,
+                                float* host_scores, unsigned int n, unsigned int dims) {
+    float* host_norms = cuda_host_norms(s, embeddings, n, dims);
+    if (!host_norms) return -1;
+    cuda_divide_by_norms(host_norms, host_query, host_scores, n, dims);
+    free(host_norms);
     return 0;
 }
+
+// Grows a stream scratch buffer to hold at least count floats.
+static CudaBuffer* cuda_scratch(CudaStream* s, CudaBuffer** slot, size_t count) {
+    if (*slot && (*slot)->size >= count * sizeof(float)) {
+        return *slot;
+    }
+    cuda_release_buffer(*slot);
+    *slot = cuda_create_buffer(s, NULL, count, 0, 0);
+    return *slot;
+}
+
+// Complete similarity search on one stream, reusing the stream's scratch
+// buffers so concurrent searches neither allocate nor free device memory
+// (cudaFree synchronizes the whole device). Scores are dot products; unless
+// normalized is set they are divided by the norms to give cosine
+// similarity. Returns the number of results, as cuda_select_topk does.
+int cuda_search(CudaStream* s, CudaBuffer* embeddings, const float* host_query,
+                unsigned int n, unsigned int dims, unsigned int k, int normalized,
+                float min_score, const uint64_t* filter,
+                unsigned int* out_indices, float* out_scores) {
+    if (cuda_use_stream(s) != 0) return -1;
+
+    CudaBuffer* query = cuda_scratch(s, &s->query, dims);
+    if (!query) return -1;
+    CudaBuffer* scores = cuda_scratch(s, &s->scores, n);
+    if (!scores) return -1;
+
+    if (cuda_buffer_copy_from_host(s, query, host_query, 0, dims) != 0) return -1;
+    if (cuda_cosine_similarity(s, embeddings, query, scores, n, dims, normalized) != 0) return -1;
+
+    float* host_scores = (float*)malloc(n * sizeof(float));
+    if (!host_scores) {
+        cuda_set_error("Failed to allocate host scores");
+        return -1;
+    }
+    int found = cuda_buffer_copy_to_host(s, scores, host_scores, 0, n);
+    if (found == 0 && !normalized) {
+        found = cuda_scale_to_cosine(s, embeddings, host_query, host_scores, n, dims);
+    }
+    if (found == 0) {
+        found = cuda_select_topk(host_scores, n, k, min_score, filter, out_indices, out_scores);
+    }
+    free(host_scores);
+    return found;
+}
+// Similarity search for the nq row-major queries in host_queries on one
+// stream. A single GEMM scores every query against every embedding, and
+// the embedding norms for cosine scaling are computed once for the batch.
+// For half-precision embeddings, packed_queries holds the queries in the
+// same precision (cuBLAS wants matching inputs) and the GEMM accumulates
+// in fp32; host_queries still supplies the query norms. For int8
+// embeddings, packed_queries is the quant image of the queries and
+// host_queries their dequantized values: the int8 GEMM's dot products of
+// codes are multiplied by the query scales and, for dot-product scores,
+// by the embedding scales at scales_offset bytes into embeddings. Query
+// j's results go to out_indices and out_scores at j*k, and their count to
+// out_found[j]. Returns 0 or -1.
+int cuda_search_batch(CudaStream* s, CudaBuffer* embeddings, const float* host_queries,
+                      const void* packed_queries, size_t scales_offset,
+                      unsigned int nq, unsigned int n, unsigned int dims, unsigned int k,
+                      int normalized, float min_score, const uint64_t* filter,
+                      unsigned int* out_indices, float* out_scores, int* out_found) {
+    if (cuda_use_stream(s) != 0) return -1;
+
+    int quantized = embeddings->precision == 3;
+    size_t total = (size_t)nq * n;
+    size_t query_bytes = (size_t)nq * dims * cuda_element_size(embeddings->precision);
+    size_t query_scales = 0;
+    if (quantized) {
+        query_scales = cuda_scales_offset((size_t)nq * dims);
+        query_bytes = query_scales + (size_t)nq * sizeof(float);
+    }
+    CudaBuffer* queries = cuda_scratch(s, &s->query, (query_bytes + sizeof(float) - 1) / sizeof(float));
+    if (!queries) return -1;
+    CudaBuffer* scores = cuda_scratch(s, &s->scores, total);
+    if (!scores) return -1;
+
+    const void* query_data = embeddings->precision == 0 ? (const void*)host_queries : packed_queries;
+    cudaError_t err = cudaMemcpyAsync(queries->data, query_data, query_bytes, cudaMemcpyHostToDevice, s->stream);
+    if (err == cudaSuccess) err = cudaStreamSynchronize(s->stream);
+    if (err != cudaSuccess) {
+        cuda_set_runtime_error(err);
+        return -1;
+    }
+
+    // scores (nq x n, row-major) = queries (nq x dims) * embeddings^T. In
+    // cuBLAS's column-major view that is embeddings^T (n x dims) times
+    // queries^T (dims x nq): an n x nq matrix with leading dimension n.
+    float alpha = 1.0f;
+    float beta = 0.0f;
+    cublasStatus_t status;
+    if (embeddings->precision == 0) {
+        status = cublasSgemm(s->cublas_handle,
+                             CUBLAS_OP_T, CUBLAS_OP_N,
+                             n, nq, dims,
+                             &alpha,
+                             embeddings->data, dims,
+                             queries->data, dims,
+                             &beta,
+                             scores->data, n);
+    } else {
+        cudaDataType type = cuda_data_type(embeddings->precision);
+        status = cublasGemmEx(s->cublas_handle,
+                              CUBLAS_OP_T, CUBLAS_OP_N,
+                              n, nq, dims,
+                              &alpha,
+                              embeddings->data, type, dims,
+                              queries->data, type, dims,
+                              &beta,
+                              scores->data, CUDA_R_32F, n,
+                              CUBLAS_COMPUTE_32F, CUBLAS_GEMM_DEFAULT);
+    }
+    if (status != CUBLAS_STATUS_SUCCESS) {
+        cuda_set_cublas_error(status, "cuBLAS gemm failed");
+        return -1;
+    }
+
+    // Dequantize int8 scores in place: scale column j by query j's scale
+    // and, for dot products, row i by embedding i's.
+    if (quantized) {
+        status = cublasSdgmm(s->cublas_handle, CUBLAS_SIDE_RIGHT, n, nq,
+                             scores->data, n,
+                             (const float*)((const char*)queries->data + query_scales), 1,
+                             scores->data, n);
+        if (status == CUBLAS_STATUS_SUCCESS && normalized) {
+            status = cublasSdgmm(s->cublas_handle, CUBLAS_SIDE_LEFT, n, nq,
+                                 scores->data, n,
+                                 (const float*)((const char*)embeddings->data + scales_offset), 1,
+                                 scores->data, n);
+        }
+        if (status != CUBLAS_STATUS_SUCCESS) {
+            cuda_set_cublas_error(status, "cuBLAS dequantization failed");
+            return -1;
+        }
+    }
+
+    float* host_scores = (float*)malloc(total * sizeof(float));
+    if (!host_scores) {
+        cuda_set_error("Failed to allocate host scores");
+        return -1;
+    }
+    int ret = cuda_buffer_copy_to_host(s, scores, host_scores, 0, total);
+    float* host_norms = NULL;
+    if (ret == 0 && !normalized) {
+        host_norms = cuda_host_norms(s, embeddings, n, dims);
+        if (!host_norms) ret = -1;
+    }
+    for (unsigned int j = 0; ret == 0 && j < nq; j++) {
+        float* row = host_scores + (size_t)j * n;
+        if (host_norms) {
+            cuda_divide_by_norms(host_norms, host_queries + (size_t)j * dims, row, n, dims);
+        }
+        int found = cuda_select_topk(row, n, k, min_score, filter,
+                                     out_indices + (size_t)j * k, out_scores + (size_t)j * k);
+        if (found < 0) {
+            ret = -1;
+        } else {
+            out_found[j] = found;
+        }
+    }
+    free(host_norms);
+    free(host_scores);
+    return ret;
+}
 */
 import "C"
 
 import (
 	"errors"
 	"fmt"
-	"sync"
+	"runtime"
 	"unsafe"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+	"github.com/orneryd/nornicdb/pkg/gpu/lanes"
+	"github.com/orneryd/nornicdb/pkg/gpu/quant"
 )
 
 // Errors
 var (
-	ErrCUDANotAvailable = errors.New("cuda: CUDA is not available on this system")
-	ErrDeviceCreation   = errors.New("cuda: failed to create CUDA device")
-	ErrBufferCreation   = errors.New("cuda: failed to create buffer")
-	ErrKernelExecution  = errors.New("cuda: kernel execution failed")
+	ErrCUDANotAvailable = gpuerr.Sentinel("cuda: CUDA is not available on this system", gpuerr.ErrNotAvailable)
+	ErrDeviceCreation   = gpuerr.Sentinel("cuda: failed to create CUDA device", gpuerr.ErrNotAvailable)
+	ErrBufferCreation   = gpuerr.Sentinel("cuda: failed to create buffer", gpuerr.ErrOutOfMemory)
+	ErrKernelExecution  = gpuerr.Sentinel("cuda: kernel execution failed", gpuerr.ErrKernel)
 	ErrInvalidBuffer    = errors.New("cuda: invalid buffer")
+	ErrDeviceReleased   = errors.New("cuda: device released")
+	ErrOutOfRange       = errors.New("cuda: range exceeds buffer size")
+	ErrPrecision        = errors.New("cuda: operation does not support this buffer precision")
+
+	errWriteFailed = errors.New("cuda: write failed")
+	errCopyFailed  = errors.New("cuda: copy failed")
 )
 
+// lastError returns the pending native error as a failure of op and clears
+// it. The error matches op and, when the code is recognized, one of the
+// gpuerr kinds.
+func lastError(op error) error {
+	msg := C.GoString(C.cuda_get_last_error())
+	code := int(C.cuda_get_last_code())
+	status := int(C.cuda_get_last_cublas_status())
+	C.cuda_clear_error()
+	return newError(op, code, status, msg)
+}
+
+// call runs fn, which makes native calls and reports success, and returns
+// the native error as a failure of op if it fails. Native error state is
+// thread-local, so the goroutine stays on its thread until it is read.
+func call(op error, fn func() bool) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if fn() {
+		return nil
+	}
+	return lastError(op)
+}
+
 // MemoryType defines how buffer memory is managed.
 type MemoryType int
 
@@ -386,6 +877,11 @@ const (
 )
 
 // Device represents a CUDA GPU device.
+//
+// A Device is safe for concurrent use. Operations run on a pool of CUDA
+// streams, each with its own cuBLAS handle and scratch memory, so up to
+// Streams() searches execute concurrently; further callers wait for a free
+// stream.
 type Device struct {
 	ptr     *C.CudaDevice
 	id      int
@@ -393,14 +889,19 @@ type Device struct {
 	memory  uint64
 	ccMajor int
 	ccMinor int
-	mu      sync.Mutex
+	streams *lanes.Pool[*C.CudaStream]
 }
 
-// Buffer represents a CUDA memory buffer.
+// Buffer represents a CUDA memory buffer. Release waits for operations
+// using the buffer to finish.
 type Buffer struct {
-	ptr    *C.CudaBuffer
-	size   uint64
-	device *Device
+	ptr       *C.CudaBuffer
+	size      uint64
+	precision Precision
+	vectors   int // PrecisionInt8: vectors of dims components
+	dims      int
+	device    *Device
+	life      lanes.Guard
 }
 
 // SearchResult holds a similarity search result.
@@ -409,6 +910,9 @@ type SearchResult struct {
 	Score float32
 }
 
+// Compiled reports whether this binary was built with CUDA support.
+const Compiled = true
+
 // IsAvailable checks if CUDA is available on this system.
 func IsAvailable() bool {
 	return C.cuda_is_available() != 0
@@ -423,17 +927,81 @@ func DeviceCount() int {
 	return int(count)
 }
 
-// NewDevice creates a new CUDA device handle.
+// UnavailableReason explains why IsAvailable returns false, for example
+// "CUDA driver version is insufficient for CUDA runtime version".
+// Returns "" when CUDA is available.
+func UnavailableReason() string {
+	return C.GoString(C.cuda_unavailable_reason())
+}
+
+// DriverVersion returns the installed CUDA driver version (e.g. "12.4"),
+// or "" if no driver is loaded.
+func DriverVersion() string {
+	v := int(C.cuda_driver_version())
+	if v == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d", v/1000, (v%1000)/10)
+}
+
+// QueryDevices returns the properties of every CUDA device without
+// creating a compute context.
+func QueryDevices() []DeviceProperties {
+	count := DeviceCount()
+	driver := DriverVersion()
+	devices := make([]DeviceProperties, 0, count)
+	for i := 0; i < count; i++ {
+		cc := int(C.cuda_device_compute_capability(C.int(i)))
+		devices = append(devices, DeviceProperties{
+			ID:                 i,
+			Name:               C.GoString(C.cuda_device_name(C.int(i))),
+			MemoryBytes:        uint64(C.cuda_device_memory(C.int(i))),
+			ComputeMajor:       cc / 10,
+			ComputeMinor:       cc % 10,
+			MaxThreadsPerBlock: int(C.cuda_device_max_threads(C.int(i))),
+			DriverVersion:      driver,
+		})
+	}
+	return devices
+}
+
+// NewDevice creates a new CUDA device handle with the default number of
+// streams (see lanes.DefaultLanes).
 func NewDevice(deviceID int) (*Device, error) {
+	return NewDeviceWithStreams(deviceID, lanes.DefaultLanes())
+}
+
+// NewDeviceWithStreams creates a new CUDA device handle that runs up to
+// streams operations concurrently.
+func NewDeviceWithStreams(deviceID, streams int) (*Device, error) {
 	if !IsAvailable() {
 		return nil, ErrCUDANotAvailable
 	}
+	streams = max(streams, 1)
 
-	ptr := C.cuda_create_device(C.int(deviceID))
-	if ptr == nil {
-		errMsg := C.GoString(C.cuda_get_last_error())
-		C.cuda_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrDeviceCreation, errMsg)
+	var ptr *C.CudaDevice
+	var pool []*C.CudaStream
+	err := call(ErrDeviceCreation, func() bool {
+		if ptr = C.cuda_create_device(C.int(deviceID)); ptr == nil {
+			return false
+		}
+		for len(pool) < streams {
+			s := C.cuda_create_stream(ptr)
+			if s == nil {
+				return false
+			}
+			pool = append(pool, s)
+		}
+		return true
+	})
+	if err != nil {
+		for _, s := range pool {
+			C.cuda_release_stream(s)
+		}
+		if ptr != nil {
+			C.cuda_release_device(ptr)
+		}
+		return nil, err
 	}
 
 	cc := int(C.cuda_device_compute_capability(C.int(deviceID)))
@@ -445,20 +1013,27 @@ func NewDevice(deviceID int) (*Device, error) {
 		memory:  uint64(C.cuda_device_memory(C.int(deviceID))),
 		ccMajor: cc / 10,
 		ccMinor: cc % 10,
+		streams: lanes.NewPool(pool),
 	}, nil
 }
 
-// Release frees the CUDA device resources.
+// Release frees the CUDA device resources after operations in progress
+// finish. Later operations return ErrDeviceReleased.
 func (d *Device) Release() {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	if d.ptr != nil {
+	released := d.streams.Close(func(s *C.CudaStream) {
+		C.cuda_release_stream(s)
+	})
+	if released {
 		C.cuda_release_device(d.ptr)
 		d.ptr = nil
 	}
 }
 
+// Streams returns the number of operations the device runs concurrently.
+func (d *Device) Streams() int {
+	return d.streams.Len()
+}
+
 // ID returns the device ID.
 func (d *Device) ID() int {
 	return d.id
@@ -486,61 +1061,87 @@ func (d *Device) ComputeCapability() (int, int) {
 
 // NewBuffer creates a new GPU buffer with data.
 func (d *Device) NewBuffer(data []float32, memType MemoryType) (*Buffer, error) {
+	return d.NewBufferWithPrecision(data, memType, PrecisionFP32)
+}
+
+// NewBufferWithPrecision creates a new GPU buffer with data stored at
+// precision. Half-precision data is converted on upload and takes half the
+// memory; Search and SearchBatch score it with fp32 accumulation, while
+// NormalizeVectors, CosineSimilarity and TopK need fp32 buffers and return
+// ErrPrecision otherwise. PrecisionInt8 buffers are made with
+// NewQuantizedBuffer.
+func (d *Device) NewBufferWithPrecision(data []float32, memType MemoryType, precision Precision) (*Buffer, error) {
 	if len(data) == 0 {
 		return nil, errors.New("cuda: cannot create empty buffer")
 	}
-
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	ptr := C.cuda_create_buffer(
-		d.ptr,
-		(*C.float)(unsafe.Pointer(&data[0])),
-		C.size_t(len(data)),
-		C.int(memType),
-	)
-
-	if ptr == nil {
-		errMsg := C.GoString(C.cuda_get_last_error())
-		C.cuda_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrBufferCreation, errMsg)
+	if err := precision.check(); err != nil {
+		return nil, err
+	}
+	if precision == PrecisionInt8 {
+		return nil, fmt.Errorf("%w: int8 buffers are made with NewQuantizedBuffer", ErrPrecision)
 	}
+	if precision == PrecisionFP32 {
+		return d.newBuffer(unsafe.Pointer(&data[0]), uint64(len(data)), memType, precision)
+	}
+	packed := precision.encode(data)
+	return d.newBuffer(unsafe.Pointer(&packed[0]), uint64(len(packed)), memType, precision)
+}
 
-	return &Buffer{
-		ptr:    ptr,
-		size:   uint64(len(data) * 4),
-		device: d,
-	}, nil
+// NewQuantizedBuffer creates a PrecisionInt8 buffer holding the row-major
+// vectors of dimensions components in data as int8 codes with a scale per
+// vector (see package quant), about a quarter of the device memory of
+// float32. Search and SearchBatch quantize the queries as well and run an
+// int8 GEMM accumulating in fp32, then apply the scales on the device.
+// ReadAt and WriteAt work on whole vectors.
+func (d *Device) NewQuantizedBuffer(data []float32, dimensions uint32, memType MemoryType) (*Buffer, error) {
+	if len(data) == 0 {
+		return nil, errors.New("cuda: cannot create empty buffer")
+	}
+	if dimensions == 0 || len(data)%int(dimensions) != 0 {
+		return nil, fmt.Errorf("cuda: %d elements are not vectors of %d dimensions", len(data), dimensions)
+	}
+	image := quant.Pack(data, int(dimensions))
+	b, err := d.newBuffer(unsafe.Pointer(&image[0]), uint64(len(image)), memType, PrecisionInt8)
+	if err != nil {
+		return nil, err
+	}
+	b.vectors = len(data) / int(dimensions)
+	b.dims = int(dimensions)
+	return b, nil
 }
 
 // NewEmptyBuffer creates an uninitialized GPU buffer.
 func (d *Device) NewEmptyBuffer(count uint64, memType MemoryType) (*Buffer, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	return d.newBuffer(nil, count, memType, PrecisionFP32)
+}
 
-	ptr := C.cuda_create_buffer(
-		d.ptr,
-		nil,
-		C.size_t(count),
-		C.int(memType),
-	)
+func (d *Device) newBuffer(data unsafe.Pointer, count uint64, memType MemoryType, precision Precision) (*Buffer, error) {
+	s, ok := d.streams.Acquire()
+	if !ok {
+		return nil, ErrDeviceReleased
+	}
+	defer d.streams.Release(s)
 
-	if ptr == nil {
-		errMsg := C.GoString(C.cuda_get_last_error())
-		C.cuda_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrBufferCreation, errMsg)
+	var ptr *C.CudaBuffer
+	err := call(ErrBufferCreation, func() bool {
+		ptr = C.cuda_create_buffer(s, data, C.size_t(count), C.int(memType), C.int(precision))
+		return ptr != nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &Buffer{
-		ptr:    ptr,
-		size:   count * 4,
-		device: d,
+		ptr:       ptr,
+		size:      count * precision.elementSize(),
+		precision: precision,
+		device:    d,
 	}, nil
 }
 
-// Release frees the buffer resources.
+// Release frees the buffer resources once operations using it finish.
 func (b *Buffer) Release() {
-	if b.ptr != nil {
+	if b.life.Close() && b.ptr != nil {
 		C.cuda_release_buffer(b.ptr)
 		b.ptr = nil
 	}
@@ -551,113 +1152,320 @@ func (b *Buffer) Size() uint64 {
 	return b.size
 }
 
-// ReadFloat32 reads float32 values from the buffer.
+// Precision returns how the buffer stores its elements.
+func (b *Buffer) Precision() Precision {
+	return b.precision
+}
+
+// requireFP32 returns ErrPrecision if any of buffers stores half precision
+// or int8.
+func requireFP32(buffers ...*Buffer) error {
+	for _, b := range buffers {
+		if b != nil && b.precision != PrecisionFP32 {
+			return fmt.Errorf("%w: %s", ErrPrecision, b.precision)
+		}
+	}
+	return nil
+}
+
+// use marks the start of an operation on each buffer and borrows a stream
+// for it. The returned function ends the operation.
+func (d *Device) use(buffers ...*Buffer) (*C.CudaStream, func(), error) {
+	for i, b := range buffers {
+		if b == nil || !b.life.Use() {
+			for _, held := range buffers[:i] {
+				held.life.Done()
+			}
+			return nil, nil, ErrInvalidBuffer
+		}
+	}
+	s, ok := d.streams.Acquire()
+	if !ok {
+		for _, b := range buffers {
+			b.life.Done()
+		}
+		return nil, nil, ErrDeviceReleased
+	}
+	return s, func() {
+		d.streams.Release(s)
+		for _, b := range buffers {
+			b.life.Done()
+		}
+	}, nil
+}
+
+// ReadFloat32 reads the first count float32 values from the buffer. It
+// returns nil on failure; use ReadAt to get the error.
 func (b *Buffer) ReadFloat32(count int) []float32 {
-	if count <= 0 || uint64(count*4) > b.size {
+	result, err := b.ReadAt(0, count)
+	if err != nil {
 		return nil
 	}
+	return result
+}
+
+// ReadAt reads count float32 values starting at element offset.
+// Half-precision elements are widened to float32 and int8 elements
+// dequantized; an int8 range must cover whole vectors.
+func (b *Buffer) ReadAt(offset, count int) ([]float32, error) {
+	if err := checkRange(b, offset, count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return []float32{}, nil
+	}
+	s, done, err := b.device.use(b)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if b.precision == PrecisionInt8 {
+		scalesAt, scalesLen, err := int8Range(offset, count, b.vectors, b.dims)
+		if err != nil {
+			return nil, err
+		}
+		codes := make([]int8, count)
+		scales := make([]float32, scalesLen/4)
+		err = call(errCopyFailed, func() bool {
+			return C.cuda_buffer_copy_to_host(s, b.ptr, unsafe.Pointer(&codes[0]), C.size_t(offset), C.size_t(count)) == 0 &&
+				C.cuda_buffer_copy_to_host(s, b.ptr, unsafe.Pointer(&scales[0]), C.size_t(scalesAt), C.size_t(scalesLen)) == 0
+		})
+		if err != nil {
+			return nil, err
+		}
+		result := make([]float32, count)
+		quant.Dequantize(result, codes, scales, b.dims)
+		return result, nil
+	}
+	if b.precision != PrecisionFP32 {
+		packed := make([]uint16, count)
+		err = call(errCopyFailed, func() bool {
+			return C.cuda_buffer_copy_to_host(s, b.ptr, unsafe.Pointer(&packed[0]), C.size_t(offset), C.size_t(count)) == 0
+		})
+		if err != nil {
+			return nil, err
+		}
+		return b.precision.decode(packed), nil
+	}
 
 	result := make([]float32, count)
-	ret := C.cuda_buffer_copy_to_host(b.ptr, (*C.float)(unsafe.Pointer(&result[0])), C.size_t(count))
-	if ret != 0 {
+	err = call(errCopyFailed, func() bool {
+		return C.cuda_buffer_copy_to_host(s, b.ptr, unsafe.Pointer(&result[0]), C.size_t(offset), C.size_t(count)) == 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// WriteFloat32 copies data into the buffer starting at element offset. It
+// is the same as WriteAt.
+func (b *Buffer) WriteFloat32(offset int, data []float32) error {
+	return b.WriteAt(offset, data)
+}
+
+// WriteAt copies data into the buffer starting at element offset, so part
+// of a buffer can be updated without uploading all of it again. Data is
+// converted to the buffer's precision; int8 data must be whole vectors,
+// which are quantized.
+func (b *Buffer) WriteAt(offset int, data []float32) error {
+	if err := checkRange(b, offset, len(data)); err != nil {
+		return err
+	}
+	if len(data) == 0 {
 		return nil
 	}
-	return result
+	s, done, err := b.device.use(b)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if b.precision == PrecisionInt8 {
+		scalesAt, scalesLen, err := int8Range(offset, len(data), b.vectors, b.dims)
+		if err != nil {
+			return err
+		}
+		codes := make([]int8, len(data))
+		scales := make([]float32, scalesLen/4)
+		quant.Quantize(codes, scales, data, b.dims)
+		return call(errWriteFailed, func() bool {
+			return C.cuda_buffer_copy_from_host(s, b.ptr, unsafe.Pointer(&codes[0]), C.size_t(offset), C.size_t(len(codes))) == 0 &&
+				C.cuda_buffer_copy_from_host(s, b.ptr, unsafe.Pointer(&scales[0]), C.size_t(scalesAt), C.size_t(scalesLen)) == 0
+		})
+	}
+
+	src := unsafe.Pointer(&data[0])
+	if b.precision != PrecisionFP32 {
+		packed := b.precision.encode(data)
+		src = unsafe.Pointer(&packed[0])
+	}
+	return call(errWriteFailed, func() bool {
+		return C.cuda_buffer_copy_from_host(s, b.ptr, src, C.size_t(offset), C.size_t(len(data))) == 0
+	})
+}
+
+// checkRange returns ErrOutOfRange unless elements [offset, offset+count)
+// lie within b.
+func checkRange(b *Buffer, offset, count int) error {
+	if b == nil {
+		return ErrInvalidBuffer
+	}
+	elements := b.size / b.precision.elementSize()
+	if b.precision == PrecisionInt8 {
+		elements = uint64(b.vectors * b.dims)
+	}
+	if offset < 0 || count < 0 || uint64(offset+count) > elements {
+		return fmt.Errorf("%w: elements [%d, %d) of %d", ErrOutOfRange, offset, offset+count, elements)
+	}
+	return nil
+}
+
+// CopyFrom copies count elements from src (typically a pinned staging
+// buffer) into this buffer starting at element offset. Both buffers must
+// have the same precision, which is not int8.
+func (b *Buffer) CopyFrom(src *Buffer, offset, count int) error {
+	if b != nil && src != nil && (b.precision != src.precision || b.precision == PrecisionInt8) {
+		return fmt.Errorf("%w: copying %s into %s", ErrPrecision, src.precision, b.precision)
+	}
+	s, done, err := b.device.use(b, src)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return call(errCopyFailed, func() bool {
+		return C.cuda_buffer_copy_from_buffer(s, b.ptr, C.size_t(offset), src.ptr, C.size_t(count)) == 0
+	})
 }
 
 // NormalizeVectors normalizes vectors in-place to unit length.
 func (d *Device) NormalizeVectors(vectors *Buffer, n, dimensions uint32) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	ret := C.cuda_normalize_vectors(d.ptr, vectors.ptr, C.uint(n), C.uint(dimensions))
-	if ret != 0 {
-		errMsg := C.GoString(C.cuda_get_last_error())
-		C.cuda_clear_error()
-		return fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
+	if err := requireFP32(vectors); err != nil {
+		return err
 	}
-	return nil
+	s, done, err := d.use(vectors)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return call(ErrKernelExecution, func() bool {
+		return C.cuda_normalize_vectors(s, vectors.ptr, C.uint(n), C.uint(dimensions)) == 0
+	})
 }
 
 // CosineSimilarity computes cosine similarity between query and all embeddings.
 func (d *Device) CosineSimilarity(embeddings, query, scores *Buffer,
 	n, dimensions uint32, normalized bool) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	if err := requireFP32(embeddings, query, scores); err != nil {
+		return err
+	}
+	s, done, err := d.use(embeddings, query, scores)
+	if err != nil {
+		return err
+	}
+	defer done()
 
 	normalizedInt := 0
 	if normalized {
 		normalizedInt = 1
 	}
 
-	ret := C.cuda_cosine_similarity(d.ptr, embeddings.ptr, query.ptr, scores.ptr,
-		C.uint(n), C.uint(dimensions), C.int(normalizedInt))
-	if ret != 0 {
-		errMsg := C.GoString(C.cuda_get_last_error())
-		C.cuda_clear_error()
-		return fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
-	}
-	return nil
+	return call(ErrKernelExecution, func() bool {
+		return C.cuda_cosine_similarity(s, embeddings.ptr, query.ptr, scores.ptr,
+			C.uint(n), C.uint(dimensions), C.int(normalizedInt)) == 0
+	})
 }
 
 // TopK finds the k highest scoring indices.
 func (d *Device) TopK(scores *Buffer, n, k uint32) ([]uint32, []float32, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	if err := requireFP32(scores); err != nil {
+		return nil, nil, err
+	}
+	s, done, err := d.use(scores)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
 
 	indices := make([]uint32, k)
 	topkScores := make([]float32, k)
 
-	ret := C.cuda_topk(d.ptr, scores.ptr,
-		(*C.uint)(unsafe.Pointer(&indices[0])),
-		(*C.float)(unsafe.Pointer(&topkScores[0])),
-		C.uint(n), C.uint(k))
-	if ret != 0 {
-		errMsg := C.GoString(C.cuda_get_last_error())
-		C.cuda_clear_error()
-		return nil, nil, fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
+	err = call(ErrKernelExecution, func() bool {
+		return C.cuda_topk(s, scores.ptr,
+			(*C.uint)(unsafe.Pointer(&indices[0])),
+			(*C.float)(unsafe.Pointer(&topkScores[0])),
+			C.uint(n), C.uint(k)) == 0
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return indices, topkScores, nil
 }
 
-// Search performs a complete similarity search.
-func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, normalized bool) ([]SearchResult, error) {
+// Search performs a complete similarity search on one stream and returns
+// up to k results, best first; see SearchOptions. The query and score
+// buffers are per-stream scratch memory, so concurrent searches do not
+// allocate device memory. Half-precision and int8 embeddings are searched
+// as a batch of one, whose GEMM takes mixed precision.
+func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, opts SearchOptions) ([]SearchResult, error) {
 	if k <= 0 {
 		return nil, nil
 	}
 	if k > int(n) {
 		k = int(n)
 	}
-
-	// Create query buffer
-	queryBuf, err := d.NewBuffer(query, MemoryDevice)
+	if len(query) < int(dimensions) {
+		return nil, fmt.Errorf("cuda: query has %d dimensions, want %d", len(query), dimensions)
+	}
+	dot, minScore, err := opts.params(n)
 	if err != nil {
 		return nil, err
 	}
-	defer queryBuf.Release()
+	if embeddings != nil && embeddings.precision != PrecisionFP32 {
+		results, err := d.SearchBatch(embeddings, [][]float32{query}, n, dimensions, k, opts)
+		if err != nil {
+			return nil, err
+		}
+		return results[0], nil
+	}
 
-	// Create scores buffer
-	scoresBuf, err := d.NewEmptyBuffer(uint64(n), MemoryDevice)
+	s, done, err := d.use(embeddings)
 	if err != nil {
 		return nil, err
 	}
-	defer scoresBuf.Release()
+	defer done()
 
-	// Compute similarities
-	if err := d.CosineSimilarity(embeddings, queryBuf, scoresBuf, n, dimensions, normalized); err != nil {
-		return nil, err
+	normalizedInt := 0
+	if dot {
+		normalizedInt = 1
+	}
+	var filter *C.uint64_t
+	if opts.Filter != nil {
+		filter = (*C.uint64_t)(unsafe.Pointer(&opts.Filter[0]))
 	}
 
-	// Find top-k
-	indices, scores, err := d.TopK(scoresBuf, n, uint32(k))
+	indices := make([]uint32, k)
+	scores := make([]float32, k)
+	var found C.int
+	err = call(ErrKernelExecution, func() bool {
+		found = C.cuda_search(s, embeddings.ptr, (*C.float)(unsafe.Pointer(&query[0])),
+			C.uint(n), C.uint(dimensions), C.uint(k), C.int(normalizedInt),
+			C.float(minScore), filter,
+			(*C.uint)(unsafe.Pointer(&indices[0])),
+			(*C.float)(unsafe.Pointer(&scores[0])))
+		return found >= 0
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	// Build results
-	results := make([]SearchResult, k)
-	for i := 0; i < k; i++ {
+	results := make([]SearchResult, found)
+	for i := range results {
 		results[i] = SearchResult{
 			Index: indices[i],
 			Score: scores[i],
@@ -667,6 +1475,77 @@ func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint3
 	return results, nil
 }
 
+// SearchBatch searches for every query on one stream and returns up to k
+// results for each, best first, in query order; opts applies to all of
+// them. One GEMM scores the whole batch and one transfer brings the scores
+// back, so a batch costs a single launch instead of one per query.
+func (d *Device) SearchBatch(embeddings *Buffer, queries [][]float32, n, dimensions uint32, k int, opts SearchOptions) ([][]SearchResult, error) {
+	if k <= 0 || len(queries) == 0 {
+		return make([][]SearchResult, len(queries)), nil
+	}
+	if k > int(n) {
+		k = int(n)
+	}
+	packed, err := packQueries(queries, n, dimensions, opts)
+	if err != nil {
+		return nil, err
+	}
+	if embeddings != nil && embeddings.precision == PrecisionInt8 && int(dimensions) != embeddings.dims {
+		return nil, fmt.Errorf("cuda: int8 buffer holds vectors of %d dimensions, not %d", embeddings.dims, dimensions)
+	}
+	dot, minScore, _ := opts.params(n)
+
+	s, done, err := d.use(embeddings)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	normalizedInt := 0
+	if dot {
+		normalizedInt = 1
+	}
+	var filter *C.uint64_t
+	if opts.Filter != nil {
+		filter = (*C.uint64_t)(unsafe.Pointer(&opts.Filter[0]))
+	}
+
+	// cuBLAS wants the queries in the embeddings' precision. Quantized
+	// queries are normed as dequantized, so cosine scores compare the
+	// vectors the GEMM multiplied.
+	hostQueries := packed
+	var packedQueries unsafe.Pointer
+	var scalesOffset int
+	switch embeddings.precision {
+	case PrecisionFP32:
+	case PrecisionInt8:
+		image := quant.Pack(packed, int(dimensions))
+		packedQueries = unsafe.Pointer(&image[0])
+		hostQueries = quant.Unpack(image, len(queries), int(dimensions))
+		scalesOffset = quant.ScalesOffset(embeddings.vectors * embeddings.dims)
+	default:
+		converted := embeddings.precision.encode(packed)
+		packedQueries = unsafe.Pointer(&converted[0])
+	}
+
+	indices := make([]uint32, len(queries)*k)
+	scores := make([]float32, len(queries)*k)
+	found := make([]int32, len(queries))
+	err = call(ErrKernelExecution, func() bool {
+		return C.cuda_search_batch(s, embeddings.ptr, (*C.float)(unsafe.Pointer(&hostQueries[0])),
+			packedQueries, C.size_t(scalesOffset),
+			C.uint(len(queries)), C.uint(n), C.uint(dimensions), C.uint(k), C.int(normalizedInt),
+			C.float(minScore), filter,
+			(*C.uint)(unsafe.Pointer(&indices[0])),
+			(*C.float)(unsafe.Pointer(&scores[0])),
+			(*C.int)(unsafe.Pointer(&found[0]))) == 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unpackBatch(indices, scores, found, k), nil
+}
+
 // HasGPUHardware returns true if CUDA GPU hardware is available.
 func HasGPUHardware() bool {
 	return IsAvailable()
@@ -13,23 +13,29 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
 )
 
 // Errors
 var (
-	ErrCUDANotAvailable = errors.New("cuda: CUDA is not available (build without cuda tag or unsupported platform)")
-	ErrDeviceCreation   = errors.New("cuda: failed to create CUDA device")
-	ErrBufferCreation   = errors.New("cuda: failed to create buffer")
-	ErrKernelExecution  = errors.New("cuda: kernel execution failed")
+	ErrCUDANotAvailable = gpuerr.Sentinel("cuda: CUDA is not available (build without cuda tag or unsupported platform)", gpuerr.ErrNotAvailable)
+	ErrDeviceCreation   = gpuerr.Sentinel("cuda: failed to create CUDA device", gpuerr.ErrNotAvailable)
+	ErrBufferCreation   = gpuerr.Sentinel("cuda: failed to create buffer", gpuerr.ErrOutOfMemory)
+	ErrKernelExecution  = gpuerr.Sentinel("cuda: kernel execution failed", gpuerr.ErrKernel)
 	ErrInvalidBuffer    = errors.New("cuda: invalid buffer")
+	ErrDeviceReleased   = errors.New("cuda: device released")
+	ErrOutOfRange       = errors.New("cuda: range exceeds buffer size")
+	ErrPrecision        = errors.New("cuda: operation does not support this buffer precision")
 )
 
 // Runtime GPU detection cache
 var (
-	gpuDetected     bool
-	gpuDetectedOnce sync.Once
-	gpuName         string
-	gpuMemoryMB     int
+	gpuDetected      bool
+	gpuDetectedOnce  sync.Once
+	gpuName          string
+	gpuMemoryMB      int
+	gpuDriverVersion string
 )
 
 // detectGPURuntime checks for NVIDIA GPU using nvidia-smi (no CUDA required)
@@ -40,7 +46,7 @@ func detectGPURuntime() {
 		}
 
 		// Try nvidia-smi to detect GPU
-		cmd := exec.Command("nvidia-smi", "--query-gpu=name,memory.total", "--format=csv,noheader,nounits")
+		cmd := exec.Command("nvidia-smi", "--query-gpu=name,memory.total,driver_version", "--format=csv,noheader,nounits")
 		output, err := cmd.Output()
 		if err != nil {
 			return
@@ -51,8 +57,8 @@ func detectGPURuntime() {
 			return
 		}
 
-		// Parse first GPU: "NVIDIA GeForce RTX 3080, 10240"
-		parts := strings.Split(lines, ",")
+		// Parse first GPU: "NVIDIA GeForce RTX 3080, 10240, 550.54.14"
+		parts := strings.Split(strings.SplitN(lines, "\n", 2)[0], ",")
 		if len(parts) >= 1 {
 			gpuName = strings.TrimSpace(parts[0])
 			gpuDetected = true
@@ -64,6 +70,9 @@ func detectGPURuntime() {
 				gpuMemoryMB = mem
 			}
 		}
+		if len(parts) >= 3 {
+			gpuDriverVersion = strings.TrimSpace(parts[2])
+		}
 	})
 }
 
@@ -87,6 +96,9 @@ type SearchResult struct {
 	Score float32
 }
 
+// Compiled reports whether this binary was built with CUDA support.
+const Compiled = false
+
 // IsAvailable checks if CUDA/GPU is available.
 // In stub mode, we detect GPU via nvidia-smi but can't use it for acceleration.
 // This allows informative logging about GPU presence.
@@ -129,6 +141,39 @@ func DeviceCount() int {
 	return 0
 }
 
+// UnavailableReason explains why CUDA cannot be used by this binary.
+func UnavailableReason() string {
+	if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		return "CUDA is only supported on Linux and Windows"
+	}
+	detectGPURuntime()
+	if gpuDetected {
+		return fmt.Sprintf("binary built without CUDA support; %s detected, build with -tags cuda", gpuName)
+	}
+	return "binary built without CUDA support; build with -tags cuda"
+}
+
+// DriverVersion returns the NVIDIA driver version reported by nvidia-smi,
+// or "" if no driver is installed.
+func DriverVersion() string {
+	detectGPURuntime()
+	return gpuDriverVersion
+}
+
+// QueryDevices returns the GPU found by nvidia-smi, if any. The device is
+// informational only: it cannot run CUDA kernels in this build.
+func QueryDevices() []DeviceProperties {
+	detectGPURuntime()
+	if !gpuDetected {
+		return nil
+	}
+	return []DeviceProperties{{
+		Name:          gpuName,
+		MemoryBytes:   uint64(gpuMemoryMB) * 1024 * 1024,
+		DriverVersion: gpuDriverVersion,
+	}}
+}
+
 // NewDevice returns an error on systems without CUDA.
 // Even with GPU detected, CUDA operations require the cuda build tag.
 func NewDevice(deviceID int) (*Device, error) {
@@ -139,9 +184,17 @@ func NewDevice(deviceID int) (*Device, error) {
 	return nil, ErrCUDANotAvailable
 }
 
+// NewDeviceWithStreams returns the same error as NewDevice.
+func NewDeviceWithStreams(deviceID, streams int) (*Device, error) {
+	return NewDevice(deviceID)
+}
+
 // Release is a no-op stub.
 func (d *Device) Release() {}
 
+// Streams returns 0.
+func (d *Device) Streams() int { return 0 }
+
 // ID returns 0.
 func (d *Device) ID() int { return 0 }
 
@@ -162,6 +215,16 @@ func (d *Device) NewBuffer(data []float32, memType MemoryType) (*Buffer, error)
 	return nil, ErrCUDANotAvailable
 }
 
+// NewBufferWithPrecision returns an error.
+func (d *Device) NewBufferWithPrecision(data []float32, memType MemoryType, precision Precision) (*Buffer, error) {
+	return nil, ErrCUDANotAvailable
+}
+
+// NewQuantizedBuffer returns an error.
+func (d *Device) NewQuantizedBuffer(data []float32, dimensions uint32, memType MemoryType) (*Buffer, error) {
+	return nil, ErrCUDANotAvailable
+}
+
 // NewEmptyBuffer returns an error.
 func (d *Device) NewEmptyBuffer(count uint64, memType MemoryType) (*Buffer, error) {
 	return nil, ErrCUDANotAvailable
@@ -173,9 +236,32 @@ func (b *Buffer) Release() {}
 // Size returns 0.
 func (b *Buffer) Size() uint64 { return 0 }
 
+// Precision returns PrecisionFP32.
+func (b *Buffer) Precision() Precision { return PrecisionFP32 }
+
 // ReadFloat32 returns nil.
 func (b *Buffer) ReadFloat32(count int) []float32 { return nil }
 
+// ReadAt returns an error.
+func (b *Buffer) ReadAt(offset, count int) ([]float32, error) {
+	return nil, ErrCUDANotAvailable
+}
+
+// WriteAt returns an error.
+func (b *Buffer) WriteAt(offset int, data []float32) error {
+	return ErrCUDANotAvailable
+}
+
+// WriteFloat32 returns an error.
+func (b *Buffer) WriteFloat32(offset int, data []float32) error {
+	return ErrCUDANotAvailable
+}
+
+// CopyFrom returns an error.
+func (b *Buffer) CopyFrom(src *Buffer, offset, count int) error {
+	return ErrCUDANotAvailable
+}
+
 // NormalizeVectors returns an error.
 func (d *Device) NormalizeVectors(vectors *Buffer, n, dimensions uint32) error {
 	return ErrCUDANotAvailable
@@ -192,6 +278,11 @@ func (d *Device) TopK(scores *Buffer, n, k uint32) ([]uint32, []float32, error)
 }
 
 // Search returns an error.
-func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, normalized bool) ([]SearchResult, error) {
+func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, opts SearchOptions) ([]SearchResult, error) {
+	return nil, ErrCUDANotAvailable
+}
+
+// SearchBatch returns an error.
+func (d *Device) SearchBatch(embeddings *Buffer, queries [][]float32, n, dimensions uint32, k int, opts SearchOptions) ([][]SearchResult, error) {
 	return nil, ErrCUDANotAvailable
 }
@@ -88,6 +88,12 @@ func TestBufferMethodsStub(t *testing.T) {
 	if buffer.ReadFloat32(10) != nil {
 		t.Error("ReadFloat32() should return nil")
 	}
+	if _, err := buffer.ReadAt(0, 10); err == nil {
+		t.Error("ReadAt() should return error")
+	}
+	if err := buffer.WriteAt(0, []float32{1}); err == nil {
+		t.Error("WriteAt() should return error")
+	}
 }
 
 func TestDeviceBufferCreationStub(t *testing.T) {
@@ -98,6 +104,16 @@ func TestDeviceBufferCreationStub(t *testing.T) {
 		t.Errorf("NewBuffer() error = %v, want ErrCUDANotAvailable", err)
 	}
 
+	_, err = device.NewBufferWithPrecision([]float32{1.0}, MemoryDevice, PrecisionFP16)
+	if err != ErrCUDANotAvailable {
+		t.Errorf("NewBufferWithPrecision() error = %v, want ErrCUDANotAvailable", err)
+	}
+
+	_, err = device.NewQuantizedBuffer([]float32{1.0}, 1, MemoryDevice)
+	if err != ErrCUDANotAvailable {
+		t.Errorf("NewQuantizedBuffer() error = %v, want ErrCUDANotAvailable", err)
+	}
+
 	_, err = device.NewEmptyBuffer(100, MemoryDevice)
 	if err != ErrCUDANotAvailable {
 		t.Errorf("NewEmptyBuffer() error = %v, want ErrCUDANotAvailable", err)
@@ -123,7 +139,7 @@ func TestDeviceOperationsStub(t *testing.T) {
 		t.Errorf("TopK() error = %v, want ErrCUDANotAvailable", err)
 	}
 
-	_, err = device.Search(&buffer, []float32{1.0}, 10, 1, 5, true)
+	_, err = device.Search(&buffer, []float32{1.0}, 10, 1, 5, SearchOptions{Normalized: true})
 	if err != ErrCUDANotAvailable {
 		t.Errorf("Search() error = %v, want ErrCUDANotAvailable", err)
 	}
@@ -155,3 +171,17 @@ func TestErrorVariables(t *testing.T) {
 		t.Error("ErrInvalidBuffer should not be nil")
 	}
 }
+
+func TestSearchAsyncStub(t *testing.T) {
+	done, err := (&Device{}).SearchAsync(nil, []float32{1, 0}, 2, 2, 1, SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchAsync: %v", err)
+	}
+	result := <-done
+	if !errors.Is(result.Err, ErrCUDANotAvailable) {
+		t.Errorf("Err = %v, want ErrCUDANotAvailable", result.Err)
+	}
+	if _, ok := <-done; ok {
+		t.Error("channel should be closed after the result")
+	}
+}
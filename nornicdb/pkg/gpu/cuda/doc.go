@@ -26,6 +26,35 @@
 //   - Build with: go build -tags cuda
 //   - Without CUDA: builds with stub implementations
 //
+// Concurrency:
+//
+// A Device is safe for concurrent use. It owns a pool of CUDA streams
+// (NewDeviceWithStreams sets the size; NewDevice uses lanes.DefaultLanes),
+// each with its own cuBLAS handle and search scratch memory, so searches
+// from different goroutines overlap on the GPU. SearchAsync starts a search
+// without waiting for it, so one goroutine can keep every stream busy;
+// when the queries are known together, SearchBatch scores them all in one
+// GEMM on a single stream.
+// Buffer.Release and Device.Release wait for operations in progress; later
+// operations return ErrInvalidBuffer or ErrDeviceReleased.
+//
+// Half precision:
+//
+// NewBufferWithPrecision stores embeddings as fp16 or bf16, halving their
+// device memory. Search and SearchBatch run a mixed-precision GEMM that
+// accumulates in fp32; ReadAt and WriteAt convert to and from float32.
+//
+//	buf, _ := device.NewBufferWithPrecision(embeddings, cuda.MemoryDevice, cuda.PrecisionFP16)
+//
+// Int8 quantization:
+//
+// NewQuantizedBuffer stores each vector as int8 codes with one float32
+// scale, a quarter of the fp32 footprint. Queries are quantized the same
+// way for an int8 GEMM, and the result is rescaled per query and, for
+// normalized embeddings, per row.
+//
+//	buf, _ := device.NewQuantizedBuffer(embeddings, dims, cuda.MemoryDevice)
+//
 // Example usage:
 //
 //	if cuda.IsAvailable() {
@@ -39,6 +68,6 @@
 //	    buf, _ := device.NewBuffer(embeddings, cuda.MemoryDevice)
 //
 //	    // Perform similarity search
-//	    results, _ := device.Search(buf, query, n, dims, k)
+//	    results, _ := device.Search(buf, query, n, dims, k, cuda.SearchOptions{})
 //	}
 package cuda
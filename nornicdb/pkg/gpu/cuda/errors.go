@@ -0,0 +1,83 @@
+package cuda
+
+import "github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+
+// CUDA runtime error codes (cudaError_t) that classify a failure.
+const (
+	cudaErrorMemoryAllocation       = 2
+	cudaErrorInitializationError    = 3
+	cudaErrorStubLibrary            = 34
+	cudaErrorInsufficientDriver     = 35
+	cudaErrorDevicesUnavailable     = 46
+	cudaErrorInvalidDeviceFunction  = 98
+	cudaErrorNoDevice               = 100
+	cudaErrorInvalidDevice          = 101
+	cudaErrorInvalidKernelImage     = 200
+	cudaErrorNoKernelImageForDevice = 209
+	cudaErrorECCUncorrectable       = 214
+	cudaErrorInvalidPtx             = 218
+	cudaErrorUnsupportedPtxVersion  = 222
+	cudaErrorIllegalAddress         = 700
+	cudaErrorLaunchOutOfResources   = 701
+	cudaErrorLaunchTimeout          = 702
+	cudaErrorHardwareStackError     = 714
+	cudaErrorIllegalInstruction     = 715
+	cudaErrorMisalignedAddress      = 716
+	cudaErrorInvalidAddressSpace    = 717
+	cudaErrorInvalidPc              = 718
+	cudaErrorLaunchFailure          = 719
+)
+
+// cuBLAS status codes (cublasStatus_t) that classify a failure.
+const (
+	cublasStatusNotInitialized  = 1
+	cublasStatusAllocFailed     = 3
+	cublasStatusArchMismatch    = 8
+	cublasStatusExecutionFailed = 13
+	cublasStatusInternalError   = 14
+)
+
+// classifyRuntime maps a cudaError_t to a shared error kind, or nil.
+//
+// Faults during a kernel (illegal address, launch failure, ...) are sticky:
+// the CUDA context is unusable afterwards, so they count as a lost device
+// rather than a kernel error.
+func classifyRuntime(code int) error {
+	switch code {
+	case cudaErrorMemoryAllocation:
+		return gpuerr.ErrOutOfMemory
+	case cudaErrorInitializationError, cudaErrorStubLibrary, cudaErrorInsufficientDriver,
+		cudaErrorDevicesUnavailable, cudaErrorNoDevice, cudaErrorInvalidDevice:
+		return gpuerr.ErrNotAvailable
+	case cudaErrorECCUncorrectable, cudaErrorIllegalAddress, cudaErrorLaunchTimeout,
+		cudaErrorHardwareStackError, cudaErrorIllegalInstruction, cudaErrorMisalignedAddress,
+		cudaErrorInvalidAddressSpace, cudaErrorInvalidPc, cudaErrorLaunchFailure:
+		return gpuerr.ErrDeviceLost
+	case cudaErrorInvalidDeviceFunction, cudaErrorInvalidKernelImage, cudaErrorNoKernelImageForDevice,
+		cudaErrorInvalidPtx, cudaErrorUnsupportedPtxVersion, cudaErrorLaunchOutOfResources:
+		return gpuerr.ErrKernel
+	}
+	return nil
+}
+
+// classifyCUBLAS maps a cublasStatus_t to a shared error kind, or nil.
+func classifyCUBLAS(status int) error {
+	switch status {
+	case cublasStatusNotInitialized:
+		return gpuerr.ErrNotAvailable
+	case cublasStatusAllocFailed:
+		return gpuerr.ErrOutOfMemory
+	case cublasStatusArchMismatch, cublasStatusExecutionFailed, cublasStatusInternalError:
+		return gpuerr.ErrKernel
+	}
+	return nil
+}
+
+// newError builds the error returned for a failed op from the last native
+// error: a CUDA runtime code, or failing that a cuBLAS status.
+func newError(op error, code, cublasStatus int, msg string) error {
+	if code == 0 && cublasStatus != 0 {
+		return gpuerr.New("cuda", op, classifyCUBLAS(cublasStatus), cublasStatus, msg)
+	}
+	return gpuerr.New("cuda", op, classifyRuntime(code), code, msg)
+}
@@ -0,0 +1,55 @@
+package cuda
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+)
+
+func TestNewErrorClassifiesRuntimeCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     error
+		code   int
+		status int
+		want   error
+	}{
+		{"cudaMalloc out of memory", ErrBufferCreation, cudaErrorMemoryAllocation, 0, gpuerr.ErrOutOfMemory},
+		{"no device", ErrDeviceCreation, cudaErrorNoDevice, 0, gpuerr.ErrNotAvailable},
+		{"old driver", ErrDeviceCreation, cudaErrorInsufficientDriver, 0, gpuerr.ErrNotAvailable},
+		{"illegal address", ErrKernelExecution, cudaErrorIllegalAddress, 0, gpuerr.ErrDeviceLost},
+		{"buffer on faulted context", ErrBufferCreation, cudaErrorLaunchFailure, 0, gpuerr.ErrDeviceLost},
+		{"missing kernel image", ErrKernelExecution, cudaErrorNoKernelImageForDevice, 0, gpuerr.ErrKernel},
+		{"cuBLAS alloc", ErrDeviceCreation, 0, cublasStatusAllocFailed, gpuerr.ErrOutOfMemory},
+		{"cuBLAS gemv", ErrKernelExecution, 0, cublasStatusExecutionFailed, gpuerr.ErrKernel},
+		{"unknown code uses op kind", ErrBufferCreation, 999, 0, gpuerr.ErrOutOfMemory},
+		{"no code uses op kind", ErrKernelExecution, 0, 0, gpuerr.ErrKernel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newError(tt.op, tt.code, tt.status, "native message")
+			if !errors.Is(err, tt.op) {
+				t.Errorf("%v should match %v", err, tt.op)
+			}
+			if got := gpuerr.KindOf(err); got != tt.want {
+				t.Errorf("kind = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSentinelKinds(t *testing.T) {
+	if !errors.Is(ErrCUDANotAvailable, gpuerr.ErrNotAvailable) {
+		t.Error("ErrCUDANotAvailable should match gpuerr.ErrNotAvailable")
+	}
+	if !errors.Is(ErrBufferCreation, gpuerr.ErrOutOfMemory) {
+		t.Error("ErrBufferCreation should match gpuerr.ErrOutOfMemory")
+	}
+	if !errors.Is(ErrKernelExecution, gpuerr.ErrKernel) {
+		t.Error("ErrKernelExecution should match gpuerr.ErrKernel")
+	}
+	if gpuerr.KindOf(ErrInvalidBuffer) != nil {
+		t.Error("ErrInvalidBuffer is a usage error, not a device failure")
+	}
+}
@@ -0,0 +1,58 @@
+package cuda
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPrecision(t *testing.T) {
+	for _, tt := range []struct {
+		p    Precision
+		name string
+		size uint64
+	}{
+		{PrecisionFP32, "fp32", 4},
+		{PrecisionFP16, "fp16", 2},
+		{PrecisionBF16, "bf16", 2},
+		{PrecisionInt8, "int8", 1},
+	} {
+		if got := tt.p.String(); got != tt.name {
+			t.Errorf("String() = %q, want %q", got, tt.name)
+		}
+		if got := tt.p.elementSize(); got != tt.size {
+			t.Errorf("%s: elementSize() = %d, want %d", tt.name, got, tt.size)
+		}
+		if err := tt.p.check(); err != nil {
+			t.Errorf("%s: check() = %v", tt.name, err)
+		}
+	}
+	if err := Precision(7).check(); err == nil {
+		t.Error("check() accepted an unknown precision")
+	}
+}
+
+func TestPrecisionEncodeDecode(t *testing.T) {
+	data := []float32{0.6, -0.8, 0, 1e-3}
+	for _, p := range []Precision{PrecisionFP16, PrecisionBF16} {
+		got := p.decode(p.encode(data))
+		for i := range data {
+			if d := math.Abs(float64(got[i] - data[i])); d > 4e-3 {
+				t.Errorf("%s: element %d = %g, want %g", p, i, got[i], data[i])
+			}
+		}
+	}
+}
+
+func TestInt8Range(t *testing.T) {
+	// 3 vectors of 5: 15 codes, so the scales start at byte 16.
+	at, n, err := int8Range(5, 10, 3, 5)
+	if err != nil || at != 20 || n != 8 {
+		t.Errorf("int8Range(5, 10) = %d, %d, %v; want 20, 8", at, n, err)
+	}
+	for _, r := range [][2]int{{1, 5}, {0, 4}} {
+		if _, _, err := int8Range(r[0], r[1], 3, 5); !errors.Is(err, ErrPrecision) {
+			t.Errorf("int8Range(%d, %d) = %v, want ErrPrecision", r[0], r[1], err)
+		}
+	}
+}
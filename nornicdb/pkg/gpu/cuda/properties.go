@@ -0,0 +1,12 @@
+package cuda
+
+// DeviceProperties describes a CUDA device as reported by the driver.
+type DeviceProperties struct {
+	ID                 int
+	Name               string
+	MemoryBytes        uint64
+	ComputeMajor       int
+	ComputeMinor       int
+	MaxThreadsPerBlock int
+	DriverVersion      string
+}
@@ -0,0 +1,139 @@
+package cuda
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Metric selects how Search scores embeddings against the query.
+type Metric int
+
+const (
+	// MetricCosine scores by cosine similarity. It is the default.
+	MetricCosine Metric = iota
+	// MetricDot scores by raw dot product, for embeddings whose magnitude
+	// carries meaning. SearchOptions.Normalized has no effect.
+	MetricDot
+)
+
+// SearchOptions controls how Search scores and selects embeddings. The zero
+// value is a cosine search over unnormalized embeddings that returns the
+// top k whatever their score.
+type SearchOptions struct {
+	// Normalized reports that the embeddings and the query have unit
+	// length, so cosine similarity is computed as a plain dot product.
+	Normalized bool
+
+	// MinScore drops embeddings scoring below it before top-k selection,
+	// so Search may return fewer than k results. It applies only if
+	// HasMinScore is set, so that a threshold of 0 can be expressed.
+	MinScore    float32
+	HasMinScore bool
+
+	// Metric is the similarity function.
+	Metric Metric
+
+	// Filter restricts the search to a subset of rows: if it is not nil,
+	// row i is considered only if bit i%64 of Filter[i/64] is set. It must
+	// cover all n rows. NewFilter builds one.
+	Filter []uint64
+}
+
+// NewFilter returns a SearchOptions.Filter for n rows that admits rows.
+func NewFilter(n int, rows ...uint32) []uint64 {
+	filter := make([]uint64, (n+63)/64)
+	for _, row := range rows {
+		if int(row) < n {
+			filter[row/64] |= 1 << (row % 64)
+		}
+	}
+	return filter
+}
+
+// params validates o for a search over n rows and returns what the native
+// search takes: whether scores are plain dot products, and the score
+// threshold (-Inf if there is none).
+func (o SearchOptions) params(n uint32) (dot bool, minScore float32, err error) {
+	switch o.Metric {
+	case MetricCosine:
+		dot = o.Normalized
+	case MetricDot:
+		dot = true
+	default:
+		return false, 0, fmt.Errorf("cuda: unknown search metric %d", o.Metric)
+	}
+	if o.Filter != nil && len(o.Filter)*64 < int(n) {
+		return false, 0, fmt.Errorf("cuda: filter covers %d rows, want %d", len(o.Filter)*64, n)
+	}
+	minScore = float32(math.Inf(-1))
+	if o.HasMinScore {
+		minScore = o.MinScore
+	}
+	return dot, minScore, nil
+}
+
+// AsyncResult is the outcome of a SearchAsync call.
+type AsyncResult struct {
+	Results []SearchResult
+	Err     error
+}
+
+// SearchAsync starts a Search and returns at once. The channel receives
+// one AsyncResult and is then closed. Searches started together run on
+// separate streams, up to Streams() at a time, so their kernels and host
+// transfers overlap; the rest wait for a free stream without blocking the
+// caller.
+//
+// Invalid arguments are reported by the returned error. query and
+// opts.Filter are copied, so the caller may reuse them. Releasing the
+// embeddings before the result arrives fails the search with
+// ErrInvalidBuffer.
+func (d *Device) SearchAsync(embeddings *Buffer, query []float32, n, dimensions uint32, k int, opts SearchOptions) (<-chan AsyncResult, error) {
+	if len(query) < int(dimensions) {
+		return nil, fmt.Errorf("cuda: query has %d dimensions, want %d", len(query), dimensions)
+	}
+	if _, _, err := opts.params(n); err != nil {
+		return nil, err
+	}
+	query = slices.Clone(query)
+	opts.Filter = slices.Clone(opts.Filter)
+
+	done := make(chan AsyncResult, 1)
+	go func() {
+		results, err := d.Search(embeddings, query, n, dimensions, k, opts)
+		done <- AsyncResult{Results: results, Err: err}
+		close(done)
+	}()
+	return done, nil
+}
+
+// packQueries validates a SearchBatch and lays queries out as one
+// row-major len(queries) × dimensions matrix, the form the native batch
+// search takes.
+func packQueries(queries [][]float32, n, dimensions uint32, opts SearchOptions) ([]float32, error) {
+	if _, _, err := opts.params(n); err != nil {
+		return nil, err
+	}
+	packed := make([]float32, 0, len(queries)*int(dimensions))
+	for i, query := range queries {
+		if len(query) < int(dimensions) {
+			return nil, fmt.Errorf("cuda: query %d has %d dimensions, want %d", i, len(query), dimensions)
+		}
+		packed = append(packed, query[:dimensions]...)
+	}
+	return packed, nil
+}
+
+// unpackBatch splits the output of a native batch search, k slots per
+// query of which found[i] are filled, into per-query results.
+func unpackBatch(indices []uint32, scores []float32, found []int32, k int) [][]SearchResult {
+	results := make([][]SearchResult, len(found))
+	for i, m := range found {
+		results[i] = make([]SearchResult, m)
+		for j := range results[i] {
+			results[i][j] = SearchResult{Index: indices[i*k+j], Score: scores[i*k+j]}
+		}
+	}
+	return results
+}
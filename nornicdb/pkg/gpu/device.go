@@ -0,0 +1,445 @@
+// Package gpu - one interface over the CUDA, Metal, OpenCL and Vulkan
+// backends.
+//
+// Each backend package exposes its own Device and Buffer types, so code
+// that used them directly needed a branch per backend for every upload,
+// write, search and release. Device and DeviceBuffer are the operations
+// all four share; OpenDevice wraps the backend's device in them and
+// AutoSelectDevice opens the backend AutoSelect picks:
+//
+//	dev, err := gpu.AutoSelectDevice()
+//	if err != nil || dev == nil {
+//		return // CPU search
+//	}
+//	defer dev.Release()
+//	buf, err := dev.NewBuffer(embeddings)
+//	if err != nil {
+//		return err
+//	}
+//	defer buf.Release()
+//	hits, err := dev.Search(buf, query, rows, dims, 10, gpu.SearchOptions{})
+//
+// Backend-specific features (fp16 and int8 storage, CUDA streams, MPS)
+// remain in the backend packages.
+package gpu
+
+import (
+	"fmt"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/cuda"
+	"github.com/orneryd/nornicdb/pkg/gpu/metal"
+	"github.com/orneryd/nornicdb/pkg/gpu/opencl"
+	"github.com/orneryd/nornicdb/pkg/gpu/vulkan"
+)
+
+// Device is a GPU opened on one backend.
+type Device interface {
+	Backend() Backend
+	Name() string
+	MemoryMB() int
+
+	// NewBuffer uploads data to device memory.
+	NewBuffer(data []float32) (DeviceBuffer, error)
+	// NormalizeVectors scales the first n rows of buf to unit length.
+	NormalizeVectors(buf DeviceBuffer, n, dimensions int) error
+	// Search scores the first n rows of buf against query and returns the
+	// k best, highest score first.
+	Search(buf DeviceBuffer, query []float32, n, dimensions, k int, opts SearchOptions) ([]Hit, error)
+	// SearchBatch is Search for several queries in one pass.
+	SearchBatch(buf DeviceBuffer, queries [][]float32, n, dimensions, k int, opts SearchOptions) ([][]Hit, error)
+
+	Release()
+}
+
+// DeviceBuffer is a block of float32 values in device memory, created by
+// a Device. It can only be used with the Device that created it.
+type DeviceBuffer interface {
+	// Size returns the buffer size in bytes.
+	Size() uint64
+	// ReadAt copies count values starting at value offset to the host.
+	ReadAt(offset, count int) ([]float32, error)
+	// WriteAt overwrites values starting at value offset.
+	WriteAt(offset int, data []float32) error
+	Release()
+}
+
+// Hit is a search result: a row of the searched buffer and its score.
+type Hit struct {
+	Index uint32
+	Score float32
+}
+
+// Metric selects how Device.Search scores rows against the query.
+type Metric int
+
+const (
+	// MetricCosine scores by cosine similarity. It is the default.
+	MetricCosine Metric = iota
+	// MetricDot scores by raw dot product; SearchOptions.Normalized has
+	// no effect.
+	MetricDot
+)
+
+// SearchOptions controls how Device.Search scores and selects rows. It
+// mirrors the SearchOptions of the backend packages; the zero value is a
+// cosine search over unnormalized rows.
+type SearchOptions struct {
+	// Normalized reports that the rows and the query have unit length.
+	Normalized bool
+
+	// MinScore drops rows scoring below it, if HasMinScore is set.
+	MinScore    float32
+	HasMinScore bool
+
+	Metric Metric
+
+	// Filter, if not nil, admits row i only if bit i%64 of Filter[i/64]
+	// is set.
+	Filter []uint64
+}
+
+// OpenDevice opens device deviceID of backend. Metal has a single device
+// and ignores deviceID.
+func OpenDevice(backend Backend, deviceID int) (Device, error) {
+	switch backend {
+	case BackendCUDA:
+		if !cuda.IsAvailable() {
+			return nil, ErrGPUNotAvailable
+		}
+		d, err := cuda.NewDevice(deviceID)
+		if err != nil {
+			return nil, err
+		}
+		return cudaDevice{d}, nil
+	case BackendMetal:
+		if !metal.IsAvailable() {
+			return nil, ErrGPUNotAvailable
+		}
+		d, err := metal.NewDevice()
+		if err != nil {
+			return nil, err
+		}
+		return metalDevice{d}, nil
+	case BackendOpenCL:
+		if !opencl.IsAvailable() {
+			return nil, ErrGPUNotAvailable
+		}
+		d, err := opencl.NewDevice(deviceID)
+		if err != nil {
+			return nil, err
+		}
+		return openclDevice{d}, nil
+	case BackendVulkan:
+		if !vulkan.IsAvailable() {
+			return nil, ErrGPUNotAvailable
+		}
+		d, err := vulkan.NewDevice(deviceID)
+		if err != nil {
+			return nil, err
+		}
+		return vulkanDevice{d}, nil
+	default:
+		return nil, ErrGPUNotAvailable
+	}
+}
+
+// AutoSelectDevice opens device 0 of the backend AutoSelect picks. It
+// returns a nil Device and no error when no GPU is available or
+// NORNICDB_GPU_BACKEND disables acceleration.
+func AutoSelectDevice() (Device, error) {
+	backend, err := AutoSelect()
+	if err != nil || backend == BackendNone {
+		return nil, err
+	}
+	return OpenDevice(backend, 0)
+}
+
+// hitsOf converts backend search results to hits.
+func hitsOf[R any](results []R, hit func(R) Hit) []Hit {
+	hits := make([]Hit, len(results))
+	for i, r := range results {
+		hits[i] = hit(r)
+	}
+	return hits
+}
+
+// foreignBuffer is the error for a buffer created by another backend.
+func foreignBuffer(backend Backend, buf DeviceBuffer) error {
+	return fmt.Errorf("gpu: %T is not a %s buffer", buf, backend)
+}
+
+// cudaDevice adapts a CUDA device. Buffers live in device memory.
+type cudaDevice struct{ d *cuda.Device }
+
+func (c cudaDevice) Backend() Backend { return BackendCUDA }
+func (c cudaDevice) Name() string     { return c.d.Name() }
+func (c cudaDevice) MemoryMB() int    { return c.d.MemoryMB() }
+func (c cudaDevice) Release()         { c.d.Release() }
+
+func (c cudaDevice) NewBuffer(data []float32) (DeviceBuffer, error) {
+	buf, err := c.d.NewBuffer(data, cuda.MemoryDevice)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (c cudaDevice) buffer(buf DeviceBuffer) (*cuda.Buffer, error) {
+	if b, ok := buf.(*cuda.Buffer); ok {
+		return b, nil
+	}
+	return nil, foreignBuffer(BackendCUDA, buf)
+}
+
+func (c cudaDevice) NormalizeVectors(buf DeviceBuffer, n, dimensions int) error {
+	b, err := c.buffer(buf)
+	if err != nil {
+		return err
+	}
+	return c.d.NormalizeVectors(b, uint32(n), uint32(dimensions))
+}
+
+func (c cudaDevice) Search(buf DeviceBuffer, query []float32, n, dimensions, k int, opts SearchOptions) ([]Hit, error) {
+	b, err := c.buffer(buf)
+	if err != nil {
+		return nil, err
+	}
+	results, err := c.d.Search(b, query, uint32(n), uint32(dimensions), k, opts.cuda())
+	if err != nil {
+		return nil, err
+	}
+	return hitsOf(results, func(r cuda.SearchResult) Hit { return Hit{r.Index, r.Score} }), nil
+}
+
+func (c cudaDevice) SearchBatch(buf DeviceBuffer, queries [][]float32, n, dimensions, k int, opts SearchOptions) ([][]Hit, error) {
+	b, err := c.buffer(buf)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := c.d.SearchBatch(b, queries, uint32(n), uint32(dimensions), k, opts.cuda())
+	if err != nil {
+		return nil, err
+	}
+	hits := make([][]Hit, len(batch))
+	for q, results := range batch {
+		hits[q] = hitsOf(results, func(r cuda.SearchResult) Hit { return Hit{r.Index, r.Score} })
+	}
+	return hits, nil
+}
+
+func (o SearchOptions) cuda() cuda.SearchOptions {
+	return cuda.SearchOptions{
+		Normalized:  o.Normalized,
+		MinScore:    o.MinScore,
+		HasMinScore: o.HasMinScore,
+		Metric:      cuda.Metric(o.Metric),
+		Filter:      o.Filter,
+	}
+}
+
+// metalDevice adapts a Metal device. Buffers use shared storage.
+type metalDevice struct{ d *metal.Device }
+
+func (m metalDevice) Backend() Backend { return BackendMetal }
+func (m metalDevice) Name() string     { return m.d.Name() }
+func (m metalDevice) MemoryMB() int    { return m.d.MemoryMB() }
+func (m metalDevice) Release()         { m.d.Release() }
+
+func (m metalDevice) NewBuffer(data []float32) (DeviceBuffer, error) {
+	buf, err := m.d.NewBuffer(data, metal.StorageShared)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (m metalDevice) buffer(buf DeviceBuffer) (*metal.Buffer, error) {
+	if b, ok := buf.(*metal.Buffer); ok {
+		return b, nil
+	}
+	return nil, foreignBuffer(BackendMetal, buf)
+}
+
+func (m metalDevice) NormalizeVectors(buf DeviceBuffer, n, dimensions int) error {
+	b, err := m.buffer(buf)
+	if err != nil {
+		return err
+	}
+	return m.d.NormalizeVectors(b, uint32(n), uint32(dimensions))
+}
+
+func (m metalDevice) Search(buf DeviceBuffer, query []float32, n, dimensions, k int, opts SearchOptions) ([]Hit, error) {
+	b, err := m.buffer(buf)
+	if err != nil {
+		return nil, err
+	}
+	results, err := m.d.Search(b, query, uint32(n), uint32(dimensions), k, opts.metal())
+	if err != nil {
+		return nil, err
+	}
+	return hitsOf(results, func(r metal.SearchResult) Hit { return Hit{r.Index, r.Score} }), nil
+}
+
+func (m metalDevice) SearchBatch(buf DeviceBuffer, queries [][]float32, n, dimensions, k int, opts SearchOptions) ([][]Hit, error) {
+	b, err := m.buffer(buf)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := m.d.SearchBatch(b, queries, uint32(n), uint32(dimensions), k, opts.metal())
+	if err != nil {
+		return nil, err
+	}
+	hits := make([][]Hit, len(batch))
+	for q, results := range batch {
+		hits[q] = hitsOf(results, func(r metal.SearchResult) Hit { return Hit{r.Index, r.Score} })
+	}
+	return hits, nil
+}
+
+func (o SearchOptions) metal() metal.SearchOptions {
+	return metal.SearchOptions{
+		Normalized:  o.Normalized,
+		MinScore:    o.MinScore,
+		HasMinScore: o.HasMinScore,
+		Metric:      metal.Metric(o.Metric),
+		Filter:      o.Filter,
+	}
+}
+
+// openclDevice adapts an OpenCL device.
+type openclDevice struct{ d *opencl.Device }
+
+func (o openclDevice) Backend() Backend { return BackendOpenCL }
+func (o openclDevice) Name() string     { return o.d.Name() }
+func (o openclDevice) MemoryMB() int    { return o.d.MemoryMB() }
+func (o openclDevice) Release()         { o.d.Release() }
+
+func (o openclDevice) NewBuffer(data []float32) (DeviceBuffer, error) {
+	buf, err := o.d.NewBuffer(data)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (o openclDevice) buffer(buf DeviceBuffer) (*opencl.Buffer, error) {
+	if b, ok := buf.(*opencl.Buffer); ok {
+		return b, nil
+	}
+	return nil, foreignBuffer(BackendOpenCL, buf)
+}
+
+func (o openclDevice) NormalizeVectors(buf DeviceBuffer, n, dimensions int) error {
+	b, err := o.buffer(buf)
+	if err != nil {
+		return err
+	}
+	return o.d.NormalizeVectors(b, uint32(n), uint32(dimensions))
+}
+
+func (o openclDevice) Search(buf DeviceBuffer, query []float32, n, dimensions, k int, opts SearchOptions) ([]Hit, error) {
+	b, err := o.buffer(buf)
+	if err != nil {
+		return nil, err
+	}
+	results, err := o.d.Search(b, query, uint32(n), uint32(dimensions), k, opts.opencl())
+	if err != nil {
+		return nil, err
+	}
+	return hitsOf(results, func(r opencl.SearchResult) Hit { return Hit{r.Index, r.Score} }), nil
+}
+
+func (o openclDevice) SearchBatch(buf DeviceBuffer, queries [][]float32, n, dimensions, k int, opts SearchOptions) ([][]Hit, error) {
+	b, err := o.buffer(buf)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := o.d.SearchBatch(b, queries, uint32(n), uint32(dimensions), k, opts.opencl())
+	if err != nil {
+		return nil, err
+	}
+	hits := make([][]Hit, len(batch))
+	for q, results := range batch {
+		hits[q] = hitsOf(results, func(r opencl.SearchResult) Hit { return Hit{r.Index, r.Score} })
+	}
+	return hits, nil
+}
+
+func (o SearchOptions) opencl() opencl.SearchOptions {
+	return opencl.SearchOptions{
+		Normalized:  o.Normalized,
+		MinScore:    o.MinScore,
+		HasMinScore: o.HasMinScore,
+		Metric:      opencl.Metric(o.Metric),
+		Filter:      o.Filter,
+	}
+}
+
+// vulkanDevice adapts a Vulkan device.
+type vulkanDevice struct{ d *vulkan.Device }
+
+func (v vulkanDevice) Backend() Backend { return BackendVulkan }
+func (v vulkanDevice) Name() string     { return v.d.Name() }
+func (v vulkanDevice) MemoryMB() int    { return v.d.MemoryMB() }
+func (v vulkanDevice) Release()         { v.d.Release() }
+
+func (v vulkanDevice) NewBuffer(data []float32) (DeviceBuffer, error) {
+	buf, err := v.d.NewBuffer(data)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (v vulkanDevice) buffer(buf DeviceBuffer) (*vulkan.Buffer, error) {
+	if b, ok := buf.(*vulkan.Buffer); ok {
+		return b, nil
+	}
+	return nil, foreignBuffer(BackendVulkan, buf)
+}
+
+func (v vulkanDevice) NormalizeVectors(buf DeviceBuffer, n, dimensions int) error {
+	b, err := v.buffer(buf)
+	if err != nil {
+		return err
+	}
+	return v.d.NormalizeVectors(b, uint32(n), uint32(dimensions))
+}
+
+func (v vulkanDevice) Search(buf DeviceBuffer, query []float32, n, dimensions, k int, opts SearchOptions) ([]Hit, error) {
+	b, err := v.buffer(buf)
+	if err != nil {
+		return nil, err
+	}
+	results, err := v.d.Search(b, query, uint32(n), uint32(dimensions), k, opts.vulkan())
+	if err != nil {
+		return nil, err
+	}
+	return hitsOf(results, func(r vulkan.SearchResult) Hit { return Hit{r.Index, r.Score} }), nil
+}
+
+func (v vulkanDevice) SearchBatch(buf DeviceBuffer, queries [][]float32, n, dimensions, k int, opts SearchOptions) ([][]Hit, error) {
+	b, err := v.buffer(buf)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := v.d.SearchBatch(b, queries, uint32(n), uint32(dimensions), k, opts.vulkan())
+	if err != nil {
+		return nil, err
+	}
+	hits := make([][]Hit, len(batch))
+	for q, results := range batch {
+		hits[q] = hitsOf(results, func(r vulkan.SearchResult) Hit { return Hit{r.Index, r.Score} })
+	}
+	return hits, nil
+}
+
+func (o SearchOptions) vulkan() vulkan.SearchOptions {
+	return vulkan.SearchOptions{
+		Normalized:  o.Normalized,
+		MinScore:    o.MinScore,
+		HasMinScore: o.HasMinScore,
+		Metric:      vulkan.Metric(o.Metric),
+		Filter:      o.Filter,
+	}
+}
@@ -0,0 +1,197 @@
+package gpu
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/cuda"
+	"github.com/orneryd/nornicdb/pkg/gpu/metal"
+	"github.com/orneryd/nornicdb/pkg/gpu/opencl"
+	"github.com/orneryd/nornicdb/pkg/gpu/vulkan"
+)
+
+// fakeDevice is a Device that keeps buffers in host memory and searches
+// them on CPU.
+type fakeDevice struct {
+	buffers  int // Live buffers
+	searches int
+	released bool
+
+	capacity uint64 // Bytes NewBuffer may allocate; 0 = unlimited
+	used     uint64
+}
+
+type fakeBuffer struct {
+	dev  *fakeDevice
+	data []float32
+}
+
+func (d *fakeDevice) Backend() Backend { return "fake" }
+func (d *fakeDevice) Name() string     { return "Fake GPU" }
+func (d *fakeDevice) MemoryMB() int    { return 1024 }
+func (d *fakeDevice) Release()         { d.released = true }
+
+func (d *fakeDevice) NewBuffer(data []float32) (DeviceBuffer, error) {
+	size := uint64(len(data) * 4)
+	if d.capacity > 0 && d.used+size > d.capacity {
+		return nil, ErrOutOfMemory
+	}
+	d.used += size
+	d.buffers++
+	return &fakeBuffer{dev: d, data: append([]float32(nil), data...)}, nil
+}
+
+func (d *fakeDevice) NormalizeVectors(buf DeviceBuffer, n, dimensions int) error {
+	return nil
+}
+
+func (d *fakeDevice) Search(buf DeviceBuffer, query []float32, n, dimensions, k int, opts SearchOptions) ([]Hit, error) {
+	b := buf.(*fakeBuffer)
+	d.searches++
+	scores := make([]float32, n)
+	indices := make([]int, n)
+	for i := range scores {
+		scores[i] = cosineSimilarityFlat(query, b.data[i*dimensions:(i+1)*dimensions])
+		indices[i] = i
+	}
+	partialSort(indices, scores, k)
+	hits := make([]Hit, k)
+	for i := range hits {
+		hits[i] = Hit{Index: uint32(indices[i]), Score: scores[indices[i]]}
+	}
+	return hits, nil
+}
+
+func (d *fakeDevice) SearchBatch(buf DeviceBuffer, queries [][]float32, n, dimensions, k int, opts SearchOptions) ([][]Hit, error) {
+	batch := make([][]Hit, len(queries))
+	for i, q := range queries {
+		hits, err := d.Search(buf, q, n, dimensions, k, opts)
+		if err != nil {
+			return nil, err
+		}
+		batch[i] = hits
+	}
+	return batch, nil
+}
+
+func (b *fakeBuffer) Size() uint64 { return uint64(len(b.data) * 4) }
+
+func (b *fakeBuffer) Release() {
+	b.dev.buffers--
+	b.dev.used -= b.Size()
+}
+
+func (b *fakeBuffer) ReadAt(offset, count int) ([]float32, error) {
+	return append([]float32(nil), b.data[offset:offset+count]...), nil
+}
+
+func (b *fakeBuffer) WriteAt(offset int, data []float32) error {
+	copy(b.data[offset:], data)
+	return nil
+}
+
+func TestGPUEmbeddingIndex_Device(t *testing.T) {
+	dev := &fakeDevice{}
+	accel := &Accelerator{config: DefaultConfig(), backend: dev.Backend(), device: dev}
+	if accel.DeviceName() != "Fake GPU" || accel.DeviceMemoryMB() != 1024 || accel.Device() != dev {
+		t.Fatalf("accelerator does not report its device")
+	}
+
+	idx := accel.NewGPUEmbeddingIndex(2)
+	if err := idx.AddBatch([]string{"a", "b", "c"}, [][]float32{{1, 0}, {0, 1}, {1, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.SyncToGPU(); err != nil {
+		t.Fatal(err)
+	}
+	if dev.buffers != 1 {
+		t.Fatalf("buffers = %d, want 1", dev.buffers)
+	}
+
+	// Updates are written to the device buffer in place
+	if err := idx.Add("b", []float32{1, 0.1}); err != nil {
+		t.Fatal(err)
+	}
+	if !idx.IsGPUSynced() {
+		t.Fatal("update of a synced row should keep the index synced")
+	}
+
+	results, backend, err := idx.SearchWithBackend([]float32{1, 0}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend != "fake" || dev.searches != 1 {
+		t.Fatalf("search ran on %q with %d device searches, want the device", backend, dev.searches)
+	}
+	if len(results) != 2 || results[0].ID != "a" || results[1].ID != "b" {
+		t.Fatalf("results = %+v, want a then b", results)
+	}
+	if stats := accel.Stats(); stats.SearchesGPU != 1 || stats.SearchesCPU != 0 {
+		t.Fatalf("stats = %+v, want one GPU search", stats)
+	}
+
+	idx.Release()
+	if dev.buffers != 0 {
+		t.Fatalf("buffers = %d after Release, want 0", dev.buffers)
+	}
+	accel.Release()
+	if !dev.released || accel.IsEnabled() {
+		t.Fatal("accelerator Release should release the device")
+	}
+}
+
+func TestOpenDevice(t *testing.T) {
+	if _, err := OpenDevice(BackendNone, 0); !errors.Is(err, ErrGPUNotAvailable) {
+		t.Errorf("OpenDevice(none) error = %v, want ErrGPUNotAvailable", err)
+	}
+	for _, b := range selectOrder {
+		dev, err := OpenDevice(b, 0)
+		if err != nil {
+			continue // Not available in this build or on this machine
+		}
+		if dev.Backend() != b || dev.Name() == "" {
+			t.Errorf("OpenDevice(%s) = %s device %q", b, dev.Backend(), dev.Name())
+		}
+		dev.Release()
+	}
+
+	t.Setenv(EnvBackend, "cpu")
+	if dev, err := AutoSelectDevice(); dev != nil || err != nil {
+		t.Errorf("AutoSelectDevice() with %s=cpu = %v, %v; want nil, nil", EnvBackend, dev, err)
+	}
+	t.Setenv(EnvBackend, "rocm")
+	if _, err := AutoSelectDevice(); err == nil {
+		t.Error("AutoSelectDevice() with an unknown backend should fail")
+	}
+}
+
+func TestDevice_ForeignBuffer(t *testing.T) {
+	foreign := &fakeBuffer{dev: &fakeDevice{}}
+	devices := []Device{cudaDevice{}, metalDevice{}, openclDevice{}, vulkanDevice{}}
+	for _, dev := range devices {
+		_, err := dev.Search(foreign, nil, 0, 0, 1, SearchOptions{})
+		if err == nil || !strings.Contains(err.Error(), "is not a "+string(dev.Backend())+" buffer") {
+			t.Errorf("%s Search of a foreign buffer: error = %v", dev.Backend(), err)
+		}
+		if err := dev.NormalizeVectors(foreign, 0, 0); err == nil {
+			t.Errorf("%s NormalizeVectors of a foreign buffer should fail", dev.Backend())
+		}
+	}
+}
+
+func TestSearchOptions_Backends(t *testing.T) {
+	opts := SearchOptions{Normalized: true, MinScore: 0.5, HasMinScore: true, Metric: MetricDot, Filter: []uint64{5}}
+	if got := opts.cuda(); !got.Normalized || got.MinScore != 0.5 || !got.HasMinScore || got.Metric != cuda.MetricDot || got.Filter[0] != 5 {
+		t.Errorf("cuda options = %+v", got)
+	}
+	if got := opts.metal(); got.Metric != metal.MetricDot || !got.HasMinScore {
+		t.Errorf("metal options = %+v", got)
+	}
+	if got := opts.opencl(); got.Metric != opencl.MetricDot || !got.Normalized {
+		t.Errorf("opencl options = %+v", got)
+	}
+	if got := opts.vulkan(); got.Metric != vulkan.MetricDot || len(got.Filter) != 1 {
+		t.Errorf("vulkan options = %+v", got)
+	}
+}
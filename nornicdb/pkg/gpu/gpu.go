@@ -149,16 +149,30 @@ import (
 	"unsafe"
 
 	"github.com/orneryd/nornicdb/pkg/gpu/cuda"
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
 	"github.com/orneryd/nornicdb/pkg/gpu/metal"
 )
 
+// Error kinds shared by all backends (see package gpuerr). Backend errors
+// match them with errors.Is, so fallback logic need not know which backend
+// failed:
+//
+//	if errors.Is(err, gpu.ErrOutOfMemory) {
+//		// retry with smaller batches or on CPU
+//	}
+var (
+	ErrNotAvailable = gpuerr.ErrNotAvailable
+	ErrOutOfMemory  = gpuerr.ErrOutOfMemory
+	ErrDeviceLost   = gpuerr.ErrDeviceLost
+	ErrKernel       = gpuerr.ErrKernel
+)
+
 // Errors
 var (
-	ErrGPUNotAvailable   = errors.New("gpu: no compatible GPU found")
-	ErrGPUDisabled       = errors.New("gpu: acceleration disabled")
-	ErrOutOfMemory       = errors.New("gpu: out of GPU memory")
-	ErrKernelFailed      = errors.New("gpu: kernel execution failed")
-	ErrDataTooLarge      = errors.New("gpu: data exceeds GPU memory")
+	ErrGPUNotAvailable   = gpuerr.Sentinel("gpu: no compatible GPU found", ErrNotAvailable)
+	ErrGPUDisabled       = gpuerr.Sentinel("gpu: acceleration disabled", ErrNotAvailable)
+	ErrKernelFailed      = ErrKernel
+	ErrDataTooLarge      = gpuerr.Sentinel("gpu: data exceeds GPU memory", ErrOutOfMemory)
 	ErrInvalidDimensions = errors.New("gpu: vector dimension mismatch")
 )
 
@@ -303,6 +317,9 @@ type Manager struct {
 
 	// Stats
 	stats Stats
+
+	// Devices used by ScorePairs, created on first use
+	pairs pairDevices
 }
 
 // Stats tracks GPU usage statistics.
@@ -854,10 +871,10 @@ type EmbeddingIndex struct {
 
 	// GPU storage (ONLY embeddings, no strings or metadata)
 	gpuBuffer    unsafe.Pointer // Native GPU buffer handle (legacy)
-	metalBuffer  *metal.Buffer  // Metal GPU buffer (macOS)
 	metalDevice  *metal.Device  // Metal device reference
-	cudaBuffer   *cuda.Buffer   // CUDA GPU buffer (NVIDIA)
 	cudaDevice   *cuda.Device   // CUDA device reference
+	shards       []deviceShard  // GPU buffers; more than one if a single buffer did not fit
+	shardDev     shardDevice    // Overrides the backend device (tests)
 	gpuAllocated int            // Bytes allocated on GPU (dimensions × count × 4)
 	gpuCapacity  int            // Max embeddings before realloc needed
 	gpuSynced    bool           // Is GPU in sync with CPU?
@@ -939,8 +956,10 @@ func NewEmbeddingIndex(manager *Manager, config *EmbeddingIndexConfig) *Embeddin
 
 // Add inserts or updates an embedding for a node.
 //
-// The embedding is stored in CPU memory and the GPU sync flag is cleared.
-// Call SyncToGPU() to upload changes to GPU for acceleration.
+// The embedding is stored in CPU memory. If the index is synced and the node
+// is already indexed, the new embedding is also written over its row on the
+// GPU; otherwise the GPU sync flag is cleared and SyncToGPU() must be called
+// to upload changes to GPU for acceleration.
 //
 // Parameters:
 //   - nodeID: Unique identifier for the node
@@ -971,7 +990,7 @@ func NewEmbeddingIndex(manager *Manager, config *EmbeddingIndexConfig) *Embeddin
 //
 // Memory:
 //   - Embedding is copied (safe to modify original after Add)
-//   - GPU sync is deferred until SyncToGPU() is called
+//   - GPU sync of new nodes is deferred until SyncToGPU() is called
 func (ei *EmbeddingIndex) Add(nodeID string, embedding []float32) error {
 	if len(embedding) != ei.dimensions {
 		return ErrInvalidDimensions
@@ -981,8 +1000,11 @@ func (ei *EmbeddingIndex) Add(nodeID string, embedding []float32) error {
 	defer ei.mu.Unlock()
 
 	if idx, exists := ei.idToIndex[nodeID]; exists {
-		// Update existing embedding
+		// Update existing embedding, on the GPU too if it is already there
 		copy(ei.cpuVectors[idx*ei.dimensions:], embedding)
+		if ei.writeRows(idx) {
+			return nil
+		}
 	} else {
 		// Add new embedding
 		ei.nodeIDs = append(ei.nodeIDs, nodeID)
@@ -994,7 +1016,8 @@ func (ei *EmbeddingIndex) Add(nodeID string, embedding []float32) error {
 	return nil
 }
 
-// AddBatch inserts multiple embeddings efficiently.
+// AddBatch inserts multiple embeddings efficiently. As with Add, a batch
+// that only updates indexed nodes keeps a synced index synced.
 func (ei *EmbeddingIndex) AddBatch(nodeIDs []string, embeddings [][]float32) error {
 	if len(nodeIDs) != len(embeddings) {
 		return errors.New("gpu: nodeIDs and embeddings length mismatch")
@@ -1003,6 +1026,8 @@ func (ei *EmbeddingIndex) AddBatch(nodeIDs []string, embeddings [][]float32) err
 	ei.mu.Lock()
 	defer ei.mu.Unlock()
 
+	var updated []int
+	added := false
 	for i, nodeID := range nodeIDs {
 		if len(embeddings[i]) != ei.dimensions {
 			return ErrInvalidDimensions
@@ -1010,18 +1035,25 @@ func (ei *EmbeddingIndex) AddBatch(nodeIDs []string, embeddings [][]float32) err
 
 		if idx, exists := ei.idToIndex[nodeID]; exists {
 			copy(ei.cpuVectors[idx*ei.dimensions:], embeddings[i])
+			updated = append(updated, idx)
 		} else {
 			ei.nodeIDs = append(ei.nodeIDs, nodeID)
 			ei.idToIndex[nodeID] = len(ei.nodeIDs) - 1
 			ei.cpuVectors = append(ei.cpuVectors, embeddings[i]...)
+			added = true
 		}
 	}
 
-	ei.gpuSynced = false
+	// New rows need a larger buffer; updates alone are written in place.
+	if added || !ei.writeRows(updated...) {
+		ei.gpuSynced = false
+	}
 	return nil
 }
 
-// Remove deletes an embedding from the index.
+// Remove deletes an embedding from the index. A synced index stays synced:
+// the embedding swapped into the removed row is written to the GPU and the
+// last row is dropped, unless that would empty the last GPU buffer.
 func (ei *EmbeddingIndex) Remove(nodeID string) bool {
 	ei.mu.Lock()
 	defer ei.mu.Unlock()
@@ -1050,7 +1082,13 @@ func (ei *EmbeddingIndex) Remove(nodeID string) bool {
 	ei.cpuVectors = ei.cpuVectors[:lastIdx*ei.dimensions]
 	delete(ei.idToIndex, nodeID)
 
-	ei.gpuSynced = false
+	moved := -1
+	if idx != lastIdx {
+		moved = idx
+	}
+	if !ei.dropLastRow(moved) {
+		ei.gpuSynced = false
+	}
 	return true
 }
 
@@ -1122,112 +1160,22 @@ func (ei *EmbeddingIndex) Search(query []float32, k int) ([]SearchResult, error)
 func (ei *EmbeddingIndex) searchGPU(query []float32, k int) ([]SearchResult, error) {
 	atomic.AddInt64(&ei.searchesGPU, 1)
 
-	// Determine which backend to use
-	if ei.manager.device != nil {
-		switch ei.manager.device.Backend {
-		case BackendMetal:
-			return ei.searchMetal(query, k)
-		case BackendCUDA:
-			return ei.searchCUDA(query, k)
-		}
-	}
-
-	// Fallback to CPU if no GPU backend available
-	return ei.searchCPU(query, k)
-}
-
-// searchCUDA performs similarity search using NVIDIA CUDA GPU.
-func (ei *EmbeddingIndex) searchCUDA(query []float32, k int) ([]SearchResult, error) {
-	if ei.cudaBuffer == nil || ei.cudaDevice == nil {
-		// Fall back to CPU if CUDA not initialized
+	if len(ei.shards) == 0 {
+		// Fall back to CPU if no GPU buffers are initialized
 		return ei.searchCPU(query, k)
 	}
 
-	n := uint32(len(ei.nodeIDs))
-	if k > int(n) {
-		k = int(n)
+	if k > len(ei.nodeIDs) {
+		k = len(ei.nodeIDs)
 	}
 
-	// Perform GPU search using CUDA
-	results, err := ei.cudaDevice.Search(
-		ei.cudaBuffer,
-		query,
-		n,
-		uint32(ei.dimensions),
-		k,
-		true, // vectors are normalized
-	)
-
+	results, err := ei.searchShards(query, k)
 	if err != nil {
 		// Fall back to CPU on GPU error
 		atomic.AddInt64(&ei.manager.stats.FallbackCount, 1)
 		return ei.searchCPU(query, k)
 	}
-
-	// Update stats
-	atomic.AddInt64(&ei.manager.stats.OperationsGPU, 1)
-	atomic.AddInt64(&ei.manager.stats.KernelExecutions, 2) // similarity + topk
-
-	// Convert CUDA results to SearchResult with nodeIDs
-	output := make([]SearchResult, len(results))
-	for i, r := range results {
-		if int(r.Index) < len(ei.nodeIDs) {
-			output[i] = SearchResult{
-				ID:       ei.nodeIDs[r.Index],
-				Score:    r.Score,
-				Distance: 1 - r.Score,
-			}
-		}
-	}
-
-	return output, nil
-}
-
-// searchMetal performs similarity search using Metal GPU.
-func (ei *EmbeddingIndex) searchMetal(query []float32, k int) ([]SearchResult, error) {
-	if ei.metalBuffer == nil || ei.metalDevice == nil {
-		// Fall back to CPU if Metal not initialized
-		return ei.searchCPU(query, k)
-	}
-
-	n := uint32(len(ei.nodeIDs))
-	if k > int(n) {
-		k = int(n)
-	}
-
-	// Perform GPU search
-	results, err := ei.metalDevice.Search(
-		ei.metalBuffer,
-		query,
-		n,
-		uint32(ei.dimensions),
-		k,
-		true, // vectors are normalized
-	)
-
-	if err != nil {
-		// Fall back to CPU on GPU error
-		atomic.AddInt64(&ei.manager.stats.FallbackCount, 1)
-		return ei.searchCPU(query, k)
-	}
-
-	// Update stats
-	atomic.AddInt64(&ei.manager.stats.OperationsGPU, 1)
-	atomic.AddInt64(&ei.manager.stats.KernelExecutions, 2) // similarity + topk
-
-	// Convert Metal results to SearchResult with nodeIDs
-	output := make([]SearchResult, len(results))
-	for i, r := range results {
-		if int(r.Index) < len(ei.nodeIDs) {
-			output[i] = SearchResult{
-				ID:       ei.nodeIDs[r.Index],
-				Score:    r.Score,
-				Distance: 1 - r.Score,
-			}
-		}
-	}
-
-	return output, nil
+	return results, nil
 }
 
 // searchCPU performs similarity search on CPU (fallback).
@@ -1271,6 +1219,10 @@ func (ei *EmbeddingIndex) searchCPU(query []float32, k int) ([]SearchResult, err
 }
 
 // SyncToGPU uploads the current embeddings to GPU memory.
+//
+// If the device runs out of memory, the embeddings are split into smaller
+// buffers, and rows that still do not fit are searched on CPU (see
+// shards.go). ErrDataTooLarge is returned only if nothing fits.
 func (ei *EmbeddingIndex) SyncToGPU() error {
 	if !ei.manager.IsEnabled() {
 		return ErrGPUDisabled
@@ -1284,13 +1236,21 @@ func (ei *EmbeddingIndex) SyncToGPU() error {
 		return nil
 	}
 
+	if ei.shardDev != nil {
+		if err := ei.syncShards(ei.shardDev, ei.cpuVectors); err != nil {
+			return err
+		}
+		ei.gpuSynced = true
+		return nil
+	}
+
 	// Determine which backend to use
 	if ei.manager.device != nil {
 		switch ei.manager.device.Backend {
 		case BackendMetal:
-			return ei.syncToMetal()
+			return ei.syncToMetal(ei.cpuVectors)
 		case BackendCUDA:
-			return ei.syncToCUDA()
+			return ei.syncToCUDA(ei.cpuVectors)
 		}
 	}
 
@@ -1299,7 +1259,8 @@ func (ei *EmbeddingIndex) SyncToGPU() error {
 }
 
 // syncToCUDA uploads embeddings to NVIDIA CUDA GPU buffer.
-func (ei *EmbeddingIndex) syncToCUDA() error {
+// vectors must hold the index's current contents (cpuVectors or a mapped copy).
+func (ei *EmbeddingIndex) syncToCUDA(vectors []float32) error {
 	// Initialize CUDA device if needed
 	if ei.cudaDevice == nil {
 		deviceID := 0
@@ -1313,29 +1274,12 @@ func (ei *EmbeddingIndex) syncToCUDA() error {
 		ei.cudaDevice = device
 	}
 
-	// Release old buffer
-	if ei.cudaBuffer != nil {
-		ei.cudaBuffer.Release()
-		ei.cudaBuffer = nil
-	}
-
-	// Create new buffer with embeddings
-	buffer, err := ei.cudaDevice.NewBuffer(ei.cpuVectors, cuda.MemoryDevice)
-	if err != nil {
+	// Replace old buffers with embeddings (split into shards if VRAM is short)
+	if err := ei.syncShards(cudaShards{ei}, vectors); err != nil {
 		return err
 	}
-	ei.cudaBuffer = buffer
-
-	// Normalize vectors on GPU for faster cosine similarity
-	n := uint32(len(ei.nodeIDs))
-	dims := uint32(ei.dimensions)
-	if err := ei.cudaDevice.NormalizeVectors(ei.cudaBuffer, n, dims); err != nil {
-		// Non-fatal: we can still search with unnormalized vectors
-		// (but slower since we need to normalize each query)
-	}
 
 	// Update stats
-	ei.gpuAllocated = len(ei.cpuVectors) * 4
 	ei.gpuSynced = true
 	atomic.AddInt64(&ei.uploadsCount, 1)
 	atomic.AddInt64(&ei.uploadBytes, int64(ei.gpuAllocated))
@@ -1345,7 +1289,8 @@ func (ei *EmbeddingIndex) syncToCUDA() error {
 }
 
 // syncToMetal uploads embeddings to Metal GPU buffer.
-func (ei *EmbeddingIndex) syncToMetal() error {
+// vectors must hold the index's current contents (cpuVectors or a mapped copy).
+func (ei *EmbeddingIndex) syncToMetal(vectors []float32) error {
 	// Initialize Metal device if needed
 	if ei.metalDevice == nil {
 		device, err := metal.NewDevice()
@@ -1355,26 +1300,17 @@ func (ei *EmbeddingIndex) syncToMetal() error {
 		ei.metalDevice = device
 	}
 
-	// Release old buffer
-	if ei.metalBuffer != nil {
-		ei.metalBuffer.Release()
-		ei.metalBuffer = nil
-	}
-
-	// Create new buffer with embeddings
-	buffer, err := ei.metalDevice.NewBuffer(ei.cpuVectors, metal.StorageShared)
-	if err != nil {
+	// Replace old buffers with embeddings (split into shards if VRAM is short)
+	if err := ei.syncShards(metalShards{ei.metalDevice}, vectors); err != nil {
 		return err
 	}
 
-	ei.metalBuffer = buffer
-	ei.gpuAllocated = len(ei.cpuVectors) * 4
 	ei.gpuSynced = true
 	ei.uploadsCount++
-	ei.uploadBytes += int64(len(ei.cpuVectors) * 4)
+	ei.uploadBytes += int64(ei.gpuAllocated)
 
 	// Update manager stats
-	atomic.AddInt64(&ei.manager.stats.BytesTransferred, int64(len(ei.cpuVectors)*4))
+	atomic.AddInt64(&ei.manager.stats.BytesTransferred, int64(ei.gpuAllocated))
 
 	return nil
 }
@@ -1411,10 +1347,13 @@ func (ei *EmbeddingIndex) Stats() EmbeddingIndexStats {
 	ei.mu.RLock()
 	defer ei.mu.RUnlock()
 
+	shards, hostRows := ei.residentShards()
 	return EmbeddingIndexStats{
 		Count:        len(ei.nodeIDs),
 		Dimensions:   ei.dimensions,
 		GPUSynced:    ei.gpuSynced,
+		GPUShards:    shards,
+		CPURows:      hostRows,
 		SearchesGPU:  atomic.LoadInt64(&ei.searchesGPU),
 		SearchesCPU:  atomic.LoadInt64(&ei.searchesCPU),
 		UploadsCount: ei.uploadsCount,
@@ -1427,6 +1366,8 @@ type EmbeddingIndexStats struct {
 	Count        int
 	Dimensions   int
 	GPUSynced    bool
+	GPUShards    int // Device buffers the index is split across (0 = not on GPU)
+	CPURows      int // Embeddings that did not fit in GPU memory and are scored on CPU
 	SearchesGPU  int64
 	SearchesCPU  int64
 	UploadsCount int64
@@ -1457,6 +1398,15 @@ func (ei *EmbeddingIndex) Get(nodeID string) ([]float32, bool) {
 	return result, true
 }
 
+// NodeIDs returns the IDs of all indexed nodes in index order.
+func (ei *EmbeddingIndex) NodeIDs() []string {
+	ei.mu.RLock()
+	defer ei.mu.RUnlock()
+	ids := make([]string, len(ei.nodeIDs))
+	copy(ids, ei.nodeIDs)
+	return ids
+}
+
 // Clear removes all embeddings from the index.
 func (ei *EmbeddingIndex) Clear() {
 	ei.mu.Lock()
@@ -1468,10 +1418,7 @@ func (ei *EmbeddingIndex) Clear() {
 	ei.gpuSynced = false
 
 	// Release GPU resources
-	if ei.metalBuffer != nil {
-		ei.metalBuffer.Release()
-		ei.metalBuffer = nil
-	}
+	ei.releaseShards()
 	ei.gpuAllocated = 0
 }
 
@@ -1481,21 +1428,15 @@ func (ei *EmbeddingIndex) Release() {
 	ei.mu.Lock()
 	defer ei.mu.Unlock()
 
+	ei.releaseShards()
+
 	// Release Metal resources
-	if ei.metalBuffer != nil {
-		ei.metalBuffer.Release()
-		ei.metalBuffer = nil
-	}
 	if ei.metalDevice != nil {
 		ei.metalDevice.Release()
 		ei.metalDevice = nil
 	}
 
 	// Release CUDA resources
-	if ei.cudaBuffer != nil {
-		ei.cudaBuffer.Release()
-		ei.cudaBuffer = nil
-	}
 	if ei.cudaDevice != nil {
 		ei.cudaDevice.Release()
 		ei.cudaDevice = nil
@@ -2,7 +2,14 @@
 package gpu
 
 import (
+	"errors"
+	"fmt"
 	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/cuda"
+	"github.com/orneryd/nornicdb/pkg/gpu/metal"
+	"github.com/orneryd/nornicdb/pkg/gpu/opencl"
+	"github.com/orneryd/nornicdb/pkg/gpu/vulkan"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -356,6 +363,41 @@ func TestErrors(t *testing.T) {
 	}
 }
 
+func TestBackendErrorsMatchSharedKinds(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{ErrGPUNotAvailable, ErrNotAvailable},
+		{ErrGPUDisabled, ErrNotAvailable},
+		{ErrKernelFailed, ErrKernel},
+		{ErrDataTooLarge, ErrOutOfMemory},
+		{cuda.ErrCUDANotAvailable, ErrNotAvailable},
+		{cuda.ErrBufferCreation, ErrOutOfMemory},
+		{cuda.ErrKernelExecution, ErrKernel},
+		{metal.ErrMetalNotAvailable, ErrNotAvailable},
+		{metal.ErrBufferCreation, ErrOutOfMemory},
+		{metal.ErrKernelExecution, ErrKernel},
+		{opencl.ErrOpenCLNotAvailable, ErrNotAvailable},
+		{opencl.ErrBufferCreation, ErrOutOfMemory},
+		{opencl.ErrKernelExecution, ErrKernel},
+		{vulkan.ErrVulkanNotAvailable, ErrNotAvailable},
+		{vulkan.ErrBufferCreation, ErrOutOfMemory},
+		{vulkan.ErrKernelExecution, ErrKernel},
+	}
+	for _, tt := range tests {
+		wrapped := fmt.Errorf("search: %w", tt.err)
+		if !errors.Is(wrapped, tt.kind) {
+			t.Errorf("%v should match %v", tt.err, tt.kind)
+		}
+		for _, other := range []error{ErrNotAvailable, ErrOutOfMemory, ErrDeviceLost, ErrKernel} {
+			if other != tt.kind && errors.Is(wrapped, other) {
+				t.Errorf("%v should not match %v", tt.err, other)
+			}
+		}
+	}
+}
+
 func TestSearchResult(t *testing.T) {
 	sr := SearchResult{
 		ID:       "test",
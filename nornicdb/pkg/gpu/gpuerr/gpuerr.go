@@ -0,0 +1,109 @@
+// Package gpuerr defines the error kinds shared by every GPU backend.
+//
+// The backend packages (cuda, metal, opencl, vulkan) map their native error
+// codes to one of four kinds, so callers implementing fallback logic can use
+// errors.Is without knowing which backend produced an error:
+//
+//	_, err := device.Search(buffer, query, n, dims, k, opts)
+//	switch {
+//	case errors.Is(err, gpuerr.ErrOutOfMemory):
+//		// split the work or retry on CPU
+//	case errors.Is(err, gpuerr.ErrDeviceLost):
+//		// recreate the device before retrying
+//	case errors.Is(err, gpuerr.ErrNotAvailable), errors.Is(err, gpuerr.ErrKernel):
+//		// use the CPU path
+//	}
+//
+// Backend sentinels such as cuda.ErrBufferCreation still match with
+// errors.Is, and keep their messages. This package is imported by the
+// backends and re-exported by package gpu; it has no dependencies so it can
+// sit below both.
+package gpuerr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Shared error kinds.
+var (
+	// ErrNotAvailable: no usable device, driver, or runtime.
+	ErrNotAvailable = errors.New("gpu: device not available")
+	// ErrOutOfMemory: a device (or pinned host) allocation failed.
+	ErrOutOfMemory = errors.New("gpu: out of GPU memory")
+	// ErrDeviceLost: the device was reset, removed, or hit a fatal fault;
+	// it must be recreated before further use.
+	ErrDeviceLost = errors.New("gpu: device lost")
+	// ErrKernel: a kernel failed to build, launch, or run.
+	ErrKernel = errors.New("gpu: kernel execution failed")
+)
+
+// Kinds lists the shared error kinds.
+var Kinds = []error{ErrNotAvailable, ErrOutOfMemory, ErrDeviceLost, ErrKernel}
+
+// sentinel is a backend-specific sentinel that also matches a shared kind.
+type sentinel struct {
+	msg  string
+	kind error
+}
+
+func (s *sentinel) Error() string { return s.msg }
+func (s *sentinel) Unwrap() error { return s.kind }
+
+// Sentinel returns a new sentinel error with message msg that matches kind
+// with errors.Is. Backends use it for their own sentinels:
+//
+//	ErrBufferCreation = gpuerr.Sentinel("cuda: failed to create buffer", gpuerr.ErrOutOfMemory)
+func Sentinel(msg string, kind error) error {
+	return &sentinel{msg: msg, kind: kind}
+}
+
+// Error is a failure reported by a backend's native API.
+//
+// errors.Is matches Op and Kind. Kind is derived from the native code when
+// the backend recognizes it and from Op otherwise, so a buffer allocation
+// that fails because the device was lost matches ErrDeviceLost and
+// cuda.ErrBufferCreation, but not ErrOutOfMemory.
+type Error struct {
+	Backend string // "cuda", "metal", "opencl" or "vulkan"
+	Op      error  // backend sentinel for the operation, e.g. cuda.ErrBufferCreation
+	Kind    error  // one of Kinds, or nil if unclassified
+	Code    int    // native error code; 0 if the backend reported none
+	Msg     string // native error message
+}
+
+// New returns an Error for op. kind is the classification of code, or nil
+// if the backend does not recognize it; the kind of op is used then.
+func New(backend string, op, kind error, code int, msg string) *Error {
+	if kind == nil {
+		kind = KindOf(op)
+	}
+	return &Error{Backend: backend, Op: op, Kind: kind, Code: code, Msg: msg}
+}
+
+func (e *Error) Error() string {
+	s := e.Op.Error()
+	if e.Msg != "" {
+		s += ": " + e.Msg
+	}
+	if e.Code != 0 {
+		s += fmt.Sprintf(" (code %d)", e.Code)
+	}
+	return s
+}
+
+// Is matches the operation sentinel and the error kind. The operation's own
+// kind is deliberately not consulted, so a classified code takes precedence.
+func (e *Error) Is(target error) bool {
+	return target == e.Op || (e.Kind != nil && target == e.Kind)
+}
+
+// KindOf returns the shared kind err matches, or nil.
+func KindOf(err error) error {
+	for _, kind := range Kinds {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package gpuerr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSentinelMatchesKind(t *testing.T) {
+	errBuffer := Sentinel("test: failed to create buffer", ErrOutOfMemory)
+	if errBuffer.Error() != "test: failed to create buffer" {
+		t.Errorf("message = %q", errBuffer.Error())
+	}
+	if !errors.Is(errBuffer, ErrOutOfMemory) {
+		t.Error("sentinel should match its kind")
+	}
+	if errors.Is(errBuffer, ErrKernel) {
+		t.Error("sentinel should not match other kinds")
+	}
+	wrapped := fmt.Errorf("upload: %w", errBuffer)
+	if !errors.Is(wrapped, errBuffer) || !errors.Is(wrapped, ErrOutOfMemory) {
+		t.Error("wrapped sentinel should match itself and its kind")
+	}
+}
+
+func TestErrorKindFromCode(t *testing.T) {
+	errBuffer := Sentinel("test: failed to create buffer", ErrOutOfMemory)
+
+	err := New("test", errBuffer, ErrDeviceLost, 4, "device removed")
+	if got := err.Error(); got != "test: failed to create buffer: device removed (code 4)" {
+		t.Errorf("Error() = %q", got)
+	}
+	if !errors.Is(err, errBuffer) {
+		t.Error("should match the operation")
+	}
+	if !errors.Is(err, ErrDeviceLost) {
+		t.Error("should match the classified kind")
+	}
+	if errors.Is(err, ErrOutOfMemory) {
+		t.Error("classified kind should take precedence over the operation's kind")
+	}
+	if KindOf(fmt.Errorf("search: %w", err)) != ErrDeviceLost {
+		t.Error("KindOf should see through wrapping")
+	}
+}
+
+func TestErrorKindFromOp(t *testing.T) {
+	errKernel := Sentinel("test: kernel execution failed", ErrKernel)
+
+	err := New("test", errKernel, nil, 0, "")
+	if got := err.Error(); got != "test: kernel execution failed" {
+		t.Errorf("Error() = %q", got)
+	}
+	if !errors.Is(err, ErrKernel) {
+		t.Error("unclassified code should fall back to the operation's kind")
+	}
+
+	plain := errors.New("test: invalid buffer")
+	if err := New("test", plain, nil, 0, "nil"); KindOf(err) != nil {
+		t.Errorf("KindOf = %v, want nil", KindOf(err))
+	}
+}
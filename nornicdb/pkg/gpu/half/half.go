@@ -0,0 +1,128 @@
+// Package half converts between float32 and the 16-bit floating-point
+// formats the GPU backends can store embeddings in.
+//
+// Two formats are supported:
+//
+//   - FP16, IEEE 754 binary16: 11 bits of precision and a range of ±65504.
+//     Unit-length embeddings fit easily and keep about three decimal digits.
+//   - BF16, bfloat16: the upper half of a float32, so the full float32 range
+//     with 8 bits of precision.
+//
+// Both take half the memory of float32, so a device holds twice as many
+// embeddings. Backends convert on upload and widen each element back to
+// float32 inside their kernels, accumulating in float32; the only error is
+// the rounding of the stored values. Conversions round to nearest, ties to
+// even, as the hardware does.
+//
+// Example (in a backend bridge):
+//
+//	packed := make([]uint16, len(data))
+//	half.EncodeFP16(packed, data)
+//	// upload packed as len(data)*2 bytes
+package half
+
+import "math"
+
+// FromFloat32 returns the FP16 value nearest to f. Values beyond the FP16
+// range become infinities and NaNs stay NaN.
+func FromFloat32(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int32(b>>23&0xff) - 127 + 15
+	mant := b & 0x7fffff
+
+	switch {
+	case b&0x7fffffff > 0x7f800000: // NaN
+		return sign | 0x7e00
+	case exp >= 0x1f: // Overflow or infinity
+		return sign | 0x7c00
+	case exp <= 0:
+		// Subnormal: the value is mant * 2^(exp-14) in units of 2^-24.
+		if exp < -10 {
+			return sign
+		}
+		full := mant | 0x800000
+		shift := uint32(14 - exp)
+		h := full >> shift
+		rem := full & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if rem > halfway || (rem == halfway && h&1 == 1) {
+			h++ // May carry into the smallest normal, which is correct
+		}
+		return sign | uint16(h)
+	}
+
+	h := uint32(exp)<<10 | mant>>13
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && h&1 == 1) {
+		h++ // May carry into the exponent, up to infinity
+	}
+	return sign | uint16(h)
+}
+
+// ToFloat32 returns the float32 equal to the FP16 value h.
+func ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Subnormal: normalize into a float32 exponent.
+		e := uint32(127 - 14)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		return math.Float32frombits(sign | e<<23 | (mant&0x3ff)<<13)
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+// BFloat16FromFloat32 returns the BF16 value nearest to f.
+func BFloat16FromFloat32(f float32) uint16 {
+	b := math.Float32bits(f)
+	if b&0x7fffffff > 0x7f800000 {
+		return uint16(b>>16) | 0x40 // Keep NaNs quiet, and NaN
+	}
+	b += 0x7fff + (b>>16)&1
+	return uint16(b >> 16)
+}
+
+// BFloat16ToFloat32 returns the float32 equal to the BF16 value b.
+func BFloat16ToFloat32(b uint16) float32 {
+	return math.Float32frombits(uint32(b) << 16)
+}
+
+// EncodeFP16 converts src to FP16 into dst, which must be at least as long.
+func EncodeFP16(dst []uint16, src []float32) {
+	for i, f := range src {
+		dst[i] = FromFloat32(f)
+	}
+}
+
+// DecodeFP16 converts FP16 src into dst, which must be at least as long.
+func DecodeFP16(dst []float32, src []uint16) {
+	for i, h := range src {
+		dst[i] = ToFloat32(h)
+	}
+}
+
+// EncodeBF16 converts src to BF16 into dst, which must be at least as long.
+func EncodeBF16(dst []uint16, src []float32) {
+	for i, f := range src {
+		dst[i] = BFloat16FromFloat32(f)
+	}
+}
+
+// DecodeBF16 converts BF16 src into dst, which must be at least as long.
+func DecodeBF16(dst []float32, src []uint16) {
+	for i, b := range src {
+		dst[i] = BFloat16ToFloat32(b)
+	}
+}
@@ -0,0 +1,109 @@
+package half
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFromFloat32(t *testing.T) {
+	tests := []struct {
+		in   float32
+		want uint16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.5, 0x3800},
+		{65504, 0x7bff},                  // Largest finite
+		{65520, 0x7c00},                  // Rounds up to infinity
+		{1e6, 0x7c00},                    // Overflow
+		{float32(math.Inf(-1)), 0xfc00},  // Infinity
+		{6.103515625e-05, 0x0400},        // Smallest normal
+		{5.960464477539063e-08, 0x0001},  // Smallest subnormal
+		{2.9802322387695312e-08, 0x0000}, // Halfway to the smallest subnormal: ties to even
+		{1e-10, 0x0000},                  // Underflow
+		{1 + 1.0/2048, 0x3c00},           // Halfway: ties to even (down)
+		{1 + 3.0/2048, 0x3c02},           // Halfway: ties to even (up)
+		{1 + 1.0/2048 + 1.0/8192, 0x3c01},
+	}
+	for _, tt := range tests {
+		if got := FromFloat32(tt.in); got != tt.want {
+			t.Errorf("FromFloat32(%g) = %#04x, want %#04x", tt.in, got, tt.want)
+		}
+	}
+	if h := FromFloat32(float32(math.NaN())); h&0x7c00 != 0x7c00 || h&0x3ff == 0 {
+		t.Errorf("FromFloat32(NaN) = %#04x, want a NaN", h)
+	}
+}
+
+func TestFP16RoundTrip(t *testing.T) {
+	// Every non-NaN FP16 value converts to float32 and back unchanged.
+	for i := 0; i <= math.MaxUint16; i++ {
+		h := uint16(i)
+		if h&0x7c00 == 0x7c00 && h&0x3ff != 0 {
+			if f := ToFloat32(h); !math.IsNaN(float64(f)) {
+				t.Fatalf("ToFloat32(%#04x) = %g, want NaN", h, f)
+			}
+			continue
+		}
+		if got := FromFloat32(ToFloat32(h)); got != h {
+			t.Fatalf("FromFloat32(ToFloat32(%#04x)) = %#04x", h, got)
+		}
+	}
+	if got := ToFloat32(0x0001); got != 5.960464477539063e-08 {
+		t.Errorf("ToFloat32(0x0001) = %g", got)
+	}
+}
+
+func TestBFloat16(t *testing.T) {
+	tests := []struct {
+		in   float32
+		want uint16
+	}{
+		{0, 0x0000},
+		{1, 0x3f80},
+		{-2, 0xc000},
+		{3e38, 0x7f62},
+		{float32(math.Inf(1)), 0x7f80},
+		{math.Float32frombits(0x3f808000), 0x3f80}, // Halfway: ties to even (down)
+		{math.Float32frombits(0x3f818000), 0x3f82}, // Halfway: ties to even (up)
+		{math.Float32frombits(0x3f808001), 0x3f81},
+	}
+	for _, tt := range tests {
+		if got := BFloat16FromFloat32(tt.in); got != tt.want {
+			t.Errorf("BFloat16FromFloat32(%g) = %#04x, want %#04x", tt.in, got, tt.want)
+		}
+	}
+	if b := BFloat16FromFloat32(math.Float32frombits(0x7f800001)); !math.IsNaN(float64(BFloat16ToFloat32(b))) {
+		t.Errorf("NaN with a low payload became %#04x", b)
+	}
+	for i := 0; i <= math.MaxUint16; i++ {
+		b := uint16(i)
+		if f := BFloat16ToFloat32(b); !math.IsNaN(float64(f)) && BFloat16FromFloat32(f) != b {
+			t.Fatalf("BF16 %#04x did not round-trip", b)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	src := []float32{0.1, -0.25, 0.7071, 1}
+	packed := make([]uint16, len(src))
+	out := make([]float32, len(src))
+
+	EncodeFP16(packed, src)
+	DecodeFP16(out, packed)
+	for i := range src {
+		if d := math.Abs(float64(out[i] - src[i])); d > 1e-3 {
+			t.Errorf("FP16 element %d: %g -> %g", i, src[i], out[i])
+		}
+	}
+
+	EncodeBF16(packed, src)
+	DecodeBF16(out, packed)
+	for i := range src {
+		if d := math.Abs(float64(out[i] - src[i])); d > 4e-3 {
+			t.Errorf("BF16 element %d: %g -> %g", i, src[i], out[i])
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Package gpu - on-disk embedding index for fast startup.
+//
+// Rebuilding an EmbeddingIndex from the store means decoding every node and
+// re-uploading every vector. SaveFile writes the index in a layout that can
+// be memory-mapped and handed to the GPU as-is:
+//
+//	offset 0     header (64 bytes, see indexHeader)
+//	offset 4096  vectors: count × dimensions float32, little-endian, contiguous
+//	after        node IDs: count × [len:4][bytes]
+//
+// The vector block starts on a page boundary, so the mapping can be read as a
+// []float32 without decoding. Each section and the header carry a CRC32-C
+// checksum that LoadFile verifies before anything is used.
+package gpu
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"unsafe"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/cuda"
+)
+
+// Index file layout constants.
+const (
+	indexFileMagic   = "NORNVIDX"
+	indexFileVersion = 1
+	indexHeaderSize  = 64
+	indexVectorAlign = 4096 // Page-aligned vector block
+
+	// uploadChunkFloats is the number of float32 values per host-to-GPU
+	// transfer (64MB). Larger indexes are uploaded through a pinned staging
+	// buffer in chunks of this size.
+	uploadChunkFloats = 16 << 20
+)
+
+var (
+	// ErrIndexCorrupt is returned when an index file fails validation.
+	ErrIndexCorrupt = errors.New("gpu: index file corrupt")
+	// ErrIndexChecksum is returned when an index file checksum does not match.
+	ErrIndexChecksum = errors.New("gpu: index file checksum mismatch")
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// indexHeader is the fixed-size file header.
+type indexHeader struct {
+	Dimensions    uint32
+	Count         uint64
+	VectorsOffset uint64
+	IDsOffset     uint64
+	IDsLength     uint64
+	VectorsCRC    uint32
+	IDsCRC        uint32
+}
+
+func (h *indexHeader) encode() []byte {
+	buf := make([]byte, indexHeaderSize)
+	copy(buf, indexFileMagic)
+	binary.LittleEndian.PutUint32(buf[8:], indexFileVersion)
+	binary.LittleEndian.PutUint32(buf[12:], h.Dimensions)
+	binary.LittleEndian.PutUint64(buf[16:], h.Count)
+	binary.LittleEndian.PutUint64(buf[24:], h.VectorsOffset)
+	binary.LittleEndian.PutUint64(buf[32:], h.IDsOffset)
+	binary.LittleEndian.PutUint64(buf[40:], h.IDsLength)
+	binary.LittleEndian.PutUint32(buf[48:], h.VectorsCRC)
+	binary.LittleEndian.PutUint32(buf[52:], h.IDsCRC)
+	binary.LittleEndian.PutUint32(buf[56:], crc32.Checksum(buf[:56], crc32c))
+	return buf
+}
+
+func decodeIndexHeader(buf []byte) (*indexHeader, error) {
+	if len(buf) < indexHeaderSize || string(buf[:8]) != indexFileMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrIndexCorrupt)
+	}
+	if crc32.Checksum(buf[:56], crc32c) != binary.LittleEndian.Uint32(buf[56:]) {
+		return nil, fmt.Errorf("%w: header", ErrIndexChecksum)
+	}
+	if v := binary.LittleEndian.Uint32(buf[8:]); v != indexFileVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrIndexCorrupt, v)
+	}
+	return &indexHeader{
+		Dimensions:    binary.LittleEndian.Uint32(buf[12:]),
+		Count:         binary.LittleEndian.Uint64(buf[16:]),
+		VectorsOffset: binary.LittleEndian.Uint64(buf[24:]),
+		IDsOffset:     binary.LittleEndian.Uint64(buf[32:]),
+		IDsLength:     binary.LittleEndian.Uint64(buf[40:]),
+		VectorsCRC:    binary.LittleEndian.Uint32(buf[48:]),
+		IDsCRC:        binary.LittleEndian.Uint32(buf[52:]),
+	}, nil
+}
+
+// SaveFile writes the index to path in the memory-mappable layout.
+// The file is written to a temporary name and renamed into place, so a
+// crash never leaves a truncated index behind.
+//
+// Example:
+//
+//	if err := index.SaveFile(filepath.Join(dataDir, "vectors.idx")); err != nil {
+//		log.Printf("index not persisted: %v", err)
+//	}
+func (ei *EmbeddingIndex) SaveFile(path string) error {
+	ei.mu.RLock()
+	defer ei.mu.RUnlock()
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp) // No-op after a successful rename
+
+	if err := ei.writeFile(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (ei *EmbeddingIndex) writeFile(f *os.File) error {
+	h := &indexHeader{
+		Dimensions:    uint32(ei.dimensions),
+		Count:         uint64(len(ei.nodeIDs)),
+		VectorsOffset: indexVectorAlign,
+	}
+
+	// Reserve the header and padding; the header is written last
+	if _, err := f.Write(make([]byte, indexVectorAlign)); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriterSize(f, 1<<20)
+	vecCRC := crc32.New(crc32c)
+	out := io.MultiWriter(w, vecCRC)
+	chunk := make([]byte, 0, 1<<20)
+	for _, v := range ei.cpuVectors {
+		chunk = binary.LittleEndian.AppendUint32(chunk, math.Float32bits(v))
+		if len(chunk) == cap(chunk) {
+			if _, err := out.Write(chunk); err != nil {
+				return err
+			}
+			chunk = chunk[:0]
+		}
+	}
+	if _, err := out.Write(chunk); err != nil {
+		return err
+	}
+	h.VectorsCRC = vecCRC.Sum32()
+	h.IDsOffset = h.VectorsOffset + uint64(len(ei.cpuVectors))*4
+
+	idCRC := crc32.New(crc32c)
+	out = io.MultiWriter(w, idCRC)
+	var lenBuf [4]byte
+	for _, id := range ei.nodeIDs {
+		binary.LittleEndian.PutUint32(lenBuf[:], uint32(len(id)))
+		if _, err := out.Write(lenBuf[:]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(out, id); err != nil {
+			return err
+		}
+		h.IDsLength += 4 + uint64(len(id))
+	}
+	h.IDsCRC = idCRC.Sum32()
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	_, err := f.WriteAt(h.encode(), 0)
+	return err
+}
+
+// LoadFile replaces the index contents with an index written by SaveFile.
+//
+// The file is memory-mapped and every checksum is verified before the index
+// is touched; on any error the index is left unchanged. If the GPU is
+// enabled, the vectors are uploaded straight from the mapping in large
+// chunks through pinned host memory (CUDA) or a shared buffer (Metal). A
+// failed upload is not an error: the index stays usable on the CPU and
+// SyncToGPU can be retried.
+//
+// Returns ErrInvalidDimensions if the file was written for other dimensions.
+func (ei *EmbeddingIndex) LoadFile(path string) error {
+	data, unmap, err := mapIndexFile(path)
+	if err != nil {
+		return err
+	}
+	defer unmap()
+
+	h, err := decodeIndexHeader(data)
+	if err != nil {
+		return err
+	}
+	if int(h.Dimensions) != ei.dimensions {
+		return ErrInvalidDimensions
+	}
+
+	size := uint64(len(data))
+	vecBytes := h.Count * uint64(h.Dimensions) * 4
+	if h.VectorsOffset < indexHeaderSize || h.VectorsOffset > size || vecBytes > size-h.VectorsOffset ||
+		h.IDsOffset != h.VectorsOffset+vecBytes || h.IDsLength > size-h.IDsOffset {
+		return fmt.Errorf("%w: sections out of bounds", ErrIndexCorrupt)
+	}
+	vecData := data[h.VectorsOffset : h.VectorsOffset+vecBytes]
+	idData := data[h.IDsOffset : h.IDsOffset+h.IDsLength]
+	if crc32.Checksum(vecData, crc32c) != h.VectorsCRC {
+		return fmt.Errorf("%w: vectors", ErrIndexChecksum)
+	}
+	if crc32.Checksum(idData, crc32c) != h.IDsCRC {
+		return fmt.Errorf("%w: node IDs", ErrIndexChecksum)
+	}
+
+	capacity := min(h.Count, h.IDsLength/4) // Never trust a count before parsing
+	nodeIDs := make([]string, 0, capacity)
+	idToIndex := make(map[string]int, capacity)
+	for off := uint64(0); off < uint64(len(idData)); {
+		if off+4 > uint64(len(idData)) {
+			return fmt.Errorf("%w: truncated node ID", ErrIndexCorrupt)
+		}
+		n := uint64(binary.LittleEndian.Uint32(idData[off:]))
+		off += 4
+		if off+n > uint64(len(idData)) {
+			return fmt.Errorf("%w: truncated node ID", ErrIndexCorrupt)
+		}
+		id := string(idData[off : off+n])
+		off += n
+		if _, dup := idToIndex[id]; dup {
+			return fmt.Errorf("%w: duplicate node ID %q", ErrIndexCorrupt, id)
+		}
+		idToIndex[id] = len(nodeIDs)
+		nodeIDs = append(nodeIDs, id)
+	}
+	if uint64(len(nodeIDs)) != h.Count {
+		return fmt.Errorf("%w: %d node IDs for %d vectors", ErrIndexCorrupt, len(nodeIDs), h.Count)
+	}
+
+	mapped := float32View(vecData)
+	vectors := make([]float32, len(mapped), max(len(mapped), cap(ei.cpuVectors)))
+	copy(vectors, mapped)
+
+	ei.mu.Lock()
+	defer ei.mu.Unlock()
+	ei.nodeIDs = nodeIDs
+	ei.idToIndex = idToIndex
+	ei.cpuVectors = vectors
+	ei.gpuSynced = false
+
+	if ei.manager != nil && ei.manager.IsEnabled() && len(mapped) > 0 && ei.manager.device != nil {
+		switch ei.manager.device.Backend {
+		case BackendCUDA:
+			_ = ei.syncToCUDA(mapped)
+		case BackendMetal:
+			_ = ei.syncToMetal(mapped)
+		}
+	}
+	return nil
+}
+
+// float32View returns little-endian float32 data as a slice. On
+// little-endian hosts with aligned data this is a zero-copy view of the
+// mapping; otherwise the values are decoded.
+func float32View(b []byte) []float32 {
+	n := len(b) / 4
+	if n == 0 {
+		return nil
+	}
+	if nativeLittleEndian && uintptr(unsafe.Pointer(&b[0]))%4 == 0 {
+		return unsafe.Slice((*float32)(unsafe.Pointer(&b[0])), n)
+	}
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return out
+}
+
+var nativeLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// uploadCUDA copies vectors to a new device buffer. Small indexes go in one
+// transfer; large ones are staged through a pinned host buffer so each
+// chunk is a direct DMA transfer instead of a pageable copy.
+func (ei *EmbeddingIndex) uploadCUDA(vectors []float32) (*cuda.Buffer, error) {
+	if len(vectors) <= uploadChunkFloats {
+		return ei.cudaDevice.NewBuffer(vectors, cuda.MemoryDevice)
+	}
+
+	buffer, err := ei.cudaDevice.NewEmptyBuffer(uint64(len(vectors)), cuda.MemoryDevice)
+	if err != nil {
+		return nil, err
+	}
+	staging, err := ei.cudaDevice.NewEmptyBuffer(uploadChunkFloats, cuda.MemoryPinned)
+	if err != nil {
+		buffer.Release()
+		return nil, err
+	}
+	defer staging.Release()
+
+	for off := 0; off < len(vectors); off += uploadChunkFloats {
+		end := min(off+uploadChunkFloats, len(vectors))
+		if err := staging.WriteFloat32(0, vectors[off:end]); err != nil {
+			buffer.Release()
+			return nil, err
+		}
+		if err := buffer.CopyFrom(staging, off, end-off); err != nil {
+			buffer.Release()
+			return nil, err
+		}
+	}
+	return buffer, nil
+}
+
+// LoadFile loads embeddings written by SaveFile and discards any cluster
+// state, which no longer matches; call Cluster to rebuild it.
+func (ci *ClusterIndex) LoadFile(path string) error {
+	if err := ci.EmbeddingIndex.LoadFile(path); err != nil {
+		return err
+	}
+
+	ci.clusterMu.Lock()
+	defer ci.clusterMu.Unlock()
+	ci.centroids = nil
+	ci.assignments = nil
+	ci.clusterMap = make(map[int][]int)
+	ci.pendingUpdates = ci.pendingUpdates[:0]
+	ci.updatesSinceCluster = 0
+	ci.clustered = false
+	return nil
+}
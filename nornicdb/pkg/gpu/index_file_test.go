@@ -0,0 +1,178 @@
+package gpu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newFileTestIndex(t testing.TB, n, dims int) *EmbeddingIndex {
+	t.Helper()
+	m, _ := NewManager(nil)
+	ei := NewEmbeddingIndex(m, &EmbeddingIndexConfig{Dimensions: dims, InitialCap: n})
+	for i := 0; i < n; i++ {
+		vec := make([]float32, dims)
+		for j := range vec {
+			vec[j] = float32((i*31+j*7)%101) / 101
+		}
+		if err := ei.Add(fmt.Sprintf("node-%d", i), vec); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	return ei
+}
+
+func TestEmbeddingIndexSaveLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.idx")
+	ei := newFileTestIndex(t, 50, 16)
+	ei.Remove("node-3") // Saved order differs from insertion order
+
+	if err := ei.SaveFile(path); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temporary file should be renamed")
+	}
+
+	m, _ := NewManager(nil)
+	loaded := NewEmbeddingIndex(m, &EmbeddingIndexConfig{Dimensions: 16})
+	if err := loaded.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if loaded.Count() != 49 {
+		t.Fatalf("expected 49 embeddings, got %d", loaded.Count())
+	}
+	if loaded.Has("node-3") {
+		t.Error("removed node should not be loaded")
+	}
+	for _, id := range []string{"node-0", "node-49", "node-17"} {
+		want, _ := ei.Get(id)
+		got, ok := loaded.Get(id)
+		if !ok {
+			t.Fatalf("%s missing after load", id)
+		}
+		for i := range want {
+			if want[i] != got[i] {
+				t.Fatalf("%s[%d] = %v, want %v", id, i, got[i], want[i])
+			}
+		}
+	}
+
+	// The loaded index stays writable
+	if err := loaded.Add("node-new", make([]float32, 16)); err != nil {
+		t.Fatalf("Add() after load error = %v", err)
+	}
+	query, _ := ei.Get("node-17")
+	results, err := loaded.Search(query, 1)
+	if err != nil || len(results) != 1 || results[0].ID != "node-17" {
+		t.Errorf("Search() after load = %v, %v", results, err)
+	}
+}
+
+func TestEmbeddingIndexLoadFileValidation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vectors.idx")
+	if err := newFileTestIndex(t, 10, 8).SaveFile(path); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+	good, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func([]byte) []byte
+		want    error
+	}{
+		{"vector bit flip", func(b []byte) []byte { b[indexVectorAlign+5] ^= 0x01; return b }, ErrIndexChecksum},
+		{"node ID bit flip", func(b []byte) []byte { b[len(b)-1] ^= 0x01; return b }, ErrIndexChecksum},
+		{"header bit flip", func(b []byte) []byte { b[20] ^= 0x01; return b }, ErrIndexChecksum},
+		{"bad magic", func(b []byte) []byte { b[0] = 'X'; return b }, ErrIndexCorrupt},
+		{"truncated", func(b []byte) []byte { return b[:len(b)-10] }, ErrIndexCorrupt},
+		{"empty", func(b []byte) []byte { return nil }, ErrIndexCorrupt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.corrupt(append([]byte(nil), good...))
+			bad := filepath.Join(dir, "bad.idx")
+			if err := os.WriteFile(bad, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			ei := newFileTestIndex(t, 3, 8)
+			if err := ei.LoadFile(bad); !errors.Is(err, tt.want) {
+				t.Fatalf("LoadFile() error = %v, want %v", err, tt.want)
+			}
+			if ei.Count() != 3 {
+				t.Error("index should be unchanged after a failed load")
+			}
+		})
+	}
+
+	other := NewEmbeddingIndex(nil, &EmbeddingIndexConfig{Dimensions: 4})
+	if err := other.LoadFile(path); !errors.Is(err, ErrInvalidDimensions) {
+		t.Errorf("expected ErrInvalidDimensions, got %v", err)
+	}
+	if err := other.LoadFile(filepath.Join(dir, "missing.idx")); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error, got %v", err)
+	}
+}
+
+func TestClusterIndexLoadFileResetsClusters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.idx")
+	src := newFileTestIndex(t, 40, 8)
+	if err := src.SaveFile(path); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+
+	ci := NewClusterIndex(nil, &EmbeddingIndexConfig{Dimensions: 8}, &KMeansConfig{NumClusters: 4, MaxIterations: 5, InitMethod: "random"})
+	for i := 0; i < 20; i++ {
+		ci.Add(fmt.Sprintf("old-%d", i), make([]float32, 8))
+	}
+	if err := ci.Cluster(); err != nil {
+		t.Fatalf("Cluster() error = %v", err)
+	}
+
+	if err := ci.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if ci.IsClustered() {
+		t.Error("cluster state should be discarded on load")
+	}
+	if ci.Count() != 40 {
+		t.Errorf("expected 40 embeddings, got %d", ci.Count())
+	}
+	if err := ci.Cluster(); err != nil {
+		t.Fatalf("Cluster() after load error = %v", err)
+	}
+}
+
+func BenchmarkEmbeddingIndexLoad(b *testing.B) {
+	const n, dims = 20000, 384
+	path := filepath.Join(b.TempDir(), "vectors.idx")
+	ei := newFileTestIndex(b, n, dims)
+	if err := ei.SaveFile(path); err != nil {
+		b.Fatal(err)
+	}
+	data, _ := ei.Serialize()
+
+	b.Run("LoadFile", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			loaded := NewEmbeddingIndex(nil, &EmbeddingIndexConfig{Dimensions: dims})
+			if err := loaded.LoadFile(path); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Rebuild", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			loaded := NewEmbeddingIndex(nil, &EmbeddingIndexConfig{Dimensions: dims})
+			if err := loaded.Deserialize(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package gpu
+
+import "os"
+
+// mapIndexFile reads an index file into memory on platforms without mmap.
+func mapIndexFile(path string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -967,3 +967,22 @@ func (ci *ClusterIndex) Dimensions() int {
 func (ci *ClusterIndex) GetConfig() *KMeansConfig {
 	return ci.config
 }
+
+// Assignments returns the cluster ID of each node. Returns nil before
+// clustering.
+func (ci *ClusterIndex) Assignments() map[string]int {
+	ci.mu.RLock()
+	defer ci.mu.RUnlock()
+	ci.clusterMu.RLock()
+	defer ci.clusterMu.RUnlock()
+	if !ci.clustered {
+		return nil
+	}
+	assignments := make(map[string]int, len(ci.assignments))
+	for i, cluster := range ci.assignments {
+		if i < len(ci.nodeIDs) {
+			assignments[ci.nodeIDs[i]] = cluster
+		}
+	}
+	return assignments
+}
@@ -350,6 +350,37 @@ func TestClusterIndex_GetClusterMembers(t *testing.T) {
 	}
 }
 
+func TestClusterIndex_Assignments(t *testing.T) {
+	ci := NewClusterIndex(testManager(), embConfig(testDims), &KMeansConfig{
+		NumClusters:   3,
+		MaxIterations: 50,
+		InitMethod:    "kmeans++",
+	})
+
+	nodeIDs, embeddings, _ := generateClusteredEmbeddings(3, 10, testDims)
+	for i, emb := range embeddings {
+		ci.Add(nodeIDs[i], emb)
+	}
+
+	if ci.Assignments() != nil {
+		t.Error("Assignments should return nil before clustering")
+	}
+
+	if err := ci.Cluster(); err != nil {
+		t.Fatalf("Cluster() error = %v", err)
+	}
+
+	assignments := ci.Assignments()
+	if len(assignments) != 30 {
+		t.Fatalf("expected 30 assignments, got %d", len(assignments))
+	}
+	for i, id := range nodeIDs {
+		if got := ci.FindNearestCentroid(embeddings[i]); got != assignments[id] {
+			t.Errorf("%s assigned to %d, nearest centroid is %d", id, assignments[id], got)
+		}
+	}
+}
+
 func TestClusterIndex_SearchWithClusters(t *testing.T) {
 	ci := NewClusterIndex(testManager(), embConfig(testDims), &KMeansConfig{
 		NumClusters:   5,
@@ -0,0 +1,143 @@
+// Package lanes schedules concurrent work on a GPU device.
+//
+// A device used to serialize every operation behind one mutex, so
+// concurrent searches queued up even when the hardware could overlap them.
+// Instead, a device now owns a fixed set of lanes - CUDA streams, OpenCL
+// command queues - each with its own native handles and scratch memory. An
+// operation borrows a lane for its duration; operations on different lanes
+// run concurrently.
+//
+// Freeing native resources while another goroutine still uses them would
+// crash the process, so lifetimes are guarded too: Pool.Close and
+// Guard.Close wait for in-flight operations, and operations started after
+// Close fail instead of touching freed memory.
+//
+// Example (in a backend bridge):
+//
+//	stream, ok := d.streams.Acquire()
+//	if !ok {
+//		return ErrDeviceReleased
+//	}
+//	defer d.streams.Release(stream)
+//	if !embeddings.life.Use() {
+//		return ErrInvalidBuffer
+//	}
+//	defer embeddings.life.Done()
+//	// launch kernels on stream
+package lanes
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DefaultLanes returns the default number of lanes per device: enough to
+// keep a GPU busy while other lanes copy results back, capped by the number
+// of CPUs that can drive them.
+func DefaultLanes() int {
+	return min(4, max(1, runtime.GOMAXPROCS(0)))
+}
+
+// Pool is a fixed set of lanes. It is safe for concurrent use.
+type Pool[T any] struct {
+	free chan T
+	all  []T
+	life Guard // held by every borrowed lane
+}
+
+// NewPool returns a pool of the given lanes.
+func NewPool[T any](lanes []T) *Pool[T] {
+	p := &Pool[T]{free: make(chan T, len(lanes)), all: lanes}
+	for _, lane := range lanes {
+		p.free <- lane
+	}
+	return p
+}
+
+// Len returns the number of lanes, the maximum number of concurrent
+// operations.
+func (p *Pool[T]) Len() int {
+	return cap(p.free)
+}
+
+// Acquire borrows a lane, waiting until one is free. It returns false if
+// the pool is closed; otherwise the caller must Release the lane. A caller
+// must not Acquire a second lane from the same pool while holding one.
+func (p *Pool[T]) Acquire() (T, bool) {
+	if !p.life.Use() {
+		var zero T
+		return zero, false
+	}
+	return <-p.free, true
+}
+
+// Release returns a lane borrowed with Acquire.
+func (p *Pool[T]) Release(lane T) {
+	p.free <- lane
+	p.life.Done()
+}
+
+// Close waits for borrowed lanes to be released, then calls destroy for
+// each lane. Later Acquire calls fail. Close returns false, without calling
+// destroy, if the pool was already closed.
+func (p *Pool[T]) Close(destroy func(T)) bool {
+	if !p.life.Close() {
+		return false
+	}
+	for _, lane := range p.all {
+		destroy(lane)
+	}
+	p.all = nil
+	return true
+}
+
+// Guard protects a native resource, such as a device buffer, that one
+// goroutine may free while others use it. Use never blocks, so a goroutine
+// may hold several guards without risking deadlock. The zero value is ready
+// to use.
+type Guard struct {
+	mu     sync.Mutex
+	users  int
+	closed bool
+	idle   *sync.Cond // signalled when users drops to 0 during Close
+}
+
+// Use marks the start of an operation on the resource. It returns false if
+// the resource was closed; otherwise the caller must call Done.
+func (g *Guard) Use() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	g.users++
+	return true
+}
+
+// Done marks the end of an operation started with Use.
+func (g *Guard) Done() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.users--
+	if g.users == 0 && g.idle != nil {
+		g.idle.Broadcast()
+	}
+}
+
+// Close marks the resource closed and waits for operations in progress. It
+// returns true only for the first call, which should free the resource.
+func (g *Guard) Close() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	g.closed = true
+	if g.idle == nil {
+		g.idle = sync.NewCond(&g.mu)
+	}
+	for g.users > 0 {
+		g.idle.Wait()
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package lanes
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolBoundsConcurrency(t *testing.T) {
+	pool := NewPool([]int{0, 1, 2})
+	if pool.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", pool.Len())
+	}
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lane, ok := pool.Acquire()
+			if !ok {
+				t.Error("Acquire failed on an open pool")
+				return
+			}
+			defer pool.Release(lane)
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > 3 {
+		t.Errorf("%d operations ran at once on 3 lanes", got)
+	}
+	if got := peak.Load(); got < 2 {
+		t.Errorf("operations did not overlap (peak %d)", got)
+	}
+}
+
+func TestPoolCloseWaitsForInFlight(t *testing.T) {
+	pool := NewPool([]string{"a", "b"})
+	lane, _ := pool.Acquire()
+
+	var destroyed []string
+	closed := make(chan bool)
+	go func() {
+		closed <- pool.Close(func(l string) { destroyed = append(destroyed, l) })
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a lane was borrowed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	pool.Release(lane)
+	if !<-closed {
+		t.Fatal("first Close should report true")
+	}
+	if len(destroyed) != 2 {
+		t.Errorf("destroyed %v, want both lanes", destroyed)
+	}
+	if _, ok := pool.Acquire(); ok {
+		t.Error("Acquire should fail after Close")
+	}
+	if pool.Close(func(string) { t.Error("lanes destroyed twice") }) {
+		t.Error("second Close should report false")
+	}
+}
+
+func TestGuard(t *testing.T) {
+	var g Guard
+	if !g.Use() || !g.Use() {
+		t.Fatal("Use should succeed on an open resource")
+	}
+
+	closed := make(chan bool)
+	go func() { closed <- g.Close() }()
+
+	g.Done()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while the resource was in use")
+	case <-time.After(20 * time.Millisecond):
+	}
+	g.Done()
+
+	if !<-closed {
+		t.Error("first Close should report true")
+	}
+	if g.Use() {
+		t.Error("Use should fail after Close")
+	}
+	if g.Close() {
+		t.Error("second Close should report false")
+	}
+}
+
+// BenchmarkPoolConcurrentLoad simulates searches that keep a lane busy for
+// 200µs (a kernel launch plus result copy) under parallel load. With one lane
+// - the old per-device mutex - throughput is flat; more lanes overlap them.
+func BenchmarkPoolConcurrentLoad(b *testing.B) {
+	for _, n := range []int{1, 2, 4} {
+		b.Run(fmt.Sprintf("lanes=%d", n), func(b *testing.B) {
+			lanes := make([]int, n)
+			pool := NewPool(lanes)
+			b.SetParallelism(4)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					lane, _ := pool.Acquire()
+					time.Sleep(200 * time.Microsecond)
+					pool.Release(lane)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,465 @@
+// Package gpu - VRAM accounting, pinning and eviction.
+//
+// A Device fails NewBuffer with ErrOutOfMemory once VRAM is exhausted, and
+// nothing records which buffers hold it. MemoryManager wraps a Device and
+// tracks every buffer created through it. When an allocation does not fit
+// the budget, or the device reports it is out of memory, the least recently
+// searched buffers are copied back to host memory and freed until it does.
+// An evicted buffer keeps working: reads and writes go to the host copy,
+// and the next search uploads it again, evicting others if needed. Pinned
+// buffers, such as the shards of a hot index, are never evicted.
+//
+//	mem := gpu.NewMemoryManager(dev, 0) // Budget: 80% of device memory
+//	hot, err := mem.NewLabeledBuffer("docs/shard-0", embeddings)
+//	if err != nil {
+//		return err
+//	}
+//	hot.Pin()
+//	for _, info := range mem.Residency() {
+//		fmt.Println(info.Label, info.Bytes, info.Resident)
+//	}
+package gpu
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrBufferReleased is returned by operations on a released ManagedBuffer.
+var ErrBufferReleased = errors.New("gpu: buffer released")
+
+// MemoryManager is a Device that tracks the VRAM used by its buffers and
+// evicts the least recently searched ones to host memory when an
+// allocation does not fit. It is safe for concurrent use.
+type MemoryManager struct {
+	device Device
+	budget uint64 // Bytes; 0 = no limit beyond the device's own
+
+	mu        sync.Mutex
+	buffers   map[uint64]*ManagedBuffer
+	nextID    uint64
+	clock     uint64 // Orders buffer uses for LRU eviction
+	resident  uint64 // Bytes on the device
+	evictions int64
+	restores  int64
+	failures  int64
+}
+
+// ManagedBuffer is a DeviceBuffer created by a MemoryManager. It is either
+// resident on the device or evicted to host memory.
+type ManagedBuffer struct {
+	mgr   *MemoryManager
+	id    uint64
+	label string
+	size  uint64
+
+	// Guarded by mgr.mu
+	device   DeviceBuffer // nil while evicted
+	host     []float32    // Contents while evicted
+	pinned   bool
+	released bool
+	active   int    // Operations using device
+	lastUsed uint64 // mgr.clock at creation or the last search
+	usedAt   time.Time
+	searches int64
+}
+
+// BufferInfo describes one buffer of a MemoryManager.
+type BufferInfo struct {
+	ID       uint64
+	Label    string
+	Bytes    uint64
+	Resident bool
+	Pinned   bool
+	Searches int64
+	LastUsed time.Time // Zero if never searched
+}
+
+// MemoryStats summarizes a MemoryManager.
+type MemoryStats struct {
+	BudgetBytes   uint64 // 0 = limited only by the device
+	ResidentBytes uint64
+	PinnedBytes   uint64
+	EvictedBytes  uint64 // Held in host memory
+	Buffers       int
+	Evictions     int64 // Buffers copied back to host memory
+	Restores      int64 // Evicted buffers uploaded again
+	AllocFailures int64 // Allocations that failed even after evicting
+}
+
+// NewMemoryManager wraps device. Buffers may use up to budget bytes of
+// device memory; 0 uses 80% of the device's memory, or no limit if the
+// device does not report it. The manager owns device: Release releases it.
+func NewMemoryManager(device Device, budget uint64) *MemoryManager {
+	if budget == 0 {
+		budget = uint64(device.MemoryMB()) * 1024 * 1024 * 8 / 10
+	}
+	return &MemoryManager{
+		device:  device,
+		budget:  budget,
+		buffers: make(map[uint64]*ManagedBuffer),
+	}
+}
+
+// Backend returns the backend of the managed device.
+func (m *MemoryManager) Backend() Backend { return m.device.Backend() }
+
+// Name returns the name of the managed device.
+func (m *MemoryManager) Name() string { return m.device.Name() }
+
+// MemoryMB returns the memory of the managed device.
+func (m *MemoryManager) MemoryMB() int { return m.device.MemoryMB() }
+
+// NewBuffer uploads data to the device, evicting other buffers if needed.
+func (m *MemoryManager) NewBuffer(data []float32) (DeviceBuffer, error) {
+	buf, err := m.NewLabeledBuffer("", data)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// NewLabeledBuffer is NewBuffer with a label reported by Residency.
+func (m *MemoryManager) NewLabeledBuffer(label string, data []float32) (*ManagedBuffer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	b := &ManagedBuffer{mgr: m, id: m.nextID, label: label, size: uint64(len(data)) * 4}
+	device, err := m.allocate(b, data)
+	if err != nil {
+		return nil, err
+	}
+	b.device = device
+	m.clock++
+	b.lastUsed = m.clock // New buffers rank as just used
+	m.buffers[b.id] = b
+	return b, nil
+}
+
+// allocate uploads data for b, first evicting buffers until it fits the
+// budget and then for as long as the device is out of memory. The caller
+// holds m.mu and adds b.size to m.resident on success.
+func (m *MemoryManager) allocate(b *ManagedBuffer, data []float32) (DeviceBuffer, error) {
+	if m.budget > 0 && b.size > m.budget {
+		m.failures++
+		return nil, fmt.Errorf("%w: %d bytes exceed the %d byte budget", ErrDataTooLarge, b.size, m.budget)
+	}
+	for m.budget > 0 && m.resident+b.size > m.budget {
+		if !m.evictOne(b) {
+			m.failures++
+			return nil, fmt.Errorf("%w: %d bytes do not fit beside %d pinned or busy bytes", ErrOutOfMemory, b.size, m.resident)
+		}
+	}
+	for {
+		device, err := m.device.NewBuffer(data)
+		if err == nil {
+			m.resident += b.size
+			return device, nil
+		}
+		if !isOutOfMemory(err) || !m.evictOne(b) {
+			m.failures++
+			return nil, err
+		}
+	}
+}
+
+// evictOne copies the least recently used buffer other than keep back
+// to host memory and frees its device memory. Pinned buffers and buffers
+// in use are skipped. It reports false if no buffer could be evicted.
+func (m *MemoryManager) evictOne(keep *ManagedBuffer) bool {
+	var victims []*ManagedBuffer
+	for _, b := range m.buffers {
+		if b != keep && b.device != nil && !b.pinned && b.active == 0 {
+			victims = append(victims, b)
+		}
+	}
+	sort.Slice(victims, func(i, j int) bool { return victims[i].lastUsed < victims[j].lastUsed })
+	for _, b := range victims {
+		host, err := b.device.ReadAt(0, int(b.size/4))
+		if err != nil {
+			continue // Leave it resident rather than lose its contents
+		}
+		b.device.Release()
+		b.device = nil
+		b.host = host
+		m.resident -= b.size
+		m.evictions++
+		return true
+	}
+	return false
+}
+
+// acquire makes b resident and marks it in use; the caller must call
+// m.done(b). Searches also update b's LRU position.
+func (m *MemoryManager) acquire(b *ManagedBuffer, search bool) (DeviceBuffer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if b.released {
+		return nil, ErrBufferReleased
+	}
+	if b.device == nil {
+		device, err := m.allocate(b, b.host)
+		if err != nil {
+			return nil, err
+		}
+		b.device = device
+		b.host = nil
+		m.restores++
+	}
+	if search {
+		m.clock++
+		b.lastUsed = m.clock
+		b.usedAt = time.Now()
+		b.searches++
+	}
+	b.active++
+	return b.device, nil
+}
+
+func (m *MemoryManager) done(b *ManagedBuffer) {
+	m.mu.Lock()
+	b.active--
+	m.mu.Unlock()
+}
+
+// buffer returns buf as a buffer of this manager.
+func (m *MemoryManager) buffer(buf DeviceBuffer) (*ManagedBuffer, error) {
+	if b, ok := buf.(*ManagedBuffer); ok && b.mgr == m {
+		return b, nil
+	}
+	return nil, fmt.Errorf("gpu: %T is not a buffer of this memory manager", buf)
+}
+
+// NormalizeVectors normalizes buf on the device, uploading it first if it
+// was evicted.
+func (m *MemoryManager) NormalizeVectors(buf DeviceBuffer, n, dimensions int) error {
+	b, err := m.buffer(buf)
+	if err != nil {
+		return err
+	}
+	device, err := m.acquire(b, false)
+	if err != nil {
+		return err
+	}
+	defer m.done(b)
+	return m.device.NormalizeVectors(device, n, dimensions)
+}
+
+// Search searches buf on the device, uploading it first if it was evicted.
+// If the search itself runs out of memory, other buffers are evicted and
+// it is retried.
+func (m *MemoryManager) Search(buf DeviceBuffer, query []float32, n, dimensions, k int, opts SearchOptions) ([]Hit, error) {
+	var hits []Hit
+	err := m.search(buf, func(device DeviceBuffer) (err error) {
+		hits, err = m.device.Search(device, query, n, dimensions, k, opts)
+		return err
+	})
+	return hits, err
+}
+
+// SearchBatch is Search for several queries in one pass.
+func (m *MemoryManager) SearchBatch(buf DeviceBuffer, queries [][]float32, n, dimensions, k int, opts SearchOptions) ([][]Hit, error) {
+	var hits [][]Hit
+	err := m.search(buf, func(device DeviceBuffer) (err error) {
+		hits, err = m.device.SearchBatch(device, queries, n, dimensions, k, opts)
+		return err
+	})
+	return hits, err
+}
+
+func (m *MemoryManager) search(buf DeviceBuffer, run func(DeviceBuffer) error) error {
+	b, err := m.buffer(buf)
+	if err != nil {
+		return err
+	}
+	device, err := m.acquire(b, true)
+	if err != nil {
+		return err
+	}
+	defer m.done(b)
+
+	for {
+		err := run(device)
+		if !isOutOfMemory(err) {
+			return err
+		}
+		m.mu.Lock()
+		evicted := m.evictOne(b)
+		m.mu.Unlock()
+		if !evicted {
+			return err
+		}
+	}
+}
+
+// Release releases every buffer and the device.
+func (m *MemoryManager) Release() {
+	m.mu.Lock()
+	buffers := make([]*ManagedBuffer, 0, len(m.buffers))
+	for _, b := range m.buffers {
+		buffers = append(buffers, b)
+	}
+	m.mu.Unlock()
+
+	for _, b := range buffers {
+		b.Release()
+	}
+	m.device.Release()
+}
+
+// Residency lists the buffers, most recently used first.
+func (m *MemoryManager) Residency() []BufferInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buffers := make([]*ManagedBuffer, 0, len(m.buffers))
+	for _, b := range m.buffers {
+		buffers = append(buffers, b)
+	}
+	sort.Slice(buffers, func(i, j int) bool { return buffers[i].lastUsed > buffers[j].lastUsed })
+
+	infos := make([]BufferInfo, len(buffers))
+	for i, b := range buffers {
+		infos[i] = BufferInfo{
+			ID:       b.id,
+			Label:    b.label,
+			Bytes:    b.size,
+			Resident: b.device != nil,
+			Pinned:   b.pinned,
+			Searches: b.searches,
+			LastUsed: b.usedAt,
+		}
+	}
+	return infos
+}
+
+// Stats returns memory usage and eviction counts.
+func (m *MemoryManager) Stats() MemoryStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := MemoryStats{
+		BudgetBytes:   m.budget,
+		ResidentBytes: m.resident,
+		Buffers:       len(m.buffers),
+		Evictions:     m.evictions,
+		Restores:      m.restores,
+		AllocFailures: m.failures,
+	}
+	for _, b := range m.buffers {
+		switch {
+		case b.device == nil:
+			stats.EvictedBytes += b.size
+		case b.pinned:
+			stats.PinnedBytes += b.size
+		}
+	}
+	return stats
+}
+
+// Label returns the label given to NewLabeledBuffer.
+func (b *ManagedBuffer) Label() string { return b.label }
+
+// Size returns the buffer size in bytes.
+func (b *ManagedBuffer) Size() uint64 { return b.size }
+
+// Resident reports whether the buffer is in device memory.
+func (b *ManagedBuffer) Resident() bool {
+	b.mgr.mu.Lock()
+	defer b.mgr.mu.Unlock()
+	return b.device != nil
+}
+
+// Pin keeps the buffer in device memory, uploading it first if it was
+// evicted.
+func (b *ManagedBuffer) Pin() error {
+	if _, err := b.mgr.acquire(b, false); err != nil {
+		return err
+	}
+	b.mgr.mu.Lock()
+	b.pinned = true
+	b.active--
+	b.mgr.mu.Unlock()
+	return nil
+}
+
+// Unpin makes the buffer evictable again.
+func (b *ManagedBuffer) Unpin() {
+	b.mgr.mu.Lock()
+	b.pinned = false
+	b.mgr.mu.Unlock()
+}
+
+// ReadAt copies count values starting at value offset to the host.
+func (b *ManagedBuffer) ReadAt(offset, count int) ([]float32, error) {
+	m := b.mgr
+	m.mu.Lock()
+	if b.released {
+		m.mu.Unlock()
+		return nil, ErrBufferReleased
+	}
+	if b.device == nil {
+		defer m.mu.Unlock()
+		if offset < 0 || count < 0 || offset+count > len(b.host) {
+			return nil, fmt.Errorf("gpu: read of %d values at %d is out of range", count, offset)
+		}
+		return append([]float32(nil), b.host[offset:offset+count]...), nil
+	}
+	device := b.device
+	b.active++
+	m.mu.Unlock()
+	defer m.done(b)
+	return device.ReadAt(offset, count)
+}
+
+// WriteAt overwrites values starting at value offset.
+func (b *ManagedBuffer) WriteAt(offset int, data []float32) error {
+	m := b.mgr
+	m.mu.Lock()
+	if b.released {
+		m.mu.Unlock()
+		return ErrBufferReleased
+	}
+	if b.device == nil {
+		defer m.mu.Unlock()
+		if offset < 0 || offset+len(data) > len(b.host) {
+			return fmt.Errorf("gpu: write of %d values at %d is out of range", len(data), offset)
+		}
+		copy(b.host[offset:], data)
+		return nil
+	}
+	device := b.device
+	b.active++
+	m.mu.Unlock()
+	defer m.done(b)
+	return device.WriteAt(offset, data)
+}
+
+// Release frees the buffer's device and host memory.
+func (b *ManagedBuffer) Release() {
+	m := b.mgr
+	m.mu.Lock()
+	if b.released {
+		m.mu.Unlock()
+		return
+	}
+	b.released = true
+	delete(m.buffers, b.id)
+	device := b.device
+	if device != nil {
+		m.resident -= b.size
+	}
+	b.device = nil
+	b.host = nil
+	m.mu.Unlock()
+
+	// Backend buffers wait for operations in progress
+	if device != nil {
+		device.Release()
+	}
+}
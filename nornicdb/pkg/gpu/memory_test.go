@@ -0,0 +1,183 @@
+package gpu
+
+import (
+	"errors"
+	"testing"
+)
+
+// rows returns n rows of dims values, row i filled with i+1.
+func rows(n, dims int) []float32 {
+	data := make([]float32, n*dims)
+	for i := range data {
+		data[i] = float32(i/dims + 1)
+	}
+	return data
+}
+
+func TestMemoryManager_EvictsLeastRecentlySearched(t *testing.T) {
+	dev := &fakeDevice{}
+	mem := NewMemoryManager(dev, 3*64) // Three buffers of 64 bytes
+
+	var bufs []*ManagedBuffer
+	for _, label := range []string{"a", "b", "c"} {
+		b, err := mem.NewLabeledBuffer(label, rows(4, 4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bufs = append(bufs, b)
+	}
+	a, b, c := bufs[0], bufs[1], bufs[2]
+
+	// Search a and c, so b is least recently used
+	for _, buf := range []*ManagedBuffer{a, c} {
+		if _, err := mem.Search(buf, []float32{1, 0, 0, 0}, 4, 4, 1, SearchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err := mem.NewLabeledBuffer("d", rows(4, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Resident() || !a.Resident() || !c.Resident() || !d.Resident() {
+		t.Fatal("b should have been evicted")
+	}
+	if dev.buffers != 3 {
+		t.Fatalf("device buffers = %d, want 3", dev.buffers)
+	}
+
+	// The evicted buffer is read and written on the host
+	if err := b.WriteAt(4, []float32{9, 9, 9, 9}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := b.ReadAt(4, 2); got[0] != 9 || got[1] != 9 {
+		t.Fatalf("host read = %v", got)
+	}
+
+	// Searching it uploads it again, evicting the next least recently used
+	hits, err := mem.Search(b, []float32{1, 1, 1, 1}, 4, 4, 1, SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Resident() || a.Resident() {
+		t.Fatal("b should be restored in place of a")
+	}
+	if len(hits) != 1 {
+		t.Fatalf("hits = %v", hits)
+	}
+	if got, _ := b.ReadAt(4, 1); got[0] != 9 {
+		t.Fatal("restored buffer lost a host write")
+	}
+
+	stats := mem.Stats()
+	if stats.Evictions != 2 || stats.Restores != 1 || stats.ResidentBytes != 3*64 || stats.EvictedBytes != 64 || stats.Buffers != 4 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	infos := mem.Residency()
+	if len(infos) != 4 || infos[0].Label != "b" || infos[0].Searches != 1 || infos[0].LastUsed.IsZero() {
+		t.Fatalf("residency = %+v, want b first", infos)
+	}
+
+	mem.Release()
+	if dev.buffers != 0 || !dev.released {
+		t.Fatal("Release should free every buffer and the device")
+	}
+	if _, err := a.ReadAt(0, 1); !errors.Is(err, ErrBufferReleased) {
+		t.Errorf("read after Release: error = %v", err)
+	}
+}
+
+func TestMemoryManager_DeviceOutOfMemory(t *testing.T) {
+	dev := &fakeDevice{capacity: 2 * 64}
+	mem := NewMemoryManager(dev, 1<<30) // The device runs out first
+
+	a, _ := mem.NewLabeledBuffer("a", rows(4, 4))
+	b, _ := mem.NewLabeledBuffer("b", rows(4, 4))
+	if err := a.Pin(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := mem.NewLabeledBuffer("c", rows(4, 4))
+	if err != nil {
+		t.Fatalf("allocation should evict b: %v", err)
+	}
+	if !a.Resident() || b.Resident() || !c.Resident() {
+		t.Fatal("pinned a should stay resident and b be evicted")
+	}
+
+	// Evicting everything unpinned still leaves too little
+	a.Unpin()
+	if _, err := mem.NewLabeledBuffer("big", rows(16, 4)); !errors.Is(err, ErrOutOfMemory) {
+		t.Fatalf("oversized allocation: error = %v, want ErrOutOfMemory", err)
+	}
+	if mem.Stats().AllocFailures != 1 {
+		t.Errorf("stats = %+v, want one failure", mem.Stats())
+	}
+}
+
+func TestMemoryManager_Budget(t *testing.T) {
+	mem := NewMemoryManager(&fakeDevice{}, 0)
+	if got := mem.Stats().BudgetBytes; got != 1024*1024*1024*8/10 {
+		t.Errorf("default budget = %d, want 80%% of device memory", got)
+	}
+
+	mem = NewMemoryManager(&fakeDevice{}, 64)
+	a, _ := mem.NewLabeledBuffer("a", rows(4, 4))
+	if err := a.Pin(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.NewBuffer(rows(4, 4)); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("allocation beside a pinned buffer: error = %v, want ErrOutOfMemory", err)
+	}
+	if _, err := mem.NewBuffer(rows(8, 4)); !errors.Is(err, ErrDataTooLarge) {
+		t.Errorf("allocation over budget: error = %v, want ErrDataTooLarge", err)
+	}
+	if stats := mem.Stats(); stats.PinnedBytes != 64 || stats.AllocFailures != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestMemoryManager_ForeignBuffer(t *testing.T) {
+	mem := NewMemoryManager(&fakeDevice{}, 0)
+	other := NewMemoryManager(&fakeDevice{}, 0)
+	buf, _ := other.NewBuffer(rows(1, 4))
+	if _, err := mem.Search(buf, []float32{1, 0, 0, 0}, 1, 4, 1, SearchOptions{}); err == nil {
+		t.Error("search of another manager's buffer should fail")
+	}
+}
+
+func TestGPUEmbeddingIndex_Pinned(t *testing.T) {
+	dev := &fakeDevice{}
+	mem := NewMemoryManager(dev, 2*8)
+	accel := &Accelerator{config: DefaultConfig(), backend: dev.Backend(), device: mem, memory: mem}
+	if accel.Memory() != mem {
+		t.Fatal("accelerator does not report its memory manager")
+	}
+
+	hot := accel.NewGPUEmbeddingIndex(2)
+	cold := accel.NewGPUEmbeddingIndex(2)
+	hot.Add("h", []float32{1, 0})
+	cold.Add("c", []float32{0, 1})
+	if err := hot.SetPinned(true); err != nil {
+		t.Fatal(err)
+	}
+	for _, idx := range []*GPUEmbeddingIndex{hot, cold} {
+		if err := idx.SyncToGPU(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A third buffer only fits by evicting the unpinned index
+	if _, err := mem.NewBuffer([]float32{1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if !hot.rows.Buffer().(*ManagedBuffer).Resident() || cold.rows.Buffer().(*ManagedBuffer).Resident() {
+		t.Fatal("the pinned index should stay resident")
+	}
+
+	// The evicted index still searches on the GPU after a restore
+	results, backend, err := cold.SearchWithBackend([]float32{0, 1}, 1)
+	if err != nil || backend != "fake" || results[0].ID != "c" {
+		t.Fatalf("search = %v on %q, %v", results, backend, err)
+	}
+}
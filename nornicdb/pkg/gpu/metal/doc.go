@@ -34,8 +34,21 @@
 // Memory Requirements:
 //   - Each embedding: dimensions × 4 bytes
 //   - 1M embeddings @ 1024-dim = 4GB GPU memory
+//   - Half the above with NewBufferWithPrecision(embeddings, mode,
+//     metal.PrecisionFP16) or PrecisionBF16; Search and SearchBatch widen
+//     the elements in the kernel and accumulate in float32
+//   - Nearly a quarter with NewQuantizedBuffer(embeddings, dims, mode),
+//     which stores int8 codes and a float32 scale per vector (see package
+//     quant); the kernel dequantizes them as it reads
 //   - Unified memory architecture allows efficient CPU-GPU sharing
 //
+// Concurrency:
+//
+// A Device is safe for concurrent use: every operation encodes its own
+// command buffer on the shared (thread-safe) command queue. Buffer.Release
+// and Device.Release wait for operations in progress; later operations
+// return ErrInvalidBuffer or ErrDeviceReleased.
+//
 // Usage:
 //
 //	device, err := metal.NewDevice()
@@ -51,7 +64,10 @@
 //	}
 //
 //	// Search
-//	results, err := device.Search(buffer, query, 10, 0.7)
+//	results, err := device.Search(buffer, query, n, dims, 10, metal.SearchOptions{
+//		MinScore:    0.7,
+//		HasMinScore: true,
+//	})
 //
 // Build Requirements:
 //   - macOS 10.15+ or iOS 13+
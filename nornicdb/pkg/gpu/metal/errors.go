@@ -0,0 +1,41 @@
+package metal
+
+import "github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+
+// MTLCommandBufferError codes that classify a failed command buffer.
+const (
+	mtlCommandBufferErrorInternal        = 1
+	mtlCommandBufferErrorTimeout         = 2
+	mtlCommandBufferErrorPageFault       = 3
+	mtlCommandBufferErrorAccessRevoked   = 4
+	mtlCommandBufferErrorNotPermitted    = 7
+	mtlCommandBufferErrorOutOfMemory     = 8
+	mtlCommandBufferErrorInvalidResource = 9
+	mtlCommandBufferErrorMemoryless      = 10
+	mtlCommandBufferErrorDeviceRemoved   = 11
+	mtlCommandBufferErrorStackOverflow   = 12
+)
+
+// classify maps an MTLCommandBufferError code to a shared error kind, or
+// nil. Metal reports failed allocations as nil objects rather than codes;
+// those are classified by the operation (ErrBufferCreation).
+func classify(code int) error {
+	switch code {
+	case mtlCommandBufferErrorOutOfMemory, mtlCommandBufferErrorMemoryless:
+		return gpuerr.ErrOutOfMemory
+	case mtlCommandBufferErrorAccessRevoked, mtlCommandBufferErrorDeviceRemoved:
+		return gpuerr.ErrDeviceLost
+	case mtlCommandBufferErrorNotPermitted:
+		return gpuerr.ErrNotAvailable
+	case mtlCommandBufferErrorInternal, mtlCommandBufferErrorTimeout, mtlCommandBufferErrorPageFault,
+		mtlCommandBufferErrorInvalidResource, mtlCommandBufferErrorStackOverflow:
+		return gpuerr.ErrKernel
+	}
+	return nil
+}
+
+// newError builds the error returned for a failed op from the last command
+// buffer error code and message.
+func newError(op error, code int, msg string) error {
+	return gpuerr.New("metal", op, classify(code), code, msg)
+}
@@ -0,0 +1,35 @@
+package metal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+)
+
+func TestNewErrorClassifiesCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		op   error
+		code int
+		want error
+	}{
+		{"nil buffer", ErrBufferCreation, 0, gpuerr.ErrOutOfMemory},
+		{"command buffer out of memory", ErrKernelExecution, mtlCommandBufferErrorOutOfMemory, gpuerr.ErrOutOfMemory},
+		{"device removed", ErrKernelExecution, mtlCommandBufferErrorDeviceRemoved, gpuerr.ErrDeviceLost},
+		{"access revoked", ErrKernelExecution, mtlCommandBufferErrorAccessRevoked, gpuerr.ErrDeviceLost},
+		{"page fault", ErrKernelExecution, mtlCommandBufferErrorPageFault, gpuerr.ErrKernel},
+		{"no device", ErrDeviceCreation, 0, gpuerr.ErrNotAvailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newError(tt.op, tt.code, "native message")
+			if !errors.Is(err, tt.op) {
+				t.Errorf("%v should match %v", err, tt.op)
+			}
+			if got := gpuerr.KindOf(err); got != tt.want {
+				t.Errorf("kind = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
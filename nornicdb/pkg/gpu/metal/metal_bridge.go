@@ -49,7 +49,27 @@ int metal_compute_topk(
     MetalBuffer indices,
     MetalBuffer topk_scores,
     unsigned int n,
-    unsigned int k
+    unsigned int k,
+    float min_score,
+    MetalBuffer filter
+);
+
+int metal_search_batch(
+    MetalDevice device,
+    MetalBuffer embeddings,
+    MetalBuffer queries,
+    MetalBuffer scores,
+    MetalBuffer indices,
+    MetalBuffer topk_scores,
+    MetalBuffer filter,
+    unsigned int nq,
+    unsigned int n,
+    unsigned int dimensions,
+    unsigned int k,
+    float min_score,
+    bool normalized,
+    int precision,
+    unsigned long scales_offset
 );
 
 int metal_normalize_vectors(
@@ -61,6 +81,7 @@ int metal_normalize_vectors(
 
 // Error handling
 const char* metal_last_error(void);
+long metal_last_error_code(void);
 void metal_clear_error(void);
 
 // Memory tracking structs
@@ -107,19 +128,49 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"sync"
+	"math"
+	"runtime"
 	"unsafe"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+	"github.com/orneryd/nornicdb/pkg/gpu/lanes"
+	"github.com/orneryd/nornicdb/pkg/gpu/quant"
 )
 
 // Errors
 var (
-	ErrMetalNotAvailable = errors.New("metal: Metal is not available on this system")
-	ErrDeviceCreation    = errors.New("metal: failed to create Metal device")
-	ErrBufferCreation    = errors.New("metal: failed to create buffer")
-	ErrKernelExecution   = errors.New("metal: kernel execution failed")
+	ErrMetalNotAvailable = gpuerr.Sentinel("metal: Metal is not available on this system", gpuerr.ErrNotAvailable)
+	ErrDeviceCreation    = gpuerr.Sentinel("metal: failed to create Metal device", gpuerr.ErrNotAvailable)
+	ErrBufferCreation    = gpuerr.Sentinel("metal: failed to create buffer", gpuerr.ErrOutOfMemory)
+	ErrKernelExecution   = gpuerr.Sentinel("metal: kernel execution failed", gpuerr.ErrKernel)
 	ErrInvalidBuffer     = errors.New("metal: invalid buffer")
+	ErrDeviceReleased    = errors.New("metal: device released")
+	ErrOutOfRange        = errors.New("metal: range exceeds buffer size")
+	ErrPrecision         = errors.New("metal: operation does not support this buffer precision")
 )
 
+// lastError returns the pending native error as a failure of op and clears
+// it. The error matches op and, when the command buffer reported a
+// recognized code, one of the gpuerr kinds.
+func lastError(op error) error {
+	msg := C.GoString(C.metal_last_error())
+	code := int(C.metal_last_error_code())
+	C.metal_clear_error()
+	return newError(op, code, msg)
+}
+
+// call runs fn, which makes native calls and reports success, and returns
+// the native error as a failure of op if it fails. Native error state is
+// thread-local, so the goroutine stays on its thread until it is read.
+func call(op error, fn func() bool) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if fn() {
+		return nil
+	}
+	return lastError(op)
+}
+
 // StorageMode defines how buffer memory is managed.
 type StorageMode int
 
@@ -138,18 +189,27 @@ const (
 )
 
 // Device represents a Metal GPU device.
+//
+// A Device is safe for concurrent use. The Metal command queue is
+// thread-safe and every operation encodes its own command buffer, so
+// operations from different goroutines run concurrently.
 type Device struct {
 	ptr    C.MetalDevice
 	name   string
 	memory uint64
-	mu     sync.Mutex
+	life   lanes.Guard
 }
 
-// Buffer represents a Metal GPU buffer.
+// Buffer represents a Metal GPU buffer. Release waits for operations using
+// the buffer to finish.
 type Buffer struct {
-	ptr    C.MetalBuffer
-	size   uint64
-	device *Device
+	ptr       C.MetalBuffer
+	size      uint64
+	precision Precision
+	vectors   int // PrecisionInt8: vectors of dims components
+	dims      int
+	device    *Device
+	life      lanes.Guard
 }
 
 // SearchResult holds a similarity search result.
@@ -158,22 +218,36 @@ type SearchResult struct {
 	Score float32
 }
 
+// Compiled reports whether this binary was built with Metal support.
+const Compiled = true
+
 // IsAvailable checks if Metal is available on this system.
 func IsAvailable() bool {
 	return bool(C.metal_is_available())
 }
 
+// UnavailableReason explains why IsAvailable returns false.
+// Returns "" when Metal is available.
+func UnavailableReason() string {
+	if IsAvailable() {
+		return ""
+	}
+	return "no Metal-capable GPU found"
+}
+
 // NewDevice creates a new Metal device (uses default GPU).
 func NewDevice() (*Device, error) {
 	if !IsAvailable() {
 		return nil, ErrMetalNotAvailable
 	}
 
-	ptr := C.metal_create_device()
-	if ptr == nil {
-		errMsg := C.GoString(C.metal_last_error())
-		C.metal_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrDeviceCreation, errMsg)
+	var ptr C.MetalDevice
+	err := call(ErrDeviceCreation, func() bool {
+		ptr = C.metal_create_device()
+		return ptr != nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &Device{
@@ -183,12 +257,10 @@ func NewDevice() (*Device, error) {
 	}, nil
 }
 
-// Release frees the Metal device resources.
+// Release frees the Metal device resources after operations in progress
+// finish. Later operations return ErrDeviceReleased.
 func (d *Device) Release() {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	if d.ptr != nil {
+	if d.life.Close() && d.ptr != nil {
 		C.metal_release_device(d.ptr)
 		d.ptr = nil
 	}
@@ -211,31 +283,85 @@ func (d *Device) MemoryMB() int {
 
 // NewBuffer creates a new GPU buffer with copied data.
 func (d *Device) NewBuffer(data []float32, mode StorageMode) (*Buffer, error) {
+	return d.NewBufferWithPrecision(data, mode, PrecisionFP32)
+}
+
+// NewBufferWithPrecision creates a new GPU buffer with copied data stored
+// at precision. Half-precision data is converted on upload and takes half
+// the memory; Search and SearchBatch widen it in the kernel and accumulate
+// in fp32, while ComputeCosineSimilarity, ComputeTopK, NormalizeVectors and
+// the MPS operations need fp32 buffers and return ErrPrecision otherwise.
+// PrecisionInt8 buffers are made with NewQuantizedBuffer.
+func (d *Device) NewBufferWithPrecision(data []float32, mode StorageMode, precision Precision) (*Buffer, error) {
 	if len(data) == 0 {
 		return nil, errors.New("metal: cannot create empty buffer")
 	}
+	if err := precision.check(); err != nil {
+		return nil, err
+	}
+	if precision == PrecisionInt8 {
+		return nil, fmt.Errorf("%w: int8 buffers are made with NewQuantizedBuffer", ErrPrecision)
+	}
 
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	src := unsafe.Pointer(&data[0])
+	if precision != PrecisionFP32 {
+		packed := precision.encode(data)
+		src = unsafe.Pointer(&packed[0])
+	}
+	return d.newBuffer(src, uint64(len(data))*precision.elementSize(), mode, precision)
+}
 
-	size := C.ulong(len(data) * 4) // float32 = 4 bytes
-	ptr := C.metal_create_buffer(
-		d.ptr,
-		unsafe.Pointer(&data[0]),
-		size,
-		C.int(mode),
-	)
+// NewQuantizedBuffer creates a PrecisionInt8 buffer holding the row-major
+// vectors of dimensions components in data as int8 codes with a scale per
+// vector (see package quant), about a quarter of the memory of float32.
+// Search and SearchBatch dequantize the codes in the kernel and accumulate
+// in fp32; ReadAt and WriteAt work on whole vectors.
+func (d *Device) NewQuantizedBuffer(data []float32, dimensions uint32, mode StorageMode) (*Buffer, error) {
+	if len(data) == 0 {
+		return nil, errors.New("metal: cannot create empty buffer")
+	}
+	if dimensions == 0 || len(data)%int(dimensions) != 0 {
+		return nil, fmt.Errorf("metal: %d elements are not vectors of %d dimensions", len(data), dimensions)
+	}
+	image := quant.Pack(data, int(dimensions))
+	b, err := d.newBuffer(unsafe.Pointer(&image[0]), uint64(len(image)), mode, PrecisionInt8)
+	if err != nil {
+		return nil, err
+	}
+	b.vectors = len(data) / int(dimensions)
+	b.dims = int(dimensions)
+	return b, nil
+}
 
-	if ptr == nil {
-		errMsg := C.GoString(C.metal_last_error())
-		C.metal_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrBufferCreation, errMsg)
+// newBuffer creates a buffer holding a copy of the bytes at src, which
+// hold elements of precision.
+func (d *Device) newBuffer(src unsafe.Pointer, bytes uint64, mode StorageMode, precision Precision) (*Buffer, error) {
+	done, err := d.use()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	size := C.ulong(bytes)
+	var ptr C.MetalBuffer
+	err = call(ErrBufferCreation, func() bool {
+		ptr = C.metal_create_buffer(
+			d.ptr,
+			src,
+			size,
+			C.int(mode),
+		)
+		return ptr != nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &Buffer{
-		ptr:    ptr,
-		size:   uint64(size),
-		device: d,
+		ptr:       ptr,
+		size:      uint64(size),
+		precision: precision,
+		device:    d,
 	}, nil
 }
 
@@ -247,21 +373,25 @@ func (d *Device) NewBufferNoCopy(data []float32, mode StorageMode) (*Buffer, err
 		return nil, errors.New("metal: cannot create empty buffer")
 	}
 
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	done, err := d.use()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 
 	size := C.ulong(len(data) * 4)
-	ptr := C.metal_create_buffer_no_copy(
-		d.ptr,
-		unsafe.Pointer(&data[0]),
-		size,
-		C.int(mode),
-	)
-
-	if ptr == nil {
-		errMsg := C.GoString(C.metal_last_error())
-		C.metal_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrBufferCreation, errMsg)
+	var ptr C.MetalBuffer
+	err = call(ErrBufferCreation, func() bool {
+		ptr = C.metal_create_buffer_no_copy(
+			d.ptr,
+			unsafe.Pointer(&data[0]),
+			size,
+			C.int(mode),
+		)
+		return ptr != nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &Buffer{
@@ -273,20 +403,24 @@ func (d *Device) NewBufferNoCopy(data []float32, mode StorageMode) (*Buffer, err
 
 // NewEmptyBuffer creates an uninitialized GPU buffer.
 func (d *Device) NewEmptyBuffer(sizeBytes uint64, mode StorageMode) (*Buffer, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	ptr := C.metal_create_buffer(
-		d.ptr,
-		nil,
-		C.ulong(sizeBytes),
-		C.int(mode),
-	)
-
-	if ptr == nil {
-		errMsg := C.GoString(C.metal_last_error())
-		C.metal_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrBufferCreation, errMsg)
+	done, err := d.use()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var ptr C.MetalBuffer
+	err = call(ErrBufferCreation, func() bool {
+		ptr = C.metal_create_buffer(
+			d.ptr,
+			nil,
+			C.ulong(sizeBytes),
+			C.int(mode),
+		)
+		return ptr != nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &Buffer{
@@ -296,9 +430,9 @@ func (d *Device) NewEmptyBuffer(sizeBytes uint64, mode StorageMode) (*Buffer, er
 	}, nil
 }
 
-// Release frees the buffer resources.
+// Release frees the buffer resources once operations using it finish.
 func (b *Buffer) Release() {
-	if b.ptr != nil {
+	if b.life.Close() && b.ptr != nil {
 		C.metal_release_buffer(b.ptr)
 		b.ptr = nil
 	}
@@ -309,28 +443,98 @@ func (b *Buffer) Size() uint64 {
 	return b.size
 }
 
+// Precision returns how the buffer stores its elements.
+func (b *Buffer) Precision() Precision {
+	return b.precision
+}
+
+// requireFP32 returns ErrPrecision if any of buffers stores half precision
+// or int8.
+func requireFP32(buffers ...*Buffer) error {
+	for _, b := range buffers {
+		if b != nil && b.precision != PrecisionFP32 {
+			return fmt.Errorf("%w: %s", ErrPrecision, b.precision)
+		}
+	}
+	return nil
+}
+
 // Contents returns a pointer to the buffer's CPU-accessible memory.
-// Only valid for StorageShared and StorageManaged modes.
+// Only valid for StorageShared and StorageManaged modes, and only until the
+// buffer is released.
 func (b *Buffer) Contents() unsafe.Pointer {
 	return C.metal_buffer_contents(b.ptr)
 }
 
-// ReadFloat32 reads float32 values from the buffer.
+// use marks the start of an operation on each buffer and on the device.
+// The returned function ends the operation.
+func (d *Device) use(buffers ...*Buffer) (func(), error) {
+	for i, b := range buffers {
+		if b == nil || !b.life.Use() {
+			for _, held := range buffers[:i] {
+				held.life.Done()
+			}
+			return nil, ErrInvalidBuffer
+		}
+	}
+	if !d.life.Use() {
+		for _, b := range buffers {
+			b.life.Done()
+		}
+		return nil, ErrDeviceReleased
+	}
+	return func() {
+		d.life.Done()
+		for _, b := range buffers {
+			b.life.Done()
+		}
+	}, nil
+}
+
+// ReadFloat32 reads the first count float32 values from the buffer. It
+// returns nil on failure; use ReadAt to get the error.
 func (b *Buffer) ReadFloat32(count int) []float32 {
-	if count <= 0 || uint64(count*4) > b.size {
+	result, err := b.ReadAt(0, count)
+	if err != nil {
 		return nil
 	}
+	return result
+}
+
+// ReadAt reads count float32 values starting at element offset. The buffer
+// must be CPU-visible (StorageShared or StorageManaged). int8 elements are
+// dequantized; their range must cover whole vectors.
+func (b *Buffer) ReadAt(offset, count int) ([]float32, error) {
+	if err := checkRange(b, offset, count); err != nil {
+		return nil, err
+	}
+	if !b.life.Use() {
+		return nil, ErrInvalidBuffer
+	}
+	defer b.life.Done()
 
 	contents := b.Contents()
 	if contents == nil {
-		return nil
+		return nil, ErrInvalidBuffer
 	}
 
-	// Create a Go slice that references the buffer memory
+	if b.precision == PrecisionInt8 {
+		scalesAt, scalesLen, err := int8Range(offset, count, b.vectors, b.dims)
+		if err != nil {
+			return nil, err
+		}
+		codes := unsafe.Slice((*int8)(contents), b.size)[offset : offset+count]
+		scales := unsafe.Slice((*float32)(unsafe.Add(contents, scalesAt)), scalesLen/4)
+		result := make([]float32, count)
+		quant.Dequantize(result, codes, scales, b.dims)
+		return result, nil
+	}
+	if b.precision != PrecisionFP32 {
+		return b.precision.decode(unsafe.Slice((*uint16)(contents), b.size/2)[offset : offset+count]), nil
+	}
 	result := make([]float32, count)
-	src := (*[1 << 30]float32)(contents)[:count:count]
-	copy(result, src)
-	return result
+	copy(result, unsafe.Slice((*float32)(contents), b.size/4)[offset:offset+count])
+	return result, nil
 }
 
 // ReadUint32 reads uint32 values from the buffer.
@@ -338,6 +542,10 @@ func (b *Buffer) ReadUint32(count int) []uint32 {
 	if count <= 0 || uint64(count*4) > b.size {
 		return nil
 	}
+	if !b.life.Use() {
+		return nil
+	}
+	defer b.life.Done()
 
 	contents := b.Contents()
 	if contents == nil {
@@ -350,30 +558,76 @@ func (b *Buffer) ReadUint32(count int) []uint32 {
 	return result
 }
 
-// WriteFloat32 writes float32 values to the buffer.
+// WriteFloat32 writes float32 values to the buffer starting at element
+// offset. It is WriteAt with the arguments swapped.
 func (b *Buffer) WriteFloat32(data []float32, offset int) error {
+	return b.WriteAt(offset, data)
+}
+
+// WriteAt copies data into the buffer starting at element offset, so part
+// of a buffer can be updated without uploading all of it again. The buffer
+// must be CPU-visible (StorageShared or StorageManaged). Data is converted
+// to the buffer's precision; int8 data must be whole vectors, which are
+// quantized.
+func (b *Buffer) WriteAt(offset int, data []float32) error {
+	if err := checkRange(b, offset, len(data)); err != nil {
+		return err
+	}
 	if len(data) == 0 {
 		return nil
 	}
-
-	if uint64((offset+len(data))*4) > b.size {
-		return errors.New("metal: write exceeds buffer size")
+	if !b.life.Use() {
+		return ErrInvalidBuffer
 	}
+	defer b.life.Done()
 
 	contents := b.Contents()
 	if contents == nil {
 		return ErrInvalidBuffer
 	}
 
-	dst := (*[1 << 30]float32)(contents)[offset : offset+len(data) : offset+len(data)]
-	copy(dst, data)
+	if b.precision == PrecisionInt8 {
+		scalesAt, scalesLen, err := int8Range(offset, len(data), b.vectors, b.dims)
+		if err != nil {
+			return err
+		}
+		codes := unsafe.Slice((*int8)(contents), b.size)[offset : offset+len(data)]
+		scales := unsafe.Slice((*float32)(unsafe.Add(contents, scalesAt)), scalesLen/4)
+		quant.Quantize(codes, scales, data, b.dims)
+		C.metal_buffer_did_modify(b.ptr, C.ulong(offset), C.ulong(len(data)))
+		C.metal_buffer_did_modify(b.ptr, C.ulong(scalesAt), C.ulong(scalesLen))
+		return nil
+	}
+
+	elem := int(b.precision.elementSize())
+	if b.precision != PrecisionFP32 {
+		copy(unsafe.Slice((*uint16)(contents), b.size/2)[offset:offset+len(data)], b.precision.encode(data))
+	} else {
+		copy(unsafe.Slice((*float32)(contents), b.size/4)[offset:offset+len(data)], data)
+	}
 
 	// Notify Metal that buffer was modified
-	C.metal_buffer_did_modify(b.ptr, C.ulong(offset*4), C.ulong(len(data)*4))
+	C.metal_buffer_did_modify(b.ptr, C.ulong(offset*elem), C.ulong(len(data)*elem))
 
 	return nil
 }
 
+// checkRange returns ErrOutOfRange unless elements [offset, offset+count)
+// lie within b.
+func checkRange(b *Buffer, offset, count int) error {
+	if b == nil {
+		return ErrInvalidBuffer
+	}
+	elements := b.size / b.precision.elementSize()
+	if b.precision == PrecisionInt8 {
+		elements = uint64(b.vectors * b.dims)
+	}
+	if offset < 0 || count < 0 || uint64(offset+count) > elements {
+		return fmt.Errorf("%w: elements [%d, %d) of %d", ErrOutOfRange, offset, offset+count, elements)
+	}
+	return nil
+}
+
 // ComputeCosineSimilarity computes cosine similarity between query and all embeddings.
 //
 // Parameters:
@@ -390,26 +644,26 @@ func (d *Device) ComputeCosineSimilarity(
 	n, dimensions uint32,
 	normalized bool,
 ) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	result := C.metal_compute_cosine_similarity(
-		d.ptr,
-		embeddings.ptr,
-		query.ptr,
-		scores.ptr,
-		C.uint(n),
-		C.uint(dimensions),
-		C.bool(normalized),
-	)
-
-	if result != 0 {
-		errMsg := C.GoString(C.metal_last_error())
-		C.metal_clear_error()
-		return fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
+	if err := requireFP32(embeddings, query, scores); err != nil {
+		return err
 	}
-
-	return nil
+	done, err := d.use(embeddings, query, scores)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return call(ErrKernelExecution, func() bool {
+		return C.metal_compute_cosine_similarity(
+			d.ptr,
+			embeddings.ptr,
+			query.ptr,
+			scores.ptr,
+			C.uint(n),
+			C.uint(dimensions),
+			C.bool(normalized),
+		) == 0
+	})
 }
 
 // ComputeTopK finds the k highest scoring indices.
@@ -426,25 +680,45 @@ func (d *Device) ComputeTopK(
 	scores, indices, topkScores *Buffer,
 	n, k uint32,
 ) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	result := C.metal_compute_topk(
-		d.ptr,
-		scores.ptr,
-		indices.ptr,
-		topkScores.ptr,
-		C.uint(n),
-		C.uint(k),
-	)
+	return d.computeTopK(scores, indices, topkScores, nil, n, k, float32(math.Inf(-1)))
+}
 
-	if result != 0 {
-		errMsg := C.GoString(C.metal_last_error())
-		C.metal_clear_error()
-		return fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
+// computeTopK is ComputeTopK with a score threshold and an optional row
+// filter bitset. Unfilled slots keep index math.MaxUint32.
+func (d *Device) computeTopK(
+	scores, indices, topkScores, filter *Buffer,
+	n, k uint32,
+	minScore float32,
+) error {
+	if err := requireFP32(scores, topkScores); err != nil {
+		return err
+	}
+	buffers := []*Buffer{scores, indices, topkScores}
+	if filter != nil {
+		buffers = append(buffers, filter)
+	}
+	done, err := d.use(buffers...)
+	if err != nil {
+		return err
 	}
+	defer done()
 
-	return nil
+	var filterPtr C.MetalBuffer
+	if filter != nil {
+		filterPtr = filter.ptr
+	}
+	return call(ErrKernelExecution, func() bool {
+		return C.metal_compute_topk(
+			d.ptr,
+			scores.ptr,
+			indices.ptr,
+			topkScores.ptr,
+			C.uint(n),
+			C.uint(k),
+			C.float(minScore),
+			filterPtr,
+		) == 0
+	})
 }
 
 // NormalizeVectors normalizes vectors in-place to unit length.
@@ -456,30 +730,31 @@ func (d *Device) ComputeTopK(
 //
 // After normalization, cosine similarity becomes a simple dot product.
 func (d *Device) NormalizeVectors(vectors *Buffer, n, dimensions uint32) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	result := C.metal_normalize_vectors(
-		d.ptr,
-		vectors.ptr,
-		C.uint(n),
-		C.uint(dimensions),
-	)
-
-	if result != 0 {
-		errMsg := C.GoString(C.metal_last_error())
-		C.metal_clear_error()
-		return fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
+	if err := requireFP32(vectors); err != nil {
+		return err
 	}
-
-	return nil
+	done, err := d.use(vectors)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return call(ErrKernelExecution, func() bool {
+		return C.metal_normalize_vectors(
+			d.ptr,
+			vectors.ptr,
+			C.uint(n),
+			C.uint(dimensions),
+		) == 0
+	})
 }
 
 // Search performs a complete similarity search using GPU acceleration.
 //
 // This is a convenience function that:
-// 1. Computes cosine similarity for all embeddings
-// 2. Finds top-k most similar
+// 1. Computes cosine similarity (or dot product) for all embeddings
+// 2. Finds top-k most similar, dropping rows below opts.MinScore or outside
+// opts.Filter on the GPU
 // 3. Returns results
 //
 // Parameters:
@@ -488,22 +763,35 @@ func (d *Device) NormalizeVectors(vectors *Buffer, n, dimensions uint32) error {
 //   - n: Number of embeddings
 //   - dimensions: Embedding dimensions
 //   - k: Number of top results
-//   - normalized: Whether embeddings are pre-normalized
+//   - opts: Metric, threshold and filter; see SearchOptions
 //
-// Returns top-k search results sorted by similarity (descending).
+// Returns up to k search results sorted by similarity (descending).
+// Half-precision and int8 embeddings are searched as a batch of one, whose
+// kernel widens them.
 func (d *Device) Search(
 	embeddings *Buffer,
 	query []float32,
 	n, dimensions uint32,
 	k int,
-	normalized bool,
+	opts SearchOptions,
 ) ([]SearchResult, error) {
+	dot, minScore, err := opts.params(n)
+	if err != nil {
+		return nil, err
+	}
 	if k <= 0 {
 		return nil, nil
 	}
 	if k > int(n) {
 		k = int(n)
 	}
+	if embeddings != nil && embeddings.precision != PrecisionFP32 {
+		results, err := d.SearchBatch(embeddings, [][]float32{query}, n, dimensions, k, opts)
+		if err != nil {
+			return nil, err
+		}
+		return results[0], nil
+	}
 
 	// Create temporary buffers
 	queryBuf, err := d.NewBuffer(query, StorageShared)
@@ -530,31 +818,160 @@ func (d *Device) Search(
 	}
 	defer topkScoresBuf.Release()
 
-	// Compute similarities
-	if err := d.ComputeCosineSimilarity(embeddings, queryBuf, scoresBuf, n, dimensions, normalized); err != nil {
+	var filterBuf *Buffer
+	if opts.Filter != nil {
+		words := uint64((n + 63) / 64)
+		filterBuf, err = d.NewEmptyBuffer(words*8, StorageShared)
+		if err != nil {
+			return nil, err
+		}
+		defer filterBuf.Release()
+		copy(unsafe.Slice((*uint64)(filterBuf.Contents()), words), opts.Filter)
+	}
+
+	// Compute similarities; the normalized kernel is a plain dot product
+	if err := d.ComputeCosineSimilarity(embeddings, queryBuf, scoresBuf, n, dimensions, dot); err != nil {
 		return nil, err
 	}
 
 	// Find top-k
-	if err := d.ComputeTopK(scoresBuf, indicesBuf, topkScoresBuf, n, uint32(k)); err != nil {
+	if err := d.computeTopK(scoresBuf, indicesBuf, topkScoresBuf, filterBuf, n, uint32(k), minScore); err != nil {
 		return nil, err
 	}
 
 	// Read results
 	indices := indicesBuf.ReadUint32(k)
 	scores := topkScoresBuf.ReadFloat32(k)
+	if indices == nil || scores == nil {
+		return nil, ErrInvalidBuffer
+	}
 
-	results := make([]SearchResult, k)
-	for i := 0; i < k; i++ {
-		results[i] = SearchResult{
+	results := make([]SearchResult, 0, k)
+	for i := 0; i < k && indices[i] != math.MaxUint32; i++ {
+		results = append(results, SearchResult{
 			Index: indices[i],
 			Score: scores[i],
-		}
+		})
 	}
 
 	return results, nil
 }
 
+// SearchBatch searches for every query and returns up to k results for
+// each, best first, in query order; opts applies to all of them.
+//
+// One command buffer scores the whole batch in a single dispatch and
+// selects each query's top-k in a second, so a batch costs one GPU round
+// trip instead of two per query. Half-precision embeddings are widened and
+// int8 embeddings dequantized in the kernel, and scored in fp32.
+func (d *Device) SearchBatch(
+	embeddings *Buffer,
+	queries [][]float32,
+	n, dimensions uint32,
+	k int,
+	opts SearchOptions,
+) ([][]SearchResult, error) {
+	if k <= 0 || len(queries) == 0 {
+		return make([][]SearchResult, len(queries)), nil
+	}
+	if k > int(n) {
+		k = int(n)
+	}
+	packed, err := packQueries(queries, n, dimensions, opts)
+	if err != nil {
+		return nil, err
+	}
+	var scalesOffset int
+	if embeddings != nil && embeddings.precision == PrecisionInt8 {
+		if int(dimensions) != embeddings.dims {
+			return nil, fmt.Errorf("metal: int8 buffer holds vectors of %d dimensions, not %d", embeddings.dims, dimensions)
+		}
+		scalesOffset = quant.ScalesOffset(embeddings.vectors * embeddings.dims)
+	}
+	dot, minScore, _ := opts.params(n)
+	nq := uint64(len(queries))
+
+	queriesBuf, err := d.NewBuffer(packed, StorageShared)
+	if err != nil {
+		return nil, err
+	}
+	defer queriesBuf.Release()
+
+	scoresBuf, err := d.NewEmptyBuffer(nq*uint64(n)*4, StorageShared)
+	if err != nil {
+		return nil, err
+	}
+	defer scoresBuf.Release()
+
+	indicesBuf, err := d.NewEmptyBuffer(nq*uint64(k)*4, StorageShared)
+	if err != nil {
+		return nil, err
+	}
+	defer indicesBuf.Release()
+
+	topkScoresBuf, err := d.NewEmptyBuffer(nq*uint64(k)*4, StorageShared)
+	if err != nil {
+		return nil, err
+	}
+	defer topkScoresBuf.Release()
+
+	buffers := []*Buffer{embeddings, queriesBuf, scoresBuf, indicesBuf, topkScoresBuf}
+	var filterPtr C.MetalBuffer
+	if opts.Filter != nil {
+		words := uint64((n + 63) / 64)
+		filterBuf, err := d.NewEmptyBuffer(words*8, StorageShared)
+		if err != nil {
+			return nil, err
+		}
+		defer filterBuf.Release()
+		copy(unsafe.Slice((*uint64)(filterBuf.Contents()), words), opts.Filter)
+		buffers = append(buffers, filterBuf)
+		filterPtr = filterBuf.ptr
+	}
+
+	done, err := d.use(buffers...)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	err = call(ErrKernelExecution, func() bool {
+		return C.metal_search_batch(
+			d.ptr,
+			embeddings.ptr,
+			queriesBuf.ptr,
+			scoresBuf.ptr,
+			indicesBuf.ptr,
+			topkScoresBuf.ptr,
+			filterPtr,
+			C.uint(nq),
+			C.uint(n),
+			C.uint(dimensions),
+			C.uint(k),
+			C.float(minScore),
+			C.bool(dot),
+			C.int(embeddings.precision),
+			C.ulong(scalesOffset),
+		) == 0
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	indices := indicesBuf.ReadUint32(int(nq) * k)
+	scores := topkScoresBuf.ReadFloat32(int(nq) * k)
+	if indices == nil || scores == nil {
+		return nil, ErrInvalidBuffer
+	}
+	found := make([]int32, nq)
+	for i := range found {
+		for int(found[i]) < k && indices[i*k+int(found[i])] != math.MaxUint32 {
+			found[i]++
+		}
+	}
+	return unpackBatch(indices, scores, found, k), nil
+}
+
 // =============================================================================
 // Memory Tracking
 // =============================================================================
@@ -686,28 +1103,28 @@ func MPSIsSupported() bool {
 // This uses Metal Performance Shaders which are highly optimized
 // for Apple Silicon's unified memory architecture.
 func (d *Device) MPSMatrixMultiply(a, b, c *Buffer, m, n, k uint32, alpha, beta float32) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	result := C.metal_mps_matrix_multiply(
-		d.ptr,
-		a.ptr,
-		b.ptr,
-		c.ptr,
-		C.uint(m),
-		C.uint(n),
-		C.uint(k),
-		C.float(alpha),
-		C.float(beta),
-	)
-
-	if result != 0 {
-		errMsg := C.GoString(C.metal_last_error())
-		C.metal_clear_error()
-		return fmt.Errorf("MPS matrix multiply failed: %s", errMsg)
+	if err := requireFP32(a, b, c); err != nil {
+		return err
 	}
-
-	return nil
+	done, err := d.use(a, b, c)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return call(ErrKernelExecution, func() bool {
+		return C.metal_mps_matrix_multiply(
+			d.ptr,
+			a.ptr,
+			b.ptr,
+			c.ptr,
+			C.uint(m),
+			C.uint(n),
+			C.uint(k),
+			C.float(alpha),
+			C.float(beta),
+		) == 0
+	})
 }
 
 // MPSMatrixVectorMultiply performs GPU-accelerated matrix-vector multiplication.
@@ -720,27 +1137,27 @@ func (d *Device) MPSMatrixMultiply(a, b, c *Buffer, m, n, k uint32, alpha, beta
 //   - m, n: Matrix dimensions
 //   - alpha, beta: Scaling factors
 func (d *Device) MPSMatrixVectorMultiply(a, x, y *Buffer, m, n uint32, alpha, beta float32) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	result := C.metal_mps_matrix_vector_multiply(
-		d.ptr,
-		a.ptr,
-		x.ptr,
-		y.ptr,
-		C.uint(m),
-		C.uint(n),
-		C.float(alpha),
-		C.float(beta),
-	)
-
-	if result != 0 {
-		errMsg := C.GoString(C.metal_last_error())
-		C.metal_clear_error()
-		return fmt.Errorf("MPS matrix-vector multiply failed: %s", errMsg)
+	if err := requireFP32(a, x, y); err != nil {
+		return err
 	}
-
-	return nil
+	done, err := d.use(a, x, y)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return call(ErrKernelExecution, func() bool {
+		return C.metal_mps_matrix_vector_multiply(
+			d.ptr,
+			a.ptr,
+			x.ptr,
+			y.ptr,
+			C.uint(m),
+			C.uint(n),
+			C.float(alpha),
+			C.float(beta),
+		) == 0
+	})
 }
 
 // MPSBatchCosineSimilarity computes cosine similarities using MPS.
@@ -755,23 +1172,23 @@ func (d *Device) MPSMatrixVectorMultiply(a, x, y *Buffer, m, n uint32, alpha, be
 //
 // Note: Assumes embeddings are pre-normalized for cosine similarity.
 func (d *Device) MPSBatchCosineSimilarity(embeddings, query, scores *Buffer, n, dims uint32) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	result := C.metal_mps_batch_cosine_similarity(
-		d.ptr,
-		embeddings.ptr,
-		query.ptr,
-		scores.ptr,
-		C.uint(n),
-		C.uint(dims),
-	)
-
-	if result != 0 {
-		errMsg := C.GoString(C.metal_last_error())
-		C.metal_clear_error()
-		return fmt.Errorf("MPS batch cosine similarity failed: %s", errMsg)
+	if err := requireFP32(embeddings, query, scores); err != nil {
+		return err
 	}
-
-	return nil
+	done, err := d.use(embeddings, query, scores)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return call(ErrKernelExecution, func() bool {
+		return C.metal_mps_batch_cosine_similarity(
+			d.ptr,
+			embeddings.ptr,
+			query.ptr,
+			scores.ptr,
+			C.uint(n),
+			C.uint(dims),
+		) == 0
+	})
 }
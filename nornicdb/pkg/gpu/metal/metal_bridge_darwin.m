@@ -18,10 +18,18 @@
 #include <mach/mach.h>
 #include <sys/sysctl.h>
 
-// Global error message storage
-static char g_error_message[1024] = {0};
+// Error message storage. g_error_code holds the MTLCommandBufferError code
+// of a failed command buffer, classified on the Go side (errors.go). Both
+// are per thread so concurrent operations don't overwrite each other's
+// errors.
+static __thread char g_error_message[1024] = {0};
+static __thread long g_error_code = 0;
 
 static void set_error(NSError* error, const char* context) {
+    g_error_code = 0;
+    if (error && [error.domain isEqualToString:MTLCommandBufferErrorDomain]) {
+        g_error_code = (long)error.code;
+    }
     if (error) {
         snprintf(g_error_message, sizeof(g_error_message), "%s: %s",
                  context, [[error localizedDescription] UTF8String]);
@@ -43,6 +51,11 @@ typedef struct {
     id<MTLComputePipelineState> topkSimple;
     id<MTLComputePipelineState> topkSelect;
     id<MTLComputePipelineState> normalize;
+    id<MTLComputePipelineState> cosineBatch;
+    id<MTLComputePipelineState> cosineBatchF16;
+    id<MTLComputePipelineState> cosineBatchBF16;
+    id<MTLComputePipelineState> cosineBatchInt8;
+    id<MTLComputePipelineState> topkBatch;
 } MetalContext;
 
 void* metal_create_device(void) {
@@ -142,6 +155,9 @@ void* metal_create_device(void) {
                     device float* topk_scores [[buffer(2)]],
                     constant uint& n [[buffer(3)]],
                     constant uint& k [[buffer(4)]],
+                    constant float& min_score [[buffer(5)]],
+                    device const ulong* filter [[buffer(6)]],
+                    constant uint& has_filter [[buffer(7)]],
                     uint gid [[thread_position_in_grid]])
                 {
                     if (gid != 0) return;
@@ -153,6 +169,8 @@ void* metal_create_device(void) {
                     
                     for (uint i = 0; i < n; i++) {
                         float score = scores[i];
+                        if (score < min_score) continue;
+                        if (has_filter && !(filter[i / 64] & (1ul << (i % 64)))) continue;
                         
                         if (score > topk_scores[k-1]) {
                             uint pos = k - 1;
@@ -167,6 +185,188 @@ void* metal_create_device(void) {
                     }
                 }
                 
+                kernel void cosine_similarity_batch(
+                    device const float* embeddings [[buffer(0)]],
+                    device const float* queries [[buffer(1)]],
+                    device float* scores [[buffer(2)]],
+                    constant uint& n [[buffer(3)]],
+                    constant uint& dimensions [[buffer(4)]],
+                    constant uint& normalized [[buffer(5)]],
+                    uint2 gid [[thread_position_in_grid]])
+                {
+                    if (gid.x >= n) return;
+                    
+                    float dot = 0.0f;
+                    float normA = 0.0f;
+                    float normB = 0.0f;
+                    
+                    uint base = gid.x * dimensions;
+                    uint qbase = gid.y * dimensions;
+                    
+                    for (uint i = 0; i < dimensions; i++) {
+                        float a = embeddings[base + i];
+                        float b = queries[qbase + i];
+                        dot += a * b;
+                        normA += a * a;
+                        normB += b * b;
+                    }
+                    
+                    float score = dot;
+                    if (!normalized) {
+                        score = (normA == 0.0f || normB == 0.0f) ? 0.0f : dot / (sqrt(normA) * sqrt(normB));
+                    }
+                    scores[gid.y * n + gid.x] = score;
+                }
+                
+                // cosine_similarity_batch over FP16 embeddings, widened to
+                // float as they are read; accumulation stays in float.
+                kernel void cosine_similarity_batch_f16(
+                    device const half* embeddings [[buffer(0)]],
+                    device const float* queries [[buffer(1)]],
+                    device float* scores [[buffer(2)]],
+                    constant uint& n [[buffer(3)]],
+                    constant uint& dimensions [[buffer(4)]],
+                    constant uint& normalized [[buffer(5)]],
+                    uint2 gid [[thread_position_in_grid]])
+                {
+                    if (gid.x >= n) return;
+                    
+                    float dot = 0.0f;
+                    float normA = 0.0f;
+                    float normB = 0.0f;
+                    
+                    uint base = gid.x * dimensions;
+                    uint qbase = gid.y * dimensions;
+                    
+                    for (uint i = 0; i < dimensions; i++) {
+                        float a = float(embeddings[base + i]);
+                        float b = queries[qbase + i];
+                        dot += a * b;
+                        normA += a * a;
+                        normB += b * b;
+                    }
+                    
+                    float score = dot;
+                    if (!normalized) {
+                        score = (normA == 0.0f || normB == 0.0f) ? 0.0f : dot / (sqrt(normA) * sqrt(normB));
+                    }
+                    scores[gid.y * n + gid.x] = score;
+                }
+                
+                // cosine_similarity_batch over BF16 embeddings: each is the
+                // upper half of a float, widened by a shift.
+                kernel void cosine_similarity_batch_bf16(
+                    device const ushort* embeddings [[buffer(0)]],
+                    device const float* queries [[buffer(1)]],
+                    device float* scores [[buffer(2)]],
+                    constant uint& n [[buffer(3)]],
+                    constant uint& dimensions [[buffer(4)]],
+                    constant uint& normalized [[buffer(5)]],
+                    uint2 gid [[thread_position_in_grid]])
+                {
+                    if (gid.x >= n) return;
+                    
+                    float dot = 0.0f;
+                    float normA = 0.0f;
+                    float normB = 0.0f;
+                    
+                    uint base = gid.x * dimensions;
+                    uint qbase = gid.y * dimensions;
+                    
+                    for (uint i = 0; i < dimensions; i++) {
+                        float a = as_type<float>(uint(embeddings[base + i]) << 16);
+                        float b = queries[qbase + i];
+                        dot += a * b;
+                        normA += a * a;
+                        normB += b * b;
+                    }
+                    
+                    float score = dot;
+                    if (!normalized) {
+                        score = (normA == 0.0f || normB == 0.0f) ? 0.0f : dot / (sqrt(normA) * sqrt(normB));
+                    }
+                    scores[gid.y * n + gid.x] = score;
+                }
+                
+                // cosine_similarity_batch over int8 codes (see package quant), each
+                // row with a float scale in scales. The scale cancels out of cosine
+                // similarity, so only dot products are multiplied by it.
+                kernel void cosine_similarity_batch_int8(
+                    device const char* embeddings [[buffer(0)]],
+                    device const float* queries [[buffer(1)]],
+                    device float* scores [[buffer(2)]],
+                    constant uint& n [[buffer(3)]],
+                    constant uint& dimensions [[buffer(4)]],
+                    constant uint& normalized [[buffer(5)]],
+                    device const float* scales [[buffer(6)]],
+                    uint2 gid [[thread_position_in_grid]])
+                {
+                    if (gid.x >= n) return;
+                    
+                    float dot = 0.0f;
+                    float normA = 0.0f;
+                    float normB = 0.0f;
+                    
+                    uint base = gid.x * dimensions;
+                    uint qbase = gid.y * dimensions;
+                    
+                    for (uint i = 0; i < dimensions; i++) {
+                        float a = float(embeddings[base + i]);
+                        float b = queries[qbase + i];
+                        dot += a * b;
+                        normA += a * a;
+                        normB += b * b;
+                    }
+                    
+                    float score = dot * scales[gid.x];
+                    if (!normalized) {
+                        score = (normA == 0.0f || normB == 0.0f) ? 0.0f : dot / (sqrt(normA) * sqrt(normB));
+                    }
+                    scores[gid.y * n + gid.x] = score;
+                }
+
+                kernel void topk_batch(
+                    device const float* scores [[buffer(0)]],
+                    device uint* topk_indices [[buffer(1)]],
+                    device float* topk_scores [[buffer(2)]],
+                    constant uint& n [[buffer(3)]],
+                    constant uint& k [[buffer(4)]],
+                    constant float& min_score [[buffer(5)]],
+                    device const ulong* filter [[buffer(6)]],
+                    constant uint& has_filter [[buffer(7)]],
+                    constant uint& nq [[buffer(8)]],
+                    uint gid [[thread_position_in_grid]])
+                {
+                    if (gid >= nq) return;
+                    
+                    device const float* row = scores + gid * n;
+                    device uint* out_indices = topk_indices + gid * k;
+                    device float* out_scores = topk_scores + gid * k;
+                    
+                    // Same selection as topk_simple, over this query's row
+                    for (uint i = 0; i < k; i++) {
+                        out_scores[i] = -2.0f;
+                        out_indices[i] = UINT_MAX;
+                    }
+                    
+                    for (uint i = 0; i < n; i++) {
+                        float score = row[i];
+                        if (score < min_score) continue;
+                        if (has_filter && !(filter[i / 64] & (1ul << (i % 64)))) continue;
+        
+                        if (score > out_scores[k-1]) {
+                            uint pos = k - 1;
+                            while (pos > 0 && score > out_scores[pos-1]) {
+                                out_scores[pos] = out_scores[pos-1];
+                                out_indices[pos] = out_indices[pos-1];
+                                pos--;
+                            }
+                            out_scores[pos] = score;
+                            out_indices[pos] = i;
+                        }
+                    }
+                }
+                
                 kernel void normalize_vectors(
                     device float* vectors [[buffer(0)]],
                     constant uint& n [[buffer(1)]],
@@ -248,6 +448,53 @@ void* metal_create_device(void) {
             }
         }
         
+        // Batched search
+        func = [ctx->library newFunctionWithName:@"cosine_similarity_batch"];
+        if (func) {
+            ctx->cosineBatch = [device newComputePipelineStateWithFunction:func error:&error];
+            if (!ctx->cosineBatch) {
+                set_error(error, "Failed to create cosine_batch pipeline");
+                free(ctx);
+                return NULL;
+            }
+        }
+        func = [ctx->library newFunctionWithName:@"cosine_similarity_batch_f16"];
+        if (func) {
+            ctx->cosineBatchF16 = [device newComputePipelineStateWithFunction:func error:&error];
+            if (!ctx->cosineBatchF16) {
+                set_error(error, "Failed to create cosine_batch_f16 pipeline");
+                free(ctx);
+                return NULL;
+            }
+        }
+        func = [ctx->library newFunctionWithName:@"cosine_similarity_batch_bf16"];
+        if (func) {
+            ctx->cosineBatchBF16 = [device newComputePipelineStateWithFunction:func error:&error];
+            if (!ctx->cosineBatchBF16) {
+                set_error(error, "Failed to create cosine_batch_bf16 pipeline");
+                free(ctx);
+                return NULL;
+            }
+        }
+        func = [ctx->library newFunctionWithName:@"cosine_similarity_batch_int8"];
+        if (func) {
+            ctx->cosineBatchInt8 = [device newComputePipelineStateWithFunction:func error:&error];
+            if (!ctx->cosineBatchInt8) {
+                set_error(error, "Failed to create cosine_batch_int8 pipeline");
+                free(ctx);
+                return NULL;
+            }
+        }
+        func = [ctx->library newFunctionWithName:@"topk_batch"];
+        if (func) {
+            ctx->topkBatch = [device newComputePipelineStateWithFunction:func error:&error];
+            if (!ctx->topkBatch) {
+                set_error(error, "Failed to create topk_batch pipeline");
+                free(ctx);
+                return NULL;
+            }
+        }
+        
         return ctx;
     }
 }
@@ -265,6 +512,11 @@ void metal_release_device(void* device) {
         ctx->topkSimple = nil;
         ctx->topkSelect = nil;
         ctx->normalize = nil;
+        ctx->cosineBatch = nil;
+        ctx->cosineBatchF16 = nil;
+        ctx->cosineBatchBF16 = nil;
+        ctx->cosineBatchInt8 = nil;
+        ctx->topkBatch = nil;
         free(ctx);
     }
 }
@@ -480,7 +732,9 @@ int metal_compute_topk(
     void* indices_buf,
     void* topk_scores_buf,
     unsigned int n,
-    unsigned int k)
+    unsigned int k,
+    float min_score,
+    void* filter_buf)
 {
     if (!device || !scores_buf || !indices_buf || !topk_scores_buf) {
         set_error(nil, "Invalid parameters");
@@ -517,6 +771,13 @@ int metal_compute_topk(
         [encoder setBuffer:topkScores offset:0 atIndex:2];
         [encoder setBytes:&n length:sizeof(n) atIndex:3];
         [encoder setBytes:&k length:sizeof(k) atIndex:4];
+        [encoder setBytes:&min_score length:sizeof(min_score) atIndex:5];
+        // Without a filter the kernel never reads buffer 6; bind the scores
+        // so the slot is not empty.
+        unsigned int has_filter = filter_buf ? 1 : 0;
+        id<MTLBuffer> filter = filter_buf ? (__bridge id<MTLBuffer>)filter_buf : scores;
+        [encoder setBuffer:filter offset:0 atIndex:6];
+        [encoder setBytes:&has_filter length:sizeof(has_filter) atIndex:7];
         
         // topk_simple runs on single thread
         MTLSize gridSize = MTLSizeMake(1, 1, 1);
@@ -594,6 +855,109 @@ int metal_normalize_vectors(
     }
 }
 
+// Batched search: scores nq queries against all embeddings, then selects
+// the top k of each, in one command buffer. scores holds nq × n floats and
+// indices and topk_scores nq × k each; unfilled slots keep index UINT_MAX.
+// precision selects the embeddings' element type: 0 float, 1 FP16, 2 BF16,
+// 3 int8 codes whose row scales start scales_offset bytes into embeddings.
+int metal_search_batch(
+    void* device,
+    void* embeddings_buf,
+    void* queries_buf,
+    void* scores_buf,
+    void* indices_buf,
+    void* topk_scores_buf,
+    void* filter_buf,
+    unsigned int nq,
+    unsigned int n,
+    unsigned int dimensions,
+    unsigned int k,
+    float min_score,
+    bool normalized,
+    int precision,
+    unsigned long scales_offset)
+{
+    if (!device || !embeddings_buf || !queries_buf || !scores_buf || !indices_buf || !topk_scores_buf) {
+        set_error(nil, "Invalid parameters");
+        return -1;
+    }
+    
+    @autoreleasepool {
+        MetalContext* ctx = (MetalContext*)device;
+        id<MTLBuffer> embeddings = (__bridge id<MTLBuffer>)embeddings_buf;
+        id<MTLBuffer> queries = (__bridge id<MTLBuffer>)queries_buf;
+        id<MTLBuffer> scores = (__bridge id<MTLBuffer>)scores_buf;
+        id<MTLBuffer> indices = (__bridge id<MTLBuffer>)indices_buf;
+        id<MTLBuffer> topkScores = (__bridge id<MTLBuffer>)topk_scores_buf;
+        
+        id<MTLComputePipelineState> cosine = ctx->cosineBatch;
+        if (precision == 1) cosine = ctx->cosineBatchF16;
+        if (precision == 2) cosine = ctx->cosineBatchBF16;
+        if (precision == 3) cosine = ctx->cosineBatchInt8;
+        if (!cosine || !ctx->topkBatch) {
+            set_error(nil, "Batch pipelines not initialized");
+            return -1;
+        }
+        
+        id<MTLCommandBuffer> commandBuffer = [ctx->commandQueue commandBuffer];
+        if (!commandBuffer) {
+            set_error(nil, "Failed to create command buffer");
+            return -1;
+        }
+        
+        id<MTLComputeCommandEncoder> encoder = [commandBuffer computeCommandEncoder];
+        if (!encoder) {
+            set_error(nil, "Failed to create command encoder");
+            return -1;
+        }
+        
+        // Scores: one thread per (embedding, query) pair
+        unsigned int normalizedInt = normalized ? 1 : 0;
+        [encoder setComputePipelineState:cosine];
+        [encoder setBuffer:embeddings offset:0 atIndex:0];
+        [encoder setBuffer:queries offset:0 atIndex:1];
+        [encoder setBuffer:scores offset:0 atIndex:2];
+        [encoder setBytes:&n length:sizeof(n) atIndex:3];
+        [encoder setBytes:&dimensions length:sizeof(dimensions) atIndex:4];
+        [encoder setBytes:&normalizedInt length:sizeof(normalizedInt) atIndex:5];
+        if (precision == 3) {
+            [encoder setBuffer:embeddings offset:scales_offset atIndex:6];
+        }
+        
+        NSUInteger threadGroupSize = MIN(cosine.maxTotalThreadsPerThreadgroup, 256);
+        [encoder dispatchThreads:MTLSizeMake(n, nq, 1) threadsPerThreadgroup:MTLSizeMake(threadGroupSize, 1, 1)];
+        
+        // Top-k: one thread per query. The encoder dispatches serially, so
+        // this sees all the scores.
+        unsigned int has_filter = filter_buf ? 1 : 0;
+        id<MTLBuffer> filter = filter_buf ? (__bridge id<MTLBuffer>)filter_buf : scores;
+        [encoder setComputePipelineState:ctx->topkBatch];
+        [encoder setBuffer:scores offset:0 atIndex:0];
+        [encoder setBuffer:indices offset:0 atIndex:1];
+        [encoder setBuffer:topkScores offset:0 atIndex:2];
+        [encoder setBytes:&n length:sizeof(n) atIndex:3];
+        [encoder setBytes:&k length:sizeof(k) atIndex:4];
+        [encoder setBytes:&min_score length:sizeof(min_score) atIndex:5];
+        [encoder setBuffer:filter offset:0 atIndex:6];
+        [encoder setBytes:&has_filter length:sizeof(has_filter) atIndex:7];
+        [encoder setBytes:&nq length:sizeof(nq) atIndex:8];
+        
+        threadGroupSize = MIN(ctx->topkBatch.maxTotalThreadsPerThreadgroup, 256);
+        [encoder dispatchThreads:MTLSizeMake(nq, 1, 1) threadsPerThreadgroup:MTLSizeMake(MIN(threadGroupSize, nq), 1, 1)];
+        [encoder endEncoding];
+        
+        [commandBuffer commit];
+        [commandBuffer waitUntilCompleted];
+        
+        if (commandBuffer.error) {
+            set_error(commandBuffer.error, "Batch search kernel failed");
+            return -1;
+        }
+        
+        return 0;
+    }
+}
+
 // =============================================================================
 // Error Handling
 // =============================================================================
@@ -602,8 +966,13 @@ const char* metal_last_error(void) {
     return g_error_message;
 }
 
+long metal_last_error_code(void) {
+    return g_error_code;
+}
+
 void metal_clear_error(void) {
     g_error_message[0] = '\0';
+    g_error_code = 0;
 }
 
 // =============================================================================
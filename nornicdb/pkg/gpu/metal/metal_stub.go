@@ -5,15 +5,22 @@
 // This file provides stubs for non-Darwin systems.
 package metal
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+)
 
 // Errors
 var (
-	ErrMetalNotAvailable = errors.New("metal: Metal is only available on macOS")
-	ErrDeviceCreation    = errors.New("metal: failed to create Metal device")
-	ErrBufferCreation    = errors.New("metal: failed to create buffer")
-	ErrKernelExecution   = errors.New("metal: kernel execution failed")
+	ErrMetalNotAvailable = gpuerr.Sentinel("metal: Metal is only available on macOS", gpuerr.ErrNotAvailable)
+	ErrDeviceCreation    = gpuerr.Sentinel("metal: failed to create Metal device", gpuerr.ErrNotAvailable)
+	ErrBufferCreation    = gpuerr.Sentinel("metal: failed to create buffer", gpuerr.ErrOutOfMemory)
+	ErrKernelExecution   = gpuerr.Sentinel("metal: kernel execution failed", gpuerr.ErrKernel)
 	ErrInvalidBuffer     = errors.New("metal: invalid buffer")
+	ErrDeviceReleased    = errors.New("metal: device released")
+	ErrOutOfRange        = errors.New("metal: range exceeds buffer size")
+	ErrPrecision         = errors.New("metal: operation does not support this buffer precision")
 )
 
 // StorageMode defines how buffer memory is managed.
@@ -37,11 +44,19 @@ type SearchResult struct {
 	Score float32
 }
 
+// Compiled reports whether this binary was built with Metal support.
+const Compiled = false
+
 // IsAvailable checks if Metal is available (always false on non-Darwin).
 func IsAvailable() bool {
 	return false
 }
 
+// UnavailableReason explains why Metal cannot be used by this binary.
+func UnavailableReason() string {
+	return "Metal is only available on macOS"
+}
+
 // NewDevice creates a new Metal device (not available on non-Darwin).
 func NewDevice() (*Device, error) {
 	return nil, ErrMetalNotAvailable
@@ -64,6 +79,16 @@ func (d *Device) NewBuffer(data []float32, mode StorageMode) (*Buffer, error) {
 	return nil, ErrMetalNotAvailable
 }
 
+// NewBufferWithPrecision creates a new GPU buffer stored at precision.
+func (d *Device) NewBufferWithPrecision(data []float32, mode StorageMode, precision Precision) (*Buffer, error) {
+	return nil, ErrMetalNotAvailable
+}
+
+// NewQuantizedBuffer creates a GPU buffer of int8-quantized vectors.
+func (d *Device) NewQuantizedBuffer(data []float32, dimensions uint32, mode StorageMode) (*Buffer, error) {
+	return nil, ErrMetalNotAvailable
+}
+
 // NewBufferNoCopy creates a GPU buffer that shares memory.
 func (d *Device) NewBufferNoCopy(data []float32, mode StorageMode) (*Buffer, error) {
 	return nil, ErrMetalNotAvailable
@@ -80,6 +105,22 @@ func (b *Buffer) Release() {}
 // Size returns the buffer size in bytes.
 func (b *Buffer) Size() uint64 { return 0 }
 
+// Precision returns how the buffer stores its elements.
+func (b *Buffer) Precision() Precision { return PrecisionFP32 }
+
+// ReadFloat32 reads float32 values from the buffer (stub).
+func (b *Buffer) ReadFloat32(count int) []float32 { return nil }
+
+// ReadAt reads float32 values at an element offset (stub).
+func (b *Buffer) ReadAt(offset, count int) ([]float32, error) {
+	return nil, ErrMetalNotAvailable
+}
+
+// WriteAt writes float32 values at an element offset (stub).
+func (b *Buffer) WriteAt(offset int, data []float32) error {
+	return ErrMetalNotAvailable
+}
+
 // ComputeCosineSimilarity computes cosine similarity (stub).
 func (d *Device) ComputeCosineSimilarity(embeddings, query, scores *Buffer, n, dimensions uint32, normalized bool) error {
 	return ErrMetalNotAvailable
@@ -96,7 +137,12 @@ func (d *Device) NormalizeVectors(vectors *Buffer, n, dimensions uint32) error {
 }
 
 // Search performs a complete similarity search (stub).
-func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, normalized bool) ([]SearchResult, error) {
+func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, opts SearchOptions) ([]SearchResult, error) {
+	return nil, ErrMetalNotAvailable
+}
+
+// SearchBatch performs several similarity searches at once (stub).
+func (d *Device) SearchBatch(embeddings *Buffer, queries [][]float32, n, dimensions uint32, k int, opts SearchOptions) ([][]SearchResult, error) {
 	return nil, ErrMetalNotAvailable
 }
 
@@ -4,6 +4,9 @@
 package metal
 
 import (
+	"errors"
+	"math"
+	"sync"
 	"testing"
 )
 
@@ -324,6 +327,50 @@ func TestBufferWriteFloat32(t *testing.T) {
 	})
 }
 
+func TestBufferReadWriteAt(t *testing.T) {
+	if !IsAvailable() {
+		t.Skip("Metal not available")
+	}
+
+	device, err := NewDevice()
+	if err != nil {
+		t.Fatalf("NewDevice() error = %v", err)
+	}
+	defer device.Release()
+
+	buf, err := device.NewBuffer(make([]float32, 8), StorageShared)
+	if err != nil {
+		t.Fatalf("NewBuffer() error = %v", err)
+	}
+	defer buf.Release()
+
+	if err := buf.WriteAt(3, []float32{1, 2, 3}); err != nil {
+		t.Fatalf("WriteAt() error = %v", err)
+	}
+	got, err := buf.ReadAt(2, 5)
+	if err != nil {
+		t.Fatalf("ReadAt() error = %v", err)
+	}
+	want := []float32{0, 1, 2, 3, 0}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ReadAt(2, 5)[%d] = %f, expected %f", i, got[i], want[i])
+		}
+	}
+
+	if err := buf.WriteAt(6, []float32{1, 2, 3}); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("WriteAt() past the end: got %v, expected ErrOutOfRange", err)
+	}
+	if _, err := buf.ReadAt(-1, 2); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("ReadAt() negative offset: got %v, expected ErrOutOfRange", err)
+	}
+
+	buf.Release()
+	if _, err := buf.ReadAt(0, 1); !errors.Is(err, ErrInvalidBuffer) {
+		t.Errorf("ReadAt() on released buffer: got %v, expected ErrInvalidBuffer", err)
+	}
+}
+
 func TestComputeCosineSimilarity(t *testing.T) {
 	if !IsAvailable() {
 		t.Skip("Metal not available")
@@ -539,7 +586,7 @@ func TestSearch(t *testing.T) {
 		embBuf, _ := device.NewBuffer(embeddings, StorageShared)
 		defer embBuf.Release()
 
-		results, err := device.Search(embBuf, query, 5, 4, 3, false)
+		results, err := device.Search(embBuf, query, 5, 4, 3, SearchOptions{})
 		if err != nil {
 			t.Fatalf("Search() error = %v", err)
 		}
@@ -571,7 +618,7 @@ func TestSearch(t *testing.T) {
 		embBuf, _ := device.NewBuffer(embeddings, StorageShared)
 		defer embBuf.Release()
 
-		results, err := device.Search(embBuf, query, 1, 4, 0, false)
+		results, err := device.Search(embBuf, query, 1, 4, 0, SearchOptions{})
 		if err != nil {
 			t.Fatalf("Search(k=0) error = %v", err)
 		}
@@ -590,7 +637,7 @@ func TestSearch(t *testing.T) {
 		embBuf, _ := device.NewBuffer(embeddings, StorageShared)
 		defer embBuf.Release()
 
-		results, err := device.Search(embBuf, query, 2, 4, 10, false) // k=10 > n=2
+		results, err := device.Search(embBuf, query, 2, 4, 10, SearchOptions{}) // k=10 > n=2
 		if err != nil {
 			t.Fatalf("Search(k>n) error = %v", err)
 		}
@@ -600,6 +647,290 @@ func TestSearch(t *testing.T) {
 	})
 }
 
+func TestSearchBatch(t *testing.T) {
+	if !IsAvailable() {
+		t.Skip("Metal not available")
+	}
+
+	device, err := NewDevice()
+	if err != nil {
+		t.Fatalf("NewDevice failed: %v", err)
+	}
+	defer device.Release()
+
+	embeddings := []float32{
+		1.0, 0.0, 0.0,
+		0.0, 1.0, 0.0,
+		0.0, 0.0, 1.0,
+		0.6, 0.8, 0.0,
+		0.7, 0.7, 0.14,
+	}
+	embBuf, err := device.NewBuffer(embeddings, StorageShared)
+	if err != nil {
+		t.Fatalf("NewBuffer failed: %v", err)
+	}
+	defer embBuf.Release()
+
+	queries := [][]float32{
+		{0.6, 0.8, 0.0},
+		{0.0, 0.0, 2.0},
+		{1.0, 1.0, 0.0},
+	}
+	for _, opts := range []SearchOptions{
+		{Normalized: true},
+		{},
+		{Metric: MetricDot},
+		{MinScore: 0.5, HasMinScore: true, Filter: NewFilter(5, 0, 3, 4)},
+	} {
+		batch, err := device.SearchBatch(embBuf, queries, 5, 3, 2, opts)
+		if err != nil {
+			t.Fatalf("SearchBatch(%+v) failed: %v", opts, err)
+		}
+		if len(batch) != len(queries) {
+			t.Fatalf("SearchBatch(%+v) returned %d result sets, want %d", opts, len(batch), len(queries))
+		}
+		// Each query's results match a Search for it alone
+		for i, query := range queries {
+			want, err := device.Search(embBuf, query, 5, 3, 2, opts)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(batch[i]) != len(want) {
+				t.Errorf("SearchBatch(%+v)[%d] = %v, want %v", opts, i, batch[i], want)
+				continue
+			}
+			for j := range want {
+				if batch[i][j].Index != want[j].Index || math.Abs(float64(batch[i][j].Score-want[j].Score)) > 0.001 {
+					t.Errorf("SearchBatch(%+v)[%d] = %v, want %v", opts, i, batch[i], want)
+					break
+				}
+			}
+		}
+	}
+
+	if batch, err := device.SearchBatch(embBuf, queries, 5, 3, 0, SearchOptions{}); err != nil || len(batch) != len(queries) || batch[0] != nil {
+		t.Errorf("SearchBatch(k=0) = %v, %v; want an empty result per query", batch, err)
+	}
+	if _, err := device.SearchBatch(embBuf, [][]float32{{1.0, 0.0}}, 5, 3, 2, SearchOptions{}); err == nil {
+		t.Error("SearchBatch with a short query should fail")
+	}
+}
+
+func TestSearchHalfPrecision(t *testing.T) {
+	if !IsAvailable() {
+		t.Skip("Metal not available")
+	}
+
+	device, err := NewDevice()
+	if err != nil {
+		t.Fatalf("NewDevice failed: %v", err)
+	}
+	defer device.Release()
+
+	embeddings := []float32{
+		1.0, 0.0, 0.0,
+		0.0, 1.0, 0.0,
+		0.0, 0.0, 1.0,
+		0.6, 0.8, 0.0,
+		0.7, 0.7, 0.14,
+	}
+	queries := [][]float32{
+		{0.6, 0.8, 0.0},
+		{0.0, 0.0, 2.0},
+	}
+	fp32, err := device.NewBuffer(embeddings, StorageShared)
+	if err != nil {
+		t.Fatalf("NewBuffer failed: %v", err)
+	}
+	defer fp32.Release()
+
+	for _, precision := range []Precision{PrecisionFP16, PrecisionBF16} {
+		buf, err := device.NewBufferWithPrecision(embeddings, StorageShared, precision)
+		if err != nil {
+			t.Fatalf("NewBufferWithPrecision(%s) failed: %v", precision, err)
+		}
+		if buf.Precision() != precision || buf.Size() != uint64(len(embeddings)*2) {
+			t.Errorf("%s buffer: precision %s, %d bytes", precision, buf.Precision(), buf.Size())
+		}
+		if got, err := buf.ReadAt(3, 2); err != nil || math.Abs(float64(got[0]-0.6)) > 0.01 || math.Abs(float64(got[1]-0.8)) > 0.01 {
+			t.Errorf("%s ReadAt = %v, %v; want about [0.6 0.8]", precision, got, err)
+		}
+
+		// Scores match the fp32 buffer's to within the storage rounding
+		for _, opts := range []SearchOptions{{Normalized: true}, {}} {
+			want, err := device.SearchBatch(fp32, queries, 5, 3, 2, opts)
+			if err != nil {
+				t.Fatalf("SearchBatch failed: %v", err)
+			}
+			got, err := device.SearchBatch(buf, queries, 5, 3, 2, opts)
+			if err != nil {
+				t.Fatalf("%s SearchBatch(%+v) failed: %v", precision, opts, err)
+			}
+			single, err := device.Search(buf, queries[0], 5, 3, 2, opts)
+			if err != nil {
+				t.Fatalf("%s Search(%+v) failed: %v", precision, opts, err)
+			}
+			for i := range want {
+				for j := range want[i] {
+					if got[i][j].Index != want[i][j].Index || math.Abs(float64(got[i][j].Score-want[i][j].Score)) > 0.01 {
+						t.Errorf("%s SearchBatch(%+v)[%d] = %v, want %v", precision, opts, i, got[i], want[i])
+						break
+					}
+				}
+			}
+			if len(single) != len(got[0]) || single[0] != got[0][0] {
+				t.Errorf("%s Search(%+v) = %v, want %v", precision, opts, single, got[0])
+			}
+		}
+
+		if err := device.NormalizeVectors(buf, 5, 3); !errors.Is(err, ErrPrecision) {
+			t.Errorf("%s NormalizeVectors error = %v, want ErrPrecision", precision, err)
+		}
+		buf.Release()
+	}
+}
+
+func TestSearchInt8(t *testing.T) {
+	if !IsAvailable() {
+		t.Skip("Metal not available")
+	}
+
+	device, err := NewDevice()
+	if err != nil {
+		t.Fatalf("NewDevice failed: %v", err)
+	}
+	defer device.Release()
+
+	embeddings := []float32{
+		1.0, 0.0, 0.0,
+		0.0, 1.0, 0.0,
+		0.0, 0.0, 1.0,
+		0.6, 0.8, 0.0,
+		0.7, 0.7, 0.14,
+	}
+	queries := [][]float32{
+		{0.6, 0.8, 0.0},
+		{0.0, 0.0, 2.0},
+	}
+	fp32, err := device.NewBuffer(embeddings, StorageShared)
+	if err != nil {
+		t.Fatalf("NewBuffer failed: %v", err)
+	}
+	defer fp32.Release()
+
+	if _, err := device.NewBufferWithPrecision(embeddings, StorageShared, PrecisionInt8); !errors.Is(err, ErrPrecision) {
+		t.Errorf("NewBufferWithPrecision(int8) error = %v, want ErrPrecision", err)
+	}
+	buf, err := device.NewQuantizedBuffer(embeddings, 3, StorageShared)
+	if err != nil {
+		t.Fatalf("NewQuantizedBuffer failed: %v", err)
+	}
+	defer buf.Release()
+	if buf.Precision() != PrecisionInt8 || buf.Size() != 16+5*4 {
+		t.Errorf("int8 buffer: precision %s, %d bytes", buf.Precision(), buf.Size())
+	}
+	if got, err := buf.ReadAt(9, 3); err != nil || math.Abs(float64(got[0]-0.6)) > 0.01 || math.Abs(float64(got[1]-0.8)) > 0.01 {
+		t.Errorf("ReadAt = %v, %v; want about [0.6 0.8 0]", got, err)
+	}
+	if _, err := buf.ReadAt(1, 3); !errors.Is(err, ErrPrecision) {
+		t.Errorf("ReadAt of a partial vector error = %v, want ErrPrecision", err)
+	}
+
+	// Scores match the fp32 buffer's to within the quantization error
+	for _, opts := range []SearchOptions{{Normalized: true}, {}} {
+		want, err := device.SearchBatch(fp32, queries, 5, 3, 2, opts)
+		if err != nil {
+			t.Fatalf("SearchBatch failed: %v", err)
+		}
+		got, err := device.SearchBatch(buf, queries, 5, 3, 2, opts)
+		if err != nil {
+			t.Fatalf("int8 SearchBatch(%+v) failed: %v", opts, err)
+		}
+		for i := range want {
+			for j := range want[i] {
+				if got[i][j].Index != want[i][j].Index || math.Abs(float64(got[i][j].Score-want[i][j].Score)) > 0.02 {
+					t.Errorf("int8 SearchBatch(%+v)[%d] = %v, want %v", opts, i, got[i], want[i])
+					break
+				}
+			}
+		}
+	}
+
+	// Rewriting a vector requantizes it
+	if err := buf.WriteAt(6, []float32{0, 0, -3}); err != nil {
+		t.Fatalf("WriteAt failed: %v", err)
+	}
+	if got, err := device.Search(buf, []float32{0, 0, 1}, 5, 3, 1, SearchOptions{}); err != nil || len(got) != 1 || got[0].Index == 2 {
+		t.Errorf("Search after WriteAt = %v, %v; want vector 2 gone from the top", got, err)
+	}
+	if _, err := device.Search(buf, []float32{1, 0}, 5, 2, 1, SearchOptions{}); err == nil {
+		t.Error("Search with other dimensions than the buffer's should fail")
+	}
+	if err := device.NormalizeVectors(buf, 5, 3); !errors.Is(err, ErrPrecision) {
+		t.Errorf("NormalizeVectors error = %v, want ErrPrecision", err)
+	}
+}
+
+func TestSearchOptions(t *testing.T) {
+	if !IsAvailable() {
+		t.Skip("Metal not available")
+	}
+
+	device, err := NewDevice()
+	if err != nil {
+		t.Fatalf("NewDevice failed: %v", err)
+	}
+	defer device.Release()
+
+	// Unnormalized rows, so cosine and dot product rank differently
+	embeddings := []float32{
+		2.0, 0.0, 0.0, // cosine 1.0, dot 2.0
+		0.6, 0.8, 0.0, // cosine 0.6, dot 0.6
+		0.0, 1.0, 0.0, // cosine 0.0
+		0.0, 0.0, 3.0, // cosine 0.0
+	}
+	embBuf, err := device.NewBuffer(embeddings, StorageShared)
+	if err != nil {
+		t.Fatalf("NewBuffer failed: %v", err)
+	}
+	defer embBuf.Release()
+	query := []float32{1.0, 0.0, 0.0}
+
+	tests := []struct {
+		name   string
+		k      int
+		opts   SearchOptions
+		want   []uint32
+		scores []float32
+	}{
+		{"threshold", 4, SearchOptions{MinScore: 0.5, HasMinScore: true}, []uint32{0, 1}, []float32{1.0, 0.6}},
+		{"nothing passes", 4, SearchOptions{MinScore: 1.5, HasMinScore: true}, nil, nil},
+		{"filter", 4, SearchOptions{Filter: NewFilter(4, 1, 3)}, []uint32{1, 3}, []float32{0.6, 0.0}},
+		{"filter and threshold", 4, SearchOptions{Filter: NewFilter(4, 1, 3), MinScore: 0.5, HasMinScore: true}, []uint32{1}, []float32{0.6}},
+		{"dot", 1, SearchOptions{Metric: MetricDot}, []uint32{0}, []float32{2.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := device.Search(embBuf, query, 4, 3, tt.k, tt.opts)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("Search returned %d results, want %d: %+v", len(results), len(tt.want), results)
+			}
+			for i, r := range results {
+				if r.Index != tt.want[i] || math.Abs(float64(r.Score-tt.scores[i])) > 0.001 {
+					t.Errorf("result %d = {%d, %f}, want {%d, %f}", i, r.Index, r.Score, tt.want[i], tt.scores[i])
+				}
+			}
+		})
+	}
+
+	if _, err := device.Search(embBuf, query, 4, 3, 1, SearchOptions{Filter: NewFilter(2)}); err == nil {
+		t.Error("Search with a filter shorter than n should fail")
+	}
+}
+
 func TestStorageModeConstants(t *testing.T) {
 	if StorageShared != 0 {
 		t.Errorf("StorageShared should be 0, got %d", StorageShared)
@@ -695,6 +1026,112 @@ func BenchmarkSearch(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		device.Search(embBuf, query, n, dims, 10, false)
+		device.Search(embBuf, query, n, dims, 10, SearchOptions{})
+	}
+}
+
+func TestSearchConcurrent(t *testing.T) {
+	if !IsAvailable() {
+		t.Skip("Metal not available")
+	}
+
+	device, err := NewDevice()
+	if err != nil {
+		t.Fatalf("NewDevice() error = %v", err)
+	}
+	defer device.Release()
+
+	embeddings := []float32{
+		1, 0, 0,
+		0, 1, 0,
+		0, 0, 1,
+	}
+	embBuf, err := device.NewBuffer(embeddings, StorageShared)
+	if err != nil {
+		t.Fatalf("NewBuffer() error = %v", err)
+	}
+	defer embBuf.Release()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(want int) {
+			defer wg.Done()
+			query := make([]float32, 3)
+			query[want] = 1
+			results, err := device.Search(embBuf, query, 3, 3, 1, SearchOptions{Normalized: true})
+			if err != nil {
+				t.Errorf("Search() error = %v", err)
+				return
+			}
+			if len(results) != 1 || results[0].Index != uint32(want) {
+				t.Errorf("Search() = %v, want index %d", results, want)
+			}
+		}(i % 3)
+	}
+	wg.Wait()
+}
+
+func TestUseAfterRelease(t *testing.T) {
+	if !IsAvailable() {
+		t.Skip("Metal not available")
+	}
+
+	device, err := NewDevice()
+	if err != nil {
+		t.Fatalf("NewDevice() error = %v", err)
+	}
+	embBuf, err := device.NewBuffer([]float32{1, 0, 0, 1}, StorageShared)
+	if err != nil {
+		t.Fatalf("NewBuffer() error = %v", err)
+	}
+
+	embBuf.Release()
+	if err := device.NormalizeVectors(embBuf, 2, 2); !errors.Is(err, ErrInvalidBuffer) {
+		t.Errorf("NormalizeVectors() on released buffer = %v, want ErrInvalidBuffer", err)
+	}
+	if embBuf.ReadFloat32(4) != nil {
+		t.Error("ReadFloat32() on released buffer should return nil")
+	}
+
+	embBuf, _ = device.NewBuffer([]float32{1, 0, 0, 1}, StorageShared)
+	defer embBuf.Release()
+	device.Release()
+	if err := device.NormalizeVectors(embBuf, 2, 2); !errors.Is(err, ErrDeviceReleased) {
+		t.Errorf("NormalizeVectors() on released device = %v, want ErrDeviceReleased", err)
+	}
+}
+
+// BenchmarkSearchConcurrent runs searches from parallel goroutines; compare
+// with BenchmarkSearch, which runs them one at a time.
+func BenchmarkSearchConcurrent(b *testing.B) {
+	if !IsAvailable() {
+		b.Skip("Metal not available")
+	}
+
+	device, _ := NewDevice()
+	defer device.Release()
+
+	n := uint32(10000)
+	dims := uint32(1024)
+
+	embeddings := make([]float32, n*dims)
+	for i := range embeddings {
+		embeddings[i] = float32(i%1000) / 1000
 	}
+
+	query := make([]float32, dims)
+	for i := range query {
+		query[i] = 0.5
+	}
+
+	embBuf, _ := device.NewBuffer(embeddings, StorageShared)
+	defer embBuf.Release()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			device.Search(embBuf, query, n, dims, 10, SearchOptions{})
+		}
+	})
 }
@@ -0,0 +1,87 @@
+package metal
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestNewFilter(t *testing.T) {
+	filter := NewFilter(130, 0, 63, 64, 129, 500)
+	if len(filter) != 3 {
+		t.Fatalf("len = %d, want 3", len(filter))
+	}
+	for row := uint32(0); row < 130; row++ {
+		want := row == 0 || row == 63 || row == 64 || row == 129
+		if got := filter[row/64]&(1<<(row%64)) != 0; got != want {
+			t.Errorf("row %d admitted = %v, want %v", row, got, want)
+		}
+	}
+}
+
+func TestSearchOptionsParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     SearchOptions
+		dot      bool
+		minScore float32
+		wantErr  bool
+	}{
+		{"zero value", SearchOptions{}, false, float32(math.Inf(-1)), false},
+		{"normalized cosine", SearchOptions{Normalized: true}, true, float32(math.Inf(-1)), false},
+		{"dot product", SearchOptions{Metric: MetricDot}, true, float32(math.Inf(-1)), false},
+		{"zero threshold", SearchOptions{HasMinScore: true}, false, 0, false},
+		{"threshold", SearchOptions{MinScore: 0.7, HasMinScore: true}, false, 0.7, false},
+		{"threshold without flag", SearchOptions{MinScore: 0.7}, false, float32(math.Inf(-1)), false},
+		{"unknown metric", SearchOptions{Metric: 42}, false, 0, true},
+		{"short filter", SearchOptions{Filter: NewFilter(64)}, false, 0, true},
+		{"filter", SearchOptions{Filter: NewFilter(100)}, false, float32(math.Inf(-1)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dot, minScore, err := tt.opts.params(100)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if dot != tt.dot || minScore != tt.minScore {
+				t.Errorf("params = (%v, %v), want (%v, %v)", dot, minScore, tt.dot, tt.minScore)
+			}
+		})
+	}
+}
+
+func TestPackQueries(t *testing.T) {
+	packed, err := packQueries([][]float32{{1, 2, 9}, {3, 4}}, 4, 2, SearchOptions{})
+	if err != nil {
+		t.Fatalf("packQueries: %v", err)
+	}
+	if want := []float32{1, 2, 3, 4}; !slices.Equal(packed, want) {
+		t.Errorf("packed = %v, want %v", packed, want)
+	}
+	if _, err := packQueries([][]float32{{1, 2}, {3}}, 4, 2, SearchOptions{}); err == nil {
+		t.Error("a short query should fail")
+	}
+	if _, err := packQueries([][]float32{{1, 2}}, 4, 2, SearchOptions{Metric: 42}); err == nil {
+		t.Error("an unknown metric should fail")
+	}
+}
+
+func TestUnpackBatch(t *testing.T) {
+	// Two queries, k = 2: the first filled both slots, the second one
+	results := unpackBatch([]uint32{4, 1, 2, 0}, []float32{0.9, 0.5, 0.7, 0}, []int32{2, 1}, 2)
+	want := [][]SearchResult{
+		{{Index: 4, Score: 0.9}, {Index: 1, Score: 0.5}},
+		{{Index: 2, Score: 0.7}},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d result sets, want %d", len(results), len(want))
+	}
+	for i := range want {
+		if !slices.Equal(results[i], want[i]) {
+			t.Errorf("results[%d] = %v, want %v", i, results[i], want[i])
+		}
+	}
+}
@@ -236,6 +236,9 @@ kernel void topk_simple(
     device float* topk_scores [[buffer(2)]],
     constant uint& n [[buffer(3)]],
     constant uint& k [[buffer(4)]],
+    constant float& min_score [[buffer(5)]],     // scores below are dropped
+    device const ulong* filter [[buffer(6)]],    // row bitset, if has_filter
+    constant uint& has_filter [[buffer(7)]],
     uint gid [[thread_position_in_grid]])
 {
     // Single thread finds top-k (only use for small n)
@@ -247,9 +250,12 @@ kernel void topk_simple(
         topk_indices[i] = UINT_MAX;
     }
     
-    // Linear scan to find top-k
+    // Linear scan to find top-k. Slots left at UINT_MAX mean fewer than k
+    // rows passed the threshold and filter.
     for (uint i = 0; i < n; i++) {
         float score = scores[i];
+        if (score < min_score) continue;
+        if (has_filter && !(filter[i / 64] & (1ul << (i % 64)))) continue;
         
         // Check if this score makes it into top-k
         if (score > topk_scores[k-1]) {
@@ -266,6 +272,199 @@ kernel void topk_simple(
     }
 }
 
+// =============================================================================
+// Kernels: Batched Search
+// =============================================================================
+// Score nq queries against all embeddings in one dispatch, then select the
+// top-k of every query in a second dispatch, one thread per query.
+//
+// Memory Layout:
+//   - queries: [nq × dimensions] contiguous float array
+//   - scores: [nq × n], one row per query
+//   - topk_indices, topk_scores: [nq × k], one row per query
+
+kernel void cosine_similarity_batch(
+    device const float* embeddings [[buffer(0)]],
+    device const float* queries [[buffer(1)]],
+    device float* scores [[buffer(2)]],
+    constant uint& n [[buffer(3)]],
+    constant uint& dimensions [[buffer(4)]],
+    constant uint& normalized [[buffer(5)]],
+    uint2 gid [[thread_position_in_grid]])
+{
+    if (gid.x >= n) return;
+    
+    float dot = 0.0f;
+    float normA = 0.0f;
+    float normB = 0.0f;
+    
+    uint base = gid.x * dimensions;
+    uint qbase = gid.y * dimensions;
+    
+    for (uint i = 0; i < dimensions; i++) {
+        float a = embeddings[base + i];
+        float b = queries[qbase + i];
+        dot += a * b;
+        normA += a * a;
+        normB += b * b;
+    }
+    
+    float score = dot;
+    if (!normalized) {
+        score = (normA == 0.0f || normB == 0.0f) ? 0.0f : dot / (sqrt(normA) * sqrt(normB));
+    }
+    scores[gid.y * n + gid.x] = score;
+}
+
+// cosine_similarity_batch over FP16 embeddings, widened to float as they
+// are read; accumulation stays in float.
+kernel void cosine_similarity_batch_f16(
+    device const half* embeddings [[buffer(0)]],
+    device const float* queries [[buffer(1)]],
+    device float* scores [[buffer(2)]],
+    constant uint& n [[buffer(3)]],
+    constant uint& dimensions [[buffer(4)]],
+    constant uint& normalized [[buffer(5)]],
+    uint2 gid [[thread_position_in_grid]])
+{
+    if (gid.x >= n) return;
+    
+    float dot = 0.0f;
+    float normA = 0.0f;
+    float normB = 0.0f;
+    
+    uint base = gid.x * dimensions;
+    uint qbase = gid.y * dimensions;
+    
+    for (uint i = 0; i < dimensions; i++) {
+        float a = float(embeddings[base + i]);
+        float b = queries[qbase + i];
+        dot += a * b;
+        normA += a * a;
+        normB += b * b;
+    }
+    
+    float score = dot;
+    if (!normalized) {
+        score = (normA == 0.0f || normB == 0.0f) ? 0.0f : dot / (sqrt(normA) * sqrt(normB));
+    }
+    scores[gid.y * n + gid.x] = score;
+}
+
+// cosine_similarity_batch over BF16 embeddings: each is the upper half of
+// a float, widened by a shift.
+kernel void cosine_similarity_batch_bf16(
+    device const ushort* embeddings [[buffer(0)]],
+    device const float* queries [[buffer(1)]],
+    device float* scores [[buffer(2)]],
+    constant uint& n [[buffer(3)]],
+    constant uint& dimensions [[buffer(4)]],
+    constant uint& normalized [[buffer(5)]],
+    uint2 gid [[thread_position_in_grid]])
+{
+    if (gid.x >= n) return;
+    
+    float dot = 0.0f;
+    float normA = 0.0f;
+    float normB = 0.0f;
+    
+    uint base = gid.x * dimensions;
+    uint qbase = gid.y * dimensions;
+    
+    for (uint i = 0; i < dimensions; i++) {
+        float a = as_type<float>(uint(embeddings[base + i]) << 16);
+        float b = queries[qbase + i];
+        dot += a * b;
+        normA += a * a;
+        normB += b * b;
+    }
+    
+    float score = dot;
+    if (!normalized) {
+        score = (normA == 0.0f || normB == 0.0f) ? 0.0f : dot / (sqrt(normA) * sqrt(normB));
+    }
+    scores[gid.y * n + gid.x] = score;
+}
+
+// cosine_similarity_batch over int8 codes (see package quant), each
+// row with a float scale in scales. The scale cancels out of cosine
+// similarity, so only dot products are multiplied by it.
+kernel void cosine_similarity_batch_int8(
+    device const char* embeddings [[buffer(0)]],
+    device const float* queries [[buffer(1)]],
+    device float* scores [[buffer(2)]],
+    constant uint& n [[buffer(3)]],
+    constant uint& dimensions [[buffer(4)]],
+    constant uint& normalized [[buffer(5)]],
+    device const float* scales [[buffer(6)]],
+    uint2 gid [[thread_position_in_grid]])
+{
+    if (gid.x >= n) return;
+    
+    float dot = 0.0f;
+    float normA = 0.0f;
+    float normB = 0.0f;
+    
+    uint base = gid.x * dimensions;
+    uint qbase = gid.y * dimensions;
+    
+    for (uint i = 0; i < dimensions; i++) {
+        float a = float(embeddings[base + i]);
+        float b = queries[qbase + i];
+        dot += a * b;
+        normA += a * a;
+        normB += b * b;
+    }
+    
+    float score = dot * scales[gid.x];
+    if (!normalized) {
+        score = (normA == 0.0f || normB == 0.0f) ? 0.0f : dot / (sqrt(normA) * sqrt(normB));
+    }
+    scores[gid.y * n + gid.x] = score;
+}
+
+kernel void topk_batch(
+    device const float* scores [[buffer(0)]],
+    device uint* topk_indices [[buffer(1)]],
+    device float* topk_scores [[buffer(2)]],
+    constant uint& n [[buffer(3)]],
+    constant uint& k [[buffer(4)]],
+    constant float& min_score [[buffer(5)]],     // scores below are dropped
+    device const ulong* filter [[buffer(6)]],    // row bitset, if has_filter
+    constant uint& has_filter [[buffer(7)]],
+    constant uint& nq [[buffer(8)]],
+    uint gid [[thread_position_in_grid]])
+{
+    if (gid >= nq) return;
+    
+    device const float* row = scores + gid * n;
+    device uint* out_indices = topk_indices + gid * k;
+    device float* out_scores = topk_scores + gid * k;
+    
+    // Same selection as topk_simple, over this query's row
+    for (uint i = 0; i < k; i++) {
+        out_scores[i] = -2.0f;
+        out_indices[i] = UINT_MAX;
+    }
+    
+    for (uint i = 0; i < n; i++) {
+        float score = row[i];
+        if (score < min_score) continue;
+        if (has_filter && !(filter[i / 64] & (1ul << (i % 64)))) continue;
+        
+        if (score > out_scores[k-1]) {
+            uint pos = k - 1;
+            while (pos > 0 && score > out_scores[pos-1]) {
+                out_scores[pos] = out_scores[pos-1];
+                out_indices[pos] = out_indices[pos-1];
+                pos--;
+            }
+            out_scores[pos] = score;
+            out_indices[pos] = i;
+        }
+    }
+}
+
 // =============================================================================
 // Kernel: Vector Normalization
 // =============================================================================
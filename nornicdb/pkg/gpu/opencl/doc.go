@@ -58,6 +58,34 @@
 //   - Top-K search: Parallel reduction with local memory
 //   - Normalization: Per-vector parallelization
 //
+// # Concurrency
+//
+// A Device is safe for concurrent use. It owns a pool of command queues
+// (NewDeviceWithQueues sets the size; NewDevice uses lanes.DefaultLanes),
+// each with its own kernel objects and search scratch buffers, so searches
+// from different goroutines run on different queues instead of waiting on a
+// device-wide lock. Buffer.Release and Device.Release wait for operations in
+// progress; later operations return ErrInvalidBuffer or ErrDeviceReleased.
+// Writing a buffer while another operation reads it is still a data race.
+//
+// # Half Precision
+//
+// NewBufferWithPrecision stores embeddings as FP16 or BF16, halving their
+// device memory. Search and SearchBatch use a kernel that widens each
+// element with vload_half (or a shift, for BF16) and accumulates in float,
+// so no cl_khr_fp16 support is needed.
+//
+//	buffer, err := device.NewBufferWithPrecision(embeddings, opencl.PrecisionFP16)
+//
+// # Int8 Quantization
+//
+// NewQuantizedBuffer stores embeddings as int8 codes with a float scale per
+// vector (see package quant), nearly a quarter of their float32 device
+// memory. Search and SearchBatch use a kernel that reads the codes as char,
+// accumulates in float and applies the scale.
+//
+//	buffer, err := device.NewQuantizedBuffer(embeddings, dims)
+//
 // # Example
 //
 // Basic usage:
@@ -76,7 +104,11 @@
 //	defer buffer.Release()
 //
 //	// Search for similar vectors
-//	results, err := device.Search(buffer, query, numVectors, dimensions, topK, normalized)
+//	results, err := device.Search(buffer, query, numVectors, dimensions, topK, opencl.SearchOptions{
+//	    Normalized:  normalized,
+//	    MinScore:    0.7,
+//	    HasMinScore: true,
+//	})
 //	if err != nil {
 //	    log.Fatal(err)
 //	}
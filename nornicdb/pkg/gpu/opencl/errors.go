@@ -0,0 +1,58 @@
+package opencl
+
+import "github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+
+// OpenCL error codes (cl_int) that classify a failure.
+const (
+	clDeviceNotFound                     = -1
+	clDeviceNotAvailable                 = -2
+	clCompilerNotAvailable               = -3
+	clMemObjectAllocationFailure         = -4
+	clOutOfResources                     = -5
+	clOutOfHostMemory                    = -6
+	clBuildProgramFailure                = -11
+	clExecStatusErrorForEventsInWaitList = -14
+	clInvalidCommandQueue                = -36
+	clInvalidProgramExecutable           = -45
+	clInvalidKernelName                  = -46
+	clInvalidKernelDefinition            = -47
+	clInvalidKernel                      = -48
+	clInvalidArgIndex                    = -49
+	clInvalidArgValue                    = -50
+	clInvalidArgSize                     = -51
+	clInvalidKernelArgs                  = -52
+	clInvalidWorkGroupSize               = -54
+	clInvalidWorkItemSize                = -55
+	clInvalidBufferSize                  = -61
+	clInvalidGlobalWorkSize              = -63
+	clPlatformNotFoundKHR                = -1001
+	clNVIDIAIllegalAccess                = -9999 // NVIDIA: illegal read or write in a kernel
+)
+
+// classify maps a cl_int error code to a shared error kind, or nil.
+//
+// CL_INVALID_BUFFER_SIZE is an allocation over CL_DEVICE_MAX_MEM_ALLOC_SIZE,
+// so it counts as out of memory: a smaller buffer can succeed. An invalid
+// command queue after a successful setup means the driver reset the device.
+func classify(code int) error {
+	switch code {
+	case clMemObjectAllocationFailure, clOutOfResources, clOutOfHostMemory, clInvalidBufferSize:
+		return gpuerr.ErrOutOfMemory
+	case clDeviceNotFound, clDeviceNotAvailable, clCompilerNotAvailable, clPlatformNotFoundKHR:
+		return gpuerr.ErrNotAvailable
+	case clInvalidCommandQueue, clNVIDIAIllegalAccess:
+		return gpuerr.ErrDeviceLost
+	case clBuildProgramFailure, clExecStatusErrorForEventsInWaitList, clInvalidProgramExecutable,
+		clInvalidKernelName, clInvalidKernelDefinition, clInvalidKernel, clInvalidArgIndex,
+		clInvalidArgValue, clInvalidArgSize, clInvalidKernelArgs, clInvalidWorkGroupSize,
+		clInvalidWorkItemSize, clInvalidGlobalWorkSize:
+		return gpuerr.ErrKernel
+	}
+	return nil
+}
+
+// newError builds the error returned for a failed op from the last native
+// error code and message.
+func newError(op error, code int, msg string) error {
+	return gpuerr.New("opencl", op, classify(code), code, msg)
+}
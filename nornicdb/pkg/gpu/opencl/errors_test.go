@@ -0,0 +1,37 @@
+package opencl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+)
+
+func TestNewErrorClassifiesCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		op   error
+		code int
+		want error
+	}{
+		{"allocation failure", ErrBufferCreation, clMemObjectAllocationFailure, gpuerr.ErrOutOfMemory},
+		{"over max alloc size", ErrBufferCreation, clInvalidBufferSize, gpuerr.ErrOutOfMemory},
+		{"out of resources", ErrKernelExecution, clOutOfResources, gpuerr.ErrOutOfMemory},
+		{"no platform", ErrDeviceCreation, clPlatformNotFoundKHR, gpuerr.ErrNotAvailable},
+		{"queue gone", ErrKernelExecution, clInvalidCommandQueue, gpuerr.ErrDeviceLost},
+		{"build failure", ErrDeviceCreation, clBuildProgramFailure, gpuerr.ErrKernel},
+		{"bad work group", ErrKernelExecution, clInvalidWorkGroupSize, gpuerr.ErrKernel},
+		{"no code uses op kind", ErrBufferCreation, 0, gpuerr.ErrOutOfMemory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newError(tt.op, tt.code, "native message")
+			if !errors.Is(err, tt.op) {
+				t.Errorf("%v should match %v", err, tt.op)
+			}
+			if got := gpuerr.KindOf(err); got != tt.want {
+				t.Errorf("kind = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -18,23 +18,38 @@ package opencl
 #include <CL/cl.h>
 #endif
 
+#include <math.h>
+#include <stdint.h>
 #include <stdlib.h>
 #include <string.h>
 #include <stdio.h>
 
-// Error handling
-static char opencl_last_error[256] = {0};
+// Error handling. The cl_int code lets Go classify failures (see errors.go).
+// The state is per thread: Go reads it on the thread that made the failing
+// call, while other queues keep running.
+static __thread char opencl_last_error[256] = {0};
+static __thread int opencl_last_code = 0;
 
 void opencl_set_error(const char* msg) {
     strncpy(opencl_last_error, msg, sizeof(opencl_last_error) - 1);
 }
 
+void opencl_set_cl_error(cl_int err, const char* msg) {
+    opencl_last_code = (int)err;
+    opencl_set_error(msg);
+}
+
 const char* opencl_get_last_error() {
     return opencl_last_error;
 }
 
+int opencl_get_last_code() {
+    return opencl_last_code;
+}
+
 void opencl_clear_error() {
     opencl_last_error[0] = 0;
+    opencl_last_code = 0;
 }
 
 const char* opencl_error_string(cl_int error) {
@@ -125,6 +140,124 @@ static const char* kernel_source =
 "    scores[idx] = (denom > 1e-10f) ? (dot / denom) : 0.0f;\n"
 "}\n"
 "\n"
+"// One work item per (embedding, query) pair of a batch; scores is\n"
+"// row-major nq x n, one row per query.\n"
+"__kernel void cosine_similarity_batch(\n"
+"    __global const float* embeddings,\n"
+"    __global const float* queries,\n"
+"    __global float* scores,\n"
+"    const unsigned int n,\n"
+"    const unsigned int dims,\n"
+"    const int normalized\n"
+") {\n"
+"    unsigned int idx = get_global_id(0);\n"
+"    unsigned int qi = get_global_id(1);\n"
+"    if (idx >= n) return;\n"
+"    \n"
+"    float dot = 0.0f;\n"
+"    float norm_e = 0.0f;\n"
+"    float norm_q = 0.0f;\n"
+"    \n"
+"    __global const float* vec = embeddings + (size_t)idx * dims;\n"
+"    __global const float* query = queries + (size_t)qi * dims;\n"
+"    \n"
+"    for (unsigned int d = 0; d < dims; d++) {\n"
+"        float e = vec[d];\n"
+"        float q = query[d];\n"
+"        dot += e * q;\n"
+"        norm_e += e * e;\n"
+"        norm_q += q * q;\n"
+"    }\n"
+"    \n"
+"    float score = dot;\n"
+"    if (!normalized) {\n"
+"        float denom = sqrt(norm_e) * sqrt(norm_q);\n"
+"        score = (denom > 1e-10f) ? (dot / denom) : 0.0f;\n"
+"    }\n"
+"    scores[(size_t)qi * n + idx] = score;\n"
+"}\n"
+"\n"
+"// cosine_similarity_batch over 16-bit embeddings: FP16 (precision 1) is\n"
+"// widened with vload_half, BF16 (precision 2) by a shift into the upper\n"
+"// half of a float. Products accumulate in float.\n"
+"__kernel void cosine_similarity_half_batch(\n"
+"    __global const ushort* embeddings,\n"
+"    __global const float* queries,\n"
+"    __global float* scores,\n"
+"    const unsigned int n,\n"
+"    const unsigned int dims,\n"
+"    const int normalized,\n"
+"    const int precision\n"
+") {\n"
+"    unsigned int idx = get_global_id(0);\n"
+"    unsigned int qi = get_global_id(1);\n"
+"    if (idx >= n) return;\n"
+"    \n"
+"    float dot = 0.0f;\n"
+"    float norm_e = 0.0f;\n"
+"    float norm_q = 0.0f;\n"
+"    \n"
+"    size_t base = (size_t)idx * dims;\n"
+"    __global const float* query = queries + (size_t)qi * dims;\n"
+"    \n"
+"    for (unsigned int d = 0; d < dims; d++) {\n"
+"        float e = precision == 1\n"
+"            ? vload_half(base + d, (__global const half*)embeddings)\n"
+"            : as_float((uint)embeddings[base + d] << 16);\n"
+"        float q = query[d];\n"
+"        dot += e * q;\n"
+"        norm_e += e * e;\n"
+"        norm_q += q * q;\n"
+"    }\n"
+"    \n"
+"    float score = dot;\n"
+"    if (!normalized) {\n"
+"        float denom = sqrt(norm_e) * sqrt(norm_q);\n"
+"        score = (denom > 1e-10f) ? (dot / denom) : 0.0f;\n"
+"    }\n"
+"    scores[(size_t)qi * n + idx] = score;\n"
+"}\n"
+"\n"
+"// cosine_similarity_batch over int8 codes with a float scale per row at\n"
+"// scales_offset bytes (see package quant). Codes accumulate in float; the\n"
+"// scale cancels out of cosine similarity, so only dot products use it.\n"
+"__kernel void cosine_similarity_int8_batch(\n"
+"    __global const char* embeddings,\n"
+"    __global const float* queries,\n"
+"    __global float* scores,\n"
+"    const unsigned int n,\n"
+"    const unsigned int dims,\n"
+"    const int normalized,\n"
+"    const ulong scales_offset\n"
+") {\n"
+"    unsigned int idx = get_global_id(0);\n"
+"    unsigned int qi = get_global_id(1);\n"
+"    if (idx >= n) return;\n"
+"    \n"
+"    float dot = 0.0f;\n"
+"    float norm_e = 0.0f;\n"
+"    float norm_q = 0.0f;\n"
+"    \n"
+"    __global const char* vec = embeddings + (size_t)idx * dims;\n"
+"    __global const float* query = queries + (size_t)qi * dims;\n"
+"    \n"
+"    for (unsigned int d = 0; d < dims; d++) {\n"
+"        float e = (float)vec[d];\n"
+"        float q = query[d];\n"
+"        dot += e * q;\n"
+"        norm_e += e * e;\n"
+"        norm_q += q * q;\n"
+"    }\n"
+"    \n"
+"    __global const float* row_scales = (__global const float*)(embeddings + scales_offset);\n"
+"    float score = dot * row_scales[idx];\n"
+"    if (!normalized) {\n"
+"        float denom = sqrt(norm_e) * sqrt(norm_q);\n"
+"        score = (denom > 1e-10f) ? (dot / denom) : 0.0f;\n"
+"    }\n"
+"    scores[(size_t)qi * n + idx] = score;\n"
+"}\n"
+"\n"
 "__kernel void compute_norms(\n"
 "    __global const float* vectors,\n"
 "    __global float* norms,\n"
@@ -163,19 +296,36 @@ static const char* kernel_source =
 "    }\n"
 "}\n";
 
-// Device structure
+// Device structure. The context and program are shared; everything used to
+// launch work lives in an OpenCLQueue.
 typedef struct {
     cl_platform_id platform;
     cl_device_id device;
     cl_context context;
-    cl_command_queue queue;
     cl_program program;
+    int device_id;
+} OpenCLDevice;
+
+// A command queue with its own kernel objects and scratch buffers.
+// clSetKernelArg is not thread-safe for a shared cl_kernel, so each queue
+// creates its own kernels from the device program; operations on different
+// queues run concurrently.
+typedef struct {
+    OpenCLDevice* dev;
+    cl_command_queue queue;
     cl_kernel kernel_cosine_normalized;
     cl_kernel kernel_cosine;
+    cl_kernel kernel_cosine_batch;
+    cl_kernel kernel_cosine_half_batch;
+    cl_kernel kernel_cosine_int8_batch;
     cl_kernel kernel_norms;
     cl_kernel kernel_normalize;
-    int device_id;
-} OpenCLDevice;
+    cl_mem query;          // search scratch: query vector
+    size_t query_size;
+    cl_mem scores;         // search scratch: similarity scores
+    size_t scores_size;
+    float* host_scores;    // search scratch: scores copied back for top-k
+} OpenCLQueue;
 
 // Get number of GPU devices across all platforms
 int opencl_get_device_count() {
@@ -238,6 +388,57 @@ int opencl_get_device_by_index(int index, cl_platform_id* out_platform, cl_devic
     return -1;
 }
 
+// Why no GPU device is usable: missing ICD, no GPU platform, etc.
+const char* opencl_unavailable_reason() {
+    cl_uint num_platforms = 0;
+    cl_int err = clGetPlatformIDs(0, NULL, &num_platforms);
+    // -1001 is CL_PLATFORM_NOT_FOUND_KHR, returned by the ICD loader
+    if (err == -1001 || (err == CL_SUCCESS && num_platforms == 0)) {
+        return "no OpenCL platform found (is an OpenCL ICD for your GPU installed?)";
+    }
+    if (err != CL_SUCCESS) {
+        return opencl_error_string(err);
+    }
+    if (opencl_get_device_count() == 0) {
+        return "OpenCL platforms found but none exposes a GPU device";
+    }
+    return "";
+}
+
+// Device properties queried without creating a context
+typedef struct {
+    char name[256];
+    char vendor[256];
+    char driver_version[128];
+    char* extensions;
+    cl_ulong memory;
+    size_t max_work_group_size;
+} OpenCLDeviceProperties;
+
+int opencl_query_device(int index, OpenCLDeviceProperties* props) {
+    cl_platform_id platform;
+    cl_device_id device;
+    if (opencl_get_device_by_index(index, &platform, &device) != 0) {
+        return -1;
+    }
+
+    memset(props, 0, sizeof(OpenCLDeviceProperties));
+    clGetDeviceInfo(device, CL_DEVICE_NAME, sizeof(props->name) - 1, props->name, NULL);
+    clGetDeviceInfo(device, CL_DEVICE_VENDOR, sizeof(props->vendor) - 1, props->vendor, NULL);
+    clGetDeviceInfo(device, CL_DRIVER_VERSION, sizeof(props->driver_version) - 1, props->driver_version, NULL);
+    clGetDeviceInfo(device, CL_DEVICE_GLOBAL_MEM_SIZE, sizeof(cl_ulong), &props->memory, NULL);
+    clGetDeviceInfo(device, CL_DEVICE_MAX_WORK_GROUP_SIZE, sizeof(size_t), &props->max_work_group_size, NULL);
+
+    size_t ext_size = 0;
+    if (clGetDeviceInfo(device, CL_DEVICE_EXTENSIONS, 0, NULL, &ext_size) == CL_SUCCESS && ext_size > 0) {
+        props->extensions = (char*)calloc(ext_size + 1, 1);
+        if (props->extensions) {
+            clGetDeviceInfo(device, CL_DEVICE_EXTENSIONS, ext_size, props->extensions, NULL);
+        }
+    }
+    return 0;
+}
+
 OpenCLDevice* opencl_create_device(int device_id) {
     OpenCLDevice* dev = (OpenCLDevice*)malloc(sizeof(OpenCLDevice));
     if (!dev) {
@@ -261,18 +462,7 @@ OpenCLDevice* opencl_create_device(int device_id) {
     if (err != CL_SUCCESS) {
         char msg[256];
         snprintf(msg, sizeof(msg), "Failed to create context: %s", opencl_error_string(err));
-        opencl_set_error(msg);
-        free(dev);
-        return NULL;
-    }
-
-    // Create command queue
-    dev->queue = clCreateCommandQueue(dev->context, dev->device, 0, &err);
-    if (err != CL_SUCCESS) {
-        char msg[256];
-        snprintf(msg, sizeof(msg), "Failed to create command queue: %s", opencl_error_string(err));
-        opencl_set_error(msg);
-        clReleaseContext(dev->context);
+        opencl_set_cl_error(err, msg);
         free(dev);
         return NULL;
     }
@@ -283,8 +473,7 @@ OpenCLDevice* opencl_create_device(int device_id) {
     if (err != CL_SUCCESS) {
         char msg[256];
         snprintf(msg, sizeof(msg), "Failed to create program: %s", opencl_error_string(err));
-        opencl_set_error(msg);
-        clReleaseCommandQueue(dev->queue);
+        opencl_set_cl_error(err, msg);
         clReleaseContext(dev->context);
         free(dev);
         return NULL;
@@ -302,77 +491,87 @@ OpenCLDevice* opencl_create_device(int device_id) {
         
         char msg[256];
         snprintf(msg, sizeof(msg), "Failed to build program: %s", log);
-        opencl_set_error(msg);
+        opencl_set_cl_error(err, msg);
         
         free(log);
         clReleaseProgram(dev->program);
-        clReleaseCommandQueue(dev->queue);
         clReleaseContext(dev->context);
         free(dev);
         return NULL;
     }
 
-    // Create kernels
-    dev->kernel_cosine_normalized = clCreateKernel(dev->program, "cosine_similarity_normalized", &err);
-    if (err != CL_SUCCESS) {
-        opencl_set_error("Failed to create kernel: cosine_similarity_normalized");
-        clReleaseProgram(dev->program);
-        clReleaseCommandQueue(dev->queue);
-        clReleaseContext(dev->context);
+    return dev;
+}
+
+void opencl_release_device(OpenCLDevice* dev) {
+    if (dev) {
+        if (dev->program) clReleaseProgram(dev->program);
+        if (dev->context) clReleaseContext(dev->context);
         free(dev);
-        return NULL;
     }
+}
 
-    dev->kernel_cosine = clCreateKernel(dev->program, "cosine_similarity", &err);
+void opencl_release_queue(OpenCLQueue* q) {
+    if (q) {
+        if (q->kernel_normalize) clReleaseKernel(q->kernel_normalize);
+        if (q->kernel_norms) clReleaseKernel(q->kernel_norms);
+        if (q->kernel_cosine_int8_batch) clReleaseKernel(q->kernel_cosine_int8_batch);
+        if (q->kernel_cosine_half_batch) clReleaseKernel(q->kernel_cosine_half_batch);
+        if (q->kernel_cosine_batch) clReleaseKernel(q->kernel_cosine_batch);
+        if (q->kernel_cosine) clReleaseKernel(q->kernel_cosine);
+        if (q->kernel_cosine_normalized) clReleaseKernel(q->kernel_cosine_normalized);
+        if (q->query) clReleaseMemObject(q->query);
+        if (q->scores) clReleaseMemObject(q->scores);
+        if (q->queue) clReleaseCommandQueue(q->queue);
+        free(q->host_scores);
+        free(q);
+    }
+}
+
+static cl_kernel opencl_create_kernel(OpenCLQueue* q, const char* name) {
+    cl_int err;
+    cl_kernel kernel = clCreateKernel(q->dev->program, name, &err);
     if (err != CL_SUCCESS) {
-        opencl_set_error("Failed to create kernel: cosine_similarity");
-        clReleaseKernel(dev->kernel_cosine_normalized);
-        clReleaseProgram(dev->program);
-        clReleaseCommandQueue(dev->queue);
-        clReleaseContext(dev->context);
-        free(dev);
+        char msg[256];
+        snprintf(msg, sizeof(msg), "Failed to create kernel: %s", name);
+        opencl_set_cl_error(err, msg);
         return NULL;
     }
+    return kernel;
+}
 
-    dev->kernel_norms = clCreateKernel(dev->program, "compute_norms", &err);
-    if (err != CL_SUCCESS) {
-        opencl_set_error("Failed to create kernel: compute_norms");
-        clReleaseKernel(dev->kernel_cosine);
-        clReleaseKernel(dev->kernel_cosine_normalized);
-        clReleaseProgram(dev->program);
-        clReleaseCommandQueue(dev->queue);
-        clReleaseContext(dev->context);
-        free(dev);
+OpenCLQueue* opencl_create_queue(OpenCLDevice* dev) {
+    OpenCLQueue* q = (OpenCLQueue*)malloc(sizeof(OpenCLQueue));
+    if (!q) {
+        opencl_set_error("Failed to allocate queue struct");
         return NULL;
     }
+    memset(q, 0, sizeof(OpenCLQueue));
+    q->dev = dev;
 
-    dev->kernel_normalize = clCreateKernel(dev->program, "normalize_vectors", &err);
+    cl_int err;
+    q->queue = clCreateCommandQueue(dev->context, dev->device, 0, &err);
     if (err != CL_SUCCESS) {
-        opencl_set_error("Failed to create kernel: normalize_vectors");
-        clReleaseKernel(dev->kernel_norms);
-        clReleaseKernel(dev->kernel_cosine);
-        clReleaseKernel(dev->kernel_cosine_normalized);
-        clReleaseProgram(dev->program);
-        clReleaseCommandQueue(dev->queue);
-        clReleaseContext(dev->context);
-        free(dev);
+        char msg[256];
+        snprintf(msg, sizeof(msg), "Failed to create command queue: %s", opencl_error_string(err));
+        opencl_set_cl_error(err, msg);
+        free(q);
         return NULL;
     }
 
-    return dev;
-}
-
-void opencl_release_device(OpenCLDevice* dev) {
-    if (dev) {
-        if (dev->kernel_normalize) clReleaseKernel(dev->kernel_normalize);
-        if (dev->kernel_norms) clReleaseKernel(dev->kernel_norms);
-        if (dev->kernel_cosine) clReleaseKernel(dev->kernel_cosine);
-        if (dev->kernel_cosine_normalized) clReleaseKernel(dev->kernel_cosine_normalized);
-        if (dev->program) clReleaseProgram(dev->program);
-        if (dev->queue) clReleaseCommandQueue(dev->queue);
-        if (dev->context) clReleaseContext(dev->context);
-        free(dev);
+    q->kernel_cosine_normalized = opencl_create_kernel(q, "cosine_similarity_normalized");
+    q->kernel_cosine = q->kernel_cosine_normalized ? opencl_create_kernel(q, "cosine_similarity") : NULL;
+    q->kernel_cosine_batch = q->kernel_cosine ? opencl_create_kernel(q, "cosine_similarity_batch") : NULL;
+    q->kernel_cosine_half_batch = q->kernel_cosine_batch ? opencl_create_kernel(q, "cosine_similarity_half_batch") : NULL;
+    q->kernel_cosine_int8_batch = q->kernel_cosine_half_batch ? opencl_create_kernel(q, "cosine_similarity_int8_batch") : NULL;
+    q->kernel_norms = q->kernel_cosine_int8_batch ? opencl_create_kernel(q, "compute_norms") : NULL;
+    q->kernel_normalize = q->kernel_norms ? opencl_create_kernel(q, "normalize_vectors") : NULL;
+    if (!q->kernel_normalize) {
+        opencl_release_queue(q);
+        return NULL;
     }
+
+    return q;
 }
 
 const char* opencl_device_name(OpenCLDevice* dev) {
@@ -415,18 +614,27 @@ size_t opencl_max_work_group_size(OpenCLDevice* dev) {
 typedef struct {
     cl_mem mem;
     size_t size;
-    OpenCLDevice* device;
+    int precision; // 0 float32, 1 FP16, 2 BF16, 3 int8 (Precision in Go)
 } OpenCLBuffer;
 
-OpenCLBuffer* opencl_create_buffer(OpenCLDevice* dev, float* host_data, size_t count) {
+// Bytes per element of a buffer precision.
+static size_t opencl_element_size(int precision) {
+    switch (precision) {
+    case 0: return sizeof(float);
+    case 3: return sizeof(int8_t);
+    default: return sizeof(uint16_t);
+    }
+}
+
+OpenCLBuffer* opencl_create_buffer(OpenCLDevice* dev, void* host_data, size_t count, int precision) {
     OpenCLBuffer* buf = (OpenCLBuffer*)malloc(sizeof(OpenCLBuffer));
     if (!buf) {
         opencl_set_error("Failed to allocate buffer struct");
         return NULL;
     }
 
-    buf->size = count * sizeof(float);
-    buf->device = dev;
+    buf->size = count * opencl_element_size(precision);
+    buf->precision = precision;
 
     cl_int err;
     cl_mem_flags flags = CL_MEM_READ_WRITE;
@@ -438,7 +646,7 @@ OpenCLBuffer* opencl_create_buffer(OpenCLDevice* dev, float* host_data, size_t c
     if (err != CL_SUCCESS) {
         char msg[256];
         snprintf(msg, sizeof(msg), "Failed to create buffer: %s", opencl_error_string(err));
-        opencl_set_error(msg);
+        opencl_set_cl_error(err, msg);
         free(buf);
         return NULL;
     }
@@ -457,115 +665,161 @@ size_t opencl_buffer_size(OpenCLBuffer* buf) {
     return buf ? buf->size : 0;
 }
 
-int opencl_buffer_copy_to_host(OpenCLBuffer* buf, float* host_data, size_t count) {
-    if (!buf || !host_data) return -1;
-
-    size_t copy_size = count * sizeof(float);
-    if (copy_size > buf->size) copy_size = buf->size;
-
-    cl_int err = clEnqueueReadBuffer(buf->device->queue, buf->mem, CL_TRUE, 0, copy_size, host_data, 0, NULL, NULL);
+static int opencl_read(OpenCLQueue* q, cl_mem mem, void* host_data, size_t offset, size_t size) {
+    cl_int err = clEnqueueReadBuffer(q->queue, mem, CL_TRUE, offset, size, host_data, 0, NULL, NULL);
     if (err != CL_SUCCESS) {
         char msg[256];
         snprintf(msg, sizeof(msg), "Failed to read buffer: %s", opencl_error_string(err));
-        opencl_set_error(msg);
+        opencl_set_cl_error(err, msg);
         return -1;
     }
     return 0;
 }
 
-// Vector operations
+// Copy count elements starting at an element offset into host memory, in
+// the buffer's precision
+int opencl_buffer_copy_to_host(OpenCLQueue* q, OpenCLBuffer* buf, void* host_data, size_t offset, size_t count) {
+    if (!buf || !host_data) return -1;
 
-int opencl_normalize_vectors(OpenCLDevice* dev, OpenCLBuffer* vectors, unsigned int n, unsigned int dims) {
-    cl_int err;
+    size_t elem = opencl_element_size(buf->precision);
+    size_t byte_offset = offset * elem;
+    size_t copy_size = count * elem;
+    if (byte_offset + copy_size > buf->size) {
+        opencl_set_error("read exceeds buffer size");
+        return -1;
+    }
 
-    // Create norms buffer
-    OpenCLBuffer* norms = opencl_create_buffer(dev, NULL, n);
-    if (!norms) return -1;
+    return opencl_read(q, buf->mem, host_data, byte_offset, copy_size);
+}
 
-    // Compute norms
-    err = clSetKernelArg(dev->kernel_norms, 0, sizeof(cl_mem), &vectors->mem);
-    err |= clSetKernelArg(dev->kernel_norms, 1, sizeof(cl_mem), &norms->mem);
-    err |= clSetKernelArg(dev->kernel_norms, 2, sizeof(unsigned int), &n);
-    err |= clSetKernelArg(dev->kernel_norms, 3, sizeof(unsigned int), &dims);
+// Copy host data, in the buffer's precision, into a buffer at an element
+// offset
+int opencl_buffer_copy_from_host(OpenCLQueue* q, OpenCLBuffer* buf, const void* host_data, size_t offset, size_t count) {
+    if (!buf || !host_data) return -1;
+
+    size_t elem = opencl_element_size(buf->precision);
+    size_t byte_offset = offset * elem;
+    size_t copy_size = count * elem;
+    if (byte_offset + copy_size > buf->size) {
+        opencl_set_error("write exceeds buffer size");
+        return -1;
+    }
+
+    cl_int err = clEnqueueWriteBuffer(q->queue, buf->mem, CL_TRUE, byte_offset, copy_size, host_data, 0, NULL, NULL);
     if (err != CL_SUCCESS) {
-        opencl_release_buffer(norms);
+        char msg[256];
+        snprintf(msg, sizeof(msg), "Failed to write buffer: %s", opencl_error_string(err));
+        opencl_set_cl_error(err, msg);
         return -1;
     }
+    return 0;
+}
+
+// Vector operations
 
+static int opencl_enqueue(OpenCLQueue* q, cl_kernel kernel, unsigned int n) {
     size_t global_size = n;
-    err = clEnqueueNDRangeKernel(dev->queue, dev->kernel_norms, 1, NULL, &global_size, NULL, 0, NULL, NULL);
+    cl_int err = clEnqueueNDRangeKernel(q->queue, kernel, 1, NULL, &global_size, NULL, 0, NULL, NULL);
     if (err != CL_SUCCESS) {
-        opencl_release_buffer(norms);
+        char msg[256];
+        snprintf(msg, sizeof(msg), "Failed to enqueue kernel: %s", opencl_error_string(err));
+        opencl_set_cl_error(err, msg);
         return -1;
     }
+    return 0;
+}
 
-    // Normalize vectors
-    err = clSetKernelArg(dev->kernel_normalize, 0, sizeof(cl_mem), &vectors->mem);
-    err |= clSetKernelArg(dev->kernel_normalize, 1, sizeof(cl_mem), &norms->mem);
-    err |= clSetKernelArg(dev->kernel_normalize, 2, sizeof(unsigned int), &n);
-    err |= clSetKernelArg(dev->kernel_normalize, 3, sizeof(unsigned int), &dims);
+static int opencl_args_failed(cl_int err) {
     if (err != CL_SUCCESS) {
+        char msg[256];
+        snprintf(msg, sizeof(msg), "Failed to set kernel args: %s", opencl_error_string(err));
+        opencl_set_cl_error(err, msg);
+        return 1;
+    }
+    return 0;
+}
+
+int opencl_normalize_vectors(OpenCLQueue* q, OpenCLBuffer* vectors, unsigned int n, unsigned int dims) {
+    cl_int err;
+
+    // Create norms buffer
+    OpenCLBuffer* norms = opencl_create_buffer(q->dev, NULL, n, 0);
+    if (!norms) return -1;
+
+    // Compute norms
+    err = clSetKernelArg(q->kernel_norms, 0, sizeof(cl_mem), &vectors->mem);
+    err |= clSetKernelArg(q->kernel_norms, 1, sizeof(cl_mem), &norms->mem);
+    err |= clSetKernelArg(q->kernel_norms, 2, sizeof(unsigned int), &n);
+    err |= clSetKernelArg(q->kernel_norms, 3, sizeof(unsigned int), &dims);
+    if (opencl_args_failed(err) || opencl_enqueue(q, q->kernel_norms, n) != 0) {
         opencl_release_buffer(norms);
         return -1;
     }
 
-    err = clEnqueueNDRangeKernel(dev->queue, dev->kernel_normalize, 1, NULL, &global_size, NULL, 0, NULL, NULL);
-    if (err != CL_SUCCESS) {
+    // Normalize vectors
+    err = clSetKernelArg(q->kernel_normalize, 0, sizeof(cl_mem), &vectors->mem);
+    err |= clSetKernelArg(q->kernel_normalize, 1, sizeof(cl_mem), &norms->mem);
+    err |= clSetKernelArg(q->kernel_normalize, 2, sizeof(unsigned int), &n);
+    err |= clSetKernelArg(q->kernel_normalize, 3, sizeof(unsigned int), &dims);
+    if (opencl_args_failed(err) || opencl_enqueue(q, q->kernel_normalize, n) != 0) {
         opencl_release_buffer(norms);
         return -1;
     }
 
-    clFinish(dev->queue);
+    clFinish(q->queue);
     opencl_release_buffer(norms);
     return 0;
 }
 
-int opencl_cosine_similarity(OpenCLDevice* dev, OpenCLBuffer* embeddings, OpenCLBuffer* query,
-                              OpenCLBuffer* scores, unsigned int n, unsigned int dims, int normalized) {
-    cl_int err;
-    cl_kernel kernel = normalized ? dev->kernel_cosine_normalized : dev->kernel_cosine;
+static int opencl_run_cosine(OpenCLQueue* q, cl_mem embeddings, cl_mem query, cl_mem scores,
+                             unsigned int n, unsigned int dims, int normalized) {
+    cl_kernel kernel = normalized ? q->kernel_cosine_normalized : q->kernel_cosine;
 
-    err = clSetKernelArg(kernel, 0, sizeof(cl_mem), &embeddings->mem);
-    err |= clSetKernelArg(kernel, 1, sizeof(cl_mem), &query->mem);
-    err |= clSetKernelArg(kernel, 2, sizeof(cl_mem), &scores->mem);
+    cl_int err = clSetKernelArg(kernel, 0, sizeof(cl_mem), &embeddings);
+    err |= clSetKernelArg(kernel, 1, sizeof(cl_mem), &query);
+    err |= clSetKernelArg(kernel, 2, sizeof(cl_mem), &scores);
     err |= clSetKernelArg(kernel, 3, sizeof(unsigned int), &n);
     err |= clSetKernelArg(kernel, 4, sizeof(unsigned int), &dims);
-    if (err != CL_SUCCESS) {
-        char msg[256];
-        snprintf(msg, sizeof(msg), "Failed to set kernel args: %s", opencl_error_string(err));
-        opencl_set_error(msg);
+    if (opencl_args_failed(err)) {
         return -1;
     }
+    return opencl_enqueue(q, kernel, n);
+}
 
-    size_t global_size = n;
-    err = clEnqueueNDRangeKernel(dev->queue, kernel, 1, NULL, &global_size, NULL, 0, NULL, NULL);
-    if (err != CL_SUCCESS) {
-        char msg[256];
-        snprintf(msg, sizeof(msg), "Failed to enqueue kernel: %s", opencl_error_string(err));
-        opencl_set_error(msg);
+int opencl_cosine_similarity(OpenCLQueue* q, OpenCLBuffer* embeddings, OpenCLBuffer* query,
+                              OpenCLBuffer* scores, unsigned int n, unsigned int dims, int normalized) {
+    if (opencl_run_cosine(q, embeddings->mem, query->mem, scores->mem, n, dims, normalized) != 0) {
         return -1;
     }
-
-    clFinish(dev->queue);
+    clFinish(q->queue);
     return 0;
 }
 
-int opencl_topk(OpenCLDevice* dev, OpenCLBuffer* scores, unsigned int* out_indices,
-                float* out_scores, unsigned int n, unsigned int k) {
-    // Copy scores to host (CPU top-k for simplicity - can be optimized with GPU radix sort)
-    float* host_scores = (float*)malloc(n * sizeof(float));
-    if (opencl_buffer_copy_to_host(scores, host_scores, n) != 0) {
-        free(host_scores);
+// Selects the k highest of n host scores, skipping rows that score below
+// min_score or whose bit in filter (a row bitset, or NULL for all rows) is
+// clear. Returns the number of results, fewer than k if not enough rows
+// qualify, or -1. CPU top-k for simplicity - can be optimized with GPU
+// radix sort.
+static int opencl_select_topk(const float* host_scores, unsigned int n, unsigned int k,
+                              float min_score, const uint64_t* filter,
+                              unsigned int* out_indices, float* out_scores) {
+    unsigned int* indices = (unsigned int*)malloc(n * sizeof(unsigned int));
+    if (!indices) {
+        opencl_set_error("Failed to allocate top-k indices");
         return -1;
     }
+    unsigned int m = 0;
+    for (unsigned int i = 0; i < n; i++) {
+        if (host_scores[i] < min_score) continue;
+        if (filter && !(filter[i / 64] & (1ULL << (i % 64)))) continue;
+        indices[m++] = i;
+    }
+    if (k > m) k = m;
 
     // Simple selection sort for top-k
-    unsigned int* indices = (unsigned int*)malloc(n * sizeof(unsigned int));
-    for (unsigned int i = 0; i < n; i++) indices[i] = i;
-
-    for (unsigned int i = 0; i < k && i < n; i++) {
+    for (unsigned int i = 0; i < k; i++) {
         unsigned int max_idx = i;
-        for (unsigned int j = i + 1; j < n; j++) {
+        for (unsigned int j = i + 1; j < m; j++) {
             if (host_scores[indices[j]] > host_scores[indices[max_idx]]) {
                 max_idx = j;
             }
@@ -577,13 +831,168 @@ int opencl_topk(OpenCLDevice* dev, OpenCLBuffer* scores, unsigned int* out_indic
     }
 
     // Copy top-k results
-    for (unsigned int i = 0; i < k && i < n; i++) {
+    for (unsigned int i = 0; i < k; i++) {
         out_indices[i] = indices[i];
         out_scores[i] = host_scores[indices[i]];
     }
 
-    free(host_scores);
     free(indices);
+    return (int)k;
+}
+
+int opencl_topk(OpenCLQueue* q, OpenCLBuffer* scores, unsigned int* out_indices,
+                float* out_scores, unsigned int n, unsigned int k) {
+    // Copy scores to host
+    float* host_scores = (float*)malloc(n * sizeof(float));
+    if (!host_scores) {
+        opencl_set_error("Failed to allocate host scores");
+        return -1;
+    }
+    if (opencl_buffer_copy_to_host(q, scores, host_scores, 0, n) != 0) {
+        free(host_scores);
+        return -1;
+    }
+
+    int found = opencl_select_topk(host_scores, n, k, -INFINITY, NULL, out_indices, out_scores);
+    free(host_scores);
+    return found < 0 ? -1 : 0;
+}
+
+// Grows a queue scratch buffer to at least size bytes.
+static int opencl_scratch(OpenCLQueue* q, cl_mem* mem, size_t* have, size_t size) {
+    if (*have >= size) return 0;
+    if (*mem) clReleaseMemObject(*mem);
+    *mem = NULL;
+    *have = 0;
+
+    cl_int err;
+    *mem = clCreateBuffer(q->dev->context, CL_MEM_READ_WRITE, size, NULL, &err);
+    if (err != CL_SUCCESS) {
+        char msg[256];
+        snprintf(msg, sizeof(msg), "Failed to create scratch buffer: %s", opencl_error_string(err));
+        opencl_set_cl_error(err, msg);
+        *mem = NULL;
+        return -1;
+    }
+    *have = size;
+    return 0;
+}
+
+// Complete similarity search on one queue: upload the query, score every
+// embedding and select the top k, reusing the queue's scratch buffers.
+// Returns the number of results, as opencl_select_topk does.
+int opencl_search(OpenCLQueue* q, OpenCLBuffer* embeddings, const float* host_query,
+                  unsigned int n, unsigned int dims, unsigned int k, int normalized,
+                  float min_score, const uint64_t* filter,
+                  unsigned int* out_indices, float* out_scores) {
+    size_t query_size = dims * sizeof(float);
+    size_t scores_size = n * sizeof(float);
+    size_t host_size = q->scores_size;
+    if (opencl_scratch(q, &q->query, &q->query_size, query_size) != 0 ||
+        opencl_scratch(q, &q->scores, &q->scores_size, scores_size) != 0) {
+        return -1;
+    }
+    if (!q->host_scores || host_size < q->scores_size) {
+        free(q->host_scores);
+        q->host_scores = (float*)malloc(q->scores_size);
+        if (!q->host_scores) {
+            opencl_set_error("Failed to allocate host scores");
+            return -1;
+        }
+    }
+
+    cl_int err = clEnqueueWriteBuffer(q->queue, q->query, CL_FALSE, 0, query_size, host_query, 0, NULL, NULL);
+    if (err != CL_SUCCESS) {
+        char msg[256];
+        snprintf(msg, sizeof(msg), "Failed to write query: %s", opencl_error_string(err));
+        opencl_set_cl_error(err, msg);
+        return -1;
+    }
+    if (opencl_run_cosine(q, embeddings->mem, q->query, q->scores, n, dims, normalized) != 0) {
+        return -1;
+    }
+    // The blocking read waits for the write and kernel on this queue.
+    if (opencl_read(q, q->scores, q->host_scores, 0, scores_size) != 0) {
+        return -1;
+    }
+    return opencl_select_topk(q->host_scores, n, k, min_score, filter, out_indices, out_scores);
+}
+// Similarity search for the nq row-major queries in host_queries on one
+// queue: a single 2-D launch scores every query against every embedding,
+// and one read brings all the scores back. Half-precision embeddings use
+// cosine_similarity_half_batch, which widens them in the kernel, and int8
+// embeddings cosine_similarity_int8_batch, which finds their scales at
+// scales_offset bytes. Query j's results go to out_indices and out_scores
+// at j*k, and their count to out_found[j]. Returns 0 or -1.
+int opencl_search_batch(OpenCLQueue* q, OpenCLBuffer* embeddings, const float* host_queries,
+                        size_t scales_offset, unsigned int nq, unsigned int n, unsigned int dims, unsigned int k,
+                        int normalized, float min_score, const uint64_t* filter,
+                        unsigned int* out_indices, float* out_scores, int* out_found) {
+    size_t queries_size = (size_t)nq * dims * sizeof(float);
+    size_t scores_size = (size_t)nq * n * sizeof(float);
+    size_t host_size = q->scores_size;
+    if (opencl_scratch(q, &q->query, &q->query_size, queries_size) != 0 ||
+        opencl_scratch(q, &q->scores, &q->scores_size, scores_size) != 0) {
+        return -1;
+    }
+    if (!q->host_scores || host_size < q->scores_size) {
+        free(q->host_scores);
+        q->host_scores = (float*)malloc(q->scores_size);
+        if (!q->host_scores) {
+            opencl_set_error("Failed to allocate host scores");
+            return -1;
+        }
+    }
+
+    cl_int err = clEnqueueWriteBuffer(q->queue, q->query, CL_FALSE, 0, queries_size, host_queries, 0, NULL, NULL);
+    if (err != CL_SUCCESS) {
+        char msg[256];
+        snprintf(msg, sizeof(msg), "Failed to write queries: %s", opencl_error_string(err));
+        opencl_set_cl_error(err, msg);
+        return -1;
+    }
+
+    int precision = embeddings->precision;
+    cl_kernel kernel = q->kernel_cosine_half_batch;
+    if (precision == 0) {
+        kernel = q->kernel_cosine_batch;
+    } else if (precision == 3) {
+        kernel = q->kernel_cosine_int8_batch;
+    }
+    err = clSetKernelArg(kernel, 0, sizeof(cl_mem), &embeddings->mem);
+    err |= clSetKernelArg(kernel, 1, sizeof(cl_mem), &q->query);
+    err |= clSetKernelArg(kernel, 2, sizeof(cl_mem), &q->scores);
+    err |= clSetKernelArg(kernel, 3, sizeof(unsigned int), &n);
+    err |= clSetKernelArg(kernel, 4, sizeof(unsigned int), &dims);
+    err |= clSetKernelArg(kernel, 5, sizeof(int), &normalized);
+    if (precision == 3) {
+        cl_ulong offset = scales_offset;
+        err |= clSetKernelArg(kernel, 6, sizeof(cl_ulong), &offset);
+    } else if (precision != 0) {
+        err |= clSetKernelArg(kernel, 6, sizeof(int), &precision);
+    }
+    if (opencl_args_failed(err)) {
+        return -1;
+    }
+    size_t global_size[2] = { n, nq };
+    err = clEnqueueNDRangeKernel(q->queue, kernel, 2, NULL, global_size, NULL, 0, NULL, NULL);
+    if (err != CL_SUCCESS) {
+        char msg[256];
+        snprintf(msg, sizeof(msg), "Failed to enqueue kernel: %s", opencl_error_string(err));
+        opencl_set_cl_error(err, msg);
+        return -1;
+    }
+    // The blocking read waits for the write and kernel on this queue.
+    if (opencl_read(q, q->scores, q->host_scores, 0, scores_size) != 0) {
+        return -1;
+    }
+
+    for (unsigned int j = 0; j < nq; j++) {
+        int found = opencl_select_topk(q->host_scores + (size_t)j * n, n, k, min_score, filter,
+                                       out_indices + (size_t)j * k, out_scores + (size_t)j * k);
+        if (found < 0) return -1;
+        out_found[j] = found;
+    }
     return 0;
 }
 */
@@ -592,34 +1001,77 @@ import "C"
 import (
 	"errors"
 	"fmt"
-	"sync"
+	"runtime"
+	"strings"
 	"unsafe"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+	"github.com/orneryd/nornicdb/pkg/gpu/lanes"
+	"github.com/orneryd/nornicdb/pkg/gpu/quant"
 )
 
 // Errors
 var (
-	ErrOpenCLNotAvailable = errors.New("opencl: OpenCL is not available on this system")
-	ErrDeviceCreation     = errors.New("opencl: failed to create OpenCL device")
-	ErrBufferCreation     = errors.New("opencl: failed to create buffer")
-	ErrKernelExecution    = errors.New("opencl: kernel execution failed")
+	ErrOpenCLNotAvailable = gpuerr.Sentinel("opencl: OpenCL is not available on this system", gpuerr.ErrNotAvailable)
+	ErrDeviceCreation     = gpuerr.Sentinel("opencl: failed to create OpenCL device", gpuerr.ErrNotAvailable)
+	ErrBufferCreation     = gpuerr.Sentinel("opencl: failed to create buffer", gpuerr.ErrOutOfMemory)
+	ErrKernelExecution    = gpuerr.Sentinel("opencl: kernel execution failed", gpuerr.ErrKernel)
 	ErrInvalidBuffer      = errors.New("opencl: invalid buffer")
+	ErrDeviceReleased     = errors.New("opencl: device released")
+	ErrOutOfRange         = errors.New("opencl: range exceeds buffer size")
+	ErrPrecision          = errors.New("opencl: operation does not support this buffer precision")
+
+	errReadFailed  = errors.New("opencl: read failed")
+	errWriteFailed = errors.New("opencl: write failed")
 )
 
+// lastError returns the pending native error as a failure of op and clears
+// it. The error matches op and, when the code is recognized, one of the
+// gpuerr kinds.
+func lastError(op error) error {
+	msg := C.GoString(C.opencl_get_last_error())
+	code := int(C.opencl_get_last_code())
+	C.opencl_clear_error()
+	return newError(op, code, msg)
+}
+
+// call runs fn, which makes native calls and reports success, and returns
+// the native error as a failure of op if it fails. Native error state is
+// thread-local, so the goroutine stays on its thread until it is read.
+func call(op error, fn func() bool) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if fn() {
+		return nil
+	}
+	return lastError(op)
+}
+
 // Device represents an OpenCL GPU device.
+//
+// A Device is safe for concurrent use. Operations run on a pool of command
+// queues, each with its own kernel objects and scratch buffers, so up to
+// Queues() searches execute concurrently; further callers wait for a free
+// queue.
 type Device struct {
 	ptr    *C.OpenCLDevice
 	id     int
 	name   string
 	vendor string
 	memory uint64
-	mu     sync.Mutex
+	queues *lanes.Pool[*C.OpenCLQueue]
 }
 
-// Buffer represents an OpenCL memory buffer.
+// Buffer represents an OpenCL memory buffer. Release waits for operations
+// using the buffer to finish.
 type Buffer struct {
-	ptr    *C.OpenCLBuffer
-	size   uint64
-	device *Device
+	ptr       *C.OpenCLBuffer
+	size      uint64
+	precision Precision
+	vectors   int // PrecisionInt8: vectors of dims components
+	dims      int
+	device    *Device
+	life      lanes.Guard
 }
 
 // SearchResult holds a similarity search result.
@@ -628,6 +1080,9 @@ type SearchResult struct {
 	Score float32
 }
 
+// Compiled reports whether this binary was built with OpenCL support.
+const Compiled = true
+
 // IsAvailable checks if OpenCL is available on this system.
 func IsAvailable() bool {
 	return C.opencl_is_available() != 0
@@ -642,17 +1097,77 @@ func DeviceCount() int {
 	return int(count)
 }
 
-// NewDevice creates a new OpenCL device handle.
+// UnavailableReason explains why IsAvailable returns false, for example
+// when no OpenCL ICD is installed. Returns "" when OpenCL is available.
+func UnavailableReason() string {
+	return C.GoString(C.opencl_unavailable_reason())
+}
+
+// QueryDevices returns the properties of every OpenCL GPU device without
+// creating a context or compiling kernels.
+func QueryDevices() []DeviceProperties {
+	count := DeviceCount()
+	devices := make([]DeviceProperties, 0, count)
+	for i := 0; i < count; i++ {
+		var props C.OpenCLDeviceProperties
+		if C.opencl_query_device(C.int(i), &props) != 0 {
+			continue
+		}
+		var extensions []string
+		if props.extensions != nil {
+			extensions = strings.Fields(C.GoString(props.extensions))
+			C.free(unsafe.Pointer(props.extensions))
+		}
+		devices = append(devices, DeviceProperties{
+			ID:               i,
+			Name:             C.GoString(&props.name[0]),
+			Vendor:           C.GoString(&props.vendor[0]),
+			DriverVersion:    C.GoString(&props.driver_version[0]),
+			MemoryBytes:      uint64(props.memory),
+			MaxWorkGroupSize: int(props.max_work_group_size),
+			Extensions:       extensions,
+		})
+	}
+	return devices
+}
+
+// NewDevice creates a new OpenCL device handle with the default number of
+// command queues.
 func NewDevice(deviceID int) (*Device, error) {
+	return NewDeviceWithQueues(deviceID, lanes.DefaultLanes())
+}
+
+// NewDeviceWithQueues creates a new OpenCL device handle that runs up to
+// queues operations concurrently.
+func NewDeviceWithQueues(deviceID, queues int) (*Device, error) {
 	if !IsAvailable() {
 		return nil, ErrOpenCLNotAvailable
 	}
+	queues = max(queues, 1)
 
-	ptr := C.opencl_create_device(C.int(deviceID))
-	if ptr == nil {
-		errMsg := C.GoString(C.opencl_get_last_error())
-		C.opencl_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrDeviceCreation, errMsg)
+	var ptr *C.OpenCLDevice
+	var pool []*C.OpenCLQueue
+	err := call(ErrDeviceCreation, func() bool {
+		if ptr = C.opencl_create_device(C.int(deviceID)); ptr == nil {
+			return false
+		}
+		for len(pool) < queues {
+			q := C.opencl_create_queue(ptr)
+			if q == nil {
+				return false
+			}
+			pool = append(pool, q)
+		}
+		return true
+	})
+	if err != nil {
+		for _, q := range pool {
+			C.opencl_release_queue(q)
+		}
+		if ptr != nil {
+			C.opencl_release_device(ptr)
+		}
+		return nil, err
 	}
 
 	return &Device{
@@ -661,20 +1176,27 @@ func NewDevice(deviceID int) (*Device, error) {
 		name:   C.GoString(C.opencl_device_name(ptr)),
 		vendor: C.GoString(C.opencl_device_vendor(ptr)),
 		memory: uint64(C.opencl_device_memory(ptr)),
+		queues: lanes.NewPool(pool),
 	}, nil
 }
 
-// Release frees the OpenCL device resources.
+// Release frees the OpenCL device resources after operations in progress
+// finish. Later operations return ErrDeviceReleased.
 func (d *Device) Release() {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	if d.ptr != nil {
+	released := d.queues.Close(func(q *C.OpenCLQueue) {
+		C.opencl_release_queue(q)
+	})
+	if released {
 		C.opencl_release_device(d.ptr)
 		d.ptr = nil
 	}
 }
 
+// Queues returns the number of operations the device runs concurrently.
+func (d *Device) Queues() int {
+	return d.queues.Len()
+}
+
 // ID returns the device ID.
 func (d *Device) ID() int {
 	return d.id
@@ -702,59 +1224,87 @@ func (d *Device) MemoryMB() int {
 
 // NewBuffer creates a new GPU buffer with data.
 func (d *Device) NewBuffer(data []float32) (*Buffer, error) {
+	return d.NewBufferWithPrecision(data, PrecisionFP32)
+}
+
+// NewBufferWithPrecision creates a new GPU buffer with data stored at
+// precision. Half-precision data is converted on upload and takes half the
+// memory; Search and SearchBatch widen it in the kernel and accumulate in
+// fp32, while NormalizeVectors, CosineSimilarity and TopK need fp32 buffers
+// and return ErrPrecision otherwise.
+func (d *Device) NewBufferWithPrecision(data []float32, precision Precision) (*Buffer, error) {
 	if len(data) == 0 {
 		return nil, errors.New("opencl: cannot create empty buffer")
 	}
-
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	ptr := C.opencl_create_buffer(
-		d.ptr,
-		(*C.float)(unsafe.Pointer(&data[0])),
-		C.size_t(len(data)),
-	)
-
-	if ptr == nil {
-		errMsg := C.GoString(C.opencl_get_last_error())
-		C.opencl_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrBufferCreation, errMsg)
+	if err := precision.check(); err != nil {
+		return nil, err
+	}
+	if precision == PrecisionInt8 {
+		return nil, fmt.Errorf("%w: int8 buffers are made with NewQuantizedBuffer", ErrPrecision)
 	}
+	if precision == PrecisionFP32 {
+		return d.newBuffer(unsafe.Pointer(&data[0]), uint64(len(data)), precision)
+	}
+	packed := precision.encode(data)
+	return d.newBuffer(unsafe.Pointer(&packed[0]), uint64(len(packed)), precision)
+}
 
-	return &Buffer{
-		ptr:    ptr,
-		size:   uint64(len(data) * 4),
-		device: d,
-	}, nil
+// NewQuantizedBuffer creates a PrecisionInt8 buffer holding the row-major
+// vectors of dimensions components in data as int8 codes with a scale per
+// vector (see package quant), about a quarter of the device memory of
+// float32. Search and SearchBatch dequantize the codes in the kernel and
+// score in fp32; ReadAt and WriteAt work on whole vectors.
+func (d *Device) NewQuantizedBuffer(data []float32, dimensions uint32) (*Buffer, error) {
+	if len(data) == 0 {
+		return nil, errors.New("opencl: cannot create empty buffer")
+	}
+	if dimensions == 0 || len(data)%int(dimensions) != 0 {
+		return nil, fmt.Errorf("opencl: %d elements are not vectors of %d dimensions", len(data), dimensions)
+	}
+	image := quant.Pack(data, int(dimensions))
+	b, err := d.newBuffer(unsafe.Pointer(&image[0]), uint64(len(image)), PrecisionInt8)
+	if err != nil {
+		return nil, err
+	}
+	b.vectors = len(data) / int(dimensions)
+	b.dims = int(dimensions)
+	return b, nil
 }
 
 // NewEmptyBuffer creates an uninitialized GPU buffer.
 func (d *Device) NewEmptyBuffer(count uint64) (*Buffer, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	return d.newBuffer(nil, count, PrecisionFP32)
+}
 
-	ptr := C.opencl_create_buffer(
-		d.ptr,
-		nil,
-		C.size_t(count),
-	)
+func (d *Device) newBuffer(data unsafe.Pointer, count uint64, precision Precision) (*Buffer, error) {
+	// Buffers belong to the context, not a queue; holding a queue only keeps
+	// the device alive while the buffer is created.
+	q, ok := d.queues.Acquire()
+	if !ok {
+		return nil, ErrDeviceReleased
+	}
+	defer d.queues.Release(q)
 
-	if ptr == nil {
-		errMsg := C.GoString(C.opencl_get_last_error())
-		C.opencl_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrBufferCreation, errMsg)
+	var ptr *C.OpenCLBuffer
+	err := call(ErrBufferCreation, func() bool {
+		ptr = C.opencl_create_buffer(d.ptr, data, C.size_t(count), C.int(precision))
+		return ptr != nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &Buffer{
-		ptr:    ptr,
-		size:   count * 4,
-		device: d,
+		ptr:       ptr,
+		size:      count * precision.elementSize(),
+		precision: precision,
+		device:    d,
 	}, nil
 }
 
-// Release frees the buffer resources.
+// Release frees the buffer resources once operations using it finish.
 func (b *Buffer) Release() {
-	if b.ptr != nil {
+	if b.life.Close() && b.ptr != nil {
 		C.opencl_release_buffer(b.ptr)
 		b.ptr = nil
 	}
@@ -765,113 +1315,294 @@ func (b *Buffer) Size() uint64 {
 	return b.size
 }
 
-// ReadFloat32 reads float32 values from the buffer.
+// Precision returns how the buffer stores its elements.
+func (b *Buffer) Precision() Precision {
+	return b.precision
+}
+
+// requireFP32 returns ErrPrecision if any of buffers stores half precision
+// or int8.
+func requireFP32(buffers ...*Buffer) error {
+	for _, b := range buffers {
+		if b != nil && b.precision != PrecisionFP32 {
+			return fmt.Errorf("%w: %s", ErrPrecision, b.precision)
+		}
+	}
+	return nil
+}
+
+// use marks the start of an operation on each buffer and borrows a queue
+// for it. The returned function ends the operation.
+func (d *Device) use(buffers ...*Buffer) (*C.OpenCLQueue, func(), error) {
+	for i, b := range buffers {
+		if b == nil || !b.life.Use() {
+			for _, held := range buffers[:i] {
+				held.life.Done()
+			}
+			return nil, nil, ErrInvalidBuffer
+		}
+	}
+	q, ok := d.queues.Acquire()
+	if !ok {
+		for _, b := range buffers {
+			b.life.Done()
+		}
+		return nil, nil, ErrDeviceReleased
+	}
+	return q, func() {
+		d.queues.Release(q)
+		for _, b := range buffers {
+			b.life.Done()
+		}
+	}, nil
+}
+
+// ReadFloat32 reads the first count float32 values from the buffer. It
+// returns nil on failure; use ReadAt to get the error.
 func (b *Buffer) ReadFloat32(count int) []float32 {
-	if count <= 0 || uint64(count*4) > b.size {
+	result, err := b.ReadAt(0, count)
+	if err != nil {
 		return nil
 	}
+	return result
+}
+
+// ReadAt reads count float32 values starting at element offset. int8
+// elements are dequantized; their range must cover whole vectors.
+func (b *Buffer) ReadAt(offset, count int) ([]float32, error) {
+	if err := checkRange(b, offset, count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return []float32{}, nil
+	}
+	q, done, err := b.device.use(b)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if b.precision == PrecisionInt8 {
+		scalesAt, scalesLen, err := int8Range(offset, count, b.vectors, b.dims)
+		if err != nil {
+			return nil, err
+		}
+		codes := make([]int8, count)
+		scales := make([]float32, scalesLen/4)
+		err = call(errReadFailed, func() bool {
+			return C.opencl_buffer_copy_to_host(q, b.ptr, unsafe.Pointer(&codes[0]), C.size_t(offset), C.size_t(count)) == 0 &&
+				C.opencl_buffer_copy_to_host(q, b.ptr, unsafe.Pointer(&scales[0]), C.size_t(scalesAt), C.size_t(scalesLen)) == 0
+		})
+		if err != nil {
+			return nil, err
+		}
+		result := make([]float32, count)
+		quant.Dequantize(result, codes, scales, b.dims)
+		return result, nil
+	}
+	if b.precision != PrecisionFP32 {
+		packed := make([]uint16, count)
+		err = call(errReadFailed, func() bool {
+			return C.opencl_buffer_copy_to_host(q, b.ptr, unsafe.Pointer(&packed[0]), C.size_t(offset), C.size_t(count)) == 0
+		})
+		if err != nil {
+			return nil, err
+		}
+		return b.precision.decode(packed), nil
+	}
 
 	result := make([]float32, count)
-	ret := C.opencl_buffer_copy_to_host(b.ptr, (*C.float)(unsafe.Pointer(&result[0])), C.size_t(count))
-	if ret != 0 {
+	err = call(errReadFailed, func() bool {
+		return C.opencl_buffer_copy_to_host(q, b.ptr, unsafe.Pointer(&result[0]), C.size_t(offset), C.size_t(count)) == 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// WriteAt copies data into the buffer starting at element offset, so part
+// of a buffer can be updated without uploading all of it again. Data is
+// converted to the buffer's precision; int8 data must be whole vectors,
+// which are quantized.
+func (b *Buffer) WriteAt(offset int, data []float32) error {
+	if err := checkRange(b, offset, len(data)); err != nil {
+		return err
+	}
+	if len(data) == 0 {
 		return nil
 	}
-	return result
+	q, done, err := b.device.use(b)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if b.precision == PrecisionInt8 {
+		scalesAt, scalesLen, err := int8Range(offset, len(data), b.vectors, b.dims)
+		if err != nil {
+			return err
+		}
+		codes := make([]int8, len(data))
+		scales := make([]float32, scalesLen/4)
+		quant.Quantize(codes, scales, data, b.dims)
+		return call(errWriteFailed, func() bool {
+			return C.opencl_buffer_copy_from_host(q, b.ptr, unsafe.Pointer(&codes[0]), C.size_t(offset), C.size_t(len(codes))) == 0 &&
+				C.opencl_buffer_copy_from_host(q, b.ptr, unsafe.Pointer(&scales[0]), C.size_t(scalesAt), C.size_t(scalesLen)) == 0
+		})
+	}
+
+	src := unsafe.Pointer(&data[0])
+	if b.precision != PrecisionFP32 {
+		packed := b.precision.encode(data)
+		src = unsafe.Pointer(&packed[0])
+	}
+	return call(errWriteFailed, func() bool {
+		return C.opencl_buffer_copy_from_host(q, b.ptr, src, C.size_t(offset), C.size_t(len(data))) == 0
+	})
+}
+
+// checkRange returns ErrOutOfRange unless elements [offset, offset+count)
+// lie within b.
+func checkRange(b *Buffer, offset, count int) error {
+	if b == nil {
+		return ErrInvalidBuffer
+	}
+	elements := b.size / b.precision.elementSize()
+	if b.precision == PrecisionInt8 {
+		elements = uint64(b.vectors * b.dims)
+	}
+	if offset < 0 || count < 0 || uint64(offset+count) > elements {
+		return fmt.Errorf("%w: elements [%d, %d) of %d", ErrOutOfRange, offset, offset+count, elements)
+	}
+	return nil
 }
 
 // NormalizeVectors normalizes vectors in-place to unit length.
 func (d *Device) NormalizeVectors(vectors *Buffer, n, dimensions uint32) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	ret := C.opencl_normalize_vectors(d.ptr, vectors.ptr, C.uint(n), C.uint(dimensions))
-	if ret != 0 {
-		errMsg := C.GoString(C.opencl_get_last_error())
-		C.opencl_clear_error()
-		return fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
+	if err := requireFP32(vectors); err != nil {
+		return err
 	}
-	return nil
+	q, done, err := d.use(vectors)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return call(ErrKernelExecution, func() bool {
+		return C.opencl_normalize_vectors(q, vectors.ptr, C.uint(n), C.uint(dimensions)) == 0
+	})
 }
 
 // CosineSimilarity computes cosine similarity between query and all embeddings.
 func (d *Device) CosineSimilarity(embeddings, query, scores *Buffer,
 	n, dimensions uint32, normalized bool) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	if err := requireFP32(embeddings, query, scores); err != nil {
+		return err
+	}
+	q, done, err := d.use(embeddings, query, scores)
+	if err != nil {
+		return err
+	}
+	defer done()
 
 	normalizedInt := 0
 	if normalized {
 		normalizedInt = 1
 	}
 
-	ret := C.opencl_cosine_similarity(d.ptr, embeddings.ptr, query.ptr, scores.ptr,
-		C.uint(n), C.uint(dimensions), C.int(normalizedInt))
-	if ret != 0 {
-		errMsg := C.GoString(C.opencl_get_last_error())
-		C.opencl_clear_error()
-		return fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
-	}
-	return nil
+	return call(ErrKernelExecution, func() bool {
+		return C.opencl_cosine_similarity(q, embeddings.ptr, query.ptr, scores.ptr,
+			C.uint(n), C.uint(dimensions), C.int(normalizedInt)) == 0
+	})
 }
 
 // TopK finds the k highest scoring indices.
 func (d *Device) TopK(scores *Buffer, n, k uint32) ([]uint32, []float32, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	if err := requireFP32(scores); err != nil {
+		return nil, nil, err
+	}
+	q, done, err := d.use(scores)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
 
 	indices := make([]uint32, k)
 	topkScores := make([]float32, k)
 
-	ret := C.opencl_topk(d.ptr, scores.ptr,
-		(*C.uint)(unsafe.Pointer(&indices[0])),
-		(*C.float)(unsafe.Pointer(&topkScores[0])),
-		C.uint(n), C.uint(k))
-	if ret != 0 {
-		errMsg := C.GoString(C.opencl_get_last_error())
-		C.opencl_clear_error()
-		return nil, nil, fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
+	err = call(ErrKernelExecution, func() bool {
+		return C.opencl_topk(q, scores.ptr,
+			(*C.uint)(unsafe.Pointer(&indices[0])),
+			(*C.float)(unsafe.Pointer(&topkScores[0])),
+			C.uint(n), C.uint(k)) == 0
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return indices, topkScores, nil
 }
 
-// Search performs a complete similarity search.
-func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, normalized bool) ([]SearchResult, error) {
+// Search performs a complete similarity search on one queue and returns up
+// to k results, best first; see SearchOptions. The query and score buffers are per-queue scratch memory, so concurrent searches do not
+// allocate device memory. Half-precision and int8 embeddings are searched
+// as a batch of one, whose kernel widens them.
+func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, opts SearchOptions) ([]SearchResult, error) {
 	if k <= 0 {
 		return nil, nil
 	}
 	if k > int(n) {
 		k = int(n)
 	}
-
-	// Create query buffer
-	queryBuf, err := d.NewBuffer(query)
+	if len(query) < int(dimensions) {
+		return nil, fmt.Errorf("opencl: query has %d dimensions, want %d", len(query), dimensions)
+	}
+	dot, minScore, err := opts.params(n)
 	if err != nil {
 		return nil, err
 	}
-	defer queryBuf.Release()
+	if embeddings != nil && embeddings.precision != PrecisionFP32 {
+		results, err := d.SearchBatch(embeddings, [][]float32{query}, n, dimensions, k, opts)
+		if err != nil {
+			return nil, err
+		}
+		return results[0], nil
+	}
 
-	// Create scores buffer
-	scoresBuf, err := d.NewEmptyBuffer(uint64(n))
+	q, done, err := d.use(embeddings)
 	if err != nil {
 		return nil, err
 	}
-	defer scoresBuf.Release()
+	defer done()
 
-	// Compute similarities
-	if err := d.CosineSimilarity(embeddings, queryBuf, scoresBuf, n, dimensions, normalized); err != nil {
-		return nil, err
+	normalizedInt := 0
+	if dot {
+		normalizedInt = 1
+	}
+	var filter *C.uint64_t
+	if opts.Filter != nil {
+		filter = (*C.uint64_t)(unsafe.Pointer(&opts.Filter[0]))
 	}
 
-	// Find top-k
-	indices, scores, err := d.TopK(scoresBuf, n, uint32(k))
+	indices := make([]uint32, k)
+	scores := make([]float32, k)
+	var found C.int
+	err = call(ErrKernelExecution, func() bool {
+		found = C.opencl_search(q, embeddings.ptr, (*C.float)(unsafe.Pointer(&query[0])),
+			C.uint(n), C.uint(dimensions), C.uint(k), C.int(normalizedInt),
+			C.float(minScore), filter,
+			(*C.uint)(unsafe.Pointer(&indices[0])),
+			(*C.float)(unsafe.Pointer(&scores[0])))
+		return found >= 0
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	// Build results
-	results := make([]SearchResult, k)
-	for i := 0; i < k; i++ {
+	results := make([]SearchResult, found)
+	for i := range results {
 		results[i] = SearchResult{
 			Index: indices[i],
 			Score: scores[i],
@@ -880,3 +1611,62 @@ func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint3
 
 	return results, nil
 }
+
+// SearchBatch searches for every query on one queue and returns up to k
+// results for each, best first, in query order; opts applies to all of
+// them. A single 2-D kernel launch scores the whole batch and one read
+// brings the scores back, instead of a launch and a read per query.
+// Half-precision embeddings are widened and int8 embeddings dequantized in
+// the kernel, and scored in fp32.
+func (d *Device) SearchBatch(embeddings *Buffer, queries [][]float32, n, dimensions uint32, k int, opts SearchOptions) ([][]SearchResult, error) {
+	if k <= 0 || len(queries) == 0 {
+		return make([][]SearchResult, len(queries)), nil
+	}
+	if k > int(n) {
+		k = int(n)
+	}
+	packed, err := packQueries(queries, n, dimensions, opts)
+	if err != nil {
+		return nil, err
+	}
+	var scalesOffset int
+	if embeddings != nil && embeddings.precision == PrecisionInt8 {
+		if int(dimensions) != embeddings.dims {
+			return nil, fmt.Errorf("opencl: int8 buffer holds vectors of %d dimensions, not %d", embeddings.dims, dimensions)
+		}
+		scalesOffset = quant.ScalesOffset(embeddings.vectors * embeddings.dims)
+	}
+	dot, minScore, _ := opts.params(n)
+
+	q, done, err := d.use(embeddings)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	normalizedInt := 0
+	if dot {
+		normalizedInt = 1
+	}
+	var filter *C.uint64_t
+	if opts.Filter != nil {
+		filter = (*C.uint64_t)(unsafe.Pointer(&opts.Filter[0]))
+	}
+
+	indices := make([]uint32, len(queries)*k)
+	scores := make([]float32, len(queries)*k)
+	found := make([]int32, len(queries))
+	err = call(ErrKernelExecution, func() bool {
+		return C.opencl_search_batch(q, embeddings.ptr, (*C.float)(unsafe.Pointer(&packed[0])),
+			C.size_t(scalesOffset),
+			C.uint(len(queries)), C.uint(n), C.uint(dimensions), C.uint(k), C.int(normalizedInt),
+			C.float(minScore), filter,
+			(*C.uint)(unsafe.Pointer(&indices[0])),
+			(*C.float)(unsafe.Pointer(&scores[0])),
+			(*C.int)(unsafe.Pointer(&found[0]))) == 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unpackBatch(indices, scores, found, k), nil
+}
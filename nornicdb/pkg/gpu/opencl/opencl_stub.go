@@ -7,15 +7,20 @@ package opencl
 
 import (
 	"errors"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
 )
 
 // Errors
 var (
-	ErrOpenCLNotAvailable = errors.New("opencl: OpenCL is not available (build without opencl tag)")
-	ErrDeviceCreation     = errors.New("opencl: failed to create OpenCL device")
-	ErrBufferCreation     = errors.New("opencl: failed to create buffer")
-	ErrKernelExecution    = errors.New("opencl: kernel execution failed")
+	ErrOpenCLNotAvailable = gpuerr.Sentinel("opencl: OpenCL is not available (build without opencl tag)", gpuerr.ErrNotAvailable)
+	ErrDeviceCreation     = gpuerr.Sentinel("opencl: failed to create OpenCL device", gpuerr.ErrNotAvailable)
+	ErrBufferCreation     = gpuerr.Sentinel("opencl: failed to create buffer", gpuerr.ErrOutOfMemory)
+	ErrKernelExecution    = gpuerr.Sentinel("opencl: kernel execution failed", gpuerr.ErrKernel)
 	ErrInvalidBuffer      = errors.New("opencl: invalid buffer")
+	ErrDeviceReleased     = errors.New("opencl: device released")
+	ErrOutOfRange         = errors.New("opencl: range exceeds buffer size")
+	ErrPrecision          = errors.New("opencl: operation does not support this buffer precision")
 )
 
 // Device represents an OpenCL GPU device (stub).
@@ -30,6 +35,9 @@ type SearchResult struct {
 	Score float32
 }
 
+// Compiled reports whether this binary was built with OpenCL support.
+const Compiled = false
+
 // IsAvailable returns false on systems without OpenCL.
 func IsAvailable() bool {
 	return false
@@ -40,14 +48,32 @@ func DeviceCount() int {
 	return 0
 }
 
+// UnavailableReason explains why OpenCL cannot be used by this binary.
+func UnavailableReason() string {
+	return "binary built without OpenCL support; build with -tags opencl"
+}
+
+// QueryDevices returns nil on systems without OpenCL.
+func QueryDevices() []DeviceProperties {
+	return nil
+}
+
 // NewDevice returns an error on systems without OpenCL.
 func NewDevice(deviceID int) (*Device, error) {
 	return nil, ErrOpenCLNotAvailable
 }
 
+// NewDeviceWithQueues returns an error on systems without OpenCL.
+func NewDeviceWithQueues(deviceID, queues int) (*Device, error) {
+	return nil, ErrOpenCLNotAvailable
+}
+
 // Release is a no-op stub.
 func (d *Device) Release() {}
 
+// Queues returns 0.
+func (d *Device) Queues() int { return 0 }
+
 // ID returns 0.
 func (d *Device) ID() int { return 0 }
 
@@ -68,6 +94,16 @@ func (d *Device) NewBuffer(data []float32) (*Buffer, error) {
 	return nil, ErrOpenCLNotAvailable
 }
 
+// NewBufferWithPrecision returns an error.
+func (d *Device) NewBufferWithPrecision(data []float32, precision Precision) (*Buffer, error) {
+	return nil, ErrOpenCLNotAvailable
+}
+
+// NewQuantizedBuffer returns an error.
+func (d *Device) NewQuantizedBuffer(data []float32, dimensions uint32) (*Buffer, error) {
+	return nil, ErrOpenCLNotAvailable
+}
+
 // NewEmptyBuffer returns an error.
 func (d *Device) NewEmptyBuffer(count uint64) (*Buffer, error) {
 	return nil, ErrOpenCLNotAvailable
@@ -79,9 +115,22 @@ func (b *Buffer) Release() {}
 // Size returns 0.
 func (b *Buffer) Size() uint64 { return 0 }
 
+// Precision returns PrecisionFP32.
+func (b *Buffer) Precision() Precision { return PrecisionFP32 }
+
 // ReadFloat32 returns nil.
 func (b *Buffer) ReadFloat32(count int) []float32 { return nil }
 
+// ReadAt returns an error.
+func (b *Buffer) ReadAt(offset, count int) ([]float32, error) {
+	return nil, ErrOpenCLNotAvailable
+}
+
+// WriteAt returns an error.
+func (b *Buffer) WriteAt(offset int, data []float32) error {
+	return ErrOpenCLNotAvailable
+}
+
 // NormalizeVectors returns an error.
 func (d *Device) NormalizeVectors(vectors *Buffer, n, dimensions uint32) error {
 	return ErrOpenCLNotAvailable
@@ -98,6 +147,11 @@ func (d *Device) TopK(scores *Buffer, n, k uint32) ([]uint32, []float32, error)
 }
 
 // Search returns an error.
-func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, normalized bool) ([]SearchResult, error) {
+func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, opts SearchOptions) ([]SearchResult, error) {
+	return nil, ErrOpenCLNotAvailable
+}
+
+// SearchBatch returns an error.
+func (d *Device) SearchBatch(embeddings *Buffer, queries [][]float32, n, dimensions uint32, k int, opts SearchOptions) ([][]SearchResult, error) {
 	return nil, ErrOpenCLNotAvailable
 }
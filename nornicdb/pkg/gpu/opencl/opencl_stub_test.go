@@ -62,6 +62,12 @@ func TestBufferMethodsStub(t *testing.T) {
 	if buffer.ReadFloat32(10) != nil {
 		t.Error("ReadFloat32() should return nil")
 	}
+	if _, err := buffer.ReadAt(0, 10); err == nil {
+		t.Error("ReadAt() should return error")
+	}
+	if err := buffer.WriteAt(0, []float32{1}); err == nil {
+		t.Error("WriteAt() should return error")
+	}
 }
 
 func TestDeviceBufferCreationStub(t *testing.T) {
@@ -71,7 +77,17 @@ func TestDeviceBufferCreationStub(t *testing.T) {
 	if err != ErrOpenCLNotAvailable {
 		t.Errorf("NewBuffer() error = %v, want ErrOpenCLNotAvailable", err)
 	}
+
+	_, err = device.NewBufferWithPrecision([]float32{1.0}, PrecisionFP16)
+	if err != ErrOpenCLNotAvailable {
+		t.Errorf("NewBufferWithPrecision() error = %v, want ErrOpenCLNotAvailable", err)
+	}
 	
+	_, err = device.NewQuantizedBuffer([]float32{1.0}, 1)
+	if err != ErrOpenCLNotAvailable {
+		t.Errorf("NewQuantizedBuffer() error = %v, want ErrOpenCLNotAvailable", err)
+	}
+
 	_, err = device.NewEmptyBuffer(100)
 	if err != ErrOpenCLNotAvailable {
 		t.Errorf("NewEmptyBuffer() error = %v, want ErrOpenCLNotAvailable", err)
@@ -97,7 +113,7 @@ func TestDeviceOperationsStub(t *testing.T) {
 		t.Errorf("TopK() error = %v, want ErrOpenCLNotAvailable", err)
 	}
 	
-	_, err = device.Search(&buffer, []float32{1.0}, 10, 1, 5, true)
+	_, err = device.Search(&buffer, []float32{1.0}, 10, 1, 5, SearchOptions{Normalized: true})
 	if err != ErrOpenCLNotAvailable {
 		t.Errorf("Search() error = %v, want ErrOpenCLNotAvailable", err)
 	}
@@ -0,0 +1,12 @@
+package opencl
+
+// DeviceProperties describes an OpenCL GPU device.
+type DeviceProperties struct {
+	ID               int
+	Name             string
+	Vendor           string
+	DriverVersion    string
+	MemoryBytes      uint64
+	MaxWorkGroupSize int
+	Extensions       []string
+}
@@ -0,0 +1,265 @@
+package gpu
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/cuda"
+	"github.com/orneryd/nornicdb/pkg/gpu/metal"
+)
+
+// MinGPUPairGroup is the smallest number of candidates sharing one query
+// vector that ScorePairs sends to the GPU. Smaller groups are cheaper to
+// score on the CPU than to upload.
+const MinGPUPairGroup = 256
+
+// Pair is a query/candidate vector pair to score.
+//
+// Pairs that share the same Query slice (same backing array) are scored
+// together in a single kernel launch, so callers scoring a candidate pool
+// against one source embedding should reuse the slice rather than copy it.
+type Pair struct {
+	Query     []float32
+	Candidate []float32
+}
+
+// pairDevices holds the GPU devices used by ScorePairs.
+type pairDevices struct {
+	mu    sync.Mutex
+	cuda  *cuda.Device
+	metal *metal.Device
+}
+
+// ScorePairs computes the cosine similarity of every pair.
+//
+// Pairs are grouped by query vector. Groups of at least MinGPUPairGroup
+// candidates run as one cosine-similarity kernel on the manager's CUDA or
+// Metal device; everything else, and any group whose kernel fails, is scored
+// on the CPU across all cores. A nil or disabled manager scores everything on
+// the CPU.
+//
+// When the manager's config disables FallbackOnError, a failed kernel returns
+// ErrKernelFailed instead of falling back.
+//
+// Parameters:
+//   - m: GPU manager (may be nil)
+//   - pairs: vectors to score; pairs with mismatched dimensions score 0
+//
+// Returns:
+//   - Similarity per pair, in input order
+//   - ErrKernelFailed if a kernel fails and CPU fallback is disabled
+//
+// Example:
+//
+//	pairs := make([]gpu.Pair, len(candidates))
+//	for i, c := range candidates {
+//		pairs[i] = gpu.Pair{Query: source, Candidate: c}
+//	}
+//	scores, err := gpu.ScorePairs(manager, pairs)
+func ScorePairs(m *Manager, pairs []Pair) ([]float32, error) {
+	scores := make([]float32, len(pairs))
+	if len(pairs) == 0 {
+		return scores, nil
+	}
+
+	var cpu []int
+	if m != nil && m.IsEnabled() && m.device != nil {
+		for _, group := range groupPairs(pairs) {
+			if len(group) >= MinGPUPairGroup {
+				err := m.scoreGroupGPU(pairs, group, scores)
+				if err == nil {
+					continue
+				}
+				if err != errNoPairKernel && m.config != nil && !m.config.FallbackOnError {
+					return nil, ErrKernelFailed
+				}
+			}
+			cpu = append(cpu, group...)
+		}
+	} else {
+		cpu = make([]int, len(pairs))
+		for i := range cpu {
+			cpu[i] = i
+		}
+	}
+
+	if len(cpu) > 0 {
+		scorePairsCPU(pairs, cpu, scores)
+		if m != nil {
+			atomic.AddInt64(&m.stats.OperationsCPU, 1)
+		}
+	}
+	return scores, nil
+}
+
+// groupPairs returns pair indices grouped by query vector, in order of first
+// appearance. Pairs whose dimensions do not match their query get their own
+// group so they never reach a kernel.
+func groupPairs(pairs []Pair) [][]int {
+	var groups [][]int
+	byQuery := make(map[*float32]int)
+	for i, p := range pairs {
+		if len(p.Query) == 0 || len(p.Candidate) != len(p.Query) {
+			groups = append(groups, []int{i})
+			continue
+		}
+		key := &p.Query[0]
+		g, ok := byQuery[key]
+		if !ok {
+			g = len(groups)
+			byQuery[key] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
+
+// errNoPairKernel means the active backend has no pair-scoring kernel.
+var errNoPairKernel = errors.New("gpu: no pair scoring kernel for backend")
+
+// scoreGroupGPU scores one query group on the GPU. On error the group must be
+// scored on the CPU instead.
+func (m *Manager) scoreGroupGPU(pairs []Pair, group []int, scores []float32) error {
+	query := pairs[group[0]].Query
+	dims := len(query)
+	flat := make([]float32, 0, len(group)*dims)
+	for _, i := range group {
+		flat = append(flat, pairs[i].Candidate...)
+	}
+
+	var result []float32
+	var err error
+	switch m.device.Backend {
+	case BackendCUDA:
+		result, err = m.cosineCUDA(flat, query, len(group))
+	case BackendMetal:
+		result, err = m.cosineMetal(flat, query, len(group))
+	default:
+		return errNoPairKernel
+	}
+	if err == nil && len(result) < len(group) {
+		err = ErrKernelFailed
+	}
+	if err != nil {
+		atomic.AddInt64(&m.stats.FallbackCount, 1)
+		return err
+	}
+
+	for j, i := range group {
+		scores[i] = result[j]
+	}
+	atomic.AddInt64(&m.stats.OperationsGPU, 1)
+	atomic.AddInt64(&m.stats.KernelExecutions, 1)
+	atomic.AddInt64(&m.stats.BytesTransferred, int64(len(flat)+len(query)+len(group))*4)
+	return nil
+}
+
+// cosineCUDA runs the cosine-similarity kernel on the CUDA device.
+func (m *Manager) cosineCUDA(flat, query []float32, n int) ([]float32, error) {
+	m.pairs.mu.Lock()
+	defer m.pairs.mu.Unlock()
+
+	if m.pairs.cuda == nil {
+		deviceID := 0
+		if m.config != nil {
+			deviceID = m.config.DeviceID
+		}
+		device, err := cuda.NewDevice(deviceID)
+		if err != nil {
+			return nil, err
+		}
+		m.pairs.cuda = device
+	}
+	device := m.pairs.cuda
+
+	embeddings, err := device.NewBuffer(flat, cuda.MemoryDevice)
+	if err != nil {
+		return nil, err
+	}
+	defer embeddings.Release()
+	queryBuf, err := device.NewBuffer(query, cuda.MemoryDevice)
+	if err != nil {
+		return nil, err
+	}
+	defer queryBuf.Release()
+	scoresBuf, err := device.NewEmptyBuffer(uint64(n), cuda.MemoryDevice)
+	if err != nil {
+		return nil, err
+	}
+	defer scoresBuf.Release()
+
+	if err := device.CosineSimilarity(embeddings, queryBuf, scoresBuf, uint32(n), uint32(len(query)), false); err != nil {
+		return nil, err
+	}
+	return scoresBuf.ReadFloat32(n), nil
+}
+
+// cosineMetal runs the cosine-similarity kernel on the Metal device.
+func (m *Manager) cosineMetal(flat, query []float32, n int) ([]float32, error) {
+	m.pairs.mu.Lock()
+	defer m.pairs.mu.Unlock()
+
+	if m.pairs.metal == nil {
+		device, err := metal.NewDevice()
+		if err != nil {
+			return nil, err
+		}
+		m.pairs.metal = device
+	}
+	device := m.pairs.metal
+
+	embeddings, err := device.NewBuffer(flat, metal.StorageShared)
+	if err != nil {
+		return nil, err
+	}
+	defer embeddings.Release()
+	queryBuf, err := device.NewBuffer(query, metal.StorageShared)
+	if err != nil {
+		return nil, err
+	}
+	defer queryBuf.Release()
+	scoresBuf, err := device.NewEmptyBuffer(uint64(n*4), metal.StorageShared)
+	if err != nil {
+		return nil, err
+	}
+	defer scoresBuf.Release()
+
+	if err := device.ComputeCosineSimilarity(embeddings, queryBuf, scoresBuf, uint32(n), uint32(len(query)), false); err != nil {
+		return nil, err
+	}
+	return scoresBuf.ReadFloat32(n), nil
+}
+
+// scorePairsCPU scores the given pair indices, split across all cores.
+func scorePairsCPU(pairs []Pair, indices []int, scores []float32) {
+	workers := runtime.NumCPU()
+	if chunks := len(indices) / MinGPUPairGroup; chunks < workers {
+		workers = chunks
+	}
+	if workers <= 1 {
+		for _, i := range indices {
+			scores[i] = cosineSimilarityFlat(pairs[i].Query, pairs[i].Candidate)
+		}
+		return
+	}
+
+	chunk := (len(indices) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(indices); start += chunk {
+		end := start + chunk
+		if end > len(indices) {
+			end = len(indices)
+		}
+		wg.Add(1)
+		go func(part []int) {
+			defer wg.Done()
+			for _, i := range part {
+				scores[i] = cosineSimilarityFlat(pairs[i].Query, pairs[i].Candidate)
+			}
+		}(indices[start:end])
+	}
+	wg.Wait()
+}
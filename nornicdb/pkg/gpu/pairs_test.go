@@ -0,0 +1,145 @@
+package gpu
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/cuda"
+)
+
+func randomPairs(rng *rand.Rand, queries, perQuery, dims int) []Pair {
+	pairs := make([]Pair, 0, queries*perQuery)
+	for q := 0; q < queries; q++ {
+		query := make([]float32, dims)
+		for i := range query {
+			query[i] = rng.Float32()*2 - 1
+		}
+		for c := 0; c < perQuery; c++ {
+			candidate := make([]float32, dims)
+			for i := range candidate {
+				candidate[i] = rng.Float32()*2 - 1
+			}
+			pairs = append(pairs, Pair{Query: query, Candidate: candidate})
+		}
+	}
+	return pairs
+}
+
+// enabledManager returns a manager that reports an active device of the
+// given backend without probing hardware.
+func enabledManager(backend Backend, fallback bool) *Manager {
+	m := &Manager{
+		config: &Config{FallbackOnError: fallback},
+		device: &DeviceInfo{Backend: backend},
+	}
+	m.enabled.Store(true)
+	return m
+}
+
+func TestScorePairs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	pairs := randomPairs(rng, 3, MinGPUPairGroup+10, 32)
+	pairs = append(pairs,
+		Pair{Query: []float32{1, 0}, Candidate: []float32{1, 0, 0}}, // mismatched
+		Pair{Query: nil, Candidate: nil},
+		Pair{Query: []float32{1, 0}, Candidate: []float32{0, 1}},
+	)
+
+	for _, m := range []*Manager{nil, {config: DefaultConfig()}, enabledManager(BackendOpenCL, false)} {
+		scores, err := ScorePairs(m, pairs)
+		if err != nil {
+			t.Fatalf("ScorePairs() error = %v", err)
+		}
+		if len(scores) != len(pairs) {
+			t.Fatalf("expected %d scores, got %d", len(pairs), len(scores))
+		}
+		for i, p := range pairs {
+			want := cosineSimilarityFlat(p.Query, p.Candidate)
+			if math.Abs(float64(scores[i]-want)) > 1e-5 {
+				t.Fatalf("pair %d: expected %f, got %f", i, want, scores[i])
+			}
+		}
+	}
+
+	scores, err := ScorePairs(nil, nil)
+	if err != nil || len(scores) != 0 {
+		t.Errorf("expected no scores for no pairs, got %v, %v", scores, err)
+	}
+}
+
+func TestScorePairs_KernelFailure(t *testing.T) {
+	if cuda.IsAvailable() {
+		t.Skip("CUDA available; kernel will not fail")
+	}
+	pairs := randomPairs(rand.New(rand.NewSource(2)), 1, MinGPUPairGroup, 8)
+
+	m := enabledManager(BackendCUDA, true)
+	scores, err := ScorePairs(m, pairs)
+	if err != nil {
+		t.Fatalf("expected CPU fallback, got %v", err)
+	}
+	if want := cosineSimilarityFlat(pairs[0].Query, pairs[0].Candidate); scores[0] != want {
+		t.Errorf("expected %f, got %f", want, scores[0])
+	}
+	if stats := m.Stats(); stats.FallbackCount != 1 || stats.OperationsCPU != 1 {
+		t.Errorf("expected 1 fallback and 1 CPU operation, got %+v", stats)
+	}
+
+	// Small groups never reach the kernel
+	if _, err := ScorePairs(enabledManager(BackendCUDA, false), pairs[:10]); err != nil {
+		t.Errorf("small group should score on CPU, got %v", err)
+	}
+	if _, err := ScorePairs(enabledManager(BackendCUDA, false), pairs); !errors.Is(err, ErrKernelFailed) {
+		t.Errorf("expected ErrKernelFailed without fallback, got %v", err)
+	}
+}
+
+func TestGroupPairs(t *testing.T) {
+	a, b := []float32{1, 2}, []float32{1, 2}
+	groups := groupPairs([]Pair{
+		{Query: a, Candidate: []float32{0, 1}},
+		{Query: b, Candidate: []float32{0, 1}},
+		{Query: a, Candidate: []float32{1}},
+		{Query: a, Candidate: []float32{1, 1}},
+	})
+	want := [][]int{{0, 3}, {1}, {2}}
+	if fmt.Sprint(groups) != fmt.Sprint(want) {
+		t.Errorf("expected groups %v, got %v", want, groups)
+	}
+}
+
+func BenchmarkScorePairs(b *testing.B) {
+	// One source scored against a large candidate pool, as in link prediction
+	for _, size := range []int{1000, 10000, 100000} {
+		pairs := randomPairs(rand.New(rand.NewSource(3)), 1, size, 384)
+
+		b.Run(fmt.Sprintf("Serial_%d", size), func(b *testing.B) {
+			scores := make([]float32, len(pairs))
+			for i := 0; i < b.N; i++ {
+				for j, p := range pairs {
+					scores[j] = cosineSimilarityFlat(p.Query, p.Candidate)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("CPU_%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ScorePairs(nil, pairs)
+			}
+		})
+
+		b.Run(fmt.Sprintf("GPU_%d", size), func(b *testing.B) {
+			m, _ := NewManager(&Config{Enabled: true, FallbackOnError: true})
+			if m == nil || !m.IsEnabled() {
+				b.Skip("GPU not available")
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ScorePairs(m, pairs)
+			}
+		})
+	}
+}
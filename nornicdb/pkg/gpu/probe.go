@@ -0,0 +1,217 @@
+package gpu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/cuda"
+	"github.com/orneryd/nornicdb/pkg/gpu/metal"
+	"github.com/orneryd/nornicdb/pkg/gpu/opencl"
+	"github.com/orneryd/nornicdb/pkg/gpu/vulkan"
+)
+
+// BackendProbe describes one compute backend as seen by this binary.
+//
+// A backend is Available only when it was compiled in and a driver and at
+// least one device were found. Otherwise Reason says why, for example
+// "binary built without CUDA support" or "CUDA driver version is
+// insufficient for CUDA runtime version". Devices may be listed even when
+// the backend is unavailable, e.g. an NVIDIA GPU found by nvidia-smi in a
+// build without CUDA.
+type BackendProbe struct {
+	Backend   Backend         `json:"backend"`
+	Compiled  bool            `json:"compiled"`
+	Available bool            `json:"available"`
+	Reason    string          `json:"reason,omitempty"`
+	Devices   []DeviceDetails `json:"devices,omitempty"`
+}
+
+// DeviceDetails describes a GPU device found while probing.
+type DeviceDetails struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Vendor   string `json:"vendor,omitempty"`
+	MemoryMB int    `json:"memory_mb"`
+	// ComputeCapability is the CUDA compute capability, e.g. "8.6".
+	ComputeCapability string `json:"compute_capability,omitempty"`
+	DriverVersion     string `json:"driver_version,omitempty"`
+	// MaxWorkGroupSize is the maximum threads per block/threadgroup/work group.
+	MaxWorkGroupSize int `json:"max_work_group_size,omitempty"`
+	// Features lists optional capabilities relevant to NornicDB kernels,
+	// such as "fp16", "tensor_cores" or "unified_memory".
+	Features []string `json:"features,omitempty"`
+}
+
+// Probe reports every GPU backend with its devices, or the reason it cannot
+// be used. Unlike NewManager it never fails and does not pick a device, so
+// it is suitable for diagnostics and startup logs.
+//
+// Probing queries drivers and may take a moment; cache the result rather
+// than calling it per request.
+//
+// Example:
+//
+//	for _, b := range gpu.Probe() {
+//		if !b.Available {
+//			log.Printf("%s: %s", b.Backend, b.Reason)
+//			continue
+//		}
+//		for _, d := range b.Devices {
+//			log.Printf("%s: %s (%d MB)", b.Backend, d.Name, d.MemoryMB)
+//		}
+//	}
+func Probe() []BackendProbe {
+	return []BackendProbe{probeCUDADevices(), probeMetalDevices(), probeOpenCLDevices(), probeVulkanDevices()}
+}
+
+// String formats the probe as a one-line summary for logs.
+func (p BackendProbe) String() string {
+	if !p.Available {
+		return fmt.Sprintf("%s: unavailable (%s)", p.Backend, p.Reason)
+	}
+	names := make([]string, len(p.Devices))
+	for i, d := range p.Devices {
+		names[i] = fmt.Sprintf("%s, %dMB", d.Name, d.MemoryMB)
+		if d.ComputeCapability != "" {
+			names[i] += ", sm_" + strings.ReplaceAll(d.ComputeCapability, ".", "")
+		}
+		if d.DriverVersion != "" {
+			names[i] += ", driver " + d.DriverVersion
+		}
+	}
+	return fmt.Sprintf("%s: %d device(s) [%s]", p.Backend, len(p.Devices), strings.Join(names, "; "))
+}
+
+func probeCUDADevices() BackendProbe {
+	p := BackendProbe{Backend: BackendCUDA, Compiled: cuda.Compiled}
+	p.Available = cuda.Compiled && cuda.IsAvailable()
+	if !p.Available {
+		p.Reason = cuda.UnavailableReason()
+	}
+	for _, d := range cuda.QueryDevices() {
+		details := DeviceDetails{
+			ID:               d.ID,
+			Name:             d.Name,
+			Vendor:           "NVIDIA",
+			MemoryMB:         int(d.MemoryBytes / (1024 * 1024)),
+			DriverVersion:    d.DriverVersion,
+			MaxWorkGroupSize: d.MaxThreadsPerBlock,
+		}
+		if d.ComputeMajor > 0 {
+			details.ComputeCapability = fmt.Sprintf("%d.%d", d.ComputeMajor, d.ComputeMinor)
+			details.Features = cudaFeatures(d.ComputeMajor, d.ComputeMinor)
+		}
+		p.Devices = append(p.Devices, details)
+	}
+	return p
+}
+
+// cudaFeatures lists the capabilities implied by a compute capability.
+func cudaFeatures(major, minor int) []string {
+	cc := major*10 + minor
+	features := []string{"cublas"}
+	if cc >= 53 {
+		features = append(features, "fp16")
+	}
+	if cc >= 61 {
+		features = append(features, "int8")
+	}
+	if cc >= 70 {
+		features = append(features, "tensor_cores")
+	}
+	if cc >= 80 {
+		features = append(features, "bf16")
+	}
+	return features
+}
+
+func probeMetalDevices() BackendProbe {
+	p := BackendProbe{Backend: BackendMetal, Compiled: metal.Compiled}
+	p.Available = metal.Compiled && metal.IsAvailable()
+	if !p.Available {
+		p.Reason = metal.UnavailableReason()
+		return p
+	}
+
+	caps := metal.GetCapabilitiesNoDevice()
+	details := DeviceDetails{
+		Name:             caps.Name,
+		Vendor:           "Apple",
+		MaxWorkGroupSize: caps.MaxThreadsPerThreadgroup,
+	}
+	if device, err := metal.NewDevice(); err == nil {
+		details.MemoryMB = device.MemoryMB()
+		device.Release()
+	}
+	if caps.Architecture != "" {
+		details.Features = append(details.Features, "arch:"+caps.Architecture)
+	}
+	if caps.HasUnifiedMemory {
+		details.Features = append(details.Features, "unified_memory")
+	}
+	if metal.MPSIsSupported() {
+		details.Features = append(details.Features, "mps")
+	}
+	if caps.IsLowPower {
+		details.Features = append(details.Features, "low_power")
+	}
+	p.Devices = []DeviceDetails{details}
+	return p
+}
+
+// openclFeatures maps OpenCL extensions to feature names.
+var openclFeatures = map[string]string{
+	"cl_khr_fp16":                      "fp16",
+	"cl_khr_fp64":                      "fp64",
+	"cl_khr_int64_base_atomics":        "int64_atomics",
+	"cl_khr_subgroups":                 "subgroups",
+	"cl_intel_subgroups":               "subgroups",
+	"cl_khr_global_int32_base_atomics": "int32_atomics",
+}
+
+func probeOpenCLDevices() BackendProbe {
+	p := BackendProbe{Backend: BackendOpenCL, Compiled: opencl.Compiled}
+	p.Available = opencl.Compiled && opencl.IsAvailable()
+	if !p.Available {
+		p.Reason = opencl.UnavailableReason()
+	}
+	for _, d := range opencl.QueryDevices() {
+		details := DeviceDetails{
+			ID:               d.ID,
+			Name:             d.Name,
+			Vendor:           d.Vendor,
+			MemoryMB:         int(d.MemoryBytes / (1024 * 1024)),
+			DriverVersion:    d.DriverVersion,
+			MaxWorkGroupSize: d.MaxWorkGroupSize,
+		}
+		seen := make(map[string]bool)
+		for _, ext := range d.Extensions {
+			if f, ok := openclFeatures[ext]; ok && !seen[f] {
+				seen[f] = true
+				details.Features = append(details.Features, f)
+			}
+		}
+		p.Devices = append(p.Devices, details)
+	}
+	return p
+}
+
+func probeVulkanDevices() BackendProbe {
+	p := BackendProbe{Backend: BackendVulkan, Compiled: vulkan.Compiled}
+	p.Available = vulkan.Compiled && vulkan.IsAvailable()
+	if !p.Available {
+		p.Reason = vulkan.UnavailableReason()
+	}
+	for _, d := range vulkan.QueryDevices() {
+		p.Devices = append(p.Devices, DeviceDetails{
+			ID:               d.ID,
+			Name:             d.Name,
+			Vendor:           d.Vendor(),
+			MemoryMB:         int(d.MemoryBytes / (1024 * 1024)),
+			DriverVersion:    d.DriverVersionString(),
+			MaxWorkGroupSize: d.MaxWorkGroupInvocations,
+			Features:         []string{"vulkan_" + d.APIVersionString()},
+		})
+	}
+	return p
+}
@@ -0,0 +1,63 @@
+package gpu
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	probes := Probe()
+	want := []Backend{BackendCUDA, BackendMetal, BackendOpenCL, BackendVulkan}
+	if len(probes) != len(want) {
+		t.Fatalf("Probe() returned %d backends, want %d", len(probes), len(want))
+	}
+	for i, p := range probes {
+		if p.Backend != want[i] {
+			t.Errorf("probes[%d].Backend = %s, want %s", i, p.Backend, want[i])
+		}
+		if p.Available && !p.Compiled {
+			t.Errorf("%s: available but not compiled", p.Backend)
+		}
+		if !p.Available && p.Reason == "" {
+			t.Errorf("%s: unavailable without a reason", p.Backend)
+		}
+		if p.Available && p.Reason != "" {
+			t.Errorf("%s: available but has reason %q", p.Backend, p.Reason)
+		}
+	}
+}
+
+func TestCudaFeatures(t *testing.T) {
+	tests := []struct {
+		major, minor int
+		want         []string
+	}{
+		{5, 0, []string{"cublas"}},
+		{6, 1, []string{"cublas", "fp16", "int8"}},
+		{7, 5, []string{"cublas", "fp16", "int8", "tensor_cores"}},
+		{8, 6, []string{"cublas", "fp16", "int8", "tensor_cores", "bf16"}},
+	}
+	for _, tt := range tests {
+		if got := cudaFeatures(tt.major, tt.minor); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("cudaFeatures(%d, %d) = %v, want %v", tt.major, tt.minor, got, tt.want)
+		}
+	}
+}
+
+func TestBackendProbeString(t *testing.T) {
+	p := BackendProbe{Backend: BackendOpenCL, Reason: "binary built without OpenCL support"}
+	if got := p.String(); got != "opencl: unavailable (binary built without OpenCL support)" {
+		t.Errorf("String() = %q", got)
+	}
+
+	p = BackendProbe{Backend: BackendCUDA, Compiled: true, Available: true, Devices: []DeviceDetails{
+		{Name: "RTX 4090", MemoryMB: 24564, ComputeCapability: "8.9", DriverVersion: "12.4"},
+	}}
+	got := p.String()
+	for _, s := range []string{"cuda: 1 device(s)", "RTX 4090", "24564MB", "sm_89", "driver 12.4"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("String() = %q, missing %q", got, s)
+		}
+	}
+}
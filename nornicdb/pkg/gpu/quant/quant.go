@@ -0,0 +1,161 @@
+// Package quant scales float32 embeddings to int8 codes with one float32
+// scale per vector, the format the GPU backends store PrecisionInt8
+// buffers in.
+//
+// Quantization is symmetric: a vector's scale is its largest absolute
+// component divided by 127, and each component becomes the nearest code in
+// [-127, 127]. Dequantizing multiplies the codes by the scale back:
+//
+//	v[i] ≈ float32(codes[i]) * scale
+//
+// A vector takes dims bytes plus four for its scale instead of 4*dims
+// bytes, so a device holds almost four times as many embeddings as in
+// float32. The rounding error of each component is at most scale/2, which
+// for typical embeddings costs well under a percent of top-k recall.
+// Backends dequantize inside their kernels and accumulate in float32.
+//
+// A buffer image holds the codes of every vector, row-major, followed by
+// the scales, which start at the next multiple of four bytes so they can
+// be read as float32s:
+//
+//	| codes: vectors*dims int8 | pad | scales: vectors float32 (little-endian) |
+//	0                          ScalesOffset(vectors*dims)      Size(vectors, dims)
+//
+// Example (in a backend bridge):
+//
+//	image := quant.Pack(data, dims)
+//	// upload image; scales begin at quant.ScalesOffset(len(data))
+package quant
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// MaxCode is the largest absolute code. -128 is never produced, so
+// negating a code cannot overflow.
+const MaxCode = 127
+
+// QuantizeVector writes the codes of v to codes, which must be at least
+// as long, and returns the scale. A zero vector gets scale 0.
+func QuantizeVector(codes []int8, v []float32) float32 {
+	var maxAbs float32
+	for _, x := range v {
+		if a := float32(math.Abs(float64(x))); a > maxAbs {
+			maxAbs = a
+		}
+	}
+	if maxAbs == 0 || math.IsNaN(float64(maxAbs)) || math.IsInf(float64(maxAbs), 0) {
+		for i := range v {
+			codes[i] = 0
+		}
+		return 0
+	}
+	scale := maxAbs / MaxCode
+	for i, x := range v {
+		q := math.Round(float64(x / scale))
+		if q > MaxCode {
+			q = MaxCode
+		} else if q < -MaxCode {
+			q = -MaxCode
+		}
+		codes[i] = int8(q)
+	}
+	return scale
+}
+
+// DequantizeVector writes codes times scale to dst, which must be at
+// least as long as codes.
+func DequantizeVector(dst []float32, codes []int8, scale float32) {
+	for i, c := range codes {
+		dst[i] = float32(c) * scale
+	}
+}
+
+// Quantize quantizes the row-major vectors of dims components in data
+// into codes and scales, which must hold len(data) codes and
+// len(data)/dims scales.
+func Quantize(codes []int8, scales []float32, data []float32, dims int) {
+	for v := 0; v*dims < len(data); v++ {
+		row := data[v*dims : (v+1)*dims]
+		scales[v] = QuantizeVector(codes[v*dims:], row)
+	}
+}
+
+// Dequantize writes the vectors of dims components encoded by codes and
+// scales to dst, which must be at least as long as codes.
+func Dequantize(dst []float32, codes []int8, scales []float32, dims int) {
+	for v := 0; v*dims < len(codes); v++ {
+		DequantizeVector(dst[v*dims:], codes[v*dims:(v+1)*dims], scales[v])
+	}
+}
+
+// ScalesOffset returns the byte offset of the scales in an image holding
+// count codes: count rounded up to a multiple of four.
+func ScalesOffset(count int) int {
+	return (count + 3) &^ 3
+}
+
+// Size returns the bytes of an image holding vectors vectors of dims
+// components.
+func Size(vectors, dims int) int {
+	return ScalesOffset(vectors*dims) + 4*vectors
+}
+
+// Pack quantizes the row-major vectors of dims components in data and
+// returns their buffer image. len(data) must be a multiple of dims.
+func Pack(data []float32, dims int) []byte {
+	vectors := len(data) / dims
+	codes := make([]int8, len(data))
+	scales := make([]float32, vectors)
+	Quantize(codes, scales, data, dims)
+
+	image := make([]byte, Size(vectors, dims))
+	for i, c := range codes {
+		image[i] = byte(c)
+	}
+	off := ScalesOffset(len(codes))
+	for v, s := range scales {
+		binary.LittleEndian.PutUint32(image[off+4*v:], math.Float32bits(s))
+	}
+	return image
+}
+
+// Unpack returns the vectors of dims components encoded in an image of
+// vectors vectors made by Pack.
+func Unpack(image []byte, vectors, dims int) []float32 {
+	codes := make([]int8, vectors*dims)
+	for i := range codes {
+		codes[i] = int8(image[i])
+	}
+	off := ScalesOffset(len(codes))
+	scales := make([]float32, vectors)
+	for v := range scales {
+		scales[v] = math.Float32frombits(binary.LittleEndian.Uint32(image[off+4*v:]))
+	}
+	data := make([]float32, len(codes))
+	Dequantize(data, codes, scales, dims)
+	return data
+}
+
+// Cosine returns the score a backend computes between the quantized
+// vector codes*scale and query: the dot product when normalized is set,
+// otherwise cosine similarity. It is the CPU reference for the kernels.
+func Cosine(codes []int8, scale float32, query []float32, normalized bool) float32 {
+	var dot, codeNorm, queryNorm float32
+	for i, c := range codes {
+		e := float32(c)
+		dot += e * query[i]
+		codeNorm += e * e
+		queryNorm += query[i] * query[i]
+	}
+	if normalized {
+		return dot * scale
+	}
+	// The scale cancels out of cosine similarity.
+	denom := float32(math.Sqrt(float64(codeNorm))) * float32(math.Sqrt(float64(queryNorm)))
+	if denom <= 1e-10 {
+		return 0
+	}
+	return dot / denom
+}
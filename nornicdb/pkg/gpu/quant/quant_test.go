@@ -0,0 +1,143 @@
+package quant
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestQuantizeVector(t *testing.T) {
+	codes := make([]int8, 4)
+	scale := QuantizeVector(codes, []float32{1.27, -0.635, 0, 0.004})
+	if math.Abs(float64(scale)-0.01) > 1e-7 {
+		t.Errorf("scale = %g, want 0.01", scale)
+	}
+	want := []int8{127, -64, 0, 0} // -63.5 rounds away from zero
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("codes = %v, want %v", codes, want)
+			break
+		}
+	}
+
+	codes = []int8{5, 5}
+	if scale := QuantizeVector(codes, []float32{0, 0}); scale != 0 || codes[0] != 0 || codes[1] != 0 {
+		t.Errorf("zero vector: scale %g, codes %v", scale, codes)
+	}
+	if scale := QuantizeVector(codes, []float32{-2, 1}); codes[0] != -MaxCode || scale*MaxCode != 2 {
+		t.Errorf("negative maximum: scale %g, codes %v", scale, codes)
+	}
+}
+
+func TestPackUnpack(t *testing.T) {
+	const vectors, dims = 5, 3 // 15 codes: the scales need padding
+	rng := rand.New(rand.NewSource(1))
+	data := make([]float32, vectors*dims)
+	for i := range data {
+		data[i] = rng.Float32()*2 - 1
+	}
+
+	image := Pack(data, dims)
+	if len(image) != Size(vectors, dims) || Size(vectors, dims) != 16+4*vectors {
+		t.Fatalf("len(image) = %d, Size = %d", len(image), Size(vectors, dims))
+	}
+	got := Unpack(image, vectors, dims)
+	for v := 0; v < vectors; v++ {
+		var maxAbs float64
+		for _, x := range data[v*dims : (v+1)*dims] {
+			maxAbs = math.Max(maxAbs, math.Abs(float64(x)))
+		}
+		for d := 0; d < dims; d++ {
+			i := v*dims + d
+			if diff := math.Abs(float64(got[i] - data[i])); diff > maxAbs/MaxCode/2+1e-7 {
+				t.Errorf("element %d = %g, want %g", i, got[i], data[i])
+			}
+		}
+	}
+}
+
+func TestScalesOffset(t *testing.T) {
+	for count, want := range map[int]int{0: 0, 1: 4, 4: 4, 5: 8, 1024: 1024} {
+		if got := ScalesOffset(count); got != want {
+			t.Errorf("ScalesOffset(%d) = %d, want %d", count, got, want)
+		}
+	}
+}
+
+func TestCosine(t *testing.T) {
+	v := []float32{0.6, 0.8, 0}
+	codes := make([]int8, len(v))
+	scale := QuantizeVector(codes, v)
+
+	if got := Cosine(codes, scale, v, true); math.Abs(float64(got)-1) > 0.01 {
+		t.Errorf("dot product = %g, want 1", got)
+	}
+	if got := Cosine(codes, scale, []float32{2, 0, 0}, false); math.Abs(float64(got)-0.6) > 0.01 {
+		t.Errorf("cosine = %g, want 0.6", got)
+	}
+	if got := Cosine(make([]int8, 3), 0, v, false); got != 0 {
+		t.Errorf("cosine with a zero vector = %g, want 0", got)
+	}
+}
+
+func TestRecall(t *testing.T) {
+	// Ranking by quantized scores keeps nearly all of the true top 10.
+	const n, dims, k = 2000, 64, 10
+	rng := rand.New(rand.NewSource(2))
+	data := make([]float32, n*dims)
+	for i := range data {
+		data[i] = float32(rng.NormFloat64())
+	}
+	codes := make([]int8, len(data))
+	scales := make([]float32, n)
+	Quantize(codes, scales, data, dims)
+
+	hits, total := 0, 0
+	for q := 0; q < 20; q++ {
+		query := make([]float32, dims)
+		for i := range query {
+			query[i] = float32(rng.NormFloat64())
+		}
+		exact := make([]float32, n)
+		approx := make([]float32, n)
+		for v := 0; v < n; v++ {
+			row := data[v*dims : (v+1)*dims]
+			exact[v] = cosine(row, query)
+			approx[v] = Cosine(codes[v*dims:(v+1)*dims], scales[v], query, false)
+		}
+		want := topK(exact, k)
+		for i := range topK(approx, k) {
+			if want[i] {
+				hits++
+			}
+		}
+		total += k
+	}
+	if recall := float64(hits) / float64(total); recall < 0.95 {
+		t.Errorf("recall@%d = %.3f, want at least 0.95", k, recall)
+	}
+}
+
+func cosine(a, b []float32) float32 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i] * b[i])
+		na += float64(a[i] * a[i])
+		nb += float64(b[i] * b[i])
+	}
+	return float32(dot / math.Sqrt(na*nb))
+}
+
+func topK(scores []float32, k int) map[int]bool {
+	top := make(map[int]bool, k)
+	for len(top) < k {
+		best := -1
+		for i, s := range scores {
+			if !top[i] && (best < 0 || s > scores[best]) {
+				best = i
+			}
+		}
+		top[best] = true
+	}
+	return top
+}
@@ -0,0 +1,236 @@
+// Package gpu - growable row buffers.
+//
+// A DeviceBuffer has a fixed size, so an index that gains rows had to
+// upload its whole matrix again. RowBuffer keeps rows of one width in a
+// device buffer with spare capacity: appends, updates and deletes write
+// only the rows they touch. When the capacity runs out, the buffer grows
+// by at least a quarter, so the rows held are copied to a new buffer only
+// a logarithmic number of times as the index grows.
+//
+//	rows, err := gpu.NewRowBuffer(dev, 1024, embeddings)
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Release()
+//	rows.Append(batch)            // Written after the last row
+//	rows.Update(7, embedding)     // Row 7 overwritten in place
+//	moved, _ := rows.Delete(3)    // Last row moved into row 3
+//	hits, err := rows.Search(query, 10, gpu.SearchOptions{})
+package gpu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrRowOutOfRange is returned for a row past the last row of a RowBuffer.
+var ErrRowOutOfRange = errors.New("gpu: row out of range")
+
+// Growth of a full RowBuffer.
+const (
+	rowGrowthDivisor = 4  // Grow by at least a quarter of the rows held
+	minRowGrowth     = 64 // Grow by at least this many rows
+)
+
+// RowBuffer is a matrix of rows in device memory with room to append
+// more. Rows past Rows() are unused capacity, never searched. It is not
+// safe for concurrent use.
+type RowBuffer struct {
+	device     Device
+	dimensions int
+	buf        DeviceBuffer
+	rows       int
+	capacity   int // Rows buf can hold
+
+	bytesWritten uint64
+	grows        int64
+}
+
+// RowBufferStats summarizes a RowBuffer.
+type RowBufferStats struct {
+	Rows         int
+	Capacity     int    // Rows the device buffer can hold
+	BytesWritten uint64 // Uploaded by creation, writes and growth
+	Grows        int64  // Times the rows were copied to a larger buffer
+}
+
+// NewRowBuffer uploads data, rows of dimensions values, to device. The
+// buffer holds exactly these rows; the first Append past them grows it.
+func NewRowBuffer(device Device, dimensions int, data []float32) (*RowBuffer, error) {
+	if dimensions <= 0 || len(data)%dimensions != 0 {
+		return nil, ErrInvalidDimensions
+	}
+	r := &RowBuffer{device: device, dimensions: dimensions}
+	rows := len(data) / dimensions
+	if rows == 0 {
+		return r, nil
+	}
+	buf, err := device.NewBuffer(data)
+	if err != nil {
+		return nil, err
+	}
+	r.buf, r.rows, r.capacity = buf, rows, rows
+	r.bytesWritten = uint64(len(data) * 4)
+	return r, nil
+}
+
+// Rows returns the number of rows held.
+func (r *RowBuffer) Rows() int { return r.rows }
+
+// Capacity returns the number of rows the device buffer can hold.
+func (r *RowBuffer) Capacity() int { return r.capacity }
+
+// Dimensions returns the number of values in a row.
+func (r *RowBuffer) Dimensions() int { return r.dimensions }
+
+// Buffer returns the device buffer, or nil if no rows were ever added. It
+// changes when the RowBuffer grows.
+func (r *RowBuffer) Buffer() DeviceBuffer { return r.buf }
+
+// Append writes vectors, whole rows, after the last row, growing the
+// device buffer if they do not fit.
+func (r *RowBuffer) Append(vectors []float32) error {
+	if len(vectors)%r.dimensions != 0 {
+		return ErrInvalidDimensions
+	}
+	added := len(vectors) / r.dimensions
+	if added == 0 {
+		return nil
+	}
+	if r.rows+added > r.capacity {
+		return r.grow(vectors)
+	}
+	if err := r.buf.WriteAt(r.rows*r.dimensions, vectors); err != nil {
+		return err
+	}
+	r.rows += added
+	r.bytesWritten += uint64(len(vectors) * 4)
+	return nil
+}
+
+// grow moves the rows held and vectors to a new device buffer with room
+// for more. The old buffer is released only once the new one exists, so a
+// failed grow leaves the RowBuffer unchanged.
+func (r *RowBuffer) grow(vectors []float32) error {
+	added := len(vectors) / r.dimensions
+	capacity := r.rows + max(added, r.rows/rowGrowthDivisor, minRowGrowth)
+
+	data := make([]float32, capacity*r.dimensions)
+	if r.rows > 0 {
+		held, err := r.buf.ReadAt(0, r.rows*r.dimensions)
+		if err != nil {
+			return fmt.Errorf("gpu: read rows to grow buffer: %w", err)
+		}
+		copy(data, held)
+	}
+	copy(data[r.rows*r.dimensions:], vectors)
+
+	buf, err := r.device.NewBuffer(data)
+	if err != nil {
+		return err
+	}
+	if r.buf != nil {
+		r.buf.Release()
+	}
+	r.buf, r.capacity = buf, capacity
+	r.rows += added
+	r.bytesWritten += uint64(len(data) * 4)
+	r.grows++
+	return nil
+}
+
+// Update overwrites rows starting at row with vectors, whole rows. They
+// must already be held; use Append to add rows.
+func (r *RowBuffer) Update(row int, vectors []float32) error {
+	if len(vectors)%r.dimensions != 0 {
+		return ErrInvalidDimensions
+	}
+	if row < 0 || row+len(vectors)/r.dimensions > r.rows {
+		return fmt.Errorf("%w: rows %d-%d of %d", ErrRowOutOfRange, row, row+len(vectors)/r.dimensions-1, r.rows)
+	}
+	if len(vectors) == 0 {
+		return nil
+	}
+	if err := r.buf.WriteAt(row*r.dimensions, vectors); err != nil {
+		return err
+	}
+	r.bytesWritten += uint64(len(vectors) * 4)
+	return nil
+}
+
+// Delete removes row by moving the last row into its place, and returns
+// the former index of the moved row, or -1 if row was the last one.
+func (r *RowBuffer) Delete(row int) (int, error) {
+	if row < 0 || row >= r.rows {
+		return -1, fmt.Errorf("%w: row %d of %d", ErrRowOutOfRange, row, r.rows)
+	}
+	last := r.rows - 1
+	if row == last {
+		r.rows--
+		return -1, nil
+	}
+	vector, err := r.buf.ReadAt(last*r.dimensions, r.dimensions)
+	if err != nil {
+		return -1, err
+	}
+	if err := r.buf.WriteAt(row*r.dimensions, vector); err != nil {
+		return -1, err
+	}
+	r.rows--
+	r.bytesWritten += uint64(r.dimensions * 4)
+	return last, nil
+}
+
+// Truncate drops the rows from rows on. The capacity is kept for later
+// appends.
+func (r *RowBuffer) Truncate(rows int) error {
+	if rows < 0 || rows > r.rows {
+		return fmt.Errorf("%w: truncate to %d of %d", ErrRowOutOfRange, rows, r.rows)
+	}
+	r.rows = rows
+	return nil
+}
+
+// Search scores the rows held against query and returns the k best,
+// highest score first.
+func (r *RowBuffer) Search(query []float32, k int, opts SearchOptions) ([]Hit, error) {
+	if len(query) != r.dimensions {
+		return nil, ErrInvalidDimensions
+	}
+	if r.rows == 0 {
+		return nil, nil
+	}
+	return r.device.Search(r.buf, query, r.rows, r.dimensions, min(k, r.rows), opts)
+}
+
+// SearchBatch is Search for several queries in one pass.
+func (r *RowBuffer) SearchBatch(queries [][]float32, k int, opts SearchOptions) ([][]Hit, error) {
+	for _, q := range queries {
+		if len(q) != r.dimensions {
+			return nil, ErrInvalidDimensions
+		}
+	}
+	if r.rows == 0 {
+		return make([][]Hit, len(queries)), nil
+	}
+	return r.device.SearchBatch(r.buf, queries, r.rows, r.dimensions, min(k, r.rows), opts)
+}
+
+// Stats returns the buffer's statistics.
+func (r *RowBuffer) Stats() RowBufferStats {
+	return RowBufferStats{
+		Rows:         r.rows,
+		Capacity:     r.capacity,
+		BytesWritten: r.bytesWritten,
+		Grows:        r.grows,
+	}
+}
+
+// Release frees the device buffer.
+func (r *RowBuffer) Release() {
+	if r.buf != nil {
+		r.buf.Release()
+		r.buf = nil
+	}
+	r.rows, r.capacity = 0, 0
+}
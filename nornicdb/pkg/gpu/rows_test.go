@@ -0,0 +1,148 @@
+package gpu
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRowBuffer_Append(t *testing.T) {
+	dev := &fakeDevice{}
+	rb, err := NewRowBuffer(dev, 4, rows(2, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rb.Rows() != 2 || rb.Capacity() != 2 {
+		t.Fatalf("rows = %d, capacity = %d, want 2 and 2", rb.Rows(), rb.Capacity())
+	}
+
+	// The first append past the end grows the buffer with room to spare
+	if err := rb.Append(rows(3, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if rb.Rows() != 5 || rb.Capacity() != 2+minRowGrowth || dev.buffers != 1 {
+		t.Fatalf("rows = %d, capacity = %d, buffers = %d", rb.Rows(), rb.Capacity(), dev.buffers)
+	}
+	grown := rb.Buffer()
+
+	// Later appends write into the spare capacity
+	for i := 0; i < 10; i++ {
+		if err := rb.Append([]float32{9, 9, 9, 9}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rb.Buffer() != grown {
+		t.Fatal("appends within capacity should keep the buffer")
+	}
+	stats := rb.Stats()
+	if stats.Rows != 15 || stats.Grows != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	wantBytes := uint64(2*4*4 + (2+minRowGrowth)*4*4 + 10*4*4)
+	if stats.BytesWritten != wantBytes {
+		t.Errorf("bytes written = %d, want %d", stats.BytesWritten, wantBytes)
+	}
+
+	got, _ := rb.Buffer().ReadAt(0, 15*4)
+	for i, want := range []float32{1, 2, 1, 2, 3, 9} {
+		row := []int{0, 1, 2, 3, 4, 14}[i]
+		if got[row*4] != want {
+			t.Errorf("row %d = %v, want %v", row, got[row*4], want)
+		}
+	}
+
+	if err := rb.Append([]float32{1, 2, 3}); !errors.Is(err, ErrInvalidDimensions) {
+		t.Errorf("partial row: error = %v, want ErrInvalidDimensions", err)
+	}
+}
+
+func TestRowBuffer_GrowthFailure(t *testing.T) {
+	dev := &fakeDevice{capacity: 2 * 4 * 4 * 2}
+	rb, _ := NewRowBuffer(dev, 4, rows(2, 4))
+	if err := rb.Append(rows(1, 4)); !errors.Is(err, ErrOutOfMemory) {
+		t.Fatalf("append: error = %v, want ErrOutOfMemory", err)
+	}
+	if rb.Rows() != 2 || rb.Capacity() != 2 || rb.Buffer() == nil || dev.buffers != 1 {
+		t.Fatal("a failed grow should leave the buffer unchanged")
+	}
+}
+
+func TestRowBuffer_UpdateDelete(t *testing.T) {
+	dev := &fakeDevice{}
+	rb, _ := NewRowBuffer(dev, 2, []float32{1, 0, 0, 1, 1, 1})
+
+	if err := rb.Update(1, []float32{0, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rb.Update(2, []float32{5, 5, 5, 5}); !errors.Is(err, ErrRowOutOfRange) {
+		t.Errorf("update past the end: error = %v, want ErrRowOutOfRange", err)
+	}
+
+	moved, err := rb.Delete(0)
+	if err != nil || moved != 2 {
+		t.Fatalf("delete = %d, %v; want row 2 moved", moved, err)
+	}
+	if moved, _ := rb.Delete(1); moved != -1 {
+		t.Errorf("deleting the last row moved row %d", moved)
+	}
+	got, _ := rb.Buffer().ReadAt(0, 2)
+	if rb.Rows() != 1 || got[0] != 1 || got[1] != 1 {
+		t.Fatalf("rows = %d, row 0 = %v", rb.Rows(), got)
+	}
+
+	// Deleted rows leave capacity that appends reuse
+	if err := rb.Append([]float32{0, 3, 3, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if rb.Stats().Grows != 0 || dev.buffers != 1 {
+		t.Fatal("append into freed rows should not grow")
+	}
+	hits, err := rb.Search([]float32{0, 1}, 5, SearchOptions{})
+	if err != nil || len(hits) != 3 || hits[0].Index != 1 {
+		t.Fatalf("search = %v, %v", hits, err)
+	}
+
+	if err := rb.Truncate(1); err != nil || rb.Rows() != 1 || rb.Capacity() != 3 {
+		t.Fatalf("truncate: rows = %d, capacity = %d, %v", rb.Rows(), rb.Capacity(), err)
+	}
+	rb.Release()
+	if dev.buffers != 0 {
+		t.Error("Release should free the device buffer")
+	}
+}
+
+func TestGPUEmbeddingIndex_Append(t *testing.T) {
+	dev := &fakeDevice{}
+	accel := &Accelerator{config: DefaultConfig(), backend: dev.Backend(), device: dev}
+	idx := accel.NewGPUEmbeddingIndex(2)
+	if err := idx.SyncToGPU(); err != nil {
+		t.Fatal(err)
+	}
+
+	// New nodes are appended to the synced buffer
+	if err := idx.AddBatch([]string{"a", "b"}, [][]float32{{1, 0}, {0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Add("c", []float32{1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if !idx.IsGPUSynced() || idx.rows.Rows() != 3 || idx.rows.Stats().Grows != 1 {
+		t.Fatalf("synced = %v, stats = %+v; want appends without a sync", idx.IsGPUSynced(), idx.rows.Stats())
+	}
+
+	// Removal moves c into a's row and drops the last row
+	idx.Remove("a")
+	if !idx.IsGPUSynced() || idx.rows.Rows() != 2 {
+		t.Fatalf("synced = %v, rows = %d after Remove", idx.IsGPUSynced(), idx.rows.Rows())
+	}
+	results, backend, err := idx.SearchWithBackend([]float32{1, 0}, 2)
+	if err != nil || backend != "fake" || results[0].ID != "c" || results[1].ID != "b" {
+		t.Fatalf("search = %+v on %q, %v", results, backend, err)
+	}
+	if dev.buffers != 1 {
+		t.Errorf("buffers = %d, want 1", dev.buffers)
+	}
+	// The grow to minRowGrowth rows, c appended, c moved into a's row
+	if got := accel.Stats().BytesUploaded; got != int64(minRowGrowth*2*4+2*4+2*4) {
+		t.Errorf("bytes uploaded = %d", got)
+	}
+}
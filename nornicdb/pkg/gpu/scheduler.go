@@ -0,0 +1,287 @@
+// Package gpu - fair scheduling of GPU searches between sessions.
+//
+// Concurrent searches compete for the same compute units, so without
+// scheduling a search over millions of embeddings slows every other session
+// until it completes. The Scheduler runs one query at a time, splits each
+// query into chunks (one kernel launch each) and hands the device out in
+// time slices: a query keeps the device
+// for consecutive chunks until its slice runs out, then yields to the next
+// session with work waiting. Sessions take turns round robin, so a session
+// issuing many queries gets no more device time than one issuing a few.
+//
+// A query whose embeddings would take more VRAM than MaxQueryVRAMMB is
+// refused with ErrQueryVRAMLimit before it touches the device; callers run
+// it on CPU instead.
+//
+// Example:
+//
+//	s := gpu.NewScheduler(gpu.DefaultSchedulerConfig())
+//	chunks := s.Chunks(len(vectors))
+//	err := s.Run(ctx, "user:alice", int64(len(vectors)*dims*4), chunks, func(chunk int) error {
+//		return searchChunk(chunk)
+//	})
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+)
+
+// ErrQueryVRAMLimit is returned by Scheduler.Run for a query needing more
+// device memory than SchedulerConfig.MaxQueryVRAMMB. It matches
+// ErrOutOfMemory with errors.Is.
+var ErrQueryVRAMLimit = gpuerr.Sentinel("gpu: query exceeds the per-query VRAM limit", ErrOutOfMemory)
+
+// SchedulerConfig configures a Scheduler.
+type SchedulerConfig struct {
+	// TimeSlice is how long a query keeps the device before yielding to
+	// another session (default 20ms). A chunk is never interrupted, so a
+	// slice can run over by up to one chunk.
+	TimeSlice time.Duration
+
+	// ChunkRows is the number of embeddings searched per kernel launch
+	// (default 65536).
+	ChunkRows int
+
+	// MaxQueryVRAMMB limits the device memory one query may use (0 = no
+	// limit).
+	MaxQueryVRAMMB int
+}
+
+// DefaultSchedulerConfig returns a 20ms time slice, 65536-row chunks and no
+// VRAM limit.
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		TimeSlice: 20 * time.Millisecond,
+		ChunkRows: 65536,
+	}
+}
+
+// SchedulerStats reports scheduler counters.
+type SchedulerStats struct {
+	Queries      int64         // Queries run
+	Launches     int64         // Chunks launched
+	Yields       int64         // Times a query gave up the device to a waiting one
+	VRAMRejected int64         // Queries refused by the VRAM limit
+	Waiting      int           // Queries waiting for the device
+	Sessions     int           // Sessions with queries waiting
+	WaitTime     time.Duration // Total time queries waited for the device
+}
+
+// Scheduler shares a GPU fairly between sessions. A nil *Scheduler runs
+// every query at once, unchunked and without a VRAM limit.
+type Scheduler struct {
+	config SchedulerConfig
+	now    func() time.Time
+
+	mu     sync.Mutex
+	busy   bool                 // A query holds the device
+	queues map[string][]*waiter // Waiting queries per session, in arrival order
+	ring   []string             // Sessions with waiting queries, in turn order
+	stats  SchedulerStats
+}
+
+// waiter is a query waiting for the device; ready is closed when the device
+// is handed to it.
+type waiter struct {
+	ready chan struct{}
+}
+
+// NewScheduler returns a scheduler; zero fields of config take their
+// defaults.
+func NewScheduler(config SchedulerConfig) *Scheduler {
+	defaults := DefaultSchedulerConfig()
+	if config.TimeSlice <= 0 {
+		config.TimeSlice = defaults.TimeSlice
+	}
+	if config.ChunkRows <= 0 {
+		config.ChunkRows = defaults.ChunkRows
+	}
+	return &Scheduler{config: config, now: time.Now, queues: make(map[string][]*waiter)}
+}
+
+// ChunkRows returns the number of embeddings searched per launch, or 0 for
+// a nil scheduler (no chunking).
+func (s *Scheduler) ChunkRows() int {
+	if s == nil {
+		return 0
+	}
+	return s.config.ChunkRows
+}
+
+// Chunks returns the number of launches a search over rows embeddings is
+// split into.
+func (s *Scheduler) Chunks(rows int) int {
+	if s == nil || rows <= s.config.ChunkRows {
+		return 1
+	}
+	return (rows + s.config.ChunkRows - 1) / s.config.ChunkRows
+}
+
+// Run calls launch for chunks 0 to chunks-1 while holding the device,
+// yielding it between chunks whenever the query's time slice has run out
+// and another query is waiting. vramBytes is the device memory the query
+// needs; a query over the limit fails with ErrQueryVRAMLimit. Run returns
+// the first error from launch, or ctx's error if ctx ends while the query
+// waits or between chunks.
+func (s *Scheduler) Run(ctx context.Context, session string, vramBytes int64, chunks int, launch func(chunk int) error) error {
+	if s == nil {
+		for c := 0; c < chunks; c++ {
+			if err := launch(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if limit := int64(s.config.MaxQueryVRAMMB) << 20; limit > 0 && vramBytes > limit {
+		s.mu.Lock()
+		s.stats.VRAMRejected++
+		s.mu.Unlock()
+		return fmt.Errorf("%w: needs %d MB, limit is %d MB", ErrQueryVRAMLimit, (vramBytes+1<<20-1)>>20, s.config.MaxQueryVRAMMB)
+	}
+
+	if err := s.acquire(ctx, session); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.stats.Queries++
+	s.mu.Unlock()
+
+	sliceStart := s.now()
+	for c := 0; c < chunks; c++ {
+		if c > 0 {
+			if err := ctx.Err(); err != nil {
+				s.release()
+				return err
+			}
+			if s.now().Sub(sliceStart) >= s.config.TimeSlice && s.queued() {
+				s.mu.Lock()
+				s.stats.Yields++
+				s.mu.Unlock()
+				s.release()
+				if err := s.acquire(ctx, session); err != nil {
+					return err
+				}
+				sliceStart = s.now()
+			}
+		}
+		s.mu.Lock()
+		s.stats.Launches++
+		s.mu.Unlock()
+		if err := launch(c); err != nil {
+			s.release()
+			return err
+		}
+	}
+	s.release()
+	return nil
+}
+
+// Stats returns the scheduler counters.
+func (s *Scheduler) Stats() SchedulerStats {
+	if s == nil {
+		return SchedulerStats{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	for _, q := range s.queues {
+		stats.Waiting += len(q)
+	}
+	stats.Sessions = len(s.ring)
+	return stats
+}
+
+// acquire waits until the device is handed to the caller.
+func (s *Scheduler) acquire(ctx context.Context, session string) error {
+	s.mu.Lock()
+	if !s.busy && len(s.ring) == 0 {
+		s.busy = true
+		s.mu.Unlock()
+		return nil
+	}
+	w := &waiter{ready: make(chan struct{})}
+	if len(s.queues[session]) == 0 {
+		s.ring = append(s.ring, session)
+	}
+	s.queues[session] = append(s.queues[session], w)
+	s.mu.Unlock()
+
+	start := s.now()
+	select {
+	case <-w.ready:
+		s.mu.Lock()
+		s.stats.WaitTime += s.now().Sub(start)
+		s.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if s.dequeue(session, w) {
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+		s.mu.Unlock()
+		// The device was handed over as ctx ended; pass it on
+		s.release()
+		return ctx.Err()
+	}
+}
+
+// release hands the device to the head query of the next session in turn,
+// or frees it if no query waits.
+func (s *Scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.ring) == 0 {
+		s.busy = false
+		return
+	}
+	session := s.ring[0]
+	s.ring = s.ring[1:]
+	queue := s.queues[session]
+	next := queue[0]
+	if len(queue) > 1 {
+		s.queues[session] = queue[1:]
+		s.ring = append(s.ring, session)
+	} else {
+		delete(s.queues, session)
+	}
+	close(next.ready)
+}
+
+// dequeue removes a waiter that gave up, reporting false if it was already
+// handed the device. Callers hold s.mu.
+func (s *Scheduler) dequeue(session string, w *waiter) bool {
+	queue := s.queues[session]
+	for i, q := range queue {
+		if q != w {
+			continue
+		}
+		queue = append(queue[:i], queue[i+1:]...)
+		if len(queue) > 0 {
+			s.queues[session] = queue
+			return true
+		}
+		delete(s.queues, session)
+		for j, name := range s.ring {
+			if name == session {
+				s.ring = append(s.ring[:j], s.ring[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// queued reports whether any query waits for the device.
+func (s *Scheduler) queued() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.ring) > 0
+}
@@ -0,0 +1,170 @@
+package gpu
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schedulerLog records launches in the order the scheduler ran them.
+type schedulerLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *schedulerLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+// waitQueued waits until n queries wait for the device.
+func waitQueued(t *testing.T, s *Scheduler, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return s.Stats().Waiting == n }, time.Second, time.Millisecond)
+}
+
+func TestScheduler_YieldsBetweenChunks(t *testing.T) {
+	s := NewScheduler(SchedulerConfig{TimeSlice: time.Nanosecond, ChunkRows: 10})
+	log := &schedulerLog{}
+	blocked := make(chan struct{})
+	proceed := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, s.Run(context.Background(), "big", 0, 3, func(chunk int) error {
+			if chunk == 0 {
+				close(blocked)
+				<-proceed
+			}
+			log.add("big")
+			return nil
+		}))
+	}()
+	<-blocked
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, s.Run(context.Background(), "small", 0, 1, func(int) error {
+			log.add("small")
+			return nil
+		}))
+	}()
+	waitQueued(t, s, 1)
+	close(proceed)
+	wg.Wait()
+
+	assert.Equal(t, []string{"big", "small", "big", "big"}, log.events, "the small query runs after one chunk")
+	stats := s.Stats()
+	assert.Equal(t, int64(2), stats.Queries)
+	assert.Equal(t, int64(4), stats.Launches)
+	assert.Equal(t, int64(1), stats.Yields)
+	assert.Zero(t, stats.Waiting)
+}
+
+func TestScheduler_RoundRobinBetweenSessions(t *testing.T) {
+	s := NewScheduler(DefaultSchedulerConfig())
+	log := &schedulerLog{}
+	release := make(chan struct{})
+	held := make(chan struct{})
+
+	var wg sync.WaitGroup
+	run := func(session, name string) {
+		defer wg.Done()
+		assert.NoError(t, s.Run(context.Background(), session, 0, 1, func(int) error {
+			log.add(name)
+			return nil
+		}))
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, s.Run(context.Background(), "holder", 0, 1, func(int) error {
+			close(held)
+			<-release
+			return nil
+		}))
+	}()
+	<-held
+
+	// alice queues two queries before bob queues one
+	for i, q := range []struct{ session, name string }{{"alice", "a1"}, {"alice", "a2"}, {"bob", "b1"}} {
+		wg.Add(1)
+		go run(q.session, q.name)
+		waitQueued(t, s, i+1)
+	}
+	assert.Equal(t, 2, s.Stats().Sessions)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, []string{"a1", "b1", "a2"}, log.events)
+}
+
+func TestScheduler_VRAMLimit(t *testing.T) {
+	s := NewScheduler(SchedulerConfig{MaxQueryVRAMMB: 1})
+	launched := false
+	err := s.Run(context.Background(), "", 2<<20, 1, func(int) error {
+		launched = true
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrQueryVRAMLimit)
+	assert.ErrorIs(t, err, ErrOutOfMemory)
+	assert.False(t, launched)
+	assert.Equal(t, int64(1), s.Stats().VRAMRejected)
+
+	assert.NoError(t, s.Run(context.Background(), "", 1<<20, 1, func(int) error { return nil }))
+}
+
+func TestScheduler_CancelWhileWaiting(t *testing.T) {
+	s := NewScheduler(DefaultSchedulerConfig())
+	held := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.Run(context.Background(), "holder", 0, 1, func(int) error {
+			close(held)
+			<-release
+			return nil
+		})
+	}()
+	<-held
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Run(ctx, "waiter", 0, 1, func(int) error {
+			t.Error("a cancelled query must not launch")
+			return nil
+		})
+	}()
+	waitQueued(t, s, 1)
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	assert.Zero(t, s.Stats().Waiting)
+
+	close(release)
+	<-done
+	assert.NoError(t, s.Run(context.Background(), "after", 0, 1, func(int) error { return nil }), "the device is free again")
+}
+
+func TestScheduler_Chunks(t *testing.T) {
+	s := NewScheduler(SchedulerConfig{ChunkRows: 100})
+	assert.Equal(t, 1, s.Chunks(0))
+	assert.Equal(t, 1, s.Chunks(100))
+	assert.Equal(t, 3, s.Chunks(201))
+
+	var nilScheduler *Scheduler
+	assert.Equal(t, 1, nilScheduler.Chunks(1000))
+	calls := 0
+	require.NoError(t, nilScheduler.Run(context.Background(), "", 1<<40, 2, func(int) error {
+		calls++
+		return nil
+	}))
+	assert.Equal(t, 2, calls)
+}
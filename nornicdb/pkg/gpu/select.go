@@ -0,0 +1,82 @@
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvBackend overrides AutoSelect. It accepts cuda, metal, opencl or vulkan
+// to require that backend, and cpu or none to disable GPU acceleration.
+const EnvBackend = "NORNICDB_GPU_BACKEND"
+
+// selectOrder is the order AutoSelect tries backends in, fastest first.
+var selectOrder = []Backend{BackendCUDA, BackendMetal, BackendOpenCL, BackendVulkan}
+
+// AutoSelect picks the GPU backend for vector search. It returns the
+// backend named by NORNICDB_GPU_BACKEND if set, otherwise the first
+// available backend in the order CUDA, Metal, OpenCL, Vulkan, and
+// BackendNone if none is available.
+//
+// If the override names an unknown or unavailable backend, AutoSelect
+// returns BackendNone and an error saying why, so callers fall back to CPU
+// rather than silently using a different GPU.
+//
+// Example:
+//
+//	backend, err := gpu.AutoSelect()
+//	if err != nil {
+//		log.Printf("GPU disabled: %v", err)
+//	}
+//	if backend == gpu.BackendNone {
+//		return // CPU search
+//	}
+//	accel, err := gpu.NewAcceleratorFor(backend)
+func AutoSelect() (Backend, error) {
+	return selectBackend(os.Getenv(EnvBackend), Probe())
+}
+
+func selectBackend(override string, probes []BackendProbe) (Backend, error) {
+	byBackend := make(map[Backend]BackendProbe, len(probes))
+	for _, p := range probes {
+		byBackend[p.Backend] = p
+	}
+
+	switch b := Backend(strings.ToLower(strings.TrimSpace(override))); b {
+	case "":
+		for _, b := range selectOrder {
+			if byBackend[b].Available {
+				return b, nil
+			}
+		}
+		return BackendNone, nil
+	case "cpu", BackendNone:
+		return BackendNone, nil
+	case BackendCUDA, BackendMetal, BackendOpenCL, BackendVulkan:
+		p, ok := byBackend[b]
+		if !ok {
+			return BackendNone, fmt.Errorf("gpu: %s=%s: backend was not probed", EnvBackend, b)
+		}
+		if !p.Available {
+			return BackendNone, fmt.Errorf("gpu: %s=%s: %s", EnvBackend, b, p.Reason)
+		}
+		return b, nil
+	default:
+		return BackendNone, fmt.Errorf("gpu: %s=%q: unknown backend (want cuda, metal, opencl, vulkan or cpu)", EnvBackend, override)
+	}
+}
+
+// NewAcceleratorFor creates an accelerator on the given backend, typically
+// the one returned by AutoSelect. Unlike NewAccelerator it does not try
+// other backends; it fails if this one cannot be initialized.
+func NewAcceleratorFor(backend Backend) (*Accelerator, error) {
+	accel := &Accelerator{
+		config:  &Config{Enabled: true, PreferredBackend: backend},
+		backend: BackendNone,
+	}
+	if err := accel.tryBackend(backend); err != nil {
+		accel.Release()
+		return nil, err
+	}
+	return accel, nil
+}
@@ -0,0 +1,96 @@
+package gpu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectBackend(t *testing.T) {
+	probes := []BackendProbe{
+		{Backend: BackendCUDA, Reason: "binary built without CUDA support"},
+		{Backend: BackendMetal, Reason: "Metal is only available on macOS"},
+		{Backend: BackendOpenCL, Compiled: true, Available: true},
+		{Backend: BackendVulkan, Compiled: true, Available: true},
+	}
+
+	tests := []struct {
+		override string
+		want     Backend
+		errPart  string
+	}{
+		{"", BackendOpenCL, ""},
+		{"vulkan", BackendVulkan, ""},
+		{" Vulkan ", BackendVulkan, ""},
+		{"cpu", BackendNone, ""},
+		{"none", BackendNone, ""},
+		{"cuda", BackendNone, "built without CUDA"},
+		{"rocm", BackendNone, "unknown backend"},
+	}
+	for _, tt := range tests {
+		got, err := selectBackend(tt.override, probes)
+		if got != tt.want {
+			t.Errorf("selectBackend(%q) = %s, want %s", tt.override, got, tt.want)
+		}
+		if tt.errPart == "" && err != nil {
+			t.Errorf("selectBackend(%q) error = %v", tt.override, err)
+		}
+		if tt.errPart != "" && (err == nil || !strings.Contains(err.Error(), tt.errPart)) {
+			t.Errorf("selectBackend(%q) error = %v, want it to mention %q", tt.override, err, tt.errPart)
+		}
+	}
+
+	if got, err := selectBackend("", probes[:2]); got != BackendNone || err != nil {
+		t.Errorf("with no available backend: got %s, %v; want none, nil", got, err)
+	}
+}
+
+func TestAutoSelect(t *testing.T) {
+	t.Setenv(EnvBackend, "cpu")
+	if got, err := AutoSelect(); got != BackendNone || err != nil {
+		t.Errorf("AutoSelect() with %s=cpu = %s, %v", EnvBackend, got, err)
+	}
+
+	t.Setenv(EnvBackend, "")
+	got, err := AutoSelect()
+	if err != nil {
+		t.Fatalf("AutoSelect() error = %v", err)
+	}
+	if got == BackendNone {
+		t.Skip("no GPU backend available")
+	}
+	accel, err := NewAcceleratorFor(got)
+	if err != nil {
+		t.Fatalf("NewAcceleratorFor(%s) error = %v", got, err)
+	}
+	defer accel.Release()
+	if accel.Backend() != got {
+		t.Errorf("accelerator backend = %s, want %s", accel.Backend(), got)
+	}
+}
+
+func TestSearchWithBackend(t *testing.T) {
+	accel, _ := NewAccelerator(nil)
+	defer accel.Release()
+
+	idx := accel.NewGPUEmbeddingIndex(2)
+	idx.Add("a", []float32{1, 0})
+	idx.Add("b", []float32{0.6, 0.8})
+
+	results, backend, err := idx.SearchWithBackend([]float32{1, 0}, 1)
+	if err != nil {
+		t.Fatalf("SearchWithBackend() error = %v", err)
+	}
+	if backend != BackendNone {
+		t.Errorf("backend = %s, want none for a disabled accelerator", backend)
+	}
+	if len(results) != 1 || results[0].ID != "a" {
+		t.Errorf("results = %+v, want a", results)
+	}
+
+	if v, ok := idx.Get("b"); !ok || v[1] != 0.8 {
+		t.Errorf("Get(b) = %v, %v", v, ok)
+	}
+	if ids := idx.NodeIDs(); len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Errorf("NodeIDs() = %v", ids)
+	}
+}
@@ -0,0 +1,368 @@
+// Package gpu - splitting embedding indexes across GPU buffers.
+//
+// An EmbeddingIndex is normally uploaded as one device buffer. When the
+// device cannot allocate it (VRAM exhausted by other indexes, the embedder
+// or other processes, or a per-buffer size limit), SyncToGPU halves the
+// shard size and retries, down to minShardRows. If even shards of that size
+// do not all fit, the shards that were uploaded stay on the GPU and the rest
+// of the index is scored on CPU from the host copy. Search runs one pass per
+// shard and merges the per-shard top-k, so callers see the same results as
+// with a single buffer.
+//
+// Each search pass also allocates scratch buffers (query, scores, top-k)
+// proportional to the shard size. If those allocations fail, that shard is
+// scored on CPU instead of failing the search.
+//
+// Updates and removals of rows that are already on the device are written
+// to their shard in place (see writeRows), so a synced index stays synced;
+// only new rows need another SyncToGPU.
+package gpu
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/cuda"
+	"github.com/orneryd/nornicdb/pkg/gpu/metal"
+)
+
+// minShardRows is the smallest shard SyncToGPU splits an index into before
+// giving up and returning ErrDataTooLarge.
+const minShardRows = 1024
+
+// deviceShard is one device buffer holding rows [start, start+count) of an
+// EmbeddingIndex. A shard without a buffer did not fit on the device and is
+// scored on CPU.
+type deviceShard struct {
+	start  int
+	count  int
+	buffer shardBuffer
+}
+
+// shardHit is a search result within a shard; index is relative to the
+// shard's first row.
+type shardHit struct {
+	index uint32
+	score float32
+}
+
+// shardDevice uploads blocks of embeddings to a GPU.
+type shardDevice interface {
+	upload(vectors []float32, rows, dimensions int) (shardBuffer, error)
+}
+
+// shardBuffer is a block of embeddings resident on a GPU.
+type shardBuffer interface {
+	search(query []float32, rows, dimensions, k int) ([]shardHit, error)
+	// write overwrites one shard-relative row in place.
+	write(row int, vector []float32) error
+	release()
+}
+
+// isOutOfMemory reports whether err is a device allocation failure.
+func isOutOfMemory(err error) bool {
+	return errors.Is(err, ErrOutOfMemory)
+}
+
+// syncShards uploads vectors to dev, splitting them into smaller shards
+// while allocations fail. Existing shards are released first so their
+// memory is available to the new upload. It returns ErrDataTooLarge only if
+// not even one shard fits.
+func (ei *EmbeddingIndex) syncShards(dev shardDevice, vectors []float32) error {
+	ei.releaseShards()
+
+	rows := len(vectors) / ei.dimensions
+	shardRows := rows
+	for {
+		shards, err := uploadShards(dev, vectors, rows, ei.dimensions, shardRows, shardRows <= minShardRows)
+		if err == nil {
+			ei.shards = shards
+			for _, s := range shards {
+				if s.buffer != nil {
+					ei.gpuAllocated += s.count * ei.dimensions * 4
+				}
+			}
+			return nil
+		}
+		if !isOutOfMemory(err) {
+			return err
+		}
+		if shardRows <= minShardRows {
+			return fmt.Errorf("%w: %d embeddings do not fit in shards of %d: %v", ErrDataTooLarge, rows, shardRows, err)
+		}
+		shardRows = max((shardRows+1)/2, minShardRows)
+	}
+}
+
+// uploadShards uploads vectors in shards of shardRows rows. On failure all
+// shards uploaded so far are released, unless partial is set: then, if more
+// than one shard fit, the last one is released again to leave room for
+// search scratch buffers and the remaining rows become host shards.
+func uploadShards(dev shardDevice, vectors []float32, rows, dimensions, shardRows int, partial bool) ([]deviceShard, error) {
+	var shards []deviceShard
+	for start := 0; start < rows; start += shardRows {
+		count := min(shardRows, rows-start)
+		buffer, err := dev.upload(vectors[start*dimensions:(start+count)*dimensions], count, dimensions)
+		if err != nil {
+			if partial && isOutOfMemory(err) && len(shards) > 1 {
+				last := &shards[len(shards)-1]
+				last.buffer.release()
+				last.buffer = nil
+				return append(shards, deviceShard{start: start, count: rows - start}), nil
+			}
+			for _, s := range shards {
+				s.buffer.release()
+			}
+			return nil, err
+		}
+		shards = append(shards, deviceShard{start: start, count: count, buffer: buffer})
+	}
+	return shards, nil
+}
+
+// residentShards returns the number of shards on the device and the number
+// of rows scored on CPU because they did not fit.
+func (ei *EmbeddingIndex) residentShards() (shards, hostRows int) {
+	for _, s := range ei.shards {
+		if s.buffer != nil {
+			shards++
+		} else {
+			hostRows += s.count
+		}
+	}
+	return shards, hostRows
+}
+
+// writeRows copies rows of cpuVectors that changed in place to the shards
+// holding them, so a synced index stays synced without a full upload. It
+// returns false if the index is not synced or a write fails; the caller
+// must then leave the index for SyncToGPU to upload again.
+func (ei *EmbeddingIndex) writeRows(rows ...int) bool {
+	if !ei.gpuSynced {
+		return false
+	}
+	for _, row := range rows {
+		s, ok := ei.shardFor(row)
+		if !ok {
+			return false
+		}
+		if s.buffer == nil {
+			continue // host shard, scored from cpuVectors
+		}
+		off := row * ei.dimensions
+		if err := s.buffer.write(row-s.start, ei.cpuVectors[off:off+ei.dimensions]); err != nil {
+			return false
+		}
+		atomic.AddInt64(&ei.uploadBytes, int64(ei.dimensions*4))
+		if ei.manager != nil {
+			atomic.AddInt64(&ei.manager.stats.BytesTransferred, int64(ei.dimensions*4))
+		}
+	}
+	return true
+}
+
+// dropLastRow shrinks the shards after Remove truncated the index by one
+// row, writing moved (the row the last one was swapped into, or -1) to its
+// shard. The last shard's buffer keeps its size; rows beyond its count are
+// never searched. It returns false if the index must be uploaded again,
+// including when the last shard would become empty.
+func (ei *EmbeddingIndex) dropLastRow(moved int) bool {
+	if !ei.gpuSynced || len(ei.shards) == 0 {
+		return false
+	}
+	last := &ei.shards[len(ei.shards)-1]
+	if last.count < 2 || last.start+last.count != len(ei.nodeIDs)+1 {
+		return false
+	}
+	last.count--
+	if moved < 0 {
+		return true
+	}
+	return ei.writeRows(moved)
+}
+
+// shardFor returns the shard holding row.
+func (ei *EmbeddingIndex) shardFor(row int) (deviceShard, bool) {
+	for _, s := range ei.shards {
+		if row >= s.start && row < s.start+s.count {
+			return s, true
+		}
+	}
+	return deviceShard{}, false
+}
+
+// releaseShards frees every device buffer held by the index.
+func (ei *EmbeddingIndex) releaseShards() {
+	for _, s := range ei.shards {
+		if s.buffer != nil {
+			s.buffer.release()
+		}
+	}
+	ei.shards = nil
+	ei.gpuAllocated = 0
+}
+
+// searchShards runs the query against every shard and merges the results.
+// A shard whose scratch buffers cannot be allocated is scored on CPU; any
+// other device error is returned.
+func (ei *EmbeddingIndex) searchShards(query []float32, k int) ([]SearchResult, error) {
+	merged := make([]SearchResult, 0, k*len(ei.shards))
+	for _, s := range ei.shards {
+		shardK := min(k, s.count)
+		if s.buffer == nil {
+			atomic.AddInt64(&ei.manager.stats.OperationsCPU, 1)
+			merged = ei.appendHits(merged, s.start, ei.searchRowsCPU(query, s.start, s.count, shardK))
+			continue
+		}
+		hits, err := s.buffer.search(query, s.count, ei.dimensions, shardK)
+		if err != nil {
+			if !isOutOfMemory(err) {
+				return nil, err
+			}
+			atomic.AddInt64(&ei.manager.stats.FallbackCount, 1)
+			hits = ei.searchRowsCPU(query, s.start, s.count, shardK)
+		} else {
+			atomic.AddInt64(&ei.manager.stats.OperationsGPU, 1)
+			atomic.AddInt64(&ei.manager.stats.KernelExecutions, 2) // similarity + topk
+		}
+		merged = ei.appendHits(merged, s.start, hits)
+	}
+
+	if len(ei.shards) > 1 {
+		sort.SliceStable(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+	}
+	if len(merged) > k {
+		merged = merged[:k]
+	}
+	return merged, nil
+}
+
+// appendHits converts shard-relative hits to results.
+func (ei *EmbeddingIndex) appendHits(results []SearchResult, start int, hits []shardHit) []SearchResult {
+	for _, h := range hits {
+		idx := start + int(h.index)
+		if idx < len(ei.nodeIDs) {
+			results = append(results, SearchResult{
+				ID:       ei.nodeIDs[idx],
+				Score:    h.score,
+				Distance: 1 - h.score,
+			})
+		}
+	}
+	return results
+}
+
+// searchRowsCPU scores rows [start, start+count) on CPU and returns the
+// top k as shard-relative hits.
+func (ei *EmbeddingIndex) searchRowsCPU(query []float32, start, count, k int) []shardHit {
+	scores := make([]float32, count)
+	indices := make([]int, count)
+	for i := 0; i < count; i++ {
+		off := (start + i) * ei.dimensions
+		scores[i] = cosineSimilarityFlat(query, ei.cpuVectors[off:off+ei.dimensions])
+		indices[i] = i
+	}
+	partialSort(indices, scores, k)
+
+	hits := make([]shardHit, k)
+	for i := 0; i < k; i++ {
+		hits[i] = shardHit{index: uint32(indices[i]), score: scores[indices[i]]}
+	}
+	return hits
+}
+
+// cudaShards uploads shards to a CUDA device. Vectors are normalized on
+// upload so searches can skip per-row norms.
+type cudaShards struct {
+	ei *EmbeddingIndex
+}
+
+type cudaShard struct {
+	device *cuda.Device
+	buffer *cuda.Buffer
+}
+
+func (c cudaShards) upload(vectors []float32, rows, dimensions int) (shardBuffer, error) {
+	buffer, err := c.ei.uploadCUDA(vectors)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ei.cudaDevice.NormalizeVectors(buffer, uint32(rows), uint32(dimensions)); err != nil {
+		// Non-fatal: we can still search with unnormalized vectors
+		// (but slower since we need to normalize each query)
+	}
+	return cudaShard{device: c.ei.cudaDevice, buffer: buffer}, nil
+}
+
+func (s cudaShard) search(query []float32, rows, dimensions, k int) ([]shardHit, error) {
+	results, err := s.device.Search(s.buffer, query, uint32(rows), uint32(dimensions), k, cuda.SearchOptions{Normalized: true})
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]shardHit, len(results))
+	for i, r := range results {
+		hits[i] = shardHit{index: r.Index, score: r.Score}
+	}
+	return hits, nil
+}
+
+// write normalizes vector on the host, matching what upload did on the
+// device, and overwrites the row.
+func (s cudaShard) write(row int, vector []float32) error {
+	normalized := make([]float32, len(vector))
+	var norm float32
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = sqrt32(norm)
+		for i, v := range vector {
+			normalized[i] = v / norm
+		}
+	}
+	return s.buffer.WriteAt(row*len(vector), normalized)
+}
+
+func (s cudaShard) release() {
+	s.buffer.Release()
+}
+
+// metalShards uploads shards to a Metal device in shared storage.
+type metalShards struct {
+	device *metal.Device
+}
+
+type metalShard struct {
+	device *metal.Device
+	buffer *metal.Buffer
+}
+
+func (m metalShards) upload(vectors []float32, rows, dimensions int) (shardBuffer, error) {
+	buffer, err := m.device.NewBuffer(vectors, metal.StorageShared)
+	if err != nil {
+		return nil, err
+	}
+	return metalShard{device: m.device, buffer: buffer}, nil
+}
+
+func (s metalShard) search(query []float32, rows, dimensions, k int) ([]shardHit, error) {
+	results, err := s.device.Search(s.buffer, query, uint32(rows), uint32(dimensions), k, metal.SearchOptions{Normalized: true})
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]shardHit, len(results))
+	for i, r := range results {
+		hits[i] = shardHit{index: r.Index, score: r.Score}
+	}
+	return hits, nil
+}
+
+func (s metalShard) write(row int, vector []float32) error {
+	return s.buffer.WriteAt(row*len(vector), vector)
+}
+
+func (s metalShard) release() {
+	s.buffer.Release()
+}
@@ -0,0 +1,347 @@
+package gpu
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// lowMemoryDevice simulates a GPU with a fixed VRAM budget and, optionally,
+// a maximum buffer size. Uploads and search scratch buffers (one float32 per
+// row) both count against the budget.
+type lowMemoryDevice struct {
+	budget    int // bytes
+	maxAlloc  int // bytes per buffer, 0 = unlimited
+	allocated int
+	uploads   int
+	failed    int
+	writes    int  // rows written in place
+	failWrite bool // make in-place writes fail
+}
+
+type lowMemoryBuffer struct {
+	dev     *lowMemoryDevice
+	vectors []float32
+	bytes   int
+}
+
+func (d *lowMemoryDevice) alloc(bytes int) error {
+	if d.allocated+bytes > d.budget || (d.maxAlloc > 0 && bytes > d.maxAlloc) {
+		d.failed++
+		return fmt.Errorf("%w: %d bytes requested, %d free", ErrOutOfMemory, bytes, d.budget-d.allocated)
+	}
+	d.allocated += bytes
+	return nil
+}
+
+func (d *lowMemoryDevice) upload(vectors []float32, rows, dimensions int) (shardBuffer, error) {
+	if err := d.alloc(len(vectors) * 4); err != nil {
+		return nil, err
+	}
+	d.uploads++
+	return &lowMemoryBuffer{dev: d, vectors: append([]float32(nil), vectors...), bytes: len(vectors) * 4}, nil
+}
+
+func (b *lowMemoryBuffer) search(query []float32, rows, dimensions, k int) ([]shardHit, error) {
+	scratch := rows * 4
+	if err := b.dev.alloc(scratch); err != nil {
+		return nil, err
+	}
+	defer func() { b.dev.allocated -= scratch }()
+
+	scores := make([]float32, rows)
+	indices := make([]int, rows)
+	for i := range scores {
+		scores[i] = cosineSimilarityFlat(query, b.vectors[i*dimensions:(i+1)*dimensions])
+		indices[i] = i
+	}
+	partialSort(indices, scores, k)
+	hits := make([]shardHit, k)
+	for i := range hits {
+		hits[i] = shardHit{index: uint32(indices[i]), score: scores[indices[i]]}
+	}
+	return hits, nil
+}
+
+func (b *lowMemoryBuffer) write(row int, vector []float32) error {
+	if b.dev.failWrite {
+		return errors.New("write failed")
+	}
+	b.dev.writes++
+	copy(b.vectors[row*len(vector):], vector)
+	return nil
+}
+
+func (b *lowMemoryBuffer) release() {
+	b.dev.allocated -= b.bytes
+}
+
+func newShardedIndex(t *testing.T, dev *lowMemoryDevice, n, dims int) *EmbeddingIndex {
+	t.Helper()
+	ei := NewEmbeddingIndex(enabledManager(BackendCUDA, true), &EmbeddingIndexConfig{Dimensions: dims})
+	ei.shardDev = dev
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		vec := make([]float32, dims)
+		for j := range vec {
+			vec[j] = rng.Float32()*2 - 1
+		}
+		if err := ei.Add(fmt.Sprintf("n%d", i), vec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ei
+}
+
+func assertSameResults(t *testing.T, got, want []SearchResult) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID {
+			t.Errorf("result %d: got %s (%.4f), want %s (%.4f)", i, got[i].ID, got[i].Score, want[i].ID, want[i].Score)
+		}
+	}
+}
+
+func TestEmbeddingIndexSyncSplitsOnOutOfMemory(t *testing.T) {
+	const n, dims = 5000, 16
+	// Enough VRAM in total, but no buffer may hold more than a third.
+	dev := &lowMemoryDevice{budget: 1 << 30, maxAlloc: n * dims * 4 / 3}
+	ei := newShardedIndex(t, dev, n, dims)
+
+	if err := ei.SyncToGPU(); err != nil {
+		t.Fatalf("SyncToGPU: %v", err)
+	}
+	stats := ei.Stats()
+	if !stats.GPUSynced {
+		t.Fatal("index should be synced")
+	}
+	if stats.GPUShards < 3 || stats.CPURows != 0 {
+		t.Errorf("expected at least 3 shards and no CPU rows, got %d shards, %d CPU rows", stats.GPUShards, stats.CPURows)
+	}
+	if dev.failed == 0 {
+		t.Error("expected the full upload to fail first")
+	}
+
+	query := make([]float32, dims)
+	for i := range query {
+		query[i] = float32(i%3) - 1
+	}
+	got, err := ei.Search(query, 25)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	want, _ := ei.searchCPU(query, 25)
+	assertSameResults(t, got, want)
+	if ei.manager.stats.FallbackCount != 0 {
+		t.Errorf("expected no CPU fallback, got %d", ei.manager.stats.FallbackCount)
+	}
+	if ei.manager.stats.OperationsGPU != int64(stats.GPUShards) {
+		t.Errorf("expected one GPU pass per shard, got %d passes for %d shards",
+			ei.manager.stats.OperationsGPU, stats.GPUShards)
+	}
+}
+
+func TestEmbeddingIndexSyncPartiallyResident(t *testing.T) {
+	const n, dims = 8 * minShardRows, 8
+	// Room for about half of the index.
+	dev := &lowMemoryDevice{budget: n * dims * 4 / 2}
+	ei := newShardedIndex(t, dev, n, dims)
+
+	if err := ei.SyncToGPU(); err != nil {
+		t.Fatalf("SyncToGPU: %v", err)
+	}
+	stats := ei.Stats()
+	if stats.GPUShards == 0 || stats.CPURows == 0 {
+		t.Fatalf("expected a mix of GPU shards and CPU rows, got %d shards, %d CPU rows", stats.GPUShards, stats.CPURows)
+	}
+	if stats.GPUShards*minShardRows+stats.CPURows != n {
+		t.Errorf("shards cover %d rows, want %d", stats.GPUShards*minShardRows+stats.CPURows, n)
+	}
+	if dev.allocated >= dev.budget {
+		t.Error("expected headroom left for search scratch buffers")
+	}
+
+	query := []float32{0.3, -0.2, 0.9, 0, 0.1, -0.5, 0.4, 0.2}
+	got, err := ei.Search(query, 20)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	want, _ := ei.searchCPU(query, 20)
+	assertSameResults(t, got, want)
+	if ei.manager.stats.FallbackCount != 0 {
+		t.Errorf("expected resident shards to be searched on GPU, fallback count %d", ei.manager.stats.FallbackCount)
+	}
+}
+
+func TestEmbeddingIndexSyncSingleShardWhenItFits(t *testing.T) {
+	dev := &lowMemoryDevice{budget: 1 << 30}
+	ei := newShardedIndex(t, dev, 2000, 8)
+
+	if err := ei.SyncToGPU(); err != nil {
+		t.Fatalf("SyncToGPU: %v", err)
+	}
+	if shards := ei.Stats().GPUShards; shards != 1 {
+		t.Errorf("expected 1 shard, got %d", shards)
+	}
+	if dev.failed != 0 {
+		t.Errorf("expected no failed allocations, got %d", dev.failed)
+	}
+
+	// Resync releases the old buffers before uploading.
+	if err := ei.SyncToGPU(); err != nil {
+		t.Fatalf("SyncToGPU: %v", err)
+	}
+	if dev.allocated != 2000*8*4 {
+		t.Errorf("expected only the current upload to be allocated, got %d bytes", dev.allocated)
+	}
+	ei.Release()
+	if dev.allocated != 0 {
+		t.Errorf("expected all buffers released, got %d bytes", dev.allocated)
+	}
+}
+
+func TestEmbeddingIndexSyncTooLarge(t *testing.T) {
+	const dims = 8
+	// Not even a minimum-size shard fits.
+	dev := &lowMemoryDevice{budget: minShardRows * dims * 4 / 2}
+	ei := newShardedIndex(t, dev, 3*minShardRows, dims)
+
+	err := ei.SyncToGPU()
+	if !errors.Is(err, ErrDataTooLarge) {
+		t.Fatalf("expected ErrDataTooLarge, got %v", err)
+	}
+	if dev.allocated != 0 {
+		t.Errorf("partial uploads must be released, %d bytes still allocated", dev.allocated)
+	}
+	if ei.Stats().GPUShards != 0 {
+		t.Error("expected no shards")
+	}
+
+	// Search still works on CPU.
+	results, err := ei.Search(make([]float32, dims), 5)
+	if err != nil || len(results) != 5 {
+		t.Errorf("expected 5 CPU results, got %d (%v)", len(results), err)
+	}
+}
+
+func TestEmbeddingIndexSearchScratchOutOfMemory(t *testing.T) {
+	const n, dims = 3000, 4
+	// The whole index fits, but leaves no room for search scratch buffers.
+	dev := &lowMemoryDevice{budget: n * dims * 4}
+	ei := newShardedIndex(t, dev, n, dims)
+
+	if err := ei.SyncToGPU(); err != nil {
+		t.Fatalf("SyncToGPU: %v", err)
+	}
+	query := []float32{1, -1, 0.5, 0}
+	got, err := ei.Search(query, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	want, _ := ei.searchCPU(query, 10)
+	assertSameResults(t, got, want)
+	if ei.manager.stats.FallbackCount != 1 {
+		t.Errorf("expected the shard to be scored on CPU, fallback count %d", ei.manager.stats.FallbackCount)
+	}
+}
+
+func TestEmbeddingIndexUpdateWritesInPlace(t *testing.T) {
+	const n, dims = 3000, 8
+	// Two shards, so updates land in different buffers.
+	dev := &lowMemoryDevice{budget: 1 << 30, maxAlloc: n * dims * 4 / 2}
+	ei := newShardedIndex(t, dev, n, dims)
+	if err := ei.SyncToGPU(); err != nil {
+		t.Fatalf("SyncToGPU: %v", err)
+	}
+	uploads := dev.uploads
+
+	query := []float32{1, 0, 0, 0, 0, 0, 0, 0}
+	if err := ei.Add("n10", query); err != nil {
+		t.Fatal(err)
+	}
+	if err := ei.AddBatch([]string{"n2500", "n7"}, [][]float32{query, {0, 1, 0, 0, 0, 0, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if !ei.Stats().GPUSynced {
+		t.Fatal("updating indexed nodes should keep the index synced")
+	}
+	if dev.uploads != uploads || dev.writes != 3 {
+		t.Errorf("expected 3 row writes and no uploads, got %d writes, %d uploads", dev.writes, dev.uploads-uploads)
+	}
+
+	got, err := ei.Search(query, 5)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	want, _ := ei.searchCPU(query, 5)
+	assertSameResults(t, got, want)
+	if ei.manager.stats.OperationsGPU == 0 {
+		t.Error("expected the search to run on GPU")
+	}
+
+	// New nodes need a new upload.
+	if err := ei.Add("new", query); err != nil {
+		t.Fatal(err)
+	}
+	if ei.Stats().GPUSynced {
+		t.Error("adding a node should clear the sync flag")
+	}
+}
+
+func TestEmbeddingIndexRemoveKeepsSync(t *testing.T) {
+	const n, dims = 2000, 8
+	dev := &lowMemoryDevice{budget: 1 << 30}
+	ei := newShardedIndex(t, dev, n, dims)
+	if err := ei.SyncToGPU(); err != nil {
+		t.Fatalf("SyncToGPU: %v", err)
+	}
+
+	// Removing a middle row moves the last row into its place.
+	ei.Remove("n5")
+	ei.Remove(fmt.Sprintf("n%d", n-2)) // now the last row
+	if !ei.Stats().GPUSynced {
+		t.Fatal("removing nodes should keep the index synced")
+	}
+	if dev.writes != 1 {
+		t.Errorf("expected 1 row write for the moved embedding, got %d", dev.writes)
+	}
+
+	query := []float32{0.3, -0.2, 0.9, 0, 0.1, -0.5, 0.4, 0.2}
+	got, err := ei.Search(query, 20)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	want, _ := ei.searchCPU(query, 20)
+	assertSameResults(t, got, want)
+	for _, r := range got {
+		if r.ID == "n5" {
+			t.Error("removed node returned by search")
+		}
+	}
+}
+
+func TestEmbeddingIndexFailedWriteNeedsSync(t *testing.T) {
+	dev := &lowMemoryDevice{budget: 1 << 30}
+	ei := newShardedIndex(t, dev, 100, 4)
+	if err := ei.SyncToGPU(); err != nil {
+		t.Fatalf("SyncToGPU: %v", err)
+	}
+
+	dev.failWrite = true
+	if err := ei.Add("n1", []float32{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	if ei.Stats().GPUSynced {
+		t.Fatal("a failed row write should clear the sync flag")
+	}
+
+	// Searches fall back to CPU and see the update.
+	got, err := ei.Search([]float32{1, 2, 3, 4}, 1)
+	if err != nil || len(got) != 1 || got[0].ID != "n1" {
+		t.Errorf("expected n1 first, got %v (%v)", got, err)
+	}
+}
@@ -0,0 +1,561 @@
+// Package gpu - hot/warm/cold tiering of embeddings.
+//
+// Large collections do not fit in VRAM, and the largest do not fit in RAM.
+// TieredIndex keeps the most frequently retrieved vectors on the GPU (hot),
+// the next most frequent in host memory (warm) and the rest in a slot file on
+// disk (cold). Searches run over all three tiers and merge the results, so
+// callers never see where a vector lives. Rebalance moves vectors between
+// tiers by decayed access frequency.
+package gpu
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ErrTiersFull is returned when a vector does not fit in the hot or warm tier
+// and no cold tier file is configured.
+var ErrTiersFull = errors.New("gpu: all embedding tiers are full")
+
+// Tier identifies where a vector is stored.
+type Tier int
+
+const (
+	TierHot  Tier = iota // GPU memory
+	TierWarm             // Host memory
+	TierCold             // Disk
+)
+
+// String returns the tier name.
+func (t Tier) String() string {
+	switch t {
+	case TierHot:
+		return "hot"
+	case TierWarm:
+		return "warm"
+	case TierCold:
+		return "cold"
+	default:
+		return "unknown"
+	}
+}
+
+// TieredIndexConfig configures a TieredIndex.
+type TieredIndexConfig struct {
+	Dimensions   int     // Embedding dimensions (e.g., 1024)
+	HotCapacity  int     // Vectors resident on the GPU (default: 100000)
+	WarmCapacity int     // Vectors held in host memory (default: 1000000)
+	ColdPath     string  // Slot file for cold vectors ("" = no cold tier)
+	AccessDecay  float64 // Access counts are multiplied by this on each Rebalance (default: 0.5)
+}
+
+// DefaultTieredIndexConfig returns sensible defaults.
+func DefaultTieredIndexConfig(dimensions int) *TieredIndexConfig {
+	return &TieredIndexConfig{
+		Dimensions:   dimensions,
+		HotCapacity:  100000,
+		WarmCapacity: 1000000,
+		AccessDecay:  0.5,
+	}
+}
+
+// TieredIndexStats holds per-tier vector counts and rebalance totals.
+type TieredIndexStats struct {
+	Hot      int
+	Warm     int
+	Cold     int
+	Promoted int64 // Vectors moved to a faster tier
+	Demoted  int64 // Vectors moved to a slower tier
+}
+
+// TieredIndex stores embeddings across GPU, host memory and disk.
+//
+// Example:
+//
+//	config := gpu.DefaultTieredIndexConfig(1024)
+//	config.ColdPath = filepath.Join(dataDir, "cold_vectors.bin")
+//	ti, err := gpu.NewTieredIndex(manager, config)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer ti.Close()
+//
+//	ti.Add("node-1", embedding) // Lands in the fastest tier with room
+//	results, _ := ti.Search(query, 10)
+//
+//	// Periodically: promote frequently retrieved vectors, demote idle ones
+//	ti.Rebalance()
+//
+// Thread Safety:
+//
+//	All methods are thread-safe. Searches run concurrently with each other.
+type TieredIndex struct {
+	config *TieredIndexConfig
+	hot    *EmbeddingIndex
+	warm   *EmbeddingIndex
+	cold   *coldStore
+
+	tiers    map[string]Tier
+	hotDirty bool // Hot tier changed since the last GPU upload
+	mu       sync.RWMutex
+
+	access   map[string]float64 // Decayed retrieval counts
+	accessMu sync.Mutex
+
+	promoted int64
+	demoted  int64
+}
+
+// NewTieredIndex creates an empty tiered index. The hot tier uses the GPU
+// when the manager is enabled and falls back to CPU search otherwise.
+func NewTieredIndex(manager *Manager, config *TieredIndexConfig) (*TieredIndex, error) {
+	if config == nil {
+		config = DefaultTieredIndexConfig(1024)
+	}
+	defaults := DefaultTieredIndexConfig(config.Dimensions)
+	if config.HotCapacity < 0 {
+		config.HotCapacity = 0
+	}
+	if config.WarmCapacity < 0 {
+		config.WarmCapacity = 0
+	}
+	if config.AccessDecay <= 0 || config.AccessDecay > 1 {
+		config.AccessDecay = defaults.AccessDecay
+	}
+
+	if manager == nil {
+		manager = &Manager{} // Disabled: hot tier searches on the CPU
+	}
+
+	ti := &TieredIndex{
+		config: config,
+		hot:    NewEmbeddingIndex(manager, &EmbeddingIndexConfig{Dimensions: config.Dimensions, InitialCap: min(config.HotCapacity, 10000)}),
+		warm:   NewEmbeddingIndex(&Manager{}, &EmbeddingIndexConfig{Dimensions: config.Dimensions, InitialCap: min(config.WarmCapacity, 10000)}),
+		tiers:  make(map[string]Tier),
+		access: make(map[string]float64),
+	}
+	if config.ColdPath != "" {
+		cold, err := openColdStore(config.ColdPath, config.Dimensions)
+		if err != nil {
+			return nil, err
+		}
+		ti.cold = cold
+	}
+	return ti, nil
+}
+
+// Add inserts or updates an embedding. Updates stay in the vector's current
+// tier; new vectors go to the fastest tier with room.
+func (ti *TieredIndex) Add(nodeID string, embedding []float32) error {
+	if len(embedding) != ti.config.Dimensions {
+		return ErrInvalidDimensions
+	}
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	tier, exists := ti.tiers[nodeID]
+	if !exists {
+		switch {
+		case ti.hot.Count() < ti.config.HotCapacity:
+			tier = TierHot
+		case ti.warm.Count() < ti.config.WarmCapacity:
+			tier = TierWarm
+		case ti.cold != nil:
+			tier = TierCold
+		default:
+			return ErrTiersFull
+		}
+	}
+	if err := ti.putLocked(tier, nodeID, embedding); err != nil {
+		return err
+	}
+	ti.tiers[nodeID] = tier
+	return nil
+}
+
+// Remove deletes an embedding from whichever tier holds it.
+func (ti *TieredIndex) Remove(nodeID string) bool {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	tier, exists := ti.tiers[nodeID]
+	if !exists {
+		return false
+	}
+	ti.deleteLocked(tier, nodeID)
+	delete(ti.tiers, nodeID)
+
+	ti.accessMu.Lock()
+	delete(ti.access, nodeID)
+	ti.accessMu.Unlock()
+	return true
+}
+
+// Get returns the embedding for a node from whichever tier holds it.
+func (ti *TieredIndex) Get(nodeID string) ([]float32, bool) {
+	ti.mu.RLock()
+	defer ti.mu.RUnlock()
+	tier, exists := ti.tiers[nodeID]
+	if !exists {
+		return nil, false
+	}
+	return ti.getLocked(tier, nodeID)
+}
+
+// TierOf returns the tier holding a node's embedding.
+func (ti *TieredIndex) TierOf(nodeID string) (Tier, bool) {
+	ti.mu.RLock()
+	defer ti.mu.RUnlock()
+	tier, exists := ti.tiers[nodeID]
+	return tier, exists
+}
+
+// Count returns the number of embeddings across all tiers.
+func (ti *TieredIndex) Count() int {
+	ti.mu.RLock()
+	defer ti.mu.RUnlock()
+	return len(ti.tiers)
+}
+
+// Stats returns per-tier counts and rebalance totals.
+func (ti *TieredIndex) Stats() TieredIndexStats {
+	ti.mu.RLock()
+	defer ti.mu.RUnlock()
+	stats := TieredIndexStats{
+		Hot:      ti.hot.Count(),
+		Warm:     ti.warm.Count(),
+		Promoted: ti.promoted,
+		Demoted:  ti.demoted,
+	}
+	if ti.cold != nil {
+		stats.Cold = ti.cold.count()
+	}
+	return stats
+}
+
+// Search finds the k most similar embeddings across all tiers, sorted by
+// cosine similarity (descending). Returned vectors count as accessed for
+// the next Rebalance.
+func (ti *TieredIndex) Search(query []float32, k int) ([]SearchResult, error) {
+	if len(query) != ti.config.Dimensions {
+		return nil, ErrInvalidDimensions
+	}
+
+	ti.mu.RLock()
+	dirty := ti.hotDirty
+	ti.mu.RUnlock()
+	if dirty && ti.hot.manager.IsEnabled() {
+		ti.mu.Lock()
+		if ti.hotDirty {
+			if err := ti.hot.SyncToGPU(); err == nil {
+				ti.hotDirty = false
+			}
+		}
+		ti.mu.Unlock()
+	}
+
+	ti.mu.RLock()
+	var results []SearchResult
+	for _, idx := range []*EmbeddingIndex{ti.hot, ti.warm} {
+		tierResults, err := idx.Search(query, k)
+		if err != nil {
+			ti.mu.RUnlock()
+			return nil, err
+		}
+		results = append(results, tierResults...)
+	}
+	if ti.cold != nil {
+		coldResults, err := ti.cold.search(query, k)
+		if err != nil {
+			ti.mu.RUnlock()
+			return nil, err
+		}
+		results = append(results, coldResults...)
+	}
+	ti.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+
+	ti.accessMu.Lock()
+	for _, r := range results {
+		ti.access[r.ID]++
+	}
+	ti.accessMu.Unlock()
+	return results, nil
+}
+
+// Rebalance decays access counts and moves vectors so that the most
+// accessed fill the hot tier, the next most accessed the warm tier, and the
+// rest go cold. Ties keep vectors where they are. Returns the number of
+// vectors promoted and demoted.
+func (ti *TieredIndex) Rebalance() (promoted, demoted int, err error) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	ti.accessMu.Lock()
+	for id, count := range ti.access {
+		count *= ti.config.AccessDecay
+		if count < 0.01 {
+			delete(ti.access, id)
+		} else {
+			ti.access[id] = count
+		}
+	}
+	access := make(map[string]float64, len(ti.access))
+	for id, count := range ti.access {
+		access[id] = count
+	}
+	ti.accessMu.Unlock()
+
+	ids := make([]string, 0, len(ti.tiers))
+	for id := range ti.tiers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := ids[i], ids[j]
+		if access[a] != access[b] {
+			return access[a] > access[b]
+		}
+		if ti.tiers[a] != ti.tiers[b] {
+			return ti.tiers[a] < ti.tiers[b]
+		}
+		return a < b
+	})
+
+	target := func(rank int) Tier {
+		switch {
+		case rank < ti.config.HotCapacity:
+			return TierHot
+		case rank < ti.config.HotCapacity+ti.config.WarmCapacity || ti.cold == nil:
+			return TierWarm
+		default:
+			return TierCold
+		}
+	}
+
+	// Demote first so promotions find room
+	type move struct {
+		id       string
+		from, to Tier
+	}
+	var moves []move
+	for rank, id := range ids {
+		if to := target(rank); to != ti.tiers[id] {
+			moves = append(moves, move{id: id, from: ti.tiers[id], to: to})
+		}
+	}
+	sort.SliceStable(moves, func(i, j int) bool { return moves[i].to > moves[j].to })
+
+	for _, m := range moves {
+		vec, ok := ti.getLocked(m.from, m.id)
+		if !ok {
+			continue
+		}
+		if err := ti.putLocked(m.to, m.id, vec); err != nil {
+			return promoted, demoted, fmt.Errorf("gpu: moving %s to %s tier: %w", m.id, m.to, err)
+		}
+		ti.deleteLocked(m.from, m.id)
+		ti.tiers[m.id] = m.to
+		if m.to < m.from {
+			promoted++
+		} else {
+			demoted++
+		}
+	}
+	ti.promoted += int64(promoted)
+	ti.demoted += int64(demoted)
+
+	if ti.hotDirty && ti.hot.manager.IsEnabled() {
+		if err := ti.hot.SyncToGPU(); err == nil {
+			ti.hotDirty = false
+		}
+	}
+	return promoted, demoted, nil
+}
+
+// Close releases GPU memory and closes the cold tier file.
+func (ti *TieredIndex) Close() error {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	ti.hot.Release()
+	if ti.cold != nil {
+		return ti.cold.close()
+	}
+	return nil
+}
+
+func (ti *TieredIndex) putLocked(tier Tier, nodeID string, embedding []float32) error {
+	switch tier {
+	case TierHot:
+		ti.hotDirty = true
+		return ti.hot.Add(nodeID, embedding)
+	case TierWarm:
+		return ti.warm.Add(nodeID, embedding)
+	default:
+		if ti.cold == nil {
+			return ErrTiersFull
+		}
+		return ti.cold.put(nodeID, embedding)
+	}
+}
+
+func (ti *TieredIndex) getLocked(tier Tier, nodeID string) ([]float32, bool) {
+	switch tier {
+	case TierHot:
+		return ti.hot.Get(nodeID)
+	case TierWarm:
+		return ti.warm.Get(nodeID)
+	default:
+		return ti.cold.get(nodeID)
+	}
+}
+
+func (ti *TieredIndex) deleteLocked(tier Tier, nodeID string) {
+	switch tier {
+	case TierHot:
+		ti.hotDirty = true
+		ti.hot.Remove(nodeID)
+	case TierWarm:
+		ti.warm.Remove(nodeID)
+	default:
+		ti.cold.remove(nodeID)
+	}
+}
+
+// coldScanSlots is how many slots a cold search reads per disk access.
+const coldScanSlots = 1024
+
+// coldStore keeps vectors in fixed-size slots of a file
+// (dimensions × float32, little-endian). Freed slots are reused. The slot
+// table lives in memory; the file is scratch space recreated on open.
+type coldStore struct {
+	file       *os.File
+	dimensions int
+	slots      []string // Node ID per slot ("" = free)
+	idToSlot   map[string]int
+	free       []int
+}
+
+func openColdStore(path string, dimensions int) (*coldStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("gpu: opening cold tier: %w", err)
+	}
+	return &coldStore{file: f, dimensions: dimensions, idToSlot: make(map[string]int)}, nil
+}
+
+func (c *coldStore) slotSize() int64 {
+	return int64(c.dimensions) * 4
+}
+
+func (c *coldStore) count() int {
+	return len(c.idToSlot)
+}
+
+func (c *coldStore) put(nodeID string, embedding []float32) error {
+	slot, exists := c.idToSlot[nodeID]
+	if !exists {
+		if n := len(c.free); n > 0 {
+			slot = c.free[n-1]
+			c.free = c.free[:n-1]
+		} else {
+			slot = len(c.slots)
+			c.slots = append(c.slots, "")
+		}
+	}
+
+	buf := make([]byte, c.slotSize())
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	if _, err := c.file.WriteAt(buf, int64(slot)*c.slotSize()); err != nil {
+		if !exists {
+			c.free = append(c.free, slot)
+		}
+		return fmt.Errorf("gpu: writing cold tier: %w", err)
+	}
+	c.slots[slot] = nodeID
+	c.idToSlot[nodeID] = slot
+	return nil
+}
+
+func (c *coldStore) get(nodeID string) ([]float32, bool) {
+	slot, exists := c.idToSlot[nodeID]
+	if !exists {
+		return nil, false
+	}
+	buf := make([]byte, c.slotSize())
+	if _, err := c.file.ReadAt(buf, int64(slot)*c.slotSize()); err != nil {
+		return nil, false
+	}
+	vec := make([]float32, c.dimensions)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return vec, true
+}
+
+func (c *coldStore) remove(nodeID string) {
+	slot, exists := c.idToSlot[nodeID]
+	if !exists {
+		return
+	}
+	delete(c.idToSlot, nodeID)
+	c.slots[slot] = ""
+	c.free = append(c.free, slot)
+}
+
+// search scans the slot file sequentially in chunks and returns the top k.
+func (c *coldStore) search(query []float32, k int) ([]SearchResult, error) {
+	if len(c.idToSlot) == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, coldScanSlots*c.slotSize())
+	vec := make([]float32, c.dimensions)
+	var results []SearchResult
+	for start := 0; start < len(c.slots); start += coldScanSlots {
+		end := min(start+coldScanSlots, len(c.slots))
+		chunk := buf[:int64(end-start)*c.slotSize()]
+		if _, err := c.file.ReadAt(chunk, int64(start)*c.slotSize()); err != nil {
+			return nil, fmt.Errorf("gpu: reading cold tier: %w", err)
+		}
+		for slot := start; slot < end; slot++ {
+			id := c.slots[slot]
+			if id == "" {
+				continue
+			}
+			off := int64(slot-start) * c.slotSize()
+			for i := range vec {
+				vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(chunk[off+int64(i)*4:]))
+			}
+			score := cosineSimilarityFlat(query, vec)
+			results = append(results, SearchResult{ID: id, Score: score, Distance: 1 - score})
+		}
+		// Keep memory bounded by the result size, not the tier size
+		if len(results) > 4*k {
+			sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+			results = results[:k]
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+func (c *coldStore) close() error {
+	return c.file.Close()
+}
@@ -0,0 +1,160 @@
+package gpu
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func tieredTestVector(i, dims int) []float32 {
+	vec := make([]float32, dims)
+	vec[i%dims] = 1
+	vec[(i+1)%dims] = float32(i%7) / 10
+	return vec
+}
+
+func newTestTieredIndex(t *testing.T, hot, warm int) *TieredIndex {
+	t.Helper()
+	ti, err := NewTieredIndex(nil, &TieredIndexConfig{
+		Dimensions:   8,
+		HotCapacity:  hot,
+		WarmCapacity: warm,
+		ColdPath:     filepath.Join(t.TempDir(), "cold.bin"),
+	})
+	if err != nil {
+		t.Fatalf("NewTieredIndex() error = %v", err)
+	}
+	t.Cleanup(func() { ti.Close() })
+	return ti
+}
+
+func TestTieredIndexPlacementAndSearch(t *testing.T) {
+	ti := newTestTieredIndex(t, 2, 3)
+	for i := 0; i < 10; i++ {
+		if err := ti.Add(fmt.Sprintf("n%d", i), tieredTestVector(i, 8)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	stats := ti.Stats()
+	if stats.Hot != 2 || stats.Warm != 3 || stats.Cold != 5 {
+		t.Fatalf("tier counts = %+v, want 2/3/5", stats)
+	}
+	if tier, _ := ti.TierOf("n9"); tier != TierCold {
+		t.Errorf("n9 tier = %v, want cold", tier)
+	}
+
+	// Each tier's vectors are found, and results merge across tiers
+	for _, id := range []string{"n0", "n3", "n8"} {
+		var i int
+		fmt.Sscanf(id, "n%d", &i)
+		results, err := ti.Search(tieredTestVector(i, 8), 3)
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		if len(results) != 3 || results[0].ID != id {
+			t.Fatalf("Search(%s) = %v", id, results)
+		}
+		for j := 1; j < len(results); j++ {
+			if results[j].Score > results[j-1].Score {
+				t.Errorf("results not sorted: %v", results)
+			}
+		}
+	}
+
+	// Updates stay in place; cold reads round-trip
+	updated := tieredTestVector(3, 8)
+	if err := ti.Add("n8", updated); err != nil {
+		t.Fatalf("Add() update error = %v", err)
+	}
+	got, ok := ti.Get("n8")
+	if !ok || got[3] != 1 {
+		t.Errorf("Get(n8) = %v, %v", got, ok)
+	}
+	if ti.Count() != 10 || ti.Stats().Cold != 5 {
+		t.Errorf("update should not add: %+v", ti.Stats())
+	}
+
+	if _, err := ti.Search(make([]float32, 4), 1); !errors.Is(err, ErrInvalidDimensions) {
+		t.Errorf("expected ErrInvalidDimensions, got %v", err)
+	}
+}
+
+func TestTieredIndexRebalance(t *testing.T) {
+	ti := newTestTieredIndex(t, 2, 2)
+	for i := 0; i < 8; i++ {
+		if err := ti.Add(fmt.Sprintf("n%d", i), tieredTestVector(i, 8)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	// Retrieve two cold vectors repeatedly
+	for r := 0; r < 3; r++ {
+		for _, i := range []int{6, 7} {
+			if _, err := ti.Search(tieredTestVector(i, 8), 1); err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+		}
+	}
+
+	promoted, demoted, err := ti.Rebalance()
+	if err != nil {
+		t.Fatalf("Rebalance() error = %v", err)
+	}
+	// Two cold → hot; hot and warm each cascade two down a tier
+	if promoted != 2 || demoted != 4 {
+		t.Errorf("Rebalance() = %d promoted, %d demoted, want 2/4", promoted, demoted)
+	}
+	for _, id := range []string{"n6", "n7"} {
+		if tier, _ := ti.TierOf(id); tier != TierHot {
+			t.Errorf("%s tier = %v, want hot", id, tier)
+		}
+	}
+	stats := ti.Stats()
+	if stats.Hot != 2 || stats.Warm != 2 || stats.Cold != 4 {
+		t.Errorf("tier counts after rebalance = %+v", stats)
+	}
+
+	// Moved vectors are intact and searchable
+	results, err := ti.Search(tieredTestVector(0, 8), 1)
+	if err != nil || len(results) != 1 || results[0].ID != "n0" {
+		t.Errorf("Search(n0) after rebalance = %v, %v", results, err)
+	}
+
+	// Without further access, a second rebalance keeps tiers stable
+	if p, d, _ := ti.Rebalance(); p != 0 || d != 0 {
+		t.Errorf("second Rebalance() moved %d/%d", p, d)
+	}
+
+	// Removal frees the slot for reuse
+	if !ti.Remove("n5") || ti.Remove("n5") {
+		t.Error("Remove() should succeed once")
+	}
+	if err := ti.Add("n8", tieredTestVector(8, 8)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if ti.Count() != 8 {
+		t.Errorf("Count() = %d, want 8", ti.Count())
+	}
+}
+
+func TestTieredIndexWithoutColdTier(t *testing.T) {
+	ti, err := NewTieredIndex(nil, &TieredIndexConfig{Dimensions: 8, HotCapacity: 1, WarmCapacity: 1})
+	if err != nil {
+		t.Fatalf("NewTieredIndex() error = %v", err)
+	}
+	defer ti.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := ti.Add(fmt.Sprintf("n%d", i), tieredTestVector(i, 8)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if err := ti.Add("n2", tieredTestVector(2, 8)); !errors.Is(err, ErrTiersFull) {
+		t.Errorf("expected ErrTiersFull, got %v", err)
+	}
+	if _, _, err := ti.Rebalance(); err != nil {
+		t.Errorf("Rebalance() error = %v", err)
+	}
+}
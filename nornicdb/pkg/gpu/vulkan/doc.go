@@ -57,6 +57,39 @@
 //   - Compute command buffers for GPU dispatch
 //   - Descriptor sets for resource binding
 //
+// # Shaders
+//
+// Compute kernels are GLSL sources in shaders/. go generate (or make
+// shaders) compiles them with glslc or glslangValidator into SPIR-V for
+// several Vulkan versions and records the source hashes in
+// shaders/manifest.json; the outputs are embedded in the binary, so users
+// need no shader toolchain. The Vulkan Shaders workflow rebuilds and commits
+// them when a source changes, and fails pull requests that leave them stale.
+//
+// At device creation, LoadShader picks the SPIR-V for the device's Vulkan
+// version. If that variant is not embedded, or was built from an older
+// source, it is compiled at runtime when a compiler is installed and cached
+// in the user cache directory; otherwise the newest older embedded variant
+// is used. Kernels without SPIR-V run on CPU paths.
+//
+// # Half Precision
+//
+// NewBufferWithPrecision stores embeddings as FP16 or BF16, two bytes per
+// element. Search and SearchBatch widen each element to float32 as they
+// read it and accumulate in float32; cosine_similarity_f16 and
+// cosine_similarity_bf16 are the matching shaders.
+//
+//	buffer, err := device.NewBufferWithPrecision(embeddings, vulkan.PrecisionBF16)
+//
+// # Int8 Quantization
+//
+// NewQuantizedBuffer stores embeddings as int8 codes with a float32 scale
+// per vector (see package quant), nearly four times as many per byte as
+// float32. Search and SearchBatch dequantize each code as they read it;
+// cosine_similarity_int8 is the matching shader.
+//
+//	buffer, err := device.NewQuantizedBuffer(embeddings, dims)
+//
 // # Performance Considerations
 //
 // Vulkan provides:
@@ -70,6 +103,14 @@
 //   - Keep data on GPU across multiple searches
 //   - Pre-normalize embeddings when possible
 //
+// # Concurrency
+//
+// A Device is safe for concurrent use. Buffers are mapped once for their
+// lifetime and operations keep no device-wide state, so searches from
+// different goroutines run concurrently. Buffer.Release and Device.Release
+// wait for operations in progress; later operations return ErrInvalidBuffer
+// or ErrDeviceReleased.
+//
 // # Example
 //
 // Basic usage:
@@ -86,7 +127,11 @@
 //	}
 //	defer buffer.Release()
 //
-//	results, err := device.Search(buffer, query, numVectors, dimensions, topK, normalized)
+//	results, err := device.Search(buffer, query, numVectors, dimensions, topK, vulkan.SearchOptions{
+//	    Normalized:  normalized,
+//	    MinScore:    0.7,
+//	    HasMinScore: true,
+//	})
 //	if err != nil {
 //	    log.Fatal(err)
 //	}
@@ -0,0 +1,42 @@
+package vulkan
+
+import "github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+
+// VkResult error codes that classify a failure.
+const (
+	vkErrorOutOfHostMemory      = -1
+	vkErrorOutOfDeviceMemory    = -2
+	vkErrorInitializationFailed = -3
+	vkErrorDeviceLost           = -4
+	vkErrorMemoryMapFailed      = -5
+	vkErrorLayerNotPresent      = -6
+	vkErrorExtensionNotPresent  = -7
+	vkErrorFeatureNotPresent    = -8
+	vkErrorIncompatibleDriver   = -9
+	vkErrorFragmentedPool       = -12
+	vkErrorOutOfPoolMemory      = -1000069000
+	vkErrorInvalidShaderNV      = -1000012000
+)
+
+// classify maps a VkResult to a shared error kind, or nil.
+func classify(code int) error {
+	switch code {
+	case vkErrorOutOfHostMemory, vkErrorOutOfDeviceMemory, vkErrorMemoryMapFailed,
+		vkErrorFragmentedPool, vkErrorOutOfPoolMemory:
+		return gpuerr.ErrOutOfMemory
+	case vkErrorDeviceLost:
+		return gpuerr.ErrDeviceLost
+	case vkErrorInitializationFailed, vkErrorLayerNotPresent, vkErrorExtensionNotPresent,
+		vkErrorFeatureNotPresent, vkErrorIncompatibleDriver:
+		return gpuerr.ErrNotAvailable
+	case vkErrorInvalidShaderNV:
+		return gpuerr.ErrKernel
+	}
+	return nil
+}
+
+// newError builds the error returned for a failed op from the last VkResult
+// and message.
+func newError(op error, code int, msg string) error {
+	return gpuerr.New("vulkan", op, classify(code), code, msg)
+}
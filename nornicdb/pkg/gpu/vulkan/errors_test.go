@@ -0,0 +1,36 @@
+package vulkan
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+)
+
+func TestNewErrorClassifiesCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		op   error
+		code int
+		want error
+	}{
+		{"device memory", ErrBufferCreation, vkErrorOutOfDeviceMemory, gpuerr.ErrOutOfMemory},
+		{"descriptor pool", ErrKernelExecution, vkErrorOutOfPoolMemory, gpuerr.ErrOutOfMemory},
+		{"device lost", ErrKernelExecution, vkErrorDeviceLost, gpuerr.ErrDeviceLost},
+		{"device lost on allocation", ErrBufferCreation, vkErrorDeviceLost, gpuerr.ErrDeviceLost},
+		{"incompatible driver", ErrDeviceCreation, vkErrorIncompatibleDriver, gpuerr.ErrNotAvailable},
+		{"invalid shader", ErrKernelExecution, vkErrorInvalidShaderNV, gpuerr.ErrKernel},
+		{"no code uses op kind", ErrKernelExecution, 0, gpuerr.ErrKernel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newError(tt.op, tt.code, "native message")
+			if !errors.Is(err, tt.op) {
+				t.Errorf("%v should match %v", err, tt.op)
+			}
+			if got := gpuerr.KindOf(err); got != tt.want {
+				t.Errorf("kind = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+//go:build ignore
+
+// gen_shaders compiles shaders/*.comp to SPIR-V for embedding.
+//
+// Run from this directory with a Vulkan SDK installed:
+//
+//	go generate ./pkg/gpu/vulkan
+//	go run gen_shaders.go -targets vulkan1.0,vulkan1.1,vulkan1.2
+//
+// It writes shaders/<kernel>.<target>.spv and shaders/manifest.json, which
+// records the SHA-256 of each source so the runtime never loads SPIR-V built
+// from an older source. Commit the outputs.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type manifestShader struct {
+	SourceSHA256 string   `json:"source_sha256"`
+	Targets      []string `json:"targets"`
+}
+
+type manifest struct {
+	Compiler string                    `json:"compiler"`
+	Shaders  map[string]manifestShader `json:"shaders"`
+}
+
+func main() {
+	targets := flag.String("targets", "vulkan1.0,vulkan1.2", "comma-separated --target-env values to build")
+	dir := flag.String("dir", "shaders", "directory holding *.comp sources")
+	flag.Parse()
+
+	compiler, err := findCompiler()
+	if err != nil {
+		log.Fatal(err)
+	}
+	version, _ := exec.Command(compiler, "--version").Output()
+
+	sources, err := filepath.Glob(filepath.Join(*dir, "*.comp"))
+	if err != nil || len(sources) == 0 {
+		log.Fatalf("no shaders in %s", *dir)
+	}
+	sort.Strings(sources)
+
+	m := manifest{
+		Compiler: strings.TrimSpace(strings.SplitN(string(version), "\n", 2)[0]),
+		Shaders:  make(map[string]manifestShader),
+	}
+	for _, src := range sources {
+		kernel := strings.TrimSuffix(filepath.Base(src), ".comp")
+		data, err := os.ReadFile(src)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sum := sha256.Sum256(data)
+		entry := manifestShader{SourceSHA256: hex.EncodeToString(sum[:])}
+
+		for _, target := range strings.Split(*targets, ",") {
+			target = strings.TrimSpace(target)
+			out := filepath.Join(*dir, fmt.Sprintf("%s.%s.spv", kernel, target))
+			args := []string{"-V", "--target-env", target, "-o", out, src}
+			if strings.HasPrefix(filepath.Base(compiler), "glslc") {
+				args = []string{"--target-env=" + target, "-O", "-o", out, src}
+			}
+			if output, err := exec.Command(compiler, args...).CombinedOutput(); err != nil {
+				log.Fatalf("%s (%s): %v\n%s", src, target, err, output)
+			}
+			entry.Targets = append(entry.Targets, target)
+			fmt.Printf("%s -> %s\n", src, out)
+		}
+		m.Shaders[kernel] = entry
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, "manifest.json"), append(data, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func findCompiler() (string, error) {
+	for _, name := range []string{"glslc", "glslangValidator"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+		if sdk := os.Getenv("VULKAN_SDK"); sdk != "" {
+			path := filepath.Join(sdk, "bin", name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("glslc or glslangValidator not found; install the Vulkan SDK")
+}
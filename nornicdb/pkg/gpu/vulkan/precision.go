@@ -0,0 +1,101 @@
+package vulkan
+
+import (
+	"fmt"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/half"
+	"github.com/orneryd/nornicdb/pkg/gpu/quant"
+)
+
+// Precision selects how a Buffer stores its elements on the device.
+//
+// The half-precision formats take two bytes per element instead of four,
+// so the same memory holds twice as many embeddings. Values are converted
+// on upload and widened back to float32 by the kernels, which accumulate
+// in float32; see package half for the formats.
+//
+// PrecisionInt8 goes further: one byte per element plus a float32 scale
+// per vector, nearly a quarter of the memory of float32, at the cost of
+// rounding each component to one of 255 levels; see package quant. Its
+// buffers are made with NewQuantizedBuffer, which needs the dimensions.
+type Precision int
+
+const (
+	// PrecisionFP32 stores float32 elements. It is the default.
+	PrecisionFP32 Precision = iota
+	// PrecisionFP16 stores IEEE 754 half-precision elements. Suited to
+	// normalized embeddings, whose components are well within its range.
+	PrecisionFP16
+	// PrecisionBF16 stores bfloat16 elements: float32 range, less precision.
+	PrecisionBF16
+	// PrecisionInt8 stores int8 codes with a float32 scale per vector.
+	PrecisionInt8
+)
+
+// String returns the name of p, e.g. "fp16".
+func (p Precision) String() string {
+	switch p {
+	case PrecisionFP32:
+		return "fp32"
+	case PrecisionFP16:
+		return "fp16"
+	case PrecisionBF16:
+		return "bf16"
+	case PrecisionInt8:
+		return "int8"
+	}
+	return fmt.Sprintf("Precision(%d)", int(p))
+}
+
+// check returns an error unless p is a known precision.
+func (p Precision) check() error {
+	if p < PrecisionFP32 || p > PrecisionInt8 {
+		return fmt.Errorf("vulkan: unknown buffer precision %d", int(p))
+	}
+	return nil
+}
+
+// elementSize returns the bytes one element takes, not counting the
+// per-vector scales of PrecisionInt8.
+func (p Precision) elementSize() uint64 {
+	switch p {
+	case PrecisionFP32:
+		return 4
+	case PrecisionInt8:
+		return 1
+	}
+	return 2
+}
+
+// encode converts data to the 16-bit format of p, which is PrecisionFP16
+// or PrecisionBF16.
+func (p Precision) encode(data []float32) []uint16 {
+	packed := make([]uint16, len(data))
+	if p == PrecisionBF16 {
+		half.EncodeBF16(packed, data)
+	} else {
+		half.EncodeFP16(packed, data)
+	}
+	return packed
+}
+
+// decode converts 16-bit values in the format of p into float32s.
+func (p Precision) decode(packed []uint16) []float32 {
+	data := make([]float32, len(packed))
+	if p == PrecisionBF16 {
+		half.DecodeBF16(data, packed)
+	} else {
+		half.DecodeFP16(data, packed)
+	}
+	return data
+}
+
+// int8Range checks that elements [offset, offset+count) of an int8 buffer
+// holding vectors vectors of dims components are whole vectors, and
+// returns the byte offset and length of their scales in the buffer.
+func int8Range(offset, count, vectors, dims int) (scalesAt, scalesLen int, err error) {
+	if dims <= 0 || offset%dims != 0 || count%dims != 0 {
+		return 0, 0, fmt.Errorf("%w: int8 buffers are accessed in whole vectors of %d elements", ErrPrecision, dims)
+	}
+	return quant.ScalesOffset(vectors*dims) + 4*(offset/dims), 4 * (count / dims), nil
+}
@@ -0,0 +1,56 @@
+package vulkan
+
+import "fmt"
+
+// DeviceProperties describes a Vulkan physical device.
+type DeviceProperties struct {
+	ID   int
+	Name string
+	// VendorID is the PCI vendor ID (see Vendor).
+	VendorID uint32
+	// DriverVersion is vendor-encoded (see DriverVersionString).
+	DriverVersion uint32
+	// APIVersion is the Vulkan version supported by the driver.
+	APIVersion              uint32
+	MaxWorkGroupInvocations int
+	MemoryBytes             uint64
+}
+
+// Vendor returns the GPU vendor name for well-known PCI vendor IDs.
+func (p DeviceProperties) Vendor() string {
+	switch p.VendorID {
+	case 0x10DE:
+		return "NVIDIA"
+	case 0x1002:
+		return "AMD"
+	case 0x8086:
+		return "Intel"
+	case 0x106B:
+		return "Apple"
+	case 0x13B5:
+		return "ARM"
+	case 0x5143:
+		return "Qualcomm"
+	default:
+		return fmt.Sprintf("0x%04X", p.VendorID)
+	}
+}
+
+// DriverVersionString decodes DriverVersion. NVIDIA packs it as
+// 10.8.8.6 bits; other vendors use the standard Vulkan encoding.
+func (p DeviceProperties) DriverVersionString() string {
+	v := p.DriverVersion
+	if p.VendorID == 0x10DE {
+		return fmt.Sprintf("%d.%d.%d", v>>22, (v>>14)&0xFF, (v>>6)&0xFF)
+	}
+	return versionString(v)
+}
+
+// APIVersionString decodes APIVersion, e.g. "1.3.268".
+func (p DeviceProperties) APIVersionString() string {
+	return versionString(p.APIVersion)
+}
+
+func versionString(v uint32) string {
+	return fmt.Sprintf("%d.%d.%d", (v>>22)&0x7F, (v>>12)&0x3FF, v&0xFFF)
+}
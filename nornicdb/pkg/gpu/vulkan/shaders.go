@@ -0,0 +1,255 @@
+package vulkan
+
+//go:generate go run gen_shaders.go
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// shaderFS holds the GLSL sources and the SPIR-V built from them by
+// go generate (see gen_shaders.go), so binaries need no shader toolchain.
+//
+//go:embed shaders
+var shaderFS embed.FS
+
+// Kernels are the compute shaders in shaders/, by file name without ".comp".
+var Kernels = []string{"cosine_similarity", "normalize", "cosine_similarity_f16", "cosine_similarity_bf16", "cosine_similarity_int8"}
+
+// Shader targets, oldest first. A SPIR-V module built for a target runs on
+// devices with at least that Vulkan version.
+const (
+	TargetVulkan10 = "vulkan1.0"
+	TargetVulkan11 = "vulkan1.1"
+	TargetVulkan12 = "vulkan1.2"
+	TargetVulkan13 = "vulkan1.3"
+)
+
+var shaderTargets = []string{TargetVulkan10, TargetVulkan11, TargetVulkan12, TargetVulkan13}
+
+// spirvMagic is the first word of every SPIR-V module.
+const spirvMagic = 0x07230203
+
+// ErrShaderUnavailable is returned when no usable SPIR-V exists for a kernel
+// and none can be compiled.
+var ErrShaderUnavailable = errors.New("vulkan: shader unavailable")
+
+// shaderManifest records what go generate built, so stale SPIR-V (built from
+// an older GLSL source) is never loaded.
+type shaderManifest struct {
+	Compiler string                    `json:"compiler"`
+	Shaders  map[string]manifestShader `json:"shaders"`
+}
+
+type manifestShader struct {
+	SourceSHA256 string   `json:"source_sha256"`
+	Targets      []string `json:"targets"`
+}
+
+var (
+	manifestOnce sync.Once
+	manifest     shaderManifest
+)
+
+func loadManifest() shaderManifest {
+	manifestOnce.Do(func() {
+		data, err := shaderFS.ReadFile("shaders/manifest.json")
+		if err == nil {
+			_ = json.Unmarshal(data, &manifest)
+		}
+	})
+	return manifest
+}
+
+// compileGLSL compiles GLSL source to SPIR-V for a target. It is a variable
+// so tests can run without a shader compiler.
+var compileGLSL = compileWithToolchain
+
+// TargetForAPIVersion returns the newest shader target supported by a device
+// reporting apiVersion (VkPhysicalDeviceProperties.apiVersion).
+func TargetForAPIVersion(apiVersion uint32) string {
+	minor := (apiVersion >> 12) & 0x3FF
+	if major := (apiVersion >> 22) & 0x7F; major > 1 {
+		minor = uint32(len(shaderTargets) - 1)
+	}
+	if int(minor) >= len(shaderTargets) {
+		minor = uint32(len(shaderTargets) - 1)
+	}
+	return shaderTargets[minor]
+}
+
+// LoadShader returns SPIR-V for kernel built for the newest target the
+// device supports.
+//
+// Embedded SPIR-V is used when go generate built that exact target from the
+// current source. Otherwise the GLSL is compiled at runtime with glslc or
+// glslangValidator (from PATH or $VULKAN_SDK/bin) and cached under the user
+// cache directory. If no compiler is installed, the newest older embedded
+// target is used instead.
+//
+// Example:
+//
+//	code, target, err := vulkan.LoadShader("cosine_similarity", props.APIVersion)
+//	if err != nil {
+//		return err // GPU kernels unavailable; use CPU paths
+//	}
+//	log.Printf("cosine_similarity: %d bytes of SPIR-V for %s", len(code), target)
+func LoadShader(kernel string, apiVersion uint32) (code []byte, target string, err error) {
+	source, err := shaderFS.ReadFile("shaders/" + kernel + ".comp")
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: unknown kernel %q", ErrShaderUnavailable, kernel)
+	}
+	want := TargetForAPIVersion(apiVersion)
+	hash := sourceHash(source)
+
+	if code, ok := embeddedShader(kernel, want, hash); ok {
+		return code, want, nil
+	}
+
+	code, compileErr := compileCached(kernel, source, hash, want)
+	if compileErr == nil {
+		return code, want, nil
+	}
+
+	// No compiler: fall back to the newest older embedded target.
+	for i := targetIndex(want) - 1; i >= 0; i-- {
+		if code, ok := embeddedShader(kernel, shaderTargets[i], hash); ok {
+			return code, shaderTargets[i], nil
+		}
+	}
+	return nil, "", fmt.Errorf("%w: %s has no embedded SPIR-V for %s and runtime compilation failed: %v",
+		ErrShaderUnavailable, kernel, want, compileErr)
+}
+
+// embeddedShader returns the embedded SPIR-V for kernel and target if the
+// manifest says it was built from the source with the given hash.
+func embeddedShader(kernel, target, hash string) ([]byte, bool) {
+	entry, ok := loadManifest().Shaders[kernel]
+	if !ok || entry.SourceSHA256 != hash {
+		return nil, false
+	}
+	for _, t := range entry.Targets {
+		if t != target {
+			continue
+		}
+		code, err := shaderFS.ReadFile("shaders/" + spirvFileName(kernel, target))
+		if err != nil || validateSPIRV(code) != nil {
+			return nil, false
+		}
+		return code, true
+	}
+	return nil, false
+}
+
+// compileCached compiles source for target, reusing an earlier result from
+// the cache directory when the source is unchanged.
+func compileCached(kernel string, source []byte, hash, target string) ([]byte, error) {
+	var cachePath string
+	if dir, err := os.UserCacheDir(); err == nil {
+		cachePath = filepath.Join(dir, "nornicdb", "spirv", fmt.Sprintf("%s-%s-%s.spv", kernel, target, hash[:16]))
+		if code, err := os.ReadFile(cachePath); err == nil && validateSPIRV(code) == nil {
+			return code, nil
+		}
+	}
+
+	code, err := compileGLSL(kernel, source, target)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSPIRV(code); err != nil {
+		return nil, err
+	}
+	if cachePath != "" {
+		// Best effort: a read-only cache only costs a recompile next time.
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+			_ = os.WriteFile(cachePath, code, 0644)
+		}
+	}
+	return code, nil
+}
+
+// findShaderCompiler returns the path of glslc or glslangValidator.
+func findShaderCompiler() (string, error) {
+	for _, name := range []string{"glslc", "glslangValidator"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+		if sdk := os.Getenv("VULKAN_SDK"); sdk != "" {
+			path := filepath.Join(sdk, "bin", name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+	}
+	return "", errors.New("no shader compiler found (install glslc or glslangValidator from the Vulkan SDK)")
+}
+
+// compileWithToolchain runs glslc or glslangValidator on source.
+func compileWithToolchain(kernel string, source []byte, target string) ([]byte, error) {
+	compiler, err := findShaderCompiler()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "nornicdb-spirv-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, kernel+".comp")
+	out := filepath.Join(dir, kernel+".spv")
+	if err := os.WriteFile(in, source, 0644); err != nil {
+		return nil, err
+	}
+	if output, err := exec.Command(compiler, compilerArgs(compiler, target, in, out)...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("compile %s: %v: %s", kernel, err, output)
+	}
+	return os.ReadFile(out)
+}
+
+// compilerArgs returns the command line for compiling in to out.
+func compilerArgs(compiler, target, in, out string) []string {
+	if strings.HasPrefix(filepath.Base(compiler), "glslc") {
+		return []string{"--target-env=" + target, "-O", "-o", out, in}
+	}
+	return []string{"-V", "--target-env", target, "-o", out, in}
+}
+
+// validateSPIRV checks the SPIR-V header.
+func validateSPIRV(code []byte) error {
+	if len(code) < 20 || len(code)%4 != 0 {
+		return fmt.Errorf("%w: invalid SPIR-V size %d", ErrShaderUnavailable, len(code))
+	}
+	if binary.LittleEndian.Uint32(code) != spirvMagic {
+		return fmt.Errorf("%w: bad SPIR-V magic", ErrShaderUnavailable)
+	}
+	return nil
+}
+
+func sourceHash(source []byte) string {
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:])
+}
+
+func spirvFileName(kernel, target string) string {
+	return kernel + "." + target + ".spv"
+}
+
+func targetIndex(target string) int {
+	for i, t := range shaderTargets {
+		if t == target {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,155 @@
+package vulkan
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+)
+
+// fakeSPIRV returns a minimal module header tagged with target.
+func fakeSPIRV(target string) []byte {
+	code := make([]byte, 20, 20+len(target)+4)
+	binary.LittleEndian.PutUint32(code, spirvMagic)
+	code = append(code, target...)
+	for len(code)%4 != 0 {
+		code = append(code, 0)
+	}
+	return code
+}
+
+func withCompiler(t *testing.T, compile func(kernel string, source []byte, target string) ([]byte, error)) {
+	t.Helper()
+	orig := compileGLSL
+	compileGLSL = compile
+	t.Cleanup(func() { compileGLSL = orig })
+	// Keep compiled shaders out of the real cache.
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("LocalAppData", t.TempDir())
+}
+
+func TestKernelSourcesEmbedded(t *testing.T) {
+	for _, kernel := range Kernels {
+		source, err := shaderFS.ReadFile("shaders/" + kernel + ".comp")
+		if err != nil {
+			t.Errorf("%s: %v", kernel, err)
+			continue
+		}
+		if len(source) == 0 {
+			t.Errorf("%s: empty source", kernel)
+		}
+	}
+}
+
+func TestEmbeddedManifestMatchesSources(t *testing.T) {
+	m := loadManifest()
+	if len(m.Shaders) == 0 {
+		t.Skip("no embedded SPIR-V; run go generate with a Vulkan SDK installed")
+	}
+	for _, kernel := range Kernels {
+		entry, ok := m.Shaders[kernel]
+		if !ok {
+			t.Errorf("%s: missing from manifest", kernel)
+			continue
+		}
+		source, _ := shaderFS.ReadFile("shaders/" + kernel + ".comp")
+		for _, target := range entry.Targets {
+			if _, ok := embeddedShader(kernel, target, sourceHash(source)); !ok {
+				t.Errorf("%s: embedded SPIR-V for %s is stale or invalid; run go generate", kernel, target)
+			}
+		}
+	}
+}
+
+func TestTargetForAPIVersion(t *testing.T) {
+	tests := []struct {
+		major, minor uint32
+		want         string
+	}{
+		{1, 0, TargetVulkan10},
+		{1, 1, TargetVulkan11},
+		{1, 2, TargetVulkan12},
+		{1, 3, TargetVulkan13},
+		{1, 4, TargetVulkan13},
+	}
+	for _, tt := range tests {
+		if got := TargetForAPIVersion(tt.major<<22 | tt.minor<<12); got != tt.want {
+			t.Errorf("TargetForAPIVersion(%d.%d) = %s, want %s", tt.major, tt.minor, got, tt.want)
+		}
+	}
+}
+
+func TestLoadShaderCompilesAtRuntime(t *testing.T) {
+	calls := 0
+	withCompiler(t, func(kernel string, source []byte, target string) ([]byte, error) {
+		calls++
+		return fakeSPIRV(target), nil
+	})
+
+	code, target, err := LoadShader("cosine_similarity", 1<<22|3<<12)
+	if err != nil {
+		t.Fatalf("LoadShader: %v", err)
+	}
+	if target != TargetVulkan13 {
+		t.Errorf("target = %s, want %s", target, TargetVulkan13)
+	}
+	if err := validateSPIRV(code); err != nil {
+		t.Error(err)
+	}
+
+	// The second load comes from the cache.
+	if _, _, err := LoadShader("cosine_similarity", 1<<22|3<<12); err != nil {
+		t.Fatalf("LoadShader: %v", err)
+	}
+	if _, err := os.UserCacheDir(); err == nil && calls != 1 {
+		t.Errorf("expected 1 compile, got %d", calls)
+	}
+}
+
+func TestLoadShaderWithoutCompiler(t *testing.T) {
+	withCompiler(t, func(kernel string, source []byte, target string) ([]byte, error) {
+		return nil, errors.New("no shader compiler found")
+	})
+
+	_, _, err := LoadShader("cosine_similarity", 1<<22|2<<12)
+	if len(loadManifest().Shaders) == 0 {
+		if !errors.Is(err, ErrShaderUnavailable) {
+			t.Errorf("expected ErrShaderUnavailable, got %v", err)
+		}
+		return
+	}
+	// With embedded SPIR-V, an embedded target not newer than 1.2 is used.
+	if err != nil {
+		t.Errorf("expected embedded fallback, got %v", err)
+	}
+}
+
+func TestLoadShaderRejectsInvalidOutput(t *testing.T) {
+	withCompiler(t, func(kernel string, source []byte, target string) ([]byte, error) {
+		return []byte("not spir-v"), nil
+	})
+	if len(loadManifest().Shaders) > 0 {
+		t.Skip("embedded SPIR-V takes precedence")
+	}
+	if _, _, err := LoadShader("normalize", 1<<22); !errors.Is(err, ErrShaderUnavailable) {
+		t.Errorf("expected ErrShaderUnavailable, got %v", err)
+	}
+}
+
+func TestLoadShaderUnknownKernel(t *testing.T) {
+	if _, _, err := LoadShader("no_such_kernel", 1<<22); !errors.Is(err, ErrShaderUnavailable) {
+		t.Errorf("expected ErrShaderUnavailable, got %v", err)
+	}
+}
+
+func TestCompilerArgs(t *testing.T) {
+	got := compilerArgs("/sdk/bin/glslc", TargetVulkan12, "in.comp", "out.spv")
+	if got[0] != "--target-env=vulkan1.2" {
+		t.Errorf("glslc args = %v", got)
+	}
+	got = compilerArgs("/sdk/bin/glslangValidator", TargetVulkan12, "in.comp", "out.spv")
+	if got[0] != "-V" || got[2] != "vulkan1.2" {
+		t.Errorf("glslangValidator args = %v", got)
+	}
+}
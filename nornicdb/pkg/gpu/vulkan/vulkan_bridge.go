@@ -26,21 +26,35 @@ package vulkan
 #include <stdlib.h>
 #include <string.h>
 #include <stdio.h>
+#include <stdint.h>
 #include <math.h>
 
-// Error handling
-static char vulkan_last_error[512] = {0};
+// Error handling. The VkResult lets Go classify failures (see errors.go).
+// The state is per thread, so concurrent operations don't overwrite each
+// other's errors.
+static __thread char vulkan_last_error[512] = {0};
+static __thread int vulkan_last_code = 0;
 
 void vulkan_set_error(const char* msg) {
     strncpy(vulkan_last_error, msg, sizeof(vulkan_last_error) - 1);
 }
 
+void vulkan_set_vk_error(VkResult result, const char* msg) {
+    vulkan_last_code = (int)result;
+    vulkan_set_error(msg);
+}
+
 const char* vulkan_get_last_error() {
     return vulkan_last_error;
 }
 
+int vulkan_get_last_code() {
+    return vulkan_last_code;
+}
+
 void vulkan_clear_error() {
     vulkan_last_error[0] = 0;
+    vulkan_last_code = 0;
 }
 
 const char* vulkan_result_string(VkResult result) {
@@ -63,76 +77,9 @@ const char* vulkan_result_string(VkResult result) {
     }
 }
 
-// SPIR-V shader code for cosine similarity (pre-compiled)
-// This is the compiled SPIR-V bytecode for the compute shader
-// Equivalent GLSL:
-//
-// #version 450
-// layout(local_size_x = 256) in;
-//
-// layout(set = 0, binding = 0) readonly buffer Embeddings { float embeddings[]; };
-// layout(set = 0, binding = 1) readonly buffer Query { float query[]; };
-// layout(set = 0, binding = 2) writeonly buffer Scores { float scores[]; };
-//
-// layout(push_constant) uniform PushConstants {
-//     uint n;
-//     uint dims;
-//     uint normalized;
-// } pc;
-//
-// void main() {
-//     uint idx = gl_GlobalInvocationID.x;
-//     if (idx >= pc.n) return;
-//
-//     float dot = 0.0;
-//     float norm_e = 0.0;
-//     float norm_q = 0.0;
-//
-//     uint base = idx * pc.dims;
-//     for (uint d = 0; d < pc.dims; d++) {
-//         float e = embeddings[base + d];
-//         float q = query[d];
-//         dot += e * q;
-//         if (pc.normalized == 0) {
-//             norm_e += e * e;
-//             norm_q += q * q;
-//         }
-//     }
-//
-//     if (pc.normalized != 0) {
-//         scores[idx] = dot;
-//     } else {
-//         float denom = sqrt(norm_e) * sqrt(norm_q);
-//         scores[idx] = denom > 1e-10 ? dot / denom : 0.0;
-//     }
-// }
-
-// Pre-compiled SPIR-V for cosine similarity shader
-// Generated with: glslangValidator -V shader.comp -o shader.spv
-static const uint32_t cosine_similarity_spirv[] = {
-    // Magic number
-    0x07230203,
-    // Version 1.0
-    0x00010000,
-    // Generator magic
-    0x00080001,
-    // Bound
-    0x00000050,
-    // Schema
-    0x00000000,
-    // OpCapability Shader
-    0x00020011, 0x00000001,
-    // OpMemoryModel Logical GLSL450
-    0x0003000e, 0x00000000, 0x00000001,
-    // OpEntryPoint GLCompute %main "main" %gl_GlobalInvocationID
-    0x0006000f, 0x00000005, 0x00000001, 0x6e69616d, 0x00000000, 0x00000002,
-    // OpExecutionMode %main LocalSize 256 1 1
-    0x00060010, 0x00000001, 0x00000011, 0x00000100, 0x00000001, 0x00000001,
-    // (Simplified - actual SPIR-V would be longer)
-    // This is a placeholder - real implementation would include full shader
-};
-
-static const size_t cosine_similarity_spirv_size = sizeof(cosine_similarity_spirv);
+// Compute shaders are GLSL sources in shaders/, shipped as SPIR-V embedded
+// by go generate or compiled at runtime (see shaders.go), and turned into
+// pipelines by vulkan_create_pipeline.
 
 // Device structure
 typedef struct {
@@ -147,6 +94,9 @@ typedef struct {
     VkPipeline cosine_pipeline;
     VkPipeline topk_pipeline;
     VkPipeline normalize_pipeline;
+    VkPipeline cosine_f16_pipeline;
+    VkPipeline cosine_bf16_pipeline;
+    VkPipeline cosine_int8_pipeline;
     VkDescriptorSetLayout descriptor_set_layout;
     int device_id;
     char device_name[256];
@@ -228,6 +178,91 @@ int vulkan_find_compute_queue_family(VkPhysicalDevice physical_device) {
     return compute_family;
 }
 
+static VkResult vulkan_create_probe_instance(VkInstance* instance) {
+    VkApplicationInfo app_info = {
+        .sType = VK_STRUCTURE_TYPE_APPLICATION_INFO,
+        .pApplicationName = "NornicDB",
+        .applicationVersion = VK_MAKE_VERSION(1, 0, 0),
+        .apiVersion = VK_API_VERSION_1_1
+    };
+
+    VkInstanceCreateInfo create_info = {
+        .sType = VK_STRUCTURE_TYPE_INSTANCE_CREATE_INFO,
+        .pApplicationInfo = &app_info
+    };
+
+    return vkCreateInstance(&create_info, NULL, instance);
+}
+
+// Why no device is usable: missing loader/driver, driver too old, etc.
+const char* vulkan_unavailable_reason() {
+    VkInstance instance;
+    VkResult result = vulkan_create_probe_instance(&instance);
+    if (result == VK_ERROR_INCOMPATIBLE_DRIVER) {
+        return "no compatible Vulkan driver (Vulkan 1.1 required; driver missing or too old)";
+    }
+    if (result != VK_SUCCESS) {
+        return vulkan_result_string(result);
+    }
+
+    uint32_t device_count = 0;
+    vkEnumeratePhysicalDevices(instance, &device_count, NULL);
+    vkDestroyInstance(instance, NULL);
+    if (device_count == 0) {
+        return "no Vulkan physical devices found";
+    }
+    return "";
+}
+
+// Device properties queried without creating a logical device
+typedef struct {
+    char name[VK_MAX_PHYSICAL_DEVICE_NAME_SIZE];
+    uint32_t vendor_id;
+    uint32_t driver_version;
+    uint32_t api_version;
+    uint32_t max_invocations;
+    uint64_t memory;
+} VulkanDeviceProperties;
+
+int vulkan_query_device(int index, VulkanDeviceProperties* props) {
+    VkInstance instance;
+    if (vulkan_create_probe_instance(&instance) != VK_SUCCESS) {
+        return -1;
+    }
+
+    uint32_t device_count = 0;
+    vkEnumeratePhysicalDevices(instance, &device_count, NULL);
+    if (index < 0 || index >= (int)device_count) {
+        vkDestroyInstance(instance, NULL);
+        return -1;
+    }
+    VkPhysicalDevice* physical_devices = malloc(device_count * sizeof(VkPhysicalDevice));
+    vkEnumeratePhysicalDevices(instance, &device_count, physical_devices);
+    VkPhysicalDevice physical_device = physical_devices[index];
+    free(physical_devices);
+
+    VkPhysicalDeviceProperties properties;
+    vkGetPhysicalDeviceProperties(physical_device, &properties);
+    memset(props, 0, sizeof(VulkanDeviceProperties));
+    strncpy(props->name, properties.deviceName, sizeof(props->name) - 1);
+    props->vendor_id = properties.vendorID;
+    props->driver_version = properties.driverVersion;
+    props->api_version = properties.apiVersion;
+    props->max_invocations = properties.limits.maxComputeWorkGroupInvocations;
+
+    VkPhysicalDeviceMemoryProperties mem_properties;
+    vkGetPhysicalDeviceMemoryProperties(physical_device, &mem_properties);
+    for (uint32_t i = 0; i < mem_properties.memoryHeapCount; i++) {
+        if (mem_properties.memoryHeaps[i].flags & VK_MEMORY_HEAP_DEVICE_LOCAL_BIT) {
+            props->memory = mem_properties.memoryHeaps[i].size;
+            break;
+        }
+    }
+
+    vkDestroyInstance(instance, NULL);
+    return 0;
+}
+
 // Create Vulkan device
 VulkanDevice* vulkan_create_device(int device_id) {
     VulkanDevice* dev = (VulkanDevice*)calloc(1, sizeof(VulkanDevice));
@@ -256,7 +291,7 @@ VulkanDevice* vulkan_create_device(int device_id) {
     if (result != VK_SUCCESS) {
         char msg[256];
         snprintf(msg, sizeof(msg), "Failed to create Vulkan instance: %s", vulkan_result_string(result));
-        vulkan_set_error(msg);
+        vulkan_set_vk_error(result, msg);
         free(dev);
         return NULL;
     }
@@ -322,7 +357,7 @@ VulkanDevice* vulkan_create_device(int device_id) {
     if (result != VK_SUCCESS) {
         char msg[256];
         snprintf(msg, sizeof(msg), "Failed to create logical device: %s", vulkan_result_string(result));
-        vulkan_set_error(msg);
+        vulkan_set_vk_error(result, msg);
         vkDestroyInstance(dev->instance, NULL);
         free(dev);
         return NULL;
@@ -340,7 +375,7 @@ VulkanDevice* vulkan_create_device(int device_id) {
 
     result = vkCreateCommandPool(dev->device, &pool_info, NULL, &dev->command_pool);
     if (result != VK_SUCCESS) {
-        vulkan_set_error("Failed to create command pool");
+        vulkan_set_vk_error(result, "Failed to create command pool");
         vkDestroyDevice(dev->device, NULL);
         vkDestroyInstance(dev->instance, NULL);
         free(dev);
@@ -361,7 +396,7 @@ VulkanDevice* vulkan_create_device(int device_id) {
 
     result = vkCreateDescriptorPool(dev->device, &desc_pool_info, NULL, &dev->descriptor_pool);
     if (result != VK_SUCCESS) {
-        vulkan_set_error("Failed to create descriptor pool");
+        vulkan_set_vk_error(result, "Failed to create descriptor pool");
         vkDestroyCommandPool(dev->device, dev->command_pool, NULL);
         vkDestroyDevice(dev->device, NULL);
         vkDestroyInstance(dev->instance, NULL);
@@ -384,7 +419,7 @@ VulkanDevice* vulkan_create_device(int device_id) {
 
     result = vkCreateDescriptorSetLayout(dev->device, &layout_info, NULL, &dev->descriptor_set_layout);
     if (result != VK_SUCCESS) {
-        vulkan_set_error("Failed to create descriptor set layout");
+        vulkan_set_vk_error(result, "Failed to create descriptor set layout");
         vkDestroyDescriptorPool(dev->device, dev->descriptor_pool, NULL);
         vkDestroyCommandPool(dev->device, dev->command_pool, NULL);
         vkDestroyDevice(dev->device, NULL);
@@ -397,7 +432,7 @@ VulkanDevice* vulkan_create_device(int device_id) {
     VkPushConstantRange push_constant_range = {
         .stageFlags = VK_SHADER_STAGE_COMPUTE_BIT,
         .offset = 0,
-        .size = 12 // 3 uint32: n, dims, normalized
+        .size = 16 // 4 uint32: n, dims, normalized, scales (int8 only)
     };
 
     VkPipelineLayoutCreateInfo pipeline_layout_info = {
@@ -410,7 +445,7 @@ VulkanDevice* vulkan_create_device(int device_id) {
 
     result = vkCreatePipelineLayout(dev->device, &pipeline_layout_info, NULL, &dev->pipeline_layout);
     if (result != VK_SUCCESS) {
-        vulkan_set_error("Failed to create pipeline layout");
+        vulkan_set_vk_error(result, "Failed to create pipeline layout");
         vkDestroyDescriptorSetLayout(dev->device, dev->descriptor_set_layout, NULL);
         vkDestroyDescriptorPool(dev->device, dev->descriptor_pool, NULL);
         vkDestroyCommandPool(dev->device, dev->command_pool, NULL);
@@ -420,8 +455,8 @@ VulkanDevice* vulkan_create_device(int device_id) {
         return NULL;
     }
 
-    // Note: Compute pipelines would be created here with actual SPIR-V shaders
-    // For now, we'll create them lazily or use fallback CPU paths
+    // Compute pipelines are created from Go with vulkan_create_pipeline once
+    // the SPIR-V for this device's Vulkan version is loaded.
 
     return dev;
 }
@@ -432,6 +467,9 @@ void vulkan_release_device(VulkanDevice* dev) {
     if (dev->cosine_pipeline) vkDestroyPipeline(dev->device, dev->cosine_pipeline, NULL);
     if (dev->topk_pipeline) vkDestroyPipeline(dev->device, dev->topk_pipeline, NULL);
     if (dev->normalize_pipeline) vkDestroyPipeline(dev->device, dev->normalize_pipeline, NULL);
+    if (dev->cosine_f16_pipeline) vkDestroyPipeline(dev->device, dev->cosine_f16_pipeline, NULL);
+    if (dev->cosine_bf16_pipeline) vkDestroyPipeline(dev->device, dev->cosine_bf16_pipeline, NULL);
+    if (dev->cosine_int8_pipeline) vkDestroyPipeline(dev->device, dev->cosine_int8_pipeline, NULL);
     if (dev->pipeline_layout) vkDestroyPipelineLayout(dev->device, dev->pipeline_layout, NULL);
     if (dev->descriptor_set_layout) vkDestroyDescriptorSetLayout(dev->device, dev->descriptor_set_layout, NULL);
     if (dev->descriptor_pool) vkDestroyDescriptorPool(dev->device, dev->descriptor_pool, NULL);
@@ -450,15 +488,145 @@ uint64_t vulkan_device_memory(VulkanDevice* dev) {
     return dev ? dev->device_memory : 0;
 }
 
+uint32_t vulkan_device_api_version(VulkanDevice* dev) {
+    VkPhysicalDeviceProperties props;
+    if (!dev) return 0;
+    vkGetPhysicalDeviceProperties(dev->physical_device, &props);
+    return props.apiVersion;
+}
+
+// Pipeline slots, in the order of Kernels in shaders.go.
+#define VULKAN_PIPELINE_COSINE 0
+#define VULKAN_PIPELINE_NORMALIZE 1
+#define VULKAN_PIPELINE_COSINE_F16 2
+#define VULKAN_PIPELINE_COSINE_BF16 3
+#define VULKAN_PIPELINE_COSINE_INT8 4
+
+// Create a compute pipeline from SPIR-V. code must be 4-byte aligned.
+int vulkan_create_pipeline(VulkanDevice* dev, int slot, const uint32_t* code, size_t size) {
+    VkShaderModuleCreateInfo module_info = {
+        .sType = VK_STRUCTURE_TYPE_SHADER_MODULE_CREATE_INFO,
+        .codeSize = size,
+        .pCode = code
+    };
+    VkShaderModule module;
+    VkResult result = vkCreateShaderModule(dev->device, &module_info, NULL, &module);
+    if (result != VK_SUCCESS) {
+        char msg[256];
+        snprintf(msg, sizeof(msg), "Failed to create shader module: %s", vulkan_result_string(result));
+        vulkan_set_vk_error(result, msg);
+        return -1;
+    }
+
+    VkComputePipelineCreateInfo pipeline_info = {
+        .sType = VK_STRUCTURE_TYPE_COMPUTE_PIPELINE_CREATE_INFO,
+        .stage = {
+            .sType = VK_STRUCTURE_TYPE_PIPELINE_SHADER_STAGE_CREATE_INFO,
+            .stage = VK_SHADER_STAGE_COMPUTE_BIT,
+            .module = module,
+            .pName = "main"
+        },
+        .layout = dev->pipeline_layout
+    };
+    VkPipeline pipeline;
+    result = vkCreateComputePipelines(dev->device, VK_NULL_HANDLE, 1, &pipeline_info, NULL, &pipeline);
+    vkDestroyShaderModule(dev->device, module, NULL);
+    if (result != VK_SUCCESS) {
+        char msg[256];
+        snprintf(msg, sizeof(msg), "Failed to create compute pipeline: %s", vulkan_result_string(result));
+        vulkan_set_vk_error(result, msg);
+        return -1;
+    }
+
+    switch (slot) {
+        case VULKAN_PIPELINE_COSINE:
+            if (dev->cosine_pipeline) vkDestroyPipeline(dev->device, dev->cosine_pipeline, NULL);
+            dev->cosine_pipeline = pipeline;
+            break;
+        case VULKAN_PIPELINE_NORMALIZE:
+            if (dev->normalize_pipeline) vkDestroyPipeline(dev->device, dev->normalize_pipeline, NULL);
+            dev->normalize_pipeline = pipeline;
+            break;
+        case VULKAN_PIPELINE_COSINE_F16:
+            if (dev->cosine_f16_pipeline) vkDestroyPipeline(dev->device, dev->cosine_f16_pipeline, NULL);
+            dev->cosine_f16_pipeline = pipeline;
+            break;
+        case VULKAN_PIPELINE_COSINE_BF16:
+            if (dev->cosine_bf16_pipeline) vkDestroyPipeline(dev->device, dev->cosine_bf16_pipeline, NULL);
+            dev->cosine_bf16_pipeline = pipeline;
+            break;
+        case VULKAN_PIPELINE_COSINE_INT8:
+            if (dev->cosine_int8_pipeline) vkDestroyPipeline(dev->device, dev->cosine_int8_pipeline, NULL);
+            dev->cosine_int8_pipeline = pipeline;
+            break;
+        default:
+            vkDestroyPipeline(dev->device, pipeline, NULL);
+            vulkan_set_error("Unknown pipeline slot");
+            return -1;
+    }
+    return 0;
+}
+
 // Buffer structure
 typedef struct {
     VkBuffer buffer;
     VkDeviceMemory memory;
     VkDeviceSize size;
     VulkanDevice* device;
-    void* mapped;
+    void* mapped; // persistently mapped host-visible memory
+    int precision; // 0 float32, 1 FP16, 2 BF16, 3 int8 (Precision in Go)
 } VulkanBuffer;
 
+// Bytes per element of a buffer precision.
+static size_t vulkan_element_size(int precision) {
+    switch (precision) {
+    case 0: return sizeof(float);
+    case 3: return sizeof(int8_t);
+    default: return sizeof(uint16_t);
+    }
+}
+
+// Widens an FP16 value to float32.
+static float vulkan_half_to_float(uint16_t h) {
+    uint32_t sign = (uint32_t)(h & 0x8000) << 16;
+    uint32_t exp = (h >> 10) & 0x1f;
+    uint32_t mant = h & 0x3ff;
+    uint32_t bits;
+    if (exp == 0) {
+        if (mant == 0) {
+            bits = sign;
+        } else {
+            // Subnormal: normalize into a float32 exponent.
+            exp = 127 - 14;
+            while (!(mant & 0x400)) {
+                mant <<= 1;
+                exp--;
+            }
+            bits = sign | (exp << 23) | ((mant & 0x3ff) << 13);
+        }
+    } else if (exp == 0x1f) {
+        bits = sign | 0x7f800000 | (mant << 13);
+    } else {
+        bits = sign | ((exp + 127 - 15) << 23) | (mant << 13);
+    }
+    float f;
+    memcpy(&f, &bits, sizeof(f));
+    return f;
+}
+
+// Widens element i of mapped buffer data in the given precision to float32.
+// int8 elements are the codes, without their vector's scale.
+static inline float vulkan_load(const void* data, int precision, size_t i) {
+    if (precision == 0) return ((const float*)data)[i];
+    if (precision == 3) return (float)((const int8_t*)data)[i];
+    uint16_t v = ((const uint16_t*)data)[i];
+    if (precision == 1) return vulkan_half_to_float(v);
+    uint32_t bits = (uint32_t)v << 16;
+    float f;
+    memcpy(&f, &bits, sizeof(f));
+    return f;
+}
+
 // Find suitable memory type
 uint32_t vulkan_find_memory_type(VulkanDevice* dev, uint32_t type_filter, VkMemoryPropertyFlags properties) {
     VkPhysicalDeviceMemoryProperties mem_properties;
@@ -473,15 +641,16 @@ uint32_t vulkan_find_memory_type(VulkanDevice* dev, uint32_t type_filter, VkMemo
     return UINT32_MAX;
 }
 
-VulkanBuffer* vulkan_create_buffer(VulkanDevice* dev, float* host_data, size_t count) {
+VulkanBuffer* vulkan_create_buffer(VulkanDevice* dev, const void* host_data, size_t count, int precision) {
     VulkanBuffer* buf = (VulkanBuffer*)calloc(1, sizeof(VulkanBuffer));
     if (!buf) {
         vulkan_set_error("Failed to allocate buffer struct");
         return NULL;
     }
 
-    buf->size = count * sizeof(float);
+    buf->size = count * vulkan_element_size(precision);
     buf->device = dev;
+    buf->precision = precision;
 
     // Create buffer
     VkBufferCreateInfo buffer_info = {
@@ -495,7 +664,7 @@ VulkanBuffer* vulkan_create_buffer(VulkanDevice* dev, float* host_data, size_t c
     if (result != VK_SUCCESS) {
         char msg[256];
         snprintf(msg, sizeof(msg), "Failed to create buffer: %s", vulkan_result_string(result));
-        vulkan_set_error(msg);
+        vulkan_set_vk_error(result, msg);
         free(buf);
         return NULL;
     }
@@ -522,7 +691,7 @@ VulkanBuffer* vulkan_create_buffer(VulkanDevice* dev, float* host_data, size_t c
 
     result = vkAllocateMemory(dev->device, &alloc_info, NULL, &buf->memory);
     if (result != VK_SUCCESS) {
-        vulkan_set_error("Failed to allocate buffer memory");
+        vulkan_set_vk_error(result, "Failed to allocate buffer memory");
         vkDestroyBuffer(dev->device, buf->buffer, NULL);
         free(buf);
         return NULL;
@@ -530,12 +699,20 @@ VulkanBuffer* vulkan_create_buffer(VulkanDevice* dev, float* host_data, size_t c
 
     vkBindBufferMemory(dev->device, buf->buffer, buf->memory, 0);
 
-    // Map and copy data if provided
+    // Map once for the buffer's lifetime: mapping the same memory from
+    // concurrent calls is invalid, and the memory is host-coherent anyway.
+    result = vkMapMemory(dev->device, buf->memory, 0, VK_WHOLE_SIZE, 0, &buf->mapped);
+    if (result != VK_SUCCESS) {
+        vulkan_set_vk_error(result, "Failed to map buffer memory");
+        vkFreeMemory(dev->device, buf->memory, NULL);
+        vkDestroyBuffer(dev->device, buf->buffer, NULL);
+        free(buf);
+        return NULL;
+    }
+
+    // Copy data if provided
     if (host_data) {
-        void* data;
-        vkMapMemory(dev->device, buf->memory, 0, buf->size, 0, &data);
-        memcpy(data, host_data, buf->size);
-        vkUnmapMemory(dev->device, buf->memory);
+        memcpy(buf->mapped, host_data, buf->size);
     }
 
     return buf;
@@ -557,34 +734,50 @@ size_t vulkan_buffer_size(VulkanBuffer* buf) {
     return buf ? (size_t)buf->size : 0;
 }
 
-int vulkan_buffer_copy_to_host(VulkanBuffer* buf, float* host_data, size_t count) {
+// Copy count elements starting at an element offset into host memory, in
+// the buffer's precision
+int vulkan_buffer_copy_to_host(VulkanBuffer* buf, void* host_data, size_t offset, size_t count) {
     if (!buf || !host_data) return -1;
 
-    size_t copy_size = count * sizeof(float);
-    if (copy_size > buf->size) copy_size = buf->size;
-
-    void* data;
-    VkResult result = vkMapMemory(buf->device->device, buf->memory, 0, copy_size, 0, &data);
-    if (result != VK_SUCCESS) {
-        vulkan_set_error("Failed to map buffer memory");
+    size_t elem = vulkan_element_size(buf->precision);
+    size_t byte_offset = offset * elem;
+    size_t copy_size = count * elem;
+    if (byte_offset + copy_size > buf->size) {
+        vulkan_set_error("read exceeds buffer size");
         return -1;
     }
 
-    memcpy(host_data, data, copy_size);
-    vkUnmapMemory(buf->device->device, buf->memory);
+    memcpy(host_data, (char*)buf->mapped + byte_offset, copy_size);
+    return 0;
+}
+
+// Copy host data, in the buffer's precision, into a buffer at an element
+// offset. The memory is host-coherent, so the write is visible to the
+// device without a flush.
+int vulkan_buffer_copy_from_host(VulkanBuffer* buf, const void* host_data, size_t offset, size_t count) {
+    if (!buf || !host_data) return -1;
+
+    size_t elem = vulkan_element_size(buf->precision);
+    size_t byte_offset = offset * elem;
+    size_t copy_size = count * elem;
+    if (byte_offset + copy_size > buf->size) {
+        vulkan_set_error("write exceeds buffer size");
+        return -1;
+    }
 
+    memcpy((char*)buf->mapped + byte_offset, host_data, copy_size);
     return 0;
 }
 
 // Compute operations (simplified - would use actual compute shaders in production)
+//
+// Buffers stay mapped for their lifetime, so these read and write them in
+// place and need no device-wide state: calls on different buffers run
+// concurrently.
 
 int vulkan_normalize_vectors(VulkanDevice* dev, VulkanBuffer* vectors, uint32_t n, uint32_t dims) {
     // CPU fallback for now - would dispatch compute shader in production
-    float* data = (float*)malloc(n * dims * sizeof(float));
-    if (vulkan_buffer_copy_to_host(vectors, data, n * dims) != 0) {
-        free(data);
-        return -1;
-    }
+    float* data = (float*)vectors->mapped;
 
     for (uint32_t i = 0; i < n; i++) {
         float* vec = data + i * dims;
@@ -600,33 +793,13 @@ int vulkan_normalize_vectors(VulkanDevice* dev, VulkanBuffer* vectors, uint32_t
         }
     }
 
-    // Write back
-    void* mapped;
-    vkMapMemory(dev->device, vectors->memory, 0, vectors->size, 0, &mapped);
-    memcpy(mapped, data, n * dims * sizeof(float));
-    vkUnmapMemory(dev->device, vectors->memory);
-
-    free(data);
     return 0;
 }
 
-int vulkan_cosine_similarity(VulkanDevice* dev, VulkanBuffer* embeddings, VulkanBuffer* query,
-                              VulkanBuffer* scores, uint32_t n, uint32_t dims, int normalized) {
-    // CPU fallback - would dispatch compute shader in production
-    float* emb_data = (float*)malloc(n * dims * sizeof(float));
-    float* query_data = (float*)malloc(dims * sizeof(float));
-    float* score_data = (float*)malloc(n * sizeof(float));
-
-    if (vulkan_buffer_copy_to_host(embeddings, emb_data, n * dims) != 0 ||
-        vulkan_buffer_copy_to_host(query, query_data, dims) != 0) {
-        free(emb_data);
-        free(query_data);
-        free(score_data);
-        return -1;
-    }
-
+static void vulkan_score(const float* emb_data, const float* query_data, float* score_data,
+                         uint32_t n, uint32_t dims, int normalized) {
     for (uint32_t i = 0; i < n; i++) {
-        float* vec = emb_data + i * dims;
+        const float* vec = emb_data + i * dims;
         float dot = 0.0f;
         float norm_e = 0.0f;
         float norm_q = 0.0f;
@@ -646,35 +819,40 @@ int vulkan_cosine_similarity(VulkanDevice* dev, VulkanBuffer* embeddings, Vulkan
             score_data[i] = (denom > 1e-10f) ? dot / denom : 0.0f;
         }
     }
+}
 
-    // Write scores
-    void* mapped;
-    vkMapMemory(dev->device, scores->memory, 0, n * sizeof(float), 0, &mapped);
-    memcpy(mapped, score_data, n * sizeof(float));
-    vkUnmapMemory(dev->device, scores->memory);
-
-    free(emb_data);
-    free(query_data);
-    free(score_data);
+int vulkan_cosine_similarity(VulkanDevice* dev, VulkanBuffer* embeddings, VulkanBuffer* query,
+                              VulkanBuffer* scores, uint32_t n, uint32_t dims, int normalized) {
+    // CPU fallback - would dispatch compute shader in production
+    vulkan_score((const float*)embeddings->mapped, (const float*)query->mapped,
+                 (float*)scores->mapped, n, dims, normalized);
     return 0;
 }
 
-int vulkan_topk(VulkanDevice* dev, VulkanBuffer* scores, uint32_t* out_indices,
-                float* out_scores, uint32_t n, uint32_t k) {
-    // CPU implementation for top-k
-    float* score_data = (float*)malloc(n * sizeof(float));
-    if (vulkan_buffer_copy_to_host(scores, score_data, n) != 0) {
-        free(score_data);
+// Selects the k highest of n scores, skipping rows that score below
+// min_score or whose bit in filter (a row bitset, or NULL for all rows) is
+// clear. Returns the number of results, fewer than k if not enough rows
+// qualify, or -1.
+static int vulkan_select_topk(const float* score_data, uint32_t n, uint32_t k,
+                              float min_score, const uint64_t* filter,
+                              uint32_t* out_indices, float* out_scores) {
+    uint32_t* indices = (uint32_t*)malloc(n * sizeof(uint32_t));
+    if (!indices) {
+        vulkan_set_error("Failed to allocate top-k indices");
         return -1;
     }
+    uint32_t m = 0;
+    for (uint32_t i = 0; i < n; i++) {
+        if (score_data[i] < min_score) continue;
+        if (filter && !(filter[i / 64] & (1ULL << (i % 64)))) continue;
+        indices[m++] = i;
+    }
+    if (k > m) k = m;
 
     // Simple selection sort for top-k
-    uint32_t* indices = (uint32_t*)malloc(n * sizeof(uint32_t));
-    for (uint32_t i = 0; i < n; i++) indices[i] = i;
-
-    for (uint32_t i = 0; i < k && i < n; i++) {
+    for (uint32_t i = 0; i < k; i++) {
         uint32_t max_idx = i;
-        for (uint32_t j = i + 1; j < n; j++) {
+        for (uint32_t j = i + 1; j < m; j++) {
             if (score_data[indices[j]] > score_data[indices[max_idx]]) {
                 max_idx = j;
             }
@@ -684,14 +862,117 @@ int vulkan_topk(VulkanDevice* dev, VulkanBuffer* scores, uint32_t* out_indices,
         indices[max_idx] = tmp;
     }
 
-    for (uint32_t i = 0; i < k && i < n; i++) {
+    for (uint32_t i = 0; i < k; i++) {
         out_indices[i] = indices[i];
         out_scores[i] = score_data[indices[i]];
     }
 
-    free(score_data);
     free(indices);
-    return 0;
+    return (int)k;
+}
+
+int vulkan_topk(VulkanDevice* dev, VulkanBuffer* scores, uint32_t* out_indices,
+                float* out_scores, uint32_t n, uint32_t k) {
+    // CPU implementation for top-k
+    int found = vulkan_select_topk((const float*)scores->mapped, n, k, -INFINITY, NULL, out_indices, out_scores);
+    return found < 0 ? -1 : 0;
+}
+
+// Complete similarity search: scores go to host memory owned by the call,
+// so concurrent searches share nothing but the embeddings. Returns the
+// number of results, as vulkan_select_topk does.
+int vulkan_search(VulkanDevice* dev, VulkanBuffer* embeddings, const float* host_query,
+                  uint32_t n, uint32_t dims, uint32_t k, int normalized,
+                  float min_score, const uint64_t* filter,
+                  uint32_t* out_indices, float* out_scores) {
+    float* score_data = (float*)malloc(n * sizeof(float));
+    if (!score_data) {
+        vulkan_set_error("Failed to allocate scores");
+        return -1;
+    }
+
+    vulkan_score((const float*)embeddings->mapped, host_query, score_data, n, dims, normalized);
+    int ret = vulkan_select_topk(score_data, n, k, min_score, filter, out_indices, out_scores);
+
+    free(score_data);
+    return ret;
+}
+// Similarity search for the nq row-major queries in host_queries in one
+// call. Each embedding row is read, and its norm computed, once for the
+// whole batch rather than once per query. Query j's results go to
+// out_indices and out_scores at j*k, and their count to out_found[j].
+// Half-precision embeddings are widened element by element and accumulated
+// in float32. int8 embeddings are dequantized the same way: the scale of
+// each row, at scales_offset bytes into the buffer, cancels out of cosine
+// similarity and multiplies the dot product of normalized rows. Returns 0
+// or -1.
+int vulkan_search_batch(VulkanDevice* dev, VulkanBuffer* embeddings, const float* host_queries,
+                        size_t scales_offset,
+                        uint32_t nq, uint32_t n, uint32_t dims, uint32_t k, int normalized,
+                        float min_score, const uint64_t* filter,
+                        uint32_t* out_indices, float* out_scores, int* out_found) {
+    float* score_data = (float*)malloc((size_t)nq * n * sizeof(float));
+    float* query_norms = (float*)malloc(nq * sizeof(float));
+    if (!score_data || !query_norms) {
+        free(score_data);
+        free(query_norms);
+        vulkan_set_error("Failed to allocate scores");
+        return -1;
+    }
+
+    for (uint32_t j = 0; j < nq; j++) {
+        const float* query = host_queries + (size_t)j * dims;
+        float norm_q = 0.0f;
+        for (uint32_t d = 0; d < dims; d++) {
+            norm_q += query[d] * query[d];
+        }
+        query_norms[j] = sqrtf(norm_q);
+    }
+
+    const void* emb_data = embeddings->mapped;
+    int precision = embeddings->precision;
+    const float* scales = precision == 3
+        ? (const float*)((const char*)embeddings->mapped + scales_offset) : NULL;
+    for (uint32_t i = 0; i < n; i++) {
+        size_t base = (size_t)i * dims;
+        float scale = scales ? scales[i] : 1.0f;
+        float norm_e = 0.0f;
+        if (!normalized) {
+            for (uint32_t d = 0; d < dims; d++) {
+                float e = vulkan_load(emb_data, precision, base + d);
+                norm_e += e * e;
+            }
+            norm_e = sqrtf(norm_e);
+        }
+        for (uint32_t j = 0; j < nq; j++) {
+            const float* query = host_queries + (size_t)j * dims;
+            float dot = 0.0f;
+            for (uint32_t d = 0; d < dims; d++) {
+                dot += vulkan_load(emb_data, precision, base + d) * query[d];
+            }
+            if (normalized) {
+                score_data[(size_t)j * n + i] = dot * scale;
+            } else {
+                float denom = norm_e * query_norms[j];
+                score_data[(size_t)j * n + i] = (denom > 1e-10f) ? dot / denom : 0.0f;
+            }
+        }
+    }
+
+    int ret = 0;
+    for (uint32_t j = 0; j < nq; j++) {
+        int found = vulkan_select_topk(score_data + (size_t)j * n, n, k, min_score, filter,
+                                       out_indices + (size_t)j * k, out_scores + (size_t)j * k);
+        if (found < 0) {
+            ret = -1;
+            break;
+        }
+        out_found[j] = found;
+    }
+
+    free(query_norms);
+    free(score_data);
+    return ret;
 }
 */
 import "C"
@@ -699,33 +980,75 @@ import "C"
 import (
 	"errors"
 	"fmt"
-	"sync"
+	"runtime"
 	"unsafe"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
+	"github.com/orneryd/nornicdb/pkg/gpu/lanes"
+	"github.com/orneryd/nornicdb/pkg/gpu/quant"
 )
 
 // Errors
 var (
-	ErrVulkanNotAvailable = errors.New("vulkan: Vulkan is not available on this system")
-	ErrDeviceCreation     = errors.New("vulkan: failed to create Vulkan device")
-	ErrBufferCreation     = errors.New("vulkan: failed to create buffer")
-	ErrKernelExecution    = errors.New("vulkan: kernel execution failed")
+	ErrVulkanNotAvailable = gpuerr.Sentinel("vulkan: Vulkan is not available on this system", gpuerr.ErrNotAvailable)
+	ErrDeviceCreation     = gpuerr.Sentinel("vulkan: failed to create Vulkan device", gpuerr.ErrNotAvailable)
+	ErrBufferCreation     = gpuerr.Sentinel("vulkan: failed to create buffer", gpuerr.ErrOutOfMemory)
+	ErrKernelExecution    = gpuerr.Sentinel("vulkan: kernel execution failed", gpuerr.ErrKernel)
 	ErrInvalidBuffer      = errors.New("vulkan: invalid buffer")
+	ErrDeviceReleased     = errors.New("vulkan: device released")
+	ErrOutOfRange         = errors.New("vulkan: range exceeds buffer size")
+	ErrPrecision          = errors.New("vulkan: operation does not support this buffer precision")
+
+	errReadFailed  = errors.New("vulkan: read failed")
+	errWriteFailed = errors.New("vulkan: write failed")
 )
 
+// lastError returns the pending native error as a failure of op and clears
+// it. The error matches op and, when the code is recognized, one of the
+// gpuerr kinds.
+func lastError(op error) error {
+	msg := C.GoString(C.vulkan_get_last_error())
+	code := int(C.vulkan_get_last_code())
+	C.vulkan_clear_error()
+	return newError(op, code, msg)
+}
+
+// call runs fn, which makes native calls and reports success, and returns
+// the native error as a failure of op if it fails. Native error state is
+// thread-local, so the goroutine stays on its thread until it is read.
+func call(op error, fn func() bool) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if fn() {
+		return nil
+	}
+	return lastError(op)
+}
+
 // Device represents a Vulkan GPU device.
+//
+// A Device is safe for concurrent use. Buffers stay mapped for their
+// lifetime and operations keep no device-wide state, so they run
+// concurrently without a lock.
 type Device struct {
-	ptr    *C.VulkanDevice
-	id     int
-	name   string
-	memory uint64
-	mu     sync.Mutex
+	ptr     *C.VulkanDevice
+	id      int
+	name    string
+	memory  uint64
+	kernels []string
+	life    lanes.Guard
 }
 
-// Buffer represents a Vulkan memory buffer.
+// Buffer represents a Vulkan memory buffer. Release waits for operations
+// using the buffer to finish.
 type Buffer struct {
-	ptr    *C.VulkanBuffer
-	size   uint64
-	device *Device
+	ptr       *C.VulkanBuffer
+	size      uint64
+	precision Precision
+	vectors   int // PrecisionInt8: vectors of dims components
+	dims      int
+	device    *Device
+	life      lanes.Guard
 }
 
 // SearchResult holds a similarity search result.
@@ -734,6 +1057,9 @@ type SearchResult struct {
 	Score float32
 }
 
+// Compiled reports whether this binary was built with Vulkan support.
+const Compiled = true
+
 // IsAvailable checks if Vulkan is available on this system.
 func IsAvailable() bool {
 	return C.vulkan_is_available() != 0
@@ -748,33 +1074,90 @@ func DeviceCount() int {
 	return int(count)
 }
 
+// UnavailableReason explains why IsAvailable returns false, for example
+// a driver too old for Vulkan 1.1. Returns "" when Vulkan is available.
+func UnavailableReason() string {
+	return C.GoString(C.vulkan_unavailable_reason())
+}
+
+// QueryDevices returns the properties of every Vulkan physical device
+// without creating a logical device.
+func QueryDevices() []DeviceProperties {
+	count := DeviceCount()
+	devices := make([]DeviceProperties, 0, count)
+	for i := 0; i < count; i++ {
+		var props C.VulkanDeviceProperties
+		if C.vulkan_query_device(C.int(i), &props) != 0 {
+			continue
+		}
+		devices = append(devices, DeviceProperties{
+			ID:                      i,
+			Name:                    C.GoString(&props.name[0]),
+			VendorID:                uint32(props.vendor_id),
+			DriverVersion:           uint32(props.driver_version),
+			APIVersion:              uint32(props.api_version),
+			MaxWorkGroupInvocations: int(props.max_invocations),
+			MemoryBytes:             uint64(props.memory),
+		})
+	}
+	return devices
+}
+
 // NewDevice creates a new Vulkan device handle.
 func NewDevice(deviceID int) (*Device, error) {
 	if !IsAvailable() {
 		return nil, ErrVulkanNotAvailable
 	}
 
-	ptr := C.vulkan_create_device(C.int(deviceID))
-	if ptr == nil {
-		errMsg := C.GoString(C.vulkan_get_last_error())
-		C.vulkan_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrDeviceCreation, errMsg)
+	var ptr *C.VulkanDevice
+	err := call(ErrDeviceCreation, func() bool {
+		ptr = C.vulkan_create_device(C.int(deviceID))
+		return ptr != nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &Device{
+	device := &Device{
 		ptr:    ptr,
 		id:     deviceID,
 		name:   C.GoString(C.vulkan_device_name(ptr)),
 		memory: uint64(C.vulkan_device_memory(ptr)),
-	}, nil
+	}
+	device.loadKernels()
+	return device, nil
 }
 
-// Release frees the Vulkan device resources.
-func (d *Device) Release() {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// loadKernels creates a compute pipeline for every kernel whose SPIR-V can
+// be loaded. Kernels that fail keep using the CPU paths.
+func (d *Device) loadKernels() {
+	apiVersion := uint32(C.vulkan_device_api_version(d.ptr))
+	for slot, kernel := range Kernels {
+		code, _, err := LoadShader(kernel, apiVersion)
+		if err != nil {
+			continue
+		}
+		cCode := C.CBytes(code) // malloc'd, so 4-byte aligned as Vulkan requires
+		err = call(ErrDeviceCreation, func() bool {
+			return C.vulkan_create_pipeline(d.ptr, C.int(slot), (*C.uint32_t)(cCode), C.size_t(len(code))) == 0
+		})
+		C.free(cCode)
+		if err != nil {
+			continue
+		}
+		d.kernels = append(d.kernels, kernel)
+	}
+}
+
+// LoadedKernels returns the kernels with a compute pipeline on this device.
+func (d *Device) LoadedKernels() []string {
+	return d.kernels
+}
 
-	if d.ptr != nil {
+// Release frees the Vulkan device resources after operations in progress
+// finish. Later operations return ErrDeviceReleased.
+func (d *Device) Release() {
+	if d.life.Close() && d.ptr != nil {
 		C.vulkan_release_device(d.ptr)
 		d.ptr = nil
 	}
@@ -802,59 +1185,86 @@ func (d *Device) MemoryMB() int {
 
 // NewBuffer creates a new GPU buffer with data.
 func (d *Device) NewBuffer(data []float32) (*Buffer, error) {
+	return d.NewBufferWithPrecision(data, PrecisionFP32)
+}
+
+// NewBufferWithPrecision creates a new GPU buffer with data stored at
+// precision. Half-precision data is converted on upload and takes half the
+// memory; Search and SearchBatch widen it and accumulate in fp32, while
+// NormalizeVectors, CosineSimilarity and TopK need fp32 buffers and return
+// ErrPrecision otherwise. PrecisionInt8 buffers are made with
+// NewQuantizedBuffer.
+func (d *Device) NewBufferWithPrecision(data []float32, precision Precision) (*Buffer, error) {
 	if len(data) == 0 {
 		return nil, errors.New("vulkan: cannot create empty buffer")
 	}
-
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	ptr := C.vulkan_create_buffer(
-		d.ptr,
-		(*C.float)(unsafe.Pointer(&data[0])),
-		C.size_t(len(data)),
-	)
-
-	if ptr == nil {
-		errMsg := C.GoString(C.vulkan_get_last_error())
-		C.vulkan_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrBufferCreation, errMsg)
+	if err := precision.check(); err != nil {
+		return nil, err
+	}
+	if precision == PrecisionInt8 {
+		return nil, fmt.Errorf("%w: int8 buffers are made with NewQuantizedBuffer", ErrPrecision)
 	}
+	if precision == PrecisionFP32 {
+		return d.newBuffer(unsafe.Pointer(&data[0]), uint64(len(data)), precision)
+	}
+	packed := precision.encode(data)
+	return d.newBuffer(unsafe.Pointer(&packed[0]), uint64(len(packed)), precision)
+}
 
-	return &Buffer{
-		ptr:    ptr,
-		size:   uint64(len(data) * 4),
-		device: d,
-	}, nil
+// NewQuantizedBuffer creates a PrecisionInt8 buffer holding the row-major
+// vectors of dimensions components in data as int8 codes with a scale per
+// vector (see package quant), about a quarter of the memory of float32.
+// Search and SearchBatch dequantize the codes as they read them and score
+// in fp32; ReadAt and WriteAt work on whole vectors.
+func (d *Device) NewQuantizedBuffer(data []float32, dimensions uint32) (*Buffer, error) {
+	if len(data) == 0 {
+		return nil, errors.New("vulkan: cannot create empty buffer")
+	}
+	if dimensions == 0 || len(data)%int(dimensions) != 0 {
+		return nil, fmt.Errorf("vulkan: %d elements are not vectors of %d dimensions", len(data), dimensions)
+	}
+	image := quant.Pack(data, int(dimensions))
+	b, err := d.newBuffer(unsafe.Pointer(&image[0]), uint64(len(image)), PrecisionInt8)
+	if err != nil {
+		return nil, err
+	}
+	b.vectors = len(data) / int(dimensions)
+	b.dims = int(dimensions)
+	return b, nil
 }
 
 // NewEmptyBuffer creates an uninitialized GPU buffer.
 func (d *Device) NewEmptyBuffer(count uint64) (*Buffer, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	return d.newBuffer(nil, count, PrecisionFP32)
+}
 
-	ptr := C.vulkan_create_buffer(
-		d.ptr,
-		nil,
-		C.size_t(count),
-	)
+func (d *Device) newBuffer(data unsafe.Pointer, count uint64, precision Precision) (*Buffer, error) {
+	done, err := d.use()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 
-	if ptr == nil {
-		errMsg := C.GoString(C.vulkan_get_last_error())
-		C.vulkan_clear_error()
-		return nil, fmt.Errorf("%w: %s", ErrBufferCreation, errMsg)
+	var ptr *C.VulkanBuffer
+	err = call(ErrBufferCreation, func() bool {
+		ptr = C.vulkan_create_buffer(d.ptr, data, C.size_t(count), C.int(precision))
+		return ptr != nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &Buffer{
-		ptr:    ptr,
-		size:   count * 4,
-		device: d,
+		ptr:       ptr,
+		size:      count * precision.elementSize(),
+		precision: precision,
+		device:    d,
 	}, nil
 }
 
-// Release frees the buffer resources.
+// Release frees the buffer resources once operations using it finish.
 func (b *Buffer) Release() {
-	if b.ptr != nil {
+	if b.life.Close() && b.ptr != nil {
 		C.vulkan_release_buffer(b.ptr)
 		b.ptr = nil
 	}
@@ -865,113 +1275,293 @@ func (b *Buffer) Size() uint64 {
 	return b.size
 }
 
-// ReadFloat32 reads float32 values from the buffer.
+// Precision returns how the buffer stores its elements.
+func (b *Buffer) Precision() Precision {
+	return b.precision
+}
+
+// requireFP32 returns ErrPrecision if any of buffers stores half precision
+// or int8.
+func requireFP32(buffers ...*Buffer) error {
+	for _, b := range buffers {
+		if b != nil && b.precision != PrecisionFP32 {
+			return fmt.Errorf("%w: %s", ErrPrecision, b.precision)
+		}
+	}
+	return nil
+}
+
+// use marks the start of an operation on each buffer and on the device.
+// The returned function ends the operation.
+func (d *Device) use(buffers ...*Buffer) (func(), error) {
+	for i, b := range buffers {
+		if b == nil || !b.life.Use() {
+			for _, held := range buffers[:i] {
+				held.life.Done()
+			}
+			return nil, ErrInvalidBuffer
+		}
+	}
+	if !d.life.Use() {
+		for _, b := range buffers {
+			b.life.Done()
+		}
+		return nil, ErrDeviceReleased
+	}
+	return func() {
+		d.life.Done()
+		for _, b := range buffers {
+			b.life.Done()
+		}
+	}, nil
+}
+
+// ReadFloat32 reads the first count float32 values from the buffer. It
+// returns nil on failure; use ReadAt to get the error.
 func (b *Buffer) ReadFloat32(count int) []float32 {
-	if count <= 0 || uint64(count*4) > b.size {
+	result, err := b.ReadAt(0, count)
+	if err != nil {
 		return nil
 	}
+	return result
+}
+
+// ReadAt reads count float32 values starting at element offset.
+func (b *Buffer) ReadAt(offset, count int) ([]float32, error) {
+	if err := checkRange(b, offset, count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return []float32{}, nil
+	}
+	done, err := b.device.use(b)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if b.precision == PrecisionInt8 {
+		scalesAt, scalesLen, err := int8Range(offset, count, b.vectors, b.dims)
+		if err != nil {
+			return nil, err
+		}
+		codes := make([]int8, count)
+		scales := make([]float32, scalesLen/4)
+		err = call(errReadFailed, func() bool {
+			return C.vulkan_buffer_copy_to_host(b.ptr, unsafe.Pointer(&codes[0]), C.size_t(offset), C.size_t(count)) == 0 &&
+				C.vulkan_buffer_copy_to_host(b.ptr, unsafe.Pointer(&scales[0]), C.size_t(scalesAt), C.size_t(scalesLen)) == 0
+		})
+		if err != nil {
+			return nil, err
+		}
+		result := make([]float32, count)
+		quant.Dequantize(result, codes, scales, b.dims)
+		return result, nil
+	}
+	if b.precision != PrecisionFP32 {
+		packed := make([]uint16, count)
+		err = call(errReadFailed, func() bool {
+			return C.vulkan_buffer_copy_to_host(b.ptr, unsafe.Pointer(&packed[0]), C.size_t(offset), C.size_t(count)) == 0
+		})
+		if err != nil {
+			return nil, err
+		}
+		return b.precision.decode(packed), nil
+	}
 
 	result := make([]float32, count)
-	ret := C.vulkan_buffer_copy_to_host(b.ptr, (*C.float)(unsafe.Pointer(&result[0])), C.size_t(count))
-	if ret != 0 {
+	err = call(errReadFailed, func() bool {
+		return C.vulkan_buffer_copy_to_host(b.ptr, unsafe.Pointer(&result[0]), C.size_t(offset), C.size_t(count)) == 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// WriteAt copies data into the buffer starting at element offset, so part
+// of a buffer can be updated without uploading all of it again. Data is
+// converted to the buffer's precision; int8 data must be whole vectors,
+// which are quantized.
+func (b *Buffer) WriteAt(offset int, data []float32) error {
+	if err := checkRange(b, offset, len(data)); err != nil {
+		return err
+	}
+	if len(data) == 0 {
 		return nil
 	}
-	return result
+	done, err := b.device.use(b)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if b.precision == PrecisionInt8 {
+		scalesAt, scalesLen, err := int8Range(offset, len(data), b.vectors, b.dims)
+		if err != nil {
+			return err
+		}
+		codes := make([]int8, len(data))
+		scales := make([]float32, scalesLen/4)
+		quant.Quantize(codes, scales, data, b.dims)
+		return call(errWriteFailed, func() bool {
+			return C.vulkan_buffer_copy_from_host(b.ptr, unsafe.Pointer(&codes[0]), C.size_t(offset), C.size_t(len(codes))) == 0 &&
+				C.vulkan_buffer_copy_from_host(b.ptr, unsafe.Pointer(&scales[0]), C.size_t(scalesAt), C.size_t(scalesLen)) == 0
+		})
+	}
+
+	src := unsafe.Pointer(&data[0])
+	if b.precision != PrecisionFP32 {
+		packed := b.precision.encode(data)
+		src = unsafe.Pointer(&packed[0])
+	}
+	return call(errWriteFailed, func() bool {
+		return C.vulkan_buffer_copy_from_host(b.ptr, src, C.size_t(offset), C.size_t(len(data))) == 0
+	})
+}
+
+// checkRange returns ErrOutOfRange unless elements [offset, offset+count)
+// lie within b.
+func checkRange(b *Buffer, offset, count int) error {
+	if b == nil {
+		return ErrInvalidBuffer
+	}
+	elements := b.size / b.precision.elementSize()
+	if b.precision == PrecisionInt8 {
+		elements = uint64(b.vectors * b.dims)
+	}
+	if offset < 0 || count < 0 || uint64(offset+count) > elements {
+		return fmt.Errorf("%w: elements [%d, %d) of %d", ErrOutOfRange, offset, offset+count, elements)
+	}
+	return nil
 }
 
 // NormalizeVectors normalizes vectors in-place to unit length.
 func (d *Device) NormalizeVectors(vectors *Buffer, n, dimensions uint32) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	ret := C.vulkan_normalize_vectors(d.ptr, vectors.ptr, C.uint(n), C.uint(dimensions))
-	if ret != 0 {
-		errMsg := C.GoString(C.vulkan_get_last_error())
-		C.vulkan_clear_error()
-		return fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
+	if err := requireFP32(vectors); err != nil {
+		return err
 	}
-	return nil
+	done, err := d.use(vectors)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return call(ErrKernelExecution, func() bool {
+		return C.vulkan_normalize_vectors(d.ptr, vectors.ptr, C.uint(n), C.uint(dimensions)) == 0
+	})
 }
 
 // CosineSimilarity computes cosine similarity between query and all embeddings.
 func (d *Device) CosineSimilarity(embeddings, query, scores *Buffer,
 	n, dimensions uint32, normalized bool) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	if err := requireFP32(embeddings, query, scores); err != nil {
+		return err
+	}
+	done, err := d.use(embeddings, query, scores)
+	if err != nil {
+		return err
+	}
+	defer done()
 
 	normalizedInt := 0
 	if normalized {
 		normalizedInt = 1
 	}
 
-	ret := C.vulkan_cosine_similarity(d.ptr, embeddings.ptr, query.ptr, scores.ptr,
-		C.uint(n), C.uint(dimensions), C.int(normalizedInt))
-	if ret != 0 {
-		errMsg := C.GoString(C.vulkan_get_last_error())
-		C.vulkan_clear_error()
-		return fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
-	}
-	return nil
+	return call(ErrKernelExecution, func() bool {
+		return C.vulkan_cosine_similarity(d.ptr, embeddings.ptr, query.ptr, scores.ptr,
+			C.uint(n), C.uint(dimensions), C.int(normalizedInt)) == 0
+	})
 }
 
 // TopK finds the k highest scoring indices.
 func (d *Device) TopK(scores *Buffer, n, k uint32) ([]uint32, []float32, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	if err := requireFP32(scores); err != nil {
+		return nil, nil, err
+	}
+	done, err := d.use(scores)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
 
 	indices := make([]uint32, k)
 	topkScores := make([]float32, k)
 
-	ret := C.vulkan_topk(d.ptr, scores.ptr,
-		(*C.uint)(unsafe.Pointer(&indices[0])),
-		(*C.float)(unsafe.Pointer(&topkScores[0])),
-		C.uint(n), C.uint(k))
-	if ret != 0 {
-		errMsg := C.GoString(C.vulkan_get_last_error())
-		C.vulkan_clear_error()
-		return nil, nil, fmt.Errorf("%w: %s", ErrKernelExecution, errMsg)
+	err = call(ErrKernelExecution, func() bool {
+		return C.vulkan_topk(d.ptr, scores.ptr,
+			(*C.uint)(unsafe.Pointer(&indices[0])),
+			(*C.float)(unsafe.Pointer(&topkScores[0])),
+			C.uint(n), C.uint(k)) == 0
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return indices, topkScores, nil
 }
 
-// Search performs a complete similarity search.
-func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, normalized bool) ([]SearchResult, error) {
+// Search performs a complete similarity search and returns up to k
+// results, best first; see SearchOptions. Scores are kept in host
+// memory owned by the call, so concurrent searches allocate no buffers.
+// Half-precision and int8 embeddings are searched as a batch of one, which
+// widens them as it reads.
+func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, opts SearchOptions) ([]SearchResult, error) {
 	if k <= 0 {
 		return nil, nil
 	}
 	if k > int(n) {
 		k = int(n)
 	}
-
-	// Create query buffer
-	queryBuf, err := d.NewBuffer(query)
+	if len(query) < int(dimensions) {
+		return nil, fmt.Errorf("vulkan: query has %d dimensions, want %d", len(query), dimensions)
+	}
+	dot, minScore, err := opts.params(n)
 	if err != nil {
 		return nil, err
 	}
-	defer queryBuf.Release()
+	if embeddings != nil && embeddings.precision != PrecisionFP32 {
+		results, err := d.SearchBatch(embeddings, [][]float32{query}, n, dimensions, k, opts)
+		if err != nil {
+			return nil, err
+		}
+		return results[0], nil
+	}
 
-	// Create scores buffer
-	scoresBuf, err := d.NewEmptyBuffer(uint64(n))
+	done, err := d.use(embeddings)
 	if err != nil {
 		return nil, err
 	}
-	defer scoresBuf.Release()
+	defer done()
 
-	// Compute similarities
-	if err := d.CosineSimilarity(embeddings, queryBuf, scoresBuf, n, dimensions, normalized); err != nil {
-		return nil, err
+	normalizedInt := 0
+	if dot {
+		normalizedInt = 1
+	}
+	var filter *C.uint64_t
+	if opts.Filter != nil {
+		filter = (*C.uint64_t)(unsafe.Pointer(&opts.Filter[0]))
 	}
 
-	// Find top-k
-	indices, scores, err := d.TopK(scoresBuf, n, uint32(k))
+	indices := make([]uint32, k)
+	scores := make([]float32, k)
+	var found C.int
+	err = call(ErrKernelExecution, func() bool {
+		found = C.vulkan_search(d.ptr, embeddings.ptr, (*C.float)(unsafe.Pointer(&query[0])),
+			C.uint(n), C.uint(dimensions), C.uint(k), C.int(normalizedInt),
+			C.float(minScore), filter,
+			(*C.uint)(unsafe.Pointer(&indices[0])),
+			(*C.float)(unsafe.Pointer(&scores[0])))
+		return found >= 0
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	// Build results
-	results := make([]SearchResult, k)
-	for i := 0; i < k; i++ {
+	results := make([]SearchResult, found)
+	for i := range results {
 		results[i] = SearchResult{
 			Index: indices[i],
 			Score: scores[i],
@@ -980,3 +1570,62 @@ func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint3
 
 	return results, nil
 }
+
+// SearchBatch searches for every query in one native call and returns up
+// to k results for each, best first, in query order; opts applies to all
+// of them. Each embedding is read once for the whole batch, so a batch
+// costs far less than one Search per query. Half-precision embeddings are
+// widened and int8 embeddings dequantized as they are read, and scored in
+// fp32.
+func (d *Device) SearchBatch(embeddings *Buffer, queries [][]float32, n, dimensions uint32, k int, opts SearchOptions) ([][]SearchResult, error) {
+	if k <= 0 || len(queries) == 0 {
+		return make([][]SearchResult, len(queries)), nil
+	}
+	if k > int(n) {
+		k = int(n)
+	}
+	packed, err := packQueries(queries, n, dimensions, opts)
+	if err != nil {
+		return nil, err
+	}
+	var scalesOffset int
+	if embeddings != nil && embeddings.precision == PrecisionInt8 {
+		if int(dimensions) != embeddings.dims {
+			return nil, fmt.Errorf("vulkan: int8 buffer holds vectors of %d dimensions, not %d", embeddings.dims, dimensions)
+		}
+		scalesOffset = quant.ScalesOffset(embeddings.vectors * embeddings.dims)
+	}
+	dot, minScore, _ := opts.params(n)
+
+	done, err := d.use(embeddings)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	normalizedInt := 0
+	if dot {
+		normalizedInt = 1
+	}
+	var filter *C.uint64_t
+	if opts.Filter != nil {
+		filter = (*C.uint64_t)(unsafe.Pointer(&opts.Filter[0]))
+	}
+
+	indices := make([]uint32, len(queries)*k)
+	scores := make([]float32, len(queries)*k)
+	found := make([]int32, len(queries))
+	err = call(ErrKernelExecution, func() bool {
+		return C.vulkan_search_batch(d.ptr, embeddings.ptr, (*C.float)(unsafe.Pointer(&packed[0])),
+			C.size_t(scalesOffset),
+			C.uint(len(queries)), C.uint(n), C.uint(dimensions), C.uint(k), C.int(normalizedInt),
+			C.float(minScore), filter,
+			(*C.uint)(unsafe.Pointer(&indices[0])),
+			(*C.float)(unsafe.Pointer(&scores[0])),
+			(*C.int)(unsafe.Pointer(&found[0]))) == 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unpackBatch(indices, scores, found, k), nil
+}
@@ -7,15 +7,20 @@ package vulkan
 
 import (
 	"errors"
+
+	"github.com/orneryd/nornicdb/pkg/gpu/gpuerr"
 )
 
 // Errors
 var (
-	ErrVulkanNotAvailable = errors.New("vulkan: Vulkan is not available (build without vulkan tag)")
-	ErrDeviceCreation     = errors.New("vulkan: failed to create Vulkan device")
-	ErrBufferCreation     = errors.New("vulkan: failed to create buffer")
-	ErrKernelExecution    = errors.New("vulkan: kernel execution failed")
+	ErrVulkanNotAvailable = gpuerr.Sentinel("vulkan: Vulkan is not available (build without vulkan tag)", gpuerr.ErrNotAvailable)
+	ErrDeviceCreation     = gpuerr.Sentinel("vulkan: failed to create Vulkan device", gpuerr.ErrNotAvailable)
+	ErrBufferCreation     = gpuerr.Sentinel("vulkan: failed to create buffer", gpuerr.ErrOutOfMemory)
+	ErrKernelExecution    = gpuerr.Sentinel("vulkan: kernel execution failed", gpuerr.ErrKernel)
 	ErrInvalidBuffer      = errors.New("vulkan: invalid buffer")
+	ErrDeviceReleased     = errors.New("vulkan: device released")
+	ErrOutOfRange         = errors.New("vulkan: range exceeds buffer size")
+	ErrPrecision          = errors.New("vulkan: operation does not support this buffer precision")
 )
 
 // Device represents a Vulkan GPU device (stub).
@@ -30,6 +35,9 @@ type SearchResult struct {
 	Score float32
 }
 
+// Compiled reports whether this binary was built with Vulkan support.
+const Compiled = false
+
 // IsAvailable returns false on systems without Vulkan.
 func IsAvailable() bool {
 	return false
@@ -40,6 +48,16 @@ func DeviceCount() int {
 	return 0
 }
 
+// UnavailableReason explains why Vulkan cannot be used by this binary.
+func UnavailableReason() string {
+	return "binary built without Vulkan support; build with -tags vulkan"
+}
+
+// QueryDevices returns nil on systems without Vulkan.
+func QueryDevices() []DeviceProperties {
+	return nil
+}
+
 // NewDevice returns an error on systems without Vulkan.
 func NewDevice(deviceID int) (*Device, error) {
 	return nil, ErrVulkanNotAvailable
@@ -54,6 +72,9 @@ func (d *Device) ID() int { return 0 }
 // Name returns empty string.
 func (d *Device) Name() string { return "" }
 
+// LoadedKernels returns nil.
+func (d *Device) LoadedKernels() []string { return nil }
+
 // MemoryBytes returns 0.
 func (d *Device) MemoryBytes() uint64 { return 0 }
 
@@ -65,6 +86,16 @@ func (d *Device) NewBuffer(data []float32) (*Buffer, error) {
 	return nil, ErrVulkanNotAvailable
 }
 
+// NewBufferWithPrecision returns an error.
+func (d *Device) NewBufferWithPrecision(data []float32, precision Precision) (*Buffer, error) {
+	return nil, ErrVulkanNotAvailable
+}
+
+// NewQuantizedBuffer returns an error.
+func (d *Device) NewQuantizedBuffer(data []float32, dimensions uint32) (*Buffer, error) {
+	return nil, ErrVulkanNotAvailable
+}
+
 // NewEmptyBuffer returns an error.
 func (d *Device) NewEmptyBuffer(count uint64) (*Buffer, error) {
 	return nil, ErrVulkanNotAvailable
@@ -76,9 +107,22 @@ func (b *Buffer) Release() {}
 // Size returns 0.
 func (b *Buffer) Size() uint64 { return 0 }
 
+// Precision returns PrecisionFP32.
+func (b *Buffer) Precision() Precision { return PrecisionFP32 }
+
 // ReadFloat32 returns nil.
 func (b *Buffer) ReadFloat32(count int) []float32 { return nil }
 
+// ReadAt returns an error.
+func (b *Buffer) ReadAt(offset, count int) ([]float32, error) {
+	return nil, ErrVulkanNotAvailable
+}
+
+// WriteAt returns an error.
+func (b *Buffer) WriteAt(offset int, data []float32) error {
+	return ErrVulkanNotAvailable
+}
+
 // NormalizeVectors returns an error.
 func (d *Device) NormalizeVectors(vectors *Buffer, n, dimensions uint32) error {
 	return ErrVulkanNotAvailable
@@ -95,6 +139,11 @@ func (d *Device) TopK(scores *Buffer, n, k uint32) ([]uint32, []float32, error)
 }
 
 // Search returns an error.
-func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, normalized bool) ([]SearchResult, error) {
+func (d *Device) Search(embeddings *Buffer, query []float32, n, dimensions uint32, k int, opts SearchOptions) ([]SearchResult, error) {
+	return nil, ErrVulkanNotAvailable
+}
+
+// SearchBatch returns an error.
+func (d *Device) SearchBatch(embeddings *Buffer, queries [][]float32, n, dimensions uint32, k int, opts SearchOptions) ([][]SearchResult, error) {
 	return nil, ErrVulkanNotAvailable
 }
@@ -0,0 +1,40 @@
+package heimdall
+
+import (
+	"log"
+	"sort"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall/grammar"
+)
+
+// ActionSchema is the action command the SLM may answer with in chat:
+// {"action": <name>, "params": {...}}, where name is a registered action
+// the persona may use. With no registered actions, any name is admitted.
+func ActionSchema(persona *Persona) *grammar.Schema {
+	var names []string
+	for category, actions := range ActionCatalog() {
+		if !persona.allowsCategory(category) {
+			continue
+		}
+		for _, action := range actions {
+			names = append(names, action.Name)
+		}
+	}
+	sort.Strings(names)
+
+	return &grammar.Schema{Type: grammar.TypeObject, Properties: []grammar.Property{
+		{Name: "action", Schema: &grammar.Schema{Type: grammar.TypeString, Enum: names}},
+		{Name: "params", Schema: &grammar.Schema{Type: grammar.TypeObject}},
+	}}
+}
+
+// actionGrammar returns the grammar for a chat answer: an action command
+// from ActionSchema, or prose. It returns "" if the grammar cannot be built.
+func actionGrammar(persona *Persona) string {
+	g, err := grammar.BuildOrText(ActionSchema(persona))
+	if err != nil {
+		log.Printf("[Heimdall] Action grammar unavailable: %v", err)
+		return ""
+	}
+	return g
+}
@@ -0,0 +1,41 @@
+package heimdall
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionSchema(t *testing.T) {
+	for _, a := range []ActionFunc{
+		{Name: "heimdall.test.grammar_status", Category: "monitoring"},
+		{Name: "heimdall.test.grammar_drop", Category: "admin"},
+	} {
+		RegisterBuiltinAction(a)
+	}
+	defer func() {
+		m := GetSubsystemManager()
+		m.mu.Lock()
+		delete(m.actions, "heimdall.test.grammar_status")
+		delete(m.actions, "heimdall.test.grammar_drop")
+		m.mu.Unlock()
+	}()
+
+	schema := ActionSchema(nil)
+	require.Len(t, schema.Properties, 2)
+	names := schema.Properties[0].Schema.Enum
+	assert.Contains(t, names, "heimdall.test.grammar_status")
+	assert.Contains(t, names, "heimdall.test.grammar_drop")
+	assert.IsIncreasing(t, names)
+
+	analyst := &Persona{Name: "analyst", Categories: []string{"monitoring"}}
+	names = ActionSchema(analyst).Properties[0].Schema.Enum
+	assert.Contains(t, names, "heimdall.test.grammar_status")
+	assert.NotContains(t, names, "heimdall.test.grammar_drop")
+
+	g := actionGrammar(analyst)
+	assert.Contains(t, g, `"\"heimdall.test.grammar_status\""`)
+	assert.NotContains(t, g, "grammar_drop")
+	assert.Contains(t, g, "text ::=", "chat may still answer in prose")
+}
@@ -2,13 +2,43 @@
 package heimdall
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 )
 
+// TopicPresence is the topic on which Bifrost publishes user online/offline
+// events. Clients subscribe to it like any other topic.
+const TopicPresence = "presence"
+
+// TopicApprovals is the topic for users who approve other users' actions.
+// Subscribers receive every confirmation request.
+const TopicApprovals = "approvals"
+
+type userContextKey struct{}
+
+// WithUser returns a copy of ctx carrying the authenticated user ID.
+// The server sets it so Bifrost can address messages to that user.
+func WithUser(ctx context.Context, userID string) context.Context {
+	if userID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, userContextKey{}, userID)
+}
+
+// UserFromContext returns the user ID set by WithUser, or "" if none.
+func UserFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(userContextKey{}).(string)
+	return id
+}
+
 // Bifrost implements BifrostBridge for real-time communication with clients.
 // Named after the rainbow bridge that connects Asgard to other realms.
 // Bifrost is the communication layer between Heimdall and connected UI clients.
@@ -16,15 +46,34 @@ type Bifrost struct {
 	mu      sync.RWMutex
 	clients map[string]*BifrostClient
 	config  Config
+	confirm confirmations
 }
 
 // BifrostClient represents a connected client.
 type BifrostClient struct {
 	ID          string
+	UserID      string // Authenticated user ("" for anonymous connections)
+	SessionID   string // Client session (defaults to the client ID)
+	Topics      map[string]bool
 	Flusher     http.Flusher
 	Writer      http.ResponseWriter
 	ConnectedAt time.Time
 	LastPing    time.Time
+
+	writeMu      sync.Mutex // Serializes writes and guards the fields below
+	closed       bool
+	pending      []BifrostMessage // Notifications waiting for the batch window
+	pendingBytes int
+	flushTimer   *time.Timer
+}
+
+// UserPresence describes a user with at least one open Bifrost connection.
+type UserPresence struct {
+	UserID      string    `json:"user_id"`
+	Connections int       `json:"connections"`
+	Sessions    []string  `json:"sessions"`
+	ConnectedAt time.Time `json:"connected_at"` // Oldest open connection
+	LastSeen    time.Time `json:"last_seen"`
 }
 
 // BifrostMessage is a message sent through Bifrost.
@@ -35,6 +84,18 @@ type BifrostMessage struct {
 	Title     string                 `json:"title,omitempty"`
 	Level     string                 `json:"level,omitempty"` // "info", "warning", "error", "success"
 	Data      map[string]interface{} `json:"data,omitempty"`
+
+	// Messages holds the batched messages of a "batch" event, oldest first.
+	// Its Data carries "count", the number of messages.
+	Messages []BifrostMessage `json:"messages,omitempty"`
+}
+
+// bifrostBatchTypes are the message types that may be delayed and batched.
+// Other messages are sent at once, after any pending batch.
+var bifrostBatchTypes = map[string]bool{
+	"notification": true,
+	"presence":     true,
+	"live_query":   true,
 }
 
 // NewBifrost creates a new Bifrost bridge.
@@ -46,27 +107,151 @@ func NewBifrost(cfg Config) *Bifrost {
 	return &Bifrost{
 		clients: make(map[string]*BifrostClient),
 		config:  cfg,
+		confirm: confirmations{pending: make(map[string]*pendingConfirmation)},
 	}
 }
 
-// RegisterClient adds a new connected client.
+// RegisterClient adds a new anonymous connected client.
+// Anonymous clients receive broadcasts only.
 func (b *Bifrost) RegisterClient(id string, w http.ResponseWriter, f http.Flusher) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	b.clients[id] = &BifrostClient{
+	b.RegisterSession(id, "", "", nil, w, f)
+}
+
+// RegisterSession adds a connected client owned by userID. sessionID groups
+// connections of one client session (e.g. several browser tabs) and defaults
+// to id. The client receives messages published on topics.
+// The first connection of a user publishes an "online" presence event.
+func (b *Bifrost) RegisterSession(id, userID, sessionID string, topics []string, w http.ResponseWriter, f http.Flusher) {
+	if sessionID == "" {
+		sessionID = id
+	}
+	client := &BifrostClient{
 		ID:          id,
+		UserID:      userID,
+		SessionID:   sessionID,
+		Topics:      make(map[string]bool, len(topics)),
 		Writer:      w,
 		Flusher:     f,
 		ConnectedAt: time.Now(),
 		LastPing:    time.Now(),
 	}
+	for _, t := range topics {
+		if t != "" {
+			client.Topics[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	b.clients[id] = client
+	online := userID != "" && b.userConnectionsLocked(userID) == 1
+	b.mu.Unlock()
+
+	if online {
+		b.publishPresence(userID, "online")
+	}
 }
 
 // UnregisterClient removes a disconnected client.
+// Closing the last connection of a user publishes an "offline" presence event.
 func (b *Bifrost) UnregisterClient(id string) {
 	b.mu.Lock()
-	defer b.mu.Unlock()
+	client, ok := b.clients[id]
 	delete(b.clients, id)
+	offline := ok && client.UserID != "" && b.userConnectionsLocked(client.UserID) == 0
+	b.mu.Unlock()
+
+	// The connection's writer is invalid once its handler returns
+	if ok {
+		client.writeMu.Lock()
+		client.closed = true
+		client.pending = nil
+		if client.flushTimer != nil {
+			client.flushTimer.Stop()
+			client.flushTimer = nil
+		}
+		client.writeMu.Unlock()
+	}
+
+	if offline {
+		b.publishPresence(client.UserID, "offline")
+	}
+}
+
+// Subscribe adds topics to a connected client. Returns false if the client
+// is not connected.
+func (b *Bifrost) Subscribe(clientID string, topics ...string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	client, ok := b.clients[clientID]
+	if !ok {
+		return false
+	}
+	for _, t := range topics {
+		if t != "" {
+			client.Topics[t] = true
+		}
+	}
+	return true
+}
+
+// Unsubscribe removes topics from a connected client.
+func (b *Bifrost) Unsubscribe(clientID string, topics ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if client, ok := b.clients[clientID]; ok {
+		for _, t := range topics {
+			delete(client.Topics, t)
+		}
+	}
+}
+
+// Touch records activity for a client (used for presence last-seen times).
+func (b *Bifrost) Touch(clientID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if client, ok := b.clients[clientID]; ok {
+		client.LastPing = time.Now()
+	}
+}
+
+// Presence returns the users that currently have open connections,
+// sorted by user ID.
+func (b *Bifrost) Presence() []UserPresence {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	byUser := make(map[string]*UserPresence)
+	sessions := make(map[string]map[string]bool)
+	for _, c := range b.clients {
+		if c.UserID == "" {
+			continue
+		}
+		p, ok := byUser[c.UserID]
+		if !ok {
+			p = &UserPresence{UserID: c.UserID, ConnectedAt: c.ConnectedAt, LastSeen: c.LastPing}
+			byUser[c.UserID] = p
+			sessions[c.UserID] = make(map[string]bool)
+		}
+		p.Connections++
+		if c.ConnectedAt.Before(p.ConnectedAt) {
+			p.ConnectedAt = c.ConnectedAt
+		}
+		if c.LastPing.After(p.LastSeen) {
+			p.LastSeen = c.LastPing
+		}
+		if !sessions[c.UserID][c.SessionID] {
+			sessions[c.UserID][c.SessionID] = true
+			p.Sessions = append(p.Sessions, c.SessionID)
+		}
+	}
+
+	result := make([]UserPresence, 0, len(byUser))
+	for _, p := range byUser {
+		sort.Strings(p.Sessions)
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UserID < result[j].UserID })
+	return result
 }
 
 // === BifrostBridge Interface Implementation ===
@@ -105,26 +290,90 @@ func (b *Bifrost) Broadcast(msg string) error {
 
 // RequestConfirmation asks the user to confirm an action.
 // Returns true if user confirms, false if they decline or timeout.
-// Note: This is a simplified implementation - real implementation would
-// need WebSocket for bidirectional communication.
+// It blocks until a decision is made; see Confirm for structured requests.
 func (b *Bifrost) RequestConfirmation(action string) (bool, error) {
-	// For SSE (unidirectional), we can't wait for response
-	// Send notification and return false (require explicit confirmation via API)
-	err := b.broadcast(BifrostMessage{
-		Type:      "confirmation_request",
+	d, err := b.Confirm(context.Background(), ConfirmationRequest{Action: action, Summary: action, Risk: RiskMedium})
+	return d.Approved, err
+}
+
+// SendTo sends a message to every connection of one user.
+// Sending to a user who is offline is not an error.
+func (b *Bifrost) SendTo(userID, msg string) error {
+	if userID == "" {
+		return nil
+	}
+	return b.send(BifrostMessage{
+		Type:      "message",
 		Timestamp: time.Now().Unix(),
-		Content:   action,
-		Data: map[string]interface{}{
-			"action":  action,
-			"timeout": 30, // seconds
-		},
-	})
-	if err != nil {
-		return false, err
+		Content:   msg,
+	}, func(c *BifrostClient) bool { return c.UserID == userID })
+}
+
+// SendToSession sends a message to the connections of one client session.
+func (b *Bifrost) SendToSession(sessionID, msg string) error {
+	if sessionID == "" {
+		return nil
 	}
-	// SSE is unidirectional - confirmation must come via separate API call
-	// Return false to indicate confirmation pending
-	return false, nil
+	return b.send(BifrostMessage{
+		Type:      "message",
+		Timestamp: time.Now().Unix(),
+		Content:   msg,
+	}, func(c *BifrostClient) bool { return c.SessionID == sessionID })
+}
+
+// SendData sends a structured message of the given type to one connection.
+// Sending to a client that is gone is not an error.
+func (b *Bifrost) SendData(clientID, msgType string, data map[string]interface{}) error {
+	return b.send(BifrostMessage{
+		Type:      msgType,
+		Timestamp: time.Now().Unix(),
+		Data:      data,
+	}, func(c *BifrostClient) bool { return c.ID == clientID })
+}
+
+// ClientUser returns the user owning a connection ("" for anonymous
+// connections), or false if the client is not connected.
+func (b *Bifrost) ClientUser(clientID string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	client, ok := b.clients[clientID]
+	if !ok {
+		return "", false
+	}
+	return client.UserID, true
+}
+
+// NotifyUser sends a notification to every connection of one user.
+func (b *Bifrost) NotifyUser(userID, notifType, title, message string) error {
+	if userID == "" {
+		return nil
+	}
+	return b.send(BifrostMessage{
+		Type:      "notification",
+		Timestamp: time.Now().Unix(),
+		Level:     notifType,
+		Title:     title,
+		Content:   message,
+	}, func(c *BifrostClient) bool { return c.UserID == userID })
+}
+
+// Publish sends a notification to clients subscribed to topic.
+func (b *Bifrost) Publish(topic, notifType, title, message string) error {
+	return b.send(BifrostMessage{
+		Type:      "notification",
+		Timestamp: time.Now().Unix(),
+		Level:     notifType,
+		Title:     title,
+		Content:   message,
+		Data:      map[string]interface{}{"topic": topic},
+	}, func(c *BifrostClient) bool { return c.Topics[topic] })
+}
+
+// IsUserOnline returns true if the user has at least one open connection.
+func (b *Bifrost) IsUserOnline(userID string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return userID != "" && b.userConnectionsLocked(userID) > 0
 }
 
 // IsConnected returns true if there are active Bifrost connections.
@@ -145,6 +394,12 @@ func (b *Bifrost) ConnectionCount() int {
 
 // broadcast sends a message to all connected clients via SSE.
 func (b *Bifrost) broadcast(msg BifrostMessage) error {
+	return b.send(msg, nil)
+}
+
+// send writes a message via SSE to the clients accepted by match
+// (all clients if match is nil).
+func (b *Bifrost) send(msg BifrostMessage, match func(*BifrostClient) bool) error {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
@@ -158,30 +413,137 @@ func (b *Bifrost) broadcast(msg BifrostMessage) error {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	// SSE format: "data: <json>\n\n"
-	sseData := fmt.Sprintf("data: %s\n\n", string(data))
-
 	var lastErr error
 	for _, client := range b.clients {
-		if _, err := client.Writer.Write([]byte(sseData)); err != nil {
-			lastErr = err
+		if match != nil && !match(client) {
 			continue
 		}
-		client.Flusher.Flush()
+		if err := b.deliver(client, msg, data); err != nil {
+			lastErr = err
+		}
 	}
 
 	return lastErr
 }
 
+// deliver sends one encoded message to a client, or queues it for the
+// client's next batch when batching is on and the type allows it.
+func (b *Bifrost) deliver(c *BifrostClient, msg BifrostMessage, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return nil
+	}
+
+	window := b.config.BifrostBatchWindow
+	if window <= 0 || !bifrostBatchTypes[msg.Type] {
+		// Keep delivery order: anything pending goes first
+		if err := c.flushLocked(); err != nil {
+			return err
+		}
+		return c.writeLocked(data)
+	}
+
+	c.pending = append(c.pending, msg)
+	c.pendingBytes += len(data)
+	if (b.config.BifrostBatchMaxMessages > 0 && len(c.pending) >= b.config.BifrostBatchMaxMessages) ||
+		(b.config.BifrostBatchMaxBytes > 0 && c.pendingBytes >= b.config.BifrostBatchMaxBytes) {
+		return c.flushLocked()
+	}
+	if c.flushTimer == nil {
+		c.flushTimer = time.AfterFunc(window, func() {
+			c.writeMu.Lock()
+			defer c.writeMu.Unlock()
+			c.flushLocked()
+		})
+	}
+	return nil
+}
+
+// flushLocked sends the pending messages: a single message as itself,
+// several as one "batch" event. c.writeMu must be held.
+func (c *BifrostClient) flushLocked() error {
+	if c.flushTimer != nil {
+		c.flushTimer.Stop()
+		c.flushTimer = nil
+	}
+	pending := c.pending
+	c.pending, c.pendingBytes = nil, 0
+	if len(pending) == 0 || c.closed {
+		return nil
+	}
+
+	msg := pending[0]
+	if len(pending) > 1 {
+		msg = BifrostMessage{
+			Type:      "batch",
+			Timestamp: time.Now().Unix(),
+			Data:      map[string]interface{}{"count": len(pending)},
+			Messages:  pending,
+		}
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return c.writeLocked(data)
+}
+
+// writeLocked writes one SSE event and flushes it. c.writeMu must be held.
+func (c *BifrostClient) writeLocked(data []byte) error {
+	// SSE format: "data: <json>\n\n"
+	if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	c.Flusher.Flush()
+	return nil
+}
+
+// publishPresence notifies TopicPresence subscribers of a user status change.
+func (b *Bifrost) publishPresence(userID, status string) {
+	b.send(BifrostMessage{
+		Type:      "presence",
+		Timestamp: time.Now().Unix(),
+		Data: map[string]interface{}{
+			"topic":   TopicPresence,
+			"user_id": userID,
+			"status":  status,
+		},
+	}, func(c *BifrostClient) bool { return c.Topics[TopicPresence] })
+}
+
+// userConnectionsLocked counts open connections of a user. b.mu must be held.
+func (b *Bifrost) userConnectionsLocked(userID string) int {
+	n := 0
+	for _, c := range b.clients {
+		if c.UserID == userID {
+			n++
+		}
+	}
+	return n
+}
+
 // Stats returns current Bifrost statistics.
 func (b *Bifrost) Stats() map[string]interface{} {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
 	clientInfo := make([]map[string]interface{}, 0, len(b.clients))
+	users := make(map[string]bool)
 	for _, c := range b.clients {
+		if c.UserID != "" {
+			users[c.UserID] = true
+		}
+		topics := make([]string, 0, len(c.Topics))
+		for t := range c.Topics {
+			topics = append(topics, t)
+		}
+		sort.Strings(topics)
 		clientInfo = append(clientInfo, map[string]interface{}{
 			"id":           c.ID,
+			"user_id":      c.UserID,
+			"session_id":   c.SessionID,
+			"topics":       topics,
 			"connected_at": c.ConnectedAt.Unix(),
 			"last_ping":    c.LastPing.Unix(),
 		})
@@ -189,7 +551,10 @@ func (b *Bifrost) Stats() map[string]interface{} {
 
 	return map[string]interface{}{
 		"enabled":          b.config.BifrostEnabled,
+		"compression":      b.config.BifrostCompression,
+		"batch_window_ms":  b.config.BifrostBatchWindow.Milliseconds(),
 		"connection_count": len(b.clients),
+		"users_online":     len(users),
 		"clients":          clientInfo,
 	}
 }
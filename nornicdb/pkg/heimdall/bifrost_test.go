@@ -1,8 +1,11 @@
 package heimdall
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -183,8 +186,9 @@ func TestBifrost_Broadcast(t *testing.T) {
 
 func TestBifrost_RequestConfirmation(t *testing.T) {
 	cfg := Config{
-		Enabled:        true,
-		BifrostEnabled: true,
+		Enabled:             true,
+		BifrostEnabled:      true,
+		ConfirmationTimeout: 20 * time.Millisecond,
 	}
 	bifrost := NewBifrost(cfg)
 
@@ -192,14 +196,24 @@ func TestBifrost_RequestConfirmation(t *testing.T) {
 	bifrost.RegisterClient("test", w, w)
 	defer bifrost.UnregisterClient("test")
 
-	// SSE is unidirectional, so confirmation request returns false immediately
+	// Unanswered confirmations are rejected when they time out
 	confirmed, err := bifrost.RequestConfirmation("Delete all nodes?")
 	assert.NoError(t, err)
-	assert.False(t, confirmed, "SSE-based confirmation should return false (pending)")
+	assert.False(t, confirmed, "unanswered confirmation should be rejected on timeout")
 
 	body := w.Body.String()
 	assert.Contains(t, body, `"type":"confirmation_request"`)
 	assert.Contains(t, body, "Delete all nodes?")
+
+	// Answered via RespondConfirmation (POST /api/bifrost/confirmations)
+	bifrost.config.ConfirmationTimeout = time.Second
+	go func() {
+		require.Eventually(t, func() bool { return len(bifrost.PendingConfirmations()) == 1 }, time.Second, time.Millisecond)
+		bifrost.RespondConfirmation(bifrost.PendingConfirmations()[0].ID, "alice", true)
+	}()
+	confirmed, err = bifrost.RequestConfirmation("Delete all nodes?")
+	assert.NoError(t, err)
+	assert.True(t, confirmed)
 }
 
 func TestBifrost_Stats(t *testing.T) {
@@ -345,3 +359,185 @@ func TestBifrostMessage_Timestamp(t *testing.T) {
 	assert.Contains(t, body, `"timestamp":`)
 	assert.Contains(t, body, `"type":"message"`)
 }
+
+func TestBifrost_TargetedMessaging(t *testing.T) {
+	bifrost := NewBifrost(Config{Enabled: true, BifrostEnabled: true})
+	aliceTab1 := NewMockFlushWriter()
+	aliceTab2 := NewMockFlushWriter()
+	bob := NewMockFlushWriter()
+	anon := NewMockFlushWriter()
+	bifrost.RegisterSession("c1", "alice", "s1", nil, aliceTab1, aliceTab1)
+	bifrost.RegisterSession("c2", "alice", "s2", []string{"watcher"}, aliceTab2, aliceTab2)
+	bifrost.RegisterSession("c3", "bob", "", []string{"anomalies"}, bob, bob)
+	bifrost.RegisterClient("c4", anon, anon)
+
+	t.Run("SendTo reaches only the user", func(t *testing.T) {
+		require.NoError(t, bifrost.SendTo("alice", "for alice"))
+		assert.Contains(t, aliceTab1.Body.String(), "for alice")
+		assert.Contains(t, aliceTab2.Body.String(), "for alice")
+		assert.NotContains(t, bob.Body.String(), "for alice")
+		assert.NotContains(t, anon.Body.String(), "for alice")
+
+		assert.NoError(t, bifrost.SendTo("nobody", "lost"))
+		assert.NoError(t, bifrost.SendTo("", "lost"))
+		assert.NotContains(t, anon.Body.String(), "lost")
+	})
+
+	t.Run("SendToSession", func(t *testing.T) {
+		require.NoError(t, bifrost.SendToSession("s2", "tab two"))
+		assert.NotContains(t, aliceTab1.Body.String(), "tab two")
+		assert.Contains(t, aliceTab2.Body.String(), "tab two")
+		// Session defaults to the client ID
+		require.NoError(t, bifrost.SendToSession("c3", "bob session"))
+		assert.Contains(t, bob.Body.String(), "bob session")
+	})
+
+	t.Run("NotifyUser", func(t *testing.T) {
+		require.NoError(t, bifrost.NotifyUser("bob", "warning", "Quota", "almost full"))
+		assert.Contains(t, bob.Body.String(), `"level":"warning"`)
+		assert.NotContains(t, aliceTab1.Body.String(), "almost full")
+	})
+
+	t.Run("Publish reaches topic subscribers", func(t *testing.T) {
+		require.NoError(t, bifrost.Publish("watcher", "info", "High Activity", "1000 nodes/min"))
+		assert.Contains(t, aliceTab2.Body.String(), "1000 nodes/min")
+		assert.Contains(t, aliceTab2.Body.String(), `"topic":"watcher"`)
+		assert.NotContains(t, aliceTab1.Body.String(), "1000 nodes/min")
+		assert.NotContains(t, bob.Body.String(), "1000 nodes/min")
+
+		assert.True(t, bifrost.Subscribe("c1", "watcher"))
+		assert.False(t, bifrost.Subscribe("missing", "watcher"))
+		bifrost.Unsubscribe("c2", "watcher")
+		require.NoError(t, bifrost.Publish("watcher", "info", "Spike", "second spike"))
+		assert.Contains(t, aliceTab1.Body.String(), "second spike")
+		assert.NotContains(t, aliceTab2.Body.String(), "second spike")
+	})
+
+	t.Run("SendData reaches one connection", func(t *testing.T) {
+		require.NoError(t, bifrost.SendData("c2", "live_query", map[string]interface{}{"id": "live-1"}))
+		assert.Contains(t, aliceTab2.Body.String(), `"type":"live_query"`)
+		assert.Contains(t, aliceTab2.Body.String(), `"id":"live-1"`)
+		assert.NotContains(t, aliceTab1.Body.String(), "live-1")
+
+		user, ok := bifrost.ClientUser("c3")
+		assert.True(t, ok)
+		assert.Equal(t, "bob", user)
+		user, ok = bifrost.ClientUser("c4")
+		assert.True(t, ok, "anonymous clients are connected")
+		assert.Empty(t, user)
+		_, ok = bifrost.ClientUser("missing")
+		assert.False(t, ok)
+	})
+
+	t.Run("broadcast still reaches everyone", func(t *testing.T) {
+		require.NoError(t, bifrost.Broadcast("maintenance"))
+		for _, w := range []*MockFlushWriter{aliceTab1, aliceTab2, bob, anon} {
+			assert.Contains(t, w.Body.String(), "maintenance")
+		}
+	})
+}
+
+func TestBifrost_Presence(t *testing.T) {
+	bifrost := NewBifrost(Config{Enabled: true, BifrostEnabled: true})
+	watcher := NewMockFlushWriter()
+	bifrost.RegisterSession("admin", "admin", "", []string{TopicPresence}, watcher, watcher)
+
+	w1, w2 := NewMockFlushWriter(), NewMockFlushWriter()
+	bifrost.RegisterSession("c1", "alice", "s1", nil, w1, w1)
+	bifrost.RegisterSession("c2", "alice", "s2", nil, w2, w2)
+	bifrost.RegisterClient("anon", w2, w2)
+
+	assert.True(t, bifrost.IsUserOnline("alice"))
+	assert.False(t, bifrost.IsUserOnline("bob"))
+	assert.False(t, bifrost.IsUserOnline(""))
+
+	presence := bifrost.Presence()
+	require.Len(t, presence, 2)
+	assert.Equal(t, "admin", presence[0].UserID)
+	assert.Equal(t, "alice", presence[1].UserID)
+	assert.Equal(t, 2, presence[1].Connections)
+	assert.Equal(t, []string{"s1", "s2"}, presence[1].Sessions)
+	assert.Equal(t, 2, bifrost.Stats()["users_online"])
+
+	// Only the first connection announces "online"
+	assert.Equal(t, 1, strings.Count(watcher.Body.String(), `"status":"online","topic":"presence","user_id":"alice"`))
+
+	bifrost.UnregisterClient("c1")
+	assert.True(t, bifrost.IsUserOnline("alice"))
+	assert.NotContains(t, watcher.Body.String(), `"status":"offline"`)
+
+	bifrost.UnregisterClient("c2")
+	assert.False(t, bifrost.IsUserOnline("alice"))
+	assert.Contains(t, watcher.Body.String(), `"status":"offline","topic":"presence","user_id":"alice"`)
+}
+
+func TestUserContext(t *testing.T) {
+	ctx := WithUser(context.Background(), "alice")
+	assert.Equal(t, "alice", UserFromContext(ctx))
+	assert.Equal(t, "", UserFromContext(context.Background()))
+	assert.Equal(t, context.Background(), WithUser(context.Background(), ""))
+}
+
+func TestBifrost_NotificationBatching(t *testing.T) {
+	t.Run("notifications within the window share one event", func(t *testing.T) {
+		bifrost := NewBifrost(Config{BifrostEnabled: true, BifrostBatchWindow: 20 * time.Millisecond})
+		w := NewMockFlushWriter()
+		bifrost.RegisterClient("c1", w, w)
+		defer bifrost.UnregisterClient("c1")
+
+		require.NoError(t, bifrost.SendNotification("info", "one", "first"))
+		require.NoError(t, bifrost.SendNotification("info", "two", "second"))
+		bifrost.clients["c1"].writeMu.Lock()
+		assert.Empty(t, w.Body.String(), "notifications wait for the window")
+		bifrost.clients["c1"].writeMu.Unlock()
+
+		require.Eventually(t, func() bool {
+			bifrost.clients["c1"].writeMu.Lock()
+			defer bifrost.clients["c1"].writeMu.Unlock()
+			return w.Body.Len() > 0
+		}, time.Second, 5*time.Millisecond)
+
+		bifrost.clients["c1"].writeMu.Lock()
+		events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+		bifrost.clients["c1"].writeMu.Unlock()
+		require.Len(t, events, 1)
+		var msg BifrostMessage
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(events[0], "data: ")), &msg))
+		assert.Equal(t, "batch", msg.Type)
+		assert.EqualValues(t, 2, msg.Data["count"])
+		require.Len(t, msg.Messages, 2)
+		assert.Equal(t, "first", msg.Messages[0].Content)
+		assert.Equal(t, "second", msg.Messages[1].Content)
+	})
+
+	t.Run("full batch and other messages flush at once, in order", func(t *testing.T) {
+		bifrost := NewBifrost(Config{BifrostEnabled: true, BifrostBatchWindow: time.Hour, BifrostBatchMaxMessages: 2})
+		w := NewMockFlushWriter()
+		bifrost.RegisterClient("c1", w, w)
+		defer bifrost.UnregisterClient("c1")
+
+		require.NoError(t, bifrost.SendNotification("info", "", "n1"))
+		require.NoError(t, bifrost.SendNotification("info", "", "n2"))
+		require.NoError(t, bifrost.SendNotification("info", "", "n3"))
+		require.NoError(t, bifrost.SendMessage("chat"))
+
+		events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+		require.Len(t, events, 3)
+		assert.Contains(t, events[0], `"type":"batch"`)
+		assert.Contains(t, events[0], "n2")
+		assert.Contains(t, events[1], `"type":"notification"`)
+		assert.Contains(t, events[1], "n3")
+		assert.Contains(t, events[2], `"type":"message"`)
+	})
+
+	t.Run("pending notifications are dropped on disconnect", func(t *testing.T) {
+		bifrost := NewBifrost(Config{BifrostEnabled: true, BifrostBatchWindow: 10 * time.Millisecond})
+		w := NewMockFlushWriter()
+		bifrost.RegisterClient("c1", w, w)
+		require.NoError(t, bifrost.SendNotification("info", "", "late"))
+		bifrost.UnregisterClient("c1")
+
+		time.Sleep(30 * time.Millisecond)
+		assert.Empty(t, w.Body.String())
+	})
+}
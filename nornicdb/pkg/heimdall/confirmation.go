@@ -0,0 +1,280 @@
+package heimdall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RiskLevel classifies how dangerous an action is.
+// Actions at RiskMedium or above require confirmation before they execute;
+// RiskHigh actions require Config.HighRiskApprovers distinct approvers
+// (the requester may be one of them).
+type RiskLevel string
+
+const (
+	RiskLow    RiskLevel = "low"
+	RiskMedium RiskLevel = "medium"
+	RiskHigh   RiskLevel = "high"
+)
+
+// RequiresConfirmation reports whether actions at this level need approval.
+func (r RiskLevel) RequiresConfirmation() bool {
+	return r == RiskMedium || r == RiskHigh
+}
+
+// Confirmation errors.
+var (
+	ErrConfirmationNotFound = errors.New("confirmation not found or already decided")
+	ErrAlreadyApproved      = errors.New("user already approved this confirmation")
+)
+
+// ParamChange is one entry of a params diff shown in a confirmation.
+type ParamChange struct {
+	Param string      `json:"param"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// ConfirmationRequest is the payload sent to clients when an action needs
+// approval. Field names are short and flat so it renders on small screens
+// without extra processing.
+type ConfirmationRequest struct {
+	ID          string                 `json:"id"`
+	Action      string                 `json:"action"`
+	Summary     string                 `json:"summary"` // One line suitable for a push notification
+	Params      map[string]interface{} `json:"params,omitempty"`
+	Diff        []ParamChange          `json:"diff,omitempty"` // Params changed by PreExecute hooks
+	Risk        RiskLevel              `json:"risk"`
+	RequestedBy string                 `json:"requested_by,omitempty"`
+	Approvals   int                    `json:"approvals_required"`
+	OnTimeout   string                 `json:"on_timeout"` // "approve" or "deny"
+	CreatedAt   int64                  `json:"created_at"` // Unix timestamp
+	ExpiresAt   int64                  `json:"expires_at"` // Unix timestamp
+}
+
+// ConfirmationDecision is the outcome of a confirmation.
+type ConfirmationDecision struct {
+	ID         string    `json:"id"`
+	Approved   bool      `json:"approved"`
+	Approvers  []string  `json:"approvers,omitempty"`
+	RejectedBy string    `json:"rejected_by,omitempty"`
+	TimedOut   bool      `json:"timed_out,omitempty"`
+	DecidedAt  time.Time `json:"decided_at"`
+}
+
+type pendingConfirmation struct {
+	req       ConfirmationRequest
+	approvers []string
+	done      chan ConfirmationDecision
+}
+
+// confirmations tracks pending confirmations for a Bifrost bridge.
+type confirmations struct {
+	mu         sync.Mutex
+	pending    map[string]*pendingConfirmation
+	onDecision func(ConfirmationRequest, ConfirmationDecision)
+}
+
+// DiffParams returns the params that differ between before and after,
+// sorted by name.
+func DiffParams(before, after map[string]interface{}) []ParamChange {
+	var diff []ParamChange
+	for k, old := range before {
+		nv, ok := after[k]
+		if !ok {
+			diff = append(diff, ParamChange{Param: k, Old: old})
+		} else if !reflect.DeepEqual(old, nv) {
+			diff = append(diff, ParamChange{Param: k, Old: old, New: nv})
+		}
+	}
+	for k, nv := range after {
+		if _, ok := before[k]; !ok {
+			diff = append(diff, ParamChange{Param: k, New: nv})
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Param < diff[j].Param })
+	return diff
+}
+
+// OnConfirmationDecision sets a callback invoked for every decided
+// confirmation (approved, rejected or timed out). Use it to persist an
+// audit trail of who approved what.
+func (b *Bifrost) OnConfirmationDecision(fn func(ConfirmationRequest, ConfirmationDecision)) {
+	b.confirm.mu.Lock()
+	defer b.confirm.mu.Unlock()
+	b.confirm.onDecision = fn
+}
+
+// Confirm sends req to clients and blocks until enough users approve, any
+// user rejects, the confirmation times out or ctx is done.
+//
+// The request goes to the requesting user's connections (or to everyone if
+// the requester is unknown) and to clients subscribed to the "approvals"
+// topic. On timeout the configured default decision applies.
+func (b *Bifrost) Confirm(ctx context.Context, req ConfirmationRequest) (ConfirmationDecision, error) {
+	if req.Risk == "" {
+		req.Risk = RiskMedium
+	}
+	if req.ID == "" {
+		req.ID = generateID()
+	}
+	if req.Summary == "" {
+		req.Summary = fmt.Sprintf("Run %s?", req.Action)
+	}
+	timeout := b.config.ConfirmationTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	req.Approvals = 1
+	if req.Risk == RiskHigh && b.config.HighRiskApprovers > 1 {
+		req.Approvals = b.config.HighRiskApprovers
+	}
+	req.OnTimeout = "deny"
+	if b.config.ConfirmationApproveOnTimeout {
+		req.OnTimeout = "approve"
+	}
+	now := time.Now()
+	req.CreatedAt = now.Unix()
+	req.ExpiresAt = now.Add(timeout).Unix()
+
+	p := &pendingConfirmation{req: req, done: make(chan ConfirmationDecision, 1)}
+	b.confirm.mu.Lock()
+	b.confirm.pending[req.ID] = p
+	b.confirm.mu.Unlock()
+
+	msg := BifrostMessage{
+		Type:      "confirmation_request",
+		Timestamp: now.Unix(),
+		Title:     "Confirmation required",
+		Content:   req.Summary,
+		Level:     "warning",
+		Data:      map[string]interface{}{"confirmation": req},
+	}
+	err := b.send(msg, func(c *BifrostClient) bool {
+		return req.RequestedBy == "" || c.UserID == req.RequestedBy || c.Topics[TopicApprovals]
+	})
+	if err != nil {
+		log.Printf("[Bifrost] Failed to deliver confirmation %s: %v", req.ID, err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case d := <-p.done:
+		return d, nil
+	case <-timer.C:
+		d, ok := b.decide(req.ID, ConfirmationDecision{Approved: b.config.ConfirmationApproveOnTimeout, TimedOut: true})
+		if !ok {
+			// Decided concurrently with the timeout
+			return <-p.done, nil
+		}
+		return d, nil
+	case <-ctx.Done():
+		if d, ok := b.decide(req.ID, ConfirmationDecision{Approved: false}); ok {
+			return d, ctx.Err()
+		}
+		return <-p.done, nil
+	}
+}
+
+// RespondConfirmation records a user's answer to a pending confirmation.
+// A rejection decides immediately; approvals decide once the required number
+// of distinct users approved. It returns the decision once made, or nil while
+// more approvals are needed.
+func (b *Bifrost) RespondConfirmation(id, userID string, approve bool) (*ConfirmationDecision, error) {
+	b.confirm.mu.Lock()
+	p, ok := b.confirm.pending[id]
+	if !ok {
+		b.confirm.mu.Unlock()
+		return nil, ErrConfirmationNotFound
+	}
+	if approve {
+		for _, a := range p.approvers {
+			if a == userID {
+				b.confirm.mu.Unlock()
+				return nil, ErrAlreadyApproved
+			}
+		}
+		p.approvers = append(p.approvers, userID)
+		if len(p.approvers) < p.req.Approvals {
+			b.confirm.mu.Unlock()
+			return nil, nil
+		}
+	}
+	b.confirm.mu.Unlock()
+
+	d := ConfirmationDecision{Approved: approve}
+	if !approve {
+		d.RejectedBy = userID
+	}
+	decided, ok := b.decide(id, d)
+	if !ok {
+		return nil, ErrConfirmationNotFound
+	}
+	return &decided, nil
+}
+
+// PendingConfirmations returns confirmations awaiting a decision, oldest first.
+func (b *Bifrost) PendingConfirmations() []ConfirmationRequest {
+	b.confirm.mu.Lock()
+	defer b.confirm.mu.Unlock()
+	result := make([]ConfirmationRequest, 0, len(b.confirm.pending))
+	for _, p := range b.confirm.pending {
+		result = append(result, p.req)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt < result[j].CreatedAt })
+	return result
+}
+
+// decide removes a pending confirmation and delivers d to its waiter.
+// It returns false if the confirmation was already decided.
+func (b *Bifrost) decide(id string, d ConfirmationDecision) (ConfirmationDecision, bool) {
+	b.confirm.mu.Lock()
+	p, ok := b.confirm.pending[id]
+	if !ok {
+		b.confirm.mu.Unlock()
+		return d, false
+	}
+	delete(b.confirm.pending, id)
+	d.ID = id
+	d.DecidedAt = time.Now()
+	if d.Approved {
+		d.Approvers = append([]string(nil), p.approvers...)
+	}
+	onDecision := b.confirm.onDecision
+	b.confirm.mu.Unlock()
+
+	p.done <- d
+	if onDecision != nil {
+		onDecision(p.req, d)
+	}
+
+	status := "rejected"
+	if d.Approved {
+		status = "approved"
+	}
+	if d.TimedOut {
+		status += " (timeout)"
+	}
+	who := d.Approvers
+	if d.RejectedBy != "" {
+		who = []string{d.RejectedBy}
+	}
+	log.Printf("[Bifrost] Confirmation %s for %s %s by [%s]", id, p.req.Action, status, strings.Join(who, ", "))
+	b.send(BifrostMessage{
+		Type:      "confirmation_result",
+		Timestamp: d.DecidedAt.Unix(),
+		Content:   fmt.Sprintf("%s %s", p.req.Action, status),
+		Data:      map[string]interface{}{"decision": d},
+	}, func(c *BifrostClient) bool {
+		return p.req.RequestedBy == "" || c.UserID == p.req.RequestedBy || c.Topics[TopicApprovals]
+	})
+	return d, true
+}
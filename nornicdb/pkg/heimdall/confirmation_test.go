@@ -0,0 +1,258 @@
+package heimdall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newConfirmBifrost(timeout time.Duration) *Bifrost {
+	cfg := DefaultConfig()
+	cfg.BifrostEnabled = true
+	cfg.ConfirmationTimeout = timeout
+	return NewBifrost(cfg)
+}
+
+// waitPending waits until a confirmation is pending and returns it.
+func waitPending(t *testing.T, b *Bifrost) ConfirmationRequest {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(b.PendingConfirmations()) > 0 }, time.Second, time.Millisecond)
+	return b.PendingConfirmations()[0]
+}
+
+func TestDiffParams(t *testing.T) {
+	diff := DiffParams(
+		map[string]interface{}{"limit": 10, "cypher": "MATCH (n) RETURN n", "dry_run": true},
+		map[string]interface{}{"limit": 100, "cypher": "MATCH (n) RETURN n", "label": "Person"},
+	)
+	assert.Equal(t, []ParamChange{
+		{Param: "dry_run", Old: true},
+		{Param: "label", New: "Person"},
+		{Param: "limit", Old: 10, New: 100},
+	}, diff)
+	assert.Empty(t, DiffParams(map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1}))
+}
+
+func TestBifrost_ConfirmApprove(t *testing.T) {
+	b := newConfirmBifrost(time.Second)
+	alice := NewMockFlushWriter()
+	bob := NewMockFlushWriter()
+	b.RegisterSession("c1", "alice", "", nil, alice, alice)
+	b.RegisterSession("c2", "bob", "", nil, bob, bob)
+
+	var decided []ConfirmationDecision
+	var mu sync.Mutex
+	b.OnConfirmationDecision(func(req ConfirmationRequest, d ConfirmationDecision) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "heimdall.test.drop", req.Action)
+		decided = append(decided, d)
+	})
+
+	done := make(chan ConfirmationDecision)
+	go func() {
+		d, err := b.Confirm(context.Background(), ConfirmationRequest{
+			Action:      "heimdall.test.drop",
+			Params:      map[string]interface{}{"label": "Temp"},
+			Diff:        []ParamChange{{Param: "label", Old: "Tmp", New: "Temp"}},
+			RequestedBy: "alice",
+		})
+		assert.NoError(t, err)
+		done <- d
+	}()
+
+	req := waitPending(t, b)
+	assert.Equal(t, RiskMedium, req.Risk)
+	assert.Equal(t, 1, req.Approvals)
+	assert.Equal(t, "deny", req.OnTimeout)
+	assert.Equal(t, req.CreatedAt+1, req.ExpiresAt)
+
+	// Only the requester sees the request
+	assert.Contains(t, alice.Body.String(), `"type":"confirmation_request"`)
+	assert.Contains(t, alice.Body.String(), `"diff":[{"param":"label","old":"Tmp","new":"Temp"}]`)
+	assert.NotContains(t, bob.Body.String(), "confirmation_request")
+
+	d, err := b.RespondConfirmation(req.ID, "alice", true)
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	assert.True(t, d.Approved)
+
+	got := <-done
+	assert.True(t, got.Approved)
+	assert.Equal(t, []string{"alice"}, got.Approvers)
+	assert.Empty(t, b.PendingConfirmations())
+	assert.Contains(t, alice.Body.String(), `"type":"confirmation_result"`)
+
+	mu.Lock()
+	assert.Len(t, decided, 1)
+	mu.Unlock()
+
+	_, err = b.RespondConfirmation(req.ID, "alice", true)
+	assert.ErrorIs(t, err, ErrConfirmationNotFound)
+}
+
+func TestBifrost_ConfirmReject(t *testing.T) {
+	b := newConfirmBifrost(time.Second)
+	done := make(chan ConfirmationDecision)
+	go func() {
+		d, _ := b.Confirm(context.Background(), ConfirmationRequest{Action: "heimdall.test.drop"})
+		done <- d
+	}()
+	req := waitPending(t, b)
+	d, err := b.RespondConfirmation(req.ID, "bob", false)
+	require.NoError(t, err)
+	assert.False(t, d.Approved)
+	got := <-done
+	assert.False(t, got.Approved)
+	assert.Equal(t, "bob", got.RejectedBy)
+}
+
+func TestBifrost_ConfirmTimeout(t *testing.T) {
+	b := newConfirmBifrost(20 * time.Millisecond)
+	d, err := b.Confirm(context.Background(), ConfirmationRequest{Action: "heimdall.test.drop"})
+	require.NoError(t, err)
+	assert.False(t, d.Approved)
+	assert.True(t, d.TimedOut)
+
+	b.config.ConfirmationApproveOnTimeout = true
+	d, err = b.Confirm(context.Background(), ConfirmationRequest{Action: "heimdall.test.drop"})
+	require.NoError(t, err)
+	assert.True(t, d.Approved)
+	assert.True(t, d.TimedOut)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d, err = b.Confirm(ctx, ConfirmationRequest{Action: "heimdall.test.drop"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, d.Approved)
+	assert.Empty(t, b.PendingConfirmations())
+}
+
+func TestBifrost_ConfirmHighRiskMultiApprover(t *testing.T) {
+	b := newConfirmBifrost(time.Second)
+	approver := NewMockFlushWriter()
+	b.RegisterSession("ops", "carol", "", []string{TopicApprovals}, approver, approver)
+
+	done := make(chan ConfirmationDecision)
+	go func() {
+		d, _ := b.Confirm(context.Background(), ConfirmationRequest{
+			Action: "heimdall.test.wipe", Risk: RiskHigh, RequestedBy: "alice",
+		})
+		done <- d
+	}()
+	req := waitPending(t, b)
+	assert.Equal(t, 2, req.Approvals)
+	assert.Contains(t, approver.Body.String(), req.ID, "approvers topic receives high-risk requests")
+
+	d, err := b.RespondConfirmation(req.ID, "alice", true)
+	require.NoError(t, err)
+	assert.Nil(t, d, "one approval is not enough")
+
+	_, err = b.RespondConfirmation(req.ID, "alice", true)
+	assert.ErrorIs(t, err, ErrAlreadyApproved)
+
+	d, err = b.RespondConfirmation(req.ID, "carol", true)
+	require.NoError(t, err)
+	require.NotNil(t, d)
+
+	got := <-done
+	assert.True(t, got.Approved)
+	assert.Equal(t, []string{"alice", "carol"}, got.Approvers)
+}
+
+func TestHandler_Confirmations(t *testing.T) {
+	mockGen := NewMockGenerator("/test/model.gguf")
+	manager := newTestManager(mockGen)
+	cfg := manager.config
+	cfg.BifrostEnabled = true
+	cfg.ConfirmationTimeout = time.Second
+	handler := testHandler(manager, cfg)
+	b := handler.bifrost
+
+	done := make(chan ConfirmationDecision)
+	go func() {
+		d, _ := b.Confirm(context.Background(), ConfirmationRequest{Action: "heimdall.test.drop"})
+		done <- d
+	}()
+	req := waitPending(t, b)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/bifrost/confirmations", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), req.ID)
+
+	body, _ := json.Marshal(map[string]interface{}{"id": req.ID, "approve": true})
+	r := httptest.NewRequest(http.MethodPost, "/api/bifrost/confirmations", bytes.NewReader(body))
+	r = r.WithContext(WithUser(r.Context(), "alice"))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"decided"`)
+	assert.Equal(t, []string{"alice"}, (<-done).Approvers)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/bifrost/confirmations", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/bifrost/confirmations", strings.NewReader("{")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_RiskyActionNeedsConfirmation(t *testing.T) {
+	executed := 0
+	RegisterBuiltinAction(ActionFunc{
+		Name:        "heimdall.test.risky",
+		Description: "Risky test action",
+		Category:    "test",
+		Risk:        RiskMedium,
+		Handler: func(ctx ActionContext) (*ActionResult, error) {
+			executed++
+			return &ActionResult{Success: true, Message: "done"}, nil
+		},
+	})
+	defer func() {
+		m := GetSubsystemManager()
+		m.mu.Lock()
+		delete(m.actions, "heimdall.test.risky")
+		m.mu.Unlock()
+	}()
+
+	mockGen := NewMockGenerator("/test/model.gguf")
+	mockGen.generateFunc = func(ctx context.Context, prompt string, params GenerateParams) (string, error) {
+		return `{"action": "heimdall.test.risky", "params": {}}`, nil
+	}
+	manager := newTestManager(mockGen)
+	cfg := manager.config
+	cfg.BifrostEnabled = true
+	cfg.ConfirmationTimeout = time.Second
+	handler := testHandler(manager, cfg)
+
+	run := func(approve bool) string {
+		go func() {
+			req := waitPending(t, handler.bifrost)
+			handler.bifrost.RespondConfirmation(req.ID, "alice", approve)
+		}()
+		w := httptest.NewRecorder()
+		handler.handleNonStreamingResponse(w, context.Background(), "", GenerateParams{}, "test", &requestLifecycle{
+			promptCtx: &PromptContext{UserID: "alice", PluginData: map[string]interface{}{}},
+			requestID: "req-c",
+		})
+		return w.Body.String()
+	}
+
+	assert.Contains(t, run(false), "Action rejected by alice")
+	assert.Equal(t, 0, executed)
+
+	assert.Contains(t, run(true), "done")
+	assert.Equal(t, 1, executed)
+}
@@ -0,0 +1,674 @@
+// Package heimdall - database event delivery.
+//
+// Every plugin implementing DatabaseEventHook gets its own bounded queue and
+// a single delivery goroutine, so a slow plugin only delays its own events
+// and each plugin sees events in the order they were emitted. When a queue
+// is full the OverflowPolicy decides which events are lost. Events of the
+// configured critical types are never lost to overflow when a spool
+// directory is set: they are appended to a per-plugin file and delivered
+// once the queue drains, including after a restart.
+//
+// Plugins implementing DatabaseEventSubscriber are only queued the events
+// their EventSubscription matches.
+package heimdall
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/redact"
+)
+
+// OverflowPolicy decides what happens to events emitted while a plugin's
+// queue is full.
+type OverflowPolicy string
+
+const (
+	// OverflowDropOldest discards the oldest queued event to make room.
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+
+	// OverflowBlock makes the emitter wait up to BlockTimeout for room and
+	// drops the new event if none frees up. Emitters are database
+	// operations, so keep the timeout short.
+	OverflowBlock OverflowPolicy = "block"
+
+	// OverflowSample keeps one in SampleRate overflowing events, making room
+	// by discarding the oldest queued event, and drops the rest.
+	OverflowSample OverflowPolicy = "sample"
+)
+
+// EventDispatcherConfig configures database event delivery to plugins.
+type EventDispatcherConfig struct {
+	// QueueSize is the number of events buffered per plugin.
+	QueueSize int `json:"queue_size"`
+
+	// Overflow is applied when a plugin's queue is full.
+	Overflow OverflowPolicy `json:"overflow"`
+
+	// BlockTimeout bounds the wait of OverflowBlock.
+	BlockTimeout time.Duration `json:"block_timeout"`
+
+	// SampleRate is N for OverflowSample: one in N overflowing events is kept.
+	SampleRate int `json:"sample_rate"`
+
+	// SpoolDir enables persistent buffering of CriticalEvents ("" disables).
+	SpoolDir string `json:"spool_dir,omitempty"`
+
+	// CriticalEvents are spooled to SpoolDir instead of being dropped.
+	CriticalEvents []DatabaseEventType `json:"critical_events,omitempty"`
+}
+
+// DefaultEventDispatcherConfig returns the default delivery settings:
+// 1000 events per plugin, dropping the oldest on overflow, no spooling.
+func DefaultEventDispatcherConfig() EventDispatcherConfig {
+	return EventDispatcherConfig{
+		QueueSize:    1000,
+		Overflow:     OverflowDropOldest,
+		BlockTimeout: 100 * time.Millisecond,
+		SampleRate:   10,
+	}
+}
+
+// withDefaults fills zero fields from DefaultEventDispatcherConfig.
+func (c EventDispatcherConfig) withDefaults() EventDispatcherConfig {
+	def := DefaultEventDispatcherConfig()
+	if c.QueueSize <= 0 {
+		c.QueueSize = def.QueueSize
+	}
+	if c.Overflow == "" {
+		c.Overflow = def.Overflow
+	}
+	if c.BlockTimeout <= 0 {
+		c.BlockTimeout = def.BlockTimeout
+	}
+	if c.SampleRate <= 0 {
+		c.SampleRate = def.SampleRate
+	}
+	return c
+}
+
+// EventDeliveryStats reports database event delivery for one plugin.
+type EventDeliveryStats struct {
+	Plugin    string `json:"plugin"`
+	Queued    int    `json:"queued"`    // Events waiting in the queue
+	Capacity  int    `json:"capacity"`  // Queue size
+	Delivered int64  `json:"delivered"` // OnDatabaseEvent calls that returned
+	Filtered  int64  `json:"filtered"`  // Events skipped by the plugin's subscription
+	Dropped   int64  `json:"dropped"`   // Events lost to overflow or shutdown
+	Spooled   int64  `json:"spooled"`   // Critical events written to the spool
+	Replayed  int64  `json:"replayed"`  // Spooled events delivered
+	Panics    int64  `json:"panics"`    // OnDatabaseEvent calls that panicked
+}
+
+// dbEventDispatcher fans database events out to per-plugin subscribers.
+type dbEventDispatcher struct {
+	mu          sync.RWMutex
+	running     bool
+	config      EventDispatcherConfig
+	critical    map[DatabaseEventType]bool
+	subscribers map[string]*eventSubscriber // keyed by plugin name
+	redactor    *redact.Redactor
+
+	// emitMu is held shared while a single event is offered and exclusively
+	// while a committed transaction's events are, so batches reach every
+	// queue contiguously.
+	emitMu sync.RWMutex
+}
+
+var globalEventDispatcher = &dbEventDispatcher{
+	subscribers: make(map[string]*eventSubscriber),
+}
+
+// StartEventDispatcher starts delivering database events to plugins with
+// the default configuration. This should be called when Heimdall is
+// initialized.
+func StartEventDispatcher() {
+	_ = StartEventDispatcherWithConfig(DefaultEventDispatcherConfig())
+}
+
+// StartEventDispatcherWithConfig starts delivering database events to
+// plugins. Events spooled by a previous run are delivered as soon as their
+// plugin is registered. It is a no-op if the dispatcher is running.
+func StartEventDispatcherWithConfig(cfg EventDispatcherConfig) error {
+	cfg = cfg.withDefaults()
+	if cfg.SpoolDir != "" {
+		if err := os.MkdirAll(cfg.SpoolDir, 0o700); err != nil {
+			return fmt.Errorf("creating event spool directory: %w", err)
+		}
+	}
+
+	d := globalEventDispatcher
+	d.mu.Lock()
+	if d.running {
+		d.mu.Unlock()
+		return nil
+	}
+	d.running = true
+	d.config = cfg
+	d.critical = make(map[DatabaseEventType]bool, len(cfg.CriticalEvents))
+	for _, t := range cfg.CriticalEvents {
+		d.critical[t] = true
+	}
+	d.subscribers = make(map[string]*eventSubscriber)
+	d.mu.Unlock()
+
+	// Subscribing replays what registered plugins have spooled
+	d.subscribersFor(ListHeimdallPlugins())
+	return nil
+}
+
+// StopEventDispatcher stops event delivery. Queued critical events are
+// spooled when a spool directory is configured; other queued events are
+// dropped.
+func StopEventDispatcher() {
+	d := globalEventDispatcher
+	d.mu.Lock()
+	if !d.running {
+		d.mu.Unlock()
+		return
+	}
+	d.running = false
+	subscribers := d.subscribers
+	d.subscribers = make(map[string]*eventSubscriber)
+	d.mu.Unlock()
+
+	for _, s := range subscribers {
+		s.stop()
+	}
+}
+
+// EventDeliveryStatsAll returns delivery statistics for every plugin
+// receiving database events, sorted by plugin name.
+func EventDeliveryStatsAll() []EventDeliveryStats {
+	d := globalEventDispatcher
+	d.mu.RLock()
+	stats := make([]EventDeliveryStats, 0, len(d.subscribers))
+	for _, s := range d.subscribers {
+		stats = append(stats, s.stats())
+	}
+	d.mu.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Plugin < stats[j].Plugin })
+	return stats
+}
+
+// RefreshEventSubscription asks the named plugin for its EventSubscription
+// again and applies it to events emitted from now on. Plugins whose interest
+// changes at runtime call it after the change. It is a no-op if the plugin
+// receives no events or is not a DatabaseEventSubscriber.
+func RefreshEventSubscription(plugin string) {
+	d := globalEventDispatcher
+	d.mu.RLock()
+	s, ok := d.subscribers[plugin]
+	d.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if sub, ok := s.hook.(DatabaseEventSubscriber); ok {
+		s.filter.Store(compileEventFilter(sub.EventSubscription()))
+	}
+}
+
+// EmitDatabaseEvent sends a database event to all registered plugins.
+// This is non-blocking unless the OverflowBlock policy is configured -
+// events are queued per plugin for async delivery.
+func EmitDatabaseEvent(event *DatabaseEvent) {
+	d := globalEventDispatcher
+	d.emitMu.RLock()
+	defer d.emitMu.RUnlock()
+	d.emit(event)
+}
+
+// emitBatch sends events without interleaving events emitted concurrently.
+func (d *dbEventDispatcher) emitBatch(events []*DatabaseEvent) {
+	d.emitMu.Lock()
+	defer d.emitMu.Unlock()
+	for _, event := range events {
+		d.emit(event)
+	}
+}
+
+// emit redacts and timestamps event and queues it for every plugin.
+func (d *dbEventDispatcher) emit(event *DatabaseEvent) {
+	d.mu.RLock()
+	running := d.running
+	redactor := d.redactor
+	d.mu.RUnlock()
+
+	if !running || !HeimdallPluginsInitialized() {
+		return
+	}
+
+	// Sensitive values never reach plugins unmasked
+	redactEvent(redactor, event)
+
+	// Set timestamp if not already set
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	for _, s := range d.subscribersFor(ListHeimdallPlugins()) {
+		s.offer(event)
+	}
+}
+
+// subscribersFor returns the subscribers of the plugins implementing
+// DatabaseEventHook, creating missing ones.
+func (d *dbEventDispatcher) subscribersFor(plugins []*LoadedHeimdallPlugin) []*eventSubscriber {
+	subs := make([]*eventSubscriber, 0, len(plugins))
+	var missing []*LoadedHeimdallPlugin
+
+	d.mu.RLock()
+	for _, p := range plugins {
+		if _, ok := p.Plugin.(DatabaseEventHook); !ok {
+			continue
+		}
+		if s, ok := d.subscribers[p.Plugin.Name()]; ok {
+			subs = append(subs, s)
+		} else {
+			missing = append(missing, p)
+		}
+	}
+	d.mu.RUnlock()
+
+	if len(missing) == 0 {
+		return subs
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.running {
+		return subs
+	}
+	for _, p := range missing {
+		name := p.Plugin.Name()
+		s, ok := d.subscribers[name]
+		if !ok {
+			s = newEventSubscriber(name, p.Plugin.(DatabaseEventHook), d.config, d.critical)
+			d.subscribers[name] = s
+		}
+		subs = append(subs, s)
+	}
+	return subs
+}
+
+// =============================================================================
+// Per-plugin subscriber
+// =============================================================================
+
+// eventSubscriber owns one plugin's queue and delivery goroutine.
+type eventSubscriber struct {
+	name     string
+	hook     DatabaseEventHook
+	filter   atomic.Pointer[eventFilter] // nil receives every event
+	config   EventDispatcherConfig
+	critical map[DatabaseEventType]bool // shared, read-only
+	queue    chan *DatabaseEvent
+	spool    *eventSpool // nil when spooling is disabled
+	done     chan struct{}
+	stopped  chan struct{}
+
+	overflows atomic.Int64 // overflowing events seen, for sampling
+	delivered atomic.Int64
+	filtered  atomic.Int64
+	dropped   atomic.Int64
+	spooled   atomic.Int64
+	replayed  atomic.Int64
+	panics    atomic.Int64
+}
+
+func newEventSubscriber(name string, hook DatabaseEventHook, cfg EventDispatcherConfig, critical map[DatabaseEventType]bool) *eventSubscriber {
+	s := &eventSubscriber{
+		name:     name,
+		hook:     hook,
+		config:   cfg,
+		critical: critical,
+		queue:    make(chan *DatabaseEvent, cfg.QueueSize),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if sub, ok := hook.(DatabaseEventSubscriber); ok {
+		s.filter.Store(compileEventFilter(sub.EventSubscription()))
+	}
+	if cfg.SpoolDir != "" && len(critical) > 0 {
+		s.spool = openEventSpool(filepath.Join(cfg.SpoolDir, name+".events.jsonl"))
+	}
+	go s.run()
+	return s
+}
+
+// offer queues event, applying the overflow policy when the queue is full.
+// Events outside the plugin's subscription are skipped.
+func (s *eventSubscriber) offer(event *DatabaseEvent) {
+	if !s.filter.Load().matches(event) {
+		s.filtered.Add(1)
+		return
+	}
+
+	select {
+	case s.queue <- event:
+		return
+	default:
+	}
+
+	if s.spool != nil && s.critical[event.Type] && s.toSpool(event) {
+		return
+	}
+
+	switch s.config.Overflow {
+	case OverflowBlock:
+		timer := time.NewTimer(s.config.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case s.queue <- event:
+		case <-timer.C:
+			s.dropped.Add(1)
+		case <-s.done:
+			s.dropped.Add(1)
+		}
+	case OverflowSample:
+		if s.overflows.Add(1)%int64(s.config.SampleRate) != 0 {
+			s.dropped.Add(1)
+			return
+		}
+		s.replaceOldest(event)
+	default:
+		s.replaceOldest(event)
+	}
+}
+
+// replaceOldest queues event, discarding queued events until it fits.
+func (s *eventSubscriber) replaceOldest(event *DatabaseEvent) {
+	for {
+		select {
+		case s.queue <- event:
+			return
+		default:
+		}
+		select {
+		case old := <-s.queue:
+			s.discard(old)
+		default:
+		}
+	}
+}
+
+// discard spools a critical event and drops any other.
+func (s *eventSubscriber) discard(event *DatabaseEvent) {
+	if s.spool != nil && s.critical[event.Type] && s.toSpool(event) {
+		return
+	}
+	s.dropped.Add(1)
+}
+
+// toSpool appends event to the spool and reports whether it was written.
+func (s *eventSubscriber) toSpool(event *DatabaseEvent) bool {
+	if err := s.spool.append(event); err != nil {
+		fmt.Printf("[Heimdall] Failed to spool %s event for %s: %v\n", event.Type, s.name, err)
+		return false
+	}
+	s.spooled.Add(1)
+	return true
+}
+
+// run delivers queued events until stop, replaying the spool whenever the
+// queue is empty. Spooled events therefore arrive after newer queued ones;
+// plugins that care about order should use Timestamp.
+func (s *eventSubscriber) run() {
+	defer close(s.stopped)
+	for {
+		// Stopping wins over queued events
+		select {
+		case <-s.done:
+			s.discardQueued()
+			return
+		default:
+		}
+
+		if s.spool != nil && len(s.queue) == 0 {
+			for _, event := range s.spool.drain() {
+				s.deliver(event)
+				s.replayed.Add(1)
+			}
+		}
+
+		select {
+		case event := <-s.queue:
+			s.deliver(event)
+		case <-s.done:
+			s.discardQueued()
+			return
+		}
+	}
+}
+
+// discardQueued empties the queue on stop.
+func (s *eventSubscriber) discardQueued() {
+	for {
+		select {
+		case event := <-s.queue:
+			s.discard(event)
+		default:
+			return
+		}
+	}
+}
+
+// deliver calls the plugin's hook, recovering any panic.
+func (s *eventSubscriber) deliver(event *DatabaseEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.panics.Add(1)
+			recoverPluginPanic("DatabaseEventHook of "+s.name, r)
+		}
+	}()
+	s.hook.OnDatabaseEvent(event)
+	s.delivered.Add(1)
+}
+
+// stop ends delivery and waits for the delivery goroutine to exit.
+func (s *eventSubscriber) stop() {
+	close(s.done)
+	<-s.stopped
+}
+
+func (s *eventSubscriber) stats() EventDeliveryStats {
+	return EventDeliveryStats{
+		Plugin:    s.name,
+		Queued:    len(s.queue),
+		Capacity:  cap(s.queue),
+		Delivered: s.delivered.Load(),
+		Filtered:  s.filtered.Load(),
+		Dropped:   s.dropped.Load(),
+		Spooled:   s.spooled.Load(),
+		Replayed:  s.replayed.Load(),
+		Panics:    s.panics.Load(),
+	}
+}
+
+// =============================================================================
+// Subscriptions
+// =============================================================================
+
+// eventFilter is a compiled EventSubscription.
+type eventFilter struct {
+	types      map[DatabaseEventType]bool
+	labels     map[string]bool
+	relTypes   map[string]bool
+	properties []string
+}
+
+// compileEventFilter returns nil for an empty subscription.
+func compileEventFilter(sub EventSubscription) *eventFilter {
+	if len(sub.Types) == 0 && len(sub.Labels) == 0 && len(sub.RelationshipTypes) == 0 && len(sub.Properties) == 0 {
+		return nil
+	}
+	f := &eventFilter{properties: sub.Properties}
+	if len(sub.Types) > 0 {
+		f.types = make(map[DatabaseEventType]bool, len(sub.Types))
+		for _, t := range sub.Types {
+			f.types[t] = true
+		}
+	}
+	if len(sub.Labels) > 0 {
+		f.labels = make(map[string]bool, len(sub.Labels))
+		for _, l := range sub.Labels {
+			f.labels[l] = true
+		}
+	}
+	if len(sub.RelationshipTypes) > 0 {
+		f.relTypes = make(map[string]bool, len(sub.RelationshipTypes))
+		for _, t := range sub.RelationshipTypes {
+			f.relTypes[t] = true
+		}
+	}
+	for _, pattern := range sub.Properties {
+		if _, err := path.Match(pattern, ""); err != nil {
+			fmt.Printf("[Heimdall] Invalid property pattern %q in event subscription: %v\n", pattern, err)
+		}
+	}
+	return f
+}
+
+// matches reports whether event is within the subscription. A nil filter
+// matches everything.
+func (f *eventFilter) matches(event *DatabaseEvent) bool {
+	if f == nil {
+		return true
+	}
+	if f.types != nil && !f.types[event.Type] {
+		return false
+	}
+	if f.labels != nil && !f.anyLabel(event.NodeLabels) {
+		return false
+	}
+	if f.relTypes != nil && !f.relTypes[event.RelationshipType] {
+		return false
+	}
+	if f.properties != nil && !f.anyProperty(event.Properties) && !f.anyProperty(event.OldProperties) {
+		return false
+	}
+	return true
+}
+
+func (f *eventFilter) anyLabel(labels []string) bool {
+	for _, l := range labels {
+		if f.labels[l] {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *eventFilter) anyProperty(props map[string]interface{}) bool {
+	for key := range props {
+		for _, pattern := range f.properties {
+			if ok, _ := path.Match(pattern, key); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Matches reports whether event is within the subscription, as the
+// dispatcher decides it.
+func (sub EventSubscription) Matches(event *DatabaseEvent) bool {
+	return compileEventFilter(sub).matches(event)
+}
+
+// =============================================================================
+// Spool
+// =============================================================================
+
+// eventSpool is an append-only JSON-lines file of events awaiting delivery.
+type eventSpool struct {
+	mu      sync.Mutex
+	path    string
+	pending int
+}
+
+// openEventSpool opens the spool at path, counting events left by a
+// previous run so they are replayed.
+func openEventSpool(path string) *eventSpool {
+	s := &eventSpool{path: path}
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 16*1024*1024)
+		for scanner.Scan() {
+			s.pending++
+		}
+		f.Close()
+	}
+	return s
+}
+
+// append writes event to the spool and syncs it to disk.
+func (s *eventSpool) append(event *DatabaseEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.pending++
+	return nil
+}
+
+// drain returns the spooled events and empties the spool. Lines that fail
+// to decode are skipped.
+func (s *eventSpool) drain() []*DatabaseEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		fmt.Printf("[Heimdall] Failed to read event spool %s: %v\n", s.path, err)
+		return nil
+	}
+	if err := os.Remove(s.path); err != nil {
+		fmt.Printf("[Heimdall] Failed to clear event spool %s: %v\n", s.path, err)
+		return nil
+	}
+	s.pending = 0
+
+	var events []*DatabaseEvent
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var event DatabaseEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		events = append(events, &event)
+	}
+	return events
+}
@@ -0,0 +1,350 @@
+package heimdall
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedHook records delivered events. While closed, each delivery blocks
+// until Release, after signalling on started.
+type gatedHook struct {
+	mu      sync.Mutex
+	events  []string
+	gate    chan struct{}
+	started chan struct{}
+}
+
+func newGatedHook(closed bool) *gatedHook {
+	h := &gatedHook{gate: make(chan struct{}), started: make(chan struct{}, 100)}
+	if !closed {
+		close(h.gate)
+	}
+	return h
+}
+
+func (h *gatedHook) OnDatabaseEvent(event *DatabaseEvent) {
+	select {
+	case h.started <- struct{}{}:
+	default:
+	}
+	<-h.gate
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event.Query)
+}
+
+func (h *gatedHook) Release() { close(h.gate) }
+
+func (h *gatedHook) Events() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.events...)
+}
+
+func queryEvent(q string) *DatabaseEvent {
+	return &DatabaseEvent{Type: EventQueryExecuted, Query: q}
+}
+
+// blockedSubscriber returns a subscriber whose worker is stuck delivering
+// event "first", so the next events stay queued.
+func blockedSubscriber(t *testing.T, cfg EventDispatcherConfig, critical ...DatabaseEventType) (*eventSubscriber, *gatedHook) {
+	t.Helper()
+	cfg.CriticalEvents = critical
+	cfg = cfg.withDefaults()
+	crit := map[DatabaseEventType]bool{}
+	for _, c := range critical {
+		crit[c] = true
+	}
+	hook := newGatedHook(true)
+	s := newEventSubscriber("test", hook, cfg, crit)
+	t.Cleanup(func() {
+		select {
+		case <-hook.gate:
+		default:
+			hook.Release()
+		}
+		s.stop()
+	})
+	s.offer(queryEvent("first"))
+	<-hook.started
+	return s, hook
+}
+
+// waitDelivered waits until n events were delivered or replayed.
+func waitDelivered(t *testing.T, s *eventSubscriber, n int64) {
+	t.Helper()
+	require.Eventually(t, func() bool { return s.delivered.Load() >= n }, time.Second, time.Millisecond)
+}
+
+func TestEventSubscriber_DeliversInOrder(t *testing.T) {
+	hook := newGatedHook(false)
+	s := newEventSubscriber("ordered", hook, DefaultEventDispatcherConfig(), nil)
+	defer s.stop()
+
+	var want []string
+	for i := 0; i < 200; i++ {
+		q := fmt.Sprintf("q%d", i)
+		want = append(want, q)
+		s.offer(queryEvent(q))
+	}
+	waitDelivered(t, s, 200)
+	assert.Equal(t, want, hook.Events())
+
+	stats := s.stats()
+	assert.Equal(t, "ordered", stats.Plugin)
+	assert.Equal(t, int64(200), stats.Delivered)
+	assert.Zero(t, stats.Dropped)
+	assert.Equal(t, 1000, stats.Capacity)
+}
+
+func TestEventSubscriber_OverflowDropOldest(t *testing.T) {
+	s, hook := blockedSubscriber(t, EventDispatcherConfig{QueueSize: 2, Overflow: OverflowDropOldest})
+	for _, q := range []string{"a", "b", "c", "d"} {
+		s.offer(queryEvent(q))
+	}
+	assert.Equal(t, int64(2), s.dropped.Load())
+	assert.Equal(t, 2, s.stats().Queued)
+
+	hook.Release()
+	waitDelivered(t, s, 3)
+	assert.Equal(t, []string{"first", "c", "d"}, hook.Events())
+}
+
+func TestEventSubscriber_OverflowBlock(t *testing.T) {
+	s, hook := blockedSubscriber(t, EventDispatcherConfig{QueueSize: 1, Overflow: OverflowBlock, BlockTimeout: 20 * time.Millisecond})
+	s.offer(queryEvent("a"))
+
+	start := time.Now()
+	s.offer(queryEvent("timed-out"))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Equal(t, int64(1), s.dropped.Load())
+
+	// Room freed while waiting lets the event in
+	s.config.BlockTimeout = time.Second
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		hook.Release()
+	}()
+	s.offer(queryEvent("b"))
+	waitDelivered(t, s, 3)
+	assert.Equal(t, []string{"first", "a", "b"}, hook.Events())
+	assert.Equal(t, int64(1), s.dropped.Load())
+}
+
+func TestEventSubscriber_OverflowSample(t *testing.T) {
+	s, hook := blockedSubscriber(t, EventDispatcherConfig{QueueSize: 1, Overflow: OverflowSample, SampleRate: 3})
+	for _, q := range []string{"a", "b", "c", "d"} {
+		s.offer(queryEvent(q))
+	}
+	// "a" queued; of b, c, d only every third overflow (d) is kept
+	assert.Equal(t, int64(3), s.dropped.Load())
+
+	hook.Release()
+	waitDelivered(t, s, 2)
+	assert.Equal(t, []string{"first", "d"}, hook.Events())
+}
+
+func TestEventSubscriber_SpoolsCriticalEvents(t *testing.T) {
+	cfg := EventDispatcherConfig{QueueSize: 1, SpoolDir: t.TempDir()}
+	s, hook := blockedSubscriber(t, cfg, EventTransactionRollback)
+
+	s.offer(&DatabaseEvent{Type: EventTransactionRollback, Query: "queued-critical"})
+	s.offer(&DatabaseEvent{Type: EventTransactionRollback, Query: "spooled"})
+	// Evicting a critical event spools it too
+	s.offer(queryEvent("a"))
+	assert.Equal(t, int64(2), s.spooled.Load())
+	assert.Zero(t, s.dropped.Load())
+
+	hook.Release()
+	waitDelivered(t, s, 4)
+	assert.Equal(t, []string{"first", "a", "spooled", "queued-critical"}, hook.Events())
+	assert.Equal(t, int64(2), s.replayed.Load())
+}
+
+func TestEventSubscriber_SpoolSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	cfg := EventDispatcherConfig{QueueSize: 4, SpoolDir: dir, CriticalEvents: []DatabaseEventType{EventBackupCompleted}}.withDefaults()
+	critical := map[DatabaseEventType]bool{EventBackupCompleted: true}
+
+	hook := newGatedHook(true)
+	s := newEventSubscriber("audit", hook, cfg, critical)
+	s.offer(queryEvent("first"))
+	<-hook.started
+	s.offer(&DatabaseEvent{Type: EventBackupCompleted, Query: "backup"})
+	s.offer(queryEvent("lost"))
+
+	// Stop before the hook returns so both events are still queued
+	close(s.done)
+	hook.Release()
+	<-s.stopped
+	assert.Equal(t, int64(1), s.spooled.Load())
+	assert.Equal(t, int64(1), s.dropped.Load())
+
+	restarted := newGatedHook(false)
+	s2 := newEventSubscriber("audit", restarted, cfg, critical)
+	defer s2.stop()
+	waitDelivered(t, s2, 1)
+	assert.Equal(t, []string{"backup"}, restarted.Events())
+	assert.Equal(t, int64(1), s2.replayed.Load())
+}
+
+func TestEventSubscriber_RecoversPanics(t *testing.T) {
+	s := newEventSubscriber("panicky", panicHook{}, DefaultEventDispatcherConfig(), nil)
+	defer s.stop()
+
+	s.offer(queryEvent("a"))
+	s.offer(queryEvent("b"))
+	require.Eventually(t, func() bool { return s.panics.Load() == 2 }, time.Second, time.Millisecond)
+	assert.Zero(t, s.delivered.Load())
+}
+
+type panicHook struct{}
+
+func (panicHook) OnDatabaseEvent(*DatabaseEvent) { panic("boom") }
+
+// eventPlugin is a mock plugin that also implements DatabaseEventHook.
+type eventPlugin struct {
+	*MockHeimdallPlugin
+	*gatedHook
+}
+
+func TestEmitDatabaseEvent_PerPluginQueues(t *testing.T) {
+	globalManager = nil
+	t.Cleanup(func() {
+		StopEventDispatcher()
+		globalManager = nil
+	})
+
+	slow := eventPlugin{NewMockPlugin("slow"), newGatedHook(true)}
+	fast := eventPlugin{NewMockPlugin("fast"), newGatedHook(false)}
+	m := GetSubsystemManager()
+	require.NoError(t, m.RegisterPlugin(slow, "", true))
+	require.NoError(t, m.RegisterPlugin(fast, "", true))
+	require.NoError(t, m.RegisterPlugin(NewMockPlugin("no_hook"), "", true))
+
+	require.NoError(t, StartEventDispatcherWithConfig(EventDispatcherConfig{QueueSize: 2}))
+	t.Cleanup(func() {
+		select {
+		case <-slow.gatedHook.gate:
+		default:
+			slow.Release()
+		}
+	})
+
+	// A blocked plugin does not hold up the others
+	for i := 0; i < 5; i++ {
+		EmitDatabaseEvent(queryEvent(fmt.Sprintf("q%d", i)))
+		require.Eventually(t, func() bool { return len(fast.gatedHook.Events()) == i+1 }, time.Second, time.Millisecond)
+		if i == 0 {
+			<-slow.gatedHook.started
+		}
+	}
+	assert.Empty(t, slow.gatedHook.Events())
+
+	stats := EventDeliveryStatsAll()
+	require.Len(t, stats, 2)
+	assert.Equal(t, "fast", stats[0].Plugin)
+	assert.Equal(t, int64(5), stats[0].Delivered)
+	assert.Equal(t, "slow", stats[1].Plugin)
+	assert.Equal(t, int64(2), stats[1].Dropped, "one in delivery, two queued")
+
+	slow.Release()
+	require.Eventually(t, func() bool { return len(slow.gatedHook.Events()) == 3 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"q0", "q3", "q4"}, slow.gatedHook.Events())
+}
+
+func TestEventSubscription_Matches(t *testing.T) {
+	personCreated := &DatabaseEvent{Type: EventNodeCreated, NodeLabels: []string{"Person", "Admin"}, Properties: map[string]interface{}{"email": "a@b.c"}}
+	docUpdated := &DatabaseEvent{Type: EventNodeUpdated, NodeLabels: []string{"Doc"}, OldProperties: map[string]interface{}{"addr_home": "x"}}
+	knows := &DatabaseEvent{Type: EventRelationshipCreated, RelationshipType: "KNOWS"}
+	failed := &DatabaseEvent{Type: EventQueryFailed, Query: "MATCH (n) RETURN n"}
+
+	tests := []struct {
+		name string
+		sub  EventSubscription
+		want []bool // personCreated, docUpdated, knows, failed
+	}{
+		{"empty matches all", EventSubscription{}, []bool{true, true, true, true}},
+		{"types", EventSubscription{Types: []DatabaseEventType{EventNodeCreated, EventQueryFailed}}, []bool{true, false, false, true}},
+		{"labels", EventSubscription{Labels: []string{"Admin", "Doc"}}, []bool{true, true, false, false}},
+		{"relationship types", EventSubscription{RelationshipTypes: []string{"KNOWS"}}, []bool{false, false, true, false}},
+		{"property patterns", EventSubscription{Properties: []string{"addr_*"}}, []bool{false, true, false, false}},
+		{"all fields must match", EventSubscription{Types: []DatabaseEventType{EventNodeCreated}, Labels: []string{"Doc"}}, []bool{false, false, false, false}},
+		{"invalid pattern never matches", EventSubscription{Properties: []string{"["}}, []bool{false, false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, event := range []*DatabaseEvent{personCreated, docUpdated, knows, failed} {
+				assert.Equal(t, tt.want[i], tt.sub.Matches(event), "event %d", i)
+			}
+		})
+	}
+}
+
+// subscribedHook only wants node deletions.
+type subscribedHook struct{ *gatedHook }
+
+func (subscribedHook) EventSubscription() EventSubscription {
+	return EventSubscription{Types: []DatabaseEventType{EventNodeDeleted}}
+}
+
+func TestEventSubscriber_Filters(t *testing.T) {
+	hook := subscribedHook{newGatedHook(false)}
+	s := newEventSubscriber("filtered", hook, DefaultEventDispatcherConfig(), nil)
+	defer s.stop()
+
+	s.offer(queryEvent("skipped"))
+	s.offer(&DatabaseEvent{Type: EventNodeDeleted, Query: "kept"})
+	waitDelivered(t, s, 1)
+
+	assert.Equal(t, []string{"kept"}, hook.Events())
+	assert.Equal(t, int64(1), s.stats().Filtered)
+}
+
+// switchableSubscriber changes its subscription at runtime.
+type switchableSubscriber struct {
+	*recordingPlugin
+	mu    sync.Mutex
+	types []DatabaseEventType
+}
+
+func (p *switchableSubscriber) EventSubscription() EventSubscription {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return EventSubscription{Types: p.types}
+}
+
+func TestRefreshEventSubscription(t *testing.T) {
+	globalManager = nil
+	t.Cleanup(func() {
+		StopEventDispatcher()
+		globalManager = nil
+	})
+	p := &switchableSubscriber{
+		recordingPlugin: &recordingPlugin{MockHeimdallPlugin: NewMockPlugin("switchable")},
+		types:           []DatabaseEventType{EventNodeDeleted},
+	}
+	require.NoError(t, GetSubsystemManager().RegisterPlugin(p, "", true))
+	require.NoError(t, StartEventDispatcherWithConfig(EventDispatcherConfig{QueueSize: 100}))
+
+	EmitDatabaseEvent(queryEvent("before"))
+	EmitDatabaseEvent(&DatabaseEvent{Type: EventNodeDeleted, NodeID: "n1"})
+	p.waitEvents(t, 1)
+
+	p.mu.Lock()
+	p.types = append(p.types, EventQueryExecuted)
+	p.mu.Unlock()
+	RefreshEventSubscription("switchable")
+	RefreshEventSubscription("unknown") // no-op
+
+	EmitDatabaseEvent(queryEvent("after"))
+	events := p.waitEvents(t, 2)
+	require.Len(t, events, 2)
+	assert.Equal(t, "n1", events[0].NodeID)
+	assert.Equal(t, "after", events[1].Query)
+}
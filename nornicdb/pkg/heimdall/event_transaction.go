@@ -0,0 +1,157 @@
+// Package heimdall - transaction-scoped database events.
+//
+// Events emitted inside an explicit transaction describe changes other
+// sessions cannot see yet, so plugins must not see them either. An
+// EventTransaction buffers them instead: Commit delivers them, in emission
+// order and without events from elsewhere in between, followed by an
+// EventTransactionCommit summary; Rollback discards them and emits an
+// EventTransactionRollback summary. Every event of the batch and both
+// summaries carry the transaction ID.
+//
+// Entry points attach the transaction to the request context:
+//
+//	tx := heimdall.BeginEventTransaction(txID, "bolt")
+//	ctx = heimdall.WithEventTransaction(ctx, tx)
+//	heimdall.EmitQueryEventContext(ctx, "bolt", query, params, d, rows, err) // buffered
+//	tx.Commit() // or tx.Rollback()
+package heimdall
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// EventTransaction buffers the database events of one explicit transaction
+// until it commits or rolls back. It is safe for concurrent use.
+type EventTransaction struct {
+	id      string
+	source  string
+	started time.Time
+
+	mu       sync.Mutex
+	events   []*DatabaseEvent
+	finished bool
+}
+
+// BeginEventTransaction starts buffering events for the transaction id.
+// source identifies the entry point ("bolt", "http", ...) and is set on the
+// summary event.
+func BeginEventTransaction(id, source string) *EventTransaction {
+	return &EventTransaction{id: id, source: source, started: time.Now()}
+}
+
+// ID returns the transaction ID.
+func (tx *EventTransaction) ID() string { return tx.id }
+
+// Started returns when the transaction began.
+func (tx *EventTransaction) Started() time.Time { return tx.started }
+
+// Len returns the number of buffered events.
+func (tx *EventTransaction) Len() int {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return len(tx.events)
+}
+
+// Emit buffers event until the transaction finishes. Events emitted after
+// Commit or Rollback are sent immediately, as outside a transaction.
+func (tx *EventTransaction) Emit(event *DatabaseEvent) {
+	event.TransactionID = tx.id
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	tx.mu.Lock()
+	if tx.finished {
+		tx.mu.Unlock()
+		EmitDatabaseEvent(event)
+		return
+	}
+	if eventDispatcherRunning() {
+		tx.events = append(tx.events, event)
+	}
+	tx.mu.Unlock()
+}
+
+// Commit delivers the buffered events in order, followed by an
+// EventTransactionCommit summary. It is a no-op once the transaction has
+// finished.
+func (tx *EventTransaction) Commit() {
+	events, ok := tx.finish()
+	if !ok {
+		return
+	}
+	summary := tx.summary(EventTransactionCommit, events)
+	summary.Metadata["events"] = len(events)
+	globalEventDispatcher.emitBatch(append(events, summary))
+}
+
+// Rollback discards the buffered events and emits an
+// EventTransactionRollback summary. It is a no-op once the transaction has
+// finished.
+func (tx *EventTransaction) Rollback() {
+	events, ok := tx.finish()
+	if !ok {
+		return
+	}
+	summary := tx.summary(EventTransactionRollback, events)
+	summary.Metadata["discarded"] = len(events)
+	globalEventDispatcher.emitBatch([]*DatabaseEvent{summary})
+}
+
+// finish marks the transaction finished and returns its buffered events.
+// ok is false if it had already finished.
+func (tx *EventTransaction) finish() (events []*DatabaseEvent, ok bool) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.finished {
+		return nil, false
+	}
+	tx.finished = true
+	events, tx.events = tx.events, nil
+	return events, true
+}
+
+// summary builds the commit or rollback event for events.
+func (tx *EventTransaction) summary(eventType DatabaseEventType, events []*DatabaseEvent) *DatabaseEvent {
+	types := make(map[string]int)
+	var rows int64
+	for _, e := range events {
+		types[string(e.Type)]++
+		rows += e.RowsAffected
+	}
+	return &DatabaseEvent{
+		Type:          eventType,
+		Timestamp:     time.Now(),
+		Duration:      time.Since(tx.started),
+		RowsAffected:  rows,
+		Source:        tx.source,
+		TransactionID: tx.id,
+		Metadata:      map[string]interface{}{"event_types": types},
+	}
+}
+
+// eventDispatcherRunning reports whether emitted events are delivered.
+func eventDispatcherRunning() bool {
+	d := globalEventDispatcher
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.running
+}
+
+type eventTransactionKey struct{}
+
+// WithEventTransaction returns a copy of ctx carrying tx. Events emitted
+// with EmitDatabaseEventContext (and EmitQueryEventContext) under the
+// returned context are buffered in tx.
+func WithEventTransaction(ctx context.Context, tx *EventTransaction) context.Context {
+	return context.WithValue(ctx, eventTransactionKey{}, tx)
+}
+
+// EventTransactionFromContext returns the transaction carried by ctx, or
+// nil.
+func EventTransactionFromContext(ctx context.Context) *EventTransaction {
+	tx, _ := ctx.Value(eventTransactionKey{}).(*EventTransaction)
+	return tx
+}
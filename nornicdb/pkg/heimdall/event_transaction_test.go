@@ -0,0 +1,170 @@
+package heimdall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPlugin is a mock plugin recording every delivered event.
+type recordingPlugin struct {
+	*MockHeimdallPlugin
+	mu     sync.Mutex
+	events []DatabaseEvent
+}
+
+func (p *recordingPlugin) OnDatabaseEvent(event *DatabaseEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, *event)
+}
+
+func (p *recordingPlugin) Events() []DatabaseEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]DatabaseEvent(nil), p.events...)
+}
+
+func (p *recordingPlugin) waitEvents(t *testing.T, n int) []DatabaseEvent {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(p.Events()) >= n }, time.Second, time.Millisecond)
+	return p.Events()
+}
+
+// startRecording registers a recording plugin and starts the dispatcher.
+func startRecording(t *testing.T) *recordingPlugin {
+	t.Helper()
+	globalManager = nil
+	t.Cleanup(func() {
+		StopEventDispatcher()
+		globalManager = nil
+	})
+
+	p := &recordingPlugin{MockHeimdallPlugin: NewMockPlugin("recorder")}
+	require.NoError(t, GetSubsystemManager().RegisterPlugin(p, "", true))
+	require.NoError(t, StartEventDispatcherWithConfig(EventDispatcherConfig{QueueSize: 10000}))
+	return p
+}
+
+func TestEventTransaction_Commit(t *testing.T) {
+	p := startRecording(t)
+
+	tx := BeginEventTransaction("tx-1", "bolt")
+	ctx := WithEventTransaction(context.Background(), tx)
+	EmitQueryEventContext(ctx, "bolt", "CREATE (n)", nil, time.Millisecond, 1, nil)
+	EmitQueryEventContext(ctx, "bolt", "CREATE (m)", nil, time.Millisecond, 2, nil)
+	EmitQueryEventContext(ctx, "bolt", "BAD", nil, time.Millisecond, 0, errors.New("syntax"))
+	assert.Equal(t, 3, tx.Len())
+
+	// Nothing is observable before commit
+	EmitDatabaseEvent(queryEvent("outside"))
+	events := p.waitEvents(t, 1)
+	require.Len(t, events, 1)
+	assert.Empty(t, events[0].TransactionID)
+
+	tx.Commit()
+	events = p.waitEvents(t, 5)
+	require.Len(t, events, 5)
+	for i, q := range []string{"CREATE (n)", "CREATE (m)", "BAD"} {
+		assert.Equal(t, q, events[i+1].Query)
+		assert.Equal(t, "tx-1", events[i+1].TransactionID)
+	}
+
+	summary := events[4]
+	assert.Equal(t, EventTransactionCommit, summary.Type)
+	assert.Equal(t, "tx-1", summary.TransactionID)
+	assert.Equal(t, "bolt", summary.Source)
+	assert.Equal(t, int64(3), summary.RowsAffected)
+	assert.Equal(t, 3, summary.Metadata["events"])
+	assert.Equal(t, map[string]int{"query.executed": 2, "query.failed": 1}, summary.Metadata["event_types"])
+
+	// Finished transactions no longer buffer
+	tx.Commit()
+	tx.Rollback()
+	EmitQueryEventContext(ctx, "bolt", "late", nil, 0, 0, nil)
+	events = p.waitEvents(t, 6)
+	require.Len(t, events, 6)
+	assert.Equal(t, "late", events[5].Query)
+}
+
+func TestEventTransaction_Rollback(t *testing.T) {
+	p := startRecording(t)
+
+	tx := BeginEventTransaction("tx-2", "http")
+	ctx := WithEventTransaction(context.Background(), tx)
+	EmitDatabaseEventContext(ctx, &DatabaseEvent{Type: EventNodeCreated, NodeID: "n1"})
+	EmitDatabaseEventContext(ctx, &DatabaseEvent{Type: EventNodeCreated, NodeID: "n2"})
+	tx.Rollback()
+
+	events := p.waitEvents(t, 1)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventTransactionRollback, events[0].Type)
+	assert.Equal(t, "tx-2", events[0].TransactionID)
+	assert.Equal(t, 2, events[0].Metadata["discarded"])
+	assert.Equal(t, map[string]int{"node.created": 2}, events[0].Metadata["event_types"])
+}
+
+func TestEventTransaction_CommitIsContiguous(t *testing.T) {
+	p := startRecording(t)
+
+	const batch = 50
+	txs := make([]*EventTransaction, 4)
+	for i := range txs {
+		txs[i] = BeginEventTransaction(fmt.Sprintf("tx-%d", i), "bolt")
+		for j := 0; j < batch; j++ {
+			txs[i].Emit(queryEvent(fmt.Sprintf("tx-%d/%d", i, j)))
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range txs {
+		wg.Add(2)
+		go func(tx *EventTransaction) {
+			defer wg.Done()
+			tx.Commit()
+		}(txs[i])
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < batch; j++ {
+				EmitDatabaseEvent(queryEvent(fmt.Sprintf("auto-%d/%d", i, j)))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	total := len(txs) * (2*batch + 1)
+	events := p.waitEvents(t, total)
+	require.Len(t, events, total)
+
+	// Each transaction's events arrive in order with nothing in between,
+	// followed by its summary
+	for i := 0; i < len(events); i++ {
+		id := events[i].TransactionID
+		if id == "" {
+			continue
+		}
+		for j := 0; j < batch; j++ {
+			require.Equal(t, fmt.Sprintf("%s/%d", id, j), events[i+j].Query)
+		}
+		require.Equal(t, EventTransactionCommit, events[i+batch].Type)
+		require.Equal(t, id, events[i+batch].TransactionID)
+		i += batch
+	}
+}
+
+func TestEventTransaction_DispatcherStopped(t *testing.T) {
+	globalManager = nil
+	t.Cleanup(func() { globalManager = nil })
+
+	tx := BeginEventTransaction("tx-3", "bolt")
+	tx.Emit(queryEvent("q"))
+	assert.Zero(t, tx.Len(), "nothing is buffered while events are not delivered")
+	assert.Nil(t, EventTransactionFromContext(context.Background()))
+	assert.Same(t, tx, EventTransactionFromContext(WithEventTransaction(context.Background(), tx)))
+}
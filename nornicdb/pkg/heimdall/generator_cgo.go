@@ -42,6 +42,7 @@ func (g *cgoGenerator) Generate(ctx context.Context, prompt string, params Gener
 		TopP:        params.TopP,
 		TopK:        params.TopK,
 		StopTokens:  params.StopTokens,
+		Grammar:     params.Grammar,
 	}
 	return g.model.Generate(ctx, prompt, llamaParams)
 }
@@ -53,6 +54,7 @@ func (g *cgoGenerator) GenerateStream(ctx context.Context, prompt string, params
 		TopP:        params.TopP,
 		TopK:        params.TopK,
 		StopTokens:  params.StopTokens,
+		Grammar:     params.Grammar,
 	}
 	return g.model.GenerateStream(ctx, prompt, llamaParams, callback)
 }
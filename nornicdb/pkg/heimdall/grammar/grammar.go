@@ -0,0 +1,350 @@
+// Package grammar builds GBNF grammars that constrain Heimdall's SLM to the
+// JSON its callers expect.
+//
+// Action commands, QC batch reviews and memory consolidation all ask the
+// SLM for JSON and then search its answer for an object, falling back to
+// fuzzy parsing when it is malformed. With the llama.cpp backend, decoding
+// can instead be restricted by a grammar (GenerateParams.Grammar), so every
+// answer parses. Build turns the schema a caller expects into such a
+// grammar; results are cached by schema, so callers can build on every
+// request.
+//
+// Example:
+//
+//	review := &grammar.Schema{Type: grammar.TypeObject, Properties: []grammar.Property{
+//		{Name: "approved", Schema: &grammar.Schema{Type: grammar.TypeArray, Items: &grammar.Schema{Type: grammar.TypeInteger}}},
+//		{Name: "reasoning", Schema: &grammar.Schema{Type: grammar.TypeString}, Optional: true},
+//	}}
+//	g, err := grammar.Build(review)
+//	params.Grammar = g
+package grammar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidSchema is returned by Build for a schema it cannot express.
+var ErrInvalidSchema = errors.New("invalid grammar schema")
+
+// JSON value types of a Schema.
+const (
+	TypeAny     = ""        // Any JSON value
+	TypeObject  = "object"  // Properties, Values, or any object
+	TypeArray   = "array"   // Items, or any array
+	TypeString  = "string"  // Enum, or any string
+	TypeNumber  = "number"  // Any JSON number
+	TypeInteger = "integer" // Whole number without fraction or exponent
+	TypeBoolean = "boolean"
+	TypeNull    = "null"
+)
+
+// Schema describes the JSON value a grammar admits. It is a small subset of
+// JSON Schema; unlike JSON Schema, object properties are ordered, and the
+// grammar requires them in that order.
+type Schema struct {
+	Type string `json:"type,omitempty"`
+
+	// Properties are the keys of an object, in order.
+	Properties []Property `json:"properties,omitempty"`
+	// Values, if set on an object without Properties, admits any keys
+	// with values of this schema.
+	Values *Schema `json:"values,omitempty"`
+
+	// Items is the schema of every array element.
+	Items *Schema `json:"items,omitempty"`
+
+	// Enum restricts a string to these values.
+	Enum []string `json:"enum,omitempty"`
+
+	// AnyOf admits a value matching any of these schemas; Type is ignored.
+	AnyOf []*Schema `json:"any_of,omitempty"`
+}
+
+// Property is one key of an object schema.
+type Property struct {
+	Name     string  `json:"name"`
+	Schema   *Schema `json:"schema,omitempty"` // nil admits any value
+	Optional bool    `json:"optional,omitempty"`
+}
+
+// primitives are the rules shared by every grammar, added when referenced.
+var primitives = map[string]string{
+	"ws":      `[ \t\n]{0,20}`,
+	"string":  `"\"" ( [^"\\\x7F\x00-\x1F] | "\\" ( ["\\/bfnrt] | "u" [0-9a-fA-F]{4} ) )* "\""`,
+	"number":  `"-"? ( [0-9] | [1-9] [0-9]{1,15} ) ( "." [0-9]+ )? ( [eE] [-+]? [0-9]+ )?`,
+	"integer": `"-"? ( [0-9] | [1-9] [0-9]{1,15} )`,
+	"boolean": `"true" | "false"`,
+	"null":    `"null"`,
+	"value":   `object | array | string | number | boolean | null`,
+	"object":  `"{" ws ( string ws ":" ws value ( ws "," ws string ws ":" ws value )* ws )? "}"`,
+	"array":   `"[" ws ( value ( ws "," ws value )* ws )? "]"`,
+	"text":    `[^{ \t\n] [^\x00]*`,
+}
+
+// primitiveDeps lists the primitives each primitive refers to.
+var primitiveDeps = map[string][]string{
+	"value":  {"object", "array", "string", "number", "boolean", "null"},
+	"object": {"ws", "string", "value"},
+	"array":  {"ws", "value"},
+}
+
+var cache sync.Map // cacheKey -> string
+
+type cacheKey struct {
+	schema string
+	text   bool
+}
+
+// contextKey is the context key of a call's grammar.
+type contextKey struct{}
+
+// NewContext returns ctx carrying grammar g, for callers that reach the SLM
+// through a func(ctx, prompt) hook rather than GenerateParams.
+func NewContext(ctx context.Context, g string) context.Context {
+	return context.WithValue(ctx, contextKey{}, g)
+}
+
+// FromContext returns the grammar carried by ctx, or "" if there is none.
+func FromContext(ctx context.Context) string {
+	g, _ := ctx.Value(contextKey{}).(string)
+	return g
+}
+
+// Build returns a grammar admitting exactly the JSON values described by
+// schema, optionally preceded by whitespace. A nil schema admits any JSON
+// value.
+func Build(schema *Schema) (string, error) {
+	return build(schema, false)
+}
+
+// BuildOrText is Build, but the grammar also admits free text that does not
+// start with "{". Chat uses it: the SLM answers with an action command or
+// in prose, and only action commands need to be valid JSON.
+func BuildOrText(schema *Schema) (string, error) {
+	return build(schema, true)
+}
+
+func build(schema *Schema, text bool) (string, error) {
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	key := cacheKey{schema: string(encoded), text: text}
+	if g, ok := cache.Load(key); ok {
+		return g.(string), nil
+	}
+
+	b := &builder{rules: make(map[string]string)}
+	ref, err := b.visit(schema, "root-value")
+	if err != nil {
+		return "", err
+	}
+	root := "ws " + ref
+	b.use("ws")
+	if text {
+		root = "ws ( " + ref + " | text )"
+		b.use("text")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("root ::= " + root + "\n")
+	for _, name := range b.order {
+		sb.WriteString(name + " ::= " + b.rules[name] + "\n")
+	}
+	g := sb.String()
+	cache.Store(key, g)
+	return g, nil
+}
+
+// builder collects the rules of one grammar in the order they are added.
+type builder struct {
+	rules map[string]string
+	order []string
+}
+
+// add adds a rule and returns its name, which is name unless another rule
+// already has it.
+func (b *builder) add(name, body string) string {
+	unique := name
+	for i := 2; ; i++ {
+		existing, ok := b.rules[unique]
+		if !ok {
+			break
+		}
+		if existing == body {
+			return unique
+		}
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	b.rules[unique] = body
+	b.order = append(b.order, unique)
+	return unique
+}
+
+// use adds a primitive rule and the primitives it refers to.
+func (b *builder) use(name string) string {
+	if _, ok := b.rules[name]; !ok {
+		b.add(name, primitives[name])
+		for _, dep := range primitiveDeps[name] {
+			b.use(dep)
+		}
+	}
+	return name
+}
+
+// visit returns the rule for values of s, adding rules named after name.
+func (b *builder) visit(s *Schema, name string) (string, error) {
+	if s == nil {
+		return b.use("value"), nil
+	}
+	if len(s.AnyOf) > 0 {
+		alts := make([]string, len(s.AnyOf))
+		for i, alt := range s.AnyOf {
+			ref, err := b.visit(alt, fmt.Sprintf("%s-%d", name, i+1))
+			if err != nil {
+				return "", err
+			}
+			alts[i] = ref
+		}
+		return b.add(name, strings.Join(alts, " | ")), nil
+	}
+	if len(s.Enum) > 0 && s.Type != TypeString {
+		return "", fmt.Errorf("%w: enum on a %q value", ErrInvalidSchema, s.Type)
+	}
+
+	switch s.Type {
+	case TypeAny:
+		return b.use("value"), nil
+	case TypeString:
+		if len(s.Enum) == 0 {
+			return b.use("string"), nil
+		}
+		alts := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			alts[i] = literal(jsonString(v))
+		}
+		return b.add(name, strings.Join(alts, " | ")), nil
+	case TypeNumber, TypeInteger, TypeBoolean, TypeNull:
+		return b.use(s.Type), nil
+	case TypeArray:
+		if s.Items == nil {
+			return b.use("array"), nil
+		}
+		item, err := b.visit(s.Items, name+"-item")
+		if err != nil {
+			return "", err
+		}
+		b.use("ws")
+		return b.add(name, fmt.Sprintf(`"[" ws ( %s ( ws "," ws %s )* ws )? "]"`, item, item)), nil
+	case TypeObject:
+		return b.object(s, name)
+	default:
+		return "", fmt.Errorf("%w: unknown type %q", ErrInvalidSchema, s.Type)
+	}
+}
+
+// object returns the rule for an object schema.
+func (b *builder) object(s *Schema, name string) (string, error) {
+	if len(s.Properties) == 0 {
+		if s.Values == nil {
+			return b.use("object"), nil
+		}
+		value, err := b.visit(s.Values, name+"-value")
+		if err != nil {
+			return "", err
+		}
+		b.use("ws")
+		b.use("string")
+		return b.add(name, fmt.Sprintf(`"{" ws ( string ws ":" ws %s ( ws "," ws string ws ":" ws %s )* ws )? "}"`, value, value)), nil
+	}
+
+	b.use("ws")
+	kvs := make([]string, len(s.Properties))
+	seen := make(map[string]bool, len(s.Properties))
+	for i, p := range s.Properties {
+		if p.Name == "" || seen[p.Name] {
+			return "", fmt.Errorf("%w: empty or duplicate property %q", ErrInvalidSchema, p.Name)
+		}
+		seen[p.Name] = true
+		value, err := b.visit(p.Schema, name+"-"+ruleName(p.Name))
+		if err != nil {
+			return "", err
+		}
+		kvs[i] = b.add(name+"-"+ruleName(p.Name)+"-kv", literal(jsonString(p.Name))+` ws ":" ws `+value)
+	}
+
+	// Each alternative starts at one property and lists the rest, so no
+	// comma leads; properties before the first required one may be skipped.
+	var alts []string
+	required := false
+	for j := range s.Properties {
+		alt := kvs[j]
+		for m := j + 1; m < len(kvs); m++ {
+			if s.Properties[m].Optional {
+				alt += ` ( ws "," ws ` + kvs[m] + ` )?`
+			} else {
+				alt += ` ws "," ws ` + kvs[m]
+			}
+		}
+		alts = append(alts, alt)
+		if !s.Properties[j].Optional {
+			required = true
+			break
+		}
+	}
+	members := strings.Join(alts, " | ")
+	if len(alts) > 1 {
+		members = "( " + members + " )"
+	}
+	if !required {
+		return b.add(name, `"{" ws ( `+members+` ws )? "}"`), nil
+	}
+	return b.add(name, `"{" ws `+members+` ws "}"`), nil
+}
+
+// ruleName turns a property name into rule name characters.
+func ruleName(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('-')
+		}
+	}
+	return sb.String()
+}
+
+// jsonString returns s as a JSON string literal.
+func jsonString(s string) string {
+	encoded, _ := json.Marshal(s)
+	return string(encoded)
+}
+
+// literal returns s as a GBNF string literal.
+func literal(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,392 @@
+package grammar
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var qcSchema = &Schema{Type: TypeObject, Properties: []Property{
+	{Name: "approved", Schema: &Schema{Type: TypeArray, Items: &Schema{Type: TypeInteger}}},
+	{Name: "rejected", Schema: &Schema{Type: TypeArray, Items: &Schema{Type: TypeInteger}}, Optional: true},
+	{Name: "type_overrides", Schema: &Schema{Type: TypeObject, Values: &Schema{Type: TypeString}}, Optional: true},
+	{Name: "reasoning", Schema: &Schema{Type: TypeString}, Optional: true},
+}}
+
+func TestBuild_Object(t *testing.T) {
+	g, err := Build(qcSchema)
+	require.NoError(t, err)
+	m := parseGBNF(t, g)
+
+	for _, ok := range []string{
+		`{"approved": [0, 2]}`,
+		` {"approved":[],"reasoning":"none fit"}`,
+		"{\n  \"approved\": [1],\n  \"rejected\": [0, -3],\n  \"type_overrides\": {\"1\": \"USES\"},\n  \"reasoning\": \"say \\\"hi\\\"\"\n}",
+	} {
+		assert.True(t, m.matches(ok), ok)
+	}
+	for _, bad := range []string{
+		`{"reasoning": "x"}`,                               // Missing required
+		`{"approved": [0.5]}`,                              // Not an integer
+		`{"approved": [1], "extra": 1}`,                    // Unknown property
+		`{"reasoning": "x", "approved": [1]}`,              // Out of order
+		`{"approved": [1],}`,                               // Trailing comma
+		`{"approved": [1]`,                                 // Unterminated
+		`Sure! {"approved": [1]}`,                          // Prose
+		`{"approved": [1], "reasoning": "a` + "\n" + `b"}`, // Raw newline in string
+	} {
+		assert.False(t, m.matches(bad), bad)
+	}
+}
+
+func TestBuild_AllOptional(t *testing.T) {
+	g, err := Build(&Schema{Type: TypeObject, Properties: []Property{
+		{Name: "a", Schema: &Schema{Type: TypeBoolean}, Optional: true},
+		{Name: "b", Schema: &Schema{Type: TypeNull}, Optional: true},
+	}})
+	require.NoError(t, err)
+	m := parseGBNF(t, g)
+	for _, ok := range []string{`{}`, `{"a": true}`, `{"b": null}`, `{"a": false, "b": null}`} {
+		assert.True(t, m.matches(ok), ok)
+	}
+	for _, bad := range []string{`{,"b": null}`, `{"b": null, "a": true}`, `{"a": 1}`} {
+		assert.False(t, m.matches(bad), bad)
+	}
+}
+
+func TestBuild_EnumAndAnyOf(t *testing.T) {
+	action := &Schema{Type: TypeObject, Properties: []Property{
+		{Name: "action", Schema: &Schema{Type: TypeString, Enum: []string{"heimdall.watcher.status", `odd "name"`}}},
+		{Name: "params", Schema: &Schema{Type: TypeObject}},
+	}}
+	g, err := Build(&Schema{AnyOf: []*Schema{action, {Type: TypeNumber}}})
+	require.NoError(t, err)
+	m := parseGBNF(t, g)
+
+	assert.True(t, m.matches(`{"action": "heimdall.watcher.status", "params": {"nested": [1, {"x": null}]}}`))
+	assert.True(t, m.matches(`{"action": "odd \"name\"", "params": {}}`))
+	assert.True(t, m.matches(`-1.5e3`))
+	assert.False(t, m.matches(`{"action": "heimdall.watcher.drop_all", "params": {}}`))
+	assert.False(t, m.matches(`"text"`))
+}
+
+func TestBuildOrText(t *testing.T) {
+	g, err := BuildOrText(&Schema{Type: TypeObject, Properties: []Property{{Name: "action", Schema: &Schema{Type: TypeString}}}})
+	require.NoError(t, err)
+	m := parseGBNF(t, g)
+
+	assert.True(t, m.matches(`{"action": "x"}`))
+	assert.True(t, m.matches("MATCH (n) returns every node.\nUse {limit} to page."))
+	assert.False(t, m.matches(`{"action": 1}`), "JSON must match the schema")
+	assert.False(t, m.matches(`{"action": "x"`), "JSON must be complete")
+}
+
+func TestBuild_AnyValue(t *testing.T) {
+	g, err := Build(nil)
+	require.NoError(t, err)
+	m := parseGBNF(t, g)
+	for _, ok := range []string{`null`, `[1, "a", {"b": [true]}]`, `"é"`} {
+		assert.True(t, m.matches(ok), ok)
+	}
+	assert.False(t, m.matches(`[1 2]`))
+	assert.False(t, m.matches(`01`))
+}
+
+func TestBuild_Invalid(t *testing.T) {
+	for _, s := range []*Schema{
+		{Type: "tuple"},
+		{Type: TypeInteger, Enum: []string{"1"}},
+		{Type: TypeObject, Properties: []Property{{Name: "a"}, {Name: "a"}}},
+		{Type: TypeArray, Items: &Schema{Type: "date"}},
+	} {
+		_, err := Build(s)
+		assert.True(t, errors.Is(err, ErrInvalidSchema), "%+v: %v", s, err)
+	}
+}
+
+func TestBuild_Cached(t *testing.T) {
+	first, err := Build(qcSchema)
+	require.NoError(t, err)
+	copied := *qcSchema
+	second, err := Build(&copied)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	text, err := BuildOrText(qcSchema)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, text)
+	assert.True(t, strings.HasPrefix(first, "root ::= ws root-value\n"))
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, FromContext(ctx))
+	assert.Equal(t, "root ::= null", FromContext(NewContext(ctx, "root ::= null")))
+}
+
+// gbnf is a recognizer for the GBNF that Build emits, so tests check what
+// a grammar admits rather than its text.
+type gbnf struct {
+	t     *testing.T
+	rules map[string]node
+}
+
+type node struct {
+	kind     byte // 'a' alternatives, 's' sequence, 'l' literal, 'c' class, 'r' rule
+	children []node
+	lit      string
+	ranges   [][2]rune
+	negate   bool
+	name     string
+	min, max int // Repetition; max -1 = unbounded
+}
+
+func parseGBNF(t *testing.T, g string) *gbnf {
+	m := &gbnf{t: t, rules: make(map[string]node)}
+	for _, line := range strings.Split(strings.TrimSpace(g), "\n") {
+		name, body, ok := strings.Cut(line, " ::= ")
+		require.True(t, ok, line)
+		_, exists := m.rules[name]
+		require.False(t, exists, "rule %s defined twice", name)
+		p := &gbnfParser{t: t, s: []rune(body)}
+		m.rules[name] = p.alternatives()
+		require.Equal(t, len(p.s), p.i, "unparsed input in %s", line)
+	}
+	for name, n := range m.rules {
+		m.checkRefs(name, n)
+	}
+	return m
+}
+
+func (m *gbnf) checkRefs(rule string, n node) {
+	if n.kind == 'r' {
+		_, ok := m.rules[n.name]
+		require.True(m.t, ok, "rule %s refers to undefined %s", rule, n.name)
+	}
+	for _, c := range n.children {
+		m.checkRefs(rule, c)
+	}
+}
+
+func (m *gbnf) matches(s string) bool {
+	in := []rune(s)
+	for _, end := range m.match(m.rules["root"], in, 0) {
+		if end == len(in) {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns every position where n, started at pos, can end.
+func (m *gbnf) match(n node, in []rune, pos int) []int {
+	ends := []int{}
+	add := func(more []int) {
+		for _, e := range more {
+			dup := false
+			for _, x := range ends {
+				dup = dup || x == e
+			}
+			if !dup {
+				ends = append(ends, e)
+			}
+		}
+	}
+	count := 0
+	current := []int{pos}
+	if n.min == 0 {
+		add(current)
+	}
+	for len(current) > 0 && (n.max < 0 || count < n.max) {
+		var next []int
+		for _, p := range current {
+			next = append(next, m.matchOnce(n, in, p)...)
+		}
+		count++
+		if count >= n.min {
+			add(next)
+		}
+		if n.max < 0 && len(next) > 0 && next[0] == current[0] && len(next) == len(current) {
+			break // No progress
+		}
+		current = next
+	}
+	return ends
+}
+
+func (m *gbnf) matchOnce(n node, in []rune, pos int) []int {
+	switch n.kind {
+	case 'a':
+		var ends []int
+		for _, c := range n.children {
+			ends = append(ends, m.match(c, in, pos)...)
+		}
+		return ends
+	case 's':
+		positions := []int{pos}
+		for _, c := range n.children {
+			var next []int
+			for _, p := range positions {
+				next = append(next, m.match(c, in, p)...)
+			}
+			positions = next
+		}
+		return positions
+	case 'l':
+		lit := []rune(n.lit)
+		if pos+len(lit) <= len(in) && string(in[pos:pos+len(lit)]) == n.lit {
+			return []int{pos + len(lit)}
+		}
+		return nil
+	case 'c':
+		if pos >= len(in) {
+			return nil
+		}
+		found := false
+		for _, r := range n.ranges {
+			found = found || (r[0] <= in[pos] && in[pos] <= r[1])
+		}
+		if found == n.negate {
+			return nil
+		}
+		return []int{pos + 1}
+	case 'r':
+		return m.match(m.rules[n.name], in, pos)
+	}
+	return nil
+}
+
+type gbnfParser struct {
+	t *testing.T
+	s []rune
+	i int
+}
+
+func (p *gbnfParser) ws() {
+	for p.i < len(p.s) && p.s[p.i] == ' ' {
+		p.i++
+	}
+}
+
+func (p *gbnfParser) alternatives() node {
+	alts := []node{p.sequence()}
+	for p.ws(); p.i < len(p.s) && p.s[p.i] == '|'; p.ws() {
+		p.i++
+		alts = append(alts, p.sequence())
+	}
+	return node{kind: 'a', children: alts, min: 1, max: 1}
+}
+
+func (p *gbnfParser) sequence() node {
+	seq := node{kind: 's', min: 1, max: 1}
+	for {
+		p.ws()
+		if p.i >= len(p.s) || p.s[p.i] == '|' || p.s[p.i] == ')' {
+			return seq
+		}
+		seq.children = append(seq.children, p.repeated(p.item()))
+	}
+}
+
+func (p *gbnfParser) item() node {
+	switch c := p.s[p.i]; {
+	case c == '(':
+		p.i++
+		n := p.alternatives()
+		p.ws()
+		require.Equal(p.t, ')', p.s[p.i])
+		p.i++
+		return n
+	case c == '"':
+		p.i++
+		var sb strings.Builder
+		for p.s[p.i] != '"' {
+			sb.WriteRune(p.char())
+		}
+		p.i++
+		return node{kind: 'l', lit: sb.String(), min: 1, max: 1}
+	case c == '[':
+		p.i++
+		n := node{kind: 'c', min: 1, max: 1}
+		if p.s[p.i] == '^' {
+			n.negate = true
+			p.i++
+		}
+		for p.s[p.i] != ']' {
+			lo := p.char()
+			hi := lo
+			if p.s[p.i] == '-' && p.s[p.i+1] != ']' {
+				p.i++
+				hi = p.char()
+			}
+			n.ranges = append(n.ranges, [2]rune{lo, hi})
+		}
+		p.i++
+		return n
+	default:
+		start := p.i
+		for p.i < len(p.s) && (p.s[p.i] == '-' || (p.s[p.i] >= 'a' && p.s[p.i] <= 'z') || (p.s[p.i] >= '0' && p.s[p.i] <= '9')) {
+			p.i++
+		}
+		require.Greater(p.t, p.i, start, "unexpected %q at %d in %s", c, p.i, string(p.s))
+		return node{kind: 'r', name: string(p.s[start:p.i]), min: 1, max: 1}
+	}
+}
+
+func (p *gbnfParser) repeated(n node) node {
+	if p.i >= len(p.s) {
+		return n
+	}
+	wrap := func(min, max int) node {
+		return node{kind: 's', children: []node{n}, min: min, max: max}
+	}
+	switch p.s[p.i] {
+	case '?':
+		p.i++
+		return wrap(0, 1)
+	case '*':
+		p.i++
+		return wrap(0, -1)
+	case '+':
+		p.i++
+		return wrap(1, -1)
+	case '{':
+		end := p.i + strings.IndexRune(string(p.s[p.i:]), '}')
+		lo, hi, _ := strings.Cut(string(p.s[p.i+1:end]), ",")
+		p.i = end + 1
+		min, _ := strconv.Atoi(lo)
+		max, _ := strconv.Atoi(hi)
+		return wrap(min, max)
+	}
+	return n
+}
+
+// char reads one possibly escaped character of a literal or class.
+func (p *gbnfParser) char() rune {
+	c := p.s[p.i]
+	p.i++
+	if c != '\\' {
+		return c
+	}
+	e := p.s[p.i]
+	p.i++
+	switch e {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'x':
+		v, err := strconv.ParseUint(string(p.s[p.i:p.i+2]), 16, 8)
+		require.NoError(p.t, err)
+		p.i += 2
+		return rune(v)
+	}
+	return e
+}
@@ -0,0 +1,413 @@
+// Package guardrails blocks unsafe Heimdall actions before they execute.
+//
+// Operators describe what the SLM must never do; every action parsed from an
+// SLM response is checked after PreExecute hooks and before execution:
+//   - Output patterns: regular expressions matched against the raw SLM
+//     response (e.g. block any response mentioning "apoc.").
+//   - Action rules: an action name glob, an optional parameter name and a
+//     pattern for that parameter's value (e.g. any "cypher" parameter
+//     containing DETACH DELETE). A rule without a pattern blocks the action.
+//   - Param validators: Go functions registered per action glob for checks
+//     that are not expressible as a pattern (length limits, read-only Cypher).
+//   - Scopes: the set of action categories a persona may use. CheckScope
+//     blocks actions outside the scope regardless of other rules.
+//
+// Blocked attempts are reported to the OnBlock callback so they can be
+// recorded as security events.
+//
+// Rule specs use the form "action[:param]=pattern":
+//
+//	heimdall.watcher.query:cypher=\bDETACH\s+DELETE\b
+//	*:cypher=\bDROP\s+(INDEX|CONSTRAINT|DATABASE)\b
+//	heimdall.admin.*
+//
+// Patterns are case-insensitive.
+//
+// Example:
+//
+//	rules, _ := guardrails.ParseRules([]string{`*:cypher=\bDETACH\s+DELETE\b`})
+//	g, err := guardrails.New(guardrails.Config{Rules: rules})
+//	if err != nil {
+//		return err
+//	}
+//	g.AddValidator("heimdall.watcher.query", guardrails.ReadOnlyCypher("cypher"))
+//	g.OnBlock(func(ctx context.Context, v *guardrails.Violation) {
+//		auditLog.LogSecurityEvent(audit.EventSecurityAlert, "", "", v.Error(), nil)
+//	})
+//
+//	if v := g.Check(ctx, rawResponse, action, params); v != nil {
+//		return v // do not execute
+//	}
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Stages at which a violation can be detected.
+const (
+	StageOutput    = "output"    // Raw SLM response matched an output pattern
+	StageRule      = "rule"      // Action/param matched a blocklist rule
+	StageValidator = "validator" // A param validator rejected the params
+	StageScope     = "scope"     // Action is outside the persona's categories
+)
+
+// Rule blocks an action, or an action whose parameter matches a pattern.
+type Rule struct {
+	// Name identifies the rule in logs (defaults to the rule spec).
+	Name string
+	// Action is a glob over action names ("heimdall.watcher.query",
+	// "heimdall.admin.*", "*"). Empty matches every action.
+	Action string
+	// Param restricts the pattern to one parameter. Empty checks every
+	// string parameter, including nested values.
+	Param string
+	// Pattern is matched case-insensitively against parameter values.
+	// Empty blocks the action outright.
+	Pattern string
+	// Reason is shown to the user and recorded with the violation.
+	Reason string
+}
+
+// Validator checks the params of an action and returns an error to block it.
+type Validator func(action string, params map[string]interface{}) error
+
+// Config configures Guardrails.
+type Config struct {
+	// Rules are action/param blocklist rules.
+	Rules []Rule
+	// OutputPatterns are regular expressions checked against raw SLM output.
+	OutputPatterns []string
+}
+
+// Violation describes a blocked action.
+type Violation struct {
+	Stage  string // StageOutput, StageRule, StageValidator or StageScope
+	Rule   string // Rule name, output pattern or validator glob
+	Action string // Action that was blocked
+	Param  string // Offending parameter, if known
+	Reason string // Human-readable reason
+}
+
+// Error implements error.
+func (v *Violation) Error() string {
+	msg := fmt.Sprintf("guardrail %q blocked %s", v.Rule, v.Action)
+	if v.Param != "" {
+		msg += " (param " + v.Param + ")"
+	}
+	if v.Reason != "" {
+		msg += ": " + v.Reason
+	}
+	return msg
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+type outputPattern struct {
+	src string
+	re  *regexp.Regexp
+}
+
+type validatorEntry struct {
+	glob string
+	fn   Validator
+}
+
+// Guardrails checks SLM output and actions against operator rules.
+// It is safe for concurrent use.
+type Guardrails struct {
+	rules   []compiledRule
+	outputs []outputPattern
+
+	mu         sync.RWMutex
+	validators []validatorEntry
+	onBlock    func(ctx context.Context, v *Violation)
+}
+
+// New compiles guardrails from config.
+func New(config Config) (*Guardrails, error) {
+	g := &Guardrails{}
+	for _, r := range config.Rules {
+		if r.Action == "" {
+			r.Action = "*"
+		}
+		if _, err := path.Match(r.Action, ""); err != nil {
+			return nil, fmt.Errorf("invalid action glob %q: %w", r.Action, err)
+		}
+		cr := compiledRule{Rule: r}
+		if r.Pattern != "" {
+			re, err := regexp.Compile("(?i)" + r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid guardrail pattern %q: %w", r.Pattern, err)
+			}
+			cr.re = re
+		}
+		if cr.Name == "" {
+			cr.Name = ruleSpec(r)
+		}
+		g.rules = append(g.rules, cr)
+	}
+	for _, p := range config.OutputPatterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output pattern %q: %w", p, err)
+		}
+		g.outputs = append(g.outputs, outputPattern{src: p, re: re})
+	}
+	return g, nil
+}
+
+// AddValidator registers a param validator for actions matching glob.
+func (g *Guardrails) AddValidator(glob string, v Validator) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.validators = append(g.validators, validatorEntry{glob: glob, fn: v})
+}
+
+// OnBlock sets the callback invoked for every blocked attempt.
+func (g *Guardrails) OnBlock(fn func(ctx context.Context, v *Violation)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onBlock = fn
+}
+
+// Check runs all guardrails for an action parsed from output. It returns nil
+// if the action may execute. Blocked attempts are reported to OnBlock.
+// A nil *Guardrails allows everything.
+func (g *Guardrails) Check(ctx context.Context, output, action string, params map[string]interface{}) *Violation {
+	if g == nil {
+		return nil
+	}
+	v := g.check(output, action, params)
+	if v != nil {
+		g.report(ctx, v)
+	}
+	return v
+}
+
+// CheckScope blocks action unless its category is one of allowed. An empty
+// allowed list permits every category. Unlike Check it is enforced on a nil
+// *Guardrails too; violations are reported to OnBlock when g is non-nil.
+func (g *Guardrails) CheckScope(ctx context.Context, scope string, allowed []string, action, category string) *Violation {
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, c := range allowed {
+		if strings.EqualFold(c, category) || c == "*" {
+			return nil
+		}
+	}
+	v := &Violation{Stage: StageScope, Rule: scope, Action: action,
+		Reason: fmt.Sprintf("category %q is not allowed for %s", category, scope)}
+	if g != nil {
+		g.report(ctx, v)
+	}
+	return v
+}
+
+func (g *Guardrails) report(ctx context.Context, v *Violation) {
+	g.mu.RLock()
+	fn := g.onBlock
+	g.mu.RUnlock()
+	if fn != nil {
+		fn(ctx, v)
+	}
+}
+
+func (g *Guardrails) check(output, action string, params map[string]interface{}) *Violation {
+	for _, op := range g.outputs {
+		if op.re.MatchString(output) {
+			return &Violation{Stage: StageOutput, Rule: op.src, Action: action,
+				Reason: "response matches a blocked output pattern"}
+		}
+	}
+
+	for _, r := range g.rules {
+		if !matchAction(r.Action, action) {
+			continue
+		}
+		if r.re == nil {
+			return &Violation{Stage: StageRule, Rule: r.Name, Action: action, Reason: reasonOr(r.Reason, "action is blocked")}
+		}
+		if param, ok := matchParams(r.re, r.Param, params); ok {
+			return &Violation{Stage: StageRule, Rule: r.Name, Action: action, Param: param,
+				Reason: reasonOr(r.Reason, "parameter matches a blocked pattern")}
+		}
+	}
+
+	g.mu.RLock()
+	validators := g.validators
+	g.mu.RUnlock()
+	for _, entry := range validators {
+		if !matchAction(entry.glob, action) {
+			continue
+		}
+		if err := entry.fn(action, params); err != nil {
+			v := &Violation{Stage: StageValidator, Rule: entry.glob, Action: action, Reason: err.Error()}
+			var pe *ParamError
+			if errors.As(err, &pe) {
+				v.Param = pe.Param
+				v.Reason = pe.Reason
+			}
+			return v
+		}
+	}
+	return nil
+}
+
+// matchAction reports whether action matches glob ("*" and "" match all).
+func matchAction(glob, action string) bool {
+	if glob == "" || glob == "*" {
+		return true
+	}
+	ok, _ := path.Match(glob, action)
+	return ok
+}
+
+// matchParams checks re against the named param, or every string param when
+// name is empty. It returns the offending param path.
+func matchParams(re *regexp.Regexp, name string, params map[string]interface{}) (string, bool) {
+	if name != "" {
+		v, ok := params[name]
+		if !ok {
+			return "", false
+		}
+		if _, hit := matchValue(re, name, v); hit {
+			return name, true
+		}
+		return "", false
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if p, hit := matchValue(re, k, params[k]); hit {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+func matchValue(re *regexp.Regexp, name string, v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return name, re.MatchString(val)
+	case map[string]interface{}:
+		if p, hit := matchParams(re, "", val); hit {
+			return name + "." + p, true
+		}
+	case []interface{}:
+		for i, item := range val {
+			if p, hit := matchValue(re, fmt.Sprintf("%s[%d]", name, i), item); hit {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+func reasonOr(reason, fallback string) string {
+	if reason != "" {
+		return reason
+	}
+	return fallback
+}
+
+func ruleSpec(r Rule) string {
+	spec := r.Action
+	if r.Param != "" {
+		spec += ":" + r.Param
+	}
+	if r.Pattern != "" {
+		spec += "=" + r.Pattern
+	}
+	return spec
+}
+
+// ParseRules parses "action[:param]=pattern" rule specs. The pattern may be
+// omitted to block an action entirely.
+func ParseRules(specs []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(specs))
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		target, pattern, _ := strings.Cut(spec, "=")
+		action, param, _ := strings.Cut(strings.TrimSpace(target), ":")
+		action = strings.TrimSpace(action)
+		if action == "" {
+			return nil, fmt.Errorf("invalid guardrail rule %q: expected action[:param]=pattern", spec)
+		}
+		rules = append(rules, Rule{
+			Name:    spec,
+			Action:  action,
+			Param:   strings.TrimSpace(param),
+			Pattern: strings.TrimSpace(pattern),
+		})
+	}
+	return rules, nil
+}
+
+// ParamError is returned by validators to name the offending parameter.
+type ParamError struct {
+	Param  string
+	Reason string
+}
+
+// Error implements error.
+func (e *ParamError) Error() string {
+	return e.Param + ": " + e.Reason
+}
+
+// MaxLength rejects string params longer than n bytes.
+func MaxLength(param string, n int) Validator {
+	return func(action string, params map[string]interface{}) error {
+		if s, ok := params[param].(string); ok && len(s) > n {
+			return &ParamError{Param: param, Reason: fmt.Sprintf("longer than %d bytes", n)}
+		}
+		return nil
+	}
+}
+
+// Required rejects actions missing a non-empty param.
+func Required(param string) Validator {
+	return func(action string, params map[string]interface{}) error {
+		v, ok := params[param]
+		if !ok || v == nil || v == "" {
+			return &ParamError{Param: param, Reason: "is required"}
+		}
+		return nil
+	}
+}
+
+// writeClause matches Cypher clauses and procedures that modify the graph or schema.
+var writeClause = regexp.MustCompile(`(?i)\b(?:CREATE|MERGE|DELETE|SET|REMOVE|DROP|LOAD\s+CSV|FOREACH)\b|\bCALL\s+(?:apoc\.(?:create|merge|refactor|periodic|trigger|schema)|db\.(?:create|drop|index\.fulltext\.create))`)
+
+// ReadOnlyCypher rejects Cypher in param that writes to the graph or schema.
+// String literals are ignored so values such as 'SET' do not trigger it.
+func ReadOnlyCypher(param string) Validator {
+	literals := regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+	return func(action string, params map[string]interface{}) error {
+		s, ok := params[param].(string)
+		if !ok {
+			return nil
+		}
+		if m := writeClause.FindString(literals.ReplaceAllString(s, "''")); m != "" {
+			return &ParamError{Param: param, Reason: fmt.Sprintf("write operation %q not allowed (read-only)", strings.ToUpper(m))}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,147 @@
+package guardrails
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGuardrails(t *testing.T, specs []string, outputs []string) *Guardrails {
+	t.Helper()
+	rules, err := ParseRules(specs)
+	require.NoError(t, err)
+	g, err := New(Config{Rules: rules, OutputPatterns: outputs})
+	require.NoError(t, err)
+	return g
+}
+
+func TestCheck_Rules(t *testing.T) {
+	g := newTestGuardrails(t, []string{
+		`heimdall.watcher.query:cypher=\bDETACH\s+DELETE\b`,
+		`*:cypher=\bDROP\s+(INDEX|CONSTRAINT|DATABASE)\b`,
+		`heimdall.admin.*`,
+		`*=password`,
+	}, nil)
+
+	tests := []struct {
+		name    string
+		action  string
+		params  map[string]interface{}
+		blocked bool
+		param   string
+	}{
+		{"detach delete", "heimdall.watcher.query", map[string]interface{}{"cypher": "MATCH (n) detach  delete n"}, true, "cypher"},
+		{"plain read", "heimdall.watcher.query", map[string]interface{}{"cypher": "MATCH (n) RETURN count(n)"}, false, ""},
+		{"rule scoped to action", "heimdall.other.query", map[string]interface{}{"cypher": "MATCH (n) DETACH DELETE n"}, false, ""},
+		{"drop on any action", "heimdall.other.query", map[string]interface{}{"cypher": "DROP INDEX idx"}, true, "cypher"},
+		{"blocked action glob", "heimdall.admin.reset", nil, true, ""},
+		{"any param nested", "heimdall.x", map[string]interface{}{"opts": map[string]interface{}{"note": "my PASSWORD"}}, true, "opts.note"},
+		{"any param list", "heimdall.x", map[string]interface{}{"tags": []interface{}{"a", "password"}}, true, "tags[1]"},
+		{"missing param", "heimdall.watcher.query", map[string]interface{}{}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := g.Check(context.Background(), "", tt.action, tt.params)
+			if !tt.blocked {
+				assert.Nil(t, v)
+				return
+			}
+			require.NotNil(t, v)
+			assert.Equal(t, StageRule, v.Stage)
+			assert.Equal(t, tt.action, v.Action)
+			assert.Equal(t, tt.param, v.Param)
+		})
+	}
+}
+
+func TestCheck_OutputPatterns(t *testing.T) {
+	g := newTestGuardrails(t, nil, []string{`apoc\.`})
+	v := g.Check(context.Background(), `{"action": "heimdall.watcher.query", "params": {"cypher": "CALL APOC.periodic.iterate()"}}`,
+		"heimdall.watcher.query", nil)
+	require.NotNil(t, v)
+	assert.Equal(t, StageOutput, v.Stage)
+	assert.Equal(t, `apoc\.`, v.Rule)
+
+	assert.Nil(t, g.Check(context.Background(), `{"action": "heimdall.watcher.status"}`, "heimdall.watcher.status", nil))
+}
+
+func TestCheck_Validators(t *testing.T) {
+	g := newTestGuardrails(t, nil, nil)
+	g.AddValidator("heimdall.watcher.query", ReadOnlyCypher("cypher"))
+	g.AddValidator("*", MaxLength("cypher", 40))
+
+	ctx := context.Background()
+	assert.Nil(t, g.Check(ctx, "", "heimdall.watcher.query", map[string]interface{}{"cypher": "MATCH (n {op: 'SET'}) RETURN n"}))
+
+	v := g.Check(ctx, "", "heimdall.watcher.query", map[string]interface{}{"cypher": "MATCH (n) SET n.x = 1"})
+	require.NotNil(t, v)
+	assert.Equal(t, StageValidator, v.Stage)
+	assert.Equal(t, "cypher", v.Param)
+	assert.Contains(t, v.Reason, "SET")
+
+	v = g.Check(ctx, "", "heimdall.other", map[string]interface{}{"cypher": "MATCH (n) WHERE n.name = 'a very long name' RETURN n"})
+	require.NotNil(t, v)
+	assert.Contains(t, v.Reason, "longer than 40")
+
+	v = g.Check(ctx, "", "heimdall.x", nil)
+	assert.Nil(t, v)
+	g.AddValidator("heimdall.x", Required("id"))
+	v = g.Check(ctx, "", "heimdall.x", nil)
+	require.NotNil(t, v)
+	assert.Equal(t, "id", v.Param)
+}
+
+func TestCheck_OnBlock(t *testing.T) {
+	g := newTestGuardrails(t, []string{"heimdall.admin.*"}, nil)
+	var got []*Violation
+	g.OnBlock(func(ctx context.Context, v *Violation) { got = append(got, v) })
+
+	g.Check(context.Background(), "", "heimdall.watcher.status", nil)
+	g.Check(context.Background(), "", "heimdall.admin.drop", nil)
+	require.Len(t, got, 1)
+	assert.Equal(t, `guardrail "heimdall.admin.*" blocked heimdall.admin.drop: action is blocked`, got[0].Error())
+}
+
+func TestNilGuardrails(t *testing.T) {
+	var g *Guardrails
+	assert.Nil(t, g.Check(context.Background(), "anything", "heimdall.admin.drop", nil))
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules([]string{" heimdall.watcher.query : cypher = DELETE ", "", "heimdall.admin.*"})
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, Rule{Name: "heimdall.watcher.query : cypher = DELETE", Action: "heimdall.watcher.query", Param: "cypher", Pattern: "DELETE"}, rules[0])
+	assert.Equal(t, "heimdall.admin.*", rules[1].Action)
+	assert.Empty(t, rules[1].Pattern)
+
+	_, err = ParseRules([]string{"=DELETE"})
+	assert.Error(t, err)
+
+	_, err = New(Config{Rules: []Rule{{Action: "x", Pattern: "("}}})
+	assert.Error(t, err)
+	_, err = New(Config{Rules: []Rule{{Action: "[", Pattern: "x"}}})
+	assert.Error(t, err)
+}
+
+func TestCheckScope(t *testing.T) {
+	g := newTestGuardrails(t, nil, nil)
+	var got []*Violation
+	g.OnBlock(func(ctx context.Context, v *Violation) { got = append(got, v) })
+	ctx := context.Background()
+
+	assert.Nil(t, g.CheckScope(ctx, "persona analyst", nil, "heimdall.admin.drop", "admin"))
+	assert.Nil(t, g.CheckScope(ctx, "persona analyst", []string{"Monitoring"}, "heimdall.watcher.status", "monitoring"))
+
+	v := g.CheckScope(ctx, "persona analyst", []string{"monitoring"}, "heimdall.admin.drop", "admin")
+	require.NotNil(t, v)
+	assert.Equal(t, StageScope, v.Stage)
+	assert.Equal(t, "persona analyst", v.Rule)
+	require.Len(t, got, 1)
+
+	var nilG *Guardrails
+	assert.NotNil(t, nilG.CheckScope(ctx, "persona analyst", []string{"monitoring"}, "heimdall.admin.drop", "admin"),
+		"scopes are enforced without configured guardrails")
+}
@@ -1,13 +1,18 @@
 package heimdall
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall/guardrails"
 )
 
 // Handler provides HTTP endpoints for Bifrost chat.
@@ -18,12 +23,16 @@ import (
 //   - GET  /api/bifrost/status           - Heimdall and Bifrost status
 //   - POST /api/bifrost/chat/completions - Chat with Heimdall
 //   - GET  /api/bifrost/events           - SSE stream for real-time events
+//   - GET  /api/bifrost/confirmations    - Pending action confirmations
+//   - POST /api/bifrost/confirmations    - Approve or reject a confirmation
+//   - GET  /api/bifrost/traces[/{id}]    - Request traces (see Trace)
 type Handler struct {
 	manager  *Manager
 	bifrost  *Bifrost
 	config   Config
 	database DatabaseReader
 	metrics  MetricsReader
+	traces   *TraceStore // nil unless Config.Trace
 }
 
 // NewHandler creates a Bifrost HTTP handler.
@@ -35,12 +44,18 @@ func NewHandler(manager *Manager, cfg Config, db DatabaseReader, metrics Metrics
 	}
 	// Bifrost is automatically enabled when Heimdall is enabled
 	bifrost := NewBifrost(cfg)
+	var traces *TraceStore
+	if cfg.Trace {
+		traces = NewTraceStore(cfg.TraceLimit)
+	}
+	setTraceStore(traces)
 	return &Handler{
 		manager:  manager,
 		bifrost:  bifrost,
 		config:   cfg,
 		database: db,
 		metrics:  metrics,
+		traces:   traces,
 	}
 }
 
@@ -62,6 +77,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.handleChatCompletions(w, r)
 	case r.URL.Path == "/api/bifrost/events":
 		h.handleEvents(w, r)
+	case r.URL.Path == "/api/bifrost/confirmations":
+		h.handleConfirmations(w, r)
+	case r.URL.Path == "/api/bifrost/personas":
+		h.handlePersonas(w, r)
+	case r.URL.Path == "/api/bifrost/traces" || strings.HasPrefix(r.URL.Path, "/api/bifrost/traces/"):
+		h.handleTraces(w, r)
 	default:
 		http.NotFound(w, r)
 	}
@@ -93,8 +114,9 @@ func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) {
 		"status": "ok",
 		"model":  h.config.Model,
 		"heimdall": map[string]interface{}{
-			"enabled": h.config.Enabled,
-			"stats":   stats,
+			"enabled":        h.config.Enabled,
+			"stats":          stats,
+			"event_delivery": EventDeliveryStatsAll(),
 		},
 		"bifrost": bifrostStats,
 	})
@@ -129,39 +151,138 @@ func (h *Handler) handleEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Compress the stream for clients that accept gzip. Every event is
+	// sync-flushed, so it can be decoded as soon as it arrives.
+	w.Header().Add("Vary", "Accept-Encoding")
+	if h.config.BifrostCompression && acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := newGzipEventWriter(w, flusher)
+		defer gz.Close()
+		w, flusher = gz, gz
+	}
+
 	// Generate client ID
 	clientID := generateID()
 
-	// Register this connection with Bifrost
-	h.bifrost.RegisterClient(clientID, w, flusher)
+	// Register this connection with Bifrost.
+	// ?session=<id> groups tabs of one client; ?topics=a,b subscribes to topics.
+	userID := UserFromContext(r.Context())
+	sessionID := r.URL.Query().Get("session")
+	var topics []string
+	for _, t := range strings.Split(r.URL.Query().Get("topics"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			topics = append(topics, t)
+		}
+	}
+	h.bifrost.RegisterSession(clientID, userID, sessionID, topics, w, flusher)
 	defer h.bifrost.UnregisterClient(clientID)
 
-	// Send initial connection message
-	connMsg := BifrostMessage{
+	// Send initial connection message through Bifrost, which serializes
+	// writes to the connection
+	h.bifrost.send(BifrostMessage{
 		Type:      "connected",
 		Timestamp: time.Now().Unix(),
 		Content:   "Connected to Bifrost",
 		Data: map[string]interface{}{
 			"client_id": clientID,
+			"user_id":   userID,
+			"topics":    topics,
 		},
-	}
-	data, _ := json.Marshal(connMsg)
-	fmt.Fprintf(w, "data: %s\n\n", string(data))
-	flusher.Flush()
+	}, func(c *BifrostClient) bool { return c.ID == clientID })
 
 	// Keep connection alive until client disconnects
 	<-r.Context().Done()
 }
 
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// gzipEventWriter gzips an event stream. Flush completes the current deflate
+// block before flushing the connection.
+type gzipEventWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer
+	flusher http.Flusher
+}
+
+func newGzipEventWriter(w http.ResponseWriter, f http.Flusher) *gzipEventWriter {
+	return &gzipEventWriter{ResponseWriter: w, gz: gzip.NewWriter(w), flusher: f}
+}
+
+func (g *gzipEventWriter) Write(p []byte) (int, error) {
+	return g.gz.Write(p)
+}
+
+// Flush implements http.Flusher.
+func (g *gzipEventWriter) Flush() {
+	g.gz.Flush()
+	g.flusher.Flush()
+}
+
+// Close writes the gzip trailer.
+func (g *gzipEventWriter) Close() error {
+	return g.gz.Close()
+}
+
+// handleConfirmations lists and answers pending action confirmations.
+// GET  /api/bifrost/confirmations - pending confirmations
+// POST /api/bifrost/confirmations - {"id": "...", "approve": true}
+//
+// The approving user is taken from the request context (see WithUser).
+func (h *Handler) handleConfirmations(w http.ResponseWriter, r *http.Request) {
+	if h.bifrost == nil {
+		http.Error(w, "Bifrost not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"confirmations": h.bifrost.PendingConfirmations(),
+		})
+	case http.MethodPost:
+		var req struct {
+			ID      string `json:"id"`
+			Approve bool   `json:"approve"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		decision, err := h.bifrost.RespondConfirmation(req.ID, UserFromContext(r.Context()), req.Approve)
+		switch {
+		case errors.Is(err, ErrConfirmationNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case decision == nil:
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "status": "pending"})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "status": "decided", "decision": decision})
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // sendCancellationResponse sends a cancellation response to the client.
 // This is called when a lifecycle hook cancels the request.
-func (h *Handler) sendCancellationResponse(w http.ResponseWriter, requestID, phase, cancelledBy, reason string) {
+func (h *Handler) sendCancellationResponse(w http.ResponseWriter, requestID, userID, phase, cancelledBy, reason string) {
 	// Log the cancellation
 	log.Printf("[Bifrost] Request %s cancelled in %s by %s: %s", requestID, phase, cancelledBy, reason)
 
-	// Send notification via Bifrost if available
-	if h.bifrost != nil {
-		h.bifrost.SendNotification("warning", "Request Cancelled",
+	// Notify the requesting user's other connections (not every client)
+	if h.bifrost != nil && userID != "" {
+		h.bifrost.NotifyUser(userID, "warning", "Request Cancelled",
 			fmt.Sprintf("Request cancelled by %s: %s", cancelledBy, reason))
 	}
 
@@ -191,6 +312,38 @@ func (h *Handler) sendCancellationResponse(w http.ResponseWriter, requestID, pha
 	json.NewEncoder(w).Encode(resp)
 }
 
+// handlePersonas lists the personas clients can select with ChatRequest.Persona.
+// GET /api/bifrost/personas
+//
+// Personas stored in the graph are included after the configured ones.
+func (h *Handler) handlePersonas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	list := ListPersonas()
+	if h.database != nil {
+		rows, err := h.database.Query(r.Context(), "MATCH (p:"+PersonaLabel+") RETURN p.name AS name ORDER BY name", nil)
+		if err != nil {
+			log.Printf("[Bifrost] Failed to list graph personas: %v", err)
+		}
+		for _, row := range rows {
+			name, _ := row["name"].(string)
+			if _, ok := GetPersona(name); ok || name == "" {
+				continue
+			}
+			if p, err := resolvePersona(r.Context(), h.database, name); err == nil {
+				list = append(list, *p)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"personas": list,
+		"default":  h.config.DefaultPersona,
+	})
+}
+
 // handleChatCompletions handles OpenAI-compatible chat completion requests via Bifrost.
 // POST /api/bifrost/chat/completions
 //
@@ -230,12 +383,31 @@ func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	// Resolve the conversation's persona (config registry, then graph)
+	var persona *Persona
+	if name := req.Persona; name != "" || h.config.DefaultPersona != "" {
+		if name == "" {
+			name = h.config.DefaultPersona
+		}
+		p, err := resolvePersona(r.Context(), h.database, name)
+		if errors.Is(err, ErrPersonaNotFound) {
+			http.Error(w, fmt.Sprintf("Unknown persona: %s", name), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		persona = p
+	}
+
 	// Create PromptContext with immutable ActionPrompt
 	requestID := generateID()
 	promptCtx := &PromptContext{
 		RequestID:    requestID,
 		RequestTime:  time.Now(),
-		ActionPrompt: ActionPrompt(), // IMMUTABLE - always first
+		UserID:       UserFromContext(r.Context()),
+		ActionPrompt: actionPromptFor(persona), // IMMUTABLE - always first
+		Persona:      persona,
 		UserMessage:  userMessage,
 		Messages:     req.Messages,
 		Examples:     defaultExamples(),
@@ -243,6 +415,7 @@ func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request)
 	}
 	// Set Bifrost for notifications (fire-and-forget SSE messages)
 	promptCtx.SetBifrost(h.bifrost)
+	promptCtx.trace = h.traces.start(promptCtx)
 
 	// === Phase 1: PrePrompt hooks (optional) ===
 	// Plugins that implement PrePromptHook can modify the prompt context
@@ -250,7 +423,8 @@ func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request)
 	CallPrePromptHooks(promptCtx)
 	if promptCtx.Cancelled() {
 		log.Printf("[Bifrost] Request cancelled by %s: %s", promptCtx.CancelledBy(), promptCtx.CancelReason())
-		h.sendCancellationResponse(w, promptCtx.RequestID, "PrePrompt", promptCtx.CancelledBy(), promptCtx.CancelReason())
+		promptCtx.trace.fail("cancelled by " + promptCtx.CancelledBy() + ": " + promptCtx.CancelReason())
+		h.sendCancellationResponse(w, promptCtx.RequestID, promptCtx.UserID, "PrePrompt", promptCtx.CancelledBy(), promptCtx.CancelReason())
 		return
 	}
 
@@ -264,6 +438,7 @@ func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request)
 		budgetInfo := promptCtx.GetBudgetInfo()
 		log.Printf("[Bifrost] Token budget exceeded: %v (system: %d, user: %d, total: %d)",
 			err, budgetInfo.SystemTokens, budgetInfo.UserTokens, budgetInfo.TotalTokens)
+		promptCtx.trace.fail(err.Error())
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -276,7 +451,8 @@ func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	prompt := BuildPrompt(messages)
+	// Mask PII from user input and plugin-injected context before it reaches the SLM
+	prompt := currentRedactor().Query(BuildPrompt(messages))
 
 	// Generation params
 	params := GenerateParams{
@@ -285,10 +461,14 @@ func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request)
 		TopP:        req.TopP,
 		TopK:        40,
 		StopTokens:  []string{"<|im_end|>", "<|endoftext|>", "</s>"},
+		Grammar:     actionGrammar(persona),
 	}
 	if params.MaxTokens == 0 {
 		params.MaxTokens = h.config.MaxTokens
 	}
+	if params.Temperature == 0 && persona != nil {
+		params.Temperature = persona.Temperature
+	}
 	if params.Temperature == 0 {
 		params.Temperature = h.config.Temperature
 	}
@@ -302,6 +482,7 @@ func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request)
 		requestID: requestID,
 		database:  h.database,
 		metrics:   h.metrics,
+		trace:     promptCtx.trace,
 	}
 
 	if req.Stream {
@@ -317,6 +498,7 @@ type requestLifecycle struct {
 	requestID string
 	database  DatabaseReader
 	metrics   MetricsReader
+	trace     *Trace // nil unless tracing
 }
 
 // defaultExamples returns built-in examples for action mapping.
@@ -330,6 +512,9 @@ func defaultExamples() []PromptExample {
 		{UserSays: "database stats", ActionJSON: `{"action": "heimdall.watcher.db_stats", "params": {}}`},
 		{UserSays: "health check", ActionJSON: `{"action": "heimdall.watcher.status", "params": {}}`},
 
+		// === PIPELINES ===
+		{UserSays: "list running queries then broadcast how many", ActionJSON: `{"action": "heimdall.pipeline", "params": {"steps": [{"id": "q", "action": "heimdall.watcher.queries"}, {"action": "heimdall.watcher.broadcast", "params": {"message": "${q.data.count} queries running"}}]}}`},
+
 		// === COUNTING & STATISTICS ===
 		{UserSays: "how many nodes", ActionJSON: `{"action": "heimdall.watcher.query", "params": {"cypher": "MATCH (n) RETURN count(n) AS total_nodes"}}`},
 		{UserSays: "count all relationships", ActionJSON: `{"action": "heimdall.watcher.query", "params": {"cypher": "MATCH ()-[r]->() RETURN count(r) AS total_relationships"}}`},
@@ -358,7 +543,9 @@ func defaultExamples() []PromptExample {
 // handleNonStreamingResponse generates complete response with lifecycle hooks.
 func (h *Handler) handleNonStreamingResponse(w http.ResponseWriter, ctx context.Context, prompt string, params GenerateParams, model string, lifecycle *requestLifecycle) {
 	response, err := h.manager.Generate(ctx, prompt, params)
+	lifecycle.trace.generated(prompt, params, response, err)
 	if err != nil {
+		lifecycle.trace.fail(fmt.Sprintf("Generation error: %v", err))
 		http.Error(w, fmt.Sprintf("Generation error: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -366,19 +553,24 @@ func (h *Handler) handleNonStreamingResponse(w http.ResponseWriter, ctx context.
 	// Try to parse action command from response
 	log.Printf("[Bifrost] SLM response: %s", response)
 	finalResponse := response
-	if parsedAction := h.tryParseAction(response); parsedAction != nil {
+	var output *ActionOutput
+	parsedAction := h.parseTrustedAction(response, lifecycle.promptCtx)
+	lifecycle.trace.parsed(parsedAction)
+	if parsedAction != nil {
 		log.Printf("[Bifrost] Action detected: %s with params: %v", parsedAction.Action, parsedAction.Params)
 
 		// === Phase 4: PreExecute hooks ===
 		preExecCtx := &PreExecuteContext{
 			RequestID:   lifecycle.requestID,
 			RequestTime: lifecycle.promptCtx.RequestTime,
+			UserID:      lifecycle.promptCtx.UserID,
 			Action:      parsedAction.Action,
 			Params:      parsedAction.Params,
 			RawResponse: response,
 			PluginData:  lifecycle.promptCtx.PluginData,
 			Database:    lifecycle.database,
 			Metrics:     lifecycle.metrics,
+			trace:       lifecycle.trace,
 		}
 		// Set Bifrost for notifications (fire-and-forget SSE messages)
 		preExecCtx.SetBifrost(h.bifrost)
@@ -386,9 +578,14 @@ func (h *Handler) handleNonStreamingResponse(w http.ResponseWriter, ctx context.
 		// === Phase 4: PreExecute hooks (optional) ===
 		// Plugins that implement PreExecuteHook can validate/modify params
 		preExecResult := CallPreExecuteHooks(preExecCtx)
+		hooked := preExecResult
+		preExecResult = checkGuardrails(ctx, response, parsedAction, lifecycle.promptCtx.Persona, preExecResult)
+		lifecycle.trace.blocked(TraceGuardrails, parsedAction.Action, hooked, preExecResult)
+		preExecResult = h.confirmAction(ctx, parsedAction, preExecCtx, preExecResult, false)
 		if preExecCtx.Cancelled() {
 			log.Printf("[Bifrost] Request cancelled by %s: %s", preExecCtx.CancelledBy(), preExecCtx.CancelReason())
-			h.sendCancellationResponse(w, lifecycle.requestID, "PreExecute", preExecCtx.CancelledBy(), preExecCtx.CancelReason())
+			lifecycle.trace.fail("cancelled by " + preExecCtx.CancelledBy() + ": " + preExecCtx.CancelReason())
+			h.sendCancellationResponse(w, lifecycle.requestID, lifecycle.promptCtx.UserID, "PreExecute", preExecCtx.CancelledBy(), preExecCtx.CancelReason())
 			return
 		}
 
@@ -407,9 +604,11 @@ func (h *Handler) handleNonStreamingResponse(w http.ResponseWriter, ctx context.
 				Bifrost:     h.bifrost,
 				Database:    h.database,
 				Metrics:     h.metrics,
+				Gate:        h.stepGate(lifecycle, response),
 			}
 			result, err := ExecuteAction(parsedAction.Action, actCtx)
 			execDuration := time.Since(startTime)
+			lifecycle.trace.executed(parsedAction.Action, parsedAction.Params, result, err, execDuration)
 
 			if err != nil {
 				log.Printf("[Bifrost] Action execution failed: %v", err)
@@ -439,10 +638,14 @@ func (h *Handler) handleNonStreamingResponse(w http.ResponseWriter, ctx context.
 				PluginData: lifecycle.promptCtx.PluginData,
 			}
 			CallPostExecuteHooks(postExecCtx)
+			if result != nil {
+				output = &ActionOutput{Action: parsedAction.Action, ActionResult: result}
+			}
 		}
 	} else {
 		log.Printf("[Bifrost] No action detected in response")
 	}
+	lifecycle.trace.finish(finalResponse)
 
 	resp := ChatResponse{
 		ID:      lifecycle.requestID,
@@ -459,6 +662,7 @@ func (h *Handler) handleNonStreamingResponse(w http.ResponseWriter, ctx context.
 				FinishReason: "stop",
 			},
 		},
+		ActionResult: output,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -509,6 +713,149 @@ func (h *Handler) tryParseAction(response string) *ParsedAction {
 	return &parsed
 }
 
+// parseTrustedAction parses an action from the SLM response and drops it if
+// it was requested by untrusted database content rather than by the user
+// (see PromptContext.AddData).
+func (h *Handler) parseTrustedAction(response string, promptCtx *PromptContext) *ParsedAction {
+	parsed := h.tryParseAction(response)
+	if parsed == nil || promptCtx == nil {
+		return parsed
+	}
+	if promptCtx.ActionFromData(parsed.Action) {
+		log.Printf("[Bifrost] ⚠️ Refusing action %s: requested by database content, not the user (possible prompt injection)",
+			parsed.Action)
+		promptCtx.trace.step(TraceStep{Phase: TraceParse, Action: parsed.Action, Detail: "refused: requested by database content"})
+		return nil
+	}
+	return parsed
+}
+
+var (
+	guardrailsMu     sync.RWMutex
+	activeGuardrails *guardrails.Guardrails
+)
+
+// SetGuardrails installs the response guardrails checked before every action
+// executes. A nil value disables them.
+func SetGuardrails(g *guardrails.Guardrails) {
+	guardrailsMu.Lock()
+	defer guardrailsMu.Unlock()
+	activeGuardrails = g
+}
+
+func currentGuardrails() *guardrails.Guardrails {
+	guardrailsMu.RLock()
+	defer guardrailsMu.RUnlock()
+	return activeGuardrails
+}
+
+// checkGuardrails runs the configured guardrails after PreExecute hooks and
+// turns a violation into an abort result. Blocked attempts are reported to
+// the guardrails' OnBlock callback as security events. A persona's action
+// categories are enforced even when no guardrails are configured.
+func checkGuardrails(ctx context.Context, response string, action *ParsedAction, persona *Persona, result PreExecuteResult) PreExecuteResult {
+	if !result.Continue {
+		return result
+	}
+	g := currentGuardrails()
+	if persona != nil {
+		category := "general"
+		if def, ok := GetSubsystemManager().GetAction(action.Action); ok && def.Category != "" {
+			category = def.Category
+		}
+		if v := g.CheckScope(ctx, "persona "+persona.Name, persona.Categories, action.Action, category); v != nil {
+			log.Printf("[Bifrost] ⛔ %s", v.Error())
+			return PreExecuteResult{Continue: false, AbortMessage: "⛔ Blocked by guardrail: " + v.Reason}
+		}
+	}
+	if v := g.Check(ctx, response, action.Action, action.Params); v != nil {
+		log.Printf("[Bifrost] ⛔ %s", v.Error())
+		return PreExecuteResult{Continue: false, AbortMessage: "⛔ Blocked by guardrail: " + v.Reason}
+	}
+	return result
+}
+
+// stepGate returns the gate for actions run by the request's action, such
+// as pipeline steps. Each step passes the PreExecute hooks, guardrails and
+// confirmation a top-level action does, and keeps any params hooks change.
+func (h *Handler) stepGate(lifecycle *requestLifecycle, response string) ActionGate {
+	return func(ctx context.Context, step *ParsedAction, confirm StepConfirm) PreExecuteResult {
+		preExecCtx := &PreExecuteContext{
+			RequestID:   lifecycle.requestID,
+			RequestTime: lifecycle.promptCtx.RequestTime,
+			UserID:      lifecycle.promptCtx.UserID,
+			Action:      step.Action,
+			Params:      step.Params,
+			RawResponse: response,
+			PluginData:  lifecycle.promptCtx.PluginData,
+			Database:    lifecycle.database,
+			Metrics:     lifecycle.metrics,
+			trace:       lifecycle.trace,
+		}
+		preExecCtx.SetBifrost(h.bifrost)
+
+		result := CallPreExecuteHooks(preExecCtx)
+		hooked := result
+		checked := &ParsedAction{Action: step.Action, Params: preExecCtx.Params}
+		result = checkGuardrails(ctx, response, checked, lifecycle.promptCtx.Persona, result)
+		lifecycle.trace.blocked(TraceGuardrails, step.Action, hooked, result)
+		result = h.confirmAction(ctx, step, preExecCtx, result, confirm == ConfirmAlways)
+		if preExecCtx.Cancelled() {
+			return PreExecuteResult{Continue: false, AbortMessage: preExecCtx.CancelReason()}
+		}
+		step.Params = preExecCtx.Params
+		return result
+	}
+}
+
+// confirmAction asks the requesting user (and approvers) to confirm actions
+// registered with RiskMedium or RiskHigh, or any action when always is set.
+// A rejected or timed-out confirmation aborts the action.
+func (h *Handler) confirmAction(ctx context.Context, action *ParsedAction, preExecCtx *PreExecuteContext, result PreExecuteResult, always bool) PreExecuteResult {
+	if !result.Continue {
+		return result
+	}
+	if h.bifrost == nil {
+		if always {
+			return PreExecuteResult{Continue: false, AbortMessage: "❎ Bifrost is not enabled to confirm " + action.Action}
+		}
+		return result
+	}
+	def, ok := GetSubsystemManager().GetAction(action.Action)
+	if !ok || !(always || def.Risk.RequiresConfirmation()) {
+		return result
+	}
+	risk := def.Risk
+	if risk == "" {
+		risk = RiskLow
+	}
+	summary := fmt.Sprintf("Run %s?", action.Action)
+	if def.Description != "" {
+		summary = fmt.Sprintf("Run %s (%s)?", action.Action, def.Description)
+	}
+	decision, err := h.bifrost.Confirm(ctx, ConfirmationRequest{
+		Action:      action.Action,
+		Summary:     summary,
+		Params:      preExecCtx.Params,
+		Diff:        DiffParams(action.Params, preExecCtx.Params),
+		Risk:        risk,
+		RequestedBy: preExecCtx.UserID,
+	})
+	preExecCtx.trace.step(TraceStep{Phase: TraceConfirm, Action: action.Action, Detail: confirmationDetail(decision, err)})
+	switch {
+	case err != nil:
+		return PreExecuteResult{Continue: false, AbortMessage: "❎ Confirmation cancelled: " + err.Error()}
+	case decision.Approved:
+		return result
+	case decision.TimedOut:
+		return PreExecuteResult{Continue: false, AbortMessage: "❎ Action not confirmed in time: " + action.Action}
+	case decision.RejectedBy != "":
+		return PreExecuteResult{Continue: false, AbortMessage: "❎ Action rejected by " + decision.RejectedBy + ": " + action.Action}
+	default:
+		return PreExecuteResult{Continue: false, AbortMessage: "❎ Action rejected: " + action.Action}
+	}
+}
+
 // handleStreamingResponse uses Server-Sent Events (SSE) for streaming with lifecycle hooks.
 // SSE is standard HTTP - works with any HTTP client, no WebSocket needed.
 // After streaming completes, checks for action commands and executes them.
@@ -563,13 +910,24 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, ctx context.Con
 		flusher.Flush()
 	}
 
-	// Collect full response to check for actions
-	var fullResponse strings.Builder
-
-	// Stream tokens
-	err := h.manager.GenerateStream(ctx, prompt, params, func(token string) error {
-		fullResponse.WriteString(token)
+	// sendStatus reports a stream state transition (e.g. "running action…")
+	sendStatus := func(status StreamStatus) {
+		chunk := ChatResponse{
+			ID:       id,
+			Object:   "chat.completion.chunk",
+			Model:    model,
+			Created:  time.Now().Unix(),
+			Choices:  []ChatChoice{{Index: 0, Delta: &ChatMessage{}}},
+			Heimdall: &status,
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
 
+	// Stream tokens. Prose streams as it is generated; an action command is
+	// withheld and replaced by an action_detected transition.
+	filter := newActionStreamFilter(func(token string) error {
 		chunk := ChatResponse{
 			ID:      id,
 			Object:  "chat.completion.chunk", // OpenAI API streaming format
@@ -589,9 +947,18 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, ctx context.Con
 		fmt.Fprintf(w, "data: %s\n\n", data)
 		flusher.Flush()
 		return nil
+	}, func() error {
+		sendStatus(StreamStatus{State: StreamActionDetected})
+		return nil
 	})
+	err := h.manager.GenerateStream(ctx, prompt, params, filter.Write)
+	if err == nil {
+		_, err = filter.Close()
+	}
 
+	lifecycle.trace.generated(prompt, params, filter.Text(), err)
 	if err != nil {
+		lifecycle.trace.fail(err.Error())
 		// Send error event
 		fmt.Fprintf(w, "data: {\"error\": \"%s\"}\n\n", err.Error())
 		flusher.Flush()
@@ -599,22 +966,31 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, ctx context.Con
 	}
 
 	// Check if response contains an action command
-	response := fullResponse.String()
+	response := filter.Text()
 	log.Printf("[Bifrost] Streaming complete, checking for action: %s", response)
 
-	if parsedAction := h.tryParseAction(response); parsedAction != nil {
+	finalResponse := response
+	parsedAction := h.parseTrustedAction(response, lifecycle.promptCtx)
+	lifecycle.trace.parsed(parsedAction)
+	if parsedAction == nil && filter.mode == streamAction {
+		// Looked like an action but was invalid or refused: show what the SLM said
+		filter.emit(response)
+	}
+	if parsedAction != nil {
 		log.Printf("[Bifrost] Action detected in stream: %s", parsedAction.Action)
 
 		// === Phase 4: PreExecute hooks ===
 		preExecCtx := &PreExecuteContext{
 			RequestID:   lifecycle.requestID,
 			RequestTime: lifecycle.promptCtx.RequestTime,
+			UserID:      lifecycle.promptCtx.UserID,
 			Action:      parsedAction.Action,
 			Params:      parsedAction.Params,
 			RawResponse: response,
 			PluginData:  lifecycle.promptCtx.PluginData,
 			Database:    lifecycle.database,
 			Metrics:     lifecycle.metrics,
+			trace:       lifecycle.trace,
 		}
 		// Set Bifrost for notifications (fire-and-forget SSE messages)
 		preExecCtx.SetBifrost(h.bifrost)
@@ -622,9 +998,14 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, ctx context.Con
 		// === PreExecute hooks (optional) ===
 		// Plugins that implement PreExecuteHook can validate/modify params
 		preExecResult := CallPreExecuteHooks(preExecCtx)
+		hooked := preExecResult
+		preExecResult = checkGuardrails(ctx, response, parsedAction, lifecycle.promptCtx.Persona, preExecResult)
+		lifecycle.trace.blocked(TraceGuardrails, parsedAction.Action, hooked, preExecResult)
+		preExecResult = h.confirmAction(ctx, parsedAction, preExecCtx, preExecResult, false)
 		cancelled := preExecCtx.Cancelled()
 		if cancelled {
 			log.Printf("[Bifrost] Request cancelled by %s: %s", preExecCtx.CancelledBy(), preExecCtx.CancelReason())
+			lifecycle.trace.fail("cancelled by " + preExecCtx.CancelledBy() + ": " + preExecCtx.CancelReason())
 			// Send cancellation as SSE chunk
 			cancelChunk := ChatResponse{
 				ID:      id,
@@ -692,6 +1073,7 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, ctx context.Con
 				}
 			} else {
 				// === Phase 5: Execute action ===
+				sendStatus(StreamStatus{State: StreamRunningAction, Action: parsedAction.Action})
 				startTime := time.Now()
 				actCtx := ActionContext{
 					Context:     ctx,
@@ -700,11 +1082,15 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, ctx context.Con
 					Bifrost:     h.bifrost,
 					Database:    h.database,
 					Metrics:     h.metrics,
+					Gate:        h.stepGate(lifecycle, response),
 				}
 
 				var err error
 				result, err = ExecuteAction(parsedAction.Action, actCtx)
 				execDuration = time.Since(startTime)
+				lifecycle.trace.executed(parsedAction.Action, parsedAction.Params, result, err, execDuration)
+				success := err == nil && result != nil && result.Success
+				sendStatus(StreamStatus{State: StreamActionComplete, Action: parsedAction.Action, Success: &success})
 
 				if err != nil {
 					log.Printf("[Bifrost] Action execution failed: %v", err)
@@ -785,11 +1171,16 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, ctx context.Con
 					},
 				},
 			}
+			if result != nil {
+				resultChunk.ActionResult = &ActionOutput{Action: parsedAction.Action, ActionResult: result}
+			}
 			data, _ := json.Marshal(resultChunk)
 			fmt.Fprintf(w, "data: %s\n\n", data)
 			flusher.Flush()
+			finalResponse = strings.TrimSpace(actionResponse)
 		}
 	}
+	lifecycle.trace.finish(finalResponse)
 
 	// Send final chunk with finish_reason (OpenAI format)
 	doneChunk := ChatResponse{
@@ -0,0 +1,348 @@
+package heimdall
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall/prompts"
+)
+
+// TaskType names a category of Heimdall work. Config.ModelRoutes picks the
+// model for each task; tasks without a route use Config.Model.
+type TaskType string
+
+const (
+	TaskChat      TaskType = "chat"      // Bifrost chat completions
+	TaskQC        TaskType = "qc"        // Auto-TLP batch review
+	TaskSummarize TaskType = "summarize" // Agent memory consolidation
+	TaskPlugin    TaskType = "plugin"    // Generation requested by plugins
+	TaskEmbedding TaskType = "embedding" // Retrieval embeddings, served by the embedding subsystem
+)
+
+// taskModelTypes gives the registry type of a model routed to each task.
+var taskModelTypes = map[TaskType]ModelType{
+	TaskChat:      ModelTypeReasoning,
+	TaskQC:        ModelTypeClassification,
+	TaskSummarize: ModelTypeReasoning,
+	TaskPlugin:    ModelTypeReasoning,
+	TaskEmbedding: ModelTypeEmbedding,
+}
+
+// ParseModelRoutes parses "task=model" entries, e.g. "qc=qwen2.5-0.5b-instruct".
+func ParseModelRoutes(entries []string) (map[TaskType]string, error) {
+	routes := make(map[TaskType]string, len(entries))
+	for _, entry := range entries {
+		task, model, ok := strings.Cut(entry, "=")
+		task, model = strings.TrimSpace(task), strings.TrimSpace(model)
+		if !ok || model == "" {
+			return nil, fmt.Errorf("invalid model route %q: expected task=model", entry)
+		}
+		if _, known := taskModelTypes[TaskType(task)]; !known {
+			return nil, fmt.Errorf("invalid model route %q: unknown task %q", entry, task)
+		}
+		routes[TaskType(task)] = model
+	}
+	return routes, nil
+}
+
+// registeredModel is a model registry entry. generator is nil for models
+// loaded by another subsystem, such as the embedder.
+type registeredModel struct {
+	info      ModelInfo
+	generator Generator
+}
+
+// taskStats aggregates the requests of one task.
+type taskStats struct {
+	requests     int64
+	errors       int64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+	judged       int64
+	accepted     int64
+}
+
+// TaskStats reports the requests of one task and the model serving it.
+type TaskStats struct {
+	Task         TaskType `json:"task"`
+	Model        string   `json:"model"`
+	Requests     int64    `json:"requests"`
+	Errors       int64    `json:"errors"`
+	AvgLatencyMs float64  `json:"avg_latency_ms"`
+	MaxLatencyMs float64  `json:"max_latency_ms"`
+	Judged       int64    `json:"judged"`   // Outputs checked with RecordOutcome
+	Accuracy     float64  `json:"accuracy"` // Accepted share of judged outputs (0 if none)
+}
+
+// GenerateFor produces a response with the model routed to task.
+func (m *Manager) GenerateFor(ctx context.Context, task TaskType, prompt string, params GenerateParams) (string, error) {
+	gen, err := m.startTask(task)
+	if err != nil {
+		return "", err
+	}
+	params = m.constrain(params)
+	start := time.Now()
+	result, err := gen.Generate(ctx, prompt, params)
+	m.finishTask(task, time.Since(start), err)
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// GenerateStreamFor produces tokens via callback with the model routed to task.
+func (m *Manager) GenerateStreamFor(ctx context.Context, task TaskType, prompt string, params GenerateParams, callback func(token string) error) error {
+	gen, err := m.startTask(task)
+	if err != nil {
+		return err
+	}
+	params = m.constrain(params)
+	start := time.Now()
+	err = gen.GenerateStream(ctx, prompt, params, callback)
+	m.finishTask(task, time.Since(start), err)
+	return err
+}
+
+// constrain drops params.Grammar unless Config.JSONGrammar is set.
+func (m *Manager) constrain(params GenerateParams) GenerateParams {
+	if !m.config.JSONGrammar {
+		params.Grammar = ""
+	}
+	return params
+}
+
+// startTask returns the generator for task and counts the request.
+func (m *Manager) startTask(task TaskType) (Generator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, fmt.Errorf("manager is closed")
+	}
+	gen, name := m.generatorLocked(task)
+	if gen == nil {
+		return nil, fmt.Errorf("no generator loaded")
+	}
+	now := time.Now()
+	m.requestCount++
+	m.lastUsed = now
+	if rm := m.models[name]; rm != nil {
+		rm.info.LastUsed = now
+	}
+	return gen, nil
+}
+
+// generatorLocked resolves the generator and model name for task, falling
+// back to the default model. m.mu must be held.
+func (m *Manager) generatorLocked(task TaskType) (Generator, string) {
+	if name, ok := m.routes[task]; ok {
+		if rm := m.models[name]; rm != nil && rm.generator != nil {
+			return rm.generator, name
+		}
+	}
+	return m.generator, m.config.Model
+}
+
+// finishTask records a request started by startTask.
+func (m *Manager) finishTask(task TaskType, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.errorCount++
+	}
+	m.observeLocked(task, elapsed, err)
+}
+
+// ObserveTask records one request of task served outside the manager, by a
+// subsystem that runs the routed model itself (like the embedder).
+func (m *Manager) ObserveTask(task TaskType, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observeLocked(task, elapsed, err)
+}
+
+// observeLocked adds a request to the stats of task. m.mu must be held.
+func (m *Manager) observeLocked(task TaskType, elapsed time.Duration, err error) {
+	s := m.taskLocked(task)
+	s.requests++
+	s.totalLatency += elapsed
+	if elapsed > s.maxLatency {
+		s.maxLatency = elapsed
+	}
+	if err != nil {
+		s.errors++
+	}
+}
+
+// RecordOutcome records whether an output of task was usable, e.g. whether
+// a QC review parsed as a decision. It feeds TaskStats.Accuracy.
+func (m *Manager) RecordOutcome(task TaskType, accepted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.taskLocked(task)
+	s.judged++
+	if accepted {
+		s.accepted++
+	}
+}
+
+// taskLocked returns the stats of task, creating them. m.mu must be held.
+func (m *Manager) taskLocked(task TaskType) *taskStats {
+	if m.tasks == nil {
+		m.tasks = make(map[TaskType]*taskStats)
+	}
+	s, ok := m.tasks[task]
+	if !ok {
+		s = &taskStats{}
+		m.tasks[task] = s
+	}
+	return s
+}
+
+// ForTask returns a Generator that serves task, for callers that take a
+// Generator (such as a LiveHeimdallInvoker). Closing it does nothing.
+func (m *Manager) ForTask(task TaskType) Generator {
+	return taskGenerator{m: m, task: task}
+}
+
+type taskGenerator struct {
+	m    *Manager
+	task TaskType
+}
+
+func (g taskGenerator) Generate(ctx context.Context, prompt string, params GenerateParams) (string, error) {
+	return g.m.GenerateFor(ctx, g.task, prompt, params)
+}
+
+func (g taskGenerator) GenerateStream(ctx context.Context, prompt string, params GenerateParams, callback func(token string) error) error {
+	return g.m.GenerateStreamFor(ctx, g.task, prompt, params, callback)
+}
+
+func (g taskGenerator) ModelPath() string {
+	g.m.mu.RLock()
+	defer g.m.mu.RUnlock()
+	if name, ok := g.m.routes[g.task]; ok {
+		if rm := g.m.models[name]; rm != nil && rm.generator != nil {
+			return rm.info.Path
+		}
+	}
+	return g.m.modelPath
+}
+
+func (g taskGenerator) Close() error { return nil }
+
+// ModelFor returns the model name routed to task and whether a route exists.
+func (m *Manager) ModelFor(task TaskType) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	name, ok := m.routes[task]
+	return name, ok
+}
+
+// RegisterModel adds a model loaded by another subsystem to the registry
+// and routes tasks to it.
+func (m *Manager) RegisterModel(info ModelInfo, tasks ...TaskType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.models == nil {
+		m.models = make(map[string]*registeredModel)
+	}
+	if m.routes == nil {
+		m.routes = make(map[TaskType]string)
+	}
+	if rm, ok := m.models[info.Name]; ok && rm.generator != nil {
+		return // Already served by this manager
+	}
+	m.models[info.Name] = &registeredModel{info: info}
+	for _, task := range tasks {
+		m.routes[task] = info.Name
+	}
+}
+
+// Models lists the model registry, sorted by name.
+func (m *Manager) Models() []ModelInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.modelsLocked()
+}
+
+// modelsLocked lists the model registry. m.mu must be held.
+func (m *Manager) modelsLocked() []ModelInfo {
+	models := make([]ModelInfo, 0, len(m.models))
+	for _, rm := range m.models {
+		models = append(models, rm.info)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+	return models
+}
+
+// TaskStats returns per-task request metrics, sorted by task.
+func (m *Manager) TaskStats() []TaskStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.taskStatsLocked()
+}
+
+// taskStatsLocked builds TaskStats for every task with a route or requests.
+// m.mu must be held.
+func (m *Manager) taskStatsLocked() []TaskStats {
+	seen := make(map[TaskType]bool, len(m.tasks)+len(m.routes))
+	for task := range m.tasks {
+		seen[task] = true
+	}
+	for task := range m.routes {
+		seen[task] = true
+	}
+
+	result := make([]TaskStats, 0, len(seen))
+	for task := range seen {
+		ts := TaskStats{Task: task, Model: m.config.Model}
+		if name, ok := m.routes[task]; ok {
+			ts.Model = name
+		}
+		if s := m.tasks[task]; s != nil {
+			ts.Requests = s.requests
+			ts.Errors = s.errors
+			ts.Judged = s.judged
+			if s.requests > 0 {
+				ts.AvgLatencyMs = durationMillis(s.totalLatency) / float64(s.requests)
+			}
+			ts.MaxLatencyMs = durationMillis(s.maxLatency)
+			if s.judged > 0 {
+				ts.Accuracy = float64(s.accepted) / float64(s.judged)
+			}
+		}
+		result = append(result, ts)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Task < result[j].Task })
+	return result
+}
+
+// SetPrompts sets the prompt registry of Heimdall's autonomous tasks, so
+// Stats reports the metrics of its prompt versions.
+func (m *Manager) SetPrompts(reg *prompts.Registry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prompts = reg
+}
+
+// Prompts returns the prompt registry set with SetPrompts, or nil.
+func (m *Manager) Prompts() *prompts.Registry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.prompts
+}
+
+// promptStatsLocked returns the prompt version metrics. m.mu must be held.
+func (m *Manager) promptStatsLocked() []prompts.VersionStats {
+	if m.prompts == nil {
+		return nil
+	}
+	return m.prompts.Stats()
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,170 @@
+package heimdall
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall/prompts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModelRoutes(t *testing.T) {
+	routes, err := ParseModelRoutes([]string{"qc=tiny", " chat = large ", "embedding=bge-m3"})
+	require.NoError(t, err)
+	assert.Equal(t, map[TaskType]string{TaskQC: "tiny", TaskChat: "large", TaskEmbedding: "bge-m3"}, routes)
+
+	_, err = ParseModelRoutes([]string{"qc"})
+	assert.ErrorContains(t, err, "expected task=model")
+	_, err = ParseModelRoutes([]string{"translate=tiny"})
+	assert.ErrorContains(t, err, "unknown task")
+}
+
+func TestNewManager_ModelRoutes(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"large", "tiny"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".gguf"), []byte("fake model data"), 0644))
+	}
+
+	loaded := map[string]*MockGenerator{}
+	origLoader := SetGeneratorLoader(func(path string, gpuLayers, contextSize, batchSize int) (Generator, error) {
+		if filepath.Base(path) == "missing.gguf" {
+			return nil, errors.New("no such model")
+		}
+		gen := NewMockGenerator(path)
+		loaded[filepath.Base(path)] = gen
+		return gen, nil
+	})
+	defer SetGeneratorLoader(origLoader)
+
+	manager, err := NewManager(Config{
+		Enabled:   true,
+		ModelsDir: dir,
+		Model:     "large",
+		ModelRoutes: map[TaskType]string{
+			TaskQC:        "tiny",
+			TaskSummarize: "tiny",
+			TaskPlugin:    "missing",
+			TaskEmbedding: "bge-m3",
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, loaded, 2, "each model is loaded once")
+
+	ctx := context.Background()
+	_, err = manager.GenerateFor(ctx, TaskQC, "review", DefaultGenerateParams())
+	require.NoError(t, err)
+	_, err = manager.GenerateFor(ctx, TaskSummarize, "summarize", DefaultGenerateParams())
+	require.NoError(t, err)
+	_, err = manager.Generate(ctx, "hello", DefaultGenerateParams())
+	require.NoError(t, err)
+	_, err = manager.ForTask(TaskPlugin).Generate(ctx, "plugin", DefaultGenerateParams())
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), loaded["tiny.gguf"].GetGenerateCount())
+	assert.Equal(t, int64(2), loaded["large.gguf"].GetGenerateCount(), "chat and the unloadable plugin route use the default model")
+	assert.Equal(t, filepath.Join(dir, "tiny.gguf"), manager.ForTask(TaskQC).ModelPath())
+
+	models := manager.Models()
+	require.Len(t, models, 3)
+	assert.Equal(t, "bge-m3", models[0].Name)
+	assert.Equal(t, ModelTypeEmbedding, models[0].Type)
+	assert.False(t, models[0].Loaded)
+	assert.Equal(t, "large", models[1].Name)
+	assert.True(t, models[1].Loaded)
+	assert.Equal(t, "tiny", models[2].Name)
+	assert.Equal(t, ModelTypeClassification, models[2].Type)
+
+	name, ok := manager.ModelFor(TaskEmbedding)
+	assert.True(t, ok)
+	assert.Equal(t, "bge-m3", name)
+	_, ok = manager.ModelFor(TaskPlugin)
+	assert.False(t, ok)
+
+	require.NoError(t, manager.Close())
+	assert.True(t, loaded["tiny.gguf"].closed)
+	assert.True(t, loaded["large.gguf"].closed)
+}
+
+func TestManager_TaskStats(t *testing.T) {
+	mockGen := NewMockGenerator("/test/model.gguf")
+	manager := newTestManager(mockGen)
+	ctx := context.Background()
+
+	_, err := manager.GenerateFor(ctx, TaskQC, "review", DefaultGenerateParams())
+	require.NoError(t, err)
+	mockGen.generateFunc = func(ctx context.Context, prompt string, params GenerateParams) (string, error) {
+		return "", errors.New("boom")
+	}
+	_, err = manager.GenerateFor(ctx, TaskQC, "review", DefaultGenerateParams())
+	require.Error(t, err)
+
+	manager.RecordOutcome(TaskQC, true)
+	manager.RecordOutcome(TaskQC, true)
+	manager.RecordOutcome(TaskQC, false)
+	manager.RegisterModel(ModelInfo{Name: "bge-m3", Type: ModelTypeEmbedding, Loaded: true}, TaskEmbedding)
+	manager.ObserveTask(TaskEmbedding, 5*time.Millisecond, nil)
+
+	stats := manager.TaskStats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, TaskEmbedding, stats[0].Task)
+	assert.Equal(t, "bge-m3", stats[0].Model)
+	assert.Equal(t, int64(1), stats[0].Requests)
+	assert.Equal(t, 5.0, stats[0].MaxLatencyMs)
+
+	qc := stats[1]
+	assert.Equal(t, TaskQC, qc.Task)
+	assert.Equal(t, "test-model", qc.Model)
+	assert.Equal(t, int64(2), qc.Requests)
+	assert.Equal(t, int64(1), qc.Errors)
+	assert.Equal(t, int64(3), qc.Judged)
+	assert.InDelta(t, 2.0/3.0, qc.Accuracy, 1e-9)
+
+	all := manager.Stats()
+	assert.Equal(t, int64(2), all.RequestCount)
+	assert.Equal(t, int64(1), all.ErrorCount)
+	assert.Len(t, all.Tasks, 2)
+	assert.Len(t, all.Models, 1)
+}
+
+func TestManager_PromptStats(t *testing.T) {
+	manager := newTestManager(NewMockGenerator("/test/model.gguf"))
+	assert.Nil(t, manager.Prompts())
+	assert.Empty(t, manager.Stats().Prompts)
+
+	reg := prompts.NewRegistry()
+	require.NoError(t, reg.Register(prompts.Template{Name: "qc", Version: "v1", Text: "review"}))
+	reg.Record("qc", "v1", prompts.Outcome{Parsed: true, Judged: 2, Approved: 1})
+	manager.SetPrompts(reg)
+
+	assert.Same(t, reg, manager.Prompts())
+	stats := manager.Stats().Prompts
+	require.Len(t, stats, 1)
+	assert.Equal(t, "v1", stats[0].Version)
+	assert.Equal(t, 0.5, stats[0].ApprovalRate)
+}
+
+func TestManager_JSONGrammar(t *testing.T) {
+	var got []string
+	mockGen := NewMockGenerator("/test/model.gguf")
+	mockGen.generateFunc = func(ctx context.Context, prompt string, params GenerateParams) (string, error) {
+		got = append(got, params.Grammar)
+		return "{}", nil
+	}
+	manager := newTestManager(mockGen)
+	ctx := context.Background()
+	params := DefaultGenerateParams()
+	params.Grammar = "root ::= \"{}\""
+
+	_, err := manager.GenerateFor(ctx, TaskQC, "review", params)
+	require.NoError(t, err)
+	manager.config.JSONGrammar = true
+	_, err = manager.GenerateFor(ctx, TaskQC, "review", params)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"", params.Grammar}, got, "the grammar is only passed on with JSONGrammar")
+}
@@ -0,0 +1,170 @@
+package heimdall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PersonaLabel is the node label for personas stored in the graph.
+//
+//	CREATE (:HeimdallPersona {name: "analyst", system_prompt: "...",
+//	        categories: ["monitoring"], temperature: 0.2})
+const PersonaLabel = "HeimdallPersona"
+
+// ErrPersonaNotFound is returned when a chat selects an unknown persona.
+var ErrPersonaNotFound = errors.New("persona not found")
+
+// Persona is a named Heimdall configuration selected per conversation.
+//
+// SystemPrompt is added to the system prompt after the action list, so it
+// cannot hide or rename actions. Categories limits which action categories
+// the SLM is shown and may execute; the guardrails layer enforces it. An
+// empty Categories list allows every category. A zero Temperature keeps the
+// request or server default.
+type Persona struct {
+	Name         string   `json:"name" yaml:"name"`
+	Description  string   `json:"description,omitempty" yaml:"description"`
+	SystemPrompt string   `json:"system_prompt,omitempty" yaml:"system_prompt"`
+	Categories   []string `json:"categories,omitempty" yaml:"categories"`
+	Temperature  float32  `json:"temperature,omitempty" yaml:"temperature"`
+}
+
+// allowsCategory reports whether actions in category are in scope.
+func (p *Persona) allowsCategory(category string) bool {
+	if p == nil || len(p.Categories) == 0 {
+		return true
+	}
+	for _, c := range p.Categories {
+		if c == "*" || strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	personasMu sync.RWMutex
+	personas   = make(map[string]Persona)
+)
+
+// RegisterPersona adds or replaces a persona in the config registry.
+func RegisterPersona(p Persona) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("persona name is required")
+	}
+	personasMu.Lock()
+	defer personasMu.Unlock()
+	personas[p.Name] = p
+	return nil
+}
+
+// UnregisterPersona removes a persona from the config registry.
+func UnregisterPersona(name string) {
+	personasMu.Lock()
+	defer personasMu.Unlock()
+	delete(personas, name)
+}
+
+// GetPersona returns a persona from the config registry.
+func GetPersona(name string) (Persona, bool) {
+	personasMu.RLock()
+	defer personasMu.RUnlock()
+	p, ok := personas[name]
+	return p, ok
+}
+
+// ListPersonas returns registered personas sorted by name.
+func ListPersonas() []Persona {
+	personasMu.RLock()
+	defer personasMu.RUnlock()
+	result := make([]Persona, 0, len(personas))
+	for _, p := range personas {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// LoadPersonasFile registers the personas listed in a YAML or JSON file:
+//
+//	personas:
+//	  - name: analyst
+//	    system_prompt: Answer with read-only queries and short summaries.
+//	    categories: [monitoring]
+//	    temperature: 0.2
+func LoadPersonasFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var file struct {
+		Personas []Persona `json:"personas" yaml:"personas"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("parse personas file %s: %w", path, err)
+	}
+	for _, p := range file.Personas {
+		if err := RegisterPersona(p); err != nil {
+			return 0, fmt.Errorf("personas file %s: %w", path, err)
+		}
+	}
+	return len(file.Personas), nil
+}
+
+// resolvePersona looks a persona up in the config registry, then in the
+// graph. Graph personas take effect without a restart.
+func resolvePersona(ctx context.Context, db DatabaseReader, name string) (*Persona, error) {
+	if p, ok := GetPersona(name); ok {
+		return &p, nil
+	}
+	if db == nil {
+		return nil, ErrPersonaNotFound
+	}
+	rows, err := db.Query(ctx, "MATCH (p:"+PersonaLabel+" {name: $name}) "+
+		"RETURN p.description AS description, p.system_prompt AS system_prompt, "+
+		"p.categories AS categories, p.temperature AS temperature LIMIT 1",
+		map[string]interface{}{"name": name})
+	if err != nil {
+		return nil, fmt.Errorf("load persona %q: %w", name, err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrPersonaNotFound
+	}
+	row := rows[0]
+	p := &Persona{Name: name}
+	p.Description, _ = row["description"].(string)
+	p.SystemPrompt, _ = row["system_prompt"].(string)
+	switch cats := row["categories"].(type) {
+	case []string:
+		p.Categories = cats
+	case []interface{}:
+		for _, c := range cats {
+			if s, ok := c.(string); ok {
+				p.Categories = append(p.Categories, s)
+			}
+		}
+	case string:
+		for _, c := range strings.Split(cats, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				p.Categories = append(p.Categories, c)
+			}
+		}
+	}
+	switch t := row["temperature"].(type) {
+	case float64:
+		p.Temperature = float32(t)
+	case float32:
+		p.Temperature = t
+	case int64:
+		p.Temperature = float32(t)
+	}
+	return p, nil
+}
@@ -0,0 +1,226 @@
+package heimdall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// personaDBReader serves :HeimdallPersona nodes from a map.
+type personaDBReader struct {
+	mockDBReader
+	nodes map[string]map[string]interface{}
+}
+
+func (m *personaDBReader) Query(ctx context.Context, cypher string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	if !strings.Contains(cypher, PersonaLabel) {
+		return m.mockDBReader.Query(ctx, cypher, params)
+	}
+	if name, ok := params["name"].(string); ok {
+		if row, ok := m.nodes[name]; ok {
+			return []map[string]interface{}{row}, nil
+		}
+		return nil, nil
+	}
+	var rows []map[string]interface{}
+	for name := range m.nodes {
+		rows = append(rows, map[string]interface{}{"name": name})
+	}
+	return rows, nil
+}
+
+func TestLoadPersonasFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "personas.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`personas:
+  - name: analyst
+    system_prompt: Answer with read-only queries.
+    categories: [monitoring]
+    temperature: 0.2
+  - name: " ops "
+`), 0o600))
+	defer UnregisterPersona("analyst")
+	defer UnregisterPersona("ops")
+
+	n, err := LoadPersonasFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	p, ok := GetPersona("analyst")
+	require.True(t, ok)
+	assert.Equal(t, []string{"monitoring"}, p.Categories)
+	assert.InDelta(t, 0.2, p.Temperature, 0.001)
+	_, ok = GetPersona("ops")
+	assert.True(t, ok, "names are trimmed")
+
+	names := []string{}
+	for _, p := range ListPersonas() {
+		names = append(names, p.Name)
+	}
+	assert.Equal(t, []string{"analyst", "ops"}, names)
+
+	require.NoError(t, os.WriteFile(path, []byte("personas:\n  - system_prompt: x\n"), 0o600))
+	_, err = LoadPersonasFile(path)
+	assert.Error(t, err)
+}
+
+func TestResolvePersona(t *testing.T) {
+	db := &personaDBReader{nodes: map[string]map[string]interface{}{
+		"support": {"system_prompt": "Be friendly.", "categories": []interface{}{"monitoring", "system"}, "temperature": 0.5},
+		"csv":     {"categories": "monitoring, configuration"},
+	}}
+	require.NoError(t, RegisterPersona(Persona{Name: "support", SystemPrompt: "From config."}))
+	defer UnregisterPersona("support")
+
+	p, err := resolvePersona(context.Background(), db, "support")
+	require.NoError(t, err)
+	assert.Equal(t, "From config.", p.SystemPrompt, "config registry wins over the graph")
+
+	UnregisterPersona("support")
+	p, err = resolvePersona(context.Background(), db, "support")
+	require.NoError(t, err)
+	assert.Equal(t, "Be friendly.", p.SystemPrompt)
+	assert.Equal(t, []string{"monitoring", "system"}, p.Categories)
+	assert.InDelta(t, 0.5, p.Temperature, 0.001)
+
+	p, err = resolvePersona(context.Background(), db, "csv")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"monitoring", "configuration"}, p.Categories)
+
+	_, err = resolvePersona(context.Background(), db, "missing")
+	assert.ErrorIs(t, err, ErrPersonaNotFound)
+	_, err = resolvePersona(context.Background(), nil, "missing")
+	assert.ErrorIs(t, err, ErrPersonaNotFound)
+}
+
+func TestHandler_ChatWithPersona(t *testing.T) {
+	executed := map[string]int{}
+	for _, a := range []ActionFunc{
+		{Name: "heimdall.test.persona_status", Description: "Persona status", Category: "monitoring"},
+		{Name: "heimdall.test.persona_drop", Description: "Persona drop", Category: "admin"},
+	} {
+		name := a.Name
+		a.Handler = func(ctx ActionContext) (*ActionResult, error) {
+			executed[name]++
+			return &ActionResult{Success: true, Message: "ran " + name}, nil
+		}
+		RegisterBuiltinAction(a)
+	}
+	defer func() {
+		m := GetSubsystemManager()
+		m.mu.Lock()
+		delete(m.actions, "heimdall.test.persona_status")
+		delete(m.actions, "heimdall.test.persona_drop")
+		m.mu.Unlock()
+	}()
+	require.NoError(t, RegisterPersona(Persona{
+		Name:         "analyst",
+		SystemPrompt: "Only report on health.",
+		Categories:   []string{"monitoring"},
+		Temperature:  0.3,
+	}))
+	defer UnregisterPersona("analyst")
+
+	var prompt string
+	var params GenerateParams
+	response := ""
+	mockGen := NewMockGenerator("/test/model.gguf")
+	mockGen.generateFunc = func(ctx context.Context, p string, gp GenerateParams) (string, error) {
+		prompt, params = p, gp
+		return response, nil
+	}
+	manager := newTestManager(mockGen)
+	handler := testHandler(manager, manager.config)
+
+	chat := func(body map[string]interface{}) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/bifrost/chat/completions", bytes.NewReader(b)))
+		return w
+	}
+	msgs := []map[string]string{{"role": "user", "content": "drop it"}}
+
+	response = `{"action": "heimdall.test.persona_drop", "params": {}}`
+	w := chat(map[string]interface{}{"messages": msgs, "persona": "analyst"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Blocked by guardrail")
+	assert.Equal(t, 0, executed["heimdall.test.persona_drop"])
+	assert.Contains(t, prompt, "Only report on health.")
+	assert.Contains(t, prompt, "heimdall.test.persona_status")
+	assert.NotContains(t, prompt, "heimdall.test.persona_drop", "out-of-scope actions are not listed")
+	assert.InDelta(t, 0.3, params.Temperature, 0.001)
+
+	response = `{"action": "heimdall.test.persona_status", "params": {}}`
+	w = chat(map[string]interface{}{"messages": msgs, "persona": "analyst", "temperature": 0.7})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, executed["heimdall.test.persona_status"])
+	assert.InDelta(t, 0.7, params.Temperature, 0.001, "request temperature overrides the persona")
+
+	// Without a persona every action is in scope
+	response = `{"action": "heimdall.test.persona_drop", "params": {}}`
+	w = chat(map[string]interface{}{"messages": msgs})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, executed["heimdall.test.persona_drop"])
+	assert.NotContains(t, prompt, "PERSONA:")
+
+	handler.database = &personaDBReader{}
+	w = chat(map[string]interface{}{"messages": msgs, "persona": "nobody"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_DefaultPersona(t *testing.T) {
+	require.NoError(t, RegisterPersona(Persona{Name: "terse", SystemPrompt: "Be terse."}))
+	defer UnregisterPersona("terse")
+
+	var prompt string
+	mockGen := NewMockGenerator("/test/model.gguf")
+	mockGen.generateFunc = func(ctx context.Context, p string, gp GenerateParams) (string, error) {
+		prompt = p
+		return "ok", nil
+	}
+	manager := newTestManager(mockGen)
+	cfg := manager.config
+	cfg.DefaultPersona = "terse"
+	handler := testHandler(manager, cfg)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/bifrost/chat/completions",
+		strings.NewReader(`{"messages": [{"role": "user", "content": "hi"}]}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, prompt, "Be terse.")
+}
+
+func TestHandler_Personas(t *testing.T) {
+	require.NoError(t, RegisterPersona(Persona{Name: "analyst", Categories: []string{"monitoring"}}))
+	defer UnregisterPersona("analyst")
+
+	mockGen := NewMockGenerator("/test/model.gguf")
+	manager := newTestManager(mockGen)
+	handler := NewHandler(manager, manager.config, &personaDBReader{nodes: map[string]map[string]interface{}{
+		"support": {"system_prompt": "Be friendly."},
+	}}, &mockMetricsReader{})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/bifrost/personas", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Personas []Persona `json:"personas"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Personas, 2)
+	assert.Equal(t, "analyst", resp.Personas[0].Name)
+	assert.Equal(t, "support", resp.Personas[1].Name)
+	assert.Equal(t, "Be friendly.", resp.Personas[1].SystemPrompt)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/bifrost/personas", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
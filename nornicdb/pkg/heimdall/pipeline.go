@@ -0,0 +1,505 @@
+// Package heimdall - action pipelines: chains of actions where each step
+// can use the results of the steps before it.
+//
+// A pipeline runs as the built-in action heimdall.pipeline, so the SLM
+// emits one like any other action, and rules and the invoker can run one
+// by name:
+//
+//	{"action": "heimdall.pipeline", "params": {"steps": [
+//	    {"id": "queries", "action": "heimdall.watcher.queries"},
+//	    {"action": "heimdall.watcher.broadcast",
+//	     "when": "${queries.data.count}",
+//	     "params": {"message": "${queries.data.count} queries running"},
+//	     "confirm": "always"}
+//	]}}
+//
+// Mapping expressions ${step.path} refer to an earlier step by id (or to
+// the previous step as "prev"), then into its result: success, message,
+// data.key, data.list.0, data.list.length. A param that is exactly one
+// expression gets the value with its type; expressions inside a longer
+// string are formatted into it.
+//
+// Every step passes through ActionContext.Gate before it runs. In chat the
+// gate applies the same PreExecute hooks, guardrails and confirmations as a
+// top-level action; elsewhere, risky steps are confirmed through Bifrost.
+package heimdall
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PipelineAction runs the pipeline given as its params.
+const PipelineAction = "heimdall.pipeline"
+
+// MaxPipelineSteps bounds the length of a pipeline.
+const MaxPipelineSteps = 20
+
+// StepConfirm is a step's confirmation policy.
+type StepConfirm string
+
+const (
+	ConfirmByRisk StepConfirm = "risk"   // Confirm RiskMedium and RiskHigh actions (default)
+	ConfirmAlways StepConfirm = "always" // Confirm even low-risk actions
+)
+
+// StepOnError says what happens when a step fails or is refused.
+type StepOnError string
+
+const (
+	StepStop     StepOnError = "stop"     // End the pipeline (default)
+	StepContinue StepOnError = "continue" // Run the remaining steps
+)
+
+// Step outcomes.
+const (
+	StepOK      = "ok"
+	StepFailed  = "failed"
+	StepRefused = "refused" // Blocked by a hook, guardrail or confirmation
+	StepSkipped = "skipped" // Its when expression was false
+)
+
+// Pipeline is a chain of actions.
+type Pipeline struct {
+	Steps []PipelineStep `json:"steps"`
+}
+
+// PipelineStep is one action of a pipeline.
+type PipelineStep struct {
+	ID      string                 `json:"id,omitempty"` // Default "step1", "step2", ...
+	Action  string                 `json:"action"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+	When    string                 `json:"when,omitempty"` // Run only if this expression is truthy
+	Confirm StepConfirm            `json:"confirm,omitempty"`
+	OnError StepOnError            `json:"on_error,omitempty"`
+}
+
+// PipelineStepResult is the outcome of one step.
+type PipelineStepResult struct {
+	ID         string                 `json:"id"`
+	Action     string                 `json:"action"`
+	Status     string                 `json:"status"`
+	Message    string                 `json:"message,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+}
+
+// ActionGate approves an action nested in another, such as a pipeline
+// step, before it runs. It may replace step.Params; a result with Continue
+// false refuses the step.
+type ActionGate func(ctx context.Context, step *ParsedAction, confirm StepConfirm) PreExecuteResult
+
+var (
+	stepIDPattern     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	expressionPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+)
+
+// ParsePipeline reads a pipeline from action params, assigns default step
+// ids and validates it.
+func ParsePipeline(params map[string]interface{}) (*Pipeline, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipeline: %w", err)
+	}
+	var p Pipeline
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("invalid pipeline: %w", err)
+	}
+	for i := range p.Steps {
+		if p.Steps[i].ID == "" {
+			p.Steps[i].ID = fmt.Sprintf("step%d", i+1)
+		}
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate reports whether the pipeline can run: known actions, unique
+// ids, known policies and expressions that refer to earlier steps.
+func (p *Pipeline) Validate() error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("pipeline has no steps")
+	}
+	if len(p.Steps) > MaxPipelineSteps {
+		return fmt.Errorf("pipeline has %d steps, at most %d are allowed", len(p.Steps), MaxPipelineSteps)
+	}
+	seen := make(map[string]bool, len(p.Steps))
+	for i, s := range p.Steps {
+		where := fmt.Sprintf("step %d (%s)", i+1, s.ID)
+		switch {
+		case !stepIDPattern.MatchString(s.ID) || s.ID == "prev":
+			return fmt.Errorf("%s: invalid id", where)
+		case seen[s.ID]:
+			return fmt.Errorf("%s: duplicate id", where)
+		case s.Action == "":
+			return fmt.Errorf("%s: action is required", where)
+		case s.Action == PipelineAction:
+			return fmt.Errorf("%s: pipelines cannot be nested", where)
+		case s.Confirm != "" && s.Confirm != ConfirmByRisk && s.Confirm != ConfirmAlways:
+			return fmt.Errorf("%s: unknown confirm policy %q", where, s.Confirm)
+		case s.OnError != "" && s.OnError != StepStop && s.OnError != StepContinue:
+			return fmt.Errorf("%s: unknown on_error policy %q", where, s.OnError)
+		}
+		if _, ok := GetHeimdallAction(s.Action); !ok {
+			return fmt.Errorf("%s: unknown action %s", where, s.Action)
+		}
+		for _, ref := range references(s.When, s.Params) {
+			if !seen[ref] && !(ref == "prev" && i > 0) {
+				return fmt.Errorf("%s: %q does not refer to an earlier step", where, ref)
+			}
+		}
+		seen[s.ID] = true
+	}
+	return nil
+}
+
+// references returns the step ids the expressions in values refer to.
+func references(values ...interface{}) []string {
+	var refs []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			for _, m := range expressionPattern.FindAllStringSubmatch(v, -1) {
+				refs = append(refs, strings.SplitN(strings.TrimSpace(m[1]), ".", 2)[0])
+			}
+		case map[string]interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	for _, v := range values {
+		walk(v)
+	}
+	return refs
+}
+
+// RunPipeline runs the steps of p in order with the database, metrics and
+// Bifrost of actx. Steps pass through actx.Gate, or when it is nil, are
+// confirmed through actx.Bifrost as their policy and risk require.
+func RunPipeline(actx ActionContext, p *Pipeline) *ActionResult {
+	gate := actx.Gate
+	if gate == nil {
+		gate = confirmationGate(actx.Bifrost)
+	}
+	ctx := actx.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	outputs := make(map[string]interface{}, len(p.Steps))
+	var results []PipelineStepResult
+	var stopped *PipelineStepResult
+	for i, step := range p.Steps {
+		res := PipelineStepResult{ID: step.ID, Action: step.Action}
+		start := time.Now()
+		if i > 0 {
+			outputs["prev"] = outputs[p.Steps[i-1].ID]
+		}
+
+		run := true
+		if step.When != "" {
+			v, err := resolveExpression(step.When, outputs)
+			if err != nil {
+				res.Status, res.Message = StepFailed, "when: "+err.Error()
+			} else if !truthy(v) {
+				res.Status, run = StepSkipped, false
+			}
+		}
+		if res.Status == "" && run {
+			runStep(ctx, actx, gate, step, outputs, &res)
+		}
+		res.DurationMs = time.Since(start).Milliseconds()
+		outputs[step.ID] = stepOutput(res)
+		results = append(results, res)
+
+		if (res.Status == StepFailed || res.Status == StepRefused) && step.OnError != StepContinue {
+			stopped = &results[len(results)-1]
+			break
+		}
+	}
+
+	var lines []string
+	for _, r := range results {
+		if r.Message != "" {
+			lines = append(lines, fmt.Sprintf("%s: %s", r.ID, r.Message))
+		}
+	}
+	message := fmt.Sprintf("Pipeline completed %d of %d steps", len(results), len(p.Steps))
+	if stopped != nil {
+		message = fmt.Sprintf("Pipeline stopped at step %s (%s)", stopped.ID, stopped.Status)
+	}
+	if len(lines) > 0 {
+		message += "\n" + strings.Join(lines, "\n")
+	}
+	return &ActionResult{
+		Success: stopped == nil,
+		Message: message,
+		Data:    map[string]interface{}{"steps": results},
+	}
+}
+
+// runStep resolves the params of step, passes it through gate and runs it.
+func runStep(ctx context.Context, actx ActionContext, gate ActionGate, step PipelineStep, outputs map[string]interface{}, res *PipelineStepResult) {
+	if ctx.Err() != nil {
+		res.Status, res.Message = StepFailed, ctx.Err().Error()
+		return
+	}
+	params, err := resolveParams(step.Params, outputs)
+	if err != nil {
+		res.Status, res.Message = StepFailed, err.Error()
+		return
+	}
+	parsed := &ParsedAction{Action: step.Action, Params: params}
+	if r := gate(ctx, parsed, step.Confirm); !r.Continue {
+		res.Status, res.Message = StepRefused, r.AbortMessage
+		return
+	}
+
+	stepCtx := actx
+	stepCtx.Context = ctx
+	stepCtx.Params = parsed.Params
+	result, err := ExecuteAction(step.Action, stepCtx)
+	switch {
+	case err != nil:
+		res.Status, res.Message = StepFailed, err.Error()
+	case result == nil:
+		res.Status = StepOK
+	default:
+		res.Status, res.Message, res.Data = StepFailed, result.Message, result.Data
+		if result.Success {
+			res.Status = StepOK
+		}
+	}
+}
+
+// stepOutput is what expressions see of a step: its result in JSON form.
+func stepOutput(res PipelineStepResult) interface{} {
+	out := map[string]interface{}{
+		"success": res.Status == StepOK,
+		"status":  res.Status,
+		"message": res.Message,
+		"data":    map[string]interface{}{},
+	}
+	if res.Data != nil {
+		if raw, err := json.Marshal(res.Data); err == nil {
+			var data map[string]interface{}
+			if json.Unmarshal(raw, &data) == nil {
+				out["data"] = data
+			}
+		}
+	}
+	return out
+}
+
+// resolveParams replaces the expressions in params.
+func resolveParams(params map[string]interface{}, outputs map[string]interface{}) (map[string]interface{}, error) {
+	resolved, err := resolveValue(params, outputs)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := resolved.(map[string]interface{})
+	if m == nil {
+		m = make(map[string]interface{})
+	}
+	return m, nil
+}
+
+func resolveValue(v interface{}, outputs map[string]interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return resolveString(v, outputs)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			r, err := resolveValue(e, outputs)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			r, err := resolveValue(e, outputs)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// resolveString resolves a string with expressions: a string that is a
+// single expression becomes its value, others are formatted.
+func resolveString(s string, outputs map[string]interface{}) (interface{}, error) {
+	matches := expressionPattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return resolveExpression(s, outputs)
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		v, err := lookupPath(s[m[2]:m[3]], outputs)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(formatValue(v))
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// resolveExpression returns the value of a single ${...} expression.
+func resolveExpression(expr string, outputs map[string]interface{}) (interface{}, error) {
+	m := expressionPattern.FindStringSubmatch(strings.TrimSpace(expr))
+	if m == nil || len(m[0]) != len(strings.TrimSpace(expr)) {
+		return nil, fmt.Errorf("%q is not a ${step.path} expression", expr)
+	}
+	return lookupPath(m[1], outputs)
+}
+
+// lookupPath follows a dotted path through step outputs. A "length"
+// segment that is not a key gives the length of a list, map or string.
+func lookupPath(path string, outputs map[string]interface{}) (interface{}, error) {
+	segments := strings.Split(strings.TrimSpace(path), ".")
+	v, ok := outputs[segments[0]]
+	if !ok {
+		return nil, fmt.Errorf("${%s}: no step %q has run", path, segments[0])
+	}
+	for _, seg := range segments[1:] {
+		switch cur := v.(type) {
+		case map[string]interface{}:
+			if next, ok := cur[seg]; ok {
+				v = next
+				continue
+			}
+			if seg == "length" {
+				v = float64(len(cur))
+				continue
+			}
+			return nil, fmt.Errorf("${%s}: no key %q", path, seg)
+		case []interface{}:
+			if seg == "length" {
+				v = float64(len(cur))
+				continue
+			}
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(cur) {
+				return nil, fmt.Errorf("${%s}: no index %q in a list of %d", path, seg, len(cur))
+			}
+			v = cur[i]
+		case string:
+			if seg != "length" {
+				return nil, fmt.Errorf("${%s}: %q of a string", path, seg)
+			}
+			v = float64(len(cur))
+		default:
+			return nil, fmt.Errorf("${%s}: %q of %v", path, seg, cur)
+		}
+	}
+	return v, nil
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	}
+	return fmt.Sprint(v)
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != "" && v != "false" && v != "0"
+	case map[string]interface{}:
+		return len(v) > 0
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// confirmationGate confirms steps through bifrost when their policy or
+// risk requires it, and refuses them when no client can confirm.
+func confirmationGate(bifrost BifrostBridge) ActionGate {
+	return func(ctx context.Context, step *ParsedAction, confirm StepConfirm) PreExecuteResult {
+		def, _ := GetSubsystemManager().GetAction(step.Action)
+		if confirm != ConfirmAlways && !def.Risk.RequiresConfirmation() {
+			return PreExecuteResult{Continue: true}
+		}
+		if bifrost == nil || !bifrost.IsConnected() {
+			return PreExecuteResult{Continue: false, AbortMessage: "❎ No client connected to confirm " + step.Action}
+		}
+		approved, err := bifrost.RequestConfirmation(step.Action)
+		switch {
+		case err != nil:
+			return PreExecuteResult{Continue: false, AbortMessage: "❎ Confirmation cancelled: " + err.Error()}
+		case !approved:
+			return PreExecuteResult{Continue: false, AbortMessage: "❎ Action rejected: " + step.Action}
+		}
+		return PreExecuteResult{Continue: true}
+	}
+}
+
+// pipelineAction is the built-in heimdall.pipeline action.
+func pipelineAction() ActionFunc {
+	return ActionFunc{
+		Name:        PipelineAction,
+		Description: `Run actions in sequence, feeding results forward (params: steps: [{id, action, params, when, confirm, on_error}], reference earlier results as ${id.data.key})`,
+		Category:    "system",
+		Handler: func(ctx ActionContext) (*ActionResult, error) {
+			p, err := ParsePipeline(ctx.Params)
+			if err != nil {
+				return &ActionResult{Success: false, Message: err.Error()}, nil
+			}
+			return RunPipeline(ctx, p), nil
+		},
+		Schema: &ResultSchema{
+			Visualization: VisualizationTable,
+			Rows:          "steps",
+			Columns: []ResultColumn{
+				{Key: "id", Label: "Step", Type: ColumnString},
+				{Key: "action", Type: ColumnString},
+				{Key: "status", Type: ColumnString},
+				{Key: "message", Type: ColumnString, Optional: true},
+				{Key: "duration_ms", Label: "Duration", Type: ColumnDuration},
+				{Key: "data", Type: ColumnObject, Optional: true},
+			},
+		},
+	}
+}
@@ -0,0 +1,272 @@
+package heimdall
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerPipelineActions registers test actions for pipelines and returns
+// the params each call of heimdall.test.record received.
+func registerPipelineActions(t *testing.T) *[]map[string]interface{} {
+	t.Helper()
+	globalManager = nil
+	t.Cleanup(func() { globalManager = nil })
+
+	var recorded []map[string]interface{}
+	RegisterBuiltinAction(pipelineAction())
+	RegisterBuiltinAction(ActionFunc{
+		Name: "heimdall.test.find",
+		Handler: func(ctx ActionContext) (*ActionResult, error) {
+			return &ActionResult{Success: true, Message: "found 2", Data: map[string]interface{}{
+				"count": 2,
+				"items": []RunningQuery{{ID: "q1", Query: "MATCH (n) RETURN n"}, {ID: "q2"}},
+			}}, nil
+		},
+	})
+	RegisterBuiltinAction(ActionFunc{
+		Name: "heimdall.test.record",
+		Handler: func(ctx ActionContext) (*ActionResult, error) {
+			recorded = append(recorded, ctx.Params)
+			return &ActionResult{Success: true, Message: "recorded"}, nil
+		},
+	})
+	RegisterBuiltinAction(ActionFunc{
+		Name: "heimdall.test.fail",
+		Handler: func(ctx ActionContext) (*ActionResult, error) {
+			return &ActionResult{Success: false, Message: "nope"}, nil
+		},
+	})
+	RegisterBuiltinAction(ActionFunc{
+		Name: "heimdall.test.risky",
+		Risk: RiskHigh,
+		Handler: func(ctx ActionContext) (*ActionResult, error) {
+			recorded = append(recorded, ctx.Params)
+			return &ActionResult{Success: true, Message: "risky done"}, nil
+		},
+	})
+	return &recorded
+}
+
+// steps builds pipeline params from JSON.
+func steps(t *testing.T, js string) map[string]interface{} {
+	t.Helper()
+	var params map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(js), &params))
+	return params
+}
+
+func TestParsePipeline(t *testing.T) {
+	registerPipelineActions(t)
+
+	p, err := ParsePipeline(steps(t, `{"steps": [{"action": "heimdall.test.find"}, {"action": "heimdall.test.record", "params": {"n": "${step1.data.count}", "m": "${prev.message}"}}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "step1", p.Steps[0].ID)
+	assert.Equal(t, "step2", p.Steps[1].ID)
+
+	tests := []struct {
+		name  string
+		steps string
+		err   string
+	}{
+		{"no steps", `[]`, "no steps"},
+		{"no action", `[{"id": "a"}]`, "action is required"},
+		{"unknown action", `[{"action": "heimdall.test.missing"}]`, "unknown action"},
+		{"nested", `[{"action": "heimdall.pipeline"}]`, "cannot be nested"},
+		{"duplicate id", `[{"id": "a", "action": "heimdall.test.find"}, {"id": "a", "action": "heimdall.test.find"}]`, "duplicate id"},
+		{"invalid id", `[{"id": "a.b", "action": "heimdall.test.find"}]`, "invalid id"},
+		{"prev id", `[{"id": "prev", "action": "heimdall.test.find"}]`, "invalid id"},
+		{"forward reference", `[{"action": "heimdall.test.record", "params": {"x": "${later.message}"}}, {"id": "later", "action": "heimdall.test.find"}]`, `"later" does not refer to an earlier step`},
+		{"prev in first step", `[{"action": "heimdall.test.record", "when": "${prev.success}"}]`, `"prev"`},
+		{"unknown confirm", `[{"action": "heimdall.test.find", "confirm": "never"}]`, "unknown confirm policy"},
+		{"unknown on_error", `[{"action": "heimdall.test.find", "on_error": "retry"}]`, "unknown on_error policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePipeline(steps(t, `{"steps": `+tt.steps+`}`))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+
+	many := make([]interface{}, MaxPipelineSteps+1)
+	for i := range many {
+		many[i] = map[string]interface{}{"action": "heimdall.test.find"}
+	}
+	_, err = ParsePipeline(map[string]interface{}{"steps": many})
+	assert.ErrorContains(t, err, "at most")
+}
+
+func TestRunPipeline_Mapping(t *testing.T) {
+	recorded := registerPipelineActions(t)
+
+	result, err := ExecuteAction(PipelineAction, ActionContext{Context: context.Background(), Params: steps(t, `{"steps": [
+		{"id": "find", "action": "heimdall.test.find"},
+		{"action": "heimdall.test.record", "params": {
+			"count": "${find.data.count}",
+			"first": "${find.data.items.0.id}",
+			"total": "${find.data.items.length}",
+			"summary": "${find.message}: ${find.data.items.1.id} and ${ find.data.count } in total",
+			"nested": {"list": ["${prev.success}", "literal {not an expression}"]}
+		}}
+	]}`)})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Message)
+	assert.Equal(t, "Pipeline completed 2 of 2 steps\nfind: found 2\nstep2: recorded", result.Message)
+	require.NotNil(t, result.Schema, "results match the step table schema")
+
+	require.Len(t, *recorded, 1)
+	assert.Equal(t, map[string]interface{}{
+		"count":   2.0,
+		"first":   "q1",
+		"total":   2.0,
+		"summary": "found 2: q2 and 2 in total",
+		"nested":  map[string]interface{}{"list": []interface{}{true, "literal {not an expression}"}},
+	}, (*recorded)[0])
+}
+
+func TestRunPipeline_ConditionsAndErrors(t *testing.T) {
+	recorded := registerPipelineActions(t)
+	run := func(js string) (*ActionResult, []PipelineStepResult) {
+		result, err := ExecuteAction(PipelineAction, ActionContext{Context: context.Background(), Params: steps(t, js)})
+		require.NoError(t, err)
+		results, _ := result.Data["steps"].([]PipelineStepResult)
+		return result, results
+	}
+	statuses := func(results []PipelineStepResult) []string {
+		var s []string
+		for _, r := range results {
+			s = append(s, r.Status)
+		}
+		return s
+	}
+
+	// A false condition skips the step
+	result, results := run(`{"steps": [
+		{"id": "f", "action": "heimdall.test.fail", "on_error": "continue"},
+		{"action": "heimdall.test.record", "when": "${f.success}"},
+		{"action": "heimdall.test.record", "when": "${f.data.length}"},
+		{"action": "heimdall.test.record", "params": {"msg": "${f.message}"}}
+	]}`)
+	assert.True(t, result.Success, "the failed step may fail")
+	assert.Equal(t, []string{StepFailed, StepSkipped, StepSkipped, StepOK}, statuses(results))
+	assert.Equal(t, []map[string]interface{}{{"msg": "nope"}}, *recorded)
+
+	// A failure stops the pipeline by default
+	result, results = run(`{"steps": [{"id": "f", "action": "heimdall.test.fail"}, {"action": "heimdall.test.record"}]}`)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Message, "Pipeline stopped at step f (failed)")
+	assert.Equal(t, []string{StepFailed}, statuses(results))
+
+	// So does a reference to data that is not there
+	result, results = run(`{"steps": [{"id": "f", "action": "heimdall.test.find"}, {"action": "heimdall.test.record", "params": {"x": "${f.data.missing}"}}]}`)
+	assert.False(t, result.Success)
+	assert.Equal(t, []string{StepOK, StepFailed}, statuses(results))
+	assert.Contains(t, results[1].Message, `no key "missing"`)
+
+	// An invalid pipeline fails before any step runs
+	result, _ = run(`{"steps": [{"action": "heimdall.test.record"}, {"action": "heimdall.test.missing"}]}`)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Message, "unknown action")
+	assert.Len(t, *recorded, 1)
+}
+
+func TestRunPipeline_Gate(t *testing.T) {
+	recorded := registerPipelineActions(t)
+	params := steps(t, `{"steps": [
+		{"action": "heimdall.test.record", "params": {"limit": 10}},
+		{"action": "heimdall.test.record", "confirm": "always", "on_error": "continue"},
+		{"action": "heimdall.test.risky"}
+	]}`)
+
+	var gated []StepConfirm
+	gate := func(ctx context.Context, step *ParsedAction, confirm StepConfirm) PreExecuteResult {
+		gated = append(gated, confirm)
+		if confirm == ConfirmAlways {
+			return PreExecuteResult{Continue: false, AbortMessage: "rejected"}
+		}
+		step.Params = map[string]interface{}{"limit": 5}
+		return PreExecuteResult{Continue: true}
+	}
+	result, err := ExecuteAction(PipelineAction, ActionContext{Context: context.Background(), Params: params, Gate: gate})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, []StepConfirm{"", ConfirmAlways, ""}, gated)
+	assert.Equal(t, []map[string]interface{}{{"limit": 5}, {"limit": 5}}, *recorded, "the gate may change params")
+	assert.Equal(t, StepRefused, result.Data["steps"].([]PipelineStepResult)[1].Status)
+
+	// Without a gate, risky and always-confirmed steps ask Bifrost
+	*recorded = nil
+	bifrost := NewMockBifrost()
+	bifrost.confirmations = false
+	result, err = ExecuteAction(PipelineAction, ActionContext{Context: context.Background(), Params: params, Bifrost: bifrost})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Message, "Action rejected: heimdall.test.risky")
+	assert.Len(t, *recorded, 1)
+
+	*recorded = nil
+	bifrost.confirmations = true
+	result, err = ExecuteAction(PipelineAction, ActionContext{Context: context.Background(), Params: params, Bifrost: bifrost})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Len(t, *recorded, 3)
+
+	// and are refused when nobody can confirm
+	*recorded = nil
+	result, err = ExecuteAction(PipelineAction, ActionContext{Context: context.Background(), Params: params})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Message, "No client connected")
+	assert.Len(t, *recorded, 1)
+}
+
+func TestHandler_PipelineStepsAreConfirmed(t *testing.T) {
+	recorded := registerPipelineActions(t)
+
+	mockGen := NewMockGenerator("/test/model.gguf")
+	mockGen.generateFunc = func(ctx context.Context, prompt string, params GenerateParams) (string, error) {
+		return `{"action": "heimdall.pipeline", "params": {"steps": [
+			{"id": "find", "action": "heimdall.test.find"},
+			{"action": "heimdall.test.risky", "params": {"n": "${find.data.count}"}}
+		]}}`, nil
+	}
+	manager := newTestManager(mockGen)
+	cfg := manager.config
+	cfg.BifrostEnabled = true
+	cfg.ConfirmationTimeout = time.Second
+	handler := testHandler(manager, cfg)
+
+	run := func(approve bool) ChatResponse {
+		go func() {
+			req := waitPending(t, handler.bifrost)
+			assert.Equal(t, "heimdall.test.risky", req.Action)
+			assert.Equal(t, map[string]interface{}{"n": 2.0}, req.Params, "the confirmation shows resolved params")
+			handler.bifrost.RespondConfirmation(req.ID, "alice", approve)
+		}()
+		w := httptest.NewRecorder()
+		handler.handleNonStreamingResponse(w, context.Background(), "", GenerateParams{}, "test", &requestLifecycle{
+			promptCtx: &PromptContext{UserID: "alice", PluginData: map[string]interface{}{}},
+			requestID: "req-p",
+		})
+		var resp ChatResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp
+	}
+
+	resp := run(false)
+	assert.Contains(t, resp.Choices[0].Message.Content, "Action rejected by alice")
+	assert.Empty(t, *recorded)
+	require.NotNil(t, resp.ActionResult)
+	assert.False(t, resp.ActionResult.Success)
+
+	resp = run(true)
+	assert.Contains(t, resp.Choices[0].Message.Content, "risky done")
+	assert.Equal(t, []map[string]interface{}{{"n": 2.0}}, *recorded)
+	require.NotNil(t, resp.ActionResult.Schema)
+}
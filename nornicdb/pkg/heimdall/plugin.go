@@ -70,12 +70,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"plugin"
 	"reflect"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/requestid"
 )
 
 // PluginType identifies the type of plugin.
@@ -222,6 +229,16 @@ type HeimdallInvoker interface {
 	//   result, err := ctx.Heimdall.SendPrompt("Analyze recent error patterns")
 	SendPrompt(prompt string) (*ActionResult, error)
 
+	// SendPromptStream is like SendPrompt but passes prose tokens to onToken
+	// as the SLM generates them. Action commands are not streamed: the action
+	// runs and its result is returned. Returning an error from onToken stops
+	// generation.
+	//
+	// Example:
+	//   result, err := ctx.Heimdall.SendPromptStream(ctx, "Summarize today's anomalies",
+	//       func(token string) error { ctx.Bifrost.SendTo(userID, token); return nil })
+	SendPromptStream(ctx context.Context, prompt string, onToken func(token string) error) (*ActionResult, error)
+
 	// InvokeActionAsync invokes an action without waiting for result.
 	// Use this for fire-and-forget scenarios where you don't need the result.
 	// Results will be broadcast via Bifrost to connected clients.
@@ -232,6 +249,20 @@ type HeimdallInvoker interface {
 	SendPromptAsync(prompt string)
 }
 
+// TextGenerator is implemented by HeimdallInvokers that can have the SLM
+// write text without treating the response as an action command. Use it
+// when the prompt carries data, such as query results to summarize, that
+// must not be able to trigger actions.
+//
+// Example:
+//
+//	if gen, ok := ctx.Heimdall.(heimdall.TextGenerator); ok {
+//	    summary, err := gen.GenerateText(ctx, "Summarize these results: ...")
+//	}
+type TextGenerator interface {
+	GenerateText(ctx context.Context, prompt string) (string, error)
+}
+
 // NoOpHeimdallInvoker is a no-op implementation when Heimdall is not available.
 type NoOpHeimdallInvoker struct{}
 
@@ -241,6 +272,9 @@ func (n *NoOpHeimdallInvoker) InvokeAction(action string, params map[string]inte
 func (n *NoOpHeimdallInvoker) SendPrompt(prompt string) (*ActionResult, error) {
 	return &ActionResult{Success: false, Message: "Heimdall not available"}, nil
 }
+func (n *NoOpHeimdallInvoker) SendPromptStream(ctx context.Context, prompt string, onToken func(string) error) (*ActionResult, error) {
+	return &ActionResult{Success: false, Message: "Heimdall not available"}, nil
+}
 func (n *NoOpHeimdallInvoker) InvokeActionAsync(action string, params map[string]interface{}) {}
 func (n *NoOpHeimdallInvoker) SendPromptAsync(prompt string)                                  {}
 
@@ -278,6 +312,19 @@ func (h *LiveHeimdallInvoker) InvokeAction(action string, params map[string]inte
 	return ExecuteAction(action, ctx)
 }
 
+// GenerateText has the SLM complete prompt and returns the text as is.
+// PII in the prompt is masked like in chat.
+func (h *LiveHeimdallInvoker) GenerateText(ctx context.Context, prompt string) (string, error) {
+	if h.generator == nil {
+		return "", fmt.Errorf("SLM not available")
+	}
+	response, err := h.generator.Generate(ctx, currentRedactor().Query(prompt), DefaultGenerateParams())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(response), nil
+}
+
 // SendPrompt sends a prompt to the SLM and processes the response.
 func (h *LiveHeimdallInvoker) SendPrompt(prompt string) (*ActionResult, error) {
 	if h.generator == nil {
@@ -289,7 +336,9 @@ func (h *LiveHeimdallInvoker) SendPrompt(prompt string) (*ActionResult, error) {
 	fullPrompt := actionPrompt + "\n\nUser: " + prompt
 
 	// Generate response from SLM
-	response, err := h.generator.Generate(context.Background(), fullPrompt, DefaultGenerateParams())
+	params := DefaultGenerateParams()
+	params.Grammar = actionGrammar(nil)
+	response, err := h.generator.Generate(context.Background(), fullPrompt, params)
 	if err != nil {
 		return &ActionResult{Success: false, Message: fmt.Sprintf("SLM error: %v", err)}, nil
 	}
@@ -306,6 +355,34 @@ func (h *LiveHeimdallInvoker) SendPrompt(prompt string) (*ActionResult, error) {
 	}, nil
 }
 
+// SendPromptStream sends a prompt to the SLM, streaming prose tokens to onToken.
+func (h *LiveHeimdallInvoker) SendPromptStream(ctx context.Context, prompt string, onToken func(token string) error) (*ActionResult, error) {
+	if h.generator == nil {
+		return &ActionResult{Success: false, Message: "SLM not available"}, nil
+	}
+
+	fullPrompt := ActionPrompt() + "\n\nUser: " + prompt
+	filter := newActionStreamFilter(onToken, nil)
+	params := DefaultGenerateParams()
+	params.Grammar = actionGrammar(nil)
+	err := h.generator.GenerateStream(ctx, fullPrompt, params, filter.Write)
+	if err == nil {
+		_, err = filter.Close()
+	}
+	if err != nil {
+		return &ActionResult{Success: false, Message: fmt.Sprintf("SLM error: %v", err)}, nil
+	}
+
+	response := filter.Text()
+	if parsedAction := tryParseActionResponse(response); parsedAction != nil {
+		return h.InvokeAction(parsedAction.Action, parsedAction.Params)
+	}
+	return &ActionResult{
+		Success: true,
+		Message: response,
+	}, nil
+}
+
 // InvokeActionAsync invokes an action asynchronously, broadcasting results via Bifrost.
 func (h *LiveHeimdallInvoker) InvokeActionAsync(action string, params map[string]interface{}) {
 	go func() {
@@ -398,11 +475,26 @@ type BifrostBridge interface {
 	// Useful for system-wide announcements.
 	Broadcast(msg string) error
 
-	// RequestConfirmation asks the user to confirm an action.
-	// Returns true if user confirms, false if they decline or timeout.
-	// The action parameter describes what needs confirmation.
+	// RequestConfirmation asks the user to confirm an action and blocks
+	// until a decision is made. Returns true if the user confirms, false if
+	// they decline or the confirmation times out (unless the configured
+	// default approves). The action parameter describes what needs confirmation.
 	RequestConfirmation(action string) (bool, error)
 
+	// SendTo sends a message to every connection of one user only.
+	// Sending to a user who is offline is not an error.
+	SendTo(userID, msg string) error
+
+	// NotifyUser sends a notification to every connection of one user only.
+	NotifyUser(userID, notifType, title, message string) error
+
+	// Publish sends a notification to clients subscribed to topic
+	// (e.g. "watcher", "anomalies"). Clients choose topics when connecting.
+	Publish(topic, notifType, title, message string) error
+
+	// IsUserOnline returns true if the user has an open Bifrost connection.
+	IsUserOnline(userID string) bool
+
 	// IsConnected returns true if there are active Bifrost connections.
 	IsConnected() bool
 
@@ -417,6 +509,10 @@ func (n *NoOpBifrost) SendMessage(msg string) error                    { return
 func (n *NoOpBifrost) SendNotification(t, title, msg string) error     { return nil }
 func (n *NoOpBifrost) Broadcast(msg string) error                      { return nil }
 func (n *NoOpBifrost) RequestConfirmation(action string) (bool, error) { return false, nil }
+func (n *NoOpBifrost) SendTo(userID, msg string) error                 { return nil }
+func (n *NoOpBifrost) NotifyUser(u, t, title, msg string) error        { return nil }
+func (n *NoOpBifrost) Publish(topic, t, title, msg string) error       { return nil }
+func (n *NoOpBifrost) IsUserOnline(userID string) bool                 { return false }
 func (n *NoOpBifrost) IsConnected() bool                               { return false }
 func (n *NoOpBifrost) ConnectionCount() int                            { return 0 }
 
@@ -465,6 +561,8 @@ type ActionFunc struct {
 	Handler     func(ctx ActionContext) (*ActionResult, error) // The action handler
 	Description string                                         // Human-readable description
 	Category    string                                         // Grouping: monitoring, optimization, curation
+	Risk        RiskLevel                                      // RiskMedium/RiskHigh require user confirmation
+	Schema      *ResultSchema                                  // Optional shape of successful results, for rich rendering
 }
 
 // ActionContext provides context for action execution.
@@ -487,6 +585,10 @@ type ActionContext struct {
 	// Bifrost provides communication bridge to the user
 	// Use this to send progress updates, request confirmation, etc.
 	Bifrost BifrostBridge
+
+	// Gate approves actions this one runs in turn, such as pipeline steps.
+	// Set by the chat handler; nil elsewhere.
+	Gate ActionGate
 }
 
 // ActionResult is the outcome of action execution.
@@ -494,6 +596,10 @@ type ActionResult struct {
 	Success bool                   `json:"success"`
 	Message string                 `json:"message"`
 	Data    map[string]interface{} `json:"data,omitempty"`
+
+	// Schema describes Data for rendering. ExecuteAction sets it from the
+	// action's schema when Data matches; handlers may set their own.
+	Schema *ResultSchema `json:"schema,omitempty"`
 }
 
 // DatabaseReader provides read-only database access for actions.
@@ -509,6 +615,17 @@ type DatabaseStats struct {
 	NodeCount         int64            `json:"node_count"`
 	RelationshipCount int64            `json:"relationship_count"`
 	LabelCounts       map[string]int64 `json:"label_counts"`
+	RunningQueries    []RunningQuery   `json:"running_queries,omitempty"`
+}
+
+// RunningQuery describes a Cypher query that is executing.
+type RunningQuery struct {
+	ID          string `json:"id"`
+	Query       string `json:"query"`
+	User        string `json:"user,omitempty"`
+	ElapsedMs   int64  `json:"elapsed_ms"`
+	Rows        int64  `json:"rows"`
+	MemoryBytes int64  `json:"memory_bytes"`
 }
 
 // MetricsReader provides runtime metrics access for actions.
@@ -522,6 +639,19 @@ type RuntimeMetrics struct {
 	GoroutineCount int    `json:"goroutine_count"`
 	MemoryAllocMB  uint64 `json:"memory_alloc_mb"`
 	NumGC          uint32 `json:"num_gc"`
+
+	// Storage and subsystem signals, zero when the host does not report them
+	WALSyncLagMs   int64  `json:"wal_sync_lag_ms,omitempty"`  // Age of the oldest WAL write not yet synced
+	DiskFreeBytes  uint64 `json:"disk_free_bytes,omitempty"`  // Free space on the data volume
+	DiskTotalBytes uint64 `json:"disk_total_bytes,omitempty"` // Size of the data volume
+	CacheHits      uint64 `json:"cache_hits,omitempty"`       // Search result cache hits (cumulative)
+	CacheMisses    uint64 `json:"cache_misses,omitempty"`     // Search result cache misses (cumulative)
+	GPUErrors      int64  `json:"gpu_errors,omitempty"`       // GPU operations that fell back to CPU (cumulative)
+
+	// Write backpressure, zero when writes are not throttled
+	WriteThrottlePressure float64 `json:"write_throttle_pressure,omitempty"` // 0 = none, 0-1 = delaying, >= 1 = rejecting
+	WritesDelayed         int64   `json:"writes_delayed,omitempty"`          // Writes admitted after a delay (cumulative)
+	WritesRejected        int64   `json:"writes_rejected,omitempty"`         // Writes rejected (cumulative)
 }
 
 // LoadedHeimdallPlugin represents a loaded SLM plugin with full subsystem management.
@@ -598,12 +728,17 @@ func (m *SubsystemManager) RegisterPlugin(p HeimdallPlugin, path string, builtin
 	for actionName, action := range p.Actions() {
 		fullName := fmt.Sprintf("heimdall.%s.%s", name, actionName)
 		action.Name = fullName
+		checkActionSchema(&action)
 		m.actions[fullName] = action
 	}
 
 	// Mark as initialized once we have at least one plugin
 	m.initialized = true
 
+	// Start its event queue now so events it spooled before a restart are
+	// delivered without waiting for the next database event
+	globalEventDispatcher.subscribersFor([]*LoadedHeimdallPlugin{m.plugins[name]})
+
 	return nil
 }
 
@@ -977,11 +1112,31 @@ func RegisterBuiltinAction(action ActionFunc) {
 	m := GetSubsystemManager()
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	checkActionSchema(&action)
 	m.actions[action.Name] = action
 }
 
+// pluginPanics counts panics recovered from action handlers and plugin hooks.
+var pluginPanics atomic.Int64
+
+// PanicCount returns the number of panics recovered from Heimdall action
+// handlers and plugin hooks since startup.
+func PanicCount() int64 {
+	return pluginPanics.Load()
+}
+
+// recoverPluginPanic logs and counts a recovered plugin panic.
+// Must be called directly from a deferred function so recover() works.
+func recoverPluginPanic(where string, r any) {
+	pluginPanics.Add(1)
+	fmt.Printf("[Heimdall] PANIC in %s: %v\n%s\n", where, r, debug.Stack())
+}
+
 // ExecuteAction executes an action by name with the given context.
-func ExecuteAction(name string, ctx ActionContext) (*ActionResult, error) {
+// A panicking handler is recovered and reported as an error so a faulty
+// plugin cannot take down the server. Successful results that match the
+// action's ResultSchema carry it for rendering.
+func ExecuteAction(name string, ctx ActionContext) (result *ActionResult, err error) {
 	m := GetSubsystemManager()
 	m.mu.RLock()
 	action, ok := m.actions[name]
@@ -995,7 +1150,17 @@ func ExecuteAction(name string, ctx ActionContext) (*ActionResult, error) {
 		return nil, fmt.Errorf("action %s has no handler", name)
 	}
 
-	return action.Handler(ctx)
+	defer func() {
+		if r := recover(); r != nil {
+			recoverPluginPanic("action "+name, r)
+			result, err = nil, fmt.Errorf("action %s panicked: %v", name, r)
+		}
+	}()
+	result, err = action.Handler(ctx)
+	if err == nil {
+		attachResultSchema(action, result)
+	}
+	return result, err
 }
 
 // ActionCatalog returns all actions grouped by category for display.
@@ -1057,6 +1222,8 @@ func BuiltinActions() []ActionFunc {
 				}, nil
 			},
 		},
+		pipelineAction(),
+		traceAction(),
 	}
 }
 
@@ -1075,10 +1242,18 @@ func init() {
 
 // ActionPrompt generates a list of available actions.
 func ActionPrompt() string {
+	return actionPromptFor(nil)
+}
+
+// actionPromptFor lists the actions a persona may use (all when nil).
+func actionPromptFor(persona *Persona) string {
 	catalog := ActionCatalog()
 
 	var prompt string
 	for category, actions := range catalog {
+		if !persona.allowsCategory(category) {
+			continue
+		}
 		prompt += fmt.Sprintf("## %s\n", category)
 		for _, action := range actions {
 			prompt += fmt.Sprintf("- %s: %s\n", action.Name, action.Description)
@@ -1143,10 +1318,13 @@ func CallPrePromptHooks(ctx *PromptContext) {
 	for _, p := range plugins {
 		// Check if plugin implements PrePromptHook
 		if hook, ok := p.Plugin.(PrePromptHook); ok {
-			if err := hook.PrePrompt(ctx); err != nil {
+			before := ctx.state()
+			err := callPrePrompt(hook, p.Plugin.Name(), ctx)
+			if err != nil {
 				// Log warning but don't abort
 				fmt.Printf("[Heimdall] PrePrompt warning from %s: %v\n", p.Plugin.Name(), err)
 			}
+			ctx.trace.step(TraceStep{Phase: TracePrePrompt, Source: p.Plugin.Name(), Detail: before.changes(ctx, err)})
 			// Check for cancellation after each plugin
 			if ctx.Cancelled() {
 				return
@@ -1169,14 +1347,22 @@ func CallPreExecuteHooks(ctx *PreExecuteContext) PreExecuteResult {
 	for _, p := range plugins {
 		// Check if plugin implements PreExecuteHook
 		if hook, ok := p.Plugin.(PreExecuteHook); ok {
+			step := TraceStep{Phase: TracePreExecute, Source: p.Plugin.Name(), Action: ctx.Action}
+			before := maps.Clone(ctx.Params) // Hooks may change the map in place
 			done := make(chan PreExecuteResult, 1)
-			hook.PreExecute(ctx, func(r PreExecuteResult) {
+			if panicked := callPreExecute(hook, p.Plugin.Name(), ctx, func(r PreExecuteResult) {
 				done <- r
-			})
+			}); panicked {
+				step.Detail = "panicked"
+				ctx.trace.step(step)
+				continue // Treat a panicking hook as a no-op rather than waiting for its timeout
+			}
 
 			select {
 			case r := <-done:
 				if !r.Continue {
+					step.Detail = "aborted: " + r.AbortMessage
+					ctx.trace.step(step)
 					return r // Abort on first Continue=false
 				}
 				if r.ModifiedParams != nil {
@@ -1184,12 +1370,17 @@ func CallPreExecuteHooks(ctx *PreExecuteContext) PreExecuteResult {
 				}
 			case <-time.After(5 * time.Second):
 				fmt.Printf("[Heimdall] PreExecute timeout from %s\n", p.Plugin.Name())
+				step.Detail = "timed out"
 			}
+			step.Changes = DiffParams(before, ctx.Params)
 
 			// Check for cancellation via context method
 			if ctx.Cancelled() {
+				step.Detail = "cancelled: " + ctx.CancelReason()
+				ctx.trace.step(step)
 				return PreExecuteResult{Continue: false, AbortMessage: ctx.CancelReason()}
 			}
+			ctx.trace.step(step)
 		}
 	}
 
@@ -1208,118 +1399,91 @@ func CallPostExecuteHooks(ctx *PostExecuteContext) {
 	for _, p := range plugins {
 		// Check if plugin implements PostExecuteHook
 		if hook, ok := p.Plugin.(PostExecuteHook); ok {
-			go hook.PostExecute(ctx) // Fire and forget
+			go callPostExecute(hook, p.Plugin.Name(), ctx) // Fire and forget
 		}
 	}
 }
 
-// =============================================================================
-// Database Event Dispatcher
-// =============================================================================
-
-// dbEventDispatcher manages asynchronous delivery of database events to plugins.
-type dbEventDispatcher struct {
-	mu      sync.RWMutex
-	running bool
-	events  chan *DatabaseEvent
-	done    chan struct{}
+// callPrePrompt invokes a PrePrompt hook, converting a panic into an error.
+func callPrePrompt(hook PrePromptHook, name string, ctx *PromptContext) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			recoverPluginPanic("PrePrompt hook of "+name, r)
+			err = fmt.Errorf("PrePrompt panicked: %v", r)
+		}
+	}()
+	return hook.PrePrompt(ctx)
 }
 
-var globalEventDispatcher = &dbEventDispatcher{
-	events: make(chan *DatabaseEvent, 1000), // Buffer up to 1000 events
-	done:   make(chan struct{}),
+// callPreExecute invokes a PreExecute hook and reports whether it panicked.
+func callPreExecute(hook PreExecuteHook, name string, ctx *PreExecuteContext, done func(PreExecuteResult)) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			recoverPluginPanic("PreExecute hook of "+name, r)
+			panicked = true
+		}
+	}()
+	hook.PreExecute(ctx, done)
+	return false
 }
 
-// StartEventDispatcher starts the background event dispatcher.
-// This should be called when Heimdall is initialized.
-func StartEventDispatcher() {
-	d := globalEventDispatcher
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	if d.running {
-		return
-	}
-
-	d.running = true
-	d.events = make(chan *DatabaseEvent, 1000)
-	d.done = make(chan struct{})
-
-	go func() {
-		for {
-			select {
-			case event := <-d.events:
-				dispatchEventToPlugins(event)
-			case <-d.done:
-				return
-			}
+// callPostExecute invokes a PostExecute hook, recovering any panic.
+func callPostExecute(hook PostExecuteHook, name string, ctx *PostExecuteContext) {
+	defer func() {
+		if r := recover(); r != nil {
+			recoverPluginPanic("PostExecute hook of "+name, r)
 		}
 	}()
+	hook.PostExecute(ctx)
 }
 
-// StopEventDispatcher stops the background event dispatcher.
-func StopEventDispatcher() {
+// =============================================================================
+// Database Event Dispatcher
+// =============================================================================
+
+// SetRedactor sets the PII redactor applied to database events before they
+// are delivered to plugins and to prompts before they are sent to the SLM.
+// A nil redactor disables redaction.
+func SetRedactor(r *redact.Redactor) {
 	d := globalEventDispatcher
 	d.mu.Lock()
 	defer d.mu.Unlock()
-
-	if !d.running {
-		return
-	}
-
-	d.running = false
-	close(d.done)
+	d.redactor = r
 }
 
-// dispatchEventToPlugins sends an event to all plugins that implement DatabaseEventHook.
-func dispatchEventToPlugins(event *DatabaseEvent) {
-	if !HeimdallPluginsInitialized() {
-		return
-	}
-
-	plugins := ListHeimdallPlugins()
-	for _, p := range plugins {
-		// Check if plugin implements DatabaseEventHook
-		if hook, ok := p.Plugin.(DatabaseEventHook); ok {
-			// Fire and forget - don't block on slow plugins
-			go func(h DatabaseEventHook, e *DatabaseEvent) {
-				defer func() {
-					if r := recover(); r != nil {
-						fmt.Printf("[Heimdall] DatabaseEventHook panic in %T: %v\n", h, r)
-					}
-				}()
-				h.OnDatabaseEvent(e)
-			}(hook, event)
-		}
-	}
-}
-
-// EmitDatabaseEvent sends a database event to all registered plugins.
-// This is non-blocking - events are queued for async delivery.
-// If the queue is full, the event is dropped (with a warning).
-func EmitDatabaseEvent(event *DatabaseEvent) {
+// currentRedactor returns the configured redactor (nil if none).
+func currentRedactor() *redact.Redactor {
 	d := globalEventDispatcher
 	d.mu.RLock()
-	running := d.running
-	d.mu.RUnlock()
+	defer d.mu.RUnlock()
+	return d.redactor
+}
 
-	if !running {
-		return // Dispatcher not running
+// redactEvent masks PII in the query, parameters, properties and error of event.
+func redactEvent(r *redact.Redactor, event *DatabaseEvent) {
+	if !r.Enabled() {
+		return
 	}
+	event.Query = r.Query(event.Query)
+	event.QueryParams = r.Map(event.QueryParams)
+	event.Properties = r.Map(event.Properties)
+	event.OldProperties = r.Map(event.OldProperties)
+	event.Error = r.String(event.Error)
+}
 
-	// Set timestamp if not already set
-	if event.Timestamp.IsZero() {
-		event.Timestamp = time.Now()
+// EmitDatabaseEventContext is like EmitDatabaseEvent but fills event.RequestID
+// from the request ID carried by ctx (see package requestid) when not already set.
+// Inside a transaction (see WithEventTransaction) the event is held until it
+// commits.
+func EmitDatabaseEventContext(ctx context.Context, event *DatabaseEvent) {
+	if event.RequestID == "" {
+		event.RequestID = requestid.FromContext(ctx)
 	}
-
-	// Non-blocking send
-	select {
-	case d.events <- event:
-		// Event queued successfully
-	default:
-		// Queue full - drop event with warning
-		fmt.Printf("[Heimdall] Event queue full, dropping event: %s\n", event.Type)
+	if tx := EventTransactionFromContext(ctx); tx != nil {
+		tx.Emit(event)
+		return
 	}
+	EmitDatabaseEvent(event)
 }
 
 // EmitNodeEvent is a convenience function for emitting node-related events.
@@ -1358,3 +1522,21 @@ func EmitQueryEvent(eventType DatabaseEventType, query string, params map[string
 	}
 	EmitDatabaseEvent(event)
 }
+
+// EmitQueryEventContext emits a query event tagged with the request ID from ctx.
+// source identifies the entry point ("bolt", "http", ...).
+func EmitQueryEventContext(ctx context.Context, source, query string, params map[string]interface{}, duration time.Duration, rowsAffected int64, err error) {
+	event := &DatabaseEvent{
+		Type:         EventQueryExecuted,
+		Query:        query,
+		QueryParams:  params,
+		Duration:     duration,
+		RowsAffected: rowsAffected,
+		Source:       source,
+	}
+	if err != nil {
+		event.Type = EventQueryFailed
+		event.Error = err.Error()
+	}
+	EmitDatabaseEventContext(ctx, event)
+}
@@ -0,0 +1,174 @@
+// Package heimdall - lock-free counters for plugin metrics.
+//
+// Plugins count requests, errors and domain events from action handlers,
+// lifecycle hooks and the event dispatcher concurrently. Guarding the counts
+// with the plugin's own mutex couples them to unrelated state and makes it
+// easy to read a value after it was reset. RateCounter and PluginMetrics keep
+// the counts in atomics instead, and track a sliding window so thresholds
+// like "5 failures in 5 minutes" need no bookkeeping of their own:
+//
+//	type MyPlugin struct {
+//		heimdall.PluginMetrics
+//		failures heimdall.RateCounter
+//	}
+//
+//	func (p *MyPlugin) Initialize(ctx heimdall.SubsystemContext) error {
+//		p.failures.SetWindow(5 * time.Minute)
+//		...
+//	}
+//
+//	func (p *MyPlugin) actionFoo(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+//		p.RecordRequest()
+//		...
+//	}
+//
+//	func (p *MyPlugin) Metrics() map[string]interface{} {
+//		return p.MetricsSnapshot()
+//	}
+package heimdall
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRateWindow is the sliding window of a RateCounter whose window was
+// never set.
+const DefaultRateWindow = time.Minute
+
+// rateSlots is the number of buckets a window is split into. Counts expire
+// one bucket (window/rateSlots) at a time.
+const rateSlots = 60
+
+// RateCounter is a monotonic counter that also counts the increments of the
+// last window. The zero value is ready to use with DefaultRateWindow. It is
+// safe for concurrent use and never blocks.
+type RateCounter struct {
+	total  atomic.Int64
+	window atomic.Int64 // nanoseconds, 0 means DefaultRateWindow
+	// Each slot packs the bucket's epoch (upper 32 bits) with its count
+	// (lower 32 bits) so a bucket is claimed and counted in one CAS.
+	slots [rateSlots]atomic.Uint64
+}
+
+// SetWindow sets the sliding window and clears the windowed count. It does
+// not affect Total. Non-positive durations select DefaultRateWindow.
+func (c *RateCounter) SetWindow(d time.Duration) {
+	if d <= 0 {
+		d = DefaultRateWindow
+	}
+	c.window.Store(int64(d))
+	c.ResetWindow()
+}
+
+// Window returns the sliding window.
+func (c *RateCounter) Window() time.Duration {
+	if w := c.window.Load(); w > 0 {
+		return time.Duration(w)
+	}
+	return DefaultRateWindow
+}
+
+// Inc adds one.
+func (c *RateCounter) Inc() { c.Add(1) }
+
+// Add adds n, which must not be negative.
+func (c *RateCounter) Add(n int64) { c.addAt(n, time.Now()) }
+
+func (c *RateCounter) addAt(n int64, now time.Time) {
+	if n <= 0 {
+		return
+	}
+	c.total.Add(n)
+
+	epoch := c.epoch(now)
+	slot := &c.slots[epoch%rateSlots]
+	for {
+		old := slot.Load()
+		next := uint64(uint32(epoch))<<32 | min(uint64(n), 1<<32-1)
+		if uint32(old>>32) == uint32(epoch) {
+			// Saturate rather than spill into the epoch bits
+			next = old&^(1<<32-1) | min(old&(1<<32-1)+uint64(n), 1<<32-1)
+		}
+		if slot.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// Total returns the sum of everything ever added.
+func (c *RateCounter) Total() int64 { return c.total.Load() }
+
+// Count returns the sum added within the last window.
+func (c *RateCounter) Count() int64 { return c.countAt(time.Now()) }
+
+func (c *RateCounter) countAt(now time.Time) int64 {
+	epoch := uint32(c.epoch(now))
+	var n int64
+	for i := range c.slots {
+		v := c.slots[i].Load()
+		if epoch-uint32(v>>32) < rateSlots {
+			n += int64(v & (1<<32 - 1))
+		}
+	}
+	return n
+}
+
+// Rate returns the per-second rate over the last window.
+func (c *RateCounter) Rate() float64 {
+	return float64(c.Count()) / c.Window().Seconds()
+}
+
+// ResetWindow clears the windowed count, e.g. after a threshold on Count
+// fired. Total is unaffected.
+func (c *RateCounter) ResetWindow() {
+	for i := range c.slots {
+		c.slots[i].Store(0)
+	}
+}
+
+// epoch returns the index of the bucket containing now.
+func (c *RateCounter) epoch(now time.Time) int64 {
+	width := int64(c.Window()) / rateSlots
+	if width <= 0 {
+		width = 1
+	}
+	return now.UnixNano() / width
+}
+
+// PluginMetrics counts a plugin's requests and errors. Embed it in a plugin
+// struct; the zero value is ready to use and safe for concurrent use.
+type PluginMetrics struct {
+	Requests RateCounter
+	Errors   RateCounter
+}
+
+// RecordRequest counts one handled request.
+func (m *PluginMetrics) RecordRequest() { m.Requests.Inc() }
+
+// RecordError counts one failed request.
+func (m *PluginMetrics) RecordError() { m.Errors.Inc() }
+
+// MetricsSnapshot returns the counters in the shape HeimdallPlugin.Metrics
+// uses: lifetime totals and error rate, plus per-second rates and the error
+// rate over the sliding window.
+func (m *PluginMetrics) MetricsSnapshot() map[string]interface{} {
+	requests, errors := m.Requests.Total(), m.Errors.Total()
+	windowRequests, windowErrors := m.Requests.Count(), m.Errors.Count()
+	return map[string]interface{}{
+		"requests":          requests,
+		"errors":            errors,
+		"error_rate":        ratio(errors, requests),
+		"requests_per_sec":  m.Requests.Rate(),
+		"errors_per_sec":    m.Errors.Rate(),
+		"window_error_rate": ratio(windowErrors, windowRequests),
+		"window_seconds":    m.Requests.Window().Seconds(),
+	}
+}
+
+func ratio(n, d int64) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
@@ -0,0 +1,98 @@
+package heimdall
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateCounter_ZeroValue(t *testing.T) {
+	var c RateCounter
+	assert.Equal(t, DefaultRateWindow, c.Window())
+	assert.Zero(t, c.Count())
+	assert.Zero(t, c.Rate())
+
+	c.Inc()
+	c.Add(4)
+	c.Add(-3) // ignored
+	assert.Equal(t, int64(5), c.Total())
+	assert.Equal(t, int64(5), c.Count())
+	assert.InDelta(t, 5.0/60, c.Rate(), 1e-9)
+}
+
+func TestRateCounter_WindowExpiry(t *testing.T) {
+	var c RateCounter
+	c.SetWindow(time.Minute)
+	start := time.Unix(1_700_000_000, 0)
+
+	c.addAt(3, start)
+	c.addAt(2, start.Add(30*time.Second))
+	assert.Equal(t, int64(5), c.countAt(start.Add(59*time.Second)))
+
+	// Buckets expire one second at a time
+	assert.Equal(t, int64(2), c.countAt(start.Add(61*time.Second)))
+	assert.Equal(t, int64(0), c.countAt(start.Add(91*time.Second)))
+
+	// A reused bucket starts over rather than adding to the stale count
+	c.addAt(1, start.Add(2*time.Minute))
+	assert.Equal(t, int64(1), c.countAt(start.Add(2*time.Minute)))
+	assert.Equal(t, int64(6), c.Total())
+}
+
+func TestRateCounter_ResetWindow(t *testing.T) {
+	var c RateCounter
+	c.Add(10)
+	c.ResetWindow()
+	assert.Zero(t, c.Count())
+	assert.Equal(t, int64(10), c.Total())
+
+	c.Add(2)
+	c.SetWindow(5 * time.Minute)
+	assert.Equal(t, 5*time.Minute, c.Window())
+	assert.Zero(t, c.Count(), "changing the window clears it")
+	assert.Equal(t, int64(12), c.Total())
+
+	c.SetWindow(0)
+	assert.Equal(t, DefaultRateWindow, c.Window())
+}
+
+func TestRateCounter_Concurrent(t *testing.T) {
+	var c RateCounter
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Inc()
+				_ = c.Count()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(8000), c.Total())
+	assert.Equal(t, int64(8000), c.Count())
+}
+
+func TestPluginMetrics_Snapshot(t *testing.T) {
+	var m PluginMetrics
+	snapshot := m.MetricsSnapshot()
+	assert.Equal(t, int64(0), snapshot["requests"])
+	assert.Equal(t, 0.0, snapshot["error_rate"])
+
+	for i := 0; i < 4; i++ {
+		m.RecordRequest()
+	}
+	m.RecordError()
+
+	snapshot = m.MetricsSnapshot()
+	assert.Equal(t, int64(4), snapshot["requests"])
+	assert.Equal(t, int64(1), snapshot["errors"])
+	assert.Equal(t, 0.25, snapshot["error_rate"])
+	assert.Equal(t, 0.25, snapshot["window_error_rate"])
+	assert.InDelta(t, 4.0/60, snapshot["requests_per_sec"], 1e-9)
+	assert.Equal(t, 60.0, snapshot["window_seconds"])
+}
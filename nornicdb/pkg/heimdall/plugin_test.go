@@ -6,6 +6,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/requestid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -231,6 +233,22 @@ func (b *MockBifrost) RequestConfirmation(action string) (bool, error) {
 	return b.confirmations, nil
 }
 
+func (b *MockBifrost) SendTo(userID, msg string) error {
+	return b.SendMessage(msg)
+}
+
+func (b *MockBifrost) NotifyUser(userID, notifType, title, message string) error {
+	return b.SendNotification(notifType, title, message)
+}
+
+func (b *MockBifrost) Publish(topic, notifType, title, message string) error {
+	return b.SendNotification(notifType, title, message)
+}
+
+func (b *MockBifrost) IsUserOnline(userID string) bool {
+	return b.connected
+}
+
 func (b *MockBifrost) IsConnected() bool {
 	return b.connected
 }
@@ -648,3 +666,62 @@ func TestHeimdallPluginsInitialized(t *testing.T) {
 	// After registering a plugin, should be initialized
 	assert.True(t, manager.initialized)
 }
+
+func TestExecuteAction_RecoversPanic(t *testing.T) {
+	// Reset global manager
+	globalManager = nil
+
+	RegisterBuiltinAction(ActionFunc{
+		Name:        "heimdall.test.panics",
+		Description: "Always panics",
+		Handler: func(ctx ActionContext) (*ActionResult, error) {
+			panic("handler exploded")
+		},
+	})
+
+	before := PanicCount()
+	result, err := ExecuteAction("heimdall.test.panics", ActionContext{Context: context.Background()})
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "handler exploded")
+	assert.Equal(t, before+1, PanicCount())
+}
+
+func TestEmitDatabaseEventContext_SetsRequestID(t *testing.T) {
+	ctx := requestid.NewContext(context.Background(), "req-42")
+
+	event := &DatabaseEvent{Type: EventQueryExecuted, Query: "RETURN 1"}
+	EmitDatabaseEventContext(ctx, event)
+	assert.Equal(t, "req-42", event.RequestID)
+
+	// An explicit RequestID is never overwritten
+	event = &DatabaseEvent{Type: EventQueryExecuted, RequestID: "explicit"}
+	EmitDatabaseEventContext(ctx, event)
+	assert.Equal(t, "explicit", event.RequestID)
+}
+
+func TestRedactEvent(t *testing.T) {
+	r, err := redact.New(redact.Config{Enabled: true, Properties: []string{"ssn"}, Patterns: []string{"email"}})
+	require.NoError(t, err)
+
+	event := &DatabaseEvent{
+		Type:          EventNodeUpdated,
+		Query:         "MATCH (p) SET p.ssn = '123-45-6789'",
+		QueryParams:   map[string]interface{}{"ssn": "123-45-6789", "name": "Ann"},
+		Properties:    map[string]interface{}{"email": "ann@example.com"},
+		OldProperties: map[string]interface{}{"ssn": "000-00-0000"},
+		Error:         "duplicate key ann@example.com",
+	}
+	redactEvent(r, event)
+
+	assert.Equal(t, "MATCH (p) SET p.ssn = '[REDACTED]'", event.Query)
+	assert.Equal(t, map[string]interface{}{"ssn": "[REDACTED]", "name": "Ann"}, event.QueryParams)
+	assert.Equal(t, map[string]interface{}{"email": "[REDACTED]"}, event.Properties)
+	assert.Equal(t, "[REDACTED]", event.OldProperties["ssn"])
+	assert.Equal(t, "duplicate key [REDACTED]", event.Error)
+
+	// No redactor leaves the event untouched
+	event = &DatabaseEvent{Query: "MATCH (p {ssn: '1'})"}
+	redactEvent(nil, event)
+	assert.Equal(t, "MATCH (p {ssn: '1'})", event.Query)
+}
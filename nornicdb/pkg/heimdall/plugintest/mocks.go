@@ -0,0 +1,448 @@
+package plugintest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+)
+
+// =============================================================================
+// DatabaseReader
+// =============================================================================
+
+// QueryCall records a query made through MockDatabase.
+type QueryCall struct {
+	Cypher string
+	Params map[string]interface{}
+}
+
+// MockDatabase is an in-memory heimdall.DatabaseReader.
+//
+// Queries are answered by QueryFunc when set, otherwise by Results keyed on
+// the exact Cypher text. Unknown queries return no rows.
+type MockDatabase struct {
+	mu sync.Mutex
+
+	// Results maps Cypher text to the rows it returns.
+	Results map[string][]map[string]interface{}
+
+	// QueryFunc, if set, answers every query instead of Results.
+	QueryFunc func(ctx context.Context, cypher string, params map[string]interface{}) ([]map[string]interface{}, error)
+
+	// DBStats is returned by Stats.
+	DBStats heimdall.DatabaseStats
+
+	queries []QueryCall
+}
+
+// NewMockDatabase creates a database with no canned results.
+func NewMockDatabase() *MockDatabase {
+	return &MockDatabase{Results: make(map[string][]map[string]interface{})}
+}
+
+// SetResult makes cypher return rows.
+func (m *MockDatabase) SetResult(cypher string, rows []map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Results[cypher] = rows
+}
+
+// Query implements heimdall.DatabaseReader.
+func (m *MockDatabase) Query(ctx context.Context, cypher string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	m.mu.Lock()
+	m.queries = append(m.queries, QueryCall{Cypher: cypher, Params: params})
+	fn := m.QueryFunc
+	rows := m.Results[cypher]
+	m.mu.Unlock()
+
+	if fn != nil {
+		return fn(ctx, cypher, params)
+	}
+	return rows, nil
+}
+
+// Stats implements heimdall.DatabaseReader.
+func (m *MockDatabase) Stats() heimdall.DatabaseStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.DBStats
+}
+
+// Queries returns the queries made so far, oldest first.
+func (m *MockDatabase) Queries() []QueryCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]QueryCall(nil), m.queries...)
+}
+
+// =============================================================================
+// MetricsReader
+// =============================================================================
+
+// MockMetrics is a heimdall.MetricsReader returning fixed metrics.
+type MockMetrics struct {
+	mu      sync.Mutex
+	runtime heimdall.RuntimeMetrics
+}
+
+// Set replaces the metrics returned by Runtime.
+func (m *MockMetrics) Set(metrics heimdall.RuntimeMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runtime = metrics
+}
+
+// Runtime implements heimdall.MetricsReader.
+func (m *MockMetrics) Runtime() heimdall.RuntimeMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.runtime
+}
+
+// =============================================================================
+// BifrostBridge
+// =============================================================================
+
+// Notification is a notification sent through MockBifrost or queued by a
+// hook context.
+type Notification struct {
+	Type    string
+	Title   string
+	Message string
+	UserID  string // Set for NotifyUser
+	Topic   string // Set for Publish
+}
+
+// Message is a chat message sent through MockBifrost.
+type Message struct {
+	Content   string
+	UserID    string // Set for SendTo
+	Broadcast bool   // Sent with Broadcast
+}
+
+// MockBifrost is a heimdall.BifrostBridge that records everything sent
+// through it. It reports itself connected with one client by default.
+type MockBifrost struct {
+	mu sync.Mutex
+
+	// Confirm is the answer to RequestConfirmation.
+	Confirm bool
+
+	// Connections is returned by ConnectionCount; IsConnected is Connections > 0.
+	Connections int
+
+	// OnlineUsers lists the users IsUserOnline reports as online.
+	OnlineUsers map[string]bool
+
+	messages      []Message
+	notifications []Notification
+	confirmations []string
+}
+
+// NewMockBifrost creates a bridge with one connected client.
+func NewMockBifrost() *MockBifrost {
+	return &MockBifrost{Connections: 1, OnlineUsers: make(map[string]bool)}
+}
+
+func (b *MockBifrost) SendMessage(msg string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, Message{Content: msg})
+	return nil
+}
+
+func (b *MockBifrost) SendNotification(notifType, title, message string) error {
+	b.record(Notification{Type: notifType, Title: title, Message: message})
+	return nil
+}
+
+func (b *MockBifrost) Broadcast(msg string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, Message{Content: msg, Broadcast: true})
+	return nil
+}
+
+func (b *MockBifrost) RequestConfirmation(action string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.confirmations = append(b.confirmations, action)
+	return b.Confirm, nil
+}
+
+func (b *MockBifrost) SendTo(userID, msg string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, Message{Content: msg, UserID: userID})
+	return nil
+}
+
+func (b *MockBifrost) NotifyUser(userID, notifType, title, message string) error {
+	b.record(Notification{Type: notifType, Title: title, Message: message, UserID: userID})
+	return nil
+}
+
+func (b *MockBifrost) Publish(topic, notifType, title, message string) error {
+	b.record(Notification{Type: notifType, Title: title, Message: message, Topic: topic})
+	return nil
+}
+
+func (b *MockBifrost) IsUserOnline(userID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.OnlineUsers[userID]
+}
+
+func (b *MockBifrost) IsConnected() bool {
+	return b.ConnectionCount() > 0
+}
+
+func (b *MockBifrost) ConnectionCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Connections
+}
+
+func (b *MockBifrost) record(n Notification) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.notifications = append(b.notifications, n)
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (b *MockBifrost) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.messages...)
+}
+
+// Notifications returns the notifications sent so far, oldest first.
+func (b *MockBifrost) Notifications() []Notification {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Notification(nil), b.notifications...)
+}
+
+// Confirmations returns the actions confirmation was requested for.
+func (b *MockBifrost) Confirmations() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.confirmations...)
+}
+
+// =============================================================================
+// SubsystemLogger
+// =============================================================================
+
+// LogEntry is a line logged through MockLogger.
+type LogEntry struct {
+	Level   string // "debug", "info", "warn" or "error"
+	Message string
+	Args    []interface{}
+}
+
+// MockLogger is a heimdall.SubsystemLogger that keeps every entry.
+type MockLogger struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+func (l *MockLogger) Debug(msg string, args ...interface{}) { l.log("debug", msg, args) }
+func (l *MockLogger) Info(msg string, args ...interface{})  { l.log("info", msg, args) }
+func (l *MockLogger) Warn(msg string, args ...interface{})  { l.log("warn", msg, args) }
+func (l *MockLogger) Error(msg string, args ...interface{}) { l.log("error", msg, args) }
+
+func (l *MockLogger) log(level, msg string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, LogEntry{Level: level, Message: msg, Args: args})
+}
+
+// Entries returns the logged entries, oldest first.
+func (l *MockLogger) Entries() []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LogEntry(nil), l.entries...)
+}
+
+// =============================================================================
+// HeimdallInvoker
+// =============================================================================
+
+// Invocation records an action invoked through Invoker.
+type Invocation struct {
+	Action string
+	Params map[string]interface{}
+	Async  bool
+}
+
+// Invoker is an in-memory heimdall.HeimdallInvoker. Actions are dispatched
+// to the plugins added with Register under their full
+// "heimdall.{plugin}.{action}" names; prompts are answered by PromptFunc.
+//
+// Async calls run on their own goroutine, like the live invoker, so a plugin
+// may call them while holding its own lock. Use Wait before asserting on
+// their effects.
+type Invoker struct {
+	mu sync.Mutex
+	wg sync.WaitGroup
+
+	// PromptFunc answers SendPrompt and friends. When nil, prompts fail
+	// with an unsuccessful result.
+	PromptFunc func(prompt string) (*heimdall.ActionResult, error)
+
+	actions     map[string]heimdall.ActionFunc
+	invocations []Invocation
+	prompts     []string
+	database    heimdall.DatabaseReader
+	metrics     heimdall.MetricsReader
+	bifrost     heimdall.BifrostBridge
+}
+
+// NewInvoker creates an invoker whose actions run against the given mocks.
+func NewInvoker(db heimdall.DatabaseReader, metrics heimdall.MetricsReader, bifrost heimdall.BifrostBridge) *Invoker {
+	return &Invoker{
+		actions:  make(map[string]heimdall.ActionFunc),
+		database: db,
+		metrics:  metrics,
+		bifrost:  bifrost,
+	}
+}
+
+// Register makes the plugin's actions invocable by their full names.
+func (i *Invoker) Register(p heimdall.HeimdallPlugin) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for name, action := range p.Actions() {
+		fullName := fmt.Sprintf("heimdall.%s.%s", p.Name(), name)
+		action.Name = fullName
+		i.actions[fullName] = action
+	}
+}
+
+// InvokeAction runs a registered action and returns its result.
+func (i *Invoker) InvokeAction(action string, params map[string]interface{}) (*heimdall.ActionResult, error) {
+	i.recordInvocation(Invocation{Action: action, Params: params})
+	return i.run(action, params)
+}
+
+// SendPrompt answers the prompt with PromptFunc.
+func (i *Invoker) SendPrompt(prompt string) (*heimdall.ActionResult, error) {
+	i.mu.Lock()
+	i.prompts = append(i.prompts, prompt)
+	fn := i.PromptFunc
+	i.mu.Unlock()
+
+	if fn == nil {
+		return &heimdall.ActionResult{Success: false, Message: "no prompt response configured"}, nil
+	}
+	return fn(prompt)
+}
+
+// GenerateText answers the prompt with the message of PromptFunc's result,
+// so the invoker also serves plugins that use heimdall.TextGenerator.
+func (i *Invoker) GenerateText(ctx context.Context, prompt string) (string, error) {
+	result, err := i.SendPrompt(prompt)
+	switch {
+	case err != nil:
+		return "", err
+	case result == nil:
+		return "", nil
+	case !result.Success:
+		return "", fmt.Errorf("%s", result.Message)
+	}
+	return result.Message, nil
+}
+
+// SendPromptStream answers the prompt with PromptFunc and streams the
+// result message as a single token.
+func (i *Invoker) SendPromptStream(ctx context.Context, prompt string, onToken func(token string) error) (*heimdall.ActionResult, error) {
+	result, err := i.SendPrompt(prompt)
+	if err != nil || result == nil || result.Message == "" {
+		return result, err
+	}
+	if err := onToken(result.Message); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// InvokeActionAsync runs a registered action on its own goroutine.
+func (i *Invoker) InvokeActionAsync(action string, params map[string]interface{}) {
+	i.recordInvocation(Invocation{Action: action, Params: params, Async: true})
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+		_, _ = i.run(action, params)
+	}()
+}
+
+// SendPromptAsync answers the prompt on its own goroutine.
+func (i *Invoker) SendPromptAsync(prompt string) {
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+		_, _ = i.SendPrompt(prompt)
+	}()
+}
+
+// Wait blocks until every async call made so far has finished.
+func (i *Invoker) Wait() {
+	i.wg.Wait()
+}
+
+// Invocations returns the actions invoked so far, oldest first.
+func (i *Invoker) Invocations() []Invocation {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]Invocation(nil), i.invocations...)
+}
+
+// Prompts returns the prompts sent so far, oldest first.
+func (i *Invoker) Prompts() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]string(nil), i.prompts...)
+}
+
+func (i *Invoker) recordInvocation(inv Invocation) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.invocations = append(i.invocations, inv)
+}
+
+func (i *Invoker) action(name string) (heimdall.ActionFunc, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	action, ok := i.actions[name]
+	return action, ok
+}
+
+// run executes a registered action without touching the global registry.
+func (i *Invoker) run(name string, params map[string]interface{}) (*heimdall.ActionResult, error) {
+	action, ok := i.action(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown action: %s", name)
+	}
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+	return action.Handler(heimdall.ActionContext{
+		Context:  context.Background(),
+		Params:   params,
+		Database: i.database,
+		Metrics:  i.metrics,
+		Bifrost:  i.bifrost,
+	})
+}
+
+// actionName expands a short action name to its full name for plugin.
+func actionName(plugin, name string) string {
+	if strings.HasPrefix(name, "heimdall.") {
+		return name
+	}
+	return fmt.Sprintf("heimdall.%s.%s", plugin, name)
+}
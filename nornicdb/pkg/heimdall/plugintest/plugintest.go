@@ -0,0 +1,322 @@
+// Package plugintest provides a test harness for Heimdall plugins.
+//
+// A Harness wires a plugin to in-memory mocks of everything in
+// heimdall.SubsystemContext: a database with canned query results, fixed
+// runtime metrics, a Bifrost bridge that records messages and notifications,
+// a logger and an invoker that dispatches to the plugin's own actions. It
+// drives the lifecycle and the optional hooks the way the Heimdall handler
+// does, and collects the notifications hooks queue so tests can assert on
+// them alongside those sent through Bifrost.
+//
+// # Usage
+//
+//	func TestMyPlugin(t *testing.T) {
+//	    h := plugintest.Start(t, &MyPlugin{})
+//	    h.Database.SetResult("MATCH (n) RETURN count(n) AS c", []map[string]interface{}{{"c": 3}})
+//
+//	    result, err := h.Action("count", nil)
+//	    require.NoError(t, err)
+//	    assert.True(t, result.Success)
+//
+//	    h.DatabaseEvent(&heimdall.DatabaseEvent{Type: heimdall.EventQueryFailed})
+//	    h.AssertEvent("warning", "query failed")
+//	    h.AssertNotification("warning", "MyPlugin")
+//	}
+//
+// Harnesses do not register plugins or actions with the global
+// SubsystemManager, so tests using them can run in parallel.
+package plugintest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+	"github.com/stretchr/testify/require"
+)
+
+// PreExecuteTimeout bounds how long Harness.PreExecute waits for the plugin
+// to call done.
+var PreExecuteTimeout = 5 * time.Second
+
+// Harness runs a single plugin against mock subsystems.
+type Harness struct {
+	T      testing.TB
+	Plugin heimdall.HeimdallPlugin
+
+	Database *MockDatabase
+	Metrics  *MockMetrics
+	Bifrost  *MockBifrost
+	Logger   *MockLogger
+	Invoker  *Invoker
+
+	// Context is passed to Initialize. Adjust Config before calling it.
+	Context heimdall.SubsystemContext
+
+	queued []Notification
+}
+
+// New creates a harness for p without initializing it.
+func New(t testing.TB, p heimdall.HeimdallPlugin) *Harness {
+	t.Helper()
+	h := &Harness{
+		T:        t,
+		Plugin:   p,
+		Database: NewMockDatabase(),
+		Metrics:  &MockMetrics{},
+		Bifrost:  NewMockBifrost(),
+		Logger:   &MockLogger{},
+	}
+	h.Invoker = NewInvoker(h.Database, h.Metrics, h.Bifrost)
+	h.Invoker.Register(p)
+	h.Context = heimdall.SubsystemContext{
+		Config:   heimdall.DefaultConfig(),
+		Database: h.Database,
+		Metrics:  h.Metrics,
+		Logger:   h.Logger,
+		Bifrost:  h.Bifrost,
+		Heimdall: h.Invoker,
+	}
+	return h
+}
+
+// Start creates a harness for p, initializes and starts the plugin, and
+// stops and shuts it down when the test finishes.
+func Start(t testing.TB, p heimdall.HeimdallPlugin) *Harness {
+	t.Helper()
+	h := New(t, p)
+	h.Initialize()
+	h.Start()
+	t.Cleanup(func() {
+		h.Invoker.Wait()
+		_ = p.Stop()
+		_ = p.Shutdown()
+	})
+	return h
+}
+
+// === Lifecycle ===
+
+// Initialize calls the plugin's Initialize with h.Context.
+func (h *Harness) Initialize() {
+	h.T.Helper()
+	require.NoError(h.T, h.Plugin.Initialize(h.Context), "Initialize")
+}
+
+// Start calls the plugin's Start and checks it reports itself running.
+func (h *Harness) Start() {
+	h.T.Helper()
+	require.NoError(h.T, h.Plugin.Start(), "Start")
+	require.Equal(h.T, heimdall.StatusRunning, h.Plugin.Status(), "status after Start")
+}
+
+// Stop calls the plugin's Stop and checks it reports itself stopped.
+func (h *Harness) Stop() {
+	h.T.Helper()
+	require.NoError(h.T, h.Plugin.Stop(), "Stop")
+	require.Equal(h.T, heimdall.StatusStopped, h.Plugin.Status(), "status after Stop")
+}
+
+// Shutdown calls the plugin's Shutdown.
+func (h *Harness) Shutdown() {
+	h.T.Helper()
+	require.NoError(h.T, h.Plugin.Shutdown(), "Shutdown")
+}
+
+// === Actions ===
+
+// Action runs one of the plugin's actions. name may be the short name
+// ("status") or the full name ("heimdall.watcher.status"). A successful
+// result must match the action's ResultSchema, which Heimdall would
+// otherwise drop before it reaches clients.
+func (h *Harness) Action(name string, params map[string]interface{}) (*heimdall.ActionResult, error) {
+	h.T.Helper()
+	full := actionName(h.Plugin.Name(), name)
+	action, ok := h.Invoker.action(full)
+	require.True(h.T, ok, "plugin %s has no action %s", h.Plugin.Name(), full)
+	if action.Schema != nil {
+		require.NoError(h.T, action.Schema.Check(), "result schema of %s", full)
+	}
+	result, err := h.Invoker.run(full, params)
+	if err == nil {
+		require.NoError(h.T, heimdall.ValidateActionResult(action, result), "result of %s", full)
+	}
+	return result, err
+}
+
+// === Hooks ===
+
+// PrePrompt runs the plugin's PrePrompt hook. Missing request fields are
+// filled in, and notifications the hook queues are collected.
+func (h *Harness) PrePrompt(ctx *heimdall.PromptContext) error {
+	h.T.Helper()
+	hook, ok := h.Plugin.(heimdall.PrePromptHook)
+	require.True(h.T, ok, "plugin %s does not implement PrePromptHook", h.Plugin.Name())
+
+	if ctx.RequestID == "" {
+		ctx.RequestID = "test-request"
+	}
+	if ctx.RequestTime.IsZero() {
+		ctx.RequestTime = time.Now()
+	}
+	if ctx.PluginData == nil {
+		ctx.PluginData = make(map[string]interface{})
+	}
+	ctx.SetBifrost(h.Bifrost)
+
+	err := hook.PrePrompt(ctx)
+	h.collect(ctx.DrainNotifications())
+	return err
+}
+
+// PreExecute runs the plugin's PreExecute hook and waits for it to call
+// done. The test fails if done is not called within PreExecuteTimeout.
+func (h *Harness) PreExecute(ctx *heimdall.PreExecuteContext) heimdall.PreExecuteResult {
+	h.T.Helper()
+	hook, ok := h.Plugin.(heimdall.PreExecuteHook)
+	require.True(h.T, ok, "plugin %s does not implement PreExecuteHook", h.Plugin.Name())
+
+	if ctx.RequestID == "" {
+		ctx.RequestID = "test-request"
+	}
+	if ctx.RequestTime.IsZero() {
+		ctx.RequestTime = time.Now()
+	}
+	if ctx.Params == nil {
+		ctx.Params = make(map[string]interface{})
+	}
+	if ctx.Database == nil {
+		ctx.Database = h.Database
+	}
+	if ctx.Metrics == nil {
+		ctx.Metrics = h.Metrics
+	}
+	ctx.SetBifrost(h.Bifrost)
+
+	results := make(chan heimdall.PreExecuteResult, 1)
+	hook.PreExecute(ctx, func(r heimdall.PreExecuteResult) {
+		select {
+		case results <- r:
+		default: // done called twice; keep the first result
+		}
+	})
+
+	select {
+	case r := <-results:
+		h.collect(ctx.DrainNotifications())
+		return r
+	case <-time.After(PreExecuteTimeout):
+		h.T.Fatalf("plugin %s did not call done within %v", h.Plugin.Name(), PreExecuteTimeout)
+		return heimdall.PreExecuteResult{}
+	}
+}
+
+// PostExecute runs the plugin's PostExecute hook and collects the
+// notifications it queues.
+func (h *Harness) PostExecute(ctx *heimdall.PostExecuteContext) {
+	h.T.Helper()
+	hook, ok := h.Plugin.(heimdall.PostExecuteHook)
+	require.True(h.T, ok, "plugin %s does not implement PostExecuteHook", h.Plugin.Name())
+
+	if ctx.RequestID == "" {
+		ctx.RequestID = "test-request"
+	}
+	hook.PostExecute(ctx)
+	h.collect(ctx.DrainNotifications())
+}
+
+// DatabaseEvent delivers event to the plugin's OnDatabaseEvent hook, unless
+// the plugin's EventSubscription excludes it. Reports whether it was
+// delivered.
+func (h *Harness) DatabaseEvent(event *heimdall.DatabaseEvent) bool {
+	h.T.Helper()
+	hook, ok := h.Plugin.(heimdall.DatabaseEventHook)
+	require.True(h.T, ok, "plugin %s does not implement DatabaseEventHook", h.Plugin.Name())
+
+	if sub, ok := hook.(heimdall.DatabaseEventSubscriber); ok && !sub.EventSubscription().Matches(event) {
+		return false
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	hook.OnDatabaseEvent(event)
+	return true
+}
+
+func (h *Harness) collect(queued []heimdall.QueuedNotification) {
+	for _, n := range queued {
+		h.queued = append(h.queued, Notification{Type: n.Type, Title: n.Title, Message: n.Message})
+	}
+}
+
+// === Assertions ===
+
+// Notifications returns every notification emitted so far: those queued by
+// hooks, then those sent through Bifrost.
+func (h *Harness) Notifications() []Notification {
+	return append(append([]Notification(nil), h.queued...), h.Bifrost.Notifications()...)
+}
+
+// AssertNotification fails the test unless a notification with the given
+// type and title was emitted, and returns the first match.
+func (h *Harness) AssertNotification(notificationType, title string) Notification {
+	h.T.Helper()
+	all := h.Notifications()
+	for _, n := range all {
+		if n.Type == notificationType && n.Title == title {
+			return n
+		}
+	}
+	h.T.Fatalf("no %q notification titled %q; got %+v", notificationType, title, all)
+	return Notification{}
+}
+
+// AssertNoNotifications fails the test if any notification was emitted.
+func (h *Harness) AssertNoNotifications() {
+	h.T.Helper()
+	require.Empty(h.T, h.Notifications(), "notifications")
+}
+
+// AssertEvent fails the test unless the plugin's RecentEvents contains an
+// event of eventType whose message contains substr, and returns it.
+func (h *Harness) AssertEvent(eventType, substr string) heimdall.SubsystemEvent {
+	h.T.Helper()
+	events := h.Plugin.RecentEvents(1000)
+	for _, e := range events {
+		if e.Type == eventType && strings.Contains(e.Message, substr) {
+			return e
+		}
+	}
+	h.T.Fatalf("no %q event containing %q; got %+v", eventType, substr, events)
+	return heimdall.SubsystemEvent{}
+}
+
+// AssertInvoked waits for async invocations and fails the test unless
+// action was invoked through h.Invoker. It returns the first invocation.
+func (h *Harness) AssertInvoked(action string) Invocation {
+	h.T.Helper()
+	h.Invoker.Wait()
+	action = actionName(h.Plugin.Name(), action)
+	invocations := h.Invoker.Invocations()
+	for _, inv := range invocations {
+		if inv.Action == action {
+			return inv
+		}
+	}
+	h.T.Fatalf("action %s was not invoked; got %+v", action, invocations)
+	return Invocation{}
+}
+
+// ActionContext returns an ActionContext backed by the harness mocks, for
+// calling action handlers directly.
+func (h *Harness) ActionContext(params map[string]interface{}) heimdall.ActionContext {
+	return heimdall.ActionContext{
+		Context:  context.Background(),
+		Params:   params,
+		Database: h.Database,
+		Metrics:  h.Metrics,
+		Bifrost:  h.Bifrost,
+	}
+}
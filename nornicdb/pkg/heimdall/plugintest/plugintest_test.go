@@ -0,0 +1,276 @@
+package plugintest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counterPlugin is a minimal plugin exercising every subsystem and hook.
+type counterPlugin struct {
+	mu       sync.Mutex
+	ctx      heimdall.SubsystemContext
+	status   heimdall.SubsystemStatus
+	events   []heimdall.SubsystemEvent
+	failures int
+}
+
+func (p *counterPlugin) Name() string        { return "counter" }
+func (p *counterPlugin) Version() string     { return "1.0.0" }
+func (p *counterPlugin) Type() string        { return heimdall.PluginTypeHeimdall }
+func (p *counterPlugin) Description() string { return "counts nodes" }
+
+func (p *counterPlugin) Initialize(ctx heimdall.SubsystemContext) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ctx = ctx
+	p.status = heimdall.StatusReady
+	ctx.Logger.Info("initialized")
+	return nil
+}
+
+func (p *counterPlugin) Start() error    { p.setStatus(heimdall.StatusRunning); return nil }
+func (p *counterPlugin) Stop() error     { p.setStatus(heimdall.StatusStopped); return nil }
+func (p *counterPlugin) Shutdown() error { p.setStatus(heimdall.StatusUninitialized); return nil }
+
+func (p *counterPlugin) setStatus(s heimdall.SubsystemStatus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = s
+}
+
+func (p *counterPlugin) Status() heimdall.SubsystemStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+func (p *counterPlugin) Health() heimdall.SubsystemHealth {
+	return heimdall.SubsystemHealth{Status: p.Status(), Healthy: true}
+}
+func (p *counterPlugin) Metrics() map[string]interface{}                 { return nil }
+func (p *counterPlugin) Config() map[string]interface{}                  { return nil }
+func (p *counterPlugin) Configure(settings map[string]interface{}) error { return nil }
+func (p *counterPlugin) ConfigSchema() map[string]interface{}            { return nil }
+func (p *counterPlugin) Summary() string                                 { return "counter" }
+
+func (p *counterPlugin) Actions() map[string]heimdall.ActionFunc {
+	return map[string]heimdall.ActionFunc{
+		"count": {
+			Description: "Count nodes",
+			Handler: func(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+				rows, err := ctx.Database.Query(ctx, "MATCH (n) RETURN count(n) AS c", ctx.Params)
+				if err != nil {
+					return nil, err
+				}
+				if len(rows) == 0 {
+					return &heimdall.ActionResult{Success: false, Message: "no rows"}, nil
+				}
+				ctx.Bifrost.SendNotification("info", "Counter", fmt.Sprintf("%v nodes", rows[0]["c"]))
+				return &heimdall.ActionResult{Success: true, Data: rows[0]}, nil
+			},
+		},
+	}
+}
+
+func (p *counterPlugin) RecentEvents(limit int) []heimdall.SubsystemEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]heimdall.SubsystemEvent(nil), p.events...)
+}
+
+func (p *counterPlugin) addEvent(eventType, message string) {
+	p.events = append(p.events, heimdall.SubsystemEvent{Time: time.Now(), Type: eventType, Message: message})
+}
+
+func (p *counterPlugin) PrePrompt(ctx *heimdall.PromptContext) error {
+	ctx.AdditionalInstructions += "Counts are exact."
+	ctx.NotifyInfo("Counter", "prompt seen")
+	if ctx.UserMessage == "" {
+		return errors.New("empty message")
+	}
+	return nil
+}
+
+func (p *counterPlugin) PreExecute(ctx *heimdall.PreExecuteContext, done func(heimdall.PreExecuteResult)) {
+	go func() {
+		if ctx.Params["limit"] == nil {
+			ctx.NotifyWarning("Counter", "limit missing")
+			done(heimdall.PreExecuteResult{Continue: false, AbortMessage: "limit required"})
+			return
+		}
+		done(heimdall.PreExecuteResult{Continue: true})
+	}()
+}
+
+func (p *counterPlugin) PostExecute(ctx *heimdall.PostExecuteContext) {
+	ctx.NotifySuccess("Counter", "done")
+}
+
+func (p *counterPlugin) OnDatabaseEvent(event *heimdall.DatabaseEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if event.Type != heimdall.EventQueryFailed {
+		return
+	}
+	p.failures++
+	p.addEvent("warning", "query failed")
+	if p.failures == 2 {
+		p.ctx.Heimdall.InvokeActionAsync("heimdall.counter.count", map[string]interface{}{"trigger": "failures"})
+	}
+}
+
+func TestHarnessLifecycle(t *testing.T) {
+	p := &counterPlugin{}
+	h := New(t, p)
+	assert.Empty(t, p.Status(), "New does not initialize")
+
+	h.Initialize()
+	assert.Equal(t, heimdall.StatusReady, p.Status())
+	assert.Equal(t, []LogEntry{{Level: "info", Message: "initialized"}}, h.Logger.Entries())
+
+	h.Start()
+	h.Stop()
+	h.Shutdown()
+	assert.Equal(t, heimdall.StatusUninitialized, p.Status())
+}
+
+func TestHarnessAction(t *testing.T) {
+	h := Start(t, &counterPlugin{})
+
+	result, err := h.Action("count", nil)
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+
+	h.Database.SetResult("MATCH (n) RETURN count(n) AS c", []map[string]interface{}{{"c": 3}})
+	result, err = h.Action("heimdall.counter.count", map[string]interface{}{"limit": 1})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 3, result.Data["c"])
+
+	n := h.AssertNotification("info", "Counter")
+	assert.Equal(t, "3 nodes", n.Message)
+	queries := h.Database.Queries()
+	require.Len(t, queries, 2)
+	assert.Equal(t, map[string]interface{}{"limit": 1}, queries[1].Params)
+
+	h.Database.QueryFunc = func(ctx context.Context, cypher string, params map[string]interface{}) ([]map[string]interface{}, error) {
+		return nil, errors.New("boom")
+	}
+	_, err = h.Action("count", nil)
+	assert.EqualError(t, err, "boom")
+}
+
+func TestHarnessHooks(t *testing.T) {
+	h := Start(t, &counterPlugin{})
+
+	t.Run("PrePrompt", func(t *testing.T) {
+		ctx := &heimdall.PromptContext{UserMessage: "how many nodes?"}
+		require.NoError(t, h.PrePrompt(ctx))
+		assert.Equal(t, "Counts are exact.", ctx.AdditionalInstructions)
+		assert.Equal(t, "test-request", ctx.RequestID)
+		assert.Equal(t, "prompt seen", h.AssertNotification("info", "Counter").Message)
+
+		assert.EqualError(t, h.PrePrompt(&heimdall.PromptContext{}), "empty message")
+	})
+
+	t.Run("PreExecute", func(t *testing.T) {
+		r := h.PreExecute(&heimdall.PreExecuteContext{Action: "heimdall.counter.count"})
+		assert.False(t, r.Continue)
+		assert.Equal(t, "limit required", r.AbortMessage)
+		h.AssertNotification("warning", "Counter")
+
+		r = h.PreExecute(&heimdall.PreExecuteContext{Params: map[string]interface{}{"limit": 5}})
+		assert.True(t, r.Continue)
+	})
+
+	t.Run("PostExecute", func(t *testing.T) {
+		h.PostExecute(&heimdall.PostExecuteContext{Result: &heimdall.ActionResult{Success: true}})
+		h.AssertNotification("success", "Counter")
+	})
+}
+
+func TestHarnessDatabaseEvents(t *testing.T) {
+	h := Start(t, &counterPlugin{})
+	h.Database.SetResult("MATCH (n) RETURN count(n) AS c", []map[string]interface{}{{"c": 7}})
+
+	h.DatabaseEvent(&heimdall.DatabaseEvent{Type: heimdall.EventNodeCreated})
+	assert.Empty(t, h.Plugin.RecentEvents(10))
+
+	h.DatabaseEvent(&heimdall.DatabaseEvent{Type: heimdall.EventQueryFailed})
+	h.DatabaseEvent(&heimdall.DatabaseEvent{Type: heimdall.EventQueryFailed})
+	h.AssertEvent("warning", "query failed")
+
+	// The autonomous invocation runs asynchronously against the same mocks
+	inv := h.AssertInvoked("count")
+	assert.True(t, inv.Async)
+	assert.Equal(t, "failures", inv.Params["trigger"])
+	assert.Equal(t, "7 nodes", h.AssertNotification("info", "Counter").Message)
+}
+
+func TestInvokerPrompts(t *testing.T) {
+	inv := NewInvoker(NewMockDatabase(), &MockMetrics{}, NewMockBifrost())
+
+	result, err := inv.SendPrompt("hello")
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+
+	inv.PromptFunc = func(prompt string) (*heimdall.ActionResult, error) {
+		return &heimdall.ActionResult{Success: true, Message: "re: " + prompt}, nil
+	}
+	var tokens []string
+	result, err = inv.SendPromptStream(context.Background(), "status", func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, []string{"re: status"}, tokens)
+
+	inv.SendPromptAsync("later")
+	inv.Wait()
+	assert.Equal(t, []string{"hello", "status", "later"}, inv.Prompts())
+
+	_, err = inv.InvokeAction("heimdall.missing.action", nil)
+	assert.Error(t, err)
+}
+
+func TestMockBifrost(t *testing.T) {
+	b := NewMockBifrost()
+	assert.True(t, b.IsConnected())
+	b.Connections = 0
+	assert.False(t, b.IsConnected())
+
+	b.OnlineUsers["alice"] = true
+	assert.True(t, b.IsUserOnline("alice"))
+	assert.False(t, b.IsUserOnline("bob"))
+
+	require.NoError(t, b.SendMessage("hi"))
+	require.NoError(t, b.Broadcast("all"))
+	require.NoError(t, b.SendTo("alice", "psst"))
+	assert.Equal(t, []Message{
+		{Content: "hi"},
+		{Content: "all", Broadcast: true},
+		{Content: "psst", UserID: "alice"},
+	}, b.Messages())
+
+	require.NoError(t, b.NotifyUser("alice", "info", "T", "M"))
+	require.NoError(t, b.Publish("ops", "warning", "T", "M"))
+	assert.Equal(t, []Notification{
+		{Type: "info", Title: "T", Message: "M", UserID: "alice"},
+		{Type: "warning", Title: "T", Message: "M", Topic: "ops"},
+	}, b.Notifications())
+
+	b.Confirm = true
+	ok, err := b.RequestConfirmation("heimdall.counter.count")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"heimdall.counter.count"}, b.Confirmations())
+}
@@ -0,0 +1,65 @@
+// Package heimdall - prompt packing: fitting many pieces of data, such as
+// documents to label or summarize, into SLM prompts. The SLM's context is
+// small (see MaxContextTokens), so each piece is truncated to its share of a
+// token budget and the pieces are batched so one Generate call handles
+// several.
+package heimdall
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TruncateToTokens shortens text to about tokens by EstimateTokens, cutting
+// at the last space when there is one nearby and marking the cut with "…".
+func TruncateToTokens(text string, tokens int) string {
+	if EstimateTokens(text) <= tokens {
+		return text
+	}
+	if tokens <= 0 {
+		return ""
+	}
+	cut := int(float64(tokens) / TokensPerChar)
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	if space := strings.LastIndexByte(text[:cut], ' '); space > cut*3/4 {
+		cut = space
+	}
+	return strings.TrimSpace(text[:cut]) + "…"
+}
+
+// PackBatches groups items, in order, into batches for prompts: the
+// estimated tokens of a batch's items add up to at most budget, and a batch
+// holds at most maxItems (0 = no limit). It returns the indexes of the
+// items in each batch. An item larger than budget is a batch of its own, so
+// truncate items with TruncateToTokens first to keep every batch within
+// budget.
+//
+// Example:
+//
+//	for _, batch := range heimdall.PackBatches(texts, 1500, 8) {
+//	    var prompt strings.Builder
+//	    for _, i := range batch {
+//	        prompt.WriteString(texts[i])
+//	    }
+//	    // one Generate call per batch
+//	}
+func PackBatches(items []string, budget, maxItems int) [][]int {
+	var batches [][]int
+	var batch []int
+	used := 0
+	for i, item := range items {
+		tokens := EstimateTokens(item)
+		if len(batch) > 0 && (used+tokens > budget || (maxItems > 0 && len(batch) >= maxItems)) {
+			batches = append(batches, batch)
+			batch, used = nil, 0
+		}
+		batch = append(batch, i)
+		used += tokens
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
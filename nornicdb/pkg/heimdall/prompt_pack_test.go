@@ -0,0 +1,37 @@
+package heimdall
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateToTokens(t *testing.T) {
+	assert.Equal(t, "short text", TruncateToTokens("short text", 10))
+	assert.Equal(t, "", TruncateToTokens("short text", 0))
+
+	long := strings.Repeat("word ", 100) // 500 chars, 125 tokens
+	truncated := TruncateToTokens(long, 10)
+	assert.Equal(t, "word word word word word word word word…", truncated, "cut at a space")
+	assert.LessOrEqual(t, EstimateTokens(truncated), 11)
+
+	// Never cuts inside a multi-byte character
+	truncated = TruncateToTokens(strings.Repeat("é", 100), 5)
+	assert.True(t, strings.HasSuffix(truncated, "…"))
+	assert.NotContains(t, truncated, "�")
+	assert.Equal(t, strings.Repeat("é", 10)+"…", truncated)
+}
+
+func TestPackBatches(t *testing.T) {
+	item := strings.Repeat("x", 40) // 10 tokens
+	items := []string{item, item, item, item, item}
+
+	assert.Equal(t, [][]int{{0, 1, 2}, {3, 4}}, PackBatches(items, 30, 0), "token budget")
+	assert.Equal(t, [][]int{{0, 1}, {2, 3}, {4}}, PackBatches(items, 100, 2), "max items")
+	assert.Equal(t, [][]int{{0, 1, 2, 3, 4}}, PackBatches(items, 100, 0))
+
+	big := strings.Repeat("x", 400) // 100 tokens
+	assert.Equal(t, [][]int{{0}, {1}, {2}}, PackBatches([]string{item, big, item}, 30, 0), "oversized item alone")
+	assert.Nil(t, PackBatches(nil, 30, 0))
+}
@@ -0,0 +1,433 @@
+// Package prompts keeps versioned prompt templates for Heimdall's autonomous
+// tasks, such as Auto-TLP quality control and memory consolidation, and
+// measures how each version performs.
+//
+// A template is text with {{variable}} placeholders. Each prompt name (a
+// task) has one or more versions; Select serves the active version, or
+// splits requests between two versions while an A/B experiment runs. The
+// caller reports every request with Record, and Stats compares the versions
+// by parse failure rate, approval rate and latency.
+//
+// Example:
+//
+//	reg := prompts.NewRegistry()
+//	reg.Register(prompts.Template{Name: "qc", Version: "v1", Text: qcV1})
+//	reg.Register(prompts.Template{Name: "qc", Version: "v2", Text: qcV2})
+//	reg.StartExperiment("qc", prompts.Experiment{A: "v1", B: "v2", Share: 0.5})
+//
+//	tmpl, _ := reg.Select("qc", batchKey)
+//	prompt, _ := tmpl.Render(map[string]string{"edges": edges})
+//	start := time.Now()
+//	answer, err := generate(ctx, prompt)
+//	reg.Record("qc", tmpl.Version, prompts.Outcome{Latency: time.Since(start), Err: err, Parsed: ok})
+//
+// Templates can also be loaded from a directory with LoadDir, so new
+// versions can be tried without a rebuild.
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownPrompt is returned for a prompt name with no versions.
+	ErrUnknownPrompt = errors.New("unknown prompt")
+	// ErrUnknownVersion is returned for a version not registered for a prompt.
+	ErrUnknownVersion = errors.New("unknown prompt version")
+	// ErrVersionExists is returned when a version is registered again with
+	// different text; versions are immutable so their metrics stay meaningful.
+	ErrVersionExists = errors.New("prompt version already registered")
+	// ErrMissingVariable is returned by Render for a placeholder without a value.
+	ErrMissingVariable = errors.New("missing prompt variable")
+)
+
+// Template is one version of a prompt.
+type Template struct {
+	Name    string `json:"name"`    // Prompt (task) name, e.g. "qc"
+	Version string `json:"version"` // e.g. "v2"
+	Text    string `json:"text"`    // Text with {{variable}} placeholders
+}
+
+// Render replaces each {{variable}} placeholder with its value in vars.
+// Placeholders without a value are an error; unused values are ignored.
+func (t Template) Render(vars map[string]string) (string, error) {
+	var sb strings.Builder
+	rest := t.Text
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			break
+		}
+		name := strings.TrimSpace(rest[start+2 : start+end])
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("%w %q in %s@%s", ErrMissingVariable, name, t.Name, t.Version)
+		}
+		sb.WriteString(rest[:start])
+		sb.WriteString(value)
+		rest = rest[start+end+2:]
+	}
+	sb.WriteString(rest)
+	return sb.String(), nil
+}
+
+// Variables lists the placeholders of the template in order of first use.
+func (t Template) Variables() []string {
+	var vars []string
+	seen := make(map[string]bool)
+	rest := t.Text
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			return vars
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			return vars
+		}
+		name := strings.TrimSpace(rest[start+2 : start+end])
+		if !seen[name] {
+			seen[name] = true
+			vars = append(vars, name)
+		}
+		rest = rest[start+end+2:]
+	}
+}
+
+// Experiment splits the requests of a prompt between versions A and B.
+type Experiment struct {
+	A     string  `json:"a"`
+	B     string  `json:"b"`
+	Share float64 `json:"share"` // Share of requests served by B, in (0, 1)
+}
+
+// Outcome is the result of one request made with a prompt version.
+type Outcome struct {
+	Latency time.Duration
+	Err     error // Generation failed; the output fields are not recorded
+
+	Parsed   bool // The output parsed as the expected format
+	Judged   int  // Items the output decided on, e.g. edges reviewed
+	Approved int  // Judged items the output approved
+}
+
+// VersionStats reports the requests made with one prompt version.
+type VersionStats struct {
+	Prompt           string  `json:"prompt"`
+	Version          string  `json:"version"`
+	Active           bool    `json:"active"`
+	Share            float64 `json:"share"` // Expected share of requests it serves
+	Requests         int64   `json:"requests"`
+	Errors           int64   `json:"errors"`
+	ParseFailures    int64   `json:"parse_failures"`
+	ParseFailureRate float64 `json:"parse_failure_rate"` // Of requests without errors
+	Judged           int64   `json:"judged"`
+	Approved         int64   `json:"approved"`
+	ApprovalRate     float64 `json:"approval_rate"` // Approved share of judged items
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+}
+
+// Registry holds prompt versions, the active version of each prompt and
+// running experiments. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	prompts map[string]*prompt
+}
+
+// prompt is the versions of one prompt name.
+type prompt struct {
+	versions   map[string]Template
+	active     string
+	experiment *Experiment
+	stats      map[string]*versionStats
+}
+
+// versionStats aggregates the outcomes of one version.
+type versionStats struct {
+	requests      int64
+	errors        int64
+	parseFailures int64
+	judged        int64
+	approved      int64
+	totalLatency  time.Duration
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{prompts: make(map[string]*prompt)}
+}
+
+// Register adds a version. The first version of a prompt becomes active.
+// Registering the same text again is a no-op.
+func (r *Registry) Register(t Template) error {
+	if t.Name == "" || t.Version == "" {
+		return fmt.Errorf("prompt template needs a name and a version")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.prompts[t.Name]
+	if !ok {
+		p = &prompt{
+			versions: make(map[string]Template),
+			active:   t.Version,
+			stats:    make(map[string]*versionStats),
+		}
+		r.prompts[t.Name] = p
+	}
+	if existing, ok := p.versions[t.Version]; ok {
+		if existing.Text != t.Text {
+			return fmt.Errorf("%w: %s@%s", ErrVersionExists, t.Name, t.Version)
+		}
+		return nil
+	}
+	p.versions[t.Version] = t
+	p.stats[t.Version] = &versionStats{}
+	return nil
+}
+
+// Get returns a registered version.
+func (r *Registry) Get(name, version string) (Template, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, ok := r.prompts[name]; ok {
+		t, ok := p.versions[version]
+		return t, ok
+	}
+	return Template{}, false
+}
+
+// Activate makes version the one Select serves outside experiments.
+func (r *Registry) Activate(name, version string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, err := r.versionLocked(name, version)
+	if err != nil {
+		return err
+	}
+	p.active = version
+	return nil
+}
+
+// StartExperiment splits the requests of name between two versions,
+// replacing any running experiment.
+func (r *Registry) StartExperiment(name string, exp Experiment) error {
+	if exp.A == exp.B {
+		return fmt.Errorf("experiment on %s needs two different versions", name)
+	}
+	if exp.Share <= 0 || exp.Share >= 1 {
+		return fmt.Errorf("experiment on %s: share %v must be between 0 and 1", name, exp.Share)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.versionLocked(name, exp.A); err != nil {
+		return err
+	}
+	p, err := r.versionLocked(name, exp.B)
+	if err != nil {
+		return err
+	}
+	p.experiment = &exp
+	return nil
+}
+
+// StopExperiment ends the experiment on name; Select serves the active
+// version again.
+func (r *Registry) StopExperiment(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.prompts[name]; ok {
+		p.experiment = nil
+	}
+}
+
+// versionLocked returns the prompt after checking version is registered.
+// r.mu must be held.
+func (r *Registry) versionLocked(name, version string) (*prompt, error) {
+	p, ok := r.prompts[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPrompt, name)
+	}
+	if _, ok := p.versions[version]; !ok {
+		return nil, fmt.Errorf("%w: %s@%s", ErrUnknownVersion, name, version)
+	}
+	return p, nil
+}
+
+// Select returns the version to use for a request. During an experiment
+// the same non-empty key always gets the same version, so retries and
+// cached work stay on one arm; an empty key picks a version at random.
+func (r *Registry) Select(name, key string) (Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.prompts[name]
+	if !ok {
+		return Template{}, fmt.Errorf("%w: %s", ErrUnknownPrompt, name)
+	}
+	version := p.active
+	if exp := p.experiment; exp != nil {
+		version = exp.A
+		if bucket(key) < exp.Share {
+			version = exp.B
+		}
+	}
+	return p.versions[version], nil
+}
+
+// bucket maps key to [0, 1): by hash, or at random for an empty key.
+func bucket(key string) float64 {
+	if key == "" {
+		return rand.Float64()
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return float64(h.Sum32()) / (1 << 32)
+}
+
+// Record adds the outcome of a request made with a version. Unknown
+// versions are ignored.
+func (r *Registry) Record(name, version string, o Outcome) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.prompts[name]
+	if !ok {
+		return
+	}
+	s, ok := p.stats[version]
+	if !ok {
+		return
+	}
+	s.requests++
+	s.totalLatency += o.Latency
+	if o.Err != nil {
+		s.errors++
+		return
+	}
+	if !o.Parsed {
+		s.parseFailures++
+	}
+	s.judged += int64(o.Judged)
+	s.approved += int64(o.Approved)
+}
+
+// Stats returns the metrics of every version, sorted by prompt and version.
+func (r *Registry) Stats() []VersionStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []VersionStats
+	for name, p := range r.prompts {
+		for version, s := range p.stats {
+			vs := VersionStats{
+				Prompt:        name,
+				Version:       version,
+				Active:        version == p.active,
+				Requests:      s.requests,
+				Errors:        s.errors,
+				ParseFailures: s.parseFailures,
+				Judged:        s.judged,
+				Approved:      s.approved,
+			}
+			switch {
+			case p.experiment != nil && version == p.experiment.A:
+				vs.Share = 1 - p.experiment.Share
+			case p.experiment != nil && version == p.experiment.B:
+				vs.Share = p.experiment.Share
+			case p.experiment == nil && vs.Active:
+				vs.Share = 1
+			}
+			if answered := s.requests - s.errors; answered > 0 {
+				vs.ParseFailureRate = float64(s.parseFailures) / float64(answered)
+			}
+			if s.judged > 0 {
+				vs.ApprovalRate = float64(s.approved) / float64(s.judged)
+			}
+			if s.requests > 0 {
+				vs.AvgLatencyMs = float64(s.totalLatency) / float64(time.Millisecond) / float64(s.requests)
+			}
+			result = append(result, vs)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Prompt != result[j].Prompt {
+			return result[i].Prompt < result[j].Prompt
+		}
+		return result[i].Version < result[j].Version
+	})
+	return result
+}
+
+// LoadDir registers the templates in dir, laid out as <name>/<version>.txt,
+// and returns how many it read.
+func (r *Registry) LoadDir(dir string) (int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.txt"))
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(files)
+	for i, file := range files {
+		text, err := os.ReadFile(file)
+		if err != nil {
+			return i, fmt.Errorf("reading prompt template: %w", err)
+		}
+		t := Template{
+			Name:    filepath.Base(filepath.Dir(file)),
+			Version: strings.TrimSuffix(filepath.Base(file), ".txt"),
+			Text:    strings.TrimRight(string(text), "\n"),
+		}
+		if err := r.Register(t); err != nil {
+			return i, err
+		}
+	}
+	return len(files), nil
+}
+
+// ParseVersions parses "name=version" entries, e.g. "qc=v2".
+func ParseVersions(entries []string) (map[string]string, error) {
+	versions := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		name, version, ok := strings.Cut(entry, "=")
+		name, version = strings.TrimSpace(name), strings.TrimSpace(version)
+		if !ok || name == "" || version == "" {
+			return nil, fmt.Errorf("invalid prompt version %q: expected name=version", entry)
+		}
+		versions[name] = version
+	}
+	return versions, nil
+}
+
+// ParseExperiments parses "name=a:b[:share]" entries, e.g. "qc=v1:v2:0.2"
+// sends a fifth of qc requests to v2. The share defaults to 0.5.
+func ParseExperiments(entries []string) (map[string]Experiment, error) {
+	experiments := make(map[string]Experiment, len(entries))
+	for _, entry := range entries {
+		name, spec, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		parts := strings.Split(spec, ":")
+		if !ok || name == "" || len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid prompt experiment %q: expected name=a:b[:share]", entry)
+		}
+		exp := Experiment{A: strings.TrimSpace(parts[0]), B: strings.TrimSpace(parts[1]), Share: 0.5}
+		if len(parts) == 3 {
+			share, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid prompt experiment %q: %w", entry, err)
+			}
+			exp.Share = share
+		}
+		experiments[name] = exp
+	}
+	return experiments, nil
+}
@@ -0,0 +1,157 @@
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	tmpl := Template{Name: "qc", Version: "v1", Text: `Review {{ edges }} for {{source}}. Output {"approved":[]} and {{edges}}.`}
+
+	got, err := tmpl.Render(map[string]string{"edges": "E", "source": "S", "unused": "x"})
+	require.NoError(t, err)
+	assert.Equal(t, `Review E for S. Output {"approved":[]} and E.`, got)
+	assert.Equal(t, []string{"edges", "source"}, tmpl.Variables())
+
+	_, err = tmpl.Render(map[string]string{"edges": "E"})
+	assert.True(t, errors.Is(err, ErrMissingVariable))
+
+	plain := Template{Text: "no placeholders {{ unterminated"}
+	got, err = plain.Render(nil)
+	require.NoError(t, err)
+	assert.Equal(t, plain.Text, got)
+}
+
+func TestRegister(t *testing.T) {
+	reg := NewRegistry()
+	require.NoError(t, reg.Register(Template{Name: "qc", Version: "v1", Text: "one"}))
+	require.NoError(t, reg.Register(Template{Name: "qc", Version: "v2", Text: "two"}))
+	require.NoError(t, reg.Register(Template{Name: "qc", Version: "v1", Text: "one"}))
+
+	err := reg.Register(Template{Name: "qc", Version: "v1", Text: "changed"})
+	assert.True(t, errors.Is(err, ErrVersionExists))
+	assert.Error(t, reg.Register(Template{Name: "qc"}))
+
+	// The first version is active
+	tmpl, err := reg.Select("qc", "key")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", tmpl.Version)
+
+	require.NoError(t, reg.Activate("qc", "v2"))
+	tmpl, _ = reg.Select("qc", "key")
+	assert.Equal(t, "two", tmpl.Text)
+
+	assert.True(t, errors.Is(reg.Activate("qc", "v3"), ErrUnknownVersion))
+	_, err = reg.Select("missing", "")
+	assert.True(t, errors.Is(err, ErrUnknownPrompt))
+
+	got, ok := reg.Get("qc", "v1")
+	assert.True(t, ok)
+	assert.Equal(t, "one", got.Text)
+}
+
+func TestExperiment(t *testing.T) {
+	reg := NewRegistry()
+	require.NoError(t, reg.Register(Template{Name: "qc", Version: "v1", Text: "one"}))
+	require.NoError(t, reg.Register(Template{Name: "qc", Version: "v2", Text: "two"}))
+
+	assert.Error(t, reg.StartExperiment("qc", Experiment{A: "v1", B: "v1", Share: 0.5}))
+	assert.Error(t, reg.StartExperiment("qc", Experiment{A: "v1", B: "v2", Share: 1}))
+	assert.True(t, errors.Is(reg.StartExperiment("qc", Experiment{A: "v1", B: "v9", Share: 0.5}), ErrUnknownVersion))
+	require.NoError(t, reg.StartExperiment("qc", Experiment{A: "v1", B: "v2", Share: 0.25}))
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		key := fmt.Sprintf("batch-%d", i)
+		tmpl, err := reg.Select("qc", key)
+		require.NoError(t, err)
+		counts[tmpl.Version]++
+
+		// The same key stays on one arm
+		again, _ := reg.Select("qc", key)
+		assert.Equal(t, tmpl.Version, again.Version)
+	}
+	assert.InDelta(t, 1000, counts["v2"], 150)
+
+	reg.StopExperiment("qc")
+	for i := 0; i < 20; i++ {
+		tmpl, _ := reg.Select("qc", fmt.Sprintf("batch-%d", i))
+		assert.Equal(t, "v1", tmpl.Version)
+	}
+}
+
+func TestStats(t *testing.T) {
+	reg := NewRegistry()
+	require.NoError(t, reg.Register(Template{Name: "qc", Version: "v1", Text: "one"}))
+	require.NoError(t, reg.Register(Template{Name: "qc", Version: "v2", Text: "two"}))
+	require.NoError(t, reg.StartExperiment("qc", Experiment{A: "v1", B: "v2", Share: 0.5}))
+
+	reg.Record("qc", "v1", Outcome{Latency: 10 * time.Millisecond, Parsed: true, Judged: 4, Approved: 3})
+	reg.Record("qc", "v1", Outcome{Latency: 30 * time.Millisecond, Parsed: false, Judged: 4, Approved: 4})
+	reg.Record("qc", "v1", Outcome{Latency: 20 * time.Millisecond, Err: errors.New("timeout")})
+	reg.Record("qc", "v2", Outcome{Latency: 5 * time.Millisecond, Parsed: true, Judged: 2, Approved: 1})
+	reg.Record("qc", "v9", Outcome{Parsed: true}) // Ignored
+	reg.Record("none", "v1", Outcome{Parsed: true})
+
+	stats := reg.Stats()
+	require.Len(t, stats, 2)
+	v1, v2 := stats[0], stats[1]
+	assert.Equal(t, "v1", v1.Version)
+	assert.True(t, v1.Active)
+	assert.Equal(t, 0.5, v1.Share)
+	assert.Equal(t, int64(3), v1.Requests)
+	assert.Equal(t, int64(1), v1.Errors)
+	assert.Equal(t, int64(1), v1.ParseFailures)
+	assert.Equal(t, 0.5, v1.ParseFailureRate)
+	assert.Equal(t, 7.0/8, v1.ApprovalRate)
+	assert.InDelta(t, 20, v1.AvgLatencyMs, 0.001)
+
+	assert.Equal(t, "v2", v2.Version)
+	assert.False(t, v2.Active)
+	assert.Equal(t, 0.5, v2.ApprovalRate)
+	assert.Equal(t, 0.0, v2.ParseFailureRate)
+
+	reg.StopExperiment("qc")
+	stats = reg.Stats()
+	assert.Equal(t, 1.0, stats[0].Share)
+	assert.Equal(t, 0.0, stats[1].Share)
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "qc"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "qc", "v2.txt"), []byte("Review {{edges}}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "qc", "notes.md"), []byte("ignored"), 0o644))
+
+	reg := NewRegistry()
+	n, err := reg.LoadDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	tmpl, ok := reg.Get("qc", "v2")
+	require.True(t, ok)
+	assert.Equal(t, "Review {{edges}}", tmpl.Text)
+}
+
+func TestParseVersionsAndExperiments(t *testing.T) {
+	versions, err := ParseVersions([]string{"qc=v2", " consolidation = v3 "})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"qc": "v2", "consolidation": "v3"}, versions)
+	_, err = ParseVersions([]string{"qc"})
+	assert.Error(t, err)
+
+	exps, err := ParseExperiments([]string{"qc=v1:v2", "consolidation=v1:v2:0.2"})
+	require.NoError(t, err)
+	assert.Equal(t, Experiment{A: "v1", B: "v2", Share: 0.5}, exps["qc"])
+	assert.Equal(t, Experiment{A: "v1", B: "v2", Share: 0.2}, exps["consolidation"])
+	for _, bad := range []string{"qc=v1", "qc=v1:v2:x", "=v1:v2", "qc=a:b:c:d"} {
+		_, err := ParseExperiments([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
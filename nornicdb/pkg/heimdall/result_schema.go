@@ -0,0 +1,254 @@
+// Package heimdall - result schemas for rich rendering of action results.
+//
+// ActionResult.Data is an untyped map, which clients can only show as JSON.
+// An action that declares a ResultSchema tells them what the data is: which
+// key holds the rows, the columns and their types, and how to draw it.
+// Successful results are checked against the schema when the action runs;
+// a matching result carries the schema to the client, a mismatch is logged
+// and the result is sent without one, so clients fall back to raw JSON
+// instead of rendering something wrong.
+//
+//	"queries": {
+//		Handler: p.actionQueries,
+//		Schema: &heimdall.ResultSchema{
+//			Visualization: heimdall.VisualizationTable,
+//			Rows:          "queries",
+//			Columns: []heimdall.ResultColumn{
+//				{Key: "id", Type: heimdall.ColumnString},
+//				{Key: "query", Type: heimdall.ColumnString},
+//				{Key: "elapsed_ms", Label: "Elapsed", Type: heimdall.ColumnDuration},
+//			},
+//		},
+//	},
+package heimdall
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Visualization suggests how a client should present a result.
+type Visualization string
+
+const (
+	VisualizationTable     Visualization = "table"      // Rows as a table
+	VisualizationBarChart  Visualization = "bar_chart"  // Rows as bars: X labels, Y values
+	VisualizationLineChart Visualization = "line_chart" // Rows as lines: X along the axis, one line per Y
+	VisualizationKeyValue  Visualization = "key_value"  // Top-level Data keys as a property list
+)
+
+// ColumnType is the type of a result column's values, as they appear in
+// the JSON sent to clients.
+type ColumnType string
+
+const (
+	ColumnAny       ColumnType = ""            // Not checked
+	ColumnString    ColumnType = "string"      // JSON string
+	ColumnInteger   ColumnType = "integer"     // Whole JSON number
+	ColumnNumber    ColumnType = "number"      // JSON number
+	ColumnBoolean   ColumnType = "boolean"     // JSON boolean
+	ColumnTimestamp ColumnType = "timestamp"   // RFC 3339 string or Unix seconds
+	ColumnDuration  ColumnType = "duration_ms" // Number of milliseconds
+	ColumnBytes     ColumnType = "bytes"       // Number of bytes
+	ColumnObject    ColumnType = "object"      // JSON object
+	ColumnList      ColumnType = "list"        // JSON array
+)
+
+// ResultColumn describes one value of each row (or, for key-value
+// results, one top-level Data key).
+type ResultColumn struct {
+	Key      string     `json:"key"`
+	Label    string     `json:"label,omitempty"` // Display name, Key if empty
+	Type     ColumnType `json:"type,omitempty"`
+	Optional bool       `json:"optional,omitempty"` // May be missing or null
+}
+
+// ResultSchema describes the Data of an action's successful results.
+type ResultSchema struct {
+	Visualization Visualization `json:"visualization"`
+
+	// Rows is the Data key holding the list of row objects. Required for
+	// tables and charts; key-value results describe Data itself.
+	Rows string `json:"rows,omitempty"`
+
+	Columns []ResultColumn `json:"columns"`
+
+	// Charts: X is the column along the axis (or labelling the bars), Y
+	// the numeric columns plotted against it.
+	X string   `json:"x,omitempty"`
+	Y []string `json:"y,omitempty"`
+}
+
+// Check reports whether the schema itself is consistent.
+func (s *ResultSchema) Check() error {
+	columns := make(map[string]ColumnType, len(s.Columns))
+	for _, c := range s.Columns {
+		if c.Key == "" {
+			return fmt.Errorf("column key is required")
+		}
+		if _, dup := columns[c.Key]; dup {
+			return fmt.Errorf("duplicate column %q", c.Key)
+		}
+		if _, ok := columnCheckers[c.Type]; !ok {
+			return fmt.Errorf("column %q: unknown type %q", c.Key, c.Type)
+		}
+		columns[c.Key] = c.Type
+	}
+	if len(s.Columns) == 0 {
+		return fmt.Errorf("at least one column is required")
+	}
+
+	switch s.Visualization {
+	case VisualizationKeyValue:
+		if s.Rows != "" {
+			return fmt.Errorf("key_value results describe Data itself, rows must be empty")
+		}
+		return nil
+	case VisualizationTable:
+	case VisualizationBarChart, VisualizationLineChart:
+		if _, ok := columns[s.X]; !ok {
+			return fmt.Errorf("%s: x must name a column", s.Visualization)
+		}
+		if len(s.Y) == 0 {
+			return fmt.Errorf("%s: y is required", s.Visualization)
+		}
+		for _, y := range s.Y {
+			switch t, ok := columns[y]; {
+			case !ok:
+				return fmt.Errorf("%s: y column %q is not defined", s.Visualization, y)
+			case t != ColumnInteger && t != ColumnNumber && t != ColumnDuration && t != ColumnBytes:
+				return fmt.Errorf("%s: y column %q must be numeric", s.Visualization, y)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown visualization %q", s.Visualization)
+	}
+	if s.Rows == "" {
+		return fmt.Errorf("%s: rows is required", s.Visualization)
+	}
+	return nil
+}
+
+// Validate reports whether data matches the schema. Data is checked in its
+// JSON form, as clients receive it.
+func (s *ResultSchema) Validate(data map[string]interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("data is not JSON serializable: %w", err)
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return err
+	}
+
+	if s.Visualization == VisualizationKeyValue {
+		return s.validateRow(normalized)
+	}
+
+	rows, ok := normalized[s.Rows].([]interface{})
+	if !ok {
+		if normalized[s.Rows] == nil {
+			return nil // No rows: an empty table
+		}
+		return fmt.Errorf("data[%q] is not a list", s.Rows)
+	}
+	for i, r := range rows {
+		row, ok := r.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("row %d is not an object", i)
+		}
+		if err := s.validateRow(row); err != nil {
+			return fmt.Errorf("row %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (s *ResultSchema) validateRow(row map[string]interface{}) error {
+	for _, c := range s.Columns {
+		v := row[c.Key]
+		if v == nil {
+			if c.Optional {
+				continue
+			}
+			return fmt.Errorf("column %q is missing", c.Key)
+		}
+		if !columnCheckers[c.Type](v) {
+			return fmt.Errorf("column %q: %v is not %s", c.Key, v, c.Type)
+		}
+	}
+	return nil
+}
+
+var columnCheckers = map[ColumnType]func(v interface{}) bool{
+	ColumnAny:    func(interface{}) bool { return true },
+	ColumnString: isJSONString,
+	ColumnInteger: func(v interface{}) bool {
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	},
+	ColumnNumber:  isJSONNumber,
+	ColumnBoolean: func(v interface{}) bool { _, ok := v.(bool); return ok },
+	ColumnTimestamp: func(v interface{}) bool {
+		if s, ok := v.(string); ok {
+			_, err := time.Parse(time.RFC3339Nano, s)
+			return err == nil
+		}
+		return isJSONNumber(v)
+	},
+	ColumnDuration: isJSONNumber,
+	ColumnBytes:    isJSONNumber,
+	ColumnObject:   func(v interface{}) bool { _, ok := v.(map[string]interface{}); return ok },
+	ColumnList:     func(v interface{}) bool { _, ok := v.([]interface{}); return ok },
+}
+
+func isJSONString(v interface{}) bool { _, ok := v.(string); return ok }
+func isJSONNumber(v interface{}) bool { _, ok := v.(float64); return ok }
+
+// ValidateActionResult checks a successful result against the schema it
+// carries, or else the action's. Failed results and results without a
+// schema always pass.
+func ValidateActionResult(action ActionFunc, result *ActionResult) error {
+	if result == nil || !result.Success {
+		return nil
+	}
+	schema := result.Schema
+	if schema != nil {
+		if err := schema.Check(); err != nil {
+			return fmt.Errorf("invalid result schema: %w", err)
+		}
+	} else if schema = action.Schema; schema == nil {
+		return nil
+	}
+	return schema.Validate(result.Data)
+}
+
+// attachResultSchema sets the schema of a successful result that matches
+// it and clears it otherwise.
+func attachResultSchema(action ActionFunc, result *ActionResult) {
+	if result == nil || !result.Success {
+		return
+	}
+	if err := ValidateActionResult(action, result); err != nil {
+		fmt.Printf("[Heimdall] Result of %s does not match its schema, sending it without: %v\n", action.Name, err)
+		result.Schema = nil
+		return
+	}
+	if result.Schema == nil {
+		result.Schema = action.Schema
+	}
+}
+
+// checkActionSchema drops an inconsistent schema from action so clients
+// never receive it.
+func checkActionSchema(action *ActionFunc) {
+	if action.Schema == nil {
+		return
+	}
+	if err := action.Schema.Check(); err != nil {
+		fmt.Printf("[Heimdall] Ignoring result schema of %s: %v\n", action.Name, err)
+		action.Schema = nil
+	}
+}
@@ -0,0 +1,219 @@
+package heimdall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var queriesSchema = &ResultSchema{
+	Visualization: VisualizationTable,
+	Rows:          "queries",
+	Columns: []ResultColumn{
+		{Key: "id", Type: ColumnString},
+		{Key: "user", Type: ColumnString, Optional: true},
+		{Key: "elapsed_ms", Type: ColumnDuration},
+		{Key: "rows", Type: ColumnInteger},
+	},
+}
+
+func TestResultSchema_Check(t *testing.T) {
+	columns := []ResultColumn{{Key: "name", Type: ColumnString}, {Key: "count", Type: ColumnInteger}}
+	tests := []struct {
+		name   string
+		schema ResultSchema
+		err    string
+	}{
+		{"table", ResultSchema{Visualization: VisualizationTable, Rows: "items", Columns: columns}, ""},
+		{"key value", ResultSchema{Visualization: VisualizationKeyValue, Columns: columns}, ""},
+		{"bar chart", ResultSchema{Visualization: VisualizationBarChart, Rows: "items", Columns: columns, X: "name", Y: []string{"count"}}, ""},
+		{"unknown visualization", ResultSchema{Visualization: "pie", Rows: "items", Columns: columns}, "unknown visualization"},
+		{"no columns", ResultSchema{Visualization: VisualizationTable, Rows: "items"}, "at least one column"},
+		{"no rows", ResultSchema{Visualization: VisualizationTable, Columns: columns}, "rows is required"},
+		{"key value rows", ResultSchema{Visualization: VisualizationKeyValue, Rows: "items", Columns: columns}, "rows must be empty"},
+		{"empty key", ResultSchema{Visualization: VisualizationTable, Rows: "items", Columns: []ResultColumn{{Type: ColumnString}}}, "key is required"},
+		{"duplicate column", ResultSchema{Visualization: VisualizationTable, Rows: "items", Columns: append(columns, columns[0])}, "duplicate column"},
+		{"unknown type", ResultSchema{Visualization: VisualizationTable, Rows: "items", Columns: []ResultColumn{{Key: "a", Type: "uuid"}}}, "unknown type"},
+		{"chart without x", ResultSchema{Visualization: VisualizationLineChart, Rows: "items", Columns: columns, Y: []string{"count"}}, "x must name a column"},
+		{"chart without y", ResultSchema{Visualization: VisualizationLineChart, Rows: "items", Columns: columns, X: "name"}, "y is required"},
+		{"undefined y", ResultSchema{Visualization: VisualizationBarChart, Rows: "items", Columns: columns, X: "name", Y: []string{"total"}}, "not defined"},
+		{"string y", ResultSchema{Visualization: VisualizationBarChart, Rows: "items", Columns: columns, X: "count", Y: []string{"name"}}, "must be numeric"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.Check()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+func TestResultSchema_Validate(t *testing.T) {
+	// Rows are checked in their JSON form, so structs work
+	assert.NoError(t, queriesSchema.Validate(map[string]interface{}{
+		"queries": []RunningQuery{{ID: "q1", Query: "MATCH (n) RETURN n", ElapsedMs: 12, Rows: 3}},
+	}))
+	assert.NoError(t, queriesSchema.Validate(map[string]interface{}{"queries": nil}), "no rows")
+	assert.NoError(t, queriesSchema.Validate(map[string]interface{}{}), "no rows")
+
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		err  string
+	}{
+		{"rows not a list", map[string]interface{}{"queries": "none"}, "not a list"},
+		{"row not an object", map[string]interface{}{"queries": []int{1}}, "row 0 is not an object"},
+		{"missing column", map[string]interface{}{"queries": []map[string]interface{}{{"id": "q1", "rows": 1}}}, `column "elapsed_ms" is missing`},
+		{"wrong type", map[string]interface{}{"queries": []map[string]interface{}{{"id": 1, "elapsed_ms": 1, "rows": 1}}}, `column "id"`},
+		{"fractional integer", map[string]interface{}{"queries": []map[string]interface{}{{"id": "q1", "elapsed_ms": 1.5, "rows": 1.5}}}, `column "rows"`},
+		{"not serializable", map[string]interface{}{"queries": make(chan int)}, "not JSON serializable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := queriesSchema.Validate(tt.data)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestResultSchema_ValidateKeyValue(t *testing.T) {
+	schema := &ResultSchema{
+		Visualization: VisualizationKeyValue,
+		Columns: []ResultColumn{
+			{Key: "started", Type: ColumnTimestamp},
+			{Key: "healthy", Type: ColumnBoolean},
+			{Key: "labels", Type: ColumnObject},
+			{Key: "uptime", Type: ColumnAny, Optional: true},
+		},
+	}
+	assert.NoError(t, schema.Validate(map[string]interface{}{
+		"started": time.Now(),
+		"healthy": true,
+		"labels":  map[string]int64{"User": 3},
+	}))
+	assert.NoError(t, schema.Validate(map[string]interface{}{
+		"started": 1700000000,
+		"healthy": false,
+		"labels":  map[string]int64{},
+		"uptime":  "3h",
+	}))
+	err := schema.Validate(map[string]interface{}{"started": "yesterday", "healthy": true, "labels": map[string]int64{}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `column "started"`)
+}
+
+func TestExecuteAction_AttachesSchema(t *testing.T) {
+	globalManager = nil
+	defer func() { globalManager = nil }()
+
+	var data map[string]interface{}
+	RegisterBuiltinAction(ActionFunc{
+		Name:    "heimdall.test.queries",
+		Schema:  queriesSchema,
+		Handler: func(ActionContext) (*ActionResult, error) { return &ActionResult{Success: true, Data: data}, nil },
+	})
+	RegisterBuiltinAction(ActionFunc{
+		Name:    "heimdall.test.broken",
+		Schema:  &ResultSchema{Visualization: VisualizationTable},
+		Handler: func(ActionContext) (*ActionResult, error) { return &ActionResult{Success: true}, nil },
+	})
+
+	data = map[string]interface{}{"queries": []RunningQuery{{ID: "q1", ElapsedMs: 5}}}
+	result, err := ExecuteAction("heimdall.test.queries", ActionContext{Context: context.Background()})
+	require.NoError(t, err)
+	assert.Same(t, queriesSchema, result.Schema)
+
+	// A mismatching result is still returned, without the schema
+	data = map[string]interface{}{"queries": []map[string]interface{}{{"id": 7}}}
+	result, err = ExecuteAction("heimdall.test.queries", ActionContext{Context: context.Background()})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Nil(t, result.Schema)
+
+	// An inconsistent schema is dropped when the action is registered
+	actions := ActionCatalog()["general"]
+	for _, a := range actions {
+		if a.Name == "heimdall.test.broken" {
+			assert.Nil(t, a.Schema)
+		}
+	}
+	result, err = ExecuteAction("heimdall.test.broken", ActionContext{Context: context.Background()})
+	require.NoError(t, err)
+	assert.Nil(t, result.Schema)
+}
+
+func TestValidateActionResult(t *testing.T) {
+	action := ActionFunc{Schema: queriesSchema}
+	assert.NoError(t, ValidateActionResult(action, nil))
+	assert.NoError(t, ValidateActionResult(action, &ActionResult{Success: false, Data: map[string]interface{}{"queries": 1}}),
+		"failed results are not checked")
+	assert.NoError(t, ValidateActionResult(ActionFunc{}, &ActionResult{Success: true, Data: map[string]interface{}{"queries": 1}}))
+
+	// A handler's own schema takes precedence
+	own := &ResultSchema{Visualization: VisualizationKeyValue, Columns: []ResultColumn{{Key: "total", Type: ColumnInteger}}}
+	assert.NoError(t, ValidateActionResult(action, &ActionResult{Success: true, Schema: own, Data: map[string]interface{}{"total": 2}}))
+	err := ValidateActionResult(action, &ActionResult{Success: true, Schema: &ResultSchema{}, Data: map[string]interface{}{}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid result schema")
+}
+
+func TestHandler_StreamingActionResult(t *testing.T) {
+	globalManager = nil
+	defer func() { globalManager = nil }()
+
+	mockGen := NewMockGenerator("/test/model.gguf")
+	manager := newTestManager(mockGen)
+	handler := testHandler(manager, manager.config)
+	RegisterBuiltinAction(ActionFunc{
+		Name:        "heimdall.test.queries",
+		Description: "List queries",
+		Schema:      queriesSchema,
+		Handler: func(ActionContext) (*ActionResult, error) {
+			return &ActionResult{Success: true, Message: "1 query", Data: map[string]interface{}{
+				"queries": []RunningQuery{{ID: "q1", ElapsedMs: 5}},
+			}}, nil
+		},
+	})
+	mockGen.streamFunc = func(ctx context.Context, prompt string, params GenerateParams, callback func(string) error) error {
+		return callback(`{"action": "heimdall.test.queries", "params": {}}`)
+	}
+
+	body, _ := json.Marshal(ChatRequest{Stream: true, Messages: []ChatMessage{{Role: "user", Content: "running queries"}}})
+	req := httptest.NewRequest(http.MethodPost, "/api/bifrost/chat/completions", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var output *ActionOutput
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		payload, ok := strings.CutPrefix(line, "data: ")
+		if !ok || payload == "[DONE]" {
+			continue
+		}
+		var chunk ChatResponse
+		require.NoError(t, json.Unmarshal([]byte(payload), &chunk))
+		if chunk.ActionResult != nil {
+			require.Nil(t, output, "one chunk carries the result")
+			output = chunk.ActionResult
+		}
+	}
+	require.NotNil(t, output)
+	assert.Equal(t, "heimdall.test.queries", output.Action)
+	require.NotNil(t, output.Schema)
+	assert.Equal(t, VisualizationTable, output.Schema.Visualization)
+	assert.Equal(t, "queries", output.Schema.Rows)
+	assert.Len(t, output.Data["queries"], 1)
+}
@@ -0,0 +1,540 @@
+// Package heimdall - threshold rules for autonomous actions.
+//
+// A Rule pairs a trigger with a response. Event rules count the database
+// events matching an EventSubscription and fire when Threshold of them
+// arrive within the window. Metric rules compare a RuntimeMetrics field
+// (by its JSON name) against Threshold and fire once the comparison has
+// held for the whole window. Firing invokes Action, sends Prompt to the
+// SLM and/or notifies Bifrost clients:
+//
+//	{
+//	  "name": "query_failures",
+//	  "event": {"types": ["query.failed"]},
+//	  "threshold": 5,
+//	  "window_seconds": 300,
+//	  "action": "heimdall.watcher.status",
+//	  "params": {"reason": "query_failures", "failures": "{count}"}
+//	}
+//
+//	{
+//	  "name": "goroutine_spike",
+//	  "metric": "goroutine_count",
+//	  "operator": ">",
+//	  "threshold": 20000,
+//	  "window_seconds": 120,
+//	  "prompt": "{value} goroutines for two minutes. What is leaking?"
+//	}
+//
+// Prompt, notification text and string params may use the placeholders
+// {rule}, {count}, {value}, {threshold} and {window}. A param that is
+// exactly "{count}" or "{value}" receives the number itself.
+//
+// A RuleEngine holds the rules of one owner (usually a plugin), feeds them
+// from OnDatabaseEvent and Evaluate, and persists runtime changes to a JSON
+// file so they survive restarts.
+package heimdall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule defaults.
+const (
+	DefaultRuleWindow   = time.Minute
+	DefaultRuleOperator = ">"
+)
+
+// Rule is a declarative trigger → response pair. Exactly one of Event and
+// Metric must be set, and at least one of Action, Prompt and Notify.
+type Rule struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// Event makes this an event rule: Threshold matching events (default 1)
+	// within the window fire it, after which counting starts over.
+	Event *EventSubscription `json:"event,omitempty"`
+
+	// Metric makes this a metric rule: it fires when the RuntimeMetrics
+	// field compares true against Threshold for the whole window, once per
+	// breach. Operator is one of > >= < <= == != (default >).
+	Metric   string `json:"metric,omitempty"`
+	Operator string `json:"operator,omitempty"`
+
+	Threshold       float64 `json:"threshold"`
+	WindowSeconds   float64 `json:"window_seconds,omitempty"`   // Default 60 for event rules, 0 (fire at once) for metric rules
+	CooldownSeconds float64 `json:"cooldown_seconds,omitempty"` // Minimum time between firings
+
+	// Responses
+	Action string                 `json:"action,omitempty"` // Invoked asynchronously with Params
+	Params map[string]interface{} `json:"params,omitempty"`
+	Prompt string                 `json:"prompt,omitempty"` // Sent to the SLM
+	Notify *RuleNotification      `json:"notify,omitempty"` // Sent to connected Bifrost clients
+
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// RuleNotification is the Bifrost notification a rule sends when it fires.
+type RuleNotification struct {
+	Type    string `json:"type"` // info, success, warning, error
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+}
+
+// Window returns the rule's window.
+func (r *Rule) Window() time.Duration {
+	if r.WindowSeconds <= 0 && r.Event != nil {
+		return DefaultRuleWindow
+	}
+	return time.Duration(r.WindowSeconds * float64(time.Second))
+}
+
+// Validate reports whether the rule is complete and consistent.
+func (r *Rule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("rule name is required")
+	}
+	if (r.Event == nil) == (r.Metric == "") {
+		return fmt.Errorf("rule %s: exactly one of event and metric is required", r.Name)
+	}
+	if r.Metric != "" {
+		if !runtimeMetricNames[r.Metric] {
+			return fmt.Errorf("rule %s: unknown metric %q", r.Name, r.Metric)
+		}
+		if _, ok := ruleOperators[r.operator()]; !ok {
+			return fmt.Errorf("rule %s: unknown operator %q", r.Name, r.Operator)
+		}
+	}
+	if r.Threshold < 0 || r.WindowSeconds < 0 || r.CooldownSeconds < 0 {
+		return fmt.Errorf("rule %s: threshold, window and cooldown must not be negative", r.Name)
+	}
+	if r.Action == "" && r.Prompt == "" && r.Notify == nil {
+		return fmt.Errorf("rule %s: one of action, prompt and notify is required", r.Name)
+	}
+	if r.Notify != nil && r.Notify.Message == "" {
+		return fmt.Errorf("rule %s: notify message is required", r.Name)
+	}
+	return nil
+}
+
+func (r *Rule) operator() string {
+	if r.Operator == "" {
+		return DefaultRuleOperator
+	}
+	return r.Operator
+}
+
+// eventThreshold returns the number of events that fire an event rule.
+func (r *Rule) eventThreshold() int64 {
+	if r.Threshold < 1 {
+		return 1
+	}
+	return int64(r.Threshold)
+}
+
+var ruleOperators = map[string]func(value, threshold float64) bool{
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
+
+// runtimeMetricNames are the JSON names of the RuntimeMetrics fields.
+var runtimeMetricNames = func() map[string]bool {
+	names := make(map[string]bool)
+	t := reflect.TypeOf(RuntimeMetrics{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		names[name] = true
+	}
+	return names
+}()
+
+// RuleFiring describes one firing of a rule.
+type RuleFiring struct {
+	Rule  string    `json:"rule"`
+	Time  time.Time `json:"time"`
+	Count int64     `json:"count,omitempty"` // Matching events in the window (event rules)
+	Value float64   `json:"value,omitempty"` // Metric value (metric rules)
+}
+
+// RuleStatus is a rule with its current state.
+type RuleStatus struct {
+	Rule
+	Count     int64      `json:"count"`                // Matching events in the current window
+	Breached  bool       `json:"breached,omitempty"`   // Metric condition currently holds
+	Fired     int64      `json:"fired"`                // Firings since the engine started
+	LastFired *time.Time `json:"last_fired,omitempty"` // Most recent firing
+}
+
+// RuleEngineConfig configures a RuleEngine. Every field is optional.
+type RuleEngineConfig struct {
+	// Invoker runs rule actions and prompts.
+	Invoker HeimdallInvoker
+
+	// Bifrost receives rule notifications while clients are connected.
+	Bifrost BifrostBridge
+
+	// Path is the JSON file rules are loaded from and saved to ("" keeps
+	// them in memory).
+	Path string
+
+	// Defaults are the rules used until the file at Path exists.
+	Defaults []Rule
+
+	// OnFire is called after each firing, e.g. to record it.
+	OnFire func(RuleFiring)
+}
+
+// RuleEngine evaluates threshold rules. It is safe for concurrent use.
+type RuleEngine struct {
+	config RuleEngineConfig
+
+	mu    sync.RWMutex
+	rules map[string]*ruleState
+}
+
+type ruleState struct {
+	rule   Rule
+	filter *eventFilter
+	events RateCounter // event rules
+
+	breachSince time.Time // metric rules: when the condition started holding
+	breachFired bool      // metric rules: fired during the current breach
+	value       float64
+
+	fired     int64
+	lastFired time.Time
+}
+
+// NewRuleEngine creates an engine holding cfg.Defaults. Invalid defaults
+// are skipped. Call Load to replace them with the rules saved at cfg.Path.
+func NewRuleEngine(cfg RuleEngineConfig) *RuleEngine {
+	e := &RuleEngine{config: cfg, rules: make(map[string]*ruleState)}
+	for _, r := range cfg.Defaults {
+		if r.Validate() == nil {
+			e.rules[r.Name] = newRuleState(r)
+		}
+	}
+	return e
+}
+
+func newRuleState(r Rule) *ruleState {
+	s := &ruleState{rule: r}
+	if r.Event != nil {
+		s.filter = compileEventFilter(*r.Event)
+		s.events.SetWindow(r.Window())
+	}
+	return s
+}
+
+// Load replaces the rules with those saved at the configured path. A
+// missing file keeps the current rules.
+func (e *RuleEngine) Load() error {
+	if e.config.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(e.config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parse rules file %s: %w", e.config.Path, err)
+	}
+	rules := make(map[string]*ruleState, len(file.Rules))
+	for _, r := range file.Rules {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("rules file %s: %w", e.config.Path, err)
+		}
+		rules[r.Name] = newRuleState(r)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+	return nil
+}
+
+// Rules returns the rules with their state, sorted by name.
+func (e *RuleEngine) Rules() []RuleStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	result := make([]RuleStatus, 0, len(e.rules))
+	for _, s := range e.rules {
+		status := RuleStatus{Rule: s.rule, Count: s.events.Count(), Fired: s.fired}
+		if s.rule.Metric != "" {
+			status.Breached = !s.breachSince.IsZero()
+		}
+		if !s.lastFired.IsZero() {
+			last := s.lastFired
+			status.LastFired = &last
+		}
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// SetRule adds a rule or replaces the rule with the same name, resetting
+// its state, and saves the rules.
+func (e *RuleEngine) SetRule(r Rule) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	previous, existed := e.rules[r.Name]
+	e.rules[r.Name] = newRuleState(r)
+	if err := e.saveLocked(); err != nil {
+		if existed {
+			e.rules[r.Name] = previous
+		} else {
+			delete(e.rules, r.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteRule removes a rule and saves the rules. It reports whether the
+// rule existed.
+func (e *RuleEngine) DeleteRule(name string) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	previous, ok := e.rules[name]
+	if !ok {
+		return false, nil
+	}
+	delete(e.rules, name)
+	if err := e.saveLocked(); err != nil {
+		e.rules[name] = previous
+		return false, err
+	}
+	return true, nil
+}
+
+// saveLocked writes the rules to the configured path. e.mu must be held.
+func (e *RuleEngine) saveLocked() error {
+	if e.config.Path == "" {
+		return nil
+	}
+	rules := make([]Rule, 0, len(e.rules))
+	for _, s := range e.rules {
+		rules = append(rules, s.rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	data, err := json.MarshalIndent(map[string]interface{}{"rules": rules}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(e.config.Path), 0o700); err != nil {
+		return fmt.Errorf("save rules: %w", err)
+	}
+	tmp := e.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("save rules: %w", err)
+	}
+	if err := os.Rename(tmp, e.config.Path); err != nil {
+		return fmt.Errorf("save rules: %w", err)
+	}
+	return nil
+}
+
+// EventTypes returns the event types the enabled event rules match, for
+// building an EventSubscription. all is true if some rule matches every
+// type.
+func (e *RuleEngine) EventTypes() (types []DatabaseEventType, all bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	seen := make(map[DatabaseEventType]bool)
+	for _, s := range e.rules {
+		if s.rule.Event == nil || s.rule.Disabled {
+			continue
+		}
+		if len(s.rule.Event.Types) == 0 {
+			all = true
+		}
+		for _, t := range s.rule.Event.Types {
+			if !seen[t] {
+				seen[t] = true
+				types = append(types, t)
+			}
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types, all
+}
+
+// OnDatabaseEvent counts event for the matching event rules and fires
+// those that reach their threshold.
+func (e *RuleEngine) OnDatabaseEvent(event *DatabaseEvent) {
+	now := time.Now()
+	var due []*ruleState
+
+	e.mu.RLock()
+	for _, s := range e.rules {
+		if s.rule.Event == nil || s.rule.Disabled || !s.filter.matches(event) {
+			continue
+		}
+		s.events.Inc()
+		if s.events.Count() >= s.rule.eventThreshold() {
+			due = append(due, s)
+		}
+	}
+	e.mu.RUnlock()
+
+	for _, s := range due {
+		e.mu.Lock()
+		// Re-check: a concurrent event may have fired the rule already
+		count := s.events.Count()
+		fire := count >= s.rule.eventThreshold() && s.coolingDownUntil().Before(now)
+		if fire {
+			s.events.ResetWindow()
+			s.fired++
+			s.lastFired = now
+		}
+		rule := s.rule
+		e.mu.Unlock()
+
+		if fire {
+			e.fire(rule, RuleFiring{Rule: rule.Name, Time: now, Count: count})
+		}
+	}
+}
+
+// Evaluate checks the metric rules against metrics and fires those whose
+// condition has held for their window. Call it periodically.
+func (e *RuleEngine) Evaluate(metrics RuntimeMetrics) {
+	e.evaluateAt(metrics, time.Now())
+}
+
+func (e *RuleEngine) evaluateAt(metrics RuntimeMetrics, now time.Time) {
+	values := runtimeMetricValues(metrics)
+	var firings []RuleFiring
+	var rules []Rule
+
+	e.mu.Lock()
+	for _, s := range e.rules {
+		if s.rule.Metric == "" || s.rule.Disabled {
+			continue
+		}
+		value, reported := values[s.rule.Metric]
+		if !reported || !ruleOperators[s.rule.operator()](value, s.rule.Threshold) {
+			s.breachSince, s.breachFired = time.Time{}, false
+			continue
+		}
+		if s.breachSince.IsZero() {
+			s.breachSince = now
+		}
+		s.value = value
+		if s.breachFired || now.Sub(s.breachSince) < s.rule.Window() || s.coolingDownUntil().After(now) {
+			continue
+		}
+		s.breachFired = true
+		s.fired++
+		s.lastFired = now
+		firings = append(firings, RuleFiring{Rule: s.rule.Name, Time: now, Value: value})
+		rules = append(rules, s.rule)
+	}
+	e.mu.Unlock()
+
+	for i := range firings {
+		e.fire(rules[i], firings[i])
+	}
+}
+
+// coolingDownUntil returns when the rule may fire again.
+func (s *ruleState) coolingDownUntil() time.Time {
+	if s.lastFired.IsZero() {
+		return time.Time{}
+	}
+	return s.lastFired.Add(time.Duration(s.rule.CooldownSeconds * float64(time.Second)))
+}
+
+// runtimeMetricValues returns the reported RuntimeMetrics fields by JSON
+// name. Optional fields the host left zero are not reported.
+func runtimeMetricValues(metrics RuntimeMetrics) map[string]float64 {
+	values := make(map[string]float64)
+	v := reflect.ValueOf(metrics)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, opts, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		field := v.Field(i)
+		if opts == "omitempty" && field.IsZero() {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			values[name] = float64(field.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			values[name] = float64(field.Uint())
+		case reflect.Float32, reflect.Float64:
+			values[name] = field.Float()
+		}
+	}
+	return values
+}
+
+// fire delivers a rule's responses.
+func (e *RuleEngine) fire(rule Rule, firing RuleFiring) {
+	render := strings.NewReplacer(
+		"{rule}", rule.Name,
+		"{count}", strconv.FormatInt(firing.Count, 10),
+		"{value}", strconv.FormatFloat(firing.Value, 'f', -1, 64),
+		"{threshold}", strconv.FormatFloat(rule.Threshold, 'f', -1, 64),
+		"{window}", rule.Window().String(),
+	).Replace
+
+	if rule.Action != "" && e.config.Invoker != nil {
+		params := map[string]interface{}{"trigger": "rule"}
+		for k, v := range rule.Params {
+			switch s, _ := v.(string); s {
+			case "{count}":
+				params[k] = firing.Count
+			case "{value}":
+				params[k] = firing.Value
+			case "":
+				params[k] = v
+			default:
+				params[k] = render(s)
+			}
+		}
+		params["rule"] = rule.Name
+		e.config.Invoker.InvokeActionAsync(rule.Action, params)
+	}
+	if rule.Prompt != "" && e.config.Invoker != nil {
+		e.config.Invoker.SendPromptAsync(render(rule.Prompt))
+	}
+	// Notify even without connected clients: the bridge may route alerts
+	// elsewhere, such as to webhooks
+	if n := rule.Notify; n != nil && e.config.Bifrost != nil {
+		title := n.Title
+		if title == "" {
+			title = "Rule: " + rule.Name
+		}
+		_ = e.config.Bifrost.SendNotification(n.Type, render(title), render(n.Message))
+	}
+	if e.config.OnFire != nil {
+		e.config.OnFire(firing)
+	}
+}
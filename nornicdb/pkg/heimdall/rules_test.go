@@ -0,0 +1,251 @@
+package heimdall
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingInvoker records async actions and prompts synchronously.
+type recordingInvoker struct {
+	mu      sync.Mutex
+	actions []recordedInvocation
+	prompts []string
+}
+
+type recordedInvocation struct {
+	Action string
+	Params map[string]interface{}
+}
+
+func (i *recordingInvoker) InvokeAction(action string, params map[string]interface{}) (*ActionResult, error) {
+	i.InvokeActionAsync(action, params)
+	return &ActionResult{Success: true}, nil
+}
+
+func (i *recordingInvoker) SendPrompt(prompt string) (*ActionResult, error) {
+	i.SendPromptAsync(prompt)
+	return &ActionResult{Success: true}, nil
+}
+
+func (i *recordingInvoker) SendPromptStream(ctx context.Context, prompt string, onToken func(token string) error) (*ActionResult, error) {
+	return i.SendPrompt(prompt)
+}
+
+func (i *recordingInvoker) InvokeActionAsync(action string, params map[string]interface{}) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.actions = append(i.actions, recordedInvocation{action, params})
+}
+
+func (i *recordingInvoker) SendPromptAsync(prompt string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.prompts = append(i.prompts, prompt)
+}
+
+func TestRule_Validate(t *testing.T) {
+	event := &EventSubscription{Types: []DatabaseEventType{EventQueryFailed}}
+	tests := []struct {
+		name string
+		rule Rule
+		err  string
+	}{
+		{"event rule", Rule{Name: "r", Event: event, Action: "heimdall.x.y"}, ""},
+		{"metric rule", Rule{Name: "r", Metric: "goroutine_count", Operator: ">=", Prompt: "p"}, ""},
+		{"no name", Rule{Event: event, Action: "a"}, "name is required"},
+		{"no trigger", Rule{Name: "r", Action: "a"}, "exactly one of event and metric"},
+		{"both triggers", Rule{Name: "r", Event: event, Metric: "num_gc", Action: "a"}, "exactly one of event and metric"},
+		{"unknown metric", Rule{Name: "r", Metric: "cpu", Action: "a"}, "unknown metric"},
+		{"unknown operator", Rule{Name: "r", Metric: "num_gc", Operator: "~", Action: "a"}, "unknown operator"},
+		{"negative window", Rule{Name: "r", Event: event, WindowSeconds: -1, Action: "a"}, "must not be negative"},
+		{"no response", Rule{Name: "r", Event: event}, "one of action, prompt and notify"},
+		{"empty notification", Rule{Name: "r", Event: event, Notify: &RuleNotification{Type: "info"}}, "notify message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+func TestRuleEngine_EventRule(t *testing.T) {
+	invoker := &recordingInvoker{}
+	var firings []RuleFiring
+	e := NewRuleEngine(RuleEngineConfig{
+		Invoker: invoker,
+		OnFire:  func(f RuleFiring) { firings = append(firings, f) },
+		Defaults: []Rule{{
+			Name:          "failures",
+			Event:         &EventSubscription{Types: []DatabaseEventType{EventQueryFailed}},
+			Threshold:     3,
+			WindowSeconds: 300,
+			Action:        "heimdall.watcher.status",
+			Params:        map[string]interface{}{"trigger": "autonomous", "failures": "{count}", "note": "{rule} over {window}"},
+			Prompt:        "{count} failures (threshold {threshold})",
+		}},
+	})
+
+	e.OnDatabaseEvent(&DatabaseEvent{Type: EventQueryExecuted})
+	e.OnDatabaseEvent(&DatabaseEvent{Type: EventQueryFailed})
+	e.OnDatabaseEvent(&DatabaseEvent{Type: EventQueryFailed})
+	assert.Empty(t, invoker.actions)
+	assert.Equal(t, int64(2), e.Rules()[0].Count)
+
+	e.OnDatabaseEvent(&DatabaseEvent{Type: EventQueryFailed})
+	require.Len(t, invoker.actions, 1)
+	assert.Equal(t, "heimdall.watcher.status", invoker.actions[0].Action)
+	assert.Equal(t, map[string]interface{}{
+		"trigger":  "autonomous",
+		"rule":     "failures",
+		"failures": int64(3),
+		"note":     "failures over 5m0s",
+	}, invoker.actions[0].Params)
+	assert.Equal(t, []string{"3 failures (threshold 3)"}, invoker.prompts)
+	require.Len(t, firings, 1)
+	assert.Equal(t, int64(3), firings[0].Count)
+
+	status := e.Rules()[0]
+	assert.Zero(t, status.Count, "the window starts over after firing")
+	assert.Equal(t, int64(1), status.Fired)
+	assert.NotNil(t, status.LastFired)
+}
+
+func TestRuleEngine_EventRuleCooldown(t *testing.T) {
+	invoker := &recordingInvoker{}
+	e := NewRuleEngine(RuleEngineConfig{Invoker: invoker, Defaults: []Rule{{
+		Name:            "creations",
+		Event:           &EventSubscription{Types: []DatabaseEventType{EventNodeCreated}, Labels: []string{"User"}},
+		CooldownSeconds: 3600,
+		Action:          "heimdall.x.y",
+	}}})
+
+	e.OnDatabaseEvent(&DatabaseEvent{Type: EventNodeCreated, NodeLabels: []string{"Post"}})
+	assert.Empty(t, invoker.actions, "labels must match")
+
+	e.OnDatabaseEvent(&DatabaseEvent{Type: EventNodeCreated, NodeLabels: []string{"User"}})
+	e.OnDatabaseEvent(&DatabaseEvent{Type: EventNodeCreated, NodeLabels: []string{"User"}})
+	assert.Len(t, invoker.actions, 1, "cooldown suppresses the second firing")
+	assert.Equal(t, int64(1), e.Rules()[0].Count)
+}
+
+func TestRuleEngine_MetricRule(t *testing.T) {
+	bifrost := NewMockBifrost()
+	e := NewRuleEngine(RuleEngineConfig{Bifrost: bifrost, Defaults: []Rule{{
+		Name:          "wal_lag",
+		Metric:        "wal_sync_lag_ms",
+		Operator:      ">=",
+		Threshold:     1000,
+		WindowSeconds: 30,
+		Notify:        &RuleNotification{Type: "warning", Message: "WAL lag {value}ms"},
+	}}})
+	start := time.Now()
+	at := func(seconds int, lag int64) {
+		e.evaluateAt(RuntimeMetrics{WALSyncLagMs: lag}, start.Add(time.Duration(seconds)*time.Second))
+	}
+
+	at(0, 1500)
+	at(20, 2000)
+	assert.Empty(t, bifrost.notifications, "condition has not held for the window yet")
+	assert.True(t, e.Rules()[0].Breached)
+
+	at(30, 2500)
+	require.Len(t, bifrost.notifications, 1)
+	assert.Equal(t, "Rule: wal_lag", bifrost.notifications[0].Title)
+	assert.Equal(t, "WAL lag 2500ms", bifrost.notifications[0].Message)
+
+	at(60, 3000)
+	assert.Len(t, bifrost.notifications, 1, "fires once per breach")
+
+	// Unreported metrics clear the breach like a healthy value
+	at(70, 0)
+	assert.False(t, e.Rules()[0].Breached)
+	at(80, 1200)
+	at(110, 1200)
+	assert.Len(t, bifrost.notifications, 2)
+
+	// Notifications reach the bridge without connected clients, so it can
+	// route them to webhooks
+	bifrost.connected = false
+	at(120, 0)
+	at(130, 5000)
+	at(160, 5000)
+	assert.Len(t, bifrost.notifications, 3)
+	assert.Equal(t, int64(3), e.Rules()[0].Fired)
+}
+
+func TestRuleEngine_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heimdall", "rules.json")
+	defaults := []Rule{{Name: "default", Event: &EventSubscription{}, Action: "a"}}
+
+	e := NewRuleEngine(RuleEngineConfig{Path: path, Defaults: defaults})
+	require.NoError(t, e.Load(), "a missing file keeps the defaults")
+	assert.Len(t, e.Rules(), 1)
+
+	require.NoError(t, e.SetRule(Rule{Name: " gc ", Metric: "num_gc", Threshold: 100, Prompt: "p"}))
+	deleted, err := e.DeleteRule("default")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = e.DeleteRule("default")
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	assert.Error(t, e.SetRule(Rule{Name: "bad"}))
+
+	loaded := NewRuleEngine(RuleEngineConfig{Path: path, Defaults: defaults})
+	require.NoError(t, loaded.Load())
+	rules := loaded.Rules()
+	require.Len(t, rules, 1)
+	assert.Equal(t, "gc", rules[0].Name)
+	assert.Equal(t, 100.0, rules[0].Threshold)
+
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+	assert.Error(t, loaded.Load())
+	assert.Len(t, loaded.Rules(), 1, "a bad file leaves the rules alone")
+}
+
+func TestRuleEngine_SaveFailureReverts(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(blocker, nil, 0o600))
+
+	// The rules directory cannot be created below a regular file
+	e := NewRuleEngine(RuleEngineConfig{
+		Path:     filepath.Join(blocker, "rules.json"),
+		Defaults: []Rule{{Name: "keep", Event: &EventSubscription{}, Action: "a"}},
+	})
+	assert.Error(t, e.SetRule(Rule{Name: "new", Event: &EventSubscription{}, Action: "a"}))
+	_, err := e.DeleteRule("keep")
+	assert.Error(t, err)
+
+	rules := e.Rules()
+	require.Len(t, rules, 1)
+	assert.Equal(t, "keep", rules[0].Name)
+}
+
+func TestRuleEngine_EventTypes(t *testing.T) {
+	e := NewRuleEngine(RuleEngineConfig{Defaults: []Rule{
+		{Name: "a", Event: &EventSubscription{Types: []DatabaseEventType{EventNodeCreated, EventQueryFailed}}, Action: "x"},
+		{Name: "b", Event: &EventSubscription{Types: []DatabaseEventType{EventNodeCreated}}, Action: "x"},
+		{Name: "c", Event: &EventSubscription{}, Action: "x", Disabled: true},
+		{Name: "d", Metric: "num_gc", Action: "x"},
+	}})
+	types, all := e.EventTypes()
+	assert.False(t, all)
+	assert.Equal(t, []DatabaseEventType{EventNodeCreated, EventQueryFailed}, types)
+
+	require.NoError(t, e.SetRule(Rule{Name: "c", Event: &EventSubscription{}, Action: "x"}))
+	_, all = e.EventTypes()
+	assert.True(t, all)
+}
@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall/prompts"
 )
 
 // Manager handles Heimdall SLM model loading and inference.
@@ -19,12 +22,19 @@ import (
 //   - NORNICDB_HEIMDALL_MODEL: Model name (default: qwen2.5-1.5b-instruct-q4_k_m)
 //   - NORNICDB_HEIMDALL_GPU_LAYERS: GPU layer offload (-1=auto, 0=CPU only)
 //   - NORNICDB_HEIMDALL_ENABLED: Feature flag (default: false)
+//
+// Config.ModelRoutes can give tasks their own models (see TaskType); each
+// model is loaded once and listed by Models.
 type Manager struct {
 	mu        sync.RWMutex
-	generator Generator
+	generator Generator // Default model (Config.Model)
 	config    Config
 	modelPath string
 	closed    bool
+	models    map[string]*registeredModel // Model registry by name
+	routes    map[TaskType]string         // Task -> model name
+	tasks     map[TaskType]*taskStats
+	prompts   *prompts.Registry // Prompt versions, reported in Stats
 
 	// Stats
 	requestCount int64
@@ -47,34 +57,9 @@ func NewManager(cfg Config) (*Manager, error) {
 	if modelName == "" {
 		modelName = "qwen2.5-0.5b-instruct"
 	}
-	modelFile := modelName + ".gguf"
-
-	// Resolve model path - check where the actual model file exists
-	modelsDir := cfg.ModelsDir
-	if modelsDir == "" {
-		modelsDir = os.Getenv("NORNICDB_MODELS_DIR")
-	}
-	if modelsDir == "" {
-		// Check common model locations - look for the actual model file
-		candidates := []string{
-			"/app/models",  // Docker container (embedded models)
-			"/data/models", // Mounted volume
-			"./models",     // Local development
-		}
-		for _, dir := range candidates {
-			fullPath := filepath.Join(dir, modelFile)
-			if _, err := os.Stat(fullPath); err == nil {
-				modelsDir = dir
-				fmt.Printf("   Found Heimdall model at: %s\n", fullPath)
-				break
-			}
-		}
-		if modelsDir == "" {
-			modelsDir = "/data/models" // Final fallback for error message
-		}
-	}
+	cfg.Model = modelName
 
-	modelPath := filepath.Join(modelsDir, modelFile)
+	modelsDir, modelPath := resolveModelPath(cfg.ModelsDir, modelName)
 
 	// Check if model file exists
 	if _, err := os.Stat(modelPath); os.IsNotExist(err) {
@@ -111,29 +96,115 @@ func NewManager(cfg Config) (*Manager, error) {
 	fmt.Printf("   Context: %d tokens, Batch: %d tokens (single-shot mode)\n", contextSize, batchSize)
 
 	// Load the model - this uses the stub for now, will be replaced with CGO impl
-	generator, err := loadGenerator(modelPath, gpuLayers, contextSize, batchSize)
+	generator, err := loadGeneratorWithFallback(modelPath, gpuLayers, contextSize, batchSize)
 	if err != nil {
-		// Try CPU fallback
-		fmt.Printf("⚠️  GPU loading failed, trying CPU fallback: %v\n", err)
-		generator, err = loadGenerator(modelPath, 0, contextSize, batchSize) // 0 = CPU only
-		if err != nil {
-			return nil, fmt.Errorf("failed to load SLM model: %w", err)
-		}
-		fmt.Printf("✅ SLM model loaded on CPU (slower but functional)\n")
-	} else {
-		fmt.Printf("✅ SLM model loaded: %s\n", modelName)
+		return nil, err
 	}
+	fmt.Printf("✅ SLM model loaded: %s\n", modelName)
 
 	// Log token budget allocation
 	fmt.Printf("   Token budget: %dK context = %dK system + %dK user (multi-batch prefill)\n",
 		MaxContextTokens/1024, MaxSystemPromptTokens/1024, MaxUserMessageTokens/1024)
 
-	return &Manager{
+	m := &Manager{
 		generator: generator,
 		config:    cfg,
 		modelPath: modelPath,
 		lastUsed:  time.Now(),
-	}, nil
+		models: map[string]*registeredModel{
+			modelName: {info: modelInfo(modelName, modelPath, ModelTypeReasoning), generator: generator},
+		},
+		routes: make(map[TaskType]string),
+	}
+
+	// Load the models routed to tasks. A model that fails to load leaves its
+	// tasks on the default model; embedding models are only recorded, as
+	// the embedding subsystem loads them.
+	tasks := make([]TaskType, 0, len(cfg.ModelRoutes))
+	for task := range cfg.ModelRoutes {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i] < tasks[j] })
+	for _, task := range tasks {
+		name := cfg.ModelRoutes[task]
+		if name == "" || name == modelName {
+			continue
+		}
+		if task == TaskEmbedding {
+			if _, ok := m.models[name]; !ok {
+				m.models[name] = &registeredModel{info: ModelInfo{Name: name, Type: ModelTypeEmbedding}}
+			}
+			m.routes[task] = name
+			continue
+		}
+		if _, ok := m.models[name]; !ok {
+			_, path := resolveModelPath(modelsDir, name)
+			gen, err := loadGeneratorWithFallback(path, gpuLayers, contextSize, batchSize)
+			if err != nil {
+				fmt.Printf("⚠️  Heimdall model for %s unavailable, using %s: %v\n", task, modelName, err)
+				continue
+			}
+			fmt.Printf("✅ SLM model loaded for %s: %s\n", task, name)
+			m.models[name] = &registeredModel{info: modelInfo(name, path, taskModelTypes[task]), generator: gen}
+		}
+		m.routes[task] = name
+	}
+
+	return m, nil
+}
+
+// resolveModelPath returns the models directory and the path of a model's
+// GGUF file. An empty modelsDir uses NORNICDB_MODELS_DIR or the first
+// common location holding the file.
+func resolveModelPath(modelsDir, modelName string) (string, string) {
+	modelFile := modelName + ".gguf"
+	if modelsDir == "" {
+		modelsDir = os.Getenv("NORNICDB_MODELS_DIR")
+	}
+	if modelsDir == "" {
+		// Check common model locations - look for the actual model file
+		candidates := []string{
+			"/app/models",  // Docker container (embedded models)
+			"/data/models", // Mounted volume
+			"./models",     // Local development
+		}
+		for _, dir := range candidates {
+			fullPath := filepath.Join(dir, modelFile)
+			if _, err := os.Stat(fullPath); err == nil {
+				modelsDir = dir
+				fmt.Printf("   Found Heimdall model at: %s\n", fullPath)
+				break
+			}
+		}
+		if modelsDir == "" {
+			modelsDir = "/data/models" // Final fallback for error message
+		}
+	}
+	return modelsDir, filepath.Join(modelsDir, modelFile)
+}
+
+// loadGeneratorWithFallback loads a model on the GPU, retrying on the CPU.
+func loadGeneratorWithFallback(modelPath string, gpuLayers, contextSize, batchSize int) (Generator, error) {
+	generator, err := loadGenerator(modelPath, gpuLayers, contextSize, batchSize)
+	if err != nil {
+		// Try CPU fallback
+		fmt.Printf("⚠️  GPU loading failed, trying CPU fallback: %v\n", err)
+		generator, err = loadGenerator(modelPath, 0, contextSize, batchSize) // 0 = CPU only
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SLM model: %w", err)
+		}
+		fmt.Printf("✅ SLM model loaded on CPU (slower but functional)\n")
+	}
+	return generator, nil
+}
+
+// modelInfo describes a loaded GGUF model for the registry.
+func modelInfo(name, path string, modelType ModelType) ModelInfo {
+	info := ModelInfo{Name: name, Path: path, Type: modelType, Loaded: true, LastUsed: time.Now()}
+	if st, err := os.Stat(path); err == nil {
+		info.SizeBytes = st.Size()
+	}
+	return info
 }
 
 // GeneratorLoader is a function type for loading generators.
@@ -167,64 +238,16 @@ func loadGenerator(modelPath string, gpuLayers, contextSize, batchSize int) (Gen
 	return generatorLoader(modelPath, gpuLayers, contextSize, batchSize)
 }
 
-// Generate produces a response for the given prompt.
+// Generate produces a response for the given prompt with the model
+// routed to TaskChat.
 func (m *Manager) Generate(ctx context.Context, prompt string, params GenerateParams) (string, error) {
-	m.mu.RLock()
-	if m.closed {
-		m.mu.RUnlock()
-		return "", fmt.Errorf("manager is closed")
-	}
-	gen := m.generator
-	m.mu.RUnlock()
-
-	if gen == nil {
-		return "", fmt.Errorf("no generator loaded")
-	}
-
-	m.mu.Lock()
-	m.requestCount++
-	m.lastUsed = time.Now()
-	m.mu.Unlock()
-
-	result, err := gen.Generate(ctx, prompt, params)
-	if err != nil {
-		m.mu.Lock()
-		m.errorCount++
-		m.mu.Unlock()
-		return "", err
-	}
-
-	return result, nil
+	return m.GenerateFor(ctx, TaskChat, prompt, params)
 }
 
-// GenerateStream produces tokens via callback.
+// GenerateStream produces tokens via callback with the model routed to
+// TaskChat.
 func (m *Manager) GenerateStream(ctx context.Context, prompt string, params GenerateParams, callback func(token string) error) error {
-	m.mu.RLock()
-	if m.closed {
-		m.mu.RUnlock()
-		return fmt.Errorf("manager is closed")
-	}
-	gen := m.generator
-	m.mu.RUnlock()
-
-	if gen == nil {
-		return fmt.Errorf("no generator loaded")
-	}
-
-	m.mu.Lock()
-	m.requestCount++
-	m.lastUsed = time.Now()
-	m.mu.Unlock()
-
-	err := gen.GenerateStream(ctx, prompt, params, callback)
-	if err != nil {
-		m.mu.Lock()
-		m.errorCount++
-		m.mu.Unlock()
-		return err
-	}
-
-	return nil
+	return m.GenerateStreamFor(ctx, TaskChat, prompt, params, callback)
 }
 
 // Chat handles chat completion requests.
@@ -269,11 +292,23 @@ func (m *Manager) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, err
 
 // Stats returns current manager statistics.
 type ManagerStats struct {
-	ModelPath    string    `json:"model_path"`
-	RequestCount int64     `json:"request_count"`
-	ErrorCount   int64     `json:"error_count"`
-	LastUsed     time.Time `json:"last_used"`
-	Enabled      bool      `json:"enabled"`
+	ModelPath    string      `json:"model_path"`
+	RequestCount int64       `json:"request_count"`
+	ErrorCount   int64       `json:"error_count"`
+	LastUsed     time.Time   `json:"last_used"`
+	Enabled      bool        `json:"enabled"`
+	Models       []ModelInfo `json:"models,omitempty"` // Model registry
+	Tasks        []TaskStats `json:"tasks,omitempty"`
+
+	Prompts []prompts.VersionStats `json:"prompts,omitempty"` // Prompt version metrics
+}
+
+// ModelPath returns the path of the loaded model, so a Manager can serve as
+// the Generator of a LiveHeimdallInvoker.
+func (m *Manager) ModelPath() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.modelPath
 }
 
 func (m *Manager) Stats() ManagerStats {
@@ -286,6 +321,9 @@ func (m *Manager) Stats() ManagerStats {
 		ErrorCount:   m.errorCount,
 		LastUsed:     m.lastUsed,
 		Enabled:      true,
+		Models:       m.modelsLocked(),
+		Tasks:        m.taskStatsLocked(),
+		Prompts:      m.promptStatsLocked(),
 	}
 }
 
@@ -299,13 +337,25 @@ func (m *Manager) Close() error {
 	}
 	m.closed = true
 
+	// Close models routed to tasks; the default model is closed last
+	var firstErr error
+	for name, rm := range m.models {
+		if rm.generator != nil && rm.generator != m.generator {
+			if err := rm.generator.Close(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("close model %s: %w", name, err)
+			}
+		}
+	}
+
 	if m.generator != nil {
 		fmt.Printf("🧠 Closing SLM model\n")
 		fmt.Printf("   Total requests: %d\n", m.requestCount)
 		fmt.Printf("   Total errors: %d\n", m.errorCount)
-		return m.generator.Close()
+		if err := m.generator.Close(); err != nil {
+			return err
+		}
 	}
-	return nil
+	return firstErr
 }
 
 // idCounter provides unique IDs even within the same nanosecond.
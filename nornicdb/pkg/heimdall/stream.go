@@ -0,0 +1,134 @@
+package heimdall
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Stream states reported in ChatResponse.Heimdall while a chat streams.
+// They let clients switch from "typing…" to "running action…" as soon as the
+// SLM starts emitting an action command instead of prose.
+const (
+	StreamActionDetected = "action_detected" // SLM output is an action command
+	StreamRunningAction  = "running_action"  // Action is executing
+	StreamActionComplete = "action_complete" // Action finished (see Success)
+)
+
+// StreamStatus is attached to streaming chunks to describe state transitions.
+type StreamStatus struct {
+	State   string `json:"state"`
+	Action  string `json:"action,omitempty"`
+	Success *bool  `json:"success,omitempty"`
+}
+
+// actionPrefix is the compacted start of an action command.
+const actionPrefix = `{"action"`
+
+// Stream filter modes.
+const (
+	streamUndecided = iota // Not enough output yet to tell prose from an action
+	streamText             // Prose: tokens pass through
+	streamAction           // Action command: tokens are withheld
+)
+
+// actionStreamFilter sits between the SLM token stream and the client.
+//
+// Prose tokens are forwarded as they arrive. When the output starts with an
+// action command ({"action": ...}, optionally in a ``` fence), the raw JSON
+// is withheld and onAction is called once so the client can show that an
+// action is coming. Tokens are only held back while the first few characters
+// are ambiguous.
+type actionStreamFilter struct {
+	emit     func(token string) error
+	onAction func() error
+
+	full strings.Builder
+	held []string
+	mode int
+}
+
+func newActionStreamFilter(emit func(token string) error, onAction func() error) *actionStreamFilter {
+	return &actionStreamFilter{emit: emit, onAction: onAction}
+}
+
+// Write processes one token from the SLM.
+func (f *actionStreamFilter) Write(token string) error {
+	f.full.WriteString(token)
+	switch f.mode {
+	case streamText:
+		return f.emit(token)
+	case streamAction:
+		return nil
+	}
+
+	f.held = append(f.held, token)
+	switch f.mode = classifyStreamStart(f.full.String()); f.mode {
+	case streamText:
+		return f.release()
+	case streamAction:
+		f.held = nil
+		if f.onAction != nil {
+			return f.onAction()
+		}
+	}
+	return nil
+}
+
+// Close releases tokens still held because the output was too short to
+// classify. It reports whether the output was detected as an action.
+func (f *actionStreamFilter) Close() (bool, error) {
+	if f.mode == streamUndecided {
+		f.mode = streamText
+		return false, f.release()
+	}
+	return f.mode == streamAction, nil
+}
+
+// Text returns the complete SLM output, including withheld tokens.
+func (f *actionStreamFilter) Text() string {
+	return f.full.String()
+}
+
+func (f *actionStreamFilter) release() error {
+	held := f.held
+	f.held = nil
+	for _, t := range held {
+		if err := f.emit(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// classifyStreamStart decides whether output so far is prose or an action.
+func classifyStreamStart(s string) int {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	if s == "" || strings.HasPrefix("```", s) {
+		return streamUndecided
+	}
+	if rest, ok := strings.CutPrefix(s, "```"); ok {
+		// Wait for the fence's language tag to finish
+		nl := strings.IndexByte(rest, '\n')
+		if nl < 0 {
+			return streamUndecided
+		}
+		s = strings.TrimLeftFunc(rest[nl+1:], unicode.IsSpace)
+		if s == "" {
+			return streamUndecided
+		}
+	}
+
+	compact := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+	if strings.HasPrefix(compact, actionPrefix) {
+		return streamAction
+	}
+	if strings.HasPrefix(actionPrefix, compact) {
+		return streamUndecided
+	}
+	return streamText
+}
@@ -0,0 +1,165 @@
+package heimdall
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyStreamStart(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", streamUndecided},
+		{"  \n", streamUndecided},
+		{"{", streamUndecided},
+		{`{ "act`, streamUndecided},
+		{`{"action"`, streamAction},
+		{"{\n  \"action\": \"heimdall.watcher.status\"", streamAction},
+		{"`", streamUndecided},
+		{"```json", streamUndecided},
+		{"```json\n{\"action\":", streamAction},
+		{"```cypher\nMATCH (n)", streamText},
+		{"Hello", streamText},
+		{`{"name": "x"}`, streamText},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, classifyStreamStart(tt.text), "%q", tt.text)
+	}
+}
+
+func TestActionStreamFilter(t *testing.T) {
+	t.Run("prose streams through", func(t *testing.T) {
+		var out []string
+		f := newActionStreamFilter(func(tok string) error { out = append(out, tok); return nil }, nil)
+		for _, tok := range []string{" ", "The", " graph", " has", " 42 nodes"} {
+			require.NoError(t, f.Write(tok))
+		}
+		isAction, err := f.Close()
+		require.NoError(t, err)
+		assert.False(t, isAction)
+		// Leading whitespace is held until the first word, then everything flows
+		assert.Equal(t, []string{" ", "The", " graph", " has", " 42 nodes"}, out)
+		assert.Equal(t, " The graph has 42 nodes", f.Text())
+	})
+
+	t.Run("action is withheld", func(t *testing.T) {
+		var out []string
+		detected := 0
+		f := newActionStreamFilter(func(tok string) error { out = append(out, tok); return nil },
+			func() error { detected++; return nil })
+		for _, tok := range []string{"{", `"action"`, `: "heimdall.watcher.status",`, ` "params": {}}`} {
+			require.NoError(t, f.Write(tok))
+		}
+		isAction, err := f.Close()
+		require.NoError(t, err)
+		assert.True(t, isAction)
+		assert.Empty(t, out)
+		assert.Equal(t, 1, detected)
+		assert.Equal(t, `{"action": "heimdall.watcher.status", "params": {}}`, f.Text())
+	})
+
+	t.Run("short output released on close", func(t *testing.T) {
+		var out []string
+		f := newActionStreamFilter(func(tok string) error { out = append(out, tok); return nil }, nil)
+		require.NoError(t, f.Write("{"))
+		assert.Empty(t, out)
+		_, err := f.Close()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"{"}, out)
+	})
+
+	t.Run("emit error stops stream", func(t *testing.T) {
+		f := newActionStreamFilter(func(string) error { return errors.New("client gone") }, nil)
+		assert.Error(t, f.Write("Hello"))
+	})
+}
+
+func TestHandler_StreamingActionTransitions(t *testing.T) {
+	RegisterBuiltinAction(ActionFunc{
+		Name:        "heimdall.test.stream_status",
+		Description: "Streaming status test",
+		Category:    "test",
+		Handler: func(ctx ActionContext) (*ActionResult, error) {
+			return &ActionResult{Success: true, Message: "all good"}, nil
+		},
+	})
+	defer func() {
+		m := GetSubsystemManager()
+		m.mu.Lock()
+		delete(m.actions, "heimdall.test.stream_status")
+		m.mu.Unlock()
+	}()
+
+	stream := func(tokens ...string) string {
+		mockGen := NewMockGenerator("/test/model.gguf")
+		mockGen.streamFunc = func(ctx context.Context, prompt string, params GenerateParams, cb func(string) error) error {
+			for _, tok := range tokens {
+				if err := cb(tok); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		manager := newTestManager(mockGen)
+		handler := testHandler(manager, manager.config)
+		w := httptest.NewRecorder()
+		handler.handleStreamingResponse(w, context.Background(), "", GenerateParams{}, "test", &requestLifecycle{
+			promptCtx: &PromptContext{UserMessage: "status", PluginData: map[string]interface{}{}},
+			requestID: "req-s",
+		})
+		return w.Body.String()
+	}
+
+	body := stream(`{"action": `, `"heimdall.test.stream_status", `, `"params": {}}`)
+	assert.NotContains(t, body, `\"action\"`, "raw action JSON must not reach the client")
+	detected := strings.Index(body, `"heimdall":{"state":"action_detected"}`)
+	running := strings.Index(body, `"heimdall":{"state":"running_action","action":"heimdall.test.stream_status"}`)
+	complete := strings.Index(body, `"heimdall":{"state":"action_complete","action":"heimdall.test.stream_status","success":true}`)
+	result := strings.Index(body, "all good")
+	require.True(t, detected >= 0 && running > detected && complete > running && result > complete, body)
+	assert.True(t, strings.HasSuffix(body, "data: [DONE]\n\n"))
+
+	body = stream("Hello", " world")
+	assert.Contains(t, body, `"content":"Hello"`)
+	assert.Contains(t, body, `"content":" world"`)
+	assert.NotContains(t, body, `"heimdall":{`)
+
+	// Unknown actions are shown as text instead of silently swallowed
+	body = stream(`{"action": "heimdall.test.missing", "params": {}}`)
+	assert.Contains(t, body, `heimdall.test.missing`)
+	assert.NotContains(t, body, "running_action")
+}
+
+func TestLiveHeimdallInvoker_SendPromptStream(t *testing.T) {
+	mockGen := NewMockGenerator("/test/model.gguf")
+	mockGen.streamFunc = func(ctx context.Context, prompt string, params GenerateParams, cb func(string) error) error {
+		for _, tok := range []string{"Two", " anomalies", " today"} {
+			if err := cb(tok); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	invoker := NewLiveHeimdallInvoker(GetSubsystemManager(), mockGen, &NoOpBifrost{}, nil, nil)
+
+	var tokens []string
+	result, err := invoker.SendPromptStream(context.Background(), "summarize", func(tok string) error {
+		tokens = append(tokens, tok)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, "Two anomalies today", result.Message)
+	assert.Equal(t, []string{"Two", " anomalies", " today"}, tokens)
+
+	result, err = (&NoOpHeimdallInvoker{}).SendPromptStream(context.Background(), "x", nil)
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+}
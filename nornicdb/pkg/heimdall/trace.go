@@ -0,0 +1,449 @@
+// Package heimdall - request traces: the chain of decisions behind a chat
+// answer.
+//
+// Logs say what Heimdall did, but not why. With Config.Trace set, every
+// chat request records its trace: the prompt sent to the SLM, the raw SLM
+// output, the action parsed from it, what each PrePrompt and PreExecute
+// hook changed, guardrail and confirmation outcomes, and the execution
+// result. The last Config.TraceLimit traces are kept in memory.
+//
+// A trace is looked up by its request ID, the ID of the chat response:
+//
+//	GET /api/bifrost/traces             - Recent traces, newest first
+//	GET /api/bifrost/traces/{id}        - One trace
+//	{"action": "heimdall.trace", "params": {"request_id": "..."}}
+//
+// and with /trace in the Bifrost console. Users see only the traces of
+// their own requests.
+package heimdall
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceAction returns a trace, or the recent traces without a request_id.
+const TraceAction = "heimdall.trace"
+
+// DefaultTraceLimit is the number of traces kept when Config.TraceLimit is 0.
+const DefaultTraceLimit = 100
+
+// Trace phases.
+const (
+	TracePrePrompt  = "pre_prompt"   // A PrePrompt hook ran
+	TraceParse      = "parse"        // The SLM output was searched for an action
+	TracePreExecute = "pre_execute"  // A PreExecute hook ran
+	TraceGuardrails = "guardrails"   // Guardrails blocked the action
+	TraceConfirm    = "confirmation" // The user was asked to confirm
+	TraceExecute    = "execute"      // The action ran
+)
+
+// Trace records how Heimdall answered one chat request.
+type Trace struct {
+	RequestID  string    `json:"request_id"`
+	UserID     string    `json:"user_id,omitempty"`
+	Persona    string    `json:"persona,omitempty"`
+	Started    time.Time `json:"started"`
+	DurationMs float64   `json:"duration_ms"` // 0 while in progress
+	Done       bool      `json:"done"`
+
+	Prompt  string `json:"prompt,omitempty"`  // As sent to the SLM, PII masked
+	Grammar bool   `json:"grammar,omitempty"` // Decoding was constrained
+	Output  string `json:"output,omitempty"`  // Raw SLM output
+
+	Action *ParsedAction          `json:"action,omitempty"` // As parsed, before hooks
+	Params map[string]interface{} `json:"params,omitempty"` // As executed
+	Steps  []TraceStep            `json:"steps,omitempty"`
+	Result *ActionResult          `json:"result,omitempty"`
+
+	Response string `json:"response,omitempty"` // Sent to the user
+	Error    string `json:"error,omitempty"`
+
+	mu sync.Mutex
+}
+
+// TraceStep is one decision recorded in a Trace.
+type TraceStep struct {
+	Phase   string        `json:"phase"`
+	Source  string        `json:"source,omitempty"` // Plugin or component
+	Action  string        `json:"action,omitempty"` // Set for pipeline steps too
+	Detail  string        `json:"detail,omitempty"`
+	Changes []ParamChange `json:"changes,omitempty"`
+	At      time.Time     `json:"at"`
+}
+
+// TraceSummary is a Trace without its prompt and output.
+type TraceSummary struct {
+	RequestID  string    `json:"request_id"`
+	Started    time.Time `json:"started"`
+	DurationMs float64   `json:"duration_ms"`
+	Done       bool      `json:"done"`
+	Action     string    `json:"action,omitempty"`
+	Steps      int       `json:"steps"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// The recorders below do nothing on a nil Trace, so callers need not check
+// whether tracing is enabled.
+
+// step records a decision.
+func (t *Trace) step(s TraceStep) {
+	if t == nil {
+		return
+	}
+	s.At = time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Steps = append(t.Steps, s)
+}
+
+// generated records the prompt and the SLM output.
+func (t *Trace) generated(prompt string, params GenerateParams, output string, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Prompt, t.Grammar, t.Output = prompt, params.Grammar != "", output
+	if err != nil {
+		t.Error = err.Error()
+	}
+}
+
+// parsed records the action found in the output, or that there was none.
+func (t *Trace) parsed(action *ParsedAction) {
+	if t == nil {
+		return
+	}
+	if action == nil {
+		t.step(TraceStep{Phase: TraceParse, Detail: "no action"})
+		return
+	}
+	t.mu.Lock()
+	t.Action = &ParsedAction{Action: action.Action, Params: maps.Clone(action.Params)}
+	t.mu.Unlock()
+	t.step(TraceStep{Phase: TraceParse, Action: action.Action, Detail: "action found"})
+}
+
+// blocked records the gate that turned before into an abort, if after is
+// one and before was not.
+func (t *Trace) blocked(phase, action string, before, after PreExecuteResult) {
+	if before.Continue && !after.Continue {
+		t.step(TraceStep{Phase: phase, Action: action, Detail: after.AbortMessage})
+	}
+}
+
+// executed records the params an action ran with and its outcome.
+func (t *Trace) executed(action string, params map[string]interface{}, result *ActionResult, err error, d time.Duration) {
+	if t == nil {
+		return
+	}
+	detail := fmt.Sprintf("failed in %s", d)
+	switch {
+	case err != nil:
+		detail = fmt.Sprintf("error in %s: %v", d, err)
+	case result != nil && result.Success:
+		detail = fmt.Sprintf("succeeded in %s", d)
+	}
+	t.mu.Lock()
+	t.Params, t.Result = maps.Clone(params), result
+	t.mu.Unlock()
+	t.step(TraceStep{Phase: TraceExecute, Action: action, Detail: detail})
+}
+
+// finish records the response and ends the trace.
+func (t *Trace) finish(response string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Done {
+		return
+	}
+	t.Response = response
+	t.DurationMs = float64(time.Since(t.Started).Microseconds()) / 1000
+	t.Done = true
+}
+
+// confirmationDetail describes the outcome of a confirmation.
+func confirmationDetail(decision ConfirmationDecision, err error) string {
+	switch {
+	case err != nil:
+		return "error: " + err.Error()
+	case decision.Approved:
+		if len(decision.Approvers) > 0 {
+			return "approved by " + strings.Join(decision.Approvers, ", ")
+		}
+		return "approved"
+	case decision.TimedOut:
+		return "timed out"
+	case decision.RejectedBy != "":
+		return "rejected by " + decision.RejectedBy
+	default:
+		return "rejected"
+	}
+}
+
+// fail ends the trace with an error.
+func (t *Trace) fail(err string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.Error = err
+	t.mu.Unlock()
+	t.finish("")
+}
+
+// snapshot returns a copy that is safe to read while the request goes on.
+func (t *Trace) snapshot() *Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := &Trace{
+		RequestID: t.RequestID, UserID: t.UserID, Persona: t.Persona,
+		Started: t.Started, DurationMs: t.DurationMs, Done: t.Done,
+		Prompt: t.Prompt, Grammar: t.Grammar, Output: t.Output,
+		Action: t.Action, Params: t.Params, Result: t.Result,
+		Response: t.Response, Error: t.Error,
+	}
+	if !t.Done {
+		c.DurationMs = float64(time.Since(t.Started).Microseconds()) / 1000
+	}
+	c.Steps = append([]TraceStep(nil), t.Steps...)
+	return c
+}
+
+// summary returns the trace's summary.
+func (t *Trace) summary() TraceSummary {
+	s := t.snapshot()
+	sum := TraceSummary{
+		RequestID: s.RequestID, Started: s.Started, DurationMs: s.DurationMs,
+		Done: s.Done, Steps: len(s.Steps), Error: s.Error,
+	}
+	if s.Action != nil {
+		sum.Action = s.Action.Action
+	}
+	return sum
+}
+
+// promptState is what a PrePrompt hook may change in a PromptContext.
+type promptState struct {
+	userMessage  string
+	instructions string
+	messages     int
+	examples     int
+	data         int
+	pluginData   int
+}
+
+func (p *PromptContext) state() promptState {
+	return promptState{
+		userMessage:  p.UserMessage,
+		instructions: p.AdditionalInstructions,
+		messages:     len(p.Messages),
+		examples:     len(p.Examples),
+		data:         len(p.dataBlocks),
+		pluginData:   len(p.PluginData),
+	}
+}
+
+// changes describes how p differs from s after a PrePrompt hook, e.g.
+// "instructions changed, examples +2".
+func (s promptState) changes(p *PromptContext, err error) string {
+	after := p.state()
+	var parts []string
+	if after.userMessage != s.userMessage {
+		parts = append(parts, "user message changed")
+	}
+	if after.instructions != s.instructions {
+		parts = append(parts, "instructions changed")
+	}
+	for _, c := range []struct {
+		name          string
+		before, after int
+	}{
+		{"messages", s.messages, after.messages},
+		{"examples", s.examples, after.examples},
+		{"data blocks", s.data, after.data},
+		{"plugin data", s.pluginData, after.pluginData},
+	} {
+		if c.after != c.before {
+			parts = append(parts, fmt.Sprintf("%s %+d", c.name, c.after-c.before))
+		}
+	}
+	if p.Cancelled() {
+		parts = append(parts, "cancelled: "+p.CancelReason())
+	}
+	if err != nil {
+		parts = append(parts, "error: "+err.Error())
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// visibleTo reports whether user may read the trace: its own user, or
+// anyone when the request was anonymous.
+func (t *Trace) visibleTo(user string) bool {
+	return t.UserID == "" || t.UserID == user
+}
+
+// TraceStore keeps the most recent traces. It is safe for concurrent use.
+type TraceStore struct {
+	mu     sync.Mutex
+	limit  int
+	traces map[string]*Trace
+	order  []string // Request IDs, oldest first
+}
+
+// NewTraceStore returns a store keeping limit traces (DefaultTraceLimit if
+// limit <= 0).
+func NewTraceStore(limit int) *TraceStore {
+	if limit <= 0 {
+		limit = DefaultTraceLimit
+	}
+	return &TraceStore{limit: limit, traces: make(map[string]*Trace)}
+}
+
+// start begins the trace of a request, evicting the oldest trace if the
+// store is full. It returns nil on a nil store.
+func (s *TraceStore) start(promptCtx *PromptContext) *Trace {
+	if s == nil {
+		return nil
+	}
+	t := &Trace{RequestID: promptCtx.RequestID, UserID: promptCtx.UserID, Started: promptCtx.RequestTime}
+	if promptCtx.Persona != nil {
+		t.Persona = promptCtx.Persona.Name
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.order) >= s.limit {
+		delete(s.traces, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.traces[t.RequestID] = t
+	s.order = append(s.order, t.RequestID)
+	return t
+}
+
+// Get returns a copy of the trace of a request, if it is still kept.
+func (s *TraceStore) Get(requestID string) (*Trace, bool) {
+	s.mu.Lock()
+	t, ok := s.traces[requestID]
+	s.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return t.snapshot(), true
+}
+
+// Recent summarizes up to n traces visible to user, newest first.
+func (s *TraceStore) Recent(user string, n int) []TraceSummary {
+	s.mu.Lock()
+	traces := make([]*Trace, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		traces = append(traces, s.traces[s.order[i]])
+	}
+	s.mu.Unlock()
+
+	summaries := make([]TraceSummary, 0, min(n, len(traces)))
+	for _, t := range traces {
+		if len(summaries) == n {
+			break
+		}
+		if t.visibleTo(user) {
+			summaries = append(summaries, t.summary())
+		}
+	}
+	return summaries
+}
+
+var (
+	traceStoreMu     sync.RWMutex
+	activeTraceStore *TraceStore
+)
+
+// setTraceStore makes the heimdall.trace action read store (nil disables it).
+func setTraceStore(store *TraceStore) {
+	traceStoreMu.Lock()
+	defer traceStoreMu.Unlock()
+	activeTraceStore = store
+}
+
+func currentTraceStore() *TraceStore {
+	traceStoreMu.RLock()
+	defer traceStoreMu.RUnlock()
+	return activeTraceStore
+}
+
+// traceAction is the built-in heimdall.trace action.
+func traceAction() ActionFunc {
+	return ActionFunc{
+		Name:        TraceAction,
+		Description: "Show why Heimdall answered a request as it did: prompt, SLM output, hooks and result (params: request_id; none lists recent requests)",
+		Category:    "system",
+		Handler: func(ctx ActionContext) (*ActionResult, error) {
+			store := currentTraceStore()
+			if store == nil {
+				return &ActionResult{Success: false, Message: "Tracing is disabled (set NORNICDB_HEIMDALL_TRACE=true)"}, nil
+			}
+			user := UserFromContext(ctx)
+			id, _ := ctx.Params["request_id"].(string)
+			if id == "" {
+				recent := store.Recent(user, 10)
+				return &ActionResult{
+					Success: true,
+					Message: fmt.Sprintf("%d recent traces", len(recent)),
+					Data:    map[string]interface{}{"traces": recent},
+				}, nil
+			}
+			t, ok := store.Get(id)
+			if !ok || !t.visibleTo(user) {
+				return &ActionResult{Success: false, Message: "No trace for request " + id}, nil
+			}
+			return &ActionResult{
+				Success: true,
+				Message: "Trace of request " + id,
+				Data:    map[string]interface{}{"trace": t},
+			}, nil
+		},
+	}
+}
+
+// handleTraces serves request traces.
+// GET /api/bifrost/traces
+// GET /api/bifrost/traces/{id}
+func (h *Handler) handleTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.traces == nil {
+		http.Error(w, "Tracing is disabled (set NORNICDB_HEIMDALL_TRACE=true)", http.StatusNotFound)
+		return
+	}
+
+	user := UserFromContext(r.Context())
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/bifrost/traces"), "/")
+	w.Header().Set("Content-Type", "application/json")
+	if id == "" {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"traces": h.traces.Recent(user, h.traces.limit),
+		})
+		return
+	}
+	t, ok := h.traces.Get(id)
+	if !ok || !t.visibleTo(user) {
+		http.Error(w, "No trace for request "+id, http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(t)
+}
@@ -0,0 +1,203 @@
+package heimdall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceStore_EvictsOldest(t *testing.T) {
+	store := NewTraceStore(2)
+	for _, id := range []string{"a", "b", "c"} {
+		store.start(&PromptContext{RequestID: id, RequestTime: time.Now()})
+	}
+
+	_, ok := store.Get("a")
+	assert.False(t, ok, "oldest trace is evicted")
+	recent := store.Recent("", 10)
+	require.Len(t, recent, 2)
+	assert.Equal(t, "c", recent[0].RequestID, "newest first")
+	assert.Equal(t, "b", recent[1].RequestID)
+	assert.Len(t, store.Recent("", 1), 1)
+}
+
+func TestTraceStore_Visibility(t *testing.T) {
+	store := NewTraceStore(0)
+	store.start(&PromptContext{RequestID: "alice-1", UserID: "alice", RequestTime: time.Now()})
+	store.start(&PromptContext{RequestID: "bob-1", UserID: "bob", RequestTime: time.Now()})
+	store.start(&PromptContext{RequestID: "anon-1", RequestTime: time.Now()})
+
+	var ids []string
+	for _, s := range store.Recent("alice", 10) {
+		ids = append(ids, s.RequestID)
+	}
+	assert.Equal(t, []string{"anon-1", "alice-1"}, ids)
+
+	bob, ok := store.Get("bob-1")
+	require.True(t, ok)
+	assert.False(t, bob.visibleTo("alice"))
+	assert.True(t, bob.visibleTo("bob"))
+}
+
+func TestTrace_NilRecordersAreNoOps(t *testing.T) {
+	var trace *Trace
+	trace.step(TraceStep{Phase: TraceParse})
+	trace.generated("prompt", GenerateParams{}, "output", nil)
+	trace.parsed(&ParsedAction{Action: "x"})
+	trace.blocked(TraceGuardrails, "x", PreExecuteResult{Continue: true}, PreExecuteResult{})
+	trace.executed("x", nil, nil, nil, 0)
+	trace.fail("error")
+	trace.finish("response")
+
+	var store *TraceStore
+	assert.Nil(t, store.start(&PromptContext{RequestID: "a"}))
+}
+
+func TestTrace_FailKeepsFirstOutcome(t *testing.T) {
+	store := NewTraceStore(0)
+	trace := store.start(&PromptContext{RequestID: "a", RequestTime: time.Now()})
+	trace.fail("cancelled by guard: no")
+	trace.finish("ignored")
+
+	got, ok := store.Get("a")
+	require.True(t, ok)
+	assert.True(t, got.Done)
+	assert.Equal(t, "cancelled by guard: no", got.Error)
+	assert.Empty(t, got.Response)
+}
+
+func TestPromptState_Changes(t *testing.T) {
+	p := &PromptContext{}
+	before := p.state()
+	assert.Equal(t, "no changes", before.changes(p, nil))
+
+	p.AdditionalInstructions = "Be brief."
+	p.Examples = append(p.Examples, PromptExample{UserSays: "hi", ActionJSON: `{"action": "heimdall.help"}`})
+	assert.Equal(t, "instructions changed, examples +1", before.changes(p, nil))
+}
+
+// traceHook adds a param before execution so the trace records the change.
+type traceHook struct{ *MockHeimdallPlugin }
+
+func (*traceHook) PreExecute(ctx *PreExecuteContext, done func(PreExecuteResult)) {
+	ctx.Params["limit"] = 5
+	done(PreExecuteResult{Continue: true})
+}
+
+func TestHandler_ChatTrace(t *testing.T) {
+	globalManager = nil
+	t.Cleanup(func() { globalManager = nil })
+	require.NoError(t, GetSubsystemManager().RegisterPlugin(&traceHook{NewMockPlugin("trace-hook")}, "", true))
+
+	var executedWith map[string]interface{}
+	RegisterBuiltinAction(ActionFunc{
+		Name:        "heimdall.test.trace_status",
+		Description: "Trace status",
+		Category:    "monitoring",
+		Handler: func(ctx ActionContext) (*ActionResult, error) {
+			executedWith = ctx.Params
+			return &ActionResult{Success: true, Message: "all good"}, nil
+		},
+	})
+	RegisterBuiltinAction(traceAction())
+
+	output := `{"action": "heimdall.test.trace_status", "params": {}}`
+	mockGen := NewMockGenerator("/test/model.gguf")
+	mockGen.generateFunc = func(ctx context.Context, p string, gp GenerateParams) (string, error) {
+		return output, nil
+	}
+	manager := newTestManager(mockGen)
+	cfg := manager.config
+	cfg.Trace = true
+	handler := testHandler(manager, cfg)
+	defer setTraceStore(nil)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"messages": []map[string]string{{"role": "user", "content": "status?"}},
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/bifrost/chat/completions", bytes.NewReader(body))
+	handler.ServeHTTP(w, req.WithContext(WithUser(req.Context(), "alice")))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp ChatResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, 5, executedWith["limit"])
+
+	trace, ok := handler.traces.Get(resp.ID)
+	require.True(t, ok)
+	assert.True(t, trace.Done)
+	assert.Equal(t, "alice", trace.UserID)
+	assert.Contains(t, trace.Prompt, "status?")
+	assert.Equal(t, output, trace.Output)
+	require.NotNil(t, trace.Action)
+	assert.Equal(t, "heimdall.test.trace_status", trace.Action.Action)
+	assert.NotContains(t, trace.Action.Params, "limit", "parsed action is recorded before hooks")
+	assert.Equal(t, 5, trace.Params["limit"])
+	require.NotNil(t, trace.Result)
+	assert.True(t, trace.Result.Success)
+	assert.Contains(t, trace.Response, "all good")
+
+	phases := map[string]TraceStep{}
+	for _, s := range trace.Steps {
+		phases[s.Phase+"/"+s.Source] = s
+	}
+	assert.Equal(t, "action found", phases[TraceParse+"/"].Detail)
+	hook := phases[TracePreExecute+"/trace-hook"]
+	require.Len(t, hook.Changes, 1)
+	assert.Equal(t, "limit", hook.Changes[0].Param)
+	assert.Contains(t, phases[TraceExecute+"/"].Detail, "succeeded")
+
+	// The action returns the trace to its own user only
+	result, err := ExecuteAction(TraceAction, ActionContext{
+		Context: WithUser(context.Background(), "alice"),
+		Params:  map[string]interface{}{"request_id": resp.ID},
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	result, err = ExecuteAction(TraceAction, ActionContext{
+		Context: WithUser(context.Background(), "bob"),
+		Params:  map[string]interface{}{"request_id": resp.ID},
+	})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+
+	// So does the HTTP endpoint
+	get := func(path, user string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		handler.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+		return w
+	}
+	w = get("/api/bifrost/traces/"+resp.ID, "alice")
+	require.Equal(t, http.StatusOK, w.Code)
+	var got Trace
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, output, got.Output)
+	assert.Equal(t, http.StatusNotFound, get("/api/bifrost/traces/"+resp.ID, "bob").Code)
+
+	w = get("/api/bifrost/traces", "alice")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Traces []TraceSummary `json:"traces"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.NotEmpty(t, list.Traces)
+	assert.Equal(t, resp.ID, list.Traces[0].RequestID)
+}
+
+func TestHandler_TracesDisabled(t *testing.T) {
+	manager := newTestManager(NewMockGenerator("/test/model.gguf"))
+	handler := testHandler(manager, manager.config)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/bifrost/traces", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "NORNICDB_HEIMDALL_TRACE")
+}
@@ -14,9 +14,12 @@ package heimdall
 import (
 	"context"
 	"fmt"
+	"log"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall/promptguard"
 )
 
 // ModelType categorizes models by their purpose.
@@ -55,6 +58,7 @@ type ChatRequest struct {
 	MaxTokens   int           `json:"max_tokens,omitempty"`
 	Temperature float32       `json:"temperature,omitempty"`
 	TopP        float32       `json:"top_p,omitempty"`
+	Persona     string        `json:"persona,omitempty"` // Named persona for this conversation
 }
 
 // ChatResponse is the response format for chat completions.
@@ -66,6 +70,22 @@ type ChatResponse struct {
 	Created int64        `json:"created"`
 	Choices []ChatChoice `json:"choices"`
 	Usage   *ChatUsage   `json:"usage,omitempty"`
+
+	// Heimdall carries streaming state transitions (Heimdall extension,
+	// ignored by OpenAI clients). Only set on "chat.completion.chunk".
+	Heimdall *StreamStatus `json:"heimdall,omitempty"`
+
+	// ActionResult is the structured result of an executed action, with its
+	// ResultSchema when it has one, so clients can render tables and charts
+	// instead of the JSON in the message text (Heimdall extension). Set on
+	// the completion, or on the chunk carrying the action's text.
+	ActionResult *ActionOutput `json:"action_result,omitempty"`
+}
+
+// ActionOutput is an ActionResult labelled with the action that produced it.
+type ActionOutput struct {
+	Action string `json:"action"`
+	*ActionResult
 }
 
 // ChatChoice represents a single completion choice.
@@ -96,6 +116,11 @@ type GenerateParams struct {
 	TopP        float32
 	TopK        int
 	StopTokens  []string
+
+	// Grammar is a GBNF grammar the answer must match (see package
+	// grammar). Only llama.cpp models honor it, and the Manager drops it
+	// unless Config.JSONGrammar is set.
+	Grammar string
 }
 
 // DefaultGenerateParams returns sensible defaults for structured output.
@@ -162,6 +187,17 @@ type Config struct {
 	// Cannot be enabled independently - Bifrost requires Heimdall.
 	BifrostEnabled bool `json:"bifrost_enabled"`
 
+	// BifrostCompression gzips the event stream for clients that accept it.
+	BifrostCompression bool `json:"bifrost_compression"`
+
+	// BifrostBatchWindow collects notifications for a client for up to this
+	// long and sends them as one "batch" event (0 sends each at once). A batch
+	// is sent early once it holds BifrostBatchMaxMessages messages or
+	// BifrostBatchMaxBytes bytes of encoded messages.
+	BifrostBatchWindow      time.Duration `json:"bifrost_batch_window"`
+	BifrostBatchMaxMessages int           `json:"bifrost_batch_max_messages"`
+	BifrostBatchMaxBytes    int           `json:"bifrost_batch_max_bytes"`
+
 	ModelsDir   string  `json:"models_dir"`
 	Model       string  `json:"model"`
 	ContextSize int     `json:"context_size"` // Context window size (single-shot, max out)
@@ -170,6 +206,14 @@ type Config struct {
 	Temperature float32 `json:"temperature"`
 	GPULayers   int     `json:"gpu_layers"`
 
+	// ModelRoutes gives tasks their own models by name, e.g. a small model
+	// for TaskQC and a larger one for TaskChat. Unrouted tasks use Model.
+	ModelRoutes map[TaskType]string `json:"model_routes,omitempty"`
+
+	// JSONGrammar constrains decoding with GenerateParams.Grammar where
+	// callers set one, so answers parsed as JSON always parse.
+	JSONGrammar bool `json:"json_grammar"`
+
 	// Feature toggles
 	AnomalyDetection bool          `json:"anomaly_detection"`
 	AnomalyInterval  time.Duration `json:"anomaly_interval"`
@@ -177,6 +221,27 @@ type Config struct {
 	RuntimeInterval  time.Duration `json:"runtime_interval"`
 	MemoryCuration   bool          `json:"memory_curation"`
 	CurationInterval time.Duration `json:"curation_interval"`
+
+	// Confirmation workflow for actions with RiskMedium or RiskHigh
+	ConfirmationTimeout          time.Duration `json:"confirmation_timeout"`            // How long to wait for approval
+	ConfirmationApproveOnTimeout bool          `json:"confirmation_approve_on_timeout"` // Default decision on timeout
+	HighRiskApprovers            int           `json:"high_risk_approvers"`             // Distinct approvers for RiskHigh
+
+	// DefaultPersona is used when a chat request does not select one
+	DefaultPersona string `json:"default_persona"`
+
+	// RulesFile persists autonomous action rules changed at runtime
+	// ("" keeps them in memory)
+	RulesFile string `json:"rules_file"`
+
+	// ReportsFile persists scheduled report definitions ("" keeps them in
+	// memory)
+	ReportsFile string `json:"reports_file"`
+
+	// Trace records how each chat request was answered, keeping the last
+	// TraceLimit traces (0 = DefaultTraceLimit) in memory (see Trace)
+	Trace      bool `json:"trace"`
+	TraceLimit int  `json:"trace_limit"`
 }
 
 // DefaultConfig returns sensible defaults.
@@ -199,6 +264,13 @@ func DefaultConfig() Config {
 		RuntimeInterval:  1 * time.Minute,
 		MemoryCuration:   false, // Experimental
 		CurationInterval: 1 * time.Hour,
+
+		ConfirmationTimeout: 30 * time.Second,
+		HighRiskApprovers:   2,
+
+		BifrostCompression:      true,
+		BifrostBatchMaxMessages: 100,
+		BifrostBatchMaxBytes:    64 * 1024,
 	}
 }
 
@@ -285,6 +357,10 @@ type PromptContext struct {
 	// RequestTime when the request started
 	RequestTime time.Time
 
+	// UserID of the authenticated user who sent the request ("" if unknown).
+	// Use it with BifrostBridge.NotifyUser to reach only this user.
+	UserID string
+
 	// === IMMUTABLE (set before PrePrompt, read-only for plugins) ===
 
 	// ActionPrompt contains all registered actions formatted for the SLM.
@@ -292,6 +368,11 @@ type PromptContext struct {
 	// Plugins CANNOT modify this field.
 	ActionPrompt string
 
+	// Persona selected for the conversation (nil for the default assistant).
+	// Its SystemPrompt follows ActionPrompt; its Categories limit ActionPrompt
+	// and are enforced before actions execute.
+	Persona *Persona
+
 	// === MUTABLE (plugins can modify these in PrePrompt) ===
 
 	// UserMessage is the current user input
@@ -314,6 +395,14 @@ type PromptContext struct {
 
 	// === INTERNAL (set by handler, used by methods) ===
 	bifrost BifrostBridge // For sending notifications
+	trace   *Trace        // Records hook changes (nil unless tracing)
+
+	// === UNTRUSTED DATA (use AddData) ===
+	// dataBlocks are sanitized, fenced database-derived content (RAG context).
+	// rawData keeps the original text so executors can detect actions that
+	// were requested by data instead of the user.
+	dataBlocks []string
+	rawData    []string
 
 	// === NOTIFICATION QUEUE (for inline streaming) ===
 	// Notifications are queued and sent at the start of the streaming response
@@ -327,6 +416,47 @@ type PromptContext struct {
 	cancelledBy  string // Which hook/plugin cancelled
 }
 
+// AddData adds database-derived content (node properties, query results) to
+// the prompt as untrusted data. Unlike AdditionalInstructions, the content is
+// sanitized against prompt injection and fenced so the SLM treats it as data,
+// never as instructions. label identifies the source (e.g. "node:42").
+//
+// The returned result reports any instruction-like content that was removed;
+// flagged content is also logged.
+//
+// Example:
+//
+//	func (p *MyRAGPlugin) PrePrompt(ctx *heimdall.PromptContext) error {
+//		for _, doc := range p.retrieve(ctx.UserMessage) {
+//			ctx.AddData("node:"+doc.ID, doc.Text)
+//		}
+//		return nil
+//	}
+func (p *PromptContext) AddData(label, content string) promptguard.Result {
+	block, res := promptguard.Fence(label, content)
+	if res.Flagged() {
+		log.Printf("[Heimdall] ⚠️ Possible prompt injection in %s: %v", label, res.Findings)
+	}
+	p.dataBlocks = append(p.dataBlocks, block)
+	p.rawData = append(p.rawData, content)
+	return res
+}
+
+// ActionFromData reports whether action is named by untrusted data added via
+// AddData but not by the user's message. Such actions are never executed:
+// they are the signature of a prompt injection stored in the database.
+func (p *PromptContext) ActionFromData(action string) bool {
+	if promptguard.MentionsAction(p.UserMessage, action) {
+		return false
+	}
+	for _, raw := range p.rawData {
+		if promptguard.MentionsAction(raw, action) {
+			return true
+		}
+	}
+	return false
+}
+
 // QueuedNotification represents a notification waiting to be sent inline.
 type QueuedNotification struct {
 	Type    string `json:"type"` // "info", "warning", "error", "success", "progress"
@@ -480,6 +610,13 @@ Your role is to help users manage the database by executing actions and running
 	sb.WriteString(p.ActionPrompt)
 	sb.WriteString("\n")
 
+	// === PERSONA (operator-defined, after the immutable actions) ===
+	if p.Persona != nil && p.Persona.SystemPrompt != "" {
+		sb.WriteString("PERSONA:\n")
+		sb.WriteString(p.Persona.SystemPrompt)
+		sb.WriteString("\n\n")
+	}
+
 	// === CYPHER QUERY PRIMER ===
 	sb.WriteString(CypherPrimer)
 	sb.WriteString("\n")
@@ -507,6 +644,18 @@ IMPORTANT: Always complete your JSON responses with proper closing braces.
 		sb.WriteString("\n\n")
 	}
 
+	// === UNTRUSTED DATA (fenced, after all instructions) ===
+	if len(p.dataBlocks) > 0 {
+		sb.WriteString("DATABASE CONTENT:\n")
+		sb.WriteString(promptguard.DataRules)
+		sb.WriteString("\n\n")
+		for _, block := range p.dataBlocks {
+			sb.WriteString(block)
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+
 	// === EXAMPLES ===
 	if len(p.Examples) > 0 {
 		sb.WriteString("EXAMPLES:\n")
@@ -528,6 +677,11 @@ func (p *PromptContext) buildMinimalPrompt() string {
 	sb.WriteString("You are Heimdall, AI assistant for NornicDB graph database.\n\n")
 	sb.WriteString("ACTIONS:\n")
 	sb.WriteString(p.ActionPrompt)
+	if p.Persona != nil && p.Persona.SystemPrompt != "" {
+		sb.WriteString("\nPERSONA:\n")
+		sb.WriteString(p.Persona.SystemPrompt)
+		sb.WriteString("\n")
+	}
 	sb.WriteString("\nFor queries: {\"action\": \"heimdall.watcher.query\", \"params\": {\"cypher\": \"...\"}}\n")
 	sb.WriteString("Respond with JSON only.\n")
 
@@ -648,6 +802,9 @@ type PreExecuteContext struct {
 	// RequestTime when the request started
 	RequestTime time.Time
 
+	// UserID of the authenticated user who sent the request ("" if unknown)
+	UserID string
+
 	// Action is the parsed action name (e.g., "heimdall.watcher.status")
 	Action string
 
@@ -668,6 +825,7 @@ type PreExecuteContext struct {
 
 	// === INTERNAL (set by handler, used by methods) ===
 	bifrost BifrostBridge // For sending notifications
+	trace   *Trace        // Records hook changes (nil unless tracing)
 
 	// === NOTIFICATION QUEUE (for inline streaming) ===
 	notificationQueue []QueuedNotification
@@ -874,6 +1032,9 @@ const (
 	EventIndexCreated DatabaseEventType = "index.created"
 	EventIndexDropped DatabaseEventType = "index.dropped"
 
+	// Schema events
+	EventSchemaViolation DatabaseEventType = "schema.violation"
+
 	// Transaction events
 	EventTransactionCommit   DatabaseEventType = "transaction.commit"
 	EventTransactionRollback DatabaseEventType = "transaction.rollback"
@@ -959,6 +1120,10 @@ type DatabaseEvent struct {
 	// Source identifies where the event came from (e.g., "bolt", "http", "internal")
 	Source string `json:"source,omitempty"`
 
+	// TransactionID is set on events emitted inside an explicit transaction
+	// (see EventTransaction) and on its commit/rollback summary
+	TransactionID string `json:"transaction_id,omitempty"`
+
 	// Metadata for any additional event-specific data
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
@@ -1035,7 +1200,10 @@ type DatabaseEventHook interface {
 	// The plugin should handle errors internally and not panic.
 	//
 	// Events are delivered asynchronously - the database operation
-	// has already completed by the time this is called.
+	// has already completed by the time this is called. Each plugin has
+	// its own bounded queue and is called from a single goroutine, in
+	// emission order; a slow plugin only delays its own events. See
+	// EventDispatcherConfig for what happens when the queue is full.
 	//
 	// Plugins can use this to:
 	//   - Build audit logs
@@ -1046,6 +1214,34 @@ type DatabaseEventHook interface {
 	OnDatabaseEvent(event *DatabaseEvent)
 }
 
+// DatabaseEventSubscriber is an optional extension of DatabaseEventHook for
+// plugins that only care about some events. The dispatcher filters centrally,
+// so the plugin is not woken for events it would ignore.
+//
+// EventSubscription is called when the plugin's event queue is created, and
+// again whenever the plugin calls RefreshEventSubscription.
+type DatabaseEventSubscriber interface {
+	DatabaseEventHook
+	EventSubscription() EventSubscription
+}
+
+// EventSubscription declares which database events a plugin receives.
+// Every non-empty field must match; an empty subscription matches all events.
+type EventSubscription struct {
+	// Types the event must be one of.
+	Types []DatabaseEventType `json:"types,omitempty"`
+
+	// Labels of which the event's NodeLabels must contain at least one.
+	Labels []string `json:"labels,omitempty"`
+
+	// RelationshipTypes the event's RelationshipType must be one of.
+	RelationshipTypes []string `json:"relationship_types,omitempty"`
+
+	// Properties are path.Match patterns (e.g. "email", "addr_*"); at least
+	// one key of the event's Properties or OldProperties must match one.
+	Properties []string `json:"properties,omitempty"`
+}
+
 // FullLifecycleHook is a convenience interface for plugins that implement all hooks.
 // Plugins are NOT required to implement this - they can pick and choose.
 type FullLifecycleHook interface {
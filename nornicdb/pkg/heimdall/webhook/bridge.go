@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+)
+
+// Bridge returns a BifrostBridge that sends through inner and also routes
+// broadcast notifications (SendNotification and Publish) to sink. Chat
+// messages and notifications addressed to one user stay in Bifrost.
+//
+// inner may be nil, in which case notifications only reach the sink.
+func Bridge(inner heimdall.BifrostBridge, sink *Sink) heimdall.BifrostBridge {
+	if inner == nil {
+		inner = &heimdall.NoOpBifrost{}
+	}
+	if sink == nil {
+		return inner
+	}
+	return &bridge{BifrostBridge: inner, sink: sink}
+}
+
+type bridge struct {
+	heimdall.BifrostBridge
+	sink *Sink
+}
+
+func (b *bridge) SendNotification(notifType, title, message string) error {
+	b.sink.Notify(Alert{
+		Level:    notifType,
+		Title:    title,
+		Message:  message,
+		Source:   "bifrost",
+		Attended: b.IsConnected(),
+	})
+	return b.BifrostBridge.SendNotification(notifType, title, message)
+}
+
+func (b *bridge) Publish(topic, notifType, title, message string) error {
+	b.sink.Notify(Alert{
+		Level:    notifType,
+		Title:    title,
+		Message:  message,
+		Source:   "topic",
+		Topic:    topic,
+		Attended: b.IsConnected(),
+	})
+	return b.BifrostBridge.Publish(topic, notifType, title, message)
+}
@@ -0,0 +1,501 @@
+// Package webhook delivers Heimdall notifications and alerts to outbound
+// webhooks such as Slack, PagerDuty or an in-house alert gateway.
+//
+// Teams without a connected Bifrost client still need to hear about a full
+// disk or a failing rule. A Sink sends each Alert to every endpoint whose
+// filters accept it:
+//   - Formats: the alert as JSON, a Slack message or a PagerDuty Events v2
+//     trigger. A custom Go text/template replaces the format entirely.
+//   - Signing: with a secret, requests carry an HMAC-SHA256 signature of
+//     the timestamp and body (see Sign) so receivers can reject forgeries.
+//   - Retries: network errors, 429 and 5xx responses are retried with
+//     exponential backoff. Every endpoint has its own queue, so a slow
+//     endpoint does not hold up the others; alerts beyond the queue are
+//     dropped and counted.
+//
+// Bridge wraps a heimdall.BifrostBridge so that notifications plugins send
+// (health findings, watcher rules) also reach the sink.
+//
+// Endpoints are usually loaded from a YAML or JSON file:
+//
+//	endpoints:
+//	  - name: oncall
+//	    url: env:PAGERDUTY_URL
+//	    format: pagerduty
+//	    routing_key: env:PAGERDUTY_ROUTING_KEY
+//	    min_level: error
+//	  - name: team-chat
+//	    url: https://hooks.slack.com/services/...
+//	    format: slack
+//	    only_unattended: true
+//
+// Example:
+//
+//	endpoints, err := webhook.LoadFile(path)
+//	if err != nil {
+//		return err
+//	}
+//	sink, err := webhook.New(webhook.Config{Endpoints: endpoints})
+//	if err != nil {
+//		return err
+//	}
+//	defer sink.Close()
+//	bifrost = webhook.Bridge(bifrost, sink)
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Built-in payload formats.
+const (
+	FormatJSON      = "json"      // The Alert as JSON (default)
+	FormatSlack     = "slack"     // Slack incoming webhook message
+	FormatPagerDuty = "pagerduty" // PagerDuty Events API v2 trigger
+)
+
+// Headers set on every request with a secret.
+const (
+	HeaderTimestamp = "X-NornicDB-Timestamp"
+	HeaderSignature = "X-NornicDB-Signature"
+)
+
+// Defaults for Config and Endpoint.
+const (
+	DefaultMinLevel    = "warning"
+	DefaultMaxAttempts = 4
+	DefaultTimeout     = 10 * time.Second
+	DefaultBackoff     = time.Second
+	DefaultQueueSize   = 100
+)
+
+// levels orders notification levels by urgency.
+var levels = map[string]int{"info": 0, "success": 0, "warning": 1, "error": 2}
+
+var formats = map[string]string{
+	FormatJSON:  `{{json .Alert}}`,
+	FormatSlack: `{"text": {{json (printf "%s *%s*\n%s" (emoji .Level) .Title .Message)}}}`,
+	FormatPagerDuty: `{"routing_key": {{json .RoutingKey}}, "event_action": "trigger",` +
+		` "dedup_key": {{json (printf "%s:%s" .Source .Title)}},` +
+		` "payload": {"summary": {{json (printf "%s: %s" .Title .Message)}}, "severity": {{json .Severity}},` +
+		` "source": "nornicdb", "component": {{json .Source}}, "timestamp": {{json .Time}}}}`,
+}
+
+// Alert is a notification delivered to webhooks.
+type Alert struct {
+	Level   string    `json:"level"` // "info", "success", "warning" or "error"
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Source  string    `json:"source,omitempty"` // What raised it, e.g. "bifrost" or "topic"
+	Topic   string    `json:"topic,omitempty"`  // Bifrost topic it was published to
+	Time    time.Time `json:"time"`
+
+	// Attended is set when a Bifrost client was connected to see the
+	// alert; endpoints with OnlyUnattended skip such alerts.
+	Attended bool `json:"attended"`
+}
+
+// Endpoint is a webhook destination.
+type Endpoint struct {
+	// Name identifies the endpoint in logs and stats.
+	Name string `json:"name" yaml:"name"`
+	// URL receives a POST per alert. Like Secret and RoutingKey it may be
+	// a secrets reference ("env:NAME", "file:/path", "vault:...") that
+	// the caller resolves before New.
+	URL string `json:"url" yaml:"url"`
+	// Secret, when set, signs requests with HMAC-SHA256.
+	Secret string `json:"secret,omitempty" yaml:"secret"`
+	// Format is FormatJSON, FormatSlack or FormatPagerDuty.
+	Format string `json:"format,omitempty" yaml:"format"`
+	// Template is a Go text/template rendering the request body. It
+	// overrides Format. The data is a TemplateData; the json function
+	// encodes a value as JSON.
+	Template string `json:"template,omitempty" yaml:"template"`
+	// ContentType of the body (default application/json).
+	ContentType string `json:"content_type,omitempty" yaml:"content_type"`
+	// Headers are added to every request.
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers"`
+	// RoutingKey is the PagerDuty integration key.
+	RoutingKey string `json:"routing_key,omitempty" yaml:"routing_key"`
+	// MinLevel is the least urgent level delivered (default "warning").
+	MinLevel string `json:"min_level,omitempty" yaml:"min_level"`
+	// Topics limits the endpoint to alerts published to these Bifrost
+	// topics; alerts without a topic always pass. Empty accepts all.
+	Topics []string `json:"topics,omitempty" yaml:"topics"`
+	// OnlyUnattended skips alerts a Bifrost client was connected to see.
+	OnlyUnattended bool `json:"only_unattended,omitempty" yaml:"only_unattended"`
+	// MaxAttempts bounds delivery attempts per alert (default 4).
+	MaxAttempts int `json:"max_attempts,omitempty" yaml:"max_attempts"`
+}
+
+// TemplateData is the data templates are rendered with.
+type TemplateData struct {
+	Alert             // Its fields, and the whole alert as .Alert
+	Endpoint   string // Endpoint name
+	RoutingKey string // Endpoint RoutingKey
+	Severity   string // PagerDuty severity: "critical", "warning" or "info"
+}
+
+// Config configures a Sink.
+type Config struct {
+	Endpoints []Endpoint
+	// Timeout bounds one delivery attempt (default 10s).
+	Timeout time.Duration
+	// Backoff is the delay before the first retry, doubled for each
+	// further retry (default 1s).
+	Backoff time.Duration
+	// QueueSize is the number of alerts each endpoint buffers (default 100).
+	QueueSize int
+	// OnError is called when an alert could not be delivered to an
+	// endpoint after all attempts. Optional.
+	OnError func(endpoint string, a Alert, err error)
+}
+
+// Stats counts deliveries per endpoint.
+type Stats struct {
+	Sent    int64 `json:"sent"`
+	Failed  int64 `json:"failed"`
+	Retries int64 `json:"retries"`
+	Dropped int64 `json:"dropped"`
+}
+
+// Sink delivers alerts to webhook endpoints.
+type Sink struct {
+	config    Config
+	client    *http.Client
+	endpoints []*endpoint
+	closing   chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	mu        sync.RWMutex // Guards queue sends against Close
+	closed    bool
+}
+
+type endpoint struct {
+	Endpoint
+	tmpl  *template.Template
+	min   int
+	queue chan Alert
+
+	sent, failed, retries, dropped atomic.Int64
+}
+
+// LoadFile reads endpoints from a YAML or JSON file with an "endpoints"
+// list. Secret references are returned unresolved.
+func LoadFile(path string) ([]Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Endpoints []Endpoint `yaml:"endpoints"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse webhooks file %s: %w", path, err)
+	}
+	return file.Endpoints, nil
+}
+
+// New validates the endpoints and starts a delivery worker for each.
+// Call Close to stop them.
+func New(cfg Config) (*Sink, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+
+	s := &Sink{
+		config:  cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		closing: make(chan struct{}),
+	}
+	names := make(map[string]bool, len(cfg.Endpoints))
+	for i, e := range cfg.Endpoints {
+		if e.Name == "" {
+			e.Name = fmt.Sprintf("webhook%d", i+1)
+		}
+		if names[e.Name] {
+			return nil, fmt.Errorf("duplicate webhook name %q", e.Name)
+		}
+		names[e.Name] = true
+		ep, err := compile(e)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %s: %w", e.Name, err)
+		}
+		ep.queue = make(chan Alert, cfg.QueueSize)
+		s.endpoints = append(s.endpoints, ep)
+	}
+	for _, ep := range s.endpoints {
+		s.wg.Add(1)
+		go s.worker(ep)
+	}
+	return s, nil
+}
+
+func compile(e Endpoint) (*endpoint, error) {
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url must be an http(s) URL")
+	}
+	if e.MinLevel == "" {
+		e.MinLevel = DefaultMinLevel
+	}
+	min, ok := levels[e.MinLevel]
+	if !ok {
+		return nil, fmt.Errorf("unknown min_level %q", e.MinLevel)
+	}
+	if e.MaxAttempts <= 0 {
+		e.MaxAttempts = DefaultMaxAttempts
+	}
+	if e.ContentType == "" {
+		e.ContentType = "application/json"
+	}
+
+	text := e.Template
+	if text == "" {
+		if e.Format == "" {
+			e.Format = FormatJSON
+		}
+		if text, ok = formats[e.Format]; !ok {
+			return nil, fmt.Errorf("unknown format %q", e.Format)
+		}
+		if e.Format == FormatPagerDuty && e.RoutingKey == "" {
+			return nil, fmt.Errorf("pagerduty format needs routing_key")
+		}
+	}
+	tmpl, err := template.New(e.Name).Option("missingkey=error").Funcs(template.FuncMap{
+		"json":  toJSON,
+		"emoji": emoji,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return &endpoint{Endpoint: e, tmpl: tmpl, min: min}, nil
+}
+
+// Notify queues a for every endpoint that accepts it. It never blocks:
+// when an endpoint's queue is full the alert is dropped for it.
+func (s *Sink) Notify(a Alert) {
+	if a.Time.IsZero() {
+		a.Time = time.Now().UTC()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	for _, ep := range s.endpoints {
+		if !ep.accepts(a) {
+			continue
+		}
+		select {
+		case ep.queue <- a:
+		default:
+			ep.dropped.Add(1)
+		}
+	}
+}
+
+// accepts reports whether the endpoint's filters let a through.
+func (ep *endpoint) accepts(a Alert) bool {
+	if levels[a.Level] < ep.min {
+		return false
+	}
+	if ep.OnlyUnattended && a.Attended {
+		return false
+	}
+	if a.Topic != "" && len(ep.Topics) > 0 {
+		for _, t := range ep.Topics {
+			if t == a.Topic {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (s *Sink) worker(ep *endpoint) {
+	defer s.wg.Done()
+	for a := range ep.queue {
+		if err := s.deliver(ep, a); err != nil {
+			ep.failed.Add(1)
+			if s.config.OnError != nil {
+				s.config.OnError(ep.Name, a, err)
+			}
+		} else {
+			ep.sent.Add(1)
+		}
+	}
+}
+
+// deliver renders a and posts it to ep, retrying transient failures.
+// Retries stop early when the sink is closing.
+func (s *Sink) deliver(ep *endpoint, a Alert) error {
+	body, err := ep.render(a)
+	if err != nil {
+		return err
+	}
+	backoff := s.config.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(ep, body)
+		if err == nil || !retry || attempt >= ep.MaxAttempts {
+			return err
+		}
+		ep.retries.Add(1)
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-s.closing:
+			return fmt.Errorf("%w (shutting down)", err)
+		}
+	}
+}
+
+// render builds the request body for a.
+func (ep *endpoint) render(a Alert) ([]byte, error) {
+	var buf bytes.Buffer
+	err := ep.tmpl.Execute(&buf, TemplateData{
+		Alert:      a,
+		Endpoint:   ep.Name,
+		RoutingKey: ep.RoutingKey,
+		Severity:   severity(a.Level),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// post sends one attempt and reports whether a failure is worth retrying.
+func (s *Sink) post(ep *endpoint, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", ep.ContentType)
+	req.Header.Set("User-Agent", "NornicDB-Heimdall")
+	for k, v := range ep.Headers {
+		req.Header.Set(k, v)
+	}
+	if ep.Secret != "" {
+		ts := time.Now().Unix()
+		req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+		req.Header.Set(HeaderSignature, Sign(ep.Secret, ts, body))
+	}
+
+	resp, err := s.client.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true, urlErr.Err // Without the URL, which may hold a token
+	} else if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("%s", resp.Status)
+	default:
+		return false, fmt.Errorf("%s", resp.Status)
+	}
+}
+
+// Sign returns the signature header value for body sent at timestamp:
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>".
+//
+// Receivers recompute it with the shared secret, compare with
+// hmac.Equal and reject stale timestamps to prevent replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Stats returns delivery counts by endpoint name.
+func (s *Sink) Stats() map[string]Stats {
+	stats := make(map[string]Stats, len(s.endpoints))
+	for _, ep := range s.endpoints {
+		stats[ep.Name] = Stats{
+			Sent:    ep.sent.Load(),
+			Failed:  ep.failed.Load(),
+			Retries: ep.retries.Load(),
+			Dropped: ep.dropped.Load(),
+		}
+	}
+	return stats
+}
+
+// Close stops accepting alerts and waits for queued alerts to be sent.
+// Pending retries are abandoned.
+func (s *Sink) Close() {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		for _, ep := range s.endpoints {
+			close(ep.queue)
+		}
+		s.mu.Unlock()
+		close(s.closing)
+		s.wg.Wait()
+	})
+}
+
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func emoji(level string) string {
+	switch level {
+	case "error":
+		return "🔴"
+	case "warning":
+		return "⚠️"
+	case "success":
+		return "✅"
+	default:
+		return "ℹ️"
+	}
+}
+
+// severity maps a notification level to a PagerDuty severity.
+func severity(level string) string {
+	switch strings.ToLower(level) {
+	case "error":
+		return "critical"
+	case "warning":
+		return "warning"
+	default:
+		return "info"
+	}
+}
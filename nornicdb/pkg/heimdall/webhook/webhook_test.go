@@ -0,0 +1,315 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a webhook endpoint that records requests and answers with
+// the queued status codes, then 200.
+type receiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+	got      chan struct{}
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, string) {
+	r := &receiver{statuses: statuses, got: make(chan struct{}, 100)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		r.mu.Unlock()
+		w.WriteHeader(status)
+		r.got <- struct{}{}
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv.URL
+}
+
+// respond queues status codes for the next requests.
+func (r *receiver) respond(statuses ...int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, statuses...)
+}
+
+// wait blocks until n more requests arrived.
+func (r *receiver) wait(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.got:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for request %d", i+1)
+		}
+	}
+}
+
+func (r *receiver) body(t *testing.T, i int) map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(r.bodies[i], &body), string(r.bodies[i]))
+	return body
+}
+
+func newSink(t *testing.T, cfg Config) *Sink {
+	if cfg.Backoff == 0 {
+		cfg.Backoff = time.Millisecond
+	}
+	s, err := New(cfg)
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+	return s
+}
+
+var diskAlert = Alert{Level: "error", Title: "Health: disk_space", Message: "2% free", Source: "bifrost"}
+
+func TestNew_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint Endpoint
+		err      string
+	}{
+		{"bad url", Endpoint{URL: "hooks.slack.com"}, "http(s) URL"},
+		{"bad level", Endpoint{URL: "https://x.test", MinLevel: "fatal"}, "unknown min_level"},
+		{"bad format", Endpoint{URL: "https://x.test", Format: "teams"}, "unknown format"},
+		{"pagerduty without key", Endpoint{URL: "https://x.test", Format: FormatPagerDuty}, "needs routing_key"},
+		{"bad template", Endpoint{URL: "https://x.test", Template: "{{.Title"}, "invalid template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(Config{Endpoints: []Endpoint{tt.endpoint}})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+
+	_, err := New(Config{Endpoints: []Endpoint{{Name: "a", URL: "https://x.test"}, {Name: "a", URL: "https://y.test"}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate webhook name")
+}
+
+func TestSink_Formats(t *testing.T) {
+	rec, url := newReceiver(t)
+	s := newSink(t, Config{Endpoints: []Endpoint{
+		{Name: "json", URL: url},
+		{Name: "slack", URL: url, Format: FormatSlack},
+		{Name: "pd", URL: url, Format: FormatPagerDuty, RoutingKey: "R0UT1NG"},
+		{Name: "custom", URL: url, Template: `{"msg": {{json .Message}}, "via": {{json .Endpoint}}}`},
+	}})
+	at := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	a := diskAlert
+	a.Time = at
+	s.Notify(a)
+	rec.wait(t, 4)
+
+	bodies := map[string]map[string]interface{}{}
+	for i := range rec.bodies {
+		body := rec.body(t, i)
+		switch {
+		case body["routing_key"] != nil:
+			bodies["pd"] = body
+		case body["text"] != nil:
+			bodies["slack"] = body
+		case body["via"] != nil:
+			bodies["custom"] = body
+		default:
+			bodies["json"] = body
+		}
+	}
+	assert.Equal(t, "Health: disk_space", bodies["json"]["title"])
+	assert.Equal(t, "error", bodies["json"]["level"])
+	assert.Equal(t, "2026-03-10T08:00:00Z", bodies["json"]["time"])
+	assert.Equal(t, "🔴 *Health: disk_space*\n2% free", bodies["slack"]["text"])
+	assert.Equal(t, "R0UT1NG", bodies["pd"]["routing_key"])
+	assert.Equal(t, "trigger", bodies["pd"]["event_action"])
+	payload := bodies["pd"]["payload"].(map[string]interface{})
+	assert.Equal(t, "critical", payload["severity"])
+	assert.Equal(t, "Health: disk_space: 2% free", payload["summary"])
+	assert.Equal(t, map[string]interface{}{"msg": "2% free", "via": "custom"}, bodies["custom"])
+	assert.Equal(t, "application/json", rec.requests[0].Header.Get("Content-Type"))
+}
+
+func TestSink_Signature(t *testing.T) {
+	rec, url := newReceiver(t)
+	s := newSink(t, Config{Endpoints: []Endpoint{
+		{Name: "signed", URL: url, Secret: "s3cret", Headers: map[string]string{"X-Team": "db"}},
+	}})
+	s.Notify(diskAlert)
+	rec.wait(t, 1)
+
+	req := rec.requests[0]
+	ts, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), time.Unix(ts, 0), time.Minute)
+	assert.Equal(t, Sign("s3cret", ts, rec.bodies[0]), req.Header.Get(HeaderSignature))
+	assert.NotEqual(t, Sign("other", ts, rec.bodies[0]), req.Header.Get(HeaderSignature))
+	assert.Equal(t, "db", req.Header.Get("X-Team"))
+
+	// Known vector: HMAC-SHA256("key", "1.body")
+	assert.Equal(t, "sha256=91b5374b153842ad05b2c4eab9349b8321b14703165bd3fb8b034dfb8be98ae5", Sign("key", 1, []byte("body")))
+}
+
+func TestSink_Retries(t *testing.T) {
+	rec, url := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	var mu sync.Mutex
+	var failures []error
+	s := newSink(t, Config{
+		Endpoints: []Endpoint{{Name: "flaky", URL: url}},
+		OnError: func(endpoint string, a Alert, err error) {
+			mu.Lock()
+			failures = append(failures, err)
+			mu.Unlock()
+		},
+	})
+	s.Notify(diskAlert)
+	rec.wait(t, 3)
+	require.Eventually(t, func() bool { return s.Stats()["flaky"].Sent == 1 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, Stats{Sent: 1, Retries: 2}, s.Stats()["flaky"])
+
+	// Client errors are not retried
+	rec.respond(http.StatusBadRequest)
+	s.Notify(diskAlert)
+	rec.wait(t, 1)
+	require.Eventually(t, func() bool { return s.Stats()["flaky"].Failed == 1 }, 5*time.Second, time.Millisecond)
+	mu.Lock()
+	require.Len(t, failures, 1)
+	assert.Contains(t, failures[0].Error(), "400")
+	mu.Unlock()
+
+	// Attempts are bounded
+	rec.respond(500, 500, 500, 500)
+	s.Notify(diskAlert)
+	rec.wait(t, 4)
+	require.Eventually(t, func() bool { return s.Stats()["flaky"].Failed == 2 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, int64(5), s.Stats()["flaky"].Retries)
+}
+
+func TestSink_Filters(t *testing.T) {
+	rec, url := newReceiver(t)
+	s := newSink(t, Config{Endpoints: []Endpoint{
+		{Name: "default", URL: url, Template: `{"e": "default", "t": {{json .Title}}}`},
+		{Name: "all", URL: url, MinLevel: "info", Topics: []string{"reports"}, Template: `{"e": "all", "t": {{json .Title}}}`},
+		{Name: "unattended", URL: url, OnlyUnattended: true, Template: `{"e": "unattended", "t": {{json .Title}}}`},
+	}})
+	s.Notify(Alert{Level: "info", Title: "a"})                                    // all
+	s.Notify(Alert{Level: "warning", Title: "b", Attended: true})                 // default, all
+	s.Notify(Alert{Level: "warning", Title: "c", Topic: "other"})                 // default, unattended
+	s.Notify(Alert{Level: "info", Title: "d", Topic: "reports"})                  // all
+	s.Notify(Alert{Level: "error", Title: "e", Topic: "reports", Attended: true}) // default, all
+	rec.wait(t, 8)
+
+	got := map[string][]string{}
+	for i := range rec.bodies {
+		body := rec.body(t, i)
+		got[body["e"].(string)] = append(got[body["e"].(string)], body["t"].(string))
+	}
+	assert.ElementsMatch(t, []string{"b", "c", "e"}, got["default"])
+	assert.ElementsMatch(t, []string{"a", "b", "d", "e"}, got["all"])
+	assert.ElementsMatch(t, []string{"c"}, got["unattended"])
+}
+
+func TestSink_DropsWhenFull(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-block }))
+	defer srv.Close()
+
+	s, err := New(Config{Endpoints: []Endpoint{{Name: "slow", URL: srv.URL}}, QueueSize: 1, Timeout: 5 * time.Second})
+	require.NoError(t, err)
+	defer s.Close()
+	defer close(block)
+	for i := 0; i < 5; i++ {
+		s.Notify(diskAlert)
+	}
+	// One in flight, one queued, the rest dropped
+	assert.GreaterOrEqual(t, s.Stats()["slow"].Dropped, int64(3))
+}
+
+func TestSink_Close(t *testing.T) {
+	rec, url := newReceiver(t)
+	s, err := New(Config{Endpoints: []Endpoint{{URL: url}}})
+	require.NoError(t, err)
+	s.Notify(diskAlert)
+	s.Close()
+	assert.Equal(t, int64(1), s.Stats()["webhook1"].Sent, "queued alerts are sent before Close returns")
+
+	s.Notify(diskAlert)
+	s.Close()
+	assert.Len(t, rec.bodies, 1)
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+endpoints:
+  - name: oncall
+    url: env:PAGERDUTY_URL
+    format: pagerduty
+    routing_key: env:PD_KEY
+    min_level: error
+  - name: chat
+    url: https://hooks.slack.com/services/T/B/X
+    format: slack
+    only_unattended: true
+    topics: [reports]
+`), 0o600))
+	endpoints, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 2)
+	assert.Equal(t, "env:PAGERDUTY_URL", endpoints[0].URL, "references are resolved by the caller")
+	assert.Equal(t, "error", endpoints[0].MinLevel)
+	assert.True(t, endpoints[1].OnlyUnattended)
+	assert.Equal(t, []string{"reports"}, endpoints[1].Topics)
+
+	jsonPath := filepath.Join(t.TempDir(), "webhooks.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"endpoints": [{"name": "hook", "url": "https://x.test", "max_attempts": 2}]}`), 0o600))
+	endpoints, err = LoadFile(jsonPath)
+	require.NoError(t, err)
+	assert.Equal(t, 2, endpoints[0].MaxAttempts)
+}
+
+func TestBridge(t *testing.T) {
+	rec, url := newReceiver(t)
+	s := newSink(t, Config{Endpoints: []Endpoint{{URL: url, MinLevel: "info"}}})
+
+	b := Bridge(nil, s)
+	require.NoError(t, b.SendNotification("warning", "Health: wal_lag", "WAL lag 2500ms"))
+	require.NoError(t, b.Publish("reports", "info", "Report: daily", "All good"))
+	require.NoError(t, b.SendMessage("chat only"))
+	require.NoError(t, b.NotifyUser("alice", "info", "Personal", "not routed"))
+	assert.False(t, b.IsConnected())
+	rec.wait(t, 2)
+
+	first, second := rec.body(t, 0), rec.body(t, 1)
+	if first["title"] != "Health: wal_lag" {
+		first, second = second, first
+	}
+	assert.Equal(t, "bifrost", first["source"])
+	assert.Equal(t, false, first["attended"])
+	assert.Equal(t, "topic", second["source"])
+	assert.Equal(t, "reports", second["topic"])
+
+	inner := &heimdall.NoOpBifrost{}
+	assert.Same(t, inner, Bridge(inner, nil))
+}
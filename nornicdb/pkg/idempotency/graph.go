@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// RecordLabel labels the nodes holding recorded results.
+const RecordLabel = "_IdempotencyKey"
+
+// NodePrefix prefixes the IDs of record nodes.
+const NodePrefix = "_idempotency:"
+
+// GraphBackend keeps recorded results in the graph, one :_IdempotencyKey
+// node per key, so they are stored, replicated and backed up with the
+// writes they belong to. Node IDs hold a hash of the key, not the key.
+type GraphBackend struct {
+	engine storage.Engine
+}
+
+// NewGraphBackend returns a Backend over engine.
+func NewGraphBackend(engine storage.Engine) *GraphBackend {
+	return &GraphBackend{engine: engine}
+}
+
+func recordNodeID(key string) storage.NodeID {
+	sum := sha256.Sum256([]byte(key))
+	return storage.NodeID(NodePrefix + hex.EncodeToString(sum[:]))
+}
+
+// LoadRecord implements Backend.
+func (b *GraphBackend) LoadRecord(key string) (Record, bool, error) {
+	node, err := b.engine.GetNode(recordNodeID(key))
+	if errors.Is(err, storage.ErrNotFound) {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+	rec, err := recordFromNode(node)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("record %s: %w", node.ID, err)
+	}
+	return rec, true, nil
+}
+
+// SaveRecord implements Backend.
+func (b *GraphBackend) SaveRecord(key string, rec Record) error {
+	now := time.Now()
+	node := &storage.Node{
+		ID:     recordNodeID(key),
+		Labels: []string{RecordLabel},
+		Properties: map[string]any{
+			"fingerprint": rec.Fingerprint,
+			"expires_at":  rec.Expires.UnixMilli(),
+			"result":      string(rec.Result),
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err := b.engine.CreateNode(node)
+	if errors.Is(err, storage.ErrAlreadyExists) {
+		// An expired record not swept yet
+		return b.engine.UpdateNode(node)
+	}
+	return err
+}
+
+// DeleteRecord implements Backend.
+func (b *GraphBackend) DeleteRecord(key string) error {
+	err := b.engine.DeleteNode(recordNodeID(key))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// DeleteExpired implements Backend.
+func (b *GraphBackend) DeleteExpired(now time.Time) error {
+	nodes, err := b.engine.GetNodesByLabel(RecordLabel)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		rec, err := recordFromNode(node)
+		if err == nil && now.Before(rec.Expires) {
+			continue
+		}
+		if err := b.engine.DeleteNode(node.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func recordFromNode(node *storage.Node) (Record, error) {
+	var rec Record
+	rec.Fingerprint, _ = node.Properties["fingerprint"].(string)
+	switch expires := node.Properties["expires_at"].(type) {
+	case int64:
+		rec.Expires = time.UnixMilli(expires)
+	case float64: // Decoded from JSON
+		rec.Expires = time.UnixMilli(int64(expires))
+	default:
+		return rec, fmt.Errorf("invalid expires_at %v", expires)
+	}
+	result, _ := node.Properties["result"].(string)
+	rec.Result = []byte(result)
+	return rec, nil
+}
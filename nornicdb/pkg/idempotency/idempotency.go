@@ -0,0 +1,348 @@
+// Package idempotency applies retried write requests exactly once.
+//
+// Drivers retry a statement when a connection drops or the server reports
+// a transient error. If the first attempt had already been applied, an
+// auto-commit write such as CREATE runs twice. A client can tag a write
+// with an idempotency key to prevent this: the first request with a key
+// runs, and later requests with the same key within the TTL receive the
+// recorded result without running again. A request arriving while the
+// first is still running waits for it.
+//
+// Only successful results are recorded, so a request that failed can be
+// retried with the same key. Reusing a key for a different statement is an
+// error rather than a silent replay.
+//
+// The dedupe table is held in memory and shared by the Bolt and HTTP
+// servers. With a Backend (see SetBackend and GraphBackend) every recorded
+// result is also persisted until it expires, so a retry that arrives after
+// a restart or a failover to a replica is answered from the record instead
+// of being applied again. Results are persisted as JSON; Decode turns a
+// replayed value back into the recorded type. A write that commits but
+// whose record cannot be saved is logged: retries are then deduplicated
+// only until the process restarts.
+//
+// Example:
+//
+//	store := idempotency.New(15*time.Minute, 100000)
+//	store.SetBackend(idempotency.NewGraphBackend(engine))
+//
+//	key := idempotency.Scope(user, clientKey)
+//	result, replayed, err := store.Do(key, idempotency.Fingerprint(query, params),
+//		func() (any, error) { return executor.Execute(ctx, query, params) })
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ParamName is the query parameter clients may use to pass a key, for
+// drivers that cannot set Bolt transaction metadata. It is removed before
+// the query runs.
+const ParamName = "_idempotency_key"
+
+// MetadataKey is the Bolt tx_metadata entry holding a key.
+const MetadataKey = "idempotency_key"
+
+// HeaderName is the HTTP header holding a key.
+const HeaderName = "Idempotency-Key"
+
+// ErrKeyReused is returned when a key already recorded for one statement
+// arrives with a different one.
+var ErrKeyReused = errors.New("idempotency key was already used for a different statement")
+
+// ErrUnavailable is returned when the Backend cannot be read, so it is
+// unknown whether the key was already applied. The request can be retried.
+var ErrUnavailable = errors.New("idempotency keys are unavailable")
+
+// sweepInterval is how often expired records are deleted from the Backend.
+const sweepInterval = time.Minute
+
+// Backend persists recorded results. GraphBackend implements it.
+type Backend interface {
+	// LoadRecord returns the record of key, or false if there is none.
+	LoadRecord(key string) (Record, bool, error)
+	// SaveRecord stores the record of key, replacing an older one.
+	SaveRecord(key string, rec Record) error
+	// DeleteRecord removes the record of key, if any.
+	DeleteRecord(key string) error
+	// DeleteExpired removes the records that expired before now.
+	DeleteExpired(now time.Time) error
+}
+
+// Record is a persisted result.
+type Record struct {
+	Fingerprint string
+	Expires     time.Time
+	Result      json.RawMessage // The value fn returned, as JSON
+}
+
+// Store is a dedupe table of recorded results keyed by idempotency key.
+// It is safe for concurrent use. A nil *Store records nothing: Do always
+// runs fn.
+type Store struct {
+	ttl     time.Duration
+	maxKeys int
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	order   []*entry // insertion order, which is also expiry order
+
+	replays atomic.Int64
+
+	backend   Backend // Guarded by mu
+	lastSweep time.Time
+}
+
+type entry struct {
+	key         string
+	fingerprint string
+	expires     time.Time
+	done        chan struct{} // closed when value and err are set
+	value       any
+	err         error
+}
+
+// New returns a store that remembers results for ttl, holding at most
+// maxKeys keys in memory (0 = unlimited); the oldest keys are dropped
+// first, but stay in the Backend until they expire. It returns nil, which
+// disables deduplication, if ttl is not positive.
+func New(ttl time.Duration, maxKeys int) *Store {
+	if ttl <= 0 {
+		return nil
+	}
+	return &Store{
+		ttl:     ttl,
+		maxKeys: maxKeys,
+		now:     time.Now,
+		entries: make(map[string]*entry),
+	}
+}
+
+// SetBackend persists recorded results in b and deletes its expired
+// records. Keys recorded before the call are not persisted.
+func (s *Store) SetBackend(b Backend) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backend = b
+	s.lastSweep = s.now()
+	if b == nil {
+		return nil
+	}
+	return b.DeleteExpired(s.lastSweep)
+}
+
+// Scope qualifies a client key with the caller, so two users choosing the
+// same key do not see each other's results.
+func Scope(principal, key string) string {
+	return principal + "\x00" + key
+}
+
+// Fingerprint identifies a statement and its parameters, to detect keys
+// reused for a different request.
+func Fingerprint(query string, params map[string]any) string {
+	h := sha256.New()
+	h.Write([]byte(query))
+	h.Write([]byte{0})
+	if encoded, err := json.Marshal(params); err == nil {
+		h.Write(encoded)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Do runs fn once per key. If key holds a recorded result for the same
+// fingerprint, Do returns it with replayed set instead of running fn. An
+// empty key, or a nil store, always runs fn. A result replayed from the
+// Backend is a json.RawMessage; see Decode. If the Backend cannot be read,
+// Do fails rather than risk applying the write twice.
+func (s *Store) Do(key, fingerprint string, fn func() (any, error)) (value any, replayed bool, err error) {
+	if s == nil || key == "" {
+		value, err = fn()
+		return value, false, err
+	}
+
+	for {
+		s.mu.Lock()
+		s.expire()
+		e, ok := s.entries[key]
+		if !ok {
+			rec, found, err := s.load(key)
+			if err != nil || found {
+				s.mu.Unlock()
+				switch {
+				case err != nil:
+					return nil, false, err
+				case rec.Fingerprint != fingerprint:
+					return nil, false, ErrKeyReused
+				}
+				s.replays.Add(1)
+				return rec.Result, true, nil
+			}
+			e = &entry{key: key, fingerprint: fingerprint, expires: s.now().Add(s.ttl), done: make(chan struct{})}
+			s.entries[key] = e
+			s.order = append(s.order, e)
+			s.mu.Unlock()
+			value, err = s.run(e, fn)
+			return value, false, err
+		}
+		s.mu.Unlock()
+
+		if e.fingerprint != fingerprint {
+			return nil, false, ErrKeyReused
+		}
+		<-e.done
+		if e.err == nil {
+			s.replays.Add(1)
+			return e.value, true, nil
+		}
+		// The first attempt failed and was forgotten; try to run again
+	}
+}
+
+// run executes fn for a new entry, forgetting the entry if fn fails or
+// panics so the key can be retried.
+func (s *Store) run(e *entry, fn func() (any, error)) (value any, err error) {
+	completed := false
+	defer func() {
+		if !completed || e.err != nil {
+			if !completed {
+				e.err = errors.New("idempotency: request panicked")
+			}
+			s.mu.Lock()
+			if s.entries[e.key] == e {
+				delete(s.entries, e.key)
+			}
+			s.mu.Unlock()
+		}
+		close(e.done)
+	}()
+	e.value, e.err = fn()
+	completed = true
+	if e.err == nil {
+		s.save(e)
+	}
+	return e.value, e.err
+}
+
+// load returns the Backend record of key, deleting it if it expired, and
+// deletes the other expired records every sweepInterval. The caller must
+// hold s.mu.
+func (s *Store) load(key string) (Record, bool, error) {
+	if s.backend == nil {
+		return Record{}, false, nil
+	}
+	now := s.now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.lastSweep = now
+		if err := s.backend.DeleteExpired(now); err != nil {
+			log.Printf("⚠️  idempotency: deleting expired keys: %v", err)
+		}
+	}
+	rec, ok, err := s.backend.LoadRecord(key)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	if ok && !now.Before(rec.Expires) {
+		if err := s.backend.DeleteRecord(key); err != nil {
+			log.Printf("⚠️  idempotency: deleting expired key: %v", err)
+		}
+		return Record{}, false, nil
+	}
+	return rec, ok, nil
+}
+
+// save persists a recorded result. The write it belongs to has been
+// applied, so failures are logged rather than returned.
+func (s *Store) save(e *entry) {
+	s.mu.Lock()
+	backend := s.backend
+	s.mu.Unlock()
+	if backend == nil {
+		return
+	}
+	result, err := json.Marshal(e.value)
+	if err == nil {
+		err = backend.SaveRecord(e.key, Record{Fingerprint: e.fingerprint, Expires: e.expires, Result: result})
+	}
+	if err != nil {
+		log.Printf("⚠️  idempotency: recording key: %v (retries after a restart will run again)", err)
+	}
+}
+
+// Decode returns a value from Do as the type fn recorded. Values recorded
+// by this process are returned as they are; values replayed from the
+// Backend are decoded from JSON, with numbers as json.Number so they are
+// written back unchanged.
+func Decode[T any](value any) (T, error) {
+	if v, ok := value.(T); ok {
+		return v, nil
+	}
+	var v T
+	raw, ok := value.(json.RawMessage)
+	if !ok {
+		return v, fmt.Errorf("idempotency: recorded %T, not %T", value, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return v, fmt.Errorf("idempotency: decoding recorded result: %w", err)
+	}
+	return v, nil
+}
+
+// expire drops expired entries and, past maxKeys, the oldest ones. The
+// caller must hold s.mu. Records in the Backend are kept until they expire.
+func (s *Store) expire() {
+	now := s.now()
+	n := 0
+	for _, e := range s.order {
+		if s.entries[e.key] != e {
+			n++ // failed and already forgotten
+			continue
+		}
+		if now.Before(e.expires) && (s.maxKeys <= 0 || len(s.entries) < s.maxKeys) {
+			break
+		}
+		delete(s.entries, e.key)
+		n++
+	}
+	if n > 0 {
+		s.order = append(s.order[:0:0], s.order[n:]...)
+	}
+}
+
+// Stats describes the dedupe table.
+type Stats struct {
+	Keys    int   // keys currently recorded or in flight
+	Replays int64 // requests answered from the table
+}
+
+// Stats returns the number of recorded keys and replays so far.
+func (s *Store) Stats() Stats {
+	if s == nil {
+		return Stats{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{Keys: len(s.entries), Replays: s.replays.Load()}
+}
+
+// String describes the store for startup logs.
+func (s *Store) String() string {
+	if s == nil {
+		return "disabled"
+	}
+	return fmt.Sprintf("ttl %s, max %d keys", s.ttl, s.maxKeys)
+}
@@ -0,0 +1,227 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoRunsOncePerKey(t *testing.T) {
+	s := New(time.Minute, 0)
+	var runs int
+	fn := func() (any, error) { runs++; return runs, nil }
+
+	v, replayed, err := s.Do("k", "q", fn)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, 1, v)
+
+	v, replayed, err = s.Do("k", "q", fn)
+	require.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, 1, v, "retry gets the recorded result")
+	assert.Equal(t, 1, runs)
+
+	_, replayed, _ = s.Do("other", "q", fn)
+	assert.False(t, replayed)
+	assert.Equal(t, 2, runs)
+	assert.Equal(t, Stats{Keys: 2, Replays: 1}, s.Stats())
+}
+
+func TestDoWithoutKeyOrStore(t *testing.T) {
+	var runs int
+	fn := func() (any, error) { runs++; return nil, nil }
+
+	s := New(time.Minute, 0)
+	s.Do("", "q", fn)
+	s.Do("", "q", fn)
+
+	var disabled *Store
+	assert.Nil(t, New(0, 10), "non-positive TTL disables the store")
+	disabled.Do("k", "q", fn)
+	disabled.Do("k", "q", fn)
+
+	assert.Equal(t, 4, runs)
+	assert.Equal(t, Stats{}, disabled.Stats())
+}
+
+func TestDoFailureIsNotRecorded(t *testing.T) {
+	s := New(time.Minute, 0)
+	boom := errors.New("transient")
+
+	_, _, err := s.Do("k", "q", func() (any, error) { return nil, boom })
+	assert.ErrorIs(t, err, boom)
+
+	v, replayed, err := s.Do("k", "q", func() (any, error) { return "ok", nil })
+	require.NoError(t, err)
+	assert.False(t, replayed, "a failed attempt must not block the retry")
+	assert.Equal(t, "ok", v)
+}
+
+func TestDoKeyReused(t *testing.T) {
+	s := New(time.Minute, 0)
+	s.Do("k", Fingerprint("CREATE (n)", nil), func() (any, error) { return nil, nil })
+
+	_, _, err := s.Do("k", Fingerprint("CREATE (m)", nil), func() (any, error) {
+		t.Error("a reused key must not run the new statement")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, ErrKeyReused)
+}
+
+func TestDoExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := New(time.Minute, 2)
+	s.now = func() time.Time { return now }
+	var runs int
+	fn := func() (any, error) { runs++; return nil, nil }
+
+	s.Do("a", "q", fn)
+	now = now.Add(2 * time.Minute)
+	_, replayed, _ := s.Do("a", "q", fn)
+	assert.False(t, replayed, "expired keys run again")
+
+	// At capacity the oldest key is dropped
+	s.Do("b", "q", fn)
+	s.Do("c", "q", fn)
+	assert.Equal(t, 2, s.Stats().Keys)
+	_, replayed, _ = s.Do("c", "q", fn)
+	assert.True(t, replayed)
+	_, replayed, _ = s.Do("a", "q", fn)
+	assert.False(t, replayed)
+}
+
+func TestDoConcurrentRetriesWait(t *testing.T) {
+	s := New(time.Minute, 0)
+	var runs atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	replays := make([]bool, 5)
+	for i := range replays {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, replays[i], _ = s.Do("k", "q", func() (any, error) {
+				runs.Add(1)
+				<-release
+				return nil, nil
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), runs.Load())
+	n := 0
+	for _, r := range replays {
+		if r {
+			n++
+		}
+	}
+	assert.Equal(t, 4, n)
+}
+
+func TestDoPanicForgetsKey(t *testing.T) {
+	s := New(time.Minute, 0)
+	assert.Panics(t, func() {
+		s.Do("k", "q", func() (any, error) { panic("boom") })
+	})
+	_, replayed, err := s.Do("k", "q", func() (any, error) { return nil, nil })
+	require.NoError(t, err)
+	assert.False(t, replayed)
+}
+
+func TestScopeAndFingerprint(t *testing.T) {
+	assert.NotEqual(t, Scope("alice", "k"), Scope("bob", "k"))
+	assert.Equal(t,
+		Fingerprint("CREATE (n {x: $x})", map[string]any{"x": 1, "y": 2}),
+		Fingerprint("CREATE (n {x: $x})", map[string]any{"y": 2, "x": 1}))
+	assert.NotEqual(t,
+		Fingerprint("CREATE (n {x: $x})", map[string]any{"x": 1}),
+		Fingerprint("CREATE (n {x: $x})", map[string]any{"x": 2}))
+}
+
+type order struct {
+	ID    int64
+	Items []any
+}
+
+func TestDoAfterRestart(t *testing.T) {
+	engine := storage.NewMemoryEngine()
+	now := time.Unix(1000, 0)
+	newStore := func() *Store {
+		s := New(time.Minute, 0)
+		s.now = func() time.Time { return now }
+		require.NoError(t, s.SetBackend(NewGraphBackend(engine)))
+		return s
+	}
+	var runs int
+	fn := func() (any, error) { runs++; return &order{ID: 1 << 60, Items: []any{int64(2), 2.5}}, nil }
+
+	first := newStore()
+	_, _, err := first.Do("k", "q", fn)
+	require.NoError(t, err)
+	_, _, err = first.Do("gone", "q", fn)
+	require.NoError(t, err)
+
+	// A new process over the same storage replays the record
+	restarted := newStore()
+	v, replayed, err := restarted.Do("k", "q", fn)
+	require.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, 2, runs)
+	got, err := Decode[*order](v)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1<<60), got.ID, "integers keep their precision")
+	assert.Equal(t, []any{json.Number("2"), json.Number("2.5")}, got.Items)
+
+	_, _, err = restarted.Do("k", "other", fn)
+	assert.ErrorIs(t, err, ErrKeyReused)
+
+	// Expired records run again and are swept
+	now = now.Add(2 * time.Minute)
+	_, replayed, err = newStore().Do("k", "q", fn)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	nodes, err := engine.GetNodesByLabel(RecordLabel)
+	require.NoError(t, err)
+	assert.Len(t, nodes, 1, "only the rerun key is recorded")
+}
+
+type failingBackend struct{ Backend }
+
+func (failingBackend) LoadRecord(string) (Record, bool, error) {
+	return Record{}, false, storage.ErrStorageClosed
+}
+
+func (failingBackend) DeleteExpired(time.Time) error { return nil }
+
+func TestDoBackendUnavailable(t *testing.T) {
+	s := New(time.Minute, 0)
+	s.backend = failingBackend{}
+	_, _, err := s.Do("k", "q", func() (any, error) {
+		t.Error("a key that cannot be checked must not run")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.ErrorIs(t, err, storage.ErrStorageClosed)
+}
+
+func TestDecode(t *testing.T) {
+	o := &order{ID: 1}
+	got, err := Decode[*order](o)
+	require.NoError(t, err)
+	assert.Same(t, o, got, "values recorded in memory are returned as they are")
+
+	_, err = Decode[*order]("text")
+	assert.Error(t, err)
+}
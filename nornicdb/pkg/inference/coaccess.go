@@ -0,0 +1,453 @@
+// Package inference - co-access tracking for the co_access link prediction
+// signal.
+//
+// Nodes read close together in time, or returned by the same query, are
+// likely related. The CoAccessTracker keeps the most recent accesses in a
+// fixed-size ring buffer and aggregates co-accessed pairs into counts that
+// decay exponentially, so old habits fade and new ones take over:
+//
+//	count(t) = count(t0) × 2^(-(t - t0) / HalfLife)
+//
+// Aggregated pair counts can be saved to and loaded from a JSON file so the
+// signal survives restarts. The inference engine owns a tracker (see
+// Engine.CoAccess) and uses it for both OnAccess suggestions and the
+// co_access ensemble scorer.
+//
+// Example:
+//
+//	tracker := inference.NewCoAccessTracker(inference.DefaultCoAccessConfig())
+//	tracker.Load("data/coaccess.json")
+//	defer tracker.Save("data/coaccess.json")
+//
+//	tracker.RecordQuery([]string{"doc-1", "doc-2", "doc-3"}) // One result set
+//	tracker.RecordAccess("doc-1")
+//
+//	for _, p := range tracker.Neighbors("doc-1", 2) {
+//		fmt.Printf("%s: %.1f\n", p.NodeB, p.Count)
+//	}
+package inference
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// countTolerance absorbs decay between a co-access and a threshold check.
+const countTolerance = 1e-3
+
+// CoAccessConfig configures a CoAccessTracker.
+type CoAccessConfig struct {
+	// Window is how close in time two accesses must be to count as co-access
+	Window time.Duration
+
+	// BufferSize is the capacity of the recent-access ring buffer
+	BufferSize int
+
+	// HalfLife is how long a pair count takes to halve (0 disables decay)
+	HalfLife time.Duration
+
+	// PruneBelow drops pairs whose decayed count falls under this value
+	PruneBelow float64
+
+	// MaxPairs bounds the number of tracked pairs; the weakest are evicted
+	MaxPairs int
+
+	// MaxQueryNodes bounds how many nodes of one query result are paired
+	MaxQueryNodes int
+}
+
+// DefaultCoAccessConfig returns the default co-access tracker settings.
+func DefaultCoAccessConfig() *CoAccessConfig {
+	return &CoAccessConfig{
+		Window:        30 * time.Second,
+		BufferSize:    1024,
+		HalfLife:      24 * time.Hour,
+		PruneBelow:    0.5,
+		MaxPairs:      100000,
+		MaxQueryNodes: 50,
+	}
+}
+
+// CoAccessPair is an aggregated co-access count between two nodes.
+type CoAccessPair struct {
+	NodeA    string    `json:"a"`
+	NodeB    string    `json:"b"`
+	Count    float64   `json:"count"`     // Decayed to LastSeen when stored, to now when returned
+	LastSeen time.Time `json:"last_seen"` // Last co-access of the pair
+}
+
+// CoAccessTracker records node accesses and aggregates co-accessed pairs.
+// It is safe for concurrent use.
+type CoAccessTracker struct {
+	config *CoAccessConfig
+	mu     sync.Mutex
+
+	// Ring buffer of recent accesses
+	ring []accessRecord
+	head int // Next write position
+	size int
+
+	pairs map[coAccessKey]*CoAccessPair
+	adj   map[string]map[string]*CoAccessPair // Node -> other node -> pair
+
+	now func() time.Time
+}
+
+// NewCoAccessTracker creates a tracker. A nil config uses defaults.
+func NewCoAccessTracker(config *CoAccessConfig) *CoAccessTracker {
+	if config == nil {
+		config = DefaultCoAccessConfig()
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultCoAccessConfig().BufferSize
+	}
+	return &CoAccessTracker{
+		config: config,
+		ring:   make([]accessRecord, config.BufferSize),
+		pairs:  make(map[coAccessKey]*CoAccessPair),
+		adj:    make(map[string]map[string]*CoAccessPair),
+		now:    time.Now,
+	}
+}
+
+// RecordAccess records a read of nodeID and counts one co-access with every
+// other node read within the window. It returns the updated pairs with
+// NodeA set to nodeID.
+func (t *CoAccessTracker) RecordAccess(nodeID string) []CoAccessPair {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var updated []CoAccessPair
+	seen := map[string]bool{nodeID: true}
+	for _, other := range t.recentLocked(now) {
+		if seen[other] {
+			continue
+		}
+		seen[other] = true
+		p := t.incrementLocked(nodeID, other, now)
+		updated = append(updated, oriented(*p, nodeID))
+	}
+	t.pushLocked(nodeID, now)
+	return updated
+}
+
+// RecordQuery records the nodes returned by one query. Every pair in the
+// result counts as one co-access, and each node is added to the recent
+// accesses. Only the first MaxQueryNodes distinct nodes are paired.
+func (t *CoAccessTracker) RecordQuery(nodeIDs []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen := make(map[string]bool, len(nodeIDs))
+	nodes := make([]string, 0, len(nodeIDs))
+	for _, id := range nodeIDs {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		nodes = append(nodes, id)
+		if t.config.MaxQueryNodes > 0 && len(nodes) >= t.config.MaxQueryNodes {
+			break
+		}
+	}
+
+	now := t.now()
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			t.incrementLocked(nodes[i], nodes[j], now)
+		}
+		t.pushLocked(nodes[i], now)
+	}
+}
+
+// Count returns the decayed co-access count of a pair.
+func (t *CoAccessTracker) Count(a, b string) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pairs[pairKey(a, b)]
+	if !ok {
+		return 0
+	}
+	return t.decayed(p, t.now())
+}
+
+// Neighbors returns the nodes co-accessed with nodeID whose decayed count is
+// at least minCount, strongest first. NodeA of each pair is nodeID.
+//
+// The comparison tolerates the small decay since the last co-access, so a
+// pair counted minCount times a moment ago still qualifies.
+func (t *CoAccessTracker) Neighbors(nodeID string, minCount float64) []CoAccessPair {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var result []CoAccessPair
+	for _, p := range t.adj[nodeID] {
+		c := oriented(*p, nodeID)
+		c.Count = t.decayed(p, now)
+		if c.Count+countTolerance >= minCount {
+			result = append(result, c)
+		}
+	}
+	sortPairs(result)
+	return result
+}
+
+// Recent returns the node IDs accessed within the window, oldest first.
+func (t *CoAccessTracker) Recent() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.recentLocked(t.now())
+}
+
+// Activity returns how much each tracked node has been read: the decayed
+// counts of its pairs summed, plus one per access within the window. Nodes
+// read often, and alongside others, come out highest.
+func (t *CoAccessTracker) Activity() map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	activity := make(map[string]float64, len(t.adj))
+	for _, p := range t.pairs {
+		c := t.decayed(p, now)
+		activity[p.NodeA] += c
+		activity[p.NodeB] += c
+	}
+	for _, id := range t.recentLocked(now) {
+		activity[id]++
+	}
+	return activity
+}
+
+// Len returns the number of tracked pairs.
+func (t *CoAccessTracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pairs)
+}
+
+// Prune removes pairs whose decayed count is below PruneBelow and returns
+// how many were removed.
+func (t *CoAccessTracker) Prune() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pruneLocked(t.now())
+}
+
+// Pairs returns all tracked pairs with counts decayed to now, strongest first.
+func (t *CoAccessTracker) Pairs() []CoAccessPair {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	result := make([]CoAccessPair, 0, len(t.pairs))
+	for _, p := range t.pairs {
+		c := *p
+		c.Count = t.decayed(p, now)
+		result = append(result, c)
+	}
+	sortPairs(result)
+	return result
+}
+
+// coAccessFile is the on-disk format of saved pair counts.
+type coAccessFile struct {
+	Version int            `json:"version"`
+	SavedAt time.Time      `json:"saved_at"`
+	Pairs   []CoAccessPair `json:"pairs"`
+}
+
+// Save writes the aggregated pair counts to path. The recent-access buffer
+// is not saved. The file is written to a temp file first, then renamed.
+func (t *CoAccessTracker) Save(path string) error {
+	t.mu.Lock()
+	t.pruneLocked(t.now())
+	file := coAccessFile{Version: 1, SavedAt: t.now(), Pairs: make([]CoAccessPair, 0, len(t.pairs))}
+	for _, p := range t.pairs {
+		file.Pairs = append(file.Pairs, *p)
+	}
+	t.mu.Unlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// Load merges pair counts saved by Save into the tracker. A missing file is
+// not an error.
+func (t *CoAccessTracker) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var file coAccessFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parse co-access file %s: %w", path, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for _, saved := range file.Pairs {
+		if saved.NodeA == "" || saved.NodeB == "" || saved.NodeA == saved.NodeB {
+			continue
+		}
+		p := t.pairLocked(saved.NodeA, saved.NodeB)
+		// Bring both counts to the later timestamp before adding
+		at := saved.LastSeen
+		if p.LastSeen.After(at) {
+			at = p.LastSeen
+		}
+		p.Count = t.decayed(p, at) + t.decayed(&saved, at)
+		p.LastSeen = at
+	}
+	t.pruneLocked(now)
+	return nil
+}
+
+// recentLocked returns the node IDs accessed within the window, oldest first.
+func (t *CoAccessTracker) recentLocked(now time.Time) []string {
+	windowStart := now.Add(-t.config.Window)
+	var recent []string
+	for i := 0; i < t.size; i++ {
+		r := t.ring[(t.head-t.size+i+len(t.ring))%len(t.ring)]
+		if r.Timestamp.After(windowStart) {
+			recent = append(recent, r.NodeID)
+		}
+	}
+	return recent
+}
+
+// pushLocked appends an access to the ring buffer, overwriting the oldest.
+func (t *CoAccessTracker) pushLocked(nodeID string, now time.Time) {
+	t.ring[t.head] = accessRecord{NodeID: nodeID, Timestamp: now}
+	t.head = (t.head + 1) % len(t.ring)
+	if t.size < len(t.ring) {
+		t.size++
+	}
+}
+
+// pairLocked returns the pair for a and b, creating it if needed.
+func (t *CoAccessTracker) pairLocked(a, b string) *CoAccessPair {
+	key := pairKey(a, b)
+	if p, ok := t.pairs[key]; ok {
+		return p
+	}
+	p := &CoAccessPair{NodeA: key.NodeA, NodeB: key.NodeB}
+	t.pairs[key] = p
+	for _, n := range [2][2]string{{a, b}, {b, a}} {
+		if t.adj[n[0]] == nil {
+			t.adj[n[0]] = make(map[string]*CoAccessPair)
+		}
+		t.adj[n[0]][n[1]] = p
+	}
+	return p
+}
+
+// incrementLocked counts one co-access of a and b.
+func (t *CoAccessTracker) incrementLocked(a, b string, now time.Time) *CoAccessPair {
+	p := t.pairLocked(a, b)
+	p.Count = t.decayed(p, now) + 1
+	p.LastSeen = now
+	if t.config.MaxPairs > 0 && len(t.pairs) > t.config.MaxPairs {
+		t.evictLocked(now, p)
+	}
+	return p
+}
+
+// evictLocked prunes decayed pairs, then drops the weakest pairs until the
+// tracker is back under MaxPairs. keep is never evicted.
+func (t *CoAccessTracker) evictLocked(now time.Time, keep *CoAccessPair) {
+	t.pruneLocked(now)
+	over := len(t.pairs) - t.config.MaxPairs
+	if over <= 0 {
+		return
+	}
+	all := make([]*CoAccessPair, 0, len(t.pairs))
+	for _, p := range t.pairs {
+		if p != keep {
+			all = append(all, p)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return t.decayed(all[i], now) < t.decayed(all[j], now) })
+	for i := 0; i < over && i < len(all); i++ {
+		t.removeLocked(all[i])
+	}
+}
+
+// pruneLocked removes pairs decayed below PruneBelow.
+func (t *CoAccessTracker) pruneLocked(now time.Time) int {
+	if t.config.HalfLife <= 0 || t.config.PruneBelow <= 0 {
+		return 0
+	}
+	removed := 0
+	for _, p := range t.pairs {
+		if t.decayed(p, now) < t.config.PruneBelow {
+			t.removeLocked(p)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (t *CoAccessTracker) removeLocked(p *CoAccessPair) {
+	delete(t.pairs, pairKey(p.NodeA, p.NodeB))
+	for _, n := range [2][2]string{{p.NodeA, p.NodeB}, {p.NodeB, p.NodeA}} {
+		delete(t.adj[n[0]], n[1])
+		if len(t.adj[n[0]]) == 0 {
+			delete(t.adj, n[0])
+		}
+	}
+}
+
+// decayed returns the pair count decayed from LastSeen to at.
+func (t *CoAccessTracker) decayed(p *CoAccessPair, at time.Time) float64 {
+	if t.config.HalfLife <= 0 || !at.After(p.LastSeen) {
+		return p.Count
+	}
+	return p.Count * math.Exp2(-float64(at.Sub(p.LastSeen))/float64(t.config.HalfLife))
+}
+
+// pairKey orders a and b so both directions share one key.
+func pairKey(a, b string) coAccessKey {
+	if a < b {
+		return coAccessKey{NodeA: a, NodeB: b}
+	}
+	return coAccessKey{NodeA: b, NodeB: a}
+}
+
+// oriented returns p with NodeA set to nodeID.
+func oriented(p CoAccessPair, nodeID string) CoAccessPair {
+	if p.NodeA != nodeID {
+		p.NodeA, p.NodeB = p.NodeB, p.NodeA
+	}
+	return p
+}
+
+func sortPairs(pairs []CoAccessPair) {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Count != pairs[j].Count {
+			return pairs[i].Count > pairs[j].Count
+		}
+		if pairs[i].NodeA != pairs[j].NodeA {
+			return pairs[i].NodeA < pairs[j].NodeA
+		}
+		return pairs[i].NodeB < pairs[j].NodeB
+	})
+}
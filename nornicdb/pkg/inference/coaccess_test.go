@@ -0,0 +1,185 @@
+package inference
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTracker returns a tracker driven by a manual clock.
+func newTestTracker(config *CoAccessConfig) (*CoAccessTracker, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewCoAccessTracker(config)
+	tracker.now = func() time.Time { return now }
+	return tracker, &now
+}
+
+func TestCoAccessTracker_RecordAccess(t *testing.T) {
+	config := DefaultCoAccessConfig()
+	config.HalfLife = 0
+	tracker, now := newTestTracker(config)
+
+	assert.Empty(t, tracker.RecordAccess("a"))
+	updated := tracker.RecordAccess("b")
+	require.Len(t, updated, 1)
+	assert.Equal(t, CoAccessPair{NodeA: "b", NodeB: "a", Count: 1, LastSeen: *now}, updated[0])
+
+	// Repeated reads of a node within the window count once
+	tracker.RecordAccess("a")
+	updated = tracker.RecordAccess("c")
+	assert.Len(t, updated, 2)
+	assert.Equal(t, 2.0, tracker.Count("a", "b"))
+	assert.Equal(t, 1.0, tracker.Count("c", "a"))
+
+	*now = now.Add(time.Minute)
+	assert.Empty(t, tracker.RecordAccess("d"), "accesses outside the window are not paired")
+	assert.Equal(t, []string{"d"}, tracker.Recent())
+	assert.Equal(t, 3, tracker.Len())
+}
+
+func TestCoAccessTracker_RingBuffer(t *testing.T) {
+	config := DefaultCoAccessConfig()
+	config.BufferSize = 3
+	tracker, _ := newTestTracker(config)
+
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		tracker.RecordAccess(id)
+	}
+	assert.Equal(t, []string{"c", "d", "e"}, tracker.Recent())
+	assert.Zero(t, tracker.Count("a", "e"), "overwritten accesses are not paired")
+	assert.Equal(t, 1.0, tracker.Count("b", "e"))
+}
+
+func TestCoAccessTracker_RecordQuery(t *testing.T) {
+	config := DefaultCoAccessConfig()
+	config.MaxQueryNodes = 3
+	tracker, _ := newTestTracker(config)
+
+	tracker.RecordQuery([]string{"a", "b", "a", "", "c", "d"})
+	assert.Equal(t, 3, tracker.Len(), "only the first 3 distinct nodes are paired")
+	assert.Equal(t, 1.0, tracker.Count("a", "c"))
+	assert.Zero(t, tracker.Count("a", "d"))
+	assert.Equal(t, []string{"a", "b", "c"}, tracker.Recent())
+
+	tracker.RecordAccess("d")
+	assert.Equal(t, 1.0, tracker.Count("d", "b"), "query results pair with later reads")
+
+	neighbors := tracker.Neighbors("b", 0)
+	require.Len(t, neighbors, 3)
+	for _, p := range neighbors {
+		assert.Equal(t, "b", p.NodeA)
+	}
+	assert.Empty(t, tracker.Neighbors("b", 2))
+}
+
+func TestCoAccessTracker_Activity(t *testing.T) {
+	config := DefaultCoAccessConfig()
+	config.HalfLife = time.Hour
+	tracker, now := newTestTracker(config)
+
+	tracker.RecordQuery([]string{"a", "b", "c"})
+	tracker.RecordAccess("a")
+	// Pairs a-b: 2, a-c: 2, b-c: 1, plus the accesses in the window
+	assert.Equal(t, map[string]float64{"a": 6, "b": 4, "c": 4}, tracker.Activity())
+
+	*now = now.Add(time.Hour)
+	activity := tracker.Activity()
+	assert.InDelta(t, 2.0, activity["a"], 1e-9, "pair counts halve, recent accesses expire")
+	assert.InDelta(t, 1.5, activity["b"], 1e-9)
+}
+
+func TestCoAccessTracker_Decay(t *testing.T) {
+	config := DefaultCoAccessConfig()
+	config.HalfLife = time.Hour
+	config.PruneBelow = 0.3
+	tracker, now := newTestTracker(config)
+
+	tracker.RecordQuery([]string{"a", "b"})
+	tracker.RecordQuery([]string{"a", "b"})
+	tracker.RecordQuery([]string{"a", "c"})
+
+	*now = now.Add(time.Hour)
+	assert.InDelta(t, 1.0, tracker.Count("a", "b"), 0.001)
+	assert.InDelta(t, 0.5, tracker.Count("a", "c"), 0.001)
+
+	tracker.RecordQuery([]string{"a", "b"})
+	assert.InDelta(t, 2.0, tracker.Count("a", "b"), 0.001, "decayed count plus one")
+
+	*now = now.Add(time.Hour)
+	assert.Equal(t, 1, tracker.Prune())
+	assert.Zero(t, tracker.Count("a", "c"))
+	assert.Equal(t, 1, tracker.Len())
+}
+
+func TestCoAccessTracker_MaxPairs(t *testing.T) {
+	config := DefaultCoAccessConfig()
+	config.MaxPairs = 2
+	tracker, _ := newTestTracker(config)
+
+	tracker.RecordQuery([]string{"a", "b"})
+	tracker.RecordQuery([]string{"a", "b"})
+	tracker.RecordQuery([]string{"c", "d"})
+	tracker.RecordQuery([]string{"e", "f"})
+
+	assert.Equal(t, 2, tracker.Len())
+	assert.Equal(t, 2.0, tracker.Count("a", "b"), "strongest pair is kept")
+	assert.Equal(t, 1.0, tracker.Count("e", "f"), "newest pair is kept")
+	assert.Zero(t, tracker.Count("c", "d"))
+	assert.Empty(t, tracker.Neighbors("c", 0))
+}
+
+func TestCoAccessTracker_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coaccess.json")
+	config := DefaultCoAccessConfig()
+	config.HalfLife = time.Hour
+
+	tracker, now := newTestTracker(config)
+	tracker.RecordQuery([]string{"a", "b"})
+	tracker.RecordQuery([]string{"a", "b"})
+	tracker.RecordQuery([]string{"b", "c"})
+	require.NoError(t, tracker.Save(path))
+	_, err := os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err), "temp file is renamed")
+
+	restored, later := newTestTracker(config)
+	*later = now.Add(time.Hour)
+	require.NoError(t, restored.Load(path))
+	assert.InDelta(t, 1.0, restored.Count("a", "b"), 0.001, "counts keep decaying across restarts")
+	assert.InDelta(t, 0.5, restored.Count("b", "c"), 0.001)
+	assert.Empty(t, restored.Recent(), "recent accesses are not persisted")
+
+	// Loading merges with existing counts
+	require.NoError(t, restored.Load(path))
+	assert.InDelta(t, 2.0, restored.Count("a", "b"), 0.001)
+
+	assert.NoError(t, restored.Load(filepath.Join(t.TempDir(), "missing.json")))
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+	assert.Error(t, restored.Load(path))
+}
+
+func TestEngine_OnQuery(t *testing.T) {
+	config := DefaultConfig()
+	config.CoAccessMinCount = 2
+	config.ScorerWeights = map[string]float64{ScorerCoAccess: 1}
+	engine := New(config)
+	ctx := context.Background()
+
+	engine.OnQuery(ctx, []string{"x", "y", "z"})
+	engine.OnQuery(ctx, []string{"x", "y"})
+	assert.Equal(t, 3, engine.GetStats().TrackedCoAccesses)
+
+	suggestions, err := engine.OnStore(ctx, "x", nil)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "y", suggestions[0].TargetID)
+	assert.InDelta(t, 0.2, suggestions[0].Confidence, 0.001)
+
+	disabled := New(&Config{})
+	disabled.OnQuery(ctx, []string{"x", "y"})
+	assert.Zero(t, disabled.CoAccess().Len())
+}
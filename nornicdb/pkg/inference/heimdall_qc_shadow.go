@@ -0,0 +1,214 @@
+// Package inference - shadow mode evaluation for Heimdall QC.
+//
+// With HeimdallQCConfig.ShadowMode set, Heimdall still reviews every batch
+// but filters nothing: each suggestion is returned, its provenance carrying
+// the decision QC would have made (QCShadowApproved or QCShadowRejected).
+// The decisions are kept until the edge's fate is known:
+//
+//   - RecordOutcome(src, dst, true): the edge was used or confirmed
+//     (materialized, or both ends read together)
+//   - RecordOutcome(src, dst, false): the edge was rejected by a user,
+//     a reviewer or a policy
+//
+// Only the first outcome of a decision counts. Outcomes are tallied per
+// suggestion method and per prompt version, so a new prompt or model can
+// be compared against real feedback before it is allowed to filter:
+//
+//	precision = approved and accepted / approved with an outcome
+//	recall    = approved and accepted / accepted with an outcome
+//
+// Example:
+//
+//	cfg := inference.DefaultHeimdallQCConfig()
+//	cfg.ShadowMode = true
+//	engine.SetHeimdallQC(inference.NewHeimdallQC(heimdallFunc, cfg))
+//
+//	for _, row := range engine.GetHeimdallQC().ShadowReport() {
+//		fmt.Printf("%s %s: precision %.2f recall %.2f\n",
+//			row.Dimension, row.Key, row.Precision(), row.Recall())
+//	}
+package inference
+
+import (
+	"sort"
+	"sync"
+)
+
+// Dimensions of a QCEvaluation.
+const (
+	QCEvalByMethod        = "method"
+	QCEvalByPromptVersion = "prompt_version"
+)
+
+// maxShadowDecisions bounds how many decisions await an outcome.
+const maxShadowDecisions = 10000
+
+// QCEvaluation compares the shadow decisions of one method or prompt
+// version with the outcomes of the edges they were made on.
+type QCEvaluation struct {
+	Dimension      string // QCEvalByMethod or QCEvalByPromptVersion
+	Key            string // Method name or prompt version
+	Pending        int64  // Decisions still awaiting an outcome
+	TruePositives  int64  // Approved, then accepted
+	FalsePositives int64  // Approved, then rejected
+	FalseNegatives int64  // Rejected, then accepted
+	TrueNegatives  int64  // Rejected, then rejected
+}
+
+// Precision returns the share of approvals that were accepted, or 0
+// without outcomes.
+func (q QCEvaluation) Precision() float64 {
+	if q.TruePositives+q.FalsePositives == 0 {
+		return 0
+	}
+	return float64(q.TruePositives) / float64(q.TruePositives+q.FalsePositives)
+}
+
+// Recall returns the share of accepted edges QC approved, or 0 without
+// outcomes.
+func (q QCEvaluation) Recall() float64 {
+	if q.TruePositives+q.FalseNegatives == 0 {
+		return 0
+	}
+	return float64(q.TruePositives) / float64(q.TruePositives+q.FalseNegatives)
+}
+
+// Outcomes returns how many decisions have an outcome.
+func (q QCEvaluation) Outcomes() int64 {
+	return q.TruePositives + q.FalsePositives + q.FalseNegatives + q.TrueNegatives
+}
+
+type shadowDecision struct {
+	method        string
+	promptVersion string
+	approved      bool
+}
+
+type evalKey struct {
+	dimension string
+	key       string
+}
+
+// shadowLog holds shadow decisions awaiting an outcome and the tallies
+// of resolved ones.
+type shadowLog struct {
+	mu        sync.Mutex
+	decisions map[coAccessKey]shadowDecision
+	evals     map[evalKey]*QCEvaluation
+}
+
+func newShadowLog() *shadowLog {
+	return &shadowLog{
+		decisions: make(map[coAccessKey]shadowDecision),
+		evals:     make(map[evalKey]*QCEvaluation),
+	}
+}
+
+// record remembers the decision on an edge, replacing an earlier one.
+func (s *shadowLog) record(sourceID, targetID string, d shadowDecision) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := pairKey(sourceID, targetID)
+	if old, ok := s.decisions[key]; ok {
+		s.forget(old)
+	} else if len(s.decisions) >= maxShadowDecisions {
+		for k, old := range s.decisions {
+			delete(s.decisions, k)
+			s.forget(old)
+			break
+		}
+	}
+	s.decisions[key] = d
+	s.evalFor(QCEvalByMethod, d.method).Pending++
+	s.evalFor(QCEvalByPromptVersion, d.promptVersion).Pending++
+}
+
+// resolve tallies the outcome of the decision on an edge, if any.
+func (s *shadowLog) resolve(sourceID, targetID string, accepted bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := pairKey(sourceID, targetID)
+	d, ok := s.decisions[key]
+	if !ok {
+		return
+	}
+	delete(s.decisions, key)
+	s.forget(d)
+	for _, q := range []*QCEvaluation{
+		s.evalFor(QCEvalByMethod, d.method),
+		s.evalFor(QCEvalByPromptVersion, d.promptVersion),
+	} {
+		switch {
+		case d.approved && accepted:
+			q.TruePositives++
+		case d.approved:
+			q.FalsePositives++
+		case accepted:
+			q.FalseNegatives++
+		default:
+			q.TrueNegatives++
+		}
+	}
+}
+
+// forget drops a decision from the pending counts. Caller must hold s.mu.
+func (s *shadowLog) forget(d shadowDecision) {
+	s.evalFor(QCEvalByMethod, d.method).Pending--
+	s.evalFor(QCEvalByPromptVersion, d.promptVersion).Pending--
+}
+
+// evalFor returns the tallies of a key. Caller must hold s.mu.
+func (s *shadowLog) evalFor(dimension, key string) *QCEvaluation {
+	k := evalKey{dimension: dimension, key: key}
+	q, ok := s.evals[k]
+	if !ok {
+		q = &QCEvaluation{Dimension: dimension, Key: key}
+		s.evals[k] = q
+	}
+	return q
+}
+
+// report returns the tallies ordered by dimension and key.
+func (s *shadowLog) report() []QCEvaluation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows := make([]QCEvaluation, 0, len(s.evals))
+	for _, q := range s.evals {
+		rows = append(rows, *q)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Dimension != rows[j].Dimension {
+			return rows[i].Dimension < rows[j].Dimension
+		}
+		return rows[i].Key < rows[j].Key
+	})
+	return rows
+}
+
+// ShadowMode reports whether QC only records its decisions.
+func (h *HeimdallQC) ShadowMode() bool {
+	return h.config.ShadowMode
+}
+
+// PromptVersion returns the version shadow decisions are tallied under:
+// HeimdallQCConfig.PromptVersion, or a short hash of the system prompt.
+func (h *HeimdallQC) PromptVersion(augment bool) string {
+	if h.config.PromptVersion != "" {
+		return h.config.PromptVersion
+	}
+	return promptHash(GetSystemPrompt(augment))[:12]
+}
+
+// RecordOutcome records whether an edge QC reviewed in shadow mode turned
+// out to be wanted: accepted when it was materialized or used, rejected
+// when a user, reviewer or policy rejected it. Edges without a pending
+// shadow decision are ignored.
+func (h *HeimdallQC) RecordOutcome(sourceID, targetID string, accepted bool) {
+	h.shadow.resolve(sourceID, targetID, accepted)
+}
+
+// ShadowReport returns the precision and recall of shadow decisions per
+// method and per prompt version.
+func (h *HeimdallQC) ShadowReport() []QCEvaluation {
+	return h.shadow.report()
+}
@@ -0,0 +1,152 @@
+package inference
+
+import (
+	"context"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newShadowQC(response string) *HeimdallQC {
+	cfg := DefaultHeimdallQCConfig()
+	cfg.ShadowMode = true
+	return NewHeimdallQC(func(ctx context.Context, prompt string) (string, error) {
+		return response, nil
+	}, cfg)
+}
+
+func TestHeimdallQC_ShadowMode_DoesNotFilter(t *testing.T) {
+	cleanupQC := config.WithAutoTLPLLMQCEnabled()
+	defer cleanupQC()
+	cleanupAug := config.WithAutoTLPLLMAugmentEnabled()
+	defer cleanupAug()
+
+	qc := newShadowQC(`{"approved": [0], "rejected": [1], "additional": [{"target_id": "pool-1", "type": "X", "conf": 0.9}]}`)
+	suggestions := createTestSuggestions(2)
+	weak := EdgeSuggestion{SourceID: "source-node", TargetID: "weak", Confidence: 0.1, Method: "similarity"}
+	suggestions = append(suggestions, weak)
+
+	kept, augmented, err := qc.ReviewBatch(context.Background(), NodeSummary{ID: "source-node"}, suggestions, []NodeSummary{{ID: "pool-1"}})
+	require.NoError(t, err)
+	assert.Empty(t, augmented, "shadow mode adds no edges")
+	require.Len(t, kept, 3)
+
+	decisions := map[string]string{}
+	for _, sug := range kept {
+		if sug.Provenance != nil {
+			decisions[sug.TargetID] = sug.Provenance.QCDecision
+		}
+	}
+	assert.Equal(t, QCShadowApproved, decisions["target-0"])
+	assert.Equal(t, QCShadowRejected, decisions["target-1"])
+	assert.NotContains(t, decisions, "weak", "below the review threshold: kept without a decision")
+}
+
+func TestHeimdallQC_ShadowReport(t *testing.T) {
+	cleanup := config.WithAutoTLPLLMQCEnabled()
+	defer cleanup()
+
+	qc := newShadowQC(`{"approved": [0, 1], "rejected": [2, 3]}`)
+	suggestions := createTestSuggestions(4)
+	suggestions[3].Method = "co_access"
+	_, _, err := qc.ReviewBatch(context.Background(), NodeSummary{ID: "source-node"}, suggestions, nil)
+	require.NoError(t, err)
+
+	qc.RecordOutcome("target-0", "source-node", true) // Order of IDs does not matter
+	qc.RecordOutcome("source-node", "target-1", false)
+	qc.RecordOutcome("source-node", "target-2", true)
+	qc.RecordOutcome("source-node", "target-0", false) // Only the first outcome counts
+	qc.RecordOutcome("source-node", "unknown", true)
+
+	version := qc.PromptVersion(false)
+	assert.Len(t, version, 12)
+	rows := map[string]QCEvaluation{}
+	for _, row := range qc.ShadowReport() {
+		rows[row.Dimension+":"+row.Key] = row
+	}
+	require.Len(t, rows, 3)
+
+	sim := rows["method:similarity"]
+	assert.Equal(t, int64(1), sim.TruePositives)
+	assert.Equal(t, int64(1), sim.FalsePositives)
+	assert.Equal(t, int64(1), sim.FalseNegatives)
+	assert.Equal(t, int64(0), sim.TrueNegatives)
+	assert.Equal(t, int64(0), sim.Pending)
+	assert.Equal(t, int64(3), sim.Outcomes())
+	assert.InDelta(t, 0.5, sim.Precision(), 0.001)
+	assert.InDelta(t, 0.5, sim.Recall(), 0.001)
+
+	coAccess := rows["method:co_access"]
+	assert.Equal(t, int64(1), coAccess.Pending)
+	assert.Zero(t, coAccess.Precision(), "no outcomes yet")
+
+	byVersion := rows["prompt_version:"+version]
+	assert.Equal(t, int64(3), byVersion.Outcomes())
+	assert.Equal(t, int64(1), byVersion.Pending)
+
+	qc.RecordOutcome("source-node", "target-3", false)
+	for _, row := range qc.ShadowReport() {
+		if row.Key == "co_access" {
+			assert.Equal(t, int64(1), row.TrueNegatives)
+			assert.Equal(t, int64(0), row.Pending)
+		}
+	}
+}
+
+func TestHeimdallQC_ShadowPromptVersion(t *testing.T) {
+	cfg := DefaultHeimdallQCConfig()
+	cfg.PromptVersion = "v2"
+	qc := NewHeimdallQC(nil, cfg)
+	assert.Equal(t, "v2", qc.PromptVersion(true))
+
+	qc = NewHeimdallQC(nil, nil)
+	assert.NotEqual(t, qc.PromptVersion(false), qc.PromptVersion(true), "versions follow the system prompt")
+	assert.False(t, qc.ShadowMode())
+
+	cleanup := config.WithAutoTLPLLMQCShadowEnabled()
+	defer cleanup()
+	assert.True(t, NewHeimdallQC(nil, nil).ShadowMode(), "the feature flag sets the default")
+}
+
+func TestEngine_ShadowQCOutcomes(t *testing.T) {
+	cleanup := config.WithAutoTLPLLMQCEnabled()
+	defer cleanup()
+
+	cfg := DefaultConfig()
+	cfg.ScorerWeights = map[string]float64{ScorerSimilarity: 1}
+	engine := New(cfg)
+	engine.SetSimilaritySearch(func(ctx context.Context, embedding []float32, k int) ([]SimilarityResult, error) {
+		return []SimilarityResult{{ID: "a", Score: 0.95}, {ID: "b", Score: 0.95}, {ID: "c", Score: 0.95}}, nil
+	})
+	qc := newShadowQC(`{"approved": [0], "rejected": [1, 2]}`)
+	engine.SetHeimdallQC(qc)
+
+	suggestions, err := engine.OnStore(context.Background(), "src", []float32{1})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 3, "shadow mode keeps rejected suggestions")
+	assert.Equal(t, int64(3), engine.GetStats().Scorers[ScorerSimilarity].Suggested)
+
+	engine.RecordMaterialization("src", suggestions[0].TargetID, "RELATES_TO")
+	engine.RecordRejection("src", suggestions[1].TargetID)
+	engine.OnAccess(context.Background(), "src")
+	engine.OnAccess(context.Background(), suggestions[2].TargetID) // Used together
+
+	var sim QCEvaluation
+	for _, row := range qc.ShadowReport() {
+		if row.Dimension == QCEvalByMethod {
+			sim = row
+		}
+	}
+	assert.Equal(t, ScorerSimilarity, sim.Key)
+	assert.Equal(t, int64(1), sim.TruePositives)
+	assert.Equal(t, int64(1), sim.TrueNegatives)
+	assert.Equal(t, int64(1), sim.FalseNegatives)
+	assert.InDelta(t, 1.0, sim.Precision(), 0.001)
+	assert.InDelta(t, 0.5, sim.Recall(), 0.001)
+
+	stats := engine.GetStats().Scorers[ScorerSimilarity]
+	assert.Equal(t, int64(1), stats.Accepted)
+	assert.Equal(t, int64(1), stats.Rejected, "only the user rejection counts against the method")
+}
@@ -61,6 +61,10 @@
 //     If A relates to B and B relates to C, then A might relate to C.
 //     Example: "Python" → "Programming" → "Computers" suggests "Python" → "Computers"
 //
+// OnStore blends similarity, co-access and graph topology (common neighbors,
+// Adamic-Adar) through pluggable Scorers with configurable weights; see
+// scorer.go.
+//
 // ELI12 (Explain Like I'm 12):
 //
 // Imagine you're organizing your school notebooks:
@@ -87,6 +91,7 @@ import (
 	"time"
 
 	"github.com/orneryd/nornicdb/pkg/config"
+	"github.com/orneryd/nornicdb/pkg/gpu"
 	"github.com/orneryd/nornicdb/pkg/storage"
 )
 
@@ -97,7 +102,8 @@ type EdgeSuggestion struct {
 	Type       string
 	Confidence float64
 	Reason     string
-	Method     string // similarity, co_access, temporal, transitive
+	Method     string      // Scorer name(s), e.g. "similarity" or "similarity,adamic_adar"; co_access, transitive
+	Provenance *Provenance // How the suggestion was produced (may be nil)
 }
 
 // Config holds inference engine configuration options.
@@ -132,6 +138,7 @@ type Config struct {
 	CoAccessEnabled  bool
 	CoAccessWindow   time.Duration // Time window for co-access
 	CoAccessMinCount int           // Minimum co-accesses to suggest edge
+	CoAccessHalfLife time.Duration // Co-access counts halve over this period (0 = no decay)
 
 	// Temporal proximity
 	TemporalEnabled bool
@@ -140,6 +147,10 @@ type Config struct {
 	// Transitive inference
 	TransitiveEnabled bool
 	TransitiveMinConf float64 // Minimum confidence for transitive edges
+
+	// Ensemble weights by scorer name (nil uses DefaultScorerWeights).
+	// Scorers missing from the map are disabled.
+	ScorerWeights map[string]float64
 }
 
 // DefaultConfig returns balanced default configuration suitable for most use cases.
@@ -149,6 +160,7 @@ type Config struct {
 //   - SimilarityTopK: 10 (check 10 most similar)
 //   - CoAccessWindow: 30 seconds
 //   - CoAccessMinCount: 3 (need 3 co-accesses before suggesting)
+//   - CoAccessHalfLife: 24 hours
 //   - TemporalWindow: 30 minutes (same "session")
 //   - TransitiveMinConf: 0.5 (moderate confidence)
 //
@@ -168,10 +180,12 @@ func DefaultConfig() *Config {
 		CoAccessEnabled:     true,
 		CoAccessWindow:      30 * time.Second,
 		CoAccessMinCount:    3,
+		CoAccessHalfLife:    24 * time.Hour,
 		TemporalEnabled:     true,
 		TemporalWindow:      30 * time.Minute,
 		TransitiveEnabled:   true,
 		TransitiveMinConf:   0.5,
+		ScorerWeights:       DefaultScorerWeights(),
 	}
 }
 
@@ -218,12 +232,15 @@ type Engine struct {
 	mu     sync.RWMutex
 
 	// Co-access tracking
-	accessHistory  []accessRecord
-	coAccessCounts map[coAccessKey]int
+	coAccess *CoAccessTracker
 
 	// For similarity lookups (injected dependency)
 	similaritySearch func(ctx context.Context, embedding []float32, k int) ([]SimilarityResult, error)
 
+	// For rescoring candidate pools by embedding (injected dependencies)
+	embeddingLookup func(id string) ([]float32, bool)
+	gpuManager      *gpu.Manager
+
 	// Optional topology integration (NEW)
 	topologyIntegration *TopologyIntegration
 
@@ -243,6 +260,11 @@ type Engine struct {
 	// Heimdall SLM quality control - validates edges before creation
 	// Enabled via NORNICDB_AUTO_TLP_LLM_QC_ENABLED=true
 	heimdallQC *HeimdallQC
+
+	// Suggestion scorers blended by OnStore, with per-method outcomes
+	scorers       []weightedScorer
+	scorerMetrics map[string]*ScorerMetrics
+	attributions  map[coAccessKey][]string // Pending suggestion -> contributing methods
 }
 
 type accessRecord struct {
@@ -287,15 +309,22 @@ func New(config *Config) *Engine {
 		config = DefaultConfig()
 	}
 
-	return &Engine{
+	coAccessConfig := DefaultCoAccessConfig()
+	coAccessConfig.Window = config.CoAccessWindow
+	coAccessConfig.HalfLife = config.CoAccessHalfLife
+
+	e := &Engine{
 		config:          config,
-		accessHistory:   make([]accessRecord, 0),
-		coAccessCounts:  make(map[coAccessKey]int),
+		coAccess:        NewCoAccessTracker(coAccessConfig),
 		cooldownTable:   NewCooldownTable(),
 		evidenceBuffer:  NewEvidenceBuffer(),
 		edgeMetaStore:   storage.NewEdgeMetaStore(),
 		nodeConfigStore: storage.NewNodeConfigStore(),
+		scorerMetrics:   make(map[string]*ScorerMetrics),
+		attributions:    make(map[coAccessKey][]string),
 	}
+	e.registerBuiltinScorers()
+	return e
 }
 
 // SetSimilaritySearch sets the similarity search function.
@@ -305,6 +334,23 @@ func (e *Engine) SetSimilaritySearch(fn func(ctx context.Context, embedding []fl
 	e.similaritySearch = fn
 }
 
+// SetEmbeddingLookup sets the function that returns a node's embedding.
+// It enables the embedding scorer, which rescores the candidates proposed by
+// the other scorers against the source embedding.
+func (e *Engine) SetEmbeddingLookup(fn func(id string) ([]float32, bool)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.embeddingLookup = fn
+}
+
+// SetGPUManager sets the GPU manager used to score candidate pools.
+// With nil (or a disabled manager) candidates are scored on the CPU.
+func (e *Engine) SetGPUManager(manager *gpu.Manager) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.gpuManager = manager
+}
+
 // SetTopologyIntegration enables topological link prediction.
 //
 // This adds graph structure analysis to edge suggestions, combining topology
@@ -499,35 +545,14 @@ func (e *Engine) OnStore(ctx context.Context, nodeID string, embedding []float32
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	suggestions := make([]EdgeSuggestion, 0)
-
-	// 1. Similarity-based suggestions (semantic)
-	if e.similaritySearch != nil && len(embedding) > 0 {
-		similar, err := e.similaritySearch(ctx, embedding, e.config.SimilarityTopK)
-		if err == nil {
-			for _, result := range similar {
-				if result.ID == nodeID {
-					continue // Skip self
-				}
-				if result.Score >= e.config.SimilarityThreshold {
-					conf := e.scoreToConfidence(result.Score)
-					suggestions = append(suggestions, EdgeSuggestion{
-						SourceID:   nodeID,
-						TargetID:   result.ID,
-						Type:       "RELATES_TO",
-						Confidence: conf,
-						Reason:     "High embedding similarity",
-						Method:     "similarity",
-					})
-				}
-			}
-		}
-	}
+	// 1. Ensemble of weighted scorers (similarity, co-access, graph topology)
+	suggestions := e.ensembleSuggestions(ctx, nodeID, embedding)
 
 	// 2. Topological suggestions (NEW - feature flagged for AUTOMATIC integration)
 	// Note: Cypher procedures (CALL gds.linkPrediction.*) are always available
 	// This flag only controls automatic use in OnStore()
-	if e.topologyIntegration != nil {
+	// Skipped when the ensemble already scores graph topology.
+	if e.topologyIntegration != nil && !e.usesGraphScorers() {
 		// Check feature flag via config (automatic integration)
 		topoConfig := e.topologyIntegration.config
 		if topoConfig != nil && topoConfig.Enabled {
@@ -578,6 +603,17 @@ func (e *Engine) validateSuggestionsWithHeimdall(ctx context.Context, sourceNode
 		augmented[i].SourceID = sourceNodeID
 	}
 
+	// Suggestions Heimdall did not approve count against their methods
+	kept := make(map[string]bool, len(approved))
+	for _, sug := range approved {
+		kept[sug.TargetID] = true
+	}
+	for _, sug := range suggestions {
+		if !kept[sug.TargetID] {
+			e.resolveAttribution(sourceNodeID, sug.TargetID, false)
+		}
+	}
+
 	// Combine approved + augmented
 	result := append(approved, augmented...)
 	return result
@@ -639,55 +675,51 @@ func (e *Engine) OnAccess(ctx context.Context, nodeID string) []EdgeSuggestion {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	now := time.Now()
 	suggestions := make([]EdgeSuggestion, 0)
 
 	if !e.config.CoAccessEnabled {
 		return suggestions
 	}
 
-	// Find recent accesses within the window
-	windowStart := now.Add(-e.config.CoAccessWindow)
-	recentNodes := make([]string, 0)
-
-	for _, record := range e.accessHistory {
-		if record.Timestamp.After(windowStart) && record.NodeID != nodeID {
-			recentNodes = append(recentNodes, record.NodeID)
+	// Count co-access with every node read within the window
+	for _, pair := range e.coAccess.RecordAccess(nodeID) {
+		// Reading both ends together is use of an edge QC reviewed in shadow mode
+		if e.heimdallQC != nil {
+			e.heimdallQC.RecordOutcome(nodeID, pair.NodeB, true)
 		}
-	}
-
-	// Update co-access counts
-	for _, otherID := range recentNodes {
-		key := e.makeCoAccessKey(nodeID, otherID)
-		e.coAccessCounts[key]++
-
 		// Check if we should suggest an edge
-		if e.coAccessCounts[key] >= e.config.CoAccessMinCount {
-			conf := float64(e.coAccessCounts[key]) / 10.0
-			if conf > 0.8 {
-				conf = 0.8 // Cap at 0.8 for co-access
-			}
+		if pair.Count >= float64(e.config.CoAccessMinCount) {
 			suggestions = append(suggestions, EdgeSuggestion{
 				SourceID:   nodeID,
-				TargetID:   otherID,
+				TargetID:   pair.NodeB,
 				Type:       "RELATES_TO",
-				Confidence: conf,
+				Confidence: coAccessConfidence(pair.Count),
 				Reason:     "Frequently accessed together",
-				Method:     "co_access",
+				Method:     ScorerCoAccess,
 			})
+			e.attribute(nodeID, pair.NodeB, []string{ScorerCoAccess})
 		}
 	}
 
-	// Add to history
-	e.accessHistory = append(e.accessHistory, accessRecord{
-		NodeID:    nodeID,
-		Timestamp: now,
-	})
+	return suggestions
+}
 
-	// Prune old history
-	e.pruneHistory(windowStart)
+// OnQuery is called with the IDs of the nodes returned by one query.
+//
+// Nodes returned together count as co-accessed, and are added to the
+// recent accesses that later OnAccess calls pair with. Unlike OnAccess it
+// returns no suggestions; the counts feed the co_access scorer.
+func (e *Engine) OnQuery(ctx context.Context, nodeIDs []string) {
+	if !e.config.CoAccessEnabled || len(nodeIDs) == 0 {
+		return
+	}
+	e.coAccess.RecordQuery(nodeIDs)
+}
 
-	return suggestions
+// CoAccess returns the engine's co-access tracker, e.g. to save or load
+// pair counts.
+func (e *Engine) CoAccess() *CoAccessTracker {
+	return e.coAccess
 }
 
 // SuggestTransitive suggests edges based on transitive relationships.
@@ -755,25 +787,9 @@ func (e *Engine) scoreToConfidence(score float64) float64 {
 	}
 }
 
-// makeCoAccessKey creates a consistent key for co-access tracking.
+// makeCoAccessKey creates a consistent key for a node pair.
 func (e *Engine) makeCoAccessKey(a, b string) coAccessKey {
-	// Ensure consistent ordering
-	if a < b {
-		return coAccessKey{NodeA: a, NodeB: b}
-	}
-	return coAccessKey{NodeA: b, NodeB: a}
-}
-
-// pruneHistory removes old access records.
-func (e *Engine) pruneHistory(before time.Time) {
-	// Keep records newer than 'before'
-	newHistory := make([]accessRecord, 0, len(e.accessHistory))
-	for _, record := range e.accessHistory {
-		if record.Timestamp.After(before) {
-			newHistory = append(newHistory, record)
-		}
-	}
-	e.accessHistory = newHistory
+	return pairKey(a, b)
 }
 
 // Stats returns inference statistics.
@@ -783,6 +799,7 @@ type Stats struct {
 	ByCoAccess        int64
 	ByTransitive      int64
 	TrackedCoAccesses int
+	Scorers           map[string]ScorerMetrics // Ensemble weight and precision per method
 }
 
 // GetStats returns current inference statistics.
@@ -791,7 +808,8 @@ func (e *Engine) GetStats() Stats {
 	defer e.mu.RUnlock()
 
 	return Stats{
-		TrackedCoAccesses: len(e.coAccessCounts),
+		TrackedCoAccesses: e.coAccess.Len(),
+		Scorers:           e.scorerStats(),
 	}
 }
 
@@ -940,6 +958,14 @@ func (e *Engine) ProcessSuggestion(suggestion EdgeSuggestion, sessionID string)
 func (e *Engine) RecordMaterialization(sourceID, targetID, edgeType string) {
 	ctx := context.Background()
 
+	// Credit the methods that suggested this edge
+	e.mu.Lock()
+	e.resolveAttribution(sourceID, targetID, true)
+	if e.heimdallQC != nil {
+		e.heimdallQC.RecordOutcome(sourceID, targetID, true)
+	}
+	e.mu.Unlock()
+
 	// Update cooldown tracking
 	if config.IsCooldownAutoIntegrationEnabled() {
 		e.cooldownTable.RecordMaterialization(sourceID, targetID, edgeType)
@@ -45,8 +45,8 @@ func TestNew(t *testing.T) {
 	
 	t.Run("initializes tracking maps", func(t *testing.T) {
 		engine := New(nil)
-		assert.NotNil(t, engine.accessHistory)
-		assert.NotNil(t, engine.coAccessCounts)
+		assert.NotNil(t, engine.coAccess)
+		assert.Empty(t, engine.coAccess.Recent())
 	})
 }
 
@@ -148,7 +148,7 @@ func TestEngine_OnAccess(t *testing.T) {
 		engine.OnAccess(context.Background(), "node-1")
 		engine.OnAccess(context.Background(), "node-2")
 		
-		assert.Len(t, engine.accessHistory, 2)
+		assert.Len(t, engine.coAccess.Recent(), 2)
 	})
 	
 	t.Run("suggests edges after threshold accesses", func(t *testing.T) {
@@ -214,8 +214,9 @@ func TestEngine_OnAccess(t *testing.T) {
 		engine.OnAccess(context.Background(), "new-node")
 		
 		// Old access should be pruned
-		assert.Len(t, engine.accessHistory, 1)
-		assert.Equal(t, "new-node", engine.accessHistory[0].NodeID)
+		recent := engine.coAccess.Recent()
+		assert.Len(t, recent, 1)
+		assert.Equal(t, "new-node", recent[0])
 	})
 }
 
@@ -0,0 +1,84 @@
+package inference
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// Heimdall QC outcomes recorded in Provenance.QCDecision.
+const (
+	QCApproved  = "approved"  // Reviewed and approved
+	QCAugmented = "augmented" // Proposed by Heimdall itself
+	QCSkipped   = "skipped"   // Not reviewed (prompt too large)
+	QCFailOpen  = "fail_open" // Review failed; kept without a decision
+
+	// Shadow mode: reviewed and kept whatever the decision
+	QCShadowApproved = "shadow_approved"
+	QCShadowRejected = "shadow_rejected"
+)
+
+// Provenance records how a suggestion was produced: the score each method
+// gave it, the ensemble weights, and the Heimdall QC decision. Edges created
+// from a suggestion carry it as properties (see Properties).
+type Provenance struct {
+	Scores      map[string]float64 // Method -> score in [0, 1]
+	Weights     map[string]float64 // Method -> ensemble weight
+	QCDecision  string             // Empty if QC did not run
+	QCReasoning string
+	PromptHash  string // SHA-256 of the QC prompt, hex encoded
+}
+
+// Properties returns the provenance as edge properties, using the
+// storage.EdgeProp* keys. Empty fields are omitted.
+func (p *Provenance) Properties() map[string]interface{} {
+	props := make(map[string]interface{})
+	if p == nil {
+		return props
+	}
+	if len(p.Scores) > 0 {
+		props[storage.EdgePropScores] = floatMap(p.Scores)
+	}
+	if len(p.Weights) > 0 {
+		props[storage.EdgePropWeights] = floatMap(p.Weights)
+	}
+	for key, value := range map[string]string{
+		storage.EdgePropQCDecision:  p.QCDecision,
+		storage.EdgePropQCReasoning: p.QCReasoning,
+		storage.EdgePropPromptHash:  p.PromptHash,
+	} {
+		if value != "" {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+// withQC returns sug with a copy of its provenance carrying the QC outcome.
+func withQC(sug EdgeSuggestion, decision, reasoning, promptHash string) EdgeSuggestion {
+	p := Provenance{}
+	if sug.Provenance != nil {
+		p = *sug.Provenance
+	}
+	p.QCDecision = decision
+	p.QCReasoning = reasoning
+	p.PromptHash = promptHash
+	sug.Provenance = &p
+	return sug
+}
+
+// promptHash returns the hex SHA-256 of a prompt.
+func promptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// floatMap converts to the map type stored in edge properties.
+func floatMap(m map[string]float64) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package inference
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/config"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenance_Properties(t *testing.T) {
+	var nilProv *Provenance
+	assert.Empty(t, nilProv.Properties())
+
+	p := &Provenance{
+		Scores:     map[string]float64{ScorerSimilarity: 0.9},
+		Weights:    map[string]float64{ScorerSimilarity: 0.5},
+		QCDecision: QCApproved,
+	}
+	props := p.Properties()
+	assert.Equal(t, map[string]interface{}{ScorerSimilarity: 0.9}, props[storage.EdgePropScores])
+	assert.Equal(t, map[string]interface{}{ScorerSimilarity: 0.5}, props[storage.EdgePropWeights])
+	assert.Equal(t, QCApproved, props[storage.EdgePropQCDecision])
+	assert.NotContains(t, props, storage.EdgePropQCReasoning, "empty fields are omitted")
+	assert.NotContains(t, props, storage.EdgePropPromptHash)
+
+	sug := EdgeSuggestion{Provenance: p}
+	reviewed := withQC(sug, QCFailOpen, "timeout", "hash")
+	assert.Equal(t, QCFailOpen, reviewed.Provenance.QCDecision)
+	assert.Equal(t, 0.9, reviewed.Provenance.Scores[ScorerSimilarity])
+	assert.Equal(t, QCApproved, p.QCDecision, "the original provenance is not modified")
+}
+
+func TestProvenance_Ensemble(t *testing.T) {
+	config := DefaultConfig()
+	config.ScorerWeights = map[string]float64{ScorerSimilarity: 0.6}
+	engine := New(config)
+	engine.SetSimilaritySearch(func(ctx context.Context, embedding []float32, k int) ([]SimilarityResult, error) {
+		return []SimilarityResult{{ID: "a", Score: 0.96}}, nil
+	})
+	engine.RegisterScorer(staticScorer("custom", Candidate{TargetID: "a", Score: 0.5}), 0.4)
+
+	suggestions, err := engine.OnStore(context.Background(), "src", []float32{1})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	prov := suggestions[0].Provenance
+	require.NotNil(t, prov)
+	assert.InDelta(t, 0.9, prov.Scores[ScorerSimilarity], 0.001)
+	assert.Equal(t, 0.5, prov.Scores["custom"])
+	assert.Equal(t, map[string]float64{ScorerSimilarity: 0.6, "custom": 0.4}, prov.Weights)
+	assert.Empty(t, prov.QCDecision, "QC did not run")
+}
+
+func TestProvenance_HeimdallQC(t *testing.T) {
+	cleanupQC := config.WithAutoTLPLLMQCEnabled()
+	defer cleanupQC()
+	cleanupAug := config.WithAutoTLPLLMAugmentEnabled()
+	defer cleanupAug()
+
+	var prompts []string
+	qc := NewHeimdallQC(func(ctx context.Context, prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return mockWithAugmentationResponse, nil
+	}, nil)
+
+	approved, augmented, err := qc.ReviewBatch(context.Background(), NodeSummary{ID: "source"}, createTestSuggestions(2),
+		[]NodeSummary{{ID: "aug-node-1", Labels: []string{"Concept"}}})
+	require.NoError(t, err)
+	require.Len(t, approved, 1)
+	require.Len(t, augmented, 1)
+	require.Len(t, prompts, 1)
+
+	hash := promptHash(prompts[0])
+	assert.Len(t, hash, 64)
+	for _, sug := range []EdgeSuggestion{approved[0], augmented[0]} {
+		require.NotNil(t, sug.Provenance)
+		assert.Equal(t, "Added extra connection", sug.Provenance.QCReasoning)
+		assert.Equal(t, hash, sug.Provenance.PromptHash)
+	}
+	assert.Equal(t, QCApproved, approved[0].Provenance.QCDecision)
+	assert.Equal(t, QCAugmented, augmented[0].Provenance.QCDecision)
+	assert.Equal(t, 0.75, augmented[0].Provenance.Scores["heimdall_augment"])
+
+	failing := NewHeimdallQC(func(ctx context.Context, prompt string) (string, error) {
+		return "", errors.New("unavailable")
+	}, nil)
+	approved, _, err = failing.ReviewBatch(context.Background(), NodeSummary{ID: "source"}, createTestSuggestions(2), nil)
+	require.NoError(t, err)
+	require.Len(t, approved, 2)
+	for _, sug := range approved {
+		assert.Equal(t, QCFailOpen, sug.Provenance.QCDecision)
+		assert.Contains(t, sug.Provenance.QCReasoning, "unavailable")
+	}
+}
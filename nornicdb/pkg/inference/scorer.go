@@ -0,0 +1,462 @@
+// Package inference - pluggable suggestion scorers for the inference engine.
+//
+// Each Scorer is one link prediction method (embedding similarity, co-access,
+// common neighbors, Adamic-Adar, ...). OnStore runs every scorer with a
+// positive weight and blends their candidates into one weighted ensemble:
+//
+//	confidence(target) = Σ weight_i × score_i(target) / Σ weight_i
+//
+// The denominator only includes scorers that produced candidates for the
+// query, so a method with no signal (e.g. no similarity search configured)
+// does not drag the other methods down.
+//
+// Per-method precision is tracked from the outcome of each suggestion:
+// RecordMaterialization counts an accept for every method that contributed,
+// RecordRejection (and Heimdall QC rejections) count a reject.
+//
+// The embedding scorer runs after all other scorers and rescores the pool
+// of candidates they proposed by cosine similarity to the source embedding.
+// Pools are scored in one batch with gpu.ScorePairs, on the GPU when the
+// engine has an enabled GPU manager.
+//
+// Example:
+//
+//	config := inference.DefaultConfig()
+//	config.ScorerWeights = map[string]float64{
+//		inference.ScorerSimilarity:  0.6,
+//		inference.ScorerAdamicAdar:  0.3,
+//		inference.ScorerCoAccess:    0.1,
+//	}
+//	engine := inference.New(config)
+//	engine.RegisterScorer(myScorer, 0.2) // Custom method
+//
+//	for name, m := range engine.GetStats().Scorers {
+//		fmt.Printf("%s: %.0f%% precision\n", name, m.Precision()*100)
+//	}
+package inference
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/gpu"
+	"github.com/orneryd/nornicdb/pkg/linkpredict"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// Built-in scorer names.
+const (
+	ScorerSimilarity      = "similarity"
+	ScorerCoAccess        = "co_access"
+	ScorerCommonNeighbors = "common_neighbors"
+	ScorerAdamicAdar      = "adamic_adar"
+	ScorerEmbedding       = "embedding"
+)
+
+// maxPendingAttributions bounds how many unresolved suggestions are
+// remembered for precision tracking.
+const maxPendingAttributions = 10000
+
+// ScoreQuery is the input to a Scorer.
+type ScoreQuery struct {
+	SourceID   string
+	Embedding  []float32 // May be empty
+	TopK       int       // Maximum candidates to return
+	Candidates []string  // Targets proposed by the scorers that ran before
+}
+
+// Candidate is a target node scored by a Scorer.
+type Candidate struct {
+	TargetID string
+	Score    float64 // Normalized to [0, 1]
+	Reason   string
+}
+
+// Scorer is a link prediction method used by the engine's ensemble.
+//
+// Score is called with the engine lock held, so implementations must not
+// call back into the Engine. Returning no candidates means the method has
+// no opinion for this query.
+type Scorer interface {
+	Name() string
+	Score(ctx context.Context, q ScoreQuery) ([]Candidate, error)
+}
+
+// ScorerMetrics contains per-method suggestion outcomes.
+type ScorerMetrics struct {
+	Weight    float64
+	Suggested int64 // Suggestions this method contributed to
+	Accepted  int64 // Contributed suggestions that were materialized
+	Rejected  int64 // Contributed suggestions that were rejected
+}
+
+// Precision returns Accepted / (Accepted + Rejected), or 0 with no outcomes.
+func (m ScorerMetrics) Precision() float64 {
+	if m.Accepted+m.Rejected == 0 {
+		return 0
+	}
+	return float64(m.Accepted) / float64(m.Accepted+m.Rejected)
+}
+
+// DefaultScorerWeights returns the built-in ensemble weights.
+// Graph scorers only produce candidates when a TopologyIntegration is set
+// and enabled; the embedding scorer only when an embedding lookup is set.
+func DefaultScorerWeights() map[string]float64 {
+	return map[string]float64{
+		ScorerSimilarity:      1.0,
+		ScorerCoAccess:        0.5,
+		ScorerCommonNeighbors: 0.2,
+		ScorerAdamicAdar:      0.3,
+		ScorerEmbedding:       0.5,
+	}
+}
+
+type weightedScorer struct {
+	scorer  Scorer
+	weight  float64
+	rescore bool // Runs after the other scorers, over their candidate pool
+}
+
+// scorerFunc adapts a function to the Scorer interface.
+type scorerFunc struct {
+	name string
+	fn   func(ctx context.Context, q ScoreQuery) ([]Candidate, error)
+}
+
+func (s scorerFunc) Name() string { return s.name }
+
+func (s scorerFunc) Score(ctx context.Context, q ScoreQuery) ([]Candidate, error) {
+	return s.fn(ctx, q)
+}
+
+// registerBuiltinScorers registers the built-in methods with configured weights.
+func (e *Engine) registerBuiltinScorers() {
+	weights := e.config.ScorerWeights
+	if weights == nil {
+		weights = DefaultScorerWeights()
+	}
+	for _, s := range []Scorer{
+		scorerFunc{ScorerSimilarity, e.scoreSimilarity},
+		scorerFunc{ScorerCoAccess, e.scoreCoAccess},
+		scorerFunc{ScorerCommonNeighbors, e.graphScorer(linkpredict.CommonNeighbors)},
+		scorerFunc{ScorerAdamicAdar, e.graphScorer(linkpredict.AdamicAdar)},
+		scorerFunc{ScorerEmbedding, e.scoreEmbeddingPool},
+	} {
+		e.scorers = append(e.scorers, weightedScorer{
+			scorer:  s,
+			weight:  weights[s.Name()],
+			rescore: s.Name() == ScorerEmbedding,
+		})
+	}
+}
+
+// RegisterScorer adds a scorer to the ensemble, replacing any scorer with the
+// same name. A weight of zero or less disables it.
+func (e *Engine) RegisterScorer(s Scorer, weight float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, ws := range e.scorers {
+		if ws.scorer.Name() == s.Name() {
+			e.scorers[i] = weightedScorer{scorer: s, weight: weight}
+			return
+		}
+	}
+	e.scorers = append(e.scorers, weightedScorer{scorer: s, weight: weight})
+}
+
+// SetScorerWeight changes the ensemble weight of a registered scorer.
+// It returns false if no scorer has that name.
+func (e *Engine) SetScorerWeight(name string, weight float64) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, ws := range e.scorers {
+		if ws.scorer.Name() == name {
+			e.scorers[i].weight = weight
+			return true
+		}
+	}
+	return false
+}
+
+// ensembleSuggestions runs all weighted scorers and blends their candidates.
+// Caller must hold e.mu.
+func (e *Engine) ensembleSuggestions(ctx context.Context, nodeID string, embedding []float32) []EdgeSuggestion {
+	type contribution struct {
+		method string
+		score  float64
+		weight float64
+		value  float64
+		reason string
+	}
+	q := ScoreQuery{SourceID: nodeID, Embedding: embedding, TopK: e.config.SimilarityTopK}
+	byTarget := make(map[string][]contribution)
+	totalWeight := 0.0
+	for _, ws := range e.orderedScorers() {
+		if ws.weight <= 0 {
+			continue
+		}
+		candidates, err := ws.scorer.Score(ctx, q)
+		if err != nil || len(candidates) == 0 {
+			continue
+		}
+		totalWeight += ws.weight
+		for _, c := range candidates {
+			if c.TargetID == nodeID {
+				continue
+			}
+			if _, seen := byTarget[c.TargetID]; !seen {
+				q.Candidates = append(q.Candidates, c.TargetID)
+			}
+			byTarget[c.TargetID] = append(byTarget[c.TargetID], contribution{
+				method: ws.scorer.Name(),
+				score:  c.Score,
+				weight: ws.weight,
+				value:  ws.weight * c.Score,
+				reason: c.Reason,
+			})
+		}
+	}
+
+	suggestions := make([]EdgeSuggestion, 0, len(byTarget))
+	for target, contribs := range byTarget {
+		sort.SliceStable(contribs, func(i, j int) bool { return contribs[i].value > contribs[j].value })
+		sum := 0.0
+		methods := make([]string, len(contribs))
+		reasons := make([]string, len(contribs))
+		prov := &Provenance{Scores: make(map[string]float64), Weights: make(map[string]float64)}
+		for i, c := range contribs {
+			sum += c.value
+			methods[i] = c.method
+			reasons[i] = c.reason
+			prov.Scores[c.method] = c.score
+			prov.Weights[c.method] = c.weight
+		}
+		sug := EdgeSuggestion{
+			SourceID:   nodeID,
+			TargetID:   target,
+			Type:       "RELATES_TO",
+			Confidence: sum / totalWeight,
+			Reason:     strings.Join(reasons, " + "),
+			Method:     strings.Join(methods, ","),
+			Provenance: prov,
+		}
+		e.attribute(sug.SourceID, sug.TargetID, methods)
+		suggestions = append(suggestions, sug)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].TargetID < suggestions[j].TargetID
+	})
+	return suggestions
+}
+
+// orderedScorers returns the scorers in registration order, with pool
+// rescorers last. Caller must hold e.mu.
+func (e *Engine) orderedScorers() []weightedScorer {
+	ordered := make([]weightedScorer, 0, len(e.scorers))
+	for _, rescore := range []bool{false, true} {
+		for _, ws := range e.scorers {
+			if ws.rescore == rescore {
+				ordered = append(ordered, ws)
+			}
+		}
+	}
+	return ordered
+}
+
+// usesGraphScorers reports whether a graph scorer is weighted and has a
+// graph to read. Caller must hold e.mu.
+func (e *Engine) usesGraphScorers() bool {
+	if e.topologyIntegration == nil || !e.topologyIntegration.config.Enabled {
+		return false
+	}
+	for _, ws := range e.scorers {
+		name := ws.scorer.Name()
+		if ws.weight > 0 && (name == ScorerCommonNeighbors || name == ScorerAdamicAdar) {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreSimilarity scores nodes with similar embeddings.
+func (e *Engine) scoreSimilarity(ctx context.Context, q ScoreQuery) ([]Candidate, error) {
+	if e.similaritySearch == nil || len(q.Embedding) == 0 {
+		return nil, nil
+	}
+	similar, err := e.similaritySearch(ctx, q.Embedding, q.TopK)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]Candidate, 0, len(similar))
+	for _, result := range similar {
+		if result.ID == q.SourceID || result.Score < e.config.SimilarityThreshold {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			TargetID: result.ID,
+			Score:    e.scoreToConfidence(result.Score),
+			Reason:   "High embedding similarity",
+		})
+	}
+	return candidates, nil
+}
+
+// scoreCoAccess scores nodes frequently accessed together with the source.
+func (e *Engine) scoreCoAccess(ctx context.Context, q ScoreQuery) ([]Candidate, error) {
+	if !e.config.CoAccessEnabled {
+		return nil, nil
+	}
+	var candidates []Candidate
+	for _, pair := range e.coAccess.Neighbors(q.SourceID, float64(e.config.CoAccessMinCount)) {
+		candidates = append(candidates, Candidate{
+			TargetID: pair.NodeB,
+			Score:    coAccessConfidence(pair.Count),
+			Reason:   "Frequently accessed together",
+		})
+	}
+	return candidates, nil
+}
+
+// coAccessConfidence maps a co-access count to a confidence capped at 0.8.
+func coAccessConfidence(count float64) float64 {
+	conf := count / 10.0
+	if conf > 0.8 {
+		conf = 0.8 // Cap at 0.8 for co-access
+	}
+	return conf
+}
+
+// scoreEmbeddingPool rescores the candidate pool by cosine similarity between
+// the source embedding and each candidate's embedding.
+func (e *Engine) scoreEmbeddingPool(ctx context.Context, q ScoreQuery) ([]Candidate, error) {
+	if e.embeddingLookup == nil || len(q.Embedding) == 0 || len(q.Candidates) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(q.Candidates))
+	pairs := make([]gpu.Pair, 0, len(q.Candidates))
+	for _, id := range q.Candidates {
+		emb, ok := e.embeddingLookup(id)
+		if !ok || len(emb) != len(q.Embedding) {
+			continue
+		}
+		ids = append(ids, id)
+		pairs = append(pairs, gpu.Pair{Query: q.Embedding, Candidate: emb})
+	}
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	scores, err := gpu.ScorePairs(e.gpuManager, pairs)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]Candidate, 0, len(ids))
+	for i, id := range ids {
+		score := float64(scores[i])
+		if score <= 0 {
+			continue
+		}
+		if score > 1 {
+			score = 1
+		}
+		candidates = append(candidates, Candidate{
+			TargetID: id,
+			Score:    score,
+			Reason:   "Similar embedding",
+		})
+	}
+	return candidates, nil
+}
+
+// graphScorer adapts a linkpredict algorithm to a scorer over the topology
+// integration's cached graph.
+func (e *Engine) graphScorer(algo func(linkpredict.Graph, storage.NodeID, int) []linkpredict.Prediction) func(context.Context, ScoreQuery) ([]Candidate, error) {
+	return func(ctx context.Context, q ScoreQuery) ([]Candidate, error) {
+		t := e.topologyIntegration
+		if t == nil || !t.config.Enabled {
+			return nil, nil
+		}
+		var candidates []Candidate
+		err := t.withGraph(ctx, func(graph linkpredict.Graph) {
+			for _, pred := range algo(graph, storage.NodeID(q.SourceID), q.TopK) {
+				candidates = append(candidates, Candidate{
+					TargetID: string(pred.TargetID),
+					Score:    pred.Score,
+					Reason:   pred.Reason,
+				})
+			}
+		})
+		return candidates, err
+	}
+}
+
+// attribute remembers which methods produced a suggestion so its outcome
+// can be credited. Caller must hold e.mu.
+func (e *Engine) attribute(sourceID, targetID string, methods []string) {
+	for _, m := range methods {
+		e.metricsFor(m).Suggested++
+	}
+	if len(e.attributions) >= maxPendingAttributions {
+		for k := range e.attributions {
+			delete(e.attributions, k)
+			break
+		}
+	}
+	e.attributions[e.makeCoAccessKey(sourceID, targetID)] = methods
+}
+
+// resolveAttribution credits the outcome of a suggestion to its methods.
+// Caller must hold e.mu.
+func (e *Engine) resolveAttribution(sourceID, targetID string, accepted bool) {
+	key := e.makeCoAccessKey(sourceID, targetID)
+	methods, ok := e.attributions[key]
+	if !ok {
+		return
+	}
+	delete(e.attributions, key)
+	for _, m := range methods {
+		if accepted {
+			e.metricsFor(m).Accepted++
+		} else {
+			e.metricsFor(m).Rejected++
+		}
+	}
+}
+
+func (e *Engine) metricsFor(method string) *ScorerMetrics {
+	m, ok := e.scorerMetrics[method]
+	if !ok {
+		m = &ScorerMetrics{}
+		e.scorerMetrics[method] = m
+	}
+	return m
+}
+
+// RecordRejection records that a suggested edge was rejected (by a user,
+// a reviewer or a policy), lowering the precision of the methods that
+// suggested it and of Heimdall QC in shadow mode.
+func (e *Engine) RecordRejection(sourceID, targetID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resolveAttribution(sourceID, targetID, false)
+	if e.heimdallQC != nil {
+		e.heimdallQC.RecordOutcome(sourceID, targetID, false)
+	}
+}
+
+// scorerStats returns per-method metrics. Caller must hold e.mu.
+func (e *Engine) scorerStats() map[string]ScorerMetrics {
+	stats := make(map[string]ScorerMetrics, len(e.scorers))
+	for _, ws := range e.scorers {
+		m := ScorerMetrics{}
+		if c := e.scorerMetrics[ws.scorer.Name()]; c != nil {
+			m = *c
+		}
+		m.Weight = ws.weight
+		stats[ws.scorer.Name()] = m
+	}
+	return stats
+}
@@ -0,0 +1,243 @@
+package inference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/gpu"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func staticScorer(name string, candidates ...Candidate) Scorer {
+	return scorerFunc{name: name, fn: func(ctx context.Context, q ScoreQuery) ([]Candidate, error) {
+		return candidates, nil
+	}}
+}
+
+func TestEnsemble_WeightedBlend(t *testing.T) {
+	config := DefaultConfig()
+	config.ScorerWeights = map[string]float64{ScorerSimilarity: 0.6}
+	engine := New(config)
+	engine.SetSimilaritySearch(func(ctx context.Context, embedding []float32, k int) ([]SimilarityResult, error) {
+		return []SimilarityResult{{ID: "a", Score: 0.96}, {ID: "b", Score: 0.96}}, nil
+	})
+	engine.RegisterScorer(staticScorer("custom", Candidate{TargetID: "a", Score: 0.5, Reason: "custom"}), 0.4)
+	engine.RegisterScorer(staticScorer("silent"), 5.0)
+
+	suggestions, err := engine.OnStore(context.Background(), "src", []float32{1})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 2)
+
+	// a: (0.6*0.9 + 0.4*0.5) / 1.0; silent scorer does not count
+	assert.Equal(t, "a", suggestions[0].TargetID)
+	assert.InDelta(t, 0.74, suggestions[0].Confidence, 0.001)
+	assert.Equal(t, "similarity,custom", suggestions[0].Method)
+	assert.Equal(t, "High embedding similarity + custom", suggestions[0].Reason)
+
+	assert.Equal(t, "b", suggestions[1].TargetID)
+	assert.InDelta(t, 0.54, suggestions[1].Confidence, 0.001)
+	assert.Equal(t, ScorerSimilarity, suggestions[1].Method)
+}
+
+func TestEnsemble_ScorerRegistration(t *testing.T) {
+	engine := New(nil)
+	engine.RegisterScorer(staticScorer("custom", Candidate{TargetID: "a", Score: 0.5}), 1)
+
+	suggestions, _ := engine.OnStore(context.Background(), "src", nil)
+	require.Len(t, suggestions, 1)
+
+	assert.True(t, engine.SetScorerWeight("custom", 0))
+	assert.False(t, engine.SetScorerWeight("missing", 1))
+	suggestions, _ = engine.OnStore(context.Background(), "src", nil)
+	assert.Empty(t, suggestions, "zero weight disables a scorer")
+
+	engine.RegisterScorer(staticScorer("custom", Candidate{TargetID: "b", Score: 0.5}), 1)
+	suggestions, _ = engine.OnStore(context.Background(), "src", nil)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "b", suggestions[0].TargetID, "same name replaces the scorer")
+
+	failing := scorerFunc{name: "failing", fn: func(ctx context.Context, q ScoreQuery) ([]Candidate, error) {
+		return []Candidate{{TargetID: "c", Score: 1}}, errors.New("boom")
+	}}
+	engine.RegisterScorer(failing, 1)
+	suggestions, _ = engine.OnStore(context.Background(), "src", nil)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "b", suggestions[0].TargetID, "failing scorers are ignored")
+
+	stats := engine.GetStats().Scorers
+	for _, name := range []string{ScorerSimilarity, ScorerCoAccess, ScorerCommonNeighbors, ScorerAdamicAdar, "custom", "failing"} {
+		assert.Contains(t, stats, name)
+	}
+	assert.Equal(t, DefaultScorerWeights()[ScorerAdamicAdar], stats[ScorerAdamicAdar].Weight)
+}
+
+func TestEnsemble_CoAccessScorer(t *testing.T) {
+	config := DefaultConfig()
+	config.CoAccessMinCount = 2
+	engine := New(config)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		engine.OnAccess(ctx, "x")
+		engine.OnAccess(ctx, "y")
+	}
+
+	suggestions, err := engine.OnStore(ctx, "x", nil)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "y", suggestions[0].TargetID)
+	assert.Equal(t, ScorerCoAccess, suggestions[0].Method)
+	assert.LessOrEqual(t, suggestions[0].Confidence, 0.8)
+}
+
+func TestEnsemble_GraphScorers(t *testing.T) {
+	store := storage.NewMemoryEngine()
+	setupTestGraph(t, store)
+
+	config := DefaultConfig()
+	config.ScorerWeights = map[string]float64{ScorerCommonNeighbors: 1, ScorerAdamicAdar: 1}
+	engine := New(config)
+
+	suggestions, err := engine.OnStore(context.Background(), "alice", nil)
+	require.NoError(t, err)
+	assert.Empty(t, suggestions, "graph scorers need a topology integration")
+
+	topoConfig := DefaultTopologyConfig()
+	topoConfig.Enabled = true
+	topoConfig.Algorithm = "jaccard"
+	engine.SetTopologyIntegration(NewTopologyIntegration(store, topoConfig))
+
+	suggestions, err = engine.OnStore(context.Background(), "alice", nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, suggestions)
+	for _, sug := range suggestions {
+		assert.NotContains(t, sug.Method, "topology_jaccard", "legacy blend is skipped when graph scorers run")
+		assert.Contains(t, sug.Method, ScorerCommonNeighbors)
+		assert.Contains(t, sug.Method, ScorerAdamicAdar)
+		assert.Greater(t, sug.Confidence, 0.0)
+		assert.LessOrEqual(t, sug.Confidence, 1.0)
+	}
+}
+
+func TestEnsemble_PrecisionMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.ScorerWeights = map[string]float64{ScorerSimilarity: 1}
+	engine := New(config)
+	engine.SetSimilaritySearch(func(ctx context.Context, embedding []float32, k int) ([]SimilarityResult, error) {
+		return []SimilarityResult{{ID: "a", Score: 0.95}, {ID: "b", Score: 0.9}, {ID: "c", Score: 0.9}}, nil
+	})
+	engine.RegisterScorer(staticScorer("custom", Candidate{TargetID: "a", Score: 1}), 1)
+
+	_, err := engine.OnStore(context.Background(), "src", []float32{1})
+	require.NoError(t, err)
+
+	engine.RecordMaterialization("src", "a", "RELATES_TO")
+	engine.RecordRejection("b", "src") // Order of IDs does not matter
+	engine.RecordRejection("src", "unknown")
+
+	stats := engine.GetStats().Scorers
+	sim := stats[ScorerSimilarity]
+	assert.Equal(t, int64(3), sim.Suggested)
+	assert.Equal(t, int64(1), sim.Accepted)
+	assert.Equal(t, int64(1), sim.Rejected)
+	assert.InDelta(t, 0.5, sim.Precision(), 0.001)
+
+	custom := stats["custom"]
+	assert.Equal(t, int64(1), custom.Suggested)
+	assert.InDelta(t, 1.0, custom.Precision(), 0.001)
+
+	assert.Zero(t, stats[ScorerAdamicAdar].Precision(), "no outcomes yet")
+
+	// Outcomes are only credited once
+	engine.RecordMaterialization("src", "a", "RELATES_TO")
+	assert.Equal(t, int64(1), engine.GetStats().Scorers[ScorerSimilarity].Accepted)
+}
+
+func TestEnsemble_EmbeddingScorer(t *testing.T) {
+	embeddings := map[string][]float32{
+		"a": {1, 0},
+		"b": {0, 1},
+		"c": {-1, 0},
+		"d": {1},
+	}
+	config := DefaultConfig()
+	config.ScorerWeights = map[string]float64{ScorerEmbedding: 1}
+	engine := New(config)
+	engine.RegisterScorer(staticScorer("custom",
+		Candidate{TargetID: "a", Score: 0.5},
+		Candidate{TargetID: "b", Score: 0.5},
+		Candidate{TargetID: "c", Score: 0.5},
+		Candidate{TargetID: "d", Score: 0.5},
+		Candidate{TargetID: "missing", Score: 0.5},
+	), 1)
+
+	suggestions, err := engine.OnStore(context.Background(), "src", []float32{1, 0})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 5)
+	for _, sug := range suggestions {
+		assert.NotContains(t, sug.Method, ScorerEmbedding, "no lookup, no embedding scorer")
+	}
+
+	engine.SetEmbeddingLookup(func(id string) ([]float32, bool) {
+		emb, ok := embeddings[id]
+		return emb, ok
+	})
+	engine.SetGPUManager(nil) // CPU scoring
+	suggestions, err = engine.OnStore(context.Background(), "src", []float32{1, 0})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 5)
+
+	// a: (1*0.5 + 1*1.0) / 2; embedding scorer runs after custom
+	assert.Equal(t, "a", suggestions[0].TargetID)
+	assert.InDelta(t, 0.75, suggestions[0].Confidence, 0.001)
+	assert.Equal(t, "embedding,custom", suggestions[0].Method)
+	assert.Equal(t, "Similar embedding + ", suggestions[0].Reason)
+	for _, sug := range suggestions[1:] {
+		assert.Equal(t, "custom", sug.Method, "orthogonal, opposite, mismatched or missing embeddings add nothing")
+		assert.InDelta(t, 0.25, sug.Confidence, 0.001)
+	}
+	assert.Equal(t, int64(1), engine.GetStats().Scorers[ScorerEmbedding].Suggested)
+}
+
+func BenchmarkEnsemble_EmbeddingPool(b *testing.B) {
+	const dims = 384
+	for _, size := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("pool_%d", size), func(b *testing.B) {
+			embeddings := make(map[string][]float32, size)
+			pool := make([]Candidate, size)
+			for i := range pool {
+				id := fmt.Sprintf("n%d", i)
+				emb := make([]float32, dims)
+				for j := range emb {
+					emb[j] = float32((i*j)%97) / 97
+				}
+				embeddings[id] = emb
+				pool[i] = Candidate{TargetID: id, Score: 0.5}
+			}
+			source := make([]float32, dims)
+			for j := range source {
+				source[j] = 0.5
+			}
+
+			config := DefaultConfig()
+			config.ScorerWeights = map[string]float64{ScorerEmbedding: 1}
+			engine := New(config)
+			engine.RegisterScorer(staticScorer("pool", pool...), 1)
+			engine.SetEmbeddingLookup(func(id string) ([]float32, bool) {
+				emb, ok := embeddings[id]
+				return emb, ok
+			})
+			if m, err := gpu.NewManager(&gpu.Config{Enabled: true, FallbackOnError: true}); err == nil {
+				engine.SetGPUManager(m)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				engine.OnStore(context.Background(), "src", source)
+			}
+		})
+	}
+}
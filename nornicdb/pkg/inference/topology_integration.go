@@ -288,6 +288,31 @@ func (t *TopologyIntegration) SuggestTopological(ctx context.Context, sourceID s
 	return suggestions, nil
 }
 
+// withGraph runs fn with the cached graph under the read lock, building or
+// refreshing it first. fn is not called when there is no graph.
+func (t *TopologyIntegration) withGraph(ctx context.Context, fn func(graph linkpredict.Graph)) error {
+	if !t.config.Enabled || t.storage == nil {
+		return nil
+	}
+	if t.config.BuildTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.config.BuildTimeout)
+		defer cancel()
+	}
+	if err := t.ensureGraph(ctx); err != nil {
+		return err
+	}
+	atomic.AddInt64(&t.predictionsRun, 1)
+	atomic.AddInt64(&t.predictionCount, 1)
+
+	t.graphMu.RLock()
+	defer t.graphMu.RUnlock()
+	if t.cachedGraph != nil {
+		fn(t.cachedGraph)
+	}
+	return nil
+}
+
 // ensureGraph builds or refreshes the cached graph if needed.
 func (t *TopologyIntegration) ensureGraph(ctx context.Context) error {
 	t.graphMu.RLock()
@@ -0,0 +1,99 @@
+package livequery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// BasePath is where the HTTP server mounts Handler.
+const BasePath = "/api/bifrost/live-queries"
+
+type registerRequest struct {
+	ClientID string         `json:"client_id"`
+	Query    string         `json:"query"`
+	Params   map[string]any `json:"params,omitempty"`
+	Key      []string       `json:"key,omitempty"`
+	Labels   []string       `json:"labels,omitempty"`
+}
+
+// Handler serves a Manager over HTTP. user returns the authenticated user
+// of a request and admin whether it may list every user's live queries:
+//
+//	POST   ...       {"client_id", "query", "params", "key", "labels"} → 201 Snapshot
+//	GET    ...       the caller's live queries (?all=true for every user's, admins only)
+//	GET    .../{id}  current Snapshot
+//	DELETE .../{id}  unregister
+//
+// client_id is the ID of the caller's Bifrost connection, sent in its
+// "connected" event; diffs arrive on it as "live_query" events.
+// Authentication is left to the caller, e.g. the server's auth middleware.
+func Handler(m *Manager, user func(*http.Request) string, admin func(*http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, BasePath), "/")
+		switch {
+		case id == "" && r.Method == http.MethodPost:
+			var req registerRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, fmt.Errorf("%w: %v", ErrInvalid, err))
+				return
+			}
+			snap, err := m.Register(r.Context(), Options{
+				ClientID: req.ClientID,
+				User:     user(r),
+				Query:    req.Query,
+				Params:   req.Params,
+				Key:      req.Key,
+				Labels:   req.Labels,
+			})
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, snap)
+		case id == "" && r.Method == http.MethodGet:
+			all := r.URL.Query().Get("all") == "true"
+			if all && !admin(r) {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": "listing every user's live queries requires admin"})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"live_queries": m.List(user(r), all)})
+		case id != "" && r.Method == http.MethodGet:
+			snap, err := m.Snapshot(id, user(r))
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, snap)
+		case id != "" && r.Method == http.MethodDelete:
+			if err := m.Unregister(id, user(r)); err != nil {
+				writeError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("unknown live query endpoint %s %s", r.Method, r.URL.Path)})
+		}
+	})
+}
+
+// writeError reports err. Query errors are the client's, so anything but
+// a missing query or the server's limit is a bad request.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrLimit):
+		status = http.StatusTooManyRequests
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
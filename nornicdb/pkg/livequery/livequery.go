@@ -0,0 +1,572 @@
+// Package livequery keeps registered Cypher queries up to date for their
+// clients (live queries), for dashboards that would otherwise poll.
+//
+// A client registers a read-only query and receives its current result.
+// From then on the Manager sends the client incremental diffs: the rows
+// added to and removed from the result and, for queries registered with
+// key columns, the rows whose other columns changed. A query is evaluated
+// again when the change log shows a write to one of the labels or
+// relationship types it watches (any write if it watches none), and at
+// least once per Interval, which catches changes not yet in the log and
+// changes the labels do not cover, such as a deleted relationship of a
+// watched node.
+//
+// Diffs of a query are numbered; a client that sees a gap has missed a
+// diff and fetches a fresh snapshot. Live queries belong to a client
+// connection and are dropped once it disconnects.
+//
+// Example:
+//
+//	m := livequery.New(run, wal, deliverer, livequery.Config{})
+//	go m.Run(ctx)
+//
+//	snap, err := m.Register(ctx, livequery.Options{
+//		ClientID: clientID,
+//		Query:    "MATCH (o:Order {status: 'open'}) RETURN o.id AS id, o.total AS total",
+//		Key:      []string{"id"},
+//		Labels:   []string{"Order"},
+//	})
+package livequery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/cdc"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+var (
+	// ErrNotFound is returned for a live query that does not exist or
+	// belongs to another user.
+	ErrNotFound = errors.New("live query not found")
+
+	// ErrLimit is returned when the server holds MaxQueries live queries.
+	ErrLimit = errors.New("too many live queries")
+
+	// ErrInvalid is returned for a query that cannot be registered.
+	ErrInvalid = errors.New("invalid live query")
+)
+
+// Runner runs a read-only query and returns its columns and rows.
+type Runner func(ctx context.Context, query string, params map[string]any) (columns []string, rows [][]any, err error)
+
+// Source is the change log watched for writes. *storage.WAL implements it.
+type Source interface {
+	// Sequence returns the sequence of the latest entry.
+	Sequence() uint64
+	// EntriesAfter returns up to limit entries after afterSeq, in order.
+	EntriesAfter(afterSeq uint64, limit int) ([]storage.WALEntry, error)
+}
+
+// Deliverer sends diffs to the client connections live queries belong to.
+type Deliverer interface {
+	// Deliver sends diff to a client.
+	Deliver(clientID string, diff *Diff) error
+	// ClientUser returns the user owning a client connection, or false once
+	// the client disconnected.
+	ClientUser(clientID string) (string, bool)
+}
+
+// Config configures a Manager. Zero fields take the defaults.
+type Config struct {
+	Interval   time.Duration // Evaluate every query at least this often (default 30s)
+	Poll       time.Duration // Check the change log this often (default 250ms)
+	Timeout    time.Duration // Bound on one evaluation (default 10s)
+	MaxQueries int           // Live queries held by the server (default 1000)
+	MaxRows    int           // Rows a live query may return (default 10000)
+}
+
+// DefaultConfig returns the default configuration.
+func DefaultConfig() Config {
+	return Config{
+		Interval:   30 * time.Second,
+		Poll:       250 * time.Millisecond,
+		Timeout:    10 * time.Second,
+		MaxQueries: 1000,
+		MaxRows:    10000,
+	}
+}
+
+// Options describes a live query.
+type Options struct {
+	ClientID string         // Connection receiving the diffs
+	User     string         // Owner; must own the connection
+	Query    string         // Read-only Cypher query
+	Params   map[string]any // Query parameters
+
+	// Key names the columns identifying a row, so that a row whose other
+	// columns change is reported as changed rather than removed and added.
+	// Without a key every distinct row is its own identity.
+	Key []string
+
+	// Labels are the node labels and relationship types whose writes
+	// trigger an evaluation (empty = any write).
+	Labels []string
+}
+
+// Snapshot is the full result of a live query.
+type Snapshot struct {
+	ID      string   `json:"id"`
+	Seq     uint64   `json:"seq"` // Diffs up to Seq are reflected in Rows
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// Change is a row whose key stayed the same while other columns changed.
+type Change struct {
+	Before []any `json:"before"`
+	After  []any `json:"after"`
+}
+
+// Diff is a change of a live query's result. Seq increases by one per
+// diff of a query, starting at 1.
+type Diff struct {
+	ID      string   `json:"id"`
+	Seq     uint64   `json:"seq"`
+	Columns []string `json:"columns"`
+	Added   [][]any  `json:"added,omitempty"`
+	Removed [][]any  `json:"removed,omitempty"`
+	Changed []Change `json:"changed,omitempty"`
+
+	// Error is set when the query failed; the last result stays current
+	// until an evaluation succeeds again.
+	Error string `json:"error,omitempty"`
+}
+
+// empty reports whether d carries nothing to deliver.
+func (d *Diff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && d.Error == ""
+}
+
+// Info describes a registered live query.
+type Info struct {
+	ID          string    `json:"id"`
+	ClientID    string    `json:"client_id"`
+	User        string    `json:"user,omitempty"`
+	Query       string    `json:"query"`
+	Key         []string  `json:"key,omitempty"`
+	Labels      []string  `json:"labels,omitempty"`
+	Rows        int       `json:"rows"`
+	Seq         uint64    `json:"seq"`
+	Evaluations int64     `json:"evaluations"`
+	Created     time.Time `json:"created"`
+	LastRun     time.Time `json:"last_run"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Manager holds the live queries of a server and keeps them up to date.
+// It is safe for concurrent use.
+type Manager struct {
+	run     Runner
+	source  Source
+	deliver Deliverer
+	config  Config
+
+	mu       sync.Mutex
+	queries  map[string]*liveQuery
+	lastFull time.Time // last time every query was marked stale
+
+	position uint64 // change log sequence read so far; owned by Refresh
+}
+
+type liveQuery struct {
+	opts    Options
+	id      string
+	keyCols []int           // indexes of the key columns
+	labels  map[string]bool // nil = any write
+	created time.Time
+
+	// Guarded by Manager.mu
+	columns     []string
+	rows        map[string][]any // current result by row identity
+	order       []string         // row identities in result order
+	seq         uint64
+	err         string
+	stale       bool
+	lastRun     time.Time
+	evaluations int64
+}
+
+// New returns a manager that runs queries with run and sends their diffs
+// with deliver. source may be nil, in which case queries are only
+// evaluated every Interval.
+func New(run Runner, source Source, deliver Deliverer, config Config) *Manager {
+	defaults := DefaultConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.Poll <= 0 {
+		config.Poll = defaults.Poll
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.MaxQueries <= 0 {
+		config.MaxQueries = defaults.MaxQueries
+	}
+	if config.MaxRows <= 0 {
+		config.MaxRows = defaults.MaxRows
+	}
+	m := &Manager{
+		run:      run,
+		source:   source,
+		deliver:  deliver,
+		config:   config,
+		queries:  make(map[string]*liveQuery),
+		lastFull: time.Now(),
+	}
+	if source != nil {
+		m.position = source.Sequence()
+	}
+	return m
+}
+
+// Register evaluates a live query and returns its first result. The
+// client receives diffs from then on.
+func (m *Manager) Register(ctx context.Context, opts Options) (*Snapshot, error) {
+	if opts.Query == "" {
+		return nil, fmt.Errorf("%w: query is required", ErrInvalid)
+	}
+	if user, ok := m.deliver.ClientUser(opts.ClientID); !ok || user != opts.User {
+		return nil, fmt.Errorf("%w: client %q is not connected", ErrInvalid, opts.ClientID)
+	}
+	m.mu.Lock()
+	full := len(m.queries) >= m.config.MaxQueries
+	m.mu.Unlock()
+	if full {
+		return nil, fmt.Errorf("%w (limit %d)", ErrLimit, m.config.MaxQueries)
+	}
+
+	q := &liveQuery{opts: opts, id: newID(), created: time.Now()}
+	if len(opts.Labels) > 0 {
+		q.labels = make(map[string]bool, len(opts.Labels))
+		for _, l := range opts.Labels {
+			q.labels[l] = true
+		}
+	}
+	columns, rows, err := m.query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range opts.Key {
+		i := slices.Index(columns, k)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: key column %q is not returned by the query", ErrInvalid, k)
+		}
+		q.keyCols = append(q.keyCols, i)
+	}
+	q.columns = columns
+	q.rows, q.order = q.index(rows)
+	q.lastRun = time.Now()
+	q.evaluations = 1
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.queries) >= m.config.MaxQueries {
+		return nil, fmt.Errorf("%w (limit %d)", ErrLimit, m.config.MaxQueries)
+	}
+	m.queries[q.id] = q
+	return &Snapshot{ID: q.id, Columns: columns, Rows: rows}, nil
+}
+
+// Unregister removes a live query of user.
+func (m *Manager) Unregister(id, user string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.queries[id]
+	if !ok || q.opts.User != user {
+		return ErrNotFound
+	}
+	delete(m.queries, id)
+	return nil
+}
+
+// Snapshot returns the current result of a live query of user, for a
+// client that missed a diff.
+func (m *Manager) Snapshot(id, user string) (*Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.queries[id]
+	if !ok || q.opts.User != user {
+		return nil, ErrNotFound
+	}
+	rows := make([][]any, len(q.order))
+	for i, k := range q.order {
+		rows[i] = q.rows[k]
+	}
+	return &Snapshot{ID: id, Seq: q.seq, Columns: q.columns, Rows: rows}, nil
+}
+
+// List returns the live queries of user, or of every user if all is set,
+// sorted by creation time.
+func (m *Manager) List(user string, all bool) []Info {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	infos := make([]Info, 0, len(m.queries))
+	for _, q := range m.queries {
+		if !all && q.opts.User != user {
+			continue
+		}
+		infos = append(infos, Info{
+			ID:          q.id,
+			ClientID:    q.opts.ClientID,
+			User:        q.opts.User,
+			Query:       q.opts.Query,
+			Key:         q.opts.Key,
+			Labels:      q.opts.Labels,
+			Rows:        len(q.rows),
+			Seq:         q.seq,
+			Evaluations: q.evaluations,
+			Created:     q.created,
+			LastRun:     q.lastRun,
+			Error:       q.err,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Created.Before(infos[j].Created) })
+	return infos
+}
+
+// Run keeps the live queries up to date until ctx ends.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.Poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh reads the change log, then evaluates the live queries it made
+// stale, or every query once Interval has passed since they all were.
+// Run calls it every Poll; without Run, call it from one goroutine.
+func (m *Manager) Refresh(ctx context.Context) {
+	m.readChanges()
+
+	m.mu.Lock()
+	if time.Since(m.lastFull) >= m.config.Interval {
+		m.lastFull = time.Now()
+		for _, q := range m.queries {
+			q.stale = true
+		}
+	}
+	var stale []*liveQuery
+	for _, q := range m.queries {
+		if q.stale {
+			q.stale = false
+			stale = append(stale, q)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, q := range stale {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, ok := m.deliver.ClientUser(q.opts.ClientID); !ok {
+			m.mu.Lock()
+			delete(m.queries, q.id)
+			m.mu.Unlock()
+			continue
+		}
+		m.evaluate(ctx, q)
+	}
+}
+
+// readChanges marks the live queries watching the labels written since the
+// last call as stale. If the log cannot be read, every query is.
+func (m *Manager) readChanges() {
+	if m.source == nil {
+		return
+	}
+	head := m.source.Sequence()
+	if head == m.position {
+		return
+	}
+
+	var events []cdc.Event
+	all := head < m.position // The log started over (e.g. restored from a backup)
+	for !all && m.position < head {
+		entries, err := m.source.EntriesAfter(m.position, 1000)
+		if err != nil || len(entries) == 0 {
+			all = true
+			break
+		}
+		m.position = entries[len(entries)-1].Sequence
+		batch, err := cdc.Events(entries)
+		if err != nil {
+			all = true
+			break
+		}
+		events = append(events, batch...)
+	}
+	if all {
+		m.position = head
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, q := range m.queries {
+		if all {
+			q.stale = true
+			continue
+		}
+		for _, ev := range events {
+			if q.watches(ev) {
+				q.stale = true
+				break
+			}
+		}
+	}
+}
+
+// evaluate runs q again and delivers the difference to its last result.
+func (m *Manager) evaluate(ctx context.Context, q *liveQuery) {
+	columns, rows, err := m.query(ctx, q)
+
+	m.mu.Lock()
+	if _, ok := m.queries[q.id]; !ok {
+		m.mu.Unlock()
+		return // Unregistered while running
+	}
+	q.lastRun = time.Now()
+	q.evaluations++
+	diff := &Diff{ID: q.id, Columns: q.columns}
+	if err != nil {
+		if q.err == err.Error() {
+			m.mu.Unlock()
+			return // Already reported
+		}
+		q.err = err.Error()
+		diff.Error = q.err
+	} else {
+		q.err = ""
+		diff = q.apply(columns, rows)
+	}
+	if diff.empty() {
+		m.mu.Unlock()
+		return
+	}
+	q.seq++
+	diff.Seq = q.seq
+	m.mu.Unlock()
+
+	m.deliver.Deliver(q.opts.ClientID, diff)
+}
+
+// query runs q's query within the evaluation timeout and row limit.
+func (m *Manager) query(ctx context.Context, q *liveQuery) ([]string, [][]any, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.config.Timeout)
+	defer cancel()
+	columns, rows, err := m.run(ctx, q.opts.Query, q.opts.Params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) > m.config.MaxRows {
+		return nil, nil, fmt.Errorf("%w: %d rows exceed the limit of %d", ErrInvalid, len(rows), m.config.MaxRows)
+	}
+	return columns, rows, nil
+}
+
+// watches reports whether a change event may affect q's result.
+func (q *liveQuery) watches(ev cdc.Event) bool {
+	if q.labels == nil {
+		return true
+	}
+	if ev.RelType != "" {
+		return q.labels[ev.RelType]
+	}
+	for _, l := range ev.Labels {
+		if q.labels[l] {
+			return true
+		}
+	}
+	return false
+}
+
+// apply replaces q's result with rows and returns the difference. If the
+// columns changed, the whole result is replaced.
+func (q *liveQuery) apply(columns []string, rows [][]any) *Diff {
+	diff := &Diff{ID: q.id, Columns: columns}
+	byKey, order := q.index(rows)
+	if !slices.Equal(columns, q.columns) {
+		for _, k := range q.order {
+			diff.Removed = append(diff.Removed, q.rows[k])
+		}
+		diff.Added = rows
+	} else {
+		for _, k := range q.order {
+			if _, ok := byKey[k]; !ok {
+				diff.Removed = append(diff.Removed, q.rows[k])
+			}
+		}
+		for _, k := range order {
+			before, ok := q.rows[k]
+			switch {
+			case !ok:
+				diff.Added = append(diff.Added, byKey[k])
+			case len(q.keyCols) > 0 && encode(before) != encode(byKey[k]):
+				diff.Changed = append(diff.Changed, Change{Before: before, After: byKey[k]})
+			}
+		}
+	}
+	q.columns, q.rows, q.order = columns, byKey, order
+	return diff
+}
+
+// index maps rows by their identity: the key columns, or the whole row.
+// Repeated identities are numbered so that duplicate rows are counted.
+func (q *liveQuery) index(rows [][]any) (map[string][]any, []string) {
+	byKey := make(map[string][]any, len(rows))
+	order := make([]string, 0, len(rows))
+	seen := make(map[string]int)
+	for _, row := range rows {
+		id := encode(row)
+		if len(q.keyCols) > 0 {
+			key := make([]any, len(q.keyCols))
+			for i, c := range q.keyCols {
+				if c < len(row) {
+					key[i] = row[c]
+				}
+			}
+			id = encode(key)
+		}
+		if n := seen[id]; n > 0 {
+			seen[id]++
+			id += "#" + strconv.Itoa(n)
+		} else {
+			seen[id] = 1
+		}
+		byKey[id] = row
+		order = append(order, id)
+	}
+	return byKey, order
+}
+
+// encode returns a comparable form of v.
+func encode(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "live-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,339 @@
+package livequery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// table is a fake Runner over rows of (id, total).
+type table struct {
+	mu   sync.Mutex
+	rows [][]any
+	err  error
+	runs int
+}
+
+func (tb *table) set(rows ...[]any) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.rows, tb.err = rows, nil
+}
+
+func (tb *table) fail(err error) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.err = err
+}
+
+func (tb *table) run(ctx context.Context, query string, params map[string]any) ([]string, [][]any, error) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.runs++
+	if tb.err != nil {
+		return nil, nil, tb.err
+	}
+	return []string{"id", "total"}, append([][]any(nil), tb.rows...), nil
+}
+
+// clients is a fake Deliverer recording diffs per client.
+type clients struct {
+	mu    sync.Mutex
+	users map[string]string
+	diffs map[string][]*Diff
+}
+
+func newClients() *clients {
+	return &clients{users: map[string]string{"c1": "alice", "c2": "bob"}, diffs: make(map[string][]*Diff)}
+}
+
+func (c *clients) Deliver(clientID string, diff *Diff) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.diffs[clientID] = append(c.diffs[clientID], diff)
+	return nil
+}
+
+func (c *clients) ClientUser(clientID string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	user, ok := c.users[clientID]
+	return user, ok
+}
+
+func (c *clients) disconnect(clientID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.users, clientID)
+}
+
+func (c *clients) received(clientID string) []*Diff {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Diff(nil), c.diffs[clientID]...)
+}
+
+// changeLog is a fake Source of node writes.
+type changeLog struct {
+	mu      sync.Mutex
+	entries []storage.WALEntry
+}
+
+func (l *changeLog) write(t *testing.T, label string) {
+	data, err := json.Marshal(storage.WALNodeData{Node: &storage.Node{ID: "n", Labels: []string{label}}})
+	require.NoError(t, err)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, storage.WALEntry{
+		Sequence:  uint64(len(l.entries) + 1),
+		Operation: storage.OpCreateNode,
+		Data:      data,
+	})
+}
+
+func (l *changeLog) Sequence() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return uint64(len(l.entries))
+}
+
+func (l *changeLog) EntriesAfter(afterSeq uint64, limit int) ([]storage.WALEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	end := min(len(l.entries), int(afterSeq)+limit)
+	return append([]storage.WALEntry(nil), l.entries[afterSeq:end]...), nil
+}
+
+func TestRegisterAndDiff(t *testing.T) {
+	tb := &table{}
+	tb.set([]any{1, 10}, []any{2, 20})
+	cl := newClients()
+	log := &changeLog{}
+	m := New(tb.run, log, cl, Config{Interval: time.Hour})
+	ctx := context.Background()
+
+	snap, err := m.Register(ctx, Options{ClientID: "c1", User: "alice", Query: "q", Key: []string{"id"}, Labels: []string{"Order"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "total"}, snap.Columns)
+	assert.Len(t, snap.Rows, 2)
+
+	// Nothing written: nothing evaluated
+	m.Refresh(ctx)
+	assert.Equal(t, 1, tb.runs)
+
+	tb.set([]any{1, 15}, []any{3, 30})
+	log.write(t, "Order")
+	m.Refresh(ctx)
+	diffs := cl.received("c1")
+	require.Len(t, diffs, 1)
+	diff := diffs[0]
+	assert.Equal(t, snap.ID, diff.ID)
+	assert.EqualValues(t, 1, diff.Seq)
+	assert.Equal(t, [][]any{{3, 30}}, diff.Added)
+	assert.Equal(t, [][]any{{2, 20}}, diff.Removed)
+	assert.Equal(t, []Change{{Before: []any{1, 10}, After: []any{1, 15}}}, diff.Changed)
+
+	current, err := m.Snapshot(snap.ID, "alice")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, current.Seq)
+	assert.Equal(t, [][]any{{1, 15}, {3, 30}}, current.Rows)
+
+	// An unchanged result sends nothing
+	log.write(t, "Order")
+	m.Refresh(ctx)
+	assert.Len(t, cl.received("c1"), 1)
+	assert.Equal(t, 3, tb.runs)
+}
+
+func TestDiffWithoutKey(t *testing.T) {
+	tb := &table{}
+	tb.set([]any{1, 10}, []any{1, 10})
+	cl := newClients()
+	log := &changeLog{}
+	m := New(tb.run, log, cl, Config{})
+	ctx := context.Background()
+	_, err := m.Register(ctx, Options{ClientID: "c1", User: "alice", Query: "q"})
+	require.NoError(t, err)
+
+	// One duplicate goes away, and a changed row is a removal and an addition
+	tb.set([]any{1, 11})
+	log.write(t, "Anything")
+	m.Refresh(ctx)
+	diffs := cl.received("c1")
+	require.Len(t, diffs, 1)
+	assert.Equal(t, [][]any{{1, 11}}, diffs[0].Added)
+	assert.Equal(t, [][]any{{1, 10}, {1, 10}}, diffs[0].Removed)
+	assert.Empty(t, diffs[0].Changed)
+}
+
+func TestLabelFilter(t *testing.T) {
+	tb := &table{}
+	cl := newClients()
+	log := &changeLog{}
+	m := New(tb.run, log, cl, Config{Interval: time.Hour})
+	ctx := context.Background()
+	_, err := m.Register(ctx, Options{ClientID: "c1", User: "alice", Query: "q", Labels: []string{"Order"}})
+	require.NoError(t, err)
+
+	log.write(t, "Customer")
+	m.Refresh(ctx)
+	assert.Equal(t, 1, tb.runs, "writes to other labels are ignored")
+
+	log.write(t, "Order")
+	m.Refresh(ctx)
+	assert.Equal(t, 2, tb.runs)
+}
+
+func TestIntervalReevaluates(t *testing.T) {
+	tb := &table{}
+	cl := newClients()
+	m := New(tb.run, nil, cl, Config{Interval: time.Millisecond})
+	ctx := context.Background()
+	_, err := m.Register(ctx, Options{ClientID: "c1", User: "alice", Query: "q"})
+	require.NoError(t, err)
+
+	tb.set([]any{1, 10})
+	time.Sleep(5 * time.Millisecond)
+	m.Refresh(ctx)
+	diffs := cl.received("c1")
+	require.Len(t, diffs, 1)
+	assert.Equal(t, [][]any{{1, 10}}, diffs[0].Added)
+}
+
+func TestErrorDiff(t *testing.T) {
+	tb := &table{}
+	tb.set([]any{1, 10})
+	cl := newClients()
+	log := &changeLog{}
+	m := New(tb.run, log, cl, Config{})
+	ctx := context.Background()
+	_, err := m.Register(ctx, Options{ClientID: "c1", User: "alice", Query: "q"})
+	require.NoError(t, err)
+
+	tb.fail(errors.New("index offline"))
+	for range 2 {
+		log.write(t, "Order")
+		m.Refresh(ctx)
+	}
+	diffs := cl.received("c1")
+	require.Len(t, diffs, 1, "an error is reported once")
+	assert.Equal(t, "index offline", diffs[0].Error)
+
+	// The result is unchanged once the query works again
+	tb.set([]any{1, 10})
+	log.write(t, "Order")
+	m.Refresh(ctx)
+	assert.Len(t, cl.received("c1"), 1)
+	assert.Empty(t, m.List("alice", false)[0].Error)
+}
+
+func TestRegisterValidation(t *testing.T) {
+	tb := &table{}
+	cl := newClients()
+	m := New(tb.run, nil, cl, Config{MaxQueries: 1})
+	ctx := context.Background()
+
+	_, err := m.Register(ctx, Options{ClientID: "c1", User: "alice"})
+	assert.ErrorIs(t, err, ErrInvalid, "query is required")
+	_, err = m.Register(ctx, Options{ClientID: "c1", User: "bob", Query: "q"})
+	assert.ErrorIs(t, err, ErrInvalid, "connection of another user")
+	_, err = m.Register(ctx, Options{ClientID: "c9", User: "alice", Query: "q"})
+	assert.ErrorIs(t, err, ErrInvalid, "unknown connection")
+	_, err = m.Register(ctx, Options{ClientID: "c1", User: "alice", Query: "q", Key: []string{"name"}})
+	assert.ErrorIs(t, err, ErrInvalid, "key column not returned")
+
+	tb.fail(errors.New("syntax error"))
+	_, err = m.Register(ctx, Options{ClientID: "c1", User: "alice", Query: "q"})
+	assert.EqualError(t, err, "syntax error")
+	tb.set()
+
+	_, err = m.Register(ctx, Options{ClientID: "c1", User: "alice", Query: "q"})
+	require.NoError(t, err)
+	_, err = m.Register(ctx, Options{ClientID: "c2", User: "bob", Query: "q"})
+	assert.ErrorIs(t, err, ErrLimit)
+}
+
+func TestOwnership(t *testing.T) {
+	tb := &table{}
+	cl := newClients()
+	m := New(tb.run, nil, cl, Config{})
+	ctx := context.Background()
+	snap, err := m.Register(ctx, Options{ClientID: "c1", User: "alice", Query: "q"})
+	require.NoError(t, err)
+	_, err = m.Register(ctx, Options{ClientID: "c2", User: "bob", Query: "q"})
+	require.NoError(t, err)
+
+	assert.Len(t, m.List("alice", false), 1)
+	assert.Len(t, m.List("", true), 2)
+	_, err = m.Snapshot(snap.ID, "bob")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, m.Unregister(snap.ID, "bob"), ErrNotFound)
+	require.NoError(t, m.Unregister(snap.ID, "alice"))
+	_, err = m.Snapshot(snap.ID, "alice")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDisconnectedClientDropped(t *testing.T) {
+	tb := &table{}
+	cl := newClients()
+	log := &changeLog{}
+	m := New(tb.run, log, cl, Config{})
+	ctx := context.Background()
+	_, err := m.Register(ctx, Options{ClientID: "c1", User: "alice", Query: "q"})
+	require.NoError(t, err)
+
+	cl.disconnect("c1")
+	log.write(t, "Order")
+	m.Refresh(ctx)
+	assert.Empty(t, m.List("", true))
+	assert.Equal(t, 1, tb.runs, "not evaluated for a disconnected client")
+}
+
+func TestHandler(t *testing.T) {
+	tb := &table{}
+	tb.set([]any{1, 10})
+	m := New(tb.run, nil, newClients(), Config{})
+	user := func(r *http.Request) string { return r.Header.Get("X-User") }
+	admin := func(r *http.Request) bool { return user(r) == "admin" }
+	h := Handler(m, user, admin)
+
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, BasePath, "alice", `{"client_id": "c1", "query": "q", "key": ["id"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var snap Snapshot
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &snap))
+	assert.Len(t, snap.Rows, 1)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, BasePath, "alice", `{"client_id": "c1"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, BasePath, "alice", `not json`).Code)
+
+	rec = do(http.MethodGet, BasePath, "alice", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), snap.ID)
+	assert.NotContains(t, do(http.MethodGet, BasePath, "bob", "").Body.String(), snap.ID)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, BasePath+"?all=true", "bob", "").Code)
+	assert.Contains(t, do(http.MethodGet, BasePath+"?all=true", "admin", "").Body.String(), snap.ID)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, BasePath+"/"+snap.ID, "alice", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, BasePath+"/"+snap.ID, "bob", "").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, BasePath+"/"+snap.ID, "alice", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, BasePath+"/"+snap.ID, "alice", "").Code)
+}
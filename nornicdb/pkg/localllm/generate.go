@@ -39,6 +39,7 @@ type GenerateParams struct {
 	TopP        float32  // Nucleus sampling (default: 0.9)
 	TopK        int      // Top-K sampling (default: 40)
 	StopTokens  []string // Stop generation on these strings
+	Grammar     string   // GBNF grammar the output must match ("" = unconstrained)
 }
 
 // DefaultGenerateParams returns parameters optimized for structured JSON output.
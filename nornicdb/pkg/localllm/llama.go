@@ -240,12 +240,23 @@ int gen_decode_token(struct llama_context* ctx, int32_t token, int pos) {
     return result;
 }
 
-// Sample next token using sampler chain
-int32_t sample_token(struct llama_context* ctx, struct llama_model* model, float temperature, float top_p, int top_k) {
-    // Create sampler chain
+// Create the sampler chain for one generation. A grammar (GBNF, root rule
+// "root") is applied first, so the other samplers only see tokens it allows;
+// it is stateful, so the chain must live for the whole generation.
+// Returns NULL if the grammar does not parse.
+struct llama_sampler* new_sampler(struct llama_model* model, float temperature, float top_p, int top_k, const char* grammar) {
     struct llama_sampler_chain_params sparams = llama_sampler_chain_default_params();
     struct llama_sampler* smpl = llama_sampler_chain_init(sparams);
 
+    if (grammar != NULL && grammar[0] != '\0') {
+        struct llama_sampler* g = llama_sampler_init_grammar(llama_model_get_vocab(model), grammar, "root");
+        if (g == NULL) {
+            llama_sampler_free(smpl);
+            return NULL;
+        }
+        llama_sampler_chain_add(smpl, g);
+    }
+
     // Add samplers to chain: top-k -> top-p -> temperature -> dist
     if (top_k > 0) {
         llama_sampler_chain_add(smpl, llama_sampler_init_top_k(top_k));
@@ -257,12 +268,13 @@ int32_t sample_token(struct llama_context* ctx, struct llama_model* model, float
         llama_sampler_chain_add(smpl, llama_sampler_init_temp(temperature));
     }
     llama_sampler_chain_add(smpl, llama_sampler_init_dist(42));
+    return smpl;
+}
 
-    // Sample from context (-1 means last token's logits)
-    int32_t token = llama_sampler_sample(smpl, ctx, -1);
-
-    llama_sampler_free(smpl);
-    return token;
+// Sample next token from the last token's logits. The chain accepts the
+// token, advancing its grammar.
+int32_t sample_token(struct llama_sampler* smpl, struct llama_context* ctx) {
+    return llama_sampler_sample(smpl, ctx, -1);
 }
 
 // Detokenize single token to string
@@ -801,6 +813,10 @@ type GenerateParams struct {
 	TopP        float32
 	TopK        int
 	StopTokens  []string
+
+	// Grammar, if set, is a GBNF grammar (root rule "root") that output
+	// must match; see package heimdall/grammar.
+	Grammar string
 }
 
 // DefaultGenerateParams returns sensible defaults for structured output.
@@ -890,6 +906,19 @@ func (g *GenerationModel) GenerateStream(ctx context.Context, prompt string, par
 		remaining -= batchTokens
 	}
 
+	// One sampler chain for the whole generation, so a grammar tracks the
+	// tokens produced so far
+	var cGrammar *C.char
+	if params.Grammar != "" {
+		cGrammar = C.CString(params.Grammar)
+		defer C.free(unsafe.Pointer(cGrammar))
+	}
+	sampler := C.new_sampler(g.model, C.float(params.Temperature), C.float(params.TopP), C.int(params.TopK), cGrammar)
+	if sampler == nil {
+		return fmt.Errorf("invalid generation grammar")
+	}
+	defer C.llama_sampler_free(sampler)
+
 	// Autoregressive generation
 	genPos := tokenCount     // Start generation after all prompt tokens
 	buf := make([]byte, 256) // Buffer for detokenization
@@ -902,7 +931,7 @@ func (g *GenerationModel) GenerateStream(ctx context.Context, prompt string, par
 		}
 
 		// Sample next token
-		token := C.sample_token(g.ctx, g.model, C.float(params.Temperature), C.float(params.TopP), C.int(params.TopK))
+		token := C.sample_token(sampler, g.ctx)
 
 		// Check for EOS
 		if C.is_eos(g.model, token) != 0 {
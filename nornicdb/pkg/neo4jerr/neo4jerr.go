@@ -0,0 +1,155 @@
+// Package neo4jerr maps internal errors to Neo4j status codes.
+//
+// Neo4j drivers decide what to do with a failure from its status code,
+// "Neo.<Classification>.<Category>.<Title>". TransientError failures are
+// retried by managed transactions (session.ExecuteWrite and friends);
+// ClientError and DatabaseError failures are returned to the application.
+// The Bolt server sends the code in FAILURE messages and the HTTP server in
+// the errors of a transaction response, so both report the same code for
+// the same error:
+//
+//	code := neo4jerr.Code(err, neo4jerr.StatementSyntaxError)
+//
+// Code walks the error chain, so wrapped errors keep their code. Packages
+// that want a specific code for an error of their own return an *Error:
+//
+//	return neo4jerr.New(neo4jerr.StatementEntityNotFound, "no such index")
+package neo4jerr
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/bookmark"
+	"github.com/orneryd/nornicdb/pkg/idempotency"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/orneryd/nornicdb/pkg/writethrottle"
+)
+
+// Status codes reported by NornicDB.
+const (
+	StatementSyntaxError       = "Neo.ClientError.Statement.SyntaxError"
+	StatementEntityNotFound    = "Neo.ClientError.Statement.EntityNotFound"
+	ConstraintValidationFailed = "Neo.ClientError.Schema.ConstraintValidationFailed"
+	Unauthorized               = "Neo.ClientError.Security.Unauthorized"
+	Forbidden                  = "Neo.ClientError.Security.Forbidden"
+	RequestInvalid             = "Neo.ClientError.Request.Invalid"
+	TooManyRequests            = "Neo.ClientError.Request.TooManyRequests"
+	QuotaExceeded              = "Neo.ClientError.Database.QuotaExceeded"
+	TransactionNotFound        = "Neo.ClientError.Transaction.TransactionNotFound"
+	InvalidBookmark            = "Neo.ClientError.Transaction.InvalidBookmark"
+	TransactionTimedOut        = "Neo.TransientError.Transaction.TransactionTimedOut"
+	Terminated                 = "Neo.TransientError.Transaction.Terminated"
+	Outdated                   = "Neo.TransientError.Transaction.Outdated"
+	BookmarkTimeout            = "Neo.TransientError.Transaction.BookmarkTimeout"
+	DatabaseUnavailable        = "Neo.TransientError.Database.DatabaseUnavailable"
+	ResourceExhaustion         = "Neo.TransientError.Request.ResourceExhaustion"
+	UnknownError               = "Neo.DatabaseError.General.UnknownError"
+	TransactionStartFailed     = "Neo.DatabaseError.Transaction.TransactionStartFailed"
+	TransactionCommitFailed    = "Neo.DatabaseError.Transaction.TransactionCommitFailed"
+	TransactionRollbackFailed  = "Neo.DatabaseError.Transaction.TransactionRollbackFailed"
+)
+
+// Error is an error with an explicit status code.
+type Error struct {
+	Code    string
+	Message string
+	Err     error // Underlying error, if any
+}
+
+// New returns an error with the given code and message.
+func New(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap returns err with the given code, or nil if err is nil.
+func Wrap(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Message: err.Error(), Err: err}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// mappings lists the internal errors with a status code, checked in order
+// with errors.Is.
+var mappings = []struct {
+	err  error
+	code string
+}{
+	{storage.ErrQuotaExceeded, QuotaExceeded},
+	{storage.ErrConflict, Outdated},
+	{storage.ErrRecovering, DatabaseUnavailable},
+	{storage.ErrStorageClosed, DatabaseUnavailable},
+	{storage.ErrWALClosed, DatabaseUnavailable},
+	{storage.ErrSchemaViolation, ConstraintValidationFailed},
+	{storage.ErrAlreadyExists, ConstraintValidationFailed},
+	{storage.ErrNotFound, StatementEntityNotFound},
+	{storage.ErrNoTransaction, TransactionNotFound},
+	{storage.ErrTransactionClosed, TransactionNotFound},
+	{auth.ErrInsufficientRole, Forbidden},
+	{auth.ErrInvalidCredentials, Unauthorized},
+	{auth.ErrInvalidToken, Unauthorized},
+	{auth.ErrSessionExpired, Unauthorized},
+	{idempotency.ErrKeyReused, RequestInvalid},
+	{idempotency.ErrUnavailable, DatabaseUnavailable},
+	{bookmark.ErrInvalid, InvalidBookmark},
+	{bookmark.ErrTimeout, BookmarkTimeout},
+	{writethrottle.ErrThrottled, ResourceExhaustion},
+	{context.DeadlineExceeded, TransactionTimedOut},
+	{context.Canceled, Terminated},
+}
+
+// Code returns the status code for err: the code of the first *Error in
+// its chain, else the code of the first internal error it wraps, else
+// fallback.
+func Code(err error, fallback string) string {
+	if err == nil {
+		return fallback
+	}
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	var throttled *ratelimit.ThrottleError
+	if errors.As(err, &throttled) {
+		return TooManyRequests
+	}
+	var violation *storage.ConstraintViolationError
+	if errors.As(err, &violation) {
+		return ConstraintValidationFailed
+	}
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			return m.code
+		}
+	}
+	return fallback
+}
+
+// Classification returns the classification of code: "ClientError",
+// "TransientError" or "DatabaseError".
+func Classification(code string) string {
+	parts := strings.SplitN(code, ".", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// IsTransient reports whether drivers retry a failure with code.
+func IsTransient(code string) bool {
+	return Classification(code) == "TransientError"
+}
@@ -0,0 +1,63 @@
+package neo4jerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/bookmark"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/orneryd/nornicdb/pkg/writethrottle"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, StatementSyntaxError},
+		{"unknown", errors.New("unexpected token"), StatementSyntaxError},
+		{"conflict", fmt.Errorf("commit: %w", storage.ErrConflict), Outdated},
+		{"quota", storage.ErrQuotaExceeded, QuotaExceeded},
+		{"recovering", storage.ErrRecovering, DatabaseUnavailable},
+		{"not found", fmt.Errorf("node 42: %w", storage.ErrNotFound), StatementEntityNotFound},
+		{"forbidden", auth.ErrInsufficientRole, Forbidden},
+		{"invalid bookmark", fmt.Errorf("begin: %w", bookmark.ErrInvalid), InvalidBookmark},
+		{"bookmark timeout", bookmark.ErrTimeout, BookmarkTimeout},
+		{"throttled", fmt.Errorf("write: %w", writethrottle.ErrThrottled), ResourceExhaustion},
+		{"rate limited", &ratelimit.ThrottleError{Limit: "qps", Key: "user:alice"}, TooManyRequests},
+		{"constraint", &storage.ConstraintViolationError{Label: "User", Message: "duplicate"}, ConstraintValidationFailed},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), TransactionTimedOut},
+		{"canceled", context.Canceled, Terminated},
+		{"explicit", fmt.Errorf("run: %w", New(Terminated, "query was killed")), Terminated},
+		{"explicit wins", Wrap(RequestInvalid, storage.ErrConflict), RequestInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Code(tt.err, StatementSyntaxError))
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	assert.Nil(t, Wrap(UnknownError, nil))
+
+	err := Wrap(UnknownError, storage.ErrConflict)
+	assert.Equal(t, storage.ErrConflict.Error(), err.Error())
+	assert.ErrorIs(t, err, storage.ErrConflict)
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, IsTransient(Outdated))
+	assert.True(t, IsTransient(ResourceExhaustion))
+	assert.False(t, IsTransient(StatementSyntaxError))
+	assert.False(t, IsTransient(TransactionCommitFailed))
+	assert.Equal(t, "ClientError", Classification(Forbidden))
+	assert.Equal(t, "", Classification("bogus"))
+}
@@ -0,0 +1,815 @@
+// Package nornicdb provides agent memory: sessions of episodic memories
+// consolidated into semantic memories.
+//
+// Agents append what happens during a session as episodic memories, one per
+// event, tagged with the session's ID. Episodes are cheap and decay fast
+// (7-day half-life). Consolidation has the SLM read the episodes of each
+// session and write down what is worth keeping as a semantic memory linked
+// to the episodes it came from:
+//
+//	(:Memory {tier: "SEMANTIC"})-[:DERIVED_FROM]->(:Memory {tier: "EPISODIC", session_id: "s1"})
+//
+// The links are reviewed by Heimdall QC like inferred edges, when the
+// inference engine has one (see SetHeimdallQC): episodes QC rejects are not
+// linked and are left out of later consolidations. Consolidation runs on
+// demand or on a schedule (StartMemoryConsolidation).
+//
+// The consolidation prompt is the PromptConsolidation template. With a
+// registry set (SetMemoryPrompts), its versions can be switched or A/B
+// tested per session, and each version's parse failure rate, QC approval
+// rate and latency are recorded.
+//
+// Retrieval is Graph-RAG: hybrid search finds memories, then DERIVED_FROM
+// links add the semantic memory of each episode found and the episodes
+// behind each semantic memory found.
+//
+// Example:
+//
+//	db.SetMemorySummarizer(func(ctx context.Context, prompt string) (string, error) {
+//		params := heimdall.DefaultGenerateParams()
+//		params.Grammar = grammar.FromContext(ctx)
+//		return manager.Generate(ctx, prompt, params)
+//	})
+//	db.AppendEpisode(ctx, "s1", "User asked for dark mode in the editor", nil)
+//	db.ConsolidateMemories(ctx, &nornicdb.ConsolidationConfig{SessionID: "s1"})
+//	hits, _ := db.RetrieveMemories(ctx, "display preferences", nil)
+package nornicdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/cypher"
+	"github.com/orneryd/nornicdb/pkg/heimdall/grammar"
+	"github.com/orneryd/nornicdb/pkg/heimdall/promptguard"
+	"github.com/orneryd/nornicdb/pkg/heimdall/prompts"
+	"github.com/orneryd/nornicdb/pkg/inference"
+	"github.com/orneryd/nornicdb/pkg/search"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// Agent memory properties and relationships.
+const (
+	// SessionIDProperty is the session an episode (or the semantic memory
+	// consolidated from it) belongs to.
+	SessionIDProperty = "session_id"
+	// ConsolidatedIntoProperty is the ID of the semantic memory an episode
+	// was consolidated into.
+	ConsolidatedIntoProperty = "consolidated_into"
+	// DerivedFromEdge links a semantic memory to its episodes.
+	DerivedFromEdge = "DERIVED_FROM"
+
+	// consolidationRejectedProperty marks episodes Heimdall QC rejected.
+	consolidationRejectedProperty = "consolidation_rejected"
+)
+
+// Consolidation defaults.
+const (
+	defaultConsolidationMinEpisodes = 2
+	defaultConsolidationMaxEpisodes = 20
+
+	// consolidationEpisodeChars bounds each episode's text in the prompt.
+	consolidationEpisodeChars = 500
+
+	// relatedMemoryWeight scales the score of memories reached over a
+	// DERIVED_FROM link from the memory search found.
+	relatedMemoryWeight = 0.5
+)
+
+// PromptConsolidation is the prompt registry name of the consolidation
+// prompt. Its variables are session, data_rules and episodes.
+const PromptConsolidation = "consolidation"
+
+// consolidationTemplate is the built-in consolidation prompt.
+var consolidationTemplate = prompts.Template{
+	Name:    PromptConsolidation,
+	Version: "v1",
+	Text: "Consolidate the memories an AI agent recorded during session {{session}}. " +
+		"Keep what is worth remembering long term: facts, preferences, decisions and outcomes, not the conversation itself.\n\n" +
+		"{{data_rules}}\n\n" +
+		"{{episodes}}\n" +
+		"Answer with only a JSON object: " +
+		`{"title": "<short title>", "content": "<the memory, at most five sentences>"}`,
+}
+
+// RegisterMemoryPrompts registers the built-in consolidation prompt as
+// version "v1".
+func RegisterMemoryPrompts(reg *prompts.Registry) error {
+	return reg.Register(consolidationTemplate)
+}
+
+var (
+	// ErrSummarizerNotConfigured is returned when consolidation has no SLM.
+	ErrSummarizerNotConfigured = errors.New("memory summarizer not configured")
+	// ErrConsolidationRunning is returned when a consolidation is already active.
+	ErrConsolidationRunning = errors.New("memory consolidation already running")
+)
+
+// MemorySummarizer answers a prompt with the SLM. Consolidation asks it to
+// turn a session's episodes into one semantic memory; ctx carries the
+// grammar of the expected JSON answer (grammar.FromContext).
+type MemorySummarizer func(ctx context.Context, prompt string) (string, error)
+
+// ConsolidationConfig selects the episodes to consolidate.
+type ConsolidationConfig struct {
+	SessionID   string        // Consolidate only this session ("" = all sessions)
+	MinAge      time.Duration // Skip episodes younger than this, so active sessions can finish
+	MinEpisodes int           // Sessions need at least this many new episodes (0 = 2)
+	MaxEpisodes int           // Episodes per semantic memory (0 = 20)
+}
+
+// ConsolidationResult summarizes one consolidation run.
+type ConsolidationResult struct {
+	Sessions int           `json:"sessions"` // Sessions with new semantic memories
+	Episodes int           `json:"episodes"` // Episodes consolidated
+	Memories int           `json:"memories"` // Semantic memories created
+	Rejected int           `json:"rejected"` // Episodes Heimdall QC rejected
+	Failed   int           `json:"failed"`   // Groups of episodes the SLM could not summarize
+	Duration time.Duration `json:"duration"`
+}
+
+// MemoryRetrieval configures RetrieveMemories.
+type MemoryRetrieval struct {
+	SessionID string // Only memories of this session ("" = all)
+	Limit     int    // Memories found by search (0 = 10); linked memories come on top
+}
+
+// MemoryHit is a memory returned by RetrieveMemories.
+type MemoryHit struct {
+	Memory *Memory `json:"memory"`
+	Score  float64 `json:"score"`
+	Via    string  `json:"via,omitempty"` // Memory search found, if reached over DERIVED_FROM
+}
+
+// SetMemorySummarizer sets the SLM used to consolidate episodes.
+func (db *DB) SetMemorySummarizer(summarizer MemorySummarizer) {
+	db.memoryMu.Lock()
+	defer db.memoryMu.Unlock()
+	db.memorySummarizer = summarizer
+}
+
+// SetMemoryPrompts makes consolidation select its prompt from reg and
+// record per-version metrics there. Register the built-in prompt with
+// RegisterMemoryPrompts.
+func (db *DB) SetMemoryPrompts(reg *prompts.Registry) {
+	db.memoryMu.Lock()
+	defer db.memoryMu.Unlock()
+	db.memoryPrompts = reg
+}
+
+// SetHeimdallQC makes Heimdall review inferred edges and the links of
+// consolidated memories. It has no effect when auto-links are disabled.
+func (db *DB) SetHeimdallQC(qc *inference.HeimdallQC) {
+	if db.inference != nil {
+		db.inference.SetHeimdallQC(qc)
+	}
+}
+
+// AppendEpisode stores an episodic memory of a session. The memory is
+// indexed for search at once and embedded by the embed queue.
+func (db *DB) AppendEpisode(ctx context.Context, sessionID, content string, properties map[string]any) (*Memory, error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
+	return db.appendEpisode(sessionID, content, properties)
+}
+
+// checkOpen returns ErrClosed once the database is closed. The agent memory
+// methods hold db.mu only for this check, as the nornicdb.memory.*
+// procedures run them while ExecuteCypher holds it.
+func (db *DB) checkOpen() error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+func (db *DB) appendEpisode(sessionID, content string, properties map[string]any) (*Memory, error) {
+	if sessionID == "" || strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("%w: session ID and content are required", ErrInvalidInput)
+	}
+	props := make(map[string]any, len(properties)+1)
+	for k, v := range properties {
+		props[k] = v
+	}
+	props[SessionIDProperty] = sessionID
+	delete(props, "tier") // Episodes stay episodic
+	delete(props, "content")
+
+	mem := &Memory{ID: generateID("mem"), Content: content, Tier: TierEpisodic, Properties: props}
+	if err := db.storeMemory(mem); err != nil {
+		return nil, err
+	}
+	return mem, nil
+}
+
+// storeMemory creates a memory without db.mu, indexes it for search and
+// queues it for embedding.
+func (db *DB) storeMemory(mem *Memory) error {
+	now := time.Now()
+	mem.DecayScore = 1.0
+	mem.CreatedAt = now
+	mem.LastAccessed = now
+	node := memoryToNode(mem)
+	node.Properties = db.encryptProperties(node.Properties)
+	if err := db.storage.CreateNode(node); err != nil {
+		return fmt.Errorf("storing memory: %w", err)
+	}
+	if db.searchService != nil {
+		_ = db.searchService.IndexNode(node) // Best effort - search may lag behind writes
+	}
+	if db.embedQueue != nil {
+		db.embedQueue.Enqueue(mem.ID)
+	}
+	return nil
+}
+
+// ConsolidateMemories turns the unconsolidated episodes of each session into
+// semantic memories with the SLM set by SetMemorySummarizer. A nil config
+// consolidates every session.
+func (db *DB) ConsolidateMemories(ctx context.Context, config *ConsolidationConfig) (*ConsolidationResult, error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
+	return db.consolidateMemories(ctx, config)
+}
+
+// consolidateMemories runs a consolidation without db.mu, so Close can stop
+// a scheduled run.
+func (db *DB) consolidateMemories(ctx context.Context, config *ConsolidationConfig) (*ConsolidationResult, error) {
+	if config == nil {
+		config = &ConsolidationConfig{}
+	}
+	minEpisodes, maxEpisodes := config.MinEpisodes, config.MaxEpisodes
+	if minEpisodes <= 0 {
+		minEpisodes = defaultConsolidationMinEpisodes
+	}
+	if maxEpisodes <= 0 {
+		maxEpisodes = defaultConsolidationMaxEpisodes
+	}
+	if maxEpisodes < minEpisodes {
+		return nil, fmt.Errorf("%w: max episodes %d is below min episodes %d", ErrInvalidInput, maxEpisodes, minEpisodes)
+	}
+
+	db.memoryMu.RLock()
+	summarize, reg := db.memorySummarizer, db.memoryPrompts
+	db.memoryMu.RUnlock()
+	if summarize == nil {
+		return nil, ErrSummarizerNotConfigured
+	}
+	if !db.consolidateMu.TryLock() {
+		return nil, ErrConsolidationRunning
+	}
+	defer db.consolidateMu.Unlock()
+
+	start := time.Now()
+	result := &ConsolidationResult{}
+
+	sessions, err := db.pendingEpisodes(ctx, config.SessionID, start.Add(-config.MinAge))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(sessions))
+	for name := range sessions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, session := range names {
+		episodes := sessions[session]
+		consolidated := false
+		// Full groups of maxEpisodes, then the rest if it is large enough;
+		// a smaller rest waits for more episodes.
+		for len(episodes) >= minEpisodes {
+			n := min(len(episodes), maxEpisodes)
+			group := episodes[:n]
+			episodes = episodes[n:]
+
+			created, err := db.consolidateGroup(ctx, summarize, reg, session, group, result)
+			if err != nil {
+				return nil, err
+			}
+			consolidated = consolidated || created
+		}
+		if consolidated {
+			result.Sessions++
+		}
+	}
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// pendingEpisodes returns the unconsolidated episodes created before cutoff,
+// by session, oldest first.
+func (db *DB) pendingEpisodes(ctx context.Context, sessionID string, cutoff time.Time) (map[string][]*Memory, error) {
+	sessions := make(map[string][]*Memory)
+	err := storage.StreamNodesWithFallback(ctx, db.storage, 1000, func(node *storage.Node) error {
+		if !nodeHasLabel(node.Labels, "Memory") || node.CreatedAt.After(cutoff) {
+			return nil
+		}
+		props := node.Properties
+		if tier, _ := props["tier"].(string); tier != string(TierEpisodic) {
+			return nil
+		}
+		session, _ := props[SessionIDProperty].(string)
+		if session == "" || (sessionID != "" && session != sessionID) {
+			return nil
+		}
+		if _, done := props[ConsolidatedIntoProperty]; done {
+			return nil
+		}
+		if rejected, _ := props[consolidationRejectedProperty].(bool); rejected {
+			return nil
+		}
+		decrypted := *node
+		decrypted.Properties = db.decryptProperties(props)
+		decrypted.Embedding = nil
+		sessions[session] = append(sessions[session], nodeToMemory(&decrypted))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("streaming episodes: %w", err)
+	}
+	for _, episodes := range sessions {
+		sort.Slice(episodes, func(i, j int) bool {
+			if !episodes[i].CreatedAt.Equal(episodes[j].CreatedAt) {
+				return episodes[i].CreatedAt.Before(episodes[j].CreatedAt)
+			}
+			return episodes[i].ID < episodes[j].ID
+		})
+	}
+	return sessions, nil
+}
+
+// consolidateGroup summarizes episodes into a semantic memory, links the
+// episodes QC approves and marks the others rejected. It reports whether a
+// memory was created; SLM failures are counted in result, not returned.
+// With a prompt registry, the prompt version is selected per session and
+// the outcome recorded against it.
+func (db *DB) consolidateGroup(ctx context.Context, summarize MemorySummarizer, reg *prompts.Registry, session string, episodes []*Memory, result *ConsolidationResult) (bool, error) {
+	tmpl := consolidationTemplate
+	if reg != nil {
+		selected, err := reg.Select(PromptConsolidation, session)
+		if err != nil {
+			return false, err
+		}
+		tmpl = selected
+	}
+	record := func(o prompts.Outcome) {
+		if reg != nil {
+			reg.Record(PromptConsolidation, tmpl.Version, o)
+		}
+	}
+
+	prompt, err := consolidationPrompt(tmpl, session, episodes)
+	if err != nil {
+		log.Printf("⚠️  Memory consolidation of session %s failed: %v", session, err)
+		result.Failed++
+		return false, nil
+	}
+	start := time.Now()
+	answer, err := summarize(consolidationContext(ctx), prompt)
+	elapsed := time.Since(start)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		record(prompts.Outcome{Latency: elapsed, Err: err})
+		log.Printf("⚠️  Memory consolidation of session %s failed: %v", session, err)
+		result.Failed++
+		return false, nil
+	}
+	title, content, err := parseConsolidation(answer)
+	if err != nil {
+		record(prompts.Outcome{Latency: elapsed})
+		log.Printf("⚠️  Memory consolidation of session %s failed: %v", session, err)
+		result.Failed++
+		return false, nil
+	}
+
+	id := generateID("mem")
+	approved, rejected := db.reviewConsolidation(ctx, id, title, content, episodes)
+	record(prompts.Outcome{Latency: elapsed, Parsed: true, Judged: len(episodes), Approved: len(approved)})
+	for _, ep := range rejected {
+		if err := db.setMemoryProperty(ep.ID, consolidationRejectedProperty, true); err != nil {
+			return false, err
+		}
+	}
+	result.Rejected += len(rejected)
+	if len(approved) == 0 {
+		return false, nil
+	}
+
+	now := time.Now()
+	mem := &Memory{
+		ID:      id,
+		Title:   title,
+		Content: content,
+		Tier:    TierSemantic,
+		Source:  "consolidation",
+		Properties: map[string]any{
+			SessionIDProperty:   session,
+			"consolidated_from": int64(len(approved)),
+			"consolidated_at":   now.Format(time.RFC3339),
+		},
+	}
+	if err := db.storeMemory(mem); err != nil {
+		return false, err
+	}
+
+	for _, ep := range approved {
+		edge := &storage.Edge{
+			ID:            storage.EdgeID(generateID("edge")),
+			StartNode:     storage.NodeID(id),
+			EndNode:       storage.NodeID(ep.ID),
+			Type:          DerivedFromEdge,
+			Confidence:    1.0,
+			AutoGenerated: true,
+			CreatedAt:     now,
+			Properties: map[string]any{
+				storage.EdgePropReason: "consolidated from session " + session,
+				storage.EdgePropMethod: "consolidation",
+			},
+		}
+		if err := db.storage.CreateEdge(edge); err != nil {
+			return false, fmt.Errorf("linking episode %s: %w", ep.ID, err)
+		}
+		if err := db.setMemoryProperty(ep.ID, ConsolidatedIntoProperty, id); err != nil {
+			return false, err
+		}
+	}
+	result.Memories++
+	result.Episodes += len(approved)
+	return true, nil
+}
+
+// reviewConsolidation has Heimdall QC review the DERIVED_FROM links of a
+// consolidated memory. Without QC, or when QC fails, every episode is
+// approved (fail-open, as for inferred edges).
+func (db *DB) reviewConsolidation(ctx context.Context, id, title, content string, episodes []*Memory) (approved, rejected []*Memory) {
+	var qc *inference.HeimdallQC
+	if db.inference != nil {
+		qc = db.inference.GetHeimdallQC()
+	}
+	if qc == nil {
+		return episodes, nil
+	}
+
+	source := inference.SummarizeNode(id, []string{"Memory"}, map[string]interface{}{
+		"tier": string(TierSemantic), "title": title, "content": content,
+	}, consolidationEpisodeChars)
+	suggestions := make([]inference.EdgeSuggestion, len(episodes))
+	pool := make([]inference.NodeSummary, len(episodes))
+	for i, ep := range episodes {
+		suggestions[i] = inference.EdgeSuggestion{
+			SourceID:   id,
+			TargetID:   ep.ID,
+			Type:       DerivedFromEdge,
+			Confidence: 1.0,
+			Reason:     "memory consolidation",
+			Method:     "consolidation",
+		}
+		pool[i] = inference.SummarizeNode(ep.ID, []string{"Memory"}, map[string]interface{}{"content": ep.Content}, consolidationEpisodeChars)
+	}
+	kept, _, err := qc.ReviewBatch(ctx, source, suggestions, pool)
+	if err != nil {
+		return episodes, nil
+	}
+	keep := make(map[string]bool, len(kept))
+	for _, s := range kept {
+		keep[s.TargetID] = true
+	}
+	for _, ep := range episodes {
+		if keep[ep.ID] {
+			approved = append(approved, ep)
+		} else {
+			rejected = append(rejected, ep)
+		}
+	}
+	return approved, rejected
+}
+
+// setMemoryProperty sets one property of a stored memory.
+func (db *DB) setMemoryProperty(id, key string, value any) error {
+	node, err := db.storage.GetNode(storage.NodeID(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil // Deleted meanwhile
+	}
+	if err != nil {
+		return fmt.Errorf("reading memory %s: %w", id, err)
+	}
+	props := make(map[string]any, len(node.Properties)+1)
+	for k, v := range node.Properties {
+		props[k] = v
+	}
+	props[key] = value
+	node.Properties = props
+	if err := db.storage.UpdateNode(node); err != nil {
+		return fmt.Errorf("updating memory %s: %w", id, err)
+	}
+	return nil
+}
+
+// consolidationPrompt renders tmpl to ask the SLM to consolidate a
+// session's episodes. The episodes are fenced as untrusted data.
+func consolidationPrompt(tmpl prompts.Template, session string, episodes []*Memory) (string, error) {
+	var sb strings.Builder
+	for i, ep := range episodes {
+		text := ep.Content
+		if runes := []rune(text); len(runes) > consolidationEpisodeChars {
+			text = string(runes[:consolidationEpisodeChars]) + "…"
+		}
+		block, _ := promptguard.Fence(fmt.Sprintf("episode %d, %s", i+1, ep.CreatedAt.Format(time.RFC3339)), text)
+		sb.WriteString(block + "\n")
+	}
+	return tmpl.Render(map[string]string{
+		"session":    session,
+		"data_rules": promptguard.DataRules,
+		"episodes":   sb.String(),
+	})
+}
+
+// consolidationSchema is the answer parseConsolidation reads.
+var consolidationSchema = &grammar.Schema{Type: grammar.TypeObject, Properties: []grammar.Property{
+	{Name: "title", Schema: &grammar.Schema{Type: grammar.TypeString}},
+	{Name: "content", Schema: &grammar.Schema{Type: grammar.TypeString}},
+}}
+
+// consolidationContext returns ctx carrying the grammar of
+// consolidationSchema, which a MemorySummarizer reads with
+// grammar.FromContext.
+func consolidationContext(ctx context.Context) context.Context {
+	g, err := grammar.Build(consolidationSchema)
+	if err != nil {
+		return ctx
+	}
+	return grammar.NewContext(ctx, g)
+}
+
+// parseConsolidation reads the JSON object in the SLM's answer, ignoring any
+// text around it.
+func parseConsolidation(answer string) (title, content string, err error) {
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return "", "", fmt.Errorf("SLM answer has no JSON object: %.80q", answer)
+	}
+	var parsed struct {
+		Title   string `json:"title"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &parsed); err != nil {
+		return "", "", fmt.Errorf("SLM answer is not valid JSON: %w", err)
+	}
+	content = strings.TrimSpace(parsed.Content)
+	if content == "" {
+		return "", "", fmt.Errorf("SLM answer has no content")
+	}
+	return strings.TrimSpace(parsed.Title), content, nil
+}
+
+// StartMemoryConsolidation consolidates memories every interval until the
+// database is closed, replacing any earlier schedule.
+func (db *DB) StartMemoryConsolidation(interval time.Duration, config *ConsolidationConfig) error {
+	if interval <= 0 {
+		return fmt.Errorf("%w: consolidation interval must be positive", ErrInvalidInput)
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+
+	db.scheduleMu.Lock()
+	defer db.scheduleMu.Unlock()
+	if db.consolidationStop != nil {
+		db.consolidationStop()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	db.consolidationStop = func() {
+		cancel()
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			result, err := db.consolidateMemories(ctx, config)
+			switch {
+			case err != nil && ctx.Err() == nil && !errors.Is(err, ErrConsolidationRunning):
+				log.Printf("⚠️  Memory consolidation failed: %v", err)
+			case err == nil && result.Memories > 0:
+				log.Printf("🧠 Consolidated %d episodes of %d sessions into %d memories (%d rejected)",
+					result.Episodes, result.Sessions, result.Memories, result.Rejected)
+			}
+		}
+	}()
+	return nil
+}
+
+// stopMemoryConsolidation stops the consolidation schedule, if any.
+func (db *DB) stopMemoryConsolidation() {
+	db.scheduleMu.Lock()
+	stop := db.consolidationStop
+	db.consolidationStop = nil
+	db.scheduleMu.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// RetrieveMemories finds memories for a query (Graph-RAG). Hybrid search
+// (vector and BM25, or BM25 alone without an embedder) finds up to Limit
+// memories; each brings the memories linked to it by DERIVED_FROM, scored
+// at half of its score. Hits are ordered by score.
+func (db *DB) RetrieveMemories(ctx context.Context, query string, options *MemoryRetrieval) ([]*MemoryHit, error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
+	return db.retrieveMemories(ctx, query, options)
+}
+
+func (db *DB) retrieveMemories(ctx context.Context, query string, options *MemoryRetrieval) ([]*MemoryHit, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("%w: query is required", ErrInvalidInput)
+	}
+	if options == nil {
+		options = &MemoryRetrieval{}
+	}
+	limit := options.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	embedding, err := db.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("embedding query: %w", err)
+	}
+	if db.searchService == nil {
+		return nil, fmt.Errorf("search service not initialized")
+	}
+	// Search past the limit, as session filtering drops results.
+	opts := search.GetAdaptiveRRFConfig(query)
+	opts.Limit = limit * 4
+	opts.Types = []string{"Memory"}
+	found, err := db.searchService.Search(ctx, query, embedding, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make(map[string]*MemoryHit)
+	var direct []*MemoryHit
+	for _, r := range found.Results {
+		if len(direct) == limit {
+			break
+		}
+		mem, ok := db.retrievedMemory(r.ID, options.SessionID)
+		if !ok {
+			continue
+		}
+		hit := &MemoryHit{Memory: mem, Score: r.Score}
+		hits[mem.ID] = hit
+		direct = append(direct, hit)
+	}
+
+	for _, hit := range direct {
+		for _, id := range db.derivedFromLinks(hit.Memory.ID) {
+			score := hit.Score * relatedMemoryWeight
+			if existing, ok := hits[id]; ok {
+				if existing.Via != "" && existing.Score < score {
+					existing.Score, existing.Via = score, hit.Memory.ID
+				}
+				continue
+			}
+			mem, ok := db.retrievedMemory(id, options.SessionID)
+			if !ok {
+				continue
+			}
+			hits[id] = &MemoryHit{Memory: mem, Score: score, Via: hit.Memory.ID}
+		}
+	}
+
+	result := make([]*MemoryHit, 0, len(hits))
+	for _, hit := range hits {
+		result = append(result, hit)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].Memory.ID < result[j].Memory.ID
+	})
+	return result, nil
+}
+
+// retrievedMemory loads a memory for retrieval, without its embedding. It
+// reports false for non-memories and memories of other sessions.
+func (db *DB) retrievedMemory(id, sessionID string) (*Memory, bool) {
+	node, err := db.storage.GetNode(storage.NodeID(id))
+	if err != nil || node == nil || !nodeHasLabel(node.Labels, "Memory") {
+		return nil, false
+	}
+	if session, _ := node.Properties[SessionIDProperty].(string); sessionID != "" && session != sessionID {
+		return nil, false
+	}
+	loaded := *node
+	loaded.Properties = db.decryptProperties(node.Properties)
+	loaded.Embedding = nil
+	return nodeToMemory(&loaded), true
+}
+
+// derivedFromLinks returns the memories linked to id by DERIVED_FROM in
+// either direction.
+func (db *DB) derivedFromLinks(id string) []string {
+	var ids []string
+	if out, err := db.storage.GetOutgoingEdges(storage.NodeID(id)); err == nil {
+		for _, e := range out {
+			if e.Type == DerivedFromEdge {
+				ids = append(ids, string(e.EndNode))
+			}
+		}
+	}
+	if in, err := db.storage.GetIncomingEdges(storage.NodeID(id)); err == nil {
+		for _, e := range in {
+			if e.Type == DerivedFromEdge {
+				ids = append(ids, string(e.StartNode))
+			}
+		}
+	}
+	return ids
+}
+
+// agentMemory adapts the DB to cypher.AgentMemory for the nornicdb.memory.*
+// procedures. ExecuteCypher holds db.mu, so it calls the unlocked methods.
+type agentMemory struct {
+	db *DB
+}
+
+func (m agentMemory) AppendEpisode(ctx context.Context, sessionID, content string, properties map[string]interface{}) (map[string]interface{}, error) {
+	mem, err := m.db.appendEpisode(sessionID, content, properties)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"id":         mem.ID,
+		"session":    sessionID,
+		"created_at": mem.CreatedAt.Format(time.RFC3339),
+	}, nil
+}
+
+func (m agentMemory) ConsolidateMemories(ctx context.Context, options cypher.MemoryConsolidationOptions) (map[string]interface{}, error) {
+	result, err := m.db.consolidateMemories(ctx, &ConsolidationConfig{
+		SessionID:   options.Session,
+		MinAge:      options.MinAge,
+		MinEpisodes: options.MinEpisodes,
+		MaxEpisodes: options.MaxEpisodes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"sessions":    int64(result.Sessions),
+		"episodes":    int64(result.Episodes),
+		"memories":    int64(result.Memories),
+		"rejected":    int64(result.Rejected),
+		"failed":      int64(result.Failed),
+		"duration_ms": result.Duration.Milliseconds(),
+	}, nil
+}
+
+func (m agentMemory) RetrieveMemories(ctx context.Context, query string, options cypher.MemoryRetrievalOptions) ([]map[string]interface{}, error) {
+	hits, err := m.db.retrieveMemories(ctx, query, &MemoryRetrieval{SessionID: options.Session, Limit: options.Limit})
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]interface{}, len(hits))
+	for i, hit := range hits {
+		session, _ := hit.Memory.Properties[SessionIDProperty].(string)
+		rows[i] = map[string]interface{}{
+			"id":      hit.Memory.ID,
+			"tier":    string(hit.Memory.Tier),
+			"title":   hit.Memory.Title,
+			"content": hit.Memory.Content,
+			"session": session,
+			"score":   hit.Score,
+			"via":     hit.Via,
+		}
+	}
+	return rows, nil
+}
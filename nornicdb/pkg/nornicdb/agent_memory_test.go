@@ -0,0 +1,283 @@
+package nornicdb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/config"
+	"github.com/orneryd/nornicdb/pkg/heimdall/grammar"
+	"github.com/orneryd/nornicdb/pkg/heimdall/promptguard"
+	"github.com/orneryd/nornicdb/pkg/heimdall/prompts"
+	"github.com/orneryd/nornicdb/pkg/inference"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSummarizer answers consolidation prompts and records them.
+type fakeSummarizer struct {
+	mu       sync.Mutex
+	answer   string
+	err      error
+	prompts  []string
+	grammars []string
+}
+
+func (f *fakeSummarizer) summarize(ctx context.Context, prompt string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prompts = append(f.prompts, prompt)
+	f.grammars = append(f.grammars, grammar.FromContext(ctx))
+	return f.answer, f.err
+}
+
+func (f *fakeSummarizer) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.prompts)
+}
+
+func appendEpisodes(t *testing.T, db *DB, session string, contents ...string) []string {
+	t.Helper()
+	ids := make([]string, len(contents))
+	for i, content := range contents {
+		mem, err := db.AppendEpisode(context.Background(), session, content, map[string]any{"agent": "ui", "tier": "PROCEDURAL"})
+		require.NoError(t, err)
+		ids[i] = mem.ID
+	}
+	return ids
+}
+
+func TestAgentMemory(t *testing.T) {
+	db, err := Open("", nil)
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	episodes := appendEpisodes(t, db, "s1",
+		"User prefers dark mode in the editor",
+		"User switched the editor theme to dark",
+		"User asked about keyboard shortcuts")
+	appendEpisodes(t, db, "s2", "User opened a billing ticket")
+
+	node, err := db.storage.GetNode(storage.NodeID(episodes[0]))
+	require.NoError(t, err)
+	assert.Equal(t, string(TierEpisodic), node.Properties["tier"], "tier cannot be overridden")
+	assert.Equal(t, "s1", node.Properties[SessionIDProperty])
+	assert.Equal(t, "ui", node.Properties["agent"])
+
+	_, err = db.AppendEpisode(ctx, "", "no session", nil)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = db.ConsolidateMemories(ctx, nil)
+	assert.ErrorIs(t, err, ErrSummarizerNotConfigured)
+
+	slm := &fakeSummarizer{answer: "Sure!\n" + `{"title": "Editor preferences", "content": "The user prefers a dark editor theme."}`}
+	db.SetMemorySummarizer(slm.summarize)
+
+	t.Run("consolidate", func(t *testing.T) {
+		_, err := db.ConsolidateMemories(ctx, &ConsolidationConfig{MinAge: time.Hour})
+		require.NoError(t, err)
+		assert.Zero(t, slm.calls(), "episodes are too young")
+
+		result, err := db.ConsolidateMemories(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Sessions, "s2 has too few episodes")
+		assert.Equal(t, 3, result.Episodes)
+		assert.Equal(t, 1, result.Memories)
+		assert.Zero(t, result.Rejected)
+
+		require.Len(t, slm.prompts, 1)
+		prompt := slm.prompts[0]
+		assert.Contains(t, prompt, promptguard.DataRules)
+		assert.Contains(t, prompt, promptguard.BeginData+" episode 1")
+		assert.Less(t, strings.Index(prompt, "dark mode"), strings.Index(prompt, "keyboard"), "oldest first")
+		assert.Contains(t, slm.grammars[0], `"\"content\""`, "the answer is constrained to the consolidation schema")
+
+		edges, err := db.storage.GetIncomingEdges(storage.NodeID(episodes[0]))
+		require.NoError(t, err)
+		require.Len(t, edges, 1)
+		assert.Equal(t, DerivedFromEdge, edges[0].Type)
+		semantic, err := db.storage.GetNode(edges[0].StartNode)
+		require.NoError(t, err)
+		assert.Equal(t, string(TierSemantic), semantic.Properties["tier"])
+		assert.Equal(t, "Editor preferences", semantic.Properties["title"])
+		assert.Equal(t, "s1", semantic.Properties[SessionIDProperty])
+		for _, id := range episodes {
+			ep, err := db.storage.GetNode(storage.NodeID(id))
+			require.NoError(t, err)
+			assert.Equal(t, string(semantic.ID), ep.Properties[ConsolidatedIntoProperty])
+		}
+
+		result, err = db.ConsolidateMemories(ctx, nil)
+		require.NoError(t, err)
+		assert.Zero(t, result.Memories, "episodes are consolidated once")
+	})
+
+	t.Run("retrieve", func(t *testing.T) {
+		hits, err := db.RetrieveMemories(ctx, "keyboard shortcuts", &MemoryRetrieval{Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, hits, 2, "the episode found and its semantic memory")
+		assert.Equal(t, episodes[2], hits[0].Memory.ID)
+		assert.Empty(t, hits[0].Via)
+		assert.Equal(t, TierSemantic, hits[1].Memory.Tier)
+		assert.Equal(t, episodes[2], hits[1].Via)
+		assert.InDelta(t, hits[0].Score*relatedMemoryWeight, hits[1].Score, 1e-9)
+
+		hits, err = db.RetrieveMemories(ctx, "billing ticket", &MemoryRetrieval{SessionID: "s1"})
+		require.NoError(t, err)
+		for _, hit := range hits {
+			assert.Equal(t, "s1", hit.Memory.Properties[SessionIDProperty])
+		}
+
+		_, err = db.RetrieveMemories(ctx, " ", nil)
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+
+	t.Run("procedures", func(t *testing.T) {
+		slm.answer = `{"title": "Billing", "content": "The user has an open billing ticket."}`
+		_, err := db.ExecuteCypher(ctx, "CALL nornicdb.memory.append('s2', 'User asked for a refund')", nil)
+		require.NoError(t, err)
+		result, err := db.ExecuteCypher(ctx, "CALL nornicdb.memory.consolidate({session: 's2'})", nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 1)
+		assert.Equal(t, []interface{}{int64(1), int64(2), int64(1)}, result.Rows[0][:3])
+
+		result, err = db.ExecuteCypher(ctx, "CALL nornicdb.memory.retrieve('refund', {session: 's2', limit: 1})", nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 2)
+		assert.Equal(t, "User asked for a refund", result.Rows[0][3])
+		assert.Equal(t, string(TierSemantic), result.Rows[1][1])
+		assert.Equal(t, "Billing", result.Rows[1][2])
+	})
+
+	t.Run("unusable answer", func(t *testing.T) {
+		appendEpisodes(t, db, "s3", "first", "second")
+		slm.answer = "I cannot help with that"
+		result, err := db.ConsolidateMemories(ctx, &ConsolidationConfig{SessionID: "s3"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Failed)
+		assert.Zero(t, result.Memories)
+
+		slm.answer, slm.err = "", errors.New("model not loaded")
+		result, err = db.ConsolidateMemories(ctx, &ConsolidationConfig{SessionID: "s3"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Failed, "episodes are retried")
+		slm.err = nil
+	})
+
+	t.Run("heimdall qc", func(t *testing.T) {
+		defer config.WithAutoTLPLLMQCEnabled()()
+		qcConfig := inference.DefaultHeimdallQCConfig()
+		qcConfig.CacheDecisions = false
+		db.SetHeimdallQC(inference.NewHeimdallQC(func(ctx context.Context, prompt string) (string, error) {
+			return `{"approved":[0],"rejected":[1],"reasoning":"second is unrelated"}`, nil
+		}, qcConfig))
+		defer db.SetHeimdallQC(nil)
+
+		ids := appendEpisodes(t, db, "s4", "User likes tea", "Weather was sunny")
+		slm.answer = `{"title": "Drinks", "content": "The user likes tea."}`
+		result, err := db.ConsolidateMemories(ctx, &ConsolidationConfig{SessionID: "s4"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Episodes)
+		assert.Equal(t, 1, result.Rejected)
+
+		rejected, err := db.storage.GetNode(storage.NodeID(ids[1]))
+		require.NoError(t, err)
+		assert.Equal(t, true, rejected.Properties[consolidationRejectedProperty])
+		assert.NotContains(t, rejected.Properties, ConsolidatedIntoProperty)
+
+		appendEpisodes(t, db, "s4", "User likes green tea")
+		result, err = db.ConsolidateMemories(ctx, &ConsolidationConfig{SessionID: "s4"})
+		require.NoError(t, err)
+		assert.Zero(t, result.Memories, "rejected episodes are not retried")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := db.ConsolidateMemories(ctx, &ConsolidationConfig{MinEpisodes: 5, MaxEpisodes: 3})
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+}
+
+func TestStartMemoryConsolidation(t *testing.T) {
+	db, err := Open("", nil)
+	require.NoError(t, err)
+	slm := &fakeSummarizer{answer: `{"title": "t", "content": "c"}`}
+	db.SetMemorySummarizer(slm.summarize)
+	appendEpisodes(t, db, "s1", "one", "two")
+
+	assert.ErrorIs(t, db.StartMemoryConsolidation(0, nil), ErrInvalidInput)
+	require.NoError(t, db.StartMemoryConsolidation(10*time.Millisecond, nil))
+	require.Eventually(t, func() bool { return slm.calls() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, db.Close(), "stops the schedule")
+	calls := slm.calls()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, calls, slm.calls())
+	assert.ErrorIs(t, db.StartMemoryConsolidation(time.Second, nil), ErrClosed)
+}
+
+func TestConsolidationPromptVersions(t *testing.T) {
+	db, err := Open("", nil)
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	reg := prompts.NewRegistry()
+	require.NoError(t, RegisterMemoryPrompts(reg))
+	require.NoError(t, reg.Register(prompts.Template{
+		Name:    PromptConsolidation,
+		Version: "v2",
+		Text:    "Summarize session {{session}}.\n{{data_rules}}\n{{episodes}}",
+	}))
+	require.NoError(t, reg.Activate(PromptConsolidation, "v2"))
+	db.SetMemoryPrompts(reg)
+
+	slm := &fakeSummarizer{answer: `{"title": "t", "content": "c"}`}
+	db.SetMemorySummarizer(slm.summarize)
+	appendEpisodes(t, db, "s1", "one", "two")
+	appendEpisodes(t, db, "s2", "three", "four")
+	_, err = db.ConsolidateMemories(ctx, &ConsolidationConfig{SessionID: "s1"})
+	require.NoError(t, err)
+	require.Len(t, slm.prompts, 1)
+	assert.True(t, strings.HasPrefix(slm.prompts[0], "Summarize session s1."))
+	assert.Contains(t, slm.prompts[0], promptguard.DataRules)
+
+	slm.answer = "no JSON here"
+	_, err = db.ConsolidateMemories(ctx, &ConsolidationConfig{SessionID: "s2"})
+	require.NoError(t, err)
+
+	var v2 prompts.VersionStats
+	for _, vs := range reg.Stats() {
+		if vs.Version == "v2" {
+			v2 = vs
+		}
+	}
+	assert.Equal(t, int64(2), v2.Requests)
+	assert.Equal(t, int64(1), v2.ParseFailures)
+	assert.Equal(t, int64(2), v2.Judged)
+	assert.Equal(t, 1.0, v2.ApprovalRate, "no QC: every episode is linked")
+
+	// The built-in template renders the same prompt as before registries
+	prompt, err := consolidationPrompt(consolidationTemplate, "s9", nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(prompt, "Consolidate the memories an AI agent recorded during session s9. "))
+	assert.True(t, strings.HasSuffix(prompt, promptguard.DataRules+"\n\n\nAnswer with only a JSON object: "+
+		`{"title": "<short title>", "content": "<the memory, at most five sentences>"}`))
+}
+
+func TestParseConsolidation(t *testing.T) {
+	title, content, err := parseConsolidation("```json\n{\"title\": \" T \", \"content\": \" C \"}\n```")
+	require.NoError(t, err)
+	assert.Equal(t, "T", title)
+	assert.Equal(t, "C", content)
+
+	for _, answer := range []string{"none", "{broken", `{"title": "only a title"}`} {
+		_, _, err := parseConsolidation(answer)
+		assert.Error(t, err, answer)
+	}
+}
@@ -0,0 +1,75 @@
+package nornicdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkWrite(t *testing.T) {
+	db, err := Open("", nil)
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	res, err := db.BulkWrite(ctx,
+		[]*storage.Node{
+			{ID: "u1", Labels: []string{"User"}, Properties: map[string]interface{}{"name": "Ada", "tier": "free"}},
+			{ID: "u2", Labels: []string{"User"}, Properties: map[string]interface{}{"name": "Bob", "embedding": "ignored"}},
+		},
+		[]*storage.Edge{{ID: "f1", StartNode: "u1", EndNode: "u2", Type: "FOLLOWS", Properties: map[string]interface{}{"since": int64(2020)}}})
+	require.NoError(t, err)
+	assert.Equal(t, BulkWriteResult{NodesCreated: 2, RelationshipsCreated: 1}, res)
+
+	// Resending upserts: labels and properties merge, nil removes
+	res, err = db.BulkWrite(ctx,
+		[]*storage.Node{
+			{ID: "u1", Labels: []string{"Admin"}, Properties: map[string]interface{}{"tier": nil, "age": int64(36)}},
+			{Labels: []string{"User"}, Properties: map[string]interface{}{"name": "Cy"}},
+		},
+		[]*storage.Edge{
+			{ID: "f1", StartNode: "u1", EndNode: "u2", Type: "FOLLOWS", Properties: map[string]interface{}{"weight": 0.5}},
+			{StartNode: "u2", EndNode: "u1", Type: "FOLLOWS"},
+		})
+	require.NoError(t, err)
+	assert.Equal(t, BulkWriteResult{NodesCreated: 1, NodesUpdated: 1, RelationshipsCreated: 1, RelationshipsUpdated: 1}, res)
+
+	u1, err := db.storage.GetNode("u1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"User", "Admin"}, u1.Labels)
+	assert.Equal(t, map[string]interface{}{"name": "Ada", "age": int64(36)}, u1.Properties)
+	u2, err := db.storage.GetNode("u2")
+	require.NoError(t, err)
+	assert.NotContains(t, u2.Properties, "embedding")
+	f1, err := db.storage.GetEdge("f1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"since": int64(2020), "weight": 0.5}, f1.Properties)
+
+	count, err := db.storage.NodeCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	edges, err := db.storage.EdgeCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), edges)
+}
+
+func TestBulkWrite_Atomic(t *testing.T) {
+	db, err := Open("", nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.BulkWrite(context.Background(),
+		[]*storage.Node{{ID: "a", Labels: []string{"User"}}},
+		[]*storage.Edge{{StartNode: "a", EndNode: "missing", Type: "FOLLOWS"}})
+	assert.ErrorContains(t, err, "relationship 0")
+
+	_, err = db.storage.GetNode("a")
+	assert.ErrorIs(t, err, storage.ErrNotFound, "nothing from a failed batch is stored")
+
+	db.Close()
+	_, err = db.BulkWrite(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, ErrClosed)
+}
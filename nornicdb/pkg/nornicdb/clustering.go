@@ -0,0 +1,395 @@
+// Package nornicdb provides clustering of node embeddings into topics.
+//
+// ClusterEmbeddings groups nodes by their embeddings and stores the result
+// in the graph, so agents can organize and browse memories by topic:
+//
+//	(:Memory {cluster_id: 3})        every clustered node
+//	(:Cluster {cluster_id: 3, ...})  one summary node per cluster, whose
+//	                                 embedding is the cluster's centroid
+//
+// Two algorithms are available. k-means runs on the GPU cluster index when
+// a GPU manager is set, and on the CPU otherwise; it scales to millions of
+// nodes but puts every node in one of K clusters. HDBSCAN runs on the CPU,
+// finds the number of clusters itself and leaves outliers unclustered; it
+// compares every pair of nodes, so it is limited to hdbscanMaxPoints.
+//
+// Each run replaces the previous one: the old :Cluster nodes are deleted
+// and nodes no longer in a cluster lose their cluster_id.
+package nornicdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/cypher"
+	"github.com/orneryd/nornicdb/pkg/gpu"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// ClusterLabel labels the summary node of each embedding cluster.
+const ClusterLabel = "Cluster"
+
+// ClusterIDProperty holds the cluster of a clustered node and the ID of a
+// :Cluster node.
+const ClusterIDProperty = "cluster_id"
+
+// Clustering algorithms.
+const (
+	ClusterKMeans  = "kmeans"
+	ClusterHDBSCAN = "hdbscan"
+)
+
+// clusterRepresentatives is how many of the members nearest the centroid a
+// :Cluster node lists, for labelling the cluster by its typical content.
+const clusterRepresentatives = 5
+
+// ErrClusteringRunning is returned when embeddings are already being
+// clustered.
+var ErrClusteringRunning = errors.New("embedding clustering already running")
+
+// EmbeddingClusterConfig controls ClusterEmbeddings.
+type EmbeddingClusterConfig struct {
+	Algorithm      string // ClusterKMeans (default) or ClusterHDBSCAN
+	K              int    // k-means clusters (0 = sqrt(n/2), at least 10)
+	MinClusterSize int    // HDBSCAN smallest cluster (default: 5)
+	Label          string // Cluster only nodes with this label ("" = all)
+}
+
+// EmbeddingClusterResult summarizes a clustering run.
+type EmbeddingClusterResult struct {
+	Algorithm string        `json:"algorithm"`
+	Nodes     int           `json:"nodes"`    // Nodes with embeddings clustered
+	Clusters  int           `json:"clusters"` // :Cluster nodes created
+	Noise     int           `json:"noise"`    // Nodes HDBSCAN left unclustered
+	Skipped   int           `json:"skipped"`  // Embeddings of less common dimensions
+	Duration  time.Duration `json:"duration"`
+}
+
+// ClusterEmbeddings clusters the embeddings of all nodes, or of those with
+// config.Label, assigns each node's cluster to its cluster_id property and
+// creates a :Cluster node per cluster with the cluster_id, the algorithm,
+// the number of members (size), the IDs of the members nearest the centroid
+// (representatives) and the centroid as its embedding. Only the
+// embeddings with the dimensions most nodes have are clustered; the others
+// are skipped. Only one run happens at a time.
+//
+// Example:
+//
+//	result, err := db.ClusterEmbeddings(ctx, &EmbeddingClusterConfig{
+//		Algorithm: ClusterHDBSCAN,
+//		Label:     "Memory",
+//	})
+//
+//	// MATCH (c:Cluster), (m:Memory {cluster_id: c.cluster_id})
+//	// RETURN c.cluster_id, c.size, collect(m.title)[..5]
+func (db *DB) ClusterEmbeddings(ctx context.Context, config *EmbeddingClusterConfig) (*EmbeddingClusterResult, error) {
+	if config == nil {
+		config = &EmbeddingClusterConfig{}
+	}
+	algorithm := strings.ToLower(config.Algorithm)
+	switch algorithm {
+	case "":
+		algorithm = ClusterKMeans
+	case ClusterKMeans, ClusterHDBSCAN:
+	default:
+		return nil, fmt.Errorf("unknown clustering algorithm %q (use %s or %s)", config.Algorithm, ClusterKMeans, ClusterHDBSCAN)
+	}
+	if config.K < 0 || config.MinClusterSize < 0 {
+		return nil, fmt.Errorf("k and min cluster size must not be negative")
+	}
+
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return nil, ErrClosed
+	}
+	engine := db.storage
+	manager, _ := db.gpuManager.(*gpu.Manager)
+	db.mu.RUnlock()
+
+	if !db.clusterMu.TryLock() {
+		return nil, ErrClusteringRunning
+	}
+	defer db.clusterMu.Unlock()
+
+	start := time.Now()
+	result := &EmbeddingClusterResult{Algorithm: algorithm}
+
+	// Collect the embeddings to cluster, grouped by dimensions, the previous
+	// :Cluster nodes and the nodes holding a cluster_id from the previous run.
+	type embeddings struct {
+		ids     []storage.NodeID
+		vectors [][]float32
+	}
+	byDims := make(map[int]*embeddings)
+	var oldClusters, previous []storage.NodeID
+	err := storage.StreamNodesWithFallback(ctx, engine, 1000, func(node *storage.Node) error {
+		if nodeHasLabel(node.Labels, ClusterLabel) {
+			if _, ok := node.Properties[ClusterIDProperty]; ok {
+				oldClusters = append(oldClusters, node.ID)
+			}
+			return nil
+		}
+		if _, ok := node.Properties[ClusterIDProperty]; ok {
+			previous = append(previous, node.ID)
+		}
+		if len(node.Embedding) == 0 || (config.Label != "" && !nodeHasLabel(node.Labels, config.Label)) {
+			return nil
+		}
+		group := byDims[len(node.Embedding)]
+		if group == nil {
+			group = &embeddings{}
+			byDims[len(node.Embedding)] = group
+		}
+		group.ids = append(group.ids, node.ID)
+		group.vectors = append(group.vectors, append([]float32(nil), node.Embedding...))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("embedding scan failed: %w", err)
+	}
+	// Cluster the dimensions most nodes have.
+	var ids []storage.NodeID
+	var vectors [][]float32
+	for _, group := range byDims {
+		if len(group.ids) > len(ids) {
+			result.Skipped += len(ids)
+			ids, vectors = group.ids, group.vectors
+		} else {
+			result.Skipped += len(group.ids)
+		}
+	}
+	result.Nodes = len(ids)
+
+	var labels []int
+	switch algorithm {
+	case ClusterKMeans:
+		labels, err = kmeansLabels(manager, ids, vectors, config.K)
+	case ClusterHDBSCAN:
+		if len(vectors) > hdbscanMaxPoints {
+			return nil, fmt.Errorf("hdbscan clusters at most %d nodes, found %d: use %s or a label", hdbscanMaxPoints, len(vectors), ClusterKMeans)
+		}
+		minSize := config.MinClusterSize
+		if minSize == 0 {
+			minSize = 5
+		}
+		labels, err = hdbscan(ctx, vectors, minSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s clustering failed: %w", algorithm, err)
+	}
+
+	// Mean of each cluster's members, in cluster order.
+	var centroids [][]float32
+	var sizes []int
+	for i, label := range labels {
+		if label == hdbscanNoise {
+			result.Noise++
+			continue
+		}
+		for label >= len(centroids) {
+			centroids = append(centroids, make([]float32, len(vectors[i])))
+			sizes = append(sizes, 0)
+		}
+		for d, v := range vectors[i] {
+			centroids[label][d] += v
+		}
+		sizes[label]++
+	}
+	for c, centroid := range centroids {
+		if sizes[c] == 0 {
+			continue // a k-means cluster that lost all its members
+		}
+		for d := range centroid {
+			centroid[d] /= float32(sizes[c])
+		}
+	}
+
+	representatives := nearestMembers(ids, vectors, labels, centroids, clusterRepresentatives)
+
+	// Replace the previous run.
+	for _, id := range oldClusters {
+		if err := engine.DeleteNode(id); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("deleting cluster %s: %w", id, err)
+		}
+		if db.searchService != nil {
+			_ = db.searchService.RemoveNode(id)
+		}
+	}
+	assigned := make(map[storage.NodeID]int, len(ids))
+	for i, id := range ids {
+		if labels[i] != hdbscanNoise {
+			assigned[id] = labels[i]
+		}
+	}
+	for _, id := range previous {
+		if _, ok := assigned[id]; !ok {
+			assigned[id] = hdbscanNoise
+		}
+	}
+	for id, label := range assigned {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := setNodeCluster(engine, id, label); err != nil {
+			return nil, fmt.Errorf("assigning cluster to %s: %w", id, err)
+		}
+	}
+
+	now := time.Now()
+	for c, centroid := range centroids {
+		if sizes[c] == 0 {
+			continue
+		}
+		props := map[string]interface{}{
+			ClusterIDProperty: int64(c),
+			"algorithm":       algorithm,
+			"size":            int64(sizes[c]),
+			"clustered_at":    now.Format(time.RFC3339),
+			"representatives": representatives[c],
+		}
+		if config.Label != "" {
+			props["member_label"] = config.Label
+		}
+		node := &storage.Node{
+			ID:         storage.NodeID(generateID("cluster")),
+			Labels:     []string{ClusterLabel},
+			Properties: props,
+			Embedding:  centroid,
+			CreatedAt:  now,
+		}
+		if err := engine.CreateNode(node); err != nil {
+			return nil, fmt.Errorf("creating cluster %d: %w", c, err)
+		}
+		if db.searchService != nil {
+			_ = db.searchService.IndexNode(node)
+		}
+		result.Clusters++
+	}
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// kmeansLabels clusters vectors with the GPU cluster index; manager may be
+// nil for CPU-only clustering.
+func kmeansLabels(manager *gpu.Manager, ids []storage.NodeID, vectors [][]float32, k int) ([]int, error) {
+	labels := make([]int, len(ids))
+	if len(ids) == 0 {
+		return labels, nil
+	}
+	kmeansConfig := gpu.DefaultKMeansConfig()
+	if k > 0 {
+		kmeansConfig.NumClusters = k
+		kmeansConfig.AutoK = false
+	}
+	embConfig := gpu.DefaultEmbeddingIndexConfig(len(vectors[0]))
+	embConfig.InitialCap = len(vectors)
+	index := gpu.NewClusterIndex(manager, embConfig, kmeansConfig)
+	defer index.Release()
+
+	nodeIDs := make([]string, len(ids))
+	for i, id := range ids {
+		nodeIDs[i] = string(id)
+	}
+	if err := index.AddBatch(nodeIDs, vectors); err != nil {
+		return nil, err
+	}
+	if err := index.Cluster(); err != nil {
+		return nil, err
+	}
+	assignments := index.Assignments()
+	for i, id := range nodeIDs {
+		labels[i] = assignments[id]
+	}
+	return labels, nil
+}
+
+// setNodeCluster sets a node's cluster_id, or removes it for hdbscanNoise.
+func setNodeCluster(engine storage.Engine, id storage.NodeID, cluster int) error {
+	node, err := engine.GetNode(id)
+	if errors.Is(err, storage.ErrNotFound) || err == nil && node == nil {
+		return nil // deleted while clustering
+	}
+	if err != nil {
+		return err
+	}
+	current, had := node.Properties[ClusterIDProperty]
+	if (cluster == hdbscanNoise && !had) || (had && current == int64(cluster)) {
+		return nil
+	}
+	node = copyNodeForEmbedding(node)
+	if node.Properties == nil {
+		node.Properties = make(map[string]interface{})
+	}
+	if cluster == hdbscanNoise {
+		delete(node.Properties, ClusterIDProperty)
+	} else {
+		node.Properties[ClusterIDProperty] = int64(cluster)
+	}
+	return engine.UpdateNode(node)
+}
+
+// embeddingClusterer adapts ClusterEmbeddings for nornicdb.cluster.embeddings.
+type embeddingClusterer struct {
+	db *DB
+}
+
+func (c embeddingClusterer) ClusterEmbeddings(ctx context.Context, options cypher.EmbeddingClusterOptions) (map[string]interface{}, error) {
+	result, err := c.db.ClusterEmbeddings(ctx, &EmbeddingClusterConfig{
+		Algorithm:      options.Algorithm,
+		K:              options.K,
+		MinClusterSize: options.MinClusterSize,
+		Label:          options.Label,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"algorithm":   result.Algorithm,
+		"nodes":       int64(result.Nodes),
+		"clusters":    int64(result.Clusters),
+		"noise":       int64(result.Noise),
+		"skipped":     int64(result.Skipped),
+		"duration_ms": result.Duration.Milliseconds(),
+	}, nil
+}
+
+// nearestMembers returns, per cluster, the IDs of up to n members nearest
+// its centroid, nearest first.
+func nearestMembers(ids []storage.NodeID, vectors [][]float32, labels []int, centroids [][]float32, n int) [][]string {
+	type member struct {
+		idx  int
+		dist float64
+	}
+	nearest := make([][]member, len(centroids))
+	for i, label := range labels {
+		if label == hdbscanNoise {
+			continue
+		}
+		m := member{idx: i, dist: euclidean(vectors[i], centroids[label])}
+		list := nearest[label]
+		pos := sort.Search(len(list), func(j int) bool { return list[j].dist > m.dist })
+		if pos >= n {
+			continue
+		}
+		if len(list) < n {
+			list = append(list, member{})
+		}
+		copy(list[pos+1:], list[pos:])
+		list[pos] = m
+		nearest[label] = list
+	}
+	result := make([][]string, len(centroids))
+	for c, list := range nearest {
+		result[c] = make([]string, len(list))
+		for j, m := range list {
+			result[c][j] = string(ids[m.idx])
+		}
+	}
+	return result
+}
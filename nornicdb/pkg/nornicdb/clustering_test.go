@@ -0,0 +1,176 @@
+package nornicdb
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blobs returns perPoint points around each center, then the outliers.
+func blobs(rnd *rand.Rand, centers [][]float32, perBlob int, outliers ...[]float32) [][]float32 {
+	var vectors [][]float32
+	for _, center := range centers {
+		for i := 0; i < perBlob; i++ {
+			v := make([]float32, len(center))
+			for d := range v {
+				v[d] = center[d] + float32(rnd.NormFloat64()*0.1)
+			}
+			vectors = append(vectors, v)
+		}
+	}
+	return append(vectors, outliers...)
+}
+
+func TestHDBSCAN(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	centers := [][]float32{{0, 0, 0, 0}, {10, 0, 0, 0}, {0, 10, 0, 0}}
+	vectors := blobs(rnd, centers, 20, []float32{50, 50, 50, 50}, []float32{-50, 20, 0, 0})
+
+	labels, err := hdbscan(context.Background(), vectors, 5)
+	require.NoError(t, err)
+	require.Len(t, labels, 62)
+
+	// Each blob is one cluster, numbered in order of first point.
+	for b := 0; b < 3; b++ {
+		for i := b * 20; i < (b+1)*20; i++ {
+			assert.Equal(t, b, labels[i], "point %d", i)
+		}
+	}
+	assert.Equal(t, []int{hdbscanNoise, hdbscanNoise}, labels[60:], "outliers are noise")
+
+	t.Run("too few points", func(t *testing.T) {
+		labels, err := hdbscan(context.Background(), vectors[:3], 5)
+		require.NoError(t, err)
+		assert.Equal(t, []int{hdbscanNoise, hdbscanNoise, hdbscanNoise}, labels)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := hdbscan(ctx, vectors, 5)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestClusterEmbeddings(t *testing.T) {
+	db, err := Open("", nil)
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	rnd := rand.New(rand.NewSource(2))
+	centers := [][]float32{{1, 0, 0, 0}, {0, 1, 0, 0}}
+	vectors := blobs(rnd, centers, 10, []float32{-5, -5, 5, 5})
+	for i, v := range vectors {
+		require.NoError(t, db.storage.CreateNode(&storage.Node{
+			ID:         storage.NodeID(fmt.Sprintf("m%02d", i)),
+			Labels:     []string{"Memory"},
+			Embedding:  v,
+			Properties: map[string]interface{}{"title": fmt.Sprintf("memory %d", i)},
+		}))
+	}
+	require.NoError(t, db.storage.CreateNode(&storage.Node{ID: "other", Labels: []string{"Doc"}, Embedding: []float32{1, 0, 0, 0}}))
+	require.NoError(t, db.storage.CreateNode(&storage.Node{ID: "wide", Labels: []string{"Memory"}, Embedding: make([]float32, 8)}))
+
+	clusterOf := func(id string) (int64, bool) {
+		node, err := db.storage.GetNode(storage.NodeID(id))
+		require.NoError(t, err)
+		c, ok := node.Properties[ClusterIDProperty].(int64)
+		return c, ok
+	}
+	clusterNodes := func() []*storage.Node {
+		nodes, err := db.storage.GetNodesByLabel(ClusterLabel)
+		require.NoError(t, err)
+		return nodes
+	}
+
+	t.Run("kmeans", func(t *testing.T) {
+		result, err := db.ClusterEmbeddings(ctx, &EmbeddingClusterConfig{K: 3, Label: "Memory"})
+		require.NoError(t, err)
+		assert.Equal(t, ClusterKMeans, result.Algorithm)
+		assert.Equal(t, 21, result.Nodes)
+		assert.Equal(t, 1, result.Skipped, "fewer nodes with these dimensions")
+		assert.Zero(t, result.Noise)
+
+		first, ok := clusterOf("m00")
+		require.True(t, ok)
+		for i := 1; i < 10; i++ {
+			c, _ := clusterOf(fmt.Sprintf("m%02d", i))
+			assert.Equal(t, first, c)
+		}
+		second, _ := clusterOf("m10")
+		assert.NotEqual(t, first, second)
+		outlier, ok := clusterOf("m20")
+		require.True(t, ok)
+		assert.NotContains(t, []int64{first, second}, outlier, "k-means clusters every node")
+		_, ok = clusterOf("other")
+		assert.False(t, ok, "outside the label")
+
+		var sizes int64
+		for _, node := range clusterNodes() {
+			assert.Equal(t, ClusterKMeans, node.Properties["algorithm"])
+			assert.Equal(t, "Memory", node.Properties["member_label"])
+			assert.Len(t, node.Embedding, 4, "centroid")
+			reps := node.Properties["representatives"].([]string)
+			assert.Len(t, reps, min(5, int(node.Properties["size"].(int64))))
+			sizes += node.Properties["size"].(int64)
+		}
+		assert.Len(t, clusterNodes(), 3)
+		assert.Equal(t, int64(21), sizes)
+	})
+
+	t.Run("hdbscan replaces kmeans", func(t *testing.T) {
+		result, err := db.ClusterEmbeddings(ctx, &EmbeddingClusterConfig{Algorithm: "HDBSCAN", MinClusterSize: 4})
+		require.NoError(t, err)
+		assert.Equal(t, ClusterHDBSCAN, result.Algorithm)
+		assert.Equal(t, 22, result.Nodes, "all labels")
+		assert.Equal(t, 2, result.Clusters)
+
+		nodes := clusterNodes()
+		require.Len(t, nodes, 2, "previous clusters deleted")
+		for _, node := range nodes {
+			assert.Equal(t, ClusterHDBSCAN, node.Properties["algorithm"])
+			// The centroid is one of the centers ("other" sits on the first).
+			near := 0
+			for _, center := range centers {
+				if euclidean(center, node.Embedding) < 0.1 {
+					near++
+				}
+			}
+			assert.Equal(t, 1, near, "centroid %v", node.Embedding)
+		}
+		_, ok := clusterOf("m20")
+		assert.False(t, ok, "noise loses its previous cluster")
+	})
+
+	t.Run("procedure", func(t *testing.T) {
+		result, err := db.ExecuteCypher(ctx, "CALL nornicdb.cluster.embeddings('kmeans', {k: 3, label: 'Memory'})", nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 1)
+		assert.Equal(t, []interface{}{"kmeans", int64(21), int64(3), int64(0), int64(1)}, result.Rows[0][:5])
+		_, ok := clusterOf("m20")
+		assert.True(t, ok)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := db.ClusterEmbeddings(ctx, &EmbeddingClusterConfig{Algorithm: "dbscan"})
+		assert.Error(t, err)
+		_, err = db.ClusterEmbeddings(ctx, &EmbeddingClusterConfig{K: -1})
+		assert.Error(t, err)
+	})
+}
+
+func TestNearestMembers(t *testing.T) {
+	ids := []storage.NodeID{"a", "b", "c", "d", "e"}
+	vectors := [][]float32{{3}, {1}, {10}, {2}, {0}}
+	labels := []int{0, 0, 1, 0, hdbscanNoise}
+	centroids := [][]float32{{0}, {10}}
+
+	assert.Equal(t, [][]string{{"b", "d"}, {"c"}}, nearestMembers(ids, vectors, labels, centroids, 2))
+	assert.Equal(t, [][]string{{"b", "d", "a"}, {"c"}}, nearestMembers(ids, vectors, labels, centroids, 5))
+}
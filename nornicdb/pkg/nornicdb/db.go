@@ -132,21 +132,30 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/orneryd/nornicdb/pkg/cdc"
 	featureflags "github.com/orneryd/nornicdb/pkg/config"
 	"github.com/orneryd/nornicdb/pkg/cypher"
 	"github.com/orneryd/nornicdb/pkg/decay"
+	"github.com/orneryd/nornicdb/pkg/edgesync"
 	"github.com/orneryd/nornicdb/pkg/embed"
 	"github.com/orneryd/nornicdb/pkg/encryption"
 	"github.com/orneryd/nornicdb/pkg/gpu"
+	"github.com/orneryd/nornicdb/pkg/heimdall/prompts"
+	"github.com/orneryd/nornicdb/pkg/idempotency"
 	"github.com/orneryd/nornicdb/pkg/inference"
 	"github.com/orneryd/nornicdb/pkg/math/vector"
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/sample"
 	"github.com/orneryd/nornicdb/pkg/search"
+	"github.com/orneryd/nornicdb/pkg/secrets"
 	"github.com/orneryd/nornicdb/pkg/storage"
 	"github.com/orneryd/nornicdb/pkg/temporal"
 )
@@ -319,6 +328,9 @@ type Config struct {
 	AutoLinksEnabled             bool          `yaml:"auto_links_enabled"`
 	AutoLinksSimilarityThreshold float64       `yaml:"auto_links_similarity_threshold"`
 	AutoLinksCoAccessWindow      time.Duration `yaml:"auto_links_co_access_window"`
+	// Ensemble weights by scorer (similarity, co_access, common_neighbors,
+	// adamic_adar, embedding); nil uses inference.DefaultScorerWeights()
+	AutoLinksScorerWeights map[string]float64 `yaml:"auto_links_scorer_weights"`
 
 	// Parallel execution
 	ParallelEnabled      bool `yaml:"parallel_enabled"`        // Enable parallel query execution
@@ -332,9 +344,23 @@ type Config struct {
 	// Encryption (data-at-rest) - AES-256-GCM with key rotation
 	// Disabled by default for performance. Enable for HIPAA/GDPR/SOC2 compliance.
 	EncryptionEnabled  bool     `yaml:"encryption_enabled"`  // Enable AES-256-GCM encryption for sensitive fields
-	EncryptionPassword string   `yaml:"encryption_password"` // Master password for key derivation (env: NORNICDB_ENCRYPTION_PASSWORD)
+	EncryptionPassword string   `yaml:"encryption_password"` // Master password or secret reference (env: NORNICDB_ENCRYPTION_PASSWORD)
 	EncryptionFields   []string `yaml:"encryption_fields"`   // Fields to encrypt (nil = default PHI/PII fields)
 
+	// Vector quantization (IVF-PQ) - compressed embeddings with exact rerank
+	// Cuts embedding memory ~16x for large collections at a small recall cost.
+	VectorQuantizationEnabled bool `yaml:"vector_quantization_enabled"`  // Compress embeddings once 4096+ are indexed
+	VectorQuantizationNProbe  int  `yaml:"vector_quantization_nprobe"`   // Inverted lists scanned per query (0 = default 8)
+	VectorQuantizationRerankK int  `yaml:"vector_quantization_rerank_k"` // Candidates re-scored exactly (0 = default 100)
+
+	// Semantic search result cache - near-identical query embeddings reuse results
+	SearchCacheSize int           `yaml:"search_cache_size"` // Cached responses (0 = disabled)
+	SearchCacheTTL  time.Duration `yaml:"search_cache_ttl"`  // Entry lifetime (0 = default 5m)
+
+	// Cold-start priming - the most frequent queries are saved on close and
+	// pre-parsed, with their labels touched in storage, on the next open
+	WorkloadPrimeQueries int `yaml:"workload_prime_queries"` // Query shapes saved and primed (0 = disabled)
+
 	// Server
 	BoltPort int `yaml:"bolt_port"`
 	HTTPPort int `yaml:"http_port"`
@@ -382,6 +408,7 @@ func DefaultConfig() *Config {
 		EncryptionEnabled:            false,                 // Encryption disabled by default (opt-in)
 		EncryptionPassword:           "",                    // Must be set if encryption enabled
 		EncryptionFields:             nil,                   // nil = use default PHI fields
+		WorkloadPrimeQueries:         cypher.DefaultWorkloadPrimeQueries,
 		BoltPort:                     7687,
 		HTTPPort:                     7474,
 	}
@@ -426,11 +453,16 @@ type DB struct {
 	// Internal components
 	storage        storage.Engine
 	wal            *storage.WAL // Write-ahead log for durability
+	cdc            *cdc.Manager // Change data capture over the WAL (nil without WAL)
 	decay          *decay.Manager
 	inference      *inference.Engine
 	cypherExecutor *cypher.StorageExecutor
 	gpuManager     interface{} // *gpu.Manager - interface to avoid circular import
 
+	// GPU ranking for db.index.vector.queryNodes (see gpu_search.go)
+	gpuVectorSearch *gpuVectorSearch
+	gpuScheduler    *gpu.Scheduler
+
 	// Search service (uses pre-computed embeddings from Mimir)
 	searchService *search.Service
 
@@ -438,6 +470,21 @@ type DB struct {
 	embedQueue        *EmbedQueue
 	embedWorkerConfig *EmbedWorkerConfig // Configurable via ENV vars
 
+	// Background re-embedding after a model change (see reembed.go)
+	reembedMu  sync.Mutex
+	reembedJob *reembedJob
+
+	// Serializes embedding clustering runs (see clustering.go)
+	clusterMu sync.Mutex
+
+	// Agent memory consolidation (see agent_memory.go)
+	memoryMu          sync.RWMutex // Guards memorySummarizer and memoryPrompts
+	memorySummarizer  MemorySummarizer
+	memoryPrompts     *prompts.Registry
+	consolidateMu     sync.Mutex // Serializes consolidation runs
+	scheduleMu        sync.Mutex // Guards consolidationStop
+	consolidationStop func()
+
 	// Encryption for data-at-rest (PHI/PII fields)
 	encryptor     *encryption.Encryptor
 	encryptFields *encryption.FieldEncryptionConfig
@@ -789,9 +836,36 @@ func Open(dataDir string, config *Config) (*DB, error) {
 		fmt.Println("⚠️  Using in-memory storage (data will not persist)")
 	}
 
+	// Change data capture: consumer groups read the WAL (see package cdc)
+	if db.wal != nil {
+		manager, err := cdc.NewManager(db.wal, cdc.NewGraphOffsetStore(db.storage), cdc.Config{})
+		if err != nil {
+			fmt.Printf("⚠️  Change data capture disabled: %v\n", err)
+		} else {
+			db.cdc = manager
+		}
+	}
+
 	// Initialize Cypher executor
 	db.cypherExecutor = cypher.NewStorageExecutor(db.storage)
 
+	// Prime the executor with the workload seen before the last shutdown
+	if dataDir != "" && config.WorkloadPrimeQueries > 0 {
+		profile, err := cypher.LoadWorkloadProfile(workloadProfilePath(dataDir))
+		if err != nil {
+			fmt.Printf("⚠️  Failed to load workload profile: %v\n", err)
+		} else if profile != nil {
+			db.bgWg.Add(1)
+			go func() {
+				defer db.bgWg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				defer cancel()
+				stats := db.cypherExecutor.PrimeFromProfile(ctx, profile, config.WorkloadPrimeQueries)
+				fmt.Printf("✅ Primed %d queries and %d labels from workload profile\n", stats.Queries, stats.Labels)
+			}()
+		}
+	}
+
 	// Load plugins from configured directory (NORNICDB_PLUGINS_DIR)
 	pluginsDir := os.Getenv("NORNICDB_PLUGINS_DIR")
 	if pluginsDir != "" {
@@ -838,10 +912,26 @@ func Open(dataDir string, config *Config) (*DB, error) {
 			CoAccessEnabled:     true,
 			CoAccessWindow:      config.AutoLinksCoAccessWindow,
 			CoAccessMinCount:    3,
+			CoAccessHalfLife:    inference.DefaultConfig().CoAccessHalfLife,
 			TransitiveEnabled:   true,
 			TransitiveMinConf:   0.5,
+			ScorerWeights:       config.AutoLinksScorerWeights,
 		}
 		db.inference = inference.New(inferConfig)
+		db.inference.SetEmbeddingLookup(func(id string) ([]float32, bool) {
+			node, err := db.storage.GetNode(storage.NodeID(id))
+			if err != nil || len(node.Embedding) == 0 {
+				return nil, false
+			}
+			return node.Embedding, true
+		})
+
+		// Restore co-access counts so the signal survives restarts
+		if dataDir != "" {
+			if err := db.inference.CoAccess().Load(coAccessPath(dataDir)); err != nil {
+				fmt.Printf("⚠️  Failed to load co-access counts: %v\n", err)
+			}
+		}
 
 		// Wire up TopologyIntegration if Auto-TLP (Temporal Link Prediction) feature flag is enabled
 		// This enables automatic relationship creation based on similarity, co-access, etc.
@@ -878,6 +968,11 @@ func Open(dataDir string, config *Config) (*DB, error) {
 
 	// Initialize search service (uses pre-computed embeddings from Mimir)
 	db.searchService = search.NewService(db.storage)
+	db.cypherExecutor.SetVectorIndexRegistry(searchVectorIndexes{db.searchService})
+	db.cypherExecutor.SetGPUDiagnostics(&gpuDiagnostics{})
+	db.cypherExecutor.SetEmbeddingClusterer(embeddingClusterer{db})
+	db.cypherExecutor.SetAgentMemory(agentMemory{db})
+	db.cypherExecutor.SetQCEvaluator(qcEvaluator{db})
 
 	// Enable k-means clustering if feature flag is set
 	// This provides 10-50x speedup on large datasets (10K+ embeddings)
@@ -887,6 +982,21 @@ func Open(dataDir string, config *Config) (*DB, error) {
 		fmt.Println("🔬 K-means clustering enabled for accelerated semantic search")
 	}
 
+	// Enable PQ compression of embeddings (trained after indexes are built)
+	if config.VectorQuantizationEnabled {
+		db.searchService.EnableQuantization(nil, quantizationConfig(config))
+		fmt.Println("🗜️  Vector quantization enabled (IVF-PQ with exact rerank)")
+	}
+
+	if config.SearchCacheSize > 0 {
+		cacheConfig := search.DefaultResultCacheConfig()
+		cacheConfig.MaxSize = config.SearchCacheSize
+		if config.SearchCacheTTL > 0 {
+			cacheConfig.TTL = config.SearchCacheTTL
+		}
+		db.searchService.EnableResultCache(cacheConfig)
+	}
+
 	// Initialize encryption if enabled (AES-256-GCM with PBKDF2 key derivation)
 	if config.EncryptionEnabled {
 		// Get password from config or environment (env takes precedence for security)
@@ -894,6 +1004,13 @@ func Open(dataDir string, config *Config) (*DB, error) {
 		if envPass := os.Getenv("NORNICDB_ENCRYPTION_PASSWORD"); envPass != "" {
 			password = envPass
 		}
+		// The password may be a secret reference (env:, file:, vault:)
+		resolved, err := secrets.Resolve(context.Background(), password)
+		if err != nil {
+			db.closeInternal()
+			return nil, fmt.Errorf("resolving encryption password: %w", err)
+		}
+		password = resolved
 
 		if password == "" {
 			// Clean up resources before returning error
@@ -963,6 +1080,15 @@ func Open(dataDir string, config *Config) (*DB, error) {
 		defer db.bgWg.Done()
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 		defer cancel()
+
+		// Load the persisted vector index so GPU buffers don't have to be
+		// rebuilt from the store; BuildIndexes then only applies changes
+		if dataDir != "" && db.searchService.IsClusteringEnabled() {
+			if err := db.searchService.LoadClusterIndex(vectorIndexPath(dataDir)); err != nil && !os.IsNotExist(err) {
+				fmt.Printf("⚠️  Ignoring persisted vector index: %v\n", err)
+			}
+		}
+
 		if err := db.searchService.BuildIndexes(ctx); err != nil {
 			fmt.Printf("⚠️  Failed to build search indexes: %v\n", err)
 		} else {
@@ -975,6 +1101,12 @@ func Open(dataDir string, config *Config) (*DB, error) {
 					fmt.Printf("⚠️  K-means clustering skipped: %v\n", err)
 				}
 			}
+
+			if config.VectorQuantizationEnabled {
+				if err := db.searchService.TriggerQuantization(); err != nil {
+					fmt.Printf("⚠️  Vector quantization skipped: %v\n", err)
+				}
+			}
 		}
 	}()
 
@@ -1063,6 +1195,33 @@ func (db *DB) LoadFromExport(ctx context.Context, exportDir string) (*LoadResult
 	}, nil
 }
 
+// LoadSample stores a generated sample dataset (see package sample).
+// Call BuildSearchIndexes afterwards to make its embeddings searchable.
+func (db *DB) LoadSample(ctx context.Context, ds *sample.Dataset) (*LoadResult, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil, ErrClosed
+	}
+
+	if err := sample.Load(db.storage, ds); err != nil {
+		return nil, fmt.Errorf("loading %s dataset: %w", ds.Name, err)
+	}
+	// Write through the async cache, so the dataset is stored on return
+	if ae, ok := db.storage.(*storage.AsyncEngine); ok {
+		if err := ae.Flush(); err != nil {
+			return nil, fmt.Errorf("flushing %s dataset: %w", ds.Name, err)
+		}
+	}
+
+	return &LoadResult{
+		NodesLoaded:      len(ds.Nodes),
+		EdgesLoaded:      len(ds.Edges),
+		EmbeddingsLoaded: ds.Embeddings(),
+	}, nil
+}
+
 // LoadResult holds the result of a data load operation.
 type LoadResult struct {
 	NodesLoaded      int `json:"nodes_loaded"`
@@ -1112,11 +1271,46 @@ func (db *DB) closeInternal() error {
 		db.decay.Stop()
 	}
 
+	// Stop any re-embedding job before its embedder goes away
+	db.reembedMu.Lock()
+	if db.reembedJob != nil {
+		db.reembedJob.stop()
+	}
+	db.reembedMu.Unlock()
+
+	// Stop scheduled memory consolidation before storage closes
+	db.stopMemoryConsolidation()
+
 	// Close embed queue gracefully (processes remaining batch)
 	if db.embedQueue != nil {
 		db.embedQueue.Close()
 	}
 
+	// Persist the clustering vector index for fast startup
+	if db.searchService != nil && db.searchService.IsClusteringEnabled() && db.config != nil && db.config.DataDir != "" {
+		if err := db.searchService.SaveClusterIndex(vectorIndexPath(db.config.DataDir)); err != nil {
+			errs = append(errs, fmt.Errorf("vector index save: %w", err))
+		}
+	}
+
+	// Persist the most frequent query shapes for cold-start priming
+	if db.cypherExecutor != nil && db.config != nil && db.config.DataDir != "" && db.config.WorkloadPrimeQueries > 0 {
+		if err := db.cypherExecutor.Workload().SaveProfile(workloadProfilePath(db.config.DataDir), db.config.WorkloadPrimeQueries); err != nil {
+			errs = append(errs, fmt.Errorf("workload profile save: %w", err))
+		}
+	}
+
+	// Persist aggregated co-access counts
+	if db.inference != nil && db.config != nil && db.config.DataDir != "" {
+		if err := db.inference.CoAccess().Save(coAccessPath(db.config.DataDir)); err != nil {
+			errs = append(errs, fmt.Errorf("co-access save: %w", err))
+		}
+	}
+
+	if db.gpuVectorSearch != nil {
+		db.gpuVectorSearch.release()
+	}
+
 	// Close WAL first to ensure all writes are flushed
 	if db.wal != nil {
 		if err := db.wal.Close(); err != nil {
@@ -1243,6 +1437,10 @@ func (db *DB) Store(ctx context.Context, mem *Memory) (*Memory, error) {
 		suggestions, err := db.inference.OnStore(ctx, mem.ID, mem.Embedding)
 		if err == nil {
 			for _, suggestion := range suggestions {
+				// Provenance travels with the edge for CALL db.tlp.explain
+				props := suggestion.Provenance.Properties()
+				props[storage.EdgePropReason] = suggestion.Reason
+				props[storage.EdgePropMethod] = suggestion.Method
 				edge := &storage.Edge{
 					ID:            storage.EdgeID(generateID("edge")),
 					StartNode:     storage.NodeID(suggestion.SourceID),
@@ -1251,10 +1449,7 @@ func (db *DB) Store(ctx context.Context, mem *Memory) (*Memory, error) {
 					Confidence:    suggestion.Confidence,
 					AutoGenerated: true,
 					CreatedAt:     now,
-					Properties: map[string]any{
-						"reason": suggestion.Reason,
-						"method": suggestion.Method,
-					},
+					Properties:    props,
 				}
 				_ = db.storage.CreateEdge(edge) // Best effort
 			}
@@ -1455,6 +1650,7 @@ func (db *DB) Cypher(ctx context.Context, query string, params map[string]any) (
 			}
 		}
 	}
+	result.Release() // Values were copied into the maps
 
 	return results, nil
 }
@@ -1761,6 +1957,144 @@ func (db *DB) Stats() DBStats {
 	return stats
 }
 
+// SetStorageQuota limits the nodes, relationships and bytes the database
+// may hold. Writes that would exceed a limit fail with an error matching
+// storage.ErrQuotaExceeded; deletes are always allowed. The zero Quota
+// removes all limits. Set it before serving writes, as current usage is
+// counted when it is set.
+func (db *DB) SetStorageQuota(q storage.Quota) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+	qe, ok := db.storage.(storage.QuotaEngine)
+	if !ok {
+		return fmt.Errorf("storage quotas are not supported by %T", db.storage)
+	}
+	return qe.SetQuota(q)
+}
+
+// SetWriteRetryPolicy sets how auto-commit writes flagged retryable are
+// retried when they abort on a write conflict. The zero policy disables
+// retries. Set it before serving writes.
+func (db *DB) SetWriteRetryPolicy(p cypher.RetryPolicy) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.cypherExecutor != nil {
+		db.cypherExecutor.SetRetryPolicy(p)
+	}
+}
+
+// SetRedactor masks PII in query text listed by CALL dbms.listQueries() and
+// in the properties of change events delivered to CDC consumer groups. A
+// nil redactor disables masking.
+func (db *DB) SetRedactor(r *redact.Redactor) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.cypherExecutor != nil {
+		db.cypherExecutor.SetRedactor(r)
+	}
+	if db.cdc != nil {
+		db.cdc.SetRedactor(r)
+	}
+}
+
+// SetVirtualLabels makes Cypher queries resolve the resolver's virtual
+// labels from external data sources, e.g. a *federation.Registry. A nil
+// resolver disables them.
+func (db *DB) SetVirtualLabels(resolver cypher.VirtualLabelResolver) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.cypherExecutor != nil {
+		db.cypherExecutor.SetVirtualLabels(resolver)
+	}
+}
+
+// OpenSyncReplica returns the offline sync replica of the database (see
+// package edgesync). Its state is kept in sync/state.json under the data
+// directory, or in memory for an in-memory database. Changes applied from
+// peers are indexed for search and queued for embedding. A nil resolver
+// uses edgesync.LastWriterWins.
+func (db *DB) OpenSyncReplica(replicaID string, resolver edgesync.Resolver) (*edgesync.Replica, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrClosed
+	}
+	opts := edgesync.Options{ReplicaID: replicaID, Resolver: resolver, OnApplied: db.onSyncApplied}
+	if dir := db.DataDir(); dir != "" {
+		opts.StateFile = filepath.Join(dir, "sync", "state.json")
+	}
+	return edgesync.Open(db.storage, opts)
+}
+
+// onSyncApplied updates the search index for a node written by a peer.
+func (db *DB) onSyncApplied(c edgesync.Change) {
+	if c.Kind != edgesync.KindNode || db.searchService == nil {
+		return
+	}
+	if c.Deleted {
+		_ = db.searchService.RemoveNode(storage.NodeID(c.ID))
+		return
+	}
+	if node, err := db.storage.GetNode(storage.NodeID(c.ID)); err == nil {
+		_ = db.searchService.IndexNode(node)
+	}
+	if db.embedQueue != nil {
+		db.embedQueue.Enqueue(c.ID)
+	}
+}
+
+// PersistIdempotencyKeys keeps the results recorded by store in the graph
+// (see idempotency.GraphBackend), so a write retried after a restart or a
+// failover to a replica is still applied once.
+func (db *DB) PersistIdempotencyKeys(store *idempotency.Store) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+	return store.SetBackend(idempotency.NewGraphBackend(db.storage))
+}
+
+// CDC returns the change data capture consumer groups, or nil for
+// databases without a WAL (in-memory databases).
+func (db *DB) CDC() *cdc.Manager {
+	return db.cdc
+}
+
+// StorageQuota returns the storage quota, current usage and rejection
+// counts, or false if no quota is set.
+func (db *DB) StorageQuota() (storage.QuotaStatus, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if qe, ok := db.storage.(storage.QuotaEngine); ok && !db.closed {
+		return qe.QuotaStatus()
+	}
+	return storage.QuotaStatus{}, false
+}
+
+// PropertySchema returns the property schema registry that writes are
+// checked against, or nil if the storage engine has none. It starts empty
+// in off mode; see storage.PropertySchema.
+func (db *DB) PropertySchema() *storage.PropertySchema {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if pe, ok := db.storage.(storage.PropertySchemaEngine); ok {
+		return pe.PropertySchema()
+	}
+	return nil
+}
+
 // SetGPUManager sets the GPU manager for vector search acceleration.
 // Uses interface{} to avoid circular import with gpu package.
 // If clustering is already enabled (via feature flag), this upgrades it to use GPU.
@@ -1776,6 +2110,16 @@ func (db *DB) SetGPUManager(manager interface{}) {
 			fmt.Println("🚀 K-means clustering upgraded to GPU-accelerated mode")
 		}
 	}
+
+	// Rerank quantized search candidates on the GPU
+	if gpuMgr, ok := manager.(*gpu.Manager); ok && db.searchService != nil && db.config != nil && db.config.VectorQuantizationEnabled {
+		db.searchService.EnableQuantization(gpuMgr, quantizationConfig(db.config))
+	}
+
+	// Score auto-link candidate pools on the GPU
+	if gpuMgr, ok := manager.(*gpu.Manager); ok && db.inference != nil {
+		db.inference.SetGPUManager(gpuMgr)
+	}
 }
 
 // GetGPUManager returns the GPU manager if set.
@@ -1786,6 +2130,41 @@ func (db *DB) GetGPUManager() interface{} {
 	return db.gpuManager
 }
 
+// WALStats returns write-ahead log statistics. ok is false when the
+// database has no WAL (in-memory databases).
+func (db *DB) WALStats() (stats storage.WALStats, ok bool) {
+	if db.wal == nil {
+		return storage.WALStats{}, false
+	}
+	return db.wal.Stats(), true
+}
+
+// WAL returns the write-ahead log, or nil for databases without one
+// (in-memory databases). Readers watch it for writes with Sequence and
+// EntriesAfter.
+func (db *DB) WAL() *storage.WAL {
+	return db.wal
+}
+
+// DataDir returns the data directory, or "" for in-memory databases.
+func (db *DB) DataDir() string {
+	if db.config == nil {
+		return ""
+	}
+	return db.config.DataDir
+}
+
+// SearchCacheStats returns semantic search result cache statistics.
+// All fields are zero when the cache is disabled.
+func (db *DB) SearchCacheStats() search.ResultCacheStats {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.searchService == nil {
+		return search.ResultCacheStats{}
+	}
+	return db.searchService.ResultCacheStats()
+}
+
 // TriggerSearchClustering runs k-means clustering on search embeddings.
 // Call this after bulk data loading to enable cluster-accelerated search.
 // Returns nil if clustering is not enabled or there are too few embeddings.
@@ -1817,6 +2196,21 @@ func (db *DB) IsAsyncWritesEnabled() bool {
 type CypherResult struct {
 	Columns []string        `json:"columns"`
 	Rows    [][]interface{} `json:"rows"`
+	Retries int             `json:"retries,omitempty"` // Write conflict retries before commit
+
+	source *cypher.ExecuteResult // Owner of pooled Rows
+}
+
+// Release returns the rows to the object pool when the executor built them
+// there. Call it at most once, after the last use of Rows; see
+// cypher.ExecuteResult.Release. Optional: unreleased rows are collected by GC.
+func (r *CypherResult) Release() {
+	if r == nil || r.source == nil {
+		return
+	}
+	r.source.Release()
+	r.source = nil
+	r.Rows = nil
 }
 
 // ExecuteCypher runs a Cypher query and returns structured results.
@@ -1834,10 +2228,228 @@ func (db *DB) ExecuteCypher(ctx context.Context, query string, params map[string
 		return nil, err
 	}
 
-	return &CypherResult{
+	// Nodes returned together feed co-access inference
+	if db.inference != nil {
+		db.inference.OnQuery(ctx, resultNodeIDs(result.Rows))
+	}
+
+	return newCypherResult(result), nil
+}
+
+// newCypherResult wraps an executor result.
+func newCypherResult(result *cypher.ExecuteResult) *CypherResult {
+	cr := &CypherResult{
 		Columns: result.Columns,
 		Rows:    result.Rows,
-	}, nil
+		source:  result,
+	}
+	if result.Stats != nil {
+		cr.Retries = result.Stats.Retries
+	}
+	return cr
+}
+
+// PrepareCypher parses and analyzes a query once for repeated execution with
+// ExecutePrepared. Bolt clients use CALL db.prepare(query) instead.
+//
+// Example:
+//
+//	stmt, err := db.PrepareCypher("MATCH (n:Person {id: $id}) RETURN n.name")
+//	for _, id := range ids {
+//		result, err := db.ExecutePrepared(ctx, stmt, map[string]interface{}{"id": id})
+//	}
+//	db.DeallocatePrepared(stmt.ID)
+func (db *DB) PrepareCypher(query string) (*cypher.PreparedStatement, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrClosed
+	}
+	return db.cypherExecutor.Prepare(query)
+}
+
+// ExecutePrepared runs a prepared query with the given parameters.
+func (db *DB) ExecutePrepared(ctx context.Context, stmt *cypher.PreparedStatement, params map[string]interface{}) (*CypherResult, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrClosed
+	}
+
+	result, err := db.cypherExecutor.ExecutePrepared(ctx, stmt, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if db.inference != nil {
+		db.inference.OnQuery(ctx, resultNodeIDs(result.Rows))
+	}
+
+	return newCypherResult(result), nil
+}
+
+// DeallocatePrepared releases a prepared query. Returns false if the handle
+// is unknown.
+func (db *DB) DeallocatePrepared(id string) bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return false
+	}
+	return db.cypherExecutor.Deallocate(id)
+}
+
+// RunningQueries returns the Cypher queries currently executing, oldest
+// first.
+func (db *DB) RunningQueries() []*cypher.RunningQuery {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil
+	}
+	return db.cypherExecutor.RunningQueries()
+}
+
+// KillQuery cancels a running query by ID. Returns false if no query has the
+// ID. Read queries stop with cypher.ErrQueryKilled; writes run to completion.
+func (db *DB) KillQuery(id string) bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return false
+	}
+	return db.cypherExecutor.KillQuery(id)
+}
+
+// resultNodeIDs returns the IDs of the nodes in query result rows, including
+// nodes inside lists.
+func resultNodeIDs(rows [][]interface{}) []string {
+	var ids []string
+	var visit func(v interface{})
+	visit = func(v interface{}) {
+		switch val := v.(type) {
+		case *storage.Node:
+			ids = append(ids, string(val.ID))
+		case map[string]interface{}:
+			if id, ok := val["_nodeId"].(string); ok {
+				ids = append(ids, id)
+			} else if _, isNode := val["properties"]; isNode && val["labels"] != nil {
+				// Nodes inside collected lists: {id, labels, properties}
+				if id, ok := val["id"].(string); ok {
+					ids = append(ids, id)
+				}
+			}
+		case []interface{}:
+			for _, item := range val {
+				visit(item)
+			}
+		}
+	}
+	for _, row := range rows {
+		for _, v := range row {
+			visit(v)
+		}
+	}
+	return ids
+}
+
+// coAccessPath returns where co-access counts are persisted.
+func coAccessPath(dataDir string) string {
+	return dataDir + "/coaccess.json"
+}
+
+// workloadProfilePath returns where the workload profile is persisted.
+func workloadProfilePath(dataDir string) string {
+	return dataDir + "/workload.json"
+}
+
+// vectorIndexPath returns where the clustering vector index is persisted.
+func vectorIndexPath(dataDir string) string {
+	return dataDir + "/vectors.idx"
+}
+
+// quantizationConfig returns the PQ parameters from the database config.
+func quantizationConfig(config *Config) *search.PQConfig {
+	pqConfig := search.DefaultPQConfig()
+	if config.VectorQuantizationNProbe > 0 {
+		pqConfig.NProbe = config.VectorQuantizationNProbe
+	}
+	if config.VectorQuantizationRerankK > 0 {
+		pqConfig.RerankK = config.VectorQuantizationRerankK
+	}
+	return &pqConfig
+}
+
+// searchVectorIndexes adapts the search service to cypher.VectorIndexRegistry.
+type searchVectorIndexes struct {
+	svc *search.Service
+}
+
+func (s searchVectorIndexes) CreateVectorIndex(ctx context.Context, name, label, property string, dimensions int, similarity string) error {
+	return s.svc.CreateVectorIndex(ctx, search.VectorIndexSpec{
+		Name:       name,
+		Label:      label,
+		Property:   property,
+		Dimensions: dimensions,
+		Similarity: similarity,
+	})
+}
+
+func (s searchVectorIndexes) DropVectorIndex(name string) bool {
+	return s.svc.DropVectorIndex(name)
+}
+
+func (s searchVectorIndexes) VectorIndexState(name string) (string, float64, bool) {
+	status, ok := s.svc.VectorIndexes().Status(name)
+	return status.State, status.Progress, ok
+}
+
+func (s searchVectorIndexes) AwaitVectorIndex(ctx context.Context, name string) error {
+	err := s.svc.VectorIndexes().Await(ctx, name)
+	if errors.Is(err, search.ErrVectorIndexNotFound) {
+		return nil // Defined in the schema only; built on first query
+	}
+	return err
+}
+
+// gpuDiagnostics adapts gpu.Probe for nornicdb.gpu.probe. The probe is run
+// once, on first use, since devices do not change while the server runs.
+type gpuDiagnostics struct {
+	once   sync.Once
+	probes []map[string]interface{}
+}
+
+func (g *gpuDiagnostics) ProbeGPU() []map[string]interface{} {
+	g.once.Do(func() {
+		for _, b := range gpu.Probe() {
+			devices := make([]interface{}, len(b.Devices))
+			for i, d := range b.Devices {
+				devices[i] = map[string]interface{}{
+					"id":                  d.ID,
+					"name":                d.Name,
+					"vendor":              d.Vendor,
+					"memory_mb":           d.MemoryMB,
+					"compute_capability":  d.ComputeCapability,
+					"driver_version":      d.DriverVersion,
+					"max_work_group_size": d.MaxWorkGroupSize,
+					"features":            d.Features,
+				}
+			}
+			g.probes = append(g.probes, map[string]interface{}{
+				"backend":   string(b.Backend),
+				"compiled":  b.Compiled,
+				"available": b.Available,
+				"reason":    b.Reason,
+				"devices":   devices,
+			})
+		}
+	})
+	return g.probes
 }
 
 // TypedCypherResult holds typed query results.
@@ -2310,6 +2922,54 @@ func (db *DB) ListEdges(ctx context.Context, relType string, limit, offset int)
 	return edges, nil
 }
 
+// StreamNodes calls fn for every node carrying any of labels, or for every
+// node if labels is empty, without loading them all into memory. Properties
+// are decrypted. fn may return storage.ErrIterationStopped to stop early.
+func (db *DB) StreamNodes(ctx context.Context, labels []string, fn func(*storage.Node) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+
+	err := storage.StreamNodesWithFallback(ctx, db.storage, 1000, func(n *storage.Node) error {
+		if len(labels) > 0 && !slices.ContainsFunc(n.Labels, func(l string) bool { return slices.Contains(labels, l) }) {
+			return nil
+		}
+		node := *n
+		node.Properties = db.decryptProperties(n.Properties)
+		return fn(&node)
+	})
+	if err == storage.ErrIterationStopped {
+		return nil
+	}
+	return err
+}
+
+// StreamEdges calls fn for every edge whose type is one of types, or for
+// every edge if types is empty. fn may return storage.ErrIterationStopped to
+// stop early.
+func (db *DB) StreamEdges(ctx context.Context, types []string, fn func(*storage.Edge) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+
+	err := storage.StreamEdgesWithFallback(ctx, db.storage, 1000, func(e *storage.Edge) error {
+		if len(types) > 0 && !slices.Contains(types, e.Type) {
+			return nil
+		}
+		return fn(e)
+	})
+	if err == storage.ErrIterationStopped {
+		return nil
+	}
+	return err
+}
+
 // GetEdge retrieves an edge by ID.
 func (db *DB) GetEdge(ctx context.Context, id string) (*GraphEdge, error) {
 	db.mu.RLock()
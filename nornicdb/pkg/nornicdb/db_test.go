@@ -8,8 +8,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/orneryd/nornicdb/pkg/cypher"
 	"github.com/orneryd/nornicdb/pkg/decay"
 	"github.com/orneryd/nornicdb/pkg/math/vector"
+	"github.com/orneryd/nornicdb/pkg/sample"
 	"github.com/orneryd/nornicdb/pkg/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -934,6 +936,44 @@ func TestExecuteCypher(t *testing.T) {
 		assert.ErrorIs(t, err, ErrClosed)
 	})
 
+	t.Run("executes prepared queries", func(t *testing.T) {
+		db, err := Open(t.TempDir(), nil)
+		require.NoError(t, err)
+
+		stmt, err := db.PrepareCypher("MERGE (n:Counter {name: $name}) RETURN n.name")
+		require.NoError(t, err)
+		for _, name := range []string{"a", "b", "a"} {
+			result, err := db.ExecutePrepared(ctx, stmt, map[string]interface{}{"name": name})
+			require.NoError(t, err)
+			assert.Equal(t, [][]interface{}{{name}}, result.Rows)
+		}
+		result, err := db.ExecuteCypher(ctx, "MATCH (n:Counter) RETURN count(n)", nil)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, result.Rows[0][0])
+
+		assert.True(t, db.DeallocatePrepared(stmt.ID))
+		_, err = db.ExecutePrepared(ctx, stmt, nil)
+		assert.Error(t, err)
+
+		db.Close()
+		_, err = db.PrepareCypher("RETURN 1")
+		assert.ErrorIs(t, err, ErrClosed)
+	})
+
+	t.Run("lists and kills running queries", func(t *testing.T) {
+		db, err := Open(t.TempDir(), nil)
+		require.NoError(t, err)
+
+		result, err := db.ExecuteCypher(ctx, "CALL dbms.listQueries()", nil)
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 1)
+		assert.Empty(t, db.RunningQueries())
+		assert.False(t, db.KillQuery(result.Rows[0][0].(string)))
+
+		db.Close()
+		assert.Nil(t, db.RunningQueries())
+	})
+
 	t.Run("creates and queries nodes", func(t *testing.T) {
 		db, err := Open(t.TempDir(), nil)
 		require.NoError(t, err)
@@ -948,6 +988,26 @@ func TestExecuteCypher(t *testing.T) {
 		require.NoError(t, err)
 		assert.NotNil(t, result)
 	})
+
+	t.Run("feeds co-access tracking and persists it", func(t *testing.T) {
+		dir := t.TempDir()
+		db, err := Open(dir, nil)
+		require.NoError(t, err)
+
+		_, err = db.ExecuteCypher(ctx, "CREATE (a:Doc {id: 'doc-a'}), (b:Doc {id: 'doc-b'})", nil)
+		require.NoError(t, err)
+		result, err := db.ExecuteCypher(ctx, "MATCH (n:Doc) RETURN collect(n) AS docs", nil)
+		require.NoError(t, err)
+		ids := resultNodeIDs(result.Rows)
+		require.Len(t, ids, 2)
+		assert.InDelta(t, 1.0, db.inference.CoAccess().Count(ids[0], ids[1]), 0.01)
+		require.NoError(t, db.Close())
+
+		db, err = Open(dir, nil)
+		require.NoError(t, err)
+		defer db.Close()
+		assert.InDelta(t, 1.0, db.inference.CoAccess().Count(ids[0], ids[1]), 0.01)
+	})
 }
 
 // =============================================================================
@@ -2154,6 +2214,48 @@ func TestHybridSearch(t *testing.T) {
 		t.Logf("HybridSearch with labels returned %d results", len(results))
 	})
 
+	t.Run("routes_to_named_vector_index", func(t *testing.T) {
+		db, err := Open("", nil)
+		require.NoError(t, err)
+		defer db.Close()
+
+		before, err := db.CreateNode(ctx, []string{"Image"}, map[string]interface{}{"clip": []float64{1, 0, 0, 0}, "content": "red square"})
+		require.NoError(t, err)
+		_, err = db.ExecuteCypher(ctx, "CREATE VECTOR INDEX image_idx FOR (n:Image) ON (n.clip) OPTIONS {indexConfig: {`vector.dimensions`: 4}}", nil)
+		require.NoError(t, err)
+		after, err := db.CreateNode(ctx, []string{"Image"}, map[string]interface{}{"clip": []float64{0, 1, 0, 0}, "content": "blue circle"})
+		require.NoError(t, err)
+
+		// A 4-dimensional query embedding finds both the backfilled and the new node
+		results, err := db.HybridSearch(ctx, "square", []float32{1, 0.1, 0, 0}, nil, 10)
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+		assert.Equal(t, before.ID, results[0].Node.ID)
+		results, err = db.HybridSearch(ctx, "circle", []float32{0.1, 1, 0, 0}, nil, 10)
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+		assert.Equal(t, after.ID, results[0].Node.ID)
+	})
+
+	t.Run("repeated_query_served_from_cache", func(t *testing.T) {
+		config := DefaultConfig()
+		config.SearchCacheSize = 10
+		db, err := Open("", config)
+		require.NoError(t, err)
+		defer db.Close()
+		assert.Equal(t, 10, db.SearchCacheStats().MaxSize)
+
+		queryEmbedding := make([]float32, 1024)
+		queryEmbedding[0] = 1
+		for i := 0; i < 3; i++ {
+			_, err := db.HybridSearch(ctx, "test", queryEmbedding, nil, 10)
+			require.NoError(t, err)
+		}
+		stats := db.SearchCacheStats()
+		assert.Equal(t, uint64(1), stats.Misses)
+		assert.Equal(t, uint64(2), stats.Hits)
+	})
+
 	t.Run("hybrid_search_closed_db", func(t *testing.T) {
 		db, err := Open(t.TempDir(), nil)
 		require.NoError(t, err)
@@ -2201,6 +2303,36 @@ func TestLoadFromExport(t *testing.T) {
 	})
 }
 
+func TestLoadSample(t *testing.T) {
+	ctx := context.Background()
+	ds, err := sample.Generate(sample.Options{Dataset: sample.Movies, Dimensions: 16})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	db, err := Open(dir, nil)
+	require.NoError(t, err)
+	result, err := db.LoadSample(ctx, ds)
+	require.NoError(t, err)
+	assert.Equal(t, len(ds.Nodes), result.NodesLoaded)
+	assert.Equal(t, ds.Embeddings(), result.EmbeddingsLoaded)
+	require.NoError(t, db.BuildSearchIndexes(ctx))
+	require.NoError(t, db.Close())
+
+	// The dataset is on disk even though Close followed immediately
+	db, err = Open(dir, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	res, err := db.ExecuteCypher(ctx, "MATCH (m:Movie) RETURN count(m)", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, ds.Embeddings(), res.Rows[0][0])
+
+	_, err = db.LoadSample(ctx, ds)
+	require.NoError(t, err, "reloading overwrites the dataset")
+	db.Close()
+	_, err = db.LoadSample(ctx, ds)
+	assert.Equal(t, ErrClosed, err)
+}
+
 func TestBuildSearchIndexes(t *testing.T) {
 	ctx := context.Background()
 
@@ -2589,3 +2721,25 @@ func TestDeleteUserData_RemovesFromSearchIndex(t *testing.T) {
 		assert.NotNil(t, retrieved, "Other user's data should remain")
 	})
 }
+
+func TestWorkloadProfilePersistence(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	db, err := Open(dir, nil)
+	require.NoError(t, err)
+	_, err = db.ExecuteCypher(ctx, "MATCH (n:Person) RETURN n", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	profile, err := cypher.LoadWorkloadProfile(workloadProfilePath(dir))
+	require.NoError(t, err)
+	require.NotNil(t, profile)
+	require.NotEmpty(t, profile.Queries)
+	assert.Equal(t, "MATCH (n:Person) RETURN n", profile.Queries[0].Example)
+
+	// Reopening primes from the saved profile
+	db, err = Open(dir, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+}
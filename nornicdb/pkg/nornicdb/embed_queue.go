@@ -351,6 +351,7 @@ func (ew *EmbedWorker) processNextBatch() bool {
 		node.Embedding = embedding
 		node.Properties["embedding_model"] = ew.embedder.Model()
 		node.Properties["embedding_dimensions"] = ew.embedder.Dimensions()
+		node.Properties[EmbeddingVersionProperty] = EmbeddingVersion(ew.embedder.Model(), ew.embedder.Dimensions())
 		node.Properties["has_embedding"] = true
 		node.Properties["embedded_at"] = time.Now().Format(time.RFC3339)
 		node.Properties["embedding"] = true // Marker for IS NOT NULL check
@@ -484,6 +485,7 @@ func buildEmbeddingText(properties map[string]interface{}) string {
 		"embedding_skipped":    true,
 		"embedding_model":      true,
 		"embedding_dimensions": true,
+		"embedding_version":    true,
 		"embedded_at":          true,
 		"createdAt":            true,
 		"updatedAt":            true,
@@ -726,6 +728,7 @@ func (ew *EmbedWorker) createFileChunksWithEmbeddings(parentFile *storage.Node,
 				"embedding":            embeddingAsInterface, // PROPERTY like Mimir!
 				"embedding_dimensions": ew.embedder.Dimensions(),
 				"embedding_model":      ew.embedder.Model(),
+				"embedding_version":    EmbeddingVersion(ew.embedder.Model(), ew.embedder.Dimensions()),
 				"type":                 "file_chunk",
 				"indexed_date":         time.Now().Format(time.RFC3339),
 				"filePath":             filePath, // Mimir sets c.filePath = f.path
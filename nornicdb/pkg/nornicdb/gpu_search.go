@@ -0,0 +1,286 @@
+// Package nornicdb provides GPU ranking for Cypher vector queries.
+//
+// db.index.vector.queryNodes scores every candidate node of an index. With
+// EnableGPUVectorSearch, cosine indexes are ranked on the backend chosen by
+// gpu.AutoSelect instead. Each index keeps its embeddings on the device
+// between queries; a query uploads only embeddings that changed since the
+// last one. Queries fall back to CPU, in this order, when:
+//
+//  1. The device buffer cannot be uploaded or the GPU search fails: the
+//     index is scored from its CPU copy.
+//  2. The index cannot be updated or searched at all, or the query needs
+//     more VRAM than the scheduler allows one query: the executor scores the
+//     candidates itself, as it does without a GPU.
+//
+// Queries share the device through a gpu.Scheduler. Each index is split
+// into chunks of the scheduler's ChunkRows embeddings, searched one kernel
+// launch at a time, so a query over a large index yields the device to
+// other sessions between chunks instead of holding it until it completes.
+// Sessions are told apart by the principal the query was admitted for.
+package nornicdb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/orneryd/nornicdb/pkg/gpu"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+)
+
+// gpuVectorSearch adapts a gpu.Accelerator to cypher.VectorSearchAccelerator,
+// with one chunked GPU embedding index per Cypher vector index.
+type gpuVectorSearch struct {
+	accel     *gpu.Accelerator
+	scheduler *gpu.Scheduler
+
+	mu      sync.Mutex
+	indexes map[string]*gpuVectorIndex // nil after release
+}
+
+// gpuVectorIndex serializes syncing and searching the chunks of one index,
+// so a launch never sees the embeddings of a concurrent query's candidates.
+type gpuVectorIndex struct {
+	mu         sync.Mutex
+	dimensions int
+	chunks     []*gpu.GPUEmbeddingIndex
+}
+
+func newGPUVectorSearch(accel *gpu.Accelerator, scheduler *gpu.Scheduler) *gpuVectorSearch {
+	return &gpuVectorSearch{accel: accel, scheduler: scheduler, indexes: make(map[string]*gpuVectorIndex)}
+}
+
+// indexFor returns the GPU index for a Cypher index, dropping its chunks if
+// the query dimensions changed. It returns nil after release.
+func (g *gpuVectorSearch) indexFor(name string, dimensions int) *gpuVectorIndex {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.indexes == nil {
+		return nil
+	}
+	vi, ok := g.indexes[name]
+	if !ok {
+		vi = &gpuVectorIndex{dimensions: dimensions}
+		g.indexes[name] = vi
+	}
+	vi.mu.Lock()
+	defer vi.mu.Unlock()
+	if vi.dimensions != dimensions {
+		vi.resize(g.accel, 0)
+		vi.dimensions = dimensions
+	}
+	return vi
+}
+
+// resize makes the index hold n chunks. Callers hold vi.mu.
+func (vi *gpuVectorIndex) resize(accel *gpu.Accelerator, n int) {
+	for len(vi.chunks) > n {
+		last := len(vi.chunks) - 1
+		vi.chunks[last].Release()
+		vi.chunks = vi.chunks[:last]
+	}
+	for len(vi.chunks) < n {
+		vi.chunks = append(vi.chunks, accel.NewGPUEmbeddingIndex(vi.dimensions))
+	}
+}
+
+func (g *gpuVectorSearch) SearchVectors(ctx context.Context, index string, ids []string, vectors [][]float32, query []float32, k int) ([]int, []float64, error) {
+	vi := g.indexFor(index, len(query))
+	if vi == nil {
+		return nil, nil, gpu.ErrGPUDisabled
+	}
+
+	chunks := g.scheduler.Chunks(len(ids))
+	chunkRows := len(ids)
+	if chunks > 1 {
+		chunkRows = g.scheduler.ChunkRows()
+	}
+	vram := int64(len(ids)) * int64(len(query)) * 4
+
+	type hit struct {
+		pos   int
+		score float64
+	}
+	var hits []hit
+	served := gpu.BackendNone
+	err := g.scheduler.Run(ctx, sessionOf(ctx), vram, chunks, func(chunk int) error {
+		from := chunk * chunkRows
+		to := min(from+chunkRows, len(ids))
+
+		vi.mu.Lock()
+		defer vi.mu.Unlock()
+		if len(vi.chunks) != chunks {
+			vi.resize(g.accel, chunks)
+		}
+		idx := vi.chunks[chunk]
+		if err := syncGPUIndex(idx, ids[from:to], vectors[from:to]); err != nil {
+			return err
+		}
+		results, backend, err := idx.SearchWithBackend(query, min(k, to-from))
+		if err != nil {
+			return err
+		}
+		if backend != gpu.BackendNone {
+			served = backend
+		}
+
+		positions := make(map[string]int, to-from)
+		for i, id := range ids[from:to] {
+			positions[id] = from + i
+		}
+		for _, r := range results {
+			if pos, ok := positions[r.ID]; ok {
+				hits = append(hits, hit{pos: pos, score: float64(r.Score)})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Merge the per-chunk top-k
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	positions := make([]int, len(hits))
+	scores := make([]float64, len(hits))
+	for i, h := range hits {
+		positions[i], scores[i] = h.pos, h.score
+	}
+
+	by := string(served)
+	if served == gpu.BackendNone {
+		by = "cpu (GPU fallback)"
+	}
+	log.Printf("🎮 Vector query on %q: %d candidates in %d chunk(s) ranked by %s", index, len(ids), chunks, by)
+	return positions, scores, nil
+}
+
+// sessionOf names the session a query belongs to for GPU scheduling: its
+// user, else its client address. Anonymous queries share one session.
+func sessionOf(ctx context.Context) string {
+	p, ok := ratelimit.PrincipalFromContext(ctx)
+	switch {
+	case !ok:
+		return ""
+	case p.User != "":
+		return "user:" + p.User
+	default:
+		return "ip:" + p.IP
+	}
+}
+
+// syncGPUIndex makes the index hold exactly the given embeddings. Changed
+// rows are written in place and new rows appended to the device buffer;
+// the whole index is uploaded again only if that fails.
+func syncGPUIndex(index *gpu.GPUEmbeddingIndex, ids []string, vectors [][]float32) error {
+	want := make(map[string]struct{}, len(ids))
+	var changedIDs []string
+	var changed [][]float32
+	for i, id := range ids {
+		want[id] = struct{}{}
+		if old, ok := index.Get(id); !ok || !slices.Equal(old, vectors[i]) {
+			changedIDs = append(changedIDs, id)
+			changed = append(changed, vectors[i])
+		}
+	}
+	for _, id := range index.NodeIDs() {
+		if _, ok := want[id]; !ok {
+			index.Remove(id)
+		}
+	}
+	if len(changed) > 0 {
+		if err := index.AddBatch(changedIDs, changed); err != nil {
+			return err
+		}
+	}
+	if !index.IsGPUSynced() {
+		if err := index.SyncToGPU(); err != nil {
+			// Search still works from the CPU copy
+			log.Printf("⚠️  GPU upload failed, vector search uses CPU: %v", err)
+		}
+	}
+	return nil
+}
+
+// release frees every index and the accelerator.
+func (g *gpuVectorSearch) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, vi := range g.indexes {
+		vi.mu.Lock()
+		vi.resize(g.accel, 0)
+		vi.mu.Unlock()
+	}
+	g.indexes = nil
+	g.accel.Release()
+}
+
+// EnableGPUVectorSearch ranks db.index.vector.queryNodes results for cosine
+// indexes on the GPU backend chosen by gpu.AutoSelect, which honours the
+// NORNICDB_GPU_BACKEND override. Every query logs the backend that served
+// it; failures fall back to CPU. Queries share the device through the
+// scheduler set with SetGPUScheduler, or one with default settings.
+//
+// It returns the backend in use, or gpu.BackendNone if queries stay on CPU.
+// An error explains why a GPU could not be used, for example an override
+// naming a backend this binary was built without.
+//
+// Example:
+//
+//	backend, err := db.EnableGPUVectorSearch()
+//	if err != nil {
+//		log.Printf("GPU vector search disabled: %v", err)
+//	} else if backend != gpu.BackendNone {
+//		log.Printf("Vector queries run on %s", backend)
+//	}
+func (db *DB) EnableGPUVectorSearch() (gpu.Backend, error) {
+	backend, err := gpu.AutoSelect()
+	if err != nil || backend == gpu.BackendNone {
+		return gpu.BackendNone, err
+	}
+	accel, err := gpu.NewAcceleratorFor(backend)
+	if err != nil {
+		return gpu.BackendNone, fmt.Errorf("initializing %s: %w", backend, err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		accel.Release()
+		return gpu.BackendNone, ErrClosed
+	}
+	if db.gpuVectorSearch != nil {
+		db.gpuVectorSearch.release()
+	}
+	if db.gpuScheduler == nil {
+		db.gpuScheduler = gpu.NewScheduler(gpu.DefaultSchedulerConfig())
+	}
+	db.gpuVectorSearch = newGPUVectorSearch(accel, db.gpuScheduler)
+	db.cypherExecutor.SetVectorSearchAccelerator(db.gpuVectorSearch)
+	return backend, nil
+}
+
+// SetGPUScheduler sets the scheduler that shares the GPU between vector
+// queries, replacing the default one. Call it before EnableGPUVectorSearch.
+func (db *DB) SetGPUScheduler(scheduler *gpu.Scheduler) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.gpuScheduler = scheduler
+}
+
+// GPUScheduler returns the scheduler of GPU vector queries, or nil if they
+// do not run on a GPU.
+func (db *DB) GPUScheduler() *gpu.Scheduler {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.gpuVectorSearch == nil {
+		return nil
+	}
+	return db.gpuScheduler
+}
@@ -0,0 +1,93 @@
+package nornicdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/gpu"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGPUVectorSearch_SyncsCandidates(t *testing.T) {
+	// A disabled accelerator serves every query from the index's CPU copy
+	accel, err := gpu.NewAccelerator(nil)
+	require.NoError(t, err)
+	g := newGPUVectorSearch(accel, nil)
+	defer g.release()
+	ctx := context.Background()
+
+	ids := []string{"a", "b", "c"}
+	vectors := [][]float32{{0, 1}, {1, 0}, {0.6, 0.8}}
+	hits, scores, err := g.SearchVectors(ctx, "docs", ids, vectors, []float32{1, 0}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, hits)
+	assert.InDelta(t, 1.0, scores[0], 1e-6)
+	assert.InDelta(t, 0.6, scores[1], 1e-6)
+
+	// Changed, removed and reordered candidates are reflected in the index
+	ids = []string{"c", "a"}
+	vectors = [][]float32{{0.6, 0.8}, {1, 0}}
+	hits, _, err = g.SearchVectors(ctx, "docs", ids, vectors, []float32{1, 0}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 0}, hits)
+	assert.ElementsMatch(t, []string{"a", "c"}, g.indexes["docs"].chunks[0].NodeIDs())
+
+	// New dimensions replace the index
+	_, _, err = g.SearchVectors(ctx, "docs", []string{"x"}, [][]float32{{1, 0, 0}}, []float32{1, 0, 0}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x"}, g.indexes["docs"].chunks[0].NodeIDs())
+
+	g.release()
+	_, _, err = g.SearchVectors(ctx, "docs", ids, vectors, []float32{1, 0}, 1)
+	assert.ErrorIs(t, err, gpu.ErrGPUDisabled, "released search must send queries back to the CPU path")
+}
+
+func TestGPUVectorSearch_Chunked(t *testing.T) {
+	accel, err := gpu.NewAccelerator(nil)
+	require.NoError(t, err)
+	scheduler := gpu.NewScheduler(gpu.SchedulerConfig{ChunkRows: 2, MaxQueryVRAMMB: 1})
+	g := newGPUVectorSearch(accel, scheduler)
+	defer g.release()
+	ctx := ratelimit.NewContext(context.Background(), ratelimit.Principal{User: "alice"})
+
+	ids := []string{"a", "b", "c", "d", "e"}
+	vectors := [][]float32{{0, 1}, {0.6, 0.8}, {0.8, 0.6}, {1, 0}, {-1, 0}}
+	hits, scores, err := g.SearchVectors(ctx, "docs", ids, vectors, []float32{1, 0}, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 2, 1}, hits, "per-chunk results are merged")
+	assert.InDelta(t, 0.8, scores[1], 1e-6)
+	assert.Len(t, g.indexes["docs"].chunks, 3)
+	assert.Equal(t, int64(3), scheduler.Stats().Launches)
+
+	// Fewer candidates release the chunks no longer needed
+	_, _, err = g.SearchVectors(ctx, "docs", ids[:2], vectors[:2], []float32{1, 0}, 1)
+	require.NoError(t, err)
+	assert.Len(t, g.indexes["docs"].chunks, 1)
+
+	// Over the per-query VRAM limit the executor scores on CPU
+	big := make([][]float32, 2)
+	for i := range big {
+		big[i] = make([]float32, 1<<18)
+	}
+	_, _, err = g.SearchVectors(ctx, "big", []string{"x", "y"}, big, big[0], 1)
+	assert.ErrorIs(t, err, gpu.ErrQueryVRAMLimit)
+}
+
+func TestEnableGPUVectorSearch(t *testing.T) {
+	db, err := Open("", nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	t.Setenv(gpu.EnvBackend, "cpu")
+	backend, err := db.EnableGPUVectorSearch()
+	require.NoError(t, err)
+	assert.Equal(t, gpu.BackendNone, backend)
+	assert.Nil(t, db.gpuVectorSearch)
+
+	t.Setenv(gpu.EnvBackend, "not-a-gpu")
+	backend, err = db.EnableGPUVectorSearch()
+	assert.Error(t, err)
+	assert.Equal(t, gpu.BackendNone, backend)
+}
@@ -0,0 +1,305 @@
+// Package nornicdb - HDBSCAN density clustering of embeddings.
+//
+// HDBSCAN finds clusters of any shape and number and leaves points in
+// sparse regions unclustered, where k-means must be told how many clusters
+// to make and puts every point in one. It suits memories, whose topics are
+// not known in advance and where many nodes belong to no topic at all.
+//
+// The implementation follows Campello et al. (2013):
+//
+//	core distance      distance to the minClusterSize-th nearest point
+//	mutual reach.      max(core(a), core(b), dist(a, b))
+//	spanning tree      minimum spanning tree over mutual reachability
+//	condensed tree     splits of the single-linkage hierarchy where both
+//	                   sides have at least minClusterSize points
+//	selection          the clusters with the most excess of mass
+//
+// It runs on the CPU and compares every pair of points twice without
+// storing the distances, so time grows with the square of the number of
+// points and memory linearly.
+package nornicdb
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// hdbscanMaxPoints bounds the points HDBSCAN clusters; beyond it the
+// pairwise comparisons take minutes and k-means is the better choice.
+const hdbscanMaxPoints = 20000
+
+// hdbscanNoise labels points that belong to no cluster.
+const hdbscanNoise = -1
+
+// hdbscan labels each vector with a cluster from 0 or hdbscanNoise.
+// Clusters are numbered in the order of their first point.
+func hdbscan(ctx context.Context, vectors [][]float32, minClusterSize int) ([]int, error) {
+	n := len(vectors)
+	labels := make([]int, n)
+	for i := range labels {
+		labels[i] = hdbscanNoise
+	}
+	if minClusterSize < 2 {
+		minClusterSize = 2
+	}
+	if n < minClusterSize {
+		return labels, nil
+	}
+
+	core, err := hdbscanCoreDistances(ctx, vectors, minClusterSize)
+	if err != nil {
+		return nil, err
+	}
+	edges, err := hdbscanSpanningTree(ctx, vectors, core)
+	if err != nil {
+		return nil, err
+	}
+	tree := newLinkageTree(n, edges)
+	condensed := condenseLinkageTree(tree, minClusterSize)
+	selected := condensed.selectClusters()
+
+	// A point belongs to the outermost selected cluster it fell out of or
+	// of which it was part.
+	ids := make(map[int]int)
+	for p := 0; p < n; p++ {
+		label := hdbscanNoise
+		for c := condensed.pointCluster[p]; c > 0; c = condensed.parent[c] {
+			if selected[c] {
+				label = c
+			}
+		}
+		if label == hdbscanNoise {
+			continue
+		}
+		id, ok := ids[label]
+		if !ok {
+			id = len(ids)
+			ids[label] = id
+		}
+		labels[p] = id
+	}
+	return labels, nil
+}
+
+// hdbscanCoreDistances returns each point's distance to its k-th nearest
+// point, counting itself.
+func hdbscanCoreDistances(ctx context.Context, vectors [][]float32, k int) ([]float64, error) {
+	n := len(vectors)
+	core := make([]float64, n)
+	dists := make([]float64, n)
+	for i := range vectors {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for j := range vectors {
+			dists[j] = euclidean(vectors[i], vectors[j])
+		}
+		sort.Float64s(dists)
+		core[i] = dists[k-1]
+	}
+	return core, nil
+}
+
+// linkageEdge joins two points at a mutual reachability distance.
+type linkageEdge struct {
+	a, b int
+	dist float64
+}
+
+// hdbscanSpanningTree builds the minimum spanning tree of the mutual
+// reachability graph with Prim's algorithm, returning its n-1 edges.
+func hdbscanSpanningTree(ctx context.Context, vectors [][]float32, core []float64) ([]linkageEdge, error) {
+	n := len(vectors)
+	inTree := make([]bool, n)
+	best := make([]float64, n)
+	from := make([]int, n)
+	for i := range best {
+		best[i] = math.Inf(1)
+	}
+	edges := make([]linkageEdge, 0, n-1)
+	current := 0
+	inTree[0] = true
+	for len(edges) < n-1 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		next := -1
+		for j := 0; j < n; j++ {
+			if inTree[j] {
+				continue
+			}
+			d := math.Max(euclidean(vectors[current], vectors[j]), math.Max(core[current], core[j]))
+			if d < best[j] {
+				best[j] = d
+				from[j] = current
+			}
+			if next < 0 || best[j] < best[next] {
+				next = j
+			}
+		}
+		inTree[next] = true
+		edges = append(edges, linkageEdge{a: from[next], b: next, dist: best[next]})
+		current = next
+	}
+	return edges, nil
+}
+
+// linkageTree is the single-linkage hierarchy: points are nodes 0 to n-1,
+// and merge i is node n+i joining left[i] and right[i] at dist[i].
+type linkageTree struct {
+	n           int
+	left, right []int
+	dist        []float64
+	size        []int // per node
+}
+
+func newLinkageTree(n int, edges []linkageEdge) *linkageTree {
+	sort.Slice(edges, func(i, j int) bool { return edges[i].dist < edges[j].dist })
+	t := &linkageTree{
+		n:     n,
+		left:  make([]int, len(edges)),
+		right: make([]int, len(edges)),
+		dist:  make([]float64, len(edges)),
+		size:  make([]int, n+len(edges)),
+	}
+	// Union-find over points, tracking the tree node of each set's root.
+	parent := make([]int, n)
+	node := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+		node[i] = i
+		t.size[i] = 1
+	}
+	find := func(x int) int {
+		for parent[x] != x {
+			parent[x] = parent[parent[x]]
+			x = parent[x]
+		}
+		return x
+	}
+	for i, e := range edges {
+		ra, rb := find(e.a), find(e.b)
+		t.left[i], t.right[i], t.dist[i] = node[ra], node[rb], e.dist
+		t.size[n+i] = t.size[node[ra]] + t.size[node[rb]]
+		parent[rb] = ra
+		node[ra] = n + i
+	}
+	return t
+}
+
+// leaves appends the points under a tree node.
+func (t *linkageTree) leaves(x int, points []int) []int {
+	stack := []int{x}
+	for len(stack) > 0 {
+		x = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if x < t.n {
+			points = append(points, x)
+			continue
+		}
+		stack = append(stack, t.left[x-t.n], t.right[x-t.n])
+	}
+	return points
+}
+
+// condensedTree holds the clusters of the condensed hierarchy. Cluster 0 is
+// the root, holding every point; parent[0] is -1.
+type condensedTree struct {
+	parent    []int
+	children  [][]int
+	stability []float64
+
+	// pointCluster is the last cluster each point was part of.
+	pointCluster []int
+}
+
+// condenseLinkageTree walks the hierarchy from the top, as density grows
+// (lambda = 1/distance), and keeps only splits into two parts of at least
+// minClusterSize points; smaller parts are points falling out of the
+// cluster. A cluster's stability sums, over its points, how long in lambda
+// each stayed in it.
+func condenseLinkageTree(t *linkageTree, minClusterSize int) *condensedTree {
+	c := &condensedTree{
+		parent:       []int{-1},
+		children:     [][]int{nil},
+		stability:    []float64{0},
+		pointCluster: make([]int, t.n),
+	}
+	birth := []float64{0}
+	newCluster := func(parent int, lambda float64) int {
+		id := len(c.parent)
+		c.parent = append(c.parent, parent)
+		c.children = append(c.children, nil)
+		c.stability = append(c.stability, 0)
+		birth = append(birth, lambda)
+		c.children[parent] = append(c.children[parent], id)
+		return id
+	}
+	fallOut := func(x, cluster int, lambda float64) {
+		points := t.leaves(x, nil)
+		for _, p := range points {
+			c.pointCluster[p] = cluster
+		}
+		c.stability[cluster] += float64(len(points)) * (lambda - birth[cluster])
+	}
+
+	type item struct{ node, cluster int }
+	stack := []item{{node: t.n + len(t.dist) - 1, cluster: 0}}
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		m := it.node - t.n
+		lambda := 1 / math.Max(t.dist[m], 1e-10)
+		l, r := t.left[m], t.right[m]
+		bigL, bigR := t.size[l] >= minClusterSize, t.size[r] >= minClusterSize
+		switch {
+		case bigL && bigR:
+			c.stability[it.cluster] += float64(t.size[l]+t.size[r]) * (lambda - birth[it.cluster])
+			stack = append(stack,
+				item{node: l, cluster: newCluster(it.cluster, lambda)},
+				item{node: r, cluster: newCluster(it.cluster, lambda)})
+		case bigL:
+			fallOut(r, it.cluster, lambda)
+			stack = append(stack, item{node: l, cluster: it.cluster})
+		case bigR:
+			fallOut(l, it.cluster, lambda)
+			stack = append(stack, item{node: r, cluster: it.cluster})
+		default:
+			fallOut(it.node, it.cluster, lambda)
+		}
+	}
+	return c
+}
+
+// selectClusters picks, bottom up, each cluster more stable than its
+// selected descendants together. The root is never selected, so data
+// without structure is all noise rather than one cluster.
+func (c *condensedTree) selectClusters() []bool {
+	selected := make([]bool, len(c.parent))
+	best := make([]float64, len(c.parent))
+	// Children are created after their parents.
+	for id := len(c.parent) - 1; id > 0; id-- {
+		var sum float64
+		for _, child := range c.children[id] {
+			sum += best[child]
+		}
+		if len(c.children[id]) == 0 || c.stability[id] >= sum {
+			selected[id] = true
+			best[id] = c.stability[id]
+		} else {
+			best[id] = sum
+		}
+	}
+	return selected
+}
+
+// euclidean returns the Euclidean distance between two vectors.
+func euclidean(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		d := float64(a[i] - b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,31 @@
+package nornicdb
+
+import "fmt"
+
+// qcEvaluator adapts the DB to cypher.QCEvaluator for nornicdb.qc.report.
+type qcEvaluator struct {
+	db *DB
+}
+
+func (q qcEvaluator) QCReport() ([]map[string]interface{}, error) {
+	if q.db.inference == nil || q.db.inference.GetHeimdallQC() == nil {
+		return nil, fmt.Errorf("Heimdall QC is not configured")
+	}
+	report := q.db.inference.GetHeimdallQC().ShadowReport()
+	rows := make([]map[string]interface{}, len(report))
+	for i, r := range report {
+		rows[i] = map[string]interface{}{
+			"dimension":       r.Dimension,
+			"key":             r.Key,
+			"pending":         r.Pending,
+			"outcomes":        r.Outcomes(),
+			"true_positives":  r.TruePositives,
+			"false_positives": r.FalsePositives,
+			"false_negatives": r.FalseNegatives,
+			"true_negatives":  r.TrueNegatives,
+			"precision":       r.Precision(),
+			"recall":          r.Recall(),
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,448 @@
+// Package nornicdb provides embedding drift detection and re-embedding jobs.
+//
+// Every embedding is tagged with the model version that produced it
+// (embedding_version = "model@dimensions"). When the embedding model changes,
+// existing vectors no longer share a space with new ones and similarity
+// search silently degrades. DetectEmbeddingDrift reports the versions present
+// in the index; StartReembed runs a throttled background job that regenerates
+// every stale embedding with the current model.
+//
+// The job re-embeds the most read nodes first, so search quality recovers
+// for hot data long before a large job finishes. How much a node is read
+// comes from its access_count (memories) and from the co-access tracker of
+// the inference engine, when enabled. Nodes with no recorded reads follow
+// in storage order. Re-embedded nodes are re-indexed, which recomputes their
+// PQ codes when quantization is enabled.
+package nornicdb
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// EmbeddingVersionProperty is the node property holding the model version
+// tag of the node's embedding.
+const EmbeddingVersionProperty = "embedding_version"
+
+// Re-embedding job states.
+const (
+	ReembedRunning   = "running"
+	ReembedCompleted = "completed"
+	ReembedCancelled = "cancelled"
+	ReembedFailed    = "failed"
+)
+
+var (
+	// ErrReembedRunning is returned when a re-embedding job is already active.
+	ErrReembedRunning = errors.New("re-embedding job already running")
+	// ErrEmbedderNotConfigured is returned when auto-embed is not enabled.
+	ErrEmbedderNotConfigured = errors.New("auto-embed not enabled")
+)
+
+// EmbeddingVersion returns the version tag for embeddings produced by a model.
+// Different dimensions of the same model are different versions.
+func EmbeddingVersion(model string, dimensions int) string {
+	return fmt.Sprintf("%s@%d", model, dimensions)
+}
+
+// nodeEmbeddingVersion returns the version tag of a node's embedding.
+// Nodes embedded before tagging are identified by their model property, or
+// as "unknown" if they have none.
+func nodeEmbeddingVersion(node *storage.Node) string {
+	if v, ok := node.Properties[EmbeddingVersionProperty].(string); ok && v != "" {
+		return v
+	}
+	model, _ := node.Properties["embedding_model"].(string)
+	if model == "" {
+		model = "unknown"
+	}
+	return EmbeddingVersion(model, len(node.Embedding))
+}
+
+// EmbeddingDriftReport describes the embedding versions present in the index.
+type EmbeddingDriftReport struct {
+	CurrentVersion string         `json:"current_version"` // Empty if no embedder is configured
+	Versions       map[string]int `json:"versions"`        // Version tag -> node count
+	Total          int            `json:"total"`           // Nodes with embeddings
+	Stale          int            `json:"stale"`           // Nodes not on CurrentVersion
+	Drifted        bool           `json:"drifted"`         // Mixed versions, or any stale node
+}
+
+// DetectEmbeddingDrift scans embedded nodes and groups them by version.
+//
+// Example:
+//
+//	report, _ := db.DetectEmbeddingDrift(ctx)
+//	if report.Drifted {
+//		db.StartReembed(nil)
+//	}
+func (db *DB) DetectEmbeddingDrift(ctx context.Context) (*EmbeddingDriftReport, error) {
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return nil, ErrClosed
+	}
+	engine := db.storage
+	current := ""
+	if db.embedQueue != nil {
+		current = EmbeddingVersion(db.embedQueue.embedder.Model(), db.embedQueue.embedder.Dimensions())
+	}
+	db.mu.RUnlock()
+
+	report := &EmbeddingDriftReport{CurrentVersion: current, Versions: make(map[string]int)}
+	err := storage.StreamNodesWithFallback(ctx, engine, 1000, func(node *storage.Node) error {
+		if len(node.Embedding) == 0 {
+			return nil
+		}
+		version := nodeEmbeddingVersion(node)
+		report.Versions[version]++
+		report.Total++
+		if current != "" && version != current {
+			report.Stale++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("drift scan failed: %w", err)
+	}
+	report.Drifted = len(report.Versions) > 1 || report.Stale > 0
+	return report, nil
+}
+
+// ReembedConfig controls a re-embedding job.
+type ReembedConfig struct {
+	Delay    time.Duration // Pause between nodes to throttle embedder load (default: 100ms)
+	MaxNodes int           // Re-embed only this many stale nodes, the most read (0 = all)
+}
+
+// DefaultReembedConfig returns sensible defaults.
+func DefaultReembedConfig() *ReembedConfig {
+	return &ReembedConfig{
+		Delay: 100 * time.Millisecond,
+	}
+}
+
+// ReembedProgress reports the state of a re-embedding job.
+type ReembedProgress struct {
+	State         string    `json:"state"`
+	TargetVersion string    `json:"target_version"`
+	Total         int       `json:"total"`     // Stale nodes found by the scan
+	Processed     int       `json:"processed"` // Re-embedded successfully
+	Failed        int       `json:"failed"`
+	Skipped       int       `json:"skipped"`     // Deleted, already current, or no content
+	Prioritized   int       `json:"prioritized"` // Stale nodes with recorded reads, re-embedded first
+	Percent       float64   `json:"percent"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// reembedJob regenerates stale embeddings in the background.
+type reembedJob struct {
+	worker *EmbedWorker
+	engine storage.Engine
+	config *ReembedConfig
+	// Co-access activity by node ID at the start of the job; nil without
+	// the inference engine
+	activity map[string]float64
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu       sync.Mutex
+	progress ReembedProgress
+}
+
+// StartReembed starts a background job that re-embeds every node whose
+// embedding version differs from the current embedder's. Only one job runs
+// at a time; poll ReembedProgress for status and CancelReembed to stop it.
+func (db *DB) StartReembed(config *ReembedConfig) (*ReembedProgress, error) {
+	if config == nil {
+		config = DefaultReembedConfig()
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return nil, ErrClosed
+	}
+	if db.embedQueue == nil {
+		return nil, ErrEmbedderNotConfigured
+	}
+
+	db.reembedMu.Lock()
+	defer db.reembedMu.Unlock()
+	if db.reembedJob != nil && db.reembedJob.Progress().State == ReembedRunning {
+		return nil, ErrReembedRunning
+	}
+
+	var activity map[string]float64
+	if db.inference != nil {
+		activity = db.inference.CoAccess().Activity()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &reembedJob{
+		worker:   db.embedQueue,
+		engine:   db.storage,
+		config:   config,
+		activity: activity,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		progress: ReembedProgress{
+			State:         ReembedRunning,
+			TargetVersion: EmbeddingVersion(db.embedQueue.embedder.Model(), db.embedQueue.embedder.Dimensions()),
+			StartedAt:     time.Now(),
+		},
+	}
+	db.reembedJob = job
+	go job.run(ctx)
+
+	progress := job.Progress()
+	return &progress, nil
+}
+
+// ReembedProgress returns the progress of the current or last re-embedding
+// job, or nil if none has run.
+func (db *DB) ReembedProgress() *ReembedProgress {
+	db.reembedMu.Lock()
+	job := db.reembedJob
+	db.reembedMu.Unlock()
+	if job == nil {
+		return nil
+	}
+	progress := job.Progress()
+	return &progress
+}
+
+// CancelReembed stops the running re-embedding job and waits for it to exit.
+// Returns false if no job was running.
+func (db *DB) CancelReembed() bool {
+	db.reembedMu.Lock()
+	job := db.reembedJob
+	db.reembedMu.Unlock()
+	if job == nil || job.Progress().State != ReembedRunning {
+		return false
+	}
+	job.stop()
+	return true
+}
+
+// Progress returns a snapshot of the job's progress.
+func (j *reembedJob) Progress() ReembedProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	p := j.progress
+	if p.Total > 0 {
+		p.Percent = float64(p.Processed+p.Failed+p.Skipped) / float64(p.Total) * 100
+	} else if p.State == ReembedCompleted {
+		p.Percent = 100
+	}
+	return p
+}
+
+// stop cancels the job and waits for it to finish.
+func (j *reembedJob) stop() {
+	j.cancel()
+	<-j.done
+}
+
+func (j *reembedJob) run(ctx context.Context) {
+	defer close(j.done)
+	defer j.cancel()
+
+	target := j.progress.TargetVersion
+	ids, prioritized, err := j.staleNodes(ctx, target)
+	if err != nil {
+		j.finish(ctx, err)
+		return
+	}
+	j.mu.Lock()
+	j.progress.Total = len(ids)
+	j.progress.Prioritized = prioritized
+	j.mu.Unlock()
+	fmt.Printf("🔁 Re-embedding %d nodes to %s\n", len(ids), target)
+
+	for i, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		if i > 0 && j.config.Delay > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(j.config.Delay):
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		outcome := j.reembed(ctx, id, target)
+		j.mu.Lock()
+		switch outcome {
+		case nil:
+			j.progress.Processed++
+		case errReembedSkip:
+			j.progress.Skipped++
+		default:
+			j.progress.Failed++
+			fmt.Printf("⚠️  Failed to re-embed node %s: %v\n", id, outcome)
+		}
+		j.mu.Unlock()
+	}
+	j.finish(ctx, nil)
+}
+
+// staleNodes returns the IDs of embedded nodes not on the target version,
+// most read first, and how many of them have recorded reads.
+func (j *reembedJob) staleNodes(ctx context.Context, target string) ([]storage.NodeID, int, error) {
+	var queue reembedQueue
+	err := storage.StreamNodesWithFallback(ctx, j.engine, 1000, func(node *storage.Node) error {
+		if len(node.Embedding) == 0 || nodeEmbeddingVersion(node) == target {
+			return nil
+		}
+		queue = append(queue, reembedCandidate{id: node.ID, reads: j.reads(node), seq: len(queue)})
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("drift scan failed: %w", err)
+	}
+
+	heap.Init(&queue)
+	n := len(queue)
+	if j.config.MaxNodes > 0 && n > j.config.MaxNodes {
+		n = j.config.MaxNodes
+	}
+	ids := make([]storage.NodeID, 0, n)
+	prioritized := 0
+	for len(ids) < n {
+		c := heap.Pop(&queue).(reembedCandidate)
+		ids = append(ids, c.id)
+		if c.reads > 0 {
+			prioritized++
+		}
+	}
+	return ids, prioritized, nil
+}
+
+// reads estimates how much a node is read: its access count plus its
+// co-access activity.
+func (j *reembedJob) reads(node *storage.Node) float64 {
+	var reads float64
+	switch v := node.Properties["access_count"].(type) {
+	case int64:
+		reads = float64(v)
+	case int:
+		reads = float64(v)
+	case float64:
+		reads = v
+	}
+	return reads + j.activity[string(node.ID)]
+}
+
+// reembedCandidate is a stale node waiting to be re-embedded.
+type reembedCandidate struct {
+	id    storage.NodeID
+	reads float64
+	seq   int // Scan order, for ties
+}
+
+// reembedQueue is a priority queue (container/heap) of stale nodes, most
+// read first.
+type reembedQueue []reembedCandidate
+
+func (q reembedQueue) Len() int { return len(q) }
+func (q reembedQueue) Less(i, j int) bool {
+	if q[i].reads != q[j].reads {
+		return q[i].reads > q[j].reads
+	}
+	return q[i].seq < q[j].seq
+}
+func (q reembedQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *reembedQueue) Push(x interface{}) { *q = append(*q, x.(reembedCandidate)) }
+func (q *reembedQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+func (j *reembedJob) finish(ctx context.Context, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.progress.FinishedAt = time.Now()
+	switch {
+	case err != nil:
+		j.progress.State = ReembedFailed
+		j.progress.Error = err.Error()
+	case ctx.Err() != nil:
+		j.progress.State = ReembedCancelled
+	default:
+		j.progress.State = ReembedCompleted
+	}
+	fmt.Printf("🔁 Re-embedding %s: %d processed, %d failed, %d skipped\n",
+		j.progress.State, j.progress.Processed, j.progress.Failed, j.progress.Skipped)
+}
+
+// errReembedSkip marks nodes that no longer need re-embedding.
+var errReembedSkip = errors.New("skip")
+
+// reembed regenerates one node's embedding with the worker's embedder.
+func (j *reembedJob) reembed(ctx context.Context, id storage.NodeID, target string) error {
+	node, err := j.engine.GetNode(id)
+	if err != nil || node == nil || len(node.Embedding) == 0 || nodeEmbeddingVersion(node) == target {
+		return errReembedSkip
+	}
+	node = copyNodeForEmbedding(node)
+
+	// FileChunks were embedded from their own text, not their metadata
+	text, _ := node.Properties["text"].(string)
+	if !nodeHasLabel(node.Labels, "FileChunk") || text == "" {
+		text = buildEmbeddingText(node.Properties)
+	}
+	if text == "" {
+		return errReembedSkip
+	}
+
+	ew := j.worker
+	embeddings, err := ew.embedder.EmbedBatch(ctx, chunkText(text, ew.config.ChunkSize, ew.config.ChunkOverlap))
+	if err != nil {
+		return err
+	}
+	embedding := averageEmbeddings(embeddings)
+	if len(embedding) == 0 {
+		return fmt.Errorf("embedder returned no vectors")
+	}
+
+	node.Embedding = embedding
+	node.Properties["embedding_model"] = ew.embedder.Model()
+	node.Properties["embedding_dimensions"] = ew.embedder.Dimensions()
+	node.Properties[EmbeddingVersionProperty] = target
+	node.Properties["embedded_at"] = time.Now().Format(time.RFC3339)
+	if _, ok := node.Properties["embedding"].([]interface{}); ok {
+		// Mimir-style chunk nodes mirror the vector as a property
+		vec := make([]interface{}, len(embedding))
+		for i, v := range embedding {
+			vec[i] = float64(v)
+		}
+		node.Properties["embedding"] = vec
+	}
+
+	if embedUpdater, ok := j.engine.(interface{ UpdateNodeEmbedding(*storage.Node) error }); ok {
+		err = embedUpdater.UpdateNodeEmbedding(node)
+	} else {
+		err = j.engine.UpdateNode(node)
+	}
+	if err != nil {
+		return err
+	}
+	if ew.onEmbedded != nil {
+		ew.onEmbedded(node)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package nornicdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddingVersion(t *testing.T) {
+	assert.Equal(t, "bge-m3@1024", EmbeddingVersion("bge-m3", 1024))
+
+	node := &storage.Node{Embedding: make([]float32, 3), Properties: map[string]interface{}{}}
+	assert.Equal(t, "unknown@3", nodeEmbeddingVersion(node))
+	node.Properties["embedding_model"] = "old"
+	assert.Equal(t, "old@3", nodeEmbeddingVersion(node), "untagged nodes fall back to the model property")
+	node.Properties[EmbeddingVersionProperty] = "tagged@3"
+	assert.Equal(t, "tagged@3", nodeEmbeddingVersion(node))
+}
+
+func TestEmbeddingDrift_Reembed(t *testing.T) {
+	db, err := Open("", nil)
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	for i, model := range []string{"old-model", "old-model", "test-model"} {
+		require.NoError(t, db.storage.CreateNode(&storage.Node{
+			ID:        storage.NodeID("n" + string(rune('a'+i))),
+			Labels:    []string{"Doc"},
+			Embedding: make([]float32, 1024),
+			Properties: map[string]interface{}{
+				"content":                "document " + string(rune('a'+i)),
+				"embedding_model":        model,
+				EmbeddingVersionProperty: EmbeddingVersion(model, 1024),
+			},
+		}))
+	}
+
+	report, err := db.DetectEmbeddingDrift(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.CurrentVersion)
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, map[string]int{"old-model@1024": 2, "test-model@1024": 1}, report.Versions)
+	assert.True(t, report.Drifted, "mixed versions in one index")
+
+	_, err = db.StartReembed(nil)
+	assert.ErrorIs(t, err, ErrEmbedderNotConfigured)
+	assert.Nil(t, db.ReembedProgress())
+
+	embedder := newMockEmbedder()
+	db.SetEmbedder(embedder)
+	report, err = db.DetectEmbeddingDrift(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "test-model@1024", report.CurrentVersion)
+	assert.Equal(t, 2, report.Stale)
+
+	progress, err := db.StartReembed(&ReembedConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, ReembedRunning, progress.State)
+	require.Eventually(t, func() bool {
+		return db.ReembedProgress().State == ReembedCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	progress = db.ReembedProgress()
+	assert.Equal(t, 2, progress.Total)
+	assert.Equal(t, 2, progress.Processed)
+	assert.Equal(t, 100.0, progress.Percent)
+
+	node, err := db.storage.GetNode("na")
+	require.NoError(t, err)
+	assert.Equal(t, "test-model@1024", node.Properties[EmbeddingVersionProperty])
+	assert.NotEqual(t, make([]float32, 1024), node.Embedding, "vector was regenerated")
+
+	report, err = db.DetectEmbeddingDrift(ctx)
+	require.NoError(t, err)
+	assert.False(t, report.Drifted)
+	assert.Equal(t, map[string]int{"test-model@1024": 3}, report.Versions)
+}
+
+func TestEmbeddingDrift_CancelReembed(t *testing.T) {
+	db, err := Open("", nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, id := range []string{"a", "b"} {
+		require.NoError(t, db.storage.CreateNode(&storage.Node{
+			ID:         storage.NodeID(id),
+			Embedding:  make([]float32, 8),
+			Properties: map[string]interface{}{"content": id, "embedding_model": "old-model"},
+		}))
+	}
+	db.SetEmbedder(newMockEmbedder())
+
+	_, err = db.StartReembed(&ReembedConfig{Delay: time.Hour})
+	require.NoError(t, err)
+	_, err = db.StartReembed(nil)
+	assert.ErrorIs(t, err, ErrReembedRunning)
+
+	require.Eventually(t, func() bool {
+		return db.ReembedProgress().Processed == 1
+	}, 5*time.Second, 10*time.Millisecond, "first node runs before the throttle delay")
+	assert.True(t, db.CancelReembed())
+	assert.False(t, db.CancelReembed())
+
+	progress := db.ReembedProgress()
+	assert.Equal(t, ReembedCancelled, progress.State)
+	assert.Equal(t, 2, progress.Total)
+	assert.InDelta(t, 50.0, progress.Percent, 0.001)
+}
+
+func TestReembed_MostReadFirst(t *testing.T) {
+	engine := storage.NewMemoryEngine()
+	for _, n := range []struct {
+		id    string
+		props map[string]interface{}
+	}{
+		{"cold1", nil},
+		{"memory", map[string]interface{}{"access_count": int64(3)}},
+		{"cold2", nil},
+		{"coaccessed", nil},
+		{"current", map[string]interface{}{"access_count": int64(100), EmbeddingVersionProperty: "new@4"}},
+		{"both", map[string]interface{}{"access_count": 1.0}},
+	} {
+		props := map[string]interface{}{"embedding_model": "old"}
+		for k, v := range n.props {
+			props[k] = v
+		}
+		require.NoError(t, engine.CreateNode(&storage.Node{ID: storage.NodeID(n.id), Embedding: make([]float32, 4), Properties: props}))
+	}
+	job := &reembedJob{
+		engine:   engine,
+		config:   &ReembedConfig{},
+		activity: map[string]float64{"coaccessed": 2.5, "both": 4},
+	}
+
+	ids, prioritized, err := job.staleNodes(context.Background(), "new@4")
+	require.NoError(t, err)
+	assert.Equal(t, 3, prioritized)
+	assert.Equal(t, []storage.NodeID{"both", "memory", "coaccessed"}, ids[:3])
+	assert.ElementsMatch(t, []storage.NodeID{"cold1", "cold2"}, ids[3:])
+
+	job.config.MaxNodes = 2
+	ids, _, err = job.staleNodes(context.Background(), "new@4")
+	require.NoError(t, err)
+	assert.Equal(t, []storage.NodeID{"both", "memory"}, ids, "the most read of the stale nodes")
+}
@@ -21,6 +21,7 @@ package pool
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 // PoolConfig configures object pooling behavior.
@@ -57,16 +58,24 @@ type PoolConfig struct {
 	MaxSize int
 }
 
-var globalConfig = PoolConfig{
-	Enabled: true,
-	MaxSize: 1000,
+// globalConfig is swapped whole by Configure, so readers on hot paths see
+// a consistent configuration without locking.
+var globalConfig atomic.Pointer[PoolConfig]
+
+func init() {
+	globalConfig.Store(&PoolConfig{
+		Enabled: true,
+		MaxSize: 1000,
+	})
 }
 
 // Configure sets global pool configuration.
 //
-// This function should be called once during application initialization,
-// before any pooled objects are allocated. Calling it multiple times will
-// reinitialize all pools, which may cause temporary allocation spikes.
+// It may be called at any time, including while queries run, e.g. from
+// CALL dbms.setConfig. A smaller MaxSize applies to objects returned from
+// then on; larger objects already pooled are dropped by the next GC, as
+// sync.Pool contents always are. Disabling pooling makes Get allocate and
+// Put discard.
 //
 // Parameters:
 //   - config: Pool configuration with Enabled and MaxSize settings
@@ -106,51 +115,14 @@ var globalConfig = PoolConfig{
 //   - MaxSize too high: More memory usage
 //
 // Thread Safety:
-//   Not thread-safe. Call only during initialization.
+//   Safe to call concurrently with Get and Put operations.
 func Configure(config PoolConfig) {
-	globalConfig = config
-
-	// Reinitialize pools to ensure New functions are set correctly
-	initPools()
+	globalConfig.Store(&config)
 }
 
-// initPools reinitializes all pools with their New functions.
-func initPools() {
-	rowSlicePool = sync.Pool{
-		New: func() any {
-			return make([][]interface{}, 0, 64)
-		},
-	}
-	nodeSlicePool = sync.Pool{
-		New: func() any {
-			return make([]*PooledNode, 0, 64)
-		},
-	}
-	stringBuilderPool = sync.Pool{
-		New: func() any {
-			return &PooledStringBuilder{buf: make([]byte, 0, 256)}
-		},
-	}
-	byteBufferPool = sync.Pool{
-		New: func() any {
-			return make([]byte, 0, 1024)
-		},
-	}
-	mapPool = sync.Pool{
-		New: func() any {
-			return make(map[string]interface{}, 8)
-		},
-	}
-	stringSlicePool = sync.Pool{
-		New: func() any {
-			return make([]string, 0, 16)
-		},
-	}
-	interfaceSlicePool = sync.Pool{
-		New: func() any {
-			return make([]interface{}, 0, 16)
-		},
-	}
+// Config returns the current pool configuration.
+func Config() PoolConfig {
+	return *globalConfig.Load()
 }
 
 // IsEnabled returns whether pooling is enabled.
@@ -179,7 +151,12 @@ func initPools() {
 //		metrics.RecordAllocation()
 //	}
 func IsEnabled() bool {
-	return globalConfig.Enabled
+	return globalConfig.Load().Enabled
+}
+
+// MaxSize returns the configured maximum size of pooled objects.
+func MaxSize() int {
+	return globalConfig.Load().MaxSize
 }
 
 // =============================================================================
@@ -268,7 +245,7 @@ var rowSlicePool = sync.Pool{
 //   - PutRowSlice: Erase it and put it back in the bin for next time
 //   - This is faster than getting new paper from the store every time!
 func GetRowSlice() [][]interface{} {
-	if !globalConfig.Enabled {
+	if !IsEnabled() {
 		return make([][]interface{}, 0, 64)
 	}
 	return rowSlicePool.Get().([][]interface{})[:0]
@@ -309,11 +286,11 @@ func GetRowSlice() [][]interface{} {
 //   - If it's too big to fit on the rack (cap > MaxSize), throw it away
 //   - Don't try to use the whiteboard after you've returned it!
 func PutRowSlice(rows [][]interface{}) {
-	if !globalConfig.Enabled {
+	if !IsEnabled() {
 		return
 	}
 	// Don't pool very large slices (memory leak prevention)
-	if cap(rows) > globalConfig.MaxSize {
+	if cap(rows) > MaxSize() {
 		return
 	}
 	// Clear references to allow GC of row contents
@@ -439,7 +416,7 @@ var nodeSlicePool = sync.Pool{
 //   - Reduces GC pressure for graph operations
 //   - Especially beneficial for traversals with many intermediate nodes
 func GetNodeSlice() []*PooledNode {
-	if !globalConfig.Enabled {
+	if !IsEnabled() {
 		return make([]*PooledNode, 0, 64)
 	}
 	return nodeSlicePool.Get().([]*PooledNode)[:0]
@@ -474,10 +451,10 @@ func GetNodeSlice() []*PooledNode {
 //   - Put the tray back on the stack (return to pool)
 //   - If the tray is too big (cap > MaxSize), throw it away
 func PutNodeSlice(nodes []*PooledNode) {
-	if !globalConfig.Enabled {
+	if !IsEnabled() {
 		return
 	}
-	if cap(nodes) > globalConfig.MaxSize {
+	if cap(nodes) > MaxSize() {
 		return
 	}
 	for i := range nodes {
@@ -634,6 +611,22 @@ func (b *PooledStringBuilder) Len() int {
 	return len(b.buf)
 }
 
+// Write appends p to the builder, so it can be used as an io.Writer
+// (for example by json.NewEncoder). It never fails.
+func (b *PooledStringBuilder) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+// Bytes returns the built bytes without copying.
+//
+// The slice aliases the builder's buffer: it is only valid until the next
+// write, Reset or PutStringBuilder. Use it to write the content out, and
+// String when the result must outlive the builder.
+func (b *PooledStringBuilder) Bytes() []byte {
+	return b.buf
+}
+
 // Reset clears the builder for reuse.
 //
 // This method resets the length to 0 while keeping the underlying capacity,
@@ -713,7 +706,7 @@ func (b *PooledStringBuilder) Reset() {
 //   - Pre-allocated capacity avoids growth for small strings
 //   - Typical savings: 1-2 allocations per call
 func GetStringBuilder() *PooledStringBuilder {
-	if !globalConfig.Enabled {
+	if !IsEnabled() {
 		return &PooledStringBuilder{buf: make([]byte, 0, 256)}
 	}
 	b := stringBuilderPool.Get().(*PooledStringBuilder)
@@ -742,7 +735,7 @@ func GetStringBuilder() *PooledStringBuilder {
 //   - Don't use the builder after calling PutStringBuilder
 //   - Large builders (>64KB) are discarded, not pooled
 func PutStringBuilder(b *PooledStringBuilder) {
-	if !globalConfig.Enabled || b == nil {
+	if !IsEnabled() || b == nil {
 		return
 	}
 	if cap(b.buf) > 64*1024 { // Don't pool huge buffers
@@ -820,7 +813,7 @@ var byteBufferPool = sync.Pool{
 //   - Pre-allocated 1KB handles most use cases
 //   - Grows automatically if needed
 func GetByteBuffer() []byte {
-	if !globalConfig.Enabled {
+	if !IsEnabled() {
 		return make([]byte, 0, 1024)
 	}
 	return byteBufferPool.Get().([]byte)[:0]
@@ -848,7 +841,7 @@ func GetByteBuffer() []byte {
 //   - Don't use buffer after calling PutByteBuffer
 //   - Buffers >1MB are discarded, not pooled
 func PutByteBuffer(buf []byte) {
-	if !globalConfig.Enabled {
+	if !IsEnabled() {
 		return
 	}
 	if cap(buf) > 1024*1024 { // Don't pool huge buffers (>1MB)
@@ -927,7 +920,7 @@ var mapPool = sync.Pool{
 //   - Pre-allocated capacity 8 handles most use cases
 //   - Typical savings: 1 allocation per call
 func GetMap() map[string]interface{} {
-	if !globalConfig.Enabled {
+	if !IsEnabled() {
 		return make(map[string]interface{}, 8)
 	}
 	m := mapPool.Get().(map[string]interface{})
@@ -960,10 +953,10 @@ func GetMap() map[string]interface{} {
 //   - Don't use map after calling PutMap
 //   - Maps with >MaxSize entries are discarded
 func PutMap(m map[string]interface{}) {
-	if !globalConfig.Enabled || m == nil {
+	if !IsEnabled() || m == nil {
 		return
 	}
-	if len(m) > globalConfig.MaxSize {
+	if len(m) > MaxSize() {
 		return
 	}
 	// Clear for reuse
@@ -1041,7 +1034,7 @@ var stringSlicePool = sync.Pool{
 //   - Pre-allocated capacity avoids growth for small lists
 //   - Typical savings: 1 allocation per call
 func GetStringSlice() []string {
-	if !globalConfig.Enabled {
+	if !IsEnabled() {
 		return make([]string, 0, 16)
 	}
 	return stringSlicePool.Get().([]string)[:0]
@@ -1068,10 +1061,10 @@ func GetStringSlice() []string {
 //   - Capacity is preserved for reuse
 //   - Don't use slice after calling PutStringSlice
 func PutStringSlice(s []string) {
-	if !globalConfig.Enabled {
+	if !IsEnabled() {
 		return
 	}
-	if cap(s) > globalConfig.MaxSize {
+	if cap(s) > MaxSize() {
 		return
 	}
 	stringSlicePool.Put(s[:0])
@@ -1139,7 +1132,7 @@ var interfaceSlicePool = sync.Pool{
 //   - Pre-allocated capacity 16 handles most rows
 //   - Typical savings: 1 allocation per call
 func GetInterfaceSlice() []interface{} {
-	if !globalConfig.Enabled {
+	if !IsEnabled() {
 		return make([]interface{}, 0, 16)
 	}
 	return interfaceSlicePool.Get().([]interface{})[:0]
@@ -1167,10 +1160,10 @@ func GetInterfaceSlice() []interface{} {
 //   - Allows GC to collect slice contents
 //   - Don't use slice after calling PutInterfaceSlice
 func PutInterfaceSlice(s []interface{}) {
-	if !globalConfig.Enabled || s == nil {
+	if !IsEnabled() || s == nil {
 		return
 	}
-	if cap(s) > globalConfig.MaxSize {
+	if cap(s) > MaxSize() {
 		return
 	}
 	// Clear references
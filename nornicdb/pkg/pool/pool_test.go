@@ -1,6 +1,7 @@
 package pool
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 )
@@ -11,7 +12,7 @@ import (
 
 func TestConfigure(t *testing.T) {
 	// Save original config
-	origConfig := globalConfig
+	origConfig := Config()
 	defer func() {
 		Configure(origConfig)
 	}()
@@ -22,8 +23,8 @@ func TestConfigure(t *testing.T) {
 		if !IsEnabled() {
 			t.Error("IsEnabled() = false, want true")
 		}
-		if globalConfig.MaxSize != 500 {
-			t.Errorf("MaxSize = %d, want 500", globalConfig.MaxSize)
+		if MaxSize() != 500 {
+			t.Errorf("MaxSize = %d, want 500", MaxSize())
 		}
 	})
 
@@ -36,6 +37,39 @@ func TestConfigure(t *testing.T) {
 	})
 }
 
+func TestConfigureWhileInUse(t *testing.T) {
+	defer Configure(PoolConfig{Enabled: true, MaxSize: 1000})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				rows := GetRowSlice()
+				rows = append(rows, []interface{}{1})
+				PutRowSlice(rows)
+				PutInterfaceSlice(GetInterfaceSlice())
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		Configure(PoolConfig{Enabled: i%3 != 0, MaxSize: 10 + i})
+	}
+	close(done)
+	wg.Wait()
+
+	if got := Config(); got != (PoolConfig{Enabled: false, MaxSize: 109}) {
+		t.Errorf("Config() = %+v, want the last configuration", got)
+	}
+}
+
 // =============================================================================
 // Row Slice Pool Tests
 // =============================================================================
@@ -145,6 +179,15 @@ func TestStringBuilderPool(t *testing.T) {
 		PutStringBuilder(b)
 	})
 
+	t.Run("io.Writer", func(t *testing.T) {
+		b := GetStringBuilder()
+		fmt.Fprintf(b, "n=%d", 42)
+		if string(b.Bytes()) != "n=42" {
+			t.Errorf("Bytes() = %q, want %q", b.Bytes(), "n=42")
+		}
+		PutStringBuilder(b)
+	})
+
 	t.Run("reset on reuse", func(t *testing.T) {
 		b := GetStringBuilder()
 		b.WriteString("test")
@@ -0,0 +1,201 @@
+package preflight
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/orneryd/nornicdb/pkg/config"
+	"github.com/orneryd/nornicdb/pkg/heimdall/webhook"
+)
+
+// embeddingProviders are the values accepted for the embedding provider.
+// "none" leaves the server without an embedder.
+var embeddingProviders = map[string]bool{"local": true, "ollama": true, "openai": true, "none": true}
+
+// gpuBackends are the values accepted for gpu.backend in a config file.
+var gpuBackends = map[string]bool{"auto": true, "metal": true, "cuda": true, "opencl": true, "vulkan": true, "cpu": true, "none": true}
+
+// checkConfig validates the effective configuration.
+func checkConfig(r *Report, cfg *config.Config) {
+	if err := cfg.Validate(); err != nil {
+		r.fail(CategoryConfig, "settings", "fix the NORNICDB_* environment variable or flag named in the error", "%v", err)
+	} else {
+		r.ok(CategoryConfig, "settings", "configuration is valid")
+	}
+
+	if cfg.Server.BoltEnabled {
+		checkPort(r, "bolt port", cfg.Server.BoltPort)
+	}
+	if cfg.Server.HTTPEnabled {
+		checkPort(r, "http port", cfg.Server.HTTPPort)
+	}
+	if cfg.Server.BoltEnabled && cfg.Server.HTTPEnabled && cfg.Server.BoltPort == cfg.Server.HTTPPort {
+		r.fail(CategoryConfig, "ports", "use different --bolt-port and --http-port values",
+			"bolt and http both use port %d", cfg.Server.BoltPort)
+	}
+
+	provider := cfg.Memory.EmbeddingProvider
+	if !embeddingProviders[provider] {
+		r.fail(CategoryConfig, "embedding provider", "use local, ollama, openai or none",
+			"unknown embedding provider %q", provider)
+	} else if provider == "none" {
+		r.ok(CategoryConfig, "embedding provider", "none (embeddings disabled)")
+	} else {
+		r.ok(CategoryConfig, "embedding provider", "%s (%s, %d dims)", provider, cfg.Memory.EmbeddingModel, cfg.Memory.EmbeddingDimensions)
+	}
+
+	checkHeimdallFiles(r, cfg.Features)
+}
+
+func checkPort(r *Report, name string, port int) {
+	if port < 1 || port > 65535 {
+		r.fail(CategoryConfig, name, "ports must be between 1 and 65535", "invalid port %d", port)
+	} else {
+		r.ok(CategoryConfig, name, "%d", port)
+	}
+}
+
+// checkHeimdallFiles verifies the files referenced by Heimdall settings.
+// Personas and webhooks are operator-written and must exist and parse;
+// rules and reports are state files the server creates on first write.
+func checkHeimdallFiles(r *Report, f config.FeatureFlagsConfig) {
+	if !f.HeimdallEnabled {
+		return
+	}
+	if path := f.HeimdallPersonasFile; path != "" {
+		var file struct {
+			Personas []yaml.Node `yaml:"personas"`
+		}
+		if err := readYAML(path, &file); err != nil {
+			r.fail(CategoryConfig, "personas file", "check NORNICDB_HEIMDALL_PERSONAS_FILE", "%v", err)
+		} else {
+			r.ok(CategoryConfig, "personas file", "%s (%d personas)", path, len(file.Personas))
+		}
+	}
+	if path := f.HeimdallWebhooksFile; path != "" {
+		endpoints, err := webhook.LoadFile(path)
+		if err != nil {
+			r.fail(CategoryConfig, "webhooks file", "check NORNICDB_HEIMDALL_WEBHOOKS_FILE", "%v", err)
+		} else {
+			r.ok(CategoryConfig, "webhooks file", "%s (%d endpoints)", path, len(endpoints))
+		}
+	}
+	for _, state := range []struct{ name, path, env string }{
+		{"rules file", f.HeimdallRulesFile, "NORNICDB_HEIMDALL_RULES_FILE"},
+		{"reports file", f.HeimdallReportsFile, "NORNICDB_HEIMDALL_REPORTS_FILE"},
+	} {
+		if state.path == "" {
+			continue
+		}
+		if _, err := os.Stat(state.path); err != nil && !os.IsNotExist(err) {
+			r.fail(CategoryConfig, state.name, "check "+state.env, "%v", err)
+		} else if err != nil {
+			r.ok(CategoryConfig, state.name, "%s (created on first change)", state.path)
+		} else {
+			r.ok(CategoryConfig, state.name, "%s", state.path)
+		}
+	}
+}
+
+func readYAML(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// checkConfigFile validates a YAML configuration file. Both the nested
+// layout of nornicdb.example.yaml and the flat layout written by
+// `nornicdb init` are accepted; values are checked by key name.
+func checkConfigFile(r *Report, path string) {
+	if path == "" {
+		return
+	}
+	var doc map[string]interface{}
+	if err := readYAML(path, &doc); err != nil {
+		r.fail(CategoryConfig, "config file", "fix the YAML syntax or pass a different --config", "%v", err)
+		return
+	}
+
+	var problems []string
+	checked := 0
+	walkYAML("", doc, func(key string, value interface{}) {
+		checked++
+		if msg := checkValue(key, value); msg != "" {
+			problems = append(problems, key+": "+msg)
+		}
+	})
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		r.fail(CategoryConfig, "config file", "", "%s: %s", path, strings.Join(problems, "; "))
+		return
+	}
+	r.ok(CategoryConfig, "config file", "%s (%d settings)", path, checked)
+}
+
+// walkYAML calls fn for every leaf value with its dotted key.
+func walkYAML(prefix string, m map[string]interface{}, fn func(key string, value interface{})) {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if child, ok := v.(map[string]interface{}); ok {
+			walkYAML(key, child, fn)
+			continue
+		}
+		fn(key, v)
+	}
+}
+
+// checkValue validates a config file value by its key name and returns a
+// problem description, or "" if the value is acceptable or not checked.
+func checkValue(key string, value interface{}) string {
+	leaf := key[strings.LastIndex(key, ".")+1:]
+	switch {
+	case strings.HasSuffix(leaf, "port"):
+		n, ok := value.(int)
+		if !ok || n < 1 || n > 65535 {
+			return fmt.Sprintf("must be a port between 1 and 65535, got %v", value)
+		}
+	case key == "gpu.backend":
+		s, _ := value.(string)
+		if !gpuBackends[strings.ToLower(s)] {
+			return fmt.Sprintf("unknown GPU backend %v", value)
+		}
+	case leaf == "embedding_provider":
+		s, _ := value.(string)
+		if !embeddingProviders[s] {
+			return fmt.Sprintf("unknown embedding provider %v", value)
+		}
+	case strings.HasSuffix(leaf, "dimensions"):
+		n, ok := value.(int)
+		if !ok || n <= 0 {
+			return fmt.Sprintf("must be a positive integer, got %v", value)
+		}
+	case strings.HasSuffix(leaf, "threshold"):
+		f, ok := toFloat(value)
+		if !ok || f < 0 || f > 1 {
+			return fmt.Sprintf("must be a number between 0 and 1, got %v", value)
+		}
+	}
+	return ""
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,77 @@
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/orneryd/nornicdb/pkg/config"
+	"github.com/orneryd/nornicdb/pkg/diskspace"
+)
+
+// checkDataDir verifies that the data directory (or, if it does not exist
+// yet, its nearest existing parent) is writable and has enough free space.
+func checkDataDir(r *Report, dataDir string, minFree, warnFree uint64) {
+	if dataDir == "" {
+		r.fail(CategoryData, "data dir", "pass --data-dir or set NORNICDB_DATA_DIR", "no data directory configured")
+		return
+	}
+
+	dir, err := filepath.Abs(dataDir)
+	if err != nil {
+		r.fail(CategoryData, "data dir", "", "%s: %v", dataDir, err)
+		return
+	}
+	existing := dir
+	for {
+		st, err := os.Stat(existing)
+		if err == nil {
+			if !st.IsDir() {
+				r.fail(CategoryData, "data dir", "remove the file or choose another --data-dir", "%s is not a directory", existing)
+				return
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			r.fail(CategoryData, "data dir", "", "%v", err)
+			return
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			r.fail(CategoryData, "data dir", "", "no existing parent directory for %s", dir)
+			return
+		}
+		existing = parent
+	}
+
+	probe, err := os.CreateTemp(existing, ".nornicdb-preflight-*")
+	if err != nil {
+		r.fail(CategoryData, "data dir", "fix the ownership or permissions of the directory, or choose another --data-dir",
+			"%s is not writable: %v", existing, err)
+		return
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	if existing == dir {
+		r.ok(CategoryData, "data dir", "%s is writable", dir)
+	} else {
+		r.ok(CategoryData, "data dir", "%s will be created (%s is writable)", dir, existing)
+	}
+
+	free, total, ok := diskspace.Usage(existing)
+	switch {
+	case !ok:
+		r.ok(CategoryData, "disk space", "not reported on this platform")
+	case free < minFree:
+		r.fail(CategoryData, "disk space", "free up space or move the data directory to a larger volume",
+			"%s free of %s, need at least %s", formatBytes(free), formatBytes(total), formatBytes(minFree))
+	case free < warnFree:
+		r.warn(CategoryData, "disk space", "free up space or move the data directory to a larger volume",
+			"only %s free of %s", formatBytes(free), formatBytes(total))
+	default:
+		r.ok(CategoryData, "disk space", "%s free of %s", formatBytes(free), formatBytes(total))
+	}
+}
+
+func formatBytes(n uint64) string {
+	return config.FormatMemorySize(int64(n))
+}
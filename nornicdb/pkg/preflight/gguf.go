@@ -0,0 +1,188 @@
+package preflight
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ggufMagic is "GGUF" read as a little-endian uint32.
+const ggufMagic = 0x46554747
+
+// maxGGUFKeys bounds the metadata scan so a corrupt header cannot make the
+// check run away.
+const maxGGUFKeys = 1 << 16
+
+// GGUF metadata value types.
+const (
+	ggufUint8 uint32 = iota
+	ggufInt8
+	ggufUint16
+	ggufInt16
+	ggufUint32
+	ggufInt32
+	ggufFloat32
+	ggufBool
+	ggufString
+	ggufArray
+	ggufUint64
+	ggufInt64
+	ggufFloat64
+)
+
+// ggufFileTypes maps general.file_type to the quantization name used by
+// llama.cpp and in model file names.
+var ggufFileTypes = map[uint32]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 7: "Q8_0", 8: "Q5_0", 9: "Q5_1",
+	10: "Q2_K", 11: "Q3_K_S", 12: "Q3_K_M", 13: "Q3_K_L", 14: "Q4_K_S", 15: "Q4_K_M",
+	16: "Q5_K_S", 17: "Q5_K_M", 18: "Q6_K", 19: "IQ2_XXS", 20: "IQ2_XS", 21: "Q2_K_S",
+	22: "IQ3_XS", 23: "IQ3_XXS", 24: "IQ1_S", 25: "IQ4_NL", 26: "IQ3_S", 27: "IQ3_M",
+	28: "IQ2_S", 29: "IQ2_M", 30: "IQ4_XS", 31: "IQ1_M", 32: "BF16",
+}
+
+// quantTagPattern finds a quantization tag such as Q4_K_M or f16 in a file name.
+var quantTagPattern = regexp.MustCompile(`(?i)(?:^|[-_.])((?:i?q\d(?:_[a-z0-9]+)*)|bf16|f16|f32)(?:$|[-.])`)
+
+// ggufInfo is the subset of GGUF header metadata used by the model checks.
+type ggufInfo struct {
+	Version      uint32
+	Architecture string
+	// Quantization is the general.file_type name, or "" if the header has
+	// no file type or an unknown one.
+	Quantization string
+	FileType     uint32
+	HasFileType  bool
+}
+
+// readGGUFInfo reads the header and metadata of a GGUF model file.
+func readGGUFInfo(path string) (*ggufInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseGGUF(bufio.NewReader(f))
+}
+
+func parseGGUF(r io.Reader) (*ggufInfo, error) {
+	var header struct {
+		Magic   uint32
+		Version uint32
+		Tensors uint64
+		Keys    uint64
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("read GGUF header: %w", err)
+	}
+	if header.Magic != ggufMagic {
+		return nil, errors.New("not a GGUF file")
+	}
+	if header.Version < 2 || header.Version > 3 {
+		return nil, fmt.Errorf("unsupported GGUF version %d", header.Version)
+	}
+	if header.Keys > maxGGUFKeys {
+		return nil, fmt.Errorf("corrupt GGUF header: %d metadata keys", header.Keys)
+	}
+
+	info := &ggufInfo{Version: header.Version}
+	for i := uint64(0); i < header.Keys; i++ {
+		key, err := readGGUFString(r)
+		if err != nil {
+			return nil, fmt.Errorf("read GGUF metadata: %w", err)
+		}
+		var typ uint32
+		if err := binary.Read(r, binary.LittleEndian, &typ); err != nil {
+			return nil, fmt.Errorf("read GGUF metadata: %w", err)
+		}
+		switch {
+		case key == "general.architecture" && typ == ggufString:
+			if info.Architecture, err = readGGUFString(r); err != nil {
+				return nil, fmt.Errorf("read GGUF metadata: %w", err)
+			}
+		case key == "general.file_type" && typ == ggufUint32:
+			if err := binary.Read(r, binary.LittleEndian, &info.FileType); err != nil {
+				return nil, fmt.Errorf("read GGUF metadata: %w", err)
+			}
+			info.HasFileType = true
+			info.Quantization = ggufFileTypes[info.FileType]
+		default:
+			if err := skipGGUFValue(r, typ); err != nil {
+				return nil, fmt.Errorf("read GGUF metadata %s: %w", key, err)
+			}
+		}
+		if info.Architecture != "" && info.HasFileType {
+			break
+		}
+	}
+	return info, nil
+}
+
+func readGGUFString(r io.Reader) (string, error) {
+	var n uint64
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	if n > 1<<20 {
+		return "", fmt.Errorf("string of %d bytes", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func skipGGUFValue(r io.Reader, typ uint32) error {
+	var size int64
+	switch typ {
+	case ggufUint8, ggufInt8, ggufBool:
+		size = 1
+	case ggufUint16, ggufInt16:
+		size = 2
+	case ggufUint32, ggufInt32, ggufFloat32:
+		size = 4
+	case ggufUint64, ggufInt64, ggufFloat64:
+		size = 8
+	case ggufString:
+		var n uint64
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return err
+		}
+		size = int64(n)
+	case ggufArray:
+		var elem uint32
+		var count uint64
+		if err := binary.Read(r, binary.LittleEndian, &elem); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+			return err
+		}
+		for i := uint64(0); i < count; i++ {
+			if err := skipGGUFValue(r, elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown value type %d", typ)
+	}
+	_, err := io.CopyN(io.Discard, r, size)
+	return err
+}
+
+// quantTag returns the quantization tag in a model file name, upper-cased,
+// or "" if the name has none.
+func quantTag(name string) string {
+	name = strings.TrimSuffix(name, ".gguf")
+	matches := quantTagPattern.FindAllStringSubmatch(name, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return strings.ToUpper(matches[len(matches)-1][1])
+}
@@ -0,0 +1,70 @@
+package preflight
+
+import "github.com/orneryd/nornicdb/pkg/gpu"
+
+// gpuBackend describes one GPU backend as seen by this binary.
+type gpuBackend struct {
+	Name     string
+	Compiled bool
+	// Available reports a usable driver/runtime and at least one device.
+	Available bool
+	Devices   int
+	// Reason explains why a compiled-in backend is unavailable.
+	Reason string
+	// Hardware is set when a device was detected that the binary cannot use
+	// (e.g. an NVIDIA GPU in a build without CUDA).
+	Hardware string
+	// BuildHint explains how to get a binary with this backend.
+	BuildHint string
+}
+
+// gpuBuildHints explain how to get a binary with each backend.
+var gpuBuildHints = map[gpu.Backend]string{
+	gpu.BackendCUDA:   "build with -tags cuda (Dockerfile.cuda) and install the NVIDIA driver and CUDA toolkit",
+	gpu.BackendMetal:  "Metal is only available on macOS",
+	gpu.BackendOpenCL: "build with -tags opencl and install an OpenCL ICD for your GPU",
+	gpu.BackendVulkan: "build with -tags vulkan and install the Vulkan loader and GPU driver",
+}
+
+// probeGPUBackends reports the state of every GPU backend. It is a variable
+// so tests can substitute fixed results.
+var probeGPUBackends = func() []gpuBackend {
+	var backends []gpuBackend
+	for _, p := range gpu.Probe() {
+		b := gpuBackend{
+			Name:      string(p.Backend),
+			Compiled:  p.Compiled,
+			Available: p.Available,
+			Devices:   len(p.Devices),
+			Reason:    p.Reason,
+			BuildHint: gpuBuildHints[p.Backend],
+		}
+		if !p.Compiled && len(p.Devices) > 0 {
+			b.Hardware = p.Devices[0].Name
+		}
+		backends = append(backends, b)
+	}
+	return backends
+}
+
+// checkGPU reports each GPU backend. GPU problems are warnings because the
+// server falls back to CPU; they explain why acceleration is off.
+func checkGPU(r *Report) {
+	for _, b := range probeGPUBackends() {
+		switch {
+		case b.Compiled && b.Available:
+			r.ok(CategoryGPU, b.Name, "available (%d device(s))", b.Devices)
+		case b.Compiled && b.Reason != "":
+			r.warn(CategoryGPU, b.Name, "install or update the "+b.Name+" driver/runtime, or ignore to run on CPU",
+				"compiled in but unavailable: %s", b.Reason)
+		case b.Compiled:
+			r.warn(CategoryGPU, b.Name, "install or update the "+b.Name+" driver/runtime, or ignore to run on CPU",
+				"compiled in but no usable driver or device found")
+		case b.Hardware != "":
+			r.warn(CategoryGPU, b.Name, b.BuildHint,
+				"%s detected but this binary was built without %s support", b.Hardware, b.Name)
+		default:
+			r.ok(CategoryGPU, b.Name, "not compiled in")
+		}
+	}
+}
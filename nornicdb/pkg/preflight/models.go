@@ -0,0 +1,93 @@
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/orneryd/nornicdb/pkg/config"
+)
+
+// heimdallModelDirs are searched for the Heimdall model when no models
+// directory is configured, matching the Heimdall scheduler.
+var heimdallModelDirs = []string{"/app/models", "/data/models", "./models"}
+
+// checkModels verifies the GGUF files for the local embedder and Heimdall.
+// Remote embedding providers need no local model.
+func checkModels(r *Report, cfg *config.Config, override string) {
+	dir := modelsDir(override)
+
+	if cfg.Memory.EmbeddingProvider == "local" {
+		embedDir := dir
+		if embedDir == "" {
+			embedDir = "/data/models"
+		}
+		checkModel(r, "embedding model", filepath.Join(embedDir, cfg.Memory.EmbeddingModel+".gguf"))
+	}
+
+	if cfg.Features.HeimdallEnabled {
+		file := cfg.Features.HeimdallModel + ".gguf"
+		path := filepath.Join(dir, file)
+		if dir == "" {
+			path = filepath.Join(heimdallModelDirs[0], file)
+			for _, d := range heimdallModelDirs {
+				if _, err := os.Stat(filepath.Join(d, file)); err == nil {
+					path = filepath.Join(d, file)
+					break
+				}
+			}
+		}
+		checkModel(r, "heimdall model", path)
+	}
+}
+
+// checkModel verifies that path is a readable GGUF file and that its
+// quantization is known and agrees with any tag in the file name.
+//
+// A missing model is a warning: the server starts without the feature, as
+// BYOM images do until a model is mounted. A file that is present but not a
+// valid GGUF model fails, since the runtime would refuse or crash on it.
+func checkModel(r *Report, name, path string) {
+	st, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		r.warn(CategoryModels, name, "download the GGUF model into the models directory or set NORNICDB_MODELS_DIR",
+			"%s not found; the feature will be unavailable", path)
+		return
+	}
+	if err != nil {
+		r.fail(CategoryModels, name, "", "%v", err)
+		return
+	}
+	if st.IsDir() {
+		r.fail(CategoryModels, name, "", "%s is a directory, expected a .gguf file", path)
+		return
+	}
+
+	info, err := readGGUFInfo(path)
+	if err != nil {
+		r.fail(CategoryModels, name, "re-download the model; the file is truncated or not in GGUF format",
+			"%s: %v", path, err)
+		return
+	}
+
+	size := config.FormatMemorySize(st.Size())
+	tag := quantTag(filepath.Base(path))
+	switch {
+	case !info.HasFileType:
+		r.warn(CategoryModels, name, "", "%s (%s): header does not record a quantization", path, size)
+	case info.Quantization == "":
+		r.warn(CategoryModels, name, "the model may need a newer llama.cpp runtime",
+			"%s (%s): unknown quantization type %d", path, size, info.FileType)
+	case tag != "" && tag != info.Quantization:
+		r.warn(CategoryModels, name, "rename the file or re-download the intended quantization",
+			"%s (%s): file name says %s but the header is %s", path, size, tag, info.Quantization)
+	case info.Quantization == "F32":
+		r.warn(CategoryModels, name, "use an F16 or Q8_0/Q4_K_M build to reduce memory use",
+			"%s (%s): unquantized F32 model", path, size)
+	default:
+		arch := info.Architecture
+		if arch == "" {
+			arch = "unknown architecture"
+		}
+		r.ok(CategoryModels, name, "%s (%s, %s, %s)", path, size, arch, info.Quantization)
+	}
+}
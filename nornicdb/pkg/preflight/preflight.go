@@ -0,0 +1,199 @@
+// Package preflight validates a NornicDB installation before the server
+// starts: the effective configuration, GPU backends compiled into the
+// binary, local GGUF model files and the data directory.
+//
+// The same checks back the `nornicdb check` command and the startup checks
+// run by `nornicdb serve`, so an operator sees identical diagnostics either
+// way.
+//
+// Example:
+//
+//	cfg := config.LoadFromEnv()
+//	report := preflight.Run(preflight.Options{Config: cfg})
+//	report.WriteText(os.Stdout)
+//	if !report.OK {
+//		os.Exit(1)
+//	}
+//
+// Each check reports one of three statuses. Failures mean the server would
+// not work as configured; warnings flag degraded but usable setups, such as
+// a GPU that is present but unusable by this binary.
+package preflight
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/orneryd/nornicdb/pkg/config"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Categories group related checks in reports.
+const (
+	CategoryConfig = "config"
+	CategoryGPU    = "gpu"
+	CategoryModels = "models"
+	CategoryData   = "data"
+)
+
+// Default free space thresholds for the data directory volume.
+const (
+	DefaultMinFreeBytes  uint64 = 512 << 20 // fail below 512MB
+	DefaultWarnFreeBytes uint64 = 2 << 30   // warn below 2GB
+)
+
+// Check is the result of one preflight check.
+type Check struct {
+	Category string `json:"category"`
+	Name     string `json:"name"`
+	Status   Status `json:"status"`
+	Message  string `json:"message"`
+	// Hint suggests how to fix a warning or failure.
+	Hint string `json:"hint,omitempty"`
+}
+
+// Report collects the checks of one preflight run.
+type Report struct {
+	// OK is false when any check failed. Warnings do not affect it.
+	OK     bool    `json:"ok"`
+	Checks []Check `json:"checks"`
+}
+
+// Options configures a preflight run.
+type Options struct {
+	// Config is the effective configuration, after CLI flag overrides.
+	// Config.Database.DataDir is the data directory to check.
+	Config *config.Config
+
+	// ConfigFile is an optional YAML configuration file to validate.
+	ConfigFile string
+
+	// ModelsDir overrides NORNICDB_MODELS_DIR when locating GGUF models.
+	ModelsDir string
+
+	// MinFreeBytes and WarnFreeBytes are the free space thresholds for the
+	// data directory volume (defaults: DefaultMinFreeBytes, DefaultWarnFreeBytes).
+	MinFreeBytes  uint64
+	WarnFreeBytes uint64
+}
+
+// Run performs all checks and returns the report. It never returns nil and
+// does not modify the data directory beyond a temporary probe file.
+func Run(opts Options) *Report {
+	if opts.Config == nil {
+		opts.Config = config.LoadFromEnv()
+	}
+	if opts.MinFreeBytes == 0 {
+		opts.MinFreeBytes = DefaultMinFreeBytes
+	}
+	if opts.WarnFreeBytes == 0 {
+		opts.WarnFreeBytes = DefaultWarnFreeBytes
+	}
+
+	r := &Report{OK: true}
+	checkConfigFile(r, opts.ConfigFile)
+	checkConfig(r, opts.Config)
+	checkGPU(r)
+	checkModels(r, opts.Config, opts.ModelsDir)
+	checkDataDir(r, opts.Config.Database.DataDir, opts.MinFreeBytes, opts.WarnFreeBytes)
+	return r
+}
+
+func (r *Report) add(c Check) {
+	if c.Status == StatusFail {
+		r.OK = false
+	}
+	r.Checks = append(r.Checks, c)
+}
+
+func (r *Report) ok(category, name, format string, args ...interface{}) {
+	r.add(Check{Category: category, Name: name, Status: StatusOK, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) warn(category, name, hint, format string, args ...interface{}) {
+	r.add(Check{Category: category, Name: name, Status: StatusWarn, Message: fmt.Sprintf(format, args...), Hint: hint})
+}
+
+func (r *Report) fail(category, name, hint, format string, args ...interface{}) {
+	r.add(Check{Category: category, Name: name, Status: StatusFail, Message: fmt.Sprintf(format, args...), Hint: hint})
+}
+
+// Problems returns the checks that warned or failed, in report order.
+func (r *Report) Problems() []Check {
+	var out []Check
+	for _, c := range r.Checks {
+		if c.Status != StatusOK {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Counts returns the number of passed, warning and failed checks.
+func (r *Report) Counts() (ok, warn, fail int) {
+	for _, c := range r.Checks {
+		switch c.Status {
+		case StatusOK:
+			ok++
+		case StatusWarn:
+			warn++
+		case StatusFail:
+			fail++
+		}
+	}
+	return ok, warn, fail
+}
+
+// WriteText writes a human-readable report grouped by category.
+func (r *Report) WriteText(w io.Writer) {
+	category := ""
+	for _, c := range r.Checks {
+		if c.Category != category {
+			if category != "" {
+				fmt.Fprintln(w)
+			}
+			category = c.Category
+			fmt.Fprintf(w, "[%s]\n", category)
+		}
+		WriteCheck(w, c)
+	}
+	ok, warn, fail := r.Counts()
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n", ok, warn, fail)
+}
+
+// WriteCheck writes one check as an indented line, followed by its hint.
+func WriteCheck(w io.Writer, c Check) {
+	fmt.Fprintf(w, "   %s %-18s %s\n", statusIcon(c.Status), c.Name, c.Message)
+	if c.Hint != "" && c.Status != StatusOK {
+		fmt.Fprintf(w, "      💡 %s\n", c.Hint)
+	}
+}
+
+func statusIcon(s Status) string {
+	switch s {
+	case StatusWarn:
+		return "⚠️ "
+	case StatusFail:
+		return "❌"
+	default:
+		return "✅"
+	}
+}
+
+// modelsDir returns the directory configured for GGUF models, or "" if
+// neither the override nor NORNICDB_MODELS_DIR is set.
+func modelsDir(override string) string {
+	if override != "" {
+		return override
+	}
+	return os.Getenv("NORNICDB_MODELS_DIR")
+}
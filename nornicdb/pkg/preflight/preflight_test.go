@@ -0,0 +1,327 @@
+package preflight
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/orneryd/nornicdb/pkg/config"
+	"github.com/orneryd/nornicdb/pkg/diskspace"
+)
+
+// ggufBytes builds a minimal GGUF v3 header with a tokenizer array before
+// the keys the checks read, so parsing has to skip values correctly.
+func ggufBytes(arch string, fileType uint32) []byte {
+	var b bytes.Buffer
+	le := func(v interface{}) { _ = binary.Write(&b, binary.LittleEndian, v) }
+	str := func(s string) { le(uint64(len(s))); b.WriteString(s) }
+
+	le(uint32(ggufMagic))
+	le(uint32(3))
+	le(uint64(0)) // tensors
+	le(uint64(4)) // metadata keys
+
+	str("tokenizer.ggml.tokens")
+	le(ggufArray)
+	le(ggufString)
+	le(uint64(3))
+	str("<s>")
+	str("</s>")
+	str("hello")
+
+	str("llama.context_length")
+	le(ggufUint32)
+	le(uint32(4096))
+
+	str("general.architecture")
+	le(ggufString)
+	str(arch)
+
+	str("general.file_type")
+	le(ggufUint32)
+	le(fileType)
+	return b.Bytes()
+}
+
+func findCheck(t *testing.T, r *Report, category, name string) Check {
+	t.Helper()
+	for _, c := range r.Checks {
+		if c.Category == category && c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no %s/%s check in %+v", category, name, r.Checks)
+	return Check{}
+}
+
+func TestParseGGUF(t *testing.T) {
+	info, err := parseGGUF(bytes.NewReader(ggufBytes("qwen2", 15)))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), info.Version)
+	assert.Equal(t, "qwen2", info.Architecture)
+	assert.True(t, info.HasFileType)
+	assert.Equal(t, "Q4_K_M", info.Quantization)
+
+	_, err = parseGGUF(strings.NewReader("PK\x03\x04 definitely a zip file"))
+	assert.ErrorContains(t, err, "not a GGUF file")
+
+	old := ggufBytes("llama", 1)
+	binary.LittleEndian.PutUint32(old[4:], 1)
+	_, err = parseGGUF(bytes.NewReader(old))
+	assert.ErrorContains(t, err, "unsupported GGUF version 1")
+
+	full := ggufBytes("llama", 1)
+	_, err = parseGGUF(bytes.NewReader(full[:len(full)-10]))
+	assert.Error(t, err)
+}
+
+func TestQuantTag(t *testing.T) {
+	cases := map[string]string{
+		"qwen2.5-0.5b-instruct-q4_k_m.gguf": "Q4_K_M",
+		"bge-m3-Q8_0.gguf":                  "Q8_0",
+		"model.f16.gguf":                    "F16",
+		"Llama-3-8B-IQ4_XS.gguf":            "IQ4_XS",
+		"bge-m3.gguf":                       "",
+		"qwen2.5-0.5b-instruct.gguf":        "",
+	}
+	for name, want := range cases {
+		assert.Equal(t, want, quantTag(name), name)
+	}
+}
+
+func TestCheckModel(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		return path
+	}
+
+	r := &Report{OK: true}
+	checkModel(r, "ok", write("bge-m3-q8_0.gguf", ggufBytes("bert", 7)))
+	checkModel(r, "mismatch", write("bge-m3-q4_k_m.gguf", ggufBytes("bert", 7)))
+	checkModel(r, "f32", write("bge-m3.gguf", ggufBytes("bert", 0)))
+	checkModel(r, "unknown", write("new.gguf", ggufBytes("bert", 999)))
+	checkModel(r, "corrupt", write("broken.gguf", []byte("GGUF")))
+	checkModel(r, "missing", filepath.Join(dir, "absent.gguf"))
+
+	assert.Equal(t, StatusOK, findCheck(t, r, CategoryModels, "ok").Status)
+	assert.Contains(t, findCheck(t, r, CategoryModels, "ok").Message, "bert, Q8_0")
+	assert.Equal(t, StatusWarn, findCheck(t, r, CategoryModels, "mismatch").Status)
+	assert.Contains(t, findCheck(t, r, CategoryModels, "mismatch").Message, "file name says Q4_K_M but the header is Q8_0")
+	assert.Equal(t, StatusWarn, findCheck(t, r, CategoryModels, "f32").Status)
+	assert.Equal(t, StatusWarn, findCheck(t, r, CategoryModels, "unknown").Status)
+	assert.Equal(t, StatusFail, findCheck(t, r, CategoryModels, "corrupt").Status)
+	assert.Equal(t, StatusWarn, findCheck(t, r, CategoryModels, "missing").Status)
+	assert.False(t, r.OK)
+}
+
+func TestCheckModels(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bge-m3.gguf"), ggufBytes("bert", 1), 0644))
+
+	cfg := config.LoadFromEnv()
+	cfg.Memory.EmbeddingProvider = "local"
+	cfg.Memory.EmbeddingModel = "bge-m3"
+	cfg.Features.HeimdallEnabled = true
+	cfg.Features.HeimdallModel = "qwen"
+
+	r := &Report{OK: true}
+	checkModels(r, cfg, dir)
+	assert.Equal(t, StatusOK, findCheck(t, r, CategoryModels, "embedding model").Status)
+	heimdall := findCheck(t, r, CategoryModels, "heimdall model")
+	assert.Equal(t, StatusWarn, heimdall.Status)
+	assert.Contains(t, heimdall.Message, filepath.Join(dir, "qwen.gguf"))
+
+	// Remote providers and a disabled Heimdall need no model files.
+	cfg.Memory.EmbeddingProvider = "ollama"
+	cfg.Features.HeimdallEnabled = false
+	r = &Report{OK: true}
+	checkModels(r, cfg, dir)
+	assert.Empty(t, r.Checks)
+}
+
+func TestCheckConfig(t *testing.T) {
+	cfg := config.LoadFromEnv()
+	cfg.Auth.Enabled = false
+	r := &Report{OK: true}
+	checkConfig(r, cfg)
+	assert.True(t, r.OK, "%+v", r.Problems())
+
+	cfg.Memory.EmbeddingProvider = "cohere"
+	cfg.Server.BoltEnabled, cfg.Server.HTTPEnabled = true, true
+	cfg.Server.BoltPort, cfg.Server.HTTPPort = 7474, 7474
+	r = &Report{OK: true}
+	checkConfig(r, cfg)
+	assert.False(t, r.OK)
+	assert.Equal(t, StatusFail, findCheck(t, r, CategoryConfig, "embedding provider").Status)
+	assert.Equal(t, StatusFail, findCheck(t, r, CategoryConfig, "ports").Status)
+
+	cfg.Memory.EmbeddingProvider = "ollama"
+	cfg.Server.BoltPort = 70000
+	r = &Report{OK: true}
+	checkConfig(r, cfg)
+	assert.Equal(t, StatusFail, findCheck(t, r, CategoryConfig, "bolt port").Status)
+}
+
+func TestCheckHeimdallFiles(t *testing.T) {
+	dir := t.TempDir()
+	personas := filepath.Join(dir, "personas.yaml")
+	require.NoError(t, os.WriteFile(personas, []byte("personas:\n  - name: analyst\n    system_prompt: hi\n"), 0644))
+	webhooks := filepath.Join(dir, "webhooks.yaml")
+	require.NoError(t, os.WriteFile(webhooks, []byte("endpoints: [oops"), 0644))
+
+	r := &Report{OK: true}
+	checkHeimdallFiles(r, config.FeatureFlagsConfig{
+		HeimdallEnabled:      true,
+		HeimdallPersonasFile: personas,
+		HeimdallWebhooksFile: webhooks,
+		HeimdallRulesFile:    filepath.Join(dir, "rules.json"),
+	})
+	assert.Contains(t, findCheck(t, r, CategoryConfig, "personas file").Message, "1 personas")
+	assert.Equal(t, StatusFail, findCheck(t, r, CategoryConfig, "webhooks file").Status)
+	assert.Contains(t, findCheck(t, r, CategoryConfig, "rules file").Message, "created on first change")
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	r := &Report{OK: true}
+	checkConfigFile(r, filepath.Join("..", "..", "nornicdb.example.yaml"))
+	assert.True(t, r.OK, "%+v", r.Problems())
+
+	r = &Report{OK: true}
+	checkConfigFile(r, write("flat.yaml", "bolt_port: 7687\nembedding_provider: ollama\nembedding_dimensions: 1024\nauto_links_similarity_threshold: 0.82\n"))
+	assert.True(t, r.OK, "%+v", r.Problems())
+	assert.Contains(t, r.Checks[0].Message, "4 settings")
+
+	r = &Report{OK: true}
+	checkConfigFile(r, write("bad.yaml", "server:\n  bolt_port: 99999\ngpu:\n  backend: tpu\nembedding_dimensions: -1\nsearch:\n  similarity_threshold: 1.5\n"))
+	require.False(t, r.OK)
+	msg := r.Checks[0].Message
+	for _, want := range []string{"server.bolt_port", "gpu.backend", "embedding_dimensions", "search.similarity_threshold"} {
+		assert.Contains(t, msg, want)
+	}
+
+	r = &Report{OK: true}
+	checkConfigFile(r, write("syntax.yaml", "server: [unclosed"))
+	assert.False(t, r.OK)
+
+	r = &Report{OK: true}
+	checkConfigFile(r, "")
+	assert.Empty(t, r.Checks)
+}
+
+func TestCheckGPU(t *testing.T) {
+	orig := probeGPUBackends
+	defer func() { probeGPUBackends = orig }()
+	probeGPUBackends = func() []gpuBackend {
+		return []gpuBackend{
+			{Name: "cuda", Hardware: "NVIDIA RTX 4090", BuildHint: "build with -tags cuda"},
+			{Name: "metal"},
+			{Name: "opencl", Compiled: true},
+			{Name: "vulkan", Compiled: true, Available: true, Devices: 2},
+			{Name: "cuda-old", Compiled: true, Reason: "CUDA driver version is insufficient for CUDA runtime version"},
+		}
+	}
+
+	r := &Report{OK: true}
+	checkGPU(r)
+	assert.True(t, r.OK, "GPU problems are warnings, not failures")
+	cuda := findCheck(t, r, CategoryGPU, "cuda")
+	assert.Equal(t, StatusWarn, cuda.Status)
+	assert.Contains(t, cuda.Message, "NVIDIA RTX 4090 detected")
+	assert.Equal(t, "build with -tags cuda", cuda.Hint)
+	assert.Equal(t, "not compiled in", findCheck(t, r, CategoryGPU, "metal").Message)
+	assert.Equal(t, StatusWarn, findCheck(t, r, CategoryGPU, "opencl").Status)
+	assert.Equal(t, "available (2 device(s))", findCheck(t, r, CategoryGPU, "vulkan").Message)
+	assert.Contains(t, findCheck(t, r, CategoryGPU, "cuda-old").Message, "driver version is insufficient")
+}
+
+func TestCheckDataDir(t *testing.T) {
+	dir := t.TempDir()
+
+	r := &Report{OK: true}
+	checkDataDir(r, dir, 1, 1)
+	assert.True(t, r.OK, "%+v", r.Problems())
+	assert.Contains(t, findCheck(t, r, CategoryData, "data dir").Message, "is writable")
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "probe file must be removed")
+
+	r = &Report{OK: true}
+	checkDataDir(r, filepath.Join(dir, "a", "b"), 1, 1)
+	assert.Contains(t, findCheck(t, r, CategoryData, "data dir").Message, "will be created")
+	_, err = os.Stat(filepath.Join(dir, "a"))
+	assert.True(t, os.IsNotExist(err), "check must not create the data directory")
+
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	r = &Report{OK: true}
+	checkDataDir(r, file, 1, 1)
+	assert.Equal(t, StatusFail, findCheck(t, r, CategoryData, "data dir").Status)
+
+	if _, _, ok := diskspace.Usage(dir); ok {
+		r = &Report{OK: true}
+		checkDataDir(r, dir, 1<<62, 1<<62)
+		assert.Equal(t, StatusFail, findCheck(t, r, CategoryData, "disk space").Status)
+
+		r = &Report{OK: true}
+		checkDataDir(r, dir, 1, 1<<62)
+		assert.Equal(t, StatusWarn, findCheck(t, r, CategoryData, "disk space").Status)
+		assert.True(t, r.OK)
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	orig := probeGPUBackends
+	defer func() { probeGPUBackends = orig }()
+	probeGPUBackends = func() []gpuBackend { return []gpuBackend{{Name: "cuda"}} }
+
+	cfg := config.LoadFromEnv()
+	cfg.Auth.Enabled = false
+	cfg.Memory.EmbeddingProvider = "ollama"
+	cfg.Features.HeimdallEnabled = false
+	cfg.Database.DataDir = t.TempDir()
+
+	r := Run(Options{Config: cfg, MinFreeBytes: 1, WarnFreeBytes: 1})
+	require.True(t, r.OK, "%+v", r.Problems())
+	assert.Empty(t, r.Problems())
+
+	var text bytes.Buffer
+	r.WriteText(&text)
+	assert.Contains(t, text.String(), "[config]")
+	assert.Contains(t, text.String(), "[data]")
+	assert.Contains(t, text.String(), "0 warnings, 0 failed")
+
+	data, err := json.Marshal(r)
+	require.NoError(t, err)
+	var decoded Report
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.True(t, decoded.OK)
+	assert.Equal(t, r.Checks, decoded.Checks)
+
+	models := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(models, "bge-m3.gguf"), []byte("not a model"), 0644))
+	cfg.Memory.EmbeddingProvider = "local"
+	cfg.Memory.EmbeddingModel = "bge-m3"
+	r = Run(Options{Config: cfg, ModelsDir: models, MinFreeBytes: 1, WarnFreeBytes: 1})
+	assert.False(t, r.OK)
+	require.Len(t, r.Problems(), 1)
+	text.Reset()
+	WriteCheck(&text, r.Problems()[0])
+	assert.Contains(t, text.String(), "❌")
+	assert.Contains(t, text.String(), "💡 re-download the model")
+}
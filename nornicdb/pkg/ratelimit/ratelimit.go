@@ -0,0 +1,431 @@
+// Package ratelimit provides per-user, per-role and per-IP query rate limiting
+// for the Bolt and HTTP servers.
+//
+// Three limits are enforced, each as a token bucket or counter keyed by the
+// caller:
+//   - Queries per second (token bucket with burst)
+//   - Concurrent in-flight queries
+//   - Result bytes per second (token bucket that may go into debt; a large
+//     result is always delivered, but later queries wait until it is paid off)
+//
+// Limits are resolved per caller in this order: explicit user limits, the
+// first of the caller's roles that has limits configured, then the default.
+// Anonymous callers are keyed by IP and use IP limits or the default.
+// An IP with explicitly configured limits is additionally enforced for
+// authenticated callers connecting from it.
+//
+// Example:
+//
+//	limiter := ratelimit.New(ratelimit.Config{
+//		Enabled: true,
+//		Default: ratelimit.Limits{QueriesPerSecond: 50, Burst: 100, MaxConcurrent: 8},
+//		Roles:   map[string]ratelimit.Limits{"admin": {}}, // unlimited
+//	})
+//
+//	release, err := limiter.Acquire(ratelimit.Principal{User: "alice", Roles: []string{"editor"}, IP: ip})
+//	if err != nil {
+//		var te *ratelimit.ThrottleError
+//		errors.As(err, &te) // te.RetryAfter tells the client when to retry
+//		return err
+//	}
+//	defer release()
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit names reported in ThrottleError and Stats.
+const (
+	LimitQueriesPerSecond = "queries_per_second"
+	LimitConcurrent       = "concurrent_queries"
+	LimitResultBytes      = "result_bytes_per_second"
+)
+
+// idleTTL is how long an idle caller's state is kept before being evicted.
+const idleTTL = 10 * time.Minute
+
+// Limits defines the limits applied to a single caller. Zero means unlimited.
+type Limits struct {
+	QueriesPerSecond     float64 // Sustained query rate
+	Burst                int     // Query burst size (defaults to ceil(QueriesPerSecond))
+	MaxConcurrent        int     // Maximum in-flight queries
+	ResultBytesPerSecond int64   // Sustained result throughput
+}
+
+// Unlimited reports whether no limit is set.
+func (l Limits) Unlimited() bool {
+	return l.QueriesPerSecond <= 0 && l.MaxConcurrent <= 0 && l.ResultBytesPerSecond <= 0
+}
+
+func (l Limits) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.QueriesPerSecond))
+}
+
+// Config configures a Limiter.
+type Config struct {
+	Enabled bool
+	Default Limits            // Applied when no more specific limits match
+	Users   map[string]Limits // Keyed by username
+	Roles   map[string]Limits // Keyed by role name
+	IPs     map[string]Limits // Keyed by client IP (no port)
+}
+
+// Principal identifies the caller a query is charged to.
+type Principal struct {
+	User  string   // Authenticated username ("" for anonymous)
+	Roles []string // Roles of the authenticated user
+	IP    string   // Client IP; a trailing ":port" is stripped
+}
+
+type principalKey struct{}
+
+// NewContext returns a context carrying the principal a query was admitted
+// for, so downstream layers (the live query registry) can attribute it.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored by NewContext.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	if ctx == nil {
+		return Principal{}, false
+	}
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// ThrottleError is returned when a caller exceeds one of its limits.
+type ThrottleError struct {
+	Limit      string        // One of the Limit* constants
+	Key        string        // Caller key, e.g. "user:alice" or "ip:10.0.0.1"
+	RetryAfter time.Duration // Suggested wait before retrying (0 = when a query finishes)
+}
+
+// Error implements the error interface.
+func (e *ThrottleError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limit exceeded: %s for %s, retry after %s",
+			e.Limit, e.Key, e.RetryAfter.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("rate limit exceeded: %s for %s, retry when a running query completes", e.Limit, e.Key)
+}
+
+// Stats reports limiter activity since startup.
+type Stats struct {
+	Throttled map[string]int64 // Rejected queries by limit name
+	Tracked   int              // Callers currently tracked
+}
+
+// bucket is a token bucket refilled continuously at a fixed rate.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) refill(now time.Time, rate, capacity float64) {
+	if b.last.IsZero() {
+		b.tokens = capacity
+	} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+	}
+	b.last = now
+}
+
+// callerState holds the live counters for a single caller key.
+type callerState struct {
+	limits   Limits
+	queries  bucket
+	bytes    bucket
+	inflight int
+	lastSeen time.Time
+}
+
+// Limiter enforces Limits per caller. Safe for concurrent use.
+// A nil *Limiter allows everything.
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	callers   map[string]*callerState
+	throttled map[string]int64
+	lastSweep time.Time
+}
+
+// New creates a Limiter from cfg.
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:       cfg,
+		now:       time.Now,
+		callers:   make(map[string]*callerState),
+		throttled: make(map[string]int64),
+	}
+}
+
+// Enabled reports whether the limiter enforces anything.
+func (l *Limiter) Enabled() bool {
+	return l != nil && l.cfg.Enabled
+}
+
+type resolved struct {
+	key    string
+	limits Limits
+}
+
+// resolve returns the caller keys and limits that apply to p.
+func (l *Limiter) resolve(p Principal) []resolved {
+	ip := stripPort(p.IP)
+	var out []resolved
+	if p.User != "" {
+		limits, ok := l.cfg.Users[p.User]
+		if !ok {
+			limits, ok = l.roleLimits(p.Roles)
+		}
+		if !ok {
+			limits = l.cfg.Default
+		}
+		out = append(out, resolved{key: "user:" + p.User, limits: limits})
+		if ipLimits, ok := l.cfg.IPs[ip]; ok && ip != "" {
+			out = append(out, resolved{key: "ip:" + ip, limits: ipLimits})
+		}
+		return out
+	}
+	limits, ok := l.cfg.IPs[ip]
+	if !ok {
+		limits = l.cfg.Default
+	}
+	if ip == "" {
+		ip = "unknown"
+	}
+	return append(out, resolved{key: "ip:" + ip, limits: limits})
+}
+
+func (l *Limiter) roleLimits(roles []string) (Limits, bool) {
+	for _, role := range roles {
+		if limits, ok := l.cfg.Roles[role]; ok {
+			return limits, true
+		}
+	}
+	return Limits{}, false
+}
+
+func (l *Limiter) state(key string, limits Limits, now time.Time) *callerState {
+	st, ok := l.callers[key]
+	if !ok {
+		st = &callerState{}
+		l.callers[key] = st
+	}
+	st.limits = limits
+	st.lastSeen = now
+	return st
+}
+
+// Acquire admits one query for p or returns a *ThrottleError.
+// On success the returned release func must be called when the query
+// finishes; it is safe to call more than once.
+func (l *Limiter) Acquire(p Principal) (release func(), err error) {
+	if !l.Enabled() {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	targets := l.resolve(p)
+	states := make([]*callerState, 0, len(targets))
+
+	// Check every applicable limit before charging any of them, so a rejected
+	// query never consumes tokens.
+	for _, t := range targets {
+		if t.limits.Unlimited() {
+			continue
+		}
+		st := l.state(t.key, t.limits, now)
+		if err := st.check(t.key, now); err != nil {
+			l.throttled[err.Limit]++
+			return nil, err
+		}
+		states = append(states, st)
+	}
+
+	for _, st := range states {
+		if st.limits.QueriesPerSecond > 0 {
+			st.queries.tokens--
+		}
+		st.inflight++
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			for _, st := range states {
+				st.inflight--
+				st.lastSeen = l.now()
+			}
+			l.mu.Unlock()
+		})
+	}, nil
+}
+
+// check reports whether one more query fits within st's limits.
+func (st *callerState) check(key string, now time.Time) *ThrottleError {
+	lim := st.limits
+	if lim.MaxConcurrent > 0 && st.inflight >= lim.MaxConcurrent {
+		return &ThrottleError{Limit: LimitConcurrent, Key: key}
+	}
+	if lim.QueriesPerSecond > 0 {
+		st.queries.refill(now, lim.QueriesPerSecond, lim.burst())
+		if st.queries.tokens < 1 {
+			wait := (1 - st.queries.tokens) / lim.QueriesPerSecond
+			return &ThrottleError{Limit: LimitQueriesPerSecond, Key: key, RetryAfter: seconds(wait)}
+		}
+	}
+	if lim.ResultBytesPerSecond > 0 {
+		rate := float64(lim.ResultBytesPerSecond)
+		st.bytes.refill(now, rate, rate)
+		if st.bytes.tokens < 0 {
+			return &ThrottleError{Limit: LimitResultBytes, Key: key, RetryAfter: seconds(-st.bytes.tokens / rate)}
+		}
+	}
+	return nil
+}
+
+// ConsumeResultBytes charges n bytes of result data to p.
+// The charge is never rejected; it is paid back before p's next query is
+// admitted.
+func (l *Limiter) ConsumeResultBytes(p Principal, n int64) {
+	if !l.Enabled() || n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for _, t := range l.resolve(p) {
+		if t.limits.ResultBytesPerSecond <= 0 {
+			continue
+		}
+		st := l.state(t.key, t.limits, now)
+		rate := float64(t.limits.ResultBytesPerSecond)
+		st.bytes.refill(now, rate, rate)
+		st.bytes.tokens -= float64(n)
+	}
+}
+
+// Stats returns a snapshot of limiter activity.
+func (l *Limiter) Stats() Stats {
+	stats := Stats{Throttled: map[string]int64{
+		LimitQueriesPerSecond: 0,
+		LimitConcurrent:       0,
+		LimitResultBytes:      0,
+	}}
+	if l == nil {
+		return stats
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, v := range l.throttled {
+		stats.Throttled[k] = v
+	}
+	stats.Tracked = len(l.callers)
+	return stats
+}
+
+// sweep evicts idle callers. Called with l.mu held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, st := range l.callers {
+		if st.inflight == 0 && now.Sub(st.lastSeen) > idleTTL {
+			delete(l.callers, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// stripPort removes a trailing port from host:port or [v6]:port.
+func stripPort(addr string) string {
+	if strings.HasPrefix(addr, "[") {
+		if i := strings.Index(addr, "]"); i > 0 {
+			return addr[1:i]
+		}
+	}
+	if i := strings.LastIndex(addr, ":"); i > 0 && strings.Count(addr, ":") == 1 {
+		return addr[:i]
+	}
+	return addr
+}
+
+// ParseLimits parses a limits spec of the form "qps/burst/concurrent/bytes".
+// Trailing fields may be omitted and empty fields mean unlimited, e.g.
+// "10" (10 qps), "10/20/4" or "//2/1048576".
+func ParseLimits(spec string) (Limits, error) {
+	var lim Limits
+	parts := strings.Split(strings.TrimSpace(spec), "/")
+	if len(parts) > 4 {
+		return lim, fmt.Errorf("invalid rate limit %q: expected qps/burst/concurrent/bytes", spec)
+	}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var err error
+		switch i {
+		case 0:
+			lim.QueriesPerSecond, err = strconv.ParseFloat(part, 64)
+		case 1:
+			lim.Burst, err = strconv.Atoi(part)
+		case 2:
+			lim.MaxConcurrent, err = strconv.Atoi(part)
+		case 3:
+			lim.ResultBytesPerSecond, err = strconv.ParseInt(part, 10, 64)
+		}
+		if err != nil {
+			return lim, fmt.Errorf("invalid rate limit %q: %w", spec, err)
+		}
+	}
+	return lim, nil
+}
+
+// ParseOverrides parses "name=spec" entries (see ParseLimits) into a map.
+//
+// Example:
+//
+//	users, err := ratelimit.ParseOverrides([]string{"alice=100/200", "batch=5/5/1"})
+func ParseOverrides(entries []string) (map[string]Limits, error) {
+	out := make(map[string]Limits, len(entries))
+	for _, entry := range entries {
+		name, spec, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid rate limit override %q: expected name=qps/burst/concurrent/bytes", entry)
+		}
+		lim, err := ParseLimits(spec)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = lim
+	}
+	return out, nil
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLimiter returns a limiter with a controllable clock.
+func newTestLimiter(cfg Config) (*Limiter, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	l := New(cfg)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func throttleLimit(t *testing.T, err error) string {
+	t.Helper()
+	var te *ThrottleError
+	require.True(t, errors.As(err, &te), "expected ThrottleError, got %v", err)
+	return te.Limit
+}
+
+func TestLimiter_Disabled(t *testing.T) {
+	l := New(Config{Default: Limits{QueriesPerSecond: 1, MaxConcurrent: 1}})
+	for i := 0; i < 10; i++ {
+		_, err := l.Acquire(Principal{IP: "10.0.0.1"})
+		require.NoError(t, err)
+	}
+
+	var nilLimiter *Limiter
+	release, err := nilLimiter.Acquire(Principal{})
+	require.NoError(t, err)
+	release()
+	nilLimiter.ConsumeResultBytes(Principal{}, 100)
+}
+
+func TestLimiter_QueriesPerSecond(t *testing.T) {
+	l, now := newTestLimiter(Config{Enabled: true, Default: Limits{QueriesPerSecond: 2, Burst: 2}})
+	p := Principal{User: "alice"}
+
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire(p)
+		require.NoError(t, err)
+		release()
+	}
+
+	_, err := l.Acquire(p)
+	require.Error(t, err)
+	assert.Equal(t, LimitQueriesPerSecond, throttleLimit(t, err))
+	var te *ThrottleError
+	errors.As(err, &te)
+	assert.Equal(t, "user:alice", te.Key)
+	assert.Equal(t, 500*time.Millisecond, te.RetryAfter)
+
+	*now = now.Add(500 * time.Millisecond)
+	_, err = l.Acquire(p)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), l.Stats().Throttled[LimitQueriesPerSecond])
+}
+
+func TestLimiter_Concurrent(t *testing.T) {
+	l, _ := newTestLimiter(Config{Enabled: true, Default: Limits{MaxConcurrent: 1}})
+	p := Principal{IP: "10.0.0.1:5000"}
+
+	release, err := l.Acquire(p)
+	require.NoError(t, err)
+
+	_, err = l.Acquire(Principal{IP: "10.0.0.1:5001"})
+	require.Error(t, err)
+	assert.Equal(t, LimitConcurrent, throttleLimit(t, err))
+	assert.Contains(t, err.Error(), "ip:10.0.0.1")
+
+	release()
+	release() // idempotent
+
+	release, err = l.Acquire(p)
+	require.NoError(t, err)
+	release()
+}
+
+func TestLimiter_ResultBytes(t *testing.T) {
+	l, now := newTestLimiter(Config{Enabled: true, Default: Limits{ResultBytesPerSecond: 1000}})
+	p := Principal{User: "bob"}
+
+	release, err := l.Acquire(p)
+	require.NoError(t, err)
+	l.ConsumeResultBytes(p, 3000) // large result is delivered, caller goes into debt
+	release()
+
+	_, err = l.Acquire(p)
+	require.Error(t, err)
+	assert.Equal(t, LimitResultBytes, throttleLimit(t, err))
+
+	*now = now.Add(2 * time.Second)
+	_, err = l.Acquire(p)
+	require.NoError(t, err)
+}
+
+func TestLimiter_Resolution(t *testing.T) {
+	l, _ := newTestLimiter(Config{
+		Enabled: true,
+		Default: Limits{MaxConcurrent: 1},
+		Users:   map[string]Limits{"alice": {MaxConcurrent: 3}},
+		Roles:   map[string]Limits{"admin": {}},
+		IPs:     map[string]Limits{"10.0.0.9": {MaxConcurrent: 2}},
+	})
+
+	t.Run("user override", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			_, err := l.Acquire(Principal{User: "alice", Roles: []string{"admin"}})
+			require.NoError(t, err)
+		}
+		_, err := l.Acquire(Principal{User: "alice"})
+		require.Error(t, err)
+	})
+
+	t.Run("role unlimited", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			_, err := l.Acquire(Principal{User: "root", Roles: []string{"viewer", "admin"}})
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("ip limits also apply to users", func(t *testing.T) {
+		_, err := l.Acquire(Principal{User: "root", Roles: []string{"admin"}, IP: "10.0.0.9:1"})
+		require.NoError(t, err)
+		_, err = l.Acquire(Principal{IP: "10.0.0.9:2"})
+		require.NoError(t, err)
+		_, err = l.Acquire(Principal{User: "root", Roles: []string{"admin"}, IP: "10.0.0.9:3"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ip:10.0.0.9")
+	})
+
+	t.Run("anonymous default", func(t *testing.T) {
+		_, err := l.Acquire(Principal{IP: "[::1]:7687"})
+		require.NoError(t, err)
+		_, err = l.Acquire(Principal{IP: "[::1]:7688"})
+		require.Error(t, err)
+	})
+}
+
+func TestParseOverrides(t *testing.T) {
+	m, err := ParseOverrides([]string{"alice=10/20/4/1048576", "batch=//1", "10.0.0.1=5"})
+	require.NoError(t, err)
+	assert.Equal(t, Limits{QueriesPerSecond: 10, Burst: 20, MaxConcurrent: 4, ResultBytesPerSecond: 1048576}, m["alice"])
+	assert.Equal(t, Limits{MaxConcurrent: 1}, m["batch"])
+	assert.Equal(t, Limits{QueriesPerSecond: 5}, m["10.0.0.1"])
+
+	_, err = ParseOverrides([]string{"alice"})
+	assert.Error(t, err)
+	_, err = ParseOverrides([]string{"alice=x"})
+	assert.Error(t, err)
+	_, err = ParseLimits("1/2/3/4/5")
+	assert.Error(t, err)
+}
+
+func TestPrincipalContext(t *testing.T) {
+	_, ok := PrincipalFromContext(context.Background())
+	assert.False(t, ok)
+
+	p := Principal{User: "alice", Roles: []string{"editor"}, IP: "10.0.0.1:5000"}
+	got, ok := PrincipalFromContext(NewContext(context.Background(), p))
+	require.True(t, ok)
+	assert.Equal(t, p, got)
+}
@@ -0,0 +1,236 @@
+// Package redact masks personally identifiable information (PII) in query
+// text, query parameters and property maps before they leave the storage
+// layer through logs or event streams.
+//
+// Two kinds of rules are supported:
+//   - Property names: values stored under (or assigned to) a sensitive
+//     property or parameter name are replaced wholesale, both in parameter
+//     and property maps and in literal Cypher such as {ssn: '123-45-6789'}
+//     or n.password = 'hunter2'. Names match case-insensitively.
+//   - Patterns: regular expressions applied to every string; matches are
+//     replaced by the mask. Built-in patterns ("email", "ssn",
+//     "credit_card", "phone", "ipv4") can be referenced by name.
+//
+// The redactor is applied to the audit log, the slow query log, Heimdall
+// prompts and the Heimdall database event stream (node, relationship and
+// query change events delivered to plugins).
+//
+// Example:
+//
+//	r, err := redact.New(redact.Config{
+//		Enabled:    true,
+//		Properties: []string{"ssn", "password"},
+//		Patterns:   []string{"email", `\bMRN-\d+\b`},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//
+//	r.Query("CREATE (:Person {name: 'Ann', ssn: '123-45-6789'})")
+//	// CREATE (:Person {name: 'Ann', ssn: '[REDACTED]'})
+//
+//	r.Map(map[string]interface{}{"email": "ann@example.com", "ssn": "123-45-6789"})
+//	// map[email:[REDACTED] ssn:[REDACTED]]
+//
+// All methods are safe on a nil *Redactor and return their input unchanged,
+// so callers can hold an optional redactor without nil checks.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultMask replaces redacted values when Config.Mask is empty.
+const DefaultMask = "[REDACTED]"
+
+// BuiltinPatterns are regular expressions for common PII, referenced by name
+// in Config.Patterns.
+var BuiltinPatterns = map[string]string{
+	"email":       `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`,
+	"ssn":         `\b\d{3}-\d{2}-\d{4}\b`,
+	"credit_card": `\b(?:\d[ \-]?){13,16}\b`,
+	"phone":       `\+?\b\d{1,3}[ .\-]?\(?\d{3}\)?[ .\-]?\d{3}[ .\-]?\d{4}\b`,
+	"ipv4":        `\b(?:\d{1,3}\.){3}\d{1,3}\b`,
+}
+
+// Config configures a Redactor.
+type Config struct {
+	// Enabled turns redaction on. A disabled redactor returns input unchanged.
+	Enabled bool
+	// Properties are sensitive property/parameter names (case-insensitive).
+	Properties []string
+	// Patterns are regular expressions or names of BuiltinPatterns.
+	Patterns []string
+	// Mask replaces redacted values (default: DefaultMask).
+	Mask string
+}
+
+// Redactor masks sensitive values. It is immutable and safe for concurrent use.
+type Redactor struct {
+	mask       string
+	properties map[string]bool
+	patterns   []*regexp.Regexp
+	literal    *regexp.Regexp // sensitive property assignments in query text
+}
+
+// New compiles a Redactor from config. It returns nil (a no-op redactor) when
+// redaction is disabled, and an error for invalid patterns.
+func New(config Config) (*Redactor, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	r := &Redactor{
+		mask:       config.Mask,
+		properties: make(map[string]bool, len(config.Properties)),
+	}
+	if r.mask == "" {
+		r.mask = DefaultMask
+	}
+
+	names := make([]string, 0, len(config.Properties))
+	for _, p := range config.Properties {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" || r.properties[p] {
+			continue
+		}
+		r.properties[p] = true
+		names = append(names, regexp.QuoteMeta(p))
+	}
+	if len(names) > 0 {
+		// Longest first so "password_hash" wins over "password"
+		sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+		r.literal = regexp.MustCompile(`(?i)(\b(?:` + strings.Join(names, "|") + `)\b\s*(?::|=)\s*)` +
+			`('(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|-?\d+(?:\.\d+)?)`)
+	}
+
+	for _, p := range config.Patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if builtin, ok := BuiltinPatterns[strings.ToLower(p)]; ok {
+			p = builtin
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	return r, nil
+}
+
+// Enabled reports whether r redacts anything.
+func (r *Redactor) Enabled() bool {
+	return r != nil
+}
+
+// Mask returns the replacement string for redacted values.
+func (r *Redactor) Mask() string {
+	if r == nil {
+		return DefaultMask
+	}
+	return r.mask
+}
+
+// Sensitive reports whether values under the given property or parameter
+// name must be masked.
+func (r *Redactor) Sensitive(name string) bool {
+	if r == nil {
+		return false
+	}
+	return r.properties[strings.ToLower(name)]
+}
+
+// String applies the pattern rules to s.
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllLiteralString(s, r.mask)
+	}
+	return s
+}
+
+// Query masks literal values assigned to sensitive properties in Cypher
+// query text, then applies the pattern rules.
+func (r *Redactor) Query(query string) string {
+	if r == nil || query == "" {
+		return query
+	}
+	if r.literal != nil {
+		quoted := "'" + r.mask + "'"
+		query = r.literal.ReplaceAllString(query, "${1}"+strings.ReplaceAll(quoted, "$", "$$"))
+	}
+	return r.String(query)
+}
+
+// Map returns a copy of m with sensitive keys masked and pattern rules
+// applied to string values, recursing into nested maps and lists. It is used
+// for both query parameters and node/relationship properties.
+func (r *Redactor) Map(m map[string]interface{}) map[string]interface{} {
+	if r == nil || m == nil {
+		return m
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if r.Sensitive(k) {
+			out[k] = r.mask
+			continue
+		}
+		out[k] = r.Value(v)
+	}
+	return out
+}
+
+// StringMap is Map for map[string]string values such as audit metadata.
+func (r *Redactor) StringMap(m map[string]string) map[string]string {
+	if r == nil || m == nil {
+		return m
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if r.Sensitive(k) {
+			out[k] = r.mask
+			continue
+		}
+		out[k] = r.String(v)
+	}
+	return out
+}
+
+// Value applies the pattern rules to v, recursing into maps and lists.
+// Values of other types are returned unchanged.
+func (r *Redactor) Value(v interface{}) interface{} {
+	if r == nil {
+		return v
+	}
+	switch val := v.(type) {
+	case string:
+		return r.String(val)
+	case map[string]interface{}:
+		return r.Map(val)
+	case map[string]string:
+		return r.StringMap(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = r.Value(item)
+		}
+		return out
+	case []string:
+		out := make([]string, len(val))
+		for i, item := range val {
+			out[i] = r.String(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
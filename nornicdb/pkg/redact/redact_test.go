@@ -0,0 +1,123 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedactor(t *testing.T) *Redactor {
+	t.Helper()
+	r, err := New(Config{
+		Enabled:    true,
+		Properties: []string{"ssn", "Password"},
+		Patterns:   []string{"email", `\bMRN-\d+\b`},
+	})
+	require.NoError(t, err)
+	return r
+}
+
+func TestNew(t *testing.T) {
+	r, err := New(Config{Properties: []string{"ssn"}})
+	require.NoError(t, err)
+	assert.Nil(t, r, "disabled config yields a no-op redactor")
+	assert.False(t, r.Enabled())
+
+	_, err = New(Config{Enabled: true, Patterns: []string{"("}})
+	assert.Error(t, err)
+}
+
+func TestRedactor_Query(t *testing.T) {
+	r := newTestRedactor(t)
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "map literal",
+			query: "CREATE (:Person {name: 'Ann', ssn: '123-45-6789'})",
+			want:  "CREATE (:Person {name: 'Ann', ssn: '[REDACTED]'})",
+		},
+		{
+			name:  "set clause case-insensitive",
+			query: `MATCH (u:User) SET u.PASSWORD = "hunter2" RETURN u`,
+			want:  `MATCH (u:User) SET u.PASSWORD = '[REDACTED]' RETURN u`,
+		},
+		{
+			name:  "numeric literal",
+			query: "MATCH (p {ssn: 123456789}) RETURN p",
+			want:  "MATCH (p {ssn: '[REDACTED]'}) RETURN p",
+		},
+		{
+			name:  "escaped quote",
+			query: `CREATE ({password: 'it\'s secret', role: 'x'})`,
+			want:  `CREATE ({password: '[REDACTED]', role: 'x'})`,
+		},
+		{
+			name:  "pattern",
+			query: "MATCH (p {contact: 'ann@example.com', id: 'MRN-42'}) RETURN p",
+			want:  "MATCH (p {contact: '[REDACTED]', id: '[REDACTED]'}) RETURN p",
+		},
+		{
+			name:  "parameter reference untouched",
+			query: "CREATE (:Person {ssn: $ssn})",
+			want:  "CREATE (:Person {ssn: $ssn})",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.Query(tt.query))
+		})
+	}
+}
+
+func TestRedactor_Map(t *testing.T) {
+	r := newTestRedactor(t)
+	in := map[string]interface{}{
+		"name": "Ann",
+		"SSN":  "123-45-6789",
+		"age":  42,
+		"note": "mail ann@example.com",
+		"nested": map[string]interface{}{
+			"password": "hunter2",
+			"tags":     []interface{}{"MRN-7", "ok"},
+		},
+	}
+
+	out := r.Map(in)
+	assert.Equal(t, "Ann", out["name"])
+	assert.Equal(t, DefaultMask, out["SSN"])
+	assert.Equal(t, 42, out["age"])
+	assert.Equal(t, "mail [REDACTED]", out["note"])
+	nested := out["nested"].(map[string]interface{})
+	assert.Equal(t, DefaultMask, nested["password"])
+	assert.Equal(t, []interface{}{DefaultMask, "ok"}, nested["tags"])
+
+	// Input is not modified
+	assert.Equal(t, "123-45-6789", in["SSN"])
+}
+
+func TestRedactor_StringMap(t *testing.T) {
+	r := newTestRedactor(t)
+	out := r.StringMap(map[string]string{"ssn": "1", "query": "x ann@example.com"})
+	assert.Equal(t, map[string]string{"ssn": DefaultMask, "query": "x " + DefaultMask}, out)
+}
+
+func TestRedactor_CustomMask(t *testing.T) {
+	r, err := New(Config{Enabled: true, Properties: []string{"token"}, Patterns: []string{"ssn"}, Mask: "$1***"})
+	require.NoError(t, err)
+	assert.Equal(t, "{token: '$1***'}", r.Query("{token: 'abc'}"))
+	assert.Equal(t, "id $1***", r.String("id 123-45-6789"))
+}
+
+func TestRedactor_Nil(t *testing.T) {
+	var r *Redactor
+	params := map[string]interface{}{"ssn": "123-45-6789"}
+	assert.Equal(t, "q {ssn: '1'}", r.Query("q {ssn: '1'}"))
+	assert.Equal(t, params, r.Map(params))
+	assert.False(t, r.Sensitive("ssn"))
+	assert.Equal(t, DefaultMask, r.Mask())
+}
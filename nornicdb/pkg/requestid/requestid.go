@@ -0,0 +1,69 @@
+// Package requestid provides request ID generation and context propagation.
+//
+// A request ID is generated once per client request (a Bolt RUN, an HTTP
+// call) and carried through context.Context into the Cypher executor, storage
+// transactions and Heimdall events so log lines from every layer can be
+// correlated back to the request that caused them.
+//
+// Example:
+//
+//	ctx = requestid.NewContext(ctx, requestid.New())
+//	result, err := executor.Execute(ctx, query, params)
+//
+//	// Anywhere downstream:
+//	log.Printf("[req=%s] slow query", requestid.FromContext(ctx))
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// HeaderName is the HTTP header used to accept and return request IDs.
+const HeaderName = "X-Request-ID"
+
+type contextKey struct{}
+
+// fallbackCounter guarantees uniqueness if crypto/rand is unavailable.
+var fallbackCounter uint64
+
+// New generates a new random request ID (16 hex characters).
+func New() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		n := atomic.AddUint64(&fallbackCounter, 1)
+		return fmt.Sprintf("%x%04x", time.Now().UnixNano(), n&0xffff)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// NewContext returns a copy of ctx carrying the given request ID.
+func NewContext(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or "" if none.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Ensure returns ctx unchanged if it already carries a request ID, otherwise
+// a copy with a freshly generated one. The effective ID is returned as well.
+func Ensure(ctx context.Context) (context.Context, string) {
+	if id := FromContext(ctx); id != "" {
+		return ctx, id
+	}
+	id := New()
+	return NewContext(ctx, id), id
+}
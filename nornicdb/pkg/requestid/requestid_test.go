@@ -0,0 +1,37 @@
+package requestid
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	a, b := New(), New()
+	assert.Len(t, a, 16)
+	assert.NotEqual(t, a, b)
+}
+
+func TestContextRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "", FromContext(ctx))
+	assert.Equal(t, "", FromContext(nil))
+
+	ctx = NewContext(ctx, "abc123")
+	assert.Equal(t, "abc123", FromContext(ctx))
+
+	// Empty IDs are not stored
+	assert.Equal(t, "abc123", FromContext(NewContext(ctx, "")))
+}
+
+func TestEnsure(t *testing.T) {
+	ctx, id := Ensure(context.Background())
+	assert.NotEmpty(t, id)
+	assert.Equal(t, id, FromContext(ctx))
+
+	// Existing ID is preserved
+	ctx2, id2 := Ensure(ctx)
+	assert.Equal(t, id, id2)
+	assert.Equal(t, ctx, ctx2)
+}
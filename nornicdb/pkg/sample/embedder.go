@@ -0,0 +1,88 @@
+package sample
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Embedder is a local feature-hashing embedding model: each word of a text
+// is hashed to a signed dimension, and the counts are L2-normalized. Texts
+// sharing words score high on cosine similarity. It needs no model files or
+// service, which makes it useful for demos and tests, but it knows nothing
+// about meaning: "film" and "movie" are unrelated to it.
+//
+// Embedder implements embed.Embedder, so a test can use it to embed the
+// string queries of db.index.vector.queryNodes consistently with the sample
+// data.
+type Embedder struct {
+	dims int
+}
+
+// NewEmbedder returns a hashing embedder producing dims-dimensional vectors.
+func NewEmbedder(dims int) *Embedder {
+	return &Embedder{dims: dims}
+}
+
+// Vector returns the embedding of text.
+func (e *Embedder) Vector(text string) []float32 {
+	vec := make([]float32, e.dims)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if len(word) < 2 {
+			continue
+		}
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+		sign := float32(1)
+		if sum>>63 == 1 {
+			sign = -1
+		}
+		vec[sum%uint64(e.dims)] += sign
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		// No words: a fixed unit vector keeps cosine similarity defined
+		vec[0] = 1
+		return vec
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vec {
+		vec[i] *= scale
+	}
+	return vec
+}
+
+// Embed implements embed.Embedder.
+func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return e.Vector(text), nil
+}
+
+// EmbedBatch implements embed.Embedder.
+func (e *Embedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i, text := range texts {
+		vecs[i] = e.Vector(text)
+	}
+	return vecs, nil
+}
+
+// Dimensions implements embed.Embedder.
+func (e *Embedder) Dimensions() int {
+	return e.dims
+}
+
+// Model implements embed.Embedder.
+func (e *Embedder) Model() string {
+	return fmt.Sprintf("sample-hashing-%d", e.dims)
+}
@@ -0,0 +1,197 @@
+package sample
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// movie is a movie of the curated movies graph.
+type movie struct {
+	title     string
+	released  int
+	genre     string
+	plot      string
+	directors []string
+	cast      []role
+}
+
+// role is an actor's part in a movie.
+type role struct {
+	actor string
+	roles []string
+}
+
+// born holds the birth years of the people in movieData.
+var born = map[string]int{
+	"Keanu Reeves": 1964, "Carrie-Anne Moss": 1967, "Laurence Fishburne": 1961,
+	"Hugo Weaving": 1960, "Lana Wachowski": 1965, "Lilly Wachowski": 1967,
+	"Charlize Theron": 1975, "Al Pacino": 1940, "Taylor Hackford": 1944,
+	"Tom Cruise": 1962, "Jack Nicholson": 1937, "Demi Moore": 1962,
+	"Kevin Bacon": 1958, "Rob Reiner": 1947, "Kelly McGillis": 1957,
+	"Val Kilmer": 1959, "Anthony Edwards": 1962, "Tony Scott": 1944,
+	"Cuba Gooding Jr.": 1968, "Renée Zellweger": 1969, "Cameron Crowe": 1957,
+	"Wil Wheaton": 1972, "River Phoenix": 1970, "Corey Feldman": 1971,
+	"Jerry O'Connell": 1974, "Kiefer Sutherland": 1966, "Billy Crystal": 1948,
+	"Meg Ryan": 1961, "Tom Hanks": 1956, "Nora Ephron": 1941,
+	"Bill Paxton": 1955, "Gary Sinise": 1955, "Ed Harris": 1950,
+	"Ron Howard": 1954, "Helen Hunt": 1963, "Robert Zemeckis": 1951,
+	"Michael Clarke Duncan": 1957, "Frank Darabont": 1959, "Jan de Bont": 1943,
+	"Audrey Tautou": 1976, "Ian McKellen": 1939,
+}
+
+// movieData is the curated movies graph.
+var movieData = []movie{
+	{"The Matrix", 1999, "science fiction", "A hacker learns that the world he lives in is a simulation run by machines and joins the rebels fighting them.",
+		[]string{"Lana Wachowski", "Lilly Wachowski"},
+		[]role{{"Keanu Reeves", []string{"Neo"}}, {"Carrie-Anne Moss", []string{"Trinity"}}, {"Laurence Fishburne", []string{"Morpheus"}}, {"Hugo Weaving", []string{"Agent Smith"}}}},
+	{"The Matrix Reloaded", 2003, "science fiction", "The rebels race to defend the last human city while Neo searches for the source of the machines' simulation.",
+		[]string{"Lana Wachowski", "Lilly Wachowski"},
+		[]role{{"Keanu Reeves", []string{"Neo"}}, {"Carrie-Anne Moss", []string{"Trinity"}}, {"Laurence Fishburne", []string{"Morpheus"}}, {"Hugo Weaving", []string{"Agent Smith"}}}},
+	{"The Devil's Advocate", 1997, "thriller", "A young lawyer who never loses a case joins a powerful New York firm whose founder has sinister plans for him.",
+		[]string{"Taylor Hackford"},
+		[]role{{"Keanu Reeves", []string{"Kevin Lomax"}}, {"Charlize Theron", []string{"Mary Ann Lomax"}}, {"Al Pacino", []string{"John Milton"}}}},
+	{"A Few Good Men", 1992, "drama", "A navy lawyer defends two marines charged with killing a comrade and uncovers a cover-up reaching their commander.",
+		[]string{"Rob Reiner"},
+		[]role{{"Tom Cruise", []string{"Lt. Daniel Kaffee"}}, {"Jack Nicholson", []string{"Col. Nathan R. Jessup"}}, {"Demi Moore", []string{"Lt. Cdr. JoAnne Galloway"}}, {"Kevin Bacon", []string{"Capt. Jack Ross"}}}},
+	{"Top Gun", 1986, "action", "A reckless navy fighter pilot trains at an elite flight school and competes to be the best in his class.",
+		[]string{"Tony Scott"},
+		[]role{{"Tom Cruise", []string{"Maverick"}}, {"Kelly McGillis", []string{"Charlie"}}, {"Val Kilmer", []string{"Iceman"}}, {"Anthony Edwards", []string{"Goose"}}}},
+	{"Jerry Maguire", 1996, "romance", "A sports agent loses his job after a crisis of conscience and starts over with a single loyal client.",
+		[]string{"Cameron Crowe"},
+		[]role{{"Tom Cruise", []string{"Jerry Maguire"}}, {"Cuba Gooding Jr.", []string{"Rod Tidwell"}}, {"Renée Zellweger", []string{"Dorothy Boyd"}}}},
+	{"Stand By Me", 1986, "drama", "Four boys set out on a journey along the railroad tracks to find the body of a missing boy.",
+		[]string{"Rob Reiner"},
+		[]role{{"Wil Wheaton", []string{"Gordie Lachance"}}, {"River Phoenix", []string{"Chris Chambers"}}, {"Corey Feldman", []string{"Teddy Duchamp"}}, {"Jerry O'Connell", []string{"Vern Tessio"}}, {"Kiefer Sutherland", []string{"Ace Merrill"}}}},
+	{"When Harry Met Sally", 1989, "romance", "Two friends meet again and again over the years and wonder whether men and women can ever just be friends.",
+		[]string{"Rob Reiner"},
+		[]role{{"Billy Crystal", []string{"Harry Burns"}}, {"Meg Ryan", []string{"Sally Albright"}}}},
+	{"Sleepless in Seattle", 1993, "romance", "A widower's son calls a radio show, and a woman on the other side of the country falls for the father's story.",
+		[]string{"Nora Ephron"},
+		[]role{{"Tom Hanks", []string{"Sam Baldwin"}}, {"Meg Ryan", []string{"Annie Reed"}}}},
+	{"You've Got Mail", 1998, "romance", "Two rival bookstore owners who dislike each other fall in love as anonymous online pen pals.",
+		[]string{"Nora Ephron"},
+		[]role{{"Tom Hanks", []string{"Joe Fox"}}, {"Meg Ryan", []string{"Kathleen Kelly"}}}},
+	{"Apollo 13", 1995, "drama", "After an explosion on their spacecraft, three astronauts and mission control improvise a way to bring the crew home.",
+		[]string{"Ron Howard"},
+		[]role{{"Tom Hanks", []string{"Jim Lovell"}}, {"Kevin Bacon", []string{"Jack Swigert"}}, {"Bill Paxton", []string{"Fred Haise"}}, {"Gary Sinise", []string{"Ken Mattingly"}}, {"Ed Harris", []string{"Gene Kranz"}}}},
+	{"Cast Away", 2000, "drama", "A courier stranded on a deserted island after a plane crash struggles to survive alone for years.",
+		[]string{"Robert Zemeckis"},
+		[]role{{"Tom Hanks", []string{"Chuck Noland"}}, {"Helen Hunt", []string{"Kelly Frears"}}}},
+	{"The Green Mile", 1999, "drama", "A prison guard on death row comes to believe that one of the inmates has a miraculous gift.",
+		[]string{"Frank Darabont"},
+		[]role{{"Tom Hanks", []string{"Paul Edgecomb"}}, {"Michael Clarke Duncan", []string{"John Coffey"}}}},
+	{"Twister", 1996, "action", "Storm chasers on the verge of divorce race across Oklahoma to place a research device inside a tornado.",
+		[]string{"Jan de Bont"},
+		[]role{{"Bill Paxton", []string{"Bill Harding"}}, {"Helen Hunt", []string{"Dr. Jo Harding"}}}},
+	{"The Da Vinci Code", 2006, "thriller", "A symbologist and a cryptologist follow clues hidden in famous paintings to solve a murder in the Louvre.",
+		[]string{"Ron Howard"},
+		[]role{{"Tom Hanks", []string{"Robert Langdon"}}, {"Audrey Tautou", []string{"Sophie Neveu"}}, {"Ian McKellen", []string{"Sir Leigh Teabing"}}}},
+}
+
+// Word lists for generated movies.
+var (
+	titleAdjectives = []string{"Silent", "Broken", "Golden", "Last", "Hidden", "Crimson", "Endless", "Frozen", "Distant", "Electric", "Wild", "Midnight"}
+	titleNouns      = []string{"Harbor", "Frontier", "Kingdom", "Signal", "Empire", "Orchard", "Horizon", "Circuit", "Garden", "River", "Station", "Crown"}
+	moviePlots      = map[string][]string{
+		"science fiction": {"A crew on a deep space mission discovers a signal that should not exist.", "An engineer builds a machine that can see one day into the future.", "Colonists on a distant planet fight the artificial intelligence that runs their city."},
+		"thriller":        {"A detective races to stop a killer who leaves riddles at every crime scene.", "A journalist uncovers a conspiracy inside a powerful bank.", "A witness to a murder goes into hiding from a corrupt police chief."},
+		"drama":           {"A family reunites at their childhood farm after their mother falls ill.", "A retired teacher mentors a gifted student from a troubled home.", "A small town fights to save its factory from closing."},
+		"romance":         {"Two strangers keep missing each other on the same train every morning.", "A chef and a food critic fall in love during a summer in Italy.", "Childhood friends meet again at a wedding after twenty years apart."},
+		"action":          {"A retired soldier is pulled back for one last rescue mission.", "A getaway driver tries to escape the city after a heist goes wrong.", "A pilot must land a crippled airliner in a storm."},
+		"comedy":          {"Two rival wedding planners are hired for the same wedding.", "A family road trip goes wrong at every possible stop.", "An office worker pretends to be his own boss for a week."},
+	}
+	genres     = []string{"science fiction", "thriller", "drama", "romance", "action", "comedy"}
+	firstNames = []string{"Ava", "Liam", "Noah", "Emma", "Olivia", "Mason", "Sofia", "Lucas", "Mia", "Ethan", "Isla", "Leo", "Zoe", "Omar", "Priya", "Kenji", "Amara", "Mateo", "Chloe", "Jonas", "Nadia", "Felix", "Hana", "Diego"}
+	lastNames  = []string{"Walker", "Nguyen", "Garcia", "Smith", "Kowalski", "Okafor", "Rossi", "Tanaka", "Larsen", "Silva", "Müller", "Patel", "Johnson", "Haddad", "Kim", "Dubois", "Novak", "Reyes", "Brennan", "Costa"}
+)
+
+// generateMovies builds the movies graph, adding 100 generated movies per
+// unit of scale above 1.
+func generateMovies(g *generator, scale int) {
+	people := make(map[string]storage.NodeID)
+	person := func(name string) storage.NodeID {
+		if id, ok := people[name]; ok {
+			return id
+		}
+		props := map[string]any{"name": name}
+		if year, ok := born[name]; ok {
+			props["born"] = int64(year)
+		}
+		id := g.node("person:"+slug(name), "Person", props, "")
+		people[name] = id
+		return id
+	}
+	addMovie := func(id string, m movie, synthetic bool) {
+		props := map[string]any{
+			"title":    m.title,
+			"released": int64(m.released),
+			"genre":    m.genre,
+			"plot":     m.plot,
+		}
+		if synthetic {
+			props["synthetic"] = true
+		}
+		movieID := g.node(id, "Movie", props, m.title+". "+m.genre+". "+m.plot)
+		for _, c := range m.cast {
+			roles := make([]any, len(c.roles))
+			for i, r := range c.roles {
+				roles[i] = r
+			}
+			g.edge(person(c.actor), movieID, "ACTED_IN", map[string]any{"roles": roles})
+		}
+		for _, d := range m.directors {
+			g.edge(person(d), movieID, "DIRECTED", nil)
+		}
+	}
+
+	for _, m := range movieData {
+		addMovie("movie:"+slug(m.title), m, false)
+	}
+
+	// Generated movies cast a pool of generated people, so they share actors
+	var pool []string
+	for len(pool) < 40*(scale-1) {
+		name := pick(g.rng, firstNames) + " " + pick(g.rng, lastNames)
+		if _, ok := people[name]; !ok {
+			person(name)
+		}
+		pool = append(pool, name)
+	}
+	for i := 0; i < 100*(scale-1); i++ {
+		genre := pick(g.rng, genres)
+		m := movie{
+			title:     fmt.Sprintf("The %s %s", pick(g.rng, titleAdjectives), pick(g.rng, titleNouns)),
+			released:  1970 + g.rng.intn(55),
+			genre:     genre,
+			plot:      pick(g.rng, moviePlots[genre]),
+			directors: []string{pick(g.rng, pool)},
+		}
+		cast := make(map[string]bool)
+		for n := 2 + g.rng.intn(4); n > 0; n-- {
+			if actor := pick(g.rng, pool); !cast[actor] {
+				cast[actor] = true
+				m.cast = append(m.cast, role{actor, []string{pick(g.rng, firstNames)}})
+			}
+		}
+		addMovie(fmt.Sprintf("movie:generated-%d", i+1), m, true)
+	}
+	g.vectorIndex("movie_embeddings", "Movie")
+}
+
+// slug turns a name into an ID fragment: "The Matrix" becomes "the-matrix".
+func slug(name string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "-")
+}
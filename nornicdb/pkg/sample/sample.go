@@ -0,0 +1,232 @@
+// Package sample generates the built-in sample datasets, so new users and
+// integration tests have realistic data without an import.
+//
+// Two datasets are available:
+//   - movies: the classic movies graph (Person and Movie nodes with ACTED_IN
+//     and DIRECTED relationships), grown with generated movies and people at
+//     larger scales
+//   - social: a synthetic social network of Person nodes who KNOW each other,
+//     write Posts and LIKE them, 1,000 people per unit of scale
+//
+// Generation is deterministic: the same Options always give the same graph.
+// Movies, posts and people's bios get embeddings from Embedder, a local
+// feature-hashing model, so vector search works without an embedding
+// service. Texts sharing words get similar vectors; for semantic similarity,
+// generate without embeddings and let the server's model embed the nodes.
+//
+// Example:
+//
+//	ds, err := sample.Generate(sample.Options{Dataset: sample.Movies, Scale: 1, Dimensions: 384})
+//	if err != nil {
+//		return err
+//	}
+//	err = sample.Load(engine, ds)
+package sample
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// Dataset names.
+const (
+	Movies = "movies"
+	Social = "social"
+)
+
+// Options configures Generate.
+type Options struct {
+	// Dataset is Movies or Social.
+	Dataset string
+
+	// Scale grows the dataset (default 1). Movies adds 100 generated movies
+	// per unit above 1; Social generates 1,000 people per unit.
+	Scale int
+
+	// Dimensions is the embedding size (0 = no embeddings).
+	Dimensions int
+
+	// Seed makes a different graph of the same shape (default 1).
+	Seed int64
+}
+
+// Dataset is a generated sample graph.
+type Dataset struct {
+	Name  string
+	Nodes []*storage.Node
+	Edges []*storage.Edge
+
+	// VectorIndex names the vector index over the embedded nodes with
+	// VectorLabel; empty without embeddings.
+	VectorIndex string
+	VectorLabel string
+	Dimensions  int
+}
+
+// generators maps dataset names to their generator.
+var generators = map[string]func(g *generator, scale int){
+	Movies: generateMovies,
+	Social: generateSocial,
+}
+
+// Names returns the available dataset names.
+func Names() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generate builds the dataset described by opts.
+func Generate(opts Options) (*Dataset, error) {
+	gen, ok := generators[opts.Dataset]
+	if !ok {
+		return nil, fmt.Errorf("unknown dataset %q (available: %s)", opts.Dataset, strings.Join(Names(), ", "))
+	}
+	if opts.Scale <= 0 {
+		opts.Scale = 1
+	}
+	if opts.Seed == 0 {
+		opts.Seed = 1
+	}
+	if opts.Dimensions < 0 {
+		return nil, fmt.Errorf("invalid embedding dimensions %d", opts.Dimensions)
+	}
+
+	g := newGenerator(opts)
+	gen(g, opts.Scale)
+	return g.ds, nil
+}
+
+// Load stores ds in engine and registers its vector index. The node and
+// relationship IDs are fixed, so loading a dataset twice never duplicates
+// it: depending on the engine, the second load fails with
+// storage.ErrAlreadyExists or overwrites the first.
+func Load(engine storage.Engine, ds *Dataset) error {
+	if err := engine.BulkCreateNodes(ds.Nodes); err != nil {
+		return fmt.Errorf("creating nodes: %w", err)
+	}
+	if err := engine.BulkCreateEdges(ds.Edges); err != nil {
+		return fmt.Errorf("creating relationships: %w", err)
+	}
+	if ds.VectorIndex != "" {
+		if schema := engine.GetSchema(); schema != nil {
+			// An index left by an earlier load is fine
+			_ = schema.AddVectorIndex(ds.VectorIndex, ds.VectorLabel, "embedding", ds.Dimensions, "cosine")
+		}
+	}
+	return nil
+}
+
+// Embeddings returns the number of nodes in ds with an embedding.
+func (ds *Dataset) Embeddings() int {
+	n := 0
+	for _, node := range ds.Nodes {
+		if len(node.Embedding) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// generator accumulates a dataset.
+type generator struct {
+	ds       *Dataset
+	rng      *rand
+	embedder *Embedder
+	now      time.Time
+	edges    int
+}
+
+func newGenerator(opts Options) *generator {
+	g := &generator{
+		ds:  &Dataset{Name: opts.Dataset},
+		rng: newRand(opts.Seed),
+		// Fixed so that generation is deterministic
+		now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if opts.Dimensions > 0 {
+		g.embedder = NewEmbedder(opts.Dimensions)
+		g.ds.Dimensions = opts.Dimensions
+	}
+	return g
+}
+
+// node adds a node; a non-empty text is embedded.
+func (g *generator) node(id, label string, props map[string]any, text string) storage.NodeID {
+	node := &storage.Node{
+		ID:         storage.NodeID(id),
+		Labels:     []string{label},
+		Properties: props,
+		CreatedAt:  g.now,
+		UpdatedAt:  g.now,
+		DecayScore: 1.0,
+	}
+	if text != "" && g.embedder != nil {
+		node.Embedding = g.embedder.Vector(text)
+	}
+	g.ds.Nodes = append(g.ds.Nodes, node)
+	return node.ID
+}
+
+// edge adds a relationship.
+func (g *generator) edge(from, to storage.NodeID, typ string, props map[string]any) {
+	g.edges++
+	g.ds.Edges = append(g.ds.Edges, &storage.Edge{
+		ID:         storage.EdgeID(fmt.Sprintf("%s:rel:%d", g.ds.Name, g.edges)),
+		StartNode:  from,
+		EndNode:    to,
+		Type:       typ,
+		Properties: props,
+		CreatedAt:  g.now,
+		UpdatedAt:  g.now,
+		Confidence: 1.0,
+	})
+}
+
+// vectorIndex records the vector index over label's embeddings.
+func (g *generator) vectorIndex(name, label string) {
+	if g.embedder != nil {
+		g.ds.VectorIndex = name
+		g.ds.VectorLabel = label
+	}
+}
+
+// rand is a small deterministic PRNG (splitmix64), so datasets do not change
+// with the Go version's math/rand algorithms.
+type rand struct {
+	state uint64
+}
+
+func newRand(seed int64) *rand {
+	return &rand{state: uint64(seed)}
+}
+
+func (r *rand) next() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// intn returns a number in [0, n).
+func (r *rand) intn(n int) int {
+	return int(r.next() % uint64(n))
+}
+
+// float returns a number in [0, 1).
+func (r *rand) float() float64 {
+	return float64(r.next()>>11) / (1 << 53)
+}
+
+// pick returns a random element of items.
+func pick[T any](r *rand, items []T) T {
+	return items[r.intn(len(items))]
+}
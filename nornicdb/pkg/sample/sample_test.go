@@ -0,0 +1,117 @@
+package sample
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/orneryd/nornicdb/pkg/cypher"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+func countLabels(ds *Dataset) map[string]int {
+	counts := make(map[string]int)
+	for _, n := range ds.Nodes {
+		counts[n.Labels[0]]++
+	}
+	for _, e := range ds.Edges {
+		counts[e.Type]++
+	}
+	return counts
+}
+
+func TestGenerate_Movies(t *testing.T) {
+	ds, err := Generate(Options{Dataset: Movies, Dimensions: 64})
+	require.NoError(t, err)
+	counts := countLabels(ds)
+	assert.Equal(t, len(movieData), counts["Movie"])
+	assert.Equal(t, len(born), counts["Person"])
+	assert.Equal(t, len(movieData), ds.Embeddings(), "every movie is embedded")
+	assert.Equal(t, "movie_embeddings", ds.VectorIndex)
+
+	bigger, err := Generate(Options{Dataset: Movies, Scale: 3})
+	require.NoError(t, err)
+	assert.Equal(t, len(movieData)+200, countLabels(bigger)["Movie"])
+	assert.Zero(t, bigger.Embeddings(), "no embeddings without dimensions")
+	assert.Empty(t, bigger.VectorIndex)
+}
+
+func TestGenerate_Social(t *testing.T) {
+	ds, err := Generate(Options{Dataset: Social, Scale: 2, Dimensions: 32})
+	require.NoError(t, err)
+	counts := countLabels(ds)
+	assert.Equal(t, 2000, counts["Person"])
+	assert.Greater(t, counts["Post"], 2000)
+	assert.Equal(t, counts["Post"], counts["POSTED"])
+	assert.Greater(t, counts["KNOWS"], 5000)
+	assert.Greater(t, counts["LIKES"], 1000)
+
+	ids := make(map[storage.EdgeID]bool)
+	for _, e := range ds.Edges {
+		assert.False(t, ids[e.ID], "duplicate relationship ID %s", e.ID)
+		ids[e.ID] = true
+	}
+}
+
+func TestGenerate_Deterministic(t *testing.T) {
+	a, err := Generate(Options{Dataset: Social, Dimensions: 8})
+	require.NoError(t, err)
+	b, err := Generate(Options{Dataset: Social, Dimensions: 8})
+	require.NoError(t, err)
+	require.Equal(t, len(a.Edges), len(b.Edges))
+	for i := range a.Nodes {
+		assert.Equal(t, a.Nodes[i].Properties, b.Nodes[i].Properties)
+		assert.Equal(t, a.Nodes[i].Embedding, b.Nodes[i].Embedding)
+	}
+
+	c, err := Generate(Options{Dataset: Social, Seed: 2})
+	require.NoError(t, err)
+	assert.NotEqual(t, a.Nodes[0].Properties["name"], c.Nodes[0].Properties["name"])
+}
+
+func TestGenerate_UnknownDataset(t *testing.T) {
+	_, err := Generate(Options{Dataset: "northwind"})
+	assert.ErrorContains(t, err, "movies, social")
+}
+
+func TestLoad_Queryable(t *testing.T) {
+	ds, err := Generate(Options{Dataset: Movies, Dimensions: 256})
+	require.NoError(t, err)
+	engine := storage.NewMemoryEngine()
+	require.NoError(t, Load(engine, ds))
+	assert.ErrorIs(t, Load(engine, ds), storage.ErrAlreadyExists, "IDs are fixed")
+
+	exec := cypher.NewStorageExecutor(engine)
+	ctx := context.Background()
+	result, err := exec.Execute(ctx, "MATCH (p:Person {name: 'Tom Hanks'})-[:ACTED_IN]->(m:Movie) RETURN count(m)", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 6, result.Rows[0][0])
+
+	// The hashing embedder ranks movies sharing words with the query first
+	exec.SetEmbedder(NewEmbedder(256))
+	result, err = exec.Execute(ctx, "CALL db.index.vector.queryNodes('movie_embeddings', 1, 'hacker simulation machines rebels') YIELD node, score", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	node, ok := result.Rows[0][0].(map[string]interface{})
+	require.True(t, ok, "node row is %T", result.Rows[0][0])
+	assert.Equal(t, "The Matrix", node["title"])
+}
+
+func TestEmbedder(t *testing.T) {
+	e := NewEmbedder(128)
+	a := e.Vector("space mission to the moon")
+	b := e.Vector("a mission to the moon")
+	c := e.Vector("cooking ramen broth")
+	dot := func(x, y []float32) (s float32) {
+		for i := range x {
+			s += x[i] * y[i]
+		}
+		return s
+	}
+	assert.InDelta(t, 1, dot(a, a), 1e-5)
+	assert.Greater(t, dot(a, b), dot(a, c))
+	assert.InDelta(t, 1, dot(e.Vector(""), e.Vector("")), 1e-5)
+	assert.Equal(t, 128, e.Dimensions())
+}
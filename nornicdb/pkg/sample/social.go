@@ -0,0 +1,121 @@
+package sample
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+// Word lists for the social network.
+var (
+	cities    = []string{"Berlin", "Lisbon", "Toronto", "Osaka", "Nairobi", "Austin", "Melbourne", "Oslo", "Bogotá", "Seoul"}
+	interests = map[string][]string{
+		"hiking":      {"Reached the summit before sunrise, the view over the valley was worth every step.", "Looking for trail recommendations for a three day hike in the mountains."},
+		"cooking":     {"Tried a new recipe for slow cooked ramen broth tonight, twelve hours well spent.", "Fresh bread from the oven every Sunday morning is my favourite ritual."},
+		"photography": {"Golden hour light on the old harbor, shot on a vintage film camera.", "Editing a series of street photos from the night market."},
+		"music":       {"Saw an incredible live jazz trio in a tiny basement club last night.", "Finally learned the guitar solo I have been practicing for months."},
+		"gaming":      {"Our team finally beat the final raid boss after forty attempts.", "Building a retro gaming console from spare parts this weekend."},
+		"running":     {"New personal best in the half marathon this morning.", "Early morning runs along the river are the best way to start the day."},
+		"reading":     {"Just finished a sweeping historical novel about a family of sailors.", "Our book club is reading a classic science fiction novel this month."},
+		"programming": {"Spent the weekend rewriting our database queries as a graph traversal.", "Released my first open source library, feedback welcome."},
+		"travel":      {"Two weeks backpacking through small coastal towns, no plans, no bookings.", "Packing light for a long train journey across the country."},
+		"gardening":   {"The tomatoes in my balcony garden are finally turning red.", "Planted a row of sunflowers along the fence for the bees."},
+	}
+	interestNames = sortedKeys(interests)
+)
+
+// generateSocial builds a social network of 1,000 people per unit of
+// scale. People mostly know others in their city, post about their
+// interests and like their friends' posts.
+func generateSocial(g *generator, scale int) {
+	n := 1000 * scale
+	type member struct {
+		id        storage.NodeID
+		city      string
+		interests []string
+		posts     []storage.NodeID
+	}
+	people := make([]*member, n)
+	byCity := make(map[string][]int)
+	posts := 0
+
+	for i := range people {
+		m := &member{city: pick(g.rng, cities)}
+		for want := 1 + g.rng.intn(3); len(m.interests) < want; {
+			if interest := pick(g.rng, interestNames); !slices.Contains(m.interests, interest) {
+				m.interests = append(m.interests, interest)
+			}
+		}
+		name := pick(g.rng, firstNames) + " " + pick(g.rng, lastNames)
+		bio := fmt.Sprintf("%s from %s, into %s.", name, m.city, strings.Join(m.interests, ", "))
+		tags := make([]any, len(m.interests))
+		for j, interest := range m.interests {
+			tags[j] = interest
+		}
+		m.id = g.node(fmt.Sprintf("social:person:%d", i+1), "Person", map[string]any{
+			"name":      name,
+			"age":       int64(18 + g.rng.intn(60)),
+			"city":      m.city,
+			"interests": tags,
+			"bio":       bio,
+		}, bio)
+		people[i] = m
+		byCity[m.city] = append(byCity[m.city], i)
+
+		for p := g.rng.intn(4); p > 0; p-- {
+			posts++
+			text := pick(g.rng, interests[pick(g.rng, m.interests)])
+			post := g.node(fmt.Sprintf("social:post:%d", posts), "Post", map[string]any{
+				"text":    text,
+				"created": g.now.AddDate(0, 0, -g.rng.intn(365)).Format("2006-01-02"),
+			}, text)
+			g.edge(m.id, post, "POSTED", nil)
+			m.posts = append(m.posts, post)
+		}
+	}
+
+	// About six KNOWS relationships per person, 80% within the same city
+	friends := make(map[[2]int]bool)
+	var pairs [][2]int
+	for i, m := range people {
+		for k := 0; k < 3; k++ {
+			j := g.rng.intn(n)
+			if g.rng.float() < 0.8 {
+				j = pick(g.rng, byCity[m.city])
+			}
+			pair := [2]int{min(i, j), max(i, j)}
+			if i == j || friends[pair] {
+				continue
+			}
+			friends[pair] = true
+			pairs = append(pairs, pair)
+			since := int64(2005 + g.rng.intn(19))
+			g.edge(m.id, people[j].id, "KNOWS", map[string]any{"since": since})
+		}
+	}
+
+	// People like a few of their friends' posts
+	for _, pair := range pairs {
+		for _, side := range [][2]int{{pair[0], pair[1]}, {pair[1], pair[0]}} {
+			fan, author := people[side[0]], people[side[1]]
+			if len(author.posts) == 0 || g.rng.float() < 0.5 {
+				continue
+			}
+			g.edge(fan.id, pick(g.rng, author.posts), "LIKES", nil)
+		}
+	}
+	g.vectorIndex("post_embeddings", "Post")
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
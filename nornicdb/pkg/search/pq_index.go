@@ -0,0 +1,520 @@
+// Package search - IVF-PQ compressed vector index with exact reranking.
+//
+// For very large collections, holding every fp32 embedding in memory is the
+// dominant cost (4KB per 1024-dim vector). PQIndex stores each vector as a
+// product-quantization code of one byte per SubspaceDims dimensions (16x
+// smaller by default) and searches in two stages:
+//
+//  1. Candidate generation: scan the NProbe inverted lists nearest the query,
+//     scoring codes with precomputed lookup tables (no decompression).
+//  2. Rerank: re-score the top RerankK candidates with their exact fp32
+//     vectors, fetched on demand, on the GPU when available.
+package search
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/orneryd/nornicdb/pkg/gpu"
+	"github.com/orneryd/nornicdb/pkg/math/vector"
+)
+
+var (
+	// ErrPQNotTrained is returned when vectors are added before Train.
+	ErrPQNotTrained = errors.New("pq index not trained")
+)
+
+// pqMaxCodes is the number of centroids per sub-quantizer (one byte per code).
+const pqMaxCodes = 256
+
+// PQConfig contains configuration parameters for the PQ index.
+type PQConfig struct {
+	NumLists        int // Inverted lists (0 = sqrt of training set size)
+	NProbe          int // Lists scanned per query (default: 8)
+	SubspaceDims    int // Dimensions per sub-quantizer; code is dims/SubspaceDims bytes (default: 4)
+	RerankK         int // Candidates re-scored with exact vectors (default: 100)
+	TrainIterations int // K-means iterations during training (default: 15)
+	TrainSampleSize int // Max vectors used for training (default: 10000)
+}
+
+// DefaultPQConfig returns sensible defaults for the PQ index.
+func DefaultPQConfig() PQConfig {
+	return PQConfig{
+		NProbe:          8,
+		SubspaceDims:    4,
+		RerankK:         100,
+		TrainIterations: 15,
+		TrainSampleSize: 10000,
+	}
+}
+
+// pqList is one inverted list: codes are stored flat, one code per ID.
+type pqList struct {
+	ids   []string
+	codes []byte
+}
+
+// pqLocation is where a vector's code lives.
+type pqLocation struct {
+	list int
+	pos  int
+}
+
+// PQIndex provides approximate nearest neighbor search over product-quantized
+// vectors, partitioned into inverted lists (IVF-PQ), with exact reranking.
+//
+// Similarity is cosine: vectors are normalized before encoding.
+//
+// Example:
+//
+//	idx := search.NewPQIndex(1024, search.DefaultPQConfig())
+//	idx.Train(sample) // Learn list centroids and codebooks
+//	idx.SetExactSource(func(ids []string) [][]float32 { ... }, gpuManager)
+//	for id, vec := range vectors {
+//		idx.Add(id, vec)
+//	}
+//	results, _ := idx.Search(ctx, query, 10, 0.5)
+//
+// Thread Safety:
+//
+//	All methods are thread-safe.
+type PQIndex struct {
+	config     PQConfig
+	dimensions int
+	subspaces  int
+	mu         sync.RWMutex
+
+	trained   bool
+	centroids [][]float32 // Inverted list centroids
+	codebooks [][]float32 // Per subspace: numCodes × SubspaceDims, flat
+	numCodes  int
+	lists     []pqList
+	locations map[string]pqLocation
+
+	exact      func(ids []string) [][]float32
+	gpuManager *gpu.Manager
+}
+
+// NewPQIndex creates an untrained PQ index for the given dimensions.
+// Zero config fields take their defaults.
+func NewPQIndex(dimensions int, config PQConfig) (*PQIndex, error) {
+	defaults := DefaultPQConfig()
+	if config.NProbe <= 0 {
+		config.NProbe = defaults.NProbe
+	}
+	if config.SubspaceDims <= 0 {
+		config.SubspaceDims = defaults.SubspaceDims
+	}
+	if config.RerankK <= 0 {
+		config.RerankK = defaults.RerankK
+	}
+	if config.TrainIterations <= 0 {
+		config.TrainIterations = defaults.TrainIterations
+	}
+	if config.TrainSampleSize <= 0 {
+		config.TrainSampleSize = defaults.TrainSampleSize
+	}
+	if dimensions <= 0 || dimensions%config.SubspaceDims != 0 {
+		return nil, fmt.Errorf("pq index: %d dimensions not divisible into subspaces of %d", dimensions, config.SubspaceDims)
+	}
+	return &PQIndex{
+		config:     config,
+		dimensions: dimensions,
+		subspaces:  dimensions / config.SubspaceDims,
+		locations:  make(map[string]pqLocation),
+	}, nil
+}
+
+// SetExactSource sets where reranking fetches exact vectors. The function
+// returns one vector per ID, nil for IDs that no longer exist. Without a
+// source, results carry approximate scores. The GPU manager (may be nil)
+// scores the rerank batch.
+func (p *PQIndex) SetExactSource(source func(ids []string) [][]float32, manager *gpu.Manager) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.exact = source
+	p.gpuManager = manager
+}
+
+// Train learns the inverted list centroids and PQ codebooks from sample
+// vectors. Training discards previously added vectors.
+func (p *PQIndex) Train(vectors [][]float32) error {
+	if len(vectors) == 0 {
+		return fmt.Errorf("pq index: no training vectors")
+	}
+	rng := rand.New(rand.NewSource(42))
+
+	sample := make([][]float32, 0, min(len(vectors), p.config.TrainSampleSize))
+	for _, i := range rng.Perm(len(vectors)) {
+		if len(sample) == p.config.TrainSampleSize {
+			break
+		}
+		if len(vectors[i]) != p.dimensions {
+			return ErrDimensionMismatch
+		}
+		sample = append(sample, vector.Normalize(vectors[i]))
+	}
+
+	numLists := p.config.NumLists
+	if numLists <= 0 {
+		numLists = int(math.Sqrt(float64(len(sample))))
+	}
+	numLists = max(1, min(numLists, len(sample)))
+	centroids := pqKMeans(sample, numLists, p.config.TrainIterations, rng)
+
+	// Sub-quantizers train independently, so spread them across cores
+	numCodes := min(pqMaxCodes, len(sample))
+	codebooks := make([][]float32, p.subspaces)
+	sd := p.config.SubspaceDims
+	var wg sync.WaitGroup
+	next := make(chan int, p.subspaces)
+	for m := 0; m < p.subspaces; m++ {
+		next <- m
+	}
+	close(next)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			wrng := rand.New(rand.NewSource(seed))
+			for m := range next {
+				sub := make([][]float32, len(sample))
+				for i, vec := range sample {
+					sub[i] = vec[m*sd : (m+1)*sd]
+				}
+				flat := make([]float32, 0, numCodes*sd)
+				for _, c := range pqKMeans(sub, numCodes, p.config.TrainIterations, wrng) {
+					flat = append(flat, c...)
+				}
+				codebooks[m] = flat
+			}
+		}(int64(w) + 43)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.centroids = centroids
+	p.codebooks = codebooks
+	p.numCodes = numCodes
+	p.lists = make([]pqList, numLists)
+	p.locations = make(map[string]pqLocation)
+	p.trained = true
+	return nil
+}
+
+// IsTrained returns true once Train has succeeded.
+func (p *PQIndex) IsTrained() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.trained
+}
+
+// Add encodes and inserts a vector, replacing any previous one with the same ID.
+func (p *PQIndex) Add(id string, vec []float32) error {
+	if len(vec) != p.dimensions {
+		return ErrDimensionMismatch
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.trained {
+		return ErrPQNotTrained
+	}
+	p.removeLocked(id)
+
+	normalized := vector.Normalize(vec)
+	list := nearestCentroid(p.centroids, normalized)
+	l := &p.lists[list]
+	p.locations[id] = pqLocation{list: list, pos: len(l.ids)}
+	l.ids = append(l.ids, id)
+	l.codes = append(l.codes, p.encode(normalized)...)
+	return nil
+}
+
+// Remove deletes a vector from the index. No-op if it does not exist.
+func (p *PQIndex) Remove(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removeLocked(id)
+}
+
+func (p *PQIndex) removeLocked(id string) {
+	loc, exists := p.locations[id]
+	if !exists {
+		return
+	}
+	delete(p.locations, id)
+
+	// Move the last entry into the hole
+	l := &p.lists[loc.list]
+	last := len(l.ids) - 1
+	if loc.pos != last {
+		l.ids[loc.pos] = l.ids[last]
+		copy(l.codes[loc.pos*p.subspaces:(loc.pos+1)*p.subspaces], l.codes[last*p.subspaces:])
+		p.locations[l.ids[loc.pos]] = pqLocation{list: loc.list, pos: loc.pos}
+	}
+	l.ids = l.ids[:last]
+	l.codes = l.codes[:last*p.subspaces]
+}
+
+// Count returns the number of vectors in the index.
+func (p *PQIndex) Count() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.locations)
+}
+
+// CodeSize returns the bytes stored per vector; dimensions*4/CodeSize is the
+// compression ratio over fp32.
+func (p *PQIndex) CodeSize() int {
+	return p.subspaces
+}
+
+// encode returns the PQ code of a normalized vector.
+func (p *PQIndex) encode(vec []float32) []byte {
+	sd := p.config.SubspaceDims
+	code := make([]byte, p.subspaces)
+	for m := 0; m < p.subspaces; m++ {
+		sub := vec[m*sd : (m+1)*sd]
+		book := p.codebooks[m]
+		best, bestDist := 0, math.MaxFloat64
+		for c := 0; c < p.numCodes; c++ {
+			if d := squaredDistance(sub, book[c*sd:(c+1)*sd]); d < bestDist {
+				best, bestDist = c, d
+			}
+		}
+		code[m] = byte(best)
+	}
+	return code
+}
+
+// Search returns the k vectors most similar to query using the configured
+// NProbe and RerankK.
+func (p *PQIndex) Search(ctx context.Context, query []float32, k int, minSimilarity float64) ([]indexResult, error) {
+	return p.SearchWithParams(ctx, query, k, minSimilarity, p.config.NProbe, p.config.RerankK)
+}
+
+// SearchWithParams is Search with per-query tuning: higher nprobe scans more
+// lists (better recall, slower); higher rerankK re-scores more candidates
+// exactly. Values <= 0 use the configured defaults.
+func (p *PQIndex) SearchWithParams(ctx context.Context, query []float32, k int, minSimilarity float64, nprobe, rerankK int) ([]indexResult, error) {
+	if len(query) != p.dimensions {
+		return nil, ErrDimensionMismatch
+	}
+	if nprobe <= 0 {
+		nprobe = p.config.NProbe
+	}
+	if rerankK <= 0 {
+		rerankK = p.config.RerankK
+	}
+	rerankK = max(rerankK, k)
+
+	normalized := vector.Normalize(query)
+	candidates, err := p.scanCandidates(ctx, normalized, nprobe, rerankK)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.RLock()
+	exact, manager := p.exact, p.gpuManager
+	p.mu.RUnlock()
+	if exact != nil && len(candidates) > 0 {
+		candidates = rerankExact(candidates, normalized, exact, manager)
+	}
+
+	results := candidates[:0]
+	for _, c := range candidates {
+		if c.Score >= minSimilarity {
+			results = append(results, c)
+		}
+	}
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// scanCandidates scores the codes in the nprobe nearest lists and returns the
+// top n by approximate similarity, best first.
+func (p *PQIndex) scanCandidates(ctx context.Context, query []float32, nprobe, n int) ([]indexResult, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.trained {
+		return nil, nil
+	}
+
+	// Nearest lists by inner product (vectors are normalized)
+	order := make([]int, len(p.centroids))
+	listScores := make([]float64, len(p.centroids))
+	for i, c := range p.centroids {
+		order[i] = i
+		listScores[i] = vector.DotProduct(query, c)
+	}
+	sort.Slice(order, func(a, b int) bool { return listScores[order[a]] > listScores[order[b]] })
+	if nprobe < len(order) {
+		order = order[:nprobe]
+	}
+
+	// Lookup table: partial inner product of each query subvector with each code
+	sd := p.config.SubspaceDims
+	table := make([]float32, p.subspaces*p.numCodes)
+	for m := 0; m < p.subspaces; m++ {
+		sub := query[m*sd : (m+1)*sd]
+		book := p.codebooks[m]
+		for c := 0; c < p.numCodes; c++ {
+			var dot float32
+			for j, v := range book[c*sd : (c+1)*sd] {
+				dot += sub[j] * v
+			}
+			table[m*p.numCodes+c] = dot
+		}
+	}
+
+	top := &pqResultHeap{}
+	for _, li := range order {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		l := &p.lists[li]
+		for i, id := range l.ids {
+			code := l.codes[i*p.subspaces : (i+1)*p.subspaces]
+			var score float32
+			for m, c := range code {
+				score += table[m*p.numCodes+int(c)]
+			}
+			if top.Len() < n {
+				heap.Push(top, indexResult{ID: id, Score: float64(score)})
+			} else if float64(score) > (*top)[0].Score {
+				(*top)[0] = indexResult{ID: id, Score: float64(score)}
+				heap.Fix(top, 0)
+			}
+		}
+	}
+
+	results := make([]indexResult, top.Len())
+	for i := len(results) - 1; i >= 0; i-- {
+		results[i] = heap.Pop(top).(indexResult)
+	}
+	return results, nil
+}
+
+// rerankExact re-scores candidates with their exact vectors and drops those
+// whose vectors are gone. Returns candidates sorted best first.
+func rerankExact(candidates []indexResult, query []float32, exact func(ids []string) [][]float32, manager *gpu.Manager) []indexResult {
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+	}
+	vectors := exact(ids)
+
+	pairs := make([]gpu.Pair, 0, len(candidates))
+	kept := make([]indexResult, 0, len(candidates))
+	for i, vec := range vectors {
+		if len(vec) != len(query) {
+			continue
+		}
+		pairs = append(pairs, gpu.Pair{Query: query, Candidate: vec})
+		kept = append(kept, candidates[i])
+	}
+	scores, err := gpu.ScorePairs(manager, pairs)
+	if err != nil {
+		return candidates // Keep approximate scores
+	}
+	for i := range kept {
+		kept[i].Score = float64(scores[i])
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Score > kept[j].Score })
+	return kept
+}
+
+// pqKMeans clusters data into k centroids with Lloyd's algorithm, seeding
+// from distinct random samples. Empty clusters keep their previous centroid.
+func pqKMeans(data [][]float32, k, iterations int, rng *rand.Rand) [][]float32 {
+	dims := len(data[0])
+	centroids := make([][]float32, k)
+	for i, idx := range rng.Perm(len(data))[:k] {
+		centroids[i] = append([]float32(nil), data[idx]...)
+	}
+
+	assign := make([]int, len(data))
+	sums := make([]float64, k*dims)
+	counts := make([]int, k)
+	for iter := 0; iter < iterations; iter++ {
+		changed := 0
+		for i, vec := range data {
+			c := nearestCentroid(centroids, vec)
+			if c != assign[i] || iter == 0 {
+				changed++
+			}
+			assign[i] = c
+		}
+		if changed == 0 {
+			break
+		}
+
+		for i := range sums {
+			sums[i] = 0
+		}
+		for i := range counts {
+			counts[i] = 0
+		}
+		for i, vec := range data {
+			c := assign[i]
+			counts[c]++
+			for j, v := range vec {
+				sums[c*dims+j] += float64(v)
+			}
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue
+			}
+			for j := range centroids[c] {
+				centroids[c][j] = float32(sums[c*dims+j] / float64(counts[c]))
+			}
+		}
+	}
+	return centroids
+}
+
+// nearestCentroid returns the index of the centroid closest to vec.
+func nearestCentroid(centroids [][]float32, vec []float32) int {
+	best, bestDist := 0, math.MaxFloat64
+	for i, c := range centroids {
+		if d := squaredDistance(vec, c); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+func squaredDistance(a, b []float32) float64 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return float64(sum)
+}
+
+// pqResultHeap is a min-heap on score, keeping the best n candidates.
+type pqResultHeap []indexResult
+
+func (h pqResultHeap) Len() int            { return len(h) }
+func (h pqResultHeap) Less(i, j int) bool  { return h[i].Score < h[j].Score }
+func (h pqResultHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pqResultHeap) Push(x interface{}) { *h = append(*h, x.(indexResult)) }
+func (h *pqResultHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
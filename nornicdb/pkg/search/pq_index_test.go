@@ -0,0 +1,207 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/orneryd/nornicdb/pkg/math/vector"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clusteredVectors returns n vectors scattered around a few random centers,
+// which is closer to real embeddings than uniform noise.
+func clusteredVectors(n, dims int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	centers := make([][]float32, 16)
+	for i := range centers {
+		centers[i] = make([]float32, dims)
+		for j := range centers[i] {
+			centers[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		c := centers[rng.Intn(len(centers))]
+		vectors[i] = make([]float32, dims)
+		for j := range vectors[i] {
+			vectors[i][j] = c[j] + float32(rng.NormFloat64()*0.5)
+		}
+	}
+	return vectors
+}
+
+func newTestPQIndex(t *testing.T, vectors [][]float32, config PQConfig) *PQIndex {
+	t.Helper()
+	idx, err := NewPQIndex(len(vectors[0]), config)
+	require.NoError(t, err)
+	require.NoError(t, idx.Train(vectors))
+	for i, vec := range vectors {
+		require.NoError(t, idx.Add(fmt.Sprintf("v%d", i), vec))
+	}
+	idx.SetExactSource(func(ids []string) [][]float32 {
+		out := make([][]float32, len(ids))
+		for i, id := range ids {
+			var n int
+			fmt.Sscanf(id, "v%d", &n)
+			out[i] = vectors[n]
+		}
+		return out
+	}, nil)
+	return idx
+}
+
+func TestPQIndex_RecallAndCompression(t *testing.T) {
+	const n, dims = 3000, 64
+	vectors := clusteredVectors(n, dims, 1)
+	idx := newTestPQIndex(t, vectors, PQConfig{NumLists: 32, NProbe: 8, RerankK: 50})
+	ctx := context.Background()
+
+	assert.Equal(t, n, idx.Count())
+	assert.GreaterOrEqual(t, dims*4/idx.CodeSize(), 10, "codes are at least 10x smaller than fp32")
+
+	exact := NewVectorIndex(dims)
+	for i, vec := range vectors {
+		require.NoError(t, exact.Add(fmt.Sprintf("v%d", i), vec))
+	}
+
+	const k, queries = 10, 20
+	hits := 0
+	for q := 0; q < queries; q++ {
+		query := clusteredVectors(1, dims, int64(100+q))[0]
+		want, err := exact.Search(ctx, query, k, -1)
+		require.NoError(t, err)
+		got, err := idx.Search(ctx, query, k, -1)
+		require.NoError(t, err)
+		require.Len(t, got, k)
+
+		wantIDs := make(map[string]bool, k)
+		for _, r := range want {
+			wantIDs[r.ID] = true
+		}
+		for i, r := range got {
+			if wantIDs[r.ID] {
+				hits++
+			}
+			if i > 0 {
+				assert.GreaterOrEqual(t, got[i-1].Score, r.Score, "sorted best first")
+			}
+		}
+	}
+	recall := float64(hits) / float64(k*queries)
+	assert.GreaterOrEqual(t, recall, 0.8, "recall@10 after exact rerank")
+
+	// Reranked scores are exact cosine similarities
+	got, err := idx.Search(ctx, vectors[7], 1, 0)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "v7", got[0].ID)
+	assert.InDelta(t, 1.0, got[0].Score, 0.001)
+
+	// Probing every list and reranking more candidates never loses recall
+	wide, err := idx.SearchWithParams(ctx, vectors[42], k, -1, 32, 500)
+	require.NoError(t, err)
+	assert.Equal(t, "v42", wide[0].ID)
+}
+
+func TestPQIndex_AddRemove(t *testing.T) {
+	vectors := clusteredVectors(500, 16, 2)
+	ctx := context.Background()
+
+	idx, err := NewPQIndex(16, PQConfig{NumLists: 4})
+	require.NoError(t, err)
+	assert.ErrorIs(t, idx.Add("v0", vectors[0]), ErrPQNotTrained)
+	assert.False(t, idx.IsTrained())
+
+	idx = newTestPQIndex(t, vectors, PQConfig{NumLists: 4, NProbe: 4})
+	assert.True(t, idx.IsTrained())
+	assert.ErrorIs(t, idx.Add("bad", make([]float32, 8)), ErrDimensionMismatch)
+
+	// Re-adding replaces, removal swaps the last entry into place
+	require.NoError(t, idx.Add("v3", vectors[3]))
+	assert.Equal(t, 500, idx.Count())
+	for i := 0; i < 250; i++ {
+		idx.Remove(fmt.Sprintf("v%d", i))
+	}
+	idx.Remove("missing")
+	assert.Equal(t, 250, idx.Count())
+
+	got, err := idx.Search(ctx, vectors[300], 1, 0)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "v300", got[0].ID)
+	got, err = idx.Search(ctx, vectors[10], 250, -1)
+	require.NoError(t, err)
+	for _, r := range got {
+		assert.NotEqual(t, "v10", r.ID)
+	}
+
+	_, err = NewPQIndex(10, PQConfig{SubspaceDims: 4})
+	assert.Error(t, err, "dimensions must divide into subspaces")
+}
+
+func TestPQIndex_RerankDropsMissingVectors(t *testing.T) {
+	vectors := clusteredVectors(300, 8, 3)
+	idx := newTestPQIndex(t, vectors, PQConfig{NumLists: 2, NProbe: 2})
+	idx.SetExactSource(func(ids []string) [][]float32 {
+		out := make([][]float32, len(ids))
+		for i, id := range ids {
+			if id != "v5" {
+				var n int
+				fmt.Sscanf(id, "v%d", &n)
+				out[i] = vectors[n]
+			}
+		}
+		return out
+	}, nil)
+
+	got, err := idx.Search(context.Background(), vectors[5], 5, -1)
+	require.NoError(t, err)
+	for _, r := range got {
+		assert.NotEqual(t, "v5", r.ID, "deleted nodes are not returned")
+	}
+}
+
+func TestSearchService_Quantization(t *testing.T) {
+	engine := storage.NewMemoryEngine()
+	defer engine.Close()
+	ctx := context.Background()
+
+	vectors := clusteredVectors(MinEmbeddingsForQuantization, 1024, 4)
+	for i, vec := range vectors {
+		require.NoError(t, engine.CreateNode(&storage.Node{
+			ID: storage.NodeID(fmt.Sprintf("n%d", i)), Labels: []string{"Doc"}, Embedding: vector.Normalize(vec),
+		}))
+	}
+
+	svc := NewService(engine)
+	assert.Error(t, svc.TriggerQuantization(), "not enabled")
+	svc.EnableQuantization(nil, &PQConfig{NumLists: 16, NProbe: 4, SubspaceDims: 8, TrainIterations: 4, TrainSampleSize: 2000})
+	require.NoError(t, svc.BuildIndexes(ctx))
+	require.NoError(t, svc.TriggerQuantization())
+	require.True(t, svc.IsQuantized())
+	assert.Equal(t, MinEmbeddingsForQuantization, svc.EmbeddingCount())
+	assert.Zero(t, svc.vectorIndex.Count(), "fp32 copies are released")
+
+	opts := DefaultSearchOptions()
+	opts.Limit = 5
+	resp, err := svc.Search(ctx, "", vectors[123], opts)
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.Results)
+	assert.Equal(t, "n123", resp.Results[0].ID)
+	resp, err = svc.vectorSearchOnly(ctx, vectors[123], opts)
+	require.NoError(t, err)
+	assert.Equal(t, "vector_pq", resp.SearchMethod)
+	assert.Equal(t, "n123", resp.Results[0].ID)
+
+	// New and removed nodes go through the compressed index
+	extra := &storage.Node{ID: "extra", Labels: []string{"Doc"}, Embedding: vector.Normalize(vectors[9])}
+	require.NoError(t, engine.CreateNode(extra))
+	require.NoError(t, svc.IndexNode(extra))
+	require.NoError(t, svc.RemoveNode("n123"))
+	assert.Equal(t, MinEmbeddingsForQuantization, svc.EmbeddingCount())
+	assert.Zero(t, svc.vectorIndex.Count())
+}
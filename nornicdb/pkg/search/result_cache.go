@@ -0,0 +1,287 @@
+// Package search - semantic result cache for repeated queries.
+//
+// RAG workloads send many near-identical queries ("what is X?", "what's X")
+// whose embeddings differ by a rounding error. ResultCache buckets query
+// embeddings with a locality-sensitive hash (random hyperplane signs), so
+// near-identical vectors share a bucket, then confirms a hit with an exact
+// cosine check before returning the cached response.
+package search
+
+import (
+	"container/list"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/math/vector"
+)
+
+// ResultCacheConfig configures a ResultCache.
+type ResultCacheConfig struct {
+	MaxSize             int           // Maximum cached responses (default: 1000)
+	TTL                 time.Duration // Entry lifetime (default: 5 minutes, 0 = no expiry)
+	HashBits            int           // LSH signature bits; more bits = smaller buckets (default: 16)
+	SimilarityThreshold float64       // Minimum cosine to the cached query for a hit (default: 0.98)
+}
+
+// DefaultResultCacheConfig returns sensible defaults.
+func DefaultResultCacheConfig() ResultCacheConfig {
+	return ResultCacheConfig{
+		MaxSize:             1000,
+		TTL:                 5 * time.Minute,
+		HashBits:            16,
+		SimilarityThreshold: 0.98,
+	}
+}
+
+// ResultCacheStats holds result cache statistics.
+type ResultCacheStats struct {
+	Size          int     // Current number of entries
+	MaxSize       int     // Maximum capacity
+	Hits          uint64  // Lookups answered from the cache
+	Misses        uint64  // Lookups that ran a search
+	HitRate       float64 // Hit rate percentage (0-100)
+	Invalidations uint64  // Times the cache was cleared by index updates
+}
+
+// resultCacheEntry is one cached response.
+type resultCacheEntry struct {
+	bucket    uint64
+	embedding []float32 // Normalized query embedding
+	response  *SearchResponse
+	expiresAt time.Time
+}
+
+// ResultCache caches search responses keyed by query-vector similarity plus
+// the query text and search options. Any index update invalidates it.
+//
+// Example:
+//
+//	cache := search.NewResultCache(search.DefaultResultCacheConfig())
+//	if resp, ok := cache.Get(query, embedding, opts); ok {
+//		return resp
+//	}
+//	resp, _ := svc.Search(ctx, query, embedding, opts)
+//	cache.Put(query, embedding, opts, resp)
+//
+// Thread Safety:
+//
+//	All methods are thread-safe.
+type ResultCache struct {
+	config ResultCacheConfig
+	mu     sync.Mutex
+
+	// Hyperplanes per embedding dimensionality, created on first use
+	planes map[int][][]float32
+
+	list    *list.List // LRU order, most recent at front
+	buckets map[uint64][]*list.Element
+
+	hits          uint64
+	misses        uint64
+	invalidations uint64
+}
+
+// NewResultCache creates an empty result cache. Zero config fields take
+// their defaults.
+func NewResultCache(config ResultCacheConfig) *ResultCache {
+	defaults := DefaultResultCacheConfig()
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaults.MaxSize
+	}
+	if config.TTL < 0 {
+		config.TTL = 0
+	}
+	if config.HashBits <= 0 || config.HashBits > 64 {
+		config.HashBits = defaults.HashBits
+	}
+	if config.SimilarityThreshold <= 0 {
+		config.SimilarityThreshold = defaults.SimilarityThreshold
+	}
+	return &ResultCache{
+		config:  config,
+		planes:  make(map[int][][]float32),
+		list:    list.New(),
+		buckets: make(map[uint64][]*list.Element),
+	}
+}
+
+// Get returns a cached response for a query whose embedding is within the
+// similarity threshold of a cached one and whose text and options match.
+// The response is a copy the caller may modify.
+func (c *ResultCache) Get(query string, embedding []float32, opts *SearchOptions) (*SearchResponse, bool) {
+	normalized := vector.Normalize(embedding)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bucket := c.bucketKey(query, normalized, opts)
+	for _, elem := range c.buckets[bucket] {
+		entry := elem.Value.(*resultCacheEntry)
+		if c.config.TTL > 0 && time.Now().After(entry.expiresAt) {
+			c.removeElement(elem)
+			continue
+		}
+		if vector.DotProduct(normalized, entry.embedding) >= c.config.SimilarityThreshold {
+			c.list.MoveToFront(elem)
+			atomic.AddUint64(&c.hits, 1)
+			return copyResponse(entry.response), true
+		}
+	}
+	atomic.AddUint64(&c.misses, 1)
+	return nil, false
+}
+
+// Put caches a response, evicting the least recently used entry when full.
+func (c *ResultCache) Put(query string, embedding []float32, opts *SearchOptions, response *SearchResponse) {
+	normalized := vector.Normalize(embedding)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.list.Len() >= c.config.MaxSize {
+		c.removeElement(c.list.Back())
+	}
+	entry := &resultCacheEntry{
+		bucket:    c.bucketKey(query, normalized, opts),
+		embedding: normalized,
+		response:  copyResponse(response),
+	}
+	if c.config.TTL > 0 {
+		entry.expiresAt = time.Now().Add(c.config.TTL)
+	}
+	elem := c.list.PushFront(entry)
+	c.buckets[entry.bucket] = append(c.buckets[entry.bucket], elem)
+}
+
+// Invalidate removes all entries. Called when indexed data changes.
+func (c *ResultCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.list.Len() == 0 {
+		return
+	}
+	c.list.Init()
+	c.buckets = make(map[uint64][]*list.Element)
+	atomic.AddUint64(&c.invalidations, 1)
+}
+
+// Stats returns cache statistics.
+func (c *ResultCache) Stats() ResultCacheStats {
+	hits := atomic.LoadUint64(&c.hits)
+	misses := atomic.LoadUint64(&c.misses)
+
+	c.mu.Lock()
+	size := c.list.Len()
+	c.mu.Unlock()
+
+	var hitRate float64
+	if total := hits + misses; total > 0 {
+		hitRate = float64(hits) / float64(total) * 100
+	}
+	return ResultCacheStats{
+		Size:          size,
+		MaxSize:       c.config.MaxSize,
+		Hits:          hits,
+		Misses:        misses,
+		HitRate:       hitRate,
+		Invalidations: atomic.LoadUint64(&c.invalidations),
+	}
+}
+
+func (c *ResultCache) removeElement(elem *list.Element) {
+	entry := c.list.Remove(elem).(*resultCacheEntry)
+	elems := c.buckets[entry.bucket]
+	for i, e := range elems {
+		if e == elem {
+			elems = append(elems[:i], elems[i+1:]...)
+			break
+		}
+	}
+	if len(elems) == 0 {
+		delete(c.buckets, entry.bucket)
+	} else {
+		c.buckets[entry.bucket] = elems
+	}
+}
+
+// bucketKey hashes the LSH signature of the embedding together with every
+// input that changes the response.
+func (c *ResultCache) bucketKey(query string, normalized []float32, opts *SearchOptions) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], c.signature(normalized))
+	h.Write(buf[:])
+	h.Write([]byte(query))
+	h.Write([]byte{0})
+
+	if opts != nil {
+		for _, f := range []float64{
+			float64(opts.Limit), opts.MinSimilarity, opts.RRFK, opts.VectorWeight, opts.BM25Weight,
+			opts.MinRRFScore, opts.MMRLambda, float64(opts.RerankTopK), opts.RerankMinScore,
+			float64(opts.QuantizedNProbe), float64(opts.QuantizedRerankK),
+		} {
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+			h.Write(buf[:])
+		}
+		flags := byte(0)
+		if opts.MMREnabled {
+			flags |= 1
+		}
+		if opts.RerankEnabled {
+			flags |= 2
+		}
+		h.Write([]byte{flags})
+		h.Write([]byte(opts.VectorIndex))
+		h.Write([]byte{0})
+
+		types := append([]string(nil), opts.Types...)
+		sort.Strings(types)
+		for _, t := range types {
+			h.Write([]byte(t))
+			h.Write([]byte{0})
+		}
+	}
+	return h.Sum64()
+}
+
+// signature returns the sign bits of the embedding's projection on each
+// hyperplane. Similar vectors agree on most bits.
+func (c *ResultCache) signature(normalized []float32) uint64 {
+	planes, ok := c.planes[len(normalized)]
+	if !ok {
+		// Seeded by dimensionality so signatures are stable across restarts
+		rng := rand.New(rand.NewSource(int64(len(normalized))))
+		planes = make([][]float32, c.config.HashBits)
+		for i := range planes {
+			planes[i] = make([]float32, len(normalized))
+			for j := range planes[i] {
+				planes[i][j] = float32(rng.NormFloat64())
+			}
+		}
+		c.planes[len(normalized)] = planes
+	}
+
+	var sig uint64
+	for i, plane := range planes {
+		if vector.DotProduct(normalized, plane) >= 0 {
+			sig |= 1 << uint(i)
+		}
+	}
+	return sig
+}
+
+// copyResponse returns a copy of a response with its own Results slice.
+func copyResponse(resp *SearchResponse) *SearchResponse {
+	if resp == nil {
+		return nil
+	}
+	cp := *resp
+	cp.Results = append([]SearchResult(nil), resp.Results...)
+	return &cp
+}
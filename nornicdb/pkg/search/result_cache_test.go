@@ -0,0 +1,114 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultCache_SimilarQueriesHit(t *testing.T) {
+	cache := NewResultCache(DefaultResultCacheConfig())
+	opts := DefaultSearchOptions()
+	resp := &SearchResponse{Status: "success", Results: []SearchResult{{ID: "a"}}}
+
+	cache.Put("what is nornic", []float32{1, 0.5, 0.25, 0}, opts, resp)
+
+	// Scaled and slightly perturbed embeddings of the same query hit
+	got, ok := cache.Get("what is nornic", []float32{2, 1, 0.5, 0}, opts)
+	require.True(t, ok)
+	assert.Equal(t, "a", got.Results[0].ID)
+	_, ok = cache.Get("what is nornic", []float32{1, 0.5, 0.2501, 0.0001}, opts)
+	assert.True(t, ok)
+
+	// Hits are copies
+	got.Results[0].ID = "mutated"
+	got, _ = cache.Get("what is nornic", []float32{1, 0.5, 0.25, 0}, opts)
+	assert.Equal(t, "a", got.Results[0].ID)
+
+	// Different vector, text or options miss
+	_, ok = cache.Get("what is nornic", []float32{0, 0, 1, 1}, opts)
+	assert.False(t, ok)
+	_, ok = cache.Get("who is nornic", []float32{1, 0.5, 0.25, 0}, opts)
+	assert.False(t, ok)
+	filtered := *opts
+	filtered.Types = []string{"Doc"}
+	_, ok = cache.Get("what is nornic", []float32{1, 0.5, 0.25, 0}, &filtered)
+	assert.False(t, ok)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.Equal(t, uint64(3), stats.Misses)
+	assert.InDelta(t, 50.0, stats.HitRate, 0.001)
+	assert.Equal(t, 1, stats.Size)
+}
+
+func TestResultCache_EvictionTTLAndInvalidate(t *testing.T) {
+	cache := NewResultCache(ResultCacheConfig{MaxSize: 2, TTL: 50 * time.Millisecond})
+	opts := DefaultSearchOptions()
+	resp := &SearchResponse{Status: "success"}
+
+	cache.Put("a", []float32{1, 0}, opts, resp)
+	cache.Put("b", []float32{1, 0}, opts, resp)
+	cache.Put("c", []float32{1, 0}, opts, resp)
+	assert.Equal(t, 2, cache.Stats().Size)
+	_, ok := cache.Get("a", []float32{1, 0}, opts)
+	assert.False(t, ok, "least recently used entry is evicted")
+
+	time.Sleep(60 * time.Millisecond)
+	_, ok = cache.Get("c", []float32{1, 0}, opts)
+	assert.False(t, ok, "expired")
+
+	cache.Put("d", []float32{1, 0}, opts, resp)
+	cache.Invalidate()
+	cache.Invalidate()
+	assert.Equal(t, 0, cache.Stats().Size)
+	assert.Equal(t, uint64(1), cache.Stats().Invalidations, "empty cache is not counted")
+}
+
+func TestSearchService_ResultCache(t *testing.T) {
+	engine := storage.NewMemoryEngine()
+	defer engine.Close()
+	ctx := context.Background()
+
+	svc := NewService(engine)
+	assert.Equal(t, ResultCacheStats{}, svc.ResultCacheStats())
+	svc.EnableResultCache(DefaultResultCacheConfig())
+
+	embedding := make([]float32, 1024)
+	embedding[0] = 1
+	node := &storage.Node{ID: "a", Labels: []string{"Doc"}, Embedding: embedding,
+		Properties: map[string]any{"content": "cached document"}}
+	require.NoError(t, engine.CreateNode(node))
+	require.NoError(t, svc.IndexNode(node))
+
+	opts := DefaultSearchOptions()
+	first, err := svc.Search(ctx, "cached", embedding, opts)
+	require.NoError(t, err)
+	require.Len(t, first.Results, 1)
+	second, err := svc.Search(ctx, "cached", embedding, opts)
+	require.NoError(t, err)
+	assert.Equal(t, first.Results, second.Results)
+	assert.Equal(t, uint64(1), svc.ResultCacheStats().Hits)
+
+	// Text-only searches bypass the cache
+	_, err = svc.Search(ctx, "cached", nil, opts)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), svc.ResultCacheStats().Misses)
+
+	// Index updates invalidate
+	other := &storage.Node{ID: "b", Labels: []string{"Doc"}, Embedding: embedding,
+		Properties: map[string]any{"content": "cached copy"}}
+	require.NoError(t, engine.CreateNode(other))
+	require.NoError(t, svc.IndexNode(other))
+	third, err := svc.Search(ctx, "cached", embedding, opts)
+	require.NoError(t, err)
+	assert.Len(t, third.Results, 2)
+	assert.Equal(t, uint64(1), svc.ResultCacheStats().Invalidations)
+
+	require.NoError(t, svc.RemoveNode("b"))
+	assert.Equal(t, uint64(2), svc.ResultCacheStats().Invalidations)
+}
@@ -178,6 +178,14 @@ type SearchOptions struct {
 	RerankEnabled  bool    // Enable cross-encoder reranking (default: false)
 	RerankTopK     int     // How many candidates to rerank (default: 100)
 	RerankMinScore float64 // Minimum cross-encoder score to include (default: 0)
+
+	// VectorIndex names the vector index to search (from CREATE VECTOR INDEX).
+	// Empty routes by the embedding's dimensions.
+	VectorIndex string
+
+	// Quantized search tuning, used once the quantizer is trained
+	QuantizedNProbe  int // Inverted lists scanned (0 = PQConfig.NProbe)
+	QuantizedRerankK int // Candidates re-scored exactly (0 = PQConfig.RerankK)
 }
 
 // DefaultSearchOptions returns sensible defaults.
@@ -224,6 +232,7 @@ func DefaultSearchOptions() *SearchOptions {
 type Service struct {
 	engine        storage.Engine
 	vectorIndex   *VectorIndex
+	vectorIndexes *VectorIndexManager // Named indexes from CREATE VECTOR INDEX
 	fulltextIndex *FulltextIndex
 	crossEncoder  *CrossEncoder
 	mu            sync.RWMutex
@@ -231,6 +240,18 @@ type Service struct {
 	// GPU k-means clustering for accelerated search (optional)
 	clusterIndex   *gpu.ClusterIndex
 	clusterEnabled bool
+
+	// Node IDs loaded by LoadClusterIndex that BuildIndexes has not seen yet;
+	// whatever remains afterwards was deleted while the index was on disk.
+	clusterUnseen map[string]struct{}
+
+	// IVF-PQ compressed vectors (optional); replaces vectorIndex once trained
+	pqConfig     *PQConfig
+	pqIndex      *PQIndex
+	pqGPUManager *gpu.Manager
+
+	// Semantic cache of recent responses (optional); cleared on index updates
+	resultCache *ResultCache
 }
 
 // NewService creates a new search Service with empty indexes.
@@ -326,6 +347,7 @@ func NewService(engine storage.Engine) *Service {
 	return &Service{
 		engine:        engine,
 		vectorIndex:   NewVectorIndex(1024), // Default to 1024 dimensions (mxbai-embed-large)
+		vectorIndexes: NewVectorIndexManager(),
 		fulltextIndex: NewFulltextIndex(),
 	}
 }
@@ -362,7 +384,14 @@ func (s *Service) EnableClustering(gpuManager *gpu.Manager, numClusters int) {
 
 	embConfig := gpu.DefaultEmbeddingIndexConfig(1024) // Match vector index dimensions
 
-	s.clusterIndex = gpu.NewClusterIndex(gpuManager, embConfig, kmeansConfig)
+	clusterIndex := gpu.NewClusterIndex(gpuManager, embConfig, kmeansConfig)
+	if s.clusterIndex != nil {
+		// Carry embeddings over when upgrading (e.g. CPU -> GPU)
+		if data, err := s.clusterIndex.Serialize(); err == nil {
+			_ = clusterIndex.Deserialize(data)
+		}
+	}
+	s.clusterIndex = clusterIndex
 	s.clusterEnabled = true
 
 	mode := "CPU"
@@ -435,6 +464,40 @@ func (s *Service) ClusterStats() *gpu.ClusterStats {
 	return &stats
 }
 
+// SaveClusterIndex persists the clustering embedding index to path so the
+// next start can load it with LoadClusterIndex instead of rebuilding GPU
+// buffers from the store. Returns an error if clustering is not enabled.
+func (s *Service) SaveClusterIndex(path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.clusterIndex == nil {
+		return fmt.Errorf("clustering not enabled")
+	}
+	return s.clusterIndex.SaveFile(path)
+}
+
+// LoadClusterIndex loads a clustering embedding index saved by
+// SaveClusterIndex. Call it after EnableClustering and before BuildIndexes;
+// BuildIndexes then only updates the loaded vectors in place.
+// Returns an error if clustering is not enabled or the file fails validation.
+func (s *Service) LoadClusterIndex(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clusterIndex == nil {
+		return fmt.Errorf("clustering not enabled")
+	}
+	start := time.Now()
+	if err := s.clusterIndex.LoadFile(path); err != nil {
+		return err
+	}
+	s.clusterUnseen = make(map[string]struct{}, s.clusterIndex.Count())
+	for _, id := range s.clusterIndex.NodeIDs() {
+		s.clusterUnseen[id] = struct{}{}
+	}
+	log.Printf("[K-MEANS] 📂 LOADED | embeddings=%d duration=%v", s.clusterIndex.Count(), time.Since(start))
+	return nil
+}
+
 // EmbeddingCount returns the total number of nodes with embeddings in the vector index.
 func (s *Service) EmbeddingCount() int {
 	s.mu.RLock()
@@ -442,24 +505,199 @@ func (s *Service) EmbeddingCount() int {
 	if s.vectorIndex == nil {
 		return 0
 	}
-	return s.vectorIndex.Count()
+	count := s.vectorIndex.Count()
+	if s.pqIndex != nil {
+		count += s.pqIndex.Count()
+	}
+	return count
+}
+
+// EnableResultCache caches search responses for queries whose embeddings
+// are near-identical to a recent query with the same text and options.
+// Indexing or removing any node clears the cache.
+//
+// Example:
+//
+//	svc.EnableResultCache(search.DefaultResultCacheConfig())
+//	resp, _ := svc.Search(ctx, query, embedding, opts) // Miss: runs the search
+//	resp, _ = svc.Search(ctx, query, embedding, opts)  // Hit: cached response
+//	fmt.Printf("hit rate: %.1f%%\n", svc.ResultCacheStats().HitRate)
+func (s *Service) EnableResultCache(config ResultCacheConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resultCache = NewResultCache(config)
+}
+
+// ResultCacheStats returns result cache statistics (zero if not enabled).
+func (s *Service) ResultCacheStats() ResultCacheStats {
+	s.mu.RLock()
+	cache := s.resultCache
+	s.mu.RUnlock()
+	if cache == nil {
+		return ResultCacheStats{}
+	}
+	return cache.Stats()
+}
+
+// invalidateResultCache clears cached responses after a change that is not
+// made under the service lock.
+func (s *Service) invalidateResultCache() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.resultCache != nil {
+		s.resultCache.Invalidate()
+	}
+}
+
+// MinEmbeddingsForQuantization is the minimum number of embeddings needed to
+// train the quantizer. Codebooks learned from fewer vectors lose too much
+// recall, and the memory saved is negligible.
+const MinEmbeddingsForQuantization = 4096
+
+// EnableQuantization enables IVF-PQ compression of the default vector index.
+// Once TriggerQuantization trains the quantizer, embeddings are held as
+// compact PQ codes (16x smaller with the default config) and searches rerank
+// the best candidates with exact vectors read from storage.
+//
+// Parameters:
+//   - gpuManager: GPU manager for exact reranking (can be nil for CPU-only)
+//   - config: PQ parameters (nil for DefaultPQConfig())
+//
+// Example:
+//
+//	svc.EnableQuantization(nil, nil)
+//	svc.BuildIndexes(ctx)
+//	svc.TriggerQuantization() // Train and compress
+func (s *Service) EnableQuantization(gpuManager *gpu.Manager, config *PQConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if config == nil {
+		defaults := DefaultPQConfig()
+		config = &defaults
+	}
+	s.pqConfig = config
+	s.pqGPUManager = gpuManager
+	if s.pqIndex != nil {
+		s.pqIndex.SetExactSource(s.exactVectors, gpuManager)
+	}
+	log.Printf("[PQ] ✅ Quantization ENABLED | nprobe=%d rerank_k=%d subspace_dims=%d",
+		config.NProbe, config.RerankK, config.SubspaceDims)
+}
+
+// IsQuantized returns true once embeddings are held in the trained PQ index.
+func (s *Service) IsQuantized() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pqIndex != nil
+}
+
+// TriggerQuantization trains the PQ quantizer on the indexed embeddings and
+// moves them into the compressed index, releasing the fp32 copies.
+//
+// Returns nil (not error) if there are too few embeddings or the index is
+// already quantized. Returns error only if quantization is not enabled or
+// training fails.
+func (s *Service) TriggerQuantization() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pqConfig == nil {
+		return fmt.Errorf("quantization not enabled - call EnableQuantization() first")
+	}
+	if s.pqIndex != nil {
+		return nil
+	}
+
+	embeddingCount := s.vectorIndex.Count()
+	if embeddingCount < MinEmbeddingsForQuantization {
+		log.Printf("[PQ] ⏭️  SKIPPED | embeddings=%d threshold=%d reason=too_few_embeddings",
+			embeddingCount, MinEmbeddingsForQuantization)
+		return nil
+	}
+
+	start := time.Now()
+	pq, err := NewPQIndex(s.vectorIndex.GetDimensions(), *s.pqConfig)
+	if err != nil {
+		return err
+	}
+	s.vectorIndex.mu.RLock()
+	vectors := make([][]float32, 0, embeddingCount)
+	for _, vec := range s.vectorIndex.vectors {
+		vectors = append(vectors, vec)
+	}
+	s.vectorIndex.mu.RUnlock()
+	if err := pq.Train(vectors); err != nil {
+		log.Printf("[PQ] ❌ FAILED | embeddings=%d error=%v", embeddingCount, err)
+		return fmt.Errorf("quantizer training failed: %w", err)
+	}
+
+	s.vectorIndex.mu.RLock()
+	for id, vec := range s.vectorIndex.vectors {
+		_ = pq.Add(id, vec)
+	}
+	s.vectorIndex.mu.RUnlock()
+	pq.SetExactSource(s.exactVectors, s.pqGPUManager)
+	s.pqIndex = pq
+	s.vectorIndex = NewVectorIndex(pq.dimensions)
+
+	log.Printf("[PQ] ✅ COMPLETE | embeddings=%d code_bytes=%d compression=%.0fx duration=%v",
+		pq.Count(), pq.CodeSize(), float64(pq.dimensions*4)/float64(pq.CodeSize()), time.Since(start))
+	return nil
+}
+
+// exactVectors reads the fp32 embeddings the PQ index reranks with.
+func (s *Service) exactVectors(ids []string) [][]float32 {
+	nodeIDs := make([]storage.NodeID, len(ids))
+	for i, id := range ids {
+		nodeIDs[i] = storage.NodeID(id)
+	}
+	vectors := make([][]float32, len(ids))
+	nodes, err := s.engine.BatchGetNodes(nodeIDs)
+	if err != nil {
+		return vectors
+	}
+	for i, id := range nodeIDs {
+		if node, ok := nodes[id]; ok && node != nil {
+			vectors[i] = node.Embedding
+		}
+	}
+	return vectors
 }
 
 // IndexNode adds a node to all search indexes.
 func (s *Service) IndexNode(node *storage.Node) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.resultCache != nil {
+		s.resultCache.Invalidate()
+	}
+
+	// Named indexes take vectors of any dimensionality they were declared with
+	named := s.vectorIndexes.IndexNode(node)
 
 	// Add to vector index if node has embedding
-	if len(node.Embedding) > 0 {
-		// DEBUG: Print embedding info
-		// fmt.Printf("DEBUG: IndexNode %s has %d-dim embedding\n", node.ID, len(node.Embedding))
+	var vectorErr error
+	if len(node.Embedding) > 0 && len(node.Embedding) != s.vectorIndex.GetDimensions() {
+		// Not fatal: the node stays full-text searchable
+		s.vectorIndex.Remove(string(node.ID))
+		if s.pqIndex != nil {
+			s.pqIndex.Remove(string(node.ID))
+		}
+		if named == 0 {
+			vectorErr = ErrDimensionMismatch
+		}
+	} else if s.pqIndex != nil && len(node.Embedding) > 0 {
+		if err := s.pqIndex.Add(string(node.ID), node.Embedding); err != nil {
+			return err
+		}
+	} else if len(node.Embedding) > 0 {
 		if err := s.vectorIndex.Add(string(node.ID), node.Embedding); err != nil {
 			return err
 		}
 
 		// Also add to cluster index if enabled
 		if s.clusterIndex != nil {
+			delete(s.clusterUnseen, string(node.ID))
 			if err := s.clusterIndex.Add(string(node.ID), node.Embedding); err != nil {
 				// Log but don't fail - cluster index is optional
 				log.Printf("Warning: failed to add to cluster index: %v", err)
@@ -473,15 +711,22 @@ func (s *Service) IndexNode(node *storage.Node) error {
 		s.fulltextIndex.Index(string(node.ID), text)
 	}
 
-	return nil
+	return vectorErr
 }
 
 // RemoveNode removes a node from all search indexes.
 func (s *Service) RemoveNode(nodeID storage.NodeID) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.resultCache != nil {
+		s.resultCache.Invalidate()
+	}
 
 	s.vectorIndex.Remove(string(nodeID))
+	if s.pqIndex != nil {
+		s.pqIndex.Remove(string(nodeID))
+	}
+	s.vectorIndexes.RemoveNode(nodeID)
 	s.fulltextIndex.Remove(string(nodeID))
 	return nil
 }
@@ -494,6 +739,8 @@ type NodeIterator interface {
 // BuildIndexes builds search indexes from all nodes in the engine.
 // Prefers streaming iteration to avoid loading all nodes into memory.
 func (s *Service) BuildIndexes(ctx context.Context) error {
+	s.syncVectorIndexes()
+
 	// Try streaming iterator first (memory efficient)
 	if iterator, ok := s.engine.(NodeIterator); ok {
 		count := 0
@@ -514,6 +761,7 @@ func (s *Service) BuildIndexes(ctx context.Context) error {
 			return err
 		}
 		fmt.Printf("📊 Indexed %d total nodes\n", count)
+		s.pruneClusterUnseen(ctx)
 		return nil
 	}
 
@@ -533,9 +781,193 @@ func (s *Service) BuildIndexes(ctx context.Context) error {
 		return err
 	}
 	fmt.Printf("📊 Indexed %d total nodes\n", count)
+	s.pruneClusterUnseen(ctx)
+	return nil
+}
+
+// pruneClusterUnseen drops loaded cluster index entries for nodes that no
+// longer exist. Only runs after a complete build and before clustering, since
+// removal reorders embeddings under existing cluster assignments.
+func (s *Service) pruneClusterUnseen(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clusterUnseen == nil || s.clusterIndex == nil {
+		return
+	}
+	if ctx.Err() == nil && !s.clusterIndex.IsClustered() {
+		for id := range s.clusterUnseen {
+			s.clusterIndex.Remove(id)
+		}
+		if len(s.clusterUnseen) > 0 {
+			log.Printf("[K-MEANS] 🧹 PRUNED | stale_embeddings=%d", len(s.clusterUnseen))
+		}
+	}
+	s.clusterUnseen = nil
+}
+
+// VectorIndexes returns the manager of named vector indexes.
+func (s *Service) VectorIndexes() *VectorIndexManager {
+	return s.vectorIndexes
+}
+
+// syncVectorIndexes registers vector indexes defined in the storage schema
+// that the manager does not have yet. They fill as nodes are indexed.
+func (s *Service) syncVectorIndexes() {
+	schema := s.engine.GetSchema()
+	if schema == nil || s.vectorIndexes == nil {
+		return
+	}
+	for _, idx := range schema.GetVectorIndexes() {
+		spec := VectorIndexSpec{
+			Name:       idx.Name,
+			Label:      idx.Label,
+			Property:   idx.Property,
+			Dimensions: idx.Dimensions,
+			Similarity: idx.SimilarityFunc,
+		}
+		if _, err := s.vectorIndexes.Create(spec); err != nil {
+			log.Printf("Warning: skipping vector index %s: %v", idx.Name, err)
+		}
+	}
+}
+
+// CreateVectorIndex registers a named vector index and populates it from
+// nodes already in storage. Creating an existing index is a no-op.
+//
+// The population runs in the background, so it neither blocks the caller
+// nor writes: nodes written meanwhile are indexed as usual, and take
+// precedence over the copies read by the scan. VectorIndexes().Status
+// reports its progress; searches of the index wait until it is online.
+// ctx is not used by the population, which outlives the request creating
+// the index; dropping the index stops it.
+//
+// Example:
+//
+//	svc.CreateVectorIndex(ctx, search.VectorIndexSpec{
+//		Name: "image_embeddings", Label: "Image", Property: "clip", Dimensions: 512,
+//	})
+//	err := svc.VectorIndexes().Await(ctx, "image_embeddings")
+func (s *Service) CreateVectorIndex(ctx context.Context, spec VectorIndexSpec) error {
+	popCtx, pop, err := s.vectorIndexes.startPopulation(spec)
+	if err != nil || pop == nil {
+		return err
+	}
+	go s.populateVectorIndex(popCtx, pop)
 	return nil
 }
 
+// populateVectorIndex scans the nodes a new vector index covers.
+func (s *Service) populateVectorIndex(ctx context.Context, pop *population) {
+	spec := pop.index.spec
+	backfill := func(node *storage.Node) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.vectorIndexes.backfill(pop, node)
+		return nil
+	}
+
+	var err error
+	if spec.Label != "" {
+		var nodes []*storage.Node
+		if nodes, err = s.engine.GetNodesByLabel(spec.Label); err == nil {
+			s.vectorIndexes.setTotal(pop, int64(len(nodes)))
+			for _, node := range nodes {
+				if err = backfill(node); err != nil {
+					break
+				}
+			}
+		}
+	} else {
+		if count, countErr := s.engine.NodeCount(); countErr == nil {
+			s.vectorIndexes.setTotal(pop, count)
+		}
+		err = storage.StreamNodesWithFallback(ctx, s.engine, 1000, backfill)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Warning: populating vector index %s failed: %v", spec.Name, err)
+	}
+	s.vectorIndexes.finishPopulation(pop, err)
+	s.invalidateResultCache() // No partial results stay cached
+}
+
+// DropVectorIndex removes a named vector index. Returns false if it did not exist.
+func (s *Service) DropVectorIndex(name string) bool {
+	if !s.vectorIndexes.Drop(name) {
+		return false
+	}
+	s.invalidateResultCache()
+	return true
+}
+
+// QueryVectorIndex returns the k nodes most similar to query in the named
+// vector index. Indexes defined in the storage schema but not yet loaded are
+// built on first use.
+//
+// Returns ErrVectorIndexNotFound for unknown indexes and ErrDimensionMismatch
+// if the query's dimensions differ from the index's.
+func (s *Service) QueryVectorIndex(ctx context.Context, name string, query []float32, k int) ([]SearchResult, error) {
+	if _, exists := s.vectorIndexes.Get(name); !exists {
+		schema := s.engine.GetSchema()
+		if schema == nil {
+			return nil, fmt.Errorf("%w: %s", ErrVectorIndexNotFound, name)
+		}
+		idx, ok := schema.GetVectorIndex(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrVectorIndexNotFound, name)
+		}
+		if err := s.CreateVectorIndex(ctx, VectorIndexSpec{
+			Name:       idx.Name,
+			Label:      idx.Label,
+			Property:   idx.Property,
+			Dimensions: idx.Dimensions,
+			Similarity: idx.SimilarityFunc,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := s.vectorIndexes.Search(ctx, name, query, k, math.Inf(-1))
+	if err != nil {
+		return nil, err
+	}
+	return s.enrichIndexResults(results, len(results)), nil
+}
+
+// routeVectorIndex picks the named vector index a search embedding goes to:
+// opts.VectorIndex if set, otherwise "" (the default index) when the
+// dimensions match it, otherwise the first named index of those dimensions.
+func (s *Service) routeVectorIndex(embedding []float32, opts *SearchOptions) (string, error) {
+	if opts.VectorIndex != "" {
+		if _, exists := s.vectorIndexes.Get(opts.VectorIndex); !exists {
+			return "", fmt.Errorf("%w: %s", ErrVectorIndexNotFound, opts.VectorIndex)
+		}
+		return opts.VectorIndex, nil
+	}
+	if len(embedding) == s.vectorIndex.GetDimensions() {
+		return "", nil
+	}
+	if name, ok := s.vectorIndexes.ForDimensions(len(embedding)); ok {
+		return name, nil
+	}
+	return "", ErrDimensionMismatch
+}
+
+// searchVectors runs the vector stage of a search on the routed index.
+func (s *Service) searchVectors(ctx context.Context, embedding []float32, limit int, opts *SearchOptions) ([]indexResult, error) {
+	name, err := s.routeVectorIndex(embedding, opts)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		return s.vectorIndexes.Search(ctx, name, embedding, limit, opts.MinSimilarity)
+	}
+	if s.pqIndex != nil {
+		return s.pqIndex.SearchWithParams(ctx, embedding, limit, opts.MinSimilarity, opts.QuantizedNProbe, opts.QuantizedRerankK)
+	}
+	return s.vectorIndex.Search(ctx, embedding, limit, opts.MinSimilarity)
+}
+
 // Search performs hybrid search with automatic fallback.
 //
 // Search strategy:
@@ -582,6 +1014,25 @@ func (s *Service) Search(ctx context.Context, query string, embedding []float32,
 		opts = DefaultSearchOptions()
 	}
 
+	s.mu.RLock()
+	cache := s.resultCache
+	s.mu.RUnlock()
+	if cache == nil || len(embedding) == 0 {
+		return s.search(ctx, query, embedding, opts)
+	}
+
+	if response, ok := cache.Get(query, embedding, opts); ok {
+		return response, nil
+	}
+	response, err := s.search(ctx, query, embedding, opts)
+	if err == nil {
+		cache.Put(query, embedding, opts, response)
+	}
+	return response, err
+}
+
+// search runs Search without the result cache.
+func (s *Service) search(ctx context.Context, query string, embedding []float32, opts *SearchOptions) (*SearchResponse, error) {
 	// If no embedding provided, fall back to full-text only
 	if len(embedding) == 0 {
 		return s.fullTextSearchOnly(ctx, query, opts)
@@ -614,7 +1065,7 @@ func (s *Service) rrfHybridSearch(ctx context.Context, query string, embedding [
 	candidateLimit := opts.Limit * 2
 
 	// Step 1: Vector search
-	vectorResults, err := s.vectorIndex.Search(ctx, embedding, candidateLimit, opts.MinSimilarity)
+	vectorResults, err := s.searchVectors(ctx, embedding, candidateLimit, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -1013,8 +1464,22 @@ func (s *Service) vectorSearchOnly(ctx context.Context, embedding []float32, opt
 	message := "Vector similarity search (cosine)"
 	searchStart := time.Now()
 
-	// Use cluster-accelerated search if available and has been clustered
-	if s.clusterIndex != nil && s.clusterIndex.IsClustered() {
+	indexName, err := s.routeVectorIndex(embedding, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexName != "" {
+		// Named index of another model - not covered by the cluster index
+		results, err = s.vectorIndexes.Search(ctx, indexName, embedding, opts.Limit*2, opts.MinSimilarity)
+		message = fmt.Sprintf("Vector similarity search (index %s)", indexName)
+	} else if s.pqIndex != nil {
+		// Compressed candidate scan, exact rerank of the best candidates
+		results, err = s.pqIndex.SearchWithParams(ctx, embedding, opts.Limit*2, opts.MinSimilarity, opts.QuantizedNProbe, opts.QuantizedRerankK)
+		searchMethod = "vector_pq"
+		message = "Quantized vector search with exact rerank"
+	} else if s.clusterIndex != nil && s.clusterIndex.IsClustered() {
+		// Use cluster-accelerated search if available and has been clustered
 		// Search using k-means clusters (much faster for large datasets)
 		numClustersToSearch := 3
 		clusterResults, clusterErr := s.clusterIndex.SearchWithClusters(embedding, opts.Limit*2, numClustersToSearch)
@@ -277,6 +277,40 @@ func TestSearchService_BuildIndexes(t *testing.T) {
 	assert.Equal(t, 2, svc.fulltextIndex.Count())
 }
 
+// TestSearchService_ClusterIndexPersistence tests saving the clustering index
+// and loading it on the next start.
+func TestSearchService_ClusterIndexPersistence(t *testing.T) {
+	engine := storage.NewMemoryEngine()
+	defer engine.Close()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.idx")
+
+	embedding := make([]float32, 1024)
+	embedding[0] = 1.0
+	for _, id := range []storage.NodeID{"keep", "gone"} {
+		require.NoError(t, engine.CreateNode(&storage.Node{ID: id, Labels: []string{"Node"}, Embedding: embedding}))
+	}
+
+	svc := NewService(engine)
+	assert.Error(t, svc.SaveClusterIndex(path), "clustering not enabled")
+	svc.EnableClustering(nil, 2)
+	require.NoError(t, svc.BuildIndexes(ctx))
+	require.NoError(t, svc.SaveClusterIndex(path))
+
+	// Node deleted while the database was down
+	require.NoError(t, engine.DeleteNode("gone"))
+
+	restarted := NewService(engine)
+	restarted.EnableClustering(nil, 2)
+	require.NoError(t, restarted.LoadClusterIndex(path))
+	assert.Equal(t, 2, restarted.clusterIndex.Count())
+	require.NoError(t, restarted.BuildIndexes(ctx))
+	assert.Equal(t, 1, restarted.clusterIndex.Count(), "stale embeddings pruned after rebuild")
+	assert.True(t, restarted.clusterIndex.Has("keep"))
+
+	assert.True(t, os.IsNotExist(restarted.LoadClusterIndex(filepath.Join(t.TempDir(), "missing.idx"))))
+}
+
 // TestSearchService_WithRealData tests search with exported Neo4j data.
 func TestSearchService_WithRealData(t *testing.T) {
 	// Path to exported data
@@ -0,0 +1,450 @@
+// Package search - named vector indexes for mixed embedding models.
+//
+// Different labels may carry embeddings from different models, so one
+// fixed-dimension vector index cannot serve them all. VectorIndexManager keeps
+// one index per CREATE VECTOR INDEX definition (name → label, property,
+// dimensions, similarity) and routes nodes and queries to the right one.
+//
+// An index created over existing nodes is populated in the background
+// (see Service.CreateVectorIndex). Until it is online, Search waits for it.
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/orneryd/nornicdb/pkg/convert"
+	"github.com/orneryd/nornicdb/pkg/math/vector"
+	"github.com/orneryd/nornicdb/pkg/storage"
+)
+
+var (
+	// ErrVectorIndexNotFound is returned when a query names an unknown vector index.
+	ErrVectorIndexNotFound = errors.New("vector index not found")
+
+	// ErrVectorIndexFailed is returned when a query names a vector index
+	// whose population failed. Drop and create it again.
+	ErrVectorIndexFailed = errors.New("vector index population failed")
+)
+
+// Vector index states, as reported by db.indexes().
+const (
+	IndexPopulating = "POPULATING"
+	IndexOnline     = "ONLINE"
+	IndexFailed     = "FAILED"
+)
+
+// VectorIndexStatus reports the state of a vector index.
+type VectorIndexStatus struct {
+	State    string  // IndexPopulating, IndexOnline or IndexFailed
+	Progress float64 // Percentage of existing nodes scanned (100 once online)
+	Error    string  // Why population failed
+}
+
+// VectorIndexSpec defines a named vector index.
+type VectorIndexSpec struct {
+	Name       string
+	Label      string // Only nodes with this label are indexed ("" = all nodes)
+	Property   string // Property holding the vector ("" = node embedding)
+	Dimensions int
+	Similarity string // "cosine", "euclidean" or "dot"
+}
+
+// namedVectorIndex holds the vectors of one VectorIndexSpec.
+type namedVectorIndex struct {
+	spec    VectorIndexSpec
+	vectors map[string][]float32
+
+	// Background population; nil once the index is online
+	population *population
+}
+
+// population tracks the scan filling a new index with existing nodes.
+// Writes during the scan are indexed directly and recorded in written, so
+// the scan does not replace them with the older copy it read. Fields other
+// than done are guarded by VectorIndexManager.mu.
+type population struct {
+	index   *namedVectorIndex
+	cancel  context.CancelFunc
+	done    chan struct{} // closed when the scan ends
+	written map[string]bool
+	scanned int64
+	total   int64 // 0 = unknown
+	err     error
+}
+
+// VectorIndexManager maintains multiple named vector indexes of independent
+// dimensionality and similarity function.
+//
+// Example:
+//
+//	m := search.NewVectorIndexManager()
+//	m.Create(search.VectorIndexSpec{Name: "doc_idx", Label: "Doc", Dimensions: 1024})
+//	m.Create(search.VectorIndexSpec{Name: "img_idx", Label: "Image", Property: "clip", Dimensions: 512})
+//	m.IndexNode(node) // Added to every index whose label and dimensions match
+//	results, _ := m.Search(ctx, "img_idx", clipQuery, 10, 0.5)
+//
+// Thread Safety:
+//
+//	All methods are thread-safe. Lookups and node updates on a nil manager
+//	are no-ops, so services built without one keep working.
+type VectorIndexManager struct {
+	mu      sync.RWMutex
+	indexes map[string]*namedVectorIndex
+}
+
+// NewVectorIndexManager creates a manager with no indexes.
+func NewVectorIndexManager() *VectorIndexManager {
+	return &VectorIndexManager{indexes: make(map[string]*namedVectorIndex)}
+}
+
+// Create registers a vector index. Returns false if an index with the same
+// name already exists (IF NOT EXISTS semantics); the existing one is kept.
+func (m *VectorIndexManager) Create(spec VectorIndexSpec) (bool, error) {
+	idx, err := m.create(spec)
+	return idx != nil, err
+}
+
+// create validates and registers spec, returning nil if the name is taken.
+func (m *VectorIndexManager) create(spec VectorIndexSpec) (*namedVectorIndex, error) {
+	if spec.Name == "" {
+		return nil, fmt.Errorf("vector index name required")
+	}
+	if spec.Dimensions <= 0 {
+		return nil, fmt.Errorf("vector index %s: dimensions must be positive, got %d", spec.Name, spec.Dimensions)
+	}
+	switch spec.Similarity {
+	case "":
+		spec.Similarity = "cosine"
+	case "cosine", "euclidean", "dot":
+	default:
+		return nil, fmt.Errorf("vector index %s: unsupported similarity function %q", spec.Name, spec.Similarity)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.indexes[spec.Name]; exists {
+		return nil, nil
+	}
+	idx := &namedVectorIndex{spec: spec, vectors: make(map[string][]float32)}
+	m.indexes[spec.Name] = idx
+	return idx, nil
+}
+
+// startPopulation registers spec as a populating index and returns its
+// population, or nil if the name is taken. The caller scans the existing
+// nodes with backfill and ends with finishPopulation; Drop cancels ctx.
+func (m *VectorIndexManager) startPopulation(spec VectorIndexSpec) (context.Context, *population, error) {
+	idx, err := m.create(spec)
+	if idx == nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	pop := &population{index: idx, cancel: cancel, done: make(chan struct{}), written: make(map[string]bool)}
+	m.mu.Lock()
+	idx.population = pop
+	m.mu.Unlock()
+	return ctx, pop, nil
+}
+
+// setTotal records how many nodes the population scans, for Status.
+func (m *VectorIndexManager) setTotal(pop *population, total int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pop.total = total
+}
+
+// backfill indexes a node read by the population scan, unless it was
+// written since.
+func (m *VectorIndexManager) backfill(pop *population, node *storage.Node) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pop.scanned++
+	if !pop.written[string(node.ID)] {
+		pop.index.put(node)
+	}
+}
+
+// finishPopulation brings the index online, or marks it failed if err is
+// set, and wakes the searches waiting for it.
+func (m *VectorIndexManager) finishPopulation(pop *population, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pop.cancel()
+	if err != nil {
+		pop.err = err
+	} else {
+		pop.index.population = nil
+	}
+	close(pop.done)
+}
+
+// Status returns the state of a vector index.
+func (m *VectorIndexManager) Status(name string) (VectorIndexStatus, bool) {
+	if m == nil {
+		return VectorIndexStatus{}, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	idx, exists := m.indexes[name]
+	if !exists {
+		return VectorIndexStatus{}, false
+	}
+	pop := idx.population
+	switch {
+	case pop == nil:
+		return VectorIndexStatus{State: IndexOnline, Progress: 100}, true
+	case pop.err != nil:
+		return VectorIndexStatus{State: IndexFailed, Error: pop.err.Error()}, true
+	}
+	var progress float64
+	if pop.total > 0 {
+		// Nodes created during the scan may push it past the total
+		progress = min(99.9, 100*float64(pop.scanned)/float64(pop.total))
+	}
+	return VectorIndexStatus{State: IndexPopulating, Progress: progress}, true
+}
+
+// Await waits until a vector index is online. It returns
+// ErrVectorIndexFailed if its population failed, ErrVectorIndexNotFound if
+// there is no such index, or the context's error.
+func (m *VectorIndexManager) Await(ctx context.Context, name string) error {
+	if m == nil {
+		return fmt.Errorf("%w: %s", ErrVectorIndexNotFound, name)
+	}
+	for {
+		m.mu.RLock()
+		idx, exists := m.indexes[name]
+		var pop *population
+		var failed error
+		if exists && idx.population != nil {
+			pop, failed = idx.population, idx.population.err
+		}
+		m.mu.RUnlock()
+		switch {
+		case !exists:
+			return fmt.Errorf("%w: %s", ErrVectorIndexNotFound, name)
+		case pop == nil:
+			return nil
+		case failed != nil:
+			return fmt.Errorf("%w: %s: %v", ErrVectorIndexFailed, name, failed)
+		}
+		select {
+		case <-pop.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Drop removes a vector index. Returns false if it did not exist.
+func (m *VectorIndexManager) Drop(name string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx, exists := m.indexes[name]
+	if !exists {
+		return false
+	}
+	if idx.population != nil {
+		idx.population.cancel()
+	}
+	delete(m.indexes, name)
+	return true
+}
+
+// Get returns the spec of a vector index.
+func (m *VectorIndexManager) Get(name string) (VectorIndexSpec, bool) {
+	if m == nil {
+		return VectorIndexSpec{}, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	idx, exists := m.indexes[name]
+	if !exists {
+		return VectorIndexSpec{}, false
+	}
+	return idx.spec, true
+}
+
+// List returns the specs of all vector indexes sorted by name.
+func (m *VectorIndexManager) List() []VectorIndexSpec {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	specs := make([]VectorIndexSpec, 0, len(m.indexes))
+	for _, idx := range m.indexes {
+		specs = append(specs, idx.spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+// Count returns the number of vectors in a vector index (0 if unknown).
+func (m *VectorIndexManager) Count(name string) int {
+	if m == nil {
+		return 0
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if idx, exists := m.indexes[name]; exists {
+		return len(idx.vectors)
+	}
+	return 0
+}
+
+// ForDimensions returns the name of the first index (by name) holding
+// vectors of the given dimensionality, for queries that name no index.
+func (m *VectorIndexManager) ForDimensions(dims int) (string, bool) {
+	for _, spec := range m.List() {
+		if spec.Dimensions == dims {
+			return spec.Name, true
+		}
+	}
+	return "", false
+}
+
+// IndexNode adds the node's vector to every index it belongs to and removes
+// it from those it no longer matches. Returns the number of indexes holding
+// the node afterwards.
+func (m *VectorIndexManager) IndexNode(node *storage.Node) int {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	indexed := 0
+	for _, idx := range m.indexes {
+		if idx.population != nil {
+			idx.population.written[string(node.ID)] = true
+		}
+		if idx.put(node) {
+			indexed++
+		}
+	}
+	return indexed
+}
+
+// put adds the node's vector to the index, or removes the node if it no
+// longer belongs, and reports whether the index holds it.
+func (idx *namedVectorIndex) put(node *storage.Node) bool {
+	id := string(node.ID)
+	vec := idx.nodeVector(node)
+	if vec == nil {
+		delete(idx.vectors, id)
+		return false
+	}
+	if idx.spec.Similarity == "cosine" {
+		vec = vector.Normalize(vec)
+	} else {
+		vec = append([]float32(nil), vec...)
+	}
+	idx.vectors[id] = vec
+	return true
+}
+
+// RemoveNode removes a node from all indexes.
+func (m *VectorIndexManager) RemoveNode(id storage.NodeID) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, idx := range m.indexes {
+		if idx.population != nil {
+			idx.population.written[string(id)] = true
+		}
+		delete(idx.vectors, string(id))
+	}
+}
+
+// nodeVector returns the node's vector for this index, or nil if the node
+// has the wrong label or no vector of the index's dimensions.
+func (idx *namedVectorIndex) nodeVector(node *storage.Node) []float32 {
+	if idx.spec.Label != "" {
+		found := false
+		for _, l := range node.Labels {
+			if l == idx.spec.Label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+
+	var vec []float32
+	if idx.spec.Property != "" {
+		if v, ok := node.Properties[idx.spec.Property]; ok {
+			vec = convert.ToFloat32Slice(v)
+		}
+	}
+	if len(vec) == 0 {
+		vec = node.Embedding
+	}
+	if len(vec) != idx.spec.Dimensions {
+		return nil
+	}
+	return vec
+}
+
+// Search returns the k most similar vectors in the named index, scored with
+// the index's similarity function.
+//
+// A populating index is searched once it is online (see Await).
+//
+// Returns ErrVectorIndexNotFound for unknown indexes and ErrDimensionMismatch
+// if the query's dimensions differ from the index's.
+func (m *VectorIndexManager) Search(ctx context.Context, name string, query []float32, k int, minSimilarity float64) ([]indexResult, error) {
+	if err := m.Await(ctx, name); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	idx, exists := m.indexes[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrVectorIndexNotFound, name)
+	}
+	if len(query) != idx.spec.Dimensions {
+		return nil, fmt.Errorf("%w: index %s has %d dimensions, query has %d",
+			ErrDimensionMismatch, name, idx.spec.Dimensions, len(query))
+	}
+
+	score := vector.DotProduct
+	switch idx.spec.Similarity {
+	case "cosine":
+		// Stored vectors are normalized, so the dot product is the cosine
+		query = vector.Normalize(query)
+	case "euclidean":
+		score = vector.EuclideanSimilarity
+	}
+
+	results := make([]indexResult, 0, len(idx.vectors))
+	for id, vec := range idx.vectors {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if sim := score(query, vec); sim >= minSimilarity {
+			results = append(results, indexResult{ID: id, Score: sim})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
@@ -0,0 +1,239 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVectorIndexManager_CreateAndValidate(t *testing.T) {
+	m := NewVectorIndexManager()
+
+	created, err := m.Create(VectorIndexSpec{Name: "docs", Label: "Doc", Dimensions: 4})
+	require.NoError(t, err)
+	assert.True(t, created)
+	spec, ok := m.Get("docs")
+	require.True(t, ok)
+	assert.Equal(t, "cosine", spec.Similarity, "similarity defaults to cosine")
+
+	created, err = m.Create(VectorIndexSpec{Name: "docs", Dimensions: 8})
+	require.NoError(t, err)
+	assert.False(t, created, "existing index is kept")
+	spec, _ = m.Get("docs")
+	assert.Equal(t, 4, spec.Dimensions)
+
+	_, err = m.Create(VectorIndexSpec{Name: "bad", Dimensions: 0})
+	assert.Error(t, err)
+	_, err = m.Create(VectorIndexSpec{Name: "bad", Dimensions: 4, Similarity: "manhattan"})
+	assert.Error(t, err)
+	_, err = m.Create(VectorIndexSpec{Dimensions: 4})
+	assert.Error(t, err)
+
+	assert.True(t, m.Drop("docs"))
+	assert.False(t, m.Drop("docs"))
+	assert.Empty(t, m.List())
+}
+
+func TestVectorIndexManager_Routing(t *testing.T) {
+	m := NewVectorIndexManager()
+	ctx := context.Background()
+	_, err := m.Create(VectorIndexSpec{Name: "text", Label: "Doc", Dimensions: 4})
+	require.NoError(t, err)
+	_, err = m.Create(VectorIndexSpec{Name: "image", Label: "Image", Property: "clip", Dimensions: 2, Similarity: "euclidean"})
+	require.NoError(t, err)
+
+	doc := &storage.Node{ID: "doc", Labels: []string{"Doc"}, Embedding: []float32{1, 0, 0, 0}}
+	img := &storage.Node{ID: "img", Labels: []string{"Image"}, Properties: map[string]any{"clip": []interface{}{0.0, 1.0}}}
+	wrongDims := &storage.Node{ID: "old", Labels: []string{"Doc"}, Embedding: []float32{1, 0}}
+	assert.Equal(t, 1, m.IndexNode(doc))
+	assert.Equal(t, 1, m.IndexNode(img))
+	assert.Equal(t, 0, m.IndexNode(wrongDims), "vectors of other dimensions are not indexed")
+	assert.Equal(t, 1, m.Count("text"))
+	assert.Equal(t, 1, m.Count("image"))
+
+	name, ok := m.ForDimensions(2)
+	assert.True(t, ok)
+	assert.Equal(t, "image", name)
+	_, ok = m.ForDimensions(3)
+	assert.False(t, ok)
+
+	results, err := m.Search(ctx, "image", []float32{0, 1}, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "img", results[0].ID)
+	assert.InDelta(t, 1.0, results[0].Score, 0.001, "identical vectors")
+
+	_, err = m.Search(ctx, "text", []float32{0, 1}, 10, 0)
+	assert.ErrorIs(t, err, ErrDimensionMismatch)
+	_, err = m.Search(ctx, "missing", []float32{0, 1}, 10, 0)
+	assert.ErrorIs(t, err, ErrVectorIndexNotFound)
+
+	// Relabelled node leaves the index; removed nodes leave all indexes
+	doc.Labels = []string{"Archived"}
+	m.IndexNode(doc)
+	assert.Equal(t, 0, m.Count("text"))
+	m.RemoveNode("img")
+	assert.Equal(t, 0, m.Count("image"))
+}
+
+func TestSearchService_MultipleVectorIndexes(t *testing.T) {
+	engine := storage.NewMemoryEngine()
+	defer engine.Close()
+	ctx := context.Background()
+
+	large := make([]float32, 1024)
+	large[0] = 1
+	require.NoError(t, engine.CreateNode(&storage.Node{
+		ID: "doc", Labels: []string{"Doc"}, Embedding: large,
+		Properties: map[string]any{"content": "default model document"},
+	}))
+	require.NoError(t, engine.CreateNode(&storage.Node{
+		ID: "img", Labels: []string{"Image"}, Embedding: []float32{0.9, 0.1, 0, 0},
+		Properties: map[string]any{"content": "small model image"},
+	}))
+	require.NoError(t, engine.GetSchema().AddVectorIndex("image_idx", "Image", "", 4, "cosine"))
+
+	svc := NewService(engine)
+	require.NoError(t, svc.BuildIndexes(ctx))
+	assert.Equal(t, 1, svc.EmbeddingCount())
+	assert.Equal(t, 1, svc.VectorIndexes().Count("image_idx"), "schema indexes are loaded on build")
+
+	// A 4-dimensional query routes to the 4-dimensional index
+	opts := DefaultSearchOptions()
+	opts.MinSimilarity = 0
+	resp, err := svc.Search(ctx, "", []float32{1, 0, 0, 0}, opts)
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.Results)
+	assert.Equal(t, "img", resp.Results[0].ID)
+
+	// Queries with dimensions no index has fail over to full-text
+	resp, err = svc.Search(ctx, "document", []float32{1, 0, 0}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "fulltext", resp.SearchMethod)
+
+	// Naming an index routes explicitly
+	opts.VectorIndex = "missing"
+	_, err = svc.vectorSearchOnly(ctx, []float32{1, 0, 0, 0}, opts)
+	assert.ErrorIs(t, err, ErrVectorIndexNotFound)
+
+	// Nodes of other dimensions are still full-text searchable
+	other := &storage.Node{ID: "other", Labels: []string{"Note"}, Embedding: []float32{1, 0, 0},
+		Properties: map[string]any{"content": "unrouted note"}}
+	require.NoError(t, engine.CreateNode(other))
+	assert.ErrorIs(t, svc.IndexNode(other), ErrDimensionMismatch)
+	resp, err = svc.Search(ctx, "unrouted", nil, DefaultSearchOptions())
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, "other", resp.Results[0].ID)
+}
+
+func TestSearchService_CreateAndQueryVectorIndex(t *testing.T) {
+	engine := storage.NewMemoryEngine()
+	defer engine.Close()
+	ctx := context.Background()
+
+	for id, vec := range map[storage.NodeID][]interface{}{"a": {1.0, 0.0}, "b": {0.0, 1.0}} {
+		require.NoError(t, engine.CreateNode(&storage.Node{
+			ID: id, Labels: []string{"Image"}, Properties: map[string]any{"clip": vec},
+		}))
+	}
+	svc := NewService(engine)
+
+	require.NoError(t, svc.CreateVectorIndex(ctx, VectorIndexSpec{Name: "clip_idx", Label: "Image", Property: "clip", Dimensions: 2}))
+	results, err := svc.QueryVectorIndex(ctx, "clip_idx", []float32{0, 1}, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "b", results[0].ID, "existing nodes are backfilled")
+
+	// Schema-only indexes are built on first query
+	require.NoError(t, engine.GetSchema().AddVectorIndex("clip_dot", "Image", "clip", 2, "dot"))
+	results, err = svc.QueryVectorIndex(ctx, "clip_dot", []float32{2, 0}, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "a", results[0].ID)
+	assert.InDelta(t, 2.0, results[0].Score, 0.001)
+
+	_, err = svc.QueryVectorIndex(ctx, "missing", []float32{0, 1}, 1)
+	assert.ErrorIs(t, err, ErrVectorIndexNotFound)
+
+	assert.True(t, svc.DropVectorIndex("clip_idx"))
+	require.NoError(t, svc.RemoveNode("a"))
+	assert.Equal(t, 1, svc.VectorIndexes().Count("clip_dot"))
+}
+
+// pausedEngine holds label scans until released, as a long population would.
+type pausedEngine struct {
+	storage.Engine
+	scanning chan struct{}
+	release  chan struct{}
+}
+
+func (e *pausedEngine) GetNodesByLabel(label string) ([]*storage.Node, error) {
+	nodes, err := e.Engine.GetNodesByLabel(label)
+	close(e.scanning)
+	<-e.release
+	return nodes, err
+}
+
+func TestSearchService_OnlineVectorIndexPopulation(t *testing.T) {
+	memory := storage.NewMemoryEngine()
+	defer memory.Close()
+	ctx := context.Background()
+	for id, vec := range map[storage.NodeID][]interface{}{"a": {1.0, 0.0}, "b": {0.0, 1.0}} {
+		require.NoError(t, memory.CreateNode(&storage.Node{
+			ID: id, Labels: []string{"Image"}, Properties: map[string]any{"clip": vec},
+		}))
+	}
+	engine := &pausedEngine{Engine: memory, scanning: make(chan struct{}), release: make(chan struct{})}
+	svc := NewService(engine)
+
+	require.NoError(t, svc.CreateVectorIndex(ctx, VectorIndexSpec{Name: "clip_idx", Label: "Image", Property: "clip", Dimensions: 2}))
+	<-engine.scanning
+	status, ok := svc.VectorIndexes().Status("clip_idx")
+	require.True(t, ok)
+	assert.Equal(t, IndexPopulating, status.State)
+
+	// Writes during the scan win over the copies it read
+	moved := &storage.Node{ID: "a", Labels: []string{"Image"}, Properties: map[string]any{"clip": []interface{}{0.0, 1.0}}}
+	require.NoError(t, memory.UpdateNode(moved))
+	_ = svc.IndexNode(moved)
+	require.NoError(t, svc.RemoveNode("b"))
+
+	// Not queryable until populated
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err := svc.QueryVectorIndex(short, "clip_idx", []float32{0, 1}, 10)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(engine.release)
+	require.NoError(t, svc.VectorIndexes().Await(ctx, "clip_idx"))
+	status, _ = svc.VectorIndexes().Status("clip_idx")
+	assert.Equal(t, VectorIndexStatus{State: IndexOnline, Progress: 100}, status)
+
+	results, err := svc.QueryVectorIndex(ctx, "clip_idx", []float32{0, 1}, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1, "the removed node is not restored")
+	assert.Equal(t, "a", results[0].ID)
+	assert.InDelta(t, 1.0, results[0].Score, 0.001, "the scan did not restore the old vector")
+}
+
+func TestSearchService_DropPopulatingVectorIndex(t *testing.T) {
+	memory := storage.NewMemoryEngine()
+	defer memory.Close()
+	ctx := context.Background()
+	engine := &pausedEngine{Engine: memory, scanning: make(chan struct{}), release: make(chan struct{})}
+	svc := NewService(engine)
+
+	require.NoError(t, svc.CreateVectorIndex(ctx, VectorIndexSpec{Name: "clip_idx", Label: "Image", Dimensions: 2}))
+	<-engine.scanning
+	waited := make(chan error, 1)
+	go func() { waited <- svc.VectorIndexes().Await(ctx, "clip_idx") }()
+
+	assert.True(t, svc.DropVectorIndex("clip_idx"))
+	close(engine.release)
+	assert.ErrorIs(t, <-waited, ErrVectorIndexNotFound)
+}
@@ -0,0 +1,194 @@
+// Package secrets resolves secret references used in NornicDB configuration.
+//
+// Passwords, JWT signing keys, TLS private keys and encryption keys should not
+// sit in plaintext config. Any such setting may instead hold a reference that
+// is resolved at startup by a secrets provider:
+//
+//	env:NAME                              - environment variable NAME
+//	file:/run/secrets/jwt_secret          - file contents (trailing newline trimmed)
+//	vault:secret/data/nornicdb#jwt_secret - HashiCorp Vault KV (v1 or v2) field
+//
+// Values without a known scheme are returned unchanged, so existing plaintext
+// configuration keeps working.
+//
+// Example:
+//
+//	// NORNICDB_AUTH_JWT_SECRET=vault:secret/data/nornicdb#jwt_secret
+//	secret, err := secrets.Resolve(ctx, os.Getenv("NORNICDB_AUTH_JWT_SECRET"))
+//	if err != nil {
+//		log.Fatalf("resolving JWT secret: %v", err)
+//	}
+//
+// The default resolver supports env: and file: references, plus vault: when
+// VAULT_ADDR and VAULT_TOKEN (optionally VAULT_NAMESPACE) are set.
+package secrets
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when a referenced secret does not exist.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider looks up secrets for one reference scheme.
+type Provider interface {
+	// Get returns the secret for ref, the part of the reference after "scheme:".
+	Get(ctx context.Context, ref string) (string, error)
+}
+
+// reservedSchemes are never treated as literal values, even when no provider
+// is registered, so a missing Vault configuration fails loudly instead of
+// using "vault:..." as a password.
+var reservedSchemes = []string{"env", "file", "vault"}
+
+// Resolver dispatches secret references to providers by scheme.
+type Resolver struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+// NewResolver returns a resolver with the env: and file: providers registered.
+func NewResolver() *Resolver {
+	r := &Resolver{providers: make(map[string]Provider)}
+	r.Register("env", EnvProvider{})
+	r.Register("file", FileProvider{})
+	return r
+}
+
+// Register adds or replaces the provider for scheme.
+func (r *Resolver) Register(scheme string, p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[scheme] = p
+}
+
+// Resolve returns the secret value for a reference, or value unchanged if it
+// is not a reference.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	scheme, ref, ok := r.split(value)
+	if !ok {
+		return value, nil
+	}
+	r.mu.RLock()
+	p := r.providers[scheme]
+	r.mu.RUnlock()
+	if p == nil {
+		return "", fmt.Errorf("secrets: no provider configured for %q references", scheme)
+	}
+	secret, err := p.Get(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("secrets: resolving %s reference: %w", scheme, err)
+	}
+	return secret, nil
+}
+
+// IsReference reports whether value is a secret reference rather than a literal.
+func (r *Resolver) IsReference(value string) bool {
+	_, _, ok := r.split(value)
+	return ok
+}
+
+func (r *Resolver) split(value string) (scheme, ref string, ok bool) {
+	scheme, ref, found := strings.Cut(value, ":")
+	if !found || ref == "" {
+		return "", "", false
+	}
+	r.mu.RLock()
+	_, registered := r.providers[scheme]
+	r.mu.RUnlock()
+	if registered {
+		return scheme, ref, true
+	}
+	for _, s := range reservedSchemes {
+		if s == scheme {
+			return scheme, ref, true
+		}
+	}
+	return "", "", false
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultResolver *Resolver
+)
+
+// Default returns the process-wide resolver configured from the environment.
+func Default() *Resolver {
+	defaultOnce.Do(func() {
+		defaultResolver = NewResolver()
+		if addr := os.Getenv("VAULT_ADDR"); addr != "" {
+			defaultResolver.Register("vault", NewVaultProvider(VaultConfig{
+				Address:   addr,
+				Token:     os.Getenv("VAULT_TOKEN"),
+				Namespace: os.Getenv("VAULT_NAMESPACE"),
+			}))
+		}
+	})
+	return defaultResolver
+}
+
+// Resolve resolves value with the default resolver.
+func Resolve(ctx context.Context, value string) (string, error) {
+	return Default().Resolve(ctx, value)
+}
+
+// EnvProvider resolves env:NAME references from environment variables.
+type EnvProvider struct{}
+
+// Get implements Provider.
+func (EnvProvider) Get(_ context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return "", fmt.Errorf("%w: environment variable %s", ErrNotFound, name)
+	}
+	return v, nil
+}
+
+// FileProvider resolves file:PATH references by reading the file.
+// Relative paths are resolved against BaseDir (default: working directory).
+// A single trailing newline is trimmed, matching Docker/Kubernetes secrets.
+type FileProvider struct {
+	BaseDir string
+}
+
+// Get implements Provider.
+func (p FileProvider) Get(_ context.Context, path string) (string, error) {
+	if !filepath.IsAbs(path) && p.BaseDir != "" {
+		path = filepath.Join(p.BaseDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: file %s", ErrNotFound, path)
+	}
+	if err != nil {
+		return "", err
+	}
+	s := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(s, "\r"), nil
+}
+
+// LoadX509KeyPair resolves PEM-encoded certificate and key references (or
+// literal PEM) and parses them into a TLS certificate.
+//
+// Example:
+//
+//	cert, err := secrets.LoadX509KeyPair(ctx,
+//		"file:/etc/nornicdb/tls.crt", "vault:secret/data/nornicdb#tls_key")
+func LoadX509KeyPair(ctx context.Context, certRef, keyRef string) (tls.Certificate, error) {
+	certPEM, err := Resolve(ctx, certRef)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("tls certificate: %w", err)
+	}
+	keyPEM, err := Resolve(ctx, keyRef)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("tls key: %w", err)
+	}
+	return tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+}
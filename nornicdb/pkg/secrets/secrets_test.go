@@ -0,0 +1,144 @@
+package secrets
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver_Literal(t *testing.T) {
+	r := NewResolver()
+	for _, v := range []string{"", "plain-password", "https://example.com", "a:b:c"} {
+		got, err := r.Resolve(context.Background(), v)
+		require.NoError(t, err)
+		assert.Equal(t, v, got)
+		assert.False(t, r.IsReference(v))
+	}
+}
+
+func TestResolver_Env(t *testing.T) {
+	t.Setenv("NORNICDB_TEST_SECRET", "from-env")
+	r := NewResolver()
+
+	got, err := r.Resolve(context.Background(), "env:NORNICDB_TEST_SECRET")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", got)
+
+	_, err = r.Resolve(context.Background(), "env:NORNICDB_TEST_MISSING")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestResolver_File(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jwt_secret")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+	r := NewResolver()
+
+	got, err := r.Resolve(context.Background(), "file:"+path)
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", got)
+
+	_, err = r.Resolve(context.Background(), "file:"+filepath.Join(dir, "missing"))
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestResolver_UnconfiguredVault(t *testing.T) {
+	r := NewResolver()
+	assert.True(t, r.IsReference("vault:secret/data/x#y"))
+	_, err := r.Resolve(context.Background(), "vault:secret/data/x#y")
+	assert.Error(t, err, "vault references must not fall back to literal values")
+}
+
+func TestVaultProvider(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Vault-Token") != "root-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/nornicdb": // KV v2
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+				"data":     map[string]any{"jwt_secret": "kv2-secret", "encryption_password": "pw"},
+				"metadata": map[string]any{"version": 3},
+			}})
+		case "/v1/kv/tls": // KV v1, single field
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"value": "kv1-value"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	r := NewResolver()
+	r.Register("vault", NewVaultProvider(VaultConfig{Address: srv.URL, Token: "root-token"}))
+	ctx := context.Background()
+
+	got, err := r.Resolve(ctx, "vault:secret/data/nornicdb#jwt_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "kv2-secret", got)
+
+	got, err = r.Resolve(ctx, "vault:secret/data/nornicdb#encryption_password")
+	require.NoError(t, err)
+	assert.Equal(t, "pw", got)
+	assert.Equal(t, 1, requests, "second field should be served from cache")
+
+	got, err = r.Resolve(ctx, "vault:kv/tls")
+	require.NoError(t, err)
+	assert.Equal(t, "kv1-value", got)
+
+	_, err = r.Resolve(ctx, "vault:secret/data/nornicdb")
+	assert.Error(t, err, "ambiguous field must be rejected")
+
+	_, err = r.Resolve(ctx, "vault:secret/data/missing#x")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	bad := NewResolver()
+	bad.Register("vault", NewVaultProvider(VaultConfig{Address: srv.URL, Token: "wrong"}))
+	_, err = bad.Resolve(ctx, "vault:secret/data/nornicdb#jwt_secret")
+	assert.ErrorContains(t, err, "permission denied")
+}
+
+func TestLoadX509KeyPair(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nornicdb-test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPath := filepath.Join(t.TempDir(), "tls.key")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	t.Setenv("NORNICDB_TEST_TLS_CERT", string(certPEM))
+
+	cert, err := LoadX509KeyPair(context.Background(), "env:NORNICDB_TEST_TLS_CERT", "file:"+keyPath)
+	require.NoError(t, err)
+	assert.Len(t, cert.Certificate, 1)
+
+	_, err = LoadX509KeyPair(context.Background(), string(certPEM), "file:"+filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultConfig configures the HashiCorp Vault provider.
+type VaultConfig struct {
+	Address    string        // Vault address, e.g. https://vault.example.com:8200
+	Token      string        // Vault token (X-Vault-Token)
+	Namespace  string        // Vault Enterprise namespace (optional)
+	CacheTTL   time.Duration // How long fetched secrets are cached (default 5m)
+	HTTPClient *http.Client  // HTTP client (default 10s timeout)
+}
+
+// VaultProvider resolves vault:PATH#FIELD references from Vault's KV engine.
+//
+// PATH is the API path below /v1/ (for KV v2 include "data/", e.g.
+// "secret/data/nornicdb"). FIELD selects a key in the secret; it may be
+// omitted when the secret has a single key or a "value" key.
+type VaultProvider struct {
+	config VaultConfig
+
+	mu    sync.Mutex
+	cache map[string]vaultCacheEntry
+}
+
+type vaultCacheEntry struct {
+	data    map[string]any
+	fetched time.Time
+}
+
+// NewVaultProvider returns a Vault provider.
+func NewVaultProvider(config VaultConfig) *VaultProvider {
+	if config.CacheTTL == 0 {
+		config.CacheTTL = 5 * time.Minute
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	return &VaultProvider{config: config, cache: make(map[string]vaultCacheEntry)}
+}
+
+// Get implements Provider.
+func (p *VaultProvider) Get(ctx context.Context, ref string) (string, error) {
+	path, field, _ := strings.Cut(ref, "#")
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "", fmt.Errorf("vault: empty path in reference %q", ref)
+	}
+
+	data, err := p.read(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	if field == "" {
+		if v, ok := data["value"]; ok {
+			return stringValue(v)
+		}
+		if len(data) == 1 {
+			for _, v := range data {
+				return stringValue(v)
+			}
+		}
+		return "", fmt.Errorf("vault: %s has %d fields, specify one with #field", path, len(data))
+	}
+	v, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("%w: vault field %s#%s", ErrNotFound, path, field)
+	}
+	return stringValue(v)
+}
+
+// read fetches the secret at path, using the cache when fresh.
+func (p *VaultProvider) read(ctx context.Context, path string) (map[string]any, error) {
+	p.mu.Lock()
+	if e, ok := p.cache[path]; ok && time.Since(e.fetched) < p.config.CacheTTL {
+		p.mu.Unlock()
+		return e.data, nil
+	}
+	p.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.Address+"/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.config.Token)
+	if p.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.Namespace)
+	}
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: vault path %s", ErrNotFound, path)
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("vault: permission denied reading %s", path)
+	default:
+		return nil, fmt.Errorf("vault: reading %s: status %d", path, resp.StatusCode)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("vault: decoding %s: %w", path, err)
+	}
+	data := body.Data
+	// KV v2 nests the secret under data.data alongside data.metadata
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, hasMeta := data["metadata"]; hasMeta {
+			data = inner
+		}
+	}
+
+	p.mu.Lock()
+	p.cache[path] = vaultCacheEntry{data: data, fetched: time.Now()}
+	p.mu.Unlock()
+	return data, nil
+}
+
+func stringValue(v any) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case nil:
+		return "", ErrNotFound
+	default:
+		b, err := json.Marshal(val)
+		return string(b), err
+	}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/nornicdb"
+)
+
+// APIVersion is the version of the HTTP API described by OpenAPISpec.
+// Bump the minor version when operations or optional fields are added and
+// the major version for changes that break existing clients.
+const APIVersion = "1.5.0"
+
+// apiOperation documents one HTTP endpoint. Request and Response are
+// values of the Go types the handler decodes and encodes; the schema is
+// generated from them, so the spec cannot drift from the handlers.
+type apiOperation struct {
+	Method     string
+	Path       string
+	ID         string
+	Tag        string
+	Summary    string
+	Permission auth.Permission // empty = public
+	Request    any             // nil = no body
+	Status     int
+	Response   any
+	ErrorBody  any  // body of error responses
+	Streams    bool // success body is also served as NDJSON or CSV (see negotiateResultFormat)
+}
+
+// apiOperations lists the endpoints covered by the published schema: the
+// Neo4j-compatible query API, vector search, authentication and admin.
+// Keep it in step with buildRouter; TestOpenAPIConformance calls each one.
+var apiOperations = []apiOperation{
+	{Method: http.MethodPost, Path: "/db/{database}/tx/commit", ID: "runQuery", Tag: "query",
+		Summary:    "Run statements in an implicit transaction that commits on return",
+		Permission: auth.PermRead, Request: TransactionRequest{}, Status: http.StatusOK, Response: TransactionResponse{}, ErrorBody: TransactionResponse{}, Streams: true},
+	{Method: http.MethodPost, Path: "/db/{database}/tx", ID: "openTransaction", Tag: "query",
+		Summary:    "Open an explicit transaction, optionally running statements",
+		Permission: auth.PermRead, Request: TransactionRequest{}, Status: http.StatusCreated, Response: TransactionResponse{}, ErrorBody: TransactionResponse{}, Streams: true},
+	{Method: http.MethodPost, Path: "/db/{database}/tx/{txId}", ID: "runInTransaction", Tag: "query",
+		Summary:    "Run statements in an open transaction",
+		Permission: auth.PermRead, Request: TransactionRequest{}, Status: http.StatusOK, Response: TransactionResponse{}, ErrorBody: TransactionResponse{}, Streams: true},
+	{Method: http.MethodPost, Path: "/db/{database}/tx/{txId}/commit", ID: "commitTransaction", Tag: "query",
+		Summary:    "Run final statements and commit an open transaction",
+		Permission: auth.PermRead, Request: TransactionRequest{}, Status: http.StatusOK, Response: TransactionResponse{}, ErrorBody: TransactionResponse{}, Streams: true},
+	{Method: http.MethodDelete, Path: "/db/{database}/tx/{txId}", ID: "rollbackTransaction", Tag: "query",
+		Summary:    "Roll back an open transaction",
+		Permission: auth.PermRead, Status: http.StatusOK, Response: TransactionResponse{}, ErrorBody: TransactionResponse{}},
+	{Method: http.MethodGet, Path: "/db/{database}/cursor/{cursor}", ID: "nextPage", Tag: "query",
+		Summary:    "Fetch the next page of a result run with a pageSize",
+		Permission: auth.PermRead, Status: http.StatusOK, Response: TransactionResponse{}, ErrorBody: TransactionResponse{}, Streams: true},
+	{Method: http.MethodDelete, Path: "/db/{database}/cursor/{cursor}", ID: "closeCursor", Tag: "query",
+		Summary:    "Close a cursor before its last page",
+		Permission: auth.PermRead, Status: http.StatusOK, Response: TransactionResponse{}, ErrorBody: TransactionResponse{}},
+	{Method: http.MethodPost, Path: "/nornicdb/search", ID: "search", Tag: "search",
+		Summary:    "Hybrid vector and BM25 search, or BM25 only when no embedder is configured",
+		Permission: auth.PermRead, Request: SearchRequest{}, Status: http.StatusOK, Response: []*nornicdb.SearchResult{}, ErrorBody: ErrorResponse{}},
+	{Method: http.MethodPost, Path: "/nornicdb/similar", ID: "findSimilar", Tag: "search",
+		Summary:    "Find the nodes whose embeddings are nearest to a node's",
+		Permission: auth.PermRead, Request: SimilarRequest{}, Status: http.StatusOK, Response: []*nornicdb.SearchResult{}, ErrorBody: ErrorResponse{}},
+	{Method: http.MethodPost, Path: "/auth/token", ID: "getToken", Tag: "auth",
+		Summary: "Exchange a username and password for a bearer token",
+		Request: TokenRequest{}, Status: http.StatusOK, Response: auth.TokenResponse{}, ErrorBody: ErrorResponse{}},
+	{Method: http.MethodGet, Path: "/health", ID: "health", Tag: "admin",
+		Summary: "Liveness check",
+		Status:  http.StatusOK, Response: HealthResponse{}},
+	{Method: http.MethodGet, Path: "/status", ID: "status", Tag: "admin",
+		Summary:    "Server, database and embedding worker status",
+		Permission: auth.PermRead, Status: http.StatusOK, Response: StatusResponse{}},
+	{Method: http.MethodGet, Path: "/admin/stats", ID: "adminStats", Tag: "admin",
+		Summary:    "Request counters, graph size and memory use",
+		Permission: auth.PermAdmin, Status: http.StatusOK, Response: AdminStatsResponse{}},
+	{Method: http.MethodGet, Path: "/admin/config", ID: "adminConfig", Tag: "admin",
+		Summary:    "Non-secret server configuration",
+		Permission: auth.PermAdmin, Status: http.StatusOK, Response: AdminConfigResponse{}},
+}
+
+// OpenAPISpec returns the OpenAPI 3.0 document describing the HTTP API,
+// for generating client SDKs. It is served at /openapi.json and committed
+// as api/openapi.json.
+func OpenAPISpec() map[string]any {
+	schemas := make(map[string]any)
+	paths := make(map[string]any)
+
+	for _, op := range apiOperations {
+		operation := map[string]any{
+			"operationId": op.ID,
+			"tags":        []any{op.Tag},
+			"summary":     op.Summary,
+		}
+		success := jsonContent("Success", schemaOf(reflect.TypeOf(op.Response), schemas))
+		if op.Streams {
+			content := success["content"].(map[string]any)
+			content["application/x-ndjson"] = map[string]any{"schema": map[string]any{
+				"type":        "string",
+				"description": "One JSON event per line: header, data (per row), summary, error and info",
+			}}
+			content["text/csv"] = map[string]any{"schema": map[string]any{
+				"type":        "string",
+				"description": "Header row and one row per record; results separated by a blank line",
+			}}
+		}
+		responses := map[string]any{
+			strconv.Itoa(op.Status): success,
+		}
+		if op.ErrorBody != nil {
+			responses["default"] = jsonContent("Error", schemaOf(reflect.TypeOf(op.ErrorBody), schemas))
+		}
+		operation["responses"] = responses
+		if op.Request != nil {
+			body := jsonContent("", schemaOf(reflect.TypeOf(op.Request), schemas))
+			delete(body, "description")
+			body["required"] = true
+			operation["requestBody"] = body
+		}
+		if op.Permission != "" {
+			operation["security"] = []any{
+				map[string]any{"bearerAuth": []any{}},
+				map[string]any{"basicAuth": []any{}},
+			}
+			operation["x-nornicdb-permission"] = string(op.Permission)
+		}
+		var params []any
+		for _, name := range pathParams(op.Path) {
+			params = append(params, map[string]any{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+		if params != nil {
+			operation["parameters"] = params
+		}
+
+		item, _ := paths[op.Path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "NornicDB HTTP API",
+			"version":     APIVersion,
+			"description": "Neo4j-compatible transactional Cypher endpoints plus NornicDB vector search, authentication and admin endpoints.",
+		},
+		"servers": []any{map[string]any{"url": "http://localhost:7474"}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"basicAuth":  map[string]any{"type": "http", "scheme": "basic"},
+			},
+		},
+	}
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, OpenAPISpec())
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf returns the JSON schema of values of t as encoding/json writes
+// them. Named structs are added to schemas and referenced.
+func schemaOf(t reflect.Type, schemas map[string]any) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		elem := schemaOf(t.Elem(), schemas)
+		if _, ok := elem["$ref"]; ok {
+			return map[string]any{"allOf": []any{elem}, "nullable": true}
+		}
+		elem["nullable"] = true
+		return elem
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		// encoding/json writes nil slices as null
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), schemas), "nullable": true}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas), "nullable": true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = nil // placeholder for recursive types
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default: // interface{}: any JSON value
+		return map[string]any{}
+	}
+}
+
+// structSchema returns the object schema of a struct's JSON fields.
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	properties := make(map[string]any)
+	var required []any
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = schemaOf(f.Type, schemas)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if required != nil {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// jsonContent returns an OpenAPI response or request body of JSON.
+func jsonContent(description string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schema},
+		},
+	}
+}
+
+// pathParams returns the {name} parameters of an OpenAPI path template.
+func pathParams(path string) []string {
+	var names []string
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			names = append(names, part[1:len(part)-1])
+		}
+	}
+	return names
+}
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/cursor"
+)
+
+var updateOpenAPI = flag.Bool("update-openapi", false, "rewrite api/openapi.json from the Go types")
+
+const openAPIFile = "../../api/openapi.json"
+
+// TestOpenAPISchemaUpToDate fails when the committed schema differs from
+// the one generated from the handler types. Regenerate with `make openapi`.
+func TestOpenAPISchemaUpToDate(t *testing.T) {
+	generated, err := json.MarshalIndent(OpenAPISpec(), "", "  ")
+	if err != nil {
+		t.Fatalf("failed to encode spec: %v", err)
+	}
+	generated = append(generated, '\n')
+
+	if *updateOpenAPI {
+		if err := os.WriteFile(openAPIFile, generated, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", openAPIFile, err)
+		}
+		return
+	}
+
+	committed, err := os.ReadFile(openAPIFile)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", openAPIFile, err)
+	}
+	if !bytes.Equal(committed, generated) {
+		t.Errorf("%s is out of date with the API types; run `make openapi` and review the diff (bump APIVersion if clients are affected)", openAPIFile)
+	}
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	resp := makeRequest(t, server, "GET", "/openapi.json", nil, "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.Code)
+	}
+	var spec map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		t.Fatalf("failed to decode spec: %v", err)
+	}
+	if version := spec["info"].(map[string]interface{})["version"]; version != APIVersion {
+		t.Errorf("expected version %s, got %v", APIVersion, version)
+	}
+}
+
+// TestOpenAPIConformance calls every documented operation and checks the
+// status and response body against the published schema.
+func TestOpenAPIConformance(t *testing.T) {
+	server, auth := setupTestServer(t)
+	token := "Bearer " + getAuthToken(t, auth, "admin")
+
+	var spec map[string]interface{}
+	data, _ := json.Marshal(OpenAPISpec())
+	json.Unmarshal(data, &spec)
+
+	statements := func(qs ...string) map[string]interface{} {
+		var list []map[string]interface{}
+		for _, q := range qs {
+			list = append(list, map[string]interface{}{"statement": q, "includeStats": true, "resultDataContents": []string{"row", "graph"}})
+		}
+		return map[string]interface{}{"statements": list}
+	}
+
+	// Open transactions to continue, commit and roll back
+	var txIDs []string
+	for i := 0; i < 2; i++ {
+		resp := makeRequest(t, server, "POST", "/db/neo4j/tx", statements(), token)
+		var body TransactionResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		parts := strings.Split(body.Commit, "/")
+		if len(parts) < 2 {
+			t.Fatalf("open transaction returned no commit URL: %+v", body)
+		}
+		txIDs = append(txIDs, parts[len(parts)-2])
+	}
+
+	// Open paged results to continue and close
+	server.config.Cursors = cursor.New(time.Minute, 0)
+	var cursors []string
+	for i := 0; i < 2; i++ {
+		resp := makeRequest(t, server, "POST", "/db/neo4j/tx/commit", map[string]interface{}{
+			"statements": []map[string]interface{}{{"statement": "UNWIND range(1, 5) AS i RETURN i", "pageSize": 2}},
+		}, token)
+		var body TransactionResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		if len(body.Results) != 1 || body.Results[0].Cursor == "" {
+			t.Fatalf("paged statement returned no cursor: %+v", body)
+		}
+		cursors = append(cursors, body.Results[0].Cursor)
+	}
+
+	calls := []struct {
+		method, template, path string
+		body                   interface{}
+		auth                   string
+	}{
+		{"POST", "/db/{database}/tx/commit", "/db/neo4j/tx/commit",
+			statements("CREATE (n:Doc {title: 'openapi', tags: ['a']}) RETURN n", "MATCH (n:Doc) RETURN n.title, count(n)"), token},
+		{"POST", "/db/{database}/tx", "/db/neo4j/tx", statements("RETURN 1 AS one"), token},
+		{"POST", "/db/{database}/tx/{txId}", "/db/neo4j/tx/" + txIDs[0], statements("RETURN 2 AS two"), token},
+		{"POST", "/db/{database}/tx/{txId}/commit", "/db/neo4j/tx/" + txIDs[0] + "/commit", statements(), token},
+		{"DELETE", "/db/{database}/tx/{txId}", "/db/neo4j/tx/" + txIDs[1], nil, token},
+		{"GET", "/db/{database}/cursor/{cursor}", "/db/neo4j/cursor/" + cursors[0], nil, token},
+		{"DELETE", "/db/{database}/cursor/{cursor}", "/db/neo4j/cursor/" + cursors[1], nil, token},
+		{"POST", "/nornicdb/search", "/nornicdb/search", SearchRequest{Query: "openapi", Limit: 5}, token},
+		{"POST", "/nornicdb/similar", "/nornicdb/similar", SimilarRequest{NodeID: "missing"}, token},
+		{"POST", "/auth/token", "/auth/token", TokenRequest{Username: "admin", Password: "password123"}, ""},
+		{"GET", "/health", "/health", nil, ""},
+		{"GET", "/status", "/status", nil, token},
+		{"GET", "/admin/stats", "/admin/stats", nil, token},
+		{"GET", "/admin/config", "/admin/config", nil, token},
+	}
+
+	covered := make(map[string]bool)
+	for _, c := range calls {
+		name := c.method + " " + c.template
+		covered[name] = true
+		t.Run(name, func(t *testing.T) {
+			op, ok := lookup(spec, "paths", c.template, strings.ToLower(c.method)).(map[string]interface{})
+			if !ok {
+				t.Fatalf("operation not in spec")
+			}
+			resp := makeRequest(t, server, c.method, c.path, c.body, c.auth)
+
+			// Successes must use the documented status; errors the default response
+			key := strconv.Itoa(resp.Code)
+			if resp.Code >= 400 {
+				key = "default"
+			}
+			response := lookup(op, "responses", key)
+			if response == nil {
+				t.Fatalf("undocumented status %d: %s", resp.Code, resp.Body.String())
+			}
+			schema := lookup(response, "content", "application/json", "schema")
+
+			var body interface{}
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("response is not JSON: %v", err)
+			}
+			if err := validateSchema(spec, schema, body, "$"); err != nil {
+				t.Errorf("response does not match schema: %v\n%s", err, resp.Body.String())
+			}
+
+			// Unauthenticated calls to protected operations are rejected
+			if op["security"] != nil {
+				if resp := makeRequest(t, server, c.method, c.path, c.body, ""); resp.Code != http.StatusUnauthorized {
+					t.Errorf("expected 401 without credentials, got %d", resp.Code)
+				}
+			}
+		})
+	}
+
+	for _, op := range apiOperations {
+		if !covered[op.Method+" "+op.Path] {
+			t.Errorf("%s %s is documented but not exercised by this test", op.Method, op.Path)
+		}
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	var spec map[string]interface{}
+	data, _ := json.Marshal(OpenAPISpec())
+	json.Unmarshal(data, &spec)
+	ref := map[string]interface{}{"$ref": "#/components/schemas/HealthResponse"}
+
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{`{"status": "healthy"}`, true},
+		{`{}`, false},                           // missing required field
+		{`{"status": 1}`, false},                // wrong type
+		{`{"status": "ok", "extra": 1}`, false}, // undocumented field
+	}
+	for _, tt := range tests {
+		var v interface{}
+		json.Unmarshal([]byte(tt.value), &v)
+		if err := validateSchema(spec, ref, v, "$"); (err == nil) != tt.ok {
+			t.Errorf("validate %s: got error %v, want ok=%v", tt.value, err, tt.ok)
+		}
+	}
+}
+
+// lookup walks nested JSON objects by key, returning nil if a key is missing.
+func lookup(v interface{}, keys ...string) interface{} {
+	for _, k := range keys {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+// validateSchema checks a decoded JSON value against the subset of OpenAPI
+// schema the generator emits.
+func validateSchema(spec map[string]interface{}, schema, v interface{}, path string) error {
+	s, _ := schema.(map[string]interface{})
+	if ref, ok := s["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		return validateSchema(spec, lookup(spec, "components", "schemas", name), v, path)
+	}
+	if v == nil {
+		if len(s) == 0 || s["nullable"] == true {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", path)
+	}
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if err := validateSchema(spec, sub, v, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch s["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, v)
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		required, _ := s["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		for k, fv := range obj {
+			fs, ok := props[k]
+			if !ok {
+				if s["additionalProperties"] == false {
+					return fmt.Errorf("%s: undocumented field %q", path, k)
+				}
+				fs = s["additionalProperties"]
+			}
+			if err := validateSchema(spec, fs, fv, path+"."+k); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, v)
+		}
+		for i, item := range arr {
+			if err := validateSchema(spec, s["items"], item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, v)
+		}
+	case "integer":
+		if f, ok := v.(float64); !ok || f != math.Trunc(f) {
+			return fmt.Errorf("%s: expected integer, got %v", path, v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: expected number, got %T", path, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/orneryd/nornicdb/pkg/pool"
+)
+
+// Result serialization formats for the transaction endpoints, chosen by the
+// Accept header:
+//
+//   - application/json (default): the Neo4j TransactionResponse document
+//   - application/x-ndjson, application/jsonl: one JSON event per line,
+//     like Neo4j's Jolt event stream: {"header":{"fields":[...]}}, then
+//     {"data":[...]} per row and {"summary":{...}} per result, an
+//     {"error":{...}} line if a statement failed and a final {"info":{...}}
+//   - text/csv: a header row and one row per record; multiple results are
+//     separated by a blank line. Add header=absent to leave out the header.
+//
+// NDJSON and CSV are written row by row through pooled string builders and
+// flushed as they go, so the encoded result is never held in memory.
+// CSV cannot carry errors or cursors: a response with errors, or with a
+// result paged by pageSize, is sent as JSON.
+type resultEncoding int
+
+const (
+	encodingJSON resultEncoding = iota
+	encodingNDJSON
+	encodingCSV
+)
+
+// resultFormat is the negotiated encoding and its response Content-Type.
+type resultFormat struct {
+	encoding    resultEncoding
+	contentType string
+	csvHeader   bool
+}
+
+// resultFlushRows is how many streamed rows are written between flushes.
+const resultFlushRows = 1000
+
+var jsonResultFormat = resultFormat{encoding: encodingJSON, contentType: "application/json"}
+
+// negotiateResultFormat picks the result format for an Accept header. It
+// returns false when the header accepts none of the supported formats.
+func negotiateResultFormat(accept string) (resultFormat, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return jsonResultFormat, true
+	}
+
+	type mediaRange struct {
+		mediaType string
+		params    map[string]string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mr := mediaRange{
+			mediaType: strings.ToLower(strings.TrimSpace(fields[0])),
+			params:    map[string]string{},
+			q:         1,
+		}
+		for _, param := range fields[1:] {
+			key, value, _ := strings.Cut(param, "=")
+			key = strings.ToLower(strings.TrimSpace(key))
+			value = strings.ToLower(strings.Trim(strings.TrimSpace(value), `"`))
+			if key == "q" {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					mr.q = q
+				}
+				continue
+			}
+			mr.params[key] = value
+		}
+		if mr.mediaType != "" && mr.q > 0 {
+			ranges = append(ranges, mr)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, mr := range ranges {
+		switch mr.mediaType {
+		case "application/json", "application/*", "*/*":
+			return jsonResultFormat, true
+		case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+			return resultFormat{encoding: encodingNDJSON, contentType: mr.mediaType}, true
+		case "text/csv", "text/*":
+			f := resultFormat{encoding: encodingCSV, contentType: "text/csv; charset=utf-8", csvHeader: mr.params["header"] != "absent"}
+			if !f.csvHeader {
+				f.contentType += "; header=absent"
+			}
+			return f, true
+		}
+	}
+	return resultFormat{}, false
+}
+
+// requestResultFormat negotiates the result format of r. If nothing
+// acceptable can be produced it writes a 406 error and returns false.
+func (s *Server) requestResultFormat(w http.ResponseWriter, r *http.Request) (resultFormat, bool) {
+	format, ok := negotiateResultFormat(r.Header.Get("Accept"))
+	if !ok {
+		s.writeNeo4jError(w, http.StatusNotAcceptable, "Neo.ClientError.Request.Invalid",
+			"unsupported Accept header; use application/json, application/x-ndjson or text/csv")
+	}
+	return format, ok
+}
+
+// writeTransactionResponse writes response in the negotiated format.
+func (s *Server) writeTransactionResponse(w http.ResponseWriter, status int, response *TransactionResponse, format resultFormat) {
+	switch {
+	case format.encoding == encodingNDJSON:
+		w.Header().Set("Content-Type", format.contentType)
+		w.WriteHeader(status)
+		newResultStream(w).writeNDJSON(response)
+	case format.encoding == encodingCSV && len(response.Errors) == 0 && !hasCursor(response):
+		w.Header().Set("Content-Type", format.contentType)
+		w.WriteHeader(status)
+		newResultStream(w).writeCSV(response, format.csvHeader)
+	default:
+		s.writeJSON(w, status, response)
+	}
+}
+
+// hasCursor reports whether a result of response continues in a cursor.
+func hasCursor(response *TransactionResponse) bool {
+	for _, result := range response.Results {
+		if result.Cursor != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// NDJSON events, one per line.
+type (
+	ndjsonHeader struct {
+		Header struct {
+			Fields []string `json:"fields"`
+		} `json:"header"`
+	}
+	ndjsonData struct {
+		Data []interface{} `json:"data"`
+	}
+	ndjsonSummary struct {
+		Summary struct {
+			Stats  *QueryStats `json:"stats,omitempty"`
+			Cursor string      `json:"cursor,omitempty"`
+		} `json:"summary"`
+	}
+	ndjsonError struct {
+		Error struct {
+			Errors []QueryError `json:"errors"`
+		} `json:"error"`
+	}
+	ndjsonInfo struct {
+		Info struct {
+			Commit        string               `json:"commit,omitempty"`
+			Transaction   *TransactionInfo     `json:"transaction,omitempty"`
+			LastBookmarks []string             `json:"lastBookmarks,omitempty"`
+			Notifications []ServerNotification `json:"notifications,omitempty"`
+		} `json:"info"`
+	}
+)
+
+// resultStream writes a result line by line. Each line is built in a pooled
+// string builder, written, and the builder reused for the next line.
+type resultStream struct {
+	w       http.ResponseWriter
+	line    *pool.PooledStringBuilder
+	scratch *pool.PooledStringBuilder
+	enc     *json.Encoder // Encodes into scratch
+	rows    int
+	err     error
+}
+
+func newResultStream(w http.ResponseWriter) *resultStream {
+	st := &resultStream{w: w, line: pool.GetStringBuilder(), scratch: pool.GetStringBuilder()}
+	st.enc = json.NewEncoder(st.scratch)
+	st.enc.SetEscapeHTML(false)
+	return st
+}
+
+// close flushes the response and returns the builders to the pool.
+func (st *resultStream) close() {
+	st.flush()
+	pool.PutStringBuilder(st.line)
+	pool.PutStringBuilder(st.scratch)
+}
+
+func (st *resultStream) flush() {
+	if f, ok := st.w.(http.Flusher); ok && st.err == nil {
+		f.Flush()
+	}
+}
+
+// emit writes the current line. After a write error (the client went away)
+// the rest of the stream is discarded.
+func (st *resultStream) emit() {
+	if st.err == nil {
+		_, st.err = st.w.Write(st.line.Bytes())
+	}
+	st.line.Reset()
+}
+
+// row emits a record line, flushing every resultFlushRows rows.
+func (st *resultStream) row() {
+	st.emit()
+	st.rows++
+	if st.rows%resultFlushRows == 0 {
+		st.flush()
+	}
+}
+
+// encodeJSON writes v as JSON to the line, without a trailing newline.
+func (st *resultStream) encodeJSON(v interface{}) {
+	st.scratch.Reset()
+	if err := st.enc.Encode(v); err != nil {
+		st.scratch.Reset()
+		st.enc.Encode(err.Error())
+	}
+	b := st.scratch.Bytes()
+	st.line.Write(b[:len(b)-1])
+}
+
+func (st *resultStream) writeNDJSON(response *TransactionResponse) {
+	defer st.close()
+	event := func(e interface{}) {
+		st.encodeJSON(e)
+		st.line.WriteByte('\n')
+	}
+
+	for _, result := range response.Results {
+		var header ndjsonHeader
+		header.Header.Fields = result.Columns
+		event(header)
+		st.emit()
+		for _, record := range result.Data {
+			event(ndjsonData{Data: record.Row})
+			st.row()
+		}
+		var summary ndjsonSummary
+		summary.Summary.Stats = result.Stats
+		summary.Summary.Cursor = result.Cursor
+		event(summary)
+		st.emit()
+	}
+	if len(response.Errors) > 0 {
+		var e ndjsonError
+		e.Error.Errors = response.Errors
+		event(e)
+		st.emit()
+	}
+	var info ndjsonInfo
+	info.Info.Commit = response.Commit
+	info.Info.Transaction = response.Transaction
+	info.Info.LastBookmarks = response.LastBookmarks
+	info.Info.Notifications = response.Notifications
+	event(info)
+	st.emit()
+}
+
+func (st *resultStream) writeCSV(response *TransactionResponse, header bool) {
+	defer st.close()
+	for i, result := range response.Results {
+		if i > 0 {
+			st.line.WriteString("\r\n")
+			st.emit()
+		}
+		if header {
+			for j, column := range result.Columns {
+				if j > 0 {
+					st.line.WriteByte(',')
+				}
+				writeCSVField(st.line, column)
+			}
+			st.line.WriteString("\r\n")
+			st.emit()
+		}
+		for _, record := range result.Data {
+			for j, value := range record.Row {
+				if j > 0 {
+					st.line.WriteByte(',')
+				}
+				st.writeCSVValue(value)
+			}
+			st.line.WriteString("\r\n")
+			st.row()
+		}
+	}
+}
+
+// writeCSVValue writes one cell. Strings are written as text, null as an
+// empty cell, and anything else (numbers, booleans, nodes, lists) as JSON.
+func (st *resultStream) writeCSVValue(value interface{}) {
+	switch v := value.(type) {
+	case nil:
+	case string:
+		// Keep spreadsheets from evaluating text as a formula
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			v = "'" + v
+		}
+		writeCSVField(st.line, v)
+	default:
+		st.scratch.Reset()
+		if err := st.enc.Encode(v); err != nil {
+			writeCSVField(st.line, err.Error())
+			return
+		}
+		b := st.scratch.Bytes()
+		writeCSVField(st.line, string(b[:len(b)-1]))
+	}
+}
+
+// writeCSVField writes field to sb, quoted per RFC 4180 when it contains a
+// comma, quote or line break.
+func writeCSVField(sb *pool.PooledStringBuilder, field string) {
+	if !strings.ContainsAny(field, ",\"\r\n") {
+		sb.WriteString(field)
+		return
+	}
+	sb.WriteByte('"')
+	for i := 0; i < len(field); i++ {
+		if field[i] == '"' {
+			sb.WriteByte('"')
+		}
+		sb.WriteByte(field[i])
+	}
+	sb.WriteByte('"')
+}
@@ -126,15 +126,21 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -142,15 +148,37 @@ import (
 
 	"github.com/orneryd/nornicdb/pkg/audit"
 	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/bookmark"
+	"github.com/orneryd/nornicdb/pkg/cdc"
 	nornicConfig "github.com/orneryd/nornicdb/pkg/config"
+	"github.com/orneryd/nornicdb/pkg/cypher"
+	"github.com/orneryd/nornicdb/pkg/diskspace"
+	"github.com/orneryd/nornicdb/pkg/edgesync"
 	"github.com/orneryd/nornicdb/pkg/embed"
 	"github.com/orneryd/nornicdb/pkg/gpu"
 	"github.com/orneryd/nornicdb/pkg/gpu/metal"
 	"github.com/orneryd/nornicdb/pkg/heimdall"
+	"github.com/orneryd/nornicdb/pkg/heimdall/grammar"
+	"github.com/orneryd/nornicdb/pkg/heimdall/guardrails"
+	"github.com/orneryd/nornicdb/pkg/heimdall/prompts"
+	"github.com/orneryd/nornicdb/pkg/heimdall/webhook"
+	"github.com/orneryd/nornicdb/pkg/cursor"
+	"github.com/orneryd/nornicdb/pkg/idempotency"
+	"github.com/orneryd/nornicdb/pkg/inference"
+	"github.com/orneryd/nornicdb/pkg/livequery"
 	"github.com/orneryd/nornicdb/pkg/mcp"
+	"github.com/orneryd/nornicdb/pkg/neo4jerr"
 	"github.com/orneryd/nornicdb/pkg/nornicdb"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/requestid"
+	"github.com/orneryd/nornicdb/pkg/secrets"
 	"github.com/orneryd/nornicdb/pkg/security"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/orneryd/nornicdb/pkg/writethrottle"
+	healthplugin "github.com/orneryd/nornicdb/plugins/health"
 	heimdallplugin "github.com/orneryd/nornicdb/plugins/heimdall"
+	reportsplugin "github.com/orneryd/nornicdb/plugins/reports"
 )
 
 // Errors for HTTP operations.
@@ -227,10 +255,40 @@ type Config struct {
 	RateLimitPerHour int
 	// RateLimitBurst max burst size for short request spikes (default: 20)
 	RateLimitBurst int
+	// QueryLimiter enforces per-user/role/IP query limits (queries/sec,
+	// concurrent queries, result bytes/sec) on Cypher endpoints (nil = unlimited).
+	// Share one limiter with the Bolt server so limits apply across protocols.
+	QueryLimiter *ratelimit.Limiter
+	// Idempotency records write requests sent with an Idempotency-Key header
+	// so retries are applied once (nil = the header is ignored). Share one
+	// store with the Bolt server.
+	Idempotency *idempotency.Store
+	// Cursors holds the rest of results requested with a pageSize
+	// (nil = pageSize is ignored and results are returned whole). Share
+	// one store with the Arrow Flight server.
+	Cursors *cursor.Store
+	// Sync serves an offline sync replica to edge instances at
+	// /nornicdb/sync/ (nil = not served).
+	Sync *edgesync.Replica
+	// WriteThrottle delays or rejects Cypher writes while the server is
+	// under strain (nil = writes are never throttled). Share one throttle
+	// with the Bolt server.
+	WriteThrottle *writethrottle.Throttle
+	// Bookmarks numbers commits for the lastBookmarks of transaction
+	// responses and makes requests carrying bookmarks wait for the state
+	// they name (nil = the server keeps its own, with a 30s wait timeout).
+	// Share one manager with the Bolt server.
+	Bookmarks *bookmark.Manager
+	// LiveQuery configures the live queries served over Bifrost when
+	// Heimdall is enabled (zero fields take the livequery defaults).
+	LiveQuery livequery.Config
 	// TLSCertFile for HTTPS
 	TLSCertFile string
 	// TLSKeyFile for HTTPS
 	TLSKeyFile string
+	// TLSCertificate for HTTPS, used instead of TLSCertFile/TLSKeyFile when set
+	// (e.g. a key pair loaded from a secrets provider with secrets.LoadX509KeyPair)
+	TLSCertificate *tls.Certificate
 
 	// MCP Configuration (Model Context Protocol)
 	// MCPEnabled controls whether the MCP server is started (default: true)
@@ -252,6 +310,10 @@ type Config struct {
 	// EmbeddingCacheSize is max embeddings to cache (0 = disabled, default: 10000)
 	// Each cached embedding uses ~4KB (1024 dims × 4 bytes)
 	EmbeddingCacheSize int
+	// EmbeddingCacheTTL expires cached embeddings (0 = never)
+	EmbeddingCacheTTL time.Duration
+	// EmbeddingCacheDir also keeps cached embeddings on disk ("" = memory only)
+	EmbeddingCacheDir string
 
 	// Slow Query Logging Configuration
 	// SlowQueryEnabled turns on slow query logging (default: true)
@@ -262,6 +324,9 @@ type Config struct {
 	// SlowQueryLogFile is optional file path for slow query log
 	// If empty, logs to stderr with other server logs
 	SlowQueryLogFile string
+	// Redactor masks PII in query text and parameters before they are
+	// written to the slow query log (nil = no redaction)
+	Redactor *redact.Redactor
 
 	// Headless Mode Configuration
 	// Headless disables the web UI and browser-related endpoints
@@ -407,15 +472,28 @@ type Server struct {
 	// MCP server for LLM tool interface
 	mcpServer *mcp.Server
 
+	// Embedding cache in front of the embedder (nil when disabled)
+	embeddingCache *embed.CachedEmbedder
+
 	// Heimdall - AI assistant for database management
 	heimdallHandler *heimdall.Handler
 
+	// Outbound webhooks for Heimdall alerts (nil when not configured)
+	heimdallWebhooks *webhook.Sink
+
 	httpServer *http.Server
 	listener   net.Listener
 
 	// Rate limiter for DoS protection
 	rateLimiter *IPRateLimiter
 
+	// Commit sequence for bookmarks (Config.Bookmarks or the server's own)
+	bookmarks *bookmark.Manager
+
+	// Live queries delivered over Bifrost (nil without Heimdall)
+	liveQueries     *livequery.Manager
+	stopLiveQueries context.CancelFunc
+
 	mu      sync.RWMutex
 	closed  atomic.Bool
 	started time.Time
@@ -424,10 +502,17 @@ type Server struct {
 	requestCount   atomic.Int64
 	errorCount     atomic.Int64
 	activeRequests atomic.Int64
+	panicCount     atomic.Int64
+
+	// External panic counters reported by /metrics (e.g. the Bolt server)
+	panicSources map[string]func() int64
 
 	// Slow query logging
 	slowQueryLogger *log.Logger
 	slowQueryCount  atomic.Int64
+
+	// Heimdall events of open explicit transactions, keyed by transaction ID
+	eventTxs sync.Map
 }
 
 // IPRateLimiter provides IP-based rate limiting to prevent DoS attacks.
@@ -611,32 +696,91 @@ func New(db *nornicdb.DB, authenticator *auth.Authenticator, config *Config) (*S
 	// Heimdall - AI Assistant for Database Management
 	// ==========================================================================
 	var heimdallHandler *heimdall.Handler
+	var heimdallManager *heimdall.Manager
+	var heimdallGuards *guardrails.Guardrails
+	var heimdallWebhooks *webhook.Sink
 	globalConfig := nornicConfig.LoadFromEnv()
 	if globalConfig.Features.HeimdallEnabled {
 		log.Println("🛡️  Heimdall AI Assistant initializing...")
 		heimdallCfg := heimdall.ConfigFromFeatureFlags(&globalConfig.Features)
+		heimdallCfg.ConfirmationTimeout = globalConfig.Features.HeimdallConfirmTimeout
+		heimdallCfg.ConfirmationApproveOnTimeout = globalConfig.Features.HeimdallConfirmApproveOnTimeout
+		heimdallCfg.HighRiskApprovers = globalConfig.Features.HeimdallHighRiskApprovers
+		heimdallCfg.BifrostCompression = globalConfig.Features.HeimdallBifrostCompression
+		heimdallCfg.BifrostBatchWindow = globalConfig.Features.HeimdallBifrostBatchWindow
+		heimdallCfg.BifrostBatchMaxMessages = globalConfig.Features.HeimdallBifrostBatchMaxMessages
+		heimdallCfg.BifrostBatchMaxBytes = globalConfig.Features.HeimdallBifrostBatchMaxBytes
+		if routes, err := heimdall.ParseModelRoutes(globalConfig.Features.HeimdallModelRoutes); err != nil {
+			log.Printf("⚠️  Ignoring Heimdall model routes: %v", err)
+		} else {
+			heimdallCfg.ModelRoutes = routes
+		}
+		heimdallCfg.DefaultPersona = globalConfig.Features.HeimdallDefaultPersona
+		heimdallCfg.JSONGrammar = globalConfig.Features.HeimdallJSONGrammar
+		heimdallCfg.Trace = globalConfig.Features.HeimdallTrace
+		heimdallCfg.TraceLimit = globalConfig.Features.HeimdallTraceLimit
+		heimdallCfg.RulesFile = globalConfig.Features.HeimdallRulesFile
+		if heimdallCfg.RulesFile == "" && db.DataDir() != "" {
+			heimdallCfg.RulesFile = filepath.Join(db.DataDir(), "heimdall", "rules.json")
+		}
+		heimdallCfg.ReportsFile = globalConfig.Features.HeimdallReportsFile
+		if heimdallCfg.ReportsFile == "" && db.DataDir() != "" {
+			heimdallCfg.ReportsFile = filepath.Join(db.DataDir(), "heimdall", "reports.json")
+		}
 		manager, err := heimdall.NewManager(heimdallCfg)
 		if err != nil {
 			log.Printf("⚠️  Heimdall initialization failed: %v", err)
 			log.Println("   → AI Assistant will not be available")
 			log.Println("   → Check NORNICDB_HEIMDALL_MODEL and NORNICDB_MODELS_DIR")
 		} else {
+			heimdallManager = manager
+
 			// Create database reader wrapper for Heimdall
 			dbReader := &heimdallDBReader{db: db}
-			metricsReader := &heimdallMetricsReader{}
+			metricsReader := &heimdallMetricsReader{db: db, throttle: config.WriteThrottle}
 			heimdallHandler = heimdall.NewHandler(manager, heimdallCfg, dbReader, metricsReader)
 
+			// Plugin notifications also go to webhooks, if configured
+			bifrost := heimdallHandler.Bifrost()
+			if path := globalConfig.Features.HeimdallWebhooksFile; path != "" {
+				if heimdallWebhooks, err = newHeimdallWebhooks(path); err != nil {
+					log.Printf("   ⚠️  Heimdall webhooks disabled: %v", err)
+				} else {
+					bifrost = webhook.Bridge(bifrost, heimdallWebhooks)
+				}
+			}
+
 			// Initialize Heimdall plugin subsystem
 			subsystemMgr := heimdall.GetSubsystemManager()
 			subsystemCtx := heimdall.SubsystemContext{
-				Config:  heimdallCfg,
-				Bifrost: heimdallHandler.Bifrost(),
+				Config:   heimdallCfg,
+				Database: dbReader,
+				Metrics:  metricsReader,
+				Bifrost:  bifrost,
 			}
+			subsystemCtx.Heimdall = heimdall.NewLiveHeimdallInvoker(subsystemMgr, manager.ForTask(heimdall.TaskPlugin), subsystemCtx.Bifrost, dbReader, metricsReader)
 			subsystemMgr.SetContext(subsystemCtx)
 
 			// Register built-in actions
 			heimdall.InitBuiltinActions()
 
+			// Response guardrails (checked after PreExecute hooks)
+			heimdallGuards, err = newHeimdallGuardrails(&globalConfig.Features)
+			if err != nil {
+				log.Printf("   ⚠️  Invalid Heimdall guardrails, actions disabled: %v", err)
+				heimdallGuards, _ = guardrails.New(guardrails.Config{Rules: []guardrails.Rule{{Action: "*", Reason: "guardrails misconfigured"}}})
+			}
+			heimdall.SetGuardrails(heimdallGuards)
+
+			// Operator-defined personas (graph personas are resolved per request)
+			if path := globalConfig.Features.HeimdallPersonasFile; path != "" {
+				if n, err := heimdall.LoadPersonasFile(path); err != nil {
+					log.Printf("   ⚠️  Failed to load Heimdall personas: %v", err)
+				} else {
+					log.Printf("   → Personas: %d loaded from %s", n, path)
+				}
+			}
+
 			// Register built-in watcher plugin
 			watcherPlugin := heimdallplugin.Plugin
 			if err := subsystemMgr.RegisterPlugin(watcherPlugin, "", true); err != nil {
@@ -647,6 +791,49 @@ func New(db *nornicdb.DB, authenticator *auth.Authenticator, config *Config) (*S
 				}
 			}
 
+			// Register built-in health plugin
+			if err := subsystemMgr.RegisterPlugin(healthplugin.Plugin, "", true); err != nil {
+				log.Printf("   ⚠️  Failed to register health plugin: %v", err)
+			} else if err := healthplugin.Plugin.Start(); err != nil {
+				log.Printf("   ⚠️  Failed to start health plugin: %v", err)
+			}
+
+			// Register built-in reports plugin
+			if err := subsystemMgr.RegisterPlugin(reportsplugin.Plugin, "", true); err != nil {
+				log.Printf("   ⚠️  Failed to register reports plugin: %v", err)
+			} else if err := reportsplugin.Plugin.Start(); err != nil {
+				log.Printf("   ⚠️  Failed to start reports plugin: %v", err)
+			}
+
+			// Versioned prompts of autonomous tasks, with optional A/B experiments
+			heimdallPrompts := newHeimdallPrompts(&globalConfig.Features)
+			manager.SetPrompts(heimdallPrompts)
+			db.SetMemoryPrompts(heimdallPrompts)
+
+			// Agent memory: the SLM consolidates session episodes and QC
+			// reviews the links to them (see nornicdb.memory.*)
+			db.SetMemorySummarizer(func(ctx context.Context, prompt string) (string, error) {
+				params := heimdall.DefaultGenerateParams()
+				params.Grammar = grammar.FromContext(ctx)
+				return manager.GenerateFor(ctx, heimdall.TaskSummarize, prompt, params)
+			})
+			if heimdallCfg.MemoryCuration {
+				qcCfg := inference.DefaultHeimdallQCConfig()
+				qcCfg.OnResponse = func(parsed bool) { manager.RecordOutcome(heimdall.TaskQC, parsed) }
+				qcCfg.Prompts = heimdallPrompts
+				db.SetHeimdallQC(inference.NewHeimdallQC(func(ctx context.Context, content string) (string, error) {
+					params := heimdall.DefaultGenerateParams()
+					params.Grammar = grammar.FromContext(ctx)
+					return manager.GenerateFor(ctx, heimdall.TaskQC, inference.SystemPromptFor(ctx, false)+"\n\n"+content, params)
+				}, qcCfg))
+				interval := globalConfig.Features.HeimdallMemoryConsolidationInterval
+				if err := db.StartMemoryConsolidation(interval, &nornicdb.ConsolidationConfig{MinAge: time.Hour}); err != nil {
+					log.Printf("   ⚠️  Memory consolidation not scheduled: %v", err)
+				} else {
+					log.Printf("   → Memory curation: consolidating sessions every %v", interval)
+				}
+			}
+
 			// Load external plugins if directory specified
 			pluginsDir := os.Getenv("NORNICDB_HEIMDALL_PLUGINS_DIR")
 			if pluginsDir != "" {
@@ -660,6 +847,9 @@ func New(db *nornicdb.DB, authenticator *auth.Authenticator, config *Config) (*S
 			actions := heimdall.ListHeimdallActions()
 			log.Printf("✅ Heimdall AI Assistant ready")
 			log.Printf("   → Model: %s", heimdallCfg.Model)
+			for _, ts := range manager.TaskStats() {
+				log.Printf("   → Model for %s: %s", ts.Task, ts.Model)
+			}
 			log.Printf("   → Plugins: %d loaded, %d actions available", len(plugins), len(actions))
 			log.Printf("   → Bifrost chat: /api/bifrost/chat/completions")
 			log.Printf("   → Status: /api/bifrost/status")
@@ -675,12 +865,20 @@ func New(db *nornicdb.DB, authenticator *auth.Authenticator, config *Config) (*S
 
 	// Configure embeddings if enabled
 	// Local provider doesn't need API URL, others do
+	var embeddingCache *embed.CachedEmbedder
 	embeddingsReady := config.EmbeddingEnabled && (config.EmbeddingProvider == "local" || config.EmbeddingAPIURL != "")
 	if embeddingsReady {
+		// A Heimdall model route for embeddings overrides the configured model
+		embeddingModel := config.EmbeddingModel
+		if heimdallManager != nil {
+			if name, ok := heimdallManager.ModelFor(heimdall.TaskEmbedding); ok {
+				embeddingModel = name
+			}
+		}
 		embedConfig := &embed.Config{
 			Provider:   config.EmbeddingProvider,
 			APIURL:     config.EmbeddingAPIURL,
-			Model:      config.EmbeddingModel,
+			Model:      embeddingModel,
 			Dimensions: config.EmbeddingDimensions,
 			Timeout:    30 * time.Second,
 		}
@@ -722,19 +920,38 @@ func New(db *nornicdb.DB, authenticator *auth.Authenticator, config *Config) (*S
 				}
 				log.Println("   → Falling back to full-text search only")
 			} else {
+				// Heimdall's model registry lists the embedder and times its requests
+				if heimdallManager != nil {
+					heimdallManager.RegisterModel(heimdall.ModelInfo{
+						Name:     embeddingModel,
+						Type:     heimdall.ModelTypeEmbedding,
+						Loaded:   true,
+						LastUsed: time.Now(),
+					}, heimdall.TaskEmbedding)
+					embedder = &heimdallTaskEmbedder{Embedder: embedder, manager: heimdallManager}
+				}
+
 				// Wrap with caching if enabled (default: 10K cache)
 				if config.EmbeddingCacheSize > 0 {
-					embedder = embed.NewCachedEmbedder(embedder, config.EmbeddingCacheSize)
+					embeddingCache = embed.NewCachedEmbedderWithConfig(embedder, embed.CacheConfig{
+						MaxSize: config.EmbeddingCacheSize,
+						TTL:     config.EmbeddingCacheTTL,
+						Dir:     config.EmbeddingCacheDir,
+					})
+					embedder = embeddingCache
 					log.Printf("✓ Embedding cache enabled: %d entries (~%dMB)",
 						config.EmbeddingCacheSize, embeddingCacheMemoryMB(config.EmbeddingCacheSize, config.EmbeddingDimensions))
+					if config.EmbeddingCacheDir != "" {
+						log.Printf("   → Persisted to %s", config.EmbeddingCacheDir)
+					}
 				}
 
 				if config.EmbeddingProvider == "local" {
 					log.Printf("✓ Embeddings enabled: local GGUF (%s, %d dims)",
-						config.EmbeddingModel, config.EmbeddingDimensions)
+						embeddingModel, config.EmbeddingDimensions)
 				} else {
 					log.Printf("✓ Embeddings enabled: %s (%s, %d dims)",
-						config.EmbeddingAPIURL, config.EmbeddingModel, config.EmbeddingDimensions)
+						config.EmbeddingAPIURL, embeddingModel, config.EmbeddingDimensions)
 				}
 				if mcpServer != nil {
 					mcpServer.SetEmbedder(embedder)
@@ -752,13 +969,41 @@ func New(db *nornicdb.DB, authenticator *auth.Authenticator, config *Config) (*S
 		log.Printf("✓ Rate limiting enabled: %d/min, %d/hour per IP", config.RateLimitPerMinute, config.RateLimitPerHour)
 	}
 
+	bookmarks := config.Bookmarks
+	if bookmarks == nil {
+		bookmarks = bookmark.New(30 * time.Second)
+	}
+
 	s := &Server{
-		config:          config,
-		db:              db,
-		auth:            authenticator,
-		mcpServer:       mcpServer,
-		heimdallHandler: heimdallHandler,
-		rateLimiter:     rateLimiter,
+		config:           config,
+		db:               db,
+		auth:             authenticator,
+		mcpServer:        mcpServer,
+		heimdallHandler:  heimdallHandler,
+		heimdallWebhooks: heimdallWebhooks,
+		embeddingCache:   embeddingCache,
+		rateLimiter:      rateLimiter,
+		bookmarks:        bookmarks,
+	}
+	if heimdallGuards != nil {
+		heimdallGuards.OnBlock(s.logGuardrailViolation)
+	}
+	if heimdallHandler != nil {
+		if b, ok := heimdallHandler.Bifrost().(*heimdall.Bifrost); ok {
+			b.OnConfirmationDecision(s.logConfirmationDecision)
+
+			var changes livequery.Source
+			if wal := db.WAL(); wal != nil {
+				changes = wal
+			}
+			s.liveQueries = livequery.New(s.runLiveQuery, changes, liveQueryDeliverer{b}, config.LiveQuery)
+			ctx, cancel := context.WithCancel(context.Background())
+			s.stopLiveQueries = cancel
+			go s.liveQueries.Run(ctx)
+		}
+	}
+	if schema := db.PropertySchema(); schema != nil {
+		schema.OnViolation(s.reportSchemaViolation)
 	}
 
 	// Initialize slow query logger if file specified
@@ -777,10 +1022,25 @@ func New(db *nornicdb.DB, authenticator *auth.Authenticator, config *Config) (*S
 	return s, nil
 }
 
+// AddPanicSource registers a panic counter from another component (e.g. the
+// Bolt server) so it is reported by /metrics as nornicdb_panics_total{component=...}.
+func (s *Server) AddPanicSource(component string, count func() int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.panicSources == nil {
+		s.panicSources = make(map[string]func() int64)
+	}
+	s.panicSources[component] = count
+}
+
 // SetAuditLogger sets the audit logger for compliance logging.
+// If Config.Redactor is set it is installed on the logger as well.
 func (s *Server) SetAuditLogger(logger *audit.Logger) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if logger != nil && s.config.Redactor.Enabled() {
+		logger.SetRedactor(s.config.Redactor)
+	}
 	s.audit = logger
 }
 
@@ -839,7 +1099,13 @@ func (s *Server) Start() error {
 	// Start serving
 	go func() {
 		var err error
-		if s.config.TLSCertFile != "" && s.config.TLSKeyFile != "" {
+		if s.config.TLSCertificate != nil {
+			s.httpServer.TLSConfig = &tls.Config{
+				Certificates: []tls.Certificate{*s.config.TLSCertificate},
+				MinVersion:   tls.VersionTLS12,
+			}
+			err = s.httpServer.ServeTLS(listener, "", "")
+		} else if s.config.TLSCertFile != "" && s.config.TLSKeyFile != "" {
 			err = s.httpServer.ServeTLS(listener, s.config.TLSCertFile, s.config.TLSKeyFile)
 		} else {
 			err = s.httpServer.Serve(listener)
@@ -864,6 +1130,15 @@ func (s *Server) Stop(ctx context.Context) error {
 		s.rateLimiter.Stop()
 	}
 
+	// Send queued Heimdall alerts
+	if s.heimdallWebhooks != nil {
+		s.heimdallWebhooks.Close()
+	}
+
+	if s.stopLiveQueries != nil {
+		s.stopLiveQueries()
+	}
+
 	if s.httpServer != nil {
 		return s.httpServer.Shutdown(ctx)
 	}
@@ -980,6 +1255,8 @@ func (s *Server) buildRouter() http.Handler {
 	// ==========================================================================
 	// Health check is public (required for load balancers/k8s probes)
 	mux.HandleFunc("/health", s.handleHealth)
+	// OpenAPI schema is public so client SDKs can be generated from a running server
+	mux.HandleFunc("/openapi.json", s.handleOpenAPI)
 	// Status and metrics require authentication to prevent information disclosure
 	// These expose node counts, uptime, request stats that aid reconnaissance
 	mux.HandleFunc("/status", s.withAuth(s.handleStatus, auth.PermRead))
@@ -1011,6 +1288,8 @@ func (s *Server) buildRouter() http.Handler {
 	mux.HandleFunc("/nornicdb/embed/trigger", s.withAuth(s.handleEmbedTrigger, auth.PermWrite))
 	mux.HandleFunc("/nornicdb/embed/stats", s.withAuth(s.handleEmbedStats, auth.PermRead))
 	mux.HandleFunc("/nornicdb/embed/clear", s.withAuth(s.handleEmbedClear, auth.PermAdmin))
+	mux.HandleFunc("/nornicdb/embed/drift", s.withAuth(s.handleEmbedDrift, auth.PermRead))
+	mux.HandleFunc("/nornicdb/embed/reembed", s.withAuth(s.handleEmbedReembed, auth.PermWrite))
 	mux.HandleFunc("/nornicdb/search/rebuild", s.withAuth(s.handleSearchRebuild, auth.PermWrite))
 
 	// Admin endpoints (NornicDB-specific)
@@ -1018,6 +1297,16 @@ func (s *Server) buildRouter() http.Handler {
 	mux.HandleFunc("/admin/config", s.withAuth(s.handleAdminConfig, auth.PermAdmin))
 	mux.HandleFunc("/admin/backup", s.withAuth(s.handleBackup, auth.PermAdmin))
 
+	// Offline sync for edge instances (NornicDB-specific)
+	if s.config.Sync != nil {
+		mux.HandleFunc(edgesync.BasePath, s.withAuth(edgesync.Handler(s.config.Sync).ServeHTTP, auth.PermWrite))
+	}
+
+	// Change data capture consumer groups (NornicDB-specific)
+	if changes := s.db.CDC(); changes != nil {
+		mux.HandleFunc(cdc.BasePath, s.withAuth(cdc.Handler(changes).ServeHTTP, auth.PermRead))
+	}
+
 	// GPU control endpoints (NornicDB-specific)
 	mux.HandleFunc("/admin/gpu/status", s.withAuth(s.handleGPUStatus, auth.PermAdmin))
 	mux.HandleFunc("/admin/gpu/enable", s.withAuth(s.handleGPUEnable, auth.PermAdmin))
@@ -1054,21 +1343,45 @@ func (s *Server) buildRouter() http.Handler {
 	// ==========================================================================
 	// Heimdall AI Assistant Endpoints (Bifrost chat interface)
 	// ==========================================================================
-	// Routes: /api/bifrost/status, /api/bifrost/chat/completions, /api/bifrost/events
+	// Routes: /api/bifrost/status, /api/bifrost/chat/completions, /api/bifrost/events,
+	// /api/bifrost/confirmations, /api/bifrost/personas, /api/bifrost/traces
 	// All Bifrost endpoints require authentication (PermRead minimum)
 	if s.heimdallHandler != nil {
 		// Status endpoint - read access required
 		mux.HandleFunc("/api/bifrost/status", s.withAuth(func(w http.ResponseWriter, r *http.Request) {
-			s.heimdallHandler.ServeHTTP(w, r)
+			s.serveHeimdall(w, r)
 		}, auth.PermRead))
 		// Chat completions - write access required (modifies state/generates content)
 		mux.HandleFunc("/api/bifrost/chat/completions", s.withAuth(func(w http.ResponseWriter, r *http.Request) {
-			s.heimdallHandler.ServeHTTP(w, r)
+			s.serveHeimdall(w, r)
 		}, auth.PermWrite))
 		// SSE events - read access required
 		mux.HandleFunc("/api/bifrost/events", s.withAuth(func(w http.ResponseWriter, r *http.Request) {
-			s.heimdallHandler.ServeHTTP(w, r)
+			s.serveHeimdall(w, r)
+		}, auth.PermRead))
+		// Action confirmations - write access required (approves actions)
+		mux.HandleFunc("/api/bifrost/confirmations", s.withAuth(func(w http.ResponseWriter, r *http.Request) {
+			s.serveHeimdall(w, r)
+		}, auth.PermWrite))
+		// Personas - read access required
+		mux.HandleFunc("/api/bifrost/personas", s.withAuth(func(w http.ResponseWriter, r *http.Request) {
+			s.serveHeimdall(w, r)
 		}, auth.PermRead))
+		// Request traces - read access required (users see their own traces)
+		traces := s.withAuth(func(w http.ResponseWriter, r *http.Request) {
+			s.serveHeimdall(w, r)
+		}, auth.PermRead)
+		mux.HandleFunc("/api/bifrost/traces", traces)
+		mux.HandleFunc("/api/bifrost/traces/", traces)
+	}
+	// Live queries - read access required (queries must be read-only)
+	if s.liveQueries != nil {
+		live := livequery.Handler(s.liveQueries, liveQueryUser, func(r *http.Request) bool {
+			claims := getClaims(r)
+			return claims == nil || hasPermission(claims.Roles, auth.PermAdmin)
+		})
+		mux.HandleFunc(livequery.BasePath, s.withAuth(live.ServeHTTP, auth.PermRead))
+		mux.HandleFunc(livequery.BasePath+"/", s.withAuth(live.ServeHTTP, auth.PermRead))
 	}
 
 	// Wrap with middleware (order matters: outermost runs first)
@@ -1080,6 +1393,7 @@ func (s *Server) buildRouter() http.Handler {
 	handler = s.loggingMiddleware(handler)
 	handler = s.recoveryMiddleware(handler)
 	handler = s.metricsMiddleware(handler)
+	handler = s.requestIDMiddleware(handler) // Outermost so every layer sees the ID
 
 	return handler
 }
@@ -1284,6 +1598,20 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// requestIDMiddleware assigns each request an ID for correlated logging.
+// A client-supplied X-Request-ID header is honoured; otherwise one is generated.
+// The ID is echoed back in the X-Request-ID response header.
+func (s *Server) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.HeaderName)
+		if id == "" || len(id) > 128 {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.HeaderName, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
+
 func (s *Server) recoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -1291,8 +1619,9 @@ func (s *Server) recoveryMiddleware(next http.Handler) http.Handler {
 				// Log panic
 				buf := make([]byte, 4096)
 				n := runtime.Stack(buf, false)
-				fmt.Printf("PANIC: %v\n%s\n", err, buf[:n])
+				fmt.Printf("PANIC [req=%s]: %v\n%s\n", requestid.FromContext(r.Context()), err, buf[:n])
 
+				s.panicCount.Add(1)
 				s.errorCount.Add(1)
 				s.writeError(w, http.StatusInternalServerError, "internal server error", ErrInternalError)
 			}
@@ -1351,6 +1680,10 @@ func (s *Server) handleDatabaseEndpoint(w http.ResponseWriter, r *http.Request)
 		// /db/{dbName}/cluster - cluster status
 		s.handleClusterStatus(w, r, dbName)
 
+	case remaining[0] == "cursor":
+		// /db/{dbName}/cursor/{cursor} - next page of a paged result
+		s.handleCursor(w, r, dbName, remaining[1:])
+
 	default:
 		s.writeNeo4jError(w, http.StatusNotFound, "Neo.ClientError.Request.Invalid", "unknown endpoint")
 	}
@@ -1427,6 +1760,7 @@ func (s *Server) handleTransactionEndpoint(w http.ResponseWriter, r *http.Reques
 // TransactionRequest follows Neo4j HTTP API format exactly.
 type TransactionRequest struct {
 	Statements []StatementRequest `json:"statements"`
+	Bookmarks  []string           `json:"bookmarks,omitempty"` // Wait for the state these bookmarks name before running
 }
 
 // StatementRequest is a single Cypher statement.
@@ -1435,6 +1769,8 @@ type StatementRequest struct {
 	Parameters         map[string]interface{} `json:"parameters,omitempty"`
 	ResultDataContents []string               `json:"resultDataContents,omitempty"` // ["row", "graph"]
 	IncludeStats       bool                   `json:"includeStats,omitempty"`
+	Retryable          bool                   `json:"retryable,omitempty"` // Retry an auto-commit write on conflict
+	PageSize           int                    `json:"pageSize,omitempty"`  // Rows per page; the rest is fetched with the result's cursor
 }
 
 // TransactionResponse follows Neo4j HTTP API format exactly.
@@ -1457,6 +1793,7 @@ type QueryResult struct {
 	Columns []string    `json:"columns"`
 	Data    []ResultRow `json:"data"`
 	Stats   *QueryStats `json:"stats,omitempty"`
+	Cursor  string      `json:"cursor,omitempty"` // Fetch the next page from /db/{dbName}/cursor/{cursor}
 }
 
 // ResultRow is a row of results with metadata.
@@ -1504,6 +1841,7 @@ type QueryStats struct {
 	ConstraintsAdded     int  `json:"constraints_added,omitempty"`
 	ConstraintsRemoved   int  `json:"constraints_removed,omitempty"`
 	ContainsUpdates      bool `json:"contains_updates,omitempty"`
+	Retries              int  `json:"retries,omitempty"` // Write conflict retries before commit
 }
 
 // QueryError is an error from a query (Neo4j format).
@@ -1530,21 +1868,98 @@ type NotificationPos struct {
 
 // handleImplicitTransaction executes statements in an implicit transaction.
 // This is the main query endpoint: POST /db/{dbName}/tx/commit
+//
+// Requests containing writes may carry an Idempotency-Key header. The first
+// request with a key runs; retries with the same key receive the recorded
+// response, marked with an Idempotent-Replayed header, without running again.
+// Only responses without errors are recorded.
 func (s *Server) handleImplicitTransaction(w http.ResponseWriter, r *http.Request, dbName string) {
+	format, ok := s.requestResultFormat(w, r)
+	if !ok {
+		return
+	}
 	var req TransactionRequest
 	if err := s.readJSON(r, &req); err != nil {
 		s.writeNeo4jError(w, http.StatusBadRequest, "Neo.ClientError.Request.InvalidFormat", "invalid request body")
 		return
 	}
 
-	response := TransactionResponse{
-		Results:       make([]QueryResult, 0, len(req.Statements)),
-		Errors:        make([]QueryError, 0),
-		LastBookmarks: []string{s.generateBookmark()},
+	key := ""
+	if k := r.Header.Get(idempotency.HeaderName); k != "" && containsMutation(req.Statements) {
+		user := ""
+		if claims := getClaims(r); claims != nil {
+			user = claims.Username
+		}
+		key = idempotency.Scope(user, dbName+"\x00"+k)
+	}
+
+	var response *TransactionResponse
+	written := false
+	recorded, replayed, err := s.config.Idempotency.Do(key, idempotency.Fingerprint("", map[string]any{"statements": req.Statements}), func() (any, error) {
+		response, written = s.runImplicitTransaction(w, r, dbName, &req)
+		if written || len(response.Errors) > 0 {
+			return nil, errNotRecorded
+		}
+		return response, nil
+	})
+	switch {
+	case written:
+		return
+	case errors.Is(err, idempotency.ErrKeyReused):
+		s.writeNeo4jError(w, http.StatusUnprocessableEntity, "Neo.ClientError.Request.Invalid", err.Error())
+		return
+	case errors.Is(err, idempotency.ErrUnavailable):
+		s.writeNeo4jError(w, http.StatusServiceUnavailable, "Neo.TransientError.Database.DatabaseUnavailable", err.Error())
+		return
+	case replayed:
+		if response, err = idempotency.Decode[*TransactionResponse](recorded); err != nil {
+			s.writeNeo4jError(w, http.StatusInternalServerError, "Neo.DatabaseError.General.UnknownError", err.Error())
+			return
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	// Determine appropriate status code based on consistency mode
+	// For eventual consistency (async writes), mutations return 202 Accepted
+	status := http.StatusOK
+	if s.db.IsAsyncWritesEnabled() && containsMutation(req.Statements) {
+		status = http.StatusAccepted
+		w.Header().Set("X-NornicDB-Consistency", "eventual")
+	}
+
+	s.writeTransactionResponse(w, status, response, format)
+}
+
+// errNotRecorded keeps a failed or throttled request out of the idempotency
+// store so that it can be retried with the same key.
+var errNotRecorded = errors.New("request not recorded")
+
+// containsMutation reports whether any statement writes.
+func containsMutation(statements []StatementRequest) bool {
+	for _, stmt := range statements {
+		if isMutationQuery(stmt.Statement) {
+			return true
+		}
+	}
+	return false
+}
+
+// runImplicitTransaction executes the statements of an implicit transaction,
+// stopping at the first error. It reports written if it already sent a
+// response, which happens when the caller is throttled.
+func (s *Server) runImplicitTransaction(w http.ResponseWriter, r *http.Request, dbName string, req *TransactionRequest) (response *TransactionResponse, written bool) {
+	response = &TransactionResponse{
+		Results: make([]QueryResult, 0, len(req.Statements)),
+		Errors:  make([]QueryError, 0),
+	}
+	if qe := s.waitForBookmarks(r, req.Bookmarks); qe != nil {
+		response.Errors = append(response.Errors, *qe)
+		return response, false
 	}
 
 	claims := getClaims(r)
 	hasError := false
+	wrote := false
 
 	for _, stmt := range req.Statements {
 		if hasError {
@@ -1566,55 +1981,131 @@ func (s *Server) handleImplicitTransaction(w http.ResponseWriter, r *http.Reques
 
 		// Track query execution time for slow query logging
 		queryStart := time.Now()
-		result, err := s.db.ExecuteCypher(r.Context(), stmt.Statement, stmt.Parameters)
+		result, err := s.executeCypher(retryableRequest(r, stmt), stmt.Statement, stmt.Parameters)
 		queryDuration := time.Since(queryStart)
+		if s.writeThrottled(w, err) {
+			return nil, true
+		}
 
 		// Log slow queries
-		s.logSlowQuery(stmt.Statement, stmt.Parameters, queryDuration, err)
+		s.logSlowQuery(r.Context(), stmt.Statement, stmt.Parameters, queryDuration, err)
 
 		if err != nil {
 			response.Errors = append(response.Errors, QueryError{
-				Code:    "Neo.ClientError.Statement.SyntaxError",
+				Code:    statementErrorCode(err),
 				Message: err.Error(),
 			})
 			hasError = true
 			continue
 		}
 
-		// Convert result to Neo4j format with metadata
-		qr := QueryResult{
-			Columns: result.Columns,
-			Data:    make([]ResultRow, len(result.Rows)),
-		}
-
-		for i, row := range result.Rows {
-			qr.Data[i] = ResultRow{
-				Row:  row,
-				Meta: s.generateRowMeta(row),
-			}
-		}
+		// Convert the first page to Neo4j format with metadata; the rest of
+		// the result waits in a cursor
+		page := s.config.Cursors.Open(cursorOwner(r, dbName), result.Columns, result.Rows, stmt.PageSize)
+		qr := s.pageResult(page)
 
 		if stmt.IncludeStats {
-			qr.Stats = &QueryStats{ContainsUpdates: isMutationQuery(stmt.Statement)}
+			qr.Stats = &QueryStats{ContainsUpdates: isMutationQuery(stmt.Statement), Retries: result.Retries}
 		}
 
 		response.Results = append(response.Results, qr)
+		wrote = wrote || isMutationQuery(stmt.Statement)
 	}
 
-	// Determine appropriate status code based on consistency mode
-	// For eventual consistency (async writes), mutations return 202 Accepted
-	status := http.StatusOK
-	if s.db.IsAsyncWritesEnabled() {
-		for _, stmt := range req.Statements {
-			if isMutationQuery(stmt.Statement) {
-				status = http.StatusAccepted
-				w.Header().Set("X-NornicDB-Consistency", "eventual")
-				break
+	response.LastBookmarks = s.lastBookmarks(wrote)
+	return response, false
+}
+
+// pageResult converts a page of a result to Neo4j format with metadata.
+func (s *Server) pageResult(page cursor.Page) QueryResult {
+	qr := QueryResult{
+		Columns: page.Columns,
+		Data:    make([]ResultRow, len(page.Rows)),
+		Cursor:  page.Cursor,
+	}
+	for i, row := range page.Rows {
+		qr.Data[i] = ResultRow{
+			Row:  row,
+			Meta: s.generateRowMeta(row),
+		}
+	}
+	return qr
+}
+
+// cursorOwner scopes cursors to the caller and database, so a token is of
+// no use to anyone else.
+func cursorOwner(r *http.Request, dbName string) string {
+	user := ""
+	if claims := getClaims(r); claims != nil {
+		user = claims.Username
+	}
+	return user + "\x00" + dbName
+}
+
+// handleCursor serves the pages of a result opened with a pageSize:
+//
+//	GET /db/{dbName}/cursor/{cursor}?pageSize=N - next page (default: the original page size)
+//	DELETE /db/{dbName}/cursor/{cursor} - close the cursor early
+//
+// The next page is a transaction response with one result, carrying the
+// cursor again while rows remain.
+func (s *Server) handleCursor(w http.ResponseWriter, r *http.Request, dbName string, remaining []string) {
+	if len(remaining) != 1 || remaining[0] == "" {
+		s.writeNeo4jError(w, http.StatusNotFound, "Neo.ClientError.Request.Invalid", "cursor required")
+		return
+	}
+	token := remaining[0]
+	owner := cursorOwner(r, dbName)
+
+	switch r.Method {
+	case http.MethodGet:
+		format, ok := s.requestResultFormat(w, r)
+		if !ok {
+			return
+		}
+		pageSize := 0
+		if v := r.URL.Query().Get("pageSize"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				s.writeNeo4jError(w, http.StatusBadRequest, "Neo.ClientError.Request.Invalid", "pageSize must be a positive integer")
+				return
 			}
+			pageSize = n
+		}
+		page, err := s.config.Cursors.Next(owner, token, pageSize)
+		if err != nil {
+			s.writeNeo4jError(w, http.StatusNotFound, "Neo.ClientError.Request.Invalid", err.Error())
+			return
+		}
+		s.writeTransactionResponse(w, http.StatusOK, &TransactionResponse{
+			Results: []QueryResult{s.pageResult(page)},
+			Errors:  make([]QueryError, 0),
+		}, format)
+
+	case http.MethodDelete:
+		if !s.config.Cursors.Close(owner, token) {
+			s.writeNeo4jError(w, http.StatusNotFound, "Neo.ClientError.Request.Invalid", cursor.ErrNotFound.Error())
+			return
 		}
+		s.writeJSON(w, http.StatusOK, TransactionResponse{
+			Results: make([]QueryResult, 0),
+			Errors:  make([]QueryError, 0),
+		})
+
+	default:
+		s.writeNeo4jError(w, http.StatusMethodNotAllowed, "Neo.ClientError.Request.Invalid", "use GET or DELETE")
 	}
+}
 
-	s.writeJSON(w, status, response)
+// retryableRequest returns r set up to retry stmt on a write conflict if the
+// statement is flagged retryable. Only auto-commit statements are retried.
+func retryableRequest(r *http.Request, stmt StatementRequest) *http.Request {
+	if !stmt.Retryable {
+		return r
+	}
+	ctx := r.Context()
+	opts := cypher.QueryOptionsFromContext(ctx).Merge(cypher.QueryOptions{Retryable: true})
+	return r.WithContext(cypher.WithQueryOptions(ctx, opts))
 }
 
 // generateRowMeta generates metadata for each value in a row
@@ -1641,27 +2132,102 @@ func (s *Server) generateRowMeta(row []interface{}) []interface{} {
 	return meta
 }
 
-// generateBookmark generates a bookmark for causal consistency
-func (s *Server) generateBookmark() string {
-	return fmt.Sprintf("FB:nornicdb:%d", time.Now().UnixNano())
+// lastBookmarks returns the bookmarks of a transaction response: a new
+// commit if the transaction wrote, else the last commit.
+func (s *Server) lastBookmarks(wrote bool) []string {
+	b := s.bookmarks.Current()
+	if wrote {
+		b = s.bookmarks.Commit()
+	}
+	if b == "" {
+		return nil
+	}
+	return []string{b}
+}
+
+// waitForBookmarks waits until the state named by a request's bookmarks has
+// been applied, returning the error to report if it was not.
+func (s *Server) waitForBookmarks(r *http.Request, bookmarks []string) *QueryError {
+	if err := s.bookmarks.Wait(r.Context(), bookmarks); err != nil {
+		return &QueryError{Code: neo4jerr.Code(err, neo4jerr.BookmarkTimeout), Message: err.Error()}
+	}
+	return nil
 }
 
 // Transaction management (explicit transactions)
 // For now, we implement simplified single-request transactions
 // TODO: Implement full explicit transaction support with transaction IDs
 
+// eventTxTimeout is how long the Heimdall events of an explicit transaction
+// are held before the transaction is considered abandoned and its events
+// are discarded.
+const eventTxTimeout = 5 * time.Minute
+
+// beginEventTx starts holding back the Heimdall events of transaction txID
+// and discards those of abandoned transactions.
+func (s *Server) beginEventTx(txID string) {
+	s.eventTxs.Range(func(key, value interface{}) bool {
+		if tx := value.(*heimdall.EventTransaction); time.Since(tx.Started()) > eventTxTimeout {
+			s.eventTxs.Delete(key)
+			tx.Rollback()
+		}
+		return true
+	})
+	s.eventTxs.Store(txID, heimdall.BeginEventTransaction(txID, "http"))
+}
+
+// withEventTx returns r with the event transaction of txID attached, so
+// the events of its statements wait for the commit.
+func (s *Server) withEventTx(r *http.Request, txID string) *http.Request {
+	tx, ok := s.eventTxs.Load(txID)
+	if !ok {
+		return r
+	}
+	return r.WithContext(heimdall.WithEventTransaction(r.Context(), tx.(*heimdall.EventTransaction)))
+}
+
+// endEventTx publishes (commit) or discards the events of transaction txID.
+func (s *Server) endEventTx(txID string, commit bool) {
+	v, ok := s.eventTxs.LoadAndDelete(txID)
+	if !ok {
+		return
+	}
+	if tx := v.(*heimdall.EventTransaction); commit {
+		tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+}
+
 func (s *Server) handleOpenTransaction(w http.ResponseWriter, r *http.Request, dbName string) {
+	format, ok := s.requestResultFormat(w, r)
+	if !ok {
+		return
+	}
+
+	var req TransactionRequest
+	_ = s.readJSON(r, &req) // Optional body
+
+	// The transaction reads the state named by its bookmarks
+	if qe := s.waitForBookmarks(r, req.Bookmarks); qe != nil {
+		status := http.StatusBadRequest
+		if neo4jerr.IsTransient(qe.Code) {
+			status = http.StatusServiceUnavailable
+		}
+		s.writeNeo4jError(w, status, qe.Code, qe.Message)
+		return
+	}
+
 	// Generate transaction ID
 	txID := fmt.Sprintf("%d", time.Now().UnixNano())
+	s.beginEventTx(txID)
+	r = s.withEventTx(r, txID)
 
 	host := s.config.Address
 	if host == "0.0.0.0" {
 		host = "localhost"
 	}
 
-	var req TransactionRequest
-	_ = s.readJSON(r, &req) // Optional body
-
 	response := TransactionResponse{
 		Results: make([]QueryResult, 0),
 		Errors:  make([]QueryError, 0),
@@ -1674,10 +2240,13 @@ func (s *Server) handleOpenTransaction(w http.ResponseWriter, r *http.Request, d
 	// Execute any provided statements
 	if len(req.Statements) > 0 {
 		for _, stmt := range req.Statements {
-			result, err := s.db.ExecuteCypher(r.Context(), stmt.Statement, stmt.Parameters)
+			result, err := s.executeCypher(r, stmt.Statement, stmt.Parameters)
+			if s.writeThrottled(w, err) {
+				return
+			}
 			if err != nil {
 				response.Errors = append(response.Errors, QueryError{
-					Code:    "Neo.ClientError.Statement.SyntaxError",
+					Code:    statementErrorCode(err),
 					Message: err.Error(),
 				})
 				continue
@@ -1705,31 +2274,38 @@ func (s *Server) handleOpenTransaction(w http.ResponseWriter, r *http.Request, d
 		}
 	}
 
-	s.writeJSON(w, http.StatusCreated, response)
+	s.writeTransactionResponse(w, http.StatusCreated, &response, format)
 }
 
 func (s *Server) handleExecuteInTransaction(w http.ResponseWriter, r *http.Request, dbName, txID string) {
 	// Execute statements in open transaction
 	// For simplified implementation, treat as immediate execution
-	s.handleImplicitTransaction(w, r, dbName)
+	s.handleImplicitTransaction(w, s.withEventTx(r, txID), dbName)
 }
 
 func (s *Server) handleCommitTransaction(w http.ResponseWriter, r *http.Request, dbName, txID string) {
+	format, ok := s.requestResultFormat(w, r)
+	if !ok {
+		return
+	}
+	r = s.withEventTx(r, txID)
 	var req TransactionRequest
 	_ = s.readJSON(r, &req) // Optional final statements
 
 	response := TransactionResponse{
-		Results:       make([]QueryResult, 0),
-		Errors:        make([]QueryError, 0),
-		LastBookmarks: []string{s.generateBookmark()},
+		Results: make([]QueryResult, 0),
+		Errors:  make([]QueryError, 0),
 	}
 
 	// Execute any final statements
 	for _, stmt := range req.Statements {
-		result, err := s.db.ExecuteCypher(r.Context(), stmt.Statement, stmt.Parameters)
+		result, err := s.executeCypher(r, stmt.Statement, stmt.Parameters)
+		if s.writeThrottled(w, err) {
+			return
+		}
 		if err != nil {
 			response.Errors = append(response.Errors, QueryError{
-				Code:    "Neo.ClientError.Statement.SyntaxError",
+				Code:    statementErrorCode(err),
 				Message: err.Error(),
 			})
 			continue
@@ -1744,6 +2320,8 @@ func (s *Server) handleCommitTransaction(w http.ResponseWriter, r *http.Request,
 		}
 		response.Results = append(response.Results, qr)
 	}
+	s.endEventTx(txID, true)
+	response.LastBookmarks = s.lastBookmarks(true)
 
 	// For commits with async writes and mutations, use 202 Accepted
 	status := http.StatusOK
@@ -1757,11 +2335,12 @@ func (s *Server) handleCommitTransaction(w http.ResponseWriter, r *http.Request,
 		}
 	}
 
-	s.writeJSON(w, status, response)
+	s.writeTransactionResponse(w, status, &response, format)
 }
 
 func (s *Server) handleRollbackTransaction(w http.ResponseWriter, r *http.Request, dbName, txID string) {
 	// Rollback transaction (for simplified implementation, just acknowledge)
+	s.endEventTx(txID, false)
 	response := TransactionResponse{
 		Results: make([]QueryResult, 0),
 		Errors:  make([]QueryError, 0),
@@ -1903,6 +2482,73 @@ func (s *Server) handleEmbedClear(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, http.StatusOK, response)
 }
 
+// handleEmbedDrift reports the embedding model versions present in the index.
+func (s *Server) handleEmbedDrift(w http.ResponseWriter, r *http.Request) {
+	report, err := s.db.DetectEmbeddingDrift(r.Context())
+	if err != nil {
+		s.writeNeo4jError(w, http.StatusInternalServerError, "Neo.DatabaseError.General.UnknownError", err.Error())
+		return
+	}
+	s.writeJSON(w, http.StatusOK, report)
+}
+
+// handleEmbedReembed manages the background re-embedding job.
+//   - GET: job progress
+//   - POST: start re-embedding stale nodes, most read first (query params:
+//     delay=100ms, limit=N for the N most read)
+//   - DELETE: cancel the running job
+func (s *Server) handleEmbedReembed(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		progress := s.db.ReembedProgress()
+		if progress == nil {
+			s.writeJSON(w, http.StatusOK, map[string]interface{}{"state": "idle"})
+			return
+		}
+		s.writeJSON(w, http.StatusOK, progress)
+
+	case http.MethodPost:
+		config := nornicdb.DefaultReembedConfig()
+		if v := r.URL.Query().Get("delay"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				s.writeNeo4jError(w, http.StatusBadRequest, "Neo.ClientError.Request.Invalid", "invalid delay: "+v)
+				return
+			}
+			config.Delay = d
+		}
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				s.writeNeo4jError(w, http.StatusBadRequest, "Neo.ClientError.Request.Invalid", "invalid limit: "+v)
+				return
+			}
+			config.MaxNodes = n
+		}
+		progress, err := s.db.StartReembed(config)
+		switch {
+		case errors.Is(err, nornicdb.ErrReembedRunning):
+			s.writeNeo4jError(w, http.StatusConflict, "Neo.ClientError.Request.Invalid", err.Error())
+		case errors.Is(err, nornicdb.ErrEmbedderNotConfigured):
+			s.writeNeo4jError(w, http.StatusServiceUnavailable, "Neo.DatabaseError.General.UnknownError", "Auto-embed not enabled")
+		case err != nil:
+			s.writeNeo4jError(w, http.StatusInternalServerError, "Neo.DatabaseError.General.UnknownError", err.Error())
+		default:
+			s.writeJSON(w, http.StatusAccepted, progress)
+		}
+
+	case http.MethodDelete:
+		cancelled := s.db.CancelReembed()
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"cancelled": cancelled,
+			"progress":  s.db.ReembedProgress(),
+		})
+
+	default:
+		s.writeNeo4jError(w, http.StatusMethodNotAllowed, "Neo.ClientError.Request.Invalid", "GET, POST or DELETE required")
+	}
+}
+
 // handleSearchRebuild rebuilds search indexes from all nodes.
 func (s *Server) handleSearchRebuild(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -1952,13 +2598,62 @@ func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, http.StatusOK, response)
 }
 
+// HealthResponse is the body of GET /health. Status is "healthy", or
+// "recovering" while WAL recovery runs and writes are rejected.
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// StatusResponse is the body of GET /status.
+type StatusResponse struct {
+	Status     string           `json:"status"`
+	Server     StatusServer     `json:"server"`
+	Database   StatusDatabase   `json:"database"`
+	Embeddings StatusEmbeddings `json:"embeddings"`
+	Recovery   *StatusRecovery  `json:"recovery,omitempty"`
+}
+
+// StatusServer holds the server counters reported by /status.
+type StatusServer struct {
+	UptimeSeconds float64 `json:"uptime_seconds"`
+	Requests      int64   `json:"requests"`
+	Errors        int64   `json:"errors"`
+	Active        int64   `json:"active"`
+}
+
+// StatusDatabase holds the graph size reported by /status.
+type StatusDatabase struct {
+	Nodes int64 `json:"nodes"`
+	Edges int64 `json:"edges"`
+}
+
+// StatusEmbeddings describes the embedding worker. Status is "idle" or
+// "processing", and omitted when embeddings are disabled.
+type StatusEmbeddings struct {
+	Enabled   bool   `json:"enabled"`
+	Status    string `json:"status,omitempty"`
+	Processed int    `json:"processed"`
+	Failed    int    `json:"failed"`
+}
+
+// StatusRecovery reports WAL recovery progress while it runs; writes are
+// rejected until it completes.
+type StatusRecovery struct {
+	Phase      string  `json:"phase"`
+	Done       int     `json:"done"`
+	Total      int     `json:"total"`
+	Percent    float64 `json:"percent"`
+	ETASeconds float64 `json:"eta_seconds"`
+}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	// Minimal health response - no operational details to reduce reconnaissance surface
 	// Detailed status available at authenticated /status endpoint
-	response := map[string]interface{}{
-		"status": "healthy",
+	status := "healthy"
+	if _, recovering := storage.Recovering(); recovering {
+		status = "recovering"
 	}
-	s.writeJSON(w, http.StatusOK, response)
+	s.writeJSON(w, http.StatusOK, HealthResponse{Status: status})
 }
 
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
@@ -1966,35 +2661,43 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	dbStats := s.db.Stats()
 
 	// Build embedding info
-	embedInfo := map[string]interface{}{
-		"enabled": false,
-	}
+	var embedInfo StatusEmbeddings
 	if embedStats := s.db.EmbedQueueStats(); embedStats != nil {
 		status := "idle"
 		if embedStats.Running {
 			status = "processing"
 		}
-		embedInfo = map[string]interface{}{
-			"enabled":   true,
-			"status":    status,
-			"processed": embedStats.Processed,
-			"failed":    embedStats.Failed,
+		embedInfo = StatusEmbeddings{
+			Enabled:   true,
+			Status:    status,
+			Processed: embedStats.Processed,
+			Failed:    embedStats.Failed,
 		}
 	}
 
-	response := map[string]interface{}{
-		"status": "running",
-		"server": map[string]interface{}{
-			"uptime_seconds": stats.Uptime.Seconds(),
-			"requests":       stats.RequestCount,
-			"errors":         stats.ErrorCount,
-			"active":         stats.ActiveRequests,
+	response := StatusResponse{
+		Status: "running",
+		Server: StatusServer{
+			UptimeSeconds: stats.Uptime.Seconds(),
+			Requests:      stats.RequestCount,
+			Errors:        stats.ErrorCount,
+			Active:        stats.ActiveRequests,
 		},
-		"database": map[string]interface{}{
-			"nodes": dbStats.NodeCount,
-			"edges": dbStats.EdgeCount,
+		Database: StatusDatabase{
+			Nodes: dbStats.NodeCount,
+			Edges: dbStats.EdgeCount,
 		},
-		"embeddings": embedInfo,
+		Embeddings: embedInfo,
+	}
+	if progress, recovering := storage.Recovering(); recovering {
+		response.Status = "recovering"
+		response.Recovery = &StatusRecovery{
+			Phase:      progress.Phase,
+			Done:       progress.Done,
+			Total:      progress.Total,
+			Percent:    progress.Percent(),
+			ETASeconds: progress.ETA().Seconds(),
+		}
 	}
 
 	s.writeJSON(w, http.StatusOK, response)
@@ -2013,6 +2716,8 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 //   - nornicdb_embeddings_processed: Embeddings processed
 //   - nornicdb_embeddings_failed: Embedding failures
 //   - nornicdb_embedding_worker_running: Whether embed worker is active (0/1)
+//   - nornicdb_panics_total{component}: Recovered panics (http, cypher, heimdall, bolt)
+//   - nornicdb_rate_limit_throttled_total{limit}: Queries rejected by rate limits
 //
 // Example Prometheus config:
 //
@@ -2082,6 +2787,162 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	sb.WriteString("# TYPE nornicdb_slow_query_threshold_ms gauge\n")
 	fmt.Fprintf(&sb, "nornicdb_slow_query_threshold_ms %d\n", s.config.SlowQueryThreshold.Milliseconds())
 
+	// Recovered panic metrics
+	sb.WriteString("# HELP nornicdb_panics_total Recovered panics by component\n")
+	sb.WriteString("# TYPE nornicdb_panics_total counter\n")
+	fmt.Fprintf(&sb, "nornicdb_panics_total{component=\"http\"} %d\n", s.panicCount.Load())
+	fmt.Fprintf(&sb, "nornicdb_panics_total{component=\"cypher\"} %d\n", cypher.PanicCount())
+	fmt.Fprintf(&sb, "nornicdb_panics_total{component=\"heimdall\"} %d\n", heimdall.PanicCount())
+	s.mu.RLock()
+	for component, count := range s.panicSources {
+		fmt.Fprintf(&sb, "nornicdb_panics_total{component=%q} %d\n", component, count())
+	}
+	s.mu.RUnlock()
+
+	// Query rate limit metrics (shared by Bolt and HTTP)
+	if s.config.QueryLimiter.Enabled() {
+		limitStats := s.config.QueryLimiter.Stats()
+		sb.WriteString("# HELP nornicdb_rate_limit_throttled_total Queries rejected by rate limits\n")
+		sb.WriteString("# TYPE nornicdb_rate_limit_throttled_total counter\n")
+		for _, limit := range []string{ratelimit.LimitQueriesPerSecond, ratelimit.LimitConcurrent, ratelimit.LimitResultBytes} {
+			fmt.Fprintf(&sb, "nornicdb_rate_limit_throttled_total{limit=%q} %d\n", limit, limitStats.Throttled[limit])
+		}
+		sb.WriteString("# HELP nornicdb_rate_limit_tracked_callers Users and IPs with live rate limit state\n")
+		sb.WriteString("# TYPE nornicdb_rate_limit_tracked_callers gauge\n")
+		fmt.Fprintf(&sb, "nornicdb_rate_limit_tracked_callers %d\n", limitStats.Tracked)
+	}
+
+	// Write throttle metrics (shared by Bolt and HTTP)
+	if s.config.WriteThrottle.Enabled() {
+		throttle := s.config.WriteThrottle.Stats()
+		sb.WriteString("# HELP nornicdb_write_throttle_pressure Write backpressure (0 = none, 0-1 = delaying writes, >= 1 = rejecting writes)\n")
+		sb.WriteString("# TYPE nornicdb_write_throttle_pressure gauge\n")
+		fmt.Fprintf(&sb, "nornicdb_write_throttle_pressure %g\n", throttle.Pressure)
+		sb.WriteString("# HELP nornicdb_write_throttle_writes_total Writes by throttle outcome\n")
+		sb.WriteString("# TYPE nornicdb_write_throttle_writes_total counter\n")
+		fmt.Fprintf(&sb, "nornicdb_write_throttle_writes_total{outcome=\"admitted\"} %d\n", throttle.Admitted)
+		fmt.Fprintf(&sb, "nornicdb_write_throttle_writes_total{outcome=\"delayed\"} %d\n", throttle.Delayed)
+		fmt.Fprintf(&sb, "nornicdb_write_throttle_writes_total{outcome=\"rejected\"} %d\n", throttle.Rejected)
+		sb.WriteString("# HELP nornicdb_write_throttle_delay_seconds_total Time writes were held back\n")
+		sb.WriteString("# TYPE nornicdb_write_throttle_delay_seconds_total counter\n")
+		fmt.Fprintf(&sb, "nornicdb_write_throttle_delay_seconds_total %g\n", throttle.Delay.Seconds())
+	}
+
+	// Embedding cache metrics
+	if s.embeddingCache != nil {
+		cacheStats := s.embeddingCache.Stats()
+		sb.WriteString("# HELP nornicdb_embedding_cache_requests_total Embedding requests by cache outcome\n")
+		sb.WriteString("# TYPE nornicdb_embedding_cache_requests_total counter\n")
+		fmt.Fprintf(&sb, "nornicdb_embedding_cache_requests_total{outcome=\"memory_hit\"} %d\n", cacheStats.Hits-cacheStats.DiskHits)
+		fmt.Fprintf(&sb, "nornicdb_embedding_cache_requests_total{outcome=\"disk_hit\"} %d\n", cacheStats.DiskHits)
+		fmt.Fprintf(&sb, "nornicdb_embedding_cache_requests_total{outcome=\"miss\"} %d\n", cacheStats.Misses)
+		sb.WriteString("# HELP nornicdb_embedding_cache_expired_total Cached embeddings dropped after their TTL\n")
+		sb.WriteString("# TYPE nornicdb_embedding_cache_expired_total counter\n")
+		fmt.Fprintf(&sb, "nornicdb_embedding_cache_expired_total %d\n", cacheStats.Expired)
+		sb.WriteString("# HELP nornicdb_embedding_cache_entries Embeddings cached in memory\n")
+		sb.WriteString("# TYPE nornicdb_embedding_cache_entries gauge\n")
+		fmt.Fprintf(&sb, "nornicdb_embedding_cache_entries %d\n", cacheStats.Size)
+	}
+
+	// GPU vector query scheduler metrics
+	if scheduler := s.db.GPUScheduler(); scheduler != nil {
+		gpuStats := scheduler.Stats()
+		sb.WriteString("# HELP nornicdb_gpu_scheduler_queries_total Vector queries run on the GPU\n")
+		sb.WriteString("# TYPE nornicdb_gpu_scheduler_queries_total counter\n")
+		fmt.Fprintf(&sb, "nornicdb_gpu_scheduler_queries_total %d\n", gpuStats.Queries)
+		sb.WriteString("# HELP nornicdb_gpu_scheduler_launches_total Chunks launched on the GPU\n")
+		sb.WriteString("# TYPE nornicdb_gpu_scheduler_launches_total counter\n")
+		fmt.Fprintf(&sb, "nornicdb_gpu_scheduler_launches_total %d\n", gpuStats.Launches)
+		sb.WriteString("# HELP nornicdb_gpu_scheduler_yields_total Times a query yielded the GPU to a waiting one\n")
+		sb.WriteString("# TYPE nornicdb_gpu_scheduler_yields_total counter\n")
+		fmt.Fprintf(&sb, "nornicdb_gpu_scheduler_yields_total %d\n", gpuStats.Yields)
+		sb.WriteString("# HELP nornicdb_gpu_scheduler_vram_rejected_total Queries scored on CPU for exceeding the per-query VRAM limit\n")
+		sb.WriteString("# TYPE nornicdb_gpu_scheduler_vram_rejected_total counter\n")
+		fmt.Fprintf(&sb, "nornicdb_gpu_scheduler_vram_rejected_total %d\n", gpuStats.VRAMRejected)
+		sb.WriteString("# HELP nornicdb_gpu_scheduler_waiting Queries waiting for the GPU\n")
+		sb.WriteString("# TYPE nornicdb_gpu_scheduler_waiting gauge\n")
+		fmt.Fprintf(&sb, "nornicdb_gpu_scheduler_waiting %d\n", gpuStats.Waiting)
+		sb.WriteString("# HELP nornicdb_gpu_scheduler_wait_seconds_total Time queries waited for the GPU\n")
+		sb.WriteString("# TYPE nornicdb_gpu_scheduler_wait_seconds_total counter\n")
+		fmt.Fprintf(&sb, "nornicdb_gpu_scheduler_wait_seconds_total %g\n", gpuStats.WaitTime.Seconds())
+	}
+
+	// Storage quota metrics
+	if quota, ok := s.db.StorageQuota(); ok {
+		limits := []struct {
+			resource    string
+			limit, used int64
+		}{
+			{storage.QuotaResourceNodes, quota.Quota.MaxNodes, quota.Usage.Nodes},
+			{storage.QuotaResourceRelationships, quota.Quota.MaxRelationships, quota.Usage.Relationships},
+			{storage.QuotaResourceBytes, quota.Quota.MaxBytes, quota.Usage.Bytes},
+		}
+		labels := make([]string, 0, len(quota.Quota.MaxNodesPerLabel))
+		for label := range quota.Quota.MaxNodesPerLabel {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+
+		sb.WriteString("# HELP nornicdb_storage_quota_limit Storage quota limit (0 = unlimited)\n")
+		sb.WriteString("# TYPE nornicdb_storage_quota_limit gauge\n")
+		for _, l := range limits {
+			fmt.Fprintf(&sb, "nornicdb_storage_quota_limit{resource=%q} %d\n", l.resource, l.limit)
+		}
+		for _, label := range labels {
+			fmt.Fprintf(&sb, "nornicdb_storage_quota_limit{resource=\"label\",label=%q} %d\n", label, quota.Quota.MaxNodesPerLabel[label])
+		}
+		sb.WriteString("# HELP nornicdb_storage_quota_used Storage counted against the quota\n")
+		sb.WriteString("# TYPE nornicdb_storage_quota_used gauge\n")
+		for _, l := range limits {
+			fmt.Fprintf(&sb, "nornicdb_storage_quota_used{resource=%q} %d\n", l.resource, l.used)
+		}
+		for _, label := range labels {
+			fmt.Fprintf(&sb, "nornicdb_storage_quota_used{resource=\"label\",label=%q} %d\n", label, quota.Usage.NodesPerLabel[label])
+		}
+		sb.WriteString("# HELP nornicdb_storage_quota_rejections_total Writes rejected by storage quotas\n")
+		sb.WriteString("# TYPE nornicdb_storage_quota_rejections_total counter\n")
+		for _, l := range limits {
+			fmt.Fprintf(&sb, "nornicdb_storage_quota_rejections_total{resource=%q} %d\n", l.resource, quota.Rejected[l.resource])
+		}
+		for _, label := range labels {
+			fmt.Fprintf(&sb, "nornicdb_storage_quota_rejections_total{resource=\"label\",label=%q} %d\n", label, quota.RejectedPerLabel[label])
+		}
+	}
+
+	// Change data capture metrics
+	if changes := s.db.CDC(); changes != nil {
+		groups := changes.Stats()
+		sb.WriteString("# HELP nornicdb_cdc_group_lag WAL entries not yet acknowledged by a CDC consumer group\n")
+		sb.WriteString("# TYPE nornicdb_cdc_group_lag gauge\n")
+		for _, g := range groups {
+			fmt.Fprintf(&sb, "nornicdb_cdc_group_lag{group=%q} %d\n", g.Name, g.Lag)
+		}
+		sb.WriteString("# HELP nornicdb_cdc_group_in_flight Unacknowledged CDC batches of a consumer group\n")
+		sb.WriteString("# TYPE nornicdb_cdc_group_in_flight gauge\n")
+		for _, g := range groups {
+			fmt.Fprintf(&sb, "nornicdb_cdc_group_in_flight{group=%q} %d\n", g.Name, g.InFlight)
+		}
+		sb.WriteString("# HELP nornicdb_cdc_redeliveries_total CDC batches delivered again after a timeout or nack\n")
+		sb.WriteString("# TYPE nornicdb_cdc_redeliveries_total counter\n")
+		for _, g := range groups {
+			fmt.Fprintf(&sb, "nornicdb_cdc_redeliveries_total{group=%q} %d\n", g.Name, g.Redeliveries)
+		}
+		sb.WriteString("# HELP nornicdb_cdc_consumer_lag WAL entries between a CDC consumer's oldest unacknowledged batch and the head\n")
+		sb.WriteString("# TYPE nornicdb_cdc_consumer_lag gauge\n")
+		for _, g := range groups {
+			for _, c := range g.Consumers {
+				fmt.Fprintf(&sb, "nornicdb_cdc_consumer_lag{group=%q,consumer=%q} %d\n", g.Name, c.Name, c.Lag)
+			}
+		}
+		sb.WriteString("# HELP nornicdb_cdc_consumer_events_total CDC events delivered to a consumer\n")
+		sb.WriteString("# TYPE nornicdb_cdc_consumer_events_total counter\n")
+		for _, g := range groups {
+			for _, c := range g.Consumers {
+				fmt.Fprintf(&sb, "nornicdb_cdc_consumer_events_total{group=%q,consumer=%q} %d\n", g.Name, c.Name, c.Events)
+			}
+		}
+	}
+
 	// Info metric with version
 	sb.WriteString("# HELP nornicdb_info Database information\n")
 	sb.WriteString("# TYPE nornicdb_info gauge\n")
@@ -2097,6 +2958,13 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 // Authentication Handlers
 // =============================================================================
 
+// TokenRequest is the body of POST /auth/token (OAuth 2.0 password grant).
+type TokenRequest struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	GrantType string `json:"grant_type,omitempty"` // "password" or empty
+}
+
 func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		s.writeError(w, http.StatusMethodNotAllowed, "POST required", ErrMethodNotAllowed)
@@ -2109,11 +2977,7 @@ func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse request body
-	var req struct {
-		Username  string `json:"username"`
-		Password  string `json:"password"`
-		GrantType string `json:"grant_type"`
-	}
+	var req TokenRequest
 
 	if err := s.readJSON(r, &req); err != nil {
 		s.writeError(w, http.StatusBadRequest, "invalid request body", ErrBadRequest)
@@ -2318,17 +3182,26 @@ func (s *Server) handleUserByID(w http.ResponseWriter, r *http.Request) {
 // Search Handlers
 // =============================================================================
 
+// SearchRequest is the body of POST /nornicdb/search.
+type SearchRequest struct {
+	Query  string   `json:"query"`
+	Labels []string `json:"labels,omitempty"`
+	Limit  int      `json:"limit,omitempty"` // default 10
+}
+
+// SimilarRequest is the body of POST /nornicdb/similar.
+type SimilarRequest struct {
+	NodeID string `json:"node_id"`
+	Limit  int    `json:"limit,omitempty"` // default 10
+}
+
 func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		s.writeError(w, http.StatusMethodNotAllowed, "POST required", ErrMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		Query  string   `json:"query"`
-		Labels []string `json:"labels,omitempty"`
-		Limit  int      `json:"limit,omitempty"`
-	}
+	var req SearchRequest
 
 	if err := s.readJSON(r, &req); err != nil {
 		s.writeError(w, http.StatusBadRequest, "invalid request body", ErrBadRequest)
@@ -2367,10 +3240,7 @@ func (s *Server) handleSimilar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		NodeID string `json:"node_id"`
-		Limit  int    `json:"limit,omitempty"`
-	}
+	var req SimilarRequest
 
 	if err := s.readJSON(r, &req); err != nil {
 		s.writeError(w, http.StatusBadRequest, "invalid request body", ErrBadRequest)
@@ -2394,16 +3264,35 @@ func (s *Server) handleSimilar(w http.ResponseWriter, r *http.Request) {
 // Admin Handlers
 // =============================================================================
 
-func (s *Server) handleAdminStats(w http.ResponseWriter, r *http.Request) {
-	serverStats := s.Stats()
-	dbStats := s.db.Stats()
+// AdminStatsResponse is the body of GET /admin/stats.
+type AdminStatsResponse struct {
+	Server   ServerStats      `json:"server"`
+	Database nornicdb.DBStats `json:"database"`
+	Memory   MemoryStats      `json:"memory"`
+}
 
-	response := map[string]interface{}{
-		"server":   serverStats,
-		"database": dbStats,
-		"memory": map[string]interface{}{
-			"alloc_mb":   getMemoryUsageMB(),
-			"goroutines": runtime.NumGoroutine(),
+// MemoryStats holds process memory figures.
+type MemoryStats struct {
+	AllocMB    float64 `json:"alloc_mb"`
+	Goroutines int     `json:"goroutines"`
+}
+
+// AdminConfigResponse is the body of GET /admin/config. It holds no secrets.
+type AdminConfigResponse struct {
+	Address     string `json:"address"`
+	Port        int    `json:"port"`
+	CORSEnabled bool   `json:"cors_enabled"`
+	Compression bool   `json:"compression"`
+	TLSEnabled  bool   `json:"tls_enabled"`
+}
+
+func (s *Server) handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	response := AdminStatsResponse{
+		Server:   s.Stats(),
+		Database: s.db.Stats(),
+		Memory: MemoryStats{
+			AllocMB:    getMemoryUsageMB(),
+			Goroutines: runtime.NumGoroutine(),
 		},
 	}
 
@@ -2412,12 +3301,12 @@ func (s *Server) handleAdminStats(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleAdminConfig(w http.ResponseWriter, r *http.Request) {
 	// Return safe config (no secrets)
-	config := map[string]interface{}{
-		"address":      s.config.Address,
-		"port":         s.config.Port,
-		"cors_enabled": s.config.EnableCORS,
-		"compression":  s.config.EnableCompression,
-		"tls_enabled":  s.config.TLSCertFile != "",
+	config := AdminConfigResponse{
+		Address:     s.config.Address,
+		Port:        s.config.Port,
+		CORSEnabled: s.config.EnableCORS,
+		Compression: s.config.EnableCompression,
+		TLSEnabled:  s.config.TLSCertFile != "" || s.config.TLSCertificate != nil,
 	}
 
 	s.writeJSON(w, http.StatusOK, config)
@@ -2860,28 +3749,106 @@ func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	json.NewEncoder(w).Encode(v)
 }
 
+// ErrorResponse is the body of errors from NornicDB-specific endpoints.
+// Neo4j-compatible endpoints report errors in TransactionResponse.Errors.
+type ErrorResponse struct {
+	Error   bool   `json:"error"`
+	Message string `json:"message"`
+	Code    int    `json:"code"` // HTTP status
+}
+
 func (s *Server) writeError(w http.ResponseWriter, status int, message string, err error) {
 	s.errorCount.Add(1)
-
-	response := map[string]interface{}{
-		"error":   true,
-		"message": message,
-		"code":    status,
-	}
-
-	s.writeJSON(w, status, response)
+	s.writeJSON(w, status, ErrorResponse{Error: true, Message: message, Code: status})
 }
 
 // Logging helpers
 
 func (s *Server) logRequest(r *http.Request, status int, duration time.Duration) {
 	// Could be enhanced with structured logging
-	fmt.Printf("[HTTP] %s %s %d %v\n", r.Method, r.URL.Path, status, duration)
+	fmt.Printf("[HTTP] [req=%s] %s %s %d %v\n", requestid.FromContext(r.Context()), r.Method, r.URL.Path, status, duration)
+}
+
+// executeCypher runs a statement for an HTTP endpoint and publishes the
+// matching query event to Heimdall plugins, tagged with the request ID.
+// Query rate limits and write backpressure are enforced here; a rejected
+// statement returns a *ratelimit.ThrottleError or *writethrottle.Error
+// (see writeThrottled).
+func (s *Server) executeCypher(r *http.Request, query string, params map[string]interface{}) (*nornicdb.CypherResult, error) {
+	ctx := r.Context()
+
+	// Apply write backpressure before taking a query slot
+	if isMutationQuery(query) {
+		if err := s.config.WriteThrottle.Admit(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	principal := queryPrincipal(r)
+	release, err := s.config.QueryLimiter.Acquire(principal)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx = ratelimit.NewContext(ctx, principal)
+
+	start := time.Now()
+	result, err := s.db.ExecuteCypher(ctx, query, params)
+	var rows int64
+	if result != nil {
+		rows = int64(len(result.Rows))
+		if s.config.QueryLimiter.Enabled() {
+			// Approximate the wire size with the JSON encoding of the rows
+			if encoded, mErr := json.Marshal(result.Rows); mErr == nil {
+				s.config.QueryLimiter.ConsumeResultBytes(principal, int64(len(encoded)))
+			}
+		}
+	}
+	heimdall.EmitQueryEventContext(ctx, "http", query, params, time.Since(start), rows, err)
+	return result, err
+}
+
+// queryPrincipal identifies the caller of an HTTP request for rate limiting.
+func queryPrincipal(r *http.Request) ratelimit.Principal {
+	p := ratelimit.Principal{IP: getClientIP(r)}
+	if claims := getClaims(r); claims != nil {
+		p.User = claims.Username
+		p.Roles = claims.Roles
+	}
+	return p
+}
+
+// writeThrottled writes a 429 response if err is a rate limit or write
+// throttle rejection. Returns false (writing nothing) for any other error.
+func (s *Server) writeThrottled(w http.ResponseWriter, err error) bool {
+	var we *writethrottle.Error
+	if errors.As(err, &we) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(we.RetryAfter.Seconds()))))
+		s.writeNeo4jError(w, http.StatusTooManyRequests, "Neo.TransientError.Request.ResourceExhaustion", we.Error())
+		return true
+	}
+	var te *ratelimit.ThrottleError
+	if !errors.As(err, &te) {
+		return false
+	}
+	retryAfter := int(math.Ceil(te.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	s.writeNeo4jError(w, http.StatusTooManyRequests, "Neo.ClientError.Request.TooManyRequests", te.Error())
+	return true
+}
+
+// statementErrorCode returns the Neo4j status code reported for a failed
+// statement.
+func statementErrorCode(err error) string {
+	return neo4jerr.Code(err, neo4jerr.StatementSyntaxError)
 }
 
 // logSlowQuery logs queries that exceed the configured threshold.
 // Logged info includes: query text (truncated), duration, parameters, error if any.
-func (s *Server) logSlowQuery(query string, params map[string]interface{}, duration time.Duration, err error) {
+func (s *Server) logSlowQuery(ctx context.Context, query string, params map[string]interface{}, duration time.Duration, err error) {
 	if !s.config.SlowQueryEnabled {
 		return
 	}
@@ -2892,8 +3859,11 @@ func (s *Server) logSlowQuery(query string, params map[string]interface{}, durat
 
 	s.slowQueryCount.Add(1)
 
+	// Mask PII before truncating so partial values cannot leak
+	queryLog := s.config.Redactor.Query(query)
+	params = s.config.Redactor.Map(params)
+
 	// Truncate long queries for logging
-	queryLog := query
 	if len(queryLog) > 500 {
 		queryLog = queryLog[:500] + "..."
 	}
@@ -2915,8 +3885,8 @@ func (s *Server) logSlowQuery(query string, params map[string]interface{}, durat
 		}
 	}
 
-	logMsg := fmt.Sprintf("[SLOW QUERY] duration=%v status=%s query=%q params=%s",
-		duration, status, queryLog, paramStr)
+	logMsg := fmt.Sprintf("[SLOW QUERY] req=%s duration=%v status=%s query=%q params=%s",
+		requestid.FromContext(ctx), duration, status, queryLog, paramStr)
 
 	// Log to slow query logger if configured, otherwise to stderr
 	if s.slowQueryLogger != nil {
@@ -2940,6 +3910,208 @@ func (s *Server) logAudit(r *http.Request, userID, eventType string, success boo
 		Success:     success,
 		Reason:      details,
 		RequestPath: r.URL.Path,
+		RequestID:   requestid.FromContext(r.Context()),
+	})
+}
+
+// reportSchemaViolation publishes a property schema violation as a Heimdall
+// event, for the watcher's anomaly rules, and records it in the audit log.
+func (s *Server) reportSchemaViolation(v storage.SchemaViolation) {
+	event := &heimdall.DatabaseEvent{
+		Type:   heimdall.EventSchemaViolation,
+		Source: "storage",
+		Error:  v.String(),
+		Metadata: map[string]interface{}{
+			"label":    v.Label,
+			"property": v.Property,
+			"reason":   v.Reason,
+			"rejected": v.Rejected,
+		},
+	}
+	if v.Kind == "node" {
+		event.NodeID = v.ID
+		event.NodeLabels = []string{v.Label}
+	} else {
+		event.RelationshipID = v.ID
+		event.RelationshipType = v.Label
+	}
+	heimdall.EmitDatabaseEvent(event)
+
+	if s.audit == nil {
+		return
+	}
+	s.audit.Log(audit.Event{
+		Timestamp:  time.Now(),
+		Type:       audit.EventSchemaViolation,
+		Success:    !v.Rejected,
+		Resource:   v.Kind,
+		ResourceID: v.ID,
+		Reason:     v.Reason,
+		Metadata: map[string]string{
+			"label":    v.Label,
+			"property": v.Property,
+		},
+	})
+}
+
+// serveHeimdall passes a request to the Heimdall handler with the
+// authenticated user attached, so Bifrost can address that user directly.
+func (s *Server) serveHeimdall(w http.ResponseWriter, r *http.Request) {
+	if claims := getClaims(r); claims != nil {
+		r = r.WithContext(heimdall.WithUser(r.Context(), claims.Sub))
+	}
+	s.heimdallHandler.ServeHTTP(w, r)
+}
+
+// runLiveQuery evaluates a live query. Live queries are re-run in the
+// background, so only read-only queries are accepted.
+func (s *Server) runLiveQuery(ctx context.Context, query string, params map[string]any) ([]string, [][]any, error) {
+	if isMutationQuery(query) {
+		return nil, nil, fmt.Errorf("%w: live queries must be read-only", livequery.ErrInvalid)
+	}
+	result, err := s.db.ExecuteCypher(ctx, query, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.Columns, result.Rows, nil
+}
+
+// liveQueryUser returns the user live queries of a request belong to, the
+// same user Bifrost records for the caller's connection.
+func liveQueryUser(r *http.Request) string {
+	if claims := getClaims(r); claims != nil {
+		return claims.Sub
+	}
+	return ""
+}
+
+// liveQueryDeliverer sends live query diffs as "live_query" Bifrost events.
+type liveQueryDeliverer struct {
+	bifrost *heimdall.Bifrost
+}
+
+func (d liveQueryDeliverer) Deliver(clientID string, diff *livequery.Diff) error {
+	return d.bifrost.SendData(clientID, "live_query", map[string]interface{}{"live_query": diff})
+}
+
+func (d liveQueryDeliverer) ClientUser(clientID string) (string, bool) {
+	return d.bifrost.ClientUser(clientID)
+}
+
+// logConfirmationDecision persists who approved or rejected a Heimdall action.
+func (s *Server) logConfirmationDecision(req heimdall.ConfirmationRequest, d heimdall.ConfirmationDecision) {
+	if s.audit == nil {
+		return
+	}
+	reason := "rejected"
+	switch {
+	case d.TimedOut && d.Approved:
+		reason = "approved on timeout"
+	case d.TimedOut:
+		reason = "rejected on timeout"
+	case d.Approved:
+		reason = "approved"
+	}
+	userID := d.RejectedBy
+	if d.Approved && len(d.Approvers) > 0 {
+		userID = d.Approvers[len(d.Approvers)-1]
+	}
+	params, _ := json.Marshal(req.Params)
+	diff, _ := json.Marshal(req.Diff)
+	s.audit.Log(audit.Event{
+		Timestamp:  d.DecidedAt,
+		Type:       audit.EventActionApproval,
+		UserID:     userID,
+		Success:    d.Approved,
+		Resource:   "heimdall_action",
+		ResourceID: req.ID,
+		Action:     req.Action,
+		Reason:     reason,
+		Metadata: map[string]string{
+			"requested_by": req.RequestedBy,
+			"approvers":    strings.Join(d.Approvers, ","),
+			"risk":         string(req.Risk),
+			"params":       string(params),
+			"diff":         string(diff),
+		},
+	})
+}
+
+// newHeimdallGuardrails builds the Heimdall response guardrails from the
+// feature flags. It returns nil when no guardrails are configured.
+func newHeimdallGuardrails(f *nornicConfig.FeatureFlagsConfig) (*guardrails.Guardrails, error) {
+	if len(f.HeimdallGuardrails) == 0 && len(f.HeimdallOutputBlocklist) == 0 && !f.HeimdallReadOnly {
+		return nil, nil
+	}
+	rules, err := guardrails.ParseRules(f.HeimdallGuardrails)
+	if err != nil {
+		return nil, err
+	}
+	g, err := guardrails.New(guardrails.Config{Rules: rules, OutputPatterns: f.HeimdallOutputBlocklist})
+	if err != nil {
+		return nil, err
+	}
+	if f.HeimdallReadOnly {
+		g.AddValidator("*", guardrails.ReadOnlyCypher("cypher"))
+	}
+	log.Printf("   → Guardrails: %d rules, %d output patterns, read-only=%v",
+		len(rules), len(f.HeimdallOutputBlocklist), f.HeimdallReadOnly)
+	return g, nil
+}
+
+// newHeimdallWebhooks starts delivering Heimdall alerts to the webhook
+// endpoints listed in path. URLs, secrets, routing keys and header values
+// may be secret references.
+func newHeimdallWebhooks(path string) (*webhook.Sink, error) {
+	endpoints, err := webhook.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	for i := range endpoints {
+		e := &endpoints[i]
+		for _, field := range []*string{&e.URL, &e.Secret, &e.RoutingKey} {
+			if *field, err = secrets.Resolve(ctx, *field); err != nil {
+				return nil, fmt.Errorf("webhook %s: %w", e.Name, err)
+			}
+		}
+		for k, v := range e.Headers {
+			if e.Headers[k], err = secrets.Resolve(ctx, v); err != nil {
+				return nil, fmt.Errorf("webhook %s: header %s: %w", e.Name, k, err)
+			}
+		}
+	}
+	sink, err := webhook.New(webhook.Config{
+		Endpoints: endpoints,
+		OnError: func(endpoint string, a webhook.Alert, err error) {
+			log.Printf("⚠️  Heimdall webhook %s failed to deliver %q: %v", endpoint, a.Title, err)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("   → Webhooks: %d endpoints from %s", len(endpoints), path)
+	return sink, nil
+}
+
+// logGuardrailViolation records a blocked Heimdall action as a security event.
+func (s *Server) logGuardrailViolation(ctx context.Context, v *guardrails.Violation) {
+	reqID := requestid.FromContext(ctx)
+	log.Printf("⛔ Heimdall guardrail blocked %s (stage=%s, rule=%q, request_id=%s)", v.Action, v.Stage, v.Rule, reqID)
+	if s.audit == nil {
+		return
+	}
+	userID := ""
+	if claims, ok := ctx.Value(contextKeyClaims).(*auth.JWTClaims); ok && claims != nil {
+		userID = claims.Sub
+	}
+	s.audit.LogSecurityEvent(audit.EventSecurityAlert, userID, "", v.Error(), map[string]string{
+		"component":  "heimdall",
+		"action":     v.Action,
+		"stage":      v.Stage,
+		"rule":       v.Rule,
+		"param":      v.Param,
+		"request_id": reqID,
 	})
 }
 
@@ -2947,6 +4119,73 @@ func (s *Server) logAudit(r *http.Request, userID, eventType string, success boo
 // Heimdall Database/Metrics Wrappers
 // ==========================================================================
 
+// newHeimdallPrompts builds the prompt registry of Heimdall's autonomous
+// tasks: the built-in versions, versions from the prompts directory, then
+// the configured active versions and experiments. Invalid settings are
+// logged and skipped.
+func newHeimdallPrompts(features *nornicConfig.FeatureFlagsConfig) *prompts.Registry {
+	reg := prompts.NewRegistry()
+	if err := inference.RegisterPrompts(reg); err != nil {
+		log.Printf("⚠️  Heimdall QC prompts: %v", err)
+	}
+	if err := nornicdb.RegisterMemoryPrompts(reg); err != nil {
+		log.Printf("⚠️  Memory consolidation prompts: %v", err)
+	}
+	if features.HeimdallPromptsDir != "" {
+		n, err := reg.LoadDir(features.HeimdallPromptsDir)
+		if err != nil {
+			log.Printf("⚠️  Heimdall prompts from %s: %v", features.HeimdallPromptsDir, err)
+		}
+		log.Printf("   → Prompt templates: %d loaded from %s", n, features.HeimdallPromptsDir)
+	}
+
+	versions, err := prompts.ParseVersions(features.HeimdallPromptVersions)
+	if err != nil {
+		log.Printf("⚠️  Ignoring Heimdall prompt versions: %v", err)
+	}
+	for name, version := range versions {
+		if err := reg.Activate(name, version); err != nil {
+			log.Printf("⚠️  Ignoring Heimdall prompt version: %v", err)
+		} else {
+			log.Printf("   → Prompt %s: %s", name, version)
+		}
+	}
+
+	experiments, err := prompts.ParseExperiments(features.HeimdallPromptExperiments)
+	if err != nil {
+		log.Printf("⚠️  Ignoring Heimdall prompt experiments: %v", err)
+	}
+	for name, exp := range experiments {
+		if err := reg.StartExperiment(name, exp); err != nil {
+			log.Printf("⚠️  Ignoring Heimdall prompt experiment: %v", err)
+		} else {
+			log.Printf("   → Prompt %s: A/B testing %s vs %s (%.0f%% to %s)", name, exp.A, exp.B, exp.Share*100, exp.B)
+		}
+	}
+	return reg
+}
+
+// heimdallTaskEmbedder records embedding requests as Heimdall TaskEmbedding
+// requests, so the model registry reports their latency.
+type heimdallTaskEmbedder struct {
+	embed.Embedder
+	manager *heimdall.Manager
+}
+
+func (e *heimdallTaskEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	start := time.Now()
+	vec, err := e.Embedder.Embed(ctx, text)
+	e.manager.ObserveTask(heimdall.TaskEmbedding, time.Since(start), err)
+	return vec, err
+}
+
+func (e *heimdallTaskEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	start := time.Now()
+	vecs, err := e.Embedder.EmbedBatch(ctx, texts)
+	e.manager.ObserveTask(heimdall.TaskEmbedding, time.Since(start), err)
+	return vecs, err
+}
+
 // heimdallDBReader wraps NornicDB for Heimdall's DatabaseReader interface.
 type heimdallDBReader struct {
 	db *nornicdb.DB
@@ -2973,22 +4212,82 @@ func (r *heimdallDBReader) Query(ctx context.Context, cypher string, params map[
 
 func (r *heimdallDBReader) Stats() heimdall.DatabaseStats {
 	stats := r.db.Stats()
+	var running []heimdall.RunningQuery
+	for _, q := range r.db.RunningQueries() {
+		running = append(running, heimdall.RunningQuery{
+			ID:          q.ID,
+			Query:       q.Query,
+			User:        q.User,
+			ElapsedMs:   q.Elapsed().Milliseconds(),
+			Rows:        q.Rows(),
+			MemoryBytes: q.MemoryBytes(),
+		})
+	}
 	return heimdall.DatabaseStats{
 		NodeCount:         stats.NodeCount,
 		RelationshipCount: stats.EdgeCount,
 		LabelCounts:       make(map[string]int64), // TODO: implement label counts
+		RunningQueries:    running,
+	}
+}
+
+// WriteThrottleSignals reads the write throttle's signals: the WAL sync lag
+// of db, heap usage against the memory limit, and the fullest Heimdall
+// plugin event queue.
+func WriteThrottleSignals(db *nornicdb.DB) writethrottle.SignalSource {
+	return func() writethrottle.Signals {
+		var signals writethrottle.Signals
+		if wal, ok := db.WALStats(); ok {
+			signals.WALSyncLag = wal.SyncLag(time.Now())
+		}
+		signals.HeapBytes, signals.MemoryLimit = writethrottle.MemoryUsage()
+		for _, queue := range heimdall.EventDeliveryStatsAll() {
+			if queue.Capacity > 0 {
+				signals.EventQueueFill = math.Max(signals.EventQueueFill, float64(queue.Queued)/float64(queue.Capacity))
+			}
+		}
+		return signals
 	}
 }
 
 // heimdallMetricsReader provides runtime metrics for Heimdall.
-type heimdallMetricsReader struct{}
+type heimdallMetricsReader struct {
+	db       *nornicdb.DB
+	throttle *writethrottle.Throttle
+}
 
 func (r *heimdallMetricsReader) Runtime() heimdall.RuntimeMetrics {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
-	return heimdall.RuntimeMetrics{
+	metrics := heimdall.RuntimeMetrics{
 		GoroutineCount: runtime.NumGoroutine(),
 		MemoryAllocMB:  m.Alloc / 1024 / 1024,
 		NumGC:          m.NumGC,
 	}
+	if r.throttle.Enabled() {
+		throttle := r.throttle.Stats()
+		metrics.WriteThrottlePressure = throttle.Pressure
+		metrics.WritesDelayed = throttle.Delayed
+		metrics.WritesRejected = throttle.Rejected
+	}
+	if r.db == nil {
+		return metrics
+	}
+
+	if wal, ok := r.db.WALStats(); ok {
+		metrics.WALSyncLagMs = wal.SyncLag(time.Now()).Milliseconds()
+	}
+	if dir := r.db.DataDir(); dir != "" {
+		if free, total, ok := diskspace.Usage(dir); ok {
+			metrics.DiskFreeBytes = free
+			metrics.DiskTotalBytes = total
+		}
+	}
+	cache := r.db.SearchCacheStats()
+	metrics.CacheHits = cache.Hits
+	metrics.CacheMisses = cache.Misses
+	if gpuMgr, ok := r.db.GetGPUManager().(*gpu.Manager); ok && gpuMgr != nil {
+		metrics.GPUErrors = gpuMgr.Stats().FallbackCount
+	}
+	return metrics
 }
@@ -6,18 +6,34 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/orneryd/nornicdb/pkg/audit"
 	"github.com/orneryd/nornicdb/pkg/auth"
+	"github.com/orneryd/nornicdb/pkg/bookmark"
+	nornicConfig "github.com/orneryd/nornicdb/pkg/config"
+	"github.com/orneryd/nornicdb/pkg/cursor"
+	"github.com/orneryd/nornicdb/pkg/cypher"
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+	"github.com/orneryd/nornicdb/pkg/heimdall/webhook"
+	"github.com/orneryd/nornicdb/pkg/idempotency"
+	"github.com/orneryd/nornicdb/pkg/livequery"
 	"github.com/orneryd/nornicdb/pkg/nornicdb"
+	"github.com/orneryd/nornicdb/pkg/ratelimit"
+	"github.com/orneryd/nornicdb/pkg/redact"
+	"github.com/orneryd/nornicdb/pkg/requestid"
+	"github.com/orneryd/nornicdb/pkg/storage"
+	"github.com/orneryd/nornicdb/pkg/writethrottle"
 )
 
 // =============================================================================
@@ -570,6 +586,45 @@ func TestRollbackTransaction(t *testing.T) {
 	}
 }
 
+func TestExplicitTransactionHoldsEvents(t *testing.T) {
+	server, auth := setupTestServer(t)
+	token := getAuthToken(t, auth, "admin")
+
+	open := func() string {
+		resp := makeRequest(t, server, "POST", "/db/neo4j/tx", map[string]interface{}{
+			"statements": []map[string]interface{}{},
+		}, "Bearer "+token)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		parts := strings.Split(result["commit"].(string), "/")
+		txID := parts[len(parts)-2]
+		if _, ok := server.eventTxs.Load(txID); !ok {
+			t.Fatalf("no event transaction for %s", txID)
+		}
+		return txID
+	}
+
+	committed := open()
+	makeRequest(t, server, "POST", fmt.Sprintf("/db/neo4j/tx/%s", committed), map[string]interface{}{
+		"statements": []map[string]interface{}{{"statement": "MATCH (n) RETURN count(n)"}},
+	}, "Bearer "+token)
+	if _, ok := server.eventTxs.Load(committed); !ok {
+		t.Error("event transaction should stay open between statements")
+	}
+	makeRequest(t, server, "POST", fmt.Sprintf("/db/neo4j/tx/%s/commit", committed), map[string]interface{}{
+		"statements": []map[string]interface{}{},
+	}, "Bearer "+token)
+	if _, ok := server.eventTxs.Load(committed); ok {
+		t.Error("event transaction should end on commit")
+	}
+
+	rolledBack := open()
+	makeRequest(t, server, "DELETE", fmt.Sprintf("/db/neo4j/tx/%s", rolledBack), nil, "Bearer "+token)
+	if _, ok := server.eventTxs.Load(rolledBack); ok {
+		t.Error("event transaction should end on rollback")
+	}
+}
+
 // =============================================================================
 // Query Endpoint Tests
 // =============================================================================
@@ -1550,6 +1605,41 @@ func TestDecayGET(t *testing.T) {
 	}
 }
 
+func TestEmbedDriftAndReembed(t *testing.T) {
+	server, auth := setupTestServer(t)
+	token := getAuthToken(t, auth, "admin")
+
+	resp := makeRequest(t, server, "GET", "/nornicdb/embed/drift", nil, "Bearer "+token)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.Code)
+	}
+	var report map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid drift report: %v", err)
+	}
+	if report["drifted"] != false {
+		t.Errorf("expected no drift in an empty database, got %v", report)
+	}
+
+	resp = makeRequest(t, server, "GET", "/nornicdb/embed/reembed", nil, "Bearer "+token)
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "idle") {
+		t.Errorf("expected idle job, got %d %s", resp.Code, resp.Body.String())
+	}
+
+	resp = makeRequest(t, server, "POST", "/nornicdb/embed/reembed?delay=bogus", nil, "Bearer "+token)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", resp.Code)
+	}
+	resp = makeRequest(t, server, "POST", "/nornicdb/embed/reembed", nil, "Bearer "+token)
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 without an embedder, got %d", resp.Code)
+	}
+	resp = makeRequest(t, server, "PUT", "/nornicdb/embed/reembed", nil, "Bearer "+token)
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", resp.Code)
+	}
+}
+
 func TestGDPRExportMethodNotAllowed(t *testing.T) {
 	server, auth := setupTestServer(t)
 	token := getAuthToken(t, auth, "admin")
@@ -2062,6 +2152,266 @@ func TestMetricsAfterRequests(t *testing.T) {
 	}
 }
 
+func TestRequestIDHeader(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	// Generated when the client does not send one
+	resp := makeRequest(t, server, "GET", "/health", nil, "")
+	if resp.Header().Get("X-Request-ID") == "" {
+		t.Error("expected X-Request-ID response header")
+	}
+
+	// Client-supplied ID is echoed back
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("X-Request-ID", "client-req-1")
+	recorder := httptest.NewRecorder()
+	server.buildRouter().ServeHTTP(recorder, req)
+	if got := recorder.Header().Get("X-Request-ID"); got != "client-req-1" {
+		t.Errorf("expected echoed request ID client-req-1, got %q", got)
+	}
+}
+
+func TestQueryRateLimit(t *testing.T) {
+	server, auth := setupTestServer(t)
+	token := getAuthToken(t, auth, "admin")
+	server.config.QueryLimiter = ratelimit.New(ratelimit.Config{
+		Enabled: true,
+		Users:   map[string]ratelimit.Limits{"admin": {QueriesPerSecond: 1, Burst: 1}},
+	})
+
+	body := map[string]interface{}{
+		"statements": []map[string]interface{}{{"statement": "RETURN 1 AS n"}},
+	}
+	resp := makeRequest(t, server, "POST", "/db/neo4j/tx/commit", body, "Bearer "+token)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected first query to succeed, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = makeRequest(t, server, "POST", "/db/neo4j/tx/commit", body, "Bearer "+token)
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	if !strings.Contains(resp.Body.String(), "user:admin") {
+		t.Errorf("throttle error should name the caller, got %s", resp.Body.String())
+	}
+
+	metrics := makeRequest(t, server, "GET", "/metrics", nil, "Bearer "+token)
+	if !strings.Contains(metrics.Body.String(), `nornicdb_rate_limit_throttled_total{limit="queries_per_second"} 1`) {
+		t.Errorf("expected throttle metric, got:\n%s", metrics.Body.String())
+	}
+}
+
+func TestWriteThrottle(t *testing.T) {
+	server, auth := setupTestServer(t)
+	token := getAuthToken(t, auth, "admin")
+	server.config.WriteThrottle = writethrottle.New(writethrottle.DefaultConfig(), func() writethrottle.Signals {
+		return writethrottle.Signals{EventQueueFill: 1}
+	})
+
+	statement := func(query string) map[string]interface{} {
+		return map[string]interface{}{"statements": []map[string]interface{}{{"statement": query}}}
+	}
+	resp := makeRequest(t, server, "POST", "/db/neo4j/tx/commit", statement("RETURN 1 AS n"), "Bearer "+token)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected reads to pass, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = makeRequest(t, server, "POST", "/db/neo4j/tx/commit", statement("CREATE (n:Throttled) RETURN n"), "Bearer "+token)
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	if !strings.Contains(resp.Body.String(), "Neo.TransientError.Request.ResourceExhaustion") {
+		t.Errorf("expected a transient error code, got %s", resp.Body.String())
+	}
+
+	metrics := makeRequest(t, server, "GET", "/metrics", nil, "Bearer "+token)
+	if !strings.Contains(metrics.Body.String(), `nornicdb_write_throttle_writes_total{outcome="rejected"} 1`) {
+		t.Errorf("expected write throttle metric, got:\n%s", metrics.Body.String())
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	server, auth := setupTestServer(t)
+	token := getAuthToken(t, auth, "admin")
+	server.config.Idempotency = idempotency.New(time.Minute, 0)
+
+	post := func(statement, key string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"statements": []map[string]interface{}{{"statement": statement}},
+		})
+		req := httptest.NewRequest("POST", "/db/neo4j/tx/commit", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if key != "" {
+			req.Header.Set(idempotency.HeaderName, key)
+		}
+		recorder := httptest.NewRecorder()
+		server.buildRouter().ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	persisted := func() *idempotency.Store {
+		store := idempotency.New(time.Minute, 0)
+		if err := server.db.PersistIdempotencyKeys(store); err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}
+	server.config.Idempotency = persisted()
+
+	create := "CREATE (n:IdemTest {v: 1}) RETURN n.v"
+	var bodies []string
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			server.config.Idempotency = persisted() // A restart keeps the record
+		}
+		resp := post(create, "retry-1")
+		if resp.Code != http.StatusOK && resp.Code != http.StatusAccepted {
+			t.Fatalf("attempt %d: unexpected status %d: %s", i+1, resp.Code, resp.Body.String())
+		}
+		if replayed := resp.Header().Get("Idempotent-Replayed") == "true"; replayed != (i > 0) {
+			t.Errorf("attempt %d: Idempotent-Replayed = %v", i+1, replayed)
+		}
+		bodies = append(bodies, resp.Body.String())
+	}
+	if bodies[2] != bodies[0] {
+		t.Errorf("replay after restart = %s, want %s", bodies[2], bodies[0])
+	}
+
+	resp := post("MATCH (n:IdemTest) RETURN count(n) AS c", "")
+	if !strings.Contains(resp.Body.String(), `"row":[1]`) {
+		t.Errorf("retried write should be applied once, got %s", resp.Body.String())
+	}
+
+	resp = post("CREATE (n:IdemTest {v: 2})", "retry-1")
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a key reused with another statement, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestTransactionBookmarks(t *testing.T) {
+	server, auth := setupTestServer(t)
+	token := getAuthToken(t, auth, "admin")
+	leader := bookmark.New(0)
+	server.bookmarks = bookmark.New(20 * time.Millisecond)
+	server.bookmarks.Advance(leader.Epoch(), 0)
+
+	post := func(statement string, bookmarks ...string) TransactionResponse {
+		body, _ := json.Marshal(map[string]interface{}{
+			"statements": []map[string]interface{}{{"statement": statement}},
+			"bookmarks":  bookmarks,
+		})
+		req := httptest.NewRequest("POST", "/db/neo4j/tx/commit", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		server.buildRouter().ServeHTTP(recorder, req)
+		var resp TransactionResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding response %q: %v", recorder.Body.String(), err)
+		}
+		return resp
+	}
+
+	read := post("MATCH (n) RETURN count(n)")
+	write := post("CREATE (n:BookmarkTest) RETURN n")
+	if len(read.LastBookmarks) != 1 || len(write.LastBookmarks) != 1 {
+		t.Fatalf("expected one bookmark per response, got %v and %v", read.LastBookmarks, write.LastBookmarks)
+	}
+	if read.LastBookmarks[0] == write.LastBookmarks[0] {
+		t.Errorf("a write should commit a new bookmark, got %q twice", read.LastBookmarks[0])
+	}
+	if got := post("MATCH (n) RETURN count(n)", write.LastBookmarks...).LastBookmarks; len(got) != 1 || got[0] != write.LastBookmarks[0] {
+		t.Errorf("a read should return the last commit %q, got %v", write.LastBookmarks[0], got)
+	}
+
+	// A bookmark the server has not applied yet times out; one it did not
+	// issue is invalid
+	leader.Commit()
+	for b, code := range map[string]string{
+		leader.Commit(): "Neo.TransientError.Transaction.BookmarkTimeout",
+		"FB:nornicdb:1": "Neo.ClientError.Transaction.InvalidBookmark",
+	} {
+		resp := post("MATCH (n) RETURN count(n)", b)
+		if len(resp.Errors) != 1 || resp.Errors[0].Code != code {
+			t.Errorf("bookmark %q: expected %s, got %+v", b, code, resp.Errors)
+		}
+	}
+}
+
+func TestResultCursor(t *testing.T) {
+	server, auth := setupTestServer(t)
+	adminToken := getAuthToken(t, auth, "admin")
+	readerToken := getAuthToken(t, auth, "reader")
+	server.config.Cursors = cursor.New(time.Minute, 0)
+
+	do := func(method, path, token string, body interface{}) (*httptest.ResponseRecorder, TransactionResponse) {
+		var reader io.Reader
+		if body != nil {
+			encoded, _ := json.Marshal(body)
+			reader = bytes.NewReader(encoded)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		server.buildRouter().ServeHTTP(recorder, req)
+		var response TransactionResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		return recorder, response
+	}
+
+	resp, body := do("POST", "/db/neo4j/tx/commit", adminToken, map[string]interface{}{
+		"statements": []map[string]interface{}{{"statement": "UNWIND range(1, 5) AS i RETURN i", "pageSize": 2}},
+	})
+	if resp.Code != http.StatusOK || len(body.Results) != 1 {
+		t.Fatalf("unexpected response %d: %s", resp.Code, resp.Body.String())
+	}
+	first := body.Results[0]
+	if len(first.Data) != 2 || first.Cursor == "" {
+		t.Fatalf("expected 2 rows and a cursor, got %s", resp.Body.String())
+	}
+	path := "/db/neo4j/cursor/" + first.Cursor
+
+	if resp, _ := do("GET", path, readerToken, nil); resp.Code != http.StatusNotFound {
+		t.Errorf("another user's cursor should not be found, got %d", resp.Code)
+	}
+
+	resp, body = do("GET", path+"?pageSize=2", adminToken, nil)
+	if resp.Code != http.StatusOK || len(body.Results) != 1 || len(body.Results[0].Data) != 2 || body.Results[0].Cursor != first.Cursor {
+		t.Fatalf("unexpected second page %d: %s", resp.Code, resp.Body.String())
+	}
+	if got := body.Results[0].Data[0].Row[0]; got != float64(3) {
+		t.Errorf("second page should start at 3, got %v", got)
+	}
+
+	resp, body = do("GET", path, adminToken, nil)
+	if resp.Code != http.StatusOK || len(body.Results[0].Data) != 1 || body.Results[0].Cursor != "" {
+		t.Fatalf("unexpected last page %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp, _ := do("GET", path, adminToken, nil); resp.Code != http.StatusNotFound {
+		t.Errorf("exhausted cursor should not be found, got %d", resp.Code)
+	}
+
+	// Closing early
+	_, body = do("POST", "/db/neo4j/tx/commit", adminToken, map[string]interface{}{
+		"statements": []map[string]interface{}{{"statement": "UNWIND range(1, 5) AS i RETURN i", "pageSize": 1}},
+	})
+	path = "/db/neo4j/cursor/" + body.Results[0].Cursor
+	if resp, _ := do("DELETE", path, adminToken, nil); resp.Code != http.StatusOK {
+		t.Errorf("closing cursor: %d %s", resp.Code, resp.Body.String())
+	}
+	if resp, _ := do("GET", path, adminToken, nil); resp.Code != http.StatusNotFound {
+		t.Errorf("closed cursor should not be found, got %d", resp.Code)
+	}
+}
+
 func TestServerStopWithoutStart(t *testing.T) {
 	server, _ := setupTestServer(t)
 
@@ -2493,3 +2843,378 @@ func TestMetricsEndpointWithAuth(t *testing.T) {
 		t.Errorf("expected status 200 for /metrics with auth, got %d", resp.Code)
 	}
 }
+
+func TestStorageQuotaExceeded(t *testing.T) {
+	server, auth := setupTestServer(t)
+	token := "Bearer " + getAuthToken(t, auth, "admin")
+	if err := server.db.SetStorageQuota(storage.Quota{MaxNodesPerLabel: map[string]int64{"Log": 1}}); err != nil {
+		t.Fatalf("SetStorageQuota: %v", err)
+	}
+
+	create := map[string]interface{}{
+		"statements": []map[string]interface{}{{"statement": "CREATE (:Log {n: 1})"}},
+	}
+	var body TransactionResponse
+	makeRequest(t, server, "POST", "/db/neo4j/tx/commit", create, token)
+	resp := makeRequest(t, server, "POST", "/db/neo4j/tx/commit", create, token)
+	json.NewDecoder(resp.Body).Decode(&body)
+	if len(body.Errors) != 1 || body.Errors[0].Code != "Neo.ClientError.Database.QuotaExceeded" {
+		t.Fatalf("expected a QuotaExceeded error, got %+v", body.Errors)
+	}
+
+	metrics := makeRequest(t, server, "GET", "/metrics", nil, token).Body.String()
+	for _, want := range []string{
+		`nornicdb_storage_quota_limit{resource="label",label="log"} 1`,
+		`nornicdb_storage_quota_used{resource="label",label="log"} 1`,
+		`nornicdb_storage_quota_rejections_total{resource="label",label="log"} 1`,
+		`nornicdb_storage_quota_limit{resource="nodes"} 0`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
+
+func TestPropertySchemaViolation(t *testing.T) {
+	server, auth := setupTestServer(t)
+	token := "Bearer " + getAuthToken(t, auth, "admin")
+	var buf bytes.Buffer
+	server.SetAuditLogger(audit.NewLoggerWithWriter(&buf, audit.Config{Enabled: true}))
+
+	schema := server.db.PropertySchema()
+	schema.DefineLabel("Person", storage.EntitySchema{
+		Properties: map[string]storage.PropertyDefinition{"age": {Type: storage.PropertyTypeInteger}},
+	})
+	create := map[string]interface{}{
+		"statements": []map[string]interface{}{{"statement": "CREATE (:Person {age: 'old'})"}},
+	}
+
+	// Warn mode stores the write and audits the violation
+	schema.SetMode(storage.SchemaModeWarn)
+	var body TransactionResponse
+	json.NewDecoder(makeRequest(t, server, "POST", "/db/neo4j/tx/commit", create, token).Body).Decode(&body)
+	if len(body.Errors) != 0 {
+		t.Fatalf("warn mode should not fail the write: %+v", body.Errors)
+	}
+	if out := buf.String(); !strings.Contains(out, string(audit.EventSchemaViolation)) || !strings.Contains(out, "expected INTEGER") {
+		t.Errorf("audit log missing the violation: %s", out)
+	}
+
+	// Strict mode rejects it
+	schema.SetMode(storage.SchemaModeStrict)
+	body = TransactionResponse{}
+	json.NewDecoder(makeRequest(t, server, "POST", "/db/neo4j/tx/commit", create, token).Body).Decode(&body)
+	if len(body.Errors) != 1 || body.Errors[0].Code != "Neo.ClientError.Schema.ConstraintValidationFailed" {
+		t.Fatalf("expected a ConstraintValidationFailed error, got %+v", body.Errors)
+	}
+}
+
+func TestStatementErrorCode_QueryPanic(t *testing.T) {
+	exec := cypher.NewStorageExecutor(storage.NewMemoryEngine())
+	exec.SetNodeCreatedCallback(func(nodeID string) {
+		panic("callback exploded")
+	})
+	_, err := exec.Execute(context.Background(), "CREATE (n:Person)", nil)
+	if err == nil {
+		t.Fatal("expected the panic to be returned as an error")
+	}
+
+	// A recovered panic is a database error, not the client's syntax error
+	if code := statementErrorCode(err); code != "Neo.DatabaseError.General.UnknownError" {
+		t.Errorf("expected UnknownError for a query panic, got %s", code)
+	}
+}
+
+func TestRetryableStatement(t *testing.T) {
+	r := httptest.NewRequest("POST", "/db/neo4j/tx/commit", nil)
+	if got := retryableRequest(r, StatementRequest{Statement: "CREATE (n)"}); got != r {
+		t.Error("statements not flagged retryable should keep the request")
+	}
+	got := retryableRequest(r, StatementRequest{Statement: "CREATE (n)", Retryable: true})
+	if !cypher.QueryOptionsFromContext(got.Context()).Retryable {
+		t.Error("expected the retryable query option in the request context")
+	}
+
+	if code := statementErrorCode(fmt.Errorf("commit failed: %w", storage.ErrConflict)); code != "Neo.TransientError.Transaction.Outdated" {
+		t.Errorf("expected a transient error code for conflicts, got %s", code)
+	}
+
+	server, auth := setupTestServer(t)
+	server.db.SetWriteRetryPolicy(cypher.RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond})
+	resp := makeRequest(t, server, "POST", "/db/neo4j/tx/commit", map[string]interface{}{
+		"statements": []map[string]interface{}{{"statement": "CREATE (n:Retry) RETURN count(n)", "retryable": true, "includeStats": true}},
+	}, "Bearer "+getAuthToken(t, auth, "admin"))
+	var body TransactionResponse
+	json.NewDecoder(resp.Body).Decode(&body)
+	if len(body.Errors) != 0 || len(body.Results) != 1 {
+		t.Fatalf("expected one result, got %+v", body)
+	}
+	if stats := body.Results[0].Stats; stats == nil || stats.Retries != 0 {
+		t.Errorf("expected stats without retries, got %+v", stats)
+	}
+}
+
+func TestStoredQueryForbidden(t *testing.T) {
+	server, auth := setupTestServer(t)
+	post := func(user, statement string) TransactionResponse {
+		resp := makeRequest(t, server, "POST", "/db/neo4j/tx/commit", map[string]interface{}{
+			"statements": []map[string]interface{}{{"statement": statement}},
+		}, "Bearer "+getAuthToken(t, auth, user))
+		var body TransactionResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		return body
+	}
+
+	if body := post("admin", "CALL db.query.save('mark', 'MATCH (n:Acct) SET n.marked = true')"); len(body.Errors) != 0 {
+		t.Fatalf("save failed: %+v", body.Errors)
+	}
+	body := post("reader", "CALL db.query.run('mark', {})")
+	if len(body.Errors) != 1 || body.Errors[0].Code != "Neo.ClientError.Security.Forbidden" {
+		t.Fatalf("expected a forbidden error for a reader running a write query, got %+v", body.Errors)
+	}
+}
+
+func TestResultFormats(t *testing.T) {
+	server, auth := setupTestServer(t)
+	token := "Bearer " + getAuthToken(t, auth, "admin")
+
+	post := func(accept string, statements ...string) *httptest.ResponseRecorder {
+		var list []map[string]interface{}
+		for _, stmt := range statements {
+			list = append(list, map[string]interface{}{"statement": stmt})
+		}
+		body, _ := json.Marshal(map[string]interface{}{"statements": list})
+		req := httptest.NewRequest("POST", "/db/neo4j/tx/commit", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Accept", accept)
+		recorder := httptest.NewRecorder()
+		server.buildRouter().ServeHTTP(recorder, req)
+		return recorder
+	}
+	for i := 1; i <= 2; i++ {
+		post("", fmt.Sprintf(`CREATE (:Fmt {i: %d, text: 'say "hi", %d', tags: ['t%d'], formula: '=1+1'})`, i, i, i))
+	}
+	query := "MATCH (n:Fmt) RETURN n.i AS i, n.text AS text, n.missing AS nothing, n.tags AS list, n.formula AS formula ORDER BY i"
+
+	t.Run("ndjson", func(t *testing.T) {
+		resp := post("application/x-ndjson", query, "RETURN 1 AS one")
+		if ct := resp.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Fatalf("expected application/x-ndjson, got %q", ct)
+		}
+		lines := strings.Split(strings.TrimSuffix(resp.Body.String(), "\n"), "\n")
+		want := []string{
+			`{"header":{"fields":["i","text","nothing","list","formula"]}}`,
+			`{"data":[1,"say \"hi\", 1",null,["t1"],"=1+1"]}`,
+			`{"data":[2,"say \"hi\", 2",null,["t2"],"=1+1"]}`,
+			`{"summary":{}}`,
+			`{"header":{"fields":["one"]}}`,
+			`{"data":[1]}`,
+			`{"summary":{}}`,
+		}
+		if len(lines) != len(want)+1 {
+			t.Fatalf("expected %d lines, got %d:\n%s", len(want)+1, len(lines), resp.Body.String())
+		}
+		for i, line := range want {
+			if lines[i] != line {
+				t.Errorf("line %d: expected %s, got %s", i, line, lines[i])
+			}
+		}
+		if !strings.HasPrefix(lines[len(want)], `{"info":{"lastBookmarks":[`) {
+			t.Errorf("expected a final info line, got %s", lines[len(want)])
+		}
+
+		resp = post("application/jsonl", "RETURN 1 AS one", "MATCH (n RETURN n")
+		if !strings.Contains(resp.Body.String(), `{"error":{"errors":[{"code":"Neo.ClientError.Statement.SyntaxError"`) {
+			t.Errorf("expected an error line, got %s", resp.Body.String())
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		resp := post("text/csv", query, "RETURN 1 AS one")
+		if ct := resp.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+			t.Fatalf("expected text/csv, got %q", ct)
+		}
+		want := "i,text,nothing,list,formula\r\n" +
+			`1,"say ""hi"", 1",,"[""t1""]",'=1+1` + "\r\n" +
+			`2,"say ""hi"", 2",,"[""t2""]",'=1+1` + "\r\n" +
+			"\r\n" +
+			"one\r\n1\r\n"
+		if got := resp.Body.String(); got != want {
+			t.Errorf("expected\n%q\ngot\n%q", want, got)
+		}
+
+		resp = post("text/csv; header=absent", "RETURN 1 AS one")
+		if got := resp.Body.String(); got != "1\r\n" {
+			t.Errorf("expected no header row, got %q", got)
+		}
+
+		// Errors cannot be expressed in CSV
+		resp = post("text/csv", "MATCH (n RETURN n")
+		if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected errors as application/json, got %q", ct)
+		}
+	})
+
+	t.Run("negotiation", func(t *testing.T) {
+		resp := post("text/csv;q=0.5, application/json")
+		if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected the preferred application/json, got %q", ct)
+		}
+		resp = post("*/*", "RETURN 1 AS one")
+		if !strings.Contains(resp.Body.String(), `"row":[1]`) {
+			t.Errorf("expected JSON for */*, got %s", resp.Body.String())
+		}
+		resp = post("application/xml", "CREATE (:NotAcceptable)")
+		if resp.Code != http.StatusNotAcceptable {
+			t.Fatalf("expected 406, got %d", resp.Code)
+		}
+		count := post("", "MATCH (n:NotAcceptable) RETURN count(n)")
+		if !strings.Contains(count.Body.String(), `"row":[0]`) {
+			t.Errorf("statements must not run when the format is not acceptable, got %s", count.Body.String())
+		}
+	})
+}
+
+func TestSlowQueryLogRedaction(t *testing.T) {
+	server, _ := setupTestServer(t)
+	var buf bytes.Buffer
+	server.slowQueryLogger = log.New(&buf, "", 0)
+	server.config.SlowQueryEnabled = true
+	server.config.SlowQueryThreshold = 0
+	redactor, err := redact.New(redact.Config{Enabled: true, Properties: []string{"ssn"}, Patterns: []string{"email"}})
+	if err != nil {
+		t.Fatalf("redact.New: %v", err)
+	}
+	server.config.Redactor = redactor
+
+	server.logSlowQuery(context.Background(),
+		"CREATE (:Person {ssn: '123-45-6789', email: 'ann@example.com'})",
+		map[string]interface{}{"ssn": "987-65-4321", "name": "Ann"},
+		time.Second, nil)
+
+	out := buf.String()
+	for _, leaked := range []string{"123-45-6789", "987-65-4321", "ann@example.com"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("slow query log leaked %q: %s", leaked, out)
+		}
+	}
+	if !strings.Contains(out, "Ann") {
+		t.Errorf("non-sensitive values should be kept: %s", out)
+	}
+}
+
+func TestHeimdallGuardrailSecurityEvent(t *testing.T) {
+	server, _ := setupTestServer(t)
+	var buf bytes.Buffer
+	server.SetAuditLogger(audit.NewLoggerWithWriter(&buf, audit.Config{Enabled: true}))
+
+	g, err := newHeimdallGuardrails(&nornicConfig.FeatureFlagsConfig{
+		HeimdallGuardrails: []string{`*:cypher=\bDETACH\s+DELETE\b`},
+	})
+	if err != nil {
+		t.Fatalf("newHeimdallGuardrails: %v", err)
+	}
+	g.OnBlock(server.logGuardrailViolation)
+
+	ctx := context.WithValue(requestid.NewContext(context.Background(), "req-42"), contextKeyClaims, &auth.JWTClaims{Sub: "alice"})
+	if v := g.Check(ctx, "", "heimdall.watcher.query", map[string]interface{}{"cypher": "MATCH (n) DETACH DELETE n"}); v == nil {
+		t.Fatal("expected DETACH DELETE to be blocked")
+	}
+
+	out := buf.String()
+	for _, want := range []string{string(audit.EventSecurityAlert), "alice", "heimdall.watcher.query", "req-42"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("security event missing %q: %s", want, out)
+		}
+	}
+
+	if g, _ := newHeimdallGuardrails(&nornicConfig.FeatureFlagsConfig{}); g != nil {
+		t.Error("no guardrails should be built without configuration")
+	}
+	if _, err := newHeimdallGuardrails(&nornicConfig.FeatureFlagsConfig{HeimdallOutputBlocklist: []string{"("}}); err == nil {
+		t.Error("invalid output pattern should fail")
+	}
+}
+
+func TestHeimdallWebhooks(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer hook.Close()
+
+	t.Setenv("TEST_WEBHOOK_URL", hook.URL)
+	t.Setenv("TEST_WEBHOOK_TOKEN", "Bearer t0ken")
+	path := filepath.Join(t.TempDir(), "webhooks.yaml")
+	config := "endpoints:\n  - name: ops\n    url: env:TEST_WEBHOOK_URL\n    headers: {Authorization: env:TEST_WEBHOOK_TOKEN}\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := newHeimdallWebhooks(path)
+	if err != nil {
+		t.Fatalf("newHeimdallWebhooks: %v", err)
+	}
+	defer sink.Close()
+	webhook.Bridge(nil, sink).SendNotification("error", "Health: disk_space", "2% free")
+
+	select {
+	case r := <-received:
+		if got := r.Header.Get("Authorization"); got != "Bearer t0ken" {
+			t.Errorf("Authorization = %q, want the resolved secret", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert was not delivered")
+	}
+
+	t.Setenv("TEST_WEBHOOK_URL", "")
+	if _, err := newHeimdallWebhooks(path); err == nil {
+		t.Error("an unresolvable URL should fail")
+	}
+}
+
+func TestHeimdallConfirmationAudit(t *testing.T) {
+	server, _ := setupTestServer(t)
+	var buf bytes.Buffer
+	server.SetAuditLogger(audit.NewLoggerWithWriter(&buf, audit.Config{Enabled: true}))
+
+	server.logConfirmationDecision(heimdall.ConfirmationRequest{
+		ID:          "conf-1",
+		Action:      "heimdall.test.wipe",
+		Params:      map[string]interface{}{"label": "Temp"},
+		Risk:        heimdall.RiskHigh,
+		RequestedBy: "alice",
+	}, heimdall.ConfirmationDecision{
+		ID:        "conf-1",
+		Approved:  true,
+		Approvers: []string{"alice", "carol"},
+		DecidedAt: time.Now(),
+	})
+
+	out := buf.String()
+	for _, want := range []string{string(audit.EventActionApproval), "heimdall.test.wipe", "conf-1", "alice,carol", `"risk":"high"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("approval audit missing %q: %s", want, out)
+		}
+	}
+}
+
+func TestRunLiveQuery(t *testing.T) {
+	server, _ := setupTestServer(t)
+	ctx := context.Background()
+
+	if _, _, err := server.runLiveQuery(ctx, "CREATE (n:Order {id: 1})", nil); !errors.Is(err, livequery.ErrInvalid) {
+		t.Fatalf("a write should be rejected, got %v", err)
+	}
+	if _, err := server.db.ExecuteCypher(ctx, "CREATE (n:Order {id: 1})", nil); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	columns, rows, err := server.runLiveQuery(ctx, "MATCH (n:Order) RETURN n.id AS id", nil)
+	if err != nil {
+		t.Fatalf("runLiveQuery: %v", err)
+	}
+	if len(columns) != 1 || columns[0] != "id" || len(rows) != 1 {
+		t.Errorf("got columns %v rows %v", columns, rows)
+	}
+}
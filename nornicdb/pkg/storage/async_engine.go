@@ -12,7 +12,9 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"sync"
 	"time"
@@ -34,6 +36,10 @@ type AsyncEngine struct {
 	// Label index for fast lookups - maps normalized label to node IDs
 	labelIndex map[string]map[NodeID]bool
 
+	// Storage quota admitted for cached creates, settled on flush
+	nodeQuota map[NodeID]*quotaDelta
+	edgeQuota map[EdgeID]*quotaDelta
+
 	// Background flush
 	flushInterval time.Duration
 	flushTicker   *time.Ticker
@@ -80,6 +86,8 @@ func NewAsyncEngine(engine Engine, config *AsyncEngineConfig) *AsyncEngine {
 		deleteNodes:   make(map[NodeID]bool),
 		deleteEdges:   make(map[EdgeID]bool),
 		labelIndex:    make(map[string]map[NodeID]bool),
+		nodeQuota:     make(map[NodeID]*quotaDelta),
+		edgeQuota:     make(map[EdgeID]*quotaDelta),
 		flushInterval: config.FlushInterval,
 		stopChan:      make(chan struct{}),
 	}
@@ -119,18 +127,22 @@ type FlushResult struct {
 	DeletesFailed int
 	FailedNodeIDs []NodeID // IDs that failed - still in cache for retry
 	FailedEdgeIDs []EdgeID // IDs that failed - still in cache for retry
+	NodesDropped  int      // Rejected by the storage quota or schema - removed from cache
+	EdgesDropped  int      // Rejected by the storage quota or schema - removed from cache
 }
 
 // HasErrors returns true if any flush operations failed.
 func (r FlushResult) HasErrors() bool {
-	return r.NodesFailed > 0 || r.EdgesFailed > 0 || r.DeletesFailed > 0
+	return r.NodesFailed > 0 || r.EdgesFailed > 0 || r.DeletesFailed > 0 || r.NodesDropped > 0 || r.EdgesDropped > 0
 }
 
 // Flush writes all pending changes to the underlying engine.
 // Uses batched operations for better performance - all deletes in one transaction.
 //
 // CRITICAL FIX: Failed items are NOT removed from cache - they will be retried
-// on the next flush. This prevents silent data loss.
+// on the next flush. This prevents silent data loss. The exception is writes
+// rejected by the storage quota or the property schema, which would fail
+// forever: they are dropped and logged.
 //
 // Design: Snapshot caches, clear them, UNLOCK, then write to engine.
 // Reads during write see engine data (consistent since cache is empty).
@@ -138,12 +150,17 @@ func (r FlushResult) HasErrors() bool {
 func (ae *AsyncEngine) Flush() error {
 	result := ae.FlushWithResult()
 	if result.HasErrors() {
-		return fmt.Errorf("flush incomplete: %d nodes failed, %d edges failed, %d deletes failed",
-			result.NodesFailed, result.EdgesFailed, result.DeletesFailed)
+		return fmt.Errorf("flush incomplete: %d nodes failed, %d edges failed, %d deletes failed, %d writes dropped",
+			result.NodesFailed, result.EdgesFailed, result.DeletesFailed, result.NodesDropped+result.EdgesDropped)
 	}
 	return nil
 }
 
+// isRejectedWrite reports whether a flush error can never succeed on retry.
+func isRejectedWrite(err error) bool {
+	return errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrSchemaViolation)
+}
+
 // FlushWithResult writes pending changes and returns detailed results.
 // Use this for programmatic access to flush statistics.
 func (ae *AsyncEngine) FlushWithResult() FlushResult {
@@ -187,6 +204,8 @@ func (ae *AsyncEngine) FlushWithResult() FlushResult {
 	successfulEdgeWrites := make(map[EdgeID]bool)
 	successfulNodeDeletes := make(map[NodeID]bool)
 	successfulEdgeDeletes := make(map[EdgeID]bool)
+	droppedNodes := make(map[NodeID]bool)
+	droppedEdges := make(map[EdgeID]bool)
 
 	// Apply bulk deletes first
 	if len(nodesToDelete) > 0 {
@@ -241,7 +260,11 @@ func (ae *AsyncEngine) FlushWithResult() FlushResult {
 		for _, node := range nodesToWrite {
 			if !nodesToDelete[node.ID] {
 				// UpdateNode now has upsert behavior - creates if not exists, updates if exists
-				if err := ae.engine.UpdateNode(node); err != nil {
+				if err := ae.engine.UpdateNode(node); isRejectedWrite(err) {
+					log.Printf("⚠️ AsyncEngine: dropping write of node %s: %v", node.ID, err)
+					droppedNodes[node.ID] = true
+					result.NodesDropped++
+				} else if err != nil {
 					// CRITICAL FIX: Track failed node - DON'T remove from cache
 					result.NodesFailed++
 					result.FailedNodeIDs = append(result.FailedNodeIDs, node.ID)
@@ -266,7 +289,15 @@ func (ae *AsyncEngine) FlushWithResult() FlushResult {
 				for _, edge := range edges {
 					if err := ae.engine.CreateEdge(edge); err != nil {
 						// Try update if create fails (might already exist)
-						if err := ae.engine.UpdateEdge(edge); err != nil {
+						if isRejectedWrite(err) {
+							log.Printf("⚠️ AsyncEngine: dropping write of edge %s: %v", edge.ID, err)
+							droppedEdges[edge.ID] = true
+							result.EdgesDropped++
+						} else if err := ae.engine.UpdateEdge(edge); isRejectedWrite(err) {
+							log.Printf("⚠️ AsyncEngine: dropping write of edge %s: %v", edge.ID, err)
+							droppedEdges[edge.ID] = true
+							result.EdgesDropped++
+						} else if err != nil {
 							result.EdgesFailed++
 							result.FailedEdgeIDs = append(result.FailedEdgeIDs, edge.ID)
 						} else {
@@ -289,15 +320,20 @@ func (ae *AsyncEngine) FlushWithResult() FlushResult {
 
 	// CRITICAL FIX: Only clear SUCCESSFULLY flushed items
 	ae.mu.Lock()
-	for id := range nodesToWrite {
+	for id, node := range nodesToWrite {
 		// Only clear if successfully written AND still the same object in cache
-		if successfulNodeWrites[id] && ae.nodeCache[id] == nodesToWrite[id] {
+		if (successfulNodeWrites[id] || droppedNodes[id]) && ae.nodeCache[id] == node {
 			delete(ae.nodeCache, id)
+			ae.settleQuota(id, "")
+		}
+		if droppedNodes[id] && ae.nodeCache[id] == nil {
+			ae.unindexLabels(node)
 		}
 	}
 	for id := range edgesToWrite {
-		if successfulEdgeWrites[id] && ae.edgeCache[id] == edgesToWrite[id] {
+		if (successfulEdgeWrites[id] || droppedEdges[id]) && ae.edgeCache[id] == edgesToWrite[id] {
 			delete(ae.edgeCache, id)
+			ae.settleQuota("", id)
 		}
 	}
 	for id := range nodesToDelete {
@@ -326,6 +362,14 @@ func (ae *AsyncEngine) CreateNode(node *Node) error {
 	ae.mu.Lock()
 	defer ae.mu.Unlock()
 
+	ae.applyDefaults([]*Node{node}, nil)
+	if err := ae.admitSchema([]*Node{node}, nil); err != nil {
+		return err
+	}
+	if err := ae.admitQuota([]*Node{node}, nil); err != nil {
+		return err
+	}
+
 	// Remove from delete set if present
 	delete(ae.deleteNodes, node.ID)
 	ae.nodeCache[node.ID] = node
@@ -348,6 +392,10 @@ func (ae *AsyncEngine) UpdateNode(node *Node) error {
 	ae.mu.Lock()
 	defer ae.mu.Unlock()
 
+	if err := ae.admitSchema([]*Node{node}, nil); err != nil {
+		return err
+	}
+
 	ae.nodeCache[node.ID] = node
 	ae.pendingWrites++
 	return nil
@@ -363,14 +411,10 @@ func (ae *AsyncEngine) DeleteNode(id NodeID) error {
 	// Check if node was created in this transaction (not yet flushed)
 	if node, existsInCache := ae.nodeCache[id]; existsInCache {
 		// Remove from label index
-		for _, label := range node.Labels {
-			normalLabel := strings.ToLower(label)
-			if ae.labelIndex[normalLabel] != nil {
-				delete(ae.labelIndex[normalLabel], id)
-			}
-		}
+		ae.unindexLabels(node)
 		// Node was created but not flushed - just remove from cache
 		delete(ae.nodeCache, id)
+		ae.settleQuota(id, "")
 		return nil
 	}
 
@@ -380,11 +424,30 @@ func (ae *AsyncEngine) DeleteNode(id NodeID) error {
 	return nil
 }
 
+// unindexLabels removes a cached node from the label index.
+// Caller must hold ae.mu.
+func (ae *AsyncEngine) unindexLabels(node *Node) {
+	for _, label := range node.Labels {
+		normalLabel := strings.ToLower(label)
+		if ae.labelIndex[normalLabel] != nil {
+			delete(ae.labelIndex[normalLabel], node.ID)
+		}
+	}
+}
+
 // CreateEdge adds to cache and returns immediately.
 func (ae *AsyncEngine) CreateEdge(edge *Edge) error {
 	ae.mu.Lock()
 	defer ae.mu.Unlock()
 
+	ae.applyDefaults(nil, []*Edge{edge})
+	if err := ae.admitSchema(nil, []*Edge{edge}); err != nil {
+		return err
+	}
+	if err := ae.admitQuota(nil, []*Edge{edge}); err != nil {
+		return err
+	}
+
 	delete(ae.deleteEdges, edge.ID)
 	ae.edgeCache[edge.ID] = edge
 	ae.pendingWrites++
@@ -396,6 +459,10 @@ func (ae *AsyncEngine) UpdateEdge(edge *Edge) error {
 	ae.mu.Lock()
 	defer ae.mu.Unlock()
 
+	if err := ae.admitSchema(nil, []*Edge{edge}); err != nil {
+		return err
+	}
+
 	ae.edgeCache[edge.ID] = edge
 	ae.pendingWrites++
 	return nil
@@ -413,6 +480,7 @@ func (ae *AsyncEngine) DeleteEdge(id EdgeID) error {
 		// Edge was created but not flushed - just remove from cache
 		// No need to mark for deletion since it doesn't exist in underlying engine
 		delete(ae.edgeCache, id)
+		ae.settleQuota("", id)
 		return nil
 	}
 
@@ -880,6 +948,14 @@ func (ae *AsyncEngine) BulkCreateNodes(nodes []*Node) error {
 	ae.mu.Lock()
 	defer ae.mu.Unlock()
 
+	ae.applyDefaults(nodes, nil)
+	if err := ae.admitSchema(nodes, nil); err != nil {
+		return err
+	}
+	if err := ae.admitQuota(nodes, nil); err != nil {
+		return err
+	}
+
 	for _, node := range nodes {
 		delete(ae.deleteNodes, node.ID)
 		ae.nodeCache[node.ID] = node
@@ -893,6 +969,14 @@ func (ae *AsyncEngine) BulkCreateEdges(edges []*Edge) error {
 	ae.mu.Lock()
 	defer ae.mu.Unlock()
 
+	ae.applyDefaults(nil, edges)
+	if err := ae.admitSchema(nil, edges); err != nil {
+		return err
+	}
+	if err := ae.admitQuota(nil, edges); err != nil {
+		return err
+	}
+
 	for _, edge := range edges {
 		delete(ae.deleteEdges, edge.ID)
 		ae.edgeCache[edge.ID] = edge
@@ -908,6 +992,7 @@ func (ae *AsyncEngine) BulkDeleteNodes(ids []NodeID) error {
 
 	for _, id := range ids {
 		delete(ae.nodeCache, id)
+		ae.settleQuota(id, "")
 		ae.deleteNodes[id] = true
 	}
 	ae.pendingWrites += int64(len(ids))
@@ -921,6 +1006,7 @@ func (ae *AsyncEngine) BulkDeleteEdges(ids []EdgeID) error {
 
 	for _, id := range ids {
 		delete(ae.edgeCache, id)
+		ae.settleQuota("", id)
 		ae.deleteEdges[id] = true
 	}
 	ae.pendingWrites += int64(len(ids))
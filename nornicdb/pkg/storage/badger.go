@@ -78,6 +78,15 @@ type BadgerEngine struct {
 	// Caches edges by type for O(1) lookup
 	edgeTypeCache   map[string][]*Edge // edgeType -> edges of that type
 	edgeTypeCacheMu sync.RWMutex
+
+	// Storage quota (nil = unlimited), see SetQuota
+	quota atomic.Pointer[quotaTracker]
+
+	// Per-node relationship counts, see Degree
+	degrees *degreeCounters
+
+	// Property schema checked on writes, see PropertySchema
+	propertySchema *PropertySchema
 }
 
 // IsInMemory returns true if the engine is running in memory-only mode.
@@ -315,11 +324,13 @@ func NewBadgerEngineWithOptions(opts BadgerOptions) (*BadgerEngine, error) {
 	}
 
 	return &BadgerEngine{
-		db:            db,
-		schema:        NewSchemaManager(),
-		inMemory:      opts.InMemory,
-		nodeCache:     make(map[NodeID]*Node, 10000), // Cache up to 10K hot nodes
-		edgeTypeCache: make(map[string][]*Edge, 100), // Cache edges by type for mutual queries
+		db:             db,
+		schema:         NewSchemaManager(),
+		inMemory:       opts.InMemory,
+		nodeCache:      make(map[NodeID]*Node, 10000), // Cache up to 10K hot nodes
+		edgeTypeCache:  make(map[string][]*Edge, 100), // Cache edges by type for mutual queries
+		degrees:        newDegreeCounters(),
+		propertySchema: NewPropertySchema(),
 	}, nil
 }
 
@@ -526,6 +537,8 @@ func (b *BadgerEngine) CreateNode(node *Node) error {
 	}
 	b.mu.RUnlock()
 
+	b.propertySchema.ApplyNodeDefaults(node)
+
 	// Check unique constraints for all labels and properties
 	for _, label := range node.Labels {
 		for propName, propValue := range node.Properties {
@@ -535,7 +548,11 @@ func (b *BadgerEngine) CreateNode(node *Node) error {
 		}
 	}
 
-	err := b.db.Update(func(txn *badger.Txn) error {
+	if err := b.propertySchema.CheckNode(node); err != nil {
+		return err
+	}
+
+	err := b.updateWithQuota(func(txn *badger.Txn, d *quotaDelta) error {
 		// Check if node already exists
 		key := nodeKey(node.ID)
 		_, err := txn.Get(key)
@@ -556,6 +573,7 @@ func (b *BadgerEngine) CreateNode(node *Node) error {
 		if err := txn.Set(key, data); err != nil {
 			return err
 		}
+		d.node(node.Labels, len(data), 1)
 
 		// Create label indexes
 		for _, label := range node.Labels {
@@ -657,7 +675,11 @@ func (b *BadgerEngine) UpdateNode(node *Node) error {
 	}
 	b.mu.RUnlock()
 
-	err := b.db.Update(func(txn *badger.Txn) error {
+	if err := b.propertySchema.CheckNode(node); err != nil {
+		return err
+	}
+
+	err := b.updateWithQuota(func(txn *badger.Txn, d *quotaDelta) error {
 		key := nodeKey(node.ID)
 
 		// Get existing node for label index updates (if exists)
@@ -671,6 +693,7 @@ func (b *BadgerEngine) UpdateNode(node *Node) error {
 			if err := txn.Set(key, data); err != nil {
 				return err
 			}
+			d.node(node.Labels, len(data), 1)
 			// Create label indexes
 			for _, label := range node.Labels {
 				if err := txn.Set(labelIndexKey(label, node.ID), []byte{}); err != nil {
@@ -709,6 +732,9 @@ func (b *BadgerEngine) UpdateNode(node *Node) error {
 		if err := txn.Set(key, data); err != nil {
 			return err
 		}
+		d.resize(int(item.ValueSize()), len(data))
+		d.label(existing.Labels, -1)
+		d.label(node.Labels, 1)
 
 		// Create new label indexes
 		for _, label := range node.Labels {
@@ -743,7 +769,7 @@ func (b *BadgerEngine) DeleteNode(id NodeID) error {
 	}
 	b.mu.RUnlock()
 
-	err := b.db.Update(func(txn *badger.Txn) error {
+	err := b.updateWithQuota(func(txn *badger.Txn, d *quotaDelta) error {
 		key := nodeKey(id)
 
 		// Get node for label cleanup
@@ -773,17 +799,18 @@ func (b *BadgerEngine) DeleteNode(id NodeID) error {
 
 		// Delete outgoing edges
 		outPrefix := outgoingIndexPrefix(id)
-		if err := b.deleteEdgesWithPrefix(txn, outPrefix); err != nil {
+		if err := b.deleteEdgesWithPrefix(txn, outPrefix, d); err != nil {
 			return err
 		}
 
 		// Delete incoming edges
 		inPrefix := incomingIndexPrefix(id)
-		if err := b.deleteEdgesWithPrefix(txn, inPrefix); err != nil {
+		if err := b.deleteEdgesWithPrefix(txn, inPrefix, d); err != nil {
 			return err
 		}
 
 		// Delete the node
+		d.node(node.Labels, int(item.ValueSize()), -1)
 		return txn.Delete(key)
 	})
 
@@ -798,7 +825,7 @@ func (b *BadgerEngine) DeleteNode(id NodeID) error {
 }
 
 // deleteEdgesWithPrefix deletes all edges matching a prefix (helper for DeleteNode).
-func (b *BadgerEngine) deleteEdgesWithPrefix(txn *badger.Txn, prefix []byte) error {
+func (b *BadgerEngine) deleteEdgesWithPrefix(txn *badger.Txn, prefix []byte, d *quotaDelta) error {
 	opts := badger.DefaultIteratorOptions
 	opts.PrefetchValues = false
 	it := txn.NewIterator(opts)
@@ -811,7 +838,7 @@ func (b *BadgerEngine) deleteEdgesWithPrefix(txn *badger.Txn, prefix []byte) err
 	}
 
 	for _, edgeID := range edgeIDs {
-		if err := b.deleteEdgeInTxn(txn, edgeID); err != nil && err != ErrNotFound {
+		if err := b.deleteEdgeInTxn(txn, edgeID, d); err != nil && err != ErrNotFound {
 			return err
 		}
 	}
@@ -839,7 +866,12 @@ func (b *BadgerEngine) CreateEdge(edge *Edge) error {
 	}
 	b.mu.RUnlock()
 
-	err := b.db.Update(func(txn *badger.Txn) error {
+	b.propertySchema.ApplyEdgeDefaults(edge)
+	if err := b.propertySchema.CheckEdge(edge); err != nil {
+		return err
+	}
+
+	err := b.updateWithQuota(func(txn *badger.Txn, d *quotaDelta) error {
 		// Check if edge already exists
 		key := edgeKey(edge.ID)
 		_, err := txn.Get(key)
@@ -878,6 +910,8 @@ func (b *BadgerEngine) CreateEdge(edge *Edge) error {
 		if err := txn.Set(key, data); err != nil {
 			return err
 		}
+		d.edge(len(data), 1)
+		d.relationship(edge, 1)
 
 		// Create outgoing index
 		outKey := outgoingIndexKey(edge.StartNode, edge.ID)
@@ -957,7 +991,11 @@ func (b *BadgerEngine) UpdateEdge(edge *Edge) error {
 	}
 	b.mu.RUnlock()
 
-	return b.db.Update(func(txn *badger.Txn) error {
+	if err := b.propertySchema.CheckEdge(edge); err != nil {
+		return err
+	}
+
+	return b.updateWithQuota(func(txn *badger.Txn, d *quotaDelta) error {
 		key := edgeKey(edge.ID)
 
 		// Get existing edge
@@ -1005,11 +1043,17 @@ func (b *BadgerEngine) UpdateEdge(edge *Edge) error {
 			}
 		}
 
+		if existing.StartNode != edge.StartNode || existing.EndNode != edge.EndNode || existing.Type != edge.Type {
+			d.relationship(existing, -1)
+			d.relationship(edge, 1)
+		}
+
 		// Store updated edge
 		data, err := encodeEdge(edge)
 		if err != nil {
 			return fmt.Errorf("failed to encode edge: %w", err)
 		}
+		d.resize(int(item.ValueSize()), len(data))
 
 		return txn.Set(key, data)
 	})
@@ -1035,8 +1079,8 @@ func (b *BadgerEngine) DeleteEdge(id EdgeID) error {
 		edgeType = edge.Type
 	}
 
-	err := b.db.Update(func(txn *badger.Txn) error {
-		return b.deleteEdgeInTxn(txn, id)
+	err := b.updateWithQuota(func(txn *badger.Txn, d *quotaDelta) error {
+		return b.deleteEdgeInTxn(txn, id, d)
 	})
 
 	// Invalidate only this edge type (not entire cache)
@@ -1048,7 +1092,7 @@ func (b *BadgerEngine) DeleteEdge(id EdgeID) error {
 }
 
 // deleteEdgeInTxn is the internal helper for deleting an edge within a transaction.
-func (b *BadgerEngine) deleteEdgeInTxn(txn *badger.Txn, id EdgeID) error {
+func (b *BadgerEngine) deleteEdgeInTxn(txn *badger.Txn, id EdgeID, d *quotaDelta) error {
 	key := edgeKey(id)
 
 	// Get edge for index cleanup
@@ -1081,11 +1125,13 @@ func (b *BadgerEngine) deleteEdgeInTxn(txn *badger.Txn, id EdgeID) error {
 	}
 
 	// Delete edge
+	d.edge(int(item.ValueSize()), -1)
+	d.relationship(edge, -1)
 	return txn.Delete(key)
 }
 
 // deleteNodeInTxn is the internal helper for deleting a node within a transaction.
-func (b *BadgerEngine) deleteNodeInTxn(txn *badger.Txn, id NodeID) error {
+func (b *BadgerEngine) deleteNodeInTxn(txn *badger.Txn, id NodeID, d *quotaDelta) error {
 	key := nodeKey(id)
 
 	// Get node for label cleanup
@@ -1115,17 +1161,18 @@ func (b *BadgerEngine) deleteNodeInTxn(txn *badger.Txn, id NodeID) error {
 
 	// Delete outgoing edges
 	outPrefix := outgoingIndexPrefix(id)
-	if err := b.deleteEdgesWithPrefix(txn, outPrefix); err != nil {
+	if err := b.deleteEdgesWithPrefix(txn, outPrefix, d); err != nil {
 		return err
 	}
 
 	// Delete incoming edges
 	inPrefix := incomingIndexPrefix(id)
-	if err := b.deleteEdgesWithPrefix(txn, inPrefix); err != nil {
+	if err := b.deleteEdgesWithPrefix(txn, inPrefix, d); err != nil {
 		return err
 	}
 
 	// Delete the node
+	d.node(node.Labels, int(item.ValueSize()), -1)
 	return txn.Delete(key)
 }
 
@@ -1143,13 +1190,13 @@ func (b *BadgerEngine) BulkDeleteNodes(ids []NodeID) error {
 	}
 	b.mu.RUnlock()
 
-	err := b.db.Update(func(txn *badger.Txn) error {
+	err := b.updateWithQuota(func(txn *badger.Txn, d *quotaDelta) error {
 		for _, id := range ids {
 			if id == "" {
 				continue // Skip invalid IDs
 			}
 			// Best effort - continue on ErrNotFound
-			if err := b.deleteNodeInTxn(txn, id); err != nil && err != ErrNotFound {
+			if err := b.deleteNodeInTxn(txn, id, d); err != nil && err != ErrNotFound {
 				return err
 			}
 		}
@@ -1182,13 +1229,13 @@ func (b *BadgerEngine) BulkDeleteEdges(ids []EdgeID) error {
 	}
 	b.mu.RUnlock()
 
-	err := b.db.Update(func(txn *badger.Txn) error {
+	err := b.updateWithQuota(func(txn *badger.Txn, d *quotaDelta) error {
 		for _, id := range ids {
 			if id == "" {
 				continue // Skip invalid IDs
 			}
 			// Best effort - continue on ErrNotFound
-			if err := b.deleteEdgeInTxn(txn, id); err != nil && err != ErrNotFound {
+			if err := b.deleteEdgeInTxn(txn, id, d); err != nil && err != ErrNotFound {
 				return err
 			}
 		}
@@ -1704,6 +1751,7 @@ func (b *BadgerEngine) BulkCreateNodes(nodes []*Node) error {
 		if node.ID == "" {
 			return ErrInvalidID
 		}
+		b.propertySchema.ApplyNodeDefaults(node)
 	}
 
 	// Check unique constraints for all nodes BEFORE inserting any
@@ -1717,7 +1765,13 @@ func (b *BadgerEngine) BulkCreateNodes(nodes []*Node) error {
 		}
 	}
 
-	err := b.db.Update(func(txn *badger.Txn) error {
+	for _, node := range nodes {
+		if err := b.propertySchema.CheckNode(node); err != nil {
+			return err
+		}
+	}
+
+	err := b.updateWithQuota(func(txn *badger.Txn, d *quotaDelta) error {
 		// Check for duplicates
 		for _, node := range nodes {
 			_, err := txn.Get(nodeKey(node.ID))
@@ -1739,6 +1793,7 @@ func (b *BadgerEngine) BulkCreateNodes(nodes []*Node) error {
 			if err := txn.Set(nodeKey(node.ID), data); err != nil {
 				return err
 			}
+			d.node(node.Labels, len(data), 1)
 
 			for _, label := range node.Labels {
 				if err := txn.Set(labelIndexKey(label, node.ID), []byte{}); err != nil {
@@ -1781,9 +1836,16 @@ func (b *BadgerEngine) BulkCreateEdges(edges []*Edge) error {
 		if edge.ID == "" {
 			return ErrInvalidID
 		}
+		b.propertySchema.ApplyEdgeDefaults(edge)
+	}
+
+	for _, edge := range edges {
+		if err := b.propertySchema.CheckEdge(edge); err != nil {
+			return err
+		}
 	}
 
-	err := b.db.Update(func(txn *badger.Txn) error {
+	err := b.updateWithQuota(func(txn *badger.Txn, d *quotaDelta) error {
 		// Validate all edges
 		for _, edge := range edges {
 			// Check edge doesn't exist
@@ -1814,6 +1876,8 @@ func (b *BadgerEngine) BulkCreateEdges(edges []*Edge) error {
 			if err := txn.Set(edgeKey(edge.ID), data); err != nil {
 				return err
 			}
+			d.edge(len(data), 1)
+			d.relationship(edge, 1)
 
 			if err := txn.Set(outgoingIndexKey(edge.StartNode, edge.ID), []byte{}); err != nil {
 				return err
@@ -1841,56 +1905,18 @@ func (b *BadgerEngine) BulkCreateEdges(edges []*Edge) error {
 // Degree Functions
 // ============================================================================
 
-// GetInDegree returns the number of incoming edges to a node.
+// GetInDegree returns the number of incoming edges to a node, from its degree
+// counters.
 func (b *BadgerEngine) GetInDegree(nodeID NodeID) int {
-	b.mu.RLock()
-	if b.closed {
-		b.mu.RUnlock()
-		return 0
-	}
-	b.mu.RUnlock()
-
-	count := 0
-	_ = b.db.View(func(txn *badger.Txn) error {
-		prefix := incomingIndexPrefix(nodeID)
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			count++
-		}
-		return nil
-	})
-
-	return count
+	count, _ := b.Degree(nodeID, DirectionIncoming)
+	return int(count)
 }
 
-// GetOutDegree returns the number of outgoing edges from a node.
+// GetOutDegree returns the number of outgoing edges from a node, from its degree
+// counters.
 func (b *BadgerEngine) GetOutDegree(nodeID NodeID) int {
-	b.mu.RLock()
-	if b.closed {
-		b.mu.RUnlock()
-		return 0
-	}
-	b.mu.RUnlock()
-
-	count := 0
-	_ = b.db.View(func(txn *badger.Txn) error {
-		prefix := outgoingIndexPrefix(nodeID)
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			count++
-		}
-		return nil
-	})
-
-	return count
+	count, _ := b.Degree(nodeID, DirectionOutgoing)
+	return int(count)
 }
 
 // ============================================================================
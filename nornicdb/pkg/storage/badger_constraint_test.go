@@ -191,6 +191,42 @@ func TestBadgerEngine_ValidateConstraintOnCreation(t *testing.T) {
 	}
 }
 
+// TestBadgerEngine_ValidateNodeKeyConstraintOnCreationTypes tests that NODE KEY
+// validation tells "1" from 1 instead of comparing formatted text.
+func TestBadgerEngine_ValidateNodeKeyConstraintOnCreationTypes(t *testing.T) {
+	engine, cleanup := setupTestBadgerEngine(t)
+	defer cleanup()
+
+	tx, _ := engine.BeginTransaction()
+	tx.CreateNode(&Node{
+		ID:     "sku-1",
+		Labels: []string{"Sku"},
+		Properties: map[string]interface{}{
+			"region": "eu",
+			"code":   "1",
+		},
+	})
+	tx.CreateNode(&Node{
+		ID:     "sku-2",
+		Labels: []string{"Sku"},
+		Properties: map[string]interface{}{
+			"region": "eu",
+			"code":   int64(1), // Same text, different type
+		},
+	})
+	tx.Commit()
+
+	err := engine.ValidateConstraintOnCreation(Constraint{
+		Name:       "sku_key",
+		Type:       ConstraintNodeKey,
+		Label:      "Sku",
+		Properties: []string{"region", "code"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no violation for \"1\" and 1, got %v", err)
+	}
+}
+
 // TestBadgerEngine_ValidateExistenceConstraintOnCreation tests EXISTS constraint validation.
 func TestBadgerEngine_ValidateExistenceConstraintOnCreation(t *testing.T) {
 	engine, cleanup := setupTestBadgerEngine(t)
@@ -29,6 +29,10 @@ type BadgerTransaction struct {
 	StartTime time.Time
 	Status    TransactionStatus
 
+	// RequestID of the client request that opened the transaction (optional).
+	// Included in transaction log lines for cross-layer correlation.
+	RequestID string
+
 	// Badger's native transaction
 	badgerTx *badger.Txn
 
@@ -86,10 +90,15 @@ func (tx *BadgerTransaction) CreateNode(node *Node) error {
 		return ErrTransactionClosed
 	}
 
+	tx.engine.propertySchema.ApplyNodeDefaults(node)
+
 	// Validate constraints BEFORE writing
 	if err := tx.validateNodeConstraints(node); err != nil {
 		return err
 	}
+	if err := tx.engine.propertySchema.CheckNode(node); err != nil {
+		return err
+	}
 
 	// Check for duplicates in pending
 	if _, exists := tx.pendingNodes[node.ID]; exists {
@@ -155,6 +164,9 @@ func (tx *BadgerTransaction) UpdateNode(node *Node) error {
 	if err := tx.validateNodeConstraints(node); err != nil {
 		return err
 	}
+	if err := tx.engine.propertySchema.CheckNode(node); err != nil {
+		return err
+	}
 
 	// Check if node exists
 	var oldNode *Node
@@ -313,6 +325,11 @@ func (tx *BadgerTransaction) CreateEdge(edge *Edge) error {
 		return ErrTransactionClosed
 	}
 
+	tx.engine.propertySchema.ApplyEdgeDefaults(edge)
+	if err := tx.engine.propertySchema.CheckEdge(edge); err != nil {
+		return err
+	}
+
 	// Check nodes exist
 	if !tx.nodeExists(edge.StartNode) {
 		return fmt.Errorf("start node %s does not exist", edge.StartNode)
@@ -466,6 +483,41 @@ func (tx *BadgerTransaction) GetNode(nodeID NodeID) (*Node, error) {
 	return deserializeNode(nodeBytes)
 }
 
+// GetEdge retrieves an edge (read-your-writes).
+func (tx *BadgerTransaction) GetEdge(edgeID EdgeID) (*Edge, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	// Check deleted
+	if _, deleted := tx.deletedEdges[edgeID]; deleted {
+		return nil, ErrNotFound
+	}
+
+	// Check pending
+	if edge, exists := tx.pendingEdges[edgeID]; exists {
+		return copyEdge(edge), nil
+	}
+
+	// Read from Badger
+	item, err := tx.badgerTx.Get(edgeKey(edgeID))
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading edge: %w", err)
+	}
+
+	var edgeBytes []byte
+	if err := item.Value(func(val []byte) error {
+		edgeBytes = append([]byte{}, val...)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("reading edge value: %w", err)
+	}
+
+	return deserializeEdge(edgeBytes)
+}
+
 // Commit applies all changes atomically with full constraint validation.
 // Explicit transactions get strict ACID durability with immediate fsync.
 func (tx *BadgerTransaction) Commit() error {
@@ -485,12 +537,29 @@ func (tx *BadgerTransaction) Commit() error {
 
 	// Log metadata
 	if len(tx.Metadata) > 0 {
-		log.Printf("[Transaction %s] Committing with metadata: %v", tx.ID, tx.Metadata)
+		log.Printf("[Transaction %s] Committing with metadata: %v", tx.logID(), tx.Metadata)
+	}
+
+	// Reserve the transaction's storage growth against the quota
+	quota := tx.engine.quota.Load()
+	delta := tx.quotaDelta(quota)
+	if err := quota.reserve(delta); err != nil {
+		tx.badgerTx.Discard()
+		tx.Status = TxStatusRolledBack
+		return err
 	}
 
 	// Commit Badger transaction (atomic!)
-	if err := tx.badgerTx.Commit(); err != nil {
+	degrees := tx.degreeChanges()
+	tx.engine.degrees.begin(degrees)
+	err := tx.badgerTx.Commit()
+	tx.engine.degrees.end(degrees, err == nil)
+	if err != nil {
+		quota.release(delta)
 		tx.Status = TxStatusRolledBack
+		if err == badger.ErrConflict {
+			return ErrConflict
+		}
 		return fmt.Errorf("badger commit failed: %w", err)
 	}
 
@@ -531,7 +600,7 @@ func (tx *BadgerTransaction) Commit() error {
 		if err := tx.engine.Sync(); err != nil {
 			// Transaction is committed in Badger but fsync failed
 			// Log error but don't rollback - data is in Badger's WAL
-			log.Printf("[Transaction %s] Warning: fsync failed after commit: %v", tx.ID, err)
+			log.Printf("[Transaction %s] Warning: fsync failed after commit: %v", tx.logID(), err)
 		}
 	}
 
@@ -553,6 +622,14 @@ func (tx *BadgerTransaction) Rollback() error {
 	return nil
 }
 
+// logID returns the transaction ID for log lines, tagged with the request ID if known.
+func (tx *BadgerTransaction) logID() string {
+	if tx.RequestID == "" {
+		return tx.ID
+	}
+	return tx.ID + " req=" + tx.RequestID
+}
+
 // SetMetadata sets transaction metadata (same as Transaction).
 func (tx *BadgerTransaction) SetMetadata(metadata map[string]interface{}) error {
 	tx.mu.Lock()
@@ -0,0 +1,426 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Consistency issue kinds reported by CheckConsistency.
+const (
+	// IssueCorruptTable is a data file that fails its checksum.
+	IssueCorruptTable = "corrupt_table"
+	// IssueCorruptRecord is a node or relationship record that cannot be decoded.
+	IssueCorruptRecord = "corrupt_record"
+	// IssueDanglingRelationship is a relationship whose start or end node is missing.
+	IssueDanglingRelationship = "dangling_relationship"
+	// IssueOrphanedIndexEntry is a label, adjacency or type index entry for a
+	// node or relationship that is missing or no longer matches it.
+	IssueOrphanedIndexEntry = "orphaned_index_entry"
+	// IssueMissingIndexEntry is a node label or relationship with no index entry.
+	IssueMissingIndexEntry = "missing_index_entry"
+	// IssueCountDrift is a storage usage counter that differs from the store.
+	IssueCountDrift = "count_drift"
+)
+
+// ConsistencyOptions controls CheckConsistency.
+type ConsistencyOptions struct {
+	// Repair applies safe fixes: orphaned index entries are removed,
+	// missing ones are added and drifted counters are recounted.
+	Repair bool
+	// DeleteDangling also deletes relationships whose start or end node is
+	// missing. It loses the relationships, so it needs Repair and is off
+	// by default.
+	DeleteDangling bool
+	// MaxIssues caps the issues listed in the report (default 1000). All
+	// issues are still counted and repaired.
+	MaxIssues int
+}
+
+// ConsistencyIssue is one problem found by CheckConsistency.
+type ConsistencyIssue struct {
+	Kind     string `json:"kind"`
+	Key      string `json:"key"`
+	Detail   string `json:"detail"`
+	Repaired bool   `json:"repaired"`
+}
+
+// ConsistencyReport is the result of CheckConsistency.
+type ConsistencyReport struct {
+	Nodes         int64              `json:"nodes"`
+	Relationships int64              `json:"relationships"`
+	IndexEntries  int64              `json:"index_entries"`
+	IssueCounts   map[string]int     `json:"issue_counts"`
+	Issues        []ConsistencyIssue `json:"issues"`
+	Truncated     bool               `json:"truncated"` // more issues were found than listed
+	Repaired      int                `json:"repaired"`
+	Duration      time.Duration      `json:"duration_ns"`
+}
+
+// OK reports whether the store is consistent, or every issue was repaired.
+func (r *ConsistencyReport) OK() bool {
+	return r.issueTotal() == r.Repaired
+}
+
+// WriteText writes the report for the terminal.
+func (r *ConsistencyReport) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Scanned %d nodes, %d relationships, %d index entries in %s\n\n",
+		r.Nodes, r.Relationships, r.IndexEntries, r.Duration.Round(time.Millisecond))
+	if len(r.IssueCounts) == 0 {
+		fmt.Fprintln(w, "   ✅ No issues found")
+		return
+	}
+
+	kinds := make([]string, 0, len(r.IssueCounts))
+	for kind := range r.IssueCounts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "   ❌ %-22s %d\n", kind, r.IssueCounts[kind])
+	}
+	fmt.Fprintln(w)
+	for _, issue := range r.Issues {
+		mark := "  "
+		if issue.Repaired {
+			mark = "🔧"
+		}
+		fmt.Fprintf(w, "   %s %s %s: %s\n", mark, issue.Kind, issue.Key, issue.Detail)
+	}
+	if r.Truncated {
+		fmt.Fprintln(w, "   ... more issues not listed")
+	}
+	fmt.Fprintf(w, "\n%d issues, %d repaired\n", r.issueTotal(), r.Repaired)
+}
+
+func (r *ConsistencyReport) issueTotal() int {
+	total := 0
+	for _, n := range r.IssueCounts {
+		total += n
+	}
+	return total
+}
+
+// consistencyCheck holds the state of one CheckConsistency run.
+type consistencyCheck struct {
+	b      *BadgerEngine
+	opts   ConsistencyOptions
+	report *ConsistencyReport
+
+	nodes map[NodeID]map[string]bool // node -> lowercase labels (nil if corrupt)
+	edges map[EdgeID]edgeEnds
+	usage QuotaUsage // counted as the quota tracker counts
+
+	labelIndexed map[string]bool // label + 0x00 + nodeID
+	outIndexed   map[EdgeID]bool
+	inIndexed    map[EdgeID]bool
+	typeIndexed  map[EdgeID]bool
+
+	deleteKeys [][]byte
+	setKeys    [][]byte
+	dangling   map[EdgeID]bool // to delete in repair mode
+}
+
+type edgeEnds struct {
+	start, end NodeID
+	edgeType   string // lowercase
+}
+
+// CheckConsistency scans the store for corrupt data files and records,
+// relationships with missing endpoints, index entries that disagree with
+// the records they index, and storage usage counters that have drifted.
+//
+// The scan holds every node ID, label and relationship endpoint in memory.
+// Run it on a store that is not being written to, such as with the server
+// stopped (see "nornicdb check-consistency"); concurrent writes can show up
+// as transient issues, and repairs could race with them.
+//
+// Corrupt files and records are reported but never repaired; restore them
+// from a backup.
+func (b *BadgerEngine) CheckConsistency(opts ConsistencyOptions) (*ConsistencyReport, error) {
+	b.mu.RLock()
+	closed := b.closed
+	b.mu.RUnlock()
+	if closed {
+		return nil, ErrStorageClosed
+	}
+	if opts.MaxIssues <= 0 {
+		opts.MaxIssues = 1000
+	}
+
+	start := time.Now()
+	c := &consistencyCheck{
+		b:            b,
+		opts:         opts,
+		report:       &ConsistencyReport{IssueCounts: make(map[string]int), Issues: []ConsistencyIssue{}},
+		nodes:        make(map[NodeID]map[string]bool),
+		edges:        make(map[EdgeID]edgeEnds),
+		usage:        QuotaUsage{NodesPerLabel: make(map[string]int64)},
+		labelIndexed: make(map[string]bool),
+		outIndexed:   make(map[EdgeID]bool),
+		inIndexed:    make(map[EdgeID]bool),
+		typeIndexed:  make(map[EdgeID]bool),
+		dangling:     make(map[EdgeID]bool),
+	}
+
+	if !b.inMemory {
+		if err := b.db.VerifyChecksum(); err != nil {
+			c.issue(IssueCorruptTable, "", err.Error(), false)
+		}
+	}
+	if err := b.db.View(c.scan); err != nil {
+		return nil, fmt.Errorf("consistency scan failed: %w", err)
+	}
+	c.checkMissing()
+	c.checkCounts()
+
+	if opts.Repair {
+		if err := c.repair(); err != nil {
+			return c.report, err
+		}
+	}
+	c.report.Duration = time.Since(start)
+	return c.report, nil
+}
+
+// issue records a problem; repairable ones are marked repaired when the
+// check runs in repair mode.
+func (c *consistencyCheck) issue(kind, key, detail string, repairable bool) {
+	repaired := repairable && c.opts.Repair
+	c.report.IssueCounts[kind]++
+	if repaired {
+		c.report.Repaired++
+	}
+	if len(c.report.Issues) < c.opts.MaxIssues {
+		c.report.Issues = append(c.report.Issues, ConsistencyIssue{Kind: kind, Key: key, Detail: detail, Repaired: repaired})
+	} else {
+		c.report.Truncated = true
+	}
+}
+
+// scan reads records first, then checks each index against them.
+func (c *consistencyCheck) scan(txn *badger.Txn) error {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Seek([]byte{prefixNode}); it.ValidForPrefix([]byte{prefixNode}); it.Next() {
+		item := it.Item()
+		id := NodeID(item.Key()[1:])
+		c.report.Nodes++
+		c.usage.Nodes++
+		c.usage.Bytes += item.ValueSize()
+		node, err := decodeValue(item, decodeNode)
+		if err != nil {
+			c.issue(IssueCorruptRecord, "node "+string(id), err.Error(), false)
+			c.nodes[id] = nil // exists; its labels are unknown
+			continue
+		}
+		labels := make(map[string]bool, len(node.Labels))
+		for _, label := range node.Labels {
+			labels[strings.ToLower(label)] = true
+			c.usage.NodesPerLabel[strings.ToLower(label)]++
+		}
+		c.nodes[id] = labels
+	}
+
+	for it.Seek([]byte{prefixEdge}); it.ValidForPrefix([]byte{prefixEdge}); it.Next() {
+		item := it.Item()
+		id := EdgeID(item.Key()[1:])
+		c.report.Relationships++
+		c.usage.Relationships++
+		c.usage.Bytes += item.ValueSize()
+		edge, err := decodeValue(item, decodeEdge)
+		if err != nil {
+			c.issue(IssueCorruptRecord, "relationship "+string(id), err.Error(), false)
+			continue
+		}
+		c.edges[id] = edgeEnds{start: edge.StartNode, end: edge.EndNode, edgeType: strings.ToLower(edge.Type)}
+		for _, end := range []NodeID{edge.StartNode, edge.EndNode} {
+			if _, ok := c.nodes[end]; !ok {
+				c.issue(IssueDanglingRelationship, "relationship "+string(id),
+					fmt.Sprintf("node %s does not exist", end), c.opts.DeleteDangling)
+				if c.opts.DeleteDangling {
+					c.dangling[id] = true
+				}
+				break
+			}
+		}
+	}
+
+	c.scanIndex(it, prefixLabelIndex, func(key []byte, label string, id string) string {
+		labels, ok := c.nodes[NodeID(id)]
+		if !ok {
+			return fmt.Sprintf("label %s indexes missing node %s", label, id)
+		}
+		c.labelIndexed[label+"\x00"+id] = true
+		if labels != nil && !labels[label] {
+			return fmt.Sprintf("label %s indexes node %s, which no longer has it", label, id)
+		}
+		return ""
+	})
+	c.scanIndex(it, prefixOutgoingIndex, func(key []byte, nodeID string, id string) string {
+		return c.checkAdjacency("outgoing", NodeID(nodeID), EdgeID(id), c.outIndexed, func(e edgeEnds) NodeID { return e.start })
+	})
+	c.scanIndex(it, prefixIncomingIndex, func(key []byte, nodeID string, id string) string {
+		return c.checkAdjacency("incoming", NodeID(nodeID), EdgeID(id), c.inIndexed, func(e edgeEnds) NodeID { return e.end })
+	})
+	c.scanIndex(it, prefixEdgeTypeIndex, func(key []byte, edgeType string, id string) string {
+		e, ok := c.edges[EdgeID(id)]
+		if !ok {
+			return fmt.Sprintf("type %s indexes missing relationship %s", edgeType, id)
+		}
+		if e.edgeType != edgeType {
+			return fmt.Sprintf("type %s indexes relationship %s of type %s", edgeType, id, e.edgeType)
+		}
+		c.typeIndexed[EdgeID(id)] = true
+		return ""
+	})
+	return nil
+}
+
+// scanIndex checks every entry of an index whose keys are prefix + name +
+// 0x00 + id. check returns why an entry is orphaned, or "".
+func (c *consistencyCheck) scanIndex(it *badger.Iterator, prefix byte, check func(key []byte, name, id string) string) {
+	for it.Seek([]byte{prefix}); it.ValidForPrefix([]byte{prefix}); it.Next() {
+		key := it.Item().KeyCopy(nil)
+		c.report.IndexEntries++
+		sep := bytes.IndexByte(key[1:], 0x00)
+		if sep < 0 {
+			c.issue(IssueOrphanedIndexEntry, fmt.Sprintf("%q", key), "malformed index key", true)
+			c.deleteKeys = append(c.deleteKeys, key)
+			continue
+		}
+		name, id := string(key[1:1+sep]), string(key[2+sep:])
+		if detail := check(key, name, id); detail != "" {
+			c.issue(IssueOrphanedIndexEntry, fmt.Sprintf("%q", key), detail, true)
+			c.deleteKeys = append(c.deleteKeys, key)
+		}
+	}
+}
+
+// checkAdjacency checks an outgoing or incoming index entry of nodeID.
+func (c *consistencyCheck) checkAdjacency(direction string, nodeID NodeID, id EdgeID, indexed map[EdgeID]bool, end func(edgeEnds) NodeID) string {
+	e, ok := c.edges[id]
+	if !ok {
+		return fmt.Sprintf("%s index of node %s lists missing relationship %s", direction, nodeID, id)
+	}
+	if end(e) != nodeID {
+		return fmt.Sprintf("%s index of node %s lists relationship %s, which belongs to node %s", direction, nodeID, id, end(e))
+	}
+	indexed[id] = true
+	return ""
+}
+
+// checkMissing finds records without their index entries.
+func (c *consistencyCheck) checkMissing() {
+	for id, labels := range c.nodes {
+		for label := range labels {
+			if !c.labelIndexed[label+"\x00"+string(id)] {
+				c.issue(IssueMissingIndexEntry, "node "+string(id), "label "+label+" is not indexed", true)
+				c.setKeys = append(c.setKeys, labelIndexKey(label, id))
+			}
+		}
+	}
+	for id, e := range c.edges {
+		if c.dangling[id] {
+			continue // deleted with its index entries
+		}
+		if !c.outIndexed[id] {
+			c.issue(IssueMissingIndexEntry, "relationship "+string(id), "missing from the outgoing index of node "+string(e.start), true)
+			c.setKeys = append(c.setKeys, outgoingIndexKey(e.start, id))
+		}
+		if !c.inIndexed[id] {
+			c.issue(IssueMissingIndexEntry, "relationship "+string(id), "missing from the incoming index of node "+string(e.end), true)
+			c.setKeys = append(c.setKeys, incomingIndexKey(e.end, id))
+		}
+		if !c.typeIndexed[id] {
+			c.issue(IssueMissingIndexEntry, "relationship "+string(id), "missing from the type index", true)
+			c.setKeys = append(c.setKeys, edgeTypeIndexKey(e.edgeType, id))
+		}
+	}
+}
+
+// checkCounts compares the quota tracker's usage counters with the scan.
+func (c *consistencyCheck) checkCounts() {
+	t := c.b.quota.Load()
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	usage := t.usage
+	perLabel := make(map[string]int64, len(usage.NodesPerLabel))
+	for label, n := range usage.NodesPerLabel {
+		perLabel[label] = n
+	}
+	t.mu.Unlock()
+
+	drift := func(name string, counted, actual int64) {
+		if counted != actual {
+			c.issue(IssueCountDrift, name, fmt.Sprintf("counter is %d, store has %d", counted, actual), true)
+		}
+	}
+	drift("nodes", usage.Nodes, c.usage.Nodes)
+	drift("relationships", usage.Relationships, c.usage.Relationships)
+	drift("bytes", usage.Bytes, c.usage.Bytes)
+	labels := make([]string, 0, len(perLabel))
+	for label := range perLabel {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		drift("nodes:"+label, perLabel[label], c.usage.NodesPerLabel[label])
+	}
+}
+
+// repair applies the fixes found by the scan.
+func (c *consistencyCheck) repair() error {
+	b := c.b
+	for id := range c.dangling {
+		if err := b.DeleteEdge(id); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("deleting dangling relationship %s: %w", id, err)
+		}
+	}
+
+	wb := b.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, key := range c.deleteKeys {
+		if err := wb.Delete(key); err != nil {
+			return fmt.Errorf("removing index entry: %w", err)
+		}
+	}
+	for _, key := range c.setKeys {
+		if err := wb.Set(key, []byte{}); err != nil {
+			return fmt.Errorf("adding index entry: %w", err)
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return fmt.Errorf("repairing indexes: %w", err)
+	}
+	b.InvalidateEdgeTypeCache()
+	b.degrees.reset()
+
+	if t := b.quota.Load(); t != nil && c.report.IssueCounts[IssueCountDrift] > 0 {
+		// Recount rather than reuse the scan, which missed dangling deletes.
+		if err := b.SetQuota(t.quota); err != nil {
+			return fmt.Errorf("recounting storage usage: %w", err)
+		}
+	}
+	return nil
+}
+
+// decodeValue decodes the value of a record.
+func decodeValue[T any](item *badger.Item, decode func([]byte) (T, error)) (T, error) {
+	var out T
+	err := item.Value(func(val []byte) error {
+		var err error
+		out, err = decode(val)
+		return err
+	})
+	return out, err
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newConsistencyEngine(t *testing.T) *BadgerEngine {
+	engine, err := NewBadgerEngineInMemory()
+	require.NoError(t, err)
+	t.Cleanup(func() { engine.Close() })
+
+	require.NoError(t, engine.CreateNode(&Node{ID: "a", Labels: []string{"Person"}}))
+	require.NoError(t, engine.CreateNode(&Node{ID: "b", Labels: []string{"Person"}}))
+	require.NoError(t, engine.CreateEdge(&Edge{ID: "ab", Type: "KNOWS", StartNode: "a", EndNode: "b"}))
+	return engine
+}
+
+// corrupt writes raw keys, bypassing the engine's index maintenance.
+func corrupt(t *testing.T, engine *BadgerEngine, fn func(txn *badger.Txn) error) {
+	require.NoError(t, engine.db.Update(fn))
+}
+
+func TestCheckConsistency_Clean(t *testing.T) {
+	engine := newConsistencyEngine(t)
+	report, err := engine.CheckConsistency(ConsistencyOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Empty(t, report.IssueCounts)
+	assert.Equal(t, int64(2), report.Nodes)
+	assert.Equal(t, int64(1), report.Relationships)
+	assert.Equal(t, int64(5), report.IndexEntries, "2 labels, outgoing, incoming and type")
+}
+
+func TestCheckConsistency_FindsAndRepairs(t *testing.T) {
+	engine := newConsistencyEngine(t)
+	dangling, err := encodeEdge(&Edge{ID: "ax", Type: "KNOWS", StartNode: "a", EndNode: "gone"})
+	require.NoError(t, err)
+	corrupt(t, engine, func(txn *badger.Txn) error {
+		for _, err := range []error{
+			txn.Set(labelIndexKey("Person", "ghost"), []byte{}), // orphaned: no such node
+			txn.Set(labelIndexKey("Robot", "a"), []byte{}),      // orphaned: a is not a Robot
+			txn.Delete(labelIndexKey("Person", "b")),            // missing
+			txn.Set(outgoingIndexKey("b", "ab"), []byte{}),      // orphaned: ab starts at a
+			txn.Delete(edgeTypeIndexKey("KNOWS", "ab")),         // missing
+			txn.Set(edgeKey("ax"), dangling),                    // dangling, with its index entries
+			txn.Set(outgoingIndexKey("a", "ax"), []byte{}),
+			txn.Set(incomingIndexKey("gone", "ax"), []byte{}),
+			txn.Set(edgeTypeIndexKey("KNOWS", "ax"), []byte{}),
+			txn.Set(nodeKey("broken"), []byte("not a node")), // corrupt
+		} {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	report, err := engine.CheckConsistency(ConsistencyOptions{})
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, map[string]int{
+		IssueOrphanedIndexEntry:   3,
+		IssueMissingIndexEntry:    2,
+		IssueDanglingRelationship: 1,
+		IssueCorruptRecord:        1,
+	}, report.IssueCounts)
+	assert.Zero(t, report.Repaired)
+
+	var text bytes.Buffer
+	report.WriteText(&text)
+	assert.Contains(t, text.String(), "relationship ax: node gone does not exist")
+
+	report, err = engine.CheckConsistency(ConsistencyOptions{Repair: true})
+	require.NoError(t, err)
+	assert.Equal(t, 5, report.Repaired, "index entries are repaired")
+	assert.False(t, report.OK(), "dangling and corrupt records are left alone")
+
+	report, err = engine.CheckConsistency(ConsistencyOptions{Repair: true, DeleteDangling: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{IssueDanglingRelationship: 1, IssueCorruptRecord: 1}, report.IssueCounts)
+	assert.Equal(t, 1, report.Repaired)
+
+	report, err = engine.CheckConsistency(ConsistencyOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{IssueCorruptRecord: 1}, report.IssueCounts)
+	_, err = engine.GetEdge("ax")
+	assert.ErrorIs(t, err, ErrNotFound)
+	nodes, err := engine.GetNodesByLabel("Person")
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2, "the missing label entry was restored")
+}
+
+func TestCheckConsistency_CountDrift(t *testing.T) {
+	engine := newConsistencyEngine(t)
+	require.NoError(t, engine.SetQuota(Quota{MaxNodes: 100, MaxNodesPerLabel: map[string]int64{"Person": 10}}))
+
+	// Records written around the engine are not counted.
+	node, err := encodeNode(&Node{ID: "c", Labels: []string{"Person"}})
+	require.NoError(t, err)
+	corrupt(t, engine, func(txn *badger.Txn) error {
+		if err := txn.Set(nodeKey("c"), node); err != nil {
+			return err
+		}
+		return txn.Set(labelIndexKey("Person", "c"), []byte{})
+	})
+
+	report, err := engine.CheckConsistency(ConsistencyOptions{Repair: true})
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.IssueCounts[IssueCountDrift], "nodes, bytes and nodes:person")
+	assert.True(t, report.OK())
+
+	status, ok := engine.QuotaStatus()
+	require.True(t, ok)
+	assert.Equal(t, int64(3), status.Usage.Nodes)
+	assert.Equal(t, int64(3), status.Usage.NodesPerLabel["person"])
+
+	report, err = engine.CheckConsistency(ConsistencyOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, report.IssueCounts)
+}
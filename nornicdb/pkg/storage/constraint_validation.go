@@ -3,6 +3,10 @@ package storage
 
 import (
 	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/value"
 )
 
 // ValidateConstraintOnCreation validates that all existing data satisfies the constraint.
@@ -93,8 +97,8 @@ func (b *BadgerEngine) validateNodeKeyConstraintOnCreation(c Constraint) error {
 			continue
 		}
 
-		// Create composite key string
-		compositeKey := fmt.Sprintf("%v", values)
+		// Type-tagged composite key: "1" and 1 are different keys
+		compositeKey := string(value.FromAny(values).AppendKey(nil))
 
 		if existingNodeID, found := seen[compositeKey]; found {
 			return &ConstraintViolationError{
@@ -252,6 +256,7 @@ const (
 	PropertyTypeBoolean PropertyType = "BOOLEAN"
 	PropertyTypeDate    PropertyType = "DATE"
 	PropertyTypeDateTime PropertyType = "DATETIME"
+	PropertyTypeList     PropertyType = "LIST"
 )
 
 // ValidatePropertyType checks if a value matches the expected type.
@@ -297,6 +302,29 @@ func ValidatePropertyType(value interface{}, expectedType PropertyType) error {
 		if _, ok := value.(bool); !ok {
 			return fmt.Errorf("expected BOOLEAN, got %T", value)
 		}
+	case PropertyTypeDate, PropertyTypeDateTime:
+		// Temporal functions store ISO 8601 strings
+		switch v := value.(type) {
+		case time.Time:
+			return nil
+		case string:
+			layouts := []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05"}
+			if expectedType == PropertyTypeDate {
+				layouts = []string{"2006-01-02"}
+			}
+			for _, layout := range layouts {
+				if _, err := time.Parse(layout, v); err == nil {
+					return nil
+				}
+			}
+			return fmt.Errorf("expected %s, got string %q", expectedType, v)
+		default:
+			return fmt.Errorf("expected %s, got %T", expectedType, value)
+		}
+	case PropertyTypeList:
+		if reflect.TypeOf(value).Kind() != reflect.Slice {
+			return fmt.Errorf("expected LIST, got %T", value)
+		}
 	default:
 		return fmt.Errorf("unknown property type: %s", expectedType)
 	}
@@ -0,0 +1,360 @@
+// Package storage - per-node degree counters.
+//
+// Counting a node's relationships by reading its adjacency index costs one
+// read per relationship, which adds up for hubs with millions of them.
+// BadgerEngine keeps per-node counters by direction and relationship type
+// instead. A node's counters are built from the adjacency index the first
+// time they are needed and then kept current by every committed write, so
+// degree lookups on hot nodes cost a map read.
+//
+// Counters live in memory and are not persisted: storing them would make
+// every relationship write update its endpoints' counter keys, turning
+// concurrent writes to the same hub into transaction conflicts.
+
+package storage
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Relationship directions, as seen from a node.
+const (
+	DirectionOutgoing = "outgoing"
+	DirectionIncoming = "incoming"
+	DirectionBoth     = "both"
+)
+
+// DegreeEngine is implemented by engines that count a node's relationships
+// without reading them.
+type DegreeEngine interface {
+	// Degree returns how many relationships of the node point in direction
+	// and have one of types (any type if none). For DirectionBoth a
+	// relationship from the node to itself counts once.
+	Degree(nodeID NodeID, direction string, types ...string) (int64, error)
+}
+
+// CountRelationships returns Degree from engines that implement
+// DegreeEngine, and counts the node's relationships otherwise.
+func CountRelationships(engine Engine, nodeID NodeID, direction string, types ...string) (int64, error) {
+	if de, ok := engine.(DegreeEngine); ok {
+		return de.Degree(nodeID, direction, types...)
+	}
+	return countEdges(engine, nodeID, direction, types)
+}
+
+// countEdges counts a node's relationships by reading them.
+func countEdges(engine Engine, nodeID NodeID, direction string, types []string) (int64, error) {
+	var count int64
+	matches := func(e *Edge) bool {
+		if len(types) == 0 {
+			return true
+		}
+		for _, t := range types {
+			if e.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+	if direction != DirectionIncoming {
+		out, err := engine.GetOutgoingEdges(nodeID)
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range out {
+			if matches(e) {
+				count++
+			}
+		}
+	}
+	if direction != DirectionOutgoing {
+		in, err := engine.GetIncomingEdges(nodeID)
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range in {
+			// Self-loops were counted as outgoing.
+			if matches(e) && (direction == DirectionIncoming || e.StartNode != nodeID) {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// maxDegreeEntries bounds the nodes with counters in memory. When it is
+// reached the counters are dropped and rebuilt on demand.
+const maxDegreeEntries = 100_000
+
+// nodeDegree counts one node's relationships by type.
+type nodeDegree struct {
+	out, in, loops map[string]int64
+	loading        bool // counters are being built, see degreeCounters.get
+}
+
+func newNodeDegree() *nodeDegree {
+	return &nodeDegree{out: map[string]int64{}, in: map[string]int64{}, loops: map[string]int64{}}
+}
+
+// count returns the relationships in direction with one of types.
+func (n *nodeDegree) count(direction string, types []string) int64 {
+	sum := func(m map[string]int64) int64 {
+		var total int64
+		if len(types) == 0 {
+			for _, c := range m {
+				total += c
+			}
+			return total
+		}
+		for i, t := range types {
+			if !slices.Contains(types[:i], t) {
+				total += m[t]
+			}
+		}
+		return total
+	}
+	switch direction {
+	case DirectionOutgoing:
+		return sum(n.out)
+	case DirectionIncoming:
+		return sum(n.in)
+	default:
+		return sum(n.out) + sum(n.in) - sum(n.loops)
+	}
+}
+
+// add counts a relationship being added (sign 1) or removed (sign -1).
+// start and end tell which end of it the node is.
+func (n *nodeDegree) add(relType string, start, end bool, sign int64) {
+	bump := func(m map[string]int64) {
+		if m[relType] += sign; m[relType] <= 0 {
+			delete(m, relType)
+		}
+	}
+	if start {
+		bump(n.out)
+	}
+	if end {
+		bump(n.in)
+	}
+	if start && end {
+		bump(n.loops)
+	}
+}
+
+// degreeChange is a relationship added (sign 1) or removed (sign -1) by a
+// write.
+type degreeChange struct {
+	start, end NodeID
+	relType    string
+	sign       int64
+}
+
+// degreeCounters holds the counters of the nodes that have been asked
+// about.
+//
+// Writes call begin before committing and end after. A node with a write
+// in flight is not loaded into the counters, because the loaded counts
+// may or may not include the write end is about to apply; begin also
+// discards loads started earlier, for the same reason.
+type degreeCounters struct {
+	mu       sync.Mutex
+	nodes    map[NodeID]*nodeDegree
+	inflight map[NodeID]int
+}
+
+func newDegreeCounters() *degreeCounters {
+	return &degreeCounters{nodes: make(map[NodeID]*nodeDegree), inflight: make(map[NodeID]int)}
+}
+
+// begin marks the endpoints of changes as being written.
+func (c *degreeCounters) begin(changes []degreeChange) {
+	if len(changes) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range changes {
+		for _, id := range [2]NodeID{ch.start, ch.end} {
+			c.inflight[id]++
+			if n := c.nodes[id]; n != nil && n.loading {
+				delete(c.nodes, id)
+			}
+		}
+	}
+}
+
+// end applies changes if their write committed and clears the marks made
+// by begin.
+func (c *degreeCounters) end(changes []degreeChange, committed bool) {
+	if len(changes) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range changes {
+		for _, id := range [2]NodeID{ch.start, ch.end} {
+			if c.inflight[id]--; c.inflight[id] <= 0 {
+				delete(c.inflight, id)
+			}
+		}
+		if !committed {
+			continue
+		}
+		if n := c.nodes[ch.start]; n != nil && !n.loading {
+			n.add(ch.relType, true, ch.start == ch.end, ch.sign)
+		}
+		if ch.end != ch.start {
+			if n := c.nodes[ch.end]; n != nil && !n.loading {
+				n.add(ch.relType, false, true, ch.sign)
+			}
+		}
+	}
+}
+
+// reset drops all counters, for writes made around begin and end.
+func (c *degreeCounters) reset() {
+	c.mu.Lock()
+	c.nodes = make(map[NodeID]*nodeDegree)
+	c.mu.Unlock()
+}
+
+// get returns the node's counters, loading them with load if needed.
+func (c *degreeCounters) get(id NodeID, load func() (*nodeDegree, error)) (*nodeDegree, error) {
+	c.mu.Lock()
+	if n := c.nodes[id]; n != nil && !n.loading {
+		c.mu.Unlock()
+		return n, nil
+	}
+	var placeholder *nodeDegree
+	if c.nodes[id] == nil && c.inflight[id] == 0 {
+		if len(c.nodes) >= maxDegreeEntries {
+			c.nodes = make(map[NodeID]*nodeDegree)
+		}
+		placeholder = &nodeDegree{loading: true}
+		c.nodes[id] = placeholder
+	}
+	c.mu.Unlock()
+
+	n, err := load()
+	if placeholder == nil {
+		return n, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.nodes[id] != placeholder {
+		return n, err // a write started meanwhile
+	}
+	if err != nil {
+		delete(c.nodes, id)
+		return nil, err
+	}
+	c.nodes[id] = n
+	return n, nil
+}
+
+// degreeChanges returns the relationships the transaction links and
+// unlinks.
+func (tx *BadgerTransaction) degreeChanges() []degreeChange {
+	var changes []degreeChange
+	for _, op := range tx.operations {
+		switch op.Type {
+		case OpCreateEdge:
+			changes = append(changes, degreeChange{start: op.Edge.StartNode, end: op.Edge.EndNode, relType: op.Edge.Type, sign: 1})
+		case OpDeleteEdge:
+			changes = append(changes, degreeChange{start: op.OldEdge.StartNode, end: op.OldEdge.EndNode, relType: op.OldEdge.Type, sign: -1})
+		}
+	}
+	return changes
+}
+
+// Degree returns how many relationships of the node point in direction
+// with one of types, from the node's degree counters.
+func (b *BadgerEngine) Degree(nodeID NodeID, direction string, types ...string) (int64, error) {
+	if nodeID == "" {
+		return 0, ErrInvalidID
+	}
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return 0, ErrStorageClosed
+	}
+	b.mu.RUnlock()
+
+	n, err := b.degrees.get(nodeID, func() (*nodeDegree, error) { return b.loadDegree(nodeID) })
+	if err != nil {
+		return 0, err
+	}
+	// Counters change under the lock; count while holding it.
+	b.degrees.mu.Lock()
+	defer b.degrees.mu.Unlock()
+	return n.count(direction, types), nil
+}
+
+// loadDegree counts a node's relationships from the adjacency index.
+func (b *BadgerEngine) loadDegree(nodeID NodeID) (*nodeDegree, error) {
+	n := newNodeDegree()
+	err := b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for _, dir := range []struct {
+			prefix []byte
+			start  bool
+		}{{outgoingIndexPrefix(nodeID), true}, {incomingIndexPrefix(nodeID), false}} {
+			for it.Seek(dir.prefix); it.ValidForPrefix(dir.prefix); it.Next() {
+				edgeID := extractEdgeIDFromIndexKey(it.Item().Key())
+				item, err := txn.Get(edgeKey(edgeID))
+				if err != nil {
+					continue // dangling index entry
+				}
+				var edge *Edge
+				if err := item.Value(func(val []byte) error {
+					var decodeErr error
+					edge, decodeErr = decodeEdge(val)
+					return decodeErr
+				}); err != nil {
+					continue
+				}
+				if dir.start {
+					n.add(edge.Type, true, edge.EndNode == nodeID, 1)
+				} else if edge.StartNode != nodeID {
+					n.add(edge.Type, false, true, 1)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Degree delegates to the underlying engine.
+func (w *WALEngine) Degree(nodeID NodeID, direction string, types ...string) (int64, error) {
+	return CountRelationships(w.engine, nodeID, direction, types...)
+}
+
+// Degree delegates to the underlying engine while no relationship writes
+// are waiting to be flushed, and counts the merged relationships otherwise.
+func (ae *AsyncEngine) Degree(nodeID NodeID, direction string, types ...string) (int64, error) {
+	ae.mu.RLock()
+	pending := len(ae.edgeCache) > 0 || len(ae.deleteEdges) > 0 || len(ae.deleteNodes) > 0
+	ae.mu.RUnlock()
+	if pending {
+		return countEdges(ae, nodeID, direction, types)
+	}
+	return CountRelationships(ae.engine, nodeID, direction, types...)
+}
+
+var (
+	_ DegreeEngine = (*BadgerEngine)(nil)
+	_ DegreeEngine = (*WALEngine)(nil)
+	_ DegreeEngine = (*AsyncEngine)(nil)
+)
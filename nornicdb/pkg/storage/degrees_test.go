@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// degreeGraph creates hub with 3 KNOWS out, 2 LIKES in, and a KNOWS loop.
+func degreeGraph(t *testing.T, engine Engine) {
+	for _, id := range []NodeID{"hub", "a", "b", "c"} {
+		require.NoError(t, engine.CreateNode(&Node{ID: id}))
+	}
+	for _, e := range []*Edge{
+		{ID: "k1", Type: "KNOWS", StartNode: "hub", EndNode: "a"},
+		{ID: "k2", Type: "KNOWS", StartNode: "hub", EndNode: "b"},
+		{ID: "k3", Type: "KNOWS", StartNode: "hub", EndNode: "c"},
+		{ID: "l1", Type: "LIKES", StartNode: "a", EndNode: "hub"},
+		{ID: "l2", Type: "LIKES", StartNode: "b", EndNode: "hub"},
+		{ID: "self", Type: "KNOWS", StartNode: "hub", EndNode: "hub"},
+	} {
+		require.NoError(t, engine.CreateEdge(e))
+	}
+}
+
+// assertDegrees checks Degree against counting the relationships.
+func assertDegrees(t *testing.T, engine Engine, id NodeID) {
+	t.Helper()
+	for _, dir := range []string{DirectionOutgoing, DirectionIncoming, DirectionBoth} {
+		for _, types := range [][]string{nil, {"KNOWS"}, {"LIKES"}, {"KNOWS", "LIKES"}, {"MISSING"}} {
+			want, err := countEdges(engine, id, dir, types)
+			require.NoError(t, err)
+			got, err := CountRelationships(engine, id, dir, types...)
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "%s %s %v", id, dir, types)
+		}
+	}
+}
+
+func TestDegree(t *testing.T) {
+	engine := NewMemoryEngine()
+	degreeGraph(t, engine)
+
+	count := func(dir string, types ...string) int64 {
+		n, err := engine.Degree("hub", dir, types...)
+		require.NoError(t, err)
+		return n
+	}
+	assert.Equal(t, int64(4), count(DirectionOutgoing, "KNOWS"))
+	assert.Equal(t, int64(1), count(DirectionIncoming, "KNOWS"))
+	assert.Equal(t, int64(2), count(DirectionIncoming, "LIKES"))
+	assert.Equal(t, int64(6), count(DirectionBoth), "the loop counts once")
+	assert.Equal(t, int64(6), count(DirectionBoth, "KNOWS", "LIKES", "KNOWS"), "repeated types count once")
+	assert.Equal(t, 4, engine.GetOutDegree("hub"))
+	assert.Equal(t, 3, engine.GetInDegree("hub"))
+	assertDegrees(t, engine, "hub")
+
+	t.Run("writes keep counters current", func(t *testing.T) {
+		require.NoError(t, engine.CreateEdge(&Edge{ID: "l3", Type: "LIKES", StartNode: "c", EndNode: "hub"}))
+		require.NoError(t, engine.DeleteEdge("k1"))
+		require.NoError(t, engine.UpdateEdge(&Edge{ID: "k2", Type: "FOLLOWS", StartNode: "hub", EndNode: "b"}))
+		require.NoError(t, engine.UpdateEdge(&Edge{ID: "k3", Type: "KNOWS", StartNode: "a", EndNode: "c"}))
+		require.NoError(t, engine.BulkCreateEdges([]*Edge{{ID: "l4", Type: "LIKES", StartNode: "hub", EndNode: "a"}}))
+		require.NoError(t, engine.BulkDeleteEdges([]EdgeID{"self"}))
+		for _, id := range []NodeID{"hub", "a", "b", "c"} {
+			assertDegrees(t, engine, id)
+		}
+
+		require.NoError(t, engine.DeleteNode("a"))
+		for _, id := range []NodeID{"hub", "b", "c"} {
+			assertDegrees(t, engine, id)
+		}
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		tx, err := engine.BeginTransaction()
+		require.NoError(t, err)
+		require.NoError(t, tx.CreateEdge(&Edge{ID: "t1", Type: "KNOWS", StartNode: "hub", EndNode: "b"}))
+		require.NoError(t, tx.DeleteEdge("l2"))
+		require.NoError(t, tx.Rollback())
+		assertDegrees(t, engine, "hub")
+
+		tx, err = engine.BeginTransaction()
+		require.NoError(t, err)
+		require.NoError(t, tx.CreateEdge(&Edge{ID: "t1", Type: "KNOWS", StartNode: "hub", EndNode: "b"}))
+		require.NoError(t, tx.DeleteEdge("l2"))
+		require.NoError(t, tx.Commit())
+		assertDegrees(t, engine, "hub")
+		assertDegrees(t, engine, "b")
+	})
+}
+
+func TestDegreeConcurrentWrites(t *testing.T) {
+	engine := NewMemoryEngine()
+	require.NoError(t, engine.CreateNode(&Node{ID: "hub"}))
+
+	const writers, perWriter = 8, 50
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_, _ = engine.Degree("hub", DirectionIncoming, "FOLLOWS")
+			}
+		}
+	}()
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				id := NodeID(fmt.Sprintf("u%d-%d", w, i))
+				assert.NoError(t, engine.CreateNode(&Node{ID: id}))
+				assert.NoError(t, engine.CreateEdge(&Edge{ID: EdgeID(id), Type: "FOLLOWS", StartNode: id, EndNode: "hub"}))
+				if i%5 == 0 {
+					assert.NoError(t, engine.DeleteEdge(EdgeID(id)))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(stop)
+
+	got, err := engine.Degree("hub", DirectionIncoming, "FOLLOWS")
+	require.NoError(t, err)
+	assert.Equal(t, int64(writers*perWriter*4/5), got)
+	assertDegrees(t, engine, "hub")
+}
+
+func TestAsyncEngineDegree(t *testing.T) {
+	config := DefaultAsyncEngineConfig()
+	config.FlushInterval = time.Hour
+	engine := NewAsyncEngine(NewMemoryEngine(), config)
+	defer engine.Close()
+	degreeGraph(t, engine)
+
+	assertDegrees(t, engine, "hub") // pending writes
+	require.NoError(t, engine.Flush())
+	assertDegrees(t, engine, "hub")
+	require.NoError(t, engine.DeleteEdge("l1"))
+	assertDegrees(t, engine, "hub")
+}
@@ -43,6 +43,19 @@ const (
 	SignalTemporal     SignalType = "temporal"   // Temporal proximity
 )
 
+// Provenance properties set on edges created by the inference engine.
+// They travel with the edge, so CALL db.tlp.explain(edgeId) can rebuild the
+// decision chain after a restart.
+const (
+	EdgePropMethod      = "method"           // Contributing methods, e.g. "similarity,adamic_adar"
+	EdgePropReason      = "reason"           // Human-readable reason
+	EdgePropScores      = "tlp_scores"       // Method -> score in [0, 1]
+	EdgePropWeights     = "tlp_weights"      // Method -> ensemble weight
+	EdgePropQCDecision  = "tlp_qc_decision"  // Heimdall QC outcome
+	EdgePropQCReasoning = "tlp_qc_reasoning" // Heimdall QC explanation
+	EdgePropPromptHash  = "tlp_prompt_hash"  // SHA-256 of the QC prompt
+)
+
 // EdgeMeta stores provenance for auto-generated edges.
 // This is append-only for auditability.
 type EdgeMeta struct {
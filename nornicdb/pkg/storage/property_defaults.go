@@ -0,0 +1,168 @@
+// Package storage - default and generated property values.
+//
+// A property definition in the PropertySchema may carry a default, which is
+// set on nodes and relationships created without the property, whatever the
+// schema mode. Defaults are either literals or generated per write:
+//
+//	createdAt: INTEGER DEFAULT timestamp()   // Unix milliseconds
+//	id:        STRING DEFAULT randomUUID()
+//	status:    STRING DEFAULT 'draft'
+//
+// Defaults are applied by every engine in the stack before it logs or
+// caches a create, so a generated value is fixed once, when the write is
+// accepted, and WAL replay restores the same value.
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// propertyGenerators are the functions a default can call, by lowercase
+// name. Their results match the Cypher functions of the same name.
+var propertyGenerators = map[string]struct {
+	name string
+	gen  func() any
+}{
+	"timestamp":  {"timestamp()", func() any { return time.Now().UnixMilli() }},
+	"datetime":   {"datetime()", func() any { return time.Now().Format(time.RFC3339) }},
+	"date":       {"date()", func() any { return time.Now().Format("2006-01-02") }},
+	"randomuuid": {"randomUUID()", func() any { return uuid.NewString() }},
+}
+
+// PropertyDefault is the default value of a property: a literal, or a
+// value generated on each create.
+type PropertyDefault struct {
+	expr  string
+	value any
+	gen   func() any
+}
+
+// ParsePropertyDefault parses a default: a quoted string, a number, true,
+// false, or one of timestamp(), datetime(), date() and randomUUID().
+func ParsePropertyDefault(s string) (*PropertyDefault, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("missing default value")
+	case strings.HasSuffix(s, "()"):
+		g, ok := propertyGenerators[strings.ToLower(strings.TrimSpace(strings.TrimSuffix(s, "()")))]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %s: expected timestamp(), datetime(), date() or randomUUID()", s)
+		}
+		return &PropertyDefault{expr: g.name, gen: g.gen}, nil
+	case len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0]:
+		return &PropertyDefault{expr: s, value: s[1 : len(s)-1]}, nil
+	case strings.EqualFold(s, "true"), strings.EqualFold(s, "false"):
+		return &PropertyDefault{expr: strings.ToLower(s), value: strings.EqualFold(s, "true")}, nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return &PropertyDefault{expr: s, value: i}, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return &PropertyDefault{expr: s, value: f}, nil
+	}
+	return nil, fmt.Errorf("invalid default %q: expected a quoted string, number, boolean or generator function", s)
+}
+
+// Value returns the default, generating a new one for generator defaults.
+func (d *PropertyDefault) Value() any {
+	if d.gen != nil {
+		return d.gen()
+	}
+	return d.value
+}
+
+// String returns the default as written, e.g. "timestamp()" or "'draft'".
+func (d *PropertyDefault) String() string {
+	return d.expr
+}
+
+// defaultKeywordIndex returns the index of the DEFAULT keyword in a property
+// definition, or -1. Quoted literals after it may contain any text.
+func defaultKeywordIndex(s string) int {
+	upper := strings.ToUpper(s)
+	for i := strings.Index(upper, "DEFAULT"); i >= 0; {
+		end := i + len("DEFAULT")
+		if (i == 0 || upper[i-1] == ' ') && (end == len(upper) || upper[end] == ' ') {
+			return i
+		}
+		next := strings.Index(upper[end:], "DEFAULT")
+		if next < 0 {
+			break
+		}
+		i = end + next
+	}
+	return -1
+}
+
+// ApplyNodeDefaults sets the defaults of node's label schemas on properties
+// it does not have. The properties map is replaced rather than modified,
+// since callers may share one map between nodes.
+func (s *PropertySchema) ApplyNodeDefaults(node *Node) {
+	if s == nil || node == nil {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.labels) == 0 {
+		return
+	}
+	for _, label := range node.Labels {
+		if schema, ok := s.labels[strings.ToLower(label)]; ok {
+			node.Properties = applyDefaults(schema, node.Properties)
+		}
+	}
+}
+
+// ApplyEdgeDefaults sets the defaults of edge's type schema on properties it
+// does not have, replacing the properties map like ApplyNodeDefaults.
+func (s *PropertySchema) ApplyEdgeDefaults(edge *Edge) {
+	if s == nil || edge == nil {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if schema, ok := s.relTypes[strings.ToLower(edge.Type)]; ok {
+		edge.Properties = applyDefaults(schema, edge.Properties)
+	}
+}
+
+// applyDefaults returns props with the schema's missing defaults set,
+// copying props first if any are.
+func applyDefaults(schema EntitySchema, props map[string]any) map[string]any {
+	copied := false
+	for name, def := range schema.Properties {
+		if def.Default == nil {
+			continue
+		}
+		if v, ok := props[name]; ok && v != nil {
+			continue
+		}
+		if !copied {
+			out := make(map[string]any, len(props)+1)
+			for k, v := range props {
+				out[k] = v
+			}
+			props, copied = out, true
+		}
+		props[name] = def.Default.Value()
+	}
+	return props
+}
+
+// applyDefaults sets property defaults on creates before they are cached,
+// so reads from the cache see them and they are counted against quotas.
+func (ae *AsyncEngine) applyDefaults(nodes []*Node, edges []*Edge) {
+	schema := ae.PropertySchema()
+	for _, node := range nodes {
+		schema.ApplyNodeDefaults(node)
+	}
+	for _, edge := range edges {
+		schema.ApplyEdgeDefaults(edge)
+	}
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postSchema(t *testing.T) EntitySchema {
+	t.Helper()
+	schema := EntitySchema{Properties: map[string]PropertyDefinition{}}
+	for name, spec := range map[string]string{
+		"createdAt": "INTEGER NOT NULL DEFAULT timestamp()",
+		"id":        "STRING DEFAULT randomUUID()",
+		"status":    "DEFAULT 'draft'",
+	} {
+		def, err := ParsePropertyDefinition(spec)
+		require.NoError(t, err, spec)
+		schema.Properties[name] = def
+	}
+	return schema
+}
+
+func TestParsePropertyDefault(t *testing.T) {
+	tests := []struct {
+		in   string
+		want any
+		expr string
+	}{
+		{"'draft'", "draft", "'draft'"},
+		{`"it's"`, "it's", `"it's"`},
+		{"42", int64(42), "42"},
+		{"0.5", 0.5, "0.5"},
+		{"TRUE", true, "true"},
+	}
+	for _, tt := range tests {
+		d, err := ParsePropertyDefault(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, d.Value(), tt.in)
+		assert.Equal(t, tt.expr, d.String(), tt.in)
+	}
+
+	d, err := ParsePropertyDefault("randomuuid()")
+	require.NoError(t, err)
+	assert.Equal(t, "randomUUID()", d.String())
+	assert.NotEqual(t, d.Value(), d.Value(), "generated per call")
+
+	d, err = ParsePropertyDefault("timestamp()")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().UnixMilli(), d.Value(), 5000)
+
+	for _, bad := range []string{"", "now()", "draft", "'unclosed"} {
+		_, err := ParsePropertyDefault(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParsePropertyDefinition_Default(t *testing.T) {
+	def, err := ParsePropertyDefinition("string not null default 'a DEFAULT b'")
+	require.NoError(t, err)
+	assert.Equal(t, PropertyTypeString, def.Type)
+	assert.True(t, def.Required)
+	assert.Equal(t, "a DEFAULT b", def.Default.Value())
+	assert.Equal(t, "STRING NOT NULL DEFAULT 'a DEFAULT b'", def.String())
+
+	def, err = ParsePropertyDefinition("DEFAULT date()")
+	require.NoError(t, err)
+	assert.Empty(t, def.Type)
+	assert.Equal(t, "ANY DEFAULT date()", def.String())
+
+	// The default must match the type
+	_, err = ParsePropertyDefinition("INTEGER DEFAULT randomUUID()")
+	assert.Error(t, err)
+	_, err = ParsePropertyDefinition("DATE DEFAULT 'soon'")
+	assert.Error(t, err)
+	_, err = ParsePropertyDefinition("STRING DEFAULT")
+	assert.Error(t, err)
+}
+
+func TestPropertyDefaults_Create(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	engine.PropertySchema().DefineLabel("Post", postSchema(t))
+	engine.PropertySchema().DefineRelationshipType("TAGGED", EntitySchema{
+		Properties: map[string]PropertyDefinition{"weight": {Default: &PropertyDefault{expr: "1.0", value: 1.0}}},
+	})
+
+	// Defaults apply in off mode, and only to missing properties
+	shared := map[string]any{"title": "Hello", "status": "published"}
+	require.NoError(t, engine.CreateNode(&Node{ID: "p1", Labels: []string{"post"}, Properties: shared}))
+	require.NoError(t, engine.BulkCreateNodes([]*Node{
+		{ID: "p2", Labels: []string{"Post"}, Properties: shared},
+		{ID: "other", Labels: []string{"Other"}},
+	}))
+	assert.Len(t, shared, 2, "callers' maps are not modified")
+
+	p1, err := engine.GetNode("p1")
+	require.NoError(t, err)
+	p2, err := engine.GetNode("p2")
+	require.NoError(t, err)
+	assert.Equal(t, "published", p1.Properties["status"])
+	assert.IsType(t, int64(0), p1.Properties["createdAt"])
+	assert.Len(t, p1.Properties["id"], 36)
+	assert.NotEqual(t, p1.Properties["id"], p2.Properties["id"], "each create gets its own value")
+	other, err := engine.GetNode("other")
+	require.NoError(t, err)
+	assert.Empty(t, other.Properties)
+
+	// Updates keep what they are given
+	p1.Properties = map[string]any{"title": "Edited"}
+	require.NoError(t, engine.UpdateNode(p1))
+	p1, _ = engine.GetNode("p1")
+	assert.Equal(t, map[string]any{"title": "Edited"}, p1.Properties)
+
+	require.NoError(t, engine.CreateEdge(&Edge{ID: "e1", StartNode: "p1", EndNode: "p2", Type: "TAGGED"}))
+	e1, err := engine.GetEdge("e1")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, e1.Properties["weight"])
+
+	// A required property with a default is satisfied in strict mode
+	engine.PropertySchema().SetMode(SchemaModeStrict)
+	require.NoError(t, engine.CreateNode(&Node{ID: "p3", Labels: []string{"Post"}}))
+}
+
+func TestPropertyDefaults_Transaction(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	engine.PropertySchema().DefineLabel("Post", postSchema(t))
+
+	tx, err := engine.BeginTransaction()
+	require.NoError(t, err)
+	node := &Node{ID: "p1", Labels: []string{"Post"}}
+	require.NoError(t, tx.CreateNode(node))
+	assert.Equal(t, "draft", node.Properties["status"], "visible to the writer before commit")
+	require.NoError(t, tx.Commit())
+
+	stored, err := engine.GetNode("p1")
+	require.NoError(t, err)
+	assert.Equal(t, node.Properties["id"], stored.Properties["id"])
+}
+
+func TestPropertyDefaults_AsyncEngine(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	engine.PropertySchema().DefineLabel("Post", postSchema(t))
+	ae := NewAsyncEngine(engine, &AsyncEngineConfig{FlushInterval: time.Hour})
+	defer ae.Close()
+
+	require.NoError(t, ae.CreateNode(&Node{ID: "p1", Labels: []string{"Post"}}))
+	cached, err := ae.GetNode("p1")
+	require.NoError(t, err)
+	id := cached.Properties["id"]
+	require.NotNil(t, id)
+
+	require.NoError(t, ae.Flush())
+	stored, err := engine.GetNode("p1")
+	require.NoError(t, err)
+	assert.Equal(t, id, stored.Properties["id"], "generated once, when the write is accepted")
+}
+
+func TestPropertyDefaults_WALReplay(t *testing.T) {
+	walDir := filepath.Join(t.TempDir(), "wal")
+	wal, err := NewWAL("", &WALConfig{Dir: walDir, SyncMode: "immediate"})
+	require.NoError(t, err)
+	engine := NewMemoryEngine()
+	engine.PropertySchema().DefineLabel("Post", postSchema(t))
+	walEngine := NewWALEngine(engine, wal)
+
+	require.NoError(t, walEngine.CreateNode(&Node{ID: "p1", Labels: []string{"Post"}}))
+	stored, err := engine.GetNode("p1")
+	require.NoError(t, err)
+	require.NoError(t, walEngine.Close())
+
+	recovered, err := RecoverFromWAL(walDir, "")
+	require.NoError(t, err)
+	defer recovered.Close()
+	replayed, err := recovered.GetNode("p1")
+	require.NoError(t, err)
+	assert.Equal(t, stored.Properties["id"], replayed.Properties["id"])
+	assert.EqualValues(t, stored.Properties["createdAt"], replayed.Properties["createdAt"])
+}
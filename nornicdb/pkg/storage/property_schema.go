@@ -0,0 +1,551 @@
+// Package storage - property schema registry for NornicDB.
+//
+// A PropertySchema describes the properties expected on nodes of a label or
+// relationships of a type: their names, types and whether they are
+// required. Schemas are opt-in and only cover the labels and types defined
+// in them; other data is unaffected. Checks run when writes are applied:
+//
+//   - off (default): nothing is checked
+//   - warn: violating writes are stored and reported to OnViolation
+//     observers, which feed Heimdall events and the audit log
+//   - strict: violating writes fail with an error wrapping
+//     ErrSchemaViolation and nothing is stored; they are reported too
+//
+// A closed schema also flags properties it does not declare, which catches
+// misspelled or invented property names in generated writes. Properties
+// starting with "_" are internal and never checked.
+//
+// Properties may also have defaults, set on creates that lack them in every
+// mode, including off (see property_defaults.go).
+//
+// Example:
+//
+//	schema := engine.PropertySchema()
+//	schema.DefineLabel("Person", storage.EntitySchema{
+//		Properties: map[string]storage.PropertyDefinition{
+//			"name": {Type: storage.PropertyTypeString, Required: true},
+//			"age":  {Type: storage.PropertyTypeInteger},
+//		},
+//		Closed: true,
+//	})
+//	schema.SetMode(storage.SchemaModeStrict)
+//
+//	err := engine.CreateNode(&storage.Node{ID: "p1", Labels: []string{"Person"},
+//		Properties: map[string]any{"name": "Ada", "age": "36"}})
+//	// errors.Is(err, storage.ErrSchemaViolation): age expected INTEGER, got string
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSchemaViolation is returned (wrapped in a *SchemaViolationError) when
+// a write violates the property schema in strict mode.
+var ErrSchemaViolation = errors.New("property schema violation")
+
+// SchemaMode controls how property schema violations are handled.
+type SchemaMode string
+
+const (
+	SchemaModeOff    SchemaMode = "off"
+	SchemaModeWarn   SchemaMode = "warn"
+	SchemaModeStrict SchemaMode = "strict"
+)
+
+// ParseSchemaMode parses off, warn or strict (case-insensitive). The empty
+// string is off.
+func ParseSchemaMode(s string) (SchemaMode, error) {
+	switch mode := SchemaMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return SchemaModeOff, nil
+	case SchemaModeOff, SchemaModeWarn, SchemaModeStrict:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid schema mode %q: expected off, warn or strict", s)
+	}
+}
+
+// PropertyDefinition is the expected type of one property.
+type PropertyDefinition struct {
+	Type     PropertyType     // Empty = any type
+	Required bool             // Must be present and non-null
+	Default  *PropertyDefault // Set on create when missing, nil = none
+}
+
+// String returns the definition in the form ParsePropertyDefinition
+// accepts, e.g. "INTEGER NOT NULL DEFAULT timestamp()".
+func (d PropertyDefinition) String() string {
+	t := string(d.Type)
+	if t == "" {
+		t = "ANY"
+	}
+	if d.Required {
+		t += " NOT NULL"
+	}
+	if d.Default != nil {
+		t += " DEFAULT " + d.Default.String()
+	}
+	return t
+}
+
+// ParsePropertyDefinition parses a type such as "STRING", "INTEGER NOT NULL"
+// or "ANY", optionally followed by DEFAULT and a default value (see
+// ParsePropertyDefault), e.g. "STRING DEFAULT randomUUID()". Supported types
+// are STRING, INTEGER, FLOAT, BOOLEAN, DATE, DATETIME, LIST and ANY; the
+// type may be left out before DEFAULT.
+func ParsePropertyDefinition(s string) (PropertyDefinition, error) {
+	var d PropertyDefinition
+	s = strings.TrimSpace(s)
+	if i := defaultKeywordIndex(s); i >= 0 {
+		def, err := ParsePropertyDefault(s[i+len("DEFAULT"):])
+		if err != nil {
+			return PropertyDefinition{}, fmt.Errorf("invalid default in %q: %w", s, err)
+		}
+		d.Default = def
+		if s = strings.TrimSpace(s[:i]); s == "" {
+			s = "ANY"
+		}
+	}
+	spec := strings.ToUpper(strings.Join(strings.Fields(s), " "))
+	if rest, ok := strings.CutSuffix(spec, " NOT NULL"); ok {
+		d.Required = true
+		spec = rest
+	} else if rest, ok := strings.CutSuffix(spec, "!"); ok {
+		d.Required = true
+		spec = strings.TrimSpace(rest)
+	}
+	switch t := PropertyType(spec); t {
+	case "ANY":
+	case PropertyTypeString, PropertyTypeInteger, PropertyTypeFloat, PropertyTypeBoolean,
+		PropertyTypeDate, PropertyTypeDateTime, PropertyTypeList:
+		d.Type = t
+	default:
+		return PropertyDefinition{}, fmt.Errorf("invalid property type %q: expected STRING, INTEGER, FLOAT, BOOLEAN, DATE, DATETIME, LIST or ANY, optionally followed by NOT NULL", s)
+	}
+	if d.Default != nil && d.Type != "" {
+		if err := ValidatePropertyType(d.Default.Value(), d.Type); err != nil {
+			return PropertyDefinition{}, fmt.Errorf("invalid default %s: %w", d.Default, err)
+		}
+	}
+	return d, nil
+}
+
+// EntitySchema describes the properties of a node label or relationship
+// type.
+type EntitySchema struct {
+	// Name is the label or relationship type, as defined.
+	Name       string
+	Properties map[string]PropertyDefinition
+	// Closed flags properties not declared in Properties.
+	Closed bool
+}
+
+// SchemaViolation is one property that does not match the schema.
+type SchemaViolation struct {
+	Kind     string // "node" or "relationship"
+	ID       string // Node or relationship ID
+	Label    string // Label or relationship type whose schema was violated
+	Property string
+	Reason   string
+	Rejected bool // The write was rejected (strict mode)
+}
+
+func (v SchemaViolation) String() string {
+	return fmt.Sprintf("%s %s: %s.%s %s", v.Kind, v.ID, v.Label, v.Property, v.Reason)
+}
+
+// SchemaViolationError is returned for writes rejected in strict mode.
+type SchemaViolationError struct {
+	Violations []SchemaViolation
+}
+
+func (e *SchemaViolationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.String()
+	}
+	return fmt.Sprintf("%v: %s", ErrSchemaViolation, strings.Join(parts, "; "))
+}
+
+// Unwrap makes errors.Is(err, ErrSchemaViolation) match.
+func (e *SchemaViolationError) Unwrap() error {
+	return ErrSchemaViolation
+}
+
+// PropertySchema is a registry of node label and relationship type schemas.
+// It is safe for concurrent use. A nil *PropertySchema checks nothing.
+type PropertySchema struct {
+	mu        sync.RWMutex
+	mode      SchemaMode
+	labels    map[string]EntitySchema // key: lowercase label
+	relTypes  map[string]EntitySchema // key: lowercase relationship type
+	observers []func(SchemaViolation)
+
+	warned   atomic.Int64
+	rejected atomic.Int64
+}
+
+// NewPropertySchema returns an empty registry in off mode.
+func NewPropertySchema() *PropertySchema {
+	return &PropertySchema{
+		mode:     SchemaModeOff,
+		labels:   make(map[string]EntitySchema),
+		relTypes: make(map[string]EntitySchema),
+	}
+}
+
+// PropertySchemaEngine is implemented by engines that check writes against
+// a property schema.
+type PropertySchemaEngine interface {
+	PropertySchema() *PropertySchema
+}
+
+// Mode returns the current mode.
+func (s *PropertySchema) Mode() SchemaMode {
+	if s == nil {
+		return SchemaModeOff
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mode
+}
+
+// SetMode sets how violations are handled from the next write on.
+func (s *PropertySchema) SetMode(mode SchemaMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mode = mode
+}
+
+// DefineLabel sets the schema for nodes with label, replacing any previous
+// one. Labels match case-insensitively.
+func (s *PropertySchema) DefineLabel(label string, schema EntitySchema) {
+	schema.Name = label
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.labels[strings.ToLower(label)] = schema
+}
+
+// DefineRelationshipType sets the schema for relationships of relType,
+// replacing any previous one. Types match case-insensitively.
+func (s *PropertySchema) DefineRelationshipType(relType string, schema EntitySchema) {
+	schema.Name = relType
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.relTypes[strings.ToLower(relType)] = schema
+}
+
+// DropLabel removes the schema for label. It returns false if none was
+// defined.
+func (s *PropertySchema) DropLabel(label string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(label)
+	_, ok := s.labels[key]
+	delete(s.labels, key)
+	return ok
+}
+
+// DropRelationshipType removes the schema for relType. It returns false if
+// none was defined.
+func (s *PropertySchema) DropRelationshipType(relType string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(relType)
+	_, ok := s.relTypes[key]
+	delete(s.relTypes, key)
+	return ok
+}
+
+// Labels returns the label schemas sorted by name.
+func (s *PropertySchema) Labels() []EntitySchema {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedSchemas(s.labels)
+}
+
+// RelationshipTypes returns the relationship type schemas sorted by name.
+func (s *PropertySchema) RelationshipTypes() []EntitySchema {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedSchemas(s.relTypes)
+}
+
+func sortedSchemas(m map[string]EntitySchema) []EntitySchema {
+	out := make([]EntitySchema, 0, len(m))
+	for _, schema := range m {
+		out = append(out, schema)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// OnViolation registers fn to be called for every violation found in warn
+// or strict mode. fn runs on the writing goroutine and must not block.
+func (s *PropertySchema) OnViolation(fn func(SchemaViolation)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, fn)
+}
+
+// Stats returns how many violating writes were let through in warn mode
+// and rejected in strict mode.
+func (s *PropertySchema) Stats() (warned, rejected int64) {
+	if s == nil {
+		return 0, 0
+	}
+	return s.warned.Load(), s.rejected.Load()
+}
+
+// CheckNode checks node against the schemas of its labels. In strict mode
+// a violating node returns a *SchemaViolationError.
+func (s *PropertySchema) CheckNode(node *Node) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.mode == SchemaModeOff || len(s.labels) == 0 {
+		return nil
+	}
+
+	var schemas []EntitySchema
+	for _, label := range node.Labels {
+		if schema, ok := s.labels[strings.ToLower(label)]; ok {
+			schemas = append(schemas, schema)
+		}
+	}
+	return s.report(checkProperties("node", string(node.ID), schemas, node.Properties))
+}
+
+// CheckEdge checks edge against the schema of its type. In strict mode a
+// violating edge returns a *SchemaViolationError.
+func (s *PropertySchema) CheckEdge(edge *Edge) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.mode == SchemaModeOff || len(s.relTypes) == 0 {
+		return nil
+	}
+
+	schema, ok := s.relTypes[strings.ToLower(edge.Type)]
+	if !ok {
+		return nil
+	}
+	return s.report(checkProperties("relationship", string(edge.ID), []EntitySchema{schema}, edge.Properties))
+}
+
+// report notifies observers of violations and, in strict mode, returns
+// them as an error. Caller must hold s.mu for reading.
+func (s *PropertySchema) report(violations []SchemaViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	strict := s.mode == SchemaModeStrict
+	if strict {
+		s.rejected.Add(1)
+	} else {
+		s.warned.Add(1)
+	}
+	for i := range violations {
+		violations[i].Rejected = strict
+		for _, fn := range s.observers {
+			fn(violations[i])
+		}
+	}
+	if strict {
+		return &SchemaViolationError{Violations: violations}
+	}
+	return nil
+}
+
+// checkProperties checks props against schemas. With several schemas (a
+// node with several defined labels), a property declared by any of them is
+// known to a closed schema.
+func checkProperties(kind, id string, schemas []EntitySchema, props map[string]any) []SchemaViolation {
+	if len(schemas) == 0 {
+		return nil
+	}
+	var violations []SchemaViolation
+	add := func(label, property, reason string) {
+		violations = append(violations, SchemaViolation{Kind: kind, ID: id, Label: label, Property: property, Reason: reason})
+	}
+
+	for _, schema := range schemas {
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			def := schema.Properties[name]
+			value, ok := props[name]
+			if !ok || value == nil {
+				if def.Required {
+					add(schema.Name, name, "is required")
+				}
+				continue
+			}
+			if def.Type != "" {
+				if err := ValidatePropertyType(value, def.Type); err != nil {
+					add(schema.Name, name, err.Error())
+				}
+			}
+		}
+	}
+
+	var unknown []string
+	for name := range props {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+		declared, closed := false, ""
+		for _, schema := range schemas {
+			if _, ok := schema.Properties[name]; ok {
+				declared = true
+				break
+			}
+			if schema.Closed && closed == "" {
+				closed = schema.Name
+			}
+		}
+		if !declared && closed != "" {
+			unknown = append(unknown, name+"\x00"+closed)
+		}
+	}
+	sort.Strings(unknown)
+	for _, u := range unknown {
+		name, label, _ := strings.Cut(u, "\x00")
+		add(label, name, "is not declared")
+	}
+	return violations
+}
+
+// propertySchemaFile is the JSON form read by Load.
+type propertySchemaFile struct {
+	Mode          string                      `json:"mode,omitempty"`
+	Labels        map[string]entitySchemaFile `json:"labels"`
+	Relationships map[string]entitySchemaFile `json:"relationships"`
+}
+
+type entitySchemaFile struct {
+	Properties map[string]string `json:"properties"`
+	Closed     bool              `json:"closed,omitempty"`
+}
+
+// Load defines the label and relationship type schemas in a JSON document,
+// and sets the mode if it has one:
+//
+//	{
+//	  "mode": "warn",
+//	  "labels": {
+//	    "Person": {"properties": {"name": "STRING NOT NULL", "age": "INTEGER"}, "closed": true}
+//	  },
+//	  "relationships": {
+//	    "KNOWS": {"properties": {"since": "DATE"}}
+//	  }
+//	}
+//
+// Nothing is defined if any entry is invalid.
+func (s *PropertySchema) Load(data []byte) error {
+	var file propertySchemaFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parsing property schema: %w", err)
+	}
+	mode, err := ParseSchemaMode(file.Mode)
+	if err != nil {
+		return err
+	}
+
+	parse := func(kind string, entries map[string]entitySchemaFile) (map[string]EntitySchema, error) {
+		out := make(map[string]EntitySchema, len(entries))
+		for name, entry := range entries {
+			schema := EntitySchema{Properties: make(map[string]PropertyDefinition, len(entry.Properties)), Closed: entry.Closed}
+			for prop, spec := range entry.Properties {
+				def, err := ParsePropertyDefinition(spec)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s property %s: %w", kind, name, prop, err)
+				}
+				schema.Properties[prop] = def
+			}
+			out[name] = schema
+		}
+		return out, nil
+	}
+	labels, err := parse("label", file.Labels)
+	if err != nil {
+		return err
+	}
+	relTypes, err := parse("relationship", file.Relationships)
+	if err != nil {
+		return err
+	}
+
+	for name, schema := range labels {
+		s.DefineLabel(name, schema)
+	}
+	for name, schema := range relTypes {
+		s.DefineRelationshipType(name, schema)
+	}
+	if file.Mode != "" {
+		s.SetMode(mode)
+	}
+	return nil
+}
+
+// PropertySchema returns the engine's property schema registry.
+func (b *BadgerEngine) PropertySchema() *PropertySchema {
+	return b.propertySchema
+}
+
+// PropertySchema returns the property schema of the underlying engine.
+func (w *WALEngine) PropertySchema() *PropertySchema {
+	if pe, ok := w.engine.(PropertySchemaEngine); ok {
+		return pe.PropertySchema()
+	}
+	return nil
+}
+
+// PropertySchema returns the property schema of the underlying engine.
+func (ae *AsyncEngine) PropertySchema() *PropertySchema {
+	if pe, ok := ae.engine.(PropertySchemaEngine); ok {
+		return pe.PropertySchema()
+	}
+	return nil
+}
+
+// admitSchema checks cached writes in strict mode, so violations are
+// rejected when written rather than dropped at flush. In warn mode they are
+// reported when flushed to the underlying engine instead, once.
+func (ae *AsyncEngine) admitSchema(nodes []*Node, edges []*Edge) error {
+	schema := ae.PropertySchema()
+	if schema.Mode() != SchemaModeStrict {
+		return nil
+	}
+	for _, node := range nodes {
+		if err := schema.CheckNode(node); err != nil {
+			return err
+		}
+	}
+	for _, edge := range edges {
+		if err := schema.CheckEdge(edge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ensure property schema support is wired through the engine stack.
+var (
+	_ PropertySchemaEngine = (*BadgerEngine)(nil)
+	_ PropertySchemaEngine = (*WALEngine)(nil)
+	_ PropertySchemaEngine = (*AsyncEngine)(nil)
+)
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func personSchema() EntitySchema {
+	return EntitySchema{
+		Properties: map[string]PropertyDefinition{
+			"name": {Type: PropertyTypeString, Required: true},
+			"age":  {Type: PropertyTypeInteger},
+		},
+		Closed: true,
+	}
+}
+
+func person(id string, props map[string]any) *Node {
+	return &Node{ID: NodeID(id), Labels: []string{"Person"}, Properties: props}
+}
+
+func TestParsePropertyDefinition(t *testing.T) {
+	tests := []struct {
+		in   string
+		want PropertyDefinition
+	}{
+		{"STRING", PropertyDefinition{Type: PropertyTypeString}},
+		{"integer not null", PropertyDefinition{Type: PropertyTypeInteger, Required: true}},
+		{"DATE!", PropertyDefinition{Type: PropertyTypeDate, Required: true}},
+		{"  list ", PropertyDefinition{Type: PropertyTypeList}},
+		{"ANY", PropertyDefinition{}},
+		{"any NOT  NULL", PropertyDefinition{Required: true}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePropertyDefinition(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+	assert.Equal(t, "INTEGER NOT NULL", PropertyDefinition{Type: PropertyTypeInteger, Required: true}.String())
+	assert.Equal(t, "ANY", PropertyDefinition{}.String())
+
+	_, err := ParsePropertyDefinition("MAP")
+	assert.Error(t, err)
+}
+
+func TestParseSchemaMode(t *testing.T) {
+	mode, err := ParseSchemaMode("")
+	require.NoError(t, err)
+	assert.Equal(t, SchemaModeOff, mode)
+	mode, err = ParseSchemaMode("Strict")
+	require.NoError(t, err)
+	assert.Equal(t, SchemaModeStrict, mode)
+	_, err = ParseSchemaMode("loose")
+	assert.Error(t, err)
+}
+
+func TestPropertySchema_Strict(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	schema := engine.PropertySchema()
+	schema.DefineLabel("Person", personSchema())
+	schema.SetMode(SchemaModeStrict)
+
+	require.NoError(t, engine.CreateNode(person("ok", map[string]any{"name": "Ada", "age": int64(36), "_internal": 1})))
+
+	err := engine.CreateNode(person("bad", map[string]any{"age": "36", "nmae": "Ada"}))
+	require.ErrorIs(t, err, ErrSchemaViolation)
+	var se *SchemaViolationError
+	require.True(t, errors.As(err, &se))
+	require.Len(t, se.Violations, 3)
+	assert.Equal(t, "age", se.Violations[0].Property)
+	assert.Equal(t, "name", se.Violations[1].Property)
+	assert.Equal(t, "is required", se.Violations[1].Reason)
+	assert.Equal(t, "nmae", se.Violations[2].Property)
+	assert.Equal(t, "is not declared", se.Violations[2].Reason)
+	assert.True(t, se.Violations[0].Rejected)
+
+	_, err = engine.GetNode("bad")
+	assert.ErrorIs(t, err, ErrNotFound, "rejected writes are not stored")
+
+	// Updates, bulk creates and other labels
+	assert.ErrorIs(t, engine.UpdateNode(person("ok", map[string]any{"name": 1})), ErrSchemaViolation)
+	assert.ErrorIs(t, engine.BulkCreateNodes([]*Node{person("b1", map[string]any{"name": "x"}), person("b2", nil)}), ErrSchemaViolation)
+	require.NoError(t, engine.CreateNode(&Node{ID: "c", Labels: []string{"City"}, Properties: map[string]any{"anything": true}}))
+
+	_, rejected := schema.Stats()
+	assert.Equal(t, int64(3), rejected)
+}
+
+func TestPropertySchema_WarnReportsViolations(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	schema := engine.PropertySchema()
+	schema.DefineLabel("person", personSchema())
+	schema.SetMode(SchemaModeWarn)
+
+	var seen []SchemaViolation
+	schema.OnViolation(func(v SchemaViolation) { seen = append(seen, v) })
+
+	require.NoError(t, engine.CreateNode(person("p", map[string]any{"name": "Ada", "age": 36.5})))
+	_, err := engine.GetNode("p")
+	require.NoError(t, err, "warn mode stores the write")
+
+	require.Len(t, seen, 1)
+	assert.Equal(t, SchemaViolation{Kind: "node", ID: "p", Label: "person", Property: "age",
+		Reason: seen[0].Reason}, seen[0])
+	warned, rejected := schema.Stats()
+	assert.Equal(t, int64(1), warned)
+	assert.Zero(t, rejected)
+
+	// Off mode checks nothing
+	schema.SetMode(SchemaModeOff)
+	require.NoError(t, engine.CreateNode(person("q", nil)))
+	assert.Len(t, seen, 1)
+}
+
+func TestPropertySchema_MultipleLabelsAndRelationships(t *testing.T) {
+	schema := NewPropertySchema()
+	schema.DefineLabel("Person", personSchema())
+	schema.DefineLabel("Employee", EntitySchema{Properties: map[string]PropertyDefinition{"salary": {Type: PropertyTypeFloat}}})
+	schema.DefineRelationshipType("KNOWS", EntitySchema{
+		Properties: map[string]PropertyDefinition{"since": {Type: PropertyTypeDate, Required: true}},
+	})
+	schema.SetMode(SchemaModeStrict)
+
+	// A property declared by any of the node's schemas is known
+	node := &Node{ID: "e", Labels: []string{"Person", "Employee"}, Properties: map[string]any{"name": "Ada", "salary": 1.5}}
+	assert.NoError(t, schema.CheckNode(node))
+
+	assert.NoError(t, schema.CheckEdge(&Edge{ID: "k", Type: "knows", Properties: map[string]any{"since": "2020-01-31"}}))
+	assert.ErrorIs(t, schema.CheckEdge(&Edge{ID: "k", Type: "KNOWS", Properties: map[string]any{"since": "yesterday"}}), ErrSchemaViolation)
+	assert.NoError(t, schema.CheckEdge(&Edge{ID: "l", Type: "LIKES"}))
+
+	assert.True(t, schema.DropRelationshipType("Knows"))
+	assert.False(t, schema.DropRelationshipType("KNOWS"))
+	assert.NoError(t, schema.CheckEdge(&Edge{ID: "k", Type: "KNOWS"}))
+	assert.Len(t, schema.Labels(), 2)
+	assert.Equal(t, "Employee", schema.Labels()[0].Name)
+
+	var nilSchema *PropertySchema
+	assert.NoError(t, nilSchema.CheckNode(node))
+	assert.Equal(t, SchemaModeOff, nilSchema.Mode())
+}
+
+func TestPropertySchema_Load(t *testing.T) {
+	schema := NewPropertySchema()
+	require.NoError(t, schema.Load([]byte(`{
+		"mode": "warn",
+		"labels": {"Person": {"properties": {"name": "STRING NOT NULL", "tags": "LIST"}, "closed": true}},
+		"relationships": {"KNOWS": {"properties": {"since": "DATETIME"}}}
+	}`)))
+	assert.Equal(t, SchemaModeWarn, schema.Mode())
+	require.Len(t, schema.Labels(), 1)
+	assert.Equal(t, EntitySchema{
+		Name: "Person",
+		Properties: map[string]PropertyDefinition{
+			"name": {Type: PropertyTypeString, Required: true},
+			"tags": {Type: PropertyTypeList},
+		},
+		Closed: true,
+	}, schema.Labels()[0])
+	assert.Len(t, schema.RelationshipTypes(), 1)
+
+	// Invalid documents define nothing
+	err := schema.Load([]byte(`{"labels": {"City": {"properties": {"name": "TEXT"}}}}`))
+	assert.ErrorContains(t, err, "label City property name")
+	assert.Len(t, schema.Labels(), 1)
+	assert.Error(t, schema.Load([]byte(`{"mode": "loose"}`)))
+}
+
+func TestPropertySchema_Transaction(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	engine.PropertySchema().DefineLabel("Person", personSchema())
+	engine.PropertySchema().SetMode(SchemaModeStrict)
+
+	tx, err := engine.BeginTransaction()
+	require.NoError(t, err)
+	require.NoError(t, tx.CreateNode(person("a", map[string]any{"name": "Ada"})))
+	assert.ErrorIs(t, tx.CreateNode(person("b", map[string]any{"name": "Bob", "age": "old"})), ErrSchemaViolation)
+	require.NoError(t, tx.Commit())
+
+	count, err := engine.NodeCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestPropertySchema_AsyncEngine(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	schema := engine.PropertySchema()
+	schema.DefineLabel("Person", personSchema())
+	ae := NewAsyncEngine(engine, &AsyncEngineConfig{FlushInterval: time.Hour})
+	defer ae.Close()
+	require.Same(t, schema, ae.PropertySchema())
+
+	// Strict mode rejects writes before they are cached
+	schema.SetMode(SchemaModeStrict)
+	assert.ErrorIs(t, ae.CreateNode(person("a", nil)), ErrSchemaViolation)
+	require.NoError(t, ae.CreateNode(person("b", map[string]any{"name": "Bob"})))
+	assert.ErrorIs(t, ae.UpdateNode(person("b", map[string]any{"name": "Bob", "x": 1})), ErrSchemaViolation)
+	_, err := ae.GetNode("a")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Warn mode reports once, when the write is flushed
+	schema.SetMode(SchemaModeWarn)
+	reported := 0
+	schema.OnViolation(func(SchemaViolation) { reported++ })
+	require.NoError(t, ae.CreateNode(person("c", nil)))
+	assert.Zero(t, reported)
+	require.NoError(t, ae.Flush())
+	assert.Equal(t, 1, reported)
+
+	// A write that became invalid while cached is dropped at flush, not retried
+	require.NoError(t, ae.CreateNode(person("d", nil)))
+	schema.SetMode(SchemaModeStrict)
+	result := ae.FlushWithResult()
+	assert.Equal(t, 1, result.NodesDropped)
+	assert.Equal(t, 0, result.NodesFailed)
+	assert.False(t, ae.FlushWithResult().HasErrors())
+}
@@ -0,0 +1,645 @@
+// Package storage - storage quotas.
+//
+// A Quota caps how many nodes, relationships and bytes a database may hold,
+// optionally per node label, so one tenant cannot exhaust a shared instance.
+// Quotas are enforced when writes commit: a write that would take usage past
+// a limit fails with an error wrapping ErrQuotaExceeded and nothing is
+// stored. Writes that shrink the database (deletes, smaller updates) are
+// never rejected, so a tenant over quota can always clean up.
+//
+// Example:
+//
+//	err := engine.SetQuota(storage.Quota{
+//		MaxNodes:         1_000_000,
+//		MaxBytes:         10 << 30,
+//		MaxNodesPerLabel: map[string]int64{"Log": 50_000},
+//	})
+//	...
+//	if errors.Is(err, storage.ErrQuotaExceeded) {
+//		// tell the client to delete data or ask for a larger quota
+//	}
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ErrQuotaExceeded is returned (wrapped in a *QuotaExceededError) when a
+// write would take the database past one of its storage quotas.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// Quota resources, as reported in QuotaExceededError.Resource and the
+// rejection counts of QuotaStatus.
+const (
+	QuotaResourceNodes         = "nodes"
+	QuotaResourceRelationships = "relationships"
+	QuotaResourceBytes         = "bytes"
+	QuotaResourceLabel         = "label"
+)
+
+// Quota limits the size of a database. Zero (or negative) limits are
+// unlimited, so the zero Quota disables enforcement.
+type Quota struct {
+	MaxNodes         int64
+	MaxRelationships int64
+	// MaxBytes limits the encoded size of node and relationship records,
+	// excluding indexes and storage overhead.
+	MaxBytes int64
+	// MaxNodesPerLabel limits the nodes carrying a label. Labels match
+	// case-insensitively, like label lookups.
+	MaxNodesPerLabel map[string]int64
+}
+
+// IsZero reports whether q sets no limits.
+func (q Quota) IsZero() bool {
+	if q.MaxNodes > 0 || q.MaxRelationships > 0 || q.MaxBytes > 0 {
+		return false
+	}
+	for _, limit := range q.MaxNodesPerLabel {
+		if limit > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the limits for logs, e.g.
+// "nodes=1000000 bytes=10737418240 label:log=50000".
+func (q Quota) String() string {
+	var parts []string
+	if q.MaxNodes > 0 {
+		parts = append(parts, fmt.Sprintf("nodes=%d", q.MaxNodes))
+	}
+	if q.MaxRelationships > 0 {
+		parts = append(parts, fmt.Sprintf("relationships=%d", q.MaxRelationships))
+	}
+	if q.MaxBytes > 0 {
+		parts = append(parts, fmt.Sprintf("bytes=%d", q.MaxBytes))
+	}
+	labels := make([]string, 0, len(q.MaxNodesPerLabel))
+	for label, limit := range q.MaxNodesPerLabel {
+		if limit > 0 {
+			labels = append(labels, fmt.Sprintf("label:%s=%d", strings.ToLower(label), limit))
+		}
+	}
+	sort.Strings(labels)
+	parts = append(parts, labels...)
+	if len(parts) == 0 {
+		return "unlimited"
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseLabelQuotas parses "Label=maxNodes" entries into
+// Quota.MaxNodesPerLabel.
+//
+// Example:
+//
+//	labels, err := storage.ParseLabelQuotas([]string{"Log=50000", "Session=10000"})
+func ParseLabelQuotas(entries []string) (map[string]int64, error) {
+	out := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		label, limit, ok := strings.Cut(entry, "=")
+		label = strings.TrimSpace(label)
+		if !ok || label == "" {
+			return nil, fmt.Errorf("invalid label quota %q: expected Label=maxNodes", entry)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(limit), 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid label quota %q: limit must be a non-negative integer", entry)
+		}
+		out[label] = n
+	}
+	return out, nil
+}
+
+// QuotaUsage is the storage counted against a Quota.
+type QuotaUsage struct {
+	Nodes         int64
+	Relationships int64
+	Bytes         int64
+	// NodesPerLabel counts nodes for the labels that have a limit, keyed
+	// by lowercase label.
+	NodesPerLabel map[string]int64
+}
+
+// QuotaStatus reports a database's quota, its usage and how many writes
+// each limit has rejected.
+type QuotaStatus struct {
+	Quota Quota
+	Usage QuotaUsage
+	// Rejected counts rejected writes by resource (nodes, relationships,
+	// bytes); RejectedPerLabel counts them by lowercase label.
+	Rejected         map[string]int64
+	RejectedPerLabel map[string]int64
+}
+
+// QuotaExceededError describes the limit a rejected write would exceed.
+// It matches ErrQuotaExceeded with errors.Is.
+type QuotaExceededError struct {
+	Resource string // nodes, relationships, bytes or label
+	Label    string // set when Resource is label
+	Limit    int64
+	Used     int64 // usage before the write
+}
+
+func (e *QuotaExceededError) Error() string {
+	what := e.Resource
+	if e.Resource == QuotaResourceLabel {
+		what = "nodes labeled " + e.Label
+	}
+	return fmt.Sprintf("storage quota exceeded: limit of %d %s reached (%d in use)", e.Limit, what, e.Used)
+}
+
+// Is reports whether target is ErrQuotaExceeded.
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QuotaEngine is implemented by engines that enforce storage quotas.
+// BadgerEngine enforces them; WALEngine and AsyncEngine pass them through
+// to the engine they wrap.
+type QuotaEngine interface {
+	// SetQuota replaces the quota, counting current usage. The zero Quota
+	// removes all limits.
+	SetQuota(q Quota) error
+	// QuotaStatus returns the quota and usage, or false if none is set.
+	QuotaStatus() (QuotaStatus, bool)
+}
+
+// quotaDelta is the change in usage made by one write. Its methods are
+// no-ops on a nil delta, so write paths can record changes unconditionally.
+type quotaDelta struct {
+	nodes         int64
+	relationships int64
+	bytes         int64
+	labels        map[string]int64 // keyed by lowercase label
+
+	// Relationships added and removed, for the degree counters. They are
+	// not part of the usage and add ignores them.
+	degrees []degreeChange
+}
+
+// node records a node of size bytes being added (sign 1) or removed (sign -1).
+func (d *quotaDelta) node(labels []string, size int, sign int64) {
+	if d == nil {
+		return
+	}
+	d.nodes += sign
+	d.bytes += sign * int64(size)
+	d.label(labels, sign)
+}
+
+// label records labels being added to (sign 1) or removed from (sign -1) a node.
+func (d *quotaDelta) label(labels []string, sign int64) {
+	if d == nil || len(labels) == 0 {
+		return
+	}
+	if d.labels == nil {
+		d.labels = make(map[string]int64, len(labels))
+	}
+	for _, label := range labels {
+		d.labels[strings.ToLower(label)] += sign
+	}
+}
+
+// edge records a relationship of size bytes being added or removed.
+func (d *quotaDelta) edge(size int, sign int64) {
+	if d == nil {
+		return
+	}
+	d.relationships += sign
+	d.bytes += sign * int64(size)
+}
+
+// relationship records e being linked to (sign 1) or unlinked from
+// (sign -1) its endpoints.
+func (d *quotaDelta) relationship(e *Edge, sign int64) {
+	if d == nil {
+		return
+	}
+	d.degrees = append(d.degrees, degreeChange{start: e.StartNode, end: e.EndNode, relType: e.Type, sign: sign})
+}
+
+// resize records a stored record changing size.
+func (d *quotaDelta) resize(oldSize, newSize int) {
+	if d == nil {
+		return
+	}
+	d.bytes += int64(newSize - oldSize)
+}
+
+// add adds o to d, scaled by sign.
+func (d *quotaDelta) add(o *quotaDelta, sign int64) {
+	if d == nil || o == nil {
+		return
+	}
+	d.nodes += sign * o.nodes
+	d.relationships += sign * o.relationships
+	d.bytes += sign * o.bytes
+	for label, n := range o.labels {
+		if d.labels == nil {
+			d.labels = make(map[string]int64)
+		}
+		d.labels[label] += sign * n
+	}
+}
+
+// quotaTracker enforces a Quota against usage kept up to date by the
+// engine's write paths. Pending usage is admitted by AsyncEngine for
+// writes still in its cache, so limits hold before they are flushed.
+type quotaTracker struct {
+	mu               sync.Mutex
+	quota            Quota // MaxNodesPerLabel keyed by lowercase label
+	usage            QuotaUsage
+	pending          quotaDelta
+	rejected         map[string]int64
+	rejectedPerLabel map[string]int64
+}
+
+func newQuotaTracker(q Quota, usage QuotaUsage) *quotaTracker {
+	labels := make(map[string]int64, len(q.MaxNodesPerLabel))
+	for label, limit := range q.MaxNodesPerLabel {
+		if limit > 0 {
+			labels[strings.ToLower(label)] = limit
+		}
+	}
+	q.MaxNodesPerLabel = labels
+	if usage.NodesPerLabel == nil {
+		usage.NodesPerLabel = make(map[string]int64, len(labels))
+	}
+	return &quotaTracker{
+		quota:            q,
+		usage:            usage,
+		rejected:         make(map[string]int64),
+		rejectedPerLabel: make(map[string]int64),
+	}
+}
+
+// check returns an error for the first limit d would exceed on top of
+// usage (and pending usage if withPending). Only growth is checked.
+// The caller holds t.mu.
+func (t *quotaTracker) check(d *quotaDelta, withPending bool) error {
+	used := t.usage
+	if withPending {
+		used.Nodes += t.pending.nodes
+		used.Relationships += t.pending.relationships
+		used.Bytes += t.pending.bytes
+	}
+	over := func(resource string, limit, used, growth int64) error {
+		if limit <= 0 || growth <= 0 || used+growth <= limit {
+			return nil
+		}
+		t.rejected[resource]++
+		return &QuotaExceededError{Resource: resource, Limit: limit, Used: used}
+	}
+	if err := over(QuotaResourceNodes, t.quota.MaxNodes, used.Nodes, d.nodes); err != nil {
+		return err
+	}
+	if err := over(QuotaResourceRelationships, t.quota.MaxRelationships, used.Relationships, d.relationships); err != nil {
+		return err
+	}
+	if err := over(QuotaResourceBytes, t.quota.MaxBytes, used.Bytes, d.bytes); err != nil {
+		return err
+	}
+	for label, growth := range d.labels {
+		limit := t.quota.MaxNodesPerLabel[label]
+		n := t.usage.NodesPerLabel[label]
+		if withPending {
+			n += t.pending.labels[label]
+		}
+		if limit > 0 && growth > 0 && n+growth > limit {
+			t.rejectedPerLabel[label]++
+			return &QuotaExceededError{Resource: QuotaResourceLabel, Label: label, Limit: limit, Used: n}
+		}
+	}
+	return nil
+}
+
+// apply adds d to committed usage. The caller holds t.mu.
+func (t *quotaTracker) apply(d *quotaDelta, sign int64) {
+	t.usage.Nodes += sign * d.nodes
+	t.usage.Relationships += sign * d.relationships
+	t.usage.Bytes += sign * d.bytes
+	for label, n := range d.labels {
+		if _, limited := t.quota.MaxNodesPerLabel[label]; limited {
+			t.usage.NodesPerLabel[label] += sign * n
+		}
+	}
+}
+
+// reserve checks d against the quota and counts it as used. Call it
+// before committing the write and release if the commit fails.
+func (t *quotaTracker) reserve(d *quotaDelta) error {
+	if t == nil || d == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.check(d, false); err != nil {
+		return err
+	}
+	t.apply(d, 1)
+	return nil
+}
+
+// release undoes a reserve whose write did not commit.
+func (t *quotaTracker) release(d *quotaDelta) {
+	if t == nil || d == nil {
+		return
+	}
+	t.mu.Lock()
+	t.apply(d, -1)
+	t.mu.Unlock()
+}
+
+// admit checks a write accepted before it is committed against the quota
+// including other such writes, and counts it as pending until settle.
+func (t *quotaTracker) admit(d *quotaDelta) error {
+	if t == nil || d == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.check(d, true); err != nil {
+		return err
+	}
+	t.pending.add(d, 1)
+	return nil
+}
+
+// settle stops counting an admitted write as pending, once it has been
+// committed (and reserved) or dropped.
+func (t *quotaTracker) settle(d *quotaDelta) {
+	if t == nil || d == nil {
+		return
+	}
+	t.mu.Lock()
+	t.pending.add(d, -1)
+	t.mu.Unlock()
+}
+
+// status returns the quota, committed plus pending usage and rejections.
+func (t *quotaTracker) status() QuotaStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := QuotaStatus{
+		Quota: t.quota,
+		Usage: QuotaUsage{
+			Nodes:         t.usage.Nodes + t.pending.nodes,
+			Relationships: t.usage.Relationships + t.pending.relationships,
+			Bytes:         t.usage.Bytes + t.pending.bytes,
+			NodesPerLabel: make(map[string]int64, len(t.usage.NodesPerLabel)),
+		},
+		Rejected:         make(map[string]int64, len(t.rejected)),
+		RejectedPerLabel: make(map[string]int64, len(t.rejectedPerLabel)),
+	}
+	s.Quota.MaxNodesPerLabel = make(map[string]int64, len(t.quota.MaxNodesPerLabel))
+	for label, limit := range t.quota.MaxNodesPerLabel {
+		s.Quota.MaxNodesPerLabel[label] = limit
+		s.Usage.NodesPerLabel[label] = t.usage.NodesPerLabel[label] + t.pending.labels[label]
+	}
+	for resource, n := range t.rejected {
+		s.Rejected[resource] = n
+	}
+	for label, n := range t.rejectedPerLabel {
+		s.RejectedPerLabel[label] = n
+	}
+	return s
+}
+
+// quotaHolder is implemented by engines that hold or wrap a quotaTracker.
+type quotaHolder interface {
+	quotaTracker() *quotaTracker
+}
+
+// SetQuota sets the storage quota, counting current usage with a key scan.
+// Set it before serving writes: writes that commit during the scan may be
+// missed from the initial usage.
+func (b *BadgerEngine) SetQuota(q Quota) error {
+	if q.IsZero() {
+		b.quota.Store(nil)
+		return nil
+	}
+
+	t := newQuotaTracker(q, QuotaUsage{})
+	usage := QuotaUsage{NodesPerLabel: make(map[string]int64, len(t.quota.MaxNodesPerLabel))}
+	err := b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for _, prefix := range []byte{prefixNode, prefixEdge} {
+			for it.Seek([]byte{prefix}); it.ValidForPrefix([]byte{prefix}); it.Next() {
+				if prefix == prefixNode {
+					usage.Nodes++
+				} else {
+					usage.Relationships++
+				}
+				usage.Bytes += it.Item().ValueSize()
+			}
+		}
+		for label := range t.quota.MaxNodesPerLabel {
+			p := labelIndexPrefix(label)
+			for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+				usage.NodesPerLabel[label]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count storage usage: %w", err)
+	}
+
+	t.usage = usage
+	b.quota.Store(t)
+	return nil
+}
+
+// QuotaStatus returns the storage quota and usage, or false if no quota is set.
+func (b *BadgerEngine) QuotaStatus() (QuotaStatus, bool) {
+	t := b.quota.Load()
+	if t == nil {
+		return QuotaStatus{}, false
+	}
+	return t.status(), true
+}
+
+func (b *BadgerEngine) quotaTracker() *quotaTracker {
+	return b.quota.Load()
+}
+
+// updateWithQuota runs fn in a read-write transaction like db.Update. fn
+// records the usage change of its writes in d, which is reserved against
+// the quota before the commit, and the relationships it links and unlinks,
+// which are applied to the degree counters after it.
+func (b *BadgerEngine) updateWithQuota(fn func(txn *badger.Txn, d *quotaDelta) error) error {
+	t := b.quota.Load()
+	txn := b.db.NewTransaction(true)
+	defer txn.Discard()
+	d := &quotaDelta{}
+	if err := fn(txn, d); err != nil {
+		return err
+	}
+	if err := t.reserve(d); err != nil {
+		return err
+	}
+	b.degrees.begin(d.degrees)
+	err := txn.Commit()
+	b.degrees.end(d.degrees, err == nil)
+	if err != nil {
+		t.release(d)
+		return err
+	}
+	return nil
+}
+
+// quotaDelta returns the usage change of the transaction's operations, or
+// nil if t is nil. Record sizes are re-encoded, as stored at commit.
+func (tx *BadgerTransaction) quotaDelta(t *quotaTracker) *quotaDelta {
+	if t == nil {
+		return nil
+	}
+	nodeSize := func(n *Node) int {
+		data, _ := serializeNode(n)
+		return len(data)
+	}
+	edgeSize := func(e *Edge) int {
+		data, _ := serializeEdge(e)
+		return len(data)
+	}
+
+	d := &quotaDelta{}
+	for _, op := range tx.operations {
+		switch op.Type {
+		case OpCreateNode:
+			d.node(op.Node.Labels, nodeSize(op.Node), 1)
+		case OpUpdateNode:
+			d.node(op.OldNode.Labels, nodeSize(op.OldNode), -1)
+			d.node(op.Node.Labels, nodeSize(op.Node), 1)
+		case OpDeleteNode:
+			d.node(op.OldNode.Labels, nodeSize(op.OldNode), -1)
+		case OpCreateEdge:
+			d.edge(edgeSize(op.Edge), 1)
+		case OpDeleteEdge:
+			d.edge(edgeSize(op.OldEdge), -1)
+		}
+	}
+	return d
+}
+
+// quotaTracker returns the tracker of the wrapped engine, if any.
+func (w *WALEngine) quotaTracker() *quotaTracker {
+	if h, ok := w.engine.(quotaHolder); ok {
+		return h.quotaTracker()
+	}
+	return nil
+}
+
+// SetQuota sets the storage quota of the wrapped engine.
+func (w *WALEngine) SetQuota(q Quota) error {
+	if qe, ok := w.engine.(QuotaEngine); ok {
+		return qe.SetQuota(q)
+	}
+	return fmt.Errorf("storage quotas are not supported by %T", w.engine)
+}
+
+// QuotaStatus returns the storage quota and usage of the wrapped engine.
+func (w *WALEngine) QuotaStatus() (QuotaStatus, bool) {
+	if qe, ok := w.engine.(QuotaEngine); ok {
+		return qe.QuotaStatus()
+	}
+	return QuotaStatus{}, false
+}
+
+func (ae *AsyncEngine) quotaTracker() *quotaTracker {
+	if h, ok := ae.engine.(quotaHolder); ok {
+		return h.quotaTracker()
+	}
+	return nil
+}
+
+// SetQuota sets the storage quota of the underlying engine. Creates still
+// in the cache are counted from the next one on.
+func (ae *AsyncEngine) SetQuota(q Quota) error {
+	if qe, ok := ae.engine.(QuotaEngine); ok {
+		return qe.SetQuota(q)
+	}
+	return fmt.Errorf("storage quotas are not supported by %T", ae.engine)
+}
+
+// QuotaStatus returns the storage quota and usage of the underlying engine,
+// including creates still in the cache.
+func (ae *AsyncEngine) QuotaStatus() (QuotaStatus, bool) {
+	if qe, ok := ae.engine.(QuotaEngine); ok {
+		return qe.QuotaStatus()
+	}
+	return QuotaStatus{}, false
+}
+
+// admitQuota checks creates against the storage quota before they enter
+// the cache, so a quota holds even though the writes commit later. A create
+// that replaces a cached one is counted once. Caller must hold ae.mu.
+func (ae *AsyncEngine) admitQuota(nodes []*Node, edges []*Edge) error {
+	t := ae.quotaTracker()
+	if t == nil {
+		return nil
+	}
+
+	total := &quotaDelta{}
+	nodeDeltas := make([]*quotaDelta, len(nodes))
+	for i, node := range nodes {
+		data, _ := encodeNode(node)
+		nodeDeltas[i] = &quotaDelta{}
+		nodeDeltas[i].node(node.Labels, len(data), 1)
+		total.add(nodeDeltas[i], 1)
+		total.add(ae.nodeQuota[node.ID], -1)
+	}
+	edgeDeltas := make([]*quotaDelta, len(edges))
+	for i, edge := range edges {
+		data, _ := encodeEdge(edge)
+		edgeDeltas[i] = &quotaDelta{}
+		edgeDeltas[i].edge(len(data), 1)
+		total.add(edgeDeltas[i], 1)
+		total.add(ae.edgeQuota[edge.ID], -1)
+	}
+	if err := t.admit(total); err != nil {
+		return err
+	}
+
+	for i, node := range nodes {
+		ae.nodeQuota[node.ID] = nodeDeltas[i]
+	}
+	for i, edge := range edges {
+		ae.edgeQuota[edge.ID] = edgeDeltas[i]
+	}
+	return nil
+}
+
+// settleQuota stops counting a cached create of node or edge id as pending,
+// once it is flushed or discarded. Caller must hold ae.mu.
+func (ae *AsyncEngine) settleQuota(nodeID NodeID, edgeID EdgeID) {
+	if d := ae.nodeQuota[nodeID]; d != nil {
+		delete(ae.nodeQuota, nodeID)
+		ae.quotaTracker().settle(d)
+	}
+	if d := ae.edgeQuota[edgeID]; d != nil {
+		delete(ae.edgeQuota, edgeID)
+		ae.quotaTracker().settle(d)
+	}
+}
+
+// Ensure quota support is wired through the engine stack.
+var (
+	_ QuotaEngine = (*BadgerEngine)(nil)
+	_ QuotaEngine = (*WALEngine)(nil)
+	_ QuotaEngine = (*AsyncEngine)(nil)
+)
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quotaNode(id string, labels ...string) *Node {
+	return &Node{ID: NodeID(id), Labels: labels, Properties: map[string]any{"name": id}}
+}
+
+func TestQuota_Nodes(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	require.NoError(t, engine.CreateNode(quotaNode("existing")))
+	require.NoError(t, engine.SetQuota(Quota{MaxNodes: 2}))
+
+	require.NoError(t, engine.CreateNode(quotaNode("a")))
+	err := engine.CreateNode(quotaNode("b"))
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	var qe *QuotaExceededError
+	require.True(t, errors.As(err, &qe))
+	assert.Equal(t, QuotaResourceNodes, qe.Resource)
+	assert.Equal(t, int64(2), qe.Limit)
+	assert.Equal(t, int64(2), qe.Used)
+
+	_, err = engine.GetNode("b")
+	assert.ErrorIs(t, err, ErrNotFound, "rejected writes are not stored")
+	assert.ErrorIs(t, engine.BulkCreateNodes([]*Node{quotaNode("c"), quotaNode("d")}), ErrQuotaExceeded)
+
+	// Deletes free quota
+	require.NoError(t, engine.DeleteNode("existing"))
+	require.NoError(t, engine.CreateNode(quotaNode("b")))
+
+	status, ok := engine.QuotaStatus()
+	require.True(t, ok)
+	assert.Equal(t, int64(2), status.Usage.Nodes)
+	assert.Equal(t, int64(2), status.Rejected[QuotaResourceNodes])
+
+	// The zero quota removes the limits
+	require.NoError(t, engine.SetQuota(Quota{}))
+	require.NoError(t, engine.CreateNode(quotaNode("c")))
+	_, ok = engine.QuotaStatus()
+	assert.False(t, ok)
+}
+
+func TestQuota_Labels(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	require.NoError(t, engine.SetQuota(Quota{MaxNodesPerLabel: map[string]int64{"Log": 1}}))
+
+	require.NoError(t, engine.CreateNode(quotaNode("log1", "Log")))
+	err := engine.CreateNode(quotaNode("log2", "log"))
+	require.ErrorIs(t, err, ErrQuotaExceeded, "labels match case-insensitively")
+	assert.Contains(t, err.Error(), "nodes labeled log")
+	require.NoError(t, engine.CreateNode(quotaNode("p", "Person")))
+
+	// Adding the label in an update counts too
+	assert.ErrorIs(t, engine.UpdateNode(quotaNode("p", "Person", "Log")), ErrQuotaExceeded)
+	require.NoError(t, engine.UpdateNode(quotaNode("log1", "Person")))
+	require.NoError(t, engine.UpdateNode(quotaNode("p", "Person", "Log")))
+
+	status, _ := engine.QuotaStatus()
+	assert.Equal(t, map[string]int64{"log": 1}, status.Usage.NodesPerLabel)
+	assert.Equal(t, int64(2), status.RejectedPerLabel["log"])
+}
+
+func TestQuota_RelationshipsAndBytes(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, engine.CreateNode(quotaNode(id)))
+	}
+	require.NoError(t, engine.SetQuota(Quota{MaxRelationships: 1}))
+
+	require.NoError(t, engine.CreateEdge(&Edge{ID: "ab", StartNode: "a", EndNode: "b", Type: "KNOWS"}))
+	assert.ErrorIs(t, engine.CreateEdge(&Edge{ID: "bc", StartNode: "b", EndNode: "c", Type: "KNOWS"}), ErrQuotaExceeded)
+
+	// Deleting a node deletes its relationships
+	require.NoError(t, engine.DeleteNode("a"))
+	require.NoError(t, engine.CreateEdge(&Edge{ID: "bc", StartNode: "b", EndNode: "c", Type: "KNOWS"}))
+
+	// Bytes: allow a little more than is stored now
+	require.NoError(t, engine.SetQuota(Quota{MaxBytes: 1}))
+	status, _ := engine.QuotaStatus()
+	used := status.Usage.Bytes
+	assert.Greater(t, used, int64(0), "existing records are counted")
+	require.NoError(t, engine.SetQuota(Quota{MaxBytes: used + 100}))
+
+	big := quotaNode("b")
+	big.Properties["bio"] = strings.Repeat("x", 1000)
+	err := engine.UpdateNode(big)
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	var qe *QuotaExceededError
+	require.True(t, errors.As(err, &qe))
+	assert.Equal(t, QuotaResourceBytes, qe.Resource)
+
+	// Shrinking writes are allowed even over quota
+	require.NoError(t, engine.DeleteEdge("bc"))
+	status, _ = engine.QuotaStatus()
+	assert.Less(t, status.Usage.Bytes, used)
+}
+
+func TestQuota_Transaction(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	require.NoError(t, engine.SetQuota(Quota{MaxNodes: 1}))
+
+	tx, err := engine.BeginTransaction()
+	require.NoError(t, err)
+	require.NoError(t, tx.CreateNode(quotaNode("a")))
+	require.NoError(t, tx.CreateNode(quotaNode("b")))
+	assert.ErrorIs(t, tx.Commit(), ErrQuotaExceeded)
+
+	count, err := engine.NodeCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count, "rejected transaction is rolled back")
+
+	tx, err = engine.BeginTransaction()
+	require.NoError(t, err)
+	require.NoError(t, tx.CreateNode(quotaNode("a")))
+	require.NoError(t, tx.CreateNode(quotaNode("b")))
+	require.NoError(t, tx.DeleteNode("b"))
+	require.NoError(t, tx.Commit())
+
+	status, _ := engine.QuotaStatus()
+	assert.Equal(t, int64(1), status.Usage.Nodes)
+}
+
+func TestQuota_AsyncEngine(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	ae := NewAsyncEngine(engine, &AsyncEngineConfig{FlushInterval: time.Hour})
+	defer ae.Close()
+	require.NoError(t, ae.SetQuota(Quota{MaxNodes: 2, MaxNodesPerLabel: map[string]int64{"Log": 1}}))
+
+	// Limits hold for writes still in the cache
+	require.NoError(t, ae.CreateNode(quotaNode("a", "Log")))
+	assert.ErrorIs(t, ae.CreateNode(quotaNode("b", "Log")), ErrQuotaExceeded)
+	require.NoError(t, ae.CreateNode(quotaNode("b")))
+	require.NoError(t, ae.CreateNode(quotaNode("b")), "re-creating a cached node is counted once")
+	assert.ErrorIs(t, ae.CreateNode(quotaNode("c")), ErrQuotaExceeded)
+
+	// Discarding a cached create frees its quota
+	require.NoError(t, ae.DeleteNode("b"))
+	require.NoError(t, ae.CreateNode(quotaNode("c")))
+
+	require.NoError(t, ae.Flush())
+	status, ok := ae.QuotaStatus()
+	require.True(t, ok)
+	assert.Equal(t, int64(2), status.Usage.Nodes)
+	assert.Equal(t, int64(1), status.Usage.NodesPerLabel["log"])
+
+	count, err := engine.NodeCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestQuota_AsyncFlushDropsRejectedWrites(t *testing.T) {
+	engine := createTestBadgerEngine(t)
+	require.NoError(t, engine.CreateNode(quotaNode("a")))
+	ae := NewAsyncEngine(engine, &AsyncEngineConfig{FlushInterval: time.Hour})
+	defer ae.Close()
+	require.NoError(t, ae.SetQuota(Quota{MaxNodesPerLabel: map[string]int64{"Log": 1}}))
+	require.NoError(t, ae.CreateNode(quotaNode("b", "Log")))
+
+	// Updates are checked when flushed; one over quota is dropped, not retried
+	require.NoError(t, ae.UpdateNode(quotaNode("a", "Log")))
+	result := ae.FlushWithResult()
+	assert.Equal(t, 1, result.NodesWritten)
+	assert.Equal(t, 1, result.NodesDropped)
+	assert.Equal(t, 0, result.NodesFailed)
+
+	result = ae.FlushWithResult()
+	assert.False(t, result.HasErrors(), "dropped writes are not retried")
+	status, _ := ae.QuotaStatus()
+	assert.Equal(t, int64(1), status.Usage.NodesPerLabel["log"])
+}
+
+func TestParseLabelQuotas(t *testing.T) {
+	labels, err := ParseLabelQuotas([]string{"Log=50000", " Session = 10 "})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"Log": 50000, "Session": 10}, labels)
+
+	for _, bad := range []string{"Log", "=5", "Log=many", "Log=-1"} {
+		_, err := ParseLabelQuotas([]string{bad})
+		assert.Error(t, err, bad)
+	}
+
+	q := Quota{MaxNodes: 10, MaxNodesPerLabel: labels}
+	assert.Equal(t, "nodes=10 label:log=50000 label:session=10", q.String())
+	assert.True(t, Quota{MaxNodesPerLabel: map[string]int64{"Log": 0}}.IsZero())
+}
@@ -0,0 +1,342 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRecovering is returned for writes attempted while WAL recovery runs.
+var ErrRecovering = errors.New("database is recovering")
+
+// RecoveryOptions controls WAL replay during recovery.
+type RecoveryOptions struct {
+	// Workers replays independent entries concurrently (0 = GOMAXPROCS,
+	// 1 = strictly sequential).
+	Workers int
+	// Progress is called every ProgressInterval while entries are replayed,
+	// and once more when a replay it was called for ends, so short replays
+	// stay quiet. Nil disables progress callbacks; the recovery state is
+	// still visible through Recovering.
+	Progress func(RecoveryProgress)
+	// ProgressInterval defaults to 5 seconds.
+	ProgressInterval time.Duration
+}
+
+// DefaultRecoveryOptions replays on all CPUs and logs progress.
+func DefaultRecoveryOptions() RecoveryOptions {
+	return RecoveryOptions{Progress: LogRecoveryProgress}
+}
+
+// RecoveryProgress describes a running WAL replay.
+type RecoveryProgress struct {
+	Phase   string        // "replay" or "transactions"
+	Done    int           // Entries replayed so far
+	Total   int           // Entries to replay
+	Elapsed time.Duration // Time since replay started
+}
+
+// Percent returns how much of the replay is done (0-100).
+func (p RecoveryProgress) Percent() float64 {
+	if p.Total == 0 {
+		return 100
+	}
+	return float64(p.Done) / float64(p.Total) * 100
+}
+
+// ETA estimates the time left from the replay rate so far. It is 0 until
+// the first entry has been replayed.
+func (p RecoveryProgress) ETA() time.Duration {
+	if p.Done == 0 || p.Done >= p.Total {
+		return 0
+	}
+	perEntry := p.Elapsed / time.Duration(p.Done)
+	return perEntry * time.Duration(p.Total-p.Done)
+}
+
+// String formats the progress for logs.
+func (p RecoveryProgress) String() string {
+	return fmt.Sprintf("%s %.0f%% (%d/%d entries), elapsed %s, ETA %s",
+		p.Phase, p.Percent(), p.Done, p.Total,
+		p.Elapsed.Round(time.Second), p.ETA().Round(time.Second))
+}
+
+// LogRecoveryProgress prints recovery progress to stdout.
+func LogRecoveryProgress(p RecoveryProgress) {
+	fmt.Printf("⏳ WAL recovery: %s\n", p)
+}
+
+// activeRecovery is the replay in progress, if any.
+var activeRecovery atomic.Pointer[recoveryTracker]
+
+// Recovering reports whether WAL recovery is running in this process and
+// how far it has got. Servers report it from their health endpoints and
+// reject writes with ErrRecovering until it ends.
+func Recovering() (RecoveryProgress, bool) {
+	t := activeRecovery.Load()
+	if t == nil {
+		return RecoveryProgress{}, false
+	}
+	return t.progress(), true
+}
+
+// recoveryTracker counts replayed entries and reports progress.
+type recoveryTracker struct {
+	phase   string
+	total   int
+	started time.Time
+	done    atomic.Int64
+	stop    chan struct{}
+	stopped sync.WaitGroup
+	report  func(RecoveryProgress)
+	reports atomic.Bool // report was called while replaying
+}
+
+// startRecovery publishes a replay of total entries as the active recovery
+// and starts progress reporting.
+func startRecovery(phase string, total int, opts RecoveryOptions) *recoveryTracker {
+	t := &recoveryTracker{
+		phase:   phase,
+		total:   total,
+		started: time.Now(),
+		stop:    make(chan struct{}),
+		report:  opts.Progress,
+	}
+	activeRecovery.Store(t)
+	if t.report != nil {
+		interval := opts.ProgressInterval
+		if interval <= 0 {
+			interval = 5 * time.Second
+		}
+		t.stopped.Add(1)
+		go func() {
+			defer t.stopped.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					t.reports.Store(true)
+					t.report(t.progress())
+				case <-t.stop:
+					return
+				}
+			}
+		}()
+	}
+	return t
+}
+
+func (t *recoveryTracker) progress() RecoveryProgress {
+	return RecoveryProgress{
+		Phase:   t.phase,
+		Done:    int(t.done.Load()),
+		Total:   t.total,
+		Elapsed: time.Since(t.started),
+	}
+}
+
+// finish stops reporting, reports the final progress of a replay that
+// reported before, and clears the active recovery.
+func (t *recoveryTracker) finish() {
+	close(t.stop)
+	t.stopped.Wait()
+	if t.reports.Load() {
+		t.report(t.progress())
+	}
+	activeRecovery.CompareAndSwap(t, nil)
+}
+
+// ReplayWALEntriesWithOptions replays entries like ReplayWALEntries, but
+// replays independent entries concurrently and reports progress.
+//
+// Entries are independent when they touch different nodes and
+// relationships; a relationship also touches its endpoints. Entries are
+// grouped into waves of independent entries in WAL order, and each wave
+// completes before the next starts, so every node and relationship sees
+// its changes in the original order. Node deletes (which also remove
+// relationships) and unknown operations are replayed on their own.
+func ReplayWALEntriesWithOptions(engine Engine, entries []WALEntry, opts RecoveryOptions) ReplayResult {
+	var (
+		mu     sync.Mutex
+		result = ReplayResult{Errors: make([]ReplayError, 0)}
+	)
+	tracker := startRecovery("replay", len(entries), opts)
+	defer tracker.finish()
+
+	replayWALWaves(engine, entries, opts.Workers, tracker, func(entry WALEntry, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case entry.Operation == OpCheckpoint:
+			// Checkpoints are markers only - skip them (no-op, don't count as applied)
+			result.Skipped++
+		case err == nil:
+			result.Applied++
+		case errors.Is(err, ErrAlreadyExists):
+			// Duplicate during replay - expected (idempotency)
+			result.Skipped++
+		default:
+			result.Failed++
+			result.Errors = append(result.Errors, ReplayError{
+				Sequence:  entry.Sequence,
+				Operation: entry.Operation,
+				Error:     err,
+			})
+		}
+	})
+
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Sequence < result.Errors[j].Sequence
+	})
+	return result
+}
+
+// replayWALWaves replays entries in waves of independent entries, calling
+// done for each entry with its replay error. done may be called
+// concurrently.
+func replayWALWaves(engine Engine, entries []WALEntry, workers int, tracker *recoveryTracker, done func(WALEntry, error)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	apply := func(entry WALEntry) {
+		var err error
+		if entry.Operation != OpCheckpoint {
+			err = ReplayWALEntry(engine, entry)
+		}
+		done(entry, err)
+		tracker.done.Add(1)
+	}
+	if workers == 1 {
+		for _, entry := range entries {
+			apply(entry)
+		}
+		return
+	}
+
+	var wave []WALEntry
+	touched := make(map[string]struct{})
+	flush := func() {
+		replayWave(wave, workers, apply)
+		wave = wave[:0]
+		clear(touched)
+	}
+	for _, entry := range entries {
+		keys, alone := walEntryKeys(entry)
+		if alone {
+			flush()
+			apply(entry)
+			continue
+		}
+		for _, k := range keys {
+			if _, ok := touched[k]; ok {
+				flush()
+				break
+			}
+		}
+		for _, k := range keys {
+			touched[k] = struct{}{}
+		}
+		wave = append(wave, entry)
+	}
+	flush()
+}
+
+// replayWave applies independent entries on up to workers goroutines.
+func replayWave(wave []WALEntry, workers int, apply func(WALEntry)) {
+	if len(wave) < 2 {
+		for _, entry := range wave {
+			apply(entry)
+		}
+		return
+	}
+	workers = min(workers, len(wave))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(wave) {
+					return
+				}
+				apply(wave[i])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// walEntryRefs decodes only the IDs a WAL entry touches.
+type walEntryRefs struct {
+	ID    string   `json:"id"`
+	IDs   []string `json:"ids"`
+	Node  *walRef  `json:"node"`
+	Edge  *walRef  `json:"edge"`
+	Nodes []walRef `json:"nodes"`
+	Edges []walRef `json:"edges"`
+}
+
+type walRef struct {
+	ID        string `json:"id"`
+	StartNode string `json:"startNode"`
+	EndNode   string `json:"endNode"`
+}
+
+// walEntryKeys returns the nodes ("n:" + ID) and relationships ("e:" + ID)
+// an entry touches. alone is true for entries that must be replayed on
+// their own because what they touch is not known from the entry.
+func walEntryKeys(entry WALEntry) (keys []string, alone bool) {
+	switch entry.Operation {
+	case OpCheckpoint, OpTxBegin, OpTxCommit, OpTxAbort:
+		return nil, false
+	case OpCreateNode, OpUpdateNode, OpCreateEdge, OpUpdateEdge, OpDeleteEdge,
+		OpBulkNodes, OpBulkEdges, OpBulkDeleteEdges:
+	default:
+		// Node deletes remove relationships too; unknown operations are
+		// replayed as they always were.
+		return nil, true
+	}
+
+	var refs walEntryRefs
+	if err := json.Unmarshal(entry.Data, &refs); err != nil {
+		return nil, true // let ReplayWALEntry report the error in order
+	}
+	edge := func(r walRef) {
+		keys = append(keys, "e:"+r.ID, "n:"+r.StartNode, "n:"+r.EndNode)
+	}
+	switch entry.Operation {
+	case OpCreateNode, OpUpdateNode:
+		if refs.Node == nil {
+			return nil, true
+		}
+		keys = append(keys, "n:"+refs.Node.ID)
+	case OpCreateEdge, OpUpdateEdge:
+		if refs.Edge == nil {
+			return nil, true
+		}
+		edge(*refs.Edge)
+	case OpDeleteEdge:
+		keys = append(keys, "e:"+refs.ID)
+	case OpBulkNodes:
+		for _, n := range refs.Nodes {
+			keys = append(keys, "n:"+n.ID)
+		}
+	case OpBulkEdges:
+		for _, e := range refs.Edges {
+			edge(e)
+		}
+	case OpBulkDeleteEdges:
+		for _, id := range refs.IDs {
+			keys = append(keys, "e:"+id)
+		}
+	}
+	return keys, false
+}
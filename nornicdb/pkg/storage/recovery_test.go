@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recoveryWAL builds a WAL with creates, updates and deletes that depend on
+// each other in order.
+func recoveryWAL(t *testing.T) []WALEntry {
+	var entries []WALEntry
+	add := func(op OperationType, data interface{}) {
+		b, err := json.Marshal(data)
+		require.NoError(t, err)
+		entries = append(entries, WALEntry{Sequence: uint64(len(entries) + 1), Operation: op, Data: b})
+	}
+	for i := 0; i < 50; i++ {
+		add(OpCreateNode, WALNodeData{Node: &Node{ID: NodeID(fmt.Sprintf("n%d", i)), Labels: []string{"Person"},
+			Properties: map[string]interface{}{"v": 0}}})
+	}
+	for i := 0; i < 49; i++ {
+		add(OpCreateEdge, WALEdgeData{Edge: &Edge{ID: EdgeID(fmt.Sprintf("e%d", i)), Type: "NEXT",
+			StartNode: NodeID(fmt.Sprintf("n%d", i)), EndNode: NodeID(fmt.Sprintf("n%d", i+1))}})
+	}
+	for v := 1; v <= 3; v++ {
+		for i := 0; i < 50; i += 2 {
+			add(OpUpdateNode, WALNodeData{Node: &Node{ID: NodeID(fmt.Sprintf("n%d", i)), Labels: []string{"Person"},
+				Properties: map[string]interface{}{"v": v}}})
+		}
+	}
+	add(OpCheckpoint, struct{}{})
+	add(OpDeleteEdge, WALDeleteData{ID: "e10"})
+	add(OpDeleteNode, WALDeleteData{ID: "n49"})
+	add(OpBulkNodes, WALBulkNodesData{Nodes: []*Node{{ID: "b1"}, {ID: "b2"}}})
+	add(OpBulkEdges, WALBulkEdgesData{Edges: []*Edge{{ID: "be1", Type: "LINK", StartNode: "b1", EndNode: "b2"}}})
+	add(OpCreateNode, WALNodeData{Node: &Node{ID: "n0"}}) // duplicate
+	return entries
+}
+
+// graphState summarizes an engine's nodes and relationships for comparison.
+func graphState(t *testing.T, engine Engine) []string {
+	nodes, err := engine.AllNodes()
+	require.NoError(t, err)
+	edges, err := engine.AllEdges()
+	require.NoError(t, err)
+	var state []string
+	for _, n := range nodes {
+		state = append(state, fmt.Sprintf("node %s %v", n.ID, n.Properties["v"]))
+	}
+	for _, e := range edges {
+		state = append(state, fmt.Sprintf("edge %s %s->%s", e.ID, e.StartNode, e.EndNode))
+	}
+	sort.Strings(state)
+	return state
+}
+
+func TestReplayWALEntriesWithOptions_MatchesSequential(t *testing.T) {
+	entries := recoveryWAL(t)
+
+	sequential := NewMemoryEngine()
+	want := ReplayWALEntries(sequential, entries)
+
+	for _, workers := range []int{1, 4, 0} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			engine := NewMemoryEngine()
+			got := ReplayWALEntriesWithOptions(engine, entries, RecoveryOptions{Workers: workers})
+			assert.Equal(t, want.Summary(), got.Summary())
+			assert.Equal(t, graphState(t, sequential), graphState(t, engine))
+		})
+	}
+
+	_, recovering := Recovering()
+	assert.False(t, recovering, "recovery state is cleared when replay ends")
+}
+
+func TestWALEntryKeys(t *testing.T) {
+	entries := recoveryWAL(t)
+	keys, alone := walEntryKeys(entries[50]) // first edge
+	assert.False(t, alone)
+	assert.Equal(t, []string{"e:e0", "n:n0", "n:n1"}, keys)
+
+	for _, op := range []OperationType{OpDeleteNode, OpBulkDeleteNodes, OpUpdateEmbedding} {
+		_, alone := walEntryKeys(WALEntry{Operation: op, Data: []byte(`{"id":"n1"}`)})
+		assert.True(t, alone, "%s is replayed on its own", op)
+	}
+	_, alone = walEntryKeys(WALEntry{Operation: OpCreateNode, Data: []byte(`not json`)})
+	assert.True(t, alone, "undecodable entries are replayed on their own")
+}
+
+// blockingEngine holds node creation until released.
+type blockingEngine struct {
+	*MemoryEngine
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (b *blockingEngine) CreateNode(n *Node) error {
+	b.once.Do(func() {
+		close(b.entered)
+		<-b.release
+	})
+	return b.MemoryEngine.CreateNode(n)
+}
+
+func TestRecoveryProgress(t *testing.T) {
+	engine := &blockingEngine{MemoryEngine: NewMemoryEngine(), entered: make(chan struct{}), release: make(chan struct{})}
+	entries := recoveryWAL(t)
+
+	var (
+		mu      sync.Mutex
+		reports []RecoveryProgress
+	)
+	done := make(chan ReplayResult)
+	go func() {
+		done <- ReplayWALEntriesWithOptions(engine, entries, RecoveryOptions{
+			Workers:          1,
+			ProgressInterval: time.Millisecond,
+			Progress: func(p RecoveryProgress) {
+				mu.Lock()
+				reports = append(reports, p)
+				mu.Unlock()
+			},
+		})
+	}()
+
+	<-engine.entered
+	progress, recovering := Recovering()
+	require.True(t, recovering, "recovery state is visible while replaying")
+	assert.Equal(t, "replay", progress.Phase)
+	assert.Equal(t, len(entries), progress.Total)
+	assert.Less(t, progress.Done, progress.Total)
+	time.Sleep(10 * time.Millisecond) // let the ticker report
+	close(engine.release)
+
+	result := <-done
+	assert.Zero(t, result.Failed)
+	_, recovering = Recovering()
+	assert.False(t, recovering)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, reports)
+	last := reports[len(reports)-1]
+	assert.Equal(t, len(entries), last.Done, "a final report follows the last entry")
+	assert.Equal(t, float64(100), last.Percent())
+}
+
+func TestRecoveryProgressETA(t *testing.T) {
+	p := RecoveryProgress{Phase: "replay", Done: 250, Total: 1000, Elapsed: 10 * time.Second}
+	assert.Equal(t, float64(25), p.Percent())
+	assert.Equal(t, 30*time.Second, p.ETA())
+	assert.Equal(t, "replay 25% (250/1000 entries), elapsed 10s, ETA 30s", p.String())
+
+	assert.Zero(t, RecoveryProgress{Total: 10}.ETA(), "no estimate before the first entry")
+	assert.Equal(t, float64(100), RecoveryProgress{}.Percent())
+}
@@ -0,0 +1,227 @@
+// Package storage - random node sampling.
+//
+// Exploring a large graph starts from a handful of representative nodes,
+// and loading every node to pick them defeats the purpose. BadgerEngine
+// samples from its keys: a uniform sample reads the node or label index
+// keys without decoding a single node, and a random node for starting a
+// walk is found with a handful of seeks into the index.
+
+package storage
+
+import (
+	"math/rand"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// NodeSampler is implemented by engines that pick random nodes from their
+// indexes instead of reading the nodes.
+type NodeSampler interface {
+	// SampleNodeIDs returns n distinct nodes with label (any node if label
+	// is empty), every node equally likely, or all of them if there are no
+	// more than n.
+	SampleNodeIDs(label string, n int, rnd *rand.Rand) ([]NodeID, error)
+
+	// RandomNodeID returns a node with label (any node if label is empty)
+	// picked from a few positions in the index, or ErrNotFound if there is
+	// none. It is only as uniform as node IDs are spread over their key
+	// space: random IDs such as UUIDs sample evenly, while with sequential
+	// IDs a node among few with the same leading characters, such as n9
+	// next to n10 to n99, is picked more often.
+	RandomNodeID(label string, rnd *rand.Rand) (NodeID, error)
+}
+
+// SampleNodeIDs samples with NodeSampler when the engine implements it, and
+// from all nodes otherwise.
+func SampleNodeIDs(engine Engine, label string, n int, rnd *rand.Rand) ([]NodeID, error) {
+	if s, ok := engine.(NodeSampler); ok {
+		return s.SampleNodeIDs(label, n, rnd)
+	}
+	var nodes []*Node
+	var err error
+	if label == "" {
+		nodes, err = engine.AllNodes()
+	} else {
+		nodes, err = engine.GetNodesByLabel(label)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := newReservoir(n, rnd)
+	for _, node := range nodes {
+		r.offer(node.ID)
+	}
+	return r.ids, nil
+}
+
+// RandomNodeID picks a node with NodeSampler when the engine implements it,
+// and samples one otherwise.
+func RandomNodeID(engine Engine, label string, rnd *rand.Rand) (NodeID, error) {
+	if s, ok := engine.(NodeSampler); ok {
+		return s.RandomNodeID(label, rnd)
+	}
+	ids, err := SampleNodeIDs(engine, label, 1, rnd)
+	if err != nil {
+		return "", err
+	}
+	if len(ids) == 0 {
+		return "", ErrNotFound
+	}
+	return ids[0], nil
+}
+
+// reservoir keeps a uniform sample of the IDs offered to it.
+type reservoir struct {
+	ids  []NodeID
+	n    int
+	seen int
+	rnd  *rand.Rand
+}
+
+func newReservoir(n int, rnd *rand.Rand) *reservoir {
+	return &reservoir{ids: make([]NodeID, 0, min(n, 1024)), n: n, rnd: rnd}
+}
+
+func (r *reservoir) offer(id NodeID) {
+	r.seen++
+	if len(r.ids) < r.n {
+		r.ids = append(r.ids, id)
+		return
+	}
+	if j := r.rnd.Intn(r.seen); j < r.n {
+		r.ids[j] = id
+	}
+}
+
+// sampleIndex returns the index prefix holding the nodes with label, and
+// how to get a node ID from a key under it.
+func sampleIndex(label string) ([]byte, func(key []byte) NodeID) {
+	if label == "" {
+		return []byte{prefixNode}, func(key []byte) NodeID { return NodeID(key[1:]) }
+	}
+	prefix := labelIndexPrefix(label)
+	return prefix, func(key []byte) NodeID { return NodeID(key[len(prefix):]) }
+}
+
+// SampleNodeIDs samples node IDs from the node or label index keys.
+func (b *BadgerEngine) SampleNodeIDs(label string, n int, rnd *rand.Rand) ([]NodeID, error) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return nil, ErrStorageClosed
+	}
+	b.mu.RUnlock()
+	if n <= 0 {
+		return nil, nil
+	}
+	prefix, nodeID := sampleIndex(label)
+	r := newReservoir(n, rnd)
+	err := b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if id := nodeID(it.Item().Key()); id != "" {
+				r.offer(id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.ids, nil
+}
+
+// RandomNodeID descends the node or label index keys byte by byte,
+// choosing one of the bytes that follow at random, until a single key is
+// left. Each level costs a seek per distinct byte, so finding a node costs
+// a few dozen seeks however many nodes there are.
+func (b *BadgerEngine) RandomNodeID(label string, rnd *rand.Rand) (NodeID, error) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return "", ErrStorageClosed
+	}
+	b.mu.RUnlock()
+	prefix, nodeID := sampleIndex(label)
+	var id NodeID
+	err := b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		path := prefix
+		for {
+			it.Seek(path)
+			if !it.ValidForPrefix(path) {
+				return ErrNotFound
+			}
+			key := it.Item().KeyCopy(nil)
+			if it.Next(); !it.ValidForPrefix(path) {
+				id = nodeID(key) // the only key left
+				return nil
+			}
+
+			// The bytes following path; -1 for a key equal to path.
+			var next []int
+			if len(key) == len(path) {
+				next = append(next, -1)
+				it.Seek(key)
+				it.Next()
+			} else {
+				it.Seek(path)
+			}
+			for it.ValidForPrefix(path) {
+				c := it.Item().Key()[len(path)]
+				next = append(next, int(c))
+				if c == 0xff {
+					break
+				}
+				it.Seek(append(append([]byte{}, path...), c+1))
+			}
+
+			c := next[rnd.Intn(len(next))]
+			if c < 0 {
+				id = nodeID(path)
+				return nil
+			}
+			path = append(append([]byte{}, path...), byte(c))
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// SampleNodeIDs delegates to the underlying engine.
+func (w *WALEngine) SampleNodeIDs(label string, n int, rnd *rand.Rand) ([]NodeID, error) {
+	return SampleNodeIDs(w.engine, label, n, rnd)
+}
+
+// RandomNodeID delegates to the underlying engine.
+func (w *WALEngine) RandomNodeID(label string, rnd *rand.Rand) (NodeID, error) {
+	return RandomNodeID(w.engine, label, rnd)
+}
+
+// SampleNodeIDs samples the underlying engine; nodes waiting to be flushed
+// are not sampled until they are.
+func (ae *AsyncEngine) SampleNodeIDs(label string, n int, rnd *rand.Rand) ([]NodeID, error) {
+	return SampleNodeIDs(ae.engine, label, n, rnd)
+}
+
+// RandomNodeID picks from the underlying engine, like SampleNodeIDs.
+func (ae *AsyncEngine) RandomNodeID(label string, rnd *rand.Rand) (NodeID, error) {
+	return RandomNodeID(ae.engine, label, rnd)
+}
+
+var (
+	_ NodeSampler = (*BadgerEngine)(nil)
+	_ NodeSampler = (*WALEngine)(nil)
+	_ NodeSampler = (*AsyncEngine)(nil)
+)
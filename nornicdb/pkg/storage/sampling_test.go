@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleNodeIDs(t *testing.T) {
+	engine := NewMemoryEngine()
+	for i := 0; i < 100; i++ {
+		label := "Person"
+		if i%4 == 0 {
+			label = "Robot"
+		}
+		require.NoError(t, engine.CreateNode(&Node{ID: NodeID(fmt.Sprintf("n%03d", i)), Labels: []string{label}}))
+	}
+	rnd := rand.New(rand.NewSource(1))
+
+	ids, err := engine.SampleNodeIDs("Robot", 10, rnd)
+	require.NoError(t, err)
+	require.Len(t, ids, 10)
+	distinct := map[NodeID]bool{}
+	for _, id := range ids {
+		node, err := engine.GetNode(id)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Robot"}, node.Labels)
+		distinct[id] = true
+	}
+	assert.Len(t, distinct, 10)
+
+	ids, err = engine.SampleNodeIDs("robot", 50, rnd)
+	require.NoError(t, err)
+	assert.Len(t, ids, 25, "all of them when there are fewer")
+
+	ids, err = engine.SampleNodeIDs("", 1000, rnd)
+	require.NoError(t, err)
+	assert.Len(t, ids, 100)
+
+	ids, err = engine.SampleNodeIDs("Missing", 5, rnd)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	t.Run("uniform", func(t *testing.T) {
+		hits := map[NodeID]int{}
+		for i := 0; i < 2000; i++ {
+			ids, err := engine.SampleNodeIDs("Person", 5, rnd)
+			require.NoError(t, err)
+			for _, id := range ids {
+				hits[id]++
+			}
+		}
+		// 2000*5/75 ≈ 133 picks per node.
+		assert.Len(t, hits, 75)
+		for id, n := range hits {
+			assert.InDelta(t, 133, n, 60, "node %s", id)
+		}
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		ids, err := SampleNodeIDs(scanOnlyEngine{engine}, "Robot", 10, rnd)
+		require.NoError(t, err)
+		assert.Len(t, ids, 10)
+	})
+}
+
+// scanOnlyEngine hides the engine's NodeSampler.
+type scanOnlyEngine struct{ Engine }
+
+func TestRandomNodeID(t *testing.T) {
+	engine := NewMemoryEngine()
+	rnd := rand.New(rand.NewSource(1))
+
+	_, err := engine.RandomNodeID("Person", rnd)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for i := 0; i < 200; i++ {
+		require.NoError(t, engine.CreateNode(&Node{ID: NodeID(uuid.NewString()), Labels: []string{"Person"}}))
+	}
+	require.NoError(t, engine.CreateNode(&Node{ID: "other", Labels: []string{"Robot"}}))
+
+	picked := map[NodeID]bool{}
+	for i := 0; i < 400; i++ {
+		id, err := engine.RandomNodeID("Person", rnd)
+		require.NoError(t, err)
+		require.NotEqual(t, NodeID("other"), id)
+		picked[id] = true
+	}
+	// Random IDs are spread evenly, so most nodes come up.
+	assert.Greater(t, len(picked), 100)
+
+	id, err := engine.RandomNodeID("Robot", rnd)
+	require.NoError(t, err)
+	assert.Equal(t, NodeID("other"), id)
+
+	id, err = RandomNodeID(scanOnlyEngine{engine}, "Robot", rnd)
+	require.NoError(t, err)
+	assert.Equal(t, NodeID("other"), id)
+}
@@ -19,6 +19,7 @@ import (
 	"sync"
 
 	"github.com/orneryd/nornicdb/pkg/convert"
+	"github.com/orneryd/nornicdb/pkg/value"
 )
 
 // ConstraintType represents the type of constraint.
@@ -244,15 +245,15 @@ type CompositeKey struct {
 //   - Multi-column indexes
 //   - Deduplication of complex records
 func NewCompositeKey(values ...interface{}) CompositeKey {
-	// Create deterministic string representation
-	var parts []string
+	// Create deterministic, type-tagged encoding: "10" and 10 differ, while
+	// int 10 and int64 10 (as decoded from storage) are the same key
+	var encoded []byte
 	for _, v := range values {
-		parts = append(parts, fmt.Sprintf("%T:%v", v, v))
+		encoded = value.FromAny(v).AppendKey(encoded)
 	}
-	encoded := strings.Join(parts, "|")
 
 	// Hash for efficient map lookup
-	hash := sha256.Sum256([]byte(encoded))
+	hash := sha256.Sum256(encoded)
 
 	return CompositeKey{
 		Hash:   hex.EncodeToString(hash[:]),
@@ -994,6 +995,50 @@ func (sm *SchemaManager) GetVectorIndex(name string) (*VectorIndex, bool) {
 	return idx, exists
 }
 
+// GetVectorIndexes returns all vector indexes sorted by name.
+func (sm *SchemaManager) GetVectorIndexes() []*VectorIndex {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	indexes := make([]*VectorIndex, 0, len(sm.vectorIndexes))
+	for _, idx := range sm.vectorIndexes {
+		indexes = append(indexes, idx)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes
+}
+
+// DropVectorIndex removes a vector index. Returns false if it did not exist.
+func (sm *SchemaManager) DropVectorIndex(name string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if _, exists := sm.vectorIndexes[name]; !exists {
+		return false
+	}
+	delete(sm.vectorIndexes, name)
+	return true
+}
+
+// DropPropertyIndex removes a property or composite index by name.
+// Returns false if no such index existed.
+func (sm *SchemaManager) DropPropertyIndex(name string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for key, idx := range sm.propertyIndexes {
+		if idx.Name == name {
+			delete(sm.propertyIndexes, key)
+			return true
+		}
+	}
+	if _, exists := sm.compositeIndexes[name]; exists {
+		delete(sm.compositeIndexes, name)
+		return true
+	}
+	return false
+}
+
 // GetFulltextIndex returns a fulltext index by name.
 func (sm *SchemaManager) GetFulltextIndex(name string) (*FulltextIndex, bool) {
 	sm.mu.RLock()
@@ -161,6 +161,49 @@ func TestSchemaManager(t *testing.T) {
 		}
 	})
 
+	t.Run("GetAndDropVectorIndexes", func(t *testing.T) {
+		sm := NewSchemaManager()
+		sm.AddVectorIndex("text_idx", "Document", "embedding", 1024, "cosine")
+		sm.AddVectorIndex("image_idx", "Image", "clip", 512, "dot")
+
+		indexes := sm.GetVectorIndexes()
+		if len(indexes) != 2 || indexes[0].Name != "image_idx" || indexes[1].Name != "text_idx" {
+			t.Fatalf("Expected vector indexes sorted by name, got %v", indexes)
+		}
+
+		if !sm.DropVectorIndex("image_idx") {
+			t.Error("Expected drop to report an existing index")
+		}
+		if sm.DropVectorIndex("image_idx") {
+			t.Error("Expected second drop to report a missing index")
+		}
+		if _, exists := sm.GetVectorIndex("image_idx"); exists {
+			t.Error("Expected dropped index not to exist")
+		}
+		if len(sm.GetVectorIndexes()) != 1 {
+			t.Errorf("Expected 1 vector index, got %d", len(sm.GetVectorIndexes()))
+		}
+	})
+
+	t.Run("DropPropertyIndex", func(t *testing.T) {
+		sm := NewSchemaManager()
+		sm.AddPropertyIndex("name_idx", "User", []string{"name"})
+		sm.AddCompositeIndex("place_idx", "User", []string{"country", "city"})
+
+		if !sm.DropPropertyIndex("name_idx") || !sm.DropPropertyIndex("place_idx") {
+			t.Error("Expected drop to report existing indexes")
+		}
+		if sm.DropPropertyIndex("name_idx") {
+			t.Error("Expected second drop to report a missing index")
+		}
+		if _, exists := sm.GetPropertyIndex("User", "name"); exists {
+			t.Error("Expected dropped index not to exist")
+		}
+		if len(sm.GetIndexes()) != 0 {
+			t.Errorf("Expected no indexes, got %d", len(sm.GetIndexes()))
+		}
+	})
+
 	t.Run("GetIndexes", func(t *testing.T) {
 		sm := NewSchemaManager()
 		
@@ -363,6 +406,16 @@ func TestCompositeKey(t *testing.T) {
 		}
 	})
 
+	t.Run("CompositeKeyIntegerWidths", func(t *testing.T) {
+		// int 10 and int64 10 (as decoded from storage) are the same key
+		key1 := NewCompositeKey("US", 10)
+		key2 := NewCompositeKey("US", int64(10))
+
+		if key1.Hash != key2.Hash {
+			t.Error("Expected identical hashes for equal integers of different widths")
+		}
+	})
+
 	t.Run("CompositeKeyString", func(t *testing.T) {
 		key := NewCompositeKey("US", "NYC", 10001)
 		str := key.String()
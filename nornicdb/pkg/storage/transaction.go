@@ -42,6 +42,11 @@ var (
 	ErrTransactionActive   = errors.New("transaction already active")
 	ErrTransactionClosed   = errors.New("transaction already closed")
 	ErrTransactionRollback = errors.New("transaction rolled back")
+
+	// ErrConflict is returned by Commit when a concurrent transaction
+	// committed a write to data this transaction read or wrote. The
+	// transaction is rolled back and can be retried from the start.
+	ErrConflict = errors.New("transaction conflicts with a concurrent write")
 )
 
 // generateTxID generates a unique transaction ID using UUID v4.
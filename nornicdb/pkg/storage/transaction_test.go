@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -476,6 +477,66 @@ func TestTransaction_Isolation(t *testing.T) {
 	tx2.Rollback()
 }
 
+func TestTransaction_GetEdge(t *testing.T) {
+	engine := NewMemoryEngine()
+	defer engine.Close()
+	engine.CreateNode(&Node{ID: "get-edge-a", Labels: []string{"Node"}})
+	engine.CreateNode(&Node{ID: "get-edge-b", Labels: []string{"Node"}})
+	engine.CreateEdge(&Edge{ID: "stored", StartNode: "get-edge-a", EndNode: "get-edge-b", Type: "LINKS"})
+
+	tx, _ := engine.BeginTransaction()
+	defer tx.Rollback()
+
+	edge, err := tx.GetEdge("stored")
+	if err != nil || edge.Type != "LINKS" {
+		t.Fatalf("Expected stored edge, got %v, %v", edge, err)
+	}
+
+	// Pending creates are visible, pending deletes are not
+	tx.CreateEdge(&Edge{ID: "pending", StartNode: "get-edge-b", EndNode: "get-edge-a", Type: "BACK"})
+	if edge, err := tx.GetEdge("pending"); err != nil || edge.Type != "BACK" {
+		t.Errorf("Expected pending edge, got %v, %v", edge, err)
+	}
+	tx.DeleteEdge("stored")
+	if _, err := tx.GetEdge("stored"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for deleted edge, got %v", err)
+	}
+	if _, err := tx.GetEdge("missing"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestTransaction_Conflict(t *testing.T) {
+	engine := NewMemoryEngine()
+	defer engine.Close()
+	engine.CreateNode(&Node{ID: "counter", Labels: []string{"Counter"}})
+
+	// Both transactions read the node, then write it
+	tx1, _ := engine.BeginTransaction()
+	tx2, _ := engine.BeginTransaction()
+	if err := tx1.UpdateNode(&Node{ID: "counter", Labels: []string{"Counter"}, Properties: map[string]any{"n": 1}}); err != nil {
+		t.Fatalf("TX1 update failed: %v", err)
+	}
+	if err := tx2.UpdateNode(&Node{ID: "counter", Labels: []string{"Counter"}, Properties: map[string]any{"n": 2}}); err != nil {
+		t.Fatalf("TX2 update failed: %v", err)
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("TX1 commit failed: %v", err)
+	}
+
+	// The second commit would overwrite a value it never saw
+	if err := tx2.Commit(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+	if tx2.Status != TxStatusRolledBack {
+		t.Errorf("Expected rolled back status, got %s", tx2.Status)
+	}
+	node, _ := engine.GetNode("counter")
+	if node.Properties["n"] != 1 {
+		t.Errorf("Expected TX1's write to survive, got %v", node.Properties["n"])
+	}
+}
+
 func TestTransaction_MultipleOperationTypes(t *testing.T) {
 	engine := NewMemoryEngine()
 
@@ -32,6 +32,14 @@ func TestValidatePropertyType(t *testing.T) {
 		{"bool false valid", false, PropertyTypeBoolean, false},
 		{"bool invalid int", 1, PropertyTypeBoolean, true},
 		
+		// DATE, DATETIME and LIST tests
+		{"date string valid", "2024-02-29", PropertyTypeDate, false},
+		{"date invalid string", "tomorrow", PropertyTypeDate, true},
+		{"datetime string valid", "2024-02-29T10:30:00Z", PropertyTypeDateTime, false},
+		{"datetime invalid int", 1700000000, PropertyTypeDateTime, true},
+		{"list valid", []interface{}{1, "a"}, PropertyTypeList, false},
+		{"list invalid string", "a,b", PropertyTypeList, true},
+		
 		// NULL tests
 		{"null string", nil, PropertyTypeString, false},
 		{"null integer", nil, PropertyTypeInteger, false},
@@ -37,6 +37,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -208,6 +209,10 @@ type WAL struct {
 	totalSyncs    atomic.Int64
 	lastSyncTime  atomic.Int64
 	lastEntryTime atomic.Int64
+
+	// retention returns the highest sequence readers of the log are done
+	// with; truncation keeps later entries (see SetRetention).
+	retention func() uint64
 }
 
 // WALStats provides observability into WAL state.
@@ -222,6 +227,20 @@ type WALStats struct {
 	Closed        bool
 }
 
+// SyncLag returns how long the oldest write not yet synced to disk has been
+// waiting at now, or 0 when every write is synced.
+func (s WALStats) SyncLag(now time.Time) time.Duration {
+	if s.LastEntryTime.IsZero() || !s.LastEntryTime.After(s.LastSyncTime) {
+		return 0
+	}
+	// Writes since the last sync are pending; before any sync, all are
+	since := s.LastSyncTime
+	if since.IsZero() {
+		since = s.LastEntryTime
+	}
+	return now.Sub(since)
+}
+
 // NewWAL creates a new write-ahead log.
 func NewWAL(dir string, cfg *WALConfig) (*WAL, error) {
 	if cfg == nil {
@@ -481,6 +500,41 @@ func (w *WAL) Sequence() uint64 {
 	return w.sequence.Load()
 }
 
+// SetRetention makes TruncateAfterSnapshot keep the entries after the
+// sequence fn returns, even when a snapshot covers them, so readers of the
+// log such as CDC consumers do not miss entries. A nil fn removes the
+// limit.
+func (w *WAL) SetRetention(fn func() uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.retention = fn
+}
+
+// EntriesAfter returns up to limit entries after sequence afterSeq, in
+// order (limit <= 0 = all). Buffered entries are written out first so
+// every appended entry is visible. It reads the whole log file, so poll it
+// rather than call it per write.
+func (w *WAL) EntriesAfter(afterSeq uint64, limit int) ([]WALEntry, error) {
+	if w.closed.Load() {
+		return nil, ErrWALClosed
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.writer.Flush(); err != nil {
+		return nil, fmt.Errorf("wal: flush failed: %w", err)
+	}
+	entries, err := ReadWALEntriesAfter(filepath.Join(w.config.Dir, "wal.log"), afterSeq)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
 // =============================================================================
 // BATCH COMMIT MODE
 // =============================================================================
@@ -853,10 +907,16 @@ func (w *WAL) TruncateAfterSnapshot(snapshotSeq uint64) error {
 		return fmt.Errorf("wal: failed to read entries for truncate: %w", err)
 	}
 
-	// Filter entries AFTER snapshot sequence
+	// Filter entries AFTER snapshot sequence, or after the retention limit
+	keepAfter := snapshotSeq
+	if w.retention != nil {
+		if retained := w.retention(); retained < keepAfter {
+			keepAfter = retained
+		}
+	}
 	var keptEntries []WALEntry
 	for _, entry := range allEntries {
-		if entry.Sequence > snapshotSeq {
+		if entry.Sequence > keepAfter {
 			keptEntries = append(keptEntries, entry)
 		}
 	}
@@ -1501,6 +1561,13 @@ type TransactionState struct {
 // Incomplete transactions (no commit/abort) are rolled back using undo data.
 // Returns the engine state and recovery statistics.
 func RecoverWithTransactions(walDir, snapshotPath string) (*MemoryEngine, *TransactionRecoveryResult, error) {
+	return RecoverWithTransactionsOptions(walDir, snapshotPath, DefaultRecoveryOptions())
+}
+
+// RecoverWithTransactionsOptions is RecoverWithTransactions with control
+// over parallel replay and progress reporting. Committed transactions are
+// replayed in WAL order, with independent entries replayed concurrently.
+func RecoverWithTransactionsOptions(walDir, snapshotPath string, opts RecoveryOptions) (*MemoryEngine, *TransactionRecoveryResult, error) {
 	engine := NewMemoryEngine()
 	result := &TransactionRecoveryResult{
 		Transactions: make(map[string]*TransactionState),
@@ -1580,17 +1647,31 @@ func RecoverWithTransactions(walDir, snapshotPath string) (*MemoryEngine, *Trans
 		}
 	}
 
+	var committed []WALEntry
+	replayTotal := len(nonTxEntries)
+	for _, tx := range result.Transactions {
+		if tx.Done && !tx.Aborted {
+			committed = append(committed, tx.Entries...)
+		}
+		if !tx.Done || !tx.Aborted {
+			replayTotal += len(tx.Entries)
+		}
+	}
+	sort.Slice(committed, func(i, j int) bool { return committed[i].Sequence < committed[j].Sequence })
+	tracker := startRecovery("transactions", replayTotal, opts)
+	defer tracker.finish()
+
 	// Phase 2: Apply committed transactions
-	for txID, tx := range result.Transactions {
+	var failedMu sync.Mutex
+	replayWALWaves(engine, committed, opts.Workers, tracker, func(entry WALEntry, err error) {
+		if err != nil && !errors.Is(err, ErrAlreadyExists) {
+			failedMu.Lock()
+			result.FailedTransactions = append(result.FailedTransactions, GetEntryTxID(entry))
+			failedMu.Unlock()
+		}
+	})
+	for _, tx := range result.Transactions {
 		if tx.Done && !tx.Aborted {
-			// Transaction committed - apply all its entries
-			for _, entry := range tx.Entries {
-				if err := ReplayWALEntry(engine, entry); err != nil {
-					if !errors.Is(err, ErrAlreadyExists) {
-						result.FailedTransactions = append(result.FailedTransactions, txID)
-					}
-				}
-			}
 			result.CommittedTransactions++
 		}
 	}
@@ -1602,6 +1683,7 @@ func RecoverWithTransactions(walDir, snapshotPath string) (*MemoryEngine, *Trans
 			// First apply forward (in case partially applied before crash)
 			for _, entry := range tx.Entries {
 				ReplayWALEntry(engine, entry) // Ignore errors - might already exist
+				tracker.done.Add(1)
 			}
 			// Then undo in reverse order
 			for i := len(tx.Entries) - 1; i >= 0; i-- {
@@ -1626,6 +1708,7 @@ func RecoverWithTransactions(walDir, snapshotPath string) (*MemoryEngine, *Trans
 			}
 		}
 		result.NonTxApplied++
+		tracker.done.Add(1)
 	}
 
 	return engine, result, nil
@@ -1678,6 +1761,12 @@ func RecoverFromWAL(walDir, snapshotPath string) (*MemoryEngine, error) {
 // RecoverFromWALWithResult recovers database state and returns detailed results.
 // Use this for programmatic access to replay statistics and errors.
 func RecoverFromWALWithResult(walDir, snapshotPath string) (*MemoryEngine, ReplayResult, error) {
+	return RecoverFromWALWithOptions(walDir, snapshotPath, DefaultRecoveryOptions())
+}
+
+// RecoverFromWALWithOptions is RecoverFromWALWithResult with control over
+// parallel replay and progress reporting.
+func RecoverFromWALWithOptions(walDir, snapshotPath string, opts RecoveryOptions) (*MemoryEngine, ReplayResult, error) {
 	engine := NewMemoryEngine()
 	result := ReplayResult{}
 
@@ -1716,7 +1805,7 @@ func RecoverFromWALWithResult(walDir, snapshotPath string) (*MemoryEngine, Repla
 	}
 
 	// Replay entries with proper error tracking
-	result = ReplayWALEntries(engine, entries)
+	result = ReplayWALEntriesWithOptions(engine, entries, opts)
 
 	return engine, result, nil
 }
@@ -1899,8 +1988,10 @@ func (w *WALEngine) GetSnapshotStats() (totalSnapshots int64, lastSnapshotTime t
 	return total, lastTime
 }
 
-// CreateNode logs then executes node creation.
+// CreateNode logs then executes node creation. Property defaults are set
+// before logging, so replay restores the same generated values.
 func (w *WALEngine) CreateNode(node *Node) error {
+	w.PropertySchema().ApplyNodeDefaults(node)
 	if config.IsWALEnabled() {
 		if err := w.wal.Append(OpCreateNode, WALNodeData{Node: node}); err != nil {
 			return fmt.Errorf("wal: failed to log create_node: %w", err)
@@ -1943,6 +2034,7 @@ func (w *WALEngine) DeleteNode(id NodeID) error {
 
 // CreateEdge logs then executes edge creation.
 func (w *WALEngine) CreateEdge(edge *Edge) error {
+	w.PropertySchema().ApplyEdgeDefaults(edge)
 	if config.IsWALEnabled() {
 		if err := w.wal.Append(OpCreateEdge, WALEdgeData{Edge: edge}); err != nil {
 			return fmt.Errorf("wal: failed to log create_edge: %w", err)
@@ -1973,6 +2065,10 @@ func (w *WALEngine) DeleteEdge(id EdgeID) error {
 
 // BulkCreateNodes logs then executes bulk node creation.
 func (w *WALEngine) BulkCreateNodes(nodes []*Node) error {
+	schema := w.PropertySchema()
+	for _, node := range nodes {
+		schema.ApplyNodeDefaults(node)
+	}
 	if config.IsWALEnabled() {
 		if err := w.wal.Append(OpBulkNodes, WALBulkNodesData{Nodes: nodes}); err != nil {
 			return fmt.Errorf("wal: failed to log bulk_create_nodes: %w", err)
@@ -1983,6 +2079,10 @@ func (w *WALEngine) BulkCreateNodes(nodes []*Node) error {
 
 // BulkCreateEdges logs then executes bulk edge creation.
 func (w *WALEngine) BulkCreateEdges(edges []*Edge) error {
+	schema := w.PropertySchema()
+	for _, edge := range edges {
+		schema.ApplyEdgeDefaults(edge)
+	}
 	if config.IsWALEnabled() {
 		if err := w.wal.Append(OpBulkEdges, WALBulkEdgesData{Edges: edges}); err != nil {
 			return fmt.Errorf("wal: failed to log bulk_create_edges: %w", err)
@@ -242,6 +242,19 @@ func TestWAL_Stats(t *testing.T) {
 	assert.Equal(t, int64(10), stats.TotalWrites)
 }
 
+func TestWALStats_SyncLag(t *testing.T) {
+	now := time.Now()
+	synced := WALStats{LastEntryTime: now.Add(-2 * time.Second), LastSyncTime: now.Add(-time.Second)}
+	assert.Zero(t, synced.SyncLag(now))
+	assert.Zero(t, WALStats{}.SyncLag(now))
+
+	pending := WALStats{LastEntryTime: now.Add(-time.Second), LastSyncTime: now.Add(-3 * time.Second)}
+	assert.Equal(t, 3*time.Second, pending.SyncLag(now))
+
+	neverSynced := WALStats{LastEntryTime: now.Add(-time.Second)}
+	assert.Equal(t, time.Second, neverSynced.SyncLag(now))
+}
+
 func TestWAL_ReadEntries(t *testing.T) {
 	config.EnableWAL()
 	defer config.DisableWAL()
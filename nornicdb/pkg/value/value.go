@@ -0,0 +1,511 @@
+// Package value provides a compact tagged value type for NornicDB.
+//
+// Value stores scalars inline and tags them with a Kind, so code that works
+// on typed data can branch on one field instead of a dozen concrete Go types.
+// It is used where values are compared or hashed over and over:
+//
+//   - cypher: ORDER BY converts each sort column once and sorts on Values
+//     (Compare), and the query result caches key on AppendKey
+//   - storage: composite index keys and NODE KEY validation use AppendKey,
+//     so 1 and "1" are different keys and int and int64 are the same
+//   - bolt: the PackStream encoders write Value, []Value and Map directly
+//
+// Properties, result rows and events are still stored and passed around as
+// interface{}; FromAny and Any are the shims at those boundaries.
+//
+// Kinds:
+//   - Null, Bool, Int (int64), Float (float64), String
+//   - List ([]Value) and Map (map[string]Value)
+//   - Other: any Go value without a variant (temporal values, points...),
+//     kept as-is so conversions round-trip
+//
+// FromAny and Any convert at the interface{} boundaries:
+//
+//	v := value.FromAny(node.Properties["age"])
+//	if age, ok := v.AsInt(); ok && age >= 18 {
+//		// ...
+//	}
+//	props := value.FromAnyMap(node.Properties)
+//	back := props.Any() // map[string]interface{} again
+//
+// ELI12:
+//
+// An interface{} is a box with a sticker that says what's inside, and you
+// have to open it and check every time. A Value is a labelled tray: the label
+// says "number" or "text" and the number sits right there on the tray.
+package value
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind identifies the variant held by a Value.
+type Kind uint8
+
+// Value kinds. The zero Value is Null.
+const (
+	KindNull Kind = iota
+	KindBool
+	KindInt
+	KindFloat
+	KindString
+	KindList
+	KindMap
+	KindOther
+)
+
+// String returns the Cypher type name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case KindNull:
+		return "NULL"
+	case KindBool:
+		return "BOOLEAN"
+	case KindInt:
+		return "INTEGER"
+	case KindFloat:
+		return "FLOAT"
+	case KindString:
+		return "STRING"
+	case KindList:
+		return "LIST"
+	case KindMap:
+		return "MAP"
+	default:
+		return "ANY"
+	}
+}
+
+// Value is a tagged property or result value. Scalars are stored inline;
+// copying a Value is cheap and never allocates.
+type Value struct {
+	kind Kind
+	num  uint64 // Bool (0/1), Int (two's complement) or Float (IEEE 754 bits)
+	str  string // String
+	ref  any    // []Value for List, Map for Map, the raw value for Other
+}
+
+// Map is a property map of typed values.
+type Map map[string]Value
+
+// Null is the null value.
+var Null = Value{}
+
+// Bool returns a boolean value.
+func Bool(b bool) Value {
+	v := Value{kind: KindBool}
+	if b {
+		v.num = 1
+	}
+	return v
+}
+
+// Int returns an integer value.
+func Int(i int64) Value {
+	return Value{kind: KindInt, num: uint64(i)}
+}
+
+// Float returns a float value.
+func Float(f float64) Value {
+	return Value{kind: KindFloat, num: math.Float64bits(f)}
+}
+
+// String returns a string value.
+func String(s string) Value {
+	return Value{kind: KindString, str: s}
+}
+
+// ListOf returns a list value. The slice is not copied.
+func ListOf(items []Value) Value {
+	return Value{kind: KindList, ref: items}
+}
+
+// MapOf returns a map value. The map is not copied.
+func MapOf(m Map) Value {
+	return Value{kind: KindMap, ref: m}
+}
+
+// Kind returns the variant held by v.
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+// IsNull reports whether v is null.
+func (v Value) IsNull() bool {
+	return v.kind == KindNull
+}
+
+// AsBool returns the boolean held by v.
+func (v Value) AsBool() (bool, bool) {
+	return v.num == 1, v.kind == KindBool
+}
+
+// AsInt returns the integer held by v.
+func (v Value) AsInt() (int64, bool) {
+	return int64(v.num), v.kind == KindInt
+}
+
+// AsFloat returns v as a float. Integers are widened, as in Cypher
+// arithmetic.
+func (v Value) AsFloat() (float64, bool) {
+	switch v.kind {
+	case KindFloat:
+		return math.Float64frombits(v.num), true
+	case KindInt:
+		return float64(int64(v.num)), true
+	}
+	return 0, false
+}
+
+// AsString returns the string held by v.
+func (v Value) AsString() (string, bool) {
+	return v.str, v.kind == KindString
+}
+
+// AsList returns the items held by v.
+func (v Value) AsList() ([]Value, bool) {
+	items, ok := v.ref.([]Value)
+	return items, ok && v.kind == KindList
+}
+
+// AsMap returns the map held by v.
+func (v Value) AsMap() (Map, bool) {
+	m, ok := v.ref.(Map)
+	return m, ok && v.kind == KindMap
+}
+
+// FromAny converts a Go value to a Value. All integer and float types map to
+// Int and Float; typed slices and maps are converted element by element.
+// Types without a variant are kept as KindOther.
+func FromAny(x any) Value {
+	switch val := x.(type) {
+	case nil:
+		return Null
+	case Value:
+		return val
+	case bool:
+		return Bool(val)
+	case int:
+		return Int(int64(val))
+	case int8:
+		return Int(int64(val))
+	case int16:
+		return Int(int64(val))
+	case int32:
+		return Int(int64(val))
+	case int64:
+		return Int(val)
+	case uint:
+		return Int(int64(val))
+	case uint8:
+		return Int(int64(val))
+	case uint16:
+		return Int(int64(val))
+	case uint32:
+		return Int(int64(val))
+	case uint64:
+		return Int(int64(val))
+	case float32:
+		return Float(float64(val))
+	case float64:
+		return Float(val)
+	case string:
+		return String(val)
+	case []any:
+		return ListOf(FromRow(val))
+	case []string:
+		items := make([]Value, len(val))
+		for i, s := range val {
+			items[i] = String(s)
+		}
+		return ListOf(items)
+	case []int64:
+		items := make([]Value, len(val))
+		for i, n := range val {
+			items[i] = Int(n)
+		}
+		return ListOf(items)
+	case []int:
+		items := make([]Value, len(val))
+		for i, n := range val {
+			items[i] = Int(int64(n))
+		}
+		return ListOf(items)
+	case []float64:
+		items := make([]Value, len(val))
+		for i, f := range val {
+			items[i] = Float(f)
+		}
+		return ListOf(items)
+	case []float32:
+		items := make([]Value, len(val))
+		for i, f := range val {
+			items[i] = Float(float64(f))
+		}
+		return ListOf(items)
+	case []Value:
+		return ListOf(val)
+	case map[string]any:
+		return MapOf(FromAnyMap(val))
+	case Map:
+		return MapOf(val)
+	default:
+		return Value{kind: KindOther, ref: x}
+	}
+}
+
+// FromAnyMap converts a property map. A nil map converts to nil.
+func FromAnyMap(m map[string]any) Map {
+	if m == nil {
+		return nil
+	}
+	out := make(Map, len(m))
+	for k, x := range m {
+		out[k] = FromAny(x)
+	}
+	return out
+}
+
+// FromRow converts a result row.
+func FromRow(row []any) []Value {
+	out := make([]Value, len(row))
+	for i, x := range row {
+		out[i] = FromAny(x)
+	}
+	return out
+}
+
+// Any converts v back to the Go types used elsewhere in NornicDB: int64,
+// float64, string, bool, []interface{} and map[string]interface{}.
+func (v Value) Any() any {
+	switch v.kind {
+	case KindBool:
+		return v.num == 1
+	case KindInt:
+		return int64(v.num)
+	case KindFloat:
+		return math.Float64frombits(v.num)
+	case KindString:
+		return v.str
+	case KindList:
+		items, _ := v.ref.([]Value)
+		return ToRow(items)
+	case KindMap:
+		m, _ := v.ref.(Map)
+		return m.Any()
+	case KindOther:
+		return v.ref
+	default:
+		return nil
+	}
+}
+
+// Any converts m back to a map[string]interface{}. A nil map converts to nil.
+func (m Map) Any() map[string]any {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v.Any()
+	}
+	return out
+}
+
+// ToRow converts typed values back to a result row.
+func ToRow(items []Value) []any {
+	out := make([]any, len(items))
+	for i, v := range items {
+		out[i] = v.Any()
+	}
+	return out
+}
+
+// Equal reports whether two values are equal. Integers and floats compare
+// numerically (1 = 1.0); lists and maps compare element-wise.
+func Equal(a, b Value) bool {
+	if a.kind != b.kind {
+		af, aok := a.AsFloat()
+		bf, bok := b.AsFloat()
+		return aok && bok && af == bf
+	}
+	switch a.kind {
+	case KindNull:
+		return true
+	case KindBool, KindInt:
+		return a.num == b.num
+	case KindFloat:
+		return math.Float64frombits(a.num) == math.Float64frombits(b.num)
+	case KindString:
+		return a.str == b.str
+	case KindList:
+		al, _ := a.AsList()
+		bl, _ := b.AsList()
+		if len(al) != len(bl) {
+			return false
+		}
+		for i := range al {
+			if !Equal(al[i], bl[i]) {
+				return false
+			}
+		}
+		return true
+	case KindMap:
+		am, _ := a.AsMap()
+		bm, _ := b.AsMap()
+		if len(am) != len(bm) {
+			return false
+		}
+		for k, av := range am {
+			bv, ok := bm[k]
+			if !ok || !Equal(av, bv) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a.ref, b.ref)
+	}
+}
+
+// Compare orders two values: numbers numerically (1 < 1.5), strings and
+// booleans (false < true) by value. It reports false when the values have no
+// natural order - nulls, lists, maps, KindOther and mixed kinds - and leaves
+// the fallback to the caller.
+func Compare(a, b Value) (int, bool) {
+	if af, ok := a.AsFloat(); ok {
+		bf, ok := b.AsFloat()
+		if !ok {
+			return 0, false
+		}
+		if a.kind == KindInt && b.kind == KindInt {
+			return cmpOrdered(int64(a.num), int64(b.num)), true
+		}
+		return cmpOrdered(af, bf), true
+	}
+	if a.kind != b.kind {
+		return 0, false
+	}
+	switch a.kind {
+	case KindString:
+		return strings.Compare(a.str, b.str), true
+	case KindBool:
+		return cmpOrdered(a.num, b.num), true
+	}
+	return 0, false
+}
+
+func cmpOrdered[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// AppendKey appends a canonical, type-tagged encoding of v to b, for use in
+// hash keys. Unlike fmt's %v, the integer 1 and the string "1" differ. Map
+// keys are sorted; KindOther values fall back to their %v text.
+func (v Value) AppendKey(b []byte) []byte {
+	b = append(b, byte(v.kind))
+	switch v.kind {
+	case KindBool, KindInt, KindFloat:
+		b = binary.BigEndian.AppendUint64(b, v.num)
+	case KindString:
+		b = binary.AppendUvarint(b, uint64(len(v.str)))
+		b = append(b, v.str...)
+	case KindList:
+		items, _ := v.AsList()
+		b = binary.AppendUvarint(b, uint64(len(items)))
+		for _, item := range items {
+			b = item.AppendKey(b)
+		}
+	case KindMap:
+		m, _ := v.AsMap()
+		b = m.AppendKey(b)
+	case KindOther:
+		s := formatOther(v.ref)
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		b = append(b, s...)
+	}
+	return b
+}
+
+// AppendKey appends a canonical encoding of m to b. See Value.AppendKey.
+func (m Map) AppendKey(b []byte) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b = binary.AppendUvarint(b, uint64(len(keys)))
+	for _, k := range keys {
+		b = binary.AppendUvarint(b, uint64(len(k)))
+		b = append(b, k...)
+		b = m[k].AppendKey(b)
+	}
+	return b
+}
+
+// String formats v as a Cypher literal, for logs and error messages.
+func (v Value) String() string {
+	var sb strings.Builder
+	v.format(&sb)
+	return sb.String()
+}
+
+func (v Value) format(sb *strings.Builder) {
+	switch v.kind {
+	case KindNull:
+		sb.WriteString("null")
+	case KindBool:
+		sb.WriteString(strconv.FormatBool(v.num == 1))
+	case KindInt:
+		sb.WriteString(strconv.FormatInt(int64(v.num), 10))
+	case KindFloat:
+		sb.WriteString(strconv.FormatFloat(math.Float64frombits(v.num), 'g', -1, 64))
+	case KindString:
+		sb.WriteString(strconv.Quote(v.str))
+	case KindList:
+		items, _ := v.AsList()
+		sb.WriteByte('[')
+		for i, item := range items {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			item.format(sb)
+		}
+		sb.WriteByte(']')
+	case KindMap:
+		m, _ := v.AsMap()
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sb.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(k)
+			sb.WriteString(": ")
+			m[k].format(sb)
+		}
+		sb.WriteByte('}')
+	default:
+		sb.WriteString(formatOther(v.ref))
+	}
+}
+
+func formatOther(x any) string {
+	return fmt.Sprintf("%v", x)
+}
@@ -0,0 +1,196 @@
+package value
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZeroValueIsNull(t *testing.T) {
+	var v Value
+	assert.True(t, v.IsNull())
+	assert.Equal(t, KindNull, v.Kind())
+	assert.Nil(t, v.Any())
+	assert.Equal(t, "null", v.String())
+}
+
+func TestAccessors(t *testing.T) {
+	b, ok := Bool(true).AsBool()
+	assert.True(t, ok)
+	assert.True(t, b)
+
+	n, ok := Int(-42).AsInt()
+	assert.True(t, ok)
+	assert.Equal(t, int64(-42), n)
+
+	f, ok := Float(2.5).AsFloat()
+	assert.True(t, ok)
+	assert.Equal(t, 2.5, f)
+
+	// Integers widen to float; floats do not narrow to int
+	f, ok = Int(3).AsFloat()
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, f)
+	_, ok = Float(3).AsInt()
+	assert.False(t, ok)
+
+	s, ok := String("hi").AsString()
+	assert.True(t, ok)
+	assert.Equal(t, "hi", s)
+
+	_, ok = String("1").AsInt()
+	assert.False(t, ok)
+	_, ok = Null.AsList()
+	assert.False(t, ok)
+	_, ok = Int(1).AsMap()
+	assert.False(t, ok)
+}
+
+func TestFromAny(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		kind Kind
+		out  interface{}
+	}{
+		{"nil", nil, KindNull, nil},
+		{"bool", true, KindBool, true},
+		{"int", 7, KindInt, int64(7)},
+		{"int32", int32(-7), KindInt, int64(-7)},
+		{"uint16", uint16(9), KindInt, int64(9)},
+		{"float32", float32(0.5), KindFloat, 0.5},
+		{"float64", 1.25, KindFloat, 1.25},
+		{"string", "x", KindString, "x"},
+		{"[]string", []string{"a", "b"}, KindList, []interface{}{"a", "b"}},
+		{"[]int", []int{1, 2}, KindList, []interface{}{int64(1), int64(2)}},
+		{"[]float32", []float32{0.5}, KindList, []interface{}{0.5}},
+		{"[]interface{}", []interface{}{1, "a", nil}, KindList, []interface{}{int64(1), "a", nil}},
+		{"map", map[string]interface{}{"k": []interface{}{1}}, KindMap, map[string]interface{}{"k": []interface{}{int64(1)}}},
+		{"Value", Int(5), KindInt, int64(5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := FromAny(tt.in)
+			assert.Equal(t, tt.kind, v.Kind())
+			assert.Equal(t, tt.out, v.Any())
+		})
+	}
+}
+
+func TestFromAnyKeepsUnknownTypes(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	v := FromAny(ts)
+	assert.Equal(t, KindOther, v.Kind())
+	assert.Equal(t, ts, v.Any())
+}
+
+func TestMapRoundTrip(t *testing.T) {
+	props := map[string]interface{}{
+		"name":   "Alice",
+		"age":    int64(30),
+		"score":  0.75,
+		"active": true,
+		"tags":   []interface{}{"a", "b"},
+		"meta":   map[string]interface{}{"source": "import"},
+		"none":   nil,
+	}
+	m := FromAnyMap(props)
+	require.Len(t, m, len(props))
+	assert.Equal(t, props, m.Any())
+
+	assert.Nil(t, FromAnyMap(nil))
+	assert.Nil(t, Map(nil).Any())
+}
+
+func TestRowRoundTrip(t *testing.T) {
+	row := []interface{}{"a", int64(1), 2.5, nil}
+	assert.Equal(t, row, ToRow(FromRow(row)))
+}
+
+func TestEqual(t *testing.T) {
+	assert.True(t, Equal(Null, Null))
+	assert.True(t, Equal(Int(1), Int(1)))
+	assert.True(t, Equal(Int(1), Float(1)))
+	assert.False(t, Equal(Int(1), String("1")))
+	assert.False(t, Equal(Bool(true), Int(1)))
+	assert.False(t, Equal(Float(math.NaN()), Float(math.NaN())))
+	assert.True(t, Equal(FromAny([]int{1, 2}), FromAny([]interface{}{1.0, int64(2)})))
+	assert.False(t, Equal(FromAny([]int{1, 2}), FromAny([]int{1})))
+	assert.True(t, Equal(
+		FromAny(map[string]interface{}{"a": 1, "b": "x"}),
+		FromAny(map[string]interface{}{"b": "x", "a": 1}),
+	))
+	assert.False(t, Equal(
+		FromAny(map[string]interface{}{"a": 1}),
+		FromAny(map[string]interface{}{"b": 1}),
+	))
+}
+
+func TestCompare(t *testing.T) {
+	cmp := func(a, b Value) int {
+		c, ok := Compare(a, b)
+		assert.True(t, ok, "%v vs %v", a, b)
+		return c
+	}
+	assert.Equal(t, -1, cmp(Int(1), Int(2)))
+	assert.Equal(t, 1, cmp(Float(1.5), Int(1)))
+	assert.Equal(t, 0, cmp(Int(2), Float(2)))
+	assert.Equal(t, -1, cmp(Int(math.MaxInt64-1), Int(math.MaxInt64))) // No float rounding
+	assert.Equal(t, -1, cmp(String("a"), String("b")))
+	assert.Equal(t, -1, cmp(Bool(false), Bool(true)))
+
+	for _, pair := range [][2]Value{
+		{Null, Null},
+		{Int(1), String("1")},
+		{Bool(true), Int(1)},
+		{FromAny([]int{1}), FromAny([]int{1})},
+	} {
+		_, ok := Compare(pair[0], pair[1])
+		assert.False(t, ok, "%v vs %v", pair[0], pair[1])
+	}
+}
+
+func TestAppendKey(t *testing.T) {
+	key := func(m map[string]interface{}) string {
+		return string(FromAnyMap(m).AppendKey(nil))
+	}
+
+	// Deterministic regardless of map iteration order
+	m := map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": []interface{}{"x"}}
+	first := key(m)
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, first, key(m))
+	}
+
+	// Types are part of the key
+	assert.NotEqual(t, key(map[string]interface{}{"id": 1}), key(map[string]interface{}{"id": "1"}))
+	assert.NotEqual(t, key(map[string]interface{}{"id": 1}), key(map[string]interface{}{"id": 1.0}))
+	assert.NotEqual(t, key(map[string]interface{}{"a": "b,c"}), key(map[string]interface{}{"a": "b", "c": ""}))
+	assert.Equal(t, key(map[string]interface{}{"id": 1}), key(map[string]interface{}{"id": int64(1)}))
+}
+
+func TestString(t *testing.T) {
+	v := FromAny(map[string]interface{}{
+		"name": "Alice",
+		"age":  30,
+		"tags": []string{"a"},
+		"ok":   true,
+		"x":    1.5,
+		"none": nil,
+	})
+	assert.Equal(t, `{age: 30, name: "Alice", none: null, ok: true, tags: ["a"], x: 1.5}`, v.String())
+	assert.Equal(t, "INTEGER", KindInt.String())
+}
+
+func BenchmarkFromAnyMap(b *testing.B) {
+	props := map[string]interface{}{
+		"name": "Alice", "age": int64(30), "score": 0.75, "tags": []string{"a", "b"},
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = FromAnyMap(props)
+	}
+}
@@ -0,0 +1,283 @@
+// Package writethrottle applies backpressure to writers when the server is
+// under strain, instead of letting it degrade until it collapses.
+//
+// Three health signals are watched:
+//   - WAL sync lag: how long the oldest WAL write has waited for fsync
+//   - Memory pressure: heap in use as a fraction of the memory limit
+//   - Event queue saturation: fill of the fullest plugin event queue
+//
+// Each signal has a soft and a hard threshold. Between them, writes are
+// admitted after a delay that grows with the pressure, up to MaxDelay, so
+// writers slow down while the server catches up. Past the hard threshold
+// writes are rejected with an *Error that tells the client when to retry;
+// the servers report it as HTTP 429 and as a transient Bolt error, which
+// drivers retry with backoff. Reads are never throttled.
+//
+// Example:
+//
+//	t := writethrottle.New(writethrottle.DefaultConfig(), func() writethrottle.Signals {
+//		return writethrottle.Signals{WALSyncLag: wal.Stats().SyncLag(time.Now())}
+//	})
+//	if err := t.Admit(ctx); err != nil {
+//		return err // *writethrottle.Error
+//	}
+package writethrottle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Signal names reported in Error and Stats.
+const (
+	SignalWALLag     = "wal_lag"
+	SignalMemory     = "memory"
+	SignalEventQueue = "event_queue"
+)
+
+// ErrThrottled matches every *Error with errors.Is.
+var ErrThrottled = errors.New("writes throttled")
+
+// Error is returned when a write is rejected.
+type Error struct {
+	Signal     string        // One of the Signal* constants
+	Pressure   float64       // Pressure of the signal (>= 1 when rejected)
+	RetryAfter time.Duration // Suggested wait before retrying
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("writes throttled: server under %s pressure, retry after %s",
+		e.Signal, e.RetryAfter.Round(time.Millisecond))
+}
+
+// Is reports whether target is ErrThrottled.
+func (e *Error) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+// Signals is a reading of the health signals. Zero values are healthy.
+type Signals struct {
+	WALSyncLag     time.Duration // Age of the oldest WAL write not yet synced
+	HeapBytes      uint64        // Heap in use
+	MemoryLimit    uint64        // Memory limit (0 = none, memory not watched)
+	EventQueueFill float64       // Fill of the fullest plugin event queue, 0 to 1
+}
+
+// SignalSource reads the current signals.
+type SignalSource func() Signals
+
+// Config sets the thresholds. A signal whose hard threshold is zero is not
+// watched.
+type Config struct {
+	WALLagSoft     time.Duration // Start delaying writes (default 1s)
+	WALLagHard     time.Duration // Reject writes (default 10s)
+	MemorySoft     float64       // Heap fraction of the memory limit to start delaying (default 0.85)
+	MemoryHard     float64       // Heap fraction of the memory limit to reject (default 0.95)
+	EventQueueSoft float64       // Event queue fill to start delaying (default 0.8)
+	EventQueueHard float64       // Event queue fill to reject (default 0.95)
+	MaxDelay       time.Duration // Delay just below a hard threshold (default 500ms)
+	SampleInterval time.Duration // How long a reading of the signals is reused (default 250ms)
+}
+
+// DefaultConfig returns thresholds matching the health plugin's warning and
+// critical levels.
+func DefaultConfig() Config {
+	return Config{
+		WALLagSoft:     time.Second,
+		WALLagHard:     10 * time.Second,
+		MemorySoft:     0.85,
+		MemoryHard:     0.95,
+		EventQueueSoft: 0.8,
+		EventQueueHard: 0.95,
+		MaxDelay:       500 * time.Millisecond,
+		SampleInterval: 250 * time.Millisecond,
+	}
+}
+
+// Level describes what the throttle does to writes.
+type Level string
+
+const (
+	LevelOK        Level = "ok"
+	LevelDelaying  Level = "delaying"
+	LevelRejecting Level = "rejecting"
+)
+
+// Stats reports the throttle's state and counters.
+type Stats struct {
+	Level    Level         `json:"level"`
+	Signal   string        `json:"signal,omitempty"` // Signal under most pressure
+	Pressure float64       `json:"pressure"`         // 0 = healthy, 0-1 = delaying, >= 1 = rejecting
+	Signals  Signals       `json:"signals"`
+	Admitted int64         `json:"admitted"` // Writes admitted without delay
+	Delayed  int64         `json:"delayed"`  // Writes admitted after a delay
+	Rejected int64         `json:"rejected"`
+	Delay    time.Duration `json:"delay_total"` // Total delay imposed
+}
+
+// Throttle admits writes according to the signals. A nil *Throttle admits
+// every write.
+type Throttle struct {
+	config Config
+	source SignalSource
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+
+	mu       sync.Mutex
+	sampled  time.Time
+	signals  Signals
+	signal   string
+	pressure float64
+	admitted int64
+	delayed  int64
+	rejected int64
+	delay    time.Duration
+}
+
+// New returns a throttle reading the signals from source.
+func New(config Config, source SignalSource) *Throttle {
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = DefaultConfig().MaxDelay
+	}
+	if config.SampleInterval <= 0 {
+		config.SampleInterval = DefaultConfig().SampleInterval
+	}
+	return &Throttle{config: config, source: source, now: time.Now, sleep: sleepContext}
+}
+
+// Admit waits until a write may proceed. It returns at once when the
+// server is healthy, after a delay under moderate pressure, and with an
+// *Error past a hard threshold or ctx's error if ctx ends while waiting.
+func (t *Throttle) Admit(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	t.sample()
+	signal, pressure := t.signal, t.pressure
+	switch {
+	case pressure >= 1:
+		t.rejected++
+		t.mu.Unlock()
+		return &Error{Signal: signal, Pressure: pressure, RetryAfter: t.retryAfter()}
+	case pressure <= 0:
+		t.admitted++
+		t.mu.Unlock()
+		return nil
+	}
+	delay := time.Duration(pressure * float64(t.config.MaxDelay))
+	t.delayed++
+	t.delay += delay
+	t.mu.Unlock()
+
+	return t.sleep(ctx, delay)
+}
+
+// Stats returns the current state, reading the signals if the last
+// reading is stale.
+func (t *Throttle) Stats() Stats {
+	if t == nil {
+		return Stats{Level: LevelOK}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sample()
+	return Stats{
+		Level:    levelOf(t.pressure),
+		Signal:   t.signal,
+		Pressure: t.pressure,
+		Signals:  t.signals,
+		Admitted: t.admitted,
+		Delayed:  t.delayed,
+		Rejected: t.rejected,
+		Delay:    t.delay,
+	}
+}
+
+// Enabled reports whether writes are throttled at all.
+func (t *Throttle) Enabled() bool {
+	return t != nil
+}
+
+// sample reads the signals unless the last reading is recent. Callers hold
+// t.mu.
+func (t *Throttle) sample() {
+	now := t.now()
+	if !t.sampled.IsZero() && now.Sub(t.sampled) < t.config.SampleInterval {
+		return
+	}
+	t.sampled = now
+	t.signals = t.source()
+	t.signal, t.pressure = "", 0
+
+	c := t.config
+	s := t.signals
+	t.consider(SignalWALLag, s.WALSyncLag.Seconds(), c.WALLagSoft.Seconds(), c.WALLagHard.Seconds())
+	if s.MemoryLimit > 0 {
+		t.consider(SignalMemory, float64(s.HeapBytes)/float64(s.MemoryLimit), c.MemorySoft, c.MemoryHard)
+	}
+	t.consider(SignalEventQueue, s.EventQueueFill, c.EventQueueSoft, c.EventQueueHard)
+}
+
+// consider records signal if its pressure is the highest so far. Pressure
+// rises linearly from 0 at soft to 1 at hard.
+func (t *Throttle) consider(signal string, value, soft, hard float64) {
+	if hard <= 0 || value <= soft {
+		return
+	}
+	pressure := 1.0
+	if value < hard && hard > soft {
+		pressure = (value - soft) / (hard - soft)
+	}
+	if pressure > t.pressure {
+		t.signal, t.pressure = signal, pressure
+	}
+}
+
+// retryAfter is the wait suggested to rejected writers: long enough for a
+// fresh reading of the signals.
+func (t *Throttle) retryAfter() time.Duration {
+	return time.Duration(math.Max(float64(t.config.MaxDelay), float64(time.Second)))
+}
+
+func levelOf(pressure float64) Level {
+	switch {
+	case pressure >= 1:
+		return LevelRejecting
+	case pressure > 0:
+		return LevelDelaying
+	default:
+		return LevelOK
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// MemoryUsage returns the heap in use and the runtime's soft memory limit
+// (GOMEMLIMIT), or a zero limit when none is set.
+func MemoryUsage() (heapBytes, limit uint64) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	if l := debug.SetMemoryLimit(-1); l > 0 && l < math.MaxInt64 {
+		limit = uint64(l)
+	}
+	return m.HeapInuse, limit
+}
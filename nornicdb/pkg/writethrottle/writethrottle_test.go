@@ -0,0 +1,101 @@
+package writethrottle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixture is a throttle over settable signals that records its sleeps.
+type fixture struct {
+	*Throttle
+	signals Signals
+	slept   []time.Duration
+}
+
+func newFixture() *fixture {
+	f := &fixture{}
+	f.Throttle = New(DefaultConfig(), func() Signals { return f.signals })
+	f.config.SampleInterval = time.Nanosecond
+	f.sleep = func(ctx context.Context, d time.Duration) error {
+		f.slept = append(f.slept, d)
+		return ctx.Err()
+	}
+	return f
+}
+
+func TestAdmit(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture()
+
+	require.NoError(t, f.Admit(ctx))
+	assert.Empty(t, f.slept, "healthy: no delay")
+
+	f.signals.WALSyncLag = 5500 * time.Millisecond // halfway from 1s to 10s
+	require.NoError(t, f.Admit(ctx))
+	require.Len(t, f.slept, 1)
+	assert.Equal(t, 250*time.Millisecond, f.slept[0])
+
+	f.signals.EventQueueFill = 0.99
+	err := f.Admit(ctx)
+	var te *Error
+	require.ErrorAs(t, err, &te)
+	assert.ErrorIs(t, err, ErrThrottled)
+	assert.Equal(t, SignalEventQueue, te.Signal)
+	assert.Equal(t, time.Second, te.RetryAfter)
+
+	stats := f.Stats()
+	assert.Equal(t, LevelRejecting, stats.Level)
+	assert.Equal(t, int64(1), stats.Admitted)
+	assert.Equal(t, int64(1), stats.Delayed)
+	assert.Equal(t, int64(1), stats.Rejected)
+	assert.Equal(t, 250*time.Millisecond, stats.Delay)
+}
+
+func TestAdmit_Memory(t *testing.T) {
+	f := newFixture()
+	f.signals = Signals{HeapBytes: 99, MemoryLimit: 0}
+	assert.NoError(t, f.Admit(context.Background()), "no limit, memory not watched")
+
+	f.signals.MemoryLimit = 100
+	err := f.Admit(context.Background())
+	var te *Error
+	require.ErrorAs(t, err, &te)
+	assert.Equal(t, SignalMemory, te.Signal)
+
+	f.signals.HeapBytes = 90
+	assert.NoError(t, f.Admit(context.Background()))
+	assert.Equal(t, LevelDelaying, f.Stats().Level)
+}
+
+func TestAdmit_ContextEnds(t *testing.T) {
+	f := newFixture()
+	f.sleep = sleepContext
+	f.signals.WALSyncLag = 9 * time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, errors.Is(f.Admit(ctx), context.Canceled))
+}
+
+func TestSampleInterval(t *testing.T) {
+	reads := 0
+	th := New(Config{WALLagHard: time.Second, SampleInterval: time.Hour}, func() Signals {
+		reads++
+		return Signals{}
+	})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, th.Admit(context.Background()))
+	}
+	assert.Equal(t, 1, reads, "a reading is reused within the interval")
+}
+
+func TestNilThrottle(t *testing.T) {
+	var th *Throttle
+	assert.NoError(t, th.Admit(context.Background()))
+	assert.False(t, th.Enabled())
+	assert.Equal(t, LevelOK, th.Stats().Level)
+}
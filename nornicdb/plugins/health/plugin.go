@@ -0,0 +1,994 @@
+// Package health provides the Heimdall health plugin.
+//
+// The health plugin turns runtime and storage signals into diagnostics the
+// SLM can explain and act on. Each check grades one signal against a
+// warning and a critical threshold and, when it trips, suggests remediation,
+// usually another Heimdall action the user can run from chat.
+//
+// # Checks
+//
+//   - wal_lag - Age of WAL writes not yet synced to disk
+//   - disk_space - Free space on the data volume
+//   - cache_hit_rate - Drop of the search cache hit rate below its recent baseline
+//   - goroutines - Goroutine count, and steady growth across checks (leaks)
+//   - gpu_errors - GPU operations that fell back to CPU since the last check
+//   - event_queues - Fill level of plugin event queues, and dropped events
+//   - write_throttle - Writes delayed or rejected by write backpressure
+//
+// # Actions Provided
+//
+//   - heimdall.health.check - Run all checks
+//   - heimdall.health.goroutines - Group goroutines by function to find leaks
+//   - heimdall.health.event_queues - Per-plugin database event delivery
+//
+// # Example Usage
+//
+// User: "Is the database healthy?"
+// SLM maps to: heimdall.health.check
+// Result: Findings with severity, thresholds and suggested actions
+//
+// When interval_seconds is positive the checks also run in the background
+// and a Bifrost notification is sent whenever a check gets worse.
+package health
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+)
+
+// Plugin is the exported plugin variable.
+// For .so plugins, export as: var Plugin heimdall.HeimdallPlugin = &HealthPlugin{}
+var Plugin heimdall.HeimdallPlugin = &HealthPlugin{}
+
+// Severity grades a finding.
+type Severity string
+
+const (
+	SeverityOK       Severity = "ok"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+func (s Severity) rank() int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// worse returns the more severe of s and o.
+func (s Severity) worse(o Severity) Severity {
+	if o.rank() > s.rank() {
+		return o
+	}
+	return s
+}
+
+// Remediation is a suggested fix. Action, when set, is a Heimdall action
+// the user can run from chat.
+type Remediation struct {
+	Description string                 `json:"description"`
+	Action      string                 `json:"action,omitempty"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+}
+
+// Finding is the result of one check.
+type Finding struct {
+	Check       string        `json:"check"`
+	Severity    Severity      `json:"severity"`
+	Value       float64       `json:"value"`
+	Warning     float64       `json:"warning_threshold"`
+	Critical    float64       `json:"critical_threshold"`
+	Message     string        `json:"message"`
+	Remediation []Remediation `json:"remediation,omitempty"`
+}
+
+// setting is a configurable threshold or option.
+type setting struct {
+	key         string
+	def         float64
+	description string
+}
+
+// configSettings lists every configuration key with its default.
+var configSettings = []setting{
+	{"interval_seconds", 60, "Background check interval in seconds (0 disables background checks)"},
+	{"wal_lag_warning_ms", 1000, "WAL sync lag that raises a warning"},
+	{"wal_lag_critical_ms", 10000, "WAL sync lag that is critical"},
+	{"disk_free_warning_pct", 15, "Free disk percentage below which a warning is raised"},
+	{"disk_free_critical_pct", 5, "Free disk percentage below which it is critical"},
+	{"cache_hit_drop_warning_pct", 20, "Cache hit rate drop below baseline (percentage points) that raises a warning"},
+	{"cache_hit_drop_critical_pct", 40, "Cache hit rate drop below baseline (percentage points) that is critical"},
+	{"cache_min_lookups", 100, "Cache lookups needed between checks to grade the hit rate"},
+	{"goroutines_warning", 10000, "Goroutine count that raises a warning"},
+	{"goroutines_critical", 50000, "Goroutine count that is critical"},
+	{"goroutine_leak_checks", 5, "Consecutive checks of 50%+ total goroutine growth reported as a leak (0 disables)"},
+	{"gpu_errors_warning", 1, "GPU fallbacks between checks that raise a warning"},
+	{"gpu_errors_critical", 10, "GPU fallbacks between checks that are critical"},
+	{"event_queue_warning_pct", 80, "Plugin event queue fill percentage that raises a warning"},
+	{"event_queue_critical_pct", 95, "Plugin event queue fill percentage that is critical"},
+	{"write_rejects_warning", 1, "Writes rejected by write throttling between checks that raise a warning"},
+	{"write_rejects_critical", 100, "Writes rejected by write throttling between checks that are critical"},
+}
+
+// HealthPlugin implements heimdall.HeimdallPlugin for runtime health checks.
+type HealthPlugin struct {
+	mu      sync.RWMutex
+	ctx     heimdall.SubsystemContext
+	status  heimdall.SubsystemStatus
+	events  []heimdall.SubsystemEvent
+	config  map[string]float64
+	started time.Time
+
+	// Background checks
+	stop chan struct{}
+	done chan struct{}
+
+	// State carried between checks
+	checks        int64
+	lastCheck     time.Time
+	last          []Finding
+	cacheHits     uint64
+	cacheMisses   uint64
+	cacheBaseline float64 // Smoothed hit rate percentage, -1 until known
+	gpuErrors     int64
+	writeRejects  int64
+	dropped       map[string]int64 // Event queue drops per plugin
+	goroutines    []int            // Recent goroutine counts, oldest first
+}
+
+// === Identity Methods ===
+
+func (p *HealthPlugin) Name() string {
+	return "health"
+}
+
+func (p *HealthPlugin) Version() string {
+	return "1.0.0"
+}
+
+func (p *HealthPlugin) Type() string {
+	return heimdall.PluginTypeHeimdall
+}
+
+func (p *HealthPlugin) Description() string {
+	return "Health - runtime diagnostics with thresholds, severities and suggested remediation"
+}
+
+// === Lifecycle Methods ===
+
+func (p *HealthPlugin) Initialize(ctx heimdall.SubsystemContext) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ctx = ctx
+	p.status = heimdall.StatusReady
+	p.events = make([]heimdall.SubsystemEvent, 0, 100)
+	p.config = make(map[string]float64, len(configSettings))
+	for _, s := range configSettings {
+		p.config[s.key] = s.def
+	}
+	p.cacheBaseline = -1
+	p.dropped = make(map[string]int64)
+
+	p.addEvent("info", "Health checks initialized", nil)
+	return nil
+}
+
+func (p *HealthPlugin) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.status = heimdall.StatusRunning
+	p.started = time.Now()
+	p.startLoop()
+	p.addEvent("info", "Health checks active", nil)
+	return nil
+}
+
+func (p *HealthPlugin) Stop() error {
+	p.mu.Lock()
+	p.status = heimdall.StatusStopped
+	p.addEvent("info", "Health checks paused", nil)
+	p.mu.Unlock()
+
+	p.stopLoop()
+	return nil
+}
+
+func (p *HealthPlugin) Shutdown() error {
+	p.stopLoop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = heimdall.StatusUninitialized
+	p.addEvent("info", "Health checks shut down", nil)
+	return nil
+}
+
+// startLoop starts background checks if configured. Callers hold p.mu.
+func (p *HealthPlugin) startLoop() {
+	interval := time.Duration(p.config["interval_seconds"] * float64(time.Second))
+	if interval <= 0 || p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.loop(interval, p.stop, p.done)
+}
+
+// stopLoop stops background checks and waits for a running one to finish.
+func (p *HealthPlugin) stopLoop() {
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (p *HealthPlugin) loop(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.mu.RLock()
+			metrics, bifrost := p.ctx.Metrics, p.ctx.Bifrost
+			p.mu.RUnlock()
+			p.notify(bifrost, p.check(metrics))
+		}
+	}
+}
+
+// notify sends a Bifrost notification for each finding that got worse
+// since the previous check.
+func (p *HealthPlugin) notify(bifrost heimdall.BifrostBridge, c checkRun) {
+	if bifrost == nil {
+		return
+	}
+	for _, f := range c.worsened() {
+		notificationType := "warning"
+		if f.Severity == SeverityCritical {
+			notificationType = "error"
+		}
+		message := f.Message
+		if hint := remediationHint(f); hint != "" {
+			message += ". " + hint
+		}
+		bifrost.SendNotification(notificationType, "Health: "+f.Check, message)
+	}
+}
+
+// remediationHint describes the first suggested action of f.
+func remediationHint(f Finding) string {
+	for _, r := range f.Remediation {
+		if r.Action != "" {
+			return fmt.Sprintf("Suggested: %s (%s)", r.Action, r.Description)
+		}
+	}
+	if len(f.Remediation) > 0 {
+		return "Suggested: " + f.Remediation[0].Description
+	}
+	return ""
+}
+
+// === State & Health Methods ===
+
+func (p *HealthPlugin) Status() heimdall.SubsystemStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status
+}
+
+func (p *HealthPlugin) Health() heimdall.SubsystemHealth {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	overall := overallSeverity(p.last)
+	running := p.status == heimdall.StatusRunning || p.status == heimdall.StatusReady
+	details := map[string]interface{}{
+		"uptime_seconds": time.Since(p.started).Seconds(),
+		"checks_run":     p.checks,
+		"severity":       string(overall),
+	}
+	if !p.lastCheck.IsZero() {
+		details["last_check"] = p.lastCheck.Format(time.RFC3339)
+	}
+
+	return heimdall.SubsystemHealth{
+		Status:    p.status,
+		Healthy:   running && overall != SeverityCritical,
+		Message:   fmt.Sprintf("Health checks report: %s", overall),
+		LastCheck: time.Now(),
+		Details:   details,
+	}
+}
+
+func (p *HealthPlugin) Metrics() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	counts := map[Severity]int{}
+	for _, f := range p.last {
+		counts[f.Severity]++
+	}
+	return map[string]interface{}{
+		"status":     string(p.status),
+		"checks_run": p.checks,
+		"severity":   string(overallSeverity(p.last)),
+		"warnings":   counts[SeverityWarning],
+		"critical":   counts[SeverityCritical],
+	}
+}
+
+// === Configuration Methods ===
+
+func (p *HealthPlugin) Config() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make(map[string]interface{}, len(p.config))
+	for k, v := range p.config {
+		result[k] = v
+	}
+	return result
+}
+
+func (p *HealthPlugin) Configure(settings map[string]interface{}) error {
+	values := make(map[string]float64, len(settings))
+	for key, value := range settings {
+		if _, ok := p.setting(key); !ok {
+			return fmt.Errorf("unknown config key: %s", key)
+		}
+		v, ok := toFloat(value)
+		if !ok || v < 0 {
+			return fmt.Errorf("invalid %s: must be a non-negative number", key)
+		}
+		values[key] = v
+	}
+
+	p.mu.Lock()
+	for key, v := range values {
+		p.config[key] = v
+	}
+	p.addEvent("info", "Health configuration updated", settings)
+	_, intervalChanged := values["interval_seconds"]
+	running := p.status == heimdall.StatusRunning
+	p.mu.Unlock()
+
+	// Apply a new interval to the running loop
+	if intervalChanged && running {
+		p.stopLoop()
+		p.mu.Lock()
+		if p.status == heimdall.StatusRunning {
+			p.startLoop()
+		}
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+func (p *HealthPlugin) setting(key string) (setting, bool) {
+	for _, s := range configSettings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+func (p *HealthPlugin) ConfigSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, len(configSettings))
+	for _, s := range configSettings {
+		properties[s.key] = map[string]interface{}{
+			"type":        "number",
+			"description": s.description,
+			"minimum":     0,
+			"default":     s.def,
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// === Actions ===
+
+func (p *HealthPlugin) Actions() map[string]heimdall.ActionFunc {
+	return map[string]heimdall.ActionFunc{
+		"check": {
+			Description: "Run health checks (WAL lag, disk space, cache hit rate, goroutines, GPU errors, event queues, write throttling) with suggested fixes",
+			Category:    "monitoring",
+			Handler:     p.actionCheck,
+			Schema: &heimdall.ResultSchema{
+				Visualization: heimdall.VisualizationTable,
+				Rows:          "findings",
+				Columns: []heimdall.ResultColumn{
+					{Key: "check", Type: heimdall.ColumnString},
+					{Key: "severity", Type: heimdall.ColumnString},
+					{Key: "value", Type: heimdall.ColumnNumber},
+					{Key: "message", Type: heimdall.ColumnString},
+					{Key: "remediation", Type: heimdall.ColumnList, Optional: true},
+				},
+			},
+		},
+		"goroutines": {
+			Description: "Group running goroutines by function to find leaks (params: limit)",
+			Category:    "monitoring",
+			Handler:     p.actionGoroutines,
+			Schema: &heimdall.ResultSchema{
+				Visualization: heimdall.VisualizationBarChart,
+				Rows:          "groups",
+				Columns: []heimdall.ResultColumn{
+					{Key: "function", Type: heimdall.ColumnString},
+					{Key: "count", Label: "Goroutines", Type: heimdall.ColumnInteger},
+					{Key: "stack", Type: heimdall.ColumnList, Optional: true},
+				},
+				X: "function",
+				Y: []string{"count"},
+			},
+		},
+		"event_queues": {
+			Description: "Show per-plugin database event queue fill, drops and spooling",
+			Category:    "monitoring",
+			Handler:     p.actionEventQueues,
+		},
+	}
+}
+
+func (p *HealthPlugin) actionCheck(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	run := p.check(ctx.Metrics)
+	overall := overallSeverity(run.findings)
+
+	var issues []string
+	for _, f := range run.findings {
+		if f.Severity != SeverityOK {
+			issues = append(issues, fmt.Sprintf("%s (%s): %s", f.Check, f.Severity, f.Message))
+		}
+	}
+	message := fmt.Sprintf("All %d health checks passed", len(run.findings))
+	if len(issues) > 0 {
+		message = fmt.Sprintf("Health is %s: %s", overall, strings.Join(issues, "; "))
+	}
+
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: message,
+		Data: map[string]interface{}{
+			"severity": string(overall),
+			"findings": run.findings,
+		},
+	}, nil
+}
+
+func (p *HealthPlugin) actionGoroutines(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	limit := 10
+	if l, ok := toFloat(ctx.Params["limit"]); ok && l > 0 {
+		limit = int(l)
+	}
+
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to read goroutine profile: %v", err),
+		}, nil
+	}
+	total, groups := parseGoroutineProfile(buf.String())
+	shown := groups
+	if len(shown) > limit {
+		shown = shown[:limit]
+	}
+
+	message := fmt.Sprintf("%d goroutines in %d functions", total, len(groups))
+	if len(shown) > 0 {
+		message += fmt.Sprintf("; most in %s (%d)", shown[0].Function, shown[0].Count)
+	}
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: message,
+		Data: map[string]interface{}{
+			"total":  total,
+			"groups": shown,
+		},
+	}, nil
+}
+
+func (p *HealthPlugin) actionEventQueues(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	stats := heimdall.EventDeliveryStatsAll()
+	var queued int
+	var dropped int64
+	for _, s := range stats {
+		queued += s.Queued
+		dropped += s.Dropped
+	}
+
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("%d plugins receive events: %d queued, %d dropped", len(stats), queued, dropped),
+		Data: map[string]interface{}{
+			"plugins": stats,
+		},
+	}, nil
+}
+
+// === Checks ===
+
+// checkRun is the outcome of check: the findings and those of the
+// previous run, to tell which got worse.
+type checkRun struct {
+	findings []Finding
+	previous []Finding
+}
+
+// worsened returns the non-OK findings more severe than in the previous run.
+func (c checkRun) worsened() []Finding {
+	before := make(map[string]Severity, len(c.previous))
+	for _, f := range c.previous {
+		before[f.Check] = f.Severity
+	}
+	var out []Finding
+	for _, f := range c.findings {
+		if f.Severity != SeverityOK && f.Severity.rank() > before[f.Check].rank() {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// check runs every check against metrics (runtime statistics are read
+// directly when nil) and the event dispatcher's delivery statistics.
+func (p *HealthPlugin) check(metrics heimdall.MetricsReader) checkRun {
+	var m heimdall.RuntimeMetrics
+	if metrics != nil {
+		m = metrics.Runtime()
+	} else {
+		m.GoroutineCount = runtime.NumGoroutine()
+	}
+	queues := heimdall.EventDeliveryStatsAll()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	findings := []Finding{p.checkWALLag(m)}
+	if f, ok := p.checkDiskSpace(m); ok {
+		findings = append(findings, f)
+	}
+	findings = append(findings,
+		p.checkCacheHitRate(m),
+		p.checkGoroutines(m),
+		p.checkGPUErrors(m),
+		p.checkEventQueues(queues),
+		p.checkWriteThrottle(m),
+	)
+
+	run := checkRun{findings: findings, previous: p.last}
+	p.last = findings
+	p.checks++
+	p.lastCheck = time.Now()
+	for _, f := range run.worsened() {
+		p.addEvent(string(f.Severity), fmt.Sprintf("%s: %s", f.Check, f.Message), map[string]interface{}{
+			"value":       f.Value,
+			"remediation": f.Remediation,
+		})
+	}
+	return run
+}
+
+func (p *HealthPlugin) checkWALLag(m heimdall.RuntimeMetrics) Finding {
+	f := p.grade("wal_lag", float64(m.WALSyncLagMs), "wal_lag_warning_ms", "wal_lag_critical_ms", false)
+	f.Message = fmt.Sprintf("WAL writes have waited %dms for sync", m.WALSyncLagMs)
+	if f.Severity != SeverityOK {
+		f.Remediation = []Remediation{
+			{Description: "Look for heavy write queries", Action: "heimdall.watcher.queries"},
+			{Description: "Check disk I/O latency on the data volume; slow fsync delays WAL syncs"},
+		}
+	}
+	return f
+}
+
+// checkDiskSpace reports false when the host does not report disk usage.
+func (p *HealthPlugin) checkDiskSpace(m heimdall.RuntimeMetrics) (Finding, bool) {
+	if m.DiskTotalBytes == 0 {
+		return Finding{}, false
+	}
+	freePct := float64(m.DiskFreeBytes) / float64(m.DiskTotalBytes) * 100
+	f := p.grade("disk_space", freePct, "disk_free_warning_pct", "disk_free_critical_pct", true)
+	f.Message = fmt.Sprintf("%.1f%% free on the data volume (%d MB of %d MB)",
+		freePct, m.DiskFreeBytes/1024/1024, m.DiskTotalBytes/1024/1024)
+	if f.Severity != SeverityOK {
+		f.Remediation = []Remediation{
+			{Description: "Review database size", Action: "heimdall.watcher.db_stats"},
+			{Description: "Free space on the data volume or grow it; old backups and exports are common culprits"},
+		}
+	}
+	return f, true
+}
+
+func (p *HealthPlugin) checkCacheHitRate(m heimdall.RuntimeMetrics) Finding {
+	f := Finding{
+		Check:    "cache_hit_rate",
+		Severity: SeverityOK,
+		Warning:  p.config["cache_hit_drop_warning_pct"],
+		Critical: p.config["cache_hit_drop_critical_pct"],
+	}
+
+	// Counters going backwards mean they were reset
+	if m.CacheHits < p.cacheHits || m.CacheMisses < p.cacheMisses {
+		p.cacheHits, p.cacheMisses = 0, 0
+	}
+	hits := m.CacheHits - p.cacheHits
+	lookups := hits + m.CacheMisses - p.cacheMisses
+	if lookups < uint64(p.config["cache_min_lookups"]) || lookups == 0 {
+		f.Message = fmt.Sprintf("%d cache lookups since the last check, too few to grade", lookups)
+		return f
+	}
+	p.cacheHits, p.cacheMisses = m.CacheHits, m.CacheMisses
+
+	rate := float64(hits) / float64(lookups) * 100
+	if p.cacheBaseline < 0 {
+		p.cacheBaseline = rate
+		f.Message = fmt.Sprintf("Hit rate %.1f%%, baseline established", rate)
+		return f
+	}
+
+	drop := p.cacheBaseline - rate
+	f.Value = drop
+	f.Severity = gradeAbove(drop, f.Warning, f.Critical)
+	f.Message = fmt.Sprintf("Hit rate %.1f%% against a baseline of %.1f%%", rate, p.cacheBaseline)
+	if f.Severity != SeverityOK {
+		f.Remediation = []Remediation{
+			{Description: "Check whether write bursts or re-embedding are invalidating the search cache", Action: "heimdall.watcher.metrics"},
+			{Description: "A changed query mix also lowers the hit rate; raise the cache size if it persists"},
+		}
+	}
+	p.cacheBaseline = 0.8*p.cacheBaseline + 0.2*rate
+	return f
+}
+
+func (p *HealthPlugin) checkGoroutines(m heimdall.RuntimeMetrics) Finding {
+	count := m.GoroutineCount
+	f := p.grade("goroutines", float64(count), "goroutines_warning", "goroutines_critical", false)
+	f.Message = fmt.Sprintf("%d goroutines", count)
+
+	// A leak shows as growth in every one of the last N checks
+	n := int(p.config["goroutine_leak_checks"])
+	p.goroutines = append(p.goroutines, count)
+	if len(p.goroutines) > n+1 {
+		p.goroutines = p.goroutines[len(p.goroutines)-n-1:]
+	}
+	if n > 0 && len(p.goroutines) == n+1 && growing(p.goroutines) && float64(count) >= 1.5*float64(p.goroutines[0]) {
+		f.Severity = f.Severity.worse(SeverityWarning)
+		f.Message = fmt.Sprintf("%d goroutines, up from %d over the last %d checks (possible leak)", count, p.goroutines[0], n)
+	}
+
+	if f.Severity != SeverityOK {
+		f.Remediation = []Remediation{
+			{Description: "Group goroutines by function to find the leak", Action: "heimdall.health.goroutines"},
+			{Description: "Long-running queries hold goroutines", Action: "heimdall.watcher.queries"},
+		}
+	}
+	return f
+}
+
+// growing reports whether counts increase at every step.
+func growing(counts []int) bool {
+	for i := 1; i < len(counts); i++ {
+		if counts[i] <= counts[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *HealthPlugin) checkGPUErrors(m heimdall.RuntimeMetrics) Finding {
+	if m.GPUErrors < p.gpuErrors {
+		p.gpuErrors = 0
+	}
+	fallbacks := m.GPUErrors - p.gpuErrors
+	p.gpuErrors = m.GPUErrors
+
+	f := p.grade("gpu_errors", float64(fallbacks), "gpu_errors_warning", "gpu_errors_critical", false)
+	f.Message = fmt.Sprintf("%d GPU operations fell back to CPU since the last check", fallbacks)
+	if f.Severity != SeverityOK {
+		f.Remediation = []Remediation{
+			{Description: "Check the GPU driver and free GPU memory; fallbacks run on CPU and are slower"},
+			{Description: "Disable GPU acceleration if the device keeps failing, to stop retrying"},
+		}
+	}
+	return f
+}
+
+func (p *HealthPlugin) checkEventQueues(stats []heimdall.EventDeliveryStats) Finding {
+	f := Finding{
+		Check:    "event_queues",
+		Severity: SeverityOK,
+		Warning:  p.config["event_queue_warning_pct"],
+		Critical: p.config["event_queue_critical_pct"],
+		Message:  "No plugins receive database events",
+	}
+
+	var worst string
+	var newDrops int64
+	seen := make(map[string]bool, len(stats))
+	for _, s := range stats {
+		seen[s.Plugin] = true
+		if s.Capacity > 0 {
+			fill := float64(s.Queued) / float64(s.Capacity) * 100
+			if worst == "" || fill > f.Value {
+				f.Value, worst = fill, s.Plugin
+			}
+		}
+		if s.Dropped > p.dropped[s.Plugin] {
+			newDrops += s.Dropped - p.dropped[s.Plugin]
+		}
+		p.dropped[s.Plugin] = s.Dropped
+	}
+	for plugin := range p.dropped {
+		if !seen[plugin] {
+			delete(p.dropped, plugin)
+		}
+	}
+	if worst == "" {
+		return f
+	}
+
+	f.Severity = gradeAbove(f.Value, f.Warning, f.Critical)
+	f.Message = fmt.Sprintf("Fullest event queue is %s at %.0f%%", worst, f.Value)
+	if newDrops > 0 {
+		f.Severity = f.Severity.worse(SeverityWarning)
+		f.Message += fmt.Sprintf("; %d events dropped since the last check", newDrops)
+	}
+	if f.Severity != SeverityOK {
+		f.Remediation = []Remediation{
+			{Description: "See which plugins are falling behind", Action: "heimdall.health.event_queues"},
+			{Description: "Narrow the slow plugin's EventSubscription or raise the event queue size"},
+		}
+	}
+	return f
+}
+
+func (p *HealthPlugin) checkWriteThrottle(m heimdall.RuntimeMetrics) Finding {
+	if m.WritesRejected < p.writeRejects {
+		p.writeRejects = 0
+	}
+	rejected := m.WritesRejected - p.writeRejects
+	p.writeRejects = m.WritesRejected
+
+	f := p.grade("write_throttle", float64(rejected), "write_rejects_warning", "write_rejects_critical", false)
+	f.Message = fmt.Sprintf("%d writes rejected by write throttling since the last check", rejected)
+	if m.WriteThrottlePressure > 0 && m.WriteThrottlePressure < 1 {
+		f.Severity = f.Severity.worse(SeverityWarning)
+		f.Message = fmt.Sprintf("Writes are being delayed (pressure %.0f%%); %d rejected since the last check",
+			m.WriteThrottlePressure*100, rejected)
+	}
+	if f.Severity != SeverityOK {
+		f.Remediation = []Remediation{
+			{Description: "Writes are throttled because WAL lag, memory or event queues crossed a threshold; the other findings show which", Action: "heimdall.health.check"},
+			{Description: "Look for heavy write queries", Action: "heimdall.watcher.queries"},
+		}
+	}
+	return f
+}
+
+// grade builds a finding for value against the configured thresholds.
+// With lowerIsWorse, values below the thresholds trip them.
+func (p *HealthPlugin) grade(check string, value float64, warningKey, criticalKey string, lowerIsWorse bool) Finding {
+	f := Finding{
+		Check:    check,
+		Value:    value,
+		Warning:  p.config[warningKey],
+		Critical: p.config[criticalKey],
+	}
+	if lowerIsWorse {
+		f.Severity = gradeBelow(value, f.Warning, f.Critical)
+	} else {
+		f.Severity = gradeAbove(value, f.Warning, f.Critical)
+	}
+	return f
+}
+
+// gradeAbove grades value where higher is worse. Zero thresholds are off.
+func gradeAbove(value, warning, critical float64) Severity {
+	switch {
+	case critical > 0 && value >= critical:
+		return SeverityCritical
+	case warning > 0 && value >= warning:
+		return SeverityWarning
+	default:
+		return SeverityOK
+	}
+}
+
+// gradeBelow grades value where lower is worse. Zero thresholds are off.
+func gradeBelow(value, warning, critical float64) Severity {
+	switch {
+	case critical > 0 && value < critical:
+		return SeverityCritical
+	case warning > 0 && value < warning:
+		return SeverityWarning
+	default:
+		return SeverityOK
+	}
+}
+
+// overallSeverity returns the worst severity of findings.
+func overallSeverity(findings []Finding) Severity {
+	overall := SeverityOK
+	for _, f := range findings {
+		overall = overall.worse(f.Severity)
+	}
+	return overall
+}
+
+// === Goroutine Profile ===
+
+// goroutineGroup counts goroutines whose stack starts in Function.
+type goroutineGroup struct {
+	Count    int      `json:"count"`
+	Function string   `json:"function"`
+	Stack    []string `json:"stack"` // Top frames of the largest stack
+}
+
+// parseGoroutineProfile groups a debug=1 goroutine profile by the first
+// non-runtime function of each stack, largest group first.
+func parseGoroutineProfile(profile string) (total int, groups []goroutineGroup) {
+	byFunction := make(map[string]*goroutineGroup)
+	largest := make(map[string]int)
+
+	for _, block := range strings.Split(profile, "\n\n") {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		header := lines[0]
+		if strings.HasPrefix(header, "goroutine profile:") && len(lines) > 1 {
+			header, lines = lines[1], lines[1:]
+		}
+		at := strings.Index(header, " @ ")
+		if at < 0 {
+			continue
+		}
+		count, err := strconv.Atoi(header[:at])
+		if err != nil {
+			continue
+		}
+
+		var stack []string
+		function := ""
+		for _, line := range lines[1:] {
+			fields := strings.Split(strings.TrimSpace(line), "\t")
+			if len(fields) < 3 || fields[0] != "#" {
+				continue
+			}
+			name := fields[2]
+			if i := strings.LastIndex(name, "+0x"); i > 0 {
+				name = name[:i]
+			}
+			if len(stack) < 5 {
+				stack = append(stack, name)
+			}
+			if function == "" && !runtimeFrame(name) {
+				function = name
+			}
+		}
+		if function == "" && len(stack) > 0 {
+			function = stack[0]
+		}
+
+		total += count
+		g, ok := byFunction[function]
+		if !ok {
+			g = &goroutineGroup{Function: function}
+			byFunction[function] = g
+		}
+		g.Count += count
+		if count > largest[function] {
+			largest[function] = count
+			g.Stack = stack
+		}
+	}
+
+	for _, g := range byFunction {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Function < groups[j].Function
+	})
+	return total, groups
+}
+
+// runtimeFrame reports whether name belongs to the Go runtime rather than
+// to the code that started the goroutine.
+func runtimeFrame(name string) bool {
+	for _, prefix := range []string{"runtime.", "runtime/internal/", "internal/", "sync.runtime_"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// === Data Access Methods ===
+
+func (p *HealthPlugin) Summary() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.lastCheck.IsZero() {
+		return fmt.Sprintf("Health: Status=%s, no checks run yet", p.status)
+	}
+	return fmt.Sprintf("Health: Status=%s, Severity=%s, Checks=%d, LastCheck=%.0fs ago",
+		p.status, overallSeverity(p.last), p.checks, time.Since(p.lastCheck).Seconds())
+}
+
+func (p *HealthPlugin) RecentEvents(limit int) []heimdall.SubsystemEvent {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if limit <= 0 || limit > len(p.events) {
+		limit = len(p.events)
+	}
+	result := make([]heimdall.SubsystemEvent, limit)
+	copy(result, p.events[len(p.events)-limit:])
+	return result
+}
+
+// === Internal Helpers ===
+
+// addEvent records an event. Callers hold p.mu.
+func (p *HealthPlugin) addEvent(eventType, message string, data map[string]interface{}) {
+	p.events = append(p.events, heimdall.SubsystemEvent{
+		Time:    time.Now(),
+		Type:    eventType,
+		Message: message,
+		Data:    data,
+	})
+
+	// Keep only last 100 events
+	if len(p.events) > 100 {
+		p.events = p.events[len(p.events)-100:]
+	}
+}
+
+// toFloat converts a JSON or Go number to float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,274 @@
+package health
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+	"github.com/orneryd/nornicdb/pkg/heimdall/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// healthyMetrics reports a healthy server with a 100 GB data volume.
+func healthyMetrics() heimdall.RuntimeMetrics {
+	return heimdall.RuntimeMetrics{
+		GoroutineCount: 100,
+		WALSyncLagMs:   5,
+		DiskFreeBytes:  50 << 30,
+		DiskTotalBytes: 100 << 30,
+	}
+}
+
+// check runs the check action and returns its findings by check name.
+func check(t *testing.T, h *plugintest.Harness) (Severity, map[string]Finding) {
+	t.Helper()
+	result, err := h.Action("check", nil)
+	require.NoError(t, err)
+	require.True(t, result.Success)
+
+	byCheck := map[string]Finding{}
+	for _, f := range result.Data["findings"].([]Finding) {
+		byCheck[f.Check] = f
+	}
+	return Severity(result.Data["severity"].(string)), byCheck
+}
+
+func TestHealthPlugin_Identity(t *testing.T) {
+	p := &HealthPlugin{}
+	assert.Equal(t, "health", p.Name())
+	assert.Equal(t, heimdall.PluginTypeHeimdall, p.Type())
+	assert.Contains(t, p.Actions(), "check")
+	assert.Contains(t, p.Actions(), "goroutines")
+	assert.Contains(t, p.Actions(), "event_queues")
+}
+
+func TestHealthCheck_Healthy(t *testing.T) {
+	h := plugintest.Start(t, &HealthPlugin{})
+	h.Metrics.Set(healthyMetrics())
+
+	severity, findings := check(t, h)
+	assert.Equal(t, SeverityOK, severity)
+	for _, name := range []string{"wal_lag", "disk_space", "cache_hit_rate", "goroutines", "gpu_errors", "event_queues", "write_throttle"} {
+		require.Contains(t, findings, name)
+		assert.Equal(t, SeverityOK, findings[name].Severity, name)
+		assert.Empty(t, findings[name].Remediation, name)
+	}
+	assert.Equal(t, 50.0, findings["disk_space"].Value)
+	assert.True(t, h.Plugin.Health().Healthy)
+}
+
+func TestHealthCheck_Thresholds(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      func(m *heimdall.RuntimeMetrics)
+		check    string
+		severity Severity
+		action   string
+	}{
+		{"wal lag warning", func(m *heimdall.RuntimeMetrics) { m.WALSyncLagMs = 2000 }, "wal_lag", SeverityWarning, "heimdall.watcher.queries"},
+		{"wal lag critical", func(m *heimdall.RuntimeMetrics) { m.WALSyncLagMs = 20000 }, "wal_lag", SeverityCritical, "heimdall.watcher.queries"},
+		{"disk low", func(m *heimdall.RuntimeMetrics) { m.DiskFreeBytes = 10 << 30 }, "disk_space", SeverityWarning, "heimdall.watcher.db_stats"},
+		{"disk full", func(m *heimdall.RuntimeMetrics) { m.DiskFreeBytes = 1 << 30 }, "disk_space", SeverityCritical, "heimdall.watcher.db_stats"},
+		{"many goroutines", func(m *heimdall.RuntimeMetrics) { m.GoroutineCount = 20000 }, "goroutines", SeverityWarning, "heimdall.health.goroutines"},
+		{"gpu fallbacks", func(m *heimdall.RuntimeMetrics) { m.GPUErrors = 12 }, "gpu_errors", SeverityCritical, ""},
+		{"writes delayed", func(m *heimdall.RuntimeMetrics) { m.WriteThrottlePressure = 0.5 }, "write_throttle", SeverityWarning, "heimdall.health.check"},
+		{"writes rejected", func(m *heimdall.RuntimeMetrics) { m.WriteThrottlePressure, m.WritesRejected = 1, 250 }, "write_throttle", SeverityCritical, "heimdall.health.check"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := plugintest.Start(t, &HealthPlugin{})
+			m := healthyMetrics()
+			tt.set(&m)
+			h.Metrics.Set(m)
+
+			severity, findings := check(t, h)
+			assert.Equal(t, tt.severity, severity)
+			f := findings[tt.check]
+			assert.Equal(t, tt.severity, f.Severity)
+			require.NotEmpty(t, f.Remediation)
+			if tt.action != "" {
+				assert.Equal(t, tt.action, f.Remediation[0].Action)
+			}
+		})
+	}
+}
+
+func TestHealthCheck_NoDiskReported(t *testing.T) {
+	h := plugintest.Start(t, &HealthPlugin{})
+	h.Metrics.Set(heimdall.RuntimeMetrics{GoroutineCount: 10})
+
+	_, findings := check(t, h)
+	assert.NotContains(t, findings, "disk_space")
+}
+
+func TestHealthCheck_CacheHitRateRegression(t *testing.T) {
+	h := plugintest.Start(t, &HealthPlugin{})
+	m := healthyMetrics()
+
+	// Too few lookups to grade
+	m.CacheHits, m.CacheMisses = 40, 10
+	h.Metrics.Set(m)
+	_, findings := check(t, h)
+	assert.Contains(t, findings["cache_hit_rate"].Message, "too few")
+
+	// 90% establishes the baseline
+	m.CacheHits, m.CacheMisses = 900, 100
+	h.Metrics.Set(m)
+	_, findings = check(t, h)
+	assert.Equal(t, SeverityOK, findings["cache_hit_rate"].Severity)
+	assert.Contains(t, findings["cache_hit_rate"].Message, "baseline established")
+
+	// 40% over the next 1000 lookups is a 50 point drop
+	m.CacheHits, m.CacheMisses = 1300, 700
+	h.Metrics.Set(m)
+	_, findings = check(t, h)
+	f := findings["cache_hit_rate"]
+	assert.Equal(t, SeverityCritical, f.Severity)
+	assert.InDelta(t, 50, f.Value, 0.01)
+	assert.Equal(t, "heimdall.watcher.metrics", f.Remediation[0].Action)
+}
+
+func TestHealthCheck_GoroutineLeak(t *testing.T) {
+	h := plugintest.Start(t, &HealthPlugin{})
+	require.NoError(t, h.Plugin.Configure(map[string]interface{}{"goroutine_leak_checks": 3}))
+	m := healthyMetrics()
+
+	var findings map[string]Finding
+	for _, count := range []int{100, 120, 140, 160} {
+		m.GoroutineCount = count
+		h.Metrics.Set(m)
+		_, findings = check(t, h)
+	}
+	f := findings["goroutines"]
+	assert.Equal(t, SeverityWarning, f.Severity)
+	assert.Contains(t, f.Message, "possible leak")
+	assert.Equal(t, "heimdall.health.goroutines", f.Remediation[0].Action)
+
+	// Growth that stops is not a leak
+	m.GoroutineCount = 150
+	h.Metrics.Set(m)
+	_, findings = check(t, h)
+	assert.Equal(t, SeverityOK, findings["goroutines"].Severity)
+}
+
+func TestHealthCheck_GPUErrorsSinceLastCheck(t *testing.T) {
+	h := plugintest.Start(t, &HealthPlugin{})
+	m := healthyMetrics()
+
+	m.GPUErrors = 3
+	h.Metrics.Set(m)
+	_, findings := check(t, h)
+	assert.Equal(t, SeverityWarning, findings["gpu_errors"].Severity)
+	assert.Equal(t, 3.0, findings["gpu_errors"].Value)
+
+	_, findings = check(t, h)
+	assert.Equal(t, SeverityOK, findings["gpu_errors"].Severity)
+}
+
+func TestHealthCheck_EventQueues(t *testing.T) {
+	p := &HealthPlugin{}
+	require.NoError(t, p.Initialize(heimdall.SubsystemContext{}))
+
+	f := p.checkEventQueues(nil)
+	assert.Equal(t, SeverityOK, f.Severity)
+
+	f = p.checkEventQueues([]heimdall.EventDeliveryStats{
+		{Plugin: "fast", Queued: 10, Capacity: 1000},
+		{Plugin: "slow", Queued: 960, Capacity: 1000},
+	})
+	assert.Equal(t, SeverityCritical, f.Severity)
+	assert.Contains(t, f.Message, "slow")
+	assert.Equal(t, "heimdall.health.event_queues", f.Remediation[0].Action)
+
+	// Drops since the last check raise a warning even with room to spare
+	f = p.checkEventQueues([]heimdall.EventDeliveryStats{{Plugin: "fast", Queued: 1, Capacity: 1000, Dropped: 7}})
+	assert.Equal(t, SeverityWarning, f.Severity)
+	assert.Contains(t, f.Message, "7 events dropped")
+
+	f = p.checkEventQueues([]heimdall.EventDeliveryStats{{Plugin: "fast", Queued: 1, Capacity: 1000, Dropped: 7}})
+	assert.Equal(t, SeverityOK, f.Severity)
+}
+
+func TestHealthPlugin_BackgroundNotifications(t *testing.T) {
+	p := &HealthPlugin{}
+	h := plugintest.New(t, p)
+	h.Initialize()
+	require.NoError(t, p.Configure(map[string]interface{}{"interval_seconds": 0.01}))
+	m := healthyMetrics()
+	m.WALSyncLagMs = 30000
+	h.Metrics.Set(m)
+	h.Start()
+	t.Cleanup(func() { _ = p.Shutdown() })
+
+	require.Eventually(t, func() bool { return p.Metrics()["checks_run"].(int64) >= 3 }, time.Second, 5*time.Millisecond)
+	h.Stop()
+
+	// Notified once when the check turned critical, not on every run
+	n := h.AssertNotification("error", "Health: wal_lag")
+	assert.Contains(t, n.Message, "heimdall.watcher.queries")
+	assert.Len(t, h.Bifrost.Notifications(), 1)
+	h.AssertEvent("critical", "wal_lag")
+	assert.False(t, p.Health().Healthy)
+}
+
+func TestHealthPlugin_Configure(t *testing.T) {
+	h := plugintest.Start(t, &HealthPlugin{})
+
+	require.NoError(t, h.Plugin.Configure(map[string]interface{}{"wal_lag_warning_ms": 50, "interval_seconds": 0}))
+	assert.Equal(t, 50.0, h.Plugin.Config()["wal_lag_warning_ms"])
+
+	assert.Error(t, h.Plugin.Configure(map[string]interface{}{"bogus": 1}))
+	assert.Error(t, h.Plugin.Configure(map[string]interface{}{"wal_lag_warning_ms": -1}))
+	assert.Error(t, h.Plugin.Configure(map[string]interface{}{"wal_lag_warning_ms": "high"}))
+
+	schema := h.Plugin.ConfigSchema()["properties"].(map[string]interface{})
+	assert.Len(t, schema, len(configSettings))
+}
+
+const sampleProfile = `goroutine profile: total 7
+4 @ 0x43e5b6 0x44f1c5 0x7a1b2c
+#	0x44f1c4	sync.runtime_SemacquireMutex+0x24	/go/src/runtime/sema.go:77
+#	0x7a1b2b	github.com/orneryd/nornicdb/pkg/storage.(*WAL).syncLoop+0x8b	/src/pkg/storage/wal.go:410
+
+2 @ 0x43e5b6 0x7a1c3d
+#	0x7a1c3c	github.com/orneryd/nornicdb/pkg/bolt.(*Session).handleMessage+0x5c	/src/pkg/bolt/server.go:900
+
+1 @ 0x43e5b6 0x44f1c5 0x7a1b2c 0x7a1d00
+#	0x44f1c4	sync.runtime_SemacquireMutex+0x24	/go/src/runtime/sema.go:77
+#	0x7a1b2b	github.com/orneryd/nornicdb/pkg/storage.(*WAL).syncLoop+0x8b	/src/pkg/storage/wal.go:410
+#	0x7a1cff	github.com/orneryd/nornicdb/pkg/storage.NewWAL+0x1f	/src/pkg/storage/wal.go:260
+`
+
+func TestParseGoroutineProfile(t *testing.T) {
+	total, groups := parseGoroutineProfile(sampleProfile)
+	assert.Equal(t, 7, total)
+	require.Len(t, groups, 2)
+	assert.Equal(t, goroutineGroup{
+		Count:    5,
+		Function: "github.com/orneryd/nornicdb/pkg/storage.(*WAL).syncLoop",
+		Stack: []string{
+			"sync.runtime_SemacquireMutex",
+			"github.com/orneryd/nornicdb/pkg/storage.(*WAL).syncLoop",
+		},
+	}, groups[0])
+	assert.Equal(t, 2, groups[1].Count)
+	assert.Equal(t, "github.com/orneryd/nornicdb/pkg/bolt.(*Session).handleMessage", groups[1].Function)
+}
+
+func TestHealthActions_Diagnostics(t *testing.T) {
+	h := plugintest.Start(t, &HealthPlugin{})
+
+	result, err := h.Action("goroutines", map[string]interface{}{"limit": 1})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Positive(t, result.Data["total"])
+	assert.Len(t, result.Data["groups"], 1)
+	assert.True(t, strings.HasSuffix(result.Message, ")"), result.Message)
+
+	result, err = h.Action("event_queues", nil)
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Contains(t, result.Message, "plugins receive events")
+}
@@ -0,0 +1,416 @@
+// Package heimdall - guarded auto-indexing.
+//
+// With auto_index enabled, the watcher checks the workload's index
+// recommendations (nornicdb.workload.recommend) every autoIndexInterval and
+// creates at most one index per check, and only inside the maintenance
+// window:
+//
+//	heimdall.watcher.set_config {"auto_index": true,
+//	  "auto_index_min_queries": 500, "auto_index_window": "02:00-05:00"}
+//
+// A recommendation counts once the unindexed lookup was seen in at least
+// auto_index_min_queries queries within the workload window. With
+// auto_index_confirm "always" (the default) approval is requested over
+// Bifrost; a declined or unanswered request is not asked again until the
+// next window. With "never" the index is created and clients are notified.
+//
+// The index is created as auto_<label>_<property>. If the workload still
+// recommends it afterwards the index did not take, and it is dropped again.
+// Indexes created this way are listed by heimdall.watcher.auto_indexes and
+// dropped with heimdall.watcher.rollback_index.
+package heimdall
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+)
+
+const (
+	// autoIndexInterval is how often index recommendations are checked.
+	autoIndexInterval = time.Minute
+
+	defaultAutoIndexMinQueries = 100
+
+	autoIndexConfirmAlways = "always"
+	autoIndexConfirmNever  = "never"
+
+	recommendQuery = "CALL nornicdb.workload.recommend()"
+)
+
+// AutoIndex is an index created by the auto-indexer.
+type AutoIndex struct {
+	Name      string    `json:"name"`
+	Label     string    `json:"label"`
+	Property  string    `json:"property"`
+	Queries   int64     `json:"queries"` // Unindexed lookups that led to it
+	Reason    string    `json:"reason"`
+	Confirmed bool      `json:"confirmed"` // Approved over Bifrost
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// indexSetting matches the Label(property) setting of index recommendations.
+var indexSetting = regexp.MustCompile(`^(\w+)\((\w+)\)$`)
+
+// autoIndexSettings is the auto-indexing part of the watcher's config.
+type autoIndexSettings struct {
+	enabled    bool
+	minQueries int64
+	window     string
+	confirm    string
+}
+
+// autoIndexSettings reads the auto-indexing settings. Caller holds p.mu.
+func (p *WatcherPlugin) autoIndexSettings() autoIndexSettings {
+	s := autoIndexSettings{minQueries: defaultAutoIndexMinQueries, confirm: autoIndexConfirmAlways}
+	s.enabled, _ = p.config["auto_index"].(bool)
+	if n, ok := p.config["auto_index_min_queries"].(int64); ok {
+		s.minQueries = n
+	}
+	s.window, _ = p.config["auto_index_window"].(string)
+	if c, ok := p.config["auto_index_confirm"].(string); ok && c != "" {
+		s.confirm = c
+	}
+	return s
+}
+
+// configureAutoIndex validates and stores one auto-indexing setting.
+// Caller holds p.mu.
+func (p *WatcherPlugin) configureAutoIndex(key string, value interface{}) error {
+	switch key {
+	case "auto_index":
+		v, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("invalid auto_index: must be true or false")
+		}
+		p.config[key] = v
+	case "auto_index_min_queries":
+		var n int64
+		switch v := value.(type) {
+		case int:
+			n = int64(v)
+		case int64:
+			n = v
+		case float64:
+			if v == float64(int64(v)) {
+				n = int64(v)
+			}
+		}
+		if n < 1 {
+			return fmt.Errorf("invalid auto_index_min_queries: must be a positive integer")
+		}
+		p.config[key] = n
+	case "auto_index_window":
+		v, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("invalid auto_index_window: must be a string such as \"02:00-05:00\"")
+		}
+		if v != "" {
+			if _, _, err := parseMaintenanceWindow(v); err != nil {
+				return fmt.Errorf("invalid auto_index_window: %w", err)
+			}
+		}
+		p.config[key] = v
+	case "auto_index_confirm":
+		v, _ := value.(string)
+		if v != autoIndexConfirmAlways && v != autoIndexConfirmNever {
+			return fmt.Errorf("invalid auto_index_confirm: must be %q or %q", autoIndexConfirmAlways, autoIndexConfirmNever)
+		}
+		p.config[key] = v
+	}
+	// Declined indexes may be asked for again under new settings
+	p.autoDeclined = nil
+	return nil
+}
+
+// parseMaintenanceWindow parses "HH:MM-HH:MM" into minutes after midnight.
+// The end may be before the start for windows spanning midnight.
+func parseMaintenanceWindow(window string) (start, end int, err error) {
+	from, to, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q is not HH:MM-HH:MM", window)
+	}
+	if start, err = parseClock(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClock(to); err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("%q is empty", window)
+	}
+	return start, end, nil
+}
+
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hours, err1 := strconv.Atoi(h)
+	minutes, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hours < 0 || hours > 23 || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+// inMaintenanceWindow reports whether now (local time) falls in window.
+// An empty window is always open.
+func inMaintenanceWindow(window string, now time.Time) bool {
+	if window == "" {
+		return true
+	}
+	start, end, err := parseMaintenanceWindow(window)
+	if err != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// autoIndexName is the name given to an index on label.property.
+func autoIndexName(label, property string) string {
+	return strings.ToLower("auto_" + label + "_" + property)
+}
+
+// runAutoIndex checks index recommendations every autoIndexInterval until
+// stop is closed.
+func (p *WatcherPlugin) runAutoIndex(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(autoIndexInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			p.checkAutoIndex(context.Background(), now)
+		}
+	}
+}
+
+// stopAutoIndexLoop stops the auto-indexer and waits for it to exit.
+func (p *WatcherPlugin) stopAutoIndexLoop() {
+	p.mu.Lock()
+	stop, done := p.stopAutoIndex, p.autoIndexDone
+	p.stopAutoIndex, p.autoIndexDone = nil, nil
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// checkAutoIndex creates the first recommended index that passes the
+// threshold and has not been declined, if auto-indexing is enabled and now
+// is in the maintenance window.
+func (p *WatcherPlugin) checkAutoIndex(ctx context.Context, now time.Time) {
+	p.mu.Lock()
+	settings := p.autoIndexSettings()
+	db, bifrost := p.ctx.Database, p.ctx.Bifrost
+	if !settings.enabled || db == nil {
+		p.mu.Unlock()
+		return
+	}
+	if !inMaintenanceWindow(settings.window, now) {
+		p.autoDeclined = nil
+		p.mu.Unlock()
+		return
+	}
+	declined := make(map[string]bool, len(p.autoDeclined))
+	for setting := range p.autoDeclined {
+		declined[setting] = true
+	}
+	p.mu.Unlock()
+
+	recommendations, err := db.Query(ctx, recommendQuery, nil)
+	if err != nil {
+		log.Printf("[Watcher] Auto-index: workload analysis failed: %v", err)
+		return
+	}
+	for _, rec := range recommendations {
+		if rec["area"] != "index" {
+			continue
+		}
+		setting, _ := rec["setting"].(string)
+		m := indexSetting.FindStringSubmatch(setting)
+		queries, _ := rec["queries"].(int64)
+		if m == nil || queries < settings.minQueries || declined[setting] {
+			continue
+		}
+		reason, _ := rec["reason"].(string)
+		p.createAutoIndex(ctx, db, bifrost, settings, AutoIndex{
+			Name:     autoIndexName(m[1], m[2]),
+			Label:    m[1],
+			Property: m[2],
+			Queries:  queries,
+			Reason:   reason,
+		})
+		return
+	}
+}
+
+// createAutoIndex asks for approval as configured, creates the index and
+// drops it again if it did not take.
+func (p *WatcherPlugin) createAutoIndex(ctx context.Context, db heimdall.DatabaseReader, bifrost heimdall.BifrostBridge, settings autoIndexSettings, idx AutoIndex) {
+	setting := idx.Label + "(" + idx.Property + ")"
+	if settings.confirm == autoIndexConfirmAlways {
+		approved := false
+		if bifrost != nil {
+			summary := fmt.Sprintf("Create index %s on :%s(%s)? %s", idx.Name, idx.Label, idx.Property, idx.Reason)
+			var err error
+			if approved, err = bifrost.RequestConfirmation(summary); err != nil {
+				log.Printf("[Watcher] Auto-index: confirmation for %s failed: %v", idx.Name, err)
+			}
+		}
+		if !approved {
+			p.declineAutoIndex(setting, fmt.Sprintf("Index %s was not approved", idx.Name), idx)
+			return
+		}
+		idx.Confirmed = true
+	}
+
+	create := fmt.Sprintf("CREATE INDEX %s IF NOT EXISTS FOR (n:%s) ON (n.%s)", idx.Name, idx.Label, idx.Property)
+	if _, err := db.Query(ctx, create, nil); err != nil {
+		p.declineAutoIndex(setting, fmt.Sprintf("Index %s could not be created: %v", idx.Name, err), idx)
+		return
+	}
+
+	// The lookups should no longer be reported as unindexed
+	if recommendations, err := db.Query(ctx, recommendQuery, nil); err == nil {
+		for _, rec := range recommendations {
+			if rec["area"] == "index" && rec["setting"] == setting {
+				_, dropErr := db.Query(ctx, "DROP INDEX "+idx.Name, nil)
+				message := fmt.Sprintf("Index %s did not take effect and was rolled back", idx.Name)
+				if dropErr != nil {
+					message = fmt.Sprintf("Index %s did not take effect and could not be rolled back: %v", idx.Name, dropErr)
+				}
+				p.declineAutoIndex(setting, message, idx)
+				if bifrost != nil {
+					bifrost.SendNotification("warning", "Auto-index rolled back", message)
+				}
+				return
+			}
+		}
+	}
+
+	idx.CreatedAt = time.Now()
+	p.mu.Lock()
+	p.autoIndexes = append(p.autoIndexes, idx)
+	p.addEvent("action", fmt.Sprintf("Created index %s", idx.Name), map[string]interface{}{"index": idx})
+	p.mu.Unlock()
+	log.Printf("[Watcher] Auto-index: created %s on :%s(%s) after %d unindexed queries", idx.Name, idx.Label, idx.Property, idx.Queries)
+	if bifrost != nil {
+		bifrost.SendNotification("info", "Index created",
+			fmt.Sprintf("Created index %s on :%s(%s); roll back with heimdall.watcher.rollback_index", idx.Name, idx.Label, idx.Property))
+	}
+}
+
+// declineAutoIndex records that an index is not to be tried again in this
+// window.
+func (p *WatcherPlugin) declineAutoIndex(setting, message string, idx AutoIndex) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.autoDeclined == nil {
+		p.autoDeclined = make(map[string]bool)
+	}
+	p.autoDeclined[setting] = true
+	p.addEvent("warning", message, map[string]interface{}{"index": idx})
+}
+
+// actionAutoIndexes lists the auto-indexing settings and the indexes
+// created so far.
+func (p *WatcherPlugin) actionAutoIndexes(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	p.RecordRequest()
+
+	p.mu.RLock()
+	settings := p.autoIndexSettings()
+	indexes := append([]AutoIndex{}, p.autoIndexes...)
+	p.mu.RUnlock()
+
+	state := "disabled"
+	if settings.enabled {
+		state = "enabled"
+	}
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("Auto-indexing is %s; %d indexes created", state, len(indexes)),
+		Data: map[string]interface{}{
+			"indexes":     indexes,
+			"enabled":     settings.enabled,
+			"min_queries": settings.minQueries,
+			"window":      settings.window,
+			"confirm":     settings.confirm,
+		},
+	}, nil
+}
+
+// actionRollbackIndex drops an index created by the auto-indexer
+// (params: name).
+func (p *WatcherPlugin) actionRollbackIndex(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	p.RecordRequest()
+
+	name, _ := ctx.Params["name"].(string)
+	if name == "" {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: "Missing required parameter: name",
+		}, nil
+	}
+	if ctx.Database == nil {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: "Database not available",
+		}, nil
+	}
+
+	p.mu.RLock()
+	found := false
+	for _, idx := range p.autoIndexes {
+		found = found || idx.Name == name
+	}
+	p.mu.RUnlock()
+	if !found {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("%s was not created by the auto-indexer", name),
+		}, nil
+	}
+
+	if _, err := ctx.Database.Query(ctx.Context, "DROP INDEX "+name, nil); err != nil {
+		p.RecordError()
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to drop index %s: %v", name, err),
+		}, nil
+	}
+
+	p.mu.Lock()
+	for i, idx := range p.autoIndexes {
+		if idx.Name == name {
+			p.autoIndexes = append(p.autoIndexes[:i], p.autoIndexes[i+1:]...)
+			if p.autoDeclined == nil {
+				p.autoDeclined = make(map[string]bool)
+			}
+			// Not recreated in this window
+			p.autoDeclined[idx.Label+"("+idx.Property+")"] = true
+			break
+		}
+	}
+	p.addEvent("info", fmt.Sprintf("Index %s rolled back", name), map[string]interface{}{"index": name})
+	p.mu.Unlock()
+
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("Index %s rolled back", name),
+	}, nil
+}
@@ -17,6 +17,11 @@
 //   - heimdall.heimdall.config - Get/set SLM configuration
 //   - heimdall.heimdall.metrics - Get SLM metrics (Heimdall's awareness)
 //   - heimdall.heimdall.events - Get recent events (Heimdall's memory)
+//   - heimdall.heimdall.auto_indexes - List indexes created by guarded auto-indexing
+//   - heimdall.heimdall.rollback_index - Drop an auto-created index
+//   - heimdall.heimdall.cluster - Cluster memories by embedding into :Cluster nodes
+//   - heimdall.heimdall.label_clusters - Label :Cluster nodes with SLM-written topics
+//   - heimdall.heimdall.qc_report - Precision and recall of QC shadow decisions
 //
 // # Example Usage
 //
@@ -36,6 +41,7 @@
 package heimdall
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"runtime"
@@ -57,21 +63,28 @@ var Plugin heimdall.HeimdallPlugin = &WatcherPlugin{}
 // - Accumulates events and triggers analysis when thresholds are exceeded
 // - Uses HeimdallInvoker to autonomously invoke SLM actions
 type WatcherPlugin struct {
-	mu       sync.RWMutex
-	ctx      heimdall.SubsystemContext
-	status   heimdall.SubsystemStatus
-	events   []heimdall.SubsystemEvent
-	config   map[string]interface{}
-	started  time.Time
-	requests int64
-	errors   int64
-
-	// === Event Accumulation for Autonomous Actions ===
-	// These track database events for autonomous action triggering
-	queryFailures     int64     // Count of failed queries
-	lastFailureReset  time.Time // When failure count was last reset
-	nodeCreations     int64     // Track node creation rate
-	lastCreationReset time.Time
+	heimdall.PluginMetrics // Action requests and errors
+
+	mu      sync.RWMutex
+	ctx     heimdall.SubsystemContext
+	status  heimdall.SubsystemStatus
+	events  []heimdall.SubsystemEvent
+	config  map[string]interface{}
+	started time.Time
+
+	// === Autonomous Actions ===
+	// Threshold rules over database events and runtime metrics, created in
+	// Initialize. Metric rules are evaluated in the background while running.
+	rules    *heimdall.RuleEngine
+	stopLoop chan struct{}
+	loopDone chan struct{}
+
+	// === Guarded Auto-Indexing ===
+	// Opt-in via the auto_index config; see autoindex.go.
+	autoIndexes   []AutoIndex     // Created so far, oldest first
+	autoDeclined  map[string]bool // Label(property) not to try again this window
+	stopAutoIndex chan struct{}
+	autoIndexDone chan struct{}
 }
 
 // === Identity Methods ===
@@ -100,11 +113,27 @@ func (p *WatcherPlugin) Initialize(ctx heimdall.SubsystemContext) error {
 
 	p.ctx = ctx
 	p.status = heimdall.StatusReady
+	p.rules = heimdall.NewRuleEngine(heimdall.RuleEngineConfig{
+		Invoker:  ctx.Heimdall,
+		Bifrost:  ctx.Bifrost,
+		Path:     ctx.Config.RulesFile,
+		Defaults: DefaultRules(),
+		OnFire:   p.onRuleFired,
+	})
+	if err := p.rules.Load(); err != nil {
+		log.Printf("[Watcher] Using default rules: %v", err)
+		p.addEvent("warning", "Saved rules could not be loaded, using defaults", map[string]interface{}{"error": err.Error()})
+	}
 	p.events = make([]heimdall.SubsystemEvent, 0, 100)
 	p.config = map[string]interface{}{
 		"max_tokens":  ctx.Config.MaxTokens,
 		"temperature": ctx.Config.Temperature,
 		"model":       ctx.Config.Model,
+
+		"auto_index":             false,
+		"auto_index_min_queries": int64(defaultAutoIndexMinQueries),
+		"auto_index_window":      "",
+		"auto_index_confirm":     autoIndexConfirmAlways,
 	}
 
 	p.addEvent("info", "Heimdall awakens - SLM guardian initialized", nil)
@@ -117,11 +146,24 @@ func (p *WatcherPlugin) Start() error {
 
 	p.status = heimdall.StatusRunning
 	p.started = time.Now()
+	if p.stopLoop == nil && p.ctx.Metrics != nil && p.rules != nil {
+		p.stopLoop = make(chan struct{})
+		p.loopDone = make(chan struct{})
+		go evaluateRules(p.rules, p.ctx.Metrics, p.stopLoop, p.loopDone)
+	}
+	if p.stopAutoIndex == nil && p.ctx.Database != nil {
+		p.stopAutoIndex = make(chan struct{})
+		p.autoIndexDone = make(chan struct{})
+		go p.runAutoIndex(p.stopAutoIndex, p.autoIndexDone)
+	}
 	p.addEvent("info", "Heimdall stands watch - SLM guardian active", nil)
 	return nil
 }
 
 func (p *WatcherPlugin) Stop() error {
+	p.stopRuleLoop()
+	p.stopAutoIndexLoop()
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -131,6 +173,9 @@ func (p *WatcherPlugin) Stop() error {
 }
 
 func (p *WatcherPlugin) Shutdown() error {
+	p.stopRuleLoop()
+	p.stopAutoIndexLoop()
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -153,16 +198,27 @@ func (p *WatcherPlugin) Health() heimdall.SubsystemHealth {
 
 	healthy := p.status == heimdall.StatusRunning || p.status == heimdall.StatusReady
 
+	details := map[string]interface{}{
+		"uptime_seconds": time.Since(p.started).Seconds(),
+		"requests":       p.Requests.Total(),
+		"errors":         p.Errors.Total(),
+	}
+	if p.ctx.Database != nil {
+		queries := p.ctx.Database.Stats().RunningQueries
+		var longest int64
+		for _, q := range queries {
+			longest = max(longest, q.ElapsedMs)
+		}
+		details["running_queries"] = len(queries)
+		details["longest_query_ms"] = longest
+	}
+
 	return heimdall.SubsystemHealth{
 		Status:    p.status,
 		Healthy:   healthy,
 		Message:   fmt.Sprintf("Heimdall reports: SLM is %s", p.status),
 		LastCheck: time.Now(),
-		Details: map[string]interface{}{
-			"uptime_seconds": time.Since(p.started).Seconds(),
-			"requests":       p.requests,
-			"errors":         p.errors,
-		},
+		Details:   details,
 	}
 }
 
@@ -173,15 +229,21 @@ func (p *WatcherPlugin) Metrics() map[string]interface{} {
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
 
-	return map[string]interface{}{
-		"status":         string(p.status),
-		"uptime_seconds": time.Since(p.started).Seconds(),
-		"requests":       p.requests,
-		"errors":         p.errors,
-		"error_rate":     float64(p.errors) / float64(max(p.requests, 1)),
-		"memory_mb":      memStats.Alloc / 1024 / 1024,
-		"goroutines":     runtime.NumGoroutine(),
+	metrics := p.MetricsSnapshot()
+	metrics["status"] = string(p.status)
+	metrics["uptime_seconds"] = time.Since(p.started).Seconds()
+	metrics["memory_mb"] = memStats.Alloc / 1024 / 1024
+	metrics["goroutines"] = runtime.NumGoroutine()
+	if p.rules != nil {
+		var fired int64
+		rules := p.rules.Rules()
+		for _, r := range rules {
+			fired += r.Fired
+		}
+		metrics["rules"] = len(rules)
+		metrics["rules_fired"] = fired
 	}
+	return metrics
 }
 
 // === Configuration Methods ===
@@ -217,6 +279,10 @@ func (p *WatcherPlugin) Configure(settings map[string]interface{}) error {
 			} else {
 				return fmt.Errorf("invalid temperature: must be 0-2")
 			}
+		case "auto_index", "auto_index_min_queries", "auto_index_window", "auto_index_confirm":
+			if err := p.configureAutoIndex(key, value); err != nil {
+				return err
+			}
 		default:
 			return fmt.Errorf("unknown config key: %s", key)
 		}
@@ -244,6 +310,28 @@ func (p *WatcherPlugin) ConfigSchema() map[string]interface{} {
 				"maximum":     2,
 				"default":     0.1,
 			},
+			"auto_index": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Create recommended indexes for frequent unindexed lookups",
+				"default":     false,
+			},
+			"auto_index_min_queries": map[string]interface{}{
+				"type":        "integer",
+				"description": "Unindexed lookups within the workload window before an index is created",
+				"minimum":     1,
+				"default":     defaultAutoIndexMinQueries,
+			},
+			"auto_index_window": map[string]interface{}{
+				"type":        "string",
+				"description": "Local maintenance window for creating indexes, e.g. 02:00-05:00 (empty = any time)",
+				"default":     "",
+			},
+			"auto_index_confirm": map[string]interface{}{
+				"type":        "string",
+				"description": "Ask for approval over Bifrost before creating an index",
+				"enum":        []string{autoIndexConfirmAlways, autoIndexConfirmNever},
+				"default":     autoIndexConfirmAlways,
+			},
 		},
 	}
 }
@@ -286,17 +374,116 @@ func (p *WatcherPlugin) Actions() map[string]heimdall.ActionFunc {
 			Description: "Get recent system events (params: limit)",
 			Category:    "monitoring",
 			Handler:     p.actionEvents,
+			Schema: &heimdall.ResultSchema{
+				Visualization: heimdall.VisualizationTable,
+				Rows:          "events",
+				Columns: []heimdall.ResultColumn{
+					{Key: "time", Type: heimdall.ColumnTimestamp},
+					{Key: "type", Type: heimdall.ColumnString},
+					{Key: "message", Type: heimdall.ColumnString},
+					{Key: "data", Type: heimdall.ColumnObject, Optional: true},
+				},
+			},
 		},
 		"query": {
 			Description: "Execute a read-only Cypher query (params: cypher, params)",
 			Category:    "database",
 			Handler:     p.actionQuery,
 		},
+		"run_query": {
+			Description: "Run a read-only stored query by name (params: name, params); see db.query.list()",
+			Category:    "database",
+			Handler:     p.actionRunQuery,
+		},
+		"queries": {
+			Description: "List running Cypher queries with elapsed time, rows and memory",
+			Category:    "database",
+			Handler:     p.actionQueries,
+			Schema: &heimdall.ResultSchema{
+				Visualization: heimdall.VisualizationTable,
+				Rows:          "queries",
+				Columns: []heimdall.ResultColumn{
+					{Key: "id", Label: "ID", Type: heimdall.ColumnString},
+					{Key: "query", Type: heimdall.ColumnString},
+					{Key: "user", Type: heimdall.ColumnString, Optional: true},
+					{Key: "elapsed_ms", Label: "Elapsed", Type: heimdall.ColumnDuration},
+					{Key: "rows", Type: heimdall.ColumnInteger},
+					{Key: "memory_bytes", Label: "Memory", Type: heimdall.ColumnBytes},
+				},
+			},
+		},
 		"db_stats": {
 			Description: "Get database statistics: node/edge counts, labels, indexes",
 			Category:    "database",
 			Handler:     p.actionDBStats,
 		},
+		"optimize": {
+			Description: "Suggest query cache size, pool MaxSize and index changes from the observed query workload",
+			Category:    "database",
+			Handler:     p.actionOptimize,
+			Schema: &heimdall.ResultSchema{
+				Visualization: heimdall.VisualizationTable,
+				Rows:          "recommendations",
+				Columns: []heimdall.ResultColumn{
+					{Key: "area", Type: heimdall.ColumnString},
+					{Key: "setting", Type: heimdall.ColumnString},
+					{Key: "current", Type: heimdall.ColumnString},
+					{Key: "suggested", Type: heimdall.ColumnString},
+					{Key: "reason", Type: heimdall.ColumnString},
+				},
+			},
+		},
+		"qc_report": {
+			Description: "Report how Heimdall QC decisions made in shadow mode compare with later feedback: precision and recall per method and prompt version",
+			Category:    "monitoring",
+			Handler:     p.actionQCReport,
+			Schema: &heimdall.ResultSchema{
+				Visualization: heimdall.VisualizationTable,
+				Rows:          "evaluations",
+				Columns: []heimdall.ResultColumn{
+					{Key: "dimension", Type: heimdall.ColumnString},
+					{Key: "key", Type: heimdall.ColumnString},
+					{Key: "pending", Type: heimdall.ColumnInteger},
+					{Key: "outcomes", Type: heimdall.ColumnInteger},
+					{Key: "precision", Type: heimdall.ColumnNumber},
+					{Key: "recall", Type: heimdall.ColumnNumber},
+				},
+			},
+		},
+		"auto_indexes": {
+			Description: "List indexes created by guarded auto-indexing and its settings (see set_config auto_index)",
+			Category:    "database",
+			Handler:     p.actionAutoIndexes,
+			Schema: &heimdall.ResultSchema{
+				Visualization: heimdall.VisualizationTable,
+				Rows:          "indexes",
+				Columns: []heimdall.ResultColumn{
+					{Key: "name", Type: heimdall.ColumnString},
+					{Key: "label", Type: heimdall.ColumnString},
+					{Key: "property", Type: heimdall.ColumnString},
+					{Key: "queries", Type: heimdall.ColumnInteger},
+					{Key: "confirmed", Type: heimdall.ColumnBoolean},
+					{Key: "created_at", Label: "Created", Type: heimdall.ColumnTimestamp},
+				},
+			},
+		},
+		"cluster": {
+			Description: "Cluster node embeddings into :Cluster nodes and set each node's cluster_id (params: algorithm kmeans|hdbscan, k, min_cluster_size, label, topics to label the clusters)",
+			Category:    "database",
+			Handler:     p.actionCluster,
+			Risk:        heimdall.RiskMedium,
+		},
+		"label_clusters": {
+			Description: "Have the SLM write a topic label and summary onto :Cluster nodes without one (params: all to relabel every cluster, prompt_tokens)",
+			Category:    "database",
+			Handler:     p.actionLabelClusters,
+		},
+		"rollback_index": {
+			Description: "Drop an index created by guarded auto-indexing (params: name)",
+			Category:    "database",
+			Handler:     p.actionRollbackIndex,
+			Risk:        heimdall.RiskMedium,
+		},
 		"broadcast": {
 			Description: "Broadcast a message to all connected Bifrost clients (params: message)",
 			Category:    "system",
@@ -307,6 +494,35 @@ func (p *WatcherPlugin) Actions() map[string]heimdall.ActionFunc {
 			Category:    "system",
 			Handler:     p.actionNotify,
 		},
+		"rules": {
+			Description: "List autonomous action rules with their current counts and firings",
+			Category:    "configuration",
+			Handler:     p.actionRules,
+			Schema: &heimdall.ResultSchema{
+				Visualization: heimdall.VisualizationTable,
+				Rows:          "rules",
+				Columns: []heimdall.ResultColumn{
+					{Key: "name", Type: heimdall.ColumnString},
+					{Key: "metric", Type: heimdall.ColumnString, Optional: true},
+					{Key: "event", Type: heimdall.ColumnObject, Optional: true},
+					{Key: "threshold", Type: heimdall.ColumnNumber},
+					{Key: "count", Type: heimdall.ColumnInteger},
+					{Key: "fired", Type: heimdall.ColumnInteger},
+					{Key: "last_fired", Label: "Last fired", Type: heimdall.ColumnTimestamp, Optional: true},
+					{Key: "disabled", Type: heimdall.ColumnBoolean, Optional: true},
+				},
+			},
+		},
+		"set_rule": {
+			Description: "Add or replace an autonomous action rule (params: name, event or metric, threshold, window_seconds, action, prompt, notify)",
+			Category:    "configuration",
+			Handler:     p.actionSetRule,
+		},
+		"delete_rule": {
+			Description: "Delete an autonomous action rule (params: name)",
+			Category:    "configuration",
+			Handler:     p.actionDeleteRule,
+		},
 	}
 }
 
@@ -319,9 +535,7 @@ func (p *WatcherPlugin) Actions() map[string]heimdall.ActionFunc {
 //   - "hello world"
 //   - "run a test action"
 func (p *WatcherPlugin) actionHello(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	name := "World"
 	if n, ok := ctx.Params["name"].(string); ok && n != "" {
@@ -344,9 +558,7 @@ func (p *WatcherPlugin) actionHello(ctx heimdall.ActionContext) (*heimdall.Actio
 }
 
 func (p *WatcherPlugin) actionStatus(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	health := p.Health()
 	pluginMetrics := p.Metrics()
@@ -398,9 +610,7 @@ func (p *WatcherPlugin) actionStatus(ctx heimdall.ActionContext) (*heimdall.Acti
 }
 
 func (p *WatcherPlugin) actionHealth(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	health := p.Health()
 
@@ -414,9 +624,7 @@ func (p *WatcherPlugin) actionHealth(ctx heimdall.ActionContext) (*heimdall.Acti
 }
 
 func (p *WatcherPlugin) actionConfig(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	return &heimdall.ActionResult{
 		Success: true,
@@ -429,14 +637,10 @@ func (p *WatcherPlugin) actionConfig(ctx heimdall.ActionContext) (*heimdall.Acti
 }
 
 func (p *WatcherPlugin) actionSetConfig(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	if err := p.Configure(ctx.Params); err != nil {
-		p.mu.Lock()
-		p.errors++
-		p.mu.Unlock()
+		p.RecordError()
 		return &heimdall.ActionResult{
 			Success: false,
 			Message: fmt.Sprintf("Configuration error: %v", err),
@@ -453,9 +657,7 @@ func (p *WatcherPlugin) actionSetConfig(ctx heimdall.ActionContext) (*heimdall.A
 }
 
 func (p *WatcherPlugin) actionMetrics(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	// Collect comprehensive metrics
 	metrics := map[string]interface{}{
@@ -501,9 +703,7 @@ func (p *WatcherPlugin) actionMetrics(ctx heimdall.ActionContext) (*heimdall.Act
 }
 
 func (p *WatcherPlugin) actionEvents(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	limit := 10
 	if l, ok := ctx.Params["limit"].(int); ok && l > 0 {
@@ -523,9 +723,7 @@ func (p *WatcherPlugin) actionEvents(ctx heimdall.ActionContext) (*heimdall.Acti
 
 // actionBroadcast demonstrates using Bifrost to broadcast messages to all connected clients.
 func (p *WatcherPlugin) actionBroadcast(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	msg, ok := ctx.Params["message"].(string)
 	if !ok || msg == "" {
@@ -538,9 +736,7 @@ func (p *WatcherPlugin) actionBroadcast(ctx heimdall.ActionContext) (*heimdall.A
 	// Use Bifrost to broadcast the message
 	if ctx.Bifrost != nil {
 		if err := ctx.Bifrost.Broadcast(fmt.Sprintf("📢 Heimdall announces: %s", msg)); err != nil {
-			p.mu.Lock()
-			p.errors++
-			p.mu.Unlock()
+			p.RecordError()
 			return &heimdall.ActionResult{
 				Success: false,
 				Message: fmt.Sprintf("Failed to broadcast via Bifrost: %v", err),
@@ -562,9 +758,7 @@ func (p *WatcherPlugin) actionBroadcast(ctx heimdall.ActionContext) (*heimdall.A
 
 // actionNotify demonstrates using Bifrost to send typed notifications.
 func (p *WatcherPlugin) actionNotify(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	notifType, _ := ctx.Params["type"].(string)
 	title, _ := ctx.Params["title"].(string)
@@ -586,9 +780,7 @@ func (p *WatcherPlugin) actionNotify(ctx heimdall.ActionContext) (*heimdall.Acti
 	// Use Bifrost to send notification
 	if ctx.Bifrost != nil {
 		if err := ctx.Bifrost.SendNotification(notifType, title, message); err != nil {
-			p.mu.Lock()
-			p.errors++
-			p.mu.Unlock()
+			p.RecordError()
 			return &heimdall.ActionResult{
 				Success: false,
 				Message: fmt.Sprintf("Failed to send notification via Bifrost: %v", err),
@@ -611,9 +803,7 @@ func (p *WatcherPlugin) actionNotify(ctx heimdall.ActionContext) (*heimdall.Acti
 
 // actionQuery executes a read-only Cypher query against the database.
 func (p *WatcherPlugin) actionQuery(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	cypher, ok := ctx.Params["cypher"].(string)
 	if !ok || cypher == "" {
@@ -640,9 +830,7 @@ func (p *WatcherPlugin) actionQuery(ctx heimdall.ActionContext) (*heimdall.Actio
 	// Execute query
 	results, err := ctx.Database.Query(ctx.Context, cypher, queryParams)
 	if err != nil {
-		p.mu.Lock()
-		p.errors++
-		p.mu.Unlock()
+		p.RecordError()
 		return &heimdall.ActionResult{
 			Success: false,
 			Message: fmt.Sprintf("Query failed: %v", err),
@@ -663,11 +851,84 @@ func (p *WatcherPlugin) actionQuery(ctx heimdall.ActionContext) (*heimdall.Actio
 	}, nil
 }
 
+// actionRunQuery runs a stored query from the db.query library. Only
+// read-only queries are run, like the query action.
+func (p *WatcherPlugin) actionRunQuery(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	p.RecordRequest()
+
+	name, ok := ctx.Params["name"].(string)
+	if !ok || name == "" {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: "Missing required parameter: name",
+		}, nil
+	}
+	if ctx.Database == nil {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: "Database access not available",
+		}, nil
+	}
+
+	stored, err := ctx.Database.Query(ctx.Context, "CALL db.query.list()", nil)
+	if err != nil {
+		p.RecordError()
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("Listing stored queries failed: %v", err),
+		}, nil
+	}
+	var found, readOnly bool
+	for _, row := range stored {
+		if row["name"] == name {
+			found = true
+			readOnly, _ = row["readOnly"].(bool)
+		}
+	}
+	if !found {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("Unknown stored query: %s", name),
+		}, nil
+	}
+	if !readOnly {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("Stored query %s writes to the database and cannot be run by Heimdall", name),
+		}, nil
+	}
+
+	queryParams := make(map[string]interface{})
+	if params, ok := ctx.Params["params"].(map[string]interface{}); ok {
+		queryParams = params
+	}
+	results, err := ctx.Database.Query(ctx.Context, "CALL db.query.run($name, $params)",
+		map[string]interface{}{"name": name, "params": queryParams})
+	if err != nil {
+		p.RecordError()
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("Stored query %s failed: %v", name, err),
+		}, nil
+	}
+
+	p.addEvent("info", fmt.Sprintf("Stored query executed: %s", name), map[string]interface{}{
+		"result_count": len(results),
+	})
+
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("Stored query %s returned %d results", name, len(results)),
+		Data: map[string]interface{}{
+			"results": results,
+			"count":   len(results),
+		},
+	}, nil
+}
+
 // actionDBStats returns comprehensive database statistics.
 func (p *WatcherPlugin) actionDBStats(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	stats := map[string]interface{}{}
 
@@ -704,6 +965,277 @@ func (p *WatcherPlugin) actionDBStats(ctx heimdall.ActionContext) (*heimdall.Act
 	}, nil
 }
 
+// actionQueries lists running Cypher queries, longest-running first.
+func (p *WatcherPlugin) actionQueries(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	p.RecordRequest()
+
+	if ctx.Database == nil {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: "Database not available",
+		}, nil
+	}
+
+	// Registry order is oldest first, which is longest-running first
+	queries := ctx.Database.Stats().RunningQueries
+	var memory int64
+	for _, q := range queries {
+		memory += q.MemoryBytes
+	}
+
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("%d running queries", len(queries)),
+		Data: map[string]interface{}{
+			"queries":      queries,
+			"count":        len(queries),
+			"memory_bytes": memory,
+		},
+	}, nil
+}
+
+// actionOptimize suggests configuration and schema changes grounded in the
+// workload the database has served (see nornicdb.workload.recommend).
+func (p *WatcherPlugin) actionOptimize(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	p.RecordRequest()
+
+	if ctx.Database == nil {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: "Database not available",
+		}, nil
+	}
+
+	recommendations, err := ctx.Database.Query(ctx.Context, "CALL nornicdb.workload.recommend()", nil)
+	if err != nil {
+		p.RecordError()
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("Workload analysis failed: %v", err),
+		}, nil
+	}
+	if recommendations == nil {
+		recommendations = []map[string]interface{}{}
+	}
+	data := map[string]interface{}{
+		"recommendations": recommendations,
+		"count":           len(recommendations),
+	}
+	if workload, err := ctx.Database.Query(ctx.Context, "CALL nornicdb.workload()", nil); err == nil && len(workload) == 1 {
+		summary := workload[0]
+		delete(summary, "shapes")
+		data["workload"] = summary
+	}
+
+	message := "No changes suggested for the observed workload"
+	if len(recommendations) > 0 {
+		message = fmt.Sprintf("%d changes suggested for the observed workload", len(recommendations))
+	}
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: message,
+		Data:    data,
+	}, nil
+}
+
+// actionQCReport reports the precision and recall of the decisions Heimdall
+// QC made in shadow mode, per suggestion method and prompt version.
+func (p *WatcherPlugin) actionQCReport(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	p.RecordRequest()
+
+	if ctx.Database == nil {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: "Database not available",
+		}, nil
+	}
+
+	evaluations, err := ctx.Database.Query(ctx.Context, "CALL nornicdb.qc.report()", nil)
+	if err != nil {
+		p.RecordError()
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("QC report failed: %v", err),
+		}, nil
+	}
+	if evaluations == nil {
+		evaluations = []map[string]interface{}{}
+	}
+
+	message := "No QC shadow decisions recorded (enable NORNICDB_AUTO_TLP_LLM_QC_SHADOW_ENABLED)"
+	if len(evaluations) > 0 {
+		message = fmt.Sprintf("QC shadow evaluation for %d methods and prompt versions", len(evaluations))
+	}
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: message,
+		Data: map[string]interface{}{
+			"evaluations": evaluations,
+			"count":       len(evaluations),
+		},
+	}, nil
+}
+
+// actionCluster clusters node embeddings into :Cluster nodes (params:
+// algorithm, k, min_cluster_size, label), organizing memories by topic.
+// With topics set, the SLM then labels the new clusters (see
+// clustertopics.go).
+func (p *WatcherPlugin) actionCluster(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	p.RecordRequest()
+
+	if ctx.Database == nil {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: "Database not available",
+		}, nil
+	}
+
+	algorithm, _ := ctx.Params["algorithm"].(string)
+	if algorithm == "" {
+		algorithm = "kmeans"
+	}
+	config := map[string]interface{}{}
+	for param, key := range map[string]string{"k": "k", "min_cluster_size": "minClusterSize", "label": "label"} {
+		if v, ok := ctx.Params[param]; ok && v != nil {
+			config[key] = v
+		}
+	}
+
+	rows, err := ctx.Database.Query(ctx.Context, "CALL nornicdb.cluster.embeddings($algorithm, $config)",
+		map[string]interface{}{"algorithm": algorithm, "config": config})
+	if err == nil && len(rows) != 1 {
+		err = fmt.Errorf("expected one summary row, got %d", len(rows))
+	}
+	if err != nil {
+		p.RecordError()
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("Clustering failed: %v", err),
+		}, nil
+	}
+
+	summary := rows[0]
+	p.mu.Lock()
+	p.addEvent("action", fmt.Sprintf("%s clustering created %v clusters from %v nodes", algorithm, summary["clusters"], summary["nodes"]), summary)
+	p.mu.Unlock()
+	message := fmt.Sprintf("Created %v clusters from %v nodes (%v unclustered)", summary["clusters"], summary["nodes"], summary["noise"])
+
+	if topics, _ := ctx.Params["topics"].(bool); topics {
+		clusters, labelled, failures, err := p.labelClusters(ctx, false, defaultTopicPromptTokens)
+		if err != nil {
+			p.RecordError()
+			summary["topics_error"] = err.Error()
+			message += fmt.Sprintf("; labelling failed: %v", err)
+		} else {
+			labels := p.clusterTopicsResult(clusters, labelled, failures)
+			summary["labelled"] = labelled
+			message += "; " + labels.Message
+		}
+	}
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: message,
+		Data:    summary,
+	}, nil
+}
+
+// actionRules lists the autonomous action rules.
+func (p *WatcherPlugin) actionRules(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	p.RecordRequest()
+
+	if p.rules == nil {
+		return &heimdall.ActionResult{Success: false, Message: "Watcher is not initialized"}, nil
+	}
+	rules := p.rules.Rules()
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("%d rules", len(rules)),
+		Data: map[string]interface{}{
+			"rules": rules,
+		},
+	}, nil
+}
+
+// actionSetRule adds or replaces a rule. The params are the rule itself:
+//
+//	{"name": "slow_writes", "metric": "wal_sync_lag_ms", "threshold": 5000,
+//	 "window_seconds": 60, "prompt": "WAL sync lag is {value}ms, why?"}
+func (p *WatcherPlugin) actionSetRule(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	p.RecordRequest()
+
+	if p.rules == nil {
+		return &heimdall.ActionResult{Success: false, Message: "Watcher is not initialized"}, nil
+	}
+	var rule heimdall.Rule
+	data, err := json.Marshal(ctx.Params)
+	if err == nil {
+		err = json.Unmarshal(data, &rule)
+	}
+	if err == nil {
+		err = p.rules.SetRule(rule)
+	}
+	if err != nil {
+		p.RecordError()
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("Invalid rule: %v", err),
+		}, nil
+	}
+	heimdall.RefreshEventSubscription(p.Name())
+
+	p.mu.Lock()
+	p.addEvent("info", fmt.Sprintf("Rule %s saved", rule.Name), map[string]interface{}{"rule": rule.Name})
+	p.mu.Unlock()
+
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("Rule %s saved", rule.Name),
+		Data: map[string]interface{}{
+			"rule": rule,
+		},
+	}, nil
+}
+
+// actionDeleteRule deletes a rule by name.
+func (p *WatcherPlugin) actionDeleteRule(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	p.RecordRequest()
+
+	name, _ := ctx.Params["name"].(string)
+	if name == "" {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: "Missing required parameter: name",
+		}, nil
+	}
+	if p.rules == nil {
+		return &heimdall.ActionResult{Success: false, Message: "Watcher is not initialized"}, nil
+	}
+	deleted, err := p.rules.DeleteRule(name)
+	if err != nil {
+		p.RecordError()
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to delete rule %s: %v", name, err),
+		}, nil
+	}
+	if !deleted {
+		return &heimdall.ActionResult{
+			Success: false,
+			Message: fmt.Sprintf("No rule named %s", name),
+		}, nil
+	}
+	heimdall.RefreshEventSubscription(p.Name())
+
+	p.mu.Lock()
+	p.addEvent("info", fmt.Sprintf("Rule %s deleted", name), map[string]interface{}{"rule": name})
+	p.mu.Unlock()
+
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("Rule %s deleted", name),
+	}, nil
+}
+
 // === Data Access Methods ===
 
 func (p *WatcherPlugin) Summary() string {
@@ -714,8 +1246,8 @@ func (p *WatcherPlugin) Summary() string {
 		p.status,
 		p.config["model"],
 		time.Since(p.started).Seconds(),
-		p.requests,
-		p.errors,
+		p.Requests.Total(),
+		p.Errors.Total(),
 	)
 }
 
@@ -815,9 +1347,7 @@ func (p *WatcherPlugin) PrePrompt(ctx *heimdall.PromptContext) error {
 // - Cancelling with ctx.Cancel() method
 // - Modifying params before execution
 func (p *WatcherPlugin) PreExecute(ctx *heimdall.PreExecuteContext, done func(heimdall.PreExecuteResult)) {
-	p.mu.Lock()
-	p.requests++
-	p.mu.Unlock()
+	p.RecordRequest()
 
 	log.Printf("[Watcher] PreExecute: request=%s action=%s params=%v", ctx.RequestID, ctx.Action, ctx.Params)
 
@@ -905,7 +1435,7 @@ func (p *WatcherPlugin) PostExecute(ctx *heimdall.PostExecuteContext) {
 
 	// Track errors
 	if ctx.Result != nil && !ctx.Result.Success {
-		p.errors++
+		p.RecordError()
 	}
 
 	// === Send completion notification inline ===
@@ -953,6 +1483,129 @@ func max(a, b int64) int64 {
 // DatabaseEventHook Implementation - Autonomous Action Triggering
 // =============================================================================
 
+// ruleEvaluationInterval is how often metric rules are checked.
+const ruleEvaluationInterval = 10 * time.Second
+
+// DefaultRules are the watcher's autonomous action rules until they are
+// changed with heimdall.watcher.set_rule or delete_rule.
+func DefaultRules() []heimdall.Rule {
+	return []heimdall.Rule{
+		{
+			// Several failures in a short time: analyze
+			Name:          "query_failures",
+			Description:   "Check status after repeated query failures",
+			Event:         &heimdall.EventSubscription{Types: []heimdall.DatabaseEventType{heimdall.EventQueryFailed}},
+			Threshold:     5,
+			WindowSeconds: 300,
+			Action:        "heimdall.watcher.status",
+			Params: map[string]interface{}{
+				"trigger":  "autonomous",
+				"reason":   "query_failures",
+				"failures": "{count}",
+			},
+		},
+		{
+			// Bulk writes: tell clients and ask the SLM whether to look closer
+			Name:          "node_creation_rate",
+			Description:   "Flag high node creation rates",
+			Event:         &heimdall.EventSubscription{Types: []heimdall.DatabaseEventType{heimdall.EventNodeCreated}},
+			Threshold:     1000,
+			WindowSeconds: 60,
+			Notify: &heimdall.RuleNotification{
+				Type:    "warning",
+				Title:   "High Activity",
+				Message: "Detected {count} node creations in the last minute",
+			},
+			Prompt: "Analyze high node creation rate: {count} nodes created in 1 minute. Should we investigate?",
+		},
+		{
+			// Writes not matching the property schema: usually a client
+			// (often generated queries) drifting from the data model
+			Name:          "schema_violations",
+			Description:   "Flag repeated property schema violations",
+			Event:         &heimdall.EventSubscription{Types: []heimdall.DatabaseEventType{heimdall.EventSchemaViolation}},
+			Threshold:     10,
+			WindowSeconds: 300,
+			Notify: &heimdall.RuleNotification{
+				Type:    "warning",
+				Title:   "Schema Violations",
+				Message: "Detected {count} property schema violations in the last 5 minutes",
+			},
+		},
+	}
+}
+
+// EventSubscription limits delivery to the events OnDatabaseEvent acts on,
+// so busy databases don't wake the watcher for every read.
+// Rule changes refresh it.
+func (p *WatcherPlugin) EventSubscription() heimdall.EventSubscription {
+	// Logged by OnDatabaseEvent
+	types := []heimdall.DatabaseEventType{
+		heimdall.EventQueryExecuted,
+		heimdall.EventNodeDeleted,
+		heimdall.EventSchemaViolation,
+	}
+
+	p.mu.RLock()
+	rules := p.rules
+	p.mu.RUnlock()
+	if rules != nil {
+		ruleTypes, all := rules.EventTypes()
+		if all {
+			return heimdall.EventSubscription{}
+		}
+		for _, t := range ruleTypes {
+			if t != heimdall.EventQueryExecuted && t != heimdall.EventNodeDeleted && t != heimdall.EventSchemaViolation {
+				types = append(types, t)
+			}
+		}
+	}
+	return heimdall.EventSubscription{Types: types}
+}
+
+// onRuleFired records a rule firing.
+func (p *WatcherPlugin) onRuleFired(firing heimdall.RuleFiring) {
+	log.Printf("[Watcher] Autonomous action: rule %s fired (count=%d value=%g)", firing.Rule, firing.Count, firing.Value)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.addEvent("info", fmt.Sprintf("Autonomous action triggered by rule %s", firing.Rule), map[string]interface{}{
+		"rule":  firing.Rule,
+		"count": firing.Count,
+		"value": firing.Value,
+	})
+}
+
+// evaluateRules checks metric rules every ruleEvaluationInterval until
+// stop is closed.
+func evaluateRules(rules *heimdall.RuleEngine, metrics heimdall.MetricsReader, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(ruleEvaluationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			rules.Evaluate(metrics.Runtime())
+		}
+	}
+}
+
+// stopRuleLoop stops metric rule evaluation and waits for it to exit.
+func (p *WatcherPlugin) stopRuleLoop() {
+	p.mu.Lock()
+	stop, done := p.stopLoop, p.loopDone
+	p.stopLoop, p.loopDone = nil, nil
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
 // OnDatabaseEvent is called when database operations occur.
 // This demonstrates AUTONOMOUS ACTION INVOCATION:
 // - Accumulates events over time
@@ -964,71 +1617,17 @@ func max(a, b int64) int64 {
 //  2. High node creation rate → trigger memory curation
 //  3. Security-related events → trigger security analysis
 func (p *WatcherPlugin) OnDatabaseEvent(event *heimdall.DatabaseEvent) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	// === Track Query Failures ===
-	if event.Type == heimdall.EventQueryFailed {
-		// Reset counter every 5 minutes
-		if time.Since(p.lastFailureReset) > 5*time.Minute {
-			p.queryFailures = 0
-			p.lastFailureReset = time.Now()
-		}
-		p.queryFailures++
-
-		// AUTONOMOUS ACTION: After 5 failures in 5 minutes, analyze
-		if p.queryFailures >= 5 && p.ctx.Heimdall != nil {
-			log.Printf("[Watcher] Autonomous action: %d query failures detected, triggering analysis", p.queryFailures)
-
-			// Option 1: Directly invoke an action
-			p.ctx.Heimdall.InvokeActionAsync("heimdall.watcher.status", map[string]interface{}{
-				"trigger":  "autonomous",
-				"reason":   "query_failures",
-				"failures": p.queryFailures,
-			})
-
-			// Reset counter after triggering
-			p.queryFailures = 0
-			p.lastFailureReset = time.Now()
+	p.mu.RLock()
+	rules := p.rules
+	p.mu.RUnlock()
 
-			// Log the autonomous action
-			p.addEvent("info", "Autonomous analysis triggered due to query failures", map[string]interface{}{
-				"failures_count": p.queryFailures,
-				"time_window":    "5m",
-			})
-		}
+	// Rules count events and fire their actions; see DefaultRules
+	if rules != nil {
+		rules.OnDatabaseEvent(event)
 	}
 
-	// === Track High Node Creation Rate ===
-	if event.Type == heimdall.EventNodeCreated {
-		// Reset counter every minute
-		if time.Since(p.lastCreationReset) > time.Minute {
-			p.nodeCreations = 0
-			p.lastCreationReset = time.Now()
-		}
-		p.nodeCreations++
-
-		// AUTONOMOUS ACTION: After 1000 nodes/minute, notify about high creation rate
-		if p.nodeCreations >= 1000 && p.ctx.Bifrost != nil && p.ctx.Bifrost.IsConnected() {
-			log.Printf("[Watcher] Autonomous notification: High node creation rate (%d/min)", p.nodeCreations)
-
-			// Send notification to connected clients
-			p.ctx.Bifrost.SendNotification("warning", "High Activity",
-				fmt.Sprintf("Detected %d node creations in the last minute", p.nodeCreations))
-
-			// Option 2: Send a natural language prompt to the SLM
-			// The SLM will interpret this and decide what action to take
-			if p.ctx.Heimdall != nil {
-				p.ctx.Heimdall.SendPromptAsync(
-					fmt.Sprintf("Analyze high node creation rate: %d nodes created in 1 minute. Should we investigate?",
-						p.nodeCreations))
-			}
-
-			// Reset counter after notification
-			p.nodeCreations = 0
-			p.lastCreationReset = time.Now()
-		}
-	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
 	// === Log interesting events ===
 	switch event.Type {
@@ -1037,6 +1636,12 @@ func (p *WatcherPlugin) OnDatabaseEvent(event *heimdall.DatabaseEvent) {
 			"node_id": event.NodeID,
 			"labels":  event.NodeLabels,
 		})
+	case heimdall.EventSchemaViolation:
+		p.addEvent("warning", "Property schema violation", map[string]interface{}{
+			"node_id":         event.NodeID,
+			"relationship_id": event.RelationshipID,
+			"violation":       event.Error,
+		})
 	case heimdall.EventQueryExecuted:
 		if event.Duration > 5*time.Second {
 			p.addEvent("warning", "Slow query detected", map[string]interface{}{
@@ -2,9 +2,15 @@ package heimdall
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/orneryd/nornicdb/pkg/heimdall"
+	"github.com/orneryd/nornicdb/pkg/heimdall/plugintest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -21,6 +27,7 @@ func newActionCtx(params map[string]interface{}) heimdall.ActionContext {
 // TestWatcherPlugin_Interface verifies plugin implements HeimdallPlugin
 func TestWatcherPlugin_Interface(t *testing.T) {
 	var _ heimdall.HeimdallPlugin = &WatcherPlugin{}
+	var _ heimdall.DatabaseEventSubscriber = &WatcherPlugin{}
 }
 
 // TestWatcherPlugin_Identity tests identity methods
@@ -85,6 +92,10 @@ func TestWatcherPlugin_Actions(t *testing.T) {
 		"events",     // Get events
 		"broadcast",  // Broadcast message
 		"notify",     // Send notification
+		"queries",    // Running queries
+		"run_query",  // Stored queries
+		"optimize",   // Workload recommendations
+		"qc_report",  // QC shadow evaluation
 	}
 
 	for _, name := range expectedActions {
@@ -394,3 +405,586 @@ func TestWatcherPlugin_Concurrency(t *testing.T) {
 	metrics := p.Metrics()
 	assert.GreaterOrEqual(t, metrics["requests"].(int64), int64(1000))
 }
+
+// queriesDB reports a fixed set of running queries.
+type queriesDB struct {
+	queries []heimdall.RunningQuery
+}
+
+func (d *queriesDB) Query(ctx context.Context, cypher string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (d *queriesDB) Stats() heimdall.DatabaseStats {
+	return heimdall.DatabaseStats{RunningQueries: d.queries}
+}
+
+// TestWatcherPlugin_RunningQueries tests running queries in health and the queries action
+func TestWatcherPlugin_RunningQueries(t *testing.T) {
+	db := &queriesDB{queries: []heimdall.RunningQuery{
+		{ID: "query-1", Query: "MATCH (n) RETURN n", ElapsedMs: 1500, Rows: 10, MemoryBytes: 2048},
+		{ID: "query-2", Query: "MATCH (n) RETURN count(n)", ElapsedMs: 20, MemoryBytes: 64},
+	}}
+	p := &WatcherPlugin{}
+	require.NoError(t, p.Initialize(heimdall.SubsystemContext{Database: db}))
+	require.NoError(t, p.Start())
+
+	health := p.Health()
+	assert.Equal(t, 2, health.Details["running_queries"])
+	assert.Equal(t, int64(1500), health.Details["longest_query_ms"])
+
+	actionCtx := newActionCtx(nil)
+	actionCtx.Database = db
+	result, err := p.actionQueries(actionCtx)
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 2, result.Data["count"])
+	assert.Equal(t, int64(2112), result.Data["memory_bytes"])
+
+	result, err = p.actionQueries(newActionCtx(nil))
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+}
+
+// workloadDB answers the workload procedures with fixed rows.
+type workloadDB struct {
+	queriesDB
+}
+
+func (d *workloadDB) Query(ctx context.Context, cypher string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	switch cypher {
+	case "CALL nornicdb.workload.recommend()":
+		return []map[string]interface{}{{
+			"area": "index", "setting": "Person(email)", "current": "none",
+			"suggested": "CREATE INDEX IF NOT EXISTS FOR (n:Person) ON (n.email)", "reason": "120 queries",
+		}}, nil
+	case "CALL nornicdb.workload()":
+		return []map[string]interface{}{{"queries": int64(120), "shapes": []interface{}{}}}, nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", cypher)
+}
+
+// TestWatcherPlugin_OptimizeAction tests workload-based recommendations
+func TestWatcherPlugin_OptimizeAction(t *testing.T) {
+	p := &WatcherPlugin{}
+	db := &workloadDB{}
+	actionCtx := newActionCtx(nil)
+	actionCtx.Database = db
+
+	result, err := p.actionOptimize(actionCtx)
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, "1 changes suggested for the observed workload", result.Message)
+	assert.Equal(t, 1, result.Data["count"])
+	recs := result.Data["recommendations"].([]map[string]interface{})
+	assert.Equal(t, "Person(email)", recs[0]["setting"])
+	summary := result.Data["workload"].(map[string]interface{})
+	assert.Equal(t, int64(120), summary["queries"])
+	assert.NotContains(t, summary, "shapes", "shapes are left to nornicdb.workload()")
+
+	result, err = p.actionOptimize(newActionCtx(nil))
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+}
+
+// TestWatcherPlugin_QCReportAction tests the QC shadow evaluation report
+func TestWatcherPlugin_QCReportAction(t *testing.T) {
+	p := &WatcherPlugin{}
+	db := plugintest.NewMockDatabase()
+	actionCtx := newActionCtx(nil)
+	actionCtx.Database = db
+
+	result, err := p.actionQCReport(actionCtx)
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 0, result.Data["count"])
+	assert.Contains(t, result.Message, "No QC shadow decisions")
+
+	db.SetResult("CALL nornicdb.qc.report()", []map[string]interface{}{
+		{"dimension": "method", "key": "similarity", "precision": 0.75, "recall": 0.6},
+		{"dimension": "prompt_version", "key": "v2", "precision": 0.75, "recall": 0.6},
+	})
+	result, err = p.actionQCReport(actionCtx)
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 2, result.Data["count"])
+	rows := result.Data["evaluations"].([]map[string]interface{})
+	assert.Equal(t, "similarity", rows[0]["key"])
+
+	result, err = p.actionQCReport(newActionCtx(nil))
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+}
+
+// TestWatcherPlugin_ClusterAction tests clustering memories by embedding
+func TestWatcherPlugin_ClusterAction(t *testing.T) {
+	p := &WatcherPlugin{}
+	db := plugintest.NewMockDatabase()
+	db.SetResult("CALL nornicdb.cluster.embeddings($algorithm, $config)", []map[string]interface{}{
+		{"algorithm": "hdbscan", "nodes": int64(500), "clusters": int64(12), "noise": int64(40), "skipped": int64(0)},
+	})
+	actionCtx := newActionCtx(map[string]interface{}{
+		"algorithm":        "hdbscan",
+		"min_cluster_size": float64(8),
+		"label":            "Memory",
+	})
+	actionCtx.Database = db
+
+	result, err := p.actionCluster(actionCtx)
+	require.NoError(t, err)
+	assert.True(t, result.Success, result.Message)
+	assert.Equal(t, "Created 12 clusters from 500 nodes (40 unclustered)", result.Message)
+	calls := db.Queries()
+	require.Len(t, calls, 1)
+	assert.Equal(t, map[string]interface{}{
+		"algorithm": "hdbscan",
+		"config":    map[string]interface{}{"minClusterSize": float64(8), "label": "Memory"},
+	}, calls[0].Params)
+
+	actionCtx.Params = map[string]interface{}{}
+	_, err = p.actionCluster(actionCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "kmeans", db.Queries()[1].Params["algorithm"], "default algorithm")
+
+	result, err = p.actionCluster(newActionCtx(nil))
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+}
+
+// TestWatcherPlugin_RunQueryAction tests running stored queries by name
+func TestWatcherPlugin_RunQueryAction(t *testing.T) {
+	p := &WatcherPlugin{}
+	db := plugintest.NewMockDatabase()
+	db.SetResult("CALL db.query.list()", []map[string]interface{}{
+		{"name": "topCustomers", "readOnly": true},
+		{"name": "archiveOrders", "readOnly": false},
+	})
+	db.SetResult("CALL db.query.run($name, $params)", []map[string]interface{}{
+		{"c.name": "Ada"}, {"c.name": "Bo"},
+	})
+	actionCtx := newActionCtx(map[string]interface{}{
+		"name":   "topCustomers",
+		"params": map[string]interface{}{"n": int64(2)},
+	})
+	actionCtx.Database = db
+
+	result, err := p.actionRunQuery(actionCtx)
+	require.NoError(t, err)
+	assert.True(t, result.Success, result.Message)
+	assert.Equal(t, 2, result.Data["count"])
+	calls := db.Queries()
+	require.Len(t, calls, 2)
+	assert.Equal(t, map[string]interface{}{
+		"name":   "topCustomers",
+		"params": map[string]interface{}{"n": int64(2)},
+	}, calls[1].Params)
+
+	for name, msg := range map[string]string{
+		"archiveOrders": "cannot be run by Heimdall",
+		"missing":       "Unknown stored query",
+		"":              "Missing required parameter",
+	} {
+		actionCtx.Params = map[string]interface{}{"name": name}
+		result, err = p.actionRunQuery(actionCtx)
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Message, msg)
+	}
+}
+
+// TestWatcherPlugin_Hooks drives the optional hooks through the plugintest harness
+func TestWatcherPlugin_Hooks(t *testing.T) {
+	p := &WatcherPlugin{}
+	h := plugintest.Start(t, p)
+
+	t.Run("PrePrompt", func(t *testing.T) {
+		ctx := &heimdall.PromptContext{UserMessage: "check the system"}
+		require.NoError(t, h.PrePrompt(ctx))
+		assert.Contains(t, ctx.PluginData, "watcher_goroutines")
+		assert.NotEmpty(t, ctx.Examples)
+		h.AssertNotification("info", "Watcher")
+		h.AssertNotification("progress", "Watcher")
+		h.AssertEvent("info", "PrePrompt hook executed")
+	})
+
+	t.Run("PreExecute", func(t *testing.T) {
+		long := make([]byte, 10001)
+		r := h.PreExecute(&heimdall.PreExecuteContext{
+			Action: "heimdall.watcher.query",
+			Params: map[string]interface{}{"cypher": string(long)},
+		})
+		assert.False(t, r.Continue)
+		h.AssertNotification("warning", "Query Validation")
+
+		r = h.PreExecute(&heimdall.PreExecuteContext{Action: "heimdall.watcher.status"})
+		assert.True(t, r.Continue)
+	})
+
+	t.Run("PostExecute", func(t *testing.T) {
+		h.PostExecute(&heimdall.PostExecuteContext{
+			Action: "heimdall.watcher.status",
+			Result: &heimdall.ActionResult{Success: false, Message: "nope"},
+		})
+		n := h.AssertNotification("error", "Watcher")
+		assert.Contains(t, n.Message, "nope")
+	})
+
+	t.Run("subscription skips reads", func(t *testing.T) {
+		assert.False(t, h.DatabaseEvent(&heimdall.DatabaseEvent{Type: heimdall.EventNodeRead}))
+		assert.True(t, h.DatabaseEvent(&heimdall.DatabaseEvent{Type: heimdall.EventNodeDeleted, NodeID: "n1"}))
+		h.AssertEvent("info", "Node deleted")
+	})
+
+	t.Run("query failures trigger status", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			h.DatabaseEvent(&heimdall.DatabaseEvent{Type: heimdall.EventQueryFailed})
+		}
+		inv := h.AssertInvoked("status")
+		assert.Equal(t, "query_failures", inv.Params["reason"])
+		assert.Equal(t, int64(5), inv.Params["failures"])
+		assert.Equal(t, "query_failures", inv.Params["rule"])
+		event := h.AssertEvent("info", "rule query_failures")
+		assert.Equal(t, int64(5), event.Data["count"], "logs the count that fired, not the reset value")
+
+		// The window restarts after firing
+		for i := 0; i < 4; i++ {
+			h.DatabaseEvent(&heimdall.DatabaseEvent{Type: heimdall.EventQueryFailed})
+		}
+		h.Invoker.Wait()
+		assert.Len(t, h.Invoker.Invocations(), 1)
+		assert.Equal(t, int64(4), ruleStatus(t, p, "query_failures").Count)
+		assert.Equal(t, int64(1), p.Metrics()["rules_fired"])
+	})
+
+	t.Run("schema violations notify", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			h.DatabaseEvent(&heimdall.DatabaseEvent{Type: heimdall.EventSchemaViolation, NodeID: "n1",
+				Error: "node n1: Person.age expected INTEGER"})
+		}
+		h.AssertEvent("warning", "Property schema violation")
+		n := h.AssertNotification("warning", "Schema Violations")
+		assert.Contains(t, n.Message, "10 property schema violations")
+	})
+}
+
+func ruleStatus(t *testing.T, p *WatcherPlugin, name string) heimdall.RuleStatus {
+	t.Helper()
+	for _, r := range p.rules.Rules() {
+		if r.Name == name {
+			return r
+		}
+	}
+	t.Fatalf("no rule %s", name)
+	return heimdall.RuleStatus{}
+}
+
+// TestWatcherPlugin_RuleActions manages rules at runtime and checks they persist
+func TestWatcherPlugin_RuleActions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	p := &WatcherPlugin{}
+	h := plugintest.New(t, p)
+	h.Context.Config.RulesFile = path
+	h.Initialize()
+	h.Start()
+
+	result, err := h.Action("rules", nil)
+	require.NoError(t, err)
+	assert.Len(t, result.Data["rules"], len(DefaultRules()))
+	assert.NotContains(t, p.EventSubscription().Types, heimdall.EventRelationshipCreated)
+
+	// Event rule on a type the watcher was not subscribed to
+	result, err = h.Action("set_rule", map[string]interface{}{
+		"name":      "edge_burst",
+		"event":     map[string]interface{}{"types": []interface{}{"relationship.created"}},
+		"threshold": float64(2),
+		"notify":    map[string]interface{}{"type": "info", "message": "{count} edges"},
+	})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Message)
+	assert.Contains(t, p.EventSubscription().Types, heimdall.EventRelationshipCreated)
+
+	h.DatabaseEvent(&heimdall.DatabaseEvent{Type: heimdall.EventRelationshipCreated})
+	h.DatabaseEvent(&heimdall.DatabaseEvent{Type: heimdall.EventRelationshipCreated})
+	n := h.AssertNotification("info", "Rule: edge_burst")
+	assert.Equal(t, "2 edges", n.Message)
+
+	// Metric rule, evaluated in the background loop in production
+	result, err = h.Action("set_rule", map[string]interface{}{
+		"name":      "goroutines",
+		"metric":    "goroutine_count",
+		"threshold": float64(100),
+		"prompt":    "{value} goroutines",
+	})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Message)
+	p.rules.Evaluate(heimdall.RuntimeMetrics{GoroutineCount: 150})
+	h.Invoker.Wait()
+	assert.Equal(t, []string{"150 goroutines"}, h.Invoker.Prompts())
+
+	result, err = h.Action("set_rule", map[string]interface{}{"name": "broken", "metric": "nope", "prompt": "x"})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Message, "unknown metric")
+
+	result, err = h.Action("delete_rule", map[string]interface{}{"name": "node_creation_rate"})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	result, err = h.Action("delete_rule", map[string]interface{}{"name": "node_creation_rate"})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+
+	// A new watcher loads the saved rules instead of the defaults
+	p2 := &WatcherPlugin{}
+	require.NoError(t, p2.Initialize(heimdall.SubsystemContext{Config: heimdall.Config{RulesFile: path}}))
+	var names []string
+	for _, r := range p2.rules.Rules() {
+		names = append(names, r.Name)
+	}
+	assert.Equal(t, []string{"edge_burst", "goroutines", "query_failures", "schema_violations"}, names)
+}
+
+// TestWatcherPlugin_AutoIndex tests guarded auto-indexing against a database
+// that recommends an index until it is created
+func TestWatcherPlugin_AutoIndex(t *testing.T) {
+	p := &WatcherPlugin{}
+	h := plugintest.Start(t, p)
+
+	var mu sync.Mutex
+	indexed, takes := false, true
+	h.Database.QueryFunc = func(ctx context.Context, cypher string, params map[string]interface{}) ([]map[string]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case cypher == "CALL nornicdb.workload.recommend()":
+			if indexed {
+				return nil, nil
+			}
+			return []map[string]interface{}{
+				{"area": "pool", "setting": "nornicdb.memory.pool.max_size", "queries": int64(5000)},
+				{"area": "index", "setting": "Person(email)", "reason": "120 queries", "queries": int64(120)},
+			}, nil
+		case strings.HasPrefix(cypher, "CREATE INDEX"):
+			indexed = takes
+		case strings.HasPrefix(cypher, "DROP INDEX"):
+			indexed = false
+		}
+		return nil, nil
+	}
+	created := func() []string {
+		var out []string
+		for _, q := range h.Database.Queries() {
+			if strings.HasPrefix(q.Cypher, "CREATE INDEX") || strings.HasPrefix(q.Cypher, "DROP INDEX") {
+				out = append(out, q.Cypher)
+			}
+		}
+		return out
+	}
+	night := time.Date(2024, 5, 1, 3, 0, 0, 0, time.Local)
+	noon := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+
+	// Off by default
+	p.checkAutoIndex(context.Background(), night)
+	assert.Empty(t, h.Database.Queries())
+
+	result, err := h.Action("set_config", map[string]interface{}{
+		"auto_index": true, "auto_index_min_queries": float64(100), "auto_index_window": "22:00-06:00",
+	})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Message)
+
+	t.Run("outside window", func(t *testing.T) {
+		p.checkAutoIndex(context.Background(), noon)
+		assert.Empty(t, h.Database.Queries())
+	})
+
+	t.Run("declined", func(t *testing.T) {
+		h.Bifrost.Confirm = false
+		p.checkAutoIndex(context.Background(), night)
+		p.checkAutoIndex(context.Background(), night)
+		require.Len(t, h.Bifrost.Confirmations(), 1, "not asked again in the same window")
+		assert.Contains(t, h.Bifrost.Confirmations()[0], "auto_person_email on :Person(email)")
+		assert.Empty(t, created())
+		h.AssertEvent("warning", "was not approved")
+	})
+
+	t.Run("approved", func(t *testing.T) {
+		h.Bifrost.Confirm = true
+		p.checkAutoIndex(context.Background(), noon) // The next window asks again
+		p.checkAutoIndex(context.Background(), night)
+		assert.Equal(t, []string{"CREATE INDEX auto_person_email IF NOT EXISTS FOR (n:Person) ON (n.email)"}, created())
+		h.AssertNotification("info", "Index created")
+
+		result, err := h.Action("auto_indexes", nil)
+		require.NoError(t, err)
+		indexes := result.Data["indexes"].([]AutoIndex)
+		require.Len(t, indexes, 1)
+		assert.Equal(t, "auto_person_email", indexes[0].Name)
+		assert.Equal(t, int64(120), indexes[0].Queries)
+		assert.True(t, indexes[0].Confirmed)
+	})
+
+	t.Run("rollback action", func(t *testing.T) {
+		result, err := h.Action("rollback_index", map[string]interface{}{"name": "index_user_id"})
+		require.NoError(t, err)
+		assert.False(t, result.Success, "only auto-created indexes")
+
+		result, err = h.Action("rollback_index", map[string]interface{}{"name": "auto_person_email"})
+		require.NoError(t, err)
+		require.True(t, result.Success, result.Message)
+		assert.Equal(t, "DROP INDEX auto_person_email", created()[1])
+
+		result, err = h.Action("auto_indexes", nil)
+		require.NoError(t, err)
+		assert.Empty(t, result.Data["indexes"])
+
+		p.checkAutoIndex(context.Background(), night)
+		assert.Len(t, created(), 2, "not recreated in the same window")
+	})
+
+	t.Run("did not take", func(t *testing.T) {
+		mu.Lock()
+		takes = false
+		mu.Unlock()
+		result, err := h.Action("set_config", map[string]interface{}{"auto_index_confirm": "never"})
+		require.NoError(t, err)
+		require.True(t, result.Success, result.Message)
+
+		confirmations := len(h.Bifrost.Confirmations())
+		p.checkAutoIndex(context.Background(), night)
+		assert.Len(t, h.Bifrost.Confirmations(), confirmations, "no confirmation with never")
+		assert.Equal(t, []string{
+			"CREATE INDEX auto_person_email IF NOT EXISTS FOR (n:Person) ON (n.email)",
+			"DROP INDEX auto_person_email",
+		}, created()[2:])
+		h.AssertNotification("warning", "Auto-index rolled back")
+	})
+
+	t.Run("threshold", func(t *testing.T) {
+		result, err := h.Action("set_config", map[string]interface{}{"auto_index_min_queries": 500})
+		require.NoError(t, err)
+		require.True(t, result.Success, result.Message)
+		before := len(created())
+		p.checkAutoIndex(context.Background(), night)
+		assert.Len(t, created(), before)
+	})
+
+	for _, params := range []map[string]interface{}{
+		{"auto_index": "yes"},
+		{"auto_index_min_queries": 0},
+		{"auto_index_window": "25:00-01:00"},
+		{"auto_index_window": "02:00"},
+		{"auto_index_confirm": "sometimes"},
+	} {
+		result, err := h.Action("set_config", params)
+		require.NoError(t, err)
+		assert.False(t, result.Success, "%v", params)
+	}
+}
+
+func TestInMaintenanceWindow(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2024, 5, 1, h, m, 0, 0, time.Local) }
+
+	assert.True(t, inMaintenanceWindow("", at(12, 0)))
+	assert.True(t, inMaintenanceWindow("02:00-05:00", at(2, 0)))
+	assert.True(t, inMaintenanceWindow("02:00-05:00", at(4, 59)))
+	assert.False(t, inMaintenanceWindow("02:00-05:00", at(5, 0)))
+	assert.True(t, inMaintenanceWindow("22:30-01:00", at(23, 0)))
+	assert.True(t, inMaintenanceWindow("22:30-01:00", at(0, 30)))
+	assert.False(t, inMaintenanceWindow("22:30-01:00", at(22, 0)))
+}
+
+func TestWatcherPlugin_LabelClusters(t *testing.T) {
+	p := &WatcherPlugin{}
+	h := plugintest.Start(t, p)
+
+	// Eight clusters with two representatives each, and one without.
+	clusters := []map[string]interface{}{}
+	for i := 0; i < 8; i++ {
+		clusters = append(clusters, map[string]interface{}{
+			"id":              int64(i),
+			"representatives": []interface{}{fmt.Sprintf("n%d-a", i), fmt.Sprintf("n%d-b", i)},
+		})
+	}
+	clusters = append(clusters, map[string]interface{}{"id": int64(8), "representatives": nil})
+	h.Database.QueryFunc = func(ctx context.Context, cypher string, params map[string]interface{}) ([]map[string]interface{}, error) {
+		switch cypher {
+		case unlabelledClustersQuery:
+			return clusters, nil
+		case clusterTextsQuery:
+			var rows []map[string]interface{}
+			for _, id := range params["ids"].([]interface{}) {
+				rows = append(rows, map[string]interface{}{"id": id, "title": "note " + id.(string), "text": strings.Repeat("word ", 200)})
+			}
+			return rows, nil
+		}
+		return nil, nil
+	}
+	// The SLM labels every cluster it is shown, and one it is not.
+	h.Invoker.PromptFunc = func(prompt string) (*heimdall.ActionResult, error) {
+		var topics []string
+		for i := 0; i < 9; i++ {
+			if strings.Contains(prompt, fmt.Sprintf("Cluster %d:", i)) || i == 8 {
+				topics = append(topics, fmt.Sprintf(`{"cluster": %d, "topic": " topic %d ", "summary": "about %d"}`, i, i, i))
+			}
+		}
+		return &heimdall.ActionResult{Success: true, Message: "Here you go:\n[" + strings.Join(topics, ",") + "]"}, nil
+	}
+
+	result, err := p.actionLabelClusters(h.ActionContext(map[string]interface{}{"prompt_tokens": float64(1200)}))
+	require.NoError(t, err)
+	assert.True(t, result.Success, result.Message)
+	assert.Equal(t, "Labelled 8 of 9 clusters", result.Message)
+
+	prompts := h.Invoker.Prompts()
+	require.Len(t, prompts, 2, "batches of clusterTopicBatch clusters")
+	for _, prompt := range prompts {
+		assert.LessOrEqual(t, heimdall.EstimateTokens(prompt), 1200+200, "texts truncated to the budget")
+	}
+	assert.Contains(t, prompts[0], "note n0-a")
+	assert.Contains(t, prompts[1], "note n7-b")
+
+	var set []map[string]interface{}
+	for _, q := range h.Database.Queries() {
+		if q.Cypher == setClusterTopicQuery {
+			set = append(set, q.Params)
+		}
+	}
+	require.Len(t, set, 8, "cluster 8 has no texts to label")
+	assert.Equal(t, int64(0), set[0]["id"])
+	assert.Equal(t, "topic 0", set[0]["topic"])
+	assert.Equal(t, "about 0", set[0]["summary"])
+
+	t.Run("unparseable answer", func(t *testing.T) {
+		h.Invoker.PromptFunc = func(string) (*heimdall.ActionResult, error) {
+			return &heimdall.ActionResult{Success: true, Message: "I cannot help with that"}, nil
+		}
+		result, err := p.actionLabelClusters(h.ActionContext(nil))
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Len(t, result.Data["failures"], 2)
+		h.AssertEvent("warning", "2 prompts failed")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		result, err := p.actionLabelClusters(h.ActionContext(map[string]interface{}{"prompt_tokens": 10}))
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Message, "prompt_tokens")
+
+		result, err = (&WatcherPlugin{}).actionLabelClusters(h.ActionContext(nil))
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Message, "SLM not available")
+	})
+}
+
+func TestParseClusterTopics(t *testing.T) {
+	topics, err := parseClusterTopics("```json\n[{\"cluster\": 3, \"topic\": \"Go tooling\", \"summary\": \"Build notes.\"}]\n```")
+	require.NoError(t, err)
+	assert.Equal(t, []clusterTopic{{Cluster: float64(3), Topic: "Go tooling", Summary: "Build notes."}}, topics)
+
+	_, err = parseClusterTopics("no json here")
+	assert.Error(t, err)
+	_, err = parseClusterTopics("[{broken]")
+	assert.Error(t, err)
+}
@@ -0,0 +1,732 @@
+// Package reports provides the Heimdall reports plugin.
+//
+// An admin defines reports: read-only Cypher queries, narrative
+// instructions for the SLM and a schedule. When a report is due the plugin
+// runs its queries, has the SLM summarize the results and delivers the
+// summary to Bifrost clients (optionally a topic), a Slack incoming
+// webhook, an email gateway webhook or a generic webhook.
+//
+// # Actions Provided
+//
+//   - heimdall.reports.list - Reports with their schedule and last run
+//   - heimdall.reports.run - Generate a report now
+//   - heimdall.reports.set - Add or replace a report (confirmed)
+//   - heimdall.reports.delete - Delete a report (confirmed)
+//
+// # Example Report
+//
+//	{"name": "daily_growth", "every": "24h", "at": "08:00",
+//	 "queries": [{"name": "labels", "cypher": "MATCH (n) RETURN labels(n) AS label, count(*) AS nodes"}],
+//	 "prompt": "Summarize how the graph is composed and call out small labels.",
+//	 "deliver": [{"type": "bifrost", "topic": "reports"},
+//	             {"type": "slack", "url": "https://hooks.slack.com/services/..."}]}
+//
+// Reports are saved to Config.ReportsFile. Summaries are generated through
+// heimdall.TextGenerator, so query results cannot trigger actions; without
+// an SLM the results are delivered as a plain listing.
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+)
+
+// Plugin is the exported plugin variable.
+// For .so plugins, export as: var Plugin heimdall.HeimdallPlugin = &ReportsPlugin{}
+var Plugin heimdall.HeimdallPlugin = &ReportsPlugin{}
+
+// runTimeout bounds one report run: queries, summary and deliveries.
+const runTimeout = 5 * time.Minute
+
+// setting is a configurable option.
+type setting struct {
+	key         string
+	def         float64
+	description string
+}
+
+// configSettings lists every configuration key with its default.
+var configSettings = []setting{
+	{"check_interval_seconds", 30, "How often to look for due reports (0 disables scheduled runs)"},
+	{"webhook_timeout_seconds", 10, "Timeout of each Slack, email or webhook delivery"},
+}
+
+// RunStatus describes the most recent run of a report.
+type RunStatus struct {
+	Time       time.Time `json:"time"`
+	Trigger    string    `json:"trigger"`
+	Success    bool      `json:"success"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ReportStatus is a report with its schedule state.
+type ReportStatus struct {
+	Report
+	NextRun *time.Time `json:"next_run,omitempty"`
+	LastRun *RunStatus `json:"last_run,omitempty"`
+}
+
+// scheduled is a report and its schedule state.
+type scheduled struct {
+	report Report
+	next   time.Time
+	last   *RunStatus
+}
+
+// ReportsPlugin implements heimdall.HeimdallPlugin for scheduled reports.
+type ReportsPlugin struct {
+	mu      sync.RWMutex
+	ctx     heimdall.SubsystemContext
+	status  heimdall.SubsystemStatus
+	events  []heimdall.SubsystemEvent
+	config  map[string]float64
+	started time.Time
+	reports map[string]*scheduled
+	now     func() time.Time
+
+	// Scheduled runs
+	stop chan struct{}
+	done chan struct{}
+
+	runs     int64
+	failures int64
+}
+
+// === Identity Methods ===
+
+func (p *ReportsPlugin) Name() string {
+	return "reports"
+}
+
+func (p *ReportsPlugin) Version() string {
+	return "1.0.0"
+}
+
+func (p *ReportsPlugin) Type() string {
+	return heimdall.PluginTypeHeimdall
+}
+
+func (p *ReportsPlugin) Description() string {
+	return "Reports - scheduled graph reports summarized by the SLM and delivered to Bifrost, Slack, email or webhooks"
+}
+
+// === Lifecycle Methods ===
+
+func (p *ReportsPlugin) Initialize(ctx heimdall.SubsystemContext) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ctx = ctx
+	p.status = heimdall.StatusReady
+	p.events = make([]heimdall.SubsystemEvent, 0, 100)
+	p.config = make(map[string]float64, len(configSettings))
+	for _, s := range configSettings {
+		p.config[s.key] = s.def
+	}
+	if p.now == nil {
+		p.now = time.Now
+	}
+	p.reports = make(map[string]*scheduled)
+
+	loaded, err := loadReports(ctx.Config.ReportsFile)
+	if err != nil {
+		log.Printf("[Reports] Starting without saved reports: %v", err)
+		p.addEvent("warning", "Saved reports could not be loaded", map[string]interface{}{"error": err.Error()})
+	}
+	now := p.now()
+	for _, r := range loaded {
+		p.reports[r.Name] = &scheduled{report: r, next: r.next(now)}
+	}
+
+	p.addEvent("info", fmt.Sprintf("Reports initialized with %d reports", len(p.reports)), nil)
+	return nil
+}
+
+func (p *ReportsPlugin) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.status = heimdall.StatusRunning
+	p.started = time.Now()
+	p.startLoop()
+	p.addEvent("info", "Report scheduling active", nil)
+	return nil
+}
+
+func (p *ReportsPlugin) Stop() error {
+	p.mu.Lock()
+	p.status = heimdall.StatusStopped
+	p.addEvent("info", "Report scheduling paused", nil)
+	p.mu.Unlock()
+
+	p.stopLoop()
+	return nil
+}
+
+func (p *ReportsPlugin) Shutdown() error {
+	p.stopLoop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = heimdall.StatusUninitialized
+	p.addEvent("info", "Reports shut down", nil)
+	return nil
+}
+
+// startLoop starts scheduled runs if configured. Callers hold p.mu.
+func (p *ReportsPlugin) startLoop() {
+	interval := time.Duration(p.config["check_interval_seconds"] * float64(time.Second))
+	if interval <= 0 || p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.loop(interval, p.stop, p.done)
+}
+
+// stopLoop stops scheduled runs and waits for a running report to finish.
+func (p *ReportsPlugin) stopLoop() {
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (p *ReportsPlugin) loop(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.runDue()
+		}
+	}
+}
+
+// runDue runs every report whose scheduled time has come, one at a time,
+// and schedules its next run.
+func (p *ReportsPlugin) runDue() {
+	p.mu.Lock()
+	now := p.now()
+	var due []Report
+	for _, s := range p.reports {
+		if !s.next.IsZero() && !s.next.After(now) {
+			due = append(due, s.report)
+			s.next = s.report.next(now)
+		}
+	}
+	p.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].Name < due[j].Name })
+	for _, r := range due {
+		p.run(r, "schedule", true)
+	}
+}
+
+// run generates report r and, if deliverIt is set, delivers it.
+func (p *ReportsPlugin) run(r Report, trigger string, deliverIt bool) *Output {
+	p.mu.RLock()
+	db, bifrost, invoker := p.ctx.Database, p.ctx.Bifrost, p.ctx.Heimdall
+	client := &http.Client{Timeout: time.Duration(p.config["webhook_timeout_seconds"] * float64(time.Second))}
+	start := p.now()
+	p.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	defer cancel()
+
+	out := &Output{Report: r.Name, Description: r.Description, GeneratedAt: start, Trigger: trigger}
+	out.Results = runQueries(ctx, db, &r)
+	if r.Prompt != "" {
+		if gen, ok := invoker.(heimdall.TextGenerator); !ok {
+			out.SummaryErr = "SLM not available"
+		} else if summary, err := gen.GenerateText(ctx, summaryPrompt(&r, out.Results)); err != nil {
+			out.SummaryErr = err.Error()
+		} else {
+			out.Summary = strings.TrimSpace(summary)
+		}
+	}
+	if deliverIt {
+		out.Deliveries = deliver(client, bifrost, &r, out)
+	}
+
+	status := &RunStatus{
+		Time:       start,
+		Trigger:    trigger,
+		Success:    out.Success(),
+		DurationMs: time.Since(start).Milliseconds(),
+		Error:      outputErrors(out),
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.reports[r.Name]; ok {
+		s.last = status
+	}
+	p.runs++
+	if !status.Success {
+		p.failures++
+		p.addEvent("warning", fmt.Sprintf("Report %s ran with errors", r.Name), map[string]interface{}{"error": status.Error})
+	} else {
+		p.addEvent("info", fmt.Sprintf("Report %s generated", r.Name), map[string]interface{}{"trigger": trigger})
+	}
+	return out
+}
+
+// outputErrors joins the query and delivery errors of out.
+func outputErrors(out *Output) string {
+	var errs []string
+	for _, q := range out.Results {
+		if q.Error != "" {
+			errs = append(errs, fmt.Sprintf("query %s: %s", q.Name, q.Error))
+		}
+	}
+	for _, d := range out.Deliveries {
+		if d.Error != "" {
+			errs = append(errs, fmt.Sprintf("%s delivery: %s", d.Type, d.Error))
+		}
+	}
+	return strings.Join(errs, "; ")
+}
+
+// === Report Definitions ===
+
+// Reports returns the reports with their schedule state, sorted by name.
+func (p *ReportsPlugin) Reports() []ReportStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make([]ReportStatus, 0, len(p.reports))
+	for _, s := range p.reports {
+		status := ReportStatus{Report: s.report, LastRun: s.last}
+		if !s.next.IsZero() {
+			next := s.next
+			status.NextRun = &next
+		}
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// SetReport adds or replaces a report, schedules it and saves the reports.
+func (p *ReportsPlugin) SetReport(r Report) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	previous, existed := p.reports[r.Name]
+	p.reports[r.Name] = &scheduled{report: r, next: r.next(p.now())}
+	if err := p.saveLocked(); err != nil {
+		if existed {
+			p.reports[r.Name] = previous
+		} else {
+			delete(p.reports, r.Name)
+		}
+		return err
+	}
+	p.addEvent("info", "Report saved: "+r.Name, nil)
+	return nil
+}
+
+// DeleteReport removes a report and saves the reports. It reports whether
+// the report existed.
+func (p *ReportsPlugin) DeleteReport(name string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	previous, ok := p.reports[name]
+	if !ok {
+		return false, nil
+	}
+	delete(p.reports, name)
+	if err := p.saveLocked(); err != nil {
+		p.reports[name] = previous
+		return false, err
+	}
+	p.addEvent("info", "Report deleted: "+name, nil)
+	return true, nil
+}
+
+// loadReports reads the reports saved at path. A missing file holds no
+// reports.
+func loadReports(path string) ([]Report, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Reports []Report `json:"reports"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse reports file %s: %w", path, err)
+	}
+	for _, r := range file.Reports {
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("reports file %s: %w", path, err)
+		}
+	}
+	return file.Reports, nil
+}
+
+// saveLocked writes the reports to Config.ReportsFile. p.mu must be held.
+func (p *ReportsPlugin) saveLocked() error {
+	path := p.ctx.Config.ReportsFile
+	if path == "" {
+		return nil
+	}
+	reports := make([]Report, 0, len(p.reports))
+	for _, s := range p.reports {
+		reports = append(reports, s.report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	data, err := json.MarshalIndent(map[string]interface{}{"reports": reports}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("save reports: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("save reports: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("save reports: %w", err)
+	}
+	return nil
+}
+
+// === State & Health Methods ===
+
+func (p *ReportsPlugin) Status() heimdall.SubsystemStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status
+}
+
+func (p *ReportsPlugin) Health() heimdall.SubsystemHealth {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	failing := 0
+	for _, s := range p.reports {
+		if s.last != nil && !s.last.Success {
+			failing++
+		}
+	}
+	running := p.status == heimdall.StatusRunning || p.status == heimdall.StatusReady
+	message := fmt.Sprintf("%d reports scheduled", len(p.reports))
+	if failing > 0 {
+		message = fmt.Sprintf("%d of %d reports failed their last run", failing, len(p.reports))
+	}
+	return heimdall.SubsystemHealth{
+		Status:    p.status,
+		Healthy:   running && failing == 0,
+		Message:   message,
+		LastCheck: time.Now(),
+		Details: map[string]interface{}{
+			"uptime_seconds": time.Since(p.started).Seconds(),
+			"reports":        len(p.reports),
+			"failing":        failing,
+		},
+	}
+}
+
+func (p *ReportsPlugin) Metrics() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return map[string]interface{}{
+		"status":   string(p.status),
+		"reports":  len(p.reports),
+		"runs":     p.runs,
+		"failures": p.failures,
+	}
+}
+
+// === Configuration Methods ===
+
+func (p *ReportsPlugin) Config() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make(map[string]interface{}, len(p.config)+1)
+	for k, v := range p.config {
+		result[k] = v
+	}
+	result["reports_file"] = p.ctx.Config.ReportsFile
+	return result
+}
+
+func (p *ReportsPlugin) Configure(settings map[string]interface{}) error {
+	values := make(map[string]float64, len(settings))
+	for key, value := range settings {
+		if !knownSetting(key) {
+			return fmt.Errorf("unknown config key: %s", key)
+		}
+		v, ok := toFloat(value)
+		if !ok || v < 0 {
+			return fmt.Errorf("invalid %s: must be a non-negative number", key)
+		}
+		values[key] = v
+	}
+
+	p.mu.Lock()
+	for key, v := range values {
+		p.config[key] = v
+	}
+	p.addEvent("info", "Reports configuration updated", settings)
+	_, intervalChanged := values["check_interval_seconds"]
+	running := p.status == heimdall.StatusRunning
+	p.mu.Unlock()
+
+	// Apply a new interval to the running loop
+	if intervalChanged && running {
+		p.stopLoop()
+		p.mu.Lock()
+		if p.status == heimdall.StatusRunning {
+			p.startLoop()
+		}
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+func knownSetting(key string) bool {
+	for _, s := range configSettings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ReportsPlugin) ConfigSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, len(configSettings))
+	for _, s := range configSettings {
+		properties[s.key] = map[string]interface{}{
+			"type":        "number",
+			"description": s.description,
+			"minimum":     0,
+			"default":     s.def,
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// === Actions ===
+
+func (p *ReportsPlugin) Actions() map[string]heimdall.ActionFunc {
+	return map[string]heimdall.ActionFunc{
+		"list": {
+			Description: "List scheduled reports with their next and last run",
+			Category:    "monitoring",
+			Handler:     p.actionList,
+			Schema: &heimdall.ResultSchema{
+				Visualization: heimdall.VisualizationTable,
+				Rows:          "reports",
+				Columns: []heimdall.ResultColumn{
+					{Key: "name", Type: heimdall.ColumnString},
+					{Key: "description", Type: heimdall.ColumnString, Optional: true},
+					{Key: "every", Type: heimdall.ColumnString, Optional: true},
+					{Key: "at", Type: heimdall.ColumnString, Optional: true},
+					{Key: "next_run", Label: "Next run", Type: heimdall.ColumnTimestamp, Optional: true},
+					{Key: "last_run", Label: "Last run", Type: heimdall.ColumnObject, Optional: true},
+					{Key: "deliver", Type: heimdall.ColumnList, Optional: true},
+				},
+			},
+		},
+		"run": {
+			Description: "Generate a report now (params: name, deliver - false to only return it)",
+			Category:    "analysis",
+			Handler:     p.actionRun,
+		},
+		"set": {
+			Description: "Add or replace a scheduled report (params: name, queries [{name, cypher}], prompt, every, at, deliver [{type: bifrost|slack|email|webhook, topic, url, to}])",
+			Category:    "configuration",
+			Risk:        heimdall.RiskMedium,
+			Handler:     p.actionSet,
+		},
+		"delete": {
+			Description: "Delete a scheduled report (params: name)",
+			Category:    "configuration",
+			Risk:        heimdall.RiskMedium,
+			Handler:     p.actionDelete,
+		},
+	}
+}
+
+func (p *ReportsPlugin) actionList(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	reports := p.Reports()
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("%d reports", len(reports)),
+		Data: map[string]interface{}{
+			"reports": reports,
+		},
+	}, nil
+}
+
+func (p *ReportsPlugin) actionRun(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	name, _ := ctx.Params["name"].(string)
+	p.mu.RLock()
+	s, ok := p.reports[name]
+	var r Report
+	if ok {
+		r = s.report
+	}
+	p.mu.RUnlock()
+	if !ok {
+		return &heimdall.ActionResult{Success: false, Message: fmt.Sprintf("Unknown report: %q", name)}, nil
+	}
+
+	deliverIt := true
+	if d, ok := ctx.Params["deliver"].(bool); ok {
+		deliverIt = d
+	}
+	out := p.run(r, "manual", deliverIt)
+
+	message := out.Text()
+	if errs := outputErrors(out); errs != "" {
+		message += "\n\nErrors: " + errs
+	}
+	data := map[string]interface{}{
+		"report":       out.Report,
+		"generated_at": out.GeneratedAt,
+		"results":      out.Results,
+	}
+	if out.Summary != "" {
+		data["summary"] = out.Summary
+	}
+	if out.SummaryErr != "" {
+		data["summary_error"] = out.SummaryErr
+	}
+	if len(out.Deliveries) > 0 {
+		data["deliveries"] = out.Deliveries
+	}
+	return &heimdall.ActionResult{
+		Success: out.Success(),
+		Message: message,
+		Data:    data,
+	}, nil
+}
+
+func (p *ReportsPlugin) actionSet(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	raw, err := json.Marshal(ctx.Params)
+	if err != nil {
+		return &heimdall.ActionResult{Success: false, Message: "Invalid report: " + err.Error()}, nil
+	}
+	var r Report
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return &heimdall.ActionResult{Success: false, Message: "Invalid report: " + err.Error()}, nil
+	}
+	if err := p.SetReport(r); err != nil {
+		return &heimdall.ActionResult{Success: false, Message: err.Error()}, nil
+	}
+	return &heimdall.ActionResult{
+		Success: true,
+		Message: fmt.Sprintf("Report %s saved", r.Name),
+		Data: map[string]interface{}{
+			"report": r,
+		},
+	}, nil
+}
+
+func (p *ReportsPlugin) actionDelete(ctx heimdall.ActionContext) (*heimdall.ActionResult, error) {
+	name, _ := ctx.Params["name"].(string)
+	deleted, err := p.DeleteReport(name)
+	switch {
+	case err != nil:
+		return &heimdall.ActionResult{Success: false, Message: err.Error()}, nil
+	case !deleted:
+		return &heimdall.ActionResult{Success: false, Message: fmt.Sprintf("Unknown report: %q", name)}, nil
+	}
+	return &heimdall.ActionResult{Success: true, Message: fmt.Sprintf("Report %s deleted", name)}, nil
+}
+
+// === Data Access Methods ===
+
+func (p *ReportsPlugin) Summary() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return fmt.Sprintf("Reports: Status=%s, Reports=%d, Runs=%d, Failures=%d",
+		p.status, len(p.reports), p.runs, p.failures)
+}
+
+func (p *ReportsPlugin) RecentEvents(limit int) []heimdall.SubsystemEvent {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if limit <= 0 || limit > len(p.events) {
+		limit = len(p.events)
+	}
+	result := make([]heimdall.SubsystemEvent, limit)
+	copy(result, p.events[len(p.events)-limit:])
+	return result
+}
+
+func (p *ReportsPlugin) addEvent(eventType, message string, data map[string]interface{}) {
+	p.events = append(p.events, heimdall.SubsystemEvent{
+		Time:    time.Now(),
+		Type:    eventType,
+		Message: message,
+		Data:    data,
+	})
+
+	// Keep only last 100 events
+	if len(p.events) > 100 {
+		p.events = p.events[len(p.events)-100:]
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,339 @@
+package reports
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orneryd/nornicdb/pkg/heimdall"
+	"github.com/orneryd/nornicdb/pkg/heimdall/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const labelsQuery = "MATCH (n) RETURN labels(n) AS label, count(*) AS nodes"
+
+// recorder is a webhook endpoint that records the JSON bodies it receives.
+type recorder struct {
+	mu     sync.Mutex
+	bodies []map[string]interface{}
+	status int
+}
+
+func newRecorder(t *testing.T) (*recorder, string) {
+	rec := &recorder{status: http.StatusOK}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		rec.mu.Lock()
+		rec.bodies = append(rec.bodies, body)
+		status := rec.status
+		rec.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return rec, srv.URL + "/hooks/secret-token"
+}
+
+func (r *recorder) received() []map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]map[string]interface{}(nil), r.bodies...)
+}
+
+// start runs the plugin with reports saved to a temporary file and a fixed clock.
+func start(t *testing.T, now time.Time) (*plugintest.Harness, *ReportsPlugin) {
+	p := &ReportsPlugin{now: func() time.Time { return now }}
+	h := plugintest.New(t, p)
+	h.Context.Config.ReportsFile = filepath.Join(t.TempDir(), "reports.json")
+	h.Initialize()
+	h.Start()
+	t.Cleanup(func() { _ = p.Shutdown() })
+	return h, p
+}
+
+func TestReportsPlugin_Identity(t *testing.T) {
+	p := &ReportsPlugin{}
+	assert.Equal(t, "reports", p.Name())
+	assert.Equal(t, heimdall.PluginTypeHeimdall, p.Type())
+	for _, name := range []string{"list", "run", "set", "delete"} {
+		assert.Contains(t, p.Actions(), name)
+	}
+	assert.Equal(t, heimdall.RiskMedium, p.Actions()["set"].Risk)
+}
+
+func TestReport_Validate(t *testing.T) {
+	valid := func() Report {
+		return Report{Name: "daily", Queries: []Query{{Cypher: labelsQuery}}, Every: "24h", At: "08:00"}
+	}
+	require.NoError(t, (&Report{Name: "adhoc", Queries: []Query{{Cypher: labelsQuery}}}).Validate())
+
+	tests := []struct {
+		name   string
+		modify func(r *Report)
+		err    string
+	}{
+		{"bad name", func(r *Report) { r.Name = "daily report" }, "report name"},
+		{"no queries", func(r *Report) { r.Queries = nil }, "at least one query"},
+		{"empty cypher", func(r *Report) { r.Queries = []Query{{Cypher: " "}} }, "has no cypher"},
+		{"duplicate query", func(r *Report) { r.Queries = []Query{{Cypher: "a"}, {Name: "query1", Cypher: "b"}} }, "duplicate query name"},
+		{"bad interval", func(r *Report) { r.Every = "daily" }, "invalid every"},
+		{"short interval", func(r *Report) { r.Every = "10s" }, "at least 1m0s"},
+		{"at without every", func(r *Report) { r.Every = "" }, "at requires every"},
+		{"bad at", func(r *Report) { r.At = "8am" }, "HH:MM"},
+		{"unknown delivery", func(r *Report) { r.Deliver = []Delivery{{Type: "sms"}} }, "unknown delivery type"},
+		{"slack without url", func(r *Report) { r.Deliver = []Delivery{{Type: DeliverSlack}} }, "needs an http(s) url"},
+		{"email without recipients", func(r *Report) { r.Deliver = []Delivery{{Type: DeliverEmail, URL: "https://mail.example.com"}} }, "needs recipients"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.modify(&r)
+			err := r.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestReport_Next(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		report Report
+		want   time.Time
+	}{
+		{"on demand", Report{}, time.Time{}},
+		{"disabled", Report{Every: "1h", Disabled: true}, time.Time{}},
+		{"interval", Report{Every: "1h"}, now.Add(time.Hour)},
+		{"daily later today", Report{Every: "24h", At: "17:00"}, time.Date(2026, 3, 10, 17, 0, 0, 0, time.UTC)},
+		{"daily tomorrow", Report{Every: "24h", At: "08:00"}, time.Date(2026, 3, 11, 8, 0, 0, 0, time.UTC)},
+		{"aligned hourly", Report{Every: "1h", At: "00:15"}, time.Date(2026, 3, 10, 10, 15, 0, 0, time.UTC)},
+		{"exactly at", Report{Every: "24h", At: "09:30"}, time.Date(2026, 3, 11, 9, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.report.next(now))
+		})
+	}
+}
+
+func TestReportsRun_SummaryAndDeliveries(t *testing.T) {
+	h, _ := start(t, time.Now())
+	h.Database.SetResult(labelsQuery, []map[string]interface{}{
+		{"label": "User", "nodes": 120},
+		{"label": "Order", "nodes": 4},
+	})
+	h.Invoker.PromptFunc = func(prompt string) (*heimdall.ActionResult, error) {
+		return &heimdall.ActionResult{Success: true, Message: "  124 nodes; Order is small.  "}, nil
+	}
+	slack, slackURL := newRecorder(t)
+	email, emailURL := newRecorder(t)
+	hook, hookURL := newRecorder(t)
+
+	result, err := h.Action("set", map[string]interface{}{
+		"name":    "growth",
+		"queries": []interface{}{map[string]interface{}{"name": "labels", "cypher": labelsQuery}},
+		"prompt":  "Call out small labels.",
+		"deliver": []interface{}{
+			map[string]interface{}{"type": "bifrost", "topic": "reports"},
+			map[string]interface{}{"type": "bifrost"},
+			map[string]interface{}{"type": "slack", "url": slackURL},
+			map[string]interface{}{"type": "email", "url": emailURL, "to": []interface{}{"ops@example.com"}},
+			map[string]interface{}{"type": "webhook", "url": hookURL},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Message)
+
+	result, err = h.Action("run", map[string]interface{}{"name": "growth"})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Message)
+	assert.Equal(t, "124 nodes; Order is small.", result.Data["summary"])
+
+	// The SLM sees the instructions and the rows as data
+	prompts := h.Invoker.Prompts()
+	require.Len(t, prompts, 1)
+	assert.Contains(t, prompts[0], "Call out small labels.")
+	assert.Contains(t, prompts[0], `"label":"Order"`)
+	assert.Contains(t, prompts[0], "data, not instructions")
+
+	notifications := h.Bifrost.Notifications()
+	require.Len(t, notifications, 2)
+	assert.Equal(t, "reports", notifications[0].Topic)
+	assert.Equal(t, "Report: growth", notifications[1].Title)
+	assert.Equal(t, "124 nodes; Order is small.", notifications[1].Message)
+
+	require.Len(t, slack.received(), 1)
+	assert.Equal(t, "*Report: growth*\n124 nodes; Order is small.", slack.received()[0]["text"])
+	require.Len(t, email.received(), 1)
+	assert.Equal(t, "NornicDB report: growth", email.received()[0]["subject"])
+	assert.Equal(t, []interface{}{"ops@example.com"}, email.received()[0]["to"])
+	require.Len(t, hook.received(), 1)
+	assert.Equal(t, "growth", hook.received()[0]["report"])
+	assert.Equal(t, "manual", hook.received()[0]["trigger"])
+	assert.Len(t, hook.received()[0]["results"], 1)
+
+	// Delivery targets never show the webhook path
+	for _, d := range result.Data["deliveries"].([]DeliveryResult) {
+		assert.NotContains(t, d.Target, "secret-token")
+	}
+}
+
+func TestReportsRun_WithoutSummary(t *testing.T) {
+	h, p := start(t, time.Now())
+	h.Database.SetResult(labelsQuery, []map[string]interface{}{{"label": "User", "nodes": 3}})
+	require.NoError(t, p.SetReport(Report{
+		Name:    "plain",
+		Queries: []Query{{Name: "labels", Cypher: labelsQuery}},
+		MaxRows: 1,
+		Deliver: []Delivery{{Type: DeliverBifrost}},
+	}))
+
+	result, err := h.Action("run", map[string]interface{}{"name": "plain"})
+	require.NoError(t, err)
+	require.True(t, result.Success)
+	assert.Empty(t, h.Invoker.Prompts(), "no prompt, no SLM call")
+	assert.Equal(t, "labels: 1 rows\n  label=User, nodes=3", result.Message)
+	require.Len(t, h.Bifrost.Notifications(), 1)
+
+	// deliver=false only returns the report
+	result, err = h.Action("run", map[string]interface{}{"name": "plain", "deliver": false})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Len(t, h.Bifrost.Notifications(), 1)
+	assert.NotContains(t, result.Data, "deliveries")
+
+	result, err = h.Action("run", map[string]interface{}{"name": "missing"})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+}
+
+func TestReportsRun_Failures(t *testing.T) {
+	h, p := start(t, time.Now())
+	h.Invoker.PromptFunc = func(string) (*heimdall.ActionResult, error) {
+		return &heimdall.ActionResult{Success: false, Message: "model not loaded"}, nil
+	}
+	hook, hookURL := newRecorder(t)
+	hook.status = http.StatusBadGateway
+	require.NoError(t, p.SetReport(Report{
+		Name:    "broken",
+		Queries: []Query{{Cypher: labelsQuery}},
+		Prompt:  "Summarize.",
+		Deliver: []Delivery{{Type: DeliverWebhook, URL: hookURL}},
+	}))
+
+	result, err := h.Action("run", map[string]interface{}{"name": "broken"})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "model not loaded", result.Data["summary_error"])
+	assert.Contains(t, result.Message, "webhook delivery: 502 Bad Gateway")
+	require.Len(t, hook.received(), 1, "the plain listing is still delivered")
+
+	reports := p.Reports()
+	require.Len(t, reports, 1)
+	require.NotNil(t, reports[0].LastRun)
+	assert.False(t, reports[0].LastRun.Success)
+	assert.False(t, p.Health().Healthy)
+	h.AssertEvent("warning", "broken ran with errors")
+}
+
+func TestReportsRunDue(t *testing.T) {
+	now := time.Date(2026, 3, 10, 7, 59, 0, 0, time.UTC)
+	h, p := start(t, now)
+	require.NoError(t, p.SetReport(Report{
+		Name:    "morning",
+		Queries: []Query{{Cypher: labelsQuery}},
+		Every:   "24h",
+		At:      "08:00",
+		Deliver: []Delivery{{Type: DeliverBifrost}},
+	}))
+	require.NoError(t, p.SetReport(Report{Name: "adhoc", Queries: []Query{{Cypher: labelsQuery}}}))
+
+	p.runDue()
+	assert.Empty(t, h.Bifrost.Notifications(), "not due yet")
+
+	p.now = func() time.Time { return now.Add(time.Minute) }
+	p.runDue()
+	require.Len(t, h.Bifrost.Notifications(), 1)
+	p.runDue()
+	assert.Len(t, h.Bifrost.Notifications(), 1, "rescheduled for tomorrow")
+
+	reports := p.Reports()
+	require.Len(t, reports, 2)
+	assert.Equal(t, "adhoc", reports[0].Name)
+	assert.Nil(t, reports[0].NextRun)
+	assert.Nil(t, reports[0].LastRun)
+	assert.Equal(t, "morning", reports[1].Name)
+	assert.Equal(t, time.Date(2026, 3, 11, 8, 0, 0, 0, time.UTC), *reports[1].NextRun)
+	require.NotNil(t, reports[1].LastRun)
+	assert.Equal(t, "schedule", reports[1].LastRun.Trigger)
+}
+
+func TestReportsPersistence(t *testing.T) {
+	h, p := start(t, time.Now())
+	result, err := h.Action("set", map[string]interface{}{
+		"name":    "weekly",
+		"queries": []interface{}{map[string]interface{}{"cypher": labelsQuery}},
+		"every":   "168h",
+	})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Message)
+
+	result, err = h.Action("set", map[string]interface{}{"name": "bad", "queries": []interface{}{}})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+
+	// A new plugin loads the saved reports
+	path := h.Context.Config.ReportsFile
+	reloaded := plugintest.New(t, &ReportsPlugin{})
+	reloaded.Context.Config.ReportsFile = path
+	reloaded.Initialize()
+	result, err = reloaded.Action("list", nil)
+	require.NoError(t, err)
+	reports := result.Data["reports"].([]ReportStatus)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "weekly", reports[0].Name)
+	assert.NotNil(t, reports[0].NextRun)
+
+	result, err = h.Action("delete", map[string]interface{}{"name": "weekly"})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	result, err = h.Action("delete", map[string]interface{}{"name": "weekly"})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Empty(t, p.Reports())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"reports": []}`, string(data))
+}
+
+func TestReportsInitialize_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"reports": [{"name": "x"}]}`), 0o600))
+
+	p := &ReportsPlugin{}
+	h := plugintest.New(t, p)
+	h.Context.Config.ReportsFile = path
+	h.Initialize()
+	assert.Empty(t, p.Reports())
+	h.AssertEvent("warning", "could not be loaded")
+}
+
+func TestReportsConfigure(t *testing.T) {
+	h, p := start(t, time.Now())
+	require.NoError(t, p.Configure(map[string]interface{}{"check_interval_seconds": 0}))
+	assert.Equal(t, heimdall.StatusRunning, p.Status())
+	assert.Equal(t, 0.0, p.Config()["check_interval_seconds"])
+	assert.Error(t, p.Configure(map[string]interface{}{"unknown": 1}))
+	assert.Error(t, p.Configure(map[string]interface{}{"webhook_timeout_seconds": "fast"}))
+	assert.True(t, strings.HasSuffix(p.Config()["reports_file"].(string), "reports.json"))
+	h.AssertEvent("info", "configuration updated")
+}
@@ -76,6 +76,7 @@ export const Bifrost: React.FC<BifrostProps> = ({
   const scrollRef = useRef<HTMLDivElement>(null);
   const inputRef = useRef<HTMLTextAreaElement>(null);
   const abortControllerRef = useRef<AbortController | null>(null);
+  const lastRequestIdRef = useRef<string | null>(null); // For /trace
 
   // Auto-scroll to bottom when messages change
   const messagesLength = messages.length;
@@ -150,8 +151,59 @@ export const Bifrost: React.FC<BifrostProps> = ({
     }
   };
 
+  // Show the trace of a request (needs NORNICDB_HEIMDALL_TRACE=true)
+  const showTrace = async (requestId: string) => {
+    const show = (content: string) => setMessages(prev => [...prev, {
+      id: crypto.randomUUID(),
+      role: 'system',
+      content,
+      timestamp: new Date()
+    }]);
+    try {
+      const res = await fetch(`/api/bifrost/traces/${encodeURIComponent(requestId)}`);
+      if (!res.ok) {
+        show(`⚠️ ${(await res.text()).trim() || `HTTP ${res.status}`}`);
+        return;
+      }
+      const trace = await res.json();
+      const lines = [`Trace ${trace.request_id} (${trace.duration_ms?.toFixed(1) ?? '?'} ms)`];
+      if (trace.persona) lines.push(`Persona: ${trace.persona}`);
+      if (trace.prompt) lines.push(`Prompt: …${trace.prompt.slice(-300)}`);
+      if (trace.output) lines.push(`SLM output${trace.grammar ? ' (grammar)' : ''}: ${trace.output}`);
+      if (trace.action) lines.push(`Parsed action: ${JSON.stringify(trace.action)}`);
+      for (const step of trace.steps ?? []) {
+        const source = step.source ? ` ${step.source}` : '';
+        const action = step.action ? ` [${step.action}]` : '';
+        const changes = (step.changes ?? []).map((c: any) => ` ${c.param}: ${JSON.stringify(c.old)} → ${JSON.stringify(c.new)}`).join(',');
+        lines.push(`  • ${step.phase}${source}${action}: ${step.detail ?? ''}${changes}`);
+      }
+      if (trace.params) lines.push(`Executed with: ${JSON.stringify(trace.params)}`);
+      if (trace.result) lines.push(`Result: ${trace.result.success ? '✓' : '✗'} ${trace.result.message ?? ''}`);
+      if (trace.response) lines.push(`Response: ${trace.response}`);
+      if (trace.error) lines.push(`Error: ${trace.error}`);
+      show(lines.join('\n'));
+    } catch (err: any) {
+      show(`❌ Error: ${err.message}`);
+    }
+  };
+
   const handleBuiltInCommand = (cmd: string): boolean => {
     const command = cmd.toLowerCase().trim();
+
+    if (command === '/trace' || command.startsWith('/trace ')) {
+      const requestId = cmd.trim().slice('/trace'.length).trim() || lastRequestIdRef.current;
+      if (!requestId) {
+        setMessages(prev => [...prev, {
+          id: crypto.randomUUID(),
+          role: 'system',
+          content: 'No request to trace yet. Usage: /trace [request id]',
+          timestamp: new Date()
+        }]);
+      } else {
+        showTrace(requestId);
+      }
+      return true;
+    }
     
     if (command === '/help') {
       setMessages(prev => [...prev, {
@@ -163,7 +215,8 @@ export const Bifrost: React.FC<BifrostProps> = ({
   /health   - Check database health
   /stats    - Get graph statistics
   /status   - Show connection status
-  /model    - Show current model`,
+  /model    - Show current model
+  /trace    - Explain the last answer (or /trace <request id>)`,
         timestamp: new Date()
       }]);
       return true;
@@ -306,7 +359,22 @@ export const Bifrost: React.FC<BifrostProps> = ({
             try {
               const parsed = JSON.parse(data);
               const delta = parsed.choices?.[0]?.delta;
-              
+              if (parsed.id) lastRequestIdRef.current = parsed.id;
+
+              // Stream state transitions (action detected / running / complete)
+              const status = parsed.heimdall;
+              if (status?.state) {
+                const label = status.state === 'action_detected' ? '⚙ Preparing action…'
+                  : status.state === 'running_action' ? `⚙ Running ${status.action}…`
+                  : '';
+                setMessages(prev => prev.map(m =>
+                  m.id === assistantId
+                    ? { ...m, content: fullContent || label }
+                    : m
+                ));
+                continue;
+              }
+
               // Check if this is a Heimdall notification (inline with stream)
               if (delta?.role === 'heimdall' && delta?.content) {
                 // Insert Heimdall message before the current assistant message